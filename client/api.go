@@ -0,0 +1,207 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/akutz/goof"
+	apitypes "github.com/emccode/libstorage/api/types"
+)
+
+// Volumes returns a page of the volumes of the configured service, ordered
+// by their IDs, or all of them if opts is nil. The volumes' attachments are
+// included if attachments is set.
+func (c *Client) Volumes(
+	ctx context.Context,
+	attachments bool,
+	opts *ListOptions) ([]*apitypes.Volume, error) {
+
+	vols := []*apitypes.Volume{}
+	if err := c.get(ctx, "/r/volumes",
+		opts.values(attachmentsQuery(attachments)), &vols); err != nil {
+		return nil, err
+	}
+	return vols, nil
+}
+
+// Volume returns the volume with the provided ID.
+func (c *Client) Volume(
+	ctx context.Context,
+	id string,
+	attachments bool) (*apitypes.Volume, error) {
+
+	vol := &apitypes.Volume{}
+	if err := c.get(ctx, "/r/volumes/"+url.PathEscape(id),
+		attachmentsQuery(attachments), vol); err != nil {
+		return nil, err
+	}
+	return vol, nil
+}
+
+func attachmentsQuery(attachments bool) url.Values {
+	if !attachments {
+		return nil
+	}
+	return url.Values{"attachments": {"true"}}
+}
+
+// Snapshots returns a page of the snapshots of the configured service,
+// ordered by their IDs, or all of them if opts is nil.
+func (c *Client) Snapshots(
+	ctx context.Context,
+	opts *ListOptions) ([]*apitypes.Snapshot, error) {
+
+	snaps := []*apitypes.Snapshot{}
+	if err := c.get(ctx, "/r/snapshots", opts.values(nil), &snaps); err != nil {
+		return nil, err
+	}
+	return snaps, nil
+}
+
+// Services returns the storage services hosted by the server, by name.
+func (c *Client) Services(
+	ctx context.Context) (map[string]*apitypes.ServiceInfo, error) {
+
+	svcs := map[string]*apitypes.ServiceInfo{}
+	if err := c.get(ctx, "/r/services", nil, &svcs); err != nil {
+		return nil, err
+	}
+	return svcs, nil
+}
+
+// ServiceCapacity returns the capacity of a service's storage.
+func (c *Client) ServiceCapacity(
+	ctx context.Context, name string) ([]*Capacity, error) {
+
+	caps := []*Capacity{}
+	if err := c.get(ctx, "/services/"+url.PathEscape(name)+"/capacity",
+		nil, &caps); err != nil {
+		return nil, err
+	}
+	return caps, nil
+}
+
+// Attachments returns the volumes attached to, or mounted on, each node, or
+// only on the node with the provided instance ID or hostname if it is not
+// empty.
+func (c *Client) Attachments(
+	ctx context.Context, node string) ([]*NodeAttachments, error) {
+
+	var q url.Values
+	if node != "" {
+		q = url.Values{"node": {node}}
+	}
+	nas := []*NodeAttachments{}
+	if err := c.get(ctx, "/r/attachments", q, &nas); err != nil {
+		return nil, err
+	}
+	return nas, nil
+}
+
+// Groups returns the groups of volumes.
+func (c *Client) Groups(ctx context.Context) ([]*Group, error) {
+	all := []*Group{}
+	if err := c.get(ctx, "/r/groups", nil, &all); err != nil {
+		return nil, err
+	}
+	return all, nil
+}
+
+// Group returns the group of volumes with the provided name.
+func (c *Client) Group(ctx context.Context, name string) (*Group, error) {
+	g := &Group{}
+	if err := c.get(
+		ctx, "/r/groups/"+url.PathEscape(name), nil, g); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// Events returns a page of the retained events, ordered by their IDs, or all
+// of them if opts is nil.
+func (c *Client) Events(
+	ctx context.Context, opts *ListOptions) ([]*Event, error) {
+
+	all := []*Event{}
+	if err := c.get(ctx, "/r/events", opts.values(nil), &all); err != nil {
+		return nil, err
+	}
+	return all, nil
+}
+
+// Tasks returns a page of the tasks, ordered by their IDs, or all of them if
+// opts is nil.
+func (c *Client) Tasks(
+	ctx context.Context, opts *ListOptions) ([]*Task, error) {
+
+	all := []*Task{}
+	if err := c.get(ctx, "/r/tasks", opts.values(nil), &all); err != nil {
+		return nil, err
+	}
+	return all, nil
+}
+
+// Task returns the task with the provided ID.
+func (c *Client) Task(ctx context.Context, id int64) (*Task, error) {
+	t := &Task{}
+	if err := c.get(
+		ctx, "/r/tasks/"+strconv.FormatInt(id, 10), nil, t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// SubmitTask submits an operation to be run as a task, ex. the operation
+// volume.copy with the params volumeID and name, and returns the queued
+// task.
+func (c *Client) SubmitTask(
+	ctx context.Context,
+	operation string,
+	params map[string]string) (*Task, error) {
+
+	buf, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+	t := &Task{}
+	if err := c.do(ctx, "POST", "/r/tasks", url.Values{
+		"operation": {operation},
+		"params":    {string(buf)},
+	}, t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// WaitTask polls the task with the provided ID at the provided interval
+// until it completes or the context is done. A task that failed is returned
+// with an error of its message.
+func (c *Client) WaitTask(
+	ctx context.Context,
+	id int64,
+	interval time.Duration) (*Task, error) {
+
+	if interval <= 0 {
+		interval = time.Second
+	}
+	for {
+		t, err := c.Task(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if t.State == TaskFailed {
+			return t, goof.WithField("taskID", id, t.Error)
+		}
+		if t.IsDone() {
+			return t, nil
+		}
+		select {
+		case <-ctx.Done():
+			return t, ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
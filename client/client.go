@@ -0,0 +1,327 @@
+// Package client is a client of the REX-Ray admin API for Go programs that
+// manage a REX-Ray service's volumes and tasks without importing the daemon.
+// Requests are bound to contexts, requests that fail transiently are retried
+// when it is safe to, and the errors the API returns carry the codes of
+// package errcodes.
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/akutz/goof"
+)
+
+const (
+	// DefaultHost is the address of the admin API of a REX-Ray service in a
+	// default installation.
+	DefaultHost = "unix:///var/run/rexray/server.sock"
+
+	defaultTimeout    = 30 * time.Second
+	defaultMaxRetries = 3
+	defaultRetryWait  = 500 * time.Millisecond
+)
+
+// Config configures a client.
+type Config struct {
+
+	// Host is the address of the admin API, ex.
+	// unix:///var/run/rexray/server.sock, tcp://127.0.0.1:7980, or
+	// https://rexray.example.com:7980. Defaults to DefaultHost.
+	Host string
+
+	// Token is an API token, which is sent as a bearer token.
+	Token string
+
+	// TLS is the TLS configuration with which a tcp:// or https:// host is
+	// connected to. A tcp:// host is connected to with TLS if it is set.
+	TLS *tls.Config
+
+	// Timeout is the duration after which an attempt of a request is
+	// abandoned. Defaults to 30s.
+	Timeout time.Duration
+
+	// MaxRetries is the number of times a request that failed transiently is
+	// retried. Defaults to 3, and a negative value disables retries.
+	MaxRetries int
+
+	// RetryWait is the duration before the first retry, which is doubled
+	// before each subsequent retry. A Retry-After header takes precedence.
+	// Defaults to 500ms.
+	RetryWait time.Duration
+}
+
+// Client calls the admin API of a REX-Ray service. A client is safe for
+// concurrent use.
+type Client struct {
+	endpoint   string
+	token      string
+	client     *http.Client
+	maxRetries int
+	retryWait  time.Duration
+}
+
+// New returns a client with the provided configuration, or with the default
+// configuration if it is nil.
+func New(config *Config) (*Client, error) {
+	if config == nil {
+		config = &Config{}
+	}
+	host := config.Host
+	if host == "" {
+		host = DefaultHost
+	}
+
+	c := &Client{
+		token:      config.Token,
+		maxRetries: config.MaxRetries,
+		retryWait:  config.RetryWait,
+	}
+	if c.maxRetries == 0 {
+		c.maxRetries = defaultMaxRetries
+	}
+	if c.retryWait <= 0 {
+		c.retryWait = defaultRetryWait
+	}
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+
+	transport := &http.Transport{TLSClientConfig: config.TLS}
+	i := strings.Index(host, "://")
+	if i < 0 {
+		return nil, goof.WithField("host", host, "invalid host")
+	}
+	switch proto, addr := host[:i], host[i+3:]; proto {
+	case "unix":
+		transport.Dial = func(string, string) (net.Conn, error) {
+			return net.Dial("unix", addr)
+		}
+		c.endpoint = "http://rexray"
+	case "tcp", "http":
+		c.endpoint = "http://" + addr
+		if config.TLS != nil {
+			c.endpoint = "https://" + addr
+		}
+	case "https":
+		c.endpoint = "https://" + addr
+	default:
+		return nil, goof.WithField("host", host, "invalid host")
+	}
+
+	c.client = &http.Client{Transport: transport, Timeout: timeout}
+	return c, nil
+}
+
+// ListOptions select a page of a list. All of the items are listed if
+// Limit is zero.
+type ListOptions struct {
+
+	// Offset is the number of items that precede the page.
+	Offset int
+
+	// Limit is the greatest number of items in the page.
+	Limit int
+}
+
+func (o *ListOptions) values(v url.Values) url.Values {
+	if v == nil {
+		v = url.Values{}
+	}
+	if o == nil {
+		return v
+	}
+	if o.Offset > 0 {
+		v.Set("offset", strconv.Itoa(o.Offset))
+	}
+	if o.Limit > 0 {
+		v.Set("limit", strconv.Itoa(o.Limit))
+	}
+	return v
+}
+
+// Pages calls list with the options of successive pages of the provided
+// size, beginning with the first, until list returns fewer items than the
+// size or an error, or the context is done.
+func Pages(
+	ctx context.Context,
+	size int,
+	list func(opts *ListOptions) (int, error)) error {
+
+	if size <= 0 {
+		return goof.WithField("size", size, "invalid page size")
+	}
+	for offset := 0; ; offset += size {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		n, err := list(&ListOptions{Offset: offset, Limit: size})
+		if err != nil {
+			return err
+		}
+		if n < size {
+			return nil
+		}
+	}
+}
+
+// get calls the API with a GET request and decodes the response into
+// result.
+func (c *Client) get(
+	ctx context.Context,
+	path string,
+	query url.Values,
+	result interface{}) error {
+
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	return c.do(ctx, "GET", path, nil, result)
+}
+
+// do calls the API and decodes the response into result, retrying the
+// request while it fails transiently. A request that is not idempotent is
+// retried only if it was not handled, such as when the connection was
+// refused or the request was rate limited.
+func (c *Client) do(
+	ctx context.Context,
+	method, path string,
+	form url.Values,
+	result interface{}) error {
+
+	idempotent := method == "GET" || method == "PUT" || method == "DELETE"
+	wait := c.retryWait
+
+	for attempt := 0; ; attempt++ {
+		retry, after, err := c.try(ctx, method, path, form, result)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if attempt >= c.maxRetries || retry == retryNever ||
+			retry == retryIdempotent && !idempotent {
+			return err
+		}
+		if after > 0 {
+			wait = after
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+		wait *= 2
+	}
+}
+
+// retryable describes whether a failed request may be retried.
+type retryable int
+
+const (
+	retryNever retryable = iota
+
+	// retryIdempotent indicates the request may have been handled, so only
+	// idempotent requests are retried.
+	retryIdempotent
+
+	// retryAlways indicates the request was not handled.
+	retryAlways
+)
+
+// try makes one attempt of a request. It returns whether the request may be
+// retried if it failed, and the duration the API asked the client to wait
+// before retrying, if any.
+func (c *Client) try(
+	ctx context.Context,
+	method, path string,
+	form url.Values,
+	result interface{}) (retryable, time.Duration, error) {
+
+	var body *strings.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	} else {
+		body = strings.NewReader("")
+	}
+	req, err := http.NewRequest(method, c.endpoint+path, body)
+	if err != nil {
+		return retryNever, 0, err
+	}
+	req = req.WithContext(ctx)
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		retry := retryIdempotent
+		if isDialError(err) {
+			retry = retryAlways
+		}
+		return retry, 0, goof.WithFieldsE(goof.Fields{
+			"method": method,
+			"path":   path,
+		}, "error calling rexray", err)
+	}
+	defer res.Body.Close()
+
+	buf, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return retryIdempotent, 0, err
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		e := newError(res.StatusCode, buf)
+		switch res.StatusCode {
+		case http.StatusTooManyRequests:
+			return retryAlways, retryAfter(res), e
+		case http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout:
+			return retryIdempotent, retryAfter(res), e
+		}
+		return retryNever, 0, e
+	}
+	if result == nil || len(buf) == 0 {
+		return retryNever, 0, nil
+	}
+	if err := json.Unmarshal(buf, result); err != nil {
+		return retryNever, 0, goof.WithFieldE(
+			"path", path, "invalid response", err)
+	}
+	return retryNever, 0, nil
+}
+
+// isDialError returns a flag indicating whether an error is that of a
+// connection that could not be established, in which case no request was
+// sent.
+func isDialError(err error) bool {
+	if ue, ok := err.(*url.Error); ok {
+		err = ue.Err
+	}
+	oe, ok := err.(*net.OpError)
+	return ok && oe.Op == "dial"
+}
+
+// retryAfter returns the duration of a response's Retry-After header in
+// seconds, or zero if it has none.
+func retryAfter(res *http.Response) time.Duration {
+	secs, err := strconv.Atoi(res.Header.Get("Retry-After"))
+	if err != nil || secs <= 0 {
+		return 0
+	}
+	return time.Duration(secs) * time.Second
+}
@@ -0,0 +1,106 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/emccode/rexray/core/errcodes"
+)
+
+func newTestClient(t *testing.T, h http.HandlerFunc) (*Client, func()) {
+	s := httptest.NewServer(h)
+	c, err := New(&Config{
+		Host:      "tcp://" + strings.TrimPrefix(s.URL, "http://"),
+		RetryWait: time.Millisecond,
+	})
+	if err != nil {
+		s.Close()
+		t.Fatal(err)
+	}
+	return c, s.Close
+}
+
+func TestRetries(t *testing.T) {
+	var calls int32
+	c, done := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"id": 7, "state": "running"}`))
+	})
+	defer done()
+
+	task, err := c.Task(context.Background(), 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task.ID != 7 || task.State != TaskRunning || calls != 3 {
+		t.Fatalf("task=%+v calls=%d", task, calls)
+	}
+
+	// a submission may have been handled, so it is not retried
+	atomic.StoreInt32(&calls, 0)
+	if _, err := c.SubmitTask(
+		context.Background(), "volume.copy", nil); err == nil {
+		t.Fatal("submitted")
+	}
+	if calls != 1 {
+		t.Fatalf("submitted %d times", calls)
+	}
+}
+
+func TestError(t *testing.T) {
+	c, done := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{
+			"message": "Error servicing request",
+			"code": "VolumeNotFound",
+			"error": {"message": "volume not found", "volumeID": "vol-1"}
+		}`))
+	})
+	defer done()
+
+	_, err := c.Volume(context.Background(), "vol-1", false)
+	if !IsNotFound(err) || !IsCode(err, errcodes.VolumeNotFound) ||
+		errcodes.Of(err) != errcodes.VolumeNotFound {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err.Error() != "Error servicing request: volume not found" {
+		t.Fatalf("unexpected message %q", err.Error())
+	}
+}
+
+func TestPages(t *testing.T) {
+	c, done := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		offset, _ := strconv.Atoi(r.FormValue("offset"))
+		limit, _ := strconv.Atoi(r.FormValue("limit"))
+		ids := []string{}
+		for i := offset; i < offset+limit && i < 5; i++ {
+			ids = append(ids, `{"id": `+strconv.Itoa(i+1)+`}`)
+		}
+		w.Write([]byte("[" + strings.Join(ids, ",") + "]"))
+	})
+	defer done()
+
+	ctx := context.Background()
+	var ids []int64
+	if err := Pages(ctx, 2, func(opts *ListOptions) (int, error) {
+		page, err := c.Events(ctx, opts)
+		for _, e := range page {
+			ids = append(ids, e.ID)
+		}
+		return len(page), err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 5 || ids[0] != 1 || ids[4] != 5 {
+		t.Fatalf("unexpected events %v", ids)
+	}
+}
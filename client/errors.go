@@ -0,0 +1,118 @@
+package client
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/emccode/rexray/core/errcodes"
+)
+
+// Error is an error returned by the admin API.
+type Error struct {
+
+	// StatusCode is the HTTP status of the response.
+	StatusCode int
+
+	// ErrorCode classifies the error. It is Unknown if the response did not
+	// include a code.
+	ErrorCode errcodes.Code
+
+	// Message is the message of the response, ex. "Error servicing request".
+	Message string
+
+	// Reason is the message of the error that caused the request to fail,
+	// if the response included one.
+	Reason string
+
+	// Details is the JSON of the error that caused the request to fail,
+	// which includes the error's fields, if the response included one.
+	Details json.RawMessage
+}
+
+func newError(status int, buf []byte) *Error {
+	e := &Error{StatusCode: status}
+	res := &struct {
+		Message string          `json:"message"`
+		Code    errcodes.Code   `json:"code"`
+		Error   json.RawMessage `json:"error"`
+	}{}
+	if json.Unmarshal(buf, res) != nil || res.Message == "" {
+		e.ErrorCode = errcodes.Unknown
+		e.Message = strings.TrimSpace(string(buf))
+		if e.Message == "" {
+			e.Message = http.StatusText(status)
+		}
+		return e
+	}
+
+	e.Message, e.ErrorCode = res.Message, res.Code
+	if e.ErrorCode == "" {
+		e.ErrorCode = errcodes.Unknown
+	}
+	if len(res.Error) > 0 && string(res.Error) != "null" {
+		e.Details = res.Error
+		e.Reason = reason(res.Error)
+	}
+	return e
+}
+
+// reason returns the message of a marshaled error, which is a string or an
+// object with the message as one of its keys.
+func reason(buf json.RawMessage) string {
+	var s string
+	if json.Unmarshal(buf, &s) == nil {
+		return s
+	}
+	m := map[string]interface{}{}
+	if json.Unmarshal(buf, &m) != nil {
+		return ""
+	}
+	for _, k := range []string{"message", "msg", "error"} {
+		if s, ok := m[k].(string); ok {
+			return s
+		}
+	}
+	return ""
+}
+
+func (e *Error) Error() string {
+	if e.Reason != "" {
+		return e.Message + ": " + e.Reason
+	}
+	return e.Message
+}
+
+// Code returns the code of the error, so that errcodes.Of classifies the
+// error by its code.
+func (e *Error) Code() errcodes.Code {
+	return e.ErrorCode
+}
+
+// Status returns the HTTP status of the response.
+func (e *Error) Status() int {
+	return e.StatusCode
+}
+
+// IsCode returns a flag indicating whether an error is an error of the admin
+// API with the provided code.
+func IsCode(err error, code errcodes.Code) bool {
+	e, ok := err.(*Error)
+	return ok && e.ErrorCode == code
+}
+
+// IsNotFound returns a flag indicating whether an error is an error of the
+// admin API for an object that does not exist.
+func IsNotFound(err error) bool {
+	e, ok := err.(*Error)
+	if !ok {
+		return false
+	}
+	switch e.ErrorCode {
+	case errcodes.NotFound,
+		errcodes.VolumeNotFound,
+		errcodes.SnapshotNotFound:
+		return true
+	}
+	return e.StatusCode == http.StatusNotFound
+}
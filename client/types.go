@@ -0,0 +1,173 @@
+package client
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// The types in this file are those of the admin API's responses. They are
+// declared here rather than imported from the packages that define them,
+// which depend on the daemon. Volumes, snapshots, and services are
+// libStorage's types.
+
+// TaskState is the state of a task.
+type TaskState string
+
+const (
+	// TaskQueued indicates a task has been submitted but has not started.
+	TaskQueued TaskState = "queued"
+
+	// TaskRunning indicates a task is in progress.
+	TaskRunning TaskState = "running"
+
+	// TaskSuccess indicates a task completed successfully.
+	TaskSuccess TaskState = "success"
+
+	// TaskFailed indicates a task completed with an error.
+	TaskFailed TaskState = "failed"
+)
+
+// Task is a long operation that the service runs in the background.
+type Task struct {
+
+	// ID is the task's unique identifier.
+	ID int64 `json:"id"`
+
+	// Operation is the name of the operation the task performs.
+	Operation string `json:"operation"`
+
+	// Params are the operation's parameters.
+	Params map[string]string `json:"params,omitempty"`
+
+	// State is the task's state.
+	State TaskState `json:"state"`
+
+	// Progress is the percentage of the task that is complete.
+	Progress int `json:"progress"`
+
+	// Result is the result of the operation once it completes successfully.
+	Result json.RawMessage `json:"result,omitempty"`
+
+	// Error is the error that caused the task to fail.
+	Error string `json:"error,omitempty"`
+
+	// Resumed is the number of times the task was resumed after it was
+	// interrupted.
+	Resumed int `json:"resumed,omitempty"`
+
+	// QueueTime is the time at which the task was submitted.
+	QueueTime time.Time `json:"queueTime"`
+
+	// StartTime is the time at which the task started.
+	StartTime time.Time `json:"startTime,omitempty"`
+
+	// CompleteTime is the time at which the task completed.
+	CompleteTime time.Time `json:"completeTime,omitempty"`
+}
+
+// IsDone returns a flag indicating whether the task completed.
+func (t *Task) IsDone() bool {
+	return t.State == TaskSuccess || t.State == TaskFailed
+}
+
+// Event is a notable occurrence, such as a volume that was remounted.
+type Event struct {
+
+	// ID is the sequence number of the event.
+	ID int64 `json:"id"`
+
+	// Time is the time at which the event occurred.
+	Time time.Time `json:"time"`
+
+	// Type identifies the kind of event, ex. fuse.remounted.
+	Type string `json:"type"`
+
+	// VolumeID is the ID of the volume to which the event relates, if any.
+	VolumeID string `json:"volumeID,omitempty"`
+
+	// Message describes the event.
+	Message string `json:"message"`
+
+	// Fields are additional details about the event.
+	Fields map[string]string `json:"fields,omitempty"`
+}
+
+// Attachment is a volume attached to, or mounted on, a node.
+type Attachment struct {
+
+	// VolumeID is the ID of the volume.
+	VolumeID string `json:"volumeID"`
+
+	// VolumeName is the name of the volume.
+	VolumeName string `json:"volumeName,omitempty"`
+
+	// DeviceName is the device of the volume on the node.
+	DeviceName string `json:"deviceName,omitempty"`
+
+	// MountPoint is the path at which the volume is mounted.
+	MountPoint string `json:"mountPoint,omitempty"`
+
+	// Attached is true if the storage platform reports the volume as
+	// attached to the node.
+	Attached bool `json:"attached"`
+
+	// Mounted is true if the node's agent reported the volume as mounted.
+	Mounted bool `json:"mounted"`
+}
+
+// NodeAttachments are the volumes attached to, or mounted on, a node.
+type NodeAttachments struct {
+
+	// InstanceID is the ID of the node's instance.
+	InstanceID string `json:"instanceID"`
+
+	// Hostname is the name of the host, which is empty if the node's agent
+	// has never reported.
+	Hostname string `json:"hostname,omitempty"`
+
+	// Heartbeat is the time at which the node's agent last reported.
+	Heartbeat time.Time `json:"heartbeat,omitempty"`
+
+	// Attachments are the node's volumes, ordered by their IDs.
+	Attachments []*Attachment `json:"attachments"`
+}
+
+// Group is an ordered group of volumes.
+type Group struct {
+
+	// Name is the group's name.
+	Name string `json:"name"`
+
+	// Volumes are the names of the group's volumes in the order in which
+	// they are mounted.
+	Volumes []string `json:"volumes"`
+
+	// Created is the time at which the group was created.
+	Created time.Time `json:"created"`
+}
+
+// Capacity is the capacity of a segment of a service's storage.
+type Capacity struct {
+
+	// Service is the name of the service.
+	Service string `json:"service"`
+
+	// Driver is the name of the service's storage driver.
+	Driver string `json:"driver"`
+
+	// TotalBytes is the size of the storage. It is zero if the storage is
+	// unlimited or its size is unknown.
+	TotalBytes int64 `json:"totalBytes"`
+
+	// AvailableBytes is the size of the storage that may still be
+	// provisioned. It is zero if the storage is unlimited or its size is
+	// unknown.
+	AvailableBytes int64 `json:"availableBytes"`
+
+	// Unlimited indicates the storage is not limited by a pool.
+	Unlimited bool `json:"unlimited,omitempty"`
+
+	// Topology is the segment of the storage the capacity describes, ex.
+	// its zone and pool.
+	Topology map[string]string `json:"topology,omitempty"`
+}
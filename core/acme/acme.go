@@ -0,0 +1,461 @@
+// Package acme obtains the TLS certificate of the admin API and the
+// libStorage endpoints from an ACME certificate authority, such as Let's
+// Encrypt, and renews it before it expires. The certificate authority
+// validates the controller's control of its domains with HTTP-01 challenges,
+// which are answered by a temporary HTTP server, or with DNS-01 challenges,
+// which are answered by creating TXT records with a DNS provider.
+package acme
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	apitypes "github.com/emccode/libstorage/api/types"
+	"github.com/go-acme/lego/certcrypto"
+	"github.com/go-acme/lego/certificate"
+	"github.com/go-acme/lego/challenge/http01"
+	"github.com/go-acme/lego/lego"
+	"github.com/go-acme/lego/registration"
+
+	"github.com/emccode/rexray/core/kms"
+)
+
+const (
+	// ChallengeHTTP answers HTTP-01 challenges with a temporary HTTP server.
+	ChallengeHTTP = "http-01"
+
+	// ChallengeDNS answers DNS-01 challenges with a DNS provider.
+	ChallengeDNS = "dns-01"
+
+	// LetsEncrypt is the directory URL of Let's Encrypt's production
+	// certificate authority.
+	LetsEncrypt = "https://acme-v02.api.letsencrypt.org/directory"
+
+	accountKeyFile = "account.key"
+	accountFile    = "account.json"
+	certFile       = "cert.pem"
+	keyFile        = "key.pem"
+)
+
+func init() {
+	r := gofig.NewRegistration("ACME")
+	r.Key(gofig.Bool, "", false,
+		"Obtain the admin API's certificate from an ACME certificate authority",
+		"rexray.acme.enabled")
+	r.Key(gofig.Bool, "", false,
+		"Serve the libStorage endpoints with the ACME certificate",
+		"rexray.acme.controller")
+	r.Key(gofig.String, "", "",
+		"The domain names of the certificate, separated by spaces",
+		"rexray.acme.domains")
+	r.Key(gofig.String, "", "",
+		"The contact email address of the ACME account",
+		"rexray.acme.email")
+	r.Key(gofig.String, "", LetsEncrypt,
+		"The directory URL of the ACME certificate authority",
+		"rexray.acme.directory")
+	r.Key(gofig.String, "", ChallengeHTTP,
+		"The challenge with which domains are validated: http-01 or dns-01",
+		"rexray.acme.challenge")
+	r.Key(gofig.String, "", ":80",
+		"The address at which HTTP-01 challenges are answered",
+		"rexray.acme.http.address")
+	r.Key(gofig.String, "", "",
+		"The DNS provider with which DNS-01 challenges are answered",
+		"rexray.acme.dns.provider")
+	r.Key(gofig.String, "", "",
+		"An executable that creates and removes the TXT records of "+
+			"DNS-01 challenges",
+		"rexray.acme.dns.hook")
+	r.Key(gofig.String, "", "720h",
+		"How long before the certificate expires to renew it",
+		"rexray.acme.renewBefore")
+	r.Key(gofig.String, "", "",
+		"The directory that holds the account and certificate; "+
+			"defaults to acme in the lib directory",
+		"rexray.acme.path")
+	gofig.Register(r)
+}
+
+// Enabled returns a flag indicating whether the admin API's certificate is
+// obtained from an ACME certificate authority.
+func Enabled(config gofig.Config) bool {
+	return config.GetBool("rexray.acme.enabled")
+}
+
+// ControllerEnabled returns a flag indicating whether the libStorage
+// endpoints are served with the certificate obtained from an ACME
+// certificate authority.
+func ControllerEnabled(config gofig.Config) bool {
+	return config.GetBool("rexray.acme.controller")
+}
+
+// Manager obtains and renews a certificate, and serves it to TLS clients.
+type Manager struct {
+	ctx         apitypes.Context
+	config      gofig.Config
+	path        string
+	domains     []string
+	renewBefore time.Duration
+
+	certRwl sync.RWMutex
+	cert    *tls.Certificate
+	leaf    *x509.Certificate
+}
+
+// New returns a new certificate manager. The certificate previously obtained
+// for the configured domains, if any, is loaded but is not renewed until
+// Renew is called. The account and certificate are kept in rexray.acme.path,
+// or in the provided directory if the property is not set.
+func New(
+	ctx apitypes.Context,
+	config gofig.Config,
+	dir string) (*Manager, error) {
+
+	domains := strings.Fields(config.GetString("rexray.acme.domains"))
+	if len(domains) == 0 {
+		return nil, goof.New("acme requires rexray.acme.domains")
+	}
+	if config.GetString("rexray.acme.email") == "" {
+		return nil, goof.New("acme requires rexray.acme.email")
+	}
+	switch c := config.GetString("rexray.acme.challenge"); c {
+	case ChallengeHTTP:
+	case ChallengeDNS:
+		if _, err := dnsProvider(config); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, goof.WithField("challenge", c, "invalid acme challenge")
+	}
+	renewBefore, err := time.ParseDuration(
+		config.GetString("rexray.acme.renewBefore"))
+	if err != nil {
+		return nil, goof.WithFieldE("key", "rexray.acme.renewBefore",
+			"invalid duration", err)
+	}
+
+	path := config.GetString("rexray.acme.path")
+	if path == "" {
+		path = dir
+	}
+	if err := os.MkdirAll(path, 0700); err != nil {
+		return nil, err
+	}
+
+	m := &Manager{
+		ctx:         ctx,
+		config:      config,
+		path:        path,
+		domains:     domains,
+		renewBefore: renewBefore,
+	}
+	if err := m.load(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// TLSConfig returns a TLS configuration that serves the current certificate,
+// so that a renewed certificate is served without restarting the listener.
+func (m *Manager) TLSConfig() *tls.Config {
+	return &tls.Config{GetCertificate: m.getCertificate}
+}
+
+func (m *Manager) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	m.certRwl.RLock()
+	defer m.certRwl.RUnlock()
+	if m.cert == nil {
+		return nil, goof.New("no acme certificate")
+	}
+	return m.cert, nil
+}
+
+// Ready returns a flag indicating whether the manager has an unexpired
+// certificate to serve.
+func (m *Manager) Ready() bool {
+	m.certRwl.RLock()
+	defer m.certRwl.RUnlock()
+	return m.leaf != nil && time.Now().Before(m.leaf.NotAfter)
+}
+
+// Renew obtains a new certificate if there is none, if its domains are not
+// those configured, or if it expires within rexray.acme.renewBefore. The
+// certificate is first loaded again, since the admin API's and the
+// libStorage endpoints' managers share it.
+func (m *Manager) Renew() error {
+	if !m.due() {
+		return nil
+	}
+	if err := m.load(); err != nil {
+		return err
+	}
+	if !m.due() {
+		return nil
+	}
+
+	certPEM, keyPEM, err := m.obtain()
+	if err != nil {
+		return goof.WithFieldE("domains", strings.Join(m.domains, " "),
+			"error obtaining certificate", err)
+	}
+	if err := m.set(certPEM, keyPEM); err != nil {
+		return err
+	}
+	if err := writeFile(filepath.Join(m.path, certFile), certPEM); err != nil {
+		return err
+	}
+	if err := m.writeSecret(filepath.Join(m.path, keyFile), keyPEM); err != nil {
+		return err
+	}
+
+	m.certRwl.RLock()
+	defer m.certRwl.RUnlock()
+	m.ctx.WithFields(map[string]interface{}{
+		"domains":  strings.Join(m.domains, " "),
+		"notAfter": m.leaf.NotAfter,
+	}).Info("obtained acme certificate")
+	return nil
+}
+
+func (m *Manager) due() bool {
+	m.certRwl.RLock()
+	defer m.certRwl.RUnlock()
+	return isDue(m.leaf, m.domains, m.renewBefore, time.Now())
+}
+
+// isDue returns a flag indicating whether a certificate must be obtained.
+func isDue(
+	leaf *x509.Certificate,
+	domains []string,
+	renewBefore time.Duration,
+	now time.Time) bool {
+
+	if leaf == nil || !sameDomains(leaf.DNSNames, domains) {
+		return true
+	}
+	return !now.Before(leaf.NotAfter.Add(-renewBefore))
+}
+
+func sameDomains(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	sa := append([]string{}, a...)
+	sb := append([]string{}, b...)
+	sort.Strings(sa)
+	sort.Strings(sb)
+	for i := range sa {
+		if !strings.EqualFold(sa[i], sb[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *Manager) load() error {
+	certPEM, err := ioutil.ReadFile(filepath.Join(m.path, certFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	keyPEM, err := kms.ReadFile(m.config, filepath.Join(m.path, keyFile))
+	if err != nil {
+		return err
+	}
+	return m.set(certPEM, keyPEM)
+}
+
+func (m *Manager) set(certPEM, keyPEM []byte) error {
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return goof.WithFieldE("path", m.path, "invalid acme certificate", err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return goof.WithFieldE("path", m.path, "invalid acme certificate", err)
+	}
+	m.certRwl.Lock()
+	defer m.certRwl.Unlock()
+	m.cert, m.leaf = &cert, leaf
+	return nil
+}
+
+// obtain registers the ACME account if it is not registered, answers the
+// challenges of the domains, and returns the issued certificate chain and
+// its private key.
+func (m *Manager) obtain() ([]byte, []byte, error) {
+	u, err := m.user()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	lc := lego.NewConfig(u)
+	lc.CADirURL = m.config.GetString("rexray.acme.directory")
+	lc.Certificate.KeyType = certcrypto.EC256
+	client, err := lego.NewClient(lc)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := m.setProvider(client); err != nil {
+		return nil, nil, err
+	}
+
+	if u.Registration == nil {
+		reg, err := client.Registration.Register(
+			registration.RegisterOptions{TermsOfServiceAgreed: true})
+		if err != nil {
+			return nil, nil, goof.WithFieldE("email", u.Email,
+				"error registering acme account", err)
+		}
+		u.Registration = reg
+		if err := m.saveUser(u); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	res, err := client.Certificate.Obtain(certificate.ObtainRequest{
+		Domains: m.domains,
+		Bundle:  true,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return res.Certificate, res.PrivateKey, nil
+}
+
+func (m *Manager) setProvider(client *lego.Client) error {
+	if m.config.GetString("rexray.acme.challenge") == ChallengeDNS {
+		p, err := dnsProvider(m.config)
+		if err != nil {
+			return err
+		}
+		return client.Challenge.SetDNS01Provider(p)
+	}
+	host, port, err := net.SplitHostPort(
+		m.config.GetString("rexray.acme.http.address"))
+	if err != nil {
+		return goof.WithFieldE("key", "rexray.acme.http.address",
+			"invalid address", err)
+	}
+	return client.Challenge.SetHTTP01Provider(
+		http01.NewProviderServer(host, port))
+}
+
+// user is the ACME account with which certificates are requested.
+type user struct {
+	Email        string                 `json:"email"`
+	Registration *registration.Resource `json:"registration,omitempty"`
+	key          crypto.PrivateKey
+}
+
+func (u *user) GetEmail() string {
+	return u.Email
+}
+
+func (u *user) GetRegistration() *registration.Resource {
+	return u.Registration
+}
+
+func (u *user) GetPrivateKey() crypto.PrivateKey {
+	return u.key
+}
+
+// user returns the ACME account, creating its key the first time it is
+// needed. The account is registered again if the configured email address
+// changed.
+func (m *Manager) user() (*user, error) {
+	key, err := m.accountKey()
+	if err != nil {
+		return nil, err
+	}
+	email := m.config.GetString("rexray.acme.email")
+	u := &user{key: key}
+
+	buf, err := ioutil.ReadFile(filepath.Join(m.path, accountFile))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(buf, u); err != nil {
+			return nil, goof.WithFieldE("path", m.path,
+				"invalid acme account", err)
+		}
+	}
+	if u.Email != email {
+		u.Email, u.Registration = email, nil
+	}
+	return u, nil
+}
+
+func (m *Manager) saveUser(u *user) error {
+	buf, err := json.MarshalIndent(u, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(filepath.Join(m.path, accountFile), buf)
+}
+
+func (m *Manager) accountKey() (crypto.PrivateKey, error) {
+	path := filepath.Join(m.path, accountKeyFile)
+	buf, err := kms.ReadFile(m.config, path)
+	if err == nil {
+		b, _ := pem.Decode(buf)
+		if b == nil {
+			return nil, goof.WithField("path", path, "invalid acme account key")
+		}
+		return x509.ParseECPrivateKey(b.Bytes)
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.writeSecret(path, pem.EncodeToMemory(
+		&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// writeSecret writes a private key, encrypted with the configured key
+// management service if there is one.
+func (m *Manager) writeSecret(path string, buf []byte) error {
+	if err := kms.WriteFile(m.config, path, buf); err != nil {
+		return goof.WithFieldE("path", path, "error writing acme file", err)
+	}
+	return nil
+}
+
+func writeFile(path string, buf []byte) error {
+	if err := ioutil.WriteFile(path, buf, 0600); err != nil {
+		return goof.WithFieldE("path", path, "error writing acme file", err)
+	}
+	return nil
+}
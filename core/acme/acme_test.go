@@ -0,0 +1,30 @@
+package acme
+
+import (
+	"crypto/x509"
+	"testing"
+	"time"
+)
+
+func TestIsDue(t *testing.T) {
+	now := time.Date(2017, 3, 1, 0, 0, 0, 0, time.UTC)
+	leaf := &x509.Certificate{
+		DNSNames: []string{"rexray.example.com", "api.example.com"},
+		NotAfter: now.Add(60 * 24 * time.Hour),
+	}
+	domains := []string{"API.example.com", "rexray.example.com"}
+	renewBefore := 30 * 24 * time.Hour
+
+	if !isDue(nil, domains, renewBefore, now) {
+		t.Fatal("expected missing certificate to be due")
+	}
+	if isDue(leaf, domains, renewBefore, now) {
+		t.Fatal("expected certificate not to be due")
+	}
+	if !isDue(leaf, domains, renewBefore, now.Add(31*24*time.Hour)) {
+		t.Fatal("expected expiring certificate to be due")
+	}
+	if !isDue(leaf, domains[:1], renewBefore, now) {
+		t.Fatal("expected certificate for other domains to be due")
+	}
+}
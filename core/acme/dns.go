@@ -0,0 +1,96 @@
+package acme
+
+import (
+	"bytes"
+	"os/exec"
+	"strings"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	"github.com/go-acme/lego/challenge/dns01"
+	"github.com/go-acme/lego/providers/dns"
+)
+
+// HookProvider is the name of the DNS provider that runs the executable
+// named by rexray.acme.dns.hook.
+const HookProvider = "hook"
+
+// DNSProvider creates and removes the TXT records with which the DNS-01
+// challenges of a domain are answered.
+type DNSProvider interface {
+
+	// Present creates the TXT record of a challenge.
+	Present(domain, token, keyAuth string) error
+
+	// CleanUp removes the TXT record of a challenge.
+	CleanUp(domain, token, keyAuth string) error
+}
+
+// NewDNSProvider is a function that returns a new DNS provider.
+type NewDNSProvider func(config gofig.Config) (DNSProvider, error)
+
+var dnsProviders = map[string]NewDNSProvider{}
+
+func init() {
+	RegisterDNSProvider(HookProvider, newHookProvider)
+}
+
+// RegisterDNSProvider registers a DNS provider. The providers of the lego
+// library, such as route53 or cloudflare, are used by name without being
+// registered, and are configured with their environment variables.
+func RegisterDNSProvider(name string, ctor NewDNSProvider) {
+	dnsProviders[strings.ToLower(name)] = ctor
+}
+
+func dnsProvider(config gofig.Config) (DNSProvider, error) {
+	name := strings.ToLower(config.GetString("rexray.acme.dns.provider"))
+	if name == "" {
+		return nil, goof.New("dns-01 challenge requires rexray.acme.dns.provider")
+	}
+	if ctor, ok := dnsProviders[name]; ok {
+		return ctor(config)
+	}
+	p, err := dns.NewDNSChallengeProviderByName(name)
+	if err != nil {
+		return nil, goof.WithFieldE("provider", name,
+			"error creating dns provider", err)
+	}
+	return p, nil
+}
+
+// hookProvider runs an executable with the arguments present or cleanup, the
+// fully qualified name of the TXT record, ex. _acme-challenge.example.com.,
+// and the record's value.
+type hookProvider struct {
+	path string
+}
+
+func newHookProvider(config gofig.Config) (DNSProvider, error) {
+	path := config.GetString("rexray.acme.dns.hook")
+	if path == "" {
+		return nil, goof.New("hook dns provider requires rexray.acme.dns.hook")
+	}
+	return &hookProvider{path: path}, nil
+}
+
+func (h *hookProvider) Present(domain, token, keyAuth string) error {
+	return h.run("present", domain, keyAuth)
+}
+
+func (h *hookProvider) CleanUp(domain, token, keyAuth string) error {
+	return h.run("cleanup", domain, keyAuth)
+}
+
+func (h *hookProvider) run(action, domain, keyAuth string) error {
+	fqdn, value := dns01.GetRecord(domain, keyAuth)
+	out, err := exec.Command(h.path, action, fqdn, value).CombinedOutput()
+	if err != nil {
+		return goof.WithFieldsE(map[string]interface{}{
+			"path":   h.path,
+			"action": action,
+			"fqdn":   fqdn,
+			"output": string(bytes.TrimSpace(out)),
+		}, "dns hook failed", err)
+	}
+	return nil
+}
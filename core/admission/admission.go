@@ -0,0 +1,170 @@
+// Package admission admits the requests of the libStorage server's endpoints
+// according to the controller's policies, and serves the controller's state
+// to the hosts that report to it. The requests are admitted by the server
+// because its clients, whose local state and configuration may differ from
+// the controller's, cannot be trusted to enforce the policies themselves.
+package admission
+
+import (
+	"net/http"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/core/adoption"
+	"github.com/emccode/rexray/core/labels"
+	"github.com/emccode/rexray/core/maintenance"
+	"github.com/emccode/rexray/core/nodes"
+	"github.com/emccode/rexray/core/pins"
+	"github.com/emccode/rexray/core/policy"
+	"github.com/emccode/rexray/core/quota"
+	"github.com/emccode/rexray/core/ratelimit"
+	"github.com/emccode/rexray/core/state"
+	"github.com/emccode/rexray/core/tokens"
+)
+
+func init() {
+	r := gofig.NewRegistration("Admission")
+	r.Key(gofig.String, "", string(tokens.ReadOnly),
+		"The role of the requests that carry no token and are not received "+
+			"on the host's unix sockets",
+		"rexray.admission.role")
+	gofig.Register(r)
+
+	ratelimit.RegisterFilter(filter)
+}
+
+// Identity is the identity with which a request was authenticated.
+type Identity struct {
+
+	// Name is the token's account or subject, the SPIFFE ID or common name
+	// of the client's certificate, local if the request was received on the
+	// host's unix sockets, or anonymous.
+	Name string
+
+	// Role is the role the request was granted.
+	Role tokens.Role
+}
+
+// buckets are the roles required to write each bucket of the controller's
+// state that is served to the hosts. Any role may read them. The buckets
+// that are not listed are written only by the controller itself.
+var buckets = map[string]tokens.Role{
+	nodes.NodesBucket:        tokens.Operator,
+	nodes.FencesBucket:       tokens.Operator,
+	maintenance.Bucket:       tokens.Admin,
+	pins.Bucket:              tokens.Operator,
+	policy.AccessModesBucket: tokens.Operator,
+	policy.AttachModesBucket: tokens.Operator,
+	adoption.Bucket:          tokens.Admin,
+	quota.OwnersBucket:       tokens.Admin,
+	labels.VolumesBucket:     tokens.Admin,
+}
+
+// filter serves the controller's state, and admits the other requests
+// before it passes them to next.
+func filter(
+	ctx apitypes.Context, config gofig.Config, next http.Handler) http.Handler {
+
+	state.ServeController()
+	store := state.Default()
+	sh := state.Handler(store,
+		func(req *http.Request, bucket string, write bool) error {
+			return authorize(config, store, req, bucket, write)
+		})
+
+	next = volumes(config, store, quotas(config, store, next))
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if strings.HasPrefix(req.URL.Path, state.HandlerPath) {
+			sh.ServeHTTP(w, req)
+			return
+		}
+		next.ServeHTTP(w, req)
+	})
+}
+
+// authorize returns an error if the request may not read, or write if write
+// is set, the provided bucket of the controller's state.
+func authorize(
+	config gofig.Config,
+	s *state.Store,
+	req *http.Request,
+	bucket string,
+	write bool) error {
+
+	role, ok := buckets[bucket]
+	if !ok {
+		return goof.WithField("bucket", bucket, "bucket not served")
+	}
+	id, err := Identify(config, s, req)
+	if err != nil {
+		return err
+	}
+	if !write {
+		role = tokens.ReadOnly
+	}
+	if !id.Role.Allows(role) {
+		return goof.WithFields(goof.Fields{
+			"bucket": bucket,
+			"role":   id.Role,
+		}, "role does not permit the request")
+	}
+	return nil
+}
+
+// Identify returns the identity with which a request was authenticated. The
+// requests received on the host's unix sockets are granted the admin role,
+// since only the users permitted to open the sockets may send them. The
+// other requests are granted the role of their bearer token, or else the
+// configured role.
+func Identify(
+	config gofig.Config, s *state.Store, req *http.Request) (*Identity, error) {
+
+	if ratelimit.IsLocal(req) {
+		return &Identity{Name: "local", Role: tokens.Admin}, nil
+	}
+
+	c, err := tokens.FromRequest(config, s, req)
+	if err != nil {
+		if _, ok := err.(*tokens.Error); !ok {
+			log.WithError(err).Error("error verifying token")
+		}
+		return nil, err
+	}
+	if c != nil {
+		name := c.Account
+		if name == "" {
+			name = c.Subject
+		}
+		return &Identity{Name: name, Role: c.Role}, nil
+	}
+
+	id := &Identity{Name: peerName(req)}
+	if id.Name == "" {
+		id.Name = "anonymous"
+	}
+	role, err := tokens.ParseRole(config.GetString("rexray.admission.role"))
+	if err != nil {
+		return nil, err
+	}
+	id.Role = role
+	return id, nil
+}
+
+// peerName returns the SPIFFE ID, or else the common name, of the verified
+// certificate of the client that sent the request.
+func peerName(req *http.Request) string {
+	if req.TLS == nil || len(req.TLS.VerifiedChains) == 0 {
+		return ""
+	}
+	cert := req.TLS.VerifiedChains[0][0]
+	for _, u := range cert.URIs {
+		if u.Scheme == "spiffe" {
+			return u.String()
+		}
+	}
+	return cert.Subject.CommonName
+}
@@ -0,0 +1,235 @@
+package admission
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/akutz/gofig"
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/core/errcodes"
+	"github.com/emccode/rexray/core/labels"
+	"github.com/emccode/rexray/core/maintenance"
+	"github.com/emccode/rexray/core/nodes"
+	"github.com/emccode/rexray/core/pins"
+	"github.com/emccode/rexray/core/policy"
+	"github.com/emccode/rexray/core/quota"
+	"github.com/emccode/rexray/core/state"
+	"github.com/emccode/rexray/core/tokens"
+)
+
+func TestAuthorize(t *testing.T) {
+	config := gofig.New()
+	s := state.New(nil)
+	req, _ := http.NewRequest("PUT", state.HandlerPath+"nodes/i-1", nil)
+
+	if err := authorize(
+		config, s, req, nodes.NodesBucket, true); err == nil {
+		t.Fatal("expected anonymous request to be refused a write")
+	}
+
+	config.Set("rexray.admission.role", string(tokens.ReadOnly))
+	if err := authorize(
+		config, s, req, nodes.NodesBucket, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := authorize(
+		config, s, req, nodes.NodesBucket, true); err == nil {
+		t.Fatal("expected read-only request to be refused a write")
+	}
+
+	config.Set("rexray.admission.role", string(tokens.Operator))
+	if err := authorize(
+		config, s, req, nodes.NodesBucket, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := authorize(config, s, req, "issuedTokens", false); err == nil {
+		t.Fatal("expected request for an unserved bucket to be refused")
+	}
+
+	config.Set("rexray.admission.role", "root")
+	if err := authorize(
+		config, s, req, nodes.NodesBucket, false); err == nil {
+		t.Fatal("expected invalid role to be refused")
+	}
+}
+
+func TestAdmit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "admission")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	s := state.Open(filepath.Join(dir, "state.json"))
+
+	if _, err := maintenance.Enable(
+		gofig.New(), s, "EBS", "migration"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pins.Set(s, "vol-1", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		method, url string
+		code        errcodes.Code
+	}{
+		{"POST", "/volumes/ebs", errcodes.ServiceInMaintenance},
+		{"POST", "/volumes/ebs/vol-2?attach", errcodes.ServiceInMaintenance},
+		{"POST", "/volumes/ebs/vol-2?copy", errcodes.ServiceInMaintenance},
+		{"POST", "/snapshots/ebs/snap-1?create",
+			errcodes.ServiceInMaintenance},
+		{"POST", "/volumes/ebs/vol-2?detach", ""},
+		{"POST", "/volumes/efs", ""},
+		{"POST", "/volumes/efs/vol-1?detach", errcodes.VolumePinned},
+		{"DELETE", "/volumes/efs/vol-1", errcodes.VolumePinned},
+		{"POST", "/volumes?detach", errcodes.VolumePinned},
+		{"POST", "/volumes/efs/vol-1?attach", ""},
+		{"GET", "/volumes/ebs", ""},
+	} {
+		req, _ := http.NewRequest(tt.method, tt.url, nil)
+		var err error
+		if o := parseOperation(req); o != nil {
+			err = admit(s, o)
+		}
+		if code := errcodes.Of(err); code != tt.code {
+			t.Errorf("%s %s: expected %q, got %q",
+				tt.method, tt.url, tt.code, code)
+		}
+	}
+}
+
+func TestAdmitAttachment(t *testing.T) {
+	dir, err := ioutil.TempDir("", "admission")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	s := state.Open(filepath.Join(dir, "state.json"))
+
+	for id, m := range map[string]policy.AccessMode{
+		"vol-1": policy.ReadWriteOnce,
+		"vol-2": policy.ReadOnlyMany,
+		"vol-3": policy.ReadWriteMany,
+	} {
+		if err := s.Set(policy.AccessModesBucket, id, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, id := range []string{"vol-1", "vol-3", "vol-4"} {
+		if err := s.Set(policy.AttachModesBucket, id,
+			map[string]string{"i-1": "rw"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Set(policy.AttachModesBucket, "vol-5",
+		map[string]string{"i-1": "ro"}); err != nil {
+		t.Fatal(err)
+	}
+
+	config := gofig.New()
+	h := volumes(config, s, http.HandlerFunc(
+		func(w http.ResponseWriter, req *http.Request) {}))
+
+	for _, tt := range []struct {
+		volumeID, instanceID, body string
+		status                     int
+	}{
+		{"vol-1", "i-1", `{}`, http.StatusOK},
+		{"vol-1", "i-2", `{}`, http.StatusConflict},
+		{"vol-1", "i-2", `{"opts":{"readOnly":true}}`, http.StatusConflict},
+		{"vol-1", "i-2", `{"force":true}`, http.StatusOK},
+		{"vol-1", "", `{}`, http.StatusOK},
+		{"vol-2", "i-2", `{}`, http.StatusBadRequest},
+		{"vol-2", "i-2", `{"opts":{"readOnly":true}}`, http.StatusOK},
+		{"vol-3", "i-2", `{}`, http.StatusOK},
+		{"vol-4", "i-2", `{"opts":{"readOnly":true}}`, http.StatusConflict},
+		{"vol-5", "i-2", `{"opts":{"readOnly":true}}`, http.StatusOK},
+		{"vol-5", "i-2", `{}`, http.StatusConflict},
+	} {
+		req, _ := http.NewRequest("POST",
+			"/volumes/ebs/"+tt.volumeID+"?attach", strings.NewReader(tt.body))
+		if tt.instanceID != "" {
+			req.Header.Add(apitypes.InstanceIDHeader, "vfs=i-9")
+			req.Header.Add(apitypes.InstanceIDHeader, "ebs="+tt.instanceID)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != tt.status {
+			t.Errorf("%s %s %s: expected %d, got %d", tt.volumeID,
+				tt.instanceID, tt.body, tt.status, w.Code)
+		}
+	}
+}
+
+func TestQuotas(t *testing.T) {
+	dir, err := ioutil.TempDir("", "admission")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	s := state.Open(filepath.Join(dir, "state.json"))
+
+	config := gofig.New()
+	config.Set("rexray.admission.role", string(tokens.Operator))
+	config.Set("rexray.quota.tenantLabel", "tenant")
+	config.Set("rexray.quota.principals", map[string]interface{}{
+		"anonymous": map[string]interface{}{"volumes": 1},
+	})
+
+	vols := map[string]*apitypes.Volume{}
+	next := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch {
+		case req.Method == "GET" && req.URL.Path == "/volumes":
+			json.NewEncoder(w).Encode(
+				map[string]map[string]*apitypes.Volume{"ebs": vols})
+		case req.Method == "POST":
+			id := fmt.Sprintf("vol-%d", len(vols)+1)
+			vols[id] = &apitypes.Volume{ID: id, Size: 10}
+			json.NewEncoder(w).Encode(vols[id])
+		case req.Method == "DELETE":
+			delete(vols, path.Base(req.URL.Path))
+		}
+	})
+	h := quotas(config, s, next)
+
+	create := func() int {
+		req, _ := http.NewRequest("POST", "/volumes/ebs", strings.NewReader(
+			`{"name":"data","size":10,"opts":{"labels":"tenant=ci"}}`))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := create(); code != http.StatusOK {
+		t.Fatalf("expected creation to be admitted, got %d", code)
+	}
+	if owner, err := quota.Owner(s, "vol-1"); err != nil ||
+		owner != "anonymous" {
+		t.Fatalf("%q %v", owner, err)
+	}
+	if l, err := labels.Volume(s, "vol-1"); err != nil || l["tenant"] != "ci" {
+		t.Fatalf("%v %v", l, err)
+	}
+
+	if code := create(); code != http.StatusForbidden {
+		t.Fatalf("expected creation to exceed quota, got %d", code)
+	}
+
+	req, _ := http.NewRequest("DELETE", "/volumes/ebs/vol-1", nil)
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if owner, err := quota.Owner(s, "vol-1"); err != nil || owner != "" {
+		t.Fatalf("%q %v", owner, err)
+	}
+	if code := create(); code != http.StatusOK {
+		t.Fatalf("expected creation to be admitted, got %d", code)
+	}
+}
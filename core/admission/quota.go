@@ -0,0 +1,283 @@
+package admission
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/core/errcodes"
+	"github.com/emccode/rexray/core/labels"
+	"github.com/emccode/rexray/core/quota"
+	"github.com/emccode/rexray/core/state"
+)
+
+// quotas refuses the volume creations that would exceed the quota of the
+// volume's tenant or of the principal with which the request was
+// authenticated, and records the principal and labels of the volumes that
+// are created. The creations are admitted one at a time while any quota is
+// configured, so that concurrent requests cannot exceed it together.
+func quotas(
+	config gofig.Config, s *state.Store, next http.Handler) http.Handler {
+
+	var lock sync.Mutex
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		o := parseOperation(req)
+		if o == nil || (o.name != "volume create" &&
+			o.name != "volume copy" && o.name != "volume remove") {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		if o.name == "volume remove" {
+			rec := &recorder{header: http.Header{}}
+			next.ServeHTTP(rec, req)
+			if rec.ok() {
+				forget(s, o.volumeID)
+			}
+			rec.writeTo(w)
+			return
+		}
+
+		id, err := Identify(config, s, req)
+		if err != nil {
+			errcodes.Write(w, err)
+			return
+		}
+
+		if len(quota.Limits(config, quota.KindTenant)) > 0 ||
+			len(quota.Limits(config, quota.KindPrincipal)) > 0 {
+			lock.Lock()
+			defer lock.Unlock()
+		}
+
+		c, err := readCreation(s, req, o, next)
+		if err != nil {
+			errcodes.Write(w, err)
+			return
+		}
+		if err := checkQuota(config, s, req, next, c, id.Name); err != nil {
+			errcodes.Write(w, err)
+			return
+		}
+
+		rec := &recorder{header: http.Header{}}
+		next.ServeHTTP(rec, req)
+		if rec.ok() {
+			vol := &apitypes.Volume{}
+			if err := json.Unmarshal(rec.body.Bytes(), vol); err != nil {
+				log.WithError(err).Warn("error decoding created volume")
+			} else {
+				remember(s, vol.ID, id.Name, c.labels)
+			}
+		}
+		rec.writeTo(w)
+	})
+}
+
+// creation is a volume that is about to be created.
+type creation struct {
+
+	// size is the volume's size in GiB, or zero if it is not known.
+	size int64
+
+	// labels are the volume's labels.
+	labels labels.Labels
+}
+
+// readCreation returns the size and labels of the volume a request creates.
+// A copy inherits the size and labels of its source, and a volume created
+// from a snapshot is the size of the snapshot's volume unless another size
+// is requested.
+func readCreation(
+	s *state.Store,
+	req *http.Request,
+	o *operation,
+	next http.Handler) (*creation, error) {
+
+	c := &creation{labels: labels.Labels{}}
+	if o.name == "volume copy" {
+		vol := &apitypes.Volume{}
+		if err := inspect(
+			req, next, "/volumes/"+o.service+"/"+o.volumeID, vol); err != nil {
+			return nil, err
+		}
+		l, err := labels.Volume(s, o.volumeID)
+		if err != nil {
+			return nil, err
+		}
+		c.size, c.labels = vol.Size, l
+		return c, nil
+	}
+
+	buf, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(buf))
+
+	cr := &apitypes.VolumeCreateRequest{}
+	if len(buf) > 0 {
+		if err := json.Unmarshal(buf, cr); err != nil {
+			return nil, errcodes.New(errcodes.InvalidRequest, err)
+		}
+	}
+	if v, ok := cr.Opts[labels.OptKey].(string); ok {
+		if c.labels, err = labels.Parse([]string{v}); err != nil {
+			return nil, errcodes.New(errcodes.InvalidRequest, err)
+		}
+	}
+	if cr.Size != nil {
+		c.size = *cr.Size
+	}
+
+	if c.size == 0 && o.snapshotID != "" {
+		snap := &apitypes.Snapshot{}
+		if err := inspect(
+			req, next,
+			"/snapshots/"+o.service+"/"+o.snapshotID, snap); err != nil {
+			return nil, err
+		}
+		c.size = snap.VolumeSize
+	}
+	return c, nil
+}
+
+// checkQuota returns an error if creating the volume for the principal
+// would exceed its quota or the quota of the volume's tenant.
+func checkQuota(
+	config gofig.Config,
+	s *state.Store,
+	req *http.Request,
+	next http.Handler,
+	c *creation,
+	principal string) error {
+
+	tenant := c.labels[quota.TenantLabel(config)]
+	_, limited := quota.Limits(
+		config, quota.KindTenant)[strings.ToLower(tenant)]
+	if _, ok := quota.Limits(
+		config, quota.KindPrincipal)[strings.ToLower(principal)]; ok {
+		limited = true
+	}
+	if !limited {
+		return nil
+	}
+
+	services := map[string]map[string]*apitypes.Volume{}
+	if err := inspect(req, next, "/volumes", &services); err != nil {
+		return err
+	}
+	vols := []*apitypes.Volume{}
+	for _, svc := range services {
+		for _, v := range svc {
+			vols = append(vols, v)
+		}
+	}
+
+	if err := quota.Check(
+		config, s, vols, tenant, principal, c.size); err != nil {
+		log.WithFields(log.Fields{
+			"tenant":    tenant,
+			"principal": principal,
+			"size":      c.size,
+		}).WithError(err).Warn("refused volume creation")
+		return err
+	}
+	return nil
+}
+
+// inspect decodes into v the response of the libStorage server to a GET
+// request for the provided path, sent with the original request's headers
+// and context so that it is authenticated the same way.
+func inspect(
+	req *http.Request, next http.Handler, path string, v interface{}) error {
+
+	get, err := http.NewRequest("GET", path, nil)
+	if err != nil {
+		return err
+	}
+	get = get.WithContext(req.Context())
+	get.Header = req.Header
+	get.Host = req.Host
+	get.RemoteAddr = req.RemoteAddr
+	get.TLS = req.TLS
+
+	rec := &recorder{header: http.Header{}}
+	next.ServeHTTP(rec, get)
+	if !rec.ok() {
+		return goof.WithFields(goof.Fields{
+			"path":   path,
+			"status": rec.status,
+		}, "error inspecting resource")
+	}
+	return json.Unmarshal(rec.body.Bytes(), v)
+}
+
+// remember records the principal that created a volume and its labels.
+func remember(s *state.Store, volumeID, principal string, l labels.Labels) {
+	fields := log.Fields{"volumeID": volumeID}
+	if err := quota.SetOwner(s, volumeID, principal); err != nil {
+		log.WithFields(fields).WithError(err).Warn(
+			"error storing volume owner")
+	}
+	if err := labels.SetVolume(s, volumeID, l); err != nil {
+		log.WithFields(fields).WithError(err).Warn(
+			"error storing volume labels")
+	}
+}
+
+// forget removes the principal and labels recorded for a removed volume.
+func forget(s *state.Store, volumeID string) {
+	remember(s, volumeID, "", nil)
+}
+
+// recorder buffers a response so that it may be inspected before it is
+// written.
+type recorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (r *recorder) Header() http.Header {
+	return r.header
+}
+
+func (r *recorder) Write(buf []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.body.Write(buf)
+}
+
+func (r *recorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+}
+
+// ok returns a flag indicating whether the response was successful. A
+// response without a status is successful, as it is when it is served.
+func (r *recorder) ok() bool {
+	return r.status == 0 || (r.status >= 200 && r.status < 300)
+}
+
+// writeTo writes the buffered response.
+func (r *recorder) writeTo(w http.ResponseWriter) {
+	for k, v := range r.header {
+		w.Header()[k] = v
+	}
+	if r.status != 0 {
+		w.WriteHeader(r.status)
+	}
+	w.Write(r.body.Bytes())
+}
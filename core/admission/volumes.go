@@ -0,0 +1,249 @@
+package admission
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/akutz/gofig"
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/core/errcodes"
+	"github.com/emccode/rexray/core/maintenance"
+	"github.com/emccode/rexray/core/pins"
+	"github.com/emccode/rexray/core/policy"
+	"github.com/emccode/rexray/core/state"
+)
+
+// operation is a request of the libStorage API that is admitted according
+// to the controller's policies.
+type operation struct {
+
+	// name is the operation's name in the errors with which it is refused,
+	// ex. volume attach.
+	name string
+
+	// service is the name of the libStorage service, or empty if the
+	// operation applies to every service.
+	service string
+
+	// volumeID is the ID of the volume, or empty if the operation applies to
+	// every volume of the service.
+	volumeID string
+
+	// snapshotID is the ID of the snapshot from which a volume is created.
+	snapshotID string
+
+	// instanceID is the ID of the instance to which a volume is attached, or
+	// empty if the request does not identify it.
+	instanceID string
+
+	// readOnly is set if a volume is attached read-only.
+	readOnly bool
+
+	// force is set if an attachment preempts the volume's other
+	// attachments.
+	force bool
+}
+
+// begins returns a flag indicating whether the operation begins using a
+// volume, which is refused while the service is in maintenance.
+func (o *operation) begins() bool {
+	switch o.name {
+	case "volume create", "volume attach", "volume copy":
+		return true
+	}
+	return false
+}
+
+// releases returns a flag indicating whether the operation releases a
+// volume, which is refused while the volume is pinned.
+func (o *operation) releases() bool {
+	switch o.name {
+	case "volume detach", "volume remove":
+		return true
+	}
+	return false
+}
+
+// parseOperation returns the operation of a request, or nil if the request
+// is not admitted according to the policies.
+func parseOperation(req *http.Request) *operation {
+	parts := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	q := req.URL.Query()
+	has := func(flag string) bool {
+		_, ok := q[flag]
+		return ok
+	}
+
+	switch {
+	case req.Method == "DELETE" && len(parts) == 3 && parts[0] == "volumes":
+		return &operation{
+			name:     "volume remove",
+			service:  parts[1],
+			volumeID: parts[2],
+		}
+	case req.Method != "POST":
+		return nil
+	case parts[0] == "snapshots" && len(parts) == 3 && has("create"):
+		return &operation{
+			name:       "volume create",
+			service:    parts[1],
+			snapshotID: parts[2],
+		}
+	case parts[0] != "volumes":
+		return nil
+	}
+
+	o := &operation{}
+	if len(parts) > 1 {
+		o.service = parts[1]
+	}
+	if len(parts) > 2 {
+		o.volumeID = parts[2]
+	}
+	switch {
+	case has("detach"):
+		o.name = "volume detach"
+	case len(parts) == 2:
+		o.name = "volume create"
+	case len(parts) == 3 && has("attach"):
+		o.name = "volume attach"
+	case len(parts) == 3 && has("copy"):
+		o.name = "volume copy"
+	default:
+		return nil
+	}
+	return o
+}
+
+// readAttachment reads the instance ID and the mode of an attachment from
+// its request. The instance ID is the one of the service's driver, or the
+// only one the request carries.
+func readAttachment(
+	config gofig.Config, req *http.Request, o *operation) error {
+
+	driver := config.GetString(fmt.Sprintf(
+		"%s.%s.driver", apitypes.ConfigServices, o.service))
+	if driver == "" {
+		driver = o.service
+	}
+	headers := req.Header[apitypes.InstanceIDHeader]
+	for _, h := range headers {
+		iid := &apitypes.InstanceID{}
+		if err := iid.UnmarshalText([]byte(h)); err != nil {
+			return errcodes.New(errcodes.InvalidRequest, err)
+		}
+		if len(headers) == 1 || strings.EqualFold(iid.Driver, driver) {
+			o.instanceID = iid.ID
+			break
+		}
+	}
+
+	buf, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return err
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(buf))
+
+	ar := &apitypes.VolumeAttachRequest{}
+	if len(buf) > 0 {
+		if err := json.Unmarshal(buf, ar); err != nil {
+			return errcodes.New(errcodes.InvalidRequest, err)
+		}
+	}
+	o.readOnly, _ = ar.Opts[policy.ReadOnlyKey].(bool)
+	o.force = ar.Force
+	return nil
+}
+
+// volumes refuses the operations that begin using a volume of a service in
+// maintenance, the attachments the volume's access mode does not permit,
+// and the operations that release a pinned volume, and passes the other
+// requests to next.
+func volumes(
+	config gofig.Config, s *state.Store, next http.Handler) http.Handler {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if o := parseOperation(req); o != nil {
+			if o.name == "volume attach" && req.Body != nil {
+				if err := readAttachment(config, req, o); err != nil {
+					errcodes.Write(w, err)
+					return
+				}
+			}
+			if err := admit(s, o); err != nil {
+				errcodes.Write(w, err)
+				return
+			}
+		}
+		next.ServeHTTP(w, req)
+	})
+}
+
+// admit returns an error if the operation is refused. Detaching every
+// volume from an instance is refused while any volume is pinned, since the
+// volumes attached to the instance are not known without asking the
+// storage platform.
+func admit(s *state.Store, o *operation) error {
+	if o.begins() && o.service != "" {
+		m, ok, err := maintenance.Get(s, o.service)
+		if err != nil {
+			return err
+		}
+		if ok {
+			return &maintenance.Error{Mode: m, Operation: o.name}
+		}
+	}
+
+	if o.name == "volume attach" && o.instanceID != "" {
+		if err := admitAttachment(s, o); err != nil {
+			return err
+		}
+	}
+
+	if !o.releases() {
+		return nil
+	}
+	if o.volumeID != "" {
+		p, ok, err := pins.Get(s, o.volumeID)
+		if err != nil {
+			return err
+		}
+		if ok {
+			return &pins.Error{Pin: p, Operation: o.name}
+		}
+		return nil
+	}
+	l, err := pins.List(s)
+	if err != nil {
+		return err
+	}
+	if len(l) > 0 {
+		return &pins.Error{Pin: l[0], Operation: o.name}
+	}
+	return nil
+}
+
+// admitAttachment returns an error if the volume's access mode does not
+// permit the attachment alongside the attachments the hosts have recorded.
+// An attachment that preempts the others is checked only against the
+// volume's access mode, since the others are discarded.
+func admitAttachment(s *state.Store, o *operation) error {
+	access, _, err := policy.GetVolumeAccessMode(s, o.volumeID)
+	if err != nil {
+		return err
+	}
+	modes := map[string]string{}
+	if !o.force {
+		if modes, err = policy.GetAttachModes(s, o.volumeID); err != nil {
+			return err
+		}
+	}
+	return policy.CheckAccessMode(
+		o.volumeID, o.instanceID, access, o.readOnly, modes)
+}
@@ -0,0 +1,209 @@
+// Package adoption brings volumes that were created outside of REX-Ray under
+// its management, and releases volumes from its management without removing
+// them from the storage platform.
+//
+// The adoption records are kept, with the adopted volumes' labels and owners,
+// in the controller's store so that every host refers to an adopted volume by
+// the same name.
+package adoption
+
+import (
+	"strings"
+	"time"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/core/health"
+	"github.com/emccode/rexray/core/labels"
+	"github.com/emccode/rexray/core/quota"
+	"github.com/emccode/rexray/core/state"
+)
+
+// Bucket is the bucket of the adopted volumes' records.
+const Bucket = "adoptedVolumes"
+
+// Record describes an adopted volume.
+type Record struct {
+
+	// VolumeID is the ID of the volume on the storage platform.
+	VolumeID string `json:"volumeID"`
+
+	// Name is the name by which REX-Ray refers to the volume.
+	Name string `json:"name"`
+
+	// Service is the name of the libStorage service that owns the volume.
+	Service string `json:"service"`
+
+	// Driver is the name of the storage driver that owns the volume.
+	Driver string `json:"driver"`
+
+	// Adopted is the time at which the volume was adopted.
+	Adopted time.Time `json:"adopted"`
+}
+
+// Opts are the options used to adopt a volume.
+type Opts struct {
+
+	// Driver is the name of the storage driver expected to own the volume.
+	// The volume is not adopted if the configured service uses a different
+	// driver.
+	Driver string
+
+	// Name is the name by which REX-Ray refers to the volume. The volume's
+	// name on the storage platform is used if it is empty.
+	Name string
+
+	// Labels are applied to the volume.
+	Labels labels.Labels
+}
+
+// Adopt brings the volume with the provided ID under REX-Ray's management.
+func Adopt(
+	ctx apitypes.Context,
+	config gofig.Config,
+	client apitypes.Client,
+	s *state.Store,
+	volumeID string,
+	opts *Opts) (*Record, error) {
+
+	service := config.GetString(apitypes.ConfigService)
+	driver, err := driverName(ctx, client, service)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Driver != "" &&
+		strings.ToLower(opts.Driver) != strings.ToLower(driver) {
+		return nil, goof.WithFields(goof.Fields{
+			"service":  service,
+			"driver":   driver,
+			"expected": opts.Driver,
+		}, "service does not use the expected driver")
+	}
+
+	vol, err := client.Storage().VolumeInspect(
+		ctx, volumeID, &apitypes.VolumeInspectOpts{})
+	if err != nil {
+		return nil, goof.WithFieldE(
+			"volumeID", volumeID, "error inspecting volume", err)
+	}
+
+	name := opts.Name
+	if name == "" {
+		name = vol.Name
+	}
+	if name == "" {
+		return nil, goof.WithField(
+			"volumeID", volumeID, "volume has no name; a name is required")
+	}
+	if other, ok, err := ByName(s, name); err != nil {
+		return nil, err
+	} else if ok && other.VolumeID != vol.ID {
+		return nil, goof.WithFields(goof.Fields{
+			"name":     name,
+			"volumeID": other.VolumeID,
+		}, "name is used by another adopted volume")
+	}
+
+	l, err := labels.Volume(s, vol.ID)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range opts.Labels {
+		l[k] = v
+	}
+	if err := labels.SetVolume(s, vol.ID, l); err != nil {
+		return nil, err
+	}
+	if err := quota.SetOwner(s, vol.ID, quota.Principal(config)); err != nil {
+		return nil, err
+	}
+
+	r := &Record{
+		VolumeID: vol.ID,
+		Name:     name,
+		Service:  service,
+		Driver:   driver,
+		Adopted:  time.Now().UTC(),
+	}
+	if err := s.Set(Bucket, vol.ID, r); err != nil {
+		return nil, err
+	}
+
+	ctx.WithFields(map[string]interface{}{
+		"volumeID": r.VolumeID,
+		"name":     r.Name,
+		"driver":   r.Driver,
+	}).Info("adopted volume")
+	return r, nil
+}
+
+// Release removes the metadata REX-Ray records for the volume with the
+// provided ID: its labels, owner, and adoption record from the controller's
+// store s, and its health from the store hs in which the agents record it.
+// The volume itself is neither unmounted, detached, nor removed.
+func Release(s, hs *state.Store, volumeID string) error {
+	if err := labels.SetVolume(s, volumeID, nil); err != nil {
+		return err
+	}
+	if err := quota.SetOwner(s, volumeID, ""); err != nil {
+		return err
+	}
+	if err := health.Delete(hs, volumeID); err != nil {
+		return err
+	}
+	return s.Delete(Bucket, volumeID)
+}
+
+// Get returns the adoption record of the volume with the provided ID. The
+// returned flag is false if the volume was not adopted.
+func Get(s *state.Store, volumeID string) (*Record, bool, error) {
+	r := &Record{}
+	ok, err := s.Get(Bucket, volumeID, r)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	return r, true, nil
+}
+
+// ByName returns the adoption record of the volume adopted with the provided
+// name.
+func ByName(s *state.Store, name string) (*Record, bool, error) {
+	ids, err := s.Keys(Bucket)
+	if err != nil {
+		return nil, false, err
+	}
+	for _, id := range ids {
+		r, ok, err := Get(s, id)
+		if err != nil {
+			return nil, false, err
+		}
+		if ok && strings.ToLower(r.Name) == strings.ToLower(name) {
+			return r, true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+func driverName(
+	ctx apitypes.Context,
+	client apitypes.Client,
+	service string) (string, error) {
+
+	svcs, err := client.API().Services(ctx)
+	if err != nil {
+		return "", err
+	}
+	for name, svc := range svcs {
+		if svc.Driver == nil {
+			continue
+		}
+		// a client configured without a service uses the only service
+		if (service == "" && len(svcs) == 1) ||
+			strings.ToLower(name) == strings.ToLower(service) {
+			return svc.Driver.Name, nil
+		}
+	}
+	return "", goof.WithField("service", service, "service not found")
+}
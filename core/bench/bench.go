@@ -0,0 +1,267 @@
+// Package bench measures the latency of the volume lifecycle against a
+// libStorage service, and the I/O performance of a mounted volume, so that
+// drivers may be compared, QoS settings validated, and regressions in a
+// storage platform detected.
+package bench
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	apitypes "github.com/emccode/libstorage/api/types"
+	apiutils "github.com/emccode/libstorage/api/utils"
+
+	"github.com/emccode/rexray/core/policy"
+)
+
+// The steps of the volume lifecycle, in the order they are run.
+const (
+	StepCreate  = "create"
+	StepAttach  = "attach"
+	StepMount   = "mount"
+	StepUnmount = "unmount"
+	StepDetach  = "detach"
+	StepRemove  = "remove"
+)
+
+var steps = []string{
+	StepCreate, StepAttach, StepMount, StepUnmount, StepDetach, StepRemove,
+}
+
+// Opts are the options for a benchmark.
+type Opts struct {
+
+	// Iterations is the number of times the lifecycle is run.
+	Iterations int
+
+	// Size is the size of the created volumes in GiB.
+	Size int64
+
+	// FSType is the file system with which volumes are formatted.
+	FSType string
+
+	// NamePrefix is the prefix of the names of the created volumes.
+	NamePrefix string
+}
+
+// StepResult is the latency of one step of the lifecycle, in milliseconds.
+type StepResult struct {
+	Step     string  `json:"step" yaml:"step"`
+	Count    int     `json:"count" yaml:"count"`
+	Failures int     `json:"failures" yaml:"failures"`
+	Min      float64 `json:"minMs" yaml:"minMs"`
+	Mean     float64 `json:"meanMs" yaml:"meanMs"`
+	P50      float64 `json:"p50Ms" yaml:"p50Ms"`
+	P90      float64 `json:"p90Ms" yaml:"p90Ms"`
+	P99      float64 `json:"p99Ms" yaml:"p99Ms"`
+	Max      float64 `json:"maxMs" yaml:"maxMs"`
+}
+
+// Result is the result of a benchmark.
+type Result struct {
+	Iterations int           `json:"iterations" yaml:"iterations"`
+	Elapsed    string        `json:"elapsed" yaml:"elapsed"`
+	Steps      []*StepResult `json:"steps" yaml:"steps"`
+
+	// Errors are the first error of each failed iteration.
+	Errors []string `json:"errors,omitempty" yaml:"errors,omitempty"`
+}
+
+type timer struct {
+	samples  map[string][]time.Duration
+	failures map[string]int
+}
+
+func (t *timer) time(step string, f func() error) error {
+	start := time.Now()
+	err := f()
+	if err != nil {
+		t.failures[step]++
+		return goof.WithFieldE("step", step, "benchmark step failed", err)
+	}
+	t.samples[step] = append(t.samples[step], time.Since(start))
+	return nil
+}
+
+// Run runs the volume lifecycle the provided number of times. A failed
+// iteration is cleaned up on a best-effort basis and the benchmark
+// continues. When volumes are attached on mount the unmount step includes
+// the detachment and the detach step is skipped.
+func Run(
+	ctx apitypes.Context,
+	config gofig.Config,
+	client apitypes.Client,
+	opts *Opts) (*Result, error) {
+
+	if opts.Iterations < 1 {
+		return nil, goof.New("iterations must be at least 1")
+	}
+	if opts.NamePrefix == "" {
+		opts.NamePrefix = "rexray-bench"
+	}
+	detach := policy.GetAttachMode(config) != policy.AttachOnMount
+
+	t := &timer{
+		samples:  map[string][]time.Duration{},
+		failures: map[string]int{},
+	}
+	res := &Result{Iterations: opts.Iterations}
+	start := time.Now()
+
+	for i := 0; i < opts.Iterations; i++ {
+		name := fmt.Sprintf("%s-%d-%d", opts.NamePrefix, start.Unix(), i)
+		if err := runOnce(ctx, client, opts, t, name, detach); err != nil {
+			ctx.WithField("volumeName", name).WithError(err).Warn(
+				"benchmark iteration failed")
+			res.Errors = append(res.Errors, err.Error())
+		}
+	}
+
+	res.Elapsed = time.Since(start).String()
+	for _, step := range steps {
+		if step == StepDetach && !detach {
+			continue
+		}
+		res.Steps = append(res.Steps,
+			summarize(step, t.samples[step], t.failures[step]))
+	}
+	return res, nil
+}
+
+func runOnce(
+	ctx apitypes.Context,
+	client apitypes.Client,
+	opts *Opts,
+	t *timer,
+	name string,
+	detach bool) (err error) {
+
+	var (
+		vol      *apitypes.Volume
+		attached bool
+		mounted  bool
+	)
+
+	// clean up whatever the failed step left behind
+	defer func() {
+		if err == nil || vol == nil {
+			return
+		}
+		if mounted {
+			client.Integration().Unmount(ctx, vol.ID, "", apiutils.NewStore())
+		}
+		if attached {
+			client.Storage().VolumeDetach(ctx, vol.ID,
+				&apitypes.VolumeDetachOpts{
+					Force: true,
+					Opts:  apiutils.NewStore(),
+				})
+		}
+		client.Storage().VolumeRemove(ctx, vol.ID, apiutils.NewStore())
+	}()
+
+	if err = t.time(StepCreate, func() error {
+		var err error
+		size := opts.Size
+		vol, err = client.Storage().VolumeCreate(ctx, name,
+			&apitypes.VolumeCreateOpts{Size: &size, Opts: apiutils.NewStore()})
+		return err
+	}); err != nil {
+		return err
+	}
+
+	if err = t.time(StepAttach, func() error {
+		_, _, err := client.Storage().VolumeAttach(ctx, vol.ID,
+			&apitypes.VolumeAttachOpts{Opts: apiutils.NewStore()})
+		return err
+	}); err != nil {
+		return err
+	}
+	attached = true
+
+	if err = t.time(StepMount, func() error {
+		_, _, err := client.Integration().Mount(ctx, vol.ID, "",
+			&apitypes.VolumeMountOpts{
+				NewFSType: opts.FSType,
+				Opts:      apiutils.NewStore(),
+			})
+		return err
+	}); err != nil {
+		return err
+	}
+	mounted = true
+
+	if err = t.time(StepUnmount, func() error {
+		return client.Integration().Unmount(
+			ctx, vol.ID, "", apiutils.NewStore())
+	}); err != nil {
+		return err
+	}
+	mounted = false
+	attached = detach
+
+	if detach {
+		if err = t.time(StepDetach, func() error {
+			_, err := client.Storage().VolumeDetach(ctx, vol.ID,
+				&apitypes.VolumeDetachOpts{Opts: apiutils.NewStore()})
+			return err
+		}); err != nil {
+			return err
+		}
+		attached = false
+	}
+
+	if err = t.time(StepRemove, func() error {
+		return client.Storage().VolumeRemove(ctx, vol.ID, apiutils.NewStore())
+	}); err != nil {
+		return err
+	}
+	vol = nil
+	return nil
+}
+
+func summarize(step string, samples []time.Duration, failures int) *StepResult {
+	r := &StepResult{Step: step, Count: len(samples), Failures: failures}
+	if len(samples) == 0 {
+		return r
+	}
+
+	sorted := make([]time.Duration, len(samples))
+	copy(sorted, samples)
+	sort.Sort(durations(sorted))
+
+	var total time.Duration
+	for _, d := range sorted {
+		total += d
+	}
+
+	r.Min = ms(sorted[0])
+	r.Max = ms(sorted[len(sorted)-1])
+	r.Mean = ms(total / time.Duration(len(sorted)))
+	r.P50 = ms(percentile(sorted, 50))
+	r.P90 = ms(percentile(sorted, 90))
+	r.P99 = ms(percentile(sorted, 99))
+	return r
+}
+
+// percentile returns the nearest-rank percentile of the sorted samples.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+type durations []time.Duration
+
+func (d durations) Len() int           { return len(d) }
+func (d durations) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
+func (d durations) Less(i, j int) bool { return d[i] < d[j] }
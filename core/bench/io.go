@@ -0,0 +1,258 @@
+package bench
+
+import (
+	"crypto/rand"
+	mrand "math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/akutz/goof"
+)
+
+// The I/O patterns of a volume benchmark. They are named after the fio rw
+// modes that they correspond to.
+const (
+	PatternRead      = "read"
+	PatternWrite     = "write"
+	PatternRandRead  = "randread"
+	PatternRandWrite = "randwrite"
+	PatternRandRW    = "randrw"
+)
+
+const testFileName = ".rexray-bench.dat"
+
+// IOOpts are the options for a volume I/O benchmark.
+type IOOpts struct {
+
+	// Job is the path to a fio job file. If it is empty a job is built from
+	// the other options.
+	Job string
+
+	// Native runs the Go I/O generator even if fio is installed.
+	Native bool
+
+	// Pattern is the I/O pattern.
+	Pattern string
+
+	// BlockSize is the size of each I/O in bytes.
+	BlockSize int64
+
+	// FileSize is the size of the test file in bytes.
+	FileSize int64
+
+	// Duration is how long I/O is generated.
+	Duration time.Duration
+
+	// Workers is the number of concurrent workers.
+	Workers int
+}
+
+// IOStats are the results of one direction of I/O. Latencies are in
+// microseconds.
+type IOStats struct {
+	IOPS        float64 `json:"iops" yaml:"iops"`
+	MBps        float64 `json:"mbps" yaml:"mbps"`
+	LatencyMean float64 `json:"latencyMeanUs" yaml:"latencyMeanUs"`
+	LatencyP99  float64 `json:"latencyP99Us" yaml:"latencyP99Us"`
+}
+
+// IOResult is the result of a volume I/O benchmark.
+type IOResult struct {
+	Engine  string   `json:"engine" yaml:"engine"`
+	Pattern string   `json:"pattern,omitempty" yaml:"pattern,omitempty"`
+	Read    *IOStats `json:"read,omitempty" yaml:"read,omitempty"`
+	Write   *IOStats `json:"write,omitempty" yaml:"write,omitempty"`
+}
+
+func (o *IOOpts) setDefaults() {
+	if o.Pattern == "" {
+		o.Pattern = PatternRandRW
+	}
+	if o.BlockSize <= 0 {
+		o.BlockSize = 4096
+	}
+	if o.FileSize <= 0 {
+		o.FileSize = 256 * 1024 * 1024
+	}
+	if o.Duration <= 0 {
+		o.Duration = 30 * time.Second
+	}
+	if o.Workers <= 0 {
+		o.Workers = 4
+	}
+}
+
+// RunIO runs an I/O benchmark against a test file in the provided directory,
+// which is normally the mount path of a volume. The benchmark is run with
+// fio if it is installed, otherwise with a Go I/O generator. The test file is
+// removed afterwards.
+func RunIO(dir string, opts *IOOpts) (*IOResult, error) {
+	opts.setDefaults()
+	switch opts.Pattern {
+	case PatternRead, PatternWrite,
+		PatternRandRead, PatternRandWrite, PatternRandRW:
+	default:
+		return nil, goof.WithField("pattern", opts.Pattern, "invalid pattern")
+	}
+
+	path := filepath.Join(dir, testFileName)
+	defer os.Remove(path)
+
+	if !opts.Native {
+		if fio, err := exec.LookPath("fio"); err == nil {
+			return runFIO(fio, path, opts)
+		}
+	}
+	if opts.Job != "" {
+		return nil, goof.New("a fio job requires fio to be installed")
+	}
+	return runNative(path, opts)
+}
+
+// runNative generates I/O with the provided pattern from concurrent workers.
+// Writes are synchronous so that they reach the volume, but reads may be
+// served by the page cache, so the results approximate those of fio.
+func runNative(path string, opts *IOOpts) (*IOResult, error) {
+	if err := fillFile(path, opts.FileSize); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_SYNC, 0600)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	blocks := opts.FileSize / opts.BlockSize
+	if blocks < 1 {
+		return nil, goof.New("test file is smaller than the block size")
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		reads    []time.Duration
+		writes   []time.Duration
+		firstErr error
+	)
+
+	deadline := time.Now().Add(opts.Duration)
+	for w := 0; w < opts.Workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			r := mrand.New(mrand.NewSource(time.Now().UnixNano() + int64(w)))
+			buf := make([]byte, opts.BlockSize)
+			r.Read(buf)
+
+			var wReads, wWrites []time.Duration
+			next := int64(w) * blocks / int64(opts.Workers)
+			for time.Now().Before(deadline) {
+				block := next % blocks
+				next++
+				if opts.Pattern != PatternRead && opts.Pattern != PatternWrite {
+					block = r.Int63n(blocks)
+				}
+				write := opts.Pattern == PatternWrite ||
+					opts.Pattern == PatternRandWrite ||
+					(opts.Pattern == PatternRandRW && r.Intn(2) == 0)
+
+				start := time.Now()
+				var err error
+				if write {
+					_, err = f.WriteAt(buf, block*opts.BlockSize)
+				} else {
+					_, err = f.ReadAt(buf, block*opts.BlockSize)
+				}
+				d := time.Since(start)
+				if err != nil {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = err
+					}
+					mu.Unlock()
+					return
+				}
+				if write {
+					wWrites = append(wWrites, d)
+				} else {
+					wReads = append(wReads, d)
+				}
+			}
+
+			mu.Lock()
+			reads = append(reads, wReads...)
+			writes = append(writes, wWrites...)
+			mu.Unlock()
+		}(w)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	return &IOResult{
+		Engine:  "native",
+		Pattern: opts.Pattern,
+		Read:    ioStats(reads, opts.BlockSize, opts.Duration),
+		Write:   ioStats(writes, opts.BlockSize, opts.Duration),
+	}, nil
+}
+
+// fillFile writes random data to the test file so that reads are not served
+// from a sparse file.
+func fillFile(path string, size int64) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	buf := make([]byte, 1024*1024)
+	if _, err := rand.Read(buf); err != nil {
+		return err
+	}
+	for written := int64(0); written < size; {
+		n := int64(len(buf))
+		if size-written < n {
+			n = size - written
+		}
+		if _, err := f.Write(buf[:n]); err != nil {
+			return err
+		}
+		written += n
+	}
+	return f.Sync()
+}
+
+func ioStats(
+	samples []time.Duration, blockSize int64, elapsed time.Duration) *IOStats {
+
+	if len(samples) == 0 {
+		return nil
+	}
+	sort.Sort(durations(samples))
+
+	var total time.Duration
+	for _, d := range samples {
+		total += d
+	}
+
+	secs := elapsed.Seconds()
+	iops := float64(len(samples)) / secs
+	return &IOStats{
+		IOPS:        iops,
+		MBps:        iops * float64(blockSize) / (1024 * 1024),
+		LatencyMean: us(total / time.Duration(len(samples))),
+		LatencyP99:  us(percentile(samples, 99)),
+	}
+}
+
+func us(d time.Duration) float64 {
+	return float64(d) / float64(time.Microsecond)
+}
@@ -0,0 +1,110 @@
+package bench
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+
+	"github.com/akutz/goof"
+)
+
+// fioOutput is the subset of fio's JSON output that is reported.
+type fioOutput struct {
+	Jobs []struct {
+		Read  fioStats `json:"read"`
+		Write fioStats `json:"write"`
+	} `json:"jobs"`
+}
+
+type fioStats struct {
+	IOPS   float64 `json:"iops"`
+	BW     float64 `json:"bw"`
+	ClatNS struct {
+		Mean       float64            `json:"mean"`
+		Percentile map[string]float64 `json:"percentile"`
+	} `json:"clat_ns"`
+}
+
+// runFIO runs fio with the provided job file, or with a job built from the
+// options, and reports the aggregate results of its jobs.
+func runFIO(fio, path string, opts *IOOpts) (*IOResult, error) {
+	args := []string{"--output-format=json"}
+	if opts.Job != "" {
+		// the job's filename is overridden so that it tests the volume
+		args = append(args, "--filename="+path, opts.Job)
+	} else {
+		args = append(args,
+			"--name=rexray",
+			"--filename="+path,
+			"--rw="+opts.Pattern,
+			fmt.Sprintf("--bs=%d", opts.BlockSize),
+			fmt.Sprintf("--size=%d", opts.FileSize),
+			fmt.Sprintf("--runtime=%d", int(opts.Duration.Seconds())),
+			fmt.Sprintf("--numjobs=%d", opts.Workers),
+			"--time_based",
+			"--direct=1",
+			"--group_reporting")
+	}
+
+	out, err := exec.Command(fio, args...).Output()
+	if err != nil {
+		if ee, ok := err.(*exec.ExitError); ok {
+			return nil, goof.WithFieldE(
+				"stderr", string(ee.Stderr), "fio failed", err)
+		}
+		return nil, err
+	}
+
+	var fo fioOutput
+	if err := json.Unmarshal(out, &fo); err != nil {
+		return nil, goof.WithFieldE("fio", fio, "error parsing fio output", err)
+	}
+
+	res := &IOResult{Engine: "fio"}
+	if opts.Job == "" {
+		res.Pattern = opts.Pattern
+	}
+
+	var read, write fioStats
+	for _, j := range fo.Jobs {
+		addFIOStats(&read, &j.Read)
+		addFIOStats(&write, &j.Write)
+	}
+	res.Read = fromFIOStats(&read)
+	res.Write = fromFIOStats(&write)
+	return res, nil
+}
+
+// addFIOStats adds the throughput of a job to a total. The latencies of the
+// jobs are averaged by weight of their IOPS, and the highest 99th percentile
+// latency is kept.
+func addFIOStats(total, job *fioStats) {
+	if job.IOPS == 0 {
+		return
+	}
+	iops := total.IOPS + job.IOPS
+	total.ClatNS.Mean = (total.ClatNS.Mean*total.IOPS +
+		job.ClatNS.Mean*job.IOPS) / iops
+	total.IOPS = iops
+	total.BW += job.BW
+
+	p99 := job.ClatNS.Percentile["99.000000"]
+	if total.ClatNS.Percentile == nil {
+		total.ClatNS.Percentile = map[string]float64{}
+	}
+	if p99 > total.ClatNS.Percentile["99.000000"] {
+		total.ClatNS.Percentile["99.000000"] = p99
+	}
+}
+
+func fromFIOStats(s *fioStats) *IOStats {
+	if s.IOPS == 0 {
+		return nil
+	}
+	return &IOStats{
+		IOPS:        s.IOPS,
+		MBps:        s.BW / 1024,
+		LatencyMean: s.ClatNS.Mean / 1000,
+		LatencyP99:  s.ClatNS.Percentile["99.000000"] / 1000,
+	}
+}
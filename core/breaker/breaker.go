@@ -0,0 +1,259 @@
+// Package breaker stops REX-Ray from sending requests to a storage platform
+// that is failing. After a number of consecutive failures of a service's
+// operations the service's circuit opens, and its operations fail fast for a
+// cool-down period instead of piling up behind the platform. After the
+// cool-down one operation is let through as a trial: the circuit closes if
+// it succeeds and opens again if it fails.
+package breaker
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/akutz/gofig"
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/core/errcodes"
+)
+
+// State is the state of a service's circuit.
+type State string
+
+const (
+	// Closed indicates the service's operations are performed.
+	Closed State = "closed"
+
+	// Open indicates the service's operations fail fast.
+	Open State = "open"
+
+	// HalfOpen indicates a trial operation is being performed after the
+	// cool-down, while the service's other operations fail fast.
+	HalfOpen State = "half-open"
+
+	defaultFailures = 5
+	defaultCoolDown = 30 * time.Second
+)
+
+func init() {
+	r := gofig.NewRegistration("Circuit Breaker")
+	r.Key(gofig.Int, "", defaultFailures,
+		"The consecutive failures of a service's operations after which its "+
+			"circuit opens; 0 disables the circuit breaker",
+		"rexray.breaker.failures")
+	r.Key(gofig.String, "", defaultCoolDown.String(),
+		"How long a service's operations fail fast after its circuit opens",
+		"rexray.breaker.coolDown")
+	gofig.Register(r)
+}
+
+// Error is returned by an operation that failed fast because its service's
+// circuit is open.
+type Error struct {
+
+	// Service is the name of the service.
+	Service string
+
+	// Until is the time after which a trial operation is let through.
+	Until time.Time
+
+	// Cause is the error of the failure that opened the circuit.
+	Cause string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf(
+		"service %s is unavailable until %s after repeated failures: %s",
+		e.Service, e.Until.Format(time.RFC3339), e.Cause)
+}
+
+// Status returns the HTTP status with which the error is reported.
+func (e *Error) Status() int {
+	return http.StatusServiceUnavailable
+}
+
+// Code returns the code of the error.
+func (e *Error) Code() errcodes.Code {
+	return errcodes.BackendUnavailable
+}
+
+// Status is the status of a service's circuit.
+type Status struct {
+	Service   string    `json:"service" yaml:"service"`
+	State     State     `json:"state" yaml:"state"`
+	Failures  int       `json:"failures" yaml:"failures"`
+	LastError string    `json:"lastError,omitempty" yaml:"lastError,omitempty"`
+	OpenedAt  time.Time `json:"openedAt,omitempty" yaml:"openedAt,omitempty"`
+	Until     time.Time `json:"until,omitempty" yaml:"until,omitempty"`
+}
+
+// Breaker is the circuit breaker of a service.
+type Breaker struct {
+	service  string
+	failures int
+	coolDown time.Duration
+	now      func() time.Time
+
+	mu        sync.Mutex
+	state     State
+	count     int
+	lastError string
+	openedAt  time.Time
+}
+
+func newBreaker(
+	service string, failures int, coolDown time.Duration) *Breaker {
+
+	return &Breaker{
+		service:  service,
+		failures: failures,
+		coolDown: coolDown,
+		now:      time.Now,
+		state:    Closed,
+	}
+}
+
+var (
+	breakers    = map[string]*Breaker{}
+	breakersRwl sync.RWMutex
+)
+
+// For returns the breaker of the configured service, or nil if the circuit
+// breaker is disabled.
+func For(config gofig.Config) *Breaker {
+	failures := config.GetInt("rexray.breaker.failures")
+	if failures <= 0 {
+		return nil
+	}
+	coolDown, err := time.ParseDuration(
+		config.GetString("rexray.breaker.coolDown"))
+	if err != nil || coolDown <= 0 {
+		coolDown = defaultCoolDown
+	}
+	service := config.GetString(apitypes.ConfigService)
+
+	breakersRwl.Lock()
+	defer breakersRwl.Unlock()
+	b, ok := breakers[service]
+	if !ok {
+		b = newBreaker(service, failures, coolDown)
+		breakers[service] = b
+	}
+	return b
+}
+
+// Get returns the status of a service's circuit. The returned flag is false
+// if the service has no breaker.
+func Get(service string) (*Status, bool) {
+	breakersRwl.RLock()
+	b, ok := breakers[service]
+	breakersRwl.RUnlock()
+	if !ok {
+		return nil, false
+	}
+	return b.Status(), true
+}
+
+// Status returns the status of the breaker's circuit.
+func (b *Breaker) Status() *Status {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s := &Status{
+		Service:   b.service,
+		State:     b.state,
+		Failures:  b.count,
+		LastError: b.lastError,
+	}
+	if b.state != Closed {
+		s.OpenedAt = b.openedAt.UTC()
+		s.Until = b.openedAt.Add(b.coolDown).UTC()
+	}
+	return s
+}
+
+// allow returns an error if an operation must fail fast. The operation that
+// is allowed after the cool-down is the trial.
+func (b *Breaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case Open:
+		if b.now().Before(b.openedAt.Add(b.coolDown)) {
+			return b.error()
+		}
+		b.state = HalfOpen
+		return nil
+	case HalfOpen:
+		return b.error()
+	}
+	return nil
+}
+
+func (b *Breaker) error() error {
+	return &Error{
+		Service: b.service,
+		Until:   b.openedAt.Add(b.coolDown).UTC(),
+		Cause:   b.lastError,
+	}
+}
+
+// record records the result of an operation and returns the state the
+// circuit changed to, or an empty state if it did not change.
+func (b *Breaker) record(err error) State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !isFailure(err) {
+		b.count = 0
+		if b.state == Closed {
+			return ""
+		}
+		b.state = Closed
+		return Closed
+	}
+	b.count++
+	b.lastError = err.Error()
+	if b.state == HalfOpen || b.state == Closed && b.count >= b.failures {
+		b.state = Open
+		b.openedAt = b.now()
+		return Open
+	}
+	return ""
+}
+
+// isFailure returns a flag indicating whether an error is a failure of the
+// storage platform rather than of the request, such as a request for a
+// volume that does not exist or an operation the driver does not implement.
+func isFailure(err error) bool {
+	if err == nil || err == apitypes.ErrNotImplemented {
+		return false
+	}
+	if se, ok := err.(interface {
+		Status() int
+	}); ok {
+		return se.Status() >= http.StatusInternalServerError
+	}
+	return !strings.Contains(strings.ToLower(err.Error()), "not found")
+}
+
+// call performs an operation unless the circuit is open.
+func (b *Breaker) call(
+	ctx apitypes.Context, operation string, f func() error) error {
+
+	if err := b.allow(); err != nil {
+		return err
+	}
+	err := f()
+	switch b.record(err) {
+	case Open:
+		ctx.WithFields(map[string]interface{}{
+			"service":   b.service,
+			"operation": operation,
+			"coolDown":  b.coolDown,
+		}).WithError(err).Warn("service circuit opened")
+	case Closed:
+		ctx.WithField("service", b.service).Info("service circuit closed")
+	}
+	return err
+}
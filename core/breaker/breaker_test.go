@@ -0,0 +1,91 @@
+package breaker
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/emccode/libstorage/api/context"
+	apitypes "github.com/emccode/libstorage/api/types"
+)
+
+type statusError int
+
+func (e statusError) Error() string { return "status error" }
+func (e statusError) Status() int   { return int(e) }
+
+func TestIsFailure(t *testing.T) {
+	for err, failure := range map[error]bool{
+		errors.New("connection refused"):   true,
+		errors.New("volume not found"):     false,
+		apitypes.ErrNotImplemented:         false,
+		statusError(400):                   false,
+		statusError(504):                   true,
+		&Error{Service: "ebs"}:             true,
+		errors.New("internal error (500)"): true,
+	} {
+		if isFailure(err) != failure {
+			t.Fatalf("%v failure=%v", err, !failure)
+		}
+	}
+	if isFailure(nil) {
+		t.Fatal("nil is a failure")
+	}
+}
+
+func TestBreaker(t *testing.T) {
+	now := time.Unix(0, 0)
+	b := newBreaker("ebs", 3, 30*time.Second)
+	b.now = func() time.Time { return now }
+	ctx := context.Background()
+	errDown := errors.New("connection refused")
+	fail := func() error { return errDown }
+	succeed := func() error { return nil }
+
+	// successes reset the count of consecutive failures
+	b.call(ctx, "op", fail)
+	b.call(ctx, "op", fail)
+	b.call(ctx, "op", succeed)
+	b.call(ctx, "op", fail)
+	if s := b.Status(); s.State != Closed || s.Failures != 1 {
+		t.Fatalf("status=%+v", s)
+	}
+
+	b.call(ctx, "op", fail)
+	b.call(ctx, "op", fail)
+	if s := b.Status(); s.State != Open ||
+		!s.Until.Equal(now.Add(30*time.Second)) {
+		t.Fatalf("status=%+v", s)
+	}
+
+	// operations fail fast during the cool-down
+	called := false
+	err := b.call(ctx, "op", func() error {
+		called = true
+		return nil
+	})
+	if _, ok := err.(*Error); !ok || called {
+		t.Fatalf("err=%v called=%v", err, called)
+	}
+
+	// a failed trial opens the circuit again
+	now = now.Add(30 * time.Second)
+	if err := b.call(ctx, "op", fail); err != errDown {
+		t.Fatalf("err=%v", err)
+	}
+	if s := b.Status(); s.State != Open || !s.OpenedAt.Equal(now) {
+		t.Fatalf("status=%+v", s)
+	}
+
+	// operations fail fast while a trial is performed
+	now = now.Add(30 * time.Second)
+	b.call(ctx, "op", func() error {
+		if _, ok := b.call(ctx, "op", succeed).(*Error); !ok {
+			t.Fatal("operation performed during trial")
+		}
+		return nil
+	})
+	if s := b.Status(); s.State != Closed || s.Failures != 0 {
+		t.Fatalf("status=%+v", s)
+	}
+}
@@ -0,0 +1,347 @@
+package breaker
+
+import (
+	"github.com/akutz/gofig"
+	apitypes "github.com/emccode/libstorage/api/types"
+)
+
+type client struct {
+	apitypes.Client
+	b *Breaker
+}
+
+// Wrap returns a libStorage client whose storage and integration operations
+// fail fast while the configured service's circuit is open. The client is
+// returned as is if the circuit breaker is disabled.
+func Wrap(config gofig.Config, c apitypes.Client) apitypes.Client {
+	b := For(config)
+	if b == nil {
+		return c
+	}
+	return &client{Client: c, b: b}
+}
+
+func (c *client) Storage() apitypes.StorageDriver {
+	return &storageDriver{StorageDriver: c.Client.Storage(), b: c.b}
+}
+
+func (c *client) Integration() apitypes.IntegrationDriver {
+	return &integrationDriver{IntegrationDriver: c.Client.Integration(), b: c.b}
+}
+
+type storageDriver struct {
+	apitypes.StorageDriver
+	b *Breaker
+}
+
+func (d *storageDriver) Volumes(
+	ctx apitypes.Context,
+	opts *apitypes.VolumesOpts) ([]*apitypes.Volume, error) {
+
+	var vols []*apitypes.Volume
+	err := d.b.call(ctx, "storage.Volumes", func() (err error) {
+		vols, err = d.StorageDriver.Volumes(ctx, opts)
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+	return vols, nil
+}
+
+func (d *storageDriver) VolumeInspect(
+	ctx apitypes.Context,
+	volumeID string,
+	opts *apitypes.VolumeInspectOpts) (*apitypes.Volume, error) {
+
+	var vol *apitypes.Volume
+	err := d.b.call(ctx, "storage.VolumeInspect", func() (err error) {
+		vol, err = d.StorageDriver.VolumeInspect(ctx, volumeID, opts)
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+	return vol, nil
+}
+
+func (d *storageDriver) VolumeCreate(
+	ctx apitypes.Context,
+	volumeName string,
+	opts *apitypes.VolumeCreateOpts) (*apitypes.Volume, error) {
+
+	var vol *apitypes.Volume
+	err := d.b.call(ctx, "storage.VolumeCreate", func() (err error) {
+		vol, err = d.StorageDriver.VolumeCreate(ctx, volumeName, opts)
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+	return vol, nil
+}
+
+func (d *storageDriver) VolumeCreateFromSnapshot(
+	ctx apitypes.Context,
+	snapshotID, volumeName string,
+	opts *apitypes.VolumeCreateOpts) (*apitypes.Volume, error) {
+
+	var vol *apitypes.Volume
+	err := d.b.call(ctx, "storage.VolumeCreateFromSnapshot",
+		func() (err error) {
+			vol, err = d.StorageDriver.VolumeCreateFromSnapshot(
+				ctx, snapshotID, volumeName, opts)
+			return
+		})
+	if err != nil {
+		return nil, err
+	}
+	return vol, nil
+}
+
+func (d *storageDriver) VolumeCopy(
+	ctx apitypes.Context,
+	volumeID, volumeName string,
+	opts apitypes.Store) (*apitypes.Volume, error) {
+
+	var vol *apitypes.Volume
+	err := d.b.call(ctx, "storage.VolumeCopy", func() (err error) {
+		vol, err = d.StorageDriver.VolumeCopy(
+			ctx, volumeID, volumeName, opts)
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+	return vol, nil
+}
+
+func (d *storageDriver) VolumeSnapshot(
+	ctx apitypes.Context,
+	volumeID, snapshotName string,
+	opts apitypes.Store) (*apitypes.Snapshot, error) {
+
+	var snap *apitypes.Snapshot
+	err := d.b.call(ctx, "storage.VolumeSnapshot", func() (err error) {
+		snap, err = d.StorageDriver.VolumeSnapshot(
+			ctx, volumeID, snapshotName, opts)
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
+
+func (d *storageDriver) VolumeRemove(
+	ctx apitypes.Context,
+	volumeID string,
+	opts apitypes.Store) error {
+
+	return d.b.call(ctx, "storage.VolumeRemove", func() error {
+		return d.StorageDriver.VolumeRemove(ctx, volumeID, opts)
+	})
+}
+
+func (d *storageDriver) VolumeAttach(
+	ctx apitypes.Context,
+	volumeID string,
+	opts *apitypes.VolumeAttachOpts) (*apitypes.Volume, string, error) {
+
+	var (
+		vol   *apitypes.Volume
+		token string
+	)
+	err := d.b.call(ctx, "storage.VolumeAttach", func() (err error) {
+		vol, token, err = d.StorageDriver.VolumeAttach(ctx, volumeID, opts)
+		return
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return vol, token, nil
+}
+
+func (d *storageDriver) VolumeDetach(
+	ctx apitypes.Context,
+	volumeID string,
+	opts *apitypes.VolumeDetachOpts) (*apitypes.Volume, error) {
+
+	var vol *apitypes.Volume
+	err := d.b.call(ctx, "storage.VolumeDetach", func() (err error) {
+		vol, err = d.StorageDriver.VolumeDetach(ctx, volumeID, opts)
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+	return vol, nil
+}
+
+func (d *storageDriver) Snapshots(
+	ctx apitypes.Context,
+	opts apitypes.Store) ([]*apitypes.Snapshot, error) {
+
+	var snaps []*apitypes.Snapshot
+	err := d.b.call(ctx, "storage.Snapshots", func() (err error) {
+		snaps, err = d.StorageDriver.Snapshots(ctx, opts)
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+	return snaps, nil
+}
+
+func (d *storageDriver) SnapshotInspect(
+	ctx apitypes.Context,
+	snapshotID string,
+	opts apitypes.Store) (*apitypes.Snapshot, error) {
+
+	var snap *apitypes.Snapshot
+	err := d.b.call(ctx, "storage.SnapshotInspect", func() (err error) {
+		snap, err = d.StorageDriver.SnapshotInspect(ctx, snapshotID, opts)
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
+
+func (d *storageDriver) SnapshotCopy(
+	ctx apitypes.Context,
+	snapshotID, snapshotName, destinationID string,
+	opts apitypes.Store) (*apitypes.Snapshot, error) {
+
+	var snap *apitypes.Snapshot
+	err := d.b.call(ctx, "storage.SnapshotCopy", func() (err error) {
+		snap, err = d.StorageDriver.SnapshotCopy(
+			ctx, snapshotID, snapshotName, destinationID, opts)
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
+
+func (d *storageDriver) SnapshotRemove(
+	ctx apitypes.Context,
+	snapshotID string,
+	opts apitypes.Store) error {
+
+	return d.b.call(ctx, "storage.SnapshotRemove", func() error {
+		return d.StorageDriver.SnapshotRemove(ctx, snapshotID, opts)
+	})
+}
+
+type integrationDriver struct {
+	apitypes.IntegrationDriver
+	b *Breaker
+}
+
+func (d *integrationDriver) List(
+	ctx apitypes.Context,
+	opts apitypes.Store) ([]apitypes.VolumeMapping, error) {
+
+	var vols []apitypes.VolumeMapping
+	err := d.b.call(ctx, "integration.List", func() (err error) {
+		vols, err = d.IntegrationDriver.List(ctx, opts)
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+	return vols, nil
+}
+
+func (d *integrationDriver) Inspect(
+	ctx apitypes.Context,
+	volumeName string,
+	opts apitypes.Store) (apitypes.VolumeMapping, error) {
+
+	var vol apitypes.VolumeMapping
+	err := d.b.call(ctx, "integration.Inspect", func() (err error) {
+		vol, err = d.IntegrationDriver.Inspect(ctx, volumeName, opts)
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+	return vol, nil
+}
+
+func (d *integrationDriver) Mount(
+	ctx apitypes.Context,
+	volumeID, volumeName string,
+	opts *apitypes.VolumeMountOpts) (string, *apitypes.Volume, error) {
+
+	var (
+		mountPath string
+		vol       *apitypes.Volume
+	)
+	err := d.b.call(ctx, "integration.Mount", func() (err error) {
+		mountPath, vol, err = d.IntegrationDriver.Mount(
+			ctx, volumeID, volumeName, opts)
+		return
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	return mountPath, vol, nil
+}
+
+func (d *integrationDriver) Unmount(
+	ctx apitypes.Context,
+	volumeID, volumeName string,
+	opts apitypes.Store) error {
+
+	return d.b.call(ctx, "integration.Unmount", func() error {
+		return d.IntegrationDriver.Unmount(
+			ctx, volumeID, volumeName, opts)
+	})
+}
+
+func (d *integrationDriver) Path(
+	ctx apitypes.Context,
+	volumeID, volumeName string,
+	opts apitypes.Store) (string, error) {
+
+	var mountPath string
+	err := d.b.call(ctx, "integration.Path", func() (err error) {
+		mountPath, err = d.IntegrationDriver.Path(
+			ctx, volumeID, volumeName, opts)
+		return
+	})
+	if err != nil {
+		return "", err
+	}
+	return mountPath, nil
+}
+
+func (d *integrationDriver) Create(
+	ctx apitypes.Context,
+	volumeName string,
+	opts *apitypes.VolumeCreateOpts) (*apitypes.Volume, error) {
+
+	var vol *apitypes.Volume
+	err := d.b.call(ctx, "integration.Create", func() (err error) {
+		vol, err = d.IntegrationDriver.Create(ctx, volumeName, opts)
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+	return vol, nil
+}
+
+func (d *integrationDriver) Remove(
+	ctx apitypes.Context,
+	volumeName string,
+	opts apitypes.Store) error {
+
+	return d.b.call(ctx, "integration.Remove", func() error {
+		return d.IntegrationDriver.Remove(ctx, volumeName, opts)
+	})
+}
@@ -0,0 +1,164 @@
+// Package capacity reports the total and available capacity of a service's
+// storage, and the topology of that capacity, ex. the availability zone or
+// pool from which volumes are provisioned, so that the CSI controller and
+// external schedulers may decide where to place volumes.
+package capacity
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	apitypes "github.com/emccode/libstorage/api/types"
+)
+
+// The keys of a capacity's topology.
+const (
+	TopologyRegion = "region"
+	TopologyZone   = "zone"
+	TopologyPool   = "pool"
+)
+
+// Capacity is the capacity of a segment of a service's storage.
+type Capacity struct {
+
+	// Service is the name of the service.
+	Service string `json:"service" yaml:"service"`
+
+	// Driver is the name of the service's storage driver.
+	Driver string `json:"driver" yaml:"driver"`
+
+	// TotalBytes is the size of the storage. It is zero if the storage is
+	// unlimited or its size is unknown.
+	TotalBytes int64 `json:"totalBytes" yaml:"totalBytes"`
+
+	// AvailableBytes is the size of the storage that may still be
+	// provisioned. It is zero if the storage is unlimited or its size is
+	// unknown.
+	AvailableBytes int64 `json:"availableBytes" yaml:"availableBytes"`
+
+	// Unlimited indicates the storage is not limited by a pool, ex. a cloud
+	// provider's block storage.
+	Unlimited bool `json:"unlimited,omitempty" yaml:"unlimited,omitempty"`
+
+	// Topology is the segment of the storage the capacity describes, ex.
+	// its zone and pool.
+	Topology map[string]string `json:"topology,omitempty" yaml:"topology,omitempty"`
+}
+
+// Matches returns a flag indicating whether or not the capacity is in the
+// provided topology segment. Keys of the segment that the capacity does not
+// report are ignored.
+func (c *Capacity) Matches(segment map[string]string) bool {
+	for k, v := range segment {
+		if cv, ok := c.Topology[k]; ok && !strings.EqualFold(cv, v) {
+			return false
+		}
+	}
+	return true
+}
+
+// Reporter is implemented by storage drivers that are able to report their
+// capacity natively.
+type Reporter interface {
+
+	// Capacity returns the capacity of the driver's storage.
+	Capacity(
+		ctx apitypes.Context,
+		opts apitypes.Store) ([]*Capacity, error)
+}
+
+// Func returns the capacity of a storage platform with its native tools or
+// API.
+type Func func(ctx apitypes.Context, config gofig.Config) ([]*Capacity, error)
+
+var (
+	funcs    = map[string]Func{}
+	funcsRwl sync.RWMutex
+)
+
+// Register registers the function that reports the capacity of the storage
+// driver with the provided name.
+func Register(driverName string, f Func) {
+	funcsRwl.Lock()
+	defer funcsRwl.Unlock()
+	funcs[strings.ToLower(driverName)] = f
+}
+
+func lookup(driverName string) (Func, bool) {
+	funcsRwl.RLock()
+	defer funcsRwl.RUnlock()
+	f, ok := funcs[strings.ToLower(driverName)]
+	return f, ok
+}
+
+// Get returns the capacity of the service with the provided name. The
+// configured service is used if the name is empty.
+func Get(
+	ctx apitypes.Context,
+	config gofig.Config,
+	client apitypes.Client,
+	service string) ([]*Capacity, error) {
+
+	if service == "" {
+		service = config.GetString(apitypes.ConfigService)
+	}
+
+	svcs, err := client.API().Services(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var driverName string
+	for name, svc := range svcs {
+		if strings.EqualFold(name, service) && svc.Driver != nil {
+			service, driverName = name, svc.Driver.Name
+			break
+		}
+	}
+	if driverName == "" {
+		return nil, goof.WithField("service", service, "unknown service")
+	}
+
+	var all []*Capacity
+	if r, ok := client.Storage().(Reporter); ok &&
+		strings.EqualFold(service, config.GetString(apitypes.ConfigService)) {
+		all, err = r.Capacity(ctx, nil)
+	} else if f, ok := lookup(driverName); ok {
+		all, err = f(ctx, config)
+	} else {
+		return nil, goof.WithFields(goof.Fields{
+			"service": service,
+			"driver":  driverName,
+		}, "driver does not report capacity")
+	}
+	if err != nil {
+		return nil, goof.WithFieldsE(goof.Fields{
+			"service": service,
+			"driver":  driverName,
+		}, "error getting capacity", err)
+	}
+
+	for _, c := range all {
+		c.Service = service
+		c.Driver = driverName
+	}
+	return all, nil
+}
+
+// Available returns the capacity available in the provided topology
+// segment. The returned flag is true if the capacity of any matching
+// segment is unlimited.
+func Available(all []*Capacity, segment map[string]string) (int64, bool) {
+	var avail int64
+	for _, c := range all {
+		if !c.Matches(segment) {
+			continue
+		}
+		if c.Unlimited {
+			return 0, true
+		}
+		avail += c.AvailableBytes
+	}
+	return avail, false
+}
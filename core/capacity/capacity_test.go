@@ -0,0 +1,46 @@
+package capacity
+
+import "testing"
+
+func TestAvailable(t *testing.T) {
+	all := []*Capacity{
+		{AvailableBytes: 10, Topology: map[string]string{
+			TopologyZone: "a", TopologyPool: "p1"}},
+		{AvailableBytes: 20, Topology: map[string]string{
+			TopologyZone: "a", TopologyPool: "p2"}},
+		{AvailableBytes: 40, Topology: map[string]string{
+			TopologyZone: "b", TopologyPool: "p1"}},
+	}
+
+	tests := []struct {
+		segment map[string]string
+		avail   int64
+	}{
+		{nil, 70},
+		{map[string]string{TopologyZone: "a"}, 30},
+		{map[string]string{TopologyZone: "A", TopologyPool: "p2"}, 20},
+		{map[string]string{TopologyPool: "p1"}, 50},
+		{map[string]string{TopologyZone: "c"}, 0},
+		{map[string]string{"rack": "r1"}, 70},
+	}
+	for _, tt := range tests {
+		avail, unlimited := Available(all, tt.segment)
+		if avail != tt.avail || unlimited {
+			t.Errorf("Available(%v)=%d,%v; want %d,false",
+				tt.segment, avail, unlimited, tt.avail)
+		}
+	}
+
+	all = append(all, &Capacity{
+		Unlimited: true,
+		Topology:  map[string]string{TopologyZone: "b"},
+	})
+	if _, unlimited := Available(
+		all, map[string]string{TopologyZone: "b"}); !unlimited {
+		t.Error("zone b should be unlimited")
+	}
+	if _, unlimited := Available(
+		all, map[string]string{TopologyZone: "a"}); unlimited {
+		t.Error("zone a should not be unlimited")
+	}
+}
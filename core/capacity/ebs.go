@@ -0,0 +1,36 @@
+package capacity
+
+import (
+	"github.com/akutz/gofig"
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/core/ebs"
+)
+
+func init() {
+	Register("ebs", ebsCapacity)
+	Register("ec2", ebsCapacity)
+}
+
+// ebsCapacity reports that EBS volumes are unlimited by any pool and may be
+// created in the instance's availability zone, since they may only be
+// attached to instances in the zone in which they were created.
+func ebsCapacity(
+	ctx apitypes.Context, config gofig.Config) ([]*Capacity, error) {
+
+	id, err := ebs.NewMetadataFromConfig(config).Identity()
+	if err != nil {
+		return nil, err
+	}
+	region := config.GetString("ebs.region")
+	if region == "" {
+		region = id.Region
+	}
+	return []*Capacity{{
+		Unlimited: true,
+		Topology: map[string]string{
+			TopologyRegion: region,
+			TopologyZone:   id.AvailabilityZone,
+		},
+	}}, nil
+}
@@ -0,0 +1,302 @@
+// Package capture records the outbound requests that storage drivers make to
+// their providers' APIs, with credentials and secrets redacted, so that a
+// misbehaving cloud call can be inspected after the fact.
+package capture
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/akutz/gofig"
+	apitypes "github.com/emccode/libstorage/api/types"
+)
+
+const (
+	defaultSize        = 100
+	defaultMaxBodySize = 16 * 1024
+)
+
+// driverHosts are the API hosts matched for a service whose driver is known
+// when the service does not configure debug.captureHosts.
+var driverHosts = map[string][]string{
+	"ebs":     {"amazonaws.com"},
+	"ec2":     {"amazonaws.com"},
+	"efs":     {"amazonaws.com"},
+	"gce":     {"googleapis.com"},
+	"gcepd":   {"googleapis.com"},
+	"azureud": {"azure.com", "microsoftonline.com"},
+}
+
+func init() {
+	r := gofig.NewRegistration("Debug Capture")
+	r.Key(gofig.Int, "", defaultSize,
+		"The number of captured exchanges retained",
+		"rexray.debug.capture.size")
+	r.Key(gofig.Int, "", defaultMaxBodySize,
+		"The number of bytes of a request or response body that are captured",
+		"rexray.debug.capture.maxBodySize")
+	gofig.Register(r)
+}
+
+// Exchange is a captured request to a provider API and its response.
+type Exchange struct {
+	ID              int64       `json:"id" yaml:"id"`
+	Service         string      `json:"service" yaml:"service"`
+	Started         time.Time   `json:"started" yaml:"started"`
+	Duration        string      `json:"duration" yaml:"duration"`
+	Method          string      `json:"method" yaml:"method"`
+	URL             string      `json:"url" yaml:"url"`
+	RequestHeaders  http.Header `json:"requestHeaders,omitempty" yaml:"requestHeaders,omitempty"`
+	RequestBody     string      `json:"requestBody,omitempty" yaml:"requestBody,omitempty"`
+	Status          int         `json:"status,omitempty" yaml:"status,omitempty"`
+	ResponseHeaders http.Header `json:"responseHeaders,omitempty" yaml:"responseHeaders,omitempty"`
+	ResponseBody    string      `json:"responseBody,omitempty" yaml:"responseBody,omitempty"`
+	Error           string      `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// Buffer is a fixed-size ring of captured exchanges.
+type Buffer struct {
+	sync.Mutex
+	exchanges []*Exchange
+	next      int
+	full      bool
+	lastID    int64
+}
+
+// NewBuffer returns a buffer that retains the most recent size exchanges.
+func NewBuffer(size int) *Buffer {
+	if size <= 0 {
+		size = defaultSize
+	}
+	return &Buffer{exchanges: make([]*Exchange, size)}
+}
+
+// Add records an exchange, evicting the oldest one if the buffer is full.
+func (b *Buffer) Add(e *Exchange) {
+	b.Lock()
+	defer b.Unlock()
+	b.lastID++
+	e.ID = b.lastID
+	b.exchanges[b.next] = e
+	b.next = (b.next + 1) % len(b.exchanges)
+	if b.next == 0 {
+		b.full = true
+	}
+}
+
+// List returns the captured exchanges, oldest first. If service is not empty
+// only that service's exchanges are returned.
+func (b *Buffer) List(service string) []*Exchange {
+	b.Lock()
+	defer b.Unlock()
+	all := []*Exchange{}
+	add := func(e *Exchange) {
+		if e != nil && (service == "" || strings.EqualFold(e.Service, service)) {
+			all = append(all, e)
+		}
+	}
+	if b.full {
+		for _, e := range b.exchanges[b.next:] {
+			add(e)
+		}
+	}
+	for _, e := range b.exchanges[:b.next] {
+		add(e)
+	}
+	return all
+}
+
+// Clear removes all of the captured exchanges.
+func (b *Buffer) Clear() {
+	b.Lock()
+	defer b.Unlock()
+	b.exchanges = make([]*Exchange, len(b.exchanges))
+	b.next = 0
+	b.full = false
+}
+
+var (
+	buffer   = NewBuffer(defaultSize)
+	bufferRW sync.RWMutex
+)
+
+// Default returns the buffer into which this process captures exchanges.
+func Default() *Buffer {
+	bufferRW.RLock()
+	defer bufferRW.RUnlock()
+	return buffer
+}
+
+// target is a service whose provider API calls are captured.
+type target struct {
+	service string
+	hosts   []string
+}
+
+// Transport is an http.RoundTripper that captures the exchanges with the
+// hosts of the services for which capture is enabled.
+type Transport struct {
+	Base        http.RoundTripper
+	Buffer      *Buffer
+	MaxBodySize int64
+	targets     []*target
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	service, ok := t.match(req.URL.Hostname())
+	if !ok {
+		return t.Base.RoundTrip(req)
+	}
+
+	e := &Exchange{
+		Service:        service,
+		Started:        time.Now().UTC(),
+		Method:         req.Method,
+		URL:            redactURL(req.URL),
+		RequestHeaders: redactHeaders(req.Header),
+	}
+
+	if req.Body != nil {
+		body, rc, err := t.peek(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		req.Body = rc
+		e.RequestBody = redactBody(body)
+	}
+
+	res, err := t.Base.RoundTrip(req)
+	e.Duration = time.Since(e.Started).String()
+	if err != nil {
+		e.Error = err.Error()
+		t.Buffer.Add(e)
+		return nil, err
+	}
+
+	e.Status = res.StatusCode
+	e.ResponseHeaders = redactHeaders(res.Header)
+	if res.Body != nil {
+		body, rc, err := t.peek(res.Body)
+		if err != nil {
+			e.Error = err.Error()
+		}
+		res.Body = rc
+		e.ResponseBody = redactBody(body)
+	}
+
+	t.Buffer.Add(e)
+	return res, err
+}
+
+func (t *Transport) match(host string) (string, bool) {
+	host = strings.ToLower(host)
+	for _, tg := range t.targets {
+		for _, h := range tg.hosts {
+			if host == h || strings.HasSuffix(host, "."+h) {
+				return tg.service, true
+			}
+		}
+	}
+	return "", false
+}
+
+// peek reads up to MaxBodySize bytes of a body and returns them along with a
+// reader that yields the entire, unconsumed body.
+func (t *Transport) peek(rc io.ReadCloser) ([]byte, io.ReadCloser, error) {
+	buf, err := ioutil.ReadAll(io.LimitReader(rc, t.MaxBodySize))
+	if err != nil {
+		rc.Close()
+		return nil, nil, err
+	}
+	return buf, &readCloser{
+		Reader: io.MultiReader(bytes.NewReader(buf), rc),
+		Closer: rc,
+	}, nil
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// targets returns the services that enable debug.capture along with the API
+// hosts that are matched for each.
+func targets(config gofig.Config) []*target {
+	services, ok := config.Get(apitypes.ConfigServices).(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	tgs := []*target{}
+	for name := range services {
+		key := func(k string) string {
+			return fmt.Sprintf("%s.%s.%s", apitypes.ConfigServices, name, k)
+		}
+		if !config.GetBool(key("debug.capture")) {
+			continue
+		}
+		hosts := config.GetStringSlice(key("debug.captureHosts"))
+		if len(hosts) == 0 {
+			driver := config.GetString(key("driver"))
+			if driver == "" {
+				driver = name
+			}
+			hosts = driverHosts[strings.ToLower(driver)]
+		}
+		if len(hosts) == 0 {
+			continue
+		}
+		tg := &target{service: name}
+		for _, h := range hosts {
+			tg.hosts = append(tg.hosts, strings.ToLower(h))
+		}
+		tgs = append(tgs, tg)
+	}
+	return tgs
+}
+
+// Install replaces http.DefaultTransport, which the storage drivers use to
+// reach their providers, with a Transport if any service enables
+// debug.capture. Install returns a flag indicating whether capture is
+// enabled.
+func Install(ctx apitypes.Context, config gofig.Config) bool {
+	tgs := targets(config)
+	if len(tgs) == 0 {
+		return false
+	}
+
+	b := NewBuffer(config.GetInt("rexray.debug.capture.size"))
+	bufferRW.Lock()
+	buffer = b
+	bufferRW.Unlock()
+
+	base := http.DefaultTransport
+	if t, ok := base.(*Transport); ok {
+		base = t.Base
+	}
+	maxBody := int64(config.GetInt("rexray.debug.capture.maxBodySize"))
+	if maxBody <= 0 {
+		maxBody = defaultMaxBodySize
+	}
+	http.DefaultTransport = &Transport{
+		Base:        base,
+		Buffer:      b,
+		MaxBodySize: maxBody,
+		targets:     tgs,
+	}
+
+	for _, tg := range tgs {
+		ctx.WithFields(map[string]interface{}{
+			"service": tg.service,
+			"hosts":   tg.hosts,
+		}).Warn("capturing provider api calls; disable when finished")
+	}
+	return true
+}
@@ -0,0 +1,69 @@
+package capture
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const redacted = "REDACTED"
+
+// secretName matches the names of headers, query parameters, and body fields
+// whose values are credentials or secrets.
+var secretName = regexp.MustCompile(
+	`(?i)(authorization|password|passwd|secret|token|signature|credential|` +
+		`accesskey|access_key|apikey|api_key|private_?key|cookie|session)`)
+
+var (
+	// jsonSecret matches a JSON string field with a secret name.
+	jsonSecret = regexp.MustCompile(
+		`("[^"]*` + secretName.String() + `[^"]*"\s*:\s*)"(?:[^"\\]|\\.)*"`)
+
+	// xmlSecret matches an XML element with a secret name, such as the
+	// SecretAccessKey returned by STS.
+	xmlSecret = regexp.MustCompile(
+		`(<([A-Za-z]*` + secretName.String() + `[A-Za-z]*)>)[^<]*(</[A-Za-z]*>)`)
+
+	// formSecret matches a form-encoded field with a secret name.
+	formSecret = regexp.MustCompile(
+		`((?:^|&)[^=&]*` + secretName.String() + `[^=&]*=)[^&]*`)
+)
+
+func redactHeaders(h http.Header) http.Header {
+	if len(h) == 0 {
+		return nil
+	}
+	rh := http.Header{}
+	for k, v := range h {
+		if secretName.MatchString(k) {
+			rh[k] = []string{redacted}
+			continue
+		}
+		rh[k] = append([]string{}, v...)
+	}
+	return rh
+}
+
+func redactURL(u *url.URL) string {
+	ru := *u
+	ru.User = nil
+	q := ru.Query()
+	for k := range q {
+		if secretName.MatchString(k) {
+			q.Set(k, redacted)
+		}
+	}
+	ru.RawQuery = q.Encode()
+	return ru.String()
+}
+
+func redactBody(buf []byte) string {
+	s := string(buf)
+	s = jsonSecret.ReplaceAllString(s, `${1}"`+redacted+`"`)
+	s = xmlSecret.ReplaceAllString(s, `${1}`+redacted+`${4}`)
+	if !strings.ContainsAny(s, "{<") {
+		s = formSecret.ReplaceAllString(s, `${1}`+redacted)
+	}
+	return s
+}
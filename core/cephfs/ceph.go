@@ -0,0 +1,139 @@
+package cephfs
+
+import (
+	"bytes"
+	"encoding/json"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+)
+
+// errNotFound is the exit status of a ceph command whose subject does not
+// exist.
+const errNotFound = int(syscall.ENOENT)
+
+// ceph runs the ceph command as the provisioning user.
+type ceph struct {
+	monitors string
+	user     string
+	keyring  string
+	timeout  time.Duration
+}
+
+func newCeph(config gofig.Config) *ceph {
+	c := &ceph{
+		monitors: config.GetString("cephfs.monitors"),
+		user:     config.GetString("cephfs.user"),
+		keyring:  config.GetString("cephfs.keyring"),
+		timeout:  30 * time.Second,
+	}
+	if c.user == "" {
+		c.user = defaultUser
+	}
+	if t, err := time.ParseDuration(
+		config.GetString("cephfs.timeout")); err == nil && t > 0 {
+		c.timeout = t
+	}
+	return c
+}
+
+// cephError is the error of a ceph command that failed.
+type cephError struct {
+	args   []string
+	status int
+	stderr string
+}
+
+func (e *cephError) Error() string {
+	return "ceph " + strings.Join(e.args, " ") + ": " + e.stderr
+}
+
+// isNotFound returns a flag indicating whether the error is that of a ceph
+// command whose subject does not exist.
+func isNotFound(err error) bool {
+	e, ok := err.(*cephError)
+	return ok && e.status == errNotFound
+}
+
+// run runs a ceph command and decodes its JSON output into result unless
+// result is nil.
+func (c *ceph) run(result interface{}, args ...string) error {
+	cmdArgs := []string{"--id", c.user}
+	if c.keyring != "" {
+		cmdArgs = append(cmdArgs, "--keyring", c.keyring)
+	}
+	if c.monitors != "" {
+		cmdArgs = append(cmdArgs, "-m", c.monitors)
+	}
+	if result != nil {
+		args = append(args, "--format", "json")
+	}
+	cmdArgs = append(cmdArgs, args...)
+
+	cmd := exec.Command("ceph", cmdArgs...)
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	if err := cmd.Start(); err != nil {
+		return goof.WithFieldE("args", args, "error starting ceph", err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			e := &cephError{
+				args:   args,
+				stderr: strings.TrimSpace(stderr.String()),
+			}
+			if ee, ok := err.(*exec.ExitError); ok {
+				if ws, ok := ee.Sys().(syscall.WaitStatus); ok {
+					e.status = ws.ExitStatus()
+				}
+			}
+			return e
+		}
+	case <-time.After(c.timeout):
+		cmd.Process.Kill()
+		return goof.WithFields(goof.Fields{
+			"args":    args,
+			"timeout": c.timeout,
+		}, "ceph timed out")
+	}
+
+	if result == nil || stdout.Len() == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(stdout.Bytes(), result); err != nil {
+		return goof.WithFieldE("args", args,
+			"error decoding ceph output", err)
+	}
+	return nil
+}
+
+// subvolumeInfo is the information of a subvolume, as returned by
+// ceph fs subvolume info. The quota is "infinite" if none is set.
+type subvolumeInfo struct {
+	Path       string          `json:"path"`
+	BytesQuota json.RawMessage `json:"bytes_quota"`
+	BytesUsed  int64           `json:"bytes_used"`
+	MonAddrs   []string        `json:"mon_addrs"`
+	State      string          `json:"state"`
+}
+
+// quota returns the subvolume's quota in bytes, or 0 if it has none.
+func (i *subvolumeInfo) quota() int64 {
+	n, _ := strconv.ParseInt(string(i.BytesQuota), 10, 64)
+	return n
+}
+
+// authEntity is a cephx entity, as returned by ceph auth get-or-create.
+type authEntity struct {
+	Entity string `json:"entity"`
+	Key    string `json:"key"`
+}
@@ -0,0 +1,114 @@
+// Package cephfs is a storage driver for CephFS, the shared file system
+// complement to the RBD driver. A volume is a subvolume of a CephFS file
+// system whose size is the subvolume's quota. Each volume has a cephx client
+// whose capabilities are scoped to the subvolume's path, and whose key is
+// handed to the nodes the volume is attached to so that they may mount the
+// subvolume with the kernel client or ceph-fuse. The client is removed once
+// the volume is no longer attached to any node.
+package cephfs
+
+import (
+	"strings"
+
+	"github.com/akutz/gofig"
+	"github.com/emccode/libstorage/api/registry"
+
+	"github.com/emccode/rexray/core/sharedfs"
+)
+
+const (
+	// Name is the name with which the driver is registered.
+	Name = "cephfs"
+
+	// MounterKernel mounts subvolumes with the kernel client.
+	MounterKernel = "kernel"
+
+	// MounterFuse mounts subvolumes with ceph-fuse.
+	MounterFuse = "fuse"
+
+	defaultFSName       = "cephfs"
+	defaultUser         = "admin"
+	defaultClientPrefix = "rexray."
+	defaultSize         = 16
+
+	// attachmentsKey is the key of the subvolume metadata that records the
+	// instances a volume is attached to.
+	attachmentsKey = "rexray.attachments"
+)
+
+func init() {
+	registry.RegisterStorageDriver(Name, newDriver)
+	registry.RegisterStorageExecutor(Name, newExecutor)
+	sharedfs.Register(Name, mount)
+
+	r := gofig.NewRegistration("CephFS Driver")
+	r.Key(gofig.String, "", defaultFSName,
+		"The CephFS file system in which volumes are provisioned",
+		"cephfs.fsName")
+	r.Key(gofig.String, "", "",
+		"The subvolume group in which volumes are provisioned; defaults to "+
+			"the file system's default group",
+		"cephfs.subvolumeGroup")
+	r.Key(gofig.String, "", "",
+		"The monitors, host[:port] separated by commas, if not those of "+
+			"ceph.conf",
+		"cephfs.monitors")
+	r.Key(gofig.String, "", defaultUser,
+		"The cephx user with which volumes are provisioned",
+		"cephfs.user")
+	r.Key(gofig.String, "", "",
+		"The keyring of the cephx user with which volumes are provisioned",
+		"cephfs.keyring")
+	r.Key(gofig.String, "", defaultClientPrefix,
+		"The prefix of the names of the volumes' cephx clients",
+		"cephfs.clientPrefix")
+	r.Key(gofig.String, "", MounterKernel,
+		"How nodes mount volumes: kernel or fuse",
+		"cephfs.mounter")
+	r.Key(gofig.String, "", "30s",
+		"How long to wait for a ceph command",
+		"cephfs.timeout")
+	gofig.Register(r)
+}
+
+func fsName(config gofig.Config) string {
+	if s := config.GetString("cephfs.fsName"); s != "" {
+		return s
+	}
+	return defaultFSName
+}
+
+func clientPrefix(config gofig.Config) string {
+	if p := config.GetString("cephfs.clientPrefix"); p != "" {
+		return p
+	}
+	return defaultClientPrefix
+}
+
+// clientID returns the ID of a volume's cephx client. The client's entity
+// name is client.ID.
+func clientID(prefix, volumeID string) string {
+	return prefix + volumeID
+}
+
+// clientCaps returns the capabilities of a volume's cephx client, which
+// allow it to read and write only the subvolume's path.
+func clientCaps(fs, path string) []string {
+	return []string{
+		"mon", "allow r",
+		"mds", "allow rw path=" + path,
+		"osd", "allow rw tag cephfs data=" + fs,
+	}
+}
+
+// parseAttachments returns the instances recorded in a subvolume's
+// attachments metadata.
+func parseAttachments(s string) []string {
+	iids := []string{}
+	for _, iid := range strings.Split(s, ",") {
+		if iid = strings.TrimSpace(iid); iid != "" {
+			iids = append(iids, iid)
+		}
+	}
+	return iids
+}
@@ -0,0 +1,53 @@
+package cephfs
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestParseAttachments(t *testing.T) {
+	if iids := parseAttachments(""); len(iids) != 0 {
+		t.Fatalf("iids=%v", iids)
+	}
+	iids := parseAttachments("node1, node2,,")
+	if !reflect.DeepEqual(iids, []string{"node1", "node2"}) {
+		t.Fatalf("iids=%v", iids)
+	}
+}
+
+func TestSubvolumeInfoQuota(t *testing.T) {
+	info := &subvolumeInfo{}
+	if err := json.Unmarshal([]byte(
+		`{"path":"/volumes/_nogroup/data/1f2e","bytes_quota":"infinite"}`),
+		info); err != nil {
+		t.Fatal(err)
+	}
+	if q := info.quota(); q != 0 {
+		t.Fatalf("quota=%d", q)
+	}
+	if err := json.Unmarshal([]byte(`{"bytes_quota":17179869184}`),
+		info); err != nil {
+		t.Fatal(err)
+	}
+	if q := info.quota(); q != 16*gib {
+		t.Fatalf("quota=%d", q)
+	}
+}
+
+func TestKernelMountArgs(t *testing.T) {
+	args := kernelMountArgs("10.0.0.1:6789,10.0.0.2:6789",
+		"/volumes/_nogroup/data/1f2e", "/mnt/data", "rexray.data",
+		"/var/run/rexray/cephfs/rexray.data.secret", "cephfs", true)
+	exp := []string{
+		"-t", "ceph",
+		"10.0.0.1:6789,10.0.0.2:6789:/volumes/_nogroup/data/1f2e",
+		"/mnt/data",
+		"-o", "name=rexray.data," +
+			"secretfile=/var/run/rexray/cephfs/rexray.data.secret," +
+			"mds_namespace=cephfs,ro",
+	}
+	if !reflect.DeepEqual(args, exp) {
+		t.Fatalf("args=%v", args)
+	}
+}
@@ -0,0 +1,397 @@
+package cephfs
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	"github.com/emccode/libstorage/api/context"
+	apitypes "github.com/emccode/libstorage/api/types"
+)
+
+const gib = 1024 * 1024 * 1024
+
+type driver struct {
+	config gofig.Config
+	ceph   *ceph
+	fs     string
+	group  string
+	prefix string
+}
+
+func newDriver() apitypes.StorageDriver {
+	return &driver{}
+}
+
+func (d *driver) Name() string {
+	return Name
+}
+
+func (d *driver) Init(ctx apitypes.Context, config gofig.Config) error {
+	d.config = config
+	d.ceph = newCeph(config)
+	d.fs = fsName(config)
+	d.group = config.GetString("cephfs.subvolumeGroup")
+	d.prefix = clientPrefix(config)
+
+	ctx.WithFields(map[string]interface{}{
+		"fsName":         d.fs,
+		"subvolumeGroup": d.group,
+	}).Info("initialized cephfs driver")
+	return nil
+}
+
+// instanceID returns the ID of the instance on whose behalf an operation is
+// performed.
+func instanceID(ctx apitypes.Context) string {
+	if iid, ok := ctx.Value(context.InstanceIDKey).(*apitypes.InstanceID); ok {
+		return iid.ID
+	}
+	return ""
+}
+
+func (d *driver) Type(ctx apitypes.Context) (apitypes.StorageType, error) {
+	return apitypes.NAS, nil
+}
+
+// NextDeviceInfo returns nil because volumes are not devices.
+func (d *driver) NextDeviceInfo(
+	ctx apitypes.Context) (*apitypes.NextDeviceInfo, error) {
+
+	return nil, nil
+}
+
+func (d *driver) InstanceInspect(
+	ctx apitypes.Context,
+	opts apitypes.Store) (*apitypes.Instance, error) {
+
+	iid, ok := ctx.Value(context.InstanceIDKey).(*apitypes.InstanceID)
+	if !ok {
+		return nil, goof.New("missing instance ID")
+	}
+	return &apitypes.Instance{InstanceID: iid, Name: iid.ID}, nil
+}
+
+// subvolume runs a ceph fs subvolume command, ex. "metadata ls", for the
+// driver's file system and subvolume group.
+func (d *driver) subvolume(
+	result interface{}, cmd string, args ...string) error {
+
+	cmdArgs := append([]string{"fs", "subvolume"}, strings.Fields(cmd)...)
+	cmdArgs = append(append(cmdArgs, d.fs), args...)
+	if d.group != "" {
+		cmdArgs = append(cmdArgs, "--group_name", d.group)
+	}
+	return d.ceph.run(result, cmdArgs...)
+}
+
+// attachments returns the instances a volume is attached to.
+func (d *driver) attachments(volumeID string) ([]string, error) {
+	md := map[string]string{}
+	if err := d.subvolume(&md, "metadata ls", volumeID); err != nil {
+		return nil, err
+	}
+	return parseAttachments(md[attachmentsKey]), nil
+}
+
+func (d *driver) setAttachments(volumeID string, iids []string) error {
+	if len(iids) == 0 {
+		if err := d.subvolume(nil, "metadata rm", volumeID,
+			attachmentsKey, "--force"); err != nil && !isNotFound(err) {
+			return err
+		}
+		return nil
+	}
+	return d.subvolume(nil, "metadata set", volumeID,
+		attachmentsKey, strings.Join(iids, ","))
+}
+
+// toVolume returns a libStorage volume for a subvolume. A volume's name is
+// its ID because the subvolume is referred to by name. The fields of the
+// volume are those with which nodes mount it.
+func (d *driver) toVolume(
+	ctx apitypes.Context,
+	name string,
+	info *subvolumeInfo,
+	iids []string) *apitypes.Volume {
+
+	vol := &apitypes.Volume{
+		ID:     name,
+		Name:   name,
+		Size:   info.quota() / gib,
+		Type:   d.fs,
+		Status: "available",
+		Fields: map[string]string{
+			"fsName":   d.fs,
+			"path":     info.Path,
+			"monitors": strings.Join(info.MonAddrs, ","),
+			"clientID": clientID(d.prefix, name),
+		},
+	}
+	for _, iid := range iids {
+		vol.Attachments = append(vol.Attachments, &apitypes.VolumeAttachment{
+			VolumeID: name,
+			InstanceID: &apitypes.InstanceID{
+				ID:     iid,
+				Driver: Name,
+			},
+			MountPoint: info.Path,
+			Status:     "attached",
+		})
+		vol.Status = "attached"
+	}
+	return vol
+}
+
+func (d *driver) Volumes(
+	ctx apitypes.Context,
+	opts *apitypes.VolumesOpts) ([]*apitypes.Volume, error) {
+
+	subs := []struct {
+		Name string `json:"name"`
+	}{}
+	if err := d.subvolume(&subs, "ls"); err != nil {
+		return nil, err
+	}
+
+	all := []*apitypes.Volume{}
+	for _, s := range subs {
+		vol, err := d.inspect(ctx, s.Name, opts.Attachments)
+		if err != nil {
+			// the subvolume was removed since it was listed
+			if isNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+		all = append(all, vol)
+	}
+	return all, nil
+}
+
+func (d *driver) VolumeInspect(
+	ctx apitypes.Context,
+	volumeID string,
+	opts *apitypes.VolumeInspectOpts) (*apitypes.Volume, error) {
+
+	vol, err := d.inspect(ctx, volumeID, opts.Attachments)
+	if isNotFound(err) {
+		return nil, goof.WithField("volumeID", volumeID, "volume not found")
+	}
+	return vol, err
+}
+
+func (d *driver) inspect(
+	ctx apitypes.Context,
+	volumeID string,
+	attachments bool) (*apitypes.Volume, error) {
+
+	info := &subvolumeInfo{}
+	if err := d.subvolume(info, "info", volumeID); err != nil {
+		return nil, err
+	}
+	var iids []string
+	if attachments {
+		var err error
+		if iids, err = d.attachments(volumeID); err != nil {
+			return nil, err
+		}
+	}
+	return d.toVolume(ctx, volumeID, info, iids), nil
+}
+
+func (d *driver) VolumeCreate(
+	ctx apitypes.Context,
+	name string,
+	opts *apitypes.VolumeCreateOpts) (*apitypes.Volume, error) {
+
+	size := int64(defaultSize)
+	if opts.Size != nil && *opts.Size > 0 {
+		size = *opts.Size
+	}
+	if err := d.subvolume(nil, "create", name,
+		"--size", strconv.FormatInt(size*gib, 10)); err != nil {
+		return nil, goof.WithFieldE("name", name, "error creating volume", err)
+	}
+	return d.inspect(ctx, name, false)
+}
+
+func (d *driver) VolumeCreateFromSnapshot(
+	ctx apitypes.Context,
+	snapshotID, volumeName string,
+	opts *apitypes.VolumeCreateOpts) (*apitypes.Volume, error) {
+
+	return nil, apitypes.ErrNotImplemented
+}
+
+func (d *driver) VolumeCopy(
+	ctx apitypes.Context,
+	volumeID, volumeName string,
+	opts apitypes.Store) (*apitypes.Volume, error) {
+
+	return nil, apitypes.ErrNotImplemented
+}
+
+func (d *driver) VolumeSnapshot(
+	ctx apitypes.Context,
+	volumeID, snapshotName string,
+	opts apitypes.Store) (*apitypes.Snapshot, error) {
+
+	return nil, apitypes.ErrNotImplemented
+}
+
+// VolumeRemove removes the volume's cephx client, if it was ever attached,
+// and then its subvolume.
+func (d *driver) VolumeRemove(
+	ctx apitypes.Context,
+	volumeID string,
+	opts apitypes.Store) error {
+
+	if err := d.removeClient(volumeID); err != nil {
+		return err
+	}
+	if err := d.subvolume(nil, "rm", volumeID); err != nil {
+		return goof.WithFieldE("volumeID", volumeID,
+			"error removing volume", err)
+	}
+	return nil
+}
+
+// VolumeAttach records the instance as one the volume is attached to and
+// returns the key of the volume's cephx client as the token, creating the
+// client the first time the volume is attached. A forced attach replaces the
+// other instances, although the nodes that have already mounted the volume
+// keep their access until it is detached from all of them.
+func (d *driver) VolumeAttach(
+	ctx apitypes.Context,
+	volumeID string,
+	opts *apitypes.VolumeAttachOpts) (*apitypes.Volume, string, error) {
+
+	iid := instanceID(ctx)
+	if iid == "" {
+		return nil, "", goof.New("missing instance ID")
+	}
+	info := &subvolumeInfo{}
+	if err := d.subvolume(info, "info", volumeID); err != nil {
+		return nil, "", goof.WithFieldE("volumeID", volumeID,
+			"error inspecting volume", err)
+	}
+
+	entities := []*authEntity{}
+	args := append([]string{"auth", "get-or-create",
+		"client." + clientID(d.prefix, volumeID)},
+		clientCaps(d.fs, info.Path)...)
+	if err := d.ceph.run(&entities, args...); err != nil {
+		return nil, "", goof.WithFieldE("volumeID", volumeID,
+			"error creating cephx client", err)
+	}
+	if len(entities) == 0 || entities[0].Key == "" {
+		return nil, "", goof.WithField("volumeID", volumeID,
+			"cephx client has no key")
+	}
+
+	iids, err := d.attachments(volumeID)
+	if err != nil {
+		return nil, "", err
+	}
+	if opts.Force {
+		iids = nil
+	}
+	if !contains(iids, iid) {
+		iids = append(iids, iid)
+	}
+	if err := d.setAttachments(volumeID, iids); err != nil {
+		return nil, "", goof.WithFieldsE(goof.Fields{
+			"volumeID":   volumeID,
+			"instanceID": iid,
+		}, "error recording attachment", err)
+	}
+
+	return d.toVolume(ctx, volumeID, info, iids), entities[0].Key, nil
+}
+
+// VolumeDetach removes the instance from the ones the volume is attached to,
+// or all of them if the detach is forced. The volume's cephx client is
+// removed, revoking its key, once no instances remain.
+func (d *driver) VolumeDetach(
+	ctx apitypes.Context,
+	volumeID string,
+	opts *apitypes.VolumeDetachOpts) (*apitypes.Volume, error) {
+
+	iid := instanceID(ctx)
+	iids, err := d.attachments(volumeID)
+	if err != nil {
+		return nil, err
+	}
+	remaining := []string{}
+	if !opts.Force {
+		for _, i := range iids {
+			if i != iid {
+				remaining = append(remaining, i)
+			}
+		}
+	}
+	if err := d.setAttachments(volumeID, remaining); err != nil {
+		return nil, goof.WithFieldsE(goof.Fields{
+			"volumeID":   volumeID,
+			"instanceID": iid,
+		}, "error recording detachment", err)
+	}
+	if len(remaining) == 0 {
+		if err := d.removeClient(volumeID); err != nil {
+			return nil, err
+		}
+	}
+	return d.inspect(ctx, volumeID, true)
+}
+
+func (d *driver) removeClient(volumeID string) error {
+	entity := "client." + clientID(d.prefix, volumeID)
+	if err := d.ceph.run(
+		nil, "auth", "del", entity); err != nil && !isNotFound(err) {
+		return goof.WithFieldE("entity", entity,
+			"error removing cephx client", err)
+	}
+	return nil
+}
+
+func contains(s []string, v string) bool {
+	for _, i := range s {
+		if i == v {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *driver) Snapshots(
+	ctx apitypes.Context,
+	opts apitypes.Store) ([]*apitypes.Snapshot, error) {
+
+	return nil, apitypes.ErrNotImplemented
+}
+
+func (d *driver) SnapshotInspect(
+	ctx apitypes.Context,
+	snapshotID string,
+	opts apitypes.Store) (*apitypes.Snapshot, error) {
+
+	return nil, apitypes.ErrNotImplemented
+}
+
+func (d *driver) SnapshotCopy(
+	ctx apitypes.Context,
+	snapshotID, snapshotName, destinationID string,
+	opts apitypes.Store) (*apitypes.Snapshot, error) {
+
+	return nil, apitypes.ErrNotImplemented
+}
+
+func (d *driver) SnapshotRemove(
+	ctx apitypes.Context,
+	snapshotID string,
+	opts apitypes.Store) error {
+
+	return apitypes.ErrNotImplemented
+}
@@ -0,0 +1,131 @@
+package cephfs
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/util"
+)
+
+// mount mounts a volume's subvolume with the key of its cephx client, which
+// is the token returned when the volume was attached. The key is written to
+// a file readable only by root so that it does not appear in the mount's
+// options or the process list.
+func mount(
+	ctx apitypes.Context,
+	config gofig.Config,
+	vol *apitypes.Volume,
+	token, mountPath string,
+	readOnly bool) error {
+
+	id := vol.Fields["clientID"]
+	path := vol.Fields["path"]
+	if id == "" || path == "" {
+		return goof.WithField("volumeID", vol.ID,
+			"volume has no cephfs client or path")
+	}
+	monitors := config.GetString("cephfs.monitors")
+	if monitors == "" {
+		monitors = vol.Fields["monitors"]
+	}
+	fs := vol.Fields["fsName"]
+	if fs == "" {
+		fs = fsName(config)
+	}
+
+	dir := util.RunFilePath(Name)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	var cmd *exec.Cmd
+	switch mounter := config.GetString("cephfs.mounter"); mounter {
+	case "", MounterKernel:
+		if monitors == "" {
+			return goof.New("cephfs kernel mounts require cephfs.monitors")
+		}
+		secretFile := filepath.Join(dir, id+".secret")
+		if err := ioutil.WriteFile(
+			secretFile, []byte(token), 0600); err != nil {
+			return err
+		}
+		cmd = exec.Command("mount", kernelMountArgs(
+			monitors, path, mountPath, id, secretFile, fs, readOnly)...)
+	case MounterFuse:
+		keyringFile := filepath.Join(dir, id+".keyring")
+		if err := ioutil.WriteFile(
+			keyringFile, []byte(keyring(id, token)), 0600); err != nil {
+			return err
+		}
+		cmd = exec.Command("ceph-fuse", fuseMountArgs(
+			monitors, path, mountPath, id, keyringFile, fs, readOnly)...)
+	default:
+		return goof.WithField("mounter", mounter, "invalid cephfs mounter")
+	}
+
+	ctx.WithFields(map[string]interface{}{
+		"volumeID":  vol.ID,
+		"path":      path,
+		"mountPath": mountPath,
+		"mounter":   filepath.Base(cmd.Path),
+	}).Debug("mounting cephfs subvolume")
+
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return goof.WithFieldE("output", strings.TrimSpace(string(out)),
+			"error mounting cephfs subvolume", err)
+	}
+	return nil
+}
+
+func kernelMountArgs(
+	monitors, path, mountPath, id, secretFile, fs string,
+	readOnly bool) []string {
+
+	opts := []string{
+		"name=" + id,
+		"secretfile=" + secretFile,
+		"mds_namespace=" + fs,
+	}
+	if readOnly {
+		opts = append(opts, "ro")
+	}
+	return []string{
+		"-t", "ceph",
+		monitors + ":" + path,
+		mountPath,
+		"-o", strings.Join(opts, ","),
+	}
+}
+
+func fuseMountArgs(
+	monitors, path, mountPath, id, keyringFile, fs string,
+	readOnly bool) []string {
+
+	args := []string{
+		mountPath,
+		"--id", id,
+		"--keyring", keyringFile,
+		"--client_fs", fs,
+		"-r", path,
+	}
+	if monitors != "" {
+		args = append(args, "-m", monitors)
+	}
+	if readOnly {
+		args = append(args, "-o", "ro")
+	}
+	return args
+}
+
+// keyring returns a keyring with the key of a cephx client.
+func keyring(id, key string) string {
+	return fmt.Sprintf("[client.%s]\n\tkey = %s\n", id, key)
+}
@@ -0,0 +1,326 @@
+// Package certs serves the TLS certificates of the libStorage endpoints from
+// their files and loads them again when the files change, so that renewed or
+// rotated certificates, such as SPIFFE SVIDs, are served without restarting
+// the service. The libStorage server reads its certificates only when it
+// starts.
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	apitypes "github.com/emccode/libstorage/api/types"
+)
+
+// checkInterval is the least interval between checks of whether the files
+// changed, so that a burst of connections does not stat them each time.
+const checkInterval = time.Second
+
+func init() {
+	r := gofig.NewRegistration("TLS")
+	r.Key(gofig.Bool, "", false,
+		"Load the libStorage endpoints' certificates again when their files "+
+			"change",
+		"rexray.tls.reload")
+	r.Key(gofig.String, "", "",
+		"The URI, ex. a SPIFFE ID, that the libStorage server's certificate "+
+			"must have; the server's host name is verified if it is not set",
+		"rexray.tls.serverURI")
+	r.Key(gofig.String, "", "",
+		"The URIs, separated by commas, one of which the certificates of "+
+			"the libStorage server's clients must have; a URI ending with /* "+
+			"matches the URIs under its path",
+		"rexray.tls.clientURIs")
+	gofig.Register(r)
+}
+
+// ClientURIs returns the URIs one of which the certificates of the
+// libStorage server's clients must have, or nil if any certificate signed by
+// the trusted certificates is accepted.
+func ClientURIs(config gofig.Config) []string {
+	uris := []string{}
+	for _, u := range strings.Split(
+		config.GetString("rexray.tls.clientURIs"), ",") {
+		if u = strings.TrimSpace(u); u != "" {
+			uris = append(uris, u)
+		}
+	}
+	if len(uris) == 0 {
+		return nil
+	}
+	return uris
+}
+
+// MatchURI returns an error if the certificate has no URI that matches one
+// of the patterns. A pattern ending with /* matches the URIs under its path,
+// ex. spiffe://example.org/rexray/agent/* matches
+// spiffe://example.org/rexray/agent/node1.
+func MatchURI(cert *x509.Certificate, patterns []string) error {
+	for _, u := range cert.URIs {
+		for _, p := range patterns {
+			if matchURI(u, p) {
+				return nil
+			}
+		}
+	}
+	uris := make([]string, len(cert.URIs))
+	for i, u := range cert.URIs {
+		uris[i] = u.String()
+	}
+	return goof.WithFields(goof.Fields{
+		"uris":     uris,
+		"expected": patterns,
+	}, "certificate identifies unexpected peer")
+}
+
+func matchURI(u *url.URL, pattern string) bool {
+	s := u.String()
+	if prefix := strings.TrimSuffix(pattern, "*"); prefix != pattern {
+		return strings.HasPrefix(s, prefix) && len(s) > len(prefix)
+	}
+	return s == pattern
+}
+
+// Enabled returns a flag indicating whether the libStorage endpoints'
+// certificates are loaded again when their files change.
+func Enabled(config gofig.Config) bool {
+	return config.GetBool("rexray.tls.reload")
+}
+
+// GetCertificateFunc returns the certificate that a server presents to a
+// client.
+type GetCertificateFunc func(*tls.ClientHelloInfo) (*tls.Certificate, error)
+
+// Reloader holds a certificate and the certificates trusted to sign its
+// clients' certificates, and loads them again from their files when the
+// files' modification times change.
+type Reloader struct {
+	ctx      apitypes.Context
+	certFile string
+	keyFile  string
+	caFile   string
+	now      func() time.Time
+
+	sync.Mutex
+	checked  time.Time
+	modTimes []time.Time
+	cert     *tls.Certificate
+	pool     *x509.CertPool
+}
+
+// NewReloader returns a reloader for a certificate and its key, and for
+// the trusted certificates. The certificate and key files are empty if the
+// certificate is served by other means, and the trusted certificates' file
+// is empty if clients are verified with the system's CAs.
+func NewReloader(
+	ctx apitypes.Context,
+	certFile, keyFile, caFile string) (*Reloader, error) {
+
+	r := &Reloader{
+		ctx:      ctx,
+		certFile: certFile,
+		keyFile:  keyFile,
+		caFile:   caFile,
+		now:      time.Now,
+	}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	r.checked = r.now()
+	return r, nil
+}
+
+func (r *Reloader) files() []string {
+	files := []string{}
+	for _, f := range []string{r.certFile, r.keyFile, r.caFile} {
+		if f != "" {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+// load loads the files if any was modified since they were last loaded.
+func (r *Reloader) load() error {
+	files := r.files()
+	modTimes := make([]time.Time, len(files))
+	changed := len(r.modTimes) != len(files)
+	for i, f := range files {
+		fi, err := os.Stat(f)
+		if err != nil {
+			return goof.WithFieldE("path", f, "error reading certificate", err)
+		}
+		modTimes[i] = fi.ModTime()
+		if !changed && !modTimes[i].Equal(r.modTimes[i]) {
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+
+	var cert *tls.Certificate
+	if r.certFile != "" {
+		c, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+		if err != nil {
+			return goof.WithFieldE(
+				"path", r.certFile, "invalid certificate", err)
+		}
+		cert = &c
+	}
+
+	var pool *x509.CertPool
+	if r.caFile != "" {
+		buf, err := ioutil.ReadFile(r.caFile)
+		if err != nil {
+			return goof.WithFieldE(
+				"path", r.caFile, "error reading certificate", err)
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(buf) {
+			return goof.WithField(
+				"path", r.caFile, "no trusted certificates")
+		}
+	}
+
+	r.cert, r.pool, r.modTimes = cert, pool, modTimes
+	return nil
+}
+
+// current returns the certificate and the trusted certificates, loading them
+// again if their files changed. The previous ones are returned if the files
+// cannot be loaded, such as while they are being rewritten, and the files
+// are loaded again at the next check.
+func (r *Reloader) current() (*tls.Certificate, *x509.CertPool) {
+	r.Lock()
+	defer r.Unlock()
+	if now := r.now(); now.Sub(r.checked) >= checkInterval {
+		r.checked = now
+		if err := r.load(); err != nil {
+			r.ctx.WithError(err).Warn("error reloading certificate")
+		}
+	}
+	return r.cert, r.pool
+}
+
+// ServerConfig returns the TLS configuration of a server that presents the
+// certificate returned by getCertificate, or the reloader's certificate if it
+// is nil, and verifies the certificates of clients with the trusted
+// certificates. Clients must present a certificate if clientCertRequired is
+// set, and a certificate with one of clientURIs if any are provided.
+func (r *Reloader) ServerConfig(
+	getCertificate GetCertificateFunc,
+	clientCertRequired bool,
+	clientURIs []string) *tls.Config {
+
+	return &tls.Config{
+		GetConfigForClient: func(
+			*tls.ClientHelloInfo) (*tls.Config, error) {
+
+			cert, pool := r.current()
+			c := &tls.Config{
+				GetCertificate: getCertificate,
+				ClientCAs:      pool,
+			}
+			if getCertificate == nil {
+				if cert == nil {
+					return nil, goof.New("no certificate")
+				}
+				c.Certificates = []tls.Certificate{*cert}
+			}
+			switch {
+			case clientCertRequired || len(clientURIs) > 0:
+				c.ClientAuth = tls.RequireAndVerifyClientCert
+			case pool != nil:
+				c.ClientAuth = tls.VerifyClientCertIfGiven
+			}
+			if len(clientURIs) > 0 {
+				c.VerifyConnection = func(cs tls.ConnectionState) error {
+					if len(cs.PeerCertificates) == 0 {
+						return goof.New("client presented no certificate")
+					}
+					return MatchURI(cs.PeerCertificates[0], clientURIs)
+				}
+			}
+			return c, nil
+		},
+	}
+}
+
+// ClientConfig returns the TLS configuration with which this host connects
+// to the libStorage server, read from the libstorage.tls properties, or nil
+// if it connects without TLS. The client's certificate and the trusted
+// certificates are loaded again when their files change, so that a rotated
+// SVID is presented without restarting the service. The server's certificate
+// must have the URI rexray.tls.serverURI if it is set, in which case its host
+// name is not verified, since an SVID need not have any DNS names.
+func ClientConfig(
+	ctx apitypes.Context, config gofig.Config) (*tls.Config, error) {
+
+	if !config.IsSet("libstorage.tls") || strings.EqualFold(
+		config.GetString("libstorage.tls"), "false") {
+		return nil, nil
+	}
+
+	r, err := NewReloader(ctx,
+		config.GetString("libstorage.tls.certFile"),
+		config.GetString("libstorage.tls.keyFile"),
+		config.GetString("libstorage.tls.trustedCertsFile"))
+	if err != nil {
+		return nil, err
+	}
+
+	c := &tls.Config{
+		ServerName: config.GetString("libstorage.tls.serverName"),
+
+		// the server's certificate is verified by VerifyConnection with the
+		// trusted certificates as they are when the connection is made
+		InsecureSkipVerify: true,
+
+		GetClientCertificate: func(
+			*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			if cert, _ := r.current(); cert != nil {
+				return cert, nil
+			}
+			return &tls.Certificate{}, nil
+		},
+	}
+	if config.GetBool("libstorage.tls.insecure") {
+		return c, nil
+	}
+	serverURI := config.GetString("rexray.tls.serverURI")
+	c.VerifyConnection = func(cs tls.ConnectionState) error {
+		if len(cs.PeerCertificates) == 0 {
+			return goof.New("server presented no certificate")
+		}
+		_, pool := r.current()
+		opts := x509.VerifyOptions{
+			DNSName:       cs.ServerName,
+			Roots:         pool,
+			Intermediates: x509.NewCertPool(),
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		}
+		if serverURI != "" {
+			opts.DNSName = ""
+		}
+		for _, cert := range cs.PeerCertificates[1:] {
+			opts.Intermediates.AddCert(cert)
+		}
+		if _, err := cs.PeerCertificates[0].Verify(opts); err != nil {
+			return err
+		}
+		if serverURI != "" {
+			return MatchURI(cs.PeerCertificates[0], []string{serverURI})
+		}
+		return nil
+	}
+	return c, nil
+}
@@ -0,0 +1,147 @@
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/emccode/libstorage/api/context"
+)
+
+func writeCert(t *testing.T, dir, name string, modTime time.Time) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(
+		rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string][]byte{
+		"cert.pem": pem.EncodeToMemory(
+			&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		"key.pem": pem.EncodeToMemory(
+			&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+	for f, buf := range files {
+		path := filepath.Join(dir, f)
+		if err := ioutil.WriteFile(path, buf, 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// serverName returns the common name of the certificate a server with the
+// provided configuration presents.
+func serverName(t *testing.T, config *tls.Config) string {
+	c, s := net.Pipe()
+	defer c.Close()
+	defer s.Close()
+	go tls.Server(s, config).Handshake()
+
+	client := tls.Client(c, &tls.Config{InsecureSkipVerify: true})
+	if err := client.Handshake(); err != nil {
+		t.Fatal(err)
+	}
+	return client.ConnectionState().PeerCertificates[0].Subject.CommonName
+}
+
+func TestReloader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "certs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	then := time.Now().Add(-time.Minute)
+	writeCert(t, dir, "first", then)
+
+	r, err := NewReloader(context.Background(),
+		filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	r.now = func() time.Time { return now }
+	config := r.ServerConfig(nil, false, nil)
+
+	if n := serverName(t, config); n != "first" {
+		t.Fatalf("served %q", n)
+	}
+
+	writeCert(t, dir, "second", then.Add(time.Second))
+	if n := serverName(t, config); n != "first" {
+		t.Fatalf("served %q before check interval", n)
+	}
+	now = now.Add(checkInterval)
+	if n := serverName(t, config); n != "second" {
+		t.Fatalf("served %q after change", n)
+	}
+
+	path := filepath.Join(dir, "cert.pem")
+	if err := ioutil.WriteFile(path, []byte("partial"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(checkInterval)
+	if n := serverName(t, config); n != "second" {
+		t.Fatalf("served %q after invalid change", n)
+	}
+}
+
+func TestMatchURI(t *testing.T) {
+	u, err := url.Parse("spiffe://example.org/rexray/agent/node1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := &x509.Certificate{URIs: []*url.URL{u}}
+
+	tests := []struct {
+		patterns []string
+		match    bool
+	}{
+		{[]string{"spiffe://example.org/rexray/agent/node1"}, true},
+		{[]string{"spiffe://example.org/rexray/agent/*"}, true},
+		{[]string{"spiffe://example.org/rexray/*"}, true},
+		{[]string{
+			"spiffe://example.org/rexray/controller",
+			"spiffe://example.org/rexray/agent/*",
+		}, true},
+		{[]string{"spiffe://example.org/rexray/agent"}, false},
+		{[]string{"spiffe://example.org/rexray/agent/node1/*"}, false},
+		{[]string{"spiffe://example.org/rexray/controller"}, false},
+		{[]string{"spiffe://other.org/rexray/agent/*"}, false},
+	}
+	for _, tt := range tests {
+		if err := MatchURI(cert, tt.patterns); (err == nil) != tt.match {
+			t.Errorf("%v: got %v, want match %v", tt.patterns, err, tt.match)
+		}
+	}
+}
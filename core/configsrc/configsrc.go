@@ -0,0 +1,354 @@
+// Package configsrc loads REX-Ray's configuration from a key of a KV store,
+// such as etcd or Consul, so that the settings of a fleet may be changed
+// centrally. The key's value is YAML that is merged over the configuration
+// file, and the daemon watches the key and applies the libStorage server's
+// settings again each time it changes. Environment variables still take
+// precedence over both.
+package configsrc
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	apitypes "github.com/emccode/libstorage/api/types"
+	"gopkg.in/yaml.v1"
+)
+
+const retryDelay = 5 * time.Second
+
+func init() {
+	r := gofig.NewRegistration("Configuration Source")
+	r.Key(gofig.String, "", "",
+		"The KV store key from which configuration is loaded, ex. "+
+			"etcd://10.0.0.1:2379,10.0.0.2:2379/rexray/config or "+
+			"consul://127.0.0.1:8500/rexray/config",
+		"rexray.config.source")
+	r.Key(gofig.String, "", "",
+		"The user name with which etcd requests are authenticated",
+		"rexray.config.username")
+	r.Key(gofig.String, "", "",
+		"The password with which etcd requests are authenticated",
+		"rexray.config.password")
+	r.Key(gofig.String, "", "",
+		"The ACL token with which Consul requests are authenticated",
+		"rexray.config.token")
+	r.Key(gofig.String, "", "10s",
+		"How long to wait for a request to the configuration source",
+		"rexray.config.timeout")
+	gofig.Register(r)
+}
+
+// Source is a KV store key that holds configuration.
+type Source interface {
+
+	// Get returns the key's value and its revision. The value is nil if the
+	// key does not exist.
+	Get(ctx context.Context) ([]byte, uint64, error)
+
+	// Wait blocks until the key's revision is newer than the provided one
+	// and returns the key's new value and revision.
+	Wait(ctx context.Context, rev uint64) ([]byte, uint64, error)
+
+	// Close releases the source's resources.
+	Close() error
+}
+
+// NewSource returns a source for the key at the provided URL.
+type NewSource func(config gofig.Config, u *url.URL) (Source, error)
+
+var sources = map[string]NewSource{}
+
+// RegisterSource registers the constructor of the sources whose URLs have
+// the provided scheme.
+func RegisterSource(scheme string, ctor NewSource) {
+	sources[strings.ToLower(scheme)] = ctor
+}
+
+// New returns the configured source. The returned source is nil if none is
+// configured.
+func New(config gofig.Config) (Source, error) {
+	v := config.GetString("rexray.config.source")
+	if v == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(v)
+	if err != nil {
+		return nil, goof.WithFieldE(
+			"source", v, "invalid configuration source", err)
+	}
+	ctor, ok := sources[strings.ToLower(u.Scheme)]
+	if !ok {
+		return nil, goof.WithField(
+			"scheme", u.Scheme, "unknown configuration source")
+	}
+	if u.Host == "" || strings.Trim(u.Path, "/") == "" {
+		return nil, goof.WithField("source", v,
+			"configuration source requires endpoints and a key")
+	}
+	return ctor(config, u)
+}
+
+// endpoints returns the comma-separated endpoints of a source's URL.
+func endpoints(u *url.URL) []string {
+	return strings.Split(u.Host, ",")
+}
+
+func timeout(config gofig.Config) time.Duration {
+	if t, err := time.ParseDuration(
+		config.GetString("rexray.config.timeout")); err == nil && t > 0 {
+		return t
+	}
+	return 10 * time.Second
+}
+
+// merge merges YAML configuration over the provided configuration.
+func merge(config gofig.Config, buf []byte) error {
+	if len(bytes.TrimSpace(buf)) == 0 {
+		return nil
+	}
+	if err := config.ReadConfig(bytes.NewReader(buf)); err != nil {
+		return goof.WithFieldE(
+			"source", config.GetString("rexray.config.source"),
+			"error reading configuration from source", err)
+	}
+	return nil
+}
+
+// Load merges the configuration at the configured source, if any, over the
+// provided configuration.
+func Load(ctx apitypes.Context, config gofig.Config) error {
+	src, err := New(config)
+	if err != nil || src == nil {
+		return err
+	}
+	defer src.Close()
+
+	c, cancel := context.WithTimeout(context.Background(), timeout(config))
+	defer cancel()
+	buf, _, err := src.Get(c)
+	if err != nil {
+		return goof.WithFieldE(
+			"source", config.GetString("rexray.config.source"),
+			"error loading configuration from source", err)
+	}
+	if buf == nil {
+		ctx.WithField("source", config.GetString("rexray.config.source")).
+			Warn("configuration source key does not exist")
+		return nil
+	}
+	if err := merge(config, buf); err != nil {
+		return err
+	}
+	ctx.WithField("source", config.GetString("rexray.config.source")).
+		Info("loaded configuration from source")
+	return nil
+}
+
+// Restart restarts the libStorage server with a copy of its configuration
+// that is modified by update.
+type Restart func(update func(config gofig.Config)) error
+
+// Watch applies the configuration at the configured source each time it
+// changes, until stop is closed. The daemon's configuration is never changed
+// while it runs, since it is read concurrently and by drivers that read it
+// only once. Instead the libStorage server is restarted with a copy of its
+// configuration over which the source's configuration is merged if any of
+// the server's settings changed, except its endpoints. A change to any other
+// setting is logged and applies when the daemon restarts, as does a setting
+// that is removed from the source.
+func Watch(
+	ctx apitypes.Context,
+	config gofig.Config,
+	restart Restart,
+	stop <-chan struct{}) error {
+
+	src, err := New(config)
+	if err != nil || src == nil {
+		return err
+	}
+
+	c, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-stop
+		cancel()
+	}()
+
+	source := config.GetString("rexray.config.source")
+	go func() {
+		defer src.Close()
+
+		var (
+			rev  uint64
+			last []byte
+		)
+		for {
+			gc, gcancel := context.WithTimeout(c, timeout(config))
+			buf, r, err := src.Get(gc)
+			gcancel()
+			if err == nil {
+				rev, last = r, buf
+				break
+			}
+			ctx.WithError(err).WithField("source", source).Warn(
+				"error reading configuration source")
+			select {
+			case <-c.Done():
+				return
+			case <-time.After(retryDelay):
+			}
+		}
+
+		ctx.WithField("source", source).Info("watching configuration source")
+		for {
+			buf, r, err := src.Wait(c, rev)
+			if c.Err() != nil {
+				return
+			}
+			if err != nil {
+				ctx.WithError(err).WithField("source", source).Warn(
+					"error watching configuration source")
+				select {
+				case <-c.Done():
+					return
+				case <-time.After(retryDelay):
+				}
+				continue
+			}
+			if r == rev {
+				continue
+			}
+			rev = r
+			if buf == nil {
+				ctx.WithField("source", source).Warn(
+					"configuration source key was removed; keeping settings")
+				continue
+			}
+			if err := apply(ctx, restart, source, last, buf); err != nil {
+				ctx.WithError(err).Error("error updating configuration")
+				continue
+			}
+			last = buf
+			ctx.WithFields(map[string]interface{}{
+				"source":   source,
+				"revision": rev,
+			}).Info("updated configuration from source")
+		}
+	}()
+	return nil
+}
+
+// apply restarts the libStorage server with the source's new configuration
+// if any of the server's settings changed, and logs the changed settings
+// that apply only when the daemon restarts.
+func apply(
+	ctx apitypes.Context,
+	restart Restart,
+	source string,
+	last, buf []byte) error {
+
+	keys, err := changedKeys(last, buf)
+	if err != nil {
+		return goof.WithFieldE(
+			"source", source, "error reading configuration from source", err)
+	}
+
+	var server, other []string
+	for _, k := range keys {
+		if reloads(k) {
+			server = append(server, k)
+		} else {
+			other = append(other, k)
+		}
+	}
+
+	if len(server) > 0 {
+		var merr error
+		if err := restart(func(config gofig.Config) {
+			merr = merge(config, buf)
+		}); err != nil {
+			return err
+		}
+		if merr != nil {
+			return merr
+		}
+		ctx.WithField("keys", server).Info(
+			"restarted libStorage server with updated configuration")
+	}
+	if len(other) > 0 {
+		ctx.WithField("keys", other).Warn(
+			"configuration changed; the changes apply when the service " +
+				"restarts")
+	}
+	return nil
+}
+
+// reloads returns a flag indicating whether a change to a setting is
+// applied by restarting the libStorage server. The server's endpoints are
+// served by the front, which is not restarted.
+func reloads(key string) bool {
+	key = strings.TrimPrefix(key, "rexray.")
+	return strings.HasPrefix(key, "libstorage.server.") &&
+		!strings.HasPrefix(key, "libstorage.server.endpoints")
+}
+
+// changedKeys returns the keys of the settings that differ between two
+// YAML documents, in order.
+func changedKeys(old, new []byte) ([]string, error) {
+	a, err := flatten(old)
+	if err != nil {
+		return nil, err
+	}
+	b, err := flatten(new)
+	if err != nil {
+		return nil, err
+	}
+	keys := []string{}
+	for k, v := range b {
+		if ov, ok := a[k]; !ok || !reflect.DeepEqual(ov, v) {
+			keys = append(keys, k)
+		}
+	}
+	for k := range a {
+		if _, ok := b[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// flatten returns the settings of a YAML document by their lower-case,
+// dot-separated keys.
+func flatten(buf []byte) (map[string]interface{}, error) {
+	var doc interface{}
+	if err := yaml.Unmarshal(buf, &doc); err != nil {
+		return nil, err
+	}
+	m := map[string]interface{}{}
+	var walk func(prefix string, v interface{})
+	walk = func(prefix string, v interface{}) {
+		if mv, ok := v.(map[interface{}]interface{}); ok {
+			for k, cv := range mv {
+				key := strings.ToLower(fmt.Sprint(k))
+				if prefix != "" {
+					key = prefix + "." + key
+				}
+				walk(key, cv)
+			}
+			return
+		}
+		if prefix != "" {
+			m[prefix] = v
+		}
+	}
+	walk("", doc)
+	return m, nil
+}
@@ -0,0 +1,80 @@
+package configsrc
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/akutz/gofig"
+	"github.com/hashicorp/consul/api"
+)
+
+// consulWaitTime is how long a blocking query waits for the key to change.
+const consulWaitTime = 5 * time.Minute
+
+func init() {
+	RegisterSource("consul", newConsulSource)
+}
+
+// consulSource is a Consul key, ex. consul://127.0.0.1:8500/rexray/config.
+// The key's revision is the index of the KV store, which blocking queries
+// wait on.
+type consulSource struct {
+	kv  *api.KV
+	key string
+}
+
+func newConsulSource(config gofig.Config, u *url.URL) (Source, error) {
+	c := api.DefaultConfig()
+	c.Address = endpoints(u)[0]
+	if v := config.GetString("rexray.config.token"); v != "" {
+		c.Token = v
+	}
+	httpClient, err := api.NewHttpClient(c.Transport, c.TLSConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	// blocking queries outlast the request timeout
+	httpClient.Timeout = consulWaitTime + timeout(config)
+	c.HttpClient = httpClient
+	client, err := api.NewClient(c)
+	if err != nil {
+		return nil, err
+	}
+	return &consulSource{
+		kv:  client.KV(),
+		key: strings.TrimPrefix(u.Path, "/"),
+	}, nil
+}
+
+func (s *consulSource) get(
+	ctx context.Context, opts *api.QueryOptions) ([]byte, uint64, error) {
+
+	pair, meta, err := s.kv.Get(s.key, opts.WithContext(ctx))
+	if err != nil {
+		return nil, 0, err
+	}
+	if pair == nil {
+		return nil, meta.LastIndex, nil
+	}
+	return pair.Value, meta.LastIndex, nil
+}
+
+func (s *consulSource) Get(ctx context.Context) ([]byte, uint64, error) {
+	return s.get(ctx, &api.QueryOptions{})
+}
+
+func (s *consulSource) Wait(
+	ctx context.Context, rev uint64) ([]byte, uint64, error) {
+
+	return s.get(ctx, &api.QueryOptions{
+		WaitIndex: rev,
+		WaitTime:  consulWaitTime,
+	})
+}
+
+func (s *consulSource) Close() error {
+	return nil
+}
@@ -0,0 +1,62 @@
+package configsrc
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/akutz/gofig"
+
+	"github.com/emccode/rexray/core/etcd"
+)
+
+func init() {
+	RegisterSource("etcd", newEtcdSource)
+}
+
+// etcdSource is an etcd key, ex. etcd://10.0.0.1:2379/rexray/config. The
+// key's revision is its modify revision.
+type etcdSource struct {
+	client *etcd.Client
+	key    string
+}
+
+func newEtcdSource(config gofig.Config, u *url.URL) (Source, error) {
+	client, err := etcd.New(etcd.Config{
+		Endpoints: endpoints(u),
+		Username:  config.GetString("rexray.config.username"),
+		Password:  config.GetString("rexray.config.password"),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &etcdSource{client: client, key: u.Path}, nil
+}
+
+func (s *etcdSource) Get(ctx context.Context) ([]byte, uint64, error) {
+	kv, rev, err := s.client.Get(ctx, s.key)
+	if err != nil {
+		return nil, 0, err
+	}
+	if kv == nil {
+		return nil, uint64(rev), nil
+	}
+	return kv.Value, uint64(kv.ModRevision), nil
+}
+
+func (s *etcdSource) Wait(
+	ctx context.Context, rev uint64) ([]byte, uint64, error) {
+
+	events, err := s.client.Watch(ctx, s.key, int64(rev)+1)
+	if err != nil {
+		return nil, rev, err
+	}
+	ev := events[len(events)-1]
+	if ev.Type == "DELETE" {
+		return nil, uint64(ev.Kv.ModRevision), nil
+	}
+	return ev.Kv.Value, uint64(ev.Kv.ModRevision), nil
+}
+
+func (s *etcdSource) Close() error {
+	return nil
+}
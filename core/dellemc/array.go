@@ -0,0 +1,151 @@
+package dellemc
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/cookiejar"
+	"strings"
+	"time"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+
+	"github.com/emccode/rexray/core/errcodes"
+)
+
+// lun is a thin LUN, or a PowerStore volume, as returned by an array.
+type lun struct {
+	ID          string
+	Name        string
+	Description string
+	Size        int64
+	WWN         string
+	Health      string
+
+	// Hosts are the IDs of the hosts that may access the LUN.
+	Hosts []string
+}
+
+// snapshot is a snapshot of a LUN.
+type snapshot struct {
+	ID           string
+	Name         string
+	Description  string
+	LUNID        string
+	Size         int64
+	CreationTime time.Time
+	State        string
+}
+
+// host is a host registered with an array along with its iSCSI initiators.
+type host struct {
+	ID         string
+	Name       string
+	Initiators []string
+}
+
+// createOpts are the options of a new LUN.
+type createOpts struct {
+	Name        string
+	Description string
+	Size        int64
+
+	// Pool is the Unity pool of the LUN.
+	Pool string
+
+	// PerformancePolicy is the PowerStore performance policy of the volume.
+	PerformancePolicy string
+}
+
+// array is the REST API of a Unity or PowerStore array. The methods that
+// return an object return an error for which isNotFound is true if there is
+// no such object.
+type array interface {
+	luns() ([]*lun, error)
+	lun(id string) (*lun, error)
+	createLUN(opts *createOpts) (string, error)
+	removeLUN(id string) error
+
+	// hostByInitiator returns the host with the initiator.
+	hostByInitiator(initiator string) (*host, error)
+	createHost(name, initiator string) (string, error)
+	hosts() ([]*host, error)
+
+	// attach grants a host access to a LUN, and detach revokes it.
+	attach(lunID, hostID string) error
+	detach(lunID, hostID string) error
+
+	snapshots() ([]*snapshot, error)
+	snapshot(id string) (*snapshot, error)
+	createSnapshot(lunID, name string) (string, error)
+	removeSnapshot(id string) error
+
+	// clone creates a thin clone of a snapshot and returns its ID.
+	clone(snapshotID, name, description string) (string, error)
+
+	// portals returns the addresses of the array's iSCSI portals.
+	portals() ([]string, error)
+}
+
+// newArray returns the API of the array of the named driver.
+func newArray(name string, config gofig.Config) (array, error) {
+	endpoint := strings.TrimSuffix(config.GetString(name+".endpoint"), "/")
+	if endpoint == "" {
+		return nil, goof.New(name + " driver requires " + name + ".endpoint")
+	}
+	jar, _ := cookiejar.New(nil)
+	c := &http.Client{Timeout: 60 * time.Second, Jar: jar}
+	if config.GetBool(name + ".insecure") {
+		c.Transport = &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		}
+	}
+	username := config.GetString(name + ".username")
+	password := config.GetString(name + ".password")
+	if name == NameUnity {
+		return &unity{
+			endpoint: endpoint,
+			username: username,
+			password: password,
+			client:   c,
+		}, nil
+	}
+	return &powerStore{
+		endpoint: endpoint + "/api/rest",
+		username: username,
+		password: password,
+		client:   c,
+	}, nil
+}
+
+// apiError is an error returned by an array's API.
+type apiError struct {
+	status  int
+	message string
+}
+
+func (e *apiError) Error() string {
+	return "dellemc: " + http.StatusText(e.status) + ": " + e.message
+}
+
+// Code returns the code of the error's HTTP status.
+func (e *apiError) Code() errcodes.Code {
+	return errcodes.FromStatus(e.status)
+}
+
+// MarshalJSON marshals the error as its message and code, since the
+// libStorage server reports a driver's error as the error's JSON.
+func (e *apiError) MarshalJSON() ([]byte, error) {
+	return errcodes.Marshal(e)
+}
+
+// isNotFound returns a flag indicating whether the error is that of a
+// request for an object that does not exist.
+func isNotFound(err error) bool {
+	e, ok := err.(*apiError)
+	return ok && e.status == http.StatusNotFound
+}
+
+func notFound(kind, id string) error {
+	return &apiError{status: http.StatusNotFound, message: kind + " " + id}
+}
@@ -0,0 +1,120 @@
+// Package dellemc is a storage driver for the Dell EMC Unity and PowerStore
+// arrays. It is registered as two drivers, unity and powerstore, that manage
+// thin LUNs, their snapshots, and the hosts that may access them with the
+// arrays' REST APIs. Nodes are registered as hosts by their iSCSI initiator
+// names, log in to the arrays' iSCSI portals, and find the devices of
+// attached LUNs by their WWNs.
+package dellemc
+
+import (
+	"strings"
+
+	"github.com/akutz/gofig"
+	"github.com/emccode/libstorage/api/registry"
+	apitypes "github.com/emccode/libstorage/api/types"
+)
+
+const (
+	// NameUnity is the name of the Unity driver.
+	NameUnity = "unity"
+
+	// NamePowerStore is the name of the PowerStore driver.
+	NamePowerStore = "powerstore"
+
+	// OptPool is the create option with which the pool of a Unity LUN is
+	// requested.
+	OptPool = "pool"
+
+	// OptPerformancePolicy is the create option with which the performance
+	// policy of a PowerStore volume is requested.
+	OptPerformancePolicy = "performancePolicy"
+
+	defaultHostPrefix = "rexray-"
+	defaultSize       = 8
+
+	gib = 1024 * 1024 * 1024
+
+	// maxHostNameLen is the length of the longest host name the drivers
+	// create, which both arrays accept.
+	maxHostNameLen = 64
+)
+
+func init() {
+	registry.RegisterStorageDriver(NameUnity, newUnityDriver)
+	registry.RegisterStorageDriver(NamePowerStore, newPowerStoreDriver)
+	registry.RegisterStorageExecutor(NameUnity, newUnityExecutor)
+	registry.RegisterStorageExecutor(NamePowerStore, newPowerStoreExecutor)
+
+	r := gofig.NewRegistration("Dell EMC Unity and PowerStore Drivers")
+	for _, n := range []string{NameUnity, NamePowerStore} {
+		r.Key(gofig.String, "", "",
+			"The URL of the array's management interface",
+			n+".endpoint")
+		r.Key(gofig.String, "", "",
+			"The user with which the array's REST API is called",
+			n+".username")
+		r.Key(gofig.String, "", "",
+			"The password of the user",
+			n+".password")
+		r.Key(gofig.Bool, "", false,
+			"Skip the verification of the array's TLS certificate",
+			n+".insecure")
+		r.Key(gofig.String, "", defaultHostPrefix,
+			"The prefix of the names of the hosts the driver creates",
+			n+".hostPrefix")
+		r.Key(gofig.String, "", "",
+			"The array's iSCSI portals, host[:port] and separated by commas, "+
+				"at which nodes log in; defaults to those the array lists",
+			n+".portals")
+		r.Key(gofig.Bool, "", true,
+			"Use the multipath device of a LUN when there is one",
+			n+".multipath")
+	}
+	r.Key(gofig.String, "", "",
+		"The ID of the pool in which LUNs are created",
+		"unity.pool")
+	r.Key(gofig.String, "", "",
+		"The ID of the performance policy of new volumes",
+		"powerstore.performancePolicy")
+	gofig.Register(r)
+}
+
+func newUnityDriver() apitypes.StorageDriver {
+	return &driver{name: NameUnity}
+}
+
+func newPowerStoreDriver() apitypes.StorageDriver {
+	return &driver{name: NamePowerStore}
+}
+
+func newUnityExecutor() apitypes.StorageExecutor {
+	return &executor{name: NameUnity}
+}
+
+func newPowerStoreExecutor() apitypes.StorageExecutor {
+	return &executor{name: NamePowerStore}
+}
+
+// hostName returns the name of the host the driver creates for an
+// initiator: the initiator's name with the prefix and with the characters
+// other than letters, digits, and hyphens replaced with hyphens.
+func hostName(prefix, initiator string) string {
+	n := []rune(prefix + initiator)
+	for i, r := range n {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' ||
+			r >= '0' && r <= '9' || r == '-') {
+			n[i] = '-'
+		}
+	}
+	if len(n) > maxHostNameLen {
+		n = n[:maxHostNameLen]
+	}
+	return string(n)
+}
+
+// splitList splits a list separated by commas or spaces.
+func splitList(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+}
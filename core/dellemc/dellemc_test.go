@@ -0,0 +1,57 @@
+package dellemc
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHostName(t *testing.T) {
+	if n := hostName("rexray-", "iqn.1994-05.com.redhat:a1b2"); n !=
+		"rexray-iqn-1994-05-com-redhat-a1b2" {
+		t.Fatalf("name=%s", n)
+	}
+	if n := hostName("rexray-", strings.Repeat("a", 100)); len(n) != 64 {
+		t.Fatalf("len=%d", len(n))
+	}
+}
+
+func TestUnityLUN(t *testing.T) {
+	l := &unityLUN{}
+	if err := json.Unmarshal([]byte(`{
+		"id": "sv_16",
+		"name": "data",
+		"sizeTotal": 10737418240,
+		"wwn": "60:06:01:60:0b:b0:3c:00:4a:d1:5b:58:8b:22:c1:64",
+		"health": {"value": 5},
+		"hostAccess": [{"host": {"id": "Host_1"}, "accessMask": 1}]
+	}`), l); err != nil {
+		t.Fatal(err)
+	}
+	v := l.lun()
+	if v.ID != "sv_16" || v.Size != 10*gib || v.Health != "ok" ||
+		!reflect.DeepEqual(v.Hosts, []string{"Host_1"}) {
+		t.Fatalf("lun=%+v", v)
+	}
+}
+
+func TestPowerStoreSnapshot(t *testing.T) {
+	v := &powerStoreVolume{}
+	if err := json.Unmarshal([]byte(`{
+		"id": "7d1b",
+		"name": "nightly",
+		"size": 8589934592,
+		"state": "Ready",
+		"type": "Snapshot",
+		"creation_timestamp": "2026-10-15T02:00:00.000+00:00",
+		"protection_data": {"source_id": "3c5e"}
+	}`), v); err != nil {
+		t.Fatal(err)
+	}
+	s := v.snapshot()
+	if s.LUNID != "3c5e" || s.State != "ready" ||
+		s.CreationTime.Unix() != 1792029600 {
+		t.Fatalf("snapshot=%+v", s)
+	}
+}
@@ -0,0 +1,469 @@
+package dellemc
+
+import (
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	"github.com/emccode/libstorage/api/context"
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/core/scsi"
+)
+
+// driver is the unity or powerstore driver. The instance ID of a node is its
+// iSCSI initiator name, and the node is registered with the array as a host
+// with that initiator when a volume is first attached to it.
+type driver struct {
+	name       string
+	config     gofig.Config
+	api        array
+	hostPrefix string
+}
+
+func (d *driver) Name() string {
+	return d.name
+}
+
+func (d *driver) Init(ctx apitypes.Context, config gofig.Config) error {
+	d.config = config
+	api, err := newArray(d.name, config)
+	if err != nil {
+		return err
+	}
+	d.api = api
+	d.hostPrefix = config.GetString(d.name + ".hostPrefix")
+	if d.hostPrefix == "" {
+		d.hostPrefix = defaultHostPrefix
+	}
+
+	ctx.WithField("endpoint", config.GetString(d.name+".endpoint")).Info(
+		"initialized " + d.name + " driver")
+	return nil
+}
+
+func (d *driver) Type(ctx apitypes.Context) (apitypes.StorageType, error) {
+	return apitypes.Block, nil
+}
+
+// NextDeviceInfo returns nil because the devices of attached LUNs are named
+// by the node's SCSI subsystem.
+func (d *driver) NextDeviceInfo(
+	ctx apitypes.Context) (*apitypes.NextDeviceInfo, error) {
+
+	return nil, nil
+}
+
+func (d *driver) InstanceInspect(
+	ctx apitypes.Context,
+	opts apitypes.Store) (*apitypes.Instance, error) {
+
+	iid, ok := ctx.Value(context.InstanceIDKey).(*apitypes.InstanceID)
+	if !ok {
+		return nil, goof.New("missing instance ID")
+	}
+	return &apitypes.Instance{InstanceID: iid, Name: iid.ID}, nil
+}
+
+// instanceID returns the initiator name of the node on whose behalf an
+// operation is performed.
+func instanceID(ctx apitypes.Context) string {
+	if iid, ok := ctx.Value(context.InstanceIDKey).(*apitypes.InstanceID); ok {
+		return iid.ID
+	}
+	return ""
+}
+
+// toVolume returns a libStorage volume for a LUN. The attachments of the
+// volume are the initiators of the hosts that may access the LUN, and the
+// device of the attachment of the instance's initiator is looked up in the
+// local devices by the LUN's WWN.
+func (d *driver) toVolume(
+	ctx apitypes.Context,
+	l *lun,
+	hosts map[string]*host) *apitypes.Volume {
+
+	vol := &apitypes.Volume{
+		ID:     l.ID,
+		Name:   l.Name,
+		Size:   l.Size / gib,
+		Type:   d.name,
+		Status: l.Health,
+		Fields: map[string]string{"wwn": l.WWN},
+	}
+	if hosts == nil {
+		return vol
+	}
+	iid := instanceID(ctx)
+	ld, _ := ctx.Value(context.LocalDevicesKey).(*apitypes.LocalDevices)
+	for _, id := range l.Hosts {
+		h, ok := hosts[id]
+		if !ok {
+			continue
+		}
+		for _, i := range h.Initiators {
+			att := &apitypes.VolumeAttachment{
+				VolumeID: vol.ID,
+				InstanceID: &apitypes.InstanceID{
+					ID:     i,
+					Driver: d.name,
+				},
+				Status: "attached",
+				Fields: map[string]string{"host": h.Name},
+			}
+			if i == iid && ld != nil {
+				att.DeviceName = ld.DeviceMap[scsi.NormalizeWWN(l.WWN)]
+			}
+			vol.Attachments = append(vol.Attachments, att)
+		}
+	}
+	return vol
+}
+
+// hosts returns the array's hosts keyed by their IDs, or nil if attachments
+// are not requested.
+func (d *driver) hosts(attachments bool) (map[string]*host, error) {
+	if !attachments {
+		return nil, nil
+	}
+	all, err := d.api.hosts()
+	if err != nil {
+		return nil, err
+	}
+	hosts := map[string]*host{}
+	for _, h := range all {
+		hosts[h.ID] = h
+	}
+	return hosts, nil
+}
+
+func (d *driver) Volumes(
+	ctx apitypes.Context,
+	opts *apitypes.VolumesOpts) ([]*apitypes.Volume, error) {
+
+	luns, err := d.api.luns()
+	if err != nil {
+		return nil, err
+	}
+	hosts, err := d.hosts(opts.Attachments)
+	if err != nil {
+		return nil, err
+	}
+	vols := []*apitypes.Volume{}
+	for _, l := range luns {
+		vols = append(vols, d.toVolume(ctx, l, hosts))
+	}
+	return vols, nil
+}
+
+func (d *driver) VolumeInspect(
+	ctx apitypes.Context,
+	volumeID string,
+	opts *apitypes.VolumeInspectOpts) (*apitypes.Volume, error) {
+
+	return d.inspect(ctx, volumeID, opts.Attachments)
+}
+
+func (d *driver) inspect(
+	ctx apitypes.Context,
+	volumeID string,
+	attachments bool) (*apitypes.Volume, error) {
+
+	l, err := d.lun(volumeID)
+	if err != nil {
+		return nil, err
+	}
+	hosts, err := d.hosts(attachments)
+	if err != nil {
+		return nil, err
+	}
+	return d.toVolume(ctx, l, hosts), nil
+}
+
+func (d *driver) lun(id string) (*lun, error) {
+	l, err := d.api.lun(id)
+	if isNotFound(err) {
+		return nil, goof.WithField("volumeID", id, "volume not found")
+	}
+	return l, err
+}
+
+// createOpt returns a create option, or else the configured value.
+func (d *driver) createOpt(
+	opts *apitypes.VolumeCreateOpts, key string) string {
+
+	if opts.Opts != nil {
+		if v := opts.Opts.GetString(key); v != "" {
+			return v
+		}
+	}
+	return d.config.GetString(d.name + "." + key)
+}
+
+// VolumeCreate creates a thin LUN in the requested or configured Unity pool,
+// or a PowerStore volume with the requested or configured performance
+// policy.
+func (d *driver) VolumeCreate(
+	ctx apitypes.Context,
+	name string,
+	opts *apitypes.VolumeCreateOpts) (*apitypes.Volume, error) {
+
+	size := int64(defaultSize)
+	if opts.Size != nil && *opts.Size > 0 {
+		size = *opts.Size
+	}
+	co := &createOpts{Name: name, Size: size * gib}
+	if d.name == NameUnity {
+		co.Pool = d.createOpt(opts, OptPool)
+	} else {
+		co.PerformancePolicy = d.createOpt(opts, OptPerformancePolicy)
+	}
+
+	id, err := d.api.createLUN(co)
+	if err != nil {
+		return nil, goof.WithFieldsE(goof.Fields{
+			"name": name,
+			"size": size,
+		}, "error creating volume", err)
+	}
+	return d.inspect(ctx, id, false)
+}
+
+// VolumeCreateFromSnapshot creates a thin clone of a snapshot, which has the
+// size of the snapshot's LUN.
+func (d *driver) VolumeCreateFromSnapshot(
+	ctx apitypes.Context,
+	snapshotID, volumeName string,
+	opts *apitypes.VolumeCreateOpts) (*apitypes.Volume, error) {
+
+	id, err := d.api.clone(snapshotID, volumeName, "")
+	if err != nil {
+		return nil, goof.WithFieldsE(goof.Fields{
+			"snapshotID": snapshotID,
+			"name":       volumeName,
+		}, "error cloning snapshot", err)
+	}
+	return d.inspect(ctx, id, false)
+}
+
+func (d *driver) VolumeCopy(
+	ctx apitypes.Context,
+	volumeID, volumeName string,
+	opts apitypes.Store) (*apitypes.Volume, error) {
+
+	return nil, apitypes.ErrNotImplemented
+}
+
+func (d *driver) VolumeSnapshot(
+	ctx apitypes.Context,
+	volumeID, snapshotName string,
+	opts apitypes.Store) (*apitypes.Snapshot, error) {
+
+	id, err := d.api.createSnapshot(volumeID, snapshotName)
+	if err != nil {
+		return nil, goof.WithFieldsE(goof.Fields{
+			"volumeID": volumeID,
+			"name":     snapshotName,
+		}, "error creating snapshot", err)
+	}
+	return d.SnapshotInspect(ctx, id, opts)
+}
+
+// VolumeRemove removes a LUN. The arrays refuse to remove a LUN that hosts
+// may still access.
+func (d *driver) VolumeRemove(
+	ctx apitypes.Context,
+	volumeID string,
+	opts apitypes.Store) error {
+
+	if err := d.api.removeLUN(volumeID); err != nil {
+		if isNotFound(err) {
+			return goof.WithField("volumeID", volumeID, "volume not found")
+		}
+		return goof.WithFieldE("volumeID", volumeID,
+			"error removing volume", err)
+	}
+	return nil
+}
+
+// host returns the ID of the host with the initiator, which is created if
+// there is none.
+func (d *driver) host(ctx apitypes.Context, initiator string) (string, error) {
+	h, err := d.api.hostByInitiator(initiator)
+	if err == nil {
+		return h.ID, nil
+	}
+	if !isNotFound(err) {
+		return "", err
+	}
+	name := hostName(d.hostPrefix, initiator)
+	id, err := d.api.createHost(name, initiator)
+	if err != nil {
+		return "", goof.WithFieldsE(goof.Fields{
+			"host":      name,
+			"initiator": initiator,
+		}, "error creating host", err)
+	}
+	ctx.WithFields(map[string]interface{}{
+		"host":      name,
+		"initiator": initiator,
+	}).Info("created host")
+	return id, nil
+}
+
+// VolumeAttach grants the host of the instance's initiator access to a LUN,
+// creating the host if there is none, and returns the LUN's WWN, which is
+// the key of its device in the node's local devices, as the token. A forced
+// attach first revokes the access of other hosts.
+func (d *driver) VolumeAttach(
+	ctx apitypes.Context,
+	volumeID string,
+	opts *apitypes.VolumeAttachOpts) (*apitypes.Volume, string, error) {
+
+	iid := instanceID(ctx)
+	if iid == "" {
+		return nil, "", goof.New("missing instance ID")
+	}
+	l, err := d.lun(volumeID)
+	if err != nil {
+		return nil, "", err
+	}
+	hostID, err := d.host(ctx, iid)
+	if err != nil {
+		return nil, "", err
+	}
+
+	attached := false
+	for _, h := range l.Hosts {
+		switch {
+		case h == hostID:
+			attached = true
+		case !opts.Force:
+			return nil, "", goof.WithFields(goof.Fields{
+				"volumeID": volumeID,
+				"hostID":   h,
+			}, "volume is attached to another host")
+		default:
+			if err := d.api.detach(volumeID, h); err != nil {
+				return nil, "", goof.WithFieldsE(goof.Fields{
+					"volumeID": volumeID,
+					"hostID":   h,
+				}, "error detaching volume", err)
+			}
+		}
+	}
+	if !attached {
+		if err := d.api.attach(volumeID, hostID); err != nil {
+			return nil, "", goof.WithFieldsE(goof.Fields{
+				"volumeID": volumeID,
+				"hostID":   hostID,
+			}, "error attaching volume", err)
+		}
+	}
+
+	vol, err := d.inspect(ctx, volumeID, true)
+	if err != nil {
+		return nil, "", err
+	}
+	return vol, scsi.NormalizeWWN(l.WWN), nil
+}
+
+// VolumeDetach revokes the access of the host of the instance's initiator to
+// a LUN, or of all hosts if the detach is forced.
+func (d *driver) VolumeDetach(
+	ctx apitypes.Context,
+	volumeID string,
+	opts *apitypes.VolumeDetachOpts) (*apitypes.Volume, error) {
+
+	l, err := d.lun(volumeID)
+	if err != nil {
+		return nil, err
+	}
+	hostID := ""
+	if !opts.Force {
+		h, err := d.api.hostByInitiator(instanceID(ctx))
+		if err != nil && !isNotFound(err) {
+			return nil, err
+		}
+		if h != nil {
+			hostID = h.ID
+		}
+	}
+	for _, h := range l.Hosts {
+		if h != hostID && !opts.Force {
+			continue
+		}
+		if err := d.api.detach(volumeID, h); err != nil {
+			return nil, goof.WithFieldsE(goof.Fields{
+				"volumeID": volumeID,
+				"hostID":   h,
+			}, "error detaching volume", err)
+		}
+	}
+	return d.inspect(ctx, volumeID, true)
+}
+
+func toSnapshot(s *snapshot) *apitypes.Snapshot {
+	return &apitypes.Snapshot{
+		ID:          s.ID,
+		Name:        s.Name,
+		VolumeID:    s.LUNID,
+		VolumeSize:  s.Size / gib,
+		StartTime:   s.CreationTime.Unix(),
+		Description: s.Description,
+		Status:      s.State,
+	}
+}
+
+func (d *driver) Snapshots(
+	ctx apitypes.Context,
+	opts apitypes.Store) ([]*apitypes.Snapshot, error) {
+
+	all, err := d.api.snapshots()
+	if err != nil {
+		return nil, err
+	}
+	snaps := []*apitypes.Snapshot{}
+	for _, s := range all {
+		snaps = append(snaps, toSnapshot(s))
+	}
+	return snaps, nil
+}
+
+func (d *driver) SnapshotInspect(
+	ctx apitypes.Context,
+	snapshotID string,
+	opts apitypes.Store) (*apitypes.Snapshot, error) {
+
+	s, err := d.api.snapshot(snapshotID)
+	if isNotFound(err) {
+		return nil, goof.WithField("snapshotID", snapshotID,
+			"snapshot not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return toSnapshot(s), nil
+}
+
+func (d *driver) SnapshotCopy(
+	ctx apitypes.Context,
+	snapshotID, snapshotName, destinationID string,
+	opts apitypes.Store) (*apitypes.Snapshot, error) {
+
+	return nil, apitypes.ErrNotImplemented
+}
+
+func (d *driver) SnapshotRemove(
+	ctx apitypes.Context,
+	snapshotID string,
+	opts apitypes.Store) error {
+
+	if err := d.api.removeSnapshot(snapshotID); err != nil {
+		if isNotFound(err) {
+			return goof.WithField("snapshotID", snapshotID,
+				"snapshot not found")
+		}
+		return goof.WithFieldE("snapshotID", snapshotID,
+			"error removing snapshot", err)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package dellemc
+
+import (
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/core/openiscsi"
+	"github.com/emccode/rexray/core/scsi"
+)
+
+// executor runs on each node. A node's instance ID is its iSCSI initiator
+// name, and its local devices are the devices of the LUNs presented to it
+// through iSCSI sessions keyed by their WWNs.
+type executor struct {
+	name   string
+	config gofig.Config
+}
+
+func (e *executor) Name() string {
+	return e.name
+}
+
+func (e *executor) Init(ctx apitypes.Context, config gofig.Config) error {
+	e.config = config
+	return nil
+}
+
+func (e *executor) InstanceID(
+	ctx apitypes.Context,
+	opts apitypes.Store) (*apitypes.InstanceID, error) {
+
+	iqn, err := openiscsi.InitiatorName()
+	if err != nil {
+		return nil, err
+	}
+	return &apitypes.InstanceID{ID: iqn, Driver: e.name}, nil
+}
+
+func (e *executor) NextDevice(
+	ctx apitypes.Context,
+	opts apitypes.Store) (string, error) {
+
+	return "", apitypes.ErrNotImplemented
+}
+
+// portals returns the configured iSCSI portals, or else those the array
+// lists, which requires the node to be configured with the array's
+// endpoint and credentials.
+func (e *executor) portals() ([]string, error) {
+	portals := splitList(e.config.GetString(e.name + ".portals"))
+	if len(portals) == 0 {
+		api, err := newArray(e.name, e.config)
+		if err != nil {
+			return nil, goof.WithFieldE("driver", e.name,
+				"nodes require portals or the array's endpoint", err)
+		}
+		if portals, err = api.portals(); err != nil {
+			return nil, err
+		}
+	}
+	for i, p := range portals {
+		portals[i] = openiscsi.PortalAddr(p)
+	}
+	return portals, nil
+}
+
+// LocalDevices returns the devices of the LUNs presented to the node through
+// iSCSI sessions keyed by their normalized WWNs. A deep scan first logs in
+// to the array's portals the node has no session with, rescans the
+// sessions for LUNs the node was granted access to since, and waits for
+// multipathd to coalesce their paths.
+func (e *executor) LocalDevices(
+	ctx apitypes.Context,
+	opts *apitypes.LocalDevicesOpts) (*apitypes.LocalDevices, error) {
+
+	multipath := e.config.GetBool(e.name+".multipath") &&
+		scsi.Multipath(e.config)
+
+	if opts.ScanType == apitypes.DeviceScanDeep {
+		portals, err := e.portals()
+		if err != nil {
+			return nil, err
+		}
+		if err := login(ctx, portals); err != nil {
+			return nil, err
+		}
+		if multipath {
+			scsi.SettleMultipath(ctx, scsi.MultipathTimeout(e.config))
+		}
+	}
+
+	devs, err := scsi.ISCSIWWNDevices(multipath)
+	if err != nil {
+		return nil, err
+	}
+	return &apitypes.LocalDevices{Driver: e.name, DeviceMap: devs}, nil
+}
@@ -0,0 +1,14 @@
+package dellemc
+
+import (
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/core/openiscsi"
+)
+
+// login discovers the targets of and logs in to the portals the node has no
+// session with, and rescans its sessions for LUNs that were presented to
+// the node since they were established.
+func login(ctx apitypes.Context, portals []string) error {
+	return openiscsi.Login(ctx, portals)
+}
@@ -0,0 +1,12 @@
+// +build !linux
+
+package dellemc
+
+import (
+	"github.com/akutz/goof"
+	apitypes "github.com/emccode/libstorage/api/types"
+)
+
+func login(ctx apitypes.Context, portals []string) error {
+	return goof.New("unity and powerstore volumes are only supported on Linux")
+}
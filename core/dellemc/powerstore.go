@@ -0,0 +1,427 @@
+package dellemc
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/akutz/goof"
+)
+
+// powerStorePageSize is the number of resources requested per page.
+const powerStorePageSize = 1000
+
+// The PowerStore API's volume types.
+const (
+	powerStorePrimary  = "Primary"
+	powerStoreSnapshot = "Snapshot"
+)
+
+type powerStoreVolume struct {
+	ID                string    `json:"id"`
+	Name              string    `json:"name"`
+	Description       string    `json:"description"`
+	Size              int64     `json:"size"`
+	WWN               string    `json:"wwn"`
+	State             string    `json:"state"`
+	Type              string    `json:"type"`
+	CreationTimestamp time.Time `json:"creation_timestamp"`
+	ProtectionData    *struct {
+		SourceID string `json:"source_id"`
+	} `json:"protection_data"`
+}
+
+const powerStoreVolumeFields = "id,name,description,size,wwn,state,type," +
+	"creation_timestamp,protection_data"
+
+func (v *powerStoreVolume) snapshot() *snapshot {
+	s := &snapshot{
+		ID:           v.ID,
+		Name:         v.Name,
+		Description:  v.Description,
+		Size:         v.Size,
+		CreationTime: v.CreationTimestamp,
+		State:        strings.ToLower(v.State),
+	}
+	if v.ProtectionData != nil {
+		s.LUNID = v.ProtectionData.SourceID
+	}
+	return s
+}
+
+type powerStoreHost struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Initiators []struct {
+		PortName string `json:"port_name"`
+	} `json:"initiators"`
+}
+
+// powerStore calls the PowerStore REST API. Every request is authenticated
+// with basic authentication, and the token that requests other than GETs
+// require is read from the login session the first of them establishes.
+type powerStore struct {
+	endpoint string
+	username string
+	password string
+	client   *http.Client
+
+	tokenRwl sync.RWMutex
+	token    string
+}
+
+func (p *powerStore) do(method, path string, body, result interface{}) error {
+	res, err := p.send(method, path, body)
+	if err == nil && res.StatusCode == http.StatusUnauthorized &&
+		method != "GET" {
+
+		// the login session expired, so establish a new one and retry
+		res.Body.Close()
+		p.tokenRwl.Lock()
+		p.token = ""
+		p.tokenRwl.Unlock()
+		res, err = p.send(method, path, body)
+	}
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		e := &struct {
+			Messages []struct {
+				Message string `json:"message_l10n"`
+			} `json:"messages"`
+		}{}
+		json.NewDecoder(res.Body).Decode(e)
+		ae := &apiError{status: res.StatusCode}
+		if len(e.Messages) > 0 {
+			ae.message = e.Messages[0].Message
+		}
+		return ae
+	}
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(result)
+}
+
+// send sends a request, first establishing a login session if the request
+// requires its token.
+func (p *powerStore) send(
+	method, path string, body interface{}) (*http.Response, error) {
+
+	if method != "GET" {
+		if err := p.login(); err != nil {
+			return nil, err
+		}
+	}
+	return p.request(method, path, body)
+}
+
+func (p *powerStore) request(
+	method, path string, body interface{}) (*http.Response, error) {
+
+	var buf []byte
+	if body != nil {
+		var err error
+		if buf, err = json.Marshal(body); err != nil {
+			return nil, err
+		}
+	}
+	req, err := http.NewRequest(method, p.endpoint+path, bytes.NewReader(buf))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBasicAuth(p.username, p.password)
+	p.tokenRwl.RLock()
+	if p.token != "" {
+		req.Header.Set("DELL-EMC-TOKEN", p.token)
+	}
+	p.tokenRwl.RUnlock()
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		return nil, goof.WithFieldE("path", path,
+			"error calling powerstore", err)
+	}
+	return res, nil
+}
+
+// login establishes a login session if there is none and reads its token.
+func (p *powerStore) login() error {
+	p.tokenRwl.Lock()
+	defer p.tokenRwl.Unlock()
+	if p.token != "" {
+		return nil
+	}
+	res, err := p.request("GET", "/login_session", nil)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return &apiError{status: res.StatusCode, message: "login failed"}
+	}
+	if p.token = res.Header.Get("DELL-EMC-TOKEN"); p.token == "" {
+		return goof.New("powerstore login session has no token")
+	}
+	return nil
+}
+
+// collection returns all of the resources of a collection that match the
+// query.
+func (p *powerStore) collection(
+	path string, q url.Values, all interface{}) error {
+
+	raw := []json.RawMessage{}
+	for offset := 0; ; offset += powerStorePageSize {
+		q.Set("limit", strconv.Itoa(powerStorePageSize))
+		q.Set("offset", strconv.Itoa(offset))
+		page := []json.RawMessage{}
+		if err := p.do("GET", path+"?"+q.Encode(), nil, &page); err != nil {
+			return err
+		}
+		raw = append(raw, page...)
+		if len(page) < powerStorePageSize {
+			break
+		}
+	}
+	buf, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(buf, all)
+}
+
+// mappings returns the IDs of the hosts mapped to each volume keyed by the
+// volume's ID, or to the one volume if the ID is set.
+func (p *powerStore) mappings(volumeID string) (map[string][]string, error) {
+	q := url.Values{"select": {"host_id,volume_id"}}
+	if volumeID != "" {
+		q.Set("volume_id", "eq."+volumeID)
+	}
+	all := []*struct {
+		HostID   string `json:"host_id"`
+		VolumeID string `json:"volume_id"`
+	}{}
+	if err := p.collection("/host_volume_mapping", q, &all); err != nil {
+		return nil, err
+	}
+	m := map[string][]string{}
+	for _, hm := range all {
+		if hm.HostID != "" {
+			m[hm.VolumeID] = append(m[hm.VolumeID], hm.HostID)
+		}
+	}
+	return m, nil
+}
+
+func (p *powerStore) luns() ([]*lun, error) {
+	all := []*powerStoreVolume{}
+	if err := p.collection("/volume", url.Values{
+		"select": {powerStoreVolumeFields},
+		"type":   {"eq." + powerStorePrimary},
+	}, &all); err != nil {
+		return nil, err
+	}
+	hosts, err := p.mappings("")
+	if err != nil {
+		return nil, err
+	}
+	luns := []*lun{}
+	for _, v := range all {
+		luns = append(luns, &lun{
+			ID:          v.ID,
+			Name:        v.Name,
+			Description: v.Description,
+			Size:        v.Size,
+			WWN:         v.WWN,
+			Health:      strings.ToLower(v.State),
+			Hosts:       hosts[v.ID],
+		})
+	}
+	return luns, nil
+}
+
+func (p *powerStore) volume(id string) (*powerStoreVolume, error) {
+	v := &powerStoreVolume{}
+	if err := p.do("GET", "/volume/"+id+"?select="+
+		url.QueryEscape(powerStoreVolumeFields), nil, v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (p *powerStore) lun(id string) (*lun, error) {
+	v, err := p.volume(id)
+	if err != nil {
+		return nil, err
+	}
+	if v.Type != powerStorePrimary {
+		return nil, notFound("volume", id)
+	}
+	hosts, err := p.mappings(id)
+	if err != nil {
+		return nil, err
+	}
+	return &lun{
+		ID:          v.ID,
+		Name:        v.Name,
+		Description: v.Description,
+		Size:        v.Size,
+		WWN:         v.WWN,
+		Health:      strings.ToLower(v.State),
+		Hosts:       hosts[v.ID],
+	}, nil
+}
+
+// createLUN creates a volume, which is always thin.
+func (p *powerStore) createLUN(opts *createOpts) (string, error) {
+	body := map[string]interface{}{
+		"name":        opts.Name,
+		"description": opts.Description,
+		"size":        opts.Size,
+	}
+	if opts.PerformancePolicy != "" {
+		body["performance_policy_id"] = opts.PerformancePolicy
+	}
+	return p.create("/volume", body)
+}
+
+// create creates a resource and returns its ID.
+func (p *powerStore) create(path string, body interface{}) (string, error) {
+	res := &struct {
+		ID string `json:"id"`
+	}{}
+	if err := p.do("POST", path, body, res); err != nil {
+		return "", err
+	}
+	return res.ID, nil
+}
+
+func (p *powerStore) removeLUN(id string) error {
+	return p.do("DELETE", "/volume/"+id, nil, nil)
+}
+
+func (p *powerStore) hostByInitiator(initiator string) (*host, error) {
+	hosts, err := p.hosts()
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range hosts {
+		for _, i := range h.Initiators {
+			if i == initiator {
+				return h, nil
+			}
+		}
+	}
+	return nil, notFound("host of initiator", initiator)
+}
+
+func (p *powerStore) createHost(name, initiator string) (string, error) {
+	return p.create("/host", map[string]interface{}{
+		"name":    name,
+		"os_type": "Linux",
+		"initiators": []map[string]string{{
+			"port_name": initiator,
+			"port_type": "iSCSI",
+		}},
+	})
+}
+
+func (p *powerStore) hosts() ([]*host, error) {
+	all := []*powerStoreHost{}
+	if err := p.collection("/host", url.Values{
+		"select": {"id,name,initiators"},
+	}, &all); err != nil {
+		return nil, err
+	}
+	hosts := []*host{}
+	for _, ph := range all {
+		h := &host{ID: ph.ID, Name: ph.Name}
+		for _, i := range ph.Initiators {
+			h.Initiators = append(h.Initiators, i.PortName)
+		}
+		hosts = append(hosts, h)
+	}
+	return hosts, nil
+}
+
+func (p *powerStore) attach(lunID, hostID string) error {
+	return p.do("POST", "/volume/"+lunID+"/attach",
+		map[string]string{"host_id": hostID}, nil)
+}
+
+func (p *powerStore) detach(lunID, hostID string) error {
+	return p.do("POST", "/volume/"+lunID+"/detach",
+		map[string]string{"host_id": hostID}, nil)
+}
+
+func (p *powerStore) snapshots() ([]*snapshot, error) {
+	all := []*powerStoreVolume{}
+	if err := p.collection("/volume", url.Values{
+		"select": {powerStoreVolumeFields},
+		"type":   {"eq." + powerStoreSnapshot},
+	}, &all); err != nil {
+		return nil, err
+	}
+	snaps := []*snapshot{}
+	for _, v := range all {
+		snaps = append(snaps, v.snapshot())
+	}
+	return snaps, nil
+}
+
+func (p *powerStore) snapshot(id string) (*snapshot, error) {
+	v, err := p.volume(id)
+	if err != nil {
+		return nil, err
+	}
+	if v.Type != powerStoreSnapshot {
+		return nil, notFound("snapshot", id)
+	}
+	return v.snapshot(), nil
+}
+
+func (p *powerStore) createSnapshot(lunID, name string) (string, error) {
+	return p.create("/volume/"+lunID+"/snapshot",
+		map[string]string{"name": name})
+}
+
+func (p *powerStore) removeSnapshot(id string) error {
+	return p.do("DELETE", "/volume/"+id, nil, nil)
+}
+
+func (p *powerStore) clone(
+	snapshotID, name, description string) (string, error) {
+
+	return p.create("/volume/"+snapshotID+"/clone", map[string]string{
+		"name":        name,
+		"description": description,
+	})
+}
+
+func (p *powerStore) portals() ([]string, error) {
+	all := []*struct {
+		Address string `json:"address"`
+	}{}
+	if err := p.collection("/ip_pool_address", url.Values{
+		"select":   {"address"},
+		"purposes": {"cs.{Storage_Iscsi_Target}"},
+	}, &all); err != nil {
+		return nil, err
+	}
+	portals := []string{}
+	for _, a := range all {
+		portals = append(portals, a.Address)
+	}
+	return portals, nil
+}
@@ -0,0 +1,508 @@
+package dellemc
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/akutz/goof"
+)
+
+// unityPageSize is the number of instances requested per page.
+const unityPageSize = 1000
+
+// The Unity API's enumerations used by the driver.
+const (
+	unityAccessProduction = 1
+	unityHostTypeHost     = 1
+	unityInitiatorISCSI   = 2
+)
+
+// unityHealth are the names of the values of a Unity health status.
+var unityHealth = map[int]string{
+	0:  "unknown",
+	5:  "ok",
+	7:  "ok",
+	10: "degraded",
+	15: "minor",
+	20: "major",
+	25: "critical",
+	30: "non-recoverable",
+}
+
+// unityRef is a reference to another instance.
+type unityRef struct {
+	ID string `json:"id"`
+}
+
+type unityLUN struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	SizeTotal   int64  `json:"sizeTotal"`
+	WWN         string `json:"wwn"`
+	Health      struct {
+		Value int `json:"value"`
+	} `json:"health"`
+	HostAccess []struct {
+		Host unityRef `json:"host"`
+	} `json:"hostAccess"`
+}
+
+const unityLUNFields = "id,name,description,sizeTotal,wwn,health,hostAccess"
+
+func (l *unityLUN) lun() *lun {
+	v := &lun{
+		ID:          l.ID,
+		Name:        l.Name,
+		Description: l.Description,
+		Size:        l.SizeTotal,
+		WWN:         l.WWN,
+		Health:      unityHealth[l.Health.Value],
+	}
+	for _, a := range l.HostAccess {
+		v.Hosts = append(v.Hosts, a.Host.ID)
+	}
+	return v
+}
+
+type unitySnap struct {
+	ID           string    `json:"id"`
+	Name         string    `json:"name"`
+	Description  string    `json:"description"`
+	LUN          *unityRef `json:"lun"`
+	Size         int64     `json:"size"`
+	CreationTime time.Time `json:"creationTime"`
+	State        int       `json:"state"`
+}
+
+const unitySnapFields = "id,name,description,lun,size,creationTime,state"
+
+func (s *unitySnap) snapshot() *snapshot {
+	v := &snapshot{
+		ID:           s.ID,
+		Name:         s.Name,
+		Description:  s.Description,
+		Size:         s.Size,
+		CreationTime: s.CreationTime,
+		State:        "ready",
+	}
+	if s.LUN != nil {
+		v.LUNID = s.LUN.ID
+	}
+	if s.State != 2 {
+		v.State = strconv.Itoa(s.State)
+	}
+	return v
+}
+
+type unityInitiator struct {
+	ID          string    `json:"id"`
+	InitiatorID string    `json:"initiatorId"`
+	ParentHost  *unityRef `json:"parentHost"`
+}
+
+// unity calls the Unity REST API. Every request is authenticated with basic
+// authentication, and the CSRF token that requests other than GETs require
+// is read from the login session the first GET establishes.
+type unity struct {
+	endpoint string
+	username string
+	password string
+	client   *http.Client
+
+	csrfRwl sync.RWMutex
+	csrf    string
+}
+
+func (u *unity) do(method, path string, body, result interface{}) error {
+	res, err := u.send(method, path, body)
+	if err == nil && res.StatusCode == http.StatusUnauthorized &&
+		method != "GET" {
+
+		// the login session expired, so establish a new one and retry
+		res.Body.Close()
+		u.csrfRwl.Lock()
+		u.csrf = ""
+		u.csrfRwl.Unlock()
+		res, err = u.send(method, path, body)
+	}
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		e := &struct {
+			Error struct {
+				Messages []map[string]string `json:"messages"`
+			} `json:"error"`
+		}{}
+		json.NewDecoder(res.Body).Decode(e)
+		ae := &apiError{status: res.StatusCode}
+		if len(e.Error.Messages) > 0 {
+			ae.message = e.Error.Messages[0]["en-US"]
+		}
+		return ae
+	}
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(result)
+}
+
+// send sends a request, first establishing a login session if the request
+// requires its CSRF token.
+func (u *unity) send(
+	method, path string, body interface{}) (*http.Response, error) {
+
+	if method != "GET" {
+		if err := u.login(); err != nil {
+			return nil, err
+		}
+	}
+	return u.request(method, path, body)
+}
+
+func (u *unity) request(
+	method, path string, body interface{}) (*http.Response, error) {
+
+	var buf []byte
+	if body != nil {
+		var err error
+		if buf, err = json.Marshal(body); err != nil {
+			return nil, err
+		}
+	}
+	req, err := http.NewRequest(method, u.endpoint+path, bytes.NewReader(buf))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-EMC-REST-CLIENT", "true")
+	req.SetBasicAuth(u.username, u.password)
+	u.csrfRwl.RLock()
+	if u.csrf != "" {
+		req.Header.Set("EMC-CSRF-TOKEN", u.csrf)
+	}
+	u.csrfRwl.RUnlock()
+
+	res, err := u.client.Do(req)
+	if err != nil {
+		return nil, goof.WithFieldE("path", path, "error calling unity", err)
+	}
+	return res, nil
+}
+
+// login establishes a login session if there is none and reads its CSRF
+// token.
+func (u *unity) login() error {
+	u.csrfRwl.Lock()
+	defer u.csrfRwl.Unlock()
+	if u.csrf != "" {
+		return nil
+	}
+	res, err := u.request("GET", "/api/types/loginSessionInfo/instances", nil)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return &apiError{status: res.StatusCode, message: "login failed"}
+	}
+	if u.csrf = res.Header.Get("EMC-CSRF-TOKEN"); u.csrf == "" {
+		return goof.New("unity login session has no csrf token")
+	}
+	return nil
+}
+
+// instances returns all of the instances of a type that match the filter.
+func (u *unity) instances(
+	typ, fields, filter string, all interface{}) error {
+
+	raw := []json.RawMessage{}
+	for page := 1; ; page++ {
+		q := url.Values{
+			"fields":   {fields},
+			"page":     {strconv.Itoa(page)},
+			"per_page": {strconv.Itoa(unityPageSize)},
+		}
+		if filter != "" {
+			q.Set("filter", filter)
+		}
+		res := &struct {
+			Entries []struct {
+				Content json.RawMessage `json:"content"`
+			} `json:"entries"`
+		}{}
+		if err := u.do("GET",
+			"/api/types/"+typ+"/instances?"+q.Encode(), nil, res); err != nil {
+			return err
+		}
+		for _, e := range res.Entries {
+			raw = append(raw, e.Content)
+		}
+		if len(res.Entries) < unityPageSize {
+			break
+		}
+	}
+	buf, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(buf, all)
+}
+
+func (u *unity) instance(typ, id, fields string, v interface{}) error {
+	res := &struct {
+		Content interface{} `json:"content"`
+	}{Content: v}
+	return u.do("GET", "/api/instances/"+typ+"/"+id+
+		"?fields="+url.QueryEscape(fields), nil, res)
+}
+
+func (u *unity) luns() ([]*lun, error) {
+	all := []*unityLUN{}
+	if err := u.instances("lun", unityLUNFields, "", &all); err != nil {
+		return nil, err
+	}
+	luns := []*lun{}
+	for _, l := range all {
+		luns = append(luns, l.lun())
+	}
+	return luns, nil
+}
+
+func (u *unity) lun(id string) (*lun, error) {
+	l := &unityLUN{}
+	if err := u.instance("lun", id, unityLUNFields, l); err != nil {
+		return nil, err
+	}
+	return l.lun(), nil
+}
+
+// createLUN creates a thin LUN. The ID of a LUN is that of its storage
+// resource.
+func (u *unity) createLUN(opts *createOpts) (string, error) {
+	if opts.Pool == "" {
+		return "", goof.New("unity driver requires unity.pool")
+	}
+	res := &struct {
+		Content struct {
+			StorageResource unityRef `json:"storageResource"`
+		} `json:"content"`
+	}{}
+	if err := u.do("POST", "/api/types/storageResource/action/createLun",
+		map[string]interface{}{
+			"name":        opts.Name,
+			"description": opts.Description,
+			"lunParameters": map[string]interface{}{
+				"pool":          unityRef{ID: opts.Pool},
+				"size":          opts.Size,
+				"isThinEnabled": true,
+			},
+		}, res); err != nil {
+		return "", err
+	}
+	return res.Content.StorageResource.ID, nil
+}
+
+func (u *unity) removeLUN(id string) error {
+	return u.do("DELETE", "/api/instances/storageResource/"+
+		id, nil, nil)
+}
+
+func (u *unity) hostByInitiator(initiator string) (*host, error) {
+	all := []*unityInitiator{}
+	if err := u.instances("hostInitiator", "id,initiatorId,parentHost",
+		"initiatorId eq \""+initiator+"\"", &all); err != nil {
+		return nil, err
+	}
+	if len(all) == 0 || all[0].ParentHost == nil {
+		return nil, notFound("host of initiator", initiator)
+	}
+	h := &host{}
+	if err := u.instance("host", all[0].ParentHost.ID, "id,name", h); err != nil {
+		return nil, err
+	}
+	h.Initiators = []string{initiator}
+	return h, nil
+}
+
+func (u *unity) createHost(name, initiator string) (string, error) {
+	res := &struct {
+		Content unityRef `json:"content"`
+	}{}
+	if err := u.do("POST", "/api/types/host/instances",
+		map[string]interface{}{
+			"type":   unityHostTypeHost,
+			"name":   name,
+			"osType": "Linux",
+		}, res); err != nil {
+		return "", err
+	}
+	if err := u.do("POST", "/api/types/hostInitiator/instances",
+		map[string]interface{}{
+			"host":              res.Content,
+			"initiatorType":     unityInitiatorISCSI,
+			"initiatorWWNorIqn": initiator,
+		}, nil); err != nil {
+		return "", err
+	}
+	return res.Content.ID, nil
+}
+
+func (u *unity) hosts() ([]*host, error) {
+	all := []*host{}
+	if err := u.instances("host", "id,name", "", &all); err != nil {
+		return nil, err
+	}
+	initiators := []*unityInitiator{}
+	if err := u.instances("hostInitiator", "id,initiatorId,parentHost", "",
+		&initiators); err != nil {
+		return nil, err
+	}
+	byID := map[string]*host{}
+	for _, h := range all {
+		byID[h.ID] = h
+	}
+	for _, i := range initiators {
+		if i.ParentHost == nil {
+			continue
+		}
+		if h, ok := byID[i.ParentHost.ID]; ok {
+			h.Initiators = append(h.Initiators, i.InitiatorID)
+		}
+	}
+	return all, nil
+}
+
+// modifyHostAccess replaces the hosts that may access a LUN with those the
+// function returns given the current ones. The API only modifies a LUN's
+// host access as a whole, so the LUN is read first.
+func (u *unity) modifyHostAccess(
+	lunID string, modify func([]string) []string) error {
+
+	l, err := u.lun(lunID)
+	if err != nil {
+		return err
+	}
+	access := []map[string]interface{}{}
+	for _, h := range modify(l.Hosts) {
+		access = append(access, map[string]interface{}{
+			"host":       unityRef{ID: h},
+			"accessMask": unityAccessProduction,
+		})
+	}
+	return u.do("POST", "/api/instances/storageResource/"+
+		lunID+"/action/modifyLun",
+		map[string]interface{}{
+			"lunParameters": map[string]interface{}{"hostAccess": access},
+		}, nil)
+}
+
+func (u *unity) attach(lunID, hostID string) error {
+	return u.modifyHostAccess(lunID, func(hosts []string) []string {
+		for _, h := range hosts {
+			if h == hostID {
+				return hosts
+			}
+		}
+		return append(hosts, hostID)
+	})
+}
+
+func (u *unity) detach(lunID, hostID string) error {
+	return u.modifyHostAccess(lunID, func(hosts []string) []string {
+		keep := []string{}
+		for _, h := range hosts {
+			if h != hostID {
+				keep = append(keep, h)
+			}
+		}
+		return keep
+	})
+}
+
+func (u *unity) snapshots() ([]*snapshot, error) {
+	all := []*unitySnap{}
+	if err := u.instances("snap", unitySnapFields, "", &all); err != nil {
+		return nil, err
+	}
+	snaps := []*snapshot{}
+	for _, s := range all {
+		if s.LUN != nil {
+			snaps = append(snaps, s.snapshot())
+		}
+	}
+	return snaps, nil
+}
+
+func (u *unity) snapshot(id string) (*snapshot, error) {
+	s := &unitySnap{}
+	if err := u.instance("snap", id, unitySnapFields, s); err != nil {
+		return nil, err
+	}
+	return s.snapshot(), nil
+}
+
+func (u *unity) createSnapshot(lunID, name string) (string, error) {
+	res := &struct {
+		Content unityRef `json:"content"`
+	}{}
+	if err := u.do("POST", "/api/types/snap/instances",
+		map[string]interface{}{
+			"storageResource": unityRef{ID: lunID},
+			"name":            name,
+		}, res); err != nil {
+		return "", err
+	}
+	return res.Content.ID, nil
+}
+
+func (u *unity) removeSnapshot(id string) error {
+	return u.do("DELETE", "/api/instances/snap/"+id, nil, nil)
+}
+
+// clone creates a thin clone of a snapshot, which is an action of the
+// storage resource of the snapshot's LUN.
+func (u *unity) clone(snapshotID, name, description string) (string, error) {
+	s, err := u.snapshot(snapshotID)
+	if err != nil {
+		return "", err
+	}
+	res := &struct {
+		Content struct {
+			StorageResource unityRef `json:"storageResource"`
+		} `json:"content"`
+	}{}
+	if err := u.do("POST", "/api/instances/storageResource/"+
+		url.PathEscape(s.LUNID)+"/action/createLunThinClone",
+		map[string]interface{}{
+			"snap":        unityRef{ID: snapshotID},
+			"name":        name,
+			"description": description,
+		}, res); err != nil {
+		return "", err
+	}
+	return res.Content.StorageResource.ID, nil
+}
+
+func (u *unity) portals() ([]string, error) {
+	all := []*struct {
+		IPAddress string `json:"ipAddress"`
+	}{}
+	if err := u.instances("iscsiPortal", "ipAddress", "", &all); err != nil {
+		return nil, err
+	}
+	portals := []string{}
+	for _, p := range all {
+		portals = append(portals, p.IPAddress)
+	}
+	return portals, nil
+}
@@ -0,0 +1,227 @@
+// Package discovery finds the addresses of the REX-Ray controllers, the
+// libStorage servers that agents and the CLI connect to, from a static list,
+// a DNS SRV record, or a Consul service so that the controllers' host need
+// not be hard-coded. Connections fail over between the addresses that are
+// found.
+package discovery
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	"github.com/akutz/gotil"
+	apitypes "github.com/emccode/libstorage/api/types"
+	"github.com/hashicorp/consul/api"
+)
+
+func init() {
+	r := gofig.NewRegistration("Controller Discovery")
+	r.Key(gofig.String, "", "",
+		"A comma-separated list of controller addresses, in order of "+
+			"preference, used if libstorage.host is not set",
+		"rexray.controller.hosts")
+	r.Key(gofig.String, "", "",
+		"The DNS SRV record that lists the controllers, ex. "+
+			"_libstorage._tcp.example.com",
+		"rexray.controller.srv")
+	r.Key(gofig.String, "", "",
+		"The name of the Consul service that lists the controllers",
+		"rexray.controller.consul.service")
+	r.Key(gofig.String, "", "",
+		"The tag the Consul service's controller instances must have",
+		"rexray.controller.consul.tag")
+	r.Key(gofig.String, "", "",
+		"The address of the Consul agent; defaults to 127.0.0.1:8500",
+		"rexray.controller.consul.address")
+	r.Key(gofig.String, "", "",
+		"The ACL token used to query the Consul service",
+		"rexray.controller.consul.token")
+	r.Key(gofig.String, "", "5s",
+		"The duration after which a connection to a controller is abandoned "+
+			"and the next controller is tried",
+		"rexray.controller.dialTimeout")
+	r.Key(gofig.String, "", "1m",
+		"The interval after which discovered controllers are looked up again",
+		"rexray.controller.refreshInterval")
+	gofig.Register(r)
+}
+
+// Enabled returns a flag indicating whether the controllers are discovered
+// rather than given by libstorage.host.
+func Enabled(config gofig.Config) bool {
+	return config.GetString(apitypes.ConfigHost) == "" &&
+		(config.GetString("rexray.controller.hosts") != "" ||
+			config.GetString("rexray.controller.srv") != "" ||
+			config.GetString("rexray.controller.consul.service") != "")
+}
+
+// Resolve returns the addresses of the controllers in order of preference.
+// The static list is preferred to the SRV record, which is preferred to the
+// Consul service.
+func Resolve(config gofig.Config) ([]string, error) {
+	if v := config.GetString("rexray.controller.hosts"); v != "" {
+		addrs := []string{}
+		for _, a := range strings.Split(v, ",") {
+			if a = strings.TrimSpace(a); a != "" {
+				addrs = append(addrs, a)
+			}
+		}
+		return addrs, nil
+	}
+	if v := config.GetString("rexray.controller.srv"); v != "" {
+		return lookupSRV(v)
+	}
+	if v := config.GetString("rexray.controller.consul.service"); v != "" {
+		return lookupConsul(config, v)
+	}
+	return nil, goof.New("controller discovery is not configured")
+}
+
+// lookupSRV returns the targets of an SRV record ordered by priority, and
+// randomly by weight within a priority.
+func lookupSRV(name string) ([]string, error) {
+	_, srvs, err := net.LookupSRV("", "", name)
+	if err != nil {
+		return nil, goof.WithFieldE("srv", name, "error looking up srv", err)
+	}
+	addrs := []string{}
+	for _, s := range srvs {
+		host := strings.TrimSuffix(s.Target, ".")
+		addrs = append(addrs, tcpAddr(host, s.Port))
+	}
+	return addrs, nil
+}
+
+// lookupConsul returns the addresses of the passing instances of a Consul
+// service.
+func lookupConsul(config gofig.Config, service string) ([]string, error) {
+	c := api.DefaultConfig()
+	if v := config.GetString("rexray.controller.consul.address"); v != "" {
+		c.Address = v
+	}
+	if v := config.GetString("rexray.controller.consul.token"); v != "" {
+		c.Token = v
+	}
+	client, err := api.NewClient(c)
+	if err != nil {
+		return nil, err
+	}
+	entries, _, err := client.Health().Service(
+		service, config.GetString("rexray.controller.consul.tag"), true, nil)
+	if err != nil {
+		return nil, goof.WithFieldE(
+			"service", service, "error looking up consul service", err)
+	}
+	addrs := []string{}
+	for _, e := range entries {
+		host := e.Service.Address
+		if host == "" {
+			host = e.Node.Address
+		}
+		addrs = append(addrs, tcpAddr(host, uint16(e.Service.Port)))
+	}
+	return addrs, nil
+}
+
+func tcpAddr(host string, port uint16) string {
+	return "tcp://" + net.JoinHostPort(host, strconv.Itoa(int(port)))
+}
+
+// Dial connects to the first of the addresses that accepts a connection
+// within the timeout and returns the connection and the address's index.
+func Dial(addrs []string, timeout time.Duration) (net.Conn, int, error) {
+	errs := []string{}
+	for i, a := range addrs {
+		proto, addr, err := gotil.ParseAddress(a)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		conn, err := net.DialTimeout(proto, addr, timeout)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		return conn, i, nil
+	}
+	return nil, -1, goof.WithField("errors", strings.Join(errs, "; "),
+		fmt.Sprintf("no controller of %d is reachable", len(addrs)))
+}
+
+// DialTimeout returns the duration after which a connection to a controller
+// is abandoned.
+func DialTimeout(config gofig.Config) time.Duration {
+	d, err := time.ParseDuration(
+		config.GetString("rexray.controller.dialTimeout"))
+	if err != nil || d <= 0 {
+		return 5 * time.Second
+	}
+	return d
+}
+
+// RefreshInterval returns the interval after which discovered controllers
+// are looked up again.
+func RefreshInterval(config gofig.Config) time.Duration {
+	d, err := time.ParseDuration(
+		config.GetString("rexray.controller.refreshInterval"))
+	if err != nil || d <= 0 {
+		return time.Minute
+	}
+	return d
+}
+
+// tlsEnabled returns a flag indicating whether the libStorage client
+// connects to the controllers with TLS.
+func tlsEnabled(config gofig.Config) bool {
+	return config.IsSet("libstorage.tls") &&
+		!strings.EqualFold(config.GetString("libstorage.tls"), "false")
+}
+
+// Configure sets libstorage.host so that the libStorage client connects to
+// the discovered controllers, if discovery is enabled. A relay is started on
+// a loopback address that forwards each connection to the first controller
+// that accepts it, so that a client fails over to another controller
+// without being recreated. The relay cannot forward connections with TLS,
+// whose server names would not match, so with TLS the first controller that
+// is reachable is selected instead.
+func Configure(ctx apitypes.Context, config gofig.Config) error {
+	if !Enabled(config) {
+		return nil
+	}
+
+	addrs, err := Resolve(config)
+	if err != nil {
+		return err
+	}
+	if len(addrs) == 0 {
+		return goof.New("no controllers found")
+	}
+
+	if tlsEnabled(config) {
+		conn, i, err := Dial(addrs, DialTimeout(config))
+		if err != nil {
+			return err
+		}
+		conn.Close()
+		config.Set(apitypes.ConfigHost, addrs[i])
+		ctx.WithField("host", addrs[i]).Info("selected controller")
+		return nil
+	}
+
+	r, err := Listen(ctx, config, addrs)
+	if err != nil {
+		return err
+	}
+	go r.Serve()
+	config.Set(apitypes.ConfigHost, r.Host())
+	ctx.WithFields(map[string]interface{}{
+		"host":        r.Host(),
+		"controllers": addrs,
+	}).Debug("relaying to discovered controllers")
+	return nil
+}
@@ -0,0 +1,96 @@
+package discovery
+
+import (
+	"bufio"
+	"net"
+	"testing"
+
+	"github.com/akutz/gofig"
+	"github.com/emccode/libstorage/api/context"
+)
+
+func TestResolveHosts(t *testing.T) {
+	config := gofig.New()
+	if Enabled(config) {
+		t.Fatal("enabled without configuration")
+	}
+
+	config.Set("rexray.controller.hosts", "tcp://a:7979, tcp://b:7979,")
+	if !Enabled(config) {
+		t.Fatal("not enabled")
+	}
+	addrs, err := Resolve(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(addrs) != 2 || addrs[0] != "tcp://a:7979" ||
+		addrs[1] != "tcp://b:7979" {
+		t.Fatalf("unexpected addresses %v", addrs)
+	}
+
+	config.Set("libstorage.host", "tcp://c:7979")
+	if Enabled(config) {
+		t.Fatal("enabled with libstorage.host")
+	}
+}
+
+func TestRelayFailover(t *testing.T) {
+	dead, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dead.Close()
+
+	live, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer live.Close()
+	go func() {
+		for {
+			conn, err := live.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				line, _ := bufio.NewReader(conn).ReadString('\n')
+				conn.Write([]byte("echo " + line))
+			}()
+		}
+	}()
+
+	config := gofig.New()
+	config.Set("rexray.controller.hosts",
+		"tcp://"+dead.Addr().String()+",tcp://"+live.Addr().String())
+	addrs, err := Resolve(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := Listen(context.Background(), config, addrs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	go r.Serve()
+
+	for i := 0; i < 2; i++ {
+		conn, err := net.Dial("tcp", r.l.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.Write([]byte("hello\n"))
+		line, err := bufio.NewReader(conn).ReadString('\n')
+		conn.Close()
+		if err != nil || line != "echo hello\n" {
+			t.Fatalf("unexpected reply %q: %v", line, err)
+		}
+		r.Lock()
+		current := r.current
+		r.Unlock()
+		if current != 1 {
+			t.Fatalf("current=%d", current)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package discovery
+
+import (
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/akutz/gofig"
+	apitypes "github.com/emccode/libstorage/api/types"
+)
+
+// Relay forwards the connections it accepts on a loopback address to the
+// first of the controllers that accepts them. The controller that accepted
+// the last connection is tried first, and the controllers are looked up
+// again once the refresh interval has elapsed or if none is reachable.
+type Relay struct {
+	ctx      apitypes.Context
+	config   gofig.Config
+	l        net.Listener
+	timeout  time.Duration
+	interval time.Duration
+
+	sync.Mutex
+	addrs    []string
+	resolved time.Time
+	current  int
+}
+
+// Listen returns a relay to the provided controllers that listens on a
+// random loopback port.
+func Listen(
+	ctx apitypes.Context,
+	config gofig.Config,
+	addrs []string) (*Relay, error) {
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	return &Relay{
+		ctx:      ctx,
+		config:   config,
+		l:        l,
+		timeout:  DialTimeout(config),
+		interval: RefreshInterval(config),
+		addrs:    addrs,
+		resolved: time.Now(),
+	}, nil
+}
+
+// Host returns the address of the relay as a libStorage host.
+func (r *Relay) Host() string {
+	return "tcp://" + r.l.Addr().String()
+}
+
+// Serve forwards the accepted connections until the relay is closed.
+func (r *Relay) Serve() error {
+	for {
+		conn, err := r.l.Accept()
+		if err != nil {
+			return err
+		}
+		go r.forward(conn)
+	}
+}
+
+// Close stops the relay. Forwarded connections are not closed.
+func (r *Relay) Close() error {
+	return r.l.Close()
+}
+
+func (r *Relay) forward(conn net.Conn) {
+	defer conn.Close()
+
+	upstream, err := r.dial()
+	if err != nil {
+		r.ctx.WithError(err).Error("error connecting to controller")
+		return
+	}
+	defer upstream.Close()
+
+	done := make(chan bool, 2)
+	pipe := func(dst, src net.Conn) {
+		io.Copy(dst, src)
+		if c, ok := dst.(*net.TCPConn); ok {
+			c.CloseWrite()
+		}
+		done <- true
+	}
+	go pipe(upstream, conn)
+	go pipe(conn, upstream)
+	<-done
+	<-done
+}
+
+// dial connects to the first reachable controller, starting with the one
+// that accepted the last connection. If none is reachable the controllers
+// are looked up again and, if they changed, tried again.
+func (r *Relay) dial() (net.Conn, error) {
+	r.Lock()
+	defer r.Unlock()
+
+	if time.Since(r.resolved) >= r.interval {
+		r.refresh()
+	}
+
+	conn, err := r.dialFromCurrent()
+	if err == nil {
+		return conn, nil
+	}
+	if !r.refresh() {
+		return nil, err
+	}
+	return r.dialFromCurrent()
+}
+
+func (r *Relay) dialFromCurrent() (net.Conn, error) {
+	n := len(r.addrs)
+	ordered := make([]string, n)
+	for i := range r.addrs {
+		ordered[i] = r.addrs[(r.current+i)%n]
+	}
+	conn, i, err := Dial(ordered, r.timeout)
+	if err != nil {
+		return nil, err
+	}
+	if i > 0 {
+		r.ctx.WithFields(map[string]interface{}{
+			"from": ordered[0],
+			"to":   ordered[i],
+		}).Warn("failed over to another controller")
+	}
+	r.current = (r.current + i) % n
+	return conn, nil
+}
+
+// refresh looks up the controllers again and returns a flag indicating
+// whether they changed. The previous controllers are kept if none are found.
+// The controller that accepted the last connection is still tried first if
+// it is found again.
+func (r *Relay) refresh() bool {
+	r.resolved = time.Now()
+	addrs, err := Resolve(r.config)
+	if err != nil {
+		r.ctx.WithError(err).Warn("error looking up controllers")
+		return false
+	}
+	if len(addrs) == 0 {
+		r.ctx.Warn("no controllers found")
+		return false
+	}
+
+	current := r.addrs[r.current]
+	r.current = 0
+	for i, a := range addrs {
+		if a == current {
+			r.current = i
+		}
+	}
+	changed := !sameAddrs(addrs, r.addrs)
+	if changed {
+		r.ctx.WithField("controllers", addrs).Info("controllers changed")
+	}
+	r.addrs = addrs
+	return changed
+}
+
+// sameAddrs returns a flag indicating whether two lists hold the same
+// addresses, in any order, since SRV targets of the same priority are
+// returned in a random order.
+func sameAddrs(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	m := map[string]bool{}
+	for _, v := range a {
+		m[v] = true
+	}
+	for _, v := range b {
+		if !m[v] {
+			return false
+		}
+	}
+	return true
+}
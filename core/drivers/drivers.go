@@ -0,0 +1,63 @@
+// Package drivers records the storage drivers that are compiled into the
+// binary. All of the drivers are compiled in unless the binary is built with
+// the rexray_drivers tag, in which case only the drivers whose
+// rexray_driver_NAME tags are also present are compiled in, ex.
+//
+//     go build -tags 'rexray_drivers rexray_driver_isilon rexray_driver_vfs'
+//
+// or, with the Makefile,
+//
+//     make build DRIVERS=isilon,vfs
+//
+// The storage drivers are loaded by importing the storage package, and their
+// executors by importing the executors package.
+package drivers
+
+import (
+	"sort"
+	"sync"
+)
+
+var (
+	namesRwl sync.RWMutex
+	names    = map[string]bool{}
+
+	shutdownsRwl sync.RWMutex
+	shutdowns    []func()
+)
+
+// Register records that the named driver is compiled into the binary.
+func Register(name string) {
+	namesRwl.Lock()
+	defer namesRwl.Unlock()
+	names[name] = true
+}
+
+// Names returns the sorted names of the drivers compiled into the binary.
+func Names() []string {
+	namesRwl.RLock()
+	defer namesRwl.RUnlock()
+	s := make([]string, 0, len(names))
+	for n := range names {
+		s = append(s, n)
+	}
+	sort.Strings(s)
+	return s
+}
+
+// RegisterShutdown registers a function that releases the resources of a
+// driver, ex. the processes it started, when the libStorage server stops.
+func RegisterShutdown(f func()) {
+	shutdownsRwl.Lock()
+	defer shutdownsRwl.Unlock()
+	shutdowns = append(shutdowns, f)
+}
+
+// Shutdown invokes the registered shutdown functions.
+func Shutdown() {
+	shutdownsRwl.RLock()
+	defer shutdownsRwl.RUnlock()
+	for _, f := range shutdowns {
+		f()
+	}
+}
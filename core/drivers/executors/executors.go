@@ -0,0 +1,3 @@
+// Package executors loads the executors of the storage drivers compiled into
+// the binary.
+package executors
@@ -0,0 +1,7 @@
+// +build !rexray_drivers rexray_driver_equinix
+
+package executors
+
+import (
+	_ "github.com/emccode/rexray/core/equinix"
+)
@@ -0,0 +1,7 @@
+// +build !rexray_drivers rexray_driver_glusterfs
+
+package executors
+
+import (
+	_ "github.com/emccode/rexray/core/glusterfs"
+)
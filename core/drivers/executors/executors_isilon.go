@@ -0,0 +1,7 @@
+// +build !rexray_drivers rexray_driver_isilon
+
+package executors
+
+import (
+	_ "github.com/emccode/libstorage/drivers/storage/isilon/executor"
+)
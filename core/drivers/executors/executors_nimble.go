@@ -0,0 +1,7 @@
+// +build !rexray_drivers rexray_driver_nimble
+
+package executors
+
+import (
+	_ "github.com/emccode/rexray/core/nimble"
+)
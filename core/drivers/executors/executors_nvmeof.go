@@ -0,0 +1,7 @@
+// +build !rexray_drivers rexray_driver_nvmeof
+
+package executors
+
+import (
+	_ "github.com/emccode/rexray/core/nvmeof"
+)
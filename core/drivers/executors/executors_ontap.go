@@ -0,0 +1,7 @@
+// +build !rexray_drivers rexray_driver_ontap
+
+package executors
+
+import (
+	_ "github.com/emccode/rexray/core/ontap"
+)
@@ -0,0 +1,7 @@
+// +build !rexray_drivers rexray_driver_script
+
+package executors
+
+import (
+	_ "github.com/emccode/rexray/core/script"
+)
@@ -0,0 +1,7 @@
+// +build !rexray_drivers rexray_driver_vfs
+
+package executors
+
+import (
+	_ "github.com/emccode/libstorage/drivers/storage/vfs/executor"
+)
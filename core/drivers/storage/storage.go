@@ -0,0 +1,2 @@
+// Package storage loads the storage drivers compiled into the binary.
+package storage
@@ -0,0 +1,12 @@
+// +build !rexray_drivers rexray_driver_cephfs
+
+package storage
+
+import (
+	"github.com/emccode/rexray/core/cephfs"
+	"github.com/emccode/rexray/core/drivers"
+)
+
+func init() {
+	drivers.Register(cephfs.Name)
+}
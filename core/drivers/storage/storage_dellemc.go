@@ -0,0 +1,13 @@
+// +build !rexray_drivers rexray_driver_dellemc
+
+package storage
+
+import (
+	"github.com/emccode/rexray/core/dellemc"
+	"github.com/emccode/rexray/core/drivers"
+)
+
+func init() {
+	drivers.Register(dellemc.NamePowerStore)
+	drivers.Register(dellemc.NameUnity)
+}
@@ -0,0 +1,12 @@
+// +build !rexray_drivers rexray_driver_equinix
+
+package storage
+
+import (
+	"github.com/emccode/rexray/core/drivers"
+	"github.com/emccode/rexray/core/equinix"
+)
+
+func init() {
+	drivers.Register(equinix.Name)
+}
@@ -0,0 +1,13 @@
+// +build !rexray_drivers rexray_driver_external
+
+package storage
+
+import (
+	"github.com/emccode/rexray/core/drivers"
+	"github.com/emccode/rexray/core/external"
+)
+
+func init() {
+	drivers.Register(external.Name)
+	drivers.RegisterShutdown(external.Shutdown)
+}
@@ -0,0 +1,12 @@
+// +build !rexray_drivers rexray_driver_glusterfs
+
+package storage
+
+import (
+	"github.com/emccode/rexray/core/drivers"
+	"github.com/emccode/rexray/core/glusterfs"
+)
+
+func init() {
+	drivers.Register(glusterfs.Name)
+}
@@ -0,0 +1,12 @@
+// +build !rexray_drivers rexray_driver_ibmvpc
+
+package storage
+
+import (
+	"github.com/emccode/rexray/core/drivers"
+	"github.com/emccode/rexray/core/ibmvpc"
+)
+
+func init() {
+	drivers.Register(ibmvpc.Name)
+}
@@ -0,0 +1,12 @@
+// +build !rexray_drivers rexray_driver_iscsi
+
+package storage
+
+import (
+	"github.com/emccode/rexray/core/drivers"
+	"github.com/emccode/rexray/core/iscsi"
+)
+
+func init() {
+	drivers.Register(iscsi.Name)
+}
@@ -0,0 +1,12 @@
+// +build !rexray_drivers rexray_driver_linode
+
+package storage
+
+import (
+	"github.com/emccode/rexray/core/drivers"
+	"github.com/emccode/rexray/core/linode"
+)
+
+func init() {
+	drivers.Register(linode.Name)
+}
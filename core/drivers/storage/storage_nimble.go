@@ -0,0 +1,12 @@
+// +build !rexray_drivers rexray_driver_nimble
+
+package storage
+
+import (
+	"github.com/emccode/rexray/core/drivers"
+	"github.com/emccode/rexray/core/nimble"
+)
+
+func init() {
+	drivers.Register(nimble.Name)
+}
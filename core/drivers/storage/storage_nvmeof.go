@@ -0,0 +1,12 @@
+// +build !rexray_drivers rexray_driver_nvmeof
+
+package storage
+
+import (
+	"github.com/emccode/rexray/core/drivers"
+	"github.com/emccode/rexray/core/nvmeof"
+)
+
+func init() {
+	drivers.Register(nvmeof.Name)
+}
@@ -0,0 +1,12 @@
+// +build !rexray_drivers rexray_driver_oci
+
+package storage
+
+import (
+	"github.com/emccode/rexray/core/drivers"
+	"github.com/emccode/rexray/core/oci"
+)
+
+func init() {
+	drivers.Register(oci.Name)
+}
@@ -0,0 +1,13 @@
+// +build !rexray_drivers rexray_driver_ontap
+
+package storage
+
+import (
+	"github.com/emccode/rexray/core/drivers"
+	"github.com/emccode/rexray/core/ontap"
+)
+
+func init() {
+	drivers.Register(ontap.NameNAS)
+	drivers.Register(ontap.NameSAN)
+}
@@ -0,0 +1,13 @@
+// +build !rexray_drivers rexray_driver_scaleio
+
+package storage
+
+import (
+	_ "github.com/emccode/libstorage/drivers/storage/scaleio"
+
+	"github.com/emccode/rexray/core/drivers"
+)
+
+func init() {
+	drivers.Register("scaleio")
+}
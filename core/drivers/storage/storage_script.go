@@ -0,0 +1,12 @@
+// +build !rexray_drivers rexray_driver_script
+
+package storage
+
+import (
+	"github.com/emccode/rexray/core/drivers"
+	"github.com/emccode/rexray/core/script"
+)
+
+func init() {
+	drivers.Register(script.Name)
+}
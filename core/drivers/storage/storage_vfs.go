@@ -0,0 +1,13 @@
+// +build !rexray_drivers rexray_driver_vfs
+
+package storage
+
+import (
+	_ "github.com/emccode/libstorage/drivers/storage/vfs"
+
+	"github.com/emccode/rexray/core/drivers"
+)
+
+func init() {
+	drivers.Register("vfs")
+}
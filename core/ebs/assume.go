@@ -0,0 +1,244 @@
+package ebs
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/session"
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/core/schedule"
+)
+
+const (
+	defaultRoleSessionName = "rexray"
+	defaultRoleDuration    = time.Hour
+
+	// expiryWindow is how long before they expire that assumed credentials
+	// are refreshed.
+	expiryWindow = 5 * time.Minute
+
+	// refreshInterval is how often assumed credentials are checked for
+	// expiry.
+	refreshInterval = time.Minute
+)
+
+// roleConfig is a service's role configuration.
+type roleConfig struct {
+	service     string
+	roleARN     string
+	externalID  string
+	sessionName string
+	duration    time.Duration
+	region      string
+}
+
+// serviceKey returns the key of a setting in a service's configuration.
+func serviceKey(service, key string) string {
+	return fmt.Sprintf("%s.%s.%s", apitypes.ConfigServices, service, key)
+}
+
+// services returns the names of the EBS services.
+func services(config gofig.Config) []string {
+	svcs, ok := config.Get(apitypes.ConfigServices).(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	names := []string{}
+	for name := range svcs {
+		if IsDriver(name) ||
+			IsDriver(config.GetString(serviceKey(name, "driver"))) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// roles returns the role configurations of the EBS services that define a
+// role ARN.
+func roles(config gofig.Config) []*roleConfig {
+	rcs := []*roleConfig{}
+	for _, name := range services(config) {
+		arn := config.GetString(serviceKey(name, "ebs.roleARN"))
+		if arn == "" {
+			continue
+		}
+		key := func(k string) string { return serviceKey(name, k) }
+		rc := &roleConfig{
+			service:     name,
+			roleARN:     arn,
+			externalID:  config.GetString(key("ebs.externalID")),
+			sessionName: config.GetString(key("ebs.roleSessionName")),
+			region:      config.GetString(key("ebs.region")),
+			duration:    defaultRoleDuration,
+		}
+		if rc.sessionName == "" {
+			rc.sessionName = defaultRoleSessionName
+		}
+		if d, err := time.ParseDuration(
+			config.GetString(key("ebs.roleDuration"))); err == nil && d > 0 {
+			rc.duration = d
+		}
+		rcs = append(rcs, rc)
+	}
+	return rcs
+}
+
+// Restart restarts the libStorage server with a copy of its configuration
+// that is modified by update.
+type Restart func(update func(config gofig.Config)) error
+
+// AssumeRoles assumes the role of each EBS service that defines a role ARN
+// and sets the temporary credentials in the service's configuration, so
+// that one REX-Ray server may manage volumes in several AWS accounts. It
+// must be called before the libStorage server is started. The roles are
+// assumed with the credentials of the environment or the shared credentials
+// file, or else of the instance profile, which are read with an IMDSv2
+// session token; only the instance profile's are used if
+// rexray.ebs.instanceProfileOnly is enabled. The assumed credentials are
+// cached and refreshed in the background before they expire, and since the
+// server's drivers read their credentials only when they are initialized,
+// the server is restarted with the refreshed credentials.
+func AssumeRoles(
+	ctx apitypes.Context, config gofig.Config, restart Restart) error {
+
+	rcs := roles(config)
+	if len(rcs) == 0 {
+		return nil
+	}
+
+	var region string
+	for _, rc := range rcs {
+		if rc.region != "" {
+			continue
+		}
+		if region == "" {
+			id, err := NewMetadataFromConfig(config).Identity()
+			if err != nil {
+				return goof.WithFieldE("service", rc.service,
+					"error detecting region for assumed role", err)
+			}
+			region = id.Region
+		}
+		rc.region = region
+	}
+
+	for _, rc := range rcs {
+		creds, err := newRoleCredentials(config, rc)
+		if err != nil {
+			return err
+		}
+		v, err := creds.Get()
+		if err != nil {
+			return goof.WithFieldsE(goof.Fields{
+				"service": rc.service,
+				"roleARN": rc.roleARN,
+			}, "error assuming role", err)
+		}
+		setCredentials(config, rc, v)
+		ctx.WithFields(map[string]interface{}{
+			"service": rc.service,
+			"roleARN": rc.roleARN,
+		}).Info("assumed role for service")
+
+		if err := schedule.Default(ctx).Add(&schedule.Job{
+			Name:     "ebs.refreshCredentials." + rc.service,
+			Schedule: schedule.Every(refreshInterval),
+			Run: refreshCredentials(
+				ctx, []*roleConfig{rc}, creds, v.AccessKeyID, restart),
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newRoleCredentials(
+	config gofig.Config, rc *roleConfig) (*credentials.Credentials, error) {
+
+	source := newProfileCredentials(config)
+	if !config.GetBool("rexray.ebs.instanceProfileOnly") {
+		source = credentials.NewChainCredentials([]credentials.Provider{
+			&credentials.EnvProvider{},
+			&credentials.SharedCredentialsProvider{},
+			&profileProvider{metadata: NewMetadataFromConfig(config)},
+		})
+	}
+	sess, err := session.NewSession(&aws.Config{
+		Region:      aws.String(rc.region),
+		Credentials: source,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stscreds.NewCredentials(sess, rc.roleARN,
+		func(p *stscreds.AssumeRoleProvider) {
+			p.RoleSessionName = rc.sessionName
+			p.Duration = rc.duration
+			p.ExpiryWindow = expiryWindow
+			if rc.externalID != "" {
+				p.ExternalID = aws.String(rc.externalID)
+			}
+		}), nil
+}
+
+// setCredentials sets a service's credentials in the provided configuration.
+func setCredentials(
+	config gofig.Config, rc *roleConfig, v credentials.Value) {
+
+	config.Set(serviceKey(rc.service, "ebs.accessKey"), v.AccessKeyID)
+	config.Set(serviceKey(rc.service, "ebs.secretKey"), v.SecretAccessKey)
+	config.Set(serviceKey(rc.service, "ebs.sessionToken"), v.SessionToken)
+	config.Set(serviceKey(rc.service, "ebs.region"), rc.region)
+}
+
+// refreshCredentials returns a job that restarts the libStorage server with
+// the services' credentials when they are renewed. The cached credentials
+// are only renewed if they are about to expire, and the server is only
+// restarted if they changed. The configuration of the running server is
+// never changed since its drivers would not read it again.
+func refreshCredentials(
+	ctx apitypes.Context,
+	rcs []*roleConfig,
+	creds *credentials.Credentials,
+	accessKey string,
+	restart Restart) func() {
+
+	names := make([]string, len(rcs))
+	for i, rc := range rcs {
+		names[i] = rc.service
+	}
+	fields := map[string]interface{}{"services": names}
+	if rcs[0].roleARN != "" {
+		fields["roleARN"] = rcs[0].roleARN
+	}
+	return func() {
+		v, err := creds.Get()
+		if err != nil {
+			ctx.WithFields(fields).WithError(err).Error(
+				"error refreshing credentials")
+			return
+		}
+		if v.AccessKeyID == accessKey {
+			return
+		}
+		if err := restart(func(config gofig.Config) {
+			for _, rc := range rcs {
+				setCredentials(config, rc, v)
+			}
+		}); err != nil {
+			ctx.WithFields(fields).WithError(err).Error(
+				"error restarting libStorage server with refreshed " +
+					"credentials")
+			return
+		}
+		accessKey = v.AccessKeyID
+		ctx.WithFields(fields).Info(
+			"restarted libStorage server with refreshed credentials")
+	}
+}
@@ -0,0 +1,222 @@
+// Package ebs provides the parts of REX-Ray's support for Amazon EBS that run
+// outside of the libStorage storage driver: access to the EC2 instance
+// metadata service and the enforcement of instance-profile-only operation.
+package ebs
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	"github.com/akutz/gotil"
+	apitypes "github.com/emccode/libstorage/api/types"
+)
+
+// driverNames are the names of the libStorage drivers that manage EBS.
+var driverNames = []string{"ebs", "ec2"}
+
+// The configuration keys, environment variables, and shared file settings
+// that provide static AWS credentials, or that select credentials other
+// than the instance profile's.
+var (
+	staticKeys = []string{
+		"accessKey",
+		"secretKey",
+		"sessionToken",
+	}
+	staticEnvVars = []string{
+		"AWS_ACCESS_KEY_ID",
+		"AWS_ACCESS_KEY",
+		"AWS_SECRET_ACCESS_KEY",
+		"AWS_SECRET_KEY",
+		"AWS_SESSION_TOKEN",
+		"AWS_PROFILE",
+		"AWS_DEFAULT_PROFILE",
+		"AWS_WEB_IDENTITY_TOKEN_FILE",
+		"AWS_CONTAINER_CREDENTIALS_RELATIVE_URI",
+		"AWS_CONTAINER_CREDENTIALS_FULL_URI",
+	}
+	staticFileKeys = []string{
+		"aws_access_key_id",
+		"aws_secret_access_key",
+		"aws_session_token",
+		"credential_process",
+		"source_profile",
+		"web_identity_token_file",
+	}
+)
+
+func init() {
+	r := gofig.NewRegistration("EBS")
+	r.Key(gofig.Bool, "", false,
+		"Refuse static AWS credentials so that only the instance profile is "+
+			"ever used",
+		"rexray.ebs.instanceProfileOnly")
+	r.Key(gofig.String, "", DefaultMetadataEndpoint,
+		"The address of the EC2 instance metadata service",
+		"rexray.ebs.metadataEndpoint")
+	r.Key(gofig.String, "", "2s",
+		"The timeout for requests to the EC2 instance metadata service",
+		"rexray.ebs.metadataTimeout")
+	gofig.Register(r)
+}
+
+// NewMetadataFromConfig returns a metadata client for the configured
+// endpoint and timeout.
+func NewMetadataFromConfig(config gofig.Config) *Metadata {
+	timeout, err := time.ParseDuration(
+		config.GetString("rexray.ebs.metadataTimeout"))
+	if err != nil {
+		timeout = 2 * time.Second
+	}
+	return NewMetadata(
+		config.GetString("rexray.ebs.metadataEndpoint"), timeout)
+}
+
+// IsConfigured returns a flag indicating whether or not the configured
+// libStorage service, or any of the services the embedded server provides,
+// uses an EBS driver.
+func IsConfigured(config gofig.Config) bool {
+	if IsDriver(config.GetString(apitypes.ConfigService)) {
+		return true
+	}
+	services, ok := config.Get(apitypes.ConfigServices).(map[string]interface{})
+	if !ok {
+		return false
+	}
+	for name, v := range services {
+		if IsDriver(name) {
+			return true
+		}
+		if svc, ok := v.(map[string]interface{}); ok {
+			if d, ok := svc["driver"].(string); ok && IsDriver(d) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// IsDriver returns a flag indicating whether or not the libStorage driver
+// with the provided name manages EBS volumes.
+func IsDriver(name string) bool {
+	for _, d := range driverNames {
+		if strings.EqualFold(name, d) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateCredentials returns an error if instance-profile-only operation is
+// enabled and any source of credentials other than the instance profile is
+// configured, or if the instance has no instance profile. The sources are
+// the drivers' keys, globally and in each service, the environment, and the
+// shared credentials and config files, any profile of which would be used by
+// the AWS SDK before the instance profile.
+func ValidateCredentials(config gofig.Config) error {
+	if !config.GetBool("rexray.ebs.instanceProfileOnly") {
+		return nil
+	}
+	if err := staticCredentials(config); err != nil {
+		return err
+	}
+	if _, err := NewMetadataFromConfig(config).InstanceProfile(); err != nil {
+		return goof.WithFieldE(
+			"instanceProfileOnly", true, "no instance profile found", err)
+	}
+	return nil
+}
+
+// staticCredentials returns an error that names the first source of
+// credentials other than the instance profile that is configured, or nil if
+// there is none.
+func staticCredentials(config gofig.Config) error {
+	keys := []string{}
+	for _, d := range driverNames {
+		for _, k := range staticKeys {
+			keys = append(keys, d+"."+k)
+			for _, name := range services(config) {
+				keys = append(keys, serviceKey(name, d+"."+k))
+			}
+		}
+	}
+	for _, k := range keys {
+		if config.GetString(k) != "" {
+			return goof.WithField("key", k,
+				"static credentials refused; instance-profile-only enabled")
+		}
+	}
+	for _, k := range staticEnvVars {
+		if os.Getenv(k) != "" {
+			return goof.WithField("envVar", k,
+				"static credentials refused; instance-profile-only enabled")
+		}
+	}
+	for _, path := range sharedFiles() {
+		if err := checkSharedFile(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sharedFiles returns the paths of the shared credentials and config files.
+func sharedFiles() []string {
+	dir := filepath.Join(gotil.HomeDir(), ".aws")
+	files := []string{
+		filepath.Join(dir, "credentials"),
+		filepath.Join(dir, "config"),
+	}
+	if v := os.Getenv("AWS_SHARED_CREDENTIALS_FILE"); v != "" {
+		files[0] = v
+	}
+	if v := os.Getenv("AWS_CONFIG_FILE"); v != "" {
+		files[1] = v
+	}
+	return files
+}
+
+// checkSharedFile returns an error if the shared credentials or config file
+// at the provided path sets credentials in any of its profiles. A file that
+// does not exist sets none.
+func checkSharedFile(path string) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return goof.WithFieldE("path", path,
+			"error reading shared credentials file", err)
+	}
+	defer f.Close()
+
+	profile := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			profile = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[1]) == "" {
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(parts[0]))
+		for _, k := range staticFileKeys {
+			if key == k {
+				return goof.WithFields(goof.Fields{
+					"path":    path,
+					"profile": profile,
+					"key":     key,
+				}, "static credentials refused; instance-profile-only enabled")
+			}
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,160 @@
+package ebs
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/akutz/goof"
+)
+
+const (
+	// DefaultMetadataEndpoint is the address of the EC2 instance metadata
+	// service.
+	DefaultMetadataEndpoint = "http://169.254.169.254"
+
+	tokenTTLHeader = "X-aws-ec2-metadata-token-ttl-seconds"
+	tokenHeader    = "X-aws-ec2-metadata-token"
+	tokenTTL       = "21600"
+
+	// tokenLifetime is how long a session token is used before another is
+	// requested, which is shorter than its TTL.
+	tokenLifetime = 5 * time.Hour
+)
+
+// Metadata is a client for the EC2 instance metadata service. It uses an
+// IMDSv2 session token and falls back to IMDSv1 requests if a token cannot
+// be obtained. A client may be used concurrently, and by a long-running
+// process, since its session token is renewed before it expires.
+type Metadata struct {
+	sync.Mutex
+	endpoint string
+	client   *http.Client
+	token    string
+	tokenSet bool
+	expires  time.Time
+}
+
+// InstanceIdentity is the subset of the instance identity document that
+// REX-Ray reports.
+type InstanceIdentity struct {
+	InstanceID       string `json:"instanceId"`
+	Region           string `json:"region"`
+	AvailabilityZone string `json:"availabilityZone"`
+}
+
+// NewMetadata returns a new metadata client for the provided endpoint. The
+// default endpoint is used if the provided one is empty.
+func NewMetadata(endpoint string, timeout time.Duration) *Metadata {
+	if endpoint == "" {
+		endpoint = DefaultMetadataEndpoint
+	}
+	return &Metadata{
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		client:   &http.Client{Timeout: timeout},
+	}
+}
+
+// IMDSv2 returns a flag indicating whether or not the client obtained an
+// IMDSv2 session token.
+func (m *Metadata) IMDSv2() bool {
+	return m.sessionToken() != ""
+}
+
+// sessionToken returns an IMDSv2 session token, or an empty string if the
+// metadata service does not issue them. A token is requested again once it
+// is about to expire, but not if the service did not issue the first one.
+func (m *Metadata) sessionToken() string {
+	m.Lock()
+	defer m.Unlock()
+
+	if m.tokenSet && (m.token == "" || time.Now().Before(m.expires)) {
+		return m.token
+	}
+	m.tokenSet = true
+
+	req, err := http.NewRequest("PUT", m.endpoint+"/latest/api/token", nil)
+	if err != nil {
+		return ""
+	}
+	req.Header.Set(tokenTTLHeader, tokenTTL)
+
+	res, err := m.client.Do(req)
+	if err != nil {
+		return ""
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return ""
+	}
+
+	buf, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return ""
+	}
+	m.token = strings.TrimSpace(string(buf))
+	m.expires = time.Now().Add(tokenLifetime)
+	return m.token
+}
+
+// Get returns the metadata at the provided path, ex. meta-data/instance-id.
+func (m *Metadata) Get(path string) (string, error) {
+	req, err := http.NewRequest(
+		"GET", m.endpoint+"/latest/"+strings.TrimPrefix(path, "/"), nil)
+	if err != nil {
+		return "", err
+	}
+	if token := m.sessionToken(); token != "" {
+		req.Header.Set(tokenHeader, token)
+	}
+
+	res, err := m.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return "", goof.WithFields(goof.Fields{
+			"path":   path,
+			"status": res.StatusCode,
+		}, "error reading instance metadata")
+	}
+
+	buf, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+// Identity returns the instance's identity document.
+func (m *Metadata) Identity() (*InstanceIdentity, error) {
+	doc, err := m.Get("dynamic/instance-identity/document")
+	if err != nil {
+		return nil, err
+	}
+	id := &InstanceIdentity{}
+	if err := json.Unmarshal([]byte(doc), id); err != nil {
+		return nil, err
+	}
+	return id, nil
+}
+
+// InstanceProfile returns the name of the IAM role of the instance profile
+// associated with the instance.
+func (m *Metadata) InstanceProfile() (string, error) {
+	roles, err := m.Get("meta-data/iam/security-credentials/")
+	if err != nil {
+		return "", goof.WithFieldE(
+			"endpoint", m.endpoint, "no instance profile", err)
+	}
+	role := strings.TrimSpace(strings.SplitN(roles, "\n", 2)[0])
+	if role == "" {
+		return "", goof.New("no instance profile")
+	}
+	return role, nil
+}
@@ -0,0 +1,144 @@
+package ebs
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/core/schedule"
+)
+
+// profileProviderName is the name of the provider of instance profile
+// credentials read with an IMDSv2 session token.
+const profileProviderName = "RexrayInstanceProfileProvider"
+
+// profileProvider provides the credentials of the instance profile, which
+// it reads from the instance metadata service with REX-Ray's metadata
+// client. The AWS SDK's own provider does not use IMDSv2 session tokens, so
+// it cannot read the credentials of an instance that requires them.
+type profileProvider struct {
+	credentials.Expiry
+	metadata *Metadata
+}
+
+// profileCredentials is the document of an instance profile's credentials.
+type profileCredentials struct {
+	Code            string
+	AccessKeyID     string `json:"AccessKeyId"`
+	SecretAccessKey string
+	Token           string
+	Expiration      time.Time
+}
+
+// newProfileCredentials returns the credentials of the instance profile.
+func newProfileCredentials(config gofig.Config) *credentials.Credentials {
+	return credentials.NewCredentials(
+		&profileProvider{metadata: NewMetadataFromConfig(config)})
+}
+
+// Retrieve reads the instance profile's current credentials.
+func (p *profileProvider) Retrieve() (credentials.Value, error) {
+	role, err := p.metadata.InstanceProfile()
+	if err != nil {
+		return credentials.Value{}, err
+	}
+	doc, err := p.metadata.Get("meta-data/iam/security-credentials/" + role)
+	if err != nil {
+		return credentials.Value{}, err
+	}
+	pc := &profileCredentials{}
+	if err := json.Unmarshal([]byte(doc), pc); err != nil {
+		return credentials.Value{}, err
+	}
+	if pc.Code != "" && pc.Code != "Success" {
+		return credentials.Value{}, goof.WithFields(goof.Fields{
+			"role": role,
+			"code": pc.Code,
+		}, "error reading instance profile credentials")
+	}
+	p.SetExpiration(pc.Expiration, expiryWindow)
+	return credentials.Value{
+		AccessKeyID:     pc.AccessKeyID,
+		SecretAccessKey: pc.SecretAccessKey,
+		SessionToken:    pc.Token,
+		ProviderName:    profileProviderName,
+	}, nil
+}
+
+// profileServices returns the configurations of the EBS services that use
+// the instance profile, which are those that do not define a role ARN, or
+// none if any other source of credentials is configured, since the drivers
+// would use that source before the instance profile.
+func profileServices(config gofig.Config) []*roleConfig {
+	if staticCredentials(config) != nil {
+		return nil
+	}
+	rcs := []*roleConfig{}
+	for _, name := range services(config) {
+		key := func(k string) string { return serviceKey(name, k) }
+		if config.GetString(key("ebs.roleARN")) != "" {
+			continue
+		}
+		rcs = append(rcs, &roleConfig{
+			service: name,
+			region:  config.GetString(key("ebs.region")),
+		})
+	}
+	return rcs
+}
+
+// UseInstanceProfile reads the credentials of the instance profile, and the
+// instance's region, with an IMDSv2 session token and sets them in the
+// configuration of each EBS service that uses the instance profile, so that
+// the services' drivers do not read the instance metadata themselves. The
+// libStorage EBS driver's metadata client does not use session tokens, so
+// without them the driver fails on instances that require IMDSv2. It must be
+// called before the libStorage server is started, and the credentials are
+// refreshed, and the server restarted, like those of assumed roles.
+func UseInstanceProfile(
+	ctx apitypes.Context, config gofig.Config, restart Restart) error {
+
+	rcs := profileServices(config)
+	if len(rcs) == 0 {
+		return nil
+	}
+
+	m := NewMetadataFromConfig(config)
+	if !m.IMDSv2() {
+		ctx.Debug("IMDSv2 unavailable; EBS drivers read instance metadata")
+		return nil
+	}
+	id, err := m.Identity()
+	if err != nil {
+		return goof.WithFieldE("endpoint", m.endpoint,
+			"error reading instance identity", err)
+	}
+
+	creds := newProfileCredentials(config)
+	v, err := creds.Get()
+	if err != nil {
+		return goof.WithFieldE("endpoint", m.endpoint,
+			"error reading instance profile credentials", err)
+	}
+
+	for _, rc := range rcs {
+		if rc.region == "" {
+			rc.region = id.Region
+		}
+		setCredentials(config, rc, v)
+		ctx.WithFields(map[string]interface{}{
+			"service": rc.service,
+			"region":  rc.region,
+		}).Info("using instance profile credentials read with IMDSv2")
+	}
+
+	return schedule.Default(ctx).Add(&schedule.Job{
+		Name:     "ebs.refreshCredentials.instanceProfile",
+		Schedule: schedule.Every(refreshInterval),
+		Run:      refreshCredentials(ctx, rcs, creds, v.AccessKeyID, restart),
+	})
+}
@@ -0,0 +1,294 @@
+// Package ebsdev chooses the names with which EBS volumes are attached and
+// finds the local devices of attached volumes. The names depend on the kind
+// of instance: Xen instances expose a volume as the device it was attached
+// as, ex. /dev/xvdf, while Nitro instances expose every volume as an NVMe
+// namespace, ex. /dev/nvme1n1, whose controller reports the volume's ID and
+// the name it was attached as. The package replaces the device logic of
+// libStorage's EBS executor with a strategy for each kind of instance. The
+// executor must be registered before the package is initialized; the pinned
+// version of libStorage does not include it, in which case the package has
+// no effect.
+package ebsdev
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	"github.com/emccode/libstorage/api/registry"
+	apitypes "github.com/emccode/libstorage/api/types"
+)
+
+const (
+	// Auto selects the Nitro strategy if the instance has EBS NVMe
+	// controllers and the Xen strategy otherwise.
+	Auto = "auto"
+
+	// Xen names devices /dev/xvd[f-p] and finds them by those names.
+	Xen = "xen"
+
+	// Nitro maps the NVMe namespaces of EBS volumes to the volumes' IDs and
+	// attachment names with the identities of their controllers.
+	Nitro = "nitro"
+)
+
+// executorNames are the names with which libStorage registers the EBS
+// executor.
+var executorNames = []string{"ebs", "ec2"}
+
+// deviceLetters are the letters of the names with which volumes are
+// attached, as recommended by AWS for EBS volumes.
+const deviceLetters = "fghijklmnop"
+
+// Strategy names and finds the devices of EBS volumes on a kind of instance.
+type Strategy interface {
+
+	// NextDevice returns the name with which the next volume is attached,
+	// ex. /dev/xvdf.
+	NextDevice() (string, error)
+
+	// LocalDevices returns the local devices of the attached volumes keyed
+	// by the names with which they were attached and, if they are known, by
+	// the volumes' IDs.
+	LocalDevices() (map[string]string, error)
+}
+
+// NewStrategy returns a new strategy.
+type NewStrategy func(config gofig.Config) (Strategy, error)
+
+var (
+	strategies    = map[string]NewStrategy{}
+	strategiesRwl sync.RWMutex
+)
+
+func init() {
+	RegisterStrategy(Xen, func(gofig.Config) (Strategy, error) {
+		return &xenStrategy{}, nil
+	})
+	RegisterStrategy(Nitro, func(gofig.Config) (Strategy, error) {
+		return &nitroStrategy{}, nil
+	})
+
+	for _, name := range executorNames {
+		base, err := registry.NewStorageExecutor(name)
+		if err != nil {
+			continue
+		}
+		registry.RegisterStorageExecutor(name,
+			func() apitypes.StorageExecutor {
+				return &executor{StorageExecutor: base}
+			})
+	}
+
+	r := gofig.NewRegistration("EBS Devices")
+	r.Key(gofig.String, "", Auto,
+		"How the devices of EBS volumes are named (auto, xen, nitro)",
+		"ebs.deviceNaming")
+	gofig.Register(r)
+}
+
+// RegisterStrategy registers a device naming strategy.
+func RegisterStrategy(name string, ctor NewStrategy) {
+	strategiesRwl.Lock()
+	defer strategiesRwl.Unlock()
+	strategies[strings.ToLower(name)] = ctor
+}
+
+// New returns the configured strategy.
+func New(config gofig.Config) (Strategy, error) {
+	name := strings.ToLower(config.GetString("ebs.deviceNaming"))
+	if name == "" || name == Auto {
+		name = Xen
+		if hasEBSControllers() {
+			name = Nitro
+		}
+	}
+	strategiesRwl.RLock()
+	ctor, ok := strategies[name]
+	strategiesRwl.RUnlock()
+	if !ok {
+		return nil, goof.WithField(
+			"deviceNaming", name, "invalid ebs device naming strategy")
+	}
+	return ctor(config)
+}
+
+// executor is libStorage's EBS executor with the device logic of the
+// configured strategy.
+type executor struct {
+	apitypes.StorageExecutor
+	strategy Strategy
+}
+
+func (e *executor) Init(ctx apitypes.Context, config gofig.Config) error {
+	if err := e.StorageExecutor.Init(ctx, config); err != nil {
+		return err
+	}
+	s, err := New(config)
+	if err != nil {
+		return err
+	}
+	e.strategy = s
+	return nil
+}
+
+func (e *executor) NextDevice(
+	ctx apitypes.Context,
+	opts apitypes.Store) (string, error) {
+
+	return e.strategy.NextDevice()
+}
+
+func (e *executor) LocalDevices(
+	ctx apitypes.Context,
+	opts *apitypes.LocalDevicesOpts) (*apitypes.LocalDevices, error) {
+
+	devs, err := e.strategy.LocalDevices()
+	if err != nil {
+		return nil, err
+	}
+	return &apitypes.LocalDevices{
+		Driver:    e.StorageExecutor.Name(),
+		DeviceMap: devs,
+	}, nil
+}
+
+// xenStrategy names devices /dev/xvd[f-p], which is how the Xen block
+// frontend exposes them.
+type xenStrategy struct{}
+
+func (s *xenStrategy) NextDevice() (string, error) {
+	names, err := blockDevices()
+	if err != nil {
+		return "", err
+	}
+	return nextDevice(names)
+}
+
+func (s *xenStrategy) LocalDevices() (map[string]string, error) {
+	names, err := blockDevices()
+	if err != nil {
+		return nil, err
+	}
+	devs := map[string]string{}
+	for _, n := range names {
+		if strings.HasPrefix(n, "xvd") {
+			devs["/dev/"+n] = "/dev/" + n
+		}
+	}
+	return devs, nil
+}
+
+// nitroStrategy maps NVMe namespaces to EBS volumes.
+type nitroStrategy struct{}
+
+func (s *nitroStrategy) NextDevice() (string, error) {
+	ctrls, err := ebsControllers()
+	if err != nil {
+		return "", err
+	}
+	names := []string{}
+	for _, c := range ctrls {
+		if c.Name != "" {
+			names = append(names, strings.TrimPrefix(c.Name, "/dev/"))
+		}
+	}
+	return nextDevice(names)
+}
+
+func (s *nitroStrategy) LocalDevices() (map[string]string, error) {
+	ctrls, err := ebsControllers()
+	if err != nil {
+		return nil, err
+	}
+	devs := map[string]string{}
+	for _, c := range ctrls {
+		if c.VolumeID != "" {
+			devs[c.VolumeID] = c.Device
+		}
+		for _, n := range aliases(c.Name) {
+			devs[n] = c.Device
+		}
+	}
+	return devs, nil
+}
+
+// controller is the identity of the NVMe controller of an EBS volume.
+type controller struct {
+
+	// Device is the path of the namespace's device, ex. /dev/nvme1n1.
+	Device string
+
+	// VolumeID is the ID of the volume, ex. vol-0123456789abcdef0.
+	VolumeID string
+
+	// Name is the name with which the volume was attached, ex. /dev/sdf.
+	Name string
+}
+
+// parseIDCtrl returns the volume ID and attachment name in the binary
+// identify controller data structure of an EBS volume, as printed by
+// nvme id-ctrl --raw-binary. The serial number is the volume's ID without
+// its hyphen, and the first 32 bytes of the vendor specific area are the
+// name with which the volume was attached.
+func parseIDCtrl(buf []byte) (string, string, error) {
+	if len(buf) < 3104 {
+		return "", "", goof.WithField(
+			"length", len(buf), "short identify controller data")
+	}
+	name := strings.TrimSpace(strings.Trim(string(buf[3072:3104]), "\x00"))
+	if name != "" && !strings.HasPrefix(name, "/dev/") {
+		name = "/dev/" + name
+	}
+	return serialVolumeID(string(buf[4:24])), name, nil
+}
+
+// serialVolumeID returns the volume ID of an EBS controller's serial number,
+// ex. vol-0123456789abcdef0 for vol0123456789abcdef0.
+func serialVolumeID(sn string) string {
+	sn = strings.TrimSpace(sn)
+	if strings.HasPrefix(sn, "vol") && !strings.HasPrefix(sn, "vol-") {
+		sn = "vol-" + sn[3:]
+	}
+	return sn
+}
+
+// aliases returns the names by which a device attached with the provided
+// name may be known, since a volume attached as /dev/sdf is exposed as
+// /dev/xvdf on Xen instances.
+func aliases(name string) []string {
+	n := strings.TrimPrefix(name, "/dev/")
+	switch {
+	case n == "":
+		return nil
+	case strings.HasPrefix(n, "xvd"):
+		return []string{"/dev/" + n, "/dev/sd" + n[3:]}
+	case strings.HasPrefix(n, "sd"):
+		return []string{"/dev/" + n, "/dev/xvd" + n[2:]}
+	}
+	return []string{"/dev/" + n}
+}
+
+// nextDevice returns the first name /dev/xvd[f-p] whose letter is not used
+// by the provided device names, such as xvdf or sdg1.
+func nextDevice(inUse []string) (string, error) {
+	used := map[byte]bool{}
+	for _, n := range inUse {
+		n = strings.TrimPrefix(n, "/dev/")
+		switch {
+		case strings.HasPrefix(n, "xvd") && len(n) > 3:
+			used[n[3]] = true
+		case strings.HasPrefix(n, "sd") && len(n) > 2:
+			used[n[2]] = true
+		}
+	}
+	for i := 0; i < len(deviceLetters); i++ {
+		if !used[deviceLetters[i]] {
+			return "/dev/xvd" + string(deviceLetters[i]), nil
+		}
+	}
+	sort.Strings(inUse)
+	return "", goof.WithField("devices", inUse, "no available device names")
+}
@@ -0,0 +1,83 @@
+package ebsdev
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/akutz/goof"
+)
+
+const (
+	nvmeClassDir = "/sys/class/nvme"
+	partitions   = "/proc/partitions"
+
+	ebsModel = "Amazon Elastic Block Store"
+)
+
+// blockDevices returns the names of the block devices in /proc/partitions.
+func blockDevices() ([]string, error) {
+	f, err := os.Open(partitions)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	names := []string{}
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) == 4 && fields[0] != "major" {
+			names = append(names, fields[3])
+		}
+	}
+	return names, s.Err()
+}
+
+// ebsControllerNames returns the names of the NVMe controllers of EBS
+// volumes, ex. nvme1.
+func ebsControllerNames() []string {
+	dirs, _ := filepath.Glob(filepath.Join(nvmeClassDir, "nvme*"))
+	names := []string{}
+	for _, d := range dirs {
+		model, err := ioutil.ReadFile(filepath.Join(d, "model"))
+		if err != nil || strings.TrimSpace(string(model)) != ebsModel {
+			continue
+		}
+		names = append(names, filepath.Base(d))
+	}
+	return names
+}
+
+func hasEBSControllers() bool {
+	return len(ebsControllerNames()) > 0
+}
+
+// ebsControllers returns the identities of the controllers of EBS volumes.
+// If nvme-cli is not installed the volume IDs are read from sysfs, but the
+// names the volumes were attached with are not known.
+func ebsControllers() ([]*controller, error) {
+	ctrls := []*controller{}
+	for _, name := range ebsControllerNames() {
+		c := &controller{Device: "/dev/" + name + "n1"}
+		out, err := exec.Command(
+			"nvme", "id-ctrl", "--raw-binary", "/dev/"+name).Output()
+		if err == nil {
+			if c.VolumeID, c.Name, err = parseIDCtrl(out); err != nil {
+				return nil, goof.WithFieldE(
+					"device", "/dev/"+name, "error identifying volume", err)
+			}
+		} else {
+			sn, err := ioutil.ReadFile(
+				filepath.Join(nvmeClassDir, name, "serial"))
+			if err != nil {
+				return nil, err
+			}
+			c.VolumeID = serialVolumeID(string(sn))
+		}
+		ctrls = append(ctrls, c)
+	}
+	return ctrls, nil
+}
@@ -0,0 +1,18 @@
+// +build !linux
+
+package ebsdev
+
+// blockDevices returns no devices on operating systems other than Linux.
+func blockDevices() ([]string, error) {
+	return nil, nil
+}
+
+func hasEBSControllers() bool {
+	return false
+}
+
+// ebsControllers returns no controllers on operating systems other than
+// Linux.
+func ebsControllers() ([]*controller, error) {
+	return nil, nil
+}
@@ -0,0 +1,69 @@
+package ebsdev
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseIDCtrl(t *testing.T) {
+	buf := make([]byte, 4096)
+	copy(buf[4:], "vol0123456789abcdef0")
+	copy(buf[24:], "Amazon Elastic Block Store")
+	copy(buf[3072:], "sdf                             ")
+
+	id, name, err := parseIDCtrl(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "vol-0123456789abcdef0" {
+		t.Errorf("unexpected volume ID %q", id)
+	}
+	if name != "/dev/sdf" {
+		t.Errorf("unexpected name %q", name)
+	}
+
+	if _, _, err := parseIDCtrl(buf[:100]); err == nil {
+		t.Error("expected error for short data")
+	}
+}
+
+func TestNextDevice(t *testing.T) {
+	for _, test := range []struct {
+		inUse []string
+		next  string
+	}{
+		{nil, "/dev/xvdf"},
+		{[]string{"xvda", "xvda1", "xvdf"}, "/dev/xvdg"},
+		{[]string{"/dev/sdf", "sdg1", "nvme0n1"}, "/dev/xvdh"},
+	} {
+		next, err := nextDevice(test.inUse)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if next != test.next {
+			t.Errorf("%v: expected %s, got %s", test.inUse, test.next, next)
+		}
+	}
+
+	all := []string{}
+	for i := 0; i < len(deviceLetters); i++ {
+		all = append(all, "xvd"+string(deviceLetters[i]))
+	}
+	if _, err := nextDevice(all); err == nil {
+		t.Error("expected error when all names are in use")
+	}
+}
+
+func TestAliases(t *testing.T) {
+	if a := aliases("/dev/sdf"); !reflect.DeepEqual(
+		a, []string{"/dev/sdf", "/dev/xvdf"}) {
+		t.Errorf("unexpected aliases %v", a)
+	}
+	if a := aliases("xvdg"); !reflect.DeepEqual(
+		a, []string{"/dev/xvdg", "/dev/sdg"}) {
+		t.Errorf("unexpected aliases %v", a)
+	}
+	if a := aliases(""); a != nil {
+		t.Errorf("unexpected aliases %v", a)
+	}
+}
@@ -0,0 +1,197 @@
+package equinix
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+
+	"github.com/emccode/rexray/core/errcodes"
+)
+
+// href is a reference to another object, as returned by the API.
+type href struct {
+	Href string `json:"href"`
+}
+
+// volume is an Elastic Block Storage volume, as returned by the API. The
+// volume's name is generated, ex. volume-8f7e3c1a, and the name with which
+// REX-Ray created it is its description.
+type volume struct {
+	ID          string        `json:"id"`
+	Name        string        `json:"name"`
+	Description string        `json:"description"`
+	Size        int64         `json:"size"`
+	State       string        `json:"state"`
+	Locked      bool          `json:"locked"`
+	Plan        *plan         `json:"plan"`
+	Facility    *facility     `json:"facility"`
+	Attachments []*attachment `json:"attachments"`
+}
+
+type plan struct {
+	Slug string `json:"slug"`
+}
+
+type facility struct {
+	Code string `json:"code"`
+}
+
+// attachment is the attachment of a volume to a server.
+type attachment struct {
+	ID     string `json:"id"`
+	Href   string `json:"href"`
+	Device href   `json:"device"`
+}
+
+// deviceID returns the ID of the server of the attachment.
+func (a *attachment) deviceID() string {
+	return hrefID(a.Device.Href)
+}
+
+// device is a bare-metal server, as returned by the API.
+type device struct {
+	ID       string    `json:"id"`
+	Hostname string    `json:"hostname"`
+	Facility *facility `json:"facility"`
+}
+
+// apiError is an error returned by the API.
+type apiError struct {
+	status int
+	errors []string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf(
+		"equinix: %d %s", e.status, strings.Join(e.errors, "; "))
+}
+
+// Code returns the code of the error's HTTP status.
+func (e *apiError) Code() errcodes.Code {
+	return errcodes.FromStatus(e.status)
+}
+
+// MarshalJSON marshals the error as its message and code, since the
+// libStorage server reports a driver's error as the error's JSON.
+func (e *apiError) MarshalJSON() ([]byte, error) {
+	return errcodes.Marshal(e)
+}
+
+// isNotFound returns a flag indicating whether the error is that of a
+// request for an object that does not exist.
+func isNotFound(err error) bool {
+	e, ok := err.(*apiError)
+	return ok && e.status == http.StatusNotFound
+}
+
+// client calls the Equinix Metal API with an API token.
+type client struct {
+	endpoint string
+	token    string
+	project  string
+	client   *http.Client
+}
+
+func newClient(config gofig.Config) (*client, error) {
+	token := config.GetString("equinix.token")
+	if token == "" {
+		return nil, goof.New("equinix driver requires equinix.token")
+	}
+	project := config.GetString("equinix.projectID")
+	if project == "" {
+		return nil, goof.New("equinix driver requires equinix.projectID")
+	}
+	endpoint := strings.TrimSuffix(config.GetString("equinix.endpoint"), "/")
+	if endpoint == "" {
+		endpoint = defaultEndpoint
+	}
+	return &client{
+		endpoint: endpoint,
+		token:    token,
+		project:  project,
+		client:   &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+func (c *client) do(method, path string, body, result interface{}) error {
+	var buf []byte
+	if body != nil {
+		var err error
+		if buf, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequest(method, c.endpoint+path, bytes.NewReader(buf))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("X-Auth-Token", c.token)
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return goof.WithFieldE("path", path, "error calling equinix", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		e := &apiError{status: res.StatusCode}
+		errs := &struct {
+			Errors []string `json:"errors"`
+		}{}
+		json.NewDecoder(res.Body).Decode(errs)
+		e.errors = errs.Errors
+		return e
+	}
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(result)
+}
+
+// volumes returns all of the project's volumes.
+func (c *client) volumes() ([]*volume, error) {
+	all := []*volume{}
+	for page := 1; ; page++ {
+		res := &struct {
+			Volumes []*volume `json:"volumes"`
+			Meta    struct {
+				LastPage int `json:"last_page"`
+			} `json:"meta"`
+		}{}
+		if err := c.do("GET", fmt.Sprintf(
+			"/projects/%s/storage?include=attachments&per_page=100&page=%d",
+			c.project, page), nil, res); err != nil {
+			return nil, err
+		}
+		all = append(all, res.Volumes...)
+		if page >= res.Meta.LastPage {
+			return all, nil
+		}
+	}
+}
+
+func (c *client) volume(id string) (*volume, error) {
+	v := &volume{}
+	if err := c.do(
+		"GET", "/storage/"+id+"?include=attachments", nil, v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (c *client) device(id string) (*device, error) {
+	d := &device{}
+	if err := c.do(
+		"GET", "/devices/"+id+"?include=facility", nil, d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
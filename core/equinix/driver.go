@@ -0,0 +1,439 @@
+package equinix
+
+import (
+	"time"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	"github.com/emccode/libstorage/api/context"
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/core/topology"
+)
+
+type driver struct {
+	config   gofig.Config
+	api      *client
+	facility string
+	timeout  time.Duration
+}
+
+func newDriver() apitypes.StorageDriver {
+	return &driver{}
+}
+
+func (d *driver) Name() string {
+	return Name
+}
+
+func (d *driver) Init(ctx apitypes.Context, config gofig.Config) error {
+	d.config = config
+	api, err := newClient(config)
+	if err != nil {
+		return err
+	}
+	d.api = api
+	d.facility = config.GetString("equinix.facility")
+	d.timeout = 2 * time.Minute
+	if t, err := time.ParseDuration(
+		config.GetString("equinix.timeout")); err == nil && t > 0 {
+		d.timeout = t
+	}
+
+	ctx.WithFields(map[string]interface{}{
+		"endpoint":  d.api.endpoint,
+		"projectID": d.api.project,
+		"facility":  d.facility,
+	}).Info("initialized equinix driver")
+	return nil
+}
+
+// instanceID returns the ID of the server on whose behalf an operation is
+// performed, or an empty string if there is none.
+func instanceID(ctx apitypes.Context) string {
+	iid, ok := ctx.Value(context.InstanceIDKey).(*apitypes.InstanceID)
+	if !ok {
+		return ""
+	}
+	return iid.ID
+}
+
+func (d *driver) Type(ctx apitypes.Context) (apitypes.StorageType, error) {
+	return apitypes.Block, nil
+}
+
+// NextDeviceInfo returns nil because the devices of attached volumes are
+// looked up by the volumes' names.
+func (d *driver) NextDeviceInfo(
+	ctx apitypes.Context) (*apitypes.NextDeviceInfo, error) {
+
+	return nil, nil
+}
+
+func (d *driver) InstanceInspect(
+	ctx apitypes.Context,
+	opts apitypes.Store) (*apitypes.Instance, error) {
+
+	iid, ok := ctx.Value(context.InstanceIDKey).(*apitypes.InstanceID)
+	if !ok {
+		return nil, goof.New("missing instance ID")
+	}
+	dev, err := d.api.device(iid.ID)
+	if err != nil {
+		return nil, goof.WithFieldE("instanceID", iid.ID,
+			"error inspecting server", err)
+	}
+	i := &apitypes.Instance{InstanceID: iid, Name: dev.Hostname}
+	if dev.Facility != nil {
+		i.Region = dev.Facility.Code
+	}
+	return i, nil
+}
+
+// toVolume returns a libStorage volume for an Elastic Block Storage volume.
+// The volume's name is its description and its availability zone is its
+// facility. The device of an attachment to the server in the operation's
+// context is looked up by the volume's generated name.
+func (d *driver) toVolume(
+	ctx apitypes.Context, v *volume, attachments bool) *apitypes.Volume {
+
+	vol := &apitypes.Volume{
+		ID:     v.ID,
+		Name:   v.Description,
+		Size:   v.Size,
+		Status: v.State,
+		Fields: map[string]string{"volumeName": v.Name},
+	}
+	if v.Plan != nil {
+		vol.Type = v.Plan.Slug
+	}
+	if v.Facility != nil {
+		vol.AvailabilityZone = v.Facility.Code
+	}
+	if v.Locked {
+		vol.Fields["locked"] = "true"
+	}
+	if !attachments {
+		return vol
+	}
+
+	ld, _ := ctx.Value(context.LocalDevicesKey).(*apitypes.LocalDevices)
+	iid := instanceID(ctx)
+	for _, a := range v.Attachments {
+		att := &apitypes.VolumeAttachment{
+			VolumeID: vol.ID,
+			InstanceID: &apitypes.InstanceID{
+				ID:     a.deviceID(),
+				Driver: Name,
+			},
+			Status: "attached",
+			Fields: map[string]string{"attachmentID": a.ID},
+		}
+		if a.deviceID() == iid && ld != nil {
+			att.DeviceName = ld.DeviceMap[v.Name]
+		}
+		vol.Attachments = append(vol.Attachments, att)
+	}
+	return vol
+}
+
+func (d *driver) Volumes(
+	ctx apitypes.Context,
+	opts *apitypes.VolumesOpts) ([]*apitypes.Volume, error) {
+
+	vols, err := d.api.volumes()
+	if err != nil {
+		return nil, err
+	}
+	all := []*apitypes.Volume{}
+	for _, v := range vols {
+		all = append(all, d.toVolume(ctx, v, opts.Attachments))
+	}
+	return all, nil
+}
+
+func (d *driver) VolumeInspect(
+	ctx apitypes.Context,
+	volumeID string,
+	opts *apitypes.VolumeInspectOpts) (*apitypes.Volume, error) {
+
+	v, err := d.volume(volumeID)
+	if err != nil {
+		return nil, err
+	}
+	return d.toVolume(ctx, v, opts.Attachments), nil
+}
+
+func (d *driver) volume(id string) (*volume, error) {
+	v, err := d.api.volume(id)
+	if isNotFound(err) {
+		return nil, goof.WithField("volumeID", id, "volume not found")
+	}
+	return v, err
+}
+
+// createFacility returns the facility in which a volume is created: the
+// requested availability zone, or else the zone of the volume's selected
+// topology segment, or else the facility of the server on whose behalf the
+// volume is created, or else the configured facility.
+func (d *driver) createFacility(
+	ctx apitypes.Context, opts *apitypes.VolumeCreateOpts) (string, error) {
+
+	if opts.AvailabilityZone != nil && *opts.AvailabilityZone != "" {
+		return *opts.AvailabilityZone, nil
+	}
+	r, err := topology.FromOpts(opts.Opts)
+	if err != nil {
+		return "", err
+	}
+	if zone := r.Select()[topology.Zone]; zone != "" {
+		return zone, nil
+	}
+	if iid := instanceID(ctx); iid != "" {
+		dev, err := d.api.device(iid)
+		if err != nil {
+			return "", goof.WithFieldE("instanceID", iid,
+				"error inspecting server", err)
+		}
+		if dev.Facility != nil {
+			return dev.Facility.Code, nil
+		}
+	}
+	if d.facility != "" {
+		return d.facility, nil
+	}
+	return "", goof.New("equinix volumes require a facility: the " +
+		"availability zone, the server's facility, or equinix.facility")
+}
+
+// VolumeCreate creates a volume in the facility of the server on whose
+// behalf it is created, unless another facility is requested, with the
+// requested or configured plan, and waits for it to be provisioned.
+func (d *driver) VolumeCreate(
+	ctx apitypes.Context,
+	name string,
+	opts *apitypes.VolumeCreateOpts) (*apitypes.Volume, error) {
+
+	fac, err := d.createFacility(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	size := int64(defaultSize)
+	if opts.Size != nil && *opts.Size > 0 {
+		size = *opts.Size
+	}
+	plan := d.config.GetString("equinix.plan")
+	if opts.Type != nil && *opts.Type != "" {
+		plan = *opts.Type
+	}
+	if opts.Opts != nil {
+		if p := opts.Opts.GetString(OptPlan); p != "" {
+			plan = p
+		}
+	}
+	if plan == "" {
+		plan = defaultPlan
+	}
+
+	v := &volume{}
+	if err := d.api.do("POST", "/projects/"+d.api.project+"/storage",
+		map[string]interface{}{
+			"description":   name,
+			"size":          size,
+			"plan":          plan,
+			"facility":      fac,
+			"billing_cycle": "hourly",
+			"locked":        false,
+		}, v); err != nil {
+		return nil, goof.WithFieldsE(goof.Fields{
+			"name":     name,
+			"facility": fac,
+			"plan":     plan,
+		}, "error creating volume", err)
+	}
+	if v, err = d.wait(v.ID, func(v *volume) bool {
+		return v.State == "active"
+	}); err != nil {
+		return nil, err
+	}
+	return d.toVolume(ctx, v, false), nil
+}
+
+func (d *driver) VolumeCreateFromSnapshot(
+	ctx apitypes.Context,
+	snapshotID, volumeName string,
+	opts *apitypes.VolumeCreateOpts) (*apitypes.Volume, error) {
+
+	return nil, apitypes.ErrNotImplemented
+}
+
+func (d *driver) VolumeCopy(
+	ctx apitypes.Context,
+	volumeID, volumeName string,
+	opts apitypes.Store) (*apitypes.Volume, error) {
+
+	return nil, apitypes.ErrNotImplemented
+}
+
+func (d *driver) VolumeSnapshot(
+	ctx apitypes.Context,
+	volumeID, snapshotName string,
+	opts apitypes.Store) (*apitypes.Snapshot, error) {
+
+	return nil, apitypes.ErrNotImplemented
+}
+
+// VolumeRemove removes a volume. A locked volume may not be removed.
+func (d *driver) VolumeRemove(
+	ctx apitypes.Context,
+	volumeID string,
+	opts apitypes.Store) error {
+
+	if err := d.api.do("DELETE", "/storage/"+volumeID, nil, nil); err != nil {
+		return goof.WithFieldE("volumeID", volumeID,
+			"error removing volume", err)
+	}
+	return nil
+}
+
+// VolumeAttach attaches a volume to the instance's server, which must be in
+// the volume's facility. The returned token is the volume's generated name,
+// with which the node's device for the volume is looked up once the node
+// has logged in to the volume's target. A forced attach first detaches the
+// volume from another server.
+func (d *driver) VolumeAttach(
+	ctx apitypes.Context,
+	volumeID string,
+	opts *apitypes.VolumeAttachOpts) (*apitypes.Volume, string, error) {
+
+	iid := instanceID(ctx)
+	if iid == "" {
+		return nil, "", goof.New("missing instance ID")
+	}
+	v, err := d.volume(volumeID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	for _, a := range v.Attachments {
+		switch {
+		case a.deviceID() == iid:
+			return d.toVolume(ctx, v, true), v.Name, nil
+		case !opts.Force:
+			return nil, "", goof.WithFields(goof.Fields{
+				"volumeID":   volumeID,
+				"instanceID": a.deviceID(),
+			}, "volume is attached to another server")
+		default:
+			if err := d.detach(a.ID); err != nil {
+				return nil, "", err
+			}
+		}
+	}
+
+	if err := d.api.do("POST", "/storage/"+v.ID+"/attachments",
+		map[string]interface{}{"device_id": iid}, nil); err != nil {
+		return nil, "", goof.WithFieldsE(goof.Fields{
+			"volumeID":   volumeID,
+			"instanceID": iid,
+		}, "error attaching volume", err)
+	}
+	if v, err = d.api.volume(v.ID); err != nil {
+		return nil, "", err
+	}
+	return d.toVolume(ctx, v, true), v.Name, nil
+}
+
+// VolumeDetach detaches a volume from the instance's server, or from any
+// server if the detach is forced. The node logs out of the volume's target
+// the next time it scans for devices.
+func (d *driver) VolumeDetach(
+	ctx apitypes.Context,
+	volumeID string,
+	opts *apitypes.VolumeDetachOpts) (*apitypes.Volume, error) {
+
+	v, err := d.volume(volumeID)
+	if err != nil {
+		return nil, err
+	}
+	iid := instanceID(ctx)
+	detached := false
+	for _, a := range v.Attachments {
+		if a.deviceID() != iid && !opts.Force {
+			continue
+		}
+		if err := d.detach(a.ID); err != nil {
+			return nil, err
+		}
+		detached = true
+	}
+	if detached {
+		if v, err = d.api.volume(v.ID); err != nil {
+			return nil, err
+		}
+	}
+	return d.toVolume(ctx, v, true), nil
+}
+
+func (d *driver) detach(attachmentID string) error {
+	if err := d.api.do("DELETE", "/storage/attachments/"+attachmentID,
+		nil, nil); err != nil && !isNotFound(err) {
+		return goof.WithFieldE("attachmentID", attachmentID,
+			"error detaching volume", err)
+	}
+	return nil
+}
+
+// wait polls a volume until the condition is met since the API provisions
+// volumes asynchronously.
+func (d *driver) wait(id string, cond func(v *volume) bool) (*volume, error) {
+	deadline := time.Now().Add(d.timeout)
+	for {
+		v, err := d.api.volume(id)
+		if err != nil {
+			return nil, err
+		}
+		if cond(v) {
+			return v, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, goof.WithFields(goof.Fields{
+				"volumeID": id,
+				"state":    v.State,
+				"timeout":  d.timeout,
+			}, "timed out waiting for volume")
+		}
+		time.Sleep(2 * time.Second)
+	}
+}
+
+func (d *driver) Snapshots(
+	ctx apitypes.Context,
+	opts apitypes.Store) ([]*apitypes.Snapshot, error) {
+
+	return nil, apitypes.ErrNotImplemented
+}
+
+func (d *driver) SnapshotInspect(
+	ctx apitypes.Context,
+	snapshotID string,
+	opts apitypes.Store) (*apitypes.Snapshot, error) {
+
+	return nil, apitypes.ErrNotImplemented
+}
+
+func (d *driver) SnapshotCopy(
+	ctx apitypes.Context,
+	snapshotID, snapshotName, destinationID string,
+	opts apitypes.Store) (*apitypes.Snapshot, error) {
+
+	return nil, apitypes.ErrNotImplemented
+}
+
+func (d *driver) SnapshotRemove(
+	ctx apitypes.Context,
+	snapshotID string,
+	opts apitypes.Store) error {
+
+	return apitypes.ErrNotImplemented
+}
@@ -0,0 +1,153 @@
+// Package equinix is a storage driver for Equinix Metal, formerly Packet,
+// Elastic Block Storage. Volumes are managed with the Equinix Metal API and
+// are iSCSI targets that are attached to bare-metal servers. Nodes perform
+// the iSCSI attach steps themselves: they configure their initiator name
+// and log in to the targets of their volumes at the portals the metadata
+// service lists, and use the multipath device of a volume when multipathd
+// is active.
+package equinix
+
+import (
+	"strings"
+
+	"github.com/akutz/gofig"
+	"github.com/emccode/libstorage/api/registry"
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/core/openiscsi"
+	"github.com/emccode/rexray/core/topology"
+)
+
+const (
+	// Name is the name with which the driver is registered.
+	Name = "equinix"
+
+	// OptPlan is the create option with which a volume's plan is requested.
+	OptPlan = "plan"
+
+	defaultEndpoint         = "https://api.equinix.com/metal/v1"
+	defaultMetadataEndpoint = "https://metadata.platformequinix.com"
+	defaultPlan             = "storage_1"
+	defaultSize             = 100
+
+	// iqnPrefix is the prefix of the IQNs of the volumes' targets.
+	iqnPrefix = "iqn.2013-05.com.daterainc:"
+)
+
+func init() {
+	registry.RegisterStorageDriver(Name, newDriver)
+	registry.RegisterStorageExecutor(Name, newExecutor)
+	topology.Register(Name, instanceTopology)
+
+	r := gofig.NewRegistration("Equinix Metal Driver")
+	r.Key(gofig.String, "", "",
+		"The API token with which the Equinix Metal API is called",
+		"equinix.token")
+	r.Key(gofig.String, "", "",
+		"The ID of the project of the volumes",
+		"equinix.projectID")
+	r.Key(gofig.String, "", defaultEndpoint,
+		"The URL of the Equinix Metal API",
+		"equinix.endpoint")
+	r.Key(gofig.String, "", "",
+		"The facility of volumes created without a server",
+		"equinix.facility")
+	r.Key(gofig.String, "", defaultPlan,
+		"The plan of new volumes: storage_1 (standard) or storage_2 "+
+			"(performance)",
+		"equinix.plan")
+	r.Key(gofig.String, "", "2m",
+		"How long to wait for a volume to be provisioned",
+		"equinix.timeout")
+	r.Key(gofig.String, "", defaultMetadataEndpoint,
+		"The URL of the Equinix Metal metadata service",
+		"equinix.metadataEndpoint")
+	r.Key(gofig.Bool, "", true,
+		"Use the multipath device of a volume when there is one",
+		"equinix.multipath")
+	gofig.Register(r)
+}
+
+// instanceTopology returns the metro and facility of the node's server.
+func instanceTopology(
+	ctx apitypes.Context, config gofig.Config) (topology.Segment, error) {
+
+	md, err := newMetadata(config).read()
+	if err != nil {
+		return nil, err
+	}
+	s := topology.Segment{topology.Zone: md.Facility}
+	if md.Metro != "" {
+		s[topology.Region] = md.Metro
+	}
+	return s, nil
+}
+
+// hrefID returns the ID of the object an API reference links to, ex.
+// 8f7e... of /metal/v1/devices/8f7e....
+func hrefID(href string) string {
+	return href[strings.LastIndex(href, "/")+1:]
+}
+
+// target is an iSCSI target of a volume at one of its portals.
+type target struct {
+	IQN    string
+	Portal string
+}
+
+// targets returns the targets of the volumes the metadata service lists.
+func targets(vols []*mdVolume) []*target {
+	ts := []*target{}
+	for _, v := range vols {
+		for _, ip := range v.IPs {
+			ts = append(ts, &target{
+				IQN:    v.IQN,
+				Portal: openiscsi.PortalAddr(ip),
+			})
+		}
+	}
+	return ts
+}
+
+// volumeSessions returns the node's sessions with the targets of volumes,
+// whose IQNs begin with iqnPrefix.
+func volumeSessions(all []*openiscsi.Session) []*target {
+	sessions := []*target{}
+	for _, s := range all {
+		if strings.HasPrefix(s.Target, iqnPrefix) {
+			sessions = append(sessions,
+				&target{IQN: s.Target, Portal: s.Portal})
+		}
+	}
+	return sessions
+}
+
+// diffTargets returns the targets and portals the node has no session with
+// and the sessions whose volumes are no longer attached.
+func diffTargets(targets, sessions []*target) ([]*target, []*target) {
+	active := map[target]bool{}
+	for _, s := range sessions {
+		active[*s] = true
+	}
+	attached := map[target]bool{}
+	login := []*target{}
+	for _, t := range targets {
+		attached[*t] = true
+		if !active[*t] {
+			login = append(login, t)
+		}
+	}
+	logout := []*target{}
+	for _, s := range sessions {
+		if !attached[*s] {
+			logout = append(logout, s)
+		}
+	}
+	return login, logout
+}
+
+// byPathName returns the name of the link in /dev/disk/by-path to the LUN of
+// a volume's target at a portal.
+func byPathName(t *target) string {
+	return "ip-" + t.Portal + "-iscsi-" + t.IQN + "-lun-0"
+}
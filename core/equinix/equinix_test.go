@@ -0,0 +1,54 @@
+package equinix
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/emccode/rexray/core/openiscsi"
+)
+
+func TestVolumeSessions(t *testing.T) {
+	sessions := volumeSessions(openiscsi.ParseSessions(strings.NewReader(
+		"tcp: [1] 10.144.32.1:3260,1 " +
+			"iqn.2013-05.com.daterainc:tc:01:sn:b06f (non-flash)\n" +
+			"tcp: [2] 10.0.0.1:3260,1 iqn.2003-01.org.linux-iscsi.san:t\n")))
+	exp := []*target{{
+		IQN:    "iqn.2013-05.com.daterainc:tc:01:sn:b06f",
+		Portal: "10.144.32.1:3260",
+	}}
+	if !reflect.DeepEqual(sessions, exp) {
+		t.Fatalf("sessions=%v", sessions)
+	}
+}
+
+func TestDiffTargets(t *testing.T) {
+	ts := targets([]*mdVolume{{
+		Name: "volume-8f7e3c1a",
+		IQN:  "iqn.2013-05.com.daterainc:tc:01:sn:b06f",
+		IPs:  []string{"10.144.32.1", "10.144.48.1"},
+	}})
+	sessions := []*target{
+		{IQN: "iqn.2013-05.com.daterainc:tc:01:sn:b06f",
+			Portal: "10.144.32.1:3260"},
+		{IQN: "iqn.2013-05.com.daterainc:tc:01:sn:0a1b",
+			Portal: "10.144.32.1:3260"},
+	}
+	login, logout := diffTargets(ts, sessions)
+	if len(login) != 1 || login[0].Portal != "10.144.48.1:3260" {
+		t.Fatalf("login=%v", login)
+	}
+	if len(logout) != 1 || logout[0].IQN != sessions[1].IQN {
+		t.Fatalf("logout=%v", logout)
+	}
+	if n := byPathName(ts[0]); n != "ip-10.144.32.1:3260-iscsi-"+
+		"iqn.2013-05.com.daterainc:tc:01:sn:b06f-lun-0" {
+		t.Fatalf("name=%s", n)
+	}
+}
+
+func TestHrefID(t *testing.T) {
+	if hrefID("/metal/v1/devices/7c8a") != "7c8a" {
+		t.Fatal(hrefID("/metal/v1/devices/7c8a"))
+	}
+}
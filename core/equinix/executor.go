@@ -0,0 +1,81 @@
+package equinix
+
+import (
+	"github.com/akutz/gofig"
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/core/scsi"
+)
+
+// executor runs on each node. A node's instance ID is the ID of its server,
+// which is read from the metadata service.
+type executor struct {
+	config gofig.Config
+}
+
+func newExecutor() apitypes.StorageExecutor {
+	return &executor{}
+}
+
+func (e *executor) Name() string {
+	return Name
+}
+
+func (e *executor) Init(ctx apitypes.Context, config gofig.Config) error {
+	e.config = config
+	return nil
+}
+
+func (e *executor) InstanceID(
+	ctx apitypes.Context,
+	opts apitypes.Store) (*apitypes.InstanceID, error) {
+
+	md, err := newMetadata(e.config).read()
+	if err != nil {
+		return nil, err
+	}
+	return &apitypes.InstanceID{ID: md.ID, Driver: Name}, nil
+}
+
+func (e *executor) NextDevice(
+	ctx apitypes.Context,
+	opts apitypes.Store) (string, error) {
+
+	return "", apitypes.ErrNotImplemented
+}
+
+// LocalDevices returns the devices of the volumes attached to the node keyed
+// by their names. A deep scan first performs the iSCSI attach steps: it sets
+// the node's initiator name to the server's, logs in to the targets of the
+// volumes the metadata service lists, logs out of the targets of volumes
+// that were detached, and waits for multipathd to coalesce the paths of new
+// volumes.
+func (e *executor) LocalDevices(
+	ctx apitypes.Context,
+	opts *apitypes.LocalDevicesOpts) (*apitypes.LocalDevices, error) {
+
+	md, err := newMetadata(e.config).read()
+	if err != nil {
+		return nil, err
+	}
+	multipath := e.config.GetBool("equinix.multipath") &&
+		scsi.Multipath(e.config)
+
+	if opts.ScanType == apitypes.DeviceScanDeep {
+		if err := configureInitiator(ctx, md.IQN); err != nil {
+			return nil, err
+		}
+		if err := syncTargets(ctx, targets(md.Volumes)); err != nil {
+			return nil, err
+		}
+		if multipath {
+			scsi.SettleMultipath(ctx, scsi.MultipathTimeout(e.config))
+		}
+	}
+
+	devs, err := devices(md.Volumes, multipath)
+	if err != nil {
+		return nil, err
+	}
+	return &apitypes.LocalDevices{Driver: Name, DeviceMap: devs}, nil
+}
@@ -0,0 +1,69 @@
+package equinix
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+)
+
+// mdVolume is a volume attached to the server, as listed by the metadata
+// service.
+type mdVolume struct {
+	Name string   `json:"name"`
+	IQN  string   `json:"iqn"`
+	IPs  []string `json:"ips"`
+}
+
+// serverMetadata is the metadata of the server the node runs on.
+type serverMetadata struct {
+	ID       string      `json:"id"`
+	Hostname string      `json:"hostname"`
+	Facility string      `json:"facility"`
+	Metro    string      `json:"metro"`
+	IQN      string      `json:"iqn"`
+	Volumes  []*mdVolume `json:"volumes"`
+}
+
+// metadata is a client for the Equinix Metal metadata service.
+type metadata struct {
+	endpoint string
+	client   *http.Client
+}
+
+func newMetadata(config gofig.Config) *metadata {
+	endpoint := strings.TrimSuffix(
+		config.GetString("equinix.metadataEndpoint"), "/")
+	if endpoint == "" {
+		endpoint = defaultMetadataEndpoint
+	}
+	return &metadata{
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// read returns the metadata of the server the node runs on.
+func (m *metadata) read() (*serverMetadata, error) {
+	res, err := m.client.Get(m.endpoint + "/metadata")
+	if err != nil {
+		return nil, goof.WithFieldE("endpoint", m.endpoint,
+			"error reading equinix metadata", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, goof.WithFields(goof.Fields{
+			"endpoint": m.endpoint,
+			"status":   res.StatusCode,
+		}, "error reading equinix metadata")
+	}
+	md := &serverMetadata{}
+	if err := json.NewDecoder(res.Body).Decode(md); err != nil {
+		return nil, goof.WithFieldE("endpoint", m.endpoint,
+			"error decoding equinix metadata", err)
+	}
+	return md, nil
+}
@@ -0,0 +1,106 @@
+package equinix
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/akutz/goof"
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/core/openiscsi"
+	"github.com/emccode/rexray/core/scsi"
+)
+
+// sessions returns the node's sessions with the targets of volumes.
+func sessions() ([]*target, error) {
+	all, err := openiscsi.Sessions()
+	if err != nil {
+		return nil, err
+	}
+	return volumeSessions(all), nil
+}
+
+// configureInitiator sets the node's initiator name to the IQN the metadata
+// service lists for the server, which is the initiator the volumes' targets
+// allow, and restarts iscsid if it was changed.
+func configureInitiator(ctx apitypes.Context, iqn string) error {
+	if iqn == "" {
+		return goof.New("equinix metadata lists no initiator name")
+	}
+	if f, err := os.Open(openiscsi.InitiatorNameFile); err == nil {
+		name, ok := openiscsi.ParseInitiatorName(f)
+		f.Close()
+		if ok && name == iqn {
+			return nil
+		}
+	}
+	if err := ioutil.WriteFile(openiscsi.InitiatorNameFile,
+		[]byte("InitiatorName="+iqn+"\n"), 0644); err != nil {
+		return goof.WithFieldE("path", openiscsi.InitiatorNameFile,
+			"error writing initiator name", err)
+	}
+	out, err := exec.Command("systemctl", "restart", "iscsid").CombinedOutput()
+	if err != nil {
+		out, err = exec.Command("service", "iscsid", "restart").CombinedOutput()
+	}
+	if err != nil {
+		return goof.WithFieldsE(map[string]interface{}{
+			"output": string(bytes.TrimSpace(out)),
+		}, "error restarting iscsid", err)
+	}
+	ctx.WithField("initiatorName", iqn).Info("configured iscsi initiator")
+	return nil
+}
+
+// syncTargets logs in to the targets and portals the node has no session
+// with, and logs out of and forgets the targets of volumes that were
+// detached.
+func syncTargets(ctx apitypes.Context, targets []*target) error {
+	active, err := sessions()
+	if err != nil {
+		return err
+	}
+	login, logout := diffTargets(targets, active)
+
+	for _, t := range login {
+		if _, err := openiscsi.Run("-m", "discovery",
+			"-t", "sendtargets", "-p", t.Portal); err != nil {
+			return err
+		}
+		if err := openiscsi.LoginTarget(ctx, t.IQN, t.Portal); err != nil {
+			return err
+		}
+	}
+
+	for _, t := range logout {
+		if err := openiscsi.LogoutTarget(
+			ctx, t.IQN, t.Portal, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// devices returns the devices of the volumes keyed by their names. If
+// multipath is enabled a volume's device is the multipath device that holds
+// the paths to its target's portals, if there is one.
+func devices(vols []*mdVolume, multipath bool) (map[string]string, error) {
+	devs := map[string]string{}
+	for _, v := range vols {
+		for _, t := range targets([]*mdVolume{v}) {
+			path, err := filepath.EvalSymlinks(
+				filepath.Join(openiscsi.ByPathDir, byPathName(t)))
+			if err != nil {
+				continue
+			}
+			dev := scsi.DevicePath(path, multipath)
+			if _, ok := devs[v.Name]; !ok || dev != path {
+				devs[v.Name] = dev
+			}
+		}
+	}
+	return devs, nil
+}
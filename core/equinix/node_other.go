@@ -0,0 +1,20 @@
+// +build !linux
+
+package equinix
+
+import (
+	"github.com/akutz/goof"
+	apitypes "github.com/emccode/libstorage/api/types"
+)
+
+func configureInitiator(ctx apitypes.Context, iqn string) error {
+	return goof.New("equinix volumes are only supported on Linux")
+}
+
+func syncTargets(ctx apitypes.Context, targets []*target) error {
+	return goof.New("equinix volumes are only supported on Linux")
+}
+
+func devices(vols []*mdVolume, multipath bool) (map[string]string, error) {
+	return map[string]string{}, nil
+}
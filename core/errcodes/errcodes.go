@@ -0,0 +1,314 @@
+// Package errcodes defines the codes that classify the errors of REX-Ray's
+// operations, so that automation may branch on the type of a failure rather
+// than on its message. The admin API reports the code of an error with its
+// message, and the CLI exits with the code's exit status.
+//
+// Errors of REX-Ray's own types, such as a refused operation on a pinned
+// volume, carry their code. Errors that cross the libStorage REST API carry
+// their code as the "code" field of the response's error, which the
+// libStorage client decodes as one of the fields of the error it returns.
+package errcodes
+
+import (
+	"encoding/json"
+	"net/http"
+
+	apitypes "github.com/emccode/libstorage/api/types"
+)
+
+// Code is the code of a type of error.
+type Code string
+
+// The codes of the types of errors.
+const (
+	// Unknown is the code of an error that has no other code.
+	Unknown Code = "Unknown"
+
+	// InvalidRequest is the code of a request that is invalid, such as one
+	// with missing or malformed arguments.
+	InvalidRequest Code = "InvalidRequest"
+
+	// NotFound is the code of a request for an object other than a volume
+	// or snapshot that does not exist.
+	NotFound Code = "NotFound"
+
+	// VolumeNotFound is the code of a request for a volume that does not
+	// exist.
+	VolumeNotFound Code = "VolumeNotFound"
+
+	// SnapshotNotFound is the code of a request for a snapshot that does not
+	// exist.
+	SnapshotNotFound Code = "SnapshotNotFound"
+
+	// AlreadyAttached is the code of an attachment that is refused because
+	// the volume is attached elsewhere and its access mode does not permit
+	// another attachment.
+	AlreadyAttached Code = "AlreadyAttached"
+
+	// QuotaExceeded is the code of a volume creation that would exceed a
+	// quota.
+	QuotaExceeded Code = "QuotaExceeded"
+
+	// VolumePinned is the code of an operation that is refused because the
+	// volume is pinned.
+	VolumePinned Code = "VolumePinned"
+
+	// VolumeProtected is the code of a removal that is refused because the
+	// volume is protected.
+	VolumeProtected Code = "VolumeProtected"
+
+	// VolumeTrashed is the code of an operation that is refused because the
+	// volume is in the trash.
+	VolumeTrashed Code = "VolumeTrashed"
+
+	// GroupFailed is the code of an operation on a group of volumes that
+	// failed for one or more of the volumes.
+	GroupFailed Code = "GroupFailed"
+
+	// ServiceInMaintenance is the code of an operation that is refused
+	// because the service is in maintenance.
+	ServiceInMaintenance Code = "ServiceInMaintenance"
+
+	// RateLimited is the code of a request that is refused because the
+	// client exceeded the libStorage server's rate limits.
+	RateLimited Code = "RateLimited"
+
+	// BackendThrottled is the code of an operation that the storage platform
+	// refused because its own request limits were exceeded.
+	BackendThrottled Code = "BackendThrottled"
+
+	// BackendUnavailable is the code of an operation that failed because the
+	// storage platform could not be reached or is failing.
+	BackendUnavailable Code = "BackendUnavailable"
+
+	// CapacityExhausted is the code of a volume creation that failed because
+	// the storage platform has no capacity left.
+	CapacityExhausted Code = "CapacityExhausted"
+
+	// Timeout is the code of an operation that did not complete before its
+	// deadline.
+	Timeout Code = "Timeout"
+
+	// Canceled is the code of an operation whose request was canceled.
+	Canceled Code = "Canceled"
+
+	// Unauthorized is the code of a request whose credentials are invalid
+	// or insufficient.
+	Unauthorized Code = "Unauthorized"
+
+	// NotImplemented is the code of an operation the driver does not
+	// implement.
+	NotImplemented Code = "NotImplemented"
+)
+
+// info describes a code.
+type info struct {
+	status int
+	exit   int
+}
+
+var codes = map[Code]info{
+	Unknown:              {http.StatusInternalServerError, 1},
+	InvalidRequest:       {http.StatusBadRequest, 2},
+	NotFound:             {http.StatusNotFound, 3},
+	VolumeNotFound:       {http.StatusNotFound, 4},
+	SnapshotNotFound:     {http.StatusNotFound, 5},
+	AlreadyAttached:      {http.StatusConflict, 10},
+	QuotaExceeded:        {http.StatusForbidden, 11},
+	VolumePinned:         {http.StatusConflict, 12},
+	VolumeProtected:      {http.StatusConflict, 13},
+	VolumeTrashed:        {http.StatusGone, 14},
+	GroupFailed:          {http.StatusConflict, 15},
+	ServiceInMaintenance: {http.StatusServiceUnavailable, 20},
+	RateLimited:          {http.StatusTooManyRequests, 21},
+	BackendThrottled:     {http.StatusTooManyRequests, 22},
+	BackendUnavailable:   {http.StatusServiceUnavailable, 23},
+	CapacityExhausted:    {http.StatusInsufficientStorage, 24},
+	Timeout:              {http.StatusGatewayTimeout, 25},
+	Canceled:             {http.StatusGatewayTimeout, 26},
+	Unauthorized:         {http.StatusUnauthorized, 30},
+	NotImplemented:       {http.StatusNotImplemented, 31},
+}
+
+// Codes returns the codes of the types of errors.
+func Codes() []Code {
+	return []Code{
+		Unknown, InvalidRequest, NotFound, VolumeNotFound, SnapshotNotFound,
+		AlreadyAttached, QuotaExceeded, VolumePinned, VolumeProtected,
+		VolumeTrashed, GroupFailed, ServiceInMaintenance, RateLimited,
+		BackendThrottled, BackendUnavailable, CapacityExhausted, Timeout,
+		Canceled, Unauthorized, NotImplemented,
+	}
+}
+
+// Status returns the HTTP status with which errors of the code are
+// reported.
+func (c Code) Status() int {
+	if i, ok := codes[c]; ok {
+		return i.status
+	}
+	return http.StatusInternalServerError
+}
+
+// ExitCode returns the exit status of the CLI when a command fails with an
+// error of the code.
+func (c Code) ExitCode() int {
+	if i, ok := codes[c]; ok {
+		return i.exit
+	}
+	return 1
+}
+
+// coder is implemented by errors that carry their code.
+type coder interface {
+	Code() Code
+}
+
+// codedError is an error with a code.
+type codedError struct {
+	code Code
+	err  error
+}
+
+// New returns an error with the code and the message of the error.
+func New(code Code, err error) error {
+	return &codedError{code: code, err: err}
+}
+
+func (e *codedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *codedError) Code() Code {
+	return e.code
+}
+
+// Status returns the HTTP status with which the error is reported.
+func (e *codedError) Status() int {
+	return e.code.Status()
+}
+
+// MarshalJSON marshals the error as the error with the code is marshaled,
+// so that the fields of a goof error are preserved, with the code as the
+// "code" field.
+func (e *codedError) MarshalJSON() ([]byte, error) {
+	buf, err := json.Marshal(e.err)
+	if err != nil {
+		return nil, err
+	}
+	m := map[string]interface{}{}
+	if json.Unmarshal(buf, &m) != nil {
+		m = map[string]interface{}{"message": e.err.Error()}
+	}
+	m["code"] = e.code
+	return json.Marshal(m)
+}
+
+// Marshal returns the JSON of an error whose type has no fields to marshal,
+// such as an error of a storage platform's API, which is the error's message
+// and code.
+func Marshal(err error) ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"message": err.Error(),
+		"code":    Of(err),
+	})
+}
+
+// Write writes an error to an HTTP response in the form in which the
+// libStorage server writes its errors, with the error's code.
+func Write(w http.ResponseWriter, err error) {
+	code := Of(err)
+	status := code.Status()
+	if s, ok := err.(interface {
+		Status() int
+	}); ok {
+		status = s.Status()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": err.Error(),
+		"status":  status,
+		"error":   New(code, err),
+	})
+}
+
+// Of returns the code of an error, or an empty code if the error is nil.
+// An error that does not carry its code, such as an error decoded from a
+// response of the libStorage server, has the code of its "code" field, or
+// otherwise the code of its HTTP status.
+func Of(err error) Code {
+	if err == nil {
+		return ""
+	}
+	if c, ok := err.(coder); ok {
+		return c.Code()
+	}
+	if err == apitypes.ErrNotImplemented {
+		return NotImplemented
+	}
+	if f, ok := err.(interface {
+		Fields() map[string]interface{}
+	}); ok {
+		if c := fromFields(f.Fields()); c != "" {
+			return c
+		}
+	}
+	if s, ok := err.(interface {
+		Status() int
+	}); ok {
+		return FromStatus(s.Status())
+	}
+	return Unknown
+}
+
+// fromFields returns the code of the fields of an error, or of the fields of
+// the error it wraps, or an empty code if the fields include no known code.
+func fromFields(fields map[string]interface{}) Code {
+	if s, ok := fields["code"].(string); ok {
+		if _, ok := codes[Code(s)]; ok {
+			return Code(s)
+		}
+	}
+	for _, k := range []string{"inner", "error"} {
+		if m, ok := fields[k].(map[string]interface{}); ok {
+			if c := fromFields(m); c != "" {
+				return c
+			}
+		}
+	}
+	return ""
+}
+
+// FromStatus returns the code of an HTTP status, such as the status of a
+// response from a storage platform's API.
+func FromStatus(status int) Code {
+	switch status {
+	case http.StatusBadRequest:
+		return InvalidRequest
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return Unauthorized
+	case http.StatusNotFound:
+		return NotFound
+	case http.StatusTooManyRequests:
+		return BackendThrottled
+	case http.StatusNotImplemented:
+		return NotImplemented
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		return BackendUnavailable
+	case http.StatusGatewayTimeout:
+		return Timeout
+	case http.StatusInsufficientStorage:
+		return CapacityExhausted
+	}
+	return Unknown
+}
+
+// ExitCode returns the exit status of the CLI when a command fails with the
+// error.
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	return Of(err).ExitCode()
+}
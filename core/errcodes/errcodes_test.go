@@ -0,0 +1,120 @@
+package errcodes
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/akutz/goof"
+	apitypes "github.com/emccode/libstorage/api/types"
+)
+
+// fieldsError is an error decoded from a response of the libStorage
+// server.
+type fieldsError struct {
+	fields map[string]interface{}
+	status int
+}
+
+func (e *fieldsError) Error() string {
+	return "error"
+}
+
+func (e *fieldsError) Fields() map[string]interface{} {
+	return e.fields
+}
+
+func (e *fieldsError) Status() int {
+	return e.status
+}
+
+func TestOf(t *testing.T) {
+	inner := map[string]interface{}{"code": "BackendThrottled"}
+	for err, code := range map[error]Code{
+		nil:                                "",
+		New(VolumePinned, errors.New("x")): VolumePinned,
+		apitypes.ErrNotImplemented:         NotImplemented,
+		&fieldsError{map[string]interface{}{"code": "VolumeNotFound"},
+			http.StatusNotFound}: VolumeNotFound,
+		&fieldsError{map[string]interface{}{"inner": inner},
+			http.StatusInternalServerError}: BackendThrottled,
+		&fieldsError{map[string]interface{}{"code": "Bogus"},
+			http.StatusNotFound}: NotFound,
+		&fieldsError{nil, http.StatusInternalServerError}: Unknown,
+		errors.New("volume not found"):                    Unknown,
+	} {
+		if c := Of(err); c != code {
+			t.Errorf("%v: code=%s, expected %s", err, c, code)
+		}
+	}
+}
+
+func TestCodes(t *testing.T) {
+	exits := map[int]Code{}
+	for _, c := range Codes() {
+		i, ok := codes[c]
+		if !ok {
+			t.Fatalf("%s has no status", c)
+		}
+		if o, ok := exits[i.exit]; ok {
+			t.Fatalf("%s and %s have exit status %d", c, o, i.exit)
+		}
+		exits[i.exit] = c
+	}
+	if len(codes) != len(Codes()) {
+		t.Fatal("Codes does not return every code")
+	}
+	if Unknown.ExitCode() != 1 {
+		t.Fatal("Unknown must exit with 1")
+	}
+	if Code("Bogus").ExitCode() != 1 ||
+		Code("Bogus").Status() != http.StatusInternalServerError {
+		t.Fatal("unknown codes must be reported as Unknown")
+	}
+	if ExitCode(nil) != 0 {
+		t.Fatal("nil must exit with 0")
+	}
+}
+
+func TestNew(t *testing.T) {
+	err := New(QuotaExceeded, goof.New("quota exceeded"))
+	if err.Error() != "quota exceeded" {
+		t.Fatal(err.Error())
+	}
+	if ExitCode(err) != 11 {
+		t.Fatal(ExitCode(err))
+	}
+	if err.(interface {
+		Status() int
+	}).Status() != http.StatusForbidden {
+		t.Fatal("wrong status")
+	}
+	buf, jerr := json.Marshal(err)
+	if jerr != nil {
+		t.Fatal(jerr)
+	}
+	m := map[string]interface{}{}
+	if err := json.Unmarshal(buf, &m); err != nil {
+		t.Fatal(err)
+	}
+	if m["code"] != "QuotaExceeded" {
+		t.Fatalf("code not marshaled: %s", buf)
+	}
+
+	buf, jerr = json.Marshal(New(VolumeNotFound, goof.WithField(
+		"volumeID", "vol-1", "volume not found")))
+	if jerr != nil {
+		t.Fatal(jerr)
+	}
+	m = map[string]interface{}{}
+	if err := json.Unmarshal(buf, &m); err != nil {
+		t.Fatal(err)
+	}
+	if m["volumeID"] != "vol-1" || m["code"] != "VolumeNotFound" {
+		t.Fatalf("fields not marshaled: %s", buf)
+	}
+	if c := fromFields(m); c != VolumeNotFound {
+		t.Fatalf("decoded code=%s", c)
+	}
+}
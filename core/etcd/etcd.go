@@ -0,0 +1,430 @@
+// Package etcd is a client of the etcd v3 API's JSON gateway.
+//
+// The state store and the configuration source use the gateway rather than
+// etcd's gRPC client because that client's balancer only builds with grpc
+// releases older than the ones go-spiffe requires.
+package etcd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/akutz/goof"
+)
+
+// Config is the configuration of a client.
+type Config struct {
+	// Endpoints are the members' client URLs. An endpoint without a scheme
+	// is an http endpoint.
+	Endpoints []string
+
+	// Username and Password authenticate the client if Username is set.
+	Username string
+	Password string
+}
+
+// KeyValue is a key and the revision of its last modification.
+type KeyValue struct {
+	Key         []byte `json:"key"`
+	Value       []byte `json:"value"`
+	ModRevision int64  `json:"mod_revision,string"`
+}
+
+// Client calls the JSON gateway of one of a cluster's members.
+type Client struct {
+	config    Config
+	client    *http.Client
+	tokenLock sync.Mutex
+	token     string
+}
+
+// New returns a new client.
+func New(config Config) (*Client, error) {
+	if len(config.Endpoints) == 0 {
+		return nil, goof.New("etcd client requires at least one endpoint")
+	}
+	return &Client{config: config, client: &http.Client{}}, nil
+}
+
+// gatewayError is an error returned by the gateway.
+type gatewayError struct {
+	status  int
+	message string
+}
+
+func (e *gatewayError) Error() string {
+	return fmt.Sprintf("etcd: %d %s", e.status, e.message)
+}
+
+func (c *Client) url(endpoint, path string) string {
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "http://" + endpoint
+	}
+	return strings.TrimSuffix(endpoint, "/") + "/v3/" + path
+}
+
+// post sends the request to each endpoint in turn until one of them
+// responds, and returns that response.
+func (c *Client) post(
+	ctx context.Context,
+	path string,
+	body interface{}) (*http.Response, error) {
+
+	buf, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	token, err := c.authToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, ep := range c.config.Endpoints {
+		var req *http.Request
+		req, err = http.NewRequest(
+			http.MethodPost, c.url(ep, path), bytes.NewReader(buf))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", token)
+		}
+		var res *http.Response
+		if res, err = c.client.Do(req.WithContext(ctx)); err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			continue
+		}
+		return res, nil
+	}
+	return nil, goof.WithFieldE("path", path, "error calling etcd", err)
+}
+
+// do calls the gateway and decodes the response into result. The client
+// authenticates again once if its token has expired.
+func (c *Client) do(
+	ctx context.Context, path string, body, result interface{}) error {
+
+	for retry := true; ; retry = false {
+		res, err := c.post(ctx, path, body)
+		if err != nil {
+			return err
+		}
+		err = decode(res, result)
+		res.Body.Close()
+		if e, ok := err.(*gatewayError); ok &&
+			e.status == http.StatusUnauthorized && retry &&
+			c.config.Username != "" {
+			c.setToken("")
+			continue
+		}
+		return err
+	}
+}
+
+func decode(res *http.Response, result interface{}) error {
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		e := &gatewayError{status: res.StatusCode}
+		msg := &struct {
+			Error   string `json:"error"`
+			Message string `json:"message"`
+		}{}
+		json.NewDecoder(res.Body).Decode(msg)
+		if e.message = msg.Message; e.message == "" {
+			e.message = msg.Error
+		}
+		return e
+	}
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(result)
+}
+
+func (c *Client) setToken(token string) {
+	c.tokenLock.Lock()
+	defer c.tokenLock.Unlock()
+	c.token = token
+}
+
+// authToken returns the client's token, authenticating it first if it does
+// not have one.
+func (c *Client) authToken(ctx context.Context) (string, error) {
+	if c.config.Username == "" {
+		return "", nil
+	}
+	c.tokenLock.Lock()
+	defer c.tokenLock.Unlock()
+	if c.token != "" {
+		return c.token, nil
+	}
+
+	buf, err := json.Marshal(&struct {
+		Name     string `json:"name"`
+		Password string `json:"password"`
+	}{c.config.Username, c.config.Password})
+	if err != nil {
+		return "", err
+	}
+	for _, ep := range c.config.Endpoints {
+		req, err := http.NewRequest(http.MethodPost,
+			c.url(ep, "auth/authenticate"), bytes.NewReader(buf))
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		res, err := c.client.Do(req.WithContext(ctx))
+		if err != nil {
+			continue
+		}
+		result := &struct {
+			Token string `json:"token"`
+		}{}
+		err = decode(res, result)
+		res.Body.Close()
+		if err != nil {
+			return "", err
+		}
+		c.token = result.Token
+		return c.token, nil
+	}
+	return "", goof.New("error authenticating with etcd")
+}
+
+type rangeRequest struct {
+	Key        []byte `json:"key"`
+	RangeEnd   []byte `json:"range_end,omitempty"`
+	KeysOnly   bool   `json:"keys_only,omitempty"`
+	SortOrder  string `json:"sort_order,omitempty"`
+	SortTarget string `json:"sort_target,omitempty"`
+}
+
+type header struct {
+	Revision int64 `json:"revision,string"`
+}
+
+type rangeResponse struct {
+	Header header      `json:"header"`
+	Kvs    []*KeyValue `json:"kvs"`
+}
+
+// Get returns the key, or nil if it does not exist, and the revision of the
+// store at the time of the request.
+func (c *Client) Get(
+	ctx context.Context, key string) (*KeyValue, int64, error) {
+	res := &rangeResponse{}
+	req := &rangeRequest{Key: []byte(key)}
+	if err := c.do(ctx, "kv/range", req, res); err != nil {
+		return nil, 0, err
+	}
+	if len(res.Kvs) == 0 {
+		return nil, res.Header.Revision, nil
+	}
+	return res.Kvs[0], res.Header.Revision, nil
+}
+
+// Keys returns the keys with the prefix in ascending order.
+func (c *Client) Keys(ctx context.Context, prefix string) ([]string, error) {
+	res := &rangeResponse{}
+	if err := c.do(ctx, "kv/range", &rangeRequest{
+		Key:        []byte(prefix),
+		RangeEnd:   prefixEnd(prefix),
+		KeysOnly:   true,
+		SortOrder:  "ASCEND",
+		SortTarget: "KEY",
+	}, res); err != nil {
+		return nil, err
+	}
+	keys := []string{}
+	for _, kv := range res.Kvs {
+		keys = append(keys, string(kv.Key))
+	}
+	return keys, nil
+}
+
+// prefixEnd returns the end of the range of the keys with the prefix.
+func prefixEnd(prefix string) []byte {
+	end := []byte(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	// the prefix is all 0xff, so the range has no end
+	return []byte{0}
+}
+
+type putRequest struct {
+	Key   []byte `json:"key"`
+	Value []byte `json:"value"`
+}
+
+type deleteRequest struct {
+	Key []byte `json:"key"`
+}
+
+// Put sets the key's value.
+func (c *Client) Put(ctx context.Context, key string, value []byte) error {
+	req := &putRequest{Key: []byte(key), Value: value}
+	return c.do(ctx, "kv/put", req, nil)
+}
+
+// Delete deletes the key.
+func (c *Client) Delete(ctx context.Context, key string) error {
+	req := &deleteRequest{Key: []byte(key)}
+	return c.do(ctx, "kv/deleterange", req, nil)
+}
+
+type compare struct {
+	Target      string `json:"target"`
+	Result      string `json:"result"`
+	Key         []byte `json:"key"`
+	ModRevision int64  `json:"mod_revision,string"`
+}
+
+type requestOp struct {
+	RequestPut         *putRequest    `json:"request_put,omitempty"`
+	RequestDeleteRange *deleteRequest `json:"request_delete_range,omitempty"`
+}
+
+type txnRequest struct {
+	Compare []*compare   `json:"compare"`
+	Success []*requestOp `json:"success"`
+}
+
+// txn performs the operation if the key's revision is rev, and returns a
+// flag indicating whether it did. The revision of a key that does not exist
+// is zero.
+func (c *Client) txn(
+	ctx context.Context,
+	key string,
+	rev int64,
+	op *requestOp) (bool, error) {
+
+	res := &struct {
+		Succeeded bool `json:"succeeded"`
+	}{}
+	if err := c.do(ctx, "kv/txn", &txnRequest{
+		Compare: []*compare{{
+			Target:      "MOD",
+			Result:      "EQUAL",
+			Key:         []byte(key),
+			ModRevision: rev,
+		}},
+		Success: []*requestOp{op},
+	}, res); err != nil {
+		return false, err
+	}
+	return res.Succeeded, nil
+}
+
+// PutIf sets the key's value if the key's revision is rev, and returns a
+// flag indicating whether it did.
+func (c *Client) PutIf(
+	ctx context.Context, key string, value []byte, rev int64) (bool, error) {
+
+	return c.txn(ctx, key, rev, &requestOp{
+		RequestPut: &putRequest{Key: []byte(key), Value: value},
+	})
+}
+
+// DeleteIf deletes the key if its revision is rev, and returns a flag
+// indicating whether it did.
+func (c *Client) DeleteIf(
+	ctx context.Context, key string, rev int64) (bool, error) {
+
+	return c.txn(ctx, key, rev, &requestOp{
+		RequestDeleteRange: &deleteRequest{Key: []byte(key)},
+	})
+}
+
+type watchRequest struct {
+	CreateRequest *watchCreateRequest `json:"create_request"`
+}
+
+type watchCreateRequest struct {
+	Key           []byte `json:"key"`
+	StartRevision int64  `json:"start_revision,string"`
+}
+
+// Event is a change to a key.
+type Event struct {
+	// Type is DELETE if the change deleted the key. It is empty otherwise.
+	Type string    `json:"type"`
+	Kv   *KeyValue `json:"kv"`
+}
+
+type watchResponse struct {
+	Result *struct {
+		Events          []*Event `json:"events"`
+		Canceled        bool     `json:"canceled"`
+		CancelReason    string   `json:"cancel_reason"`
+		CompactRevision int64    `json:"compact_revision,string"`
+	} `json:"result"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// Watch waits for the changes to the key from the revision rev on, and
+// returns the first of the gateway's responses that has events. It returns
+// the context's error when the context is done.
+func (c *Client) Watch(
+	ctx context.Context, key string, rev int64) ([]*Event, error) {
+
+	req := &watchRequest{&watchCreateRequest{
+		Key:           []byte(key),
+		StartRevision: rev,
+	}}
+	for retry := true; ; retry = false {
+		res, err := c.post(ctx, "watch", req)
+		if err != nil {
+			return nil, err
+		}
+		events, err := watch(ctx, res)
+		res.Body.Close()
+		if e, ok := err.(*gatewayError); ok &&
+			e.status == http.StatusUnauthorized && retry &&
+			c.config.Username != "" {
+			c.setToken("")
+			continue
+		}
+		return events, err
+	}
+}
+
+func watch(ctx context.Context, res *http.Response) ([]*Event, error) {
+	if err := decode(res, nil); err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(res.Body)
+	for {
+		msg := &watchResponse{}
+		if err := dec.Decode(msg); err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, goof.WithError("error reading etcd watch", err)
+		}
+		switch {
+		case msg.Error != nil:
+			return nil, goof.New(msg.Error.Message)
+		case msg.Result == nil:
+			continue
+		case msg.Result.Canceled:
+			return nil, goof.WithFields(goof.Fields{
+				"reason":          msg.Result.CancelReason,
+				"compactRevision": msg.Result.CompactRevision,
+			}, "etcd watch canceled")
+		case len(msg.Result.Events) > 0:
+			return msg.Result.Events, nil
+		}
+	}
+}
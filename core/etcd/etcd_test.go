@@ -0,0 +1,133 @@
+package etcd
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPrefixEnd(t *testing.T) {
+	for prefix, end := range map[string]string{
+		"a/":     "a0",
+		"a\xff":  "b",
+		"\xff":   "\x00",
+		"rexray": "rexraz",
+	} {
+		if got := string(prefixEnd(prefix)); got != end {
+			t.Fatalf("prefixEnd(%q)=%q", prefix, got)
+		}
+	}
+}
+
+func TestClient(t *testing.T) {
+	var (
+		auths int
+		token = "t1"
+		body  map[string]interface{}
+	)
+	h := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/v3/auth/authenticate" {
+			auths++
+			w.Write([]byte(`{"token":"` + token + `"}`))
+			return
+		}
+		if req.Header.Get("Authorization") != token {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":"invalid auth token","code":16}`))
+			return
+		}
+		body = map[string]interface{}{}
+		json.NewDecoder(req.Body).Decode(&body)
+		switch req.URL.Path {
+		case "/v3/kv/range":
+			w.Write([]byte(`{"header":{"revision":"9"},"kvs":[` +
+				`{"key":"cC9h","value":"MQ==","mod_revision":"7"},` +
+				`{"key":"cC9i","mod_revision":"8"}]}`))
+		case "/v3/kv/txn":
+			w.Write([]byte(`{"header":{"revision":"10"},"succeeded":true}`))
+		default:
+			w.Write([]byte(`{}`))
+		}
+	})
+	s := httptest.NewServer(h)
+	defer s.Close()
+
+	// the first endpoint refuses connections
+	ep := strings.TrimPrefix(s.URL, "http://")
+	c, err := New(Config{
+		Endpoints: []string{"127.0.0.1:1", ep},
+		Username:  "root",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	kv, rev, err := c.Get(ctx, "p/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(kv.Key) != "p/a" || string(kv.Value) != "1" ||
+		kv.ModRevision != 7 || rev != 9 {
+		t.Fatalf("kv=%+v rev=%d", kv, rev)
+	}
+
+	keys, err := c.Keys(ctx, "p/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(keys, ",") != "p/a,p/b" {
+		t.Fatalf("keys=%v", keys)
+	}
+	if body["range_end"] != "cDA=" || body["keys_only"] != true {
+		t.Fatalf("body=%v", body)
+	}
+
+	// an expired token is replaced once
+	token = "t2"
+	ok, err := c.PutIf(ctx, "p/a", []byte("2"), 7)
+	if err != nil || !ok {
+		t.Fatalf("ok=%v err=%v", ok, err)
+	}
+	if auths != 2 {
+		t.Fatalf("auths=%d", auths)
+	}
+	cmp := body["compare"].([]interface{})[0].(map[string]interface{})
+	if cmp["target"] != "MOD" || cmp["mod_revision"] != "7" {
+		t.Fatalf("compare=%v", cmp)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	var body map[string]interface{}
+	h := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body = map[string]interface{}{}
+		json.NewDecoder(req.Body).Decode(&body)
+		w.Write([]byte(`{"result":{"created":true}}` +
+			`{"result":{"events":[{"kv":{"key":"cC9h",` +
+			`"value":"MQ==","mod_revision":"11"}},` +
+			`{"type":"DELETE","kv":{"key":"cC9h","mod_revision":"12"}}]}}`))
+	})
+	s := httptest.NewServer(h)
+	defer s.Close()
+
+	c, err := New(Config{Endpoints: []string{s.URL}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, err := c.Watch(context.Background(), "p/a", 11)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || string(events[0].Kv.Value) != "1" ||
+		events[1].Type != "DELETE" || events[1].Kv.ModRevision != 12 {
+		t.Fatalf("events=%+v", events)
+	}
+	cr := body["create_request"].(map[string]interface{})
+	if cr["key"] != "cC9h" || cr["start_revision"] != "11" {
+		t.Fatalf("body=%v", body)
+	}
+}
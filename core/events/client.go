@@ -0,0 +1,221 @@
+package events
+
+import (
+	"fmt"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/akutz/gofig"
+	apitypes "github.com/emccode/libstorage/api/types"
+	apiutils "github.com/emccode/libstorage/api/utils"
+
+	"github.com/emccode/rexray/core/state"
+)
+
+// The types of the events emitted for volume operations.
+const (
+	VolumeCreated   = "volume.created"
+	VolumeRemoved   = "volume.removed"
+	VolumeAttached  = "volume.attached"
+	VolumeDetached  = "volume.detached"
+	VolumeMounted   = "volume.mounted"
+	VolumeUnmounted = "volume.unmounted"
+	SnapshotCreated = "snapshot.created"
+
+	// VolumeRecreated is emitted when a volume is recreated from a snapshot
+	// in another availability zone.
+	VolumeRecreated = "volume.recreated"
+
+	// VolumeReverted is emitted when a volume is reverted to one of its
+	// snapshots in place.
+	VolumeReverted = "volume.reverted"
+)
+
+type client struct {
+	apitypes.Client
+	service string
+	store   *state.Store
+
+	iidOnce sync.Once
+	iid     string
+}
+
+// Wrap returns a libStorage client that emits an event after each volume is
+// successfully created, removed, attached, detached, mounted, or unmounted,
+// and after each snapshot is created.
+func Wrap(config gofig.Config, c apitypes.Client) apitypes.Client {
+	return &client{
+		Client:  c,
+		service: config.GetString(apitypes.ConfigService),
+		store:   state.Default(),
+	}
+}
+
+func (c *client) Storage() apitypes.StorageDriver {
+	return &storageDriver{StorageDriver: c.Client.Storage(), c: c}
+}
+
+func (c *client) Integration() apitypes.IntegrationDriver {
+	return &integrationDriver{IntegrationDriver: c.Client.Integration(), c: c}
+}
+
+// emit records an event for a volume. A volume operation that succeeded is
+// not failed because its event could not be recorded.
+func (c *client) emit(
+	typ, volumeID, volumeName, message string, fields map[string]string) {
+
+	if fields == nil {
+		fields = map[string]string{}
+	}
+	if c.service != "" {
+		fields["service"] = c.service
+	}
+	if volumeName != "" {
+		fields["volumeName"] = volumeName
+	}
+	e := &Event{
+		Type:     typ,
+		VolumeID: volumeID,
+		Message:  message,
+		Fields:   fields,
+	}
+	if err := Emit(c.store, e); err != nil {
+		log.WithField("event", typ).WithError(err).Warn(
+			"error recording event")
+	}
+}
+
+type storageDriver struct {
+	apitypes.StorageDriver
+	c *client
+}
+
+func (d *storageDriver) VolumeCreate(
+	ctx apitypes.Context,
+	volumeName string,
+	opts *apitypes.VolumeCreateOpts) (*apitypes.Volume, error) {
+
+	vol, err := d.StorageDriver.VolumeCreate(ctx, volumeName, opts)
+	if err == nil && vol != nil {
+		d.c.emit(VolumeCreated, vol.ID, vol.Name, "volume created", nil)
+	}
+	return vol, err
+}
+
+func (d *storageDriver) VolumeRemove(
+	ctx apitypes.Context,
+	volumeID string,
+	opts apitypes.Store) error {
+
+	err := d.StorageDriver.VolumeRemove(ctx, volumeID, opts)
+	if err == nil {
+		d.c.emit(VolumeRemoved, volumeID, "", "volume removed", nil)
+	}
+	return err
+}
+
+func (d *storageDriver) VolumeAttach(
+	ctx apitypes.Context,
+	volumeID string,
+	opts *apitypes.VolumeAttachOpts) (*apitypes.Volume, string, error) {
+
+	vol, token, err := d.StorageDriver.VolumeAttach(ctx, volumeID, opts)
+	if err == nil {
+		name := ""
+		if vol != nil {
+			name = vol.Name
+		}
+		d.c.emit(VolumeAttached, volumeID, name, "volume attached",
+			d.c.instanceFields(ctx))
+	}
+	return vol, token, err
+}
+
+func (d *storageDriver) VolumeDetach(
+	ctx apitypes.Context,
+	volumeID string,
+	opts *apitypes.VolumeDetachOpts) (*apitypes.Volume, error) {
+
+	vol, err := d.StorageDriver.VolumeDetach(ctx, volumeID, opts)
+	if err == nil {
+		name := ""
+		if vol != nil {
+			name = vol.Name
+		}
+		d.c.emit(VolumeDetached, volumeID, name, "volume detached",
+			d.c.instanceFields(ctx))
+	}
+	return vol, err
+}
+
+func (d *storageDriver) VolumeSnapshot(
+	ctx apitypes.Context,
+	volumeID, snapshotName string,
+	opts apitypes.Store) (*apitypes.Snapshot, error) {
+
+	snap, err := d.StorageDriver.VolumeSnapshot(
+		ctx, volumeID, snapshotName, opts)
+	if err == nil && snap != nil {
+		d.c.emit(SnapshotCreated, volumeID, "",
+			fmt.Sprintf("snapshot %s created", snap.ID),
+			map[string]string{
+				"snapshotID":   snap.ID,
+				"snapshotName": snap.Name,
+			})
+	}
+	return snap, err
+}
+
+type integrationDriver struct {
+	apitypes.IntegrationDriver
+	c *client
+}
+
+func (d *integrationDriver) Mount(
+	ctx apitypes.Context,
+	volumeID, volumeName string,
+	opts *apitypes.VolumeMountOpts) (string, *apitypes.Volume, error) {
+
+	mountPath, vol, err := d.IntegrationDriver.Mount(
+		ctx, volumeID, volumeName, opts)
+	if err == nil {
+		if vol != nil {
+			volumeID, volumeName = vol.ID, vol.Name
+		}
+		fields := d.c.instanceFields(ctx)
+		fields["mountPath"] = mountPath
+		d.c.emit(VolumeMounted, volumeID, volumeName, "volume mounted",
+			fields)
+	}
+	return mountPath, vol, err
+}
+
+func (d *integrationDriver) Unmount(
+	ctx apitypes.Context,
+	volumeID, volumeName string,
+	opts apitypes.Store) error {
+
+	err := d.IntegrationDriver.Unmount(ctx, volumeID, volumeName, opts)
+	if err == nil {
+		d.c.emit(VolumeUnmounted, volumeID, volumeName, "volume unmounted",
+			d.c.instanceFields(ctx))
+	}
+	return err
+}
+
+// instanceFields returns the fields that identify the instance on which an
+// operation was performed. The instance's ID is looked up with the executor
+// once per client and omitted if the executor cannot provide it.
+func (c *client) instanceFields(ctx apitypes.Context) map[string]string {
+	c.iidOnce.Do(func() {
+		iid, err := c.Client.Executor().InstanceID(ctx, apiutils.NewStore())
+		if err == nil && iid != nil {
+			c.iid = iid.ID
+		}
+	})
+	fields := map[string]string{}
+	if c.iid != "" {
+		fields["instanceID"] = c.iid
+	}
+	return fields
+}
@@ -0,0 +1,196 @@
+// Package events records notable occurrences, such as a volume being
+// remounted after its mount failed, so they may be reviewed after the fact.
+package events
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/emccode/rexray/core/state"
+)
+
+const (
+	eventsBucket = "events"
+	seqBucket    = "eventSeq"
+
+	// maxEvents is the number of events retained. The oldest events are
+	// discarded as new events are emitted.
+	maxEvents = 1000
+)
+
+var (
+	seqLock sync.Mutex
+
+	watchersRwl sync.RWMutex
+	watchers    = map[chan struct{}]bool{}
+)
+
+// Event is a notable occurrence.
+type Event struct {
+
+	// ID is the sequence number of the event.
+	ID int64 `json:"id"`
+
+	// Time is the time at which the event occurred.
+	Time time.Time `json:"time"`
+
+	// Type identifies the kind of event, ex. fuse.remounted.
+	Type string `json:"type"`
+
+	// VolumeID is the ID of the volume to which the event relates, if any.
+	VolumeID string `json:"volumeID,omitempty"`
+
+	// Message describes the event.
+	Message string `json:"message"`
+
+	// Fields are additional details about the event.
+	Fields map[string]string `json:"fields,omitempty"`
+}
+
+// Emit logs and records an event.
+func Emit(s *state.Store, e *Event) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+
+	fields := map[string]interface{}{"event": e.Type}
+	if e.VolumeID != "" {
+		fields["volumeID"] = e.VolumeID
+	}
+	for k, v := range e.Fields {
+		fields[k] = v
+	}
+	log.WithFields(fields).Info(e.Message)
+
+	seqLock.Lock()
+	defer seqLock.Unlock()
+
+	var seq int64
+	if _, err := s.Get(seqBucket, eventsBucket, &seq); err != nil {
+		return err
+	}
+	seq++
+	if err := s.Set(seqBucket, eventsBucket, seq); err != nil {
+		return err
+	}
+	e.ID = seq
+
+	if err := s.Set(eventsBucket, strconv.FormatInt(e.ID, 10), e); err != nil {
+		return err
+	}
+	notify()
+	if e.ID > maxEvents {
+		return s.Delete(
+			eventsBucket, strconv.FormatInt(e.ID-maxEvents, 10))
+	}
+	return nil
+}
+
+// List returns the retained events, oldest first.
+func List(s *state.Store) ([]*Event, error) {
+	keys, err := s.Keys(eventsBucket)
+	if err != nil {
+		return nil, err
+	}
+	all := []*Event{}
+	for _, k := range keys {
+		e := &Event{}
+		ok, err := s.Get(eventsBucket, k, e)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			all = append(all, e)
+		}
+	}
+	sort.Sort(byID(all))
+	return all, nil
+}
+
+// Since returns the retained events whose IDs are greater than the provided
+// ID, oldest first.
+func Since(s *state.Store, id int64) ([]*Event, error) {
+	all, err := List(s)
+	if err != nil {
+		return nil, err
+	}
+	i := sort.Search(len(all), func(i int) bool { return all[i].ID > id })
+	return all[i:], nil
+}
+
+// Watch returns a channel that receives a value after this process emits
+// one or more events, and a function that stops the channel from receiving
+// values. Events emitted by other processes that share the state are not
+// signaled, so watchers should also check for events periodically.
+func Watch() (<-chan struct{}, func()) {
+	c := make(chan struct{}, 1)
+	watchersRwl.Lock()
+	watchers[c] = true
+	watchersRwl.Unlock()
+	return c, func() {
+		watchersRwl.Lock()
+		delete(watchers, c)
+		watchersRwl.Unlock()
+	}
+}
+
+func notify() {
+	watchersRwl.RLock()
+	defer watchersRwl.RUnlock()
+	for c := range watchers {
+		select {
+		case c <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Filter selects events by their types and volumes. An empty filter selects
+// every event.
+type Filter struct {
+
+	// Types are the types of the selected events. A type that ends with a
+	// period selects the types it prefixes, ex. volume. selects
+	// volume.attached.
+	Types []string
+
+	// VolumeIDs are the IDs of the volumes of the selected events.
+	VolumeIDs []string
+}
+
+// Match returns a flag indicating whether the filter selects the event.
+func (f *Filter) Match(e *Event) bool {
+	if len(f.Types) > 0 {
+		ok := false
+		for _, t := range f.Types {
+			if t == e.Type ||
+				(strings.HasSuffix(t, ".") && strings.HasPrefix(e.Type, t)) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	if len(f.VolumeIDs) > 0 {
+		for _, id := range f.VolumeIDs {
+			if strings.EqualFold(id, e.VolumeID) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+type byID []*Event
+
+func (e byID) Len() int           { return len(e) }
+func (e byID) Swap(i, j int)      { e[i], e[j] = e[j], e[i] }
+func (e byID) Less(i, j int) bool { return e[i].ID < e[j].ID }
@@ -0,0 +1,63 @@
+package events
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/emccode/rexray/core/state"
+)
+
+func TestEmitList(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rexray-events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	s := state.Open(filepath.Join(dir, "state.json"))
+
+	for _, typ := range []string{"a", "b", "c"} {
+		if err := Emit(s, &Event{Type: typ, Message: typ}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	all, err := List(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 3 {
+		t.Fatalf("expected 3 events, got %d", len(all))
+	}
+	for i, typ := range []string{"a", "b", "c"} {
+		if all[i].Type != typ || all[i].ID != int64(i+1) {
+			t.Fatalf("unexpected event %d: %+v", i, all[i])
+		}
+		if all[i].Time.IsZero() {
+			t.Fatalf("event %d has no time", i)
+		}
+	}
+}
+
+func TestFilterMatch(t *testing.T) {
+	e := &Event{Type: "volume.attached", VolumeID: "vol-1"}
+	tests := []struct {
+		f  Filter
+		ok bool
+	}{
+		{Filter{}, true},
+		{Filter{Types: []string{"volume.attached"}}, true},
+		{Filter{Types: []string{"volume."}}, true},
+		{Filter{Types: []string{"volume"}}, false},
+		{Filter{Types: []string{"fuse.", "volume.detached"}}, false},
+		{Filter{VolumeIDs: []string{"VOL-1"}}, true},
+		{Filter{VolumeIDs: []string{"vol-2"}}, false},
+		{Filter{Types: []string{"volume."}, VolumeIDs: []string{"vol-2"}}, false},
+	}
+	for i, test := range tests {
+		if ok := test.f.Match(e); ok != test.ok {
+			t.Errorf("filter %d: expected %v, got %v", i, test.ok, ok)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+// Package executors embeds the libStorage executor binaries (lsx) in REX-Ray
+// so that agents always run the executor that was built with them. The
+// embedded executor is written to the executor path at startup, and the
+// executor is hashed before each of its operations so that an operation fails
+// if the executor was replaced. libStorage runs the executor from its path
+// after it is hashed, so a file that is replaced between the two is not
+// detected; the executor path must therefore be in a directory that only
+// REX-Ray's user may write to, such as the REX-Ray lib directory.
+package executors
+
+import (
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+
+	"github.com/akutz/goof"
+)
+
+// embedded holds the executors that make build-executors builds into the lsx
+// directory. The directory holds only its README in the source tree, so a
+// binary that is built without them, such as with go build, embeds no
+// executor and downloads the executors from the libStorage server.
+//go:embed lsx
+var embedded embed.FS
+
+var (
+	// ErrNotEmbedded is returned when no executor was embedded for the
+	// current operating system, such as in a development build.
+	ErrNotEmbedded = goof.New("executor not embedded")
+
+	checksum     string
+	checksumErr  error
+	checksumOnce sync.Once
+)
+
+// Name returns the name of the executor for the current operating system.
+func Name() string {
+	return "lsx-" + runtime.GOOS
+}
+
+// Embedded returns the executor embedded for the current operating system.
+func Embedded() ([]byte, error) {
+	buf, err := embedded.ReadFile("lsx/" + Name())
+	if err != nil {
+		return nil, ErrNotEmbedded
+	}
+	return buf, nil
+}
+
+// Checksum returns the SHA-256 checksum of the embedded executor.
+func Checksum() (string, error) {
+	checksumOnce.Do(func() {
+		buf, err := Embedded()
+		if err != nil {
+			checksumErr = err
+			return
+		}
+		sum := sha256.Sum256(buf)
+		checksum = hex.EncodeToString(sum[:])
+	})
+	return checksum, checksumErr
+}
+
+// IsEmbedded returns a flag indicating whether or not an executor was
+// embedded for the current operating system.
+func IsEmbedded() bool {
+	_, err := Checksum()
+	return err == nil
+}
+
+// Install writes the embedded executor to the provided path unless the file
+// there already matches it. The executor is written to a temporary file and
+// verified before it replaces the existing file.
+func Install(path string) error {
+	if !IsEmbedded() {
+		return ErrNotEmbedded
+	}
+	if err := Verify(path); err == nil {
+		return nil
+	}
+
+	buf, err := Embedded()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+Name())
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(buf); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0755); err != nil {
+		return err
+	}
+	if err := Verify(tmp.Name()); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	return Verify(path)
+}
+
+// Verify returns an error if the executor at the provided path does not
+// match the embedded executor. The file is hashed every time, rather than
+// the result being cached, since a file can be replaced without changing its
+// size or modification time.
+func Verify(path string) error {
+	expected, err := Checksum()
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+	if actual := hex.EncodeToString(h.Sum(nil)); actual != expected {
+		return goof.WithFields(goof.Fields{
+			"path":     path,
+			"expected": expected,
+			"actual":   actual,
+		}, "executor checksum mismatch")
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package external
+
+import (
+	apitypes "github.com/emccode/libstorage/api/types"
+	apiutils "github.com/emccode/libstorage/api/utils"
+
+	"github.com/emccode/rexray/core/external/externalpb"
+)
+
+func toOpts(s apitypes.Store) map[string]string {
+	if s == nil {
+		return nil
+	}
+	opts := map[string]string{}
+	for _, k := range s.Keys() {
+		opts[k] = s.GetString(k)
+	}
+	return opts
+}
+
+func fromOpts(opts map[string]string) apitypes.Store {
+	if opts == nil {
+		opts = map[string]string{}
+	}
+	return apiutils.NewStoreWithVars(opts)
+}
+
+func toVolume(v *apitypes.Volume) *externalpb.Volume {
+	if v == nil {
+		return nil
+	}
+	vol := &externalpb.Volume{
+		Id:               v.ID,
+		Name:             v.Name,
+		Size:             v.Size,
+		Type:             v.Type,
+		Iops:             v.IOPS,
+		AvailabilityZone: v.AvailabilityZone,
+		Status:           v.Status,
+		Fields:           v.Fields,
+	}
+	for _, a := range v.Attachments {
+		att := &externalpb.Attachment{
+			DeviceName: a.DeviceName,
+			MountPoint: a.MountPoint,
+			Status:     a.Status,
+			Fields:     a.Fields,
+		}
+		if a.InstanceID != nil {
+			att.InstanceId = a.InstanceID.ID
+		}
+		vol.Attachments = append(vol.Attachments, att)
+	}
+	return vol
+}
+
+func fromVolume(driver string, v *externalpb.Volume) *apitypes.Volume {
+	if v == nil {
+		return nil
+	}
+	vol := &apitypes.Volume{
+		ID:               v.Id,
+		Name:             v.Name,
+		Size:             v.Size,
+		Type:             v.Type,
+		IOPS:             v.Iops,
+		AvailabilityZone: v.AvailabilityZone,
+		Status:           v.Status,
+		Fields:           v.Fields,
+	}
+	for _, a := range v.Attachments {
+		att := &apitypes.VolumeAttachment{
+			VolumeID:   v.Id,
+			DeviceName: a.DeviceName,
+			MountPoint: a.MountPoint,
+			Status:     a.Status,
+			Fields:     a.Fields,
+		}
+		if a.InstanceId != "" {
+			att.InstanceID = &apitypes.InstanceID{ID: a.InstanceId, Driver: driver}
+		}
+		vol.Attachments = append(vol.Attachments, att)
+	}
+	return vol
+}
+
+func toSnapshot(s *apitypes.Snapshot) *externalpb.Snapshot {
+	if s == nil {
+		return nil
+	}
+	return &externalpb.Snapshot{
+		Id:          s.ID,
+		Name:        s.Name,
+		VolumeId:    s.VolumeID,
+		VolumeSize:  s.VolumeSize,
+		StartTime:   s.StartTime,
+		Description: s.Description,
+		Status:      s.Status,
+		Fields:      s.Fields,
+	}
+}
+
+func fromSnapshot(s *externalpb.Snapshot) *apitypes.Snapshot {
+	if s == nil {
+		return nil
+	}
+	return &apitypes.Snapshot{
+		ID:          s.Id,
+		Name:        s.Name,
+		VolumeID:    s.VolumeId,
+		VolumeSize:  s.VolumeSize,
+		StartTime:   s.StartTime,
+		Description: s.Description,
+		Status:      s.Status,
+		Fields:      s.Fields,
+	}
+}
+
+func toInstance(i *apitypes.Instance) *externalpb.Instance {
+	if i == nil {
+		return nil
+	}
+	inst := &externalpb.Instance{
+		Name:         i.Name,
+		ProviderName: i.ProviderName,
+		Region:       i.Region,
+		Fields:       i.Fields,
+	}
+	if i.InstanceID != nil {
+		inst.InstanceId = i.InstanceID.ID
+	}
+	return inst
+}
+
+func fromInstance(driver string, i *externalpb.Instance) *apitypes.Instance {
+	if i == nil {
+		return nil
+	}
+	return &apitypes.Instance{
+		InstanceID:   &apitypes.InstanceID{ID: i.InstanceId, Driver: driver},
+		Name:         i.Name,
+		ProviderName: i.ProviderName,
+		Region:       i.Region,
+		Fields:       i.Fields,
+	}
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func int64Value(i *int64) int64 {
+	if i == nil {
+		return 0
+	}
+	return *i
+}
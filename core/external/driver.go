@@ -0,0 +1,487 @@
+// Package external adapts a storage driver that runs as a separate process to
+// the libStorage storage driver interface. The process speaks the gRPC
+// contract defined by the externalpb package, so vendors may ship drivers
+// without forking or recompiling REX-Ray.
+package external
+
+//go:generate protoc -I externalpb --go_out=plugins=grpc:externalpb externalpb/external.proto
+
+import (
+	"encoding/json"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	"github.com/akutz/gotil"
+	"github.com/emccode/libstorage/api/context"
+	"github.com/emccode/libstorage/api/registry"
+	apitypes "github.com/emccode/libstorage/api/types"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+
+	"github.com/emccode/rexray/core/errcodes"
+	"github.com/emccode/rexray/core/external/externalpb"
+)
+
+const (
+	// Name is the name with which the driver is registered.
+	Name = "external"
+
+	// EnvSocket is the environment variable that tells a driver process
+	// started by REX-Ray the path of the UNIX socket on which to serve the
+	// driver contract.
+	EnvSocket = "REXRAY_EXTERNAL_DRIVER_SOCKET"
+
+	defaultStartTimeout = 30 * time.Second
+)
+
+func init() {
+	registry.RegisterStorageDriver(Name, newDriver)
+	registry.RegisterStorageExecutor(Name, newExecutor)
+
+	r := gofig.NewRegistration("External Driver")
+	r.Key(gofig.String, "", "",
+		"The path of the executable that implements the external driver",
+		"external.path")
+	r.Key(gofig.String, "", "",
+		"The arguments with which the external driver is started",
+		"external.args")
+	r.Key(gofig.String, "", "",
+		"The address of an external driver that is already running",
+		"external.address")
+	r.Key(gofig.String, "", "30s",
+		"How long to wait for the external driver to accept connections",
+		"external.startTimeout")
+	r.Key(gofig.String, "", "",
+		"How the external driver's LUNs are presented to nodes: fc",
+		"external.transport")
+	r.Key(gofig.Bool, "", true,
+		"Use the multipath device of a LUN when there is one",
+		"external.multipath")
+	gofig.Register(r)
+}
+
+type driver struct {
+	name   string
+	config gofig.Config
+	client externalpb.DriverClient
+}
+
+func newDriver() apitypes.StorageDriver {
+	return &driver{}
+}
+
+func (d *driver) Name() string {
+	return Name
+}
+
+// Init starts the driver process, unless the address of a running driver is
+// configured, and then sends it the service's external driver configuration.
+// Only the keys under external are sent, so that the driver process is not
+// given the settings, such as the credentials, of other services.
+func (d *driver) Init(ctx apitypes.Context, config gofig.Config) error {
+	d.config = config
+
+	addr := config.GetString("external.address")
+	path := config.GetString("external.path")
+
+	buf, err := scopedConfig(config)
+	if err != nil {
+		return err
+	}
+	req := &externalpb.InitRequest{Config: string(buf)}
+
+	var p *process
+	switch {
+	case addr != "":
+		d.name = addr
+		conn, err := dial(config, addr)
+		if err != nil {
+			return err
+		}
+		d.client = externalpb.NewDriverClient(conn)
+	case path != "":
+		if p, err = startProcess(ctx, config, path, buf); err != nil {
+			return err
+		}
+		d.name, d.client, addr = p.name, p.client, "unix://"+p.sock
+	default:
+		return goof.New(
+			"external driver requires external.path or external.address")
+	}
+
+	if _, err := d.client.Init(ctx, req); err != nil {
+		return d.error("error initializing external driver", err)
+	}
+	if p != nil {
+		p.setInit(req)
+	}
+
+	ctx.WithFields(map[string]interface{}{
+		"path":    path,
+		"address": addr,
+	}).Info("initialized external driver")
+	return nil
+}
+
+// scopedConfig returns the JSON of the keys under external in the service's
+// configuration.
+func scopedConfig(config gofig.Config) ([]byte, error) {
+	ext := map[string]interface{}{}
+	for _, k := range config.AllKeys() {
+		k = strings.ToLower(k)
+		i := strings.Index(k, "external.")
+		if i < 0 || (i > 0 && k[i-1] != '.') {
+			continue
+		}
+		key := k[i:]
+		v := config.Get(key)
+		if v == nil {
+			continue
+		}
+		m := ext
+		parts := strings.Split(strings.TrimPrefix(key, "external."), ".")
+		for _, part := range parts[:len(parts)-1] {
+			sub, ok := m[part].(map[string]interface{})
+			if !ok {
+				sub = map[string]interface{}{}
+				m[part] = sub
+			}
+			m = sub
+		}
+		m[parts[len(parts)-1]] = v
+	}
+	return json.Marshal(map[string]interface{}{"external": ext})
+}
+
+func dial(config gofig.Config, addr string) (*grpc.ClientConn, error) {
+	conn, err := grpc.Dial(addr,
+		grpc.WithInsecure(),
+		grpc.WithBlock(),
+		grpc.WithTimeout(startTimeout(config)),
+		grpc.WithDialer(func(a string, t time.Duration) (net.Conn, error) {
+			proto, laddr, err := gotil.ParseAddress(a)
+			if err != nil {
+				return nil, err
+			}
+			return net.DialTimeout(proto, laddr, t)
+		}))
+	if err != nil {
+		return nil, goof.WithFieldE("address", addr,
+			"error connecting to external driver", err)
+	}
+	return conn, nil
+}
+
+func startTimeout(config gofig.Config) time.Duration {
+	if t, err := time.ParseDuration(
+		config.GetString("external.startTimeout")); err == nil && t > 0 {
+		return t
+	}
+	return defaultStartTimeout
+}
+
+// error returns the error of a call to the external driver with the code of
+// the call's gRPC status, so that the code reaches the libStorage client.
+func (d *driver) error(msg string, err error) error {
+	return errcodes.New(errCode(grpc.Code(err)), goof.WithFieldsE(
+		map[string]interface{}{
+			"driver": d.name,
+			"cause":  grpc.ErrorDesc(err),
+		}, msg, err))
+}
+
+// errCode returns the code of a gRPC status.
+func errCode(c codes.Code) errcodes.Code {
+	switch c {
+	case codes.InvalidArgument, codes.AlreadyExists:
+		return errcodes.InvalidRequest
+	case codes.NotFound:
+		return errcodes.NotFound
+	case codes.ResourceExhausted:
+		return errcodes.BackendThrottled
+	case codes.Unavailable:
+		return errcodes.BackendUnavailable
+	case codes.DeadlineExceeded:
+		return errcodes.Timeout
+	case codes.Canceled:
+		return errcodes.Canceled
+	case codes.Unauthenticated, codes.PermissionDenied:
+		return errcodes.Unauthorized
+	case codes.Unimplemented:
+		return errcodes.NotImplemented
+	}
+	return errcodes.Unknown
+}
+
+// instanceID returns the ID of the instance on whose behalf an operation is
+// performed.
+func instanceID(ctx apitypes.Context) string {
+	if iid, ok := ctx.Value(context.InstanceIDKey).(*apitypes.InstanceID); ok {
+		return iid.ID
+	}
+	return ""
+}
+
+func (d *driver) Type(ctx apitypes.Context) (apitypes.StorageType, error) {
+	res, err := d.client.Type(ctx, &externalpb.TypeRequest{})
+	if err != nil {
+		return "", d.error("error getting storage type", err)
+	}
+	return apitypes.StorageType(res.Type), nil
+}
+
+func (d *driver) NextDeviceInfo(
+	ctx apitypes.Context) (*apitypes.NextDeviceInfo, error) {
+
+	res, err := d.client.NextDeviceInfo(
+		ctx, &externalpb.NextDeviceInfoRequest{})
+	if err != nil {
+		return nil, d.error("error getting next device info", err)
+	}
+	return &apitypes.NextDeviceInfo{
+		Prefix:  res.Prefix,
+		Pattern: res.Pattern,
+		Ignore:  res.Ignore,
+	}, nil
+}
+
+func (d *driver) InstanceInspect(
+	ctx apitypes.Context,
+	opts apitypes.Store) (*apitypes.Instance, error) {
+
+	res, err := d.client.InstanceInspect(ctx, &externalpb.InstanceInspectRequest{
+		InstanceId: instanceID(ctx),
+		Opts:       toOpts(opts),
+	})
+	if err != nil {
+		return nil, d.error("error inspecting instance", err)
+	}
+	return fromInstance(Name, res), nil
+}
+
+func (d *driver) Volumes(
+	ctx apitypes.Context,
+	opts *apitypes.VolumesOpts) ([]*apitypes.Volume, error) {
+
+	res, err := d.client.Volumes(ctx, &externalpb.VolumesRequest{
+		InstanceId:  instanceID(ctx),
+		Attachments: opts.Attachments,
+		Opts:        toOpts(opts.Opts),
+	})
+	if err != nil {
+		return nil, d.error("error listing volumes", err)
+	}
+	vols := []*apitypes.Volume{}
+	for _, v := range res.Volumes {
+		vols = append(vols, fromVolume(Name, v))
+	}
+	return vols, nil
+}
+
+func (d *driver) VolumeInspect(
+	ctx apitypes.Context,
+	volumeID string,
+	opts *apitypes.VolumeInspectOpts) (*apitypes.Volume, error) {
+
+	res, err := d.client.VolumeInspect(ctx, &externalpb.VolumeInspectRequest{
+		InstanceId:  instanceID(ctx),
+		VolumeId:    volumeID,
+		Attachments: opts.Attachments,
+		Opts:        toOpts(opts.Opts),
+	})
+	if err != nil {
+		return nil, d.error("error inspecting volume", err)
+	}
+	return fromVolume(Name, res), nil
+}
+
+func (d *driver) VolumeCreate(
+	ctx apitypes.Context,
+	name string,
+	opts *apitypes.VolumeCreateOpts) (*apitypes.Volume, error) {
+
+	return d.volumeCreate(ctx, "", name, opts)
+}
+
+func (d *driver) VolumeCreateFromSnapshot(
+	ctx apitypes.Context,
+	snapshotID, volumeName string,
+	opts *apitypes.VolumeCreateOpts) (*apitypes.Volume, error) {
+
+	return d.volumeCreate(ctx, snapshotID, volumeName, opts)
+}
+
+func (d *driver) volumeCreate(
+	ctx apitypes.Context,
+	snapshotID, name string,
+	opts *apitypes.VolumeCreateOpts) (*apitypes.Volume, error) {
+
+	res, err := d.client.VolumeCreate(ctx, &externalpb.VolumeCreateRequest{
+		InstanceId:       instanceID(ctx),
+		Name:             name,
+		SnapshotId:       snapshotID,
+		AvailabilityZone: stringValue(opts.AvailabilityZone),
+		Size:             int64Value(opts.Size),
+		Type:             stringValue(opts.Type),
+		Iops:             int64Value(opts.IOPS),
+		Opts:             toOpts(opts.Opts),
+	})
+	if err != nil {
+		return nil, d.error("error creating volume", err)
+	}
+	return fromVolume(Name, res), nil
+}
+
+func (d *driver) VolumeCopy(
+	ctx apitypes.Context,
+	volumeID, volumeName string,
+	opts apitypes.Store) (*apitypes.Volume, error) {
+
+	res, err := d.client.VolumeCopy(ctx, &externalpb.VolumeCopyRequest{
+		InstanceId: instanceID(ctx),
+		VolumeId:   volumeID,
+		Name:       volumeName,
+		Opts:       toOpts(opts),
+	})
+	if err != nil {
+		return nil, d.error("error copying volume", err)
+	}
+	return fromVolume(Name, res), nil
+}
+
+func (d *driver) VolumeSnapshot(
+	ctx apitypes.Context,
+	volumeID, snapshotName string,
+	opts apitypes.Store) (*apitypes.Snapshot, error) {
+
+	res, err := d.client.VolumeSnapshot(ctx, &externalpb.VolumeSnapshotRequest{
+		InstanceId: instanceID(ctx),
+		VolumeId:   volumeID,
+		Name:       snapshotName,
+		Opts:       toOpts(opts),
+	})
+	if err != nil {
+		return nil, d.error("error snapshotting volume", err)
+	}
+	return fromSnapshot(res), nil
+}
+
+func (d *driver) VolumeRemove(
+	ctx apitypes.Context,
+	volumeID string,
+	opts apitypes.Store) error {
+
+	if _, err := d.client.VolumeRemove(ctx, &externalpb.VolumeRemoveRequest{
+		InstanceId: instanceID(ctx),
+		VolumeId:   volumeID,
+		Opts:       toOpts(opts),
+	}); err != nil {
+		return d.error("error removing volume", err)
+	}
+	return nil
+}
+
+func (d *driver) VolumeAttach(
+	ctx apitypes.Context,
+	volumeID string,
+	opts *apitypes.VolumeAttachOpts) (*apitypes.Volume, string, error) {
+
+	res, err := d.client.VolumeAttach(ctx, &externalpb.VolumeAttachRequest{
+		InstanceId: instanceID(ctx),
+		VolumeId:   volumeID,
+		NextDevice: stringValue(opts.NextDevice),
+		Force:      opts.Force,
+		Opts:       toOpts(opts.Opts),
+	})
+	if err != nil {
+		return nil, "", d.error("error attaching volume", err)
+	}
+	return fromVolume(Name, res.Volume), res.Token, nil
+}
+
+func (d *driver) VolumeDetach(
+	ctx apitypes.Context,
+	volumeID string,
+	opts *apitypes.VolumeDetachOpts) (*apitypes.Volume, error) {
+
+	res, err := d.client.VolumeDetach(ctx, &externalpb.VolumeDetachRequest{
+		InstanceId: instanceID(ctx),
+		VolumeId:   volumeID,
+		Force:      opts.Force,
+		Opts:       toOpts(opts.Opts),
+	})
+	if err != nil {
+		return nil, d.error("error detaching volume", err)
+	}
+	return fromVolume(Name, res), nil
+}
+
+func (d *driver) Snapshots(
+	ctx apitypes.Context,
+	opts apitypes.Store) ([]*apitypes.Snapshot, error) {
+
+	res, err := d.client.Snapshots(ctx, &externalpb.SnapshotsRequest{
+		InstanceId: instanceID(ctx),
+		Opts:       toOpts(opts),
+	})
+	if err != nil {
+		return nil, d.error("error listing snapshots", err)
+	}
+	snaps := []*apitypes.Snapshot{}
+	for _, s := range res.Snapshots {
+		snaps = append(snaps, fromSnapshot(s))
+	}
+	return snaps, nil
+}
+
+func (d *driver) SnapshotInspect(
+	ctx apitypes.Context,
+	snapshotID string,
+	opts apitypes.Store) (*apitypes.Snapshot, error) {
+
+	res, err := d.client.SnapshotInspect(ctx, &externalpb.SnapshotInspectRequest{
+		InstanceId: instanceID(ctx),
+		SnapshotId: snapshotID,
+		Opts:       toOpts(opts),
+	})
+	if err != nil {
+		return nil, d.error("error inspecting snapshot", err)
+	}
+	return fromSnapshot(res), nil
+}
+
+func (d *driver) SnapshotCopy(
+	ctx apitypes.Context,
+	snapshotID, snapshotName, destinationID string,
+	opts apitypes.Store) (*apitypes.Snapshot, error) {
+
+	res, err := d.client.SnapshotCopy(ctx, &externalpb.SnapshotCopyRequest{
+		InstanceId:    instanceID(ctx),
+		SnapshotId:    snapshotID,
+		Name:          snapshotName,
+		DestinationId: destinationID,
+		Opts:          toOpts(opts),
+	})
+	if err != nil {
+		return nil, d.error("error copying snapshot", err)
+	}
+	return fromSnapshot(res), nil
+}
+
+func (d *driver) SnapshotRemove(
+	ctx apitypes.Context,
+	snapshotID string,
+	opts apitypes.Store) error {
+
+	if _, err := d.client.SnapshotRemove(ctx, &externalpb.SnapshotRemoveRequest{
+		InstanceId: instanceID(ctx),
+		SnapshotId: snapshotID,
+		Opts:       toOpts(opts),
+	}); err != nil {
+		return d.error("error removing snapshot", err)
+	}
+	return nil
+}
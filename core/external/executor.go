@@ -0,0 +1,47 @@
+package external
+
+import (
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/core/scsi"
+)
+
+// TransportFC is the transport of an external driver whose LUNs are
+// presented to nodes over Fibre Channel.
+const TransportFC = "fc"
+
+// executor discovers the devices of an external driver's volumes on a node.
+// The driver process runs with the libStorage server, so the devices are
+// discovered according to the configured transport rather than by the
+// driver.
+type executor struct {
+	apitypes.StorageExecutorFunctions
+}
+
+func newExecutor() apitypes.StorageExecutor {
+	return &executor{}
+}
+
+func (e *executor) Name() string {
+	return Name
+}
+
+func (e *executor) Init(ctx apitypes.Context, config gofig.Config) error {
+	switch t := config.GetString("external.transport"); t {
+	case TransportFC:
+		e.StorageExecutorFunctions = &scsi.FCExecutor{
+			Driver: Name,
+			Multipath: config.GetBool("external.multipath") &&
+				scsi.Multipath(config),
+			MultipathTimeout: scsi.MultipathTimeout(config),
+		}
+		return nil
+	case "":
+		return goof.New(
+			"external driver executor requires external.transport")
+	default:
+		return goof.WithField("transport", t, "invalid external transport")
+	}
+}
@@ -0,0 +1,1085 @@
+// This file was written by hand to match the output of protoc-gen-go for
+// external.proto, since protoc was not available when the contract was
+// added. It must be regenerated, and this comment with it, by running
+//
+//     go generate ./core/external
+//
+// with protoc and protoc-gen-go installed. Do not edit it otherwise.
+
+/*
+Package externalpb is a generated protocol buffer package.
+
+It is generated from these files:
+
+	external.proto
+
+It has these top-level messages:
+
+	Empty
+	Attachment
+	Volume
+	Snapshot
+	Instance
+	InitRequest
+	InitResponse
+	TypeRequest
+	TypeResponse
+	NextDeviceInfoRequest
+	NextDeviceInfoResponse
+	InstanceInspectRequest
+	VolumesRequest
+	VolumesResponse
+	VolumeInspectRequest
+	VolumeCreateRequest
+	VolumeCopyRequest
+	VolumeSnapshotRequest
+	VolumeRemoveRequest
+	VolumeAttachRequest
+	VolumeAttachResponse
+	VolumeDetachRequest
+	SnapshotsRequest
+	SnapshotsResponse
+	SnapshotInspectRequest
+	SnapshotCopyRequest
+	SnapshotRemoveRequest
+*/
+package externalpb
+
+import proto "github.com/golang/protobuf/proto"
+import fmt "fmt"
+import math "math"
+
+import (
+	context "golang.org/x/net/context"
+	grpc "google.golang.org/grpc"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
+
+type Empty struct {
+}
+
+func (m *Empty) Reset()         { *m = Empty{} }
+func (m *Empty) String() string { return proto.CompactTextString(m) }
+func (*Empty) ProtoMessage()    {}
+
+type Attachment struct {
+	InstanceId string            `protobuf:"bytes,1,opt,name=instance_id" json:"instance_id,omitempty"`
+	DeviceName string            `protobuf:"bytes,2,opt,name=device_name" json:"device_name,omitempty"`
+	MountPoint string            `protobuf:"bytes,3,opt,name=mount_point" json:"mount_point,omitempty"`
+	Status     string            `protobuf:"bytes,4,opt,name=status" json:"status,omitempty"`
+	Fields     map[string]string `protobuf:"bytes,5,rep,name=fields" json:"fields,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *Attachment) Reset()         { *m = Attachment{} }
+func (m *Attachment) String() string { return proto.CompactTextString(m) }
+func (*Attachment) ProtoMessage()    {}
+
+func (m *Attachment) GetFields() map[string]string {
+	if m != nil {
+		return m.Fields
+	}
+	return nil
+}
+
+type Volume struct {
+	Id               string            `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+	Name             string            `protobuf:"bytes,2,opt,name=name" json:"name,omitempty"`
+	Size             int64             `protobuf:"varint,3,opt,name=size" json:"size,omitempty"`
+	Type             string            `protobuf:"bytes,4,opt,name=type" json:"type,omitempty"`
+	Iops             int64             `protobuf:"varint,5,opt,name=iops" json:"iops,omitempty"`
+	AvailabilityZone string            `protobuf:"bytes,6,opt,name=availability_zone" json:"availability_zone,omitempty"`
+	Status           string            `protobuf:"bytes,7,opt,name=status" json:"status,omitempty"`
+	Attachments      []*Attachment     `protobuf:"bytes,8,rep,name=attachments" json:"attachments,omitempty"`
+	Fields           map[string]string `protobuf:"bytes,9,rep,name=fields" json:"fields,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *Volume) Reset()         { *m = Volume{} }
+func (m *Volume) String() string { return proto.CompactTextString(m) }
+func (*Volume) ProtoMessage()    {}
+
+func (m *Volume) GetAttachments() []*Attachment {
+	if m != nil {
+		return m.Attachments
+	}
+	return nil
+}
+
+func (m *Volume) GetFields() map[string]string {
+	if m != nil {
+		return m.Fields
+	}
+	return nil
+}
+
+type Snapshot struct {
+	Id          string            `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+	Name        string            `protobuf:"bytes,2,opt,name=name" json:"name,omitempty"`
+	VolumeId    string            `protobuf:"bytes,3,opt,name=volume_id" json:"volume_id,omitempty"`
+	VolumeSize  int64             `protobuf:"varint,4,opt,name=volume_size" json:"volume_size,omitempty"`
+	StartTime   int64             `protobuf:"varint,5,opt,name=start_time" json:"start_time,omitempty"`
+	Description string            `protobuf:"bytes,6,opt,name=description" json:"description,omitempty"`
+	Status      string            `protobuf:"bytes,7,opt,name=status" json:"status,omitempty"`
+	Fields      map[string]string `protobuf:"bytes,8,rep,name=fields" json:"fields,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *Snapshot) Reset()         { *m = Snapshot{} }
+func (m *Snapshot) String() string { return proto.CompactTextString(m) }
+func (*Snapshot) ProtoMessage()    {}
+
+func (m *Snapshot) GetFields() map[string]string {
+	if m != nil {
+		return m.Fields
+	}
+	return nil
+}
+
+type Instance struct {
+	InstanceId   string            `protobuf:"bytes,1,opt,name=instance_id" json:"instance_id,omitempty"`
+	Name         string            `protobuf:"bytes,2,opt,name=name" json:"name,omitempty"`
+	ProviderName string            `protobuf:"bytes,3,opt,name=provider_name" json:"provider_name,omitempty"`
+	Region       string            `protobuf:"bytes,4,opt,name=region" json:"region,omitempty"`
+	Fields       map[string]string `protobuf:"bytes,5,rep,name=fields" json:"fields,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *Instance) Reset()         { *m = Instance{} }
+func (m *Instance) String() string { return proto.CompactTextString(m) }
+func (*Instance) ProtoMessage()    {}
+
+func (m *Instance) GetFields() map[string]string {
+	if m != nil {
+		return m.Fields
+	}
+	return nil
+}
+
+type InitRequest struct {
+	Config string `protobuf:"bytes,1,opt,name=config" json:"config,omitempty"`
+}
+
+func (m *InitRequest) Reset()         { *m = InitRequest{} }
+func (m *InitRequest) String() string { return proto.CompactTextString(m) }
+func (*InitRequest) ProtoMessage()    {}
+
+type InitResponse struct {
+}
+
+func (m *InitResponse) Reset()         { *m = InitResponse{} }
+func (m *InitResponse) String() string { return proto.CompactTextString(m) }
+func (*InitResponse) ProtoMessage()    {}
+
+type TypeRequest struct {
+}
+
+func (m *TypeRequest) Reset()         { *m = TypeRequest{} }
+func (m *TypeRequest) String() string { return proto.CompactTextString(m) }
+func (*TypeRequest) ProtoMessage()    {}
+
+type TypeResponse struct {
+	Type string `protobuf:"bytes,1,opt,name=type" json:"type,omitempty"`
+}
+
+func (m *TypeResponse) Reset()         { *m = TypeResponse{} }
+func (m *TypeResponse) String() string { return proto.CompactTextString(m) }
+func (*TypeResponse) ProtoMessage()    {}
+
+type NextDeviceInfoRequest struct {
+}
+
+func (m *NextDeviceInfoRequest) Reset()         { *m = NextDeviceInfoRequest{} }
+func (m *NextDeviceInfoRequest) String() string { return proto.CompactTextString(m) }
+func (*NextDeviceInfoRequest) ProtoMessage()    {}
+
+type NextDeviceInfoResponse struct {
+	Prefix  string `protobuf:"bytes,1,opt,name=prefix" json:"prefix,omitempty"`
+	Pattern string `protobuf:"bytes,2,opt,name=pattern" json:"pattern,omitempty"`
+	Ignore  bool   `protobuf:"varint,3,opt,name=ignore" json:"ignore,omitempty"`
+}
+
+func (m *NextDeviceInfoResponse) Reset()         { *m = NextDeviceInfoResponse{} }
+func (m *NextDeviceInfoResponse) String() string { return proto.CompactTextString(m) }
+func (*NextDeviceInfoResponse) ProtoMessage()    {}
+
+type InstanceInspectRequest struct {
+	InstanceId string            `protobuf:"bytes,1,opt,name=instance_id" json:"instance_id,omitempty"`
+	Opts       map[string]string `protobuf:"bytes,2,rep,name=opts" json:"opts,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *InstanceInspectRequest) Reset()         { *m = InstanceInspectRequest{} }
+func (m *InstanceInspectRequest) String() string { return proto.CompactTextString(m) }
+func (*InstanceInspectRequest) ProtoMessage()    {}
+
+func (m *InstanceInspectRequest) GetOpts() map[string]string {
+	if m != nil {
+		return m.Opts
+	}
+	return nil
+}
+
+type VolumesRequest struct {
+	InstanceId  string            `protobuf:"bytes,1,opt,name=instance_id" json:"instance_id,omitempty"`
+	Attachments bool              `protobuf:"varint,2,opt,name=attachments" json:"attachments,omitempty"`
+	Opts        map[string]string `protobuf:"bytes,3,rep,name=opts" json:"opts,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *VolumesRequest) Reset()         { *m = VolumesRequest{} }
+func (m *VolumesRequest) String() string { return proto.CompactTextString(m) }
+func (*VolumesRequest) ProtoMessage()    {}
+
+func (m *VolumesRequest) GetOpts() map[string]string {
+	if m != nil {
+		return m.Opts
+	}
+	return nil
+}
+
+type VolumesResponse struct {
+	Volumes []*Volume `protobuf:"bytes,1,rep,name=volumes" json:"volumes,omitempty"`
+}
+
+func (m *VolumesResponse) Reset()         { *m = VolumesResponse{} }
+func (m *VolumesResponse) String() string { return proto.CompactTextString(m) }
+func (*VolumesResponse) ProtoMessage()    {}
+
+func (m *VolumesResponse) GetVolumes() []*Volume {
+	if m != nil {
+		return m.Volumes
+	}
+	return nil
+}
+
+type VolumeInspectRequest struct {
+	InstanceId  string            `protobuf:"bytes,1,opt,name=instance_id" json:"instance_id,omitempty"`
+	VolumeId    string            `protobuf:"bytes,2,opt,name=volume_id" json:"volume_id,omitempty"`
+	Attachments bool              `protobuf:"varint,3,opt,name=attachments" json:"attachments,omitempty"`
+	Opts        map[string]string `protobuf:"bytes,4,rep,name=opts" json:"opts,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *VolumeInspectRequest) Reset()         { *m = VolumeInspectRequest{} }
+func (m *VolumeInspectRequest) String() string { return proto.CompactTextString(m) }
+func (*VolumeInspectRequest) ProtoMessage()    {}
+
+func (m *VolumeInspectRequest) GetOpts() map[string]string {
+	if m != nil {
+		return m.Opts
+	}
+	return nil
+}
+
+type VolumeCreateRequest struct {
+	InstanceId       string            `protobuf:"bytes,1,opt,name=instance_id" json:"instance_id,omitempty"`
+	Name             string            `protobuf:"bytes,2,opt,name=name" json:"name,omitempty"`
+	SnapshotId       string            `protobuf:"bytes,3,opt,name=snapshot_id" json:"snapshot_id,omitempty"`
+	AvailabilityZone string            `protobuf:"bytes,4,opt,name=availability_zone" json:"availability_zone,omitempty"`
+	Size             int64             `protobuf:"varint,5,opt,name=size" json:"size,omitempty"`
+	Type             string            `protobuf:"bytes,6,opt,name=type" json:"type,omitempty"`
+	Iops             int64             `protobuf:"varint,7,opt,name=iops" json:"iops,omitempty"`
+	Opts             map[string]string `protobuf:"bytes,8,rep,name=opts" json:"opts,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *VolumeCreateRequest) Reset()         { *m = VolumeCreateRequest{} }
+func (m *VolumeCreateRequest) String() string { return proto.CompactTextString(m) }
+func (*VolumeCreateRequest) ProtoMessage()    {}
+
+func (m *VolumeCreateRequest) GetOpts() map[string]string {
+	if m != nil {
+		return m.Opts
+	}
+	return nil
+}
+
+type VolumeCopyRequest struct {
+	InstanceId string            `protobuf:"bytes,1,opt,name=instance_id" json:"instance_id,omitempty"`
+	VolumeId   string            `protobuf:"bytes,2,opt,name=volume_id" json:"volume_id,omitempty"`
+	Name       string            `protobuf:"bytes,3,opt,name=name" json:"name,omitempty"`
+	Opts       map[string]string `protobuf:"bytes,4,rep,name=opts" json:"opts,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *VolumeCopyRequest) Reset()         { *m = VolumeCopyRequest{} }
+func (m *VolumeCopyRequest) String() string { return proto.CompactTextString(m) }
+func (*VolumeCopyRequest) ProtoMessage()    {}
+
+func (m *VolumeCopyRequest) GetOpts() map[string]string {
+	if m != nil {
+		return m.Opts
+	}
+	return nil
+}
+
+type VolumeSnapshotRequest struct {
+	InstanceId string            `protobuf:"bytes,1,opt,name=instance_id" json:"instance_id,omitempty"`
+	VolumeId   string            `protobuf:"bytes,2,opt,name=volume_id" json:"volume_id,omitempty"`
+	Name       string            `protobuf:"bytes,3,opt,name=name" json:"name,omitempty"`
+	Opts       map[string]string `protobuf:"bytes,4,rep,name=opts" json:"opts,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *VolumeSnapshotRequest) Reset()         { *m = VolumeSnapshotRequest{} }
+func (m *VolumeSnapshotRequest) String() string { return proto.CompactTextString(m) }
+func (*VolumeSnapshotRequest) ProtoMessage()    {}
+
+func (m *VolumeSnapshotRequest) GetOpts() map[string]string {
+	if m != nil {
+		return m.Opts
+	}
+	return nil
+}
+
+type VolumeRemoveRequest struct {
+	InstanceId string            `protobuf:"bytes,1,opt,name=instance_id" json:"instance_id,omitempty"`
+	VolumeId   string            `protobuf:"bytes,2,opt,name=volume_id" json:"volume_id,omitempty"`
+	Opts       map[string]string `protobuf:"bytes,3,rep,name=opts" json:"opts,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *VolumeRemoveRequest) Reset()         { *m = VolumeRemoveRequest{} }
+func (m *VolumeRemoveRequest) String() string { return proto.CompactTextString(m) }
+func (*VolumeRemoveRequest) ProtoMessage()    {}
+
+func (m *VolumeRemoveRequest) GetOpts() map[string]string {
+	if m != nil {
+		return m.Opts
+	}
+	return nil
+}
+
+type VolumeAttachRequest struct {
+	InstanceId string            `protobuf:"bytes,1,opt,name=instance_id" json:"instance_id,omitempty"`
+	VolumeId   string            `protobuf:"bytes,2,opt,name=volume_id" json:"volume_id,omitempty"`
+	NextDevice string            `protobuf:"bytes,3,opt,name=next_device" json:"next_device,omitempty"`
+	Force      bool              `protobuf:"varint,4,opt,name=force" json:"force,omitempty"`
+	Opts       map[string]string `protobuf:"bytes,5,rep,name=opts" json:"opts,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *VolumeAttachRequest) Reset()         { *m = VolumeAttachRequest{} }
+func (m *VolumeAttachRequest) String() string { return proto.CompactTextString(m) }
+func (*VolumeAttachRequest) ProtoMessage()    {}
+
+func (m *VolumeAttachRequest) GetOpts() map[string]string {
+	if m != nil {
+		return m.Opts
+	}
+	return nil
+}
+
+type VolumeAttachResponse struct {
+	Volume *Volume `protobuf:"bytes,1,opt,name=volume" json:"volume,omitempty"`
+	Token  string  `protobuf:"bytes,2,opt,name=token" json:"token,omitempty"`
+}
+
+func (m *VolumeAttachResponse) Reset()         { *m = VolumeAttachResponse{} }
+func (m *VolumeAttachResponse) String() string { return proto.CompactTextString(m) }
+func (*VolumeAttachResponse) ProtoMessage()    {}
+
+func (m *VolumeAttachResponse) GetVolume() *Volume {
+	if m != nil {
+		return m.Volume
+	}
+	return nil
+}
+
+type VolumeDetachRequest struct {
+	InstanceId string            `protobuf:"bytes,1,opt,name=instance_id" json:"instance_id,omitempty"`
+	VolumeId   string            `protobuf:"bytes,2,opt,name=volume_id" json:"volume_id,omitempty"`
+	Force      bool              `protobuf:"varint,3,opt,name=force" json:"force,omitempty"`
+	Opts       map[string]string `protobuf:"bytes,4,rep,name=opts" json:"opts,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *VolumeDetachRequest) Reset()         { *m = VolumeDetachRequest{} }
+func (m *VolumeDetachRequest) String() string { return proto.CompactTextString(m) }
+func (*VolumeDetachRequest) ProtoMessage()    {}
+
+func (m *VolumeDetachRequest) GetOpts() map[string]string {
+	if m != nil {
+		return m.Opts
+	}
+	return nil
+}
+
+type SnapshotsRequest struct {
+	InstanceId string            `protobuf:"bytes,1,opt,name=instance_id" json:"instance_id,omitempty"`
+	Opts       map[string]string `protobuf:"bytes,2,rep,name=opts" json:"opts,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *SnapshotsRequest) Reset()         { *m = SnapshotsRequest{} }
+func (m *SnapshotsRequest) String() string { return proto.CompactTextString(m) }
+func (*SnapshotsRequest) ProtoMessage()    {}
+
+func (m *SnapshotsRequest) GetOpts() map[string]string {
+	if m != nil {
+		return m.Opts
+	}
+	return nil
+}
+
+type SnapshotsResponse struct {
+	Snapshots []*Snapshot `protobuf:"bytes,1,rep,name=snapshots" json:"snapshots,omitempty"`
+}
+
+func (m *SnapshotsResponse) Reset()         { *m = SnapshotsResponse{} }
+func (m *SnapshotsResponse) String() string { return proto.CompactTextString(m) }
+func (*SnapshotsResponse) ProtoMessage()    {}
+
+func (m *SnapshotsResponse) GetSnapshots() []*Snapshot {
+	if m != nil {
+		return m.Snapshots
+	}
+	return nil
+}
+
+type SnapshotInspectRequest struct {
+	InstanceId string            `protobuf:"bytes,1,opt,name=instance_id" json:"instance_id,omitempty"`
+	SnapshotId string            `protobuf:"bytes,2,opt,name=snapshot_id" json:"snapshot_id,omitempty"`
+	Opts       map[string]string `protobuf:"bytes,3,rep,name=opts" json:"opts,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *SnapshotInspectRequest) Reset()         { *m = SnapshotInspectRequest{} }
+func (m *SnapshotInspectRequest) String() string { return proto.CompactTextString(m) }
+func (*SnapshotInspectRequest) ProtoMessage()    {}
+
+func (m *SnapshotInspectRequest) GetOpts() map[string]string {
+	if m != nil {
+		return m.Opts
+	}
+	return nil
+}
+
+type SnapshotCopyRequest struct {
+	InstanceId    string            `protobuf:"bytes,1,opt,name=instance_id" json:"instance_id,omitempty"`
+	SnapshotId    string            `protobuf:"bytes,2,opt,name=snapshot_id" json:"snapshot_id,omitempty"`
+	Name          string            `protobuf:"bytes,3,opt,name=name" json:"name,omitempty"`
+	DestinationId string            `protobuf:"bytes,4,opt,name=destination_id" json:"destination_id,omitempty"`
+	Opts          map[string]string `protobuf:"bytes,5,rep,name=opts" json:"opts,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *SnapshotCopyRequest) Reset()         { *m = SnapshotCopyRequest{} }
+func (m *SnapshotCopyRequest) String() string { return proto.CompactTextString(m) }
+func (*SnapshotCopyRequest) ProtoMessage()    {}
+
+func (m *SnapshotCopyRequest) GetOpts() map[string]string {
+	if m != nil {
+		return m.Opts
+	}
+	return nil
+}
+
+type SnapshotRemoveRequest struct {
+	InstanceId string            `protobuf:"bytes,1,opt,name=instance_id" json:"instance_id,omitempty"`
+	SnapshotId string            `protobuf:"bytes,2,opt,name=snapshot_id" json:"snapshot_id,omitempty"`
+	Opts       map[string]string `protobuf:"bytes,3,rep,name=opts" json:"opts,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *SnapshotRemoveRequest) Reset()         { *m = SnapshotRemoveRequest{} }
+func (m *SnapshotRemoveRequest) String() string { return proto.CompactTextString(m) }
+func (*SnapshotRemoveRequest) ProtoMessage()    {}
+
+func (m *SnapshotRemoveRequest) GetOpts() map[string]string {
+	if m != nil {
+		return m.Opts
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterType((*Empty)(nil), "externalpb.Empty")
+	proto.RegisterType((*Attachment)(nil), "externalpb.Attachment")
+	proto.RegisterType((*Volume)(nil), "externalpb.Volume")
+	proto.RegisterType((*Snapshot)(nil), "externalpb.Snapshot")
+	proto.RegisterType((*Instance)(nil), "externalpb.Instance")
+	proto.RegisterType((*InitRequest)(nil), "externalpb.InitRequest")
+	proto.RegisterType((*InitResponse)(nil), "externalpb.InitResponse")
+	proto.RegisterType((*TypeRequest)(nil), "externalpb.TypeRequest")
+	proto.RegisterType((*TypeResponse)(nil), "externalpb.TypeResponse")
+	proto.RegisterType((*NextDeviceInfoRequest)(nil), "externalpb.NextDeviceInfoRequest")
+	proto.RegisterType((*NextDeviceInfoResponse)(nil), "externalpb.NextDeviceInfoResponse")
+	proto.RegisterType((*InstanceInspectRequest)(nil), "externalpb.InstanceInspectRequest")
+	proto.RegisterType((*VolumesRequest)(nil), "externalpb.VolumesRequest")
+	proto.RegisterType((*VolumesResponse)(nil), "externalpb.VolumesResponse")
+	proto.RegisterType((*VolumeInspectRequest)(nil), "externalpb.VolumeInspectRequest")
+	proto.RegisterType((*VolumeCreateRequest)(nil), "externalpb.VolumeCreateRequest")
+	proto.RegisterType((*VolumeCopyRequest)(nil), "externalpb.VolumeCopyRequest")
+	proto.RegisterType((*VolumeSnapshotRequest)(nil), "externalpb.VolumeSnapshotRequest")
+	proto.RegisterType((*VolumeRemoveRequest)(nil), "externalpb.VolumeRemoveRequest")
+	proto.RegisterType((*VolumeAttachRequest)(nil), "externalpb.VolumeAttachRequest")
+	proto.RegisterType((*VolumeAttachResponse)(nil), "externalpb.VolumeAttachResponse")
+	proto.RegisterType((*VolumeDetachRequest)(nil), "externalpb.VolumeDetachRequest")
+	proto.RegisterType((*SnapshotsRequest)(nil), "externalpb.SnapshotsRequest")
+	proto.RegisterType((*SnapshotsResponse)(nil), "externalpb.SnapshotsResponse")
+	proto.RegisterType((*SnapshotInspectRequest)(nil), "externalpb.SnapshotInspectRequest")
+	proto.RegisterType((*SnapshotCopyRequest)(nil), "externalpb.SnapshotCopyRequest")
+	proto.RegisterType((*SnapshotRemoveRequest)(nil), "externalpb.SnapshotRemoveRequest")
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// Client API for Driver service
+
+type DriverClient interface {
+	Init(ctx context.Context, in *InitRequest, opts ...grpc.CallOption) (*InitResponse, error)
+	Type(ctx context.Context, in *TypeRequest, opts ...grpc.CallOption) (*TypeResponse, error)
+	NextDeviceInfo(ctx context.Context, in *NextDeviceInfoRequest, opts ...grpc.CallOption) (*NextDeviceInfoResponse, error)
+	InstanceInspect(ctx context.Context, in *InstanceInspectRequest, opts ...grpc.CallOption) (*Instance, error)
+	Volumes(ctx context.Context, in *VolumesRequest, opts ...grpc.CallOption) (*VolumesResponse, error)
+	VolumeInspect(ctx context.Context, in *VolumeInspectRequest, opts ...grpc.CallOption) (*Volume, error)
+	VolumeCreate(ctx context.Context, in *VolumeCreateRequest, opts ...grpc.CallOption) (*Volume, error)
+	VolumeCopy(ctx context.Context, in *VolumeCopyRequest, opts ...grpc.CallOption) (*Volume, error)
+	VolumeSnapshot(ctx context.Context, in *VolumeSnapshotRequest, opts ...grpc.CallOption) (*Snapshot, error)
+	VolumeRemove(ctx context.Context, in *VolumeRemoveRequest, opts ...grpc.CallOption) (*Empty, error)
+	VolumeAttach(ctx context.Context, in *VolumeAttachRequest, opts ...grpc.CallOption) (*VolumeAttachResponse, error)
+	VolumeDetach(ctx context.Context, in *VolumeDetachRequest, opts ...grpc.CallOption) (*Volume, error)
+	Snapshots(ctx context.Context, in *SnapshotsRequest, opts ...grpc.CallOption) (*SnapshotsResponse, error)
+	SnapshotInspect(ctx context.Context, in *SnapshotInspectRequest, opts ...grpc.CallOption) (*Snapshot, error)
+	SnapshotCopy(ctx context.Context, in *SnapshotCopyRequest, opts ...grpc.CallOption) (*Snapshot, error)
+	SnapshotRemove(ctx context.Context, in *SnapshotRemoveRequest, opts ...grpc.CallOption) (*Empty, error)
+}
+
+type driverClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewDriverClient(cc *grpc.ClientConn) DriverClient {
+	return &driverClient{cc}
+}
+
+func (c *driverClient) Init(ctx context.Context, in *InitRequest, opts ...grpc.CallOption) (*InitResponse, error) {
+	out := new(InitResponse)
+	err := grpc.Invoke(ctx, "/externalpb.Driver/Init", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *driverClient) Type(ctx context.Context, in *TypeRequest, opts ...grpc.CallOption) (*TypeResponse, error) {
+	out := new(TypeResponse)
+	err := grpc.Invoke(ctx, "/externalpb.Driver/Type", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *driverClient) NextDeviceInfo(ctx context.Context, in *NextDeviceInfoRequest, opts ...grpc.CallOption) (*NextDeviceInfoResponse, error) {
+	out := new(NextDeviceInfoResponse)
+	err := grpc.Invoke(ctx, "/externalpb.Driver/NextDeviceInfo", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *driverClient) InstanceInspect(ctx context.Context, in *InstanceInspectRequest, opts ...grpc.CallOption) (*Instance, error) {
+	out := new(Instance)
+	err := grpc.Invoke(ctx, "/externalpb.Driver/InstanceInspect", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *driverClient) Volumes(ctx context.Context, in *VolumesRequest, opts ...grpc.CallOption) (*VolumesResponse, error) {
+	out := new(VolumesResponse)
+	err := grpc.Invoke(ctx, "/externalpb.Driver/Volumes", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *driverClient) VolumeInspect(ctx context.Context, in *VolumeInspectRequest, opts ...grpc.CallOption) (*Volume, error) {
+	out := new(Volume)
+	err := grpc.Invoke(ctx, "/externalpb.Driver/VolumeInspect", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *driverClient) VolumeCreate(ctx context.Context, in *VolumeCreateRequest, opts ...grpc.CallOption) (*Volume, error) {
+	out := new(Volume)
+	err := grpc.Invoke(ctx, "/externalpb.Driver/VolumeCreate", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *driverClient) VolumeCopy(ctx context.Context, in *VolumeCopyRequest, opts ...grpc.CallOption) (*Volume, error) {
+	out := new(Volume)
+	err := grpc.Invoke(ctx, "/externalpb.Driver/VolumeCopy", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *driverClient) VolumeSnapshot(ctx context.Context, in *VolumeSnapshotRequest, opts ...grpc.CallOption) (*Snapshot, error) {
+	out := new(Snapshot)
+	err := grpc.Invoke(ctx, "/externalpb.Driver/VolumeSnapshot", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *driverClient) VolumeRemove(ctx context.Context, in *VolumeRemoveRequest, opts ...grpc.CallOption) (*Empty, error) {
+	out := new(Empty)
+	err := grpc.Invoke(ctx, "/externalpb.Driver/VolumeRemove", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *driverClient) VolumeAttach(ctx context.Context, in *VolumeAttachRequest, opts ...grpc.CallOption) (*VolumeAttachResponse, error) {
+	out := new(VolumeAttachResponse)
+	err := grpc.Invoke(ctx, "/externalpb.Driver/VolumeAttach", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *driverClient) VolumeDetach(ctx context.Context, in *VolumeDetachRequest, opts ...grpc.CallOption) (*Volume, error) {
+	out := new(Volume)
+	err := grpc.Invoke(ctx, "/externalpb.Driver/VolumeDetach", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *driverClient) Snapshots(ctx context.Context, in *SnapshotsRequest, opts ...grpc.CallOption) (*SnapshotsResponse, error) {
+	out := new(SnapshotsResponse)
+	err := grpc.Invoke(ctx, "/externalpb.Driver/Snapshots", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *driverClient) SnapshotInspect(ctx context.Context, in *SnapshotInspectRequest, opts ...grpc.CallOption) (*Snapshot, error) {
+	out := new(Snapshot)
+	err := grpc.Invoke(ctx, "/externalpb.Driver/SnapshotInspect", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *driverClient) SnapshotCopy(ctx context.Context, in *SnapshotCopyRequest, opts ...grpc.CallOption) (*Snapshot, error) {
+	out := new(Snapshot)
+	err := grpc.Invoke(ctx, "/externalpb.Driver/SnapshotCopy", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *driverClient) SnapshotRemove(ctx context.Context, in *SnapshotRemoveRequest, opts ...grpc.CallOption) (*Empty, error) {
+	out := new(Empty)
+	err := grpc.Invoke(ctx, "/externalpb.Driver/SnapshotRemove", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Server API for Driver service
+
+type DriverServer interface {
+	Init(context.Context, *InitRequest) (*InitResponse, error)
+	Type(context.Context, *TypeRequest) (*TypeResponse, error)
+	NextDeviceInfo(context.Context, *NextDeviceInfoRequest) (*NextDeviceInfoResponse, error)
+	InstanceInspect(context.Context, *InstanceInspectRequest) (*Instance, error)
+	Volumes(context.Context, *VolumesRequest) (*VolumesResponse, error)
+	VolumeInspect(context.Context, *VolumeInspectRequest) (*Volume, error)
+	VolumeCreate(context.Context, *VolumeCreateRequest) (*Volume, error)
+	VolumeCopy(context.Context, *VolumeCopyRequest) (*Volume, error)
+	VolumeSnapshot(context.Context, *VolumeSnapshotRequest) (*Snapshot, error)
+	VolumeRemove(context.Context, *VolumeRemoveRequest) (*Empty, error)
+	VolumeAttach(context.Context, *VolumeAttachRequest) (*VolumeAttachResponse, error)
+	VolumeDetach(context.Context, *VolumeDetachRequest) (*Volume, error)
+	Snapshots(context.Context, *SnapshotsRequest) (*SnapshotsResponse, error)
+	SnapshotInspect(context.Context, *SnapshotInspectRequest) (*Snapshot, error)
+	SnapshotCopy(context.Context, *SnapshotCopyRequest) (*Snapshot, error)
+	SnapshotRemove(context.Context, *SnapshotRemoveRequest) (*Empty, error)
+}
+
+func RegisterDriverServer(s *grpc.Server, srv DriverServer) {
+	s.RegisterService(&_Driver_serviceDesc, srv)
+}
+
+func _Driver_Init_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InitRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DriverServer).Init(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/externalpb.Driver/Init",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DriverServer).Init(ctx, req.(*InitRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Driver_Type_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TypeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DriverServer).Type(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/externalpb.Driver/Type",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DriverServer).Type(ctx, req.(*TypeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Driver_NextDeviceInfo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(NextDeviceInfoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DriverServer).NextDeviceInfo(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/externalpb.Driver/NextDeviceInfo",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DriverServer).NextDeviceInfo(ctx, req.(*NextDeviceInfoRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Driver_InstanceInspect_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InstanceInspectRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DriverServer).InstanceInspect(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/externalpb.Driver/InstanceInspect",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DriverServer).InstanceInspect(ctx, req.(*InstanceInspectRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Driver_Volumes_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VolumesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DriverServer).Volumes(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/externalpb.Driver/Volumes",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DriverServer).Volumes(ctx, req.(*VolumesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Driver_VolumeInspect_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VolumeInspectRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DriverServer).VolumeInspect(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/externalpb.Driver/VolumeInspect",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DriverServer).VolumeInspect(ctx, req.(*VolumeInspectRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Driver_VolumeCreate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VolumeCreateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DriverServer).VolumeCreate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/externalpb.Driver/VolumeCreate",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DriverServer).VolumeCreate(ctx, req.(*VolumeCreateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Driver_VolumeCopy_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VolumeCopyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DriverServer).VolumeCopy(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/externalpb.Driver/VolumeCopy",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DriverServer).VolumeCopy(ctx, req.(*VolumeCopyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Driver_VolumeSnapshot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VolumeSnapshotRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DriverServer).VolumeSnapshot(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/externalpb.Driver/VolumeSnapshot",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DriverServer).VolumeSnapshot(ctx, req.(*VolumeSnapshotRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Driver_VolumeRemove_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VolumeRemoveRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DriverServer).VolumeRemove(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/externalpb.Driver/VolumeRemove",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DriverServer).VolumeRemove(ctx, req.(*VolumeRemoveRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Driver_VolumeAttach_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VolumeAttachRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DriverServer).VolumeAttach(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/externalpb.Driver/VolumeAttach",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DriverServer).VolumeAttach(ctx, req.(*VolumeAttachRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Driver_VolumeDetach_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VolumeDetachRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DriverServer).VolumeDetach(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/externalpb.Driver/VolumeDetach",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DriverServer).VolumeDetach(ctx, req.(*VolumeDetachRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Driver_Snapshots_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SnapshotsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DriverServer).Snapshots(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/externalpb.Driver/Snapshots",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DriverServer).Snapshots(ctx, req.(*SnapshotsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Driver_SnapshotInspect_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SnapshotInspectRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DriverServer).SnapshotInspect(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/externalpb.Driver/SnapshotInspect",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DriverServer).SnapshotInspect(ctx, req.(*SnapshotInspectRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Driver_SnapshotCopy_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SnapshotCopyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DriverServer).SnapshotCopy(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/externalpb.Driver/SnapshotCopy",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DriverServer).SnapshotCopy(ctx, req.(*SnapshotCopyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Driver_SnapshotRemove_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SnapshotRemoveRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DriverServer).SnapshotRemove(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/externalpb.Driver/SnapshotRemove",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DriverServer).SnapshotRemove(ctx, req.(*SnapshotRemoveRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _Driver_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "externalpb.Driver",
+	HandlerType: (*DriverServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Init",
+			Handler:    _Driver_Init_Handler,
+		},
+		{
+			MethodName: "Type",
+			Handler:    _Driver_Type_Handler,
+		},
+		{
+			MethodName: "NextDeviceInfo",
+			Handler:    _Driver_NextDeviceInfo_Handler,
+		},
+		{
+			MethodName: "InstanceInspect",
+			Handler:    _Driver_InstanceInspect_Handler,
+		},
+		{
+			MethodName: "Volumes",
+			Handler:    _Driver_Volumes_Handler,
+		},
+		{
+			MethodName: "VolumeInspect",
+			Handler:    _Driver_VolumeInspect_Handler,
+		},
+		{
+			MethodName: "VolumeCreate",
+			Handler:    _Driver_VolumeCreate_Handler,
+		},
+		{
+			MethodName: "VolumeCopy",
+			Handler:    _Driver_VolumeCopy_Handler,
+		},
+		{
+			MethodName: "VolumeSnapshot",
+			Handler:    _Driver_VolumeSnapshot_Handler,
+		},
+		{
+			MethodName: "VolumeRemove",
+			Handler:    _Driver_VolumeRemove_Handler,
+		},
+		{
+			MethodName: "VolumeAttach",
+			Handler:    _Driver_VolumeAttach_Handler,
+		},
+		{
+			MethodName: "VolumeDetach",
+			Handler:    _Driver_VolumeDetach_Handler,
+		},
+		{
+			MethodName: "Snapshots",
+			Handler:    _Driver_Snapshots_Handler,
+		},
+		{
+			MethodName: "SnapshotInspect",
+			Handler:    _Driver_SnapshotInspect_Handler,
+		},
+		{
+			MethodName: "SnapshotCopy",
+			Handler:    _Driver_SnapshotCopy_Handler,
+		},
+		{
+			MethodName: "SnapshotRemove",
+			Handler:    _Driver_SnapshotRemove_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "external.proto",
+}
@@ -0,0 +1,244 @@
+package external
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	apitypes "github.com/emccode/libstorage/api/types"
+	xctx "golang.org/x/net/context"
+	"google.golang.org/grpc"
+
+	"github.com/emccode/rexray/core/external/externalpb"
+	"github.com/emccode/rexray/util"
+)
+
+const (
+	minRestartDelay = time.Second
+	maxRestartDelay = time.Minute
+
+	// stopTimeout is how long a driver process may take to exit after it
+	// is asked to before it is killed.
+	stopTimeout = 10 * time.Second
+)
+
+// process is a driver process that REX-Ray started. It is supervised, and
+// started again whenever it exits until REX-Ray shuts down.
+type process struct {
+	sync.Mutex
+	name    string
+	path    string
+	args    []string
+	sock    string
+	timeout time.Duration
+	conn    *grpc.ClientConn
+	client  externalpb.DriverClient
+
+	cmd     *exec.Cmd
+	started time.Time
+	stopped bool
+	init    *externalpb.InitRequest
+
+	// done is closed when the process is no longer supervised.
+	done chan struct{}
+}
+
+var (
+	procs    = map[string]*process{}
+	procsMtx sync.Mutex
+)
+
+// startProcess returns the process of the driver executable with the
+// service's arguments and configuration, starting it if it is not running.
+// The services whose drivers have the same executable, arguments, and
+// configuration share a process, so that a process is not started again
+// when the libStorage server is restarted.
+func startProcess(
+	ctx apitypes.Context,
+	config gofig.Config,
+	path string,
+	buf []byte) (*process, error) {
+
+	args := strings.Fields(config.GetString("external.args"))
+	key := strings.Join(append([]string{path, string(buf)}, args...), "\x00")
+
+	procsMtx.Lock()
+	defer procsMtx.Unlock()
+
+	if p, ok := procs[key]; ok {
+		return p, nil
+	}
+
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	sum := sha256.Sum256([]byte(key))
+	sock := fmt.Sprintf("external-%s-%x.sock", name, sum[:4])
+	p := &process{
+		name:    name,
+		path:    path,
+		args:    args,
+		sock:    util.RunFilePath(sock),
+		timeout: startTimeout(config),
+		done:    make(chan struct{}),
+	}
+	if err := p.start(); err != nil {
+		return nil, err
+	}
+	conn, err := dial(config, "unix://"+p.sock)
+	if err != nil {
+		p.cmd.Process.Kill()
+		p.cmd.Wait()
+		return nil, err
+	}
+	p.conn, p.client = conn, externalpb.NewDriverClient(conn)
+
+	procs[key] = p
+	go p.supervise(ctx)
+	return p, nil
+}
+
+// start starts the process. It must be called with the process locked, or
+// before the process is supervised.
+func (p *process) start() error {
+	os.Remove(p.sock)
+	cmd := exec.Command(p.path, p.args...)
+	cmd.Env = append(os.Environ(), EnvSocket+"="+p.sock)
+	cmd.Stdout = log.StandardLogger().Writer()
+	cmd.Stderr = log.StandardLogger().Writer()
+	cmd.SysProcAttr = sysProcAttr()
+	if err := cmd.Start(); err != nil {
+		return goof.WithFieldE("path", p.path,
+			"error starting external driver", err)
+	}
+	p.cmd, p.started = cmd, time.Now()
+	return nil
+}
+
+// setInit records the request with which the process was last initialized,
+// which it is sent again when it is restarted.
+func (p *process) setInit(req *externalpb.InitRequest) {
+	p.Lock()
+	defer p.Unlock()
+	p.init = req
+}
+
+// supervise waits for the process to exit and starts it again, until it is
+// stopped. The delay before a restart doubles, up to a minute, while the
+// process keeps exiting soon after it starts.
+func (p *process) supervise(ctx apitypes.Context) {
+	defer close(p.done)
+
+	delay := minRestartDelay
+	for {
+		p.Lock()
+		cmd, started := p.cmd, p.started
+		p.Unlock()
+
+		err := cmd.Wait()
+		fields := map[string]interface{}{
+			"path": p.path,
+			"pid":  cmd.Process.Pid,
+		}
+		if p.isStopped() {
+			ctx.WithFields(fields).Info("external driver stopped")
+			return
+		}
+		ctx.WithFields(fields).WithError(err).Error(
+			"external driver exited; restarting")
+		if time.Since(started) > maxRestartDelay {
+			delay = minRestartDelay
+		}
+
+		for {
+			time.Sleep(delay)
+			if delay *= 2; delay > maxRestartDelay {
+				delay = maxRestartDelay
+			}
+			p.Lock()
+			if p.stopped {
+				p.Unlock()
+				return
+			}
+			err := p.start()
+			p.Unlock()
+			if err == nil {
+				break
+			}
+			ctx.WithError(err).Error("error restarting external driver")
+		}
+
+		if err := p.reinit(); err != nil {
+			ctx.WithFields(fields).WithError(err).Error(
+				"error initializing restarted external driver")
+			continue
+		}
+		ctx.WithField("path", p.path).Info("restarted external driver")
+	}
+}
+
+// reinit sends a restarted process the request with which it was last
+// initialized, once it accepts connections.
+func (p *process) reinit() error {
+	p.Lock()
+	req := p.init
+	p.Unlock()
+	if req == nil {
+		return nil
+	}
+	c, cancel := xctx.WithTimeout(xctx.Background(), p.timeout)
+	defer cancel()
+	_, err := p.client.Init(c, req, grpc.FailFast(false))
+	return err
+}
+
+func (p *process) isStopped() bool {
+	p.Lock()
+	defer p.Unlock()
+	return p.stopped
+}
+
+// stop asks the process to exit, and kills it if it has not exited after
+// the stop timeout.
+func (p *process) stop() {
+	p.Lock()
+	p.stopped = true
+	cmd := p.cmd
+	p.Unlock()
+
+	p.conn.Close()
+	cmd.Process.Signal(syscall.SIGTERM)
+	select {
+	case <-p.done:
+		return
+	case <-time.After(stopTimeout):
+	}
+	cmd.Process.Kill()
+	<-p.done
+}
+
+// Shutdown stops the driver processes that REX-Ray started. Each process is
+// sent SIGTERM, and is killed if it has not exited ten seconds later.
+func Shutdown() {
+	procsMtx.Lock()
+	all := procs
+	procs = map[string]*process{}
+	procsMtx.Unlock()
+
+	var wg sync.WaitGroup
+	for _, p := range all {
+		wg.Add(1)
+		go func(p *process) {
+			defer wg.Done()
+			p.stop()
+		}(p)
+	}
+	wg.Wait()
+}
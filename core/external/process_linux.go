@@ -0,0 +1,10 @@
+package external
+
+import "syscall"
+
+// sysProcAttr returns the attributes of a driver process, which is sent
+// SIGTERM if REX-Ray exits without stopping it, for example because it
+// crashed.
+func sysProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Pdeathsig: syscall.SIGTERM}
+}
@@ -0,0 +1,9 @@
+// +build !linux
+
+package external
+
+import "syscall"
+
+func sysProcAttr() *syscall.SysProcAttr {
+	return nil
+}
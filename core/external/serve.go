@@ -0,0 +1,291 @@
+package external
+
+import (
+	"net"
+	"os"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	"github.com/emccode/libstorage/api/context"
+	apitypes "github.com/emccode/libstorage/api/types"
+	xctx "golang.org/x/net/context"
+	"google.golang.org/grpc"
+
+	"github.com/emccode/rexray/core/external/externalpb"
+)
+
+// Serve serves a libStorage storage driver over the external driver contract
+// on the socket named by the REXRAY_EXTERNAL_DRIVER_SOCKET environment
+// variable. It is called from the main function of an external driver
+// process and returns when the listener fails.
+func Serve(d apitypes.StorageDriver) error {
+	sock := os.Getenv(EnvSocket)
+	if sock == "" {
+		return goof.WithField("env", EnvSocket, "missing socket path")
+	}
+	os.Remove(sock)
+
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(sock)
+
+	s := grpc.NewServer()
+	externalpb.RegisterDriverServer(s, &server{d: d})
+	return s.Serve(l)
+}
+
+type server struct {
+	d apitypes.StorageDriver
+}
+
+// ctx returns a libStorage context for an operation on behalf of the
+// instance with the provided ID.
+func (s *server) ctx(iid string) apitypes.Context {
+	ctx := context.Background()
+	if iid != "" {
+		ctx = ctx.WithValue(context.InstanceIDKey,
+			&apitypes.InstanceID{ID: iid, Driver: s.d.Name()})
+	}
+	return ctx
+}
+
+func (s *server) Init(
+	ctx xctx.Context,
+	req *externalpb.InitRequest) (*externalpb.InitResponse, error) {
+
+	config, err := gofig.FromJSON(req.Config)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.d.Init(s.ctx(""), config); err != nil {
+		return nil, err
+	}
+	return &externalpb.InitResponse{}, nil
+}
+
+func (s *server) Type(
+	ctx xctx.Context,
+	req *externalpb.TypeRequest) (*externalpb.TypeResponse, error) {
+
+	t, err := s.d.Type(s.ctx(""))
+	if err != nil {
+		return nil, err
+	}
+	return &externalpb.TypeResponse{Type: string(t)}, nil
+}
+
+func (s *server) NextDeviceInfo(
+	ctx xctx.Context,
+	req *externalpb.NextDeviceInfoRequest) (
+	*externalpb.NextDeviceInfoResponse, error) {
+
+	info, err := s.d.NextDeviceInfo(s.ctx(""))
+	if err != nil {
+		return nil, err
+	}
+	res := &externalpb.NextDeviceInfoResponse{}
+	if info != nil {
+		res.Prefix = info.Prefix
+		res.Pattern = info.Pattern
+		res.Ignore = info.Ignore
+	}
+	return res, nil
+}
+
+func (s *server) InstanceInspect(
+	ctx xctx.Context,
+	req *externalpb.InstanceInspectRequest) (*externalpb.Instance, error) {
+
+	i, err := s.d.InstanceInspect(s.ctx(req.InstanceId), fromOpts(req.Opts))
+	if err != nil {
+		return nil, err
+	}
+	return toInstance(i), nil
+}
+
+func (s *server) Volumes(
+	ctx xctx.Context,
+	req *externalpb.VolumesRequest) (*externalpb.VolumesResponse, error) {
+
+	vols, err := s.d.Volumes(s.ctx(req.InstanceId), &apitypes.VolumesOpts{
+		Attachments: req.Attachments,
+		Opts:        fromOpts(req.Opts),
+	})
+	if err != nil {
+		return nil, err
+	}
+	res := &externalpb.VolumesResponse{}
+	for _, v := range vols {
+		res.Volumes = append(res.Volumes, toVolume(v))
+	}
+	return res, nil
+}
+
+func (s *server) VolumeInspect(
+	ctx xctx.Context,
+	req *externalpb.VolumeInspectRequest) (*externalpb.Volume, error) {
+
+	v, err := s.d.VolumeInspect(
+		s.ctx(req.InstanceId), req.VolumeId, &apitypes.VolumeInspectOpts{
+			Attachments: req.Attachments,
+			Opts:        fromOpts(req.Opts),
+		})
+	if err != nil {
+		return nil, err
+	}
+	return toVolume(v), nil
+}
+
+func (s *server) VolumeCreate(
+	ctx xctx.Context,
+	req *externalpb.VolumeCreateRequest) (*externalpb.Volume, error) {
+
+	opts := &apitypes.VolumeCreateOpts{
+		AvailabilityZone: &req.AvailabilityZone,
+		Size:             &req.Size,
+		Type:             &req.Type,
+		IOPS:             &req.Iops,
+		Opts:             fromOpts(req.Opts),
+	}
+
+	var (
+		v   *apitypes.Volume
+		err error
+	)
+	if req.SnapshotId != "" {
+		v, err = s.d.VolumeCreateFromSnapshot(
+			s.ctx(req.InstanceId), req.SnapshotId, req.Name, opts)
+	} else {
+		v, err = s.d.VolumeCreate(s.ctx(req.InstanceId), req.Name, opts)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return toVolume(v), nil
+}
+
+func (s *server) VolumeCopy(
+	ctx xctx.Context,
+	req *externalpb.VolumeCopyRequest) (*externalpb.Volume, error) {
+
+	v, err := s.d.VolumeCopy(
+		s.ctx(req.InstanceId), req.VolumeId, req.Name, fromOpts(req.Opts))
+	if err != nil {
+		return nil, err
+	}
+	return toVolume(v), nil
+}
+
+func (s *server) VolumeSnapshot(
+	ctx xctx.Context,
+	req *externalpb.VolumeSnapshotRequest) (*externalpb.Snapshot, error) {
+
+	snap, err := s.d.VolumeSnapshot(
+		s.ctx(req.InstanceId), req.VolumeId, req.Name, fromOpts(req.Opts))
+	if err != nil {
+		return nil, err
+	}
+	return toSnapshot(snap), nil
+}
+
+func (s *server) VolumeRemove(
+	ctx xctx.Context,
+	req *externalpb.VolumeRemoveRequest) (*externalpb.Empty, error) {
+
+	if err := s.d.VolumeRemove(
+		s.ctx(req.InstanceId), req.VolumeId, fromOpts(req.Opts)); err != nil {
+		return nil, err
+	}
+	return &externalpb.Empty{}, nil
+}
+
+func (s *server) VolumeAttach(
+	ctx xctx.Context,
+	req *externalpb.VolumeAttachRequest) (
+	*externalpb.VolumeAttachResponse, error) {
+
+	opts := &apitypes.VolumeAttachOpts{
+		Force: req.Force,
+		Opts:  fromOpts(req.Opts),
+	}
+	if req.NextDevice != "" {
+		opts.NextDevice = &req.NextDevice
+	}
+	v, token, err := s.d.VolumeAttach(s.ctx(req.InstanceId), req.VolumeId, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &externalpb.VolumeAttachResponse{
+		Volume: toVolume(v),
+		Token:  token,
+	}, nil
+}
+
+func (s *server) VolumeDetach(
+	ctx xctx.Context,
+	req *externalpb.VolumeDetachRequest) (*externalpb.Volume, error) {
+
+	v, err := s.d.VolumeDetach(
+		s.ctx(req.InstanceId), req.VolumeId, &apitypes.VolumeDetachOpts{
+			Force: req.Force,
+			Opts:  fromOpts(req.Opts),
+		})
+	if err != nil {
+		return nil, err
+	}
+	return toVolume(v), nil
+}
+
+func (s *server) Snapshots(
+	ctx xctx.Context,
+	req *externalpb.SnapshotsRequest) (*externalpb.SnapshotsResponse, error) {
+
+	snaps, err := s.d.Snapshots(s.ctx(req.InstanceId), fromOpts(req.Opts))
+	if err != nil {
+		return nil, err
+	}
+	res := &externalpb.SnapshotsResponse{}
+	for _, snap := range snaps {
+		res.Snapshots = append(res.Snapshots, toSnapshot(snap))
+	}
+	return res, nil
+}
+
+func (s *server) SnapshotInspect(
+	ctx xctx.Context,
+	req *externalpb.SnapshotInspectRequest) (*externalpb.Snapshot, error) {
+
+	snap, err := s.d.SnapshotInspect(
+		s.ctx(req.InstanceId), req.SnapshotId, fromOpts(req.Opts))
+	if err != nil {
+		return nil, err
+	}
+	return toSnapshot(snap), nil
+}
+
+func (s *server) SnapshotCopy(
+	ctx xctx.Context,
+	req *externalpb.SnapshotCopyRequest) (*externalpb.Snapshot, error) {
+
+	snap, err := s.d.SnapshotCopy(
+		s.ctx(req.InstanceId),
+		req.SnapshotId, req.Name, req.DestinationId,
+		fromOpts(req.Opts))
+	if err != nil {
+		return nil, err
+	}
+	return toSnapshot(snap), nil
+}
+
+func (s *server) SnapshotRemove(
+	ctx xctx.Context,
+	req *externalpb.SnapshotRemoveRequest) (*externalpb.Empty, error) {
+
+	if err := s.d.SnapshotRemove(
+		s.ctx(req.InstanceId), req.SnapshotId, fromOpts(req.Opts)); err != nil {
+		return nil, err
+	}
+	return &externalpb.Empty{}, nil
+}
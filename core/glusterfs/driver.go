@@ -0,0 +1,575 @@
+package glusterfs
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	"github.com/emccode/libstorage/api/context"
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/util"
+)
+
+const gib = 1024 * 1024 * 1024
+
+type driver struct {
+	config    gofig.Config
+	gluster   *gluster
+	mode      string
+	servers   []string
+	brickRoot string
+	parent    string
+}
+
+func newDriver() apitypes.StorageDriver {
+	return &driver{}
+}
+
+func (d *driver) Name() string {
+	return Name
+}
+
+func (d *driver) Init(ctx apitypes.Context, config gofig.Config) error {
+	d.config = config
+	d.gluster = newGluster(config)
+	d.servers = servers(config)
+	d.brickRoot = config.GetString("glusterfs.brickRoot")
+	if d.brickRoot == "" {
+		d.brickRoot = defaultBrickRoot
+	}
+	d.parent = config.GetString("glusterfs.parentVolume")
+
+	switch d.mode = config.GetString("glusterfs.mode"); d.mode {
+	case "":
+		d.mode = ModeVolume
+	case ModeVolume:
+	case ModeSubdir:
+		if d.parent == "" {
+			return goof.New("glusterfs subdir mode requires " +
+				"glusterfs.parentVolume")
+		}
+	default:
+		return goof.WithField("mode", d.mode, "invalid glusterfs mode")
+	}
+	if len(d.servers) == 0 {
+		return goof.New("glusterfs requires glusterfs.servers")
+	}
+
+	ctx.WithFields(map[string]interface{}{
+		"mode":         d.mode,
+		"servers":      d.servers,
+		"parentVolume": d.parent,
+	}).Info("initialized glusterfs driver")
+	return nil
+}
+
+// instanceID returns the ID of the instance on whose behalf an operation is
+// performed.
+func instanceID(ctx apitypes.Context) string {
+	if iid, ok := ctx.Value(context.InstanceIDKey).(*apitypes.InstanceID); ok {
+		return iid.ID
+	}
+	return ""
+}
+
+func (d *driver) Type(ctx apitypes.Context) (apitypes.StorageType, error) {
+	return apitypes.NAS, nil
+}
+
+// NextDeviceInfo returns nil because volumes are not devices.
+func (d *driver) NextDeviceInfo(
+	ctx apitypes.Context) (*apitypes.NextDeviceInfo, error) {
+
+	return nil, nil
+}
+
+func (d *driver) InstanceInspect(
+	ctx apitypes.Context,
+	opts apitypes.Store) (*apitypes.Instance, error) {
+
+	iid, ok := ctx.Value(context.InstanceIDKey).(*apitypes.InstanceID)
+	if !ok {
+		return nil, goof.New("missing instance ID")
+	}
+	return &apitypes.Instance{InstanceID: iid, Name: iid.ID}, nil
+}
+
+// glusterVolume returns the name of the Gluster volume that holds a volume
+// and the option that records the instances the volume is attached to.
+func (d *driver) glusterVolume(volumeID string) (string, string) {
+	if d.mode == ModeSubdir {
+		return d.parent, attachmentsOption + "." + volumeID
+	}
+	return volumeID, attachmentsOption
+}
+
+// quotaPath returns the path of the directory whose quota is a volume's
+// size.
+func (d *driver) quotaPath(volumeID string) string {
+	if d.mode == ModeSubdir {
+		return "/" + volumeID
+	}
+	return "/"
+}
+
+// toVolume returns a libStorage volume. A volume's ID is its name because
+// the Gluster volume or directory is referred to by name. The fields of the
+// volume are those with which nodes mount it.
+func (d *driver) toVolume(
+	name string,
+	info *volumeInfo,
+	size int64,
+	attachments bool) *apitypes.Volume {
+
+	vol := &apitypes.Volume{
+		ID:     name,
+		Name:   name,
+		Size:   size / gib,
+		Type:   strings.ToLower(info.TypeStr),
+		Status: "available",
+		Fields: map[string]string{
+			"servers": strings.Join(d.servers, ","),
+			"volume":  info.Name,
+		},
+	}
+	if d.mode == ModeSubdir {
+		vol.Fields["subdir"] = name
+	}
+	if !attachments {
+		return vol
+	}
+	_, opt := d.glusterVolume(name)
+	for _, iid := range parseAttachments(info.option(opt)) {
+		vol.Attachments = append(vol.Attachments, &apitypes.VolumeAttachment{
+			VolumeID: name,
+			InstanceID: &apitypes.InstanceID{
+				ID:     iid,
+				Driver: Name,
+			},
+			MountPoint: info.Name + d.quotaPath(name),
+			Status:     "attached",
+		})
+		vol.Status = "attached"
+	}
+	return vol
+}
+
+func (d *driver) Volumes(
+	ctx apitypes.Context,
+	opts *apitypes.VolumesOpts) ([]*apitypes.Volume, error) {
+
+	all := []*apitypes.Volume{}
+
+	if d.mode == ModeSubdir {
+		parent, limits, err := d.parentInfo()
+		if err != nil {
+			return nil, err
+		}
+		for p, size := range limits {
+			name := strings.TrimPrefix(p, "/")
+			if name == "" || strings.Contains(name, "/") {
+				continue
+			}
+			all = append(all, d.toVolume(name, parent, size, opts.Attachments))
+		}
+		return all, nil
+	}
+
+	infos, err := d.gluster.volumeInfo("")
+	if err != nil {
+		return nil, err
+	}
+	for _, info := range infos {
+		limits, err := d.gluster.quotaLimits(info)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, d.toVolume(
+			info.Name, info, limits["/"], opts.Attachments))
+	}
+	return all, nil
+}
+
+func (d *driver) VolumeInspect(
+	ctx apitypes.Context,
+	volumeID string,
+	opts *apitypes.VolumeInspectOpts) (*apitypes.Volume, error) {
+
+	return d.inspect(volumeID, opts.Attachments)
+}
+
+func (d *driver) inspect(
+	volumeID string, attachments bool) (*apitypes.Volume, error) {
+
+	notFound := goof.WithField("volumeID", volumeID, "volume not found")
+
+	if d.mode == ModeSubdir {
+		parent, limits, err := d.parentInfo()
+		if err != nil {
+			return nil, err
+		}
+		size, ok := limits[d.quotaPath(volumeID)]
+		if !ok {
+			return nil, notFound
+		}
+		return d.toVolume(volumeID, parent, size, attachments), nil
+	}
+
+	infos, err := d.gluster.volumeInfo(volumeID)
+	if isNotFound(err) || (err == nil && len(infos) == 0) {
+		return nil, notFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	limits, err := d.gluster.quotaLimits(infos[0])
+	if err != nil {
+		return nil, err
+	}
+	return d.toVolume(volumeID, infos[0], limits["/"], attachments), nil
+}
+
+// parentInfo returns the parent volume and the quotas of its directories.
+func (d *driver) parentInfo() (*volumeInfo, map[string]int64, error) {
+	infos, err := d.gluster.volumeInfo(d.parent)
+	if err != nil {
+		return nil, nil, goof.WithFieldE("parentVolume", d.parent,
+			"error inspecting parent volume", err)
+	}
+	if len(infos) == 0 {
+		return nil, nil, goof.WithField("parentVolume", d.parent,
+			"parent volume not found")
+	}
+	limits, err := d.gluster.quotaLimits(infos[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	return infos[0], limits, nil
+}
+
+// VolumeCreate creates a replicated Gluster volume with a brick on each
+// server in volume mode, or a directory of the parent volume in subdir
+// mode, and limits its quota to the requested size.
+func (d *driver) VolumeCreate(
+	ctx apitypes.Context,
+	name string,
+	opts *apitypes.VolumeCreateOpts) (*apitypes.Volume, error) {
+
+	if name == "" || strings.ContainsAny(name, "/ ") {
+		return nil, goof.WithField("name", name, "invalid volume name")
+	}
+	size := int64(defaultSize)
+	if opts.Size != nil && *opts.Size > 0 {
+		size = *opts.Size
+	}
+
+	var err error
+	if d.mode == ModeSubdir {
+		err = d.createSubdir(ctx, name)
+	} else {
+		err = d.createVolume(ctx, name, opts.Opts)
+	}
+	if err != nil {
+		return nil, goof.WithFieldE("name", name, "error creating volume", err)
+	}
+
+	volume, _ := d.glusterVolume(name)
+	if err := d.limitUsage(volume, d.quotaPath(name), size); err != nil {
+		return nil, goof.WithFieldE("name", name,
+			"error setting volume quota", err)
+	}
+	return d.inspect(name, false)
+}
+
+func (d *driver) createVolume(
+	ctx apitypes.Context, name string, opts apitypes.Store) error {
+
+	replica, arbiter, err := replicaOf(d.config, opts)
+	if err != nil {
+		return err
+	}
+	bs, err := bricks(d.servers, d.brickRoot, name, replica)
+	if err != nil {
+		return err
+	}
+
+	args := []string{"volume", "create", name}
+	if replica > 1 {
+		args = append(args, "replica", strconv.Itoa(replica))
+	}
+	if arbiter {
+		args = append(args, "arbiter", "1")
+	}
+	args = append(args, bs...)
+
+	ctx.WithFields(map[string]interface{}{
+		"name":    name,
+		"replica": replica,
+		"arbiter": arbiter,
+		"bricks":  bs,
+	}).Debug("creating gluster volume")
+
+	if _, err := d.gluster.run(false, args...); err != nil {
+		return err
+	}
+	if _, err := d.gluster.run(false, "volume", "start", name); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (d *driver) createSubdir(ctx apitypes.Context, name string) error {
+	dir, err := d.mountParent(ctx)
+	if err != nil {
+		return err
+	}
+	return os.Mkdir(filepath.Join(dir, name), 0755)
+}
+
+// limitUsage enables the quota of a Gluster volume, unless it already is,
+// and limits the usage of one of its directories.
+func (d *driver) limitUsage(volume, path string, size int64) error {
+	infos, err := d.gluster.volumeInfo(volume)
+	if err != nil {
+		return err
+	}
+	if len(infos) > 0 && infos[0].option("features.quota") != "on" {
+		if _, err := d.gluster.run(
+			false, "volume", "quota", volume, "enable"); err != nil {
+			return err
+		}
+	}
+	_, err = d.gluster.run(false, "volume", "quota", volume, "limit-usage",
+		path, strconv.FormatInt(size, 10)+"GB")
+	return err
+}
+
+// mountParent mounts the parent volume on the server, unless it already is,
+// so that its directories may be created and removed, and returns the path
+// at which it is mounted.
+func (d *driver) mountParent(ctx apitypes.Context) (string, error) {
+	dir := util.RunFilePath(filepath.Join(Name, d.parent))
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	if isMountPoint(dir) {
+		return dir, nil
+	}
+	ctx.WithFields(map[string]interface{}{
+		"parentVolume": d.parent,
+		"mountPath":    dir,
+	}).Debug("mounting parent volume")
+	cmd := exec.Command("mount", mountArgs(
+		d.servers, d.parent, "", dir, false)...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return "", goof.WithFieldE("output", strings.TrimSpace(string(out)),
+			"error mounting parent volume", err)
+	}
+	return dir, nil
+}
+
+// isMountPoint returns a flag indicating whether something is mounted at
+// the path, which is the case when it is on a different device than its
+// parent directory.
+func isMountPoint(path string) bool {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	pfi, err := os.Stat(filepath.Dir(path))
+	if err != nil {
+		return false
+	}
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	pst, pok := pfi.Sys().(*syscall.Stat_t)
+	return ok && pok && st.Dev != pst.Dev
+}
+
+func (d *driver) VolumeCreateFromSnapshot(
+	ctx apitypes.Context,
+	snapshotID, volumeName string,
+	opts *apitypes.VolumeCreateOpts) (*apitypes.Volume, error) {
+
+	return nil, apitypes.ErrNotImplemented
+}
+
+func (d *driver) VolumeCopy(
+	ctx apitypes.Context,
+	volumeID, volumeName string,
+	opts apitypes.Store) (*apitypes.Volume, error) {
+
+	return nil, apitypes.ErrNotImplemented
+}
+
+func (d *driver) VolumeSnapshot(
+	ctx apitypes.Context,
+	volumeID, snapshotName string,
+	opts apitypes.Store) (*apitypes.Snapshot, error) {
+
+	return nil, apitypes.ErrNotImplemented
+}
+
+// VolumeRemove stops and deletes the volume's Gluster volume in volume mode,
+// which leaves its bricks on the servers, or removes its quota, directory,
+// and attachments option in subdir mode.
+func (d *driver) VolumeRemove(
+	ctx apitypes.Context,
+	volumeID string,
+	opts apitypes.Store) error {
+
+	if d.mode == ModeSubdir {
+		return d.removeSubdir(ctx, volumeID)
+	}
+
+	if _, err := d.gluster.run(
+		false, "volume", "stop", volumeID); err != nil &&
+		!strings.Contains(err.Error(), "not in started state") {
+		return goof.WithFieldE("volumeID", volumeID,
+			"error stopping volume", err)
+	}
+	if _, err := d.gluster.run(
+		false, "volume", "delete", volumeID); err != nil {
+		return goof.WithFieldE("volumeID", volumeID,
+			"error removing volume", err)
+	}
+	return nil
+}
+
+func (d *driver) removeSubdir(ctx apitypes.Context, volumeID string) error {
+	if volumeID == "" || strings.ContainsAny(volumeID, "/ ") {
+		return goof.WithField("volumeID", volumeID, "invalid volume ID")
+	}
+	dir, err := d.mountParent(ctx)
+	if err != nil {
+		return err
+	}
+	if _, err := d.gluster.run(false, "volume", "quota", d.parent,
+		"remove", d.quotaPath(volumeID)); err != nil {
+		return goof.WithFieldE("volumeID", volumeID,
+			"error removing volume quota", err)
+	}
+	if err := os.RemoveAll(filepath.Join(dir, volumeID)); err != nil {
+		return goof.WithFieldE("volumeID", volumeID,
+			"error removing volume", err)
+	}
+	_, opt := d.glusterVolume(volumeID)
+	return d.gluster.setOption(d.parent, opt, "")
+}
+
+// VolumeAttach records the instance as one the volume is attached to. A
+// forced attach replaces the other instances. There is no token since
+// volumes are mounted without credentials.
+func (d *driver) VolumeAttach(
+	ctx apitypes.Context,
+	volumeID string,
+	opts *apitypes.VolumeAttachOpts) (*apitypes.Volume, string, error) {
+
+	iid := instanceID(ctx)
+	if iid == "" {
+		return nil, "", goof.New("missing instance ID")
+	}
+	vol, err := d.inspect(volumeID, true)
+	if err != nil {
+		return nil, "", err
+	}
+
+	iids := []string{}
+	if !opts.Force {
+		for _, a := range vol.Attachments {
+			iids = append(iids, a.InstanceID.ID)
+		}
+	}
+	if !contains(iids, iid) {
+		iids = append(iids, iid)
+	}
+	if err := d.setAttachments(volumeID, iids); err != nil {
+		return nil, "", goof.WithFieldsE(goof.Fields{
+			"volumeID":   volumeID,
+			"instanceID": iid,
+		}, "error recording attachment", err)
+	}
+
+	vol, err = d.inspect(volumeID, true)
+	return vol, "", err
+}
+
+// VolumeDetach removes the instance from the ones the volume is attached to,
+// or all of them if the detach is forced.
+func (d *driver) VolumeDetach(
+	ctx apitypes.Context,
+	volumeID string,
+	opts *apitypes.VolumeDetachOpts) (*apitypes.Volume, error) {
+
+	iid := instanceID(ctx)
+	vol, err := d.inspect(volumeID, true)
+	if err != nil {
+		return nil, err
+	}
+
+	remaining := []string{}
+	if !opts.Force {
+		for _, a := range vol.Attachments {
+			if a.InstanceID.ID != iid {
+				remaining = append(remaining, a.InstanceID.ID)
+			}
+		}
+	}
+	if err := d.setAttachments(volumeID, remaining); err != nil {
+		return nil, goof.WithFieldsE(goof.Fields{
+			"volumeID":   volumeID,
+			"instanceID": iid,
+		}, "error recording detachment", err)
+	}
+	return d.inspect(volumeID, true)
+}
+
+func (d *driver) setAttachments(volumeID string, iids []string) error {
+	volume, opt := d.glusterVolume(volumeID)
+	return d.gluster.setOption(volume, opt, strings.Join(iids, ","))
+}
+
+func contains(s []string, v string) bool {
+	for _, i := range s {
+		if i == v {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *driver) Snapshots(
+	ctx apitypes.Context,
+	opts apitypes.Store) ([]*apitypes.Snapshot, error) {
+
+	return nil, apitypes.ErrNotImplemented
+}
+
+func (d *driver) SnapshotInspect(
+	ctx apitypes.Context,
+	snapshotID string,
+	opts apitypes.Store) (*apitypes.Snapshot, error) {
+
+	return nil, apitypes.ErrNotImplemented
+}
+
+func (d *driver) SnapshotCopy(
+	ctx apitypes.Context,
+	snapshotID, snapshotName, destinationID string,
+	opts apitypes.Store) (*apitypes.Snapshot, error) {
+
+	return nil, apitypes.ErrNotImplemented
+}
+
+func (d *driver) SnapshotRemove(
+	ctx apitypes.Context,
+	snapshotID string,
+	opts apitypes.Store) error {
+
+	return apitypes.ErrNotImplemented
+}
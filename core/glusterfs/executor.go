@@ -0,0 +1,55 @@
+package glusterfs
+
+import (
+	"os"
+
+	"github.com/akutz/gofig"
+	apitypes "github.com/emccode/libstorage/api/types"
+)
+
+// executor runs on each node. A node's instance ID is its host name.
+type executor struct {
+	config gofig.Config
+}
+
+func newExecutor() apitypes.StorageExecutor {
+	return &executor{}
+}
+
+func (e *executor) Name() string {
+	return Name
+}
+
+func (e *executor) Init(ctx apitypes.Context, config gofig.Config) error {
+	e.config = config
+	return nil
+}
+
+func (e *executor) InstanceID(
+	ctx apitypes.Context,
+	opts apitypes.Store) (*apitypes.InstanceID, error) {
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, err
+	}
+	return &apitypes.InstanceID{ID: hostname, Driver: Name}, nil
+}
+
+func (e *executor) NextDevice(
+	ctx apitypes.Context,
+	opts apitypes.Store) (string, error) {
+
+	return "", apitypes.ErrNotImplemented
+}
+
+// LocalDevices returns no devices since volumes are mounted over the network.
+func (e *executor) LocalDevices(
+	ctx apitypes.Context,
+	opts *apitypes.LocalDevicesOpts) (*apitypes.LocalDevices, error) {
+
+	return &apitypes.LocalDevices{
+		Driver:    Name,
+		DeviceMap: map[string]string{},
+	}, nil
+}
@@ -0,0 +1,187 @@
+package glusterfs
+
+import (
+	"bytes"
+	"encoding/xml"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+)
+
+// gluster runs the gluster command against the first of the servers.
+type gluster struct {
+	server  string
+	timeout time.Duration
+}
+
+func newGluster(config gofig.Config) *gluster {
+	g := &gluster{timeout: 30 * time.Second}
+	if s := servers(config); len(s) > 0 {
+		g.server = s[0]
+	}
+	if t, err := time.ParseDuration(
+		config.GetString("glusterfs.timeout")); err == nil && t > 0 {
+		g.timeout = t
+	}
+	return g
+}
+
+// cliOutput is the XML output of a gluster command.
+type cliOutput struct {
+	OpRet    int           `xml:"opRet"`
+	OpErrno  int           `xml:"opErrno"`
+	OpErrstr string        `xml:"opErrstr"`
+	Volumes  []*volumeInfo `xml:"volInfo>volumes>volume"`
+	Limits   []*quotaLimit `xml:"volQuota>limit"`
+}
+
+// volumeInfo is a volume, as returned by gluster volume info.
+type volumeInfo struct {
+	Name         string `xml:"name"`
+	ID           string `xml:"id"`
+	StatusStr    string `xml:"statusStr"`
+	TypeStr      string `xml:"typeStr"`
+	ReplicaCount int    `xml:"replicaCount"`
+	ArbiterCount int    `xml:"arbiterCount"`
+	Bricks       []struct {
+		Name string `xml:"name"`
+	} `xml:"bricks>brick"`
+	Options []struct {
+		Name  string `xml:"name"`
+		Value string `xml:"value"`
+	} `xml:"options>option"`
+}
+
+// option returns the value of one of the volume's options.
+func (v *volumeInfo) option(name string) string {
+	for _, o := range v.Options {
+		if o.Name == name {
+			return o.Value
+		}
+	}
+	return ""
+}
+
+// quotaLimit is the quota of a directory, as returned by gluster volume
+// quota list.
+type quotaLimit struct {
+	Path      string `xml:"path"`
+	HardLimit int64  `xml:"hard_limit"`
+}
+
+// errGluster is the error of a gluster command that failed.
+type errGluster struct {
+	args  []string
+	errno int
+	msg   string
+}
+
+func (e *errGluster) Error() string {
+	return "gluster " + strings.Join(e.args, " ") + ": " + e.msg
+}
+
+// isNotFound returns a flag indicating whether the error is that of a
+// gluster command whose volume does not exist.
+func isNotFound(err error) bool {
+	e, ok := err.(*errGluster)
+	return ok && strings.Contains(e.msg, "does not exist")
+}
+
+// run runs a gluster command in script mode, with XML output if the output
+// is decoded.
+func (g *gluster) run(xmlOut bool, args ...string) (*cliOutput, error) {
+	cmdArgs := []string{"--mode=script"}
+	if g.server != "" {
+		cmdArgs = append(cmdArgs, "--remote-host="+g.server)
+	}
+	if xmlOut {
+		cmdArgs = append(cmdArgs, "--xml")
+	}
+	cmdArgs = append(cmdArgs, args...)
+
+	cmd := exec.Command("gluster", cmdArgs...)
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	if err := cmd.Start(); err != nil {
+		return nil, goof.WithFieldE("args", args, "error starting gluster", err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	select {
+	case err := <-done:
+		if err != nil && !xmlOut {
+			msg := strings.TrimSpace(stderr.String())
+			if msg == "" {
+				msg = strings.TrimSpace(stdout.String())
+			}
+			return nil, &errGluster{args: args, msg: msg}
+		}
+	case <-time.After(g.timeout):
+		cmd.Process.Kill()
+		return nil, goof.WithFields(goof.Fields{
+			"args":    args,
+			"timeout": g.timeout,
+		}, "gluster timed out")
+	}
+
+	if !xmlOut {
+		return nil, nil
+	}
+	out := &cliOutput{}
+	if err := xml.Unmarshal(stdout.Bytes(), out); err != nil {
+		return nil, goof.WithFieldsE(goof.Fields{
+			"args":   args,
+			"stderr": strings.TrimSpace(stderr.String()),
+		}, "error decoding gluster output", err)
+	}
+	if out.OpRet != 0 {
+		return nil, &errGluster{
+			args: args, errno: out.OpErrno, msg: out.OpErrstr}
+	}
+	return out, nil
+}
+
+// volumeInfo returns the named volume, or all of the volumes if the name is
+// empty.
+func (g *gluster) volumeInfo(name string) ([]*volumeInfo, error) {
+	args := []string{"volume", "info"}
+	if name != "" {
+		args = append(args, name)
+	}
+	out, err := g.run(true, args...)
+	if err != nil {
+		return nil, err
+	}
+	return out.Volumes, nil
+}
+
+// quotaLimits returns the quotas of the volume's directories by their
+// paths. A volume whose quota is not enabled has none.
+func (g *gluster) quotaLimits(vol *volumeInfo) (map[string]int64, error) {
+	limits := map[string]int64{}
+	if vol.option("features.quota") != "on" {
+		return limits, nil
+	}
+	out, err := g.run(true, "volume", "quota", vol.Name, "list")
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range out.Limits {
+		limits[l.Path] = l.HardLimit
+	}
+	return limits, nil
+}
+
+// setOption sets a volume's option, or resets it if the value is empty.
+func (g *gluster) setOption(volume, name, value string) error {
+	if value == "" {
+		_, err := g.run(false, "volume", "reset", volume, name)
+		return err
+	}
+	_, err := g.run(false, "volume", "set", volume, name, value)
+	return err
+}
@@ -0,0 +1,162 @@
+// Package glusterfs is a storage driver for GlusterFS. In volume mode each
+// volume is a Gluster volume with a brick on each server, replicated and
+// optionally with an arbiter, whose size is the quota of its root. In subdir
+// mode each volume is a directory of a parent volume whose size is the
+// directory's quota, which suits clusters with fewer, larger volumes. Nodes
+// mount volumes with the FUSE client. The instances a volume is attached to
+// are recorded as user options of its Gluster volume.
+package glusterfs
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	"github.com/emccode/libstorage/api/registry"
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/core/sharedfs"
+)
+
+const (
+	// Name is the name with which the driver is registered.
+	Name = "glusterfs"
+
+	// ModeVolume provisions a Gluster volume for each volume.
+	ModeVolume = "volume"
+
+	// ModeSubdir provisions a directory of the parent volume for each
+	// volume.
+	ModeSubdir = "subdir"
+
+	// OptReplica is the create option with which a volume's replica count
+	// is requested in volume mode.
+	OptReplica = "replica"
+
+	// OptArbiter is the create option with which a volume's third replica
+	// is requested to be an arbiter in volume mode.
+	OptArbiter = "arbiter"
+
+	defaultBrickRoot = "/bricks"
+	defaultReplica   = 3
+	defaultSize      = 16
+
+	// attachmentsOption is the user option of a Gluster volume that records
+	// the instances the volume, or in subdir mode the volume's directory,
+	// is attached to.
+	attachmentsOption = "user.rexray.attachments"
+)
+
+func init() {
+	registry.RegisterStorageDriver(Name, newDriver)
+	registry.RegisterStorageExecutor(Name, newExecutor)
+	sharedfs.Register(Name, mount)
+
+	r := gofig.NewRegistration("GlusterFS Driver")
+	r.Key(gofig.String, "", ModeVolume,
+		"How volumes are provisioned: volume or subdir",
+		"glusterfs.mode")
+	r.Key(gofig.String, "", "",
+		"The Gluster servers separated by commas; the first is managed and "+
+			"mounted from and the others are backup volfile servers",
+		"glusterfs.servers")
+	r.Key(gofig.String, "", defaultBrickRoot,
+		"The directory on each server in which the bricks of volumes are "+
+			"created in volume mode",
+		"glusterfs.brickRoot")
+	r.Key(gofig.Int, "", defaultReplica,
+		"The replica count of volumes created in volume mode",
+		"glusterfs.replica")
+	r.Key(gofig.Bool, "", false,
+		"Make the third replica of volumes created in volume mode an arbiter",
+		"glusterfs.arbiter")
+	r.Key(gofig.String, "", "",
+		"The volume whose directories are volumes in subdir mode",
+		"glusterfs.parentVolume")
+	r.Key(gofig.String, "", "30s",
+		"How long to wait for a gluster command",
+		"glusterfs.timeout")
+	gofig.Register(r)
+}
+
+func servers(config gofig.Config) []string {
+	return splitList(config.GetString("glusterfs.servers"))
+}
+
+// splitList returns the items of a list separated by commas or spaces.
+func splitList(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+}
+
+// replicaOf returns the replica count and whether the third replica is an
+// arbiter, as requested by a volume's create options or else configured.
+func replicaOf(
+	config gofig.Config, opts apitypes.Store) (int, bool, error) {
+
+	replica := config.GetInt("glusterfs.replica")
+	if replica <= 0 {
+		replica = defaultReplica
+	}
+	arbiter := config.GetBool("glusterfs.arbiter")
+
+	if opts != nil {
+		if s := opts.GetString(OptReplica); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n <= 0 {
+				return 0, false, goof.WithField(
+					"replica", s, "invalid replica count")
+			}
+			replica = n
+		}
+		if s := opts.GetString(OptArbiter); s != "" {
+			b, err := strconv.ParseBool(s)
+			if err != nil {
+				return 0, false, goof.WithField(
+					"arbiter", s, "invalid arbiter flag")
+			}
+			arbiter = b
+		}
+	}
+
+	if arbiter && replica != 3 {
+		return 0, false, goof.WithField("replica", replica,
+			"an arbiter requires a replica count of 3")
+	}
+	return replica, arbiter, nil
+}
+
+// bricks returns the bricks of a new volume, one on each server. There must
+// be a multiple of the replica count of servers, in which case a volume with
+// more servers than replicas is distributed across the replica sets.
+func bricks(
+	servers []string,
+	brickRoot, volumeName string,
+	replica int) ([]string, error) {
+
+	if len(servers) == 0 || len(servers)%replica != 0 {
+		return nil, goof.WithFields(goof.Fields{
+			"servers": len(servers),
+			"replica": replica,
+		}, "the number of servers must be a multiple of the replica count")
+	}
+	bs := make([]string, len(servers))
+	for i, s := range servers {
+		bs[i] = s + ":" + strings.TrimRight(brickRoot, "/") +
+			"/" + volumeName + "/brick"
+	}
+	return bs, nil
+}
+
+// parseAttachments returns the instances recorded in an attachments option.
+func parseAttachments(s string) []string {
+	iids := []string{}
+	for _, iid := range strings.Split(s, ",") {
+		if iid = strings.TrimSpace(iid); iid != "" {
+			iids = append(iids, iid)
+		}
+	}
+	return iids
+}
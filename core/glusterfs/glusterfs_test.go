@@ -0,0 +1,91 @@
+package glusterfs
+
+import (
+	"encoding/xml"
+	"reflect"
+	"testing"
+)
+
+func TestBricks(t *testing.T) {
+	bs, err := bricks(
+		[]string{"gl1", "gl2", "gl3"}, "/bricks/", "data", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []string{
+		"gl1:/bricks/data/brick",
+		"gl2:/bricks/data/brick",
+		"gl3:/bricks/data/brick",
+	}
+	if !reflect.DeepEqual(bs, exp) {
+		t.Fatalf("bricks=%v", bs)
+	}
+	if _, err := bricks(
+		[]string{"gl1", "gl2"}, "/bricks", "data", 3); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestParseAttachments(t *testing.T) {
+	if iids := parseAttachments(""); len(iids) != 0 {
+		t.Fatalf("iids=%v", iids)
+	}
+	iids := parseAttachments("node1, node2,,")
+	if !reflect.DeepEqual(iids, []string{"node1", "node2"}) {
+		t.Fatalf("iids=%v", iids)
+	}
+}
+
+func TestMountArgs(t *testing.T) {
+	args := mountArgs([]string{"gl1", "gl2", "gl3"},
+		"shared", "data", "/mnt/data", true)
+	exp := []string{
+		"-t", "glusterfs", "gl1:/shared/data", "/mnt/data",
+		"-o", "backup-volfile-servers=gl2:gl3,ro",
+	}
+	if !reflect.DeepEqual(args, exp) {
+		t.Fatalf("args=%v", args)
+	}
+	args = mountArgs([]string{"gl1"}, "data", "", "/mnt/data", false)
+	exp = []string{"-t", "glusterfs", "gl1:/data", "/mnt/data"}
+	if !reflect.DeepEqual(args, exp) {
+		t.Fatalf("args=%v", args)
+	}
+}
+
+func TestCLIOutput(t *testing.T) {
+	out := &cliOutput{}
+	if err := xml.Unmarshal([]byte(`<cliOutput>
+  <opRet>0</opRet>
+  <opErrno>0</opErrno>
+  <volInfo>
+    <volumes>
+      <volume>
+        <name>data</name>
+        <typeStr>Replicate</typeStr>
+        <replicaCount>3</replicaCount>
+        <arbiterCount>1</arbiterCount>
+        <bricks><brick><name>gl1:/bricks/data/brick</name></brick></bricks>
+        <options>
+          <option><name>features.quota</name><value>on</value></option>
+          <option>
+            <name>user.rexray.attachments</name><value>node1</value>
+          </option>
+        </options>
+      </volume>
+    </volumes>
+  </volInfo>
+</cliOutput>`), out); err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Volumes) != 1 {
+		t.Fatalf("volumes=%d", len(out.Volumes))
+	}
+	v := out.Volumes[0]
+	if v.Name != "data" || v.ArbiterCount != 1 || len(v.Bricks) != 1 {
+		t.Fatalf("volume=%+v", v)
+	}
+	if o := v.option(attachmentsOption); o != "node1" {
+		t.Fatalf("attachments=%s", o)
+	}
+}
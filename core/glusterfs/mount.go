@@ -0,0 +1,76 @@
+package glusterfs
+
+import (
+	"os/exec"
+	"strings"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	apitypes "github.com/emccode/libstorage/api/types"
+)
+
+// mount mounts a volume's Gluster volume, or its directory of the parent
+// volume, with the FUSE client. The volume is mounted from the servers in
+// glusterfs.servers in the node's configuration, or else those of the
+// server, and the token is unused.
+func mount(
+	ctx apitypes.Context,
+	config gofig.Config,
+	vol *apitypes.Volume,
+	token, mountPath string,
+	readOnly bool) error {
+
+	volume := vol.Fields["volume"]
+	if volume == "" {
+		return goof.WithField("volumeID", vol.ID,
+			"volume has no gluster volume")
+	}
+	srvs := servers(config)
+	if len(srvs) == 0 {
+		srvs = splitList(vol.Fields["servers"])
+	}
+	if len(srvs) == 0 {
+		return goof.WithField("volumeID", vol.ID,
+			"glusterfs mounts require glusterfs.servers")
+	}
+
+	args := mountArgs(srvs, volume, vol.Fields["subdir"], mountPath, readOnly)
+	ctx.WithFields(map[string]interface{}{
+		"volumeID":  vol.ID,
+		"source":    args[2],
+		"mountPath": mountPath,
+	}).Debug("mounting gluster volume")
+
+	if out, err := exec.Command("mount", args...).CombinedOutput(); err != nil {
+		return goof.WithFieldE("output", strings.TrimSpace(string(out)),
+			"error mounting gluster volume", err)
+	}
+	return nil
+}
+
+// mountArgs returns the arguments of the mount command that mounts a Gluster
+// volume, or one of its directories, from the first of the servers. The
+// others are the backup volfile servers should the first be unavailable.
+func mountArgs(
+	servers []string,
+	volume, subdir, mountPath string,
+	readOnly bool) []string {
+
+	source := servers[0] + ":/" + volume
+	if subdir != "" {
+		source += "/" + subdir
+	}
+	opts := []string{}
+	if len(servers) > 1 {
+		opts = append(opts,
+			"backup-volfile-servers="+strings.Join(servers[1:], ":"))
+	}
+	if readOnly {
+		opts = append(opts, "ro")
+	}
+	args := []string{"-t", "glusterfs", source, mountPath}
+	if len(opts) > 0 {
+		args = append(args, "-o", strings.Join(opts, ","))
+	}
+	return args
+}
@@ -0,0 +1,281 @@
+// Package groups records groups of volumes that an application needs
+// together, such as a database's data and log volumes. A group's volumes are
+// attached all or nothing: if any of them cannot be attached or mounted, the
+// ones that were are released again. They are mounted in the order in which
+// the group lists them and unmounted in the reverse order.
+package groups
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/akutz/goof"
+	apitypes "github.com/emccode/libstorage/api/types"
+	apiutils "github.com/emccode/libstorage/api/utils"
+
+	"github.com/emccode/rexray/core/errcodes"
+	"github.com/emccode/rexray/core/state"
+)
+
+const groupsBucket = "volumeGroups"
+
+// Group is an ordered group of volumes.
+type Group struct {
+
+	// Name is the group's name.
+	Name string `json:"name" yaml:"name"`
+
+	// Volumes are the names of the group's volumes in the order in which
+	// they are mounted.
+	Volumes []string `json:"volumes" yaml:"volumes"`
+
+	// Created is the time at which the group was created.
+	Created time.Time `json:"created" yaml:"created"`
+}
+
+// Result is the result of an operation on one of a group's volumes.
+type Result struct {
+	Volume string `json:"volume" yaml:"volume"`
+
+	// MountPath is the path at which the volume was mounted.
+	MountPath string `json:"mountPath,omitempty" yaml:"mountPath,omitempty"`
+
+	// RolledBack indicates the operation succeeded but was undone because
+	// another volume of the group failed.
+	RolledBack bool `json:"rolledBack,omitempty" yaml:"rolledBack,omitempty"`
+
+	Error string `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// Error is returned when an operation on a group fails. The results of the
+// operation on each volume are included so that the caller may report which
+// volume failed and which were rolled back.
+type Error struct {
+	Group     string    `json:"group"`
+	Operation string    `json:"operation"`
+	Results   []*Result `json:"results"`
+}
+
+func (e *Error) Error() string {
+	msgs := []string{}
+	for _, r := range e.Results {
+		if r.Error != "" {
+			msgs = append(msgs, r.Volume+": "+r.Error)
+		}
+	}
+	return "group " + e.Operation + " failed for " + e.Group + ": " +
+		strings.Join(msgs, "; ")
+}
+
+// Status returns the HTTP status code with which the error is reported.
+func (e *Error) Status() int {
+	return http.StatusConflict
+}
+
+// Code returns the code of the error.
+func (e *Error) Code() errcodes.Code {
+	return errcodes.GroupFailed
+}
+
+// Set creates or replaces the group with the provided name.
+func Set(s *state.Store, name string, volumes []string) (*Group, error) {
+	if name == "" {
+		return nil, goof.New("missing group name")
+	}
+	if len(volumes) == 0 {
+		return nil, goof.WithField("group", name, "group has no volumes")
+	}
+	seen := map[string]bool{}
+	for _, v := range volumes {
+		if seen[v] {
+			return nil, goof.WithFields(goof.Fields{
+				"group":  name,
+				"volume": v,
+			}, "volume listed more than once")
+		}
+		seen[v] = true
+	}
+
+	g := &Group{Name: name, Volumes: volumes, Created: time.Now().UTC()}
+	if err := s.Set(groupsBucket, name, g); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// Remove removes the group with the provided name. Its volumes are not
+// affected.
+func Remove(s *state.Store, name string) error {
+	return s.Delete(groupsBucket, name)
+}
+
+// Get returns the group with the provided name.
+func Get(s *state.Store, name string) (*Group, error) {
+	g := &Group{}
+	ok, err := s.Get(groupsBucket, name, g)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errcodes.New(errcodes.NotFound,
+			goof.WithField("group", name, "group not found"))
+	}
+	return g, nil
+}
+
+// List returns the groups.
+func List(s *state.Store) ([]*Group, error) {
+	names, err := s.Keys(groupsBucket)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	all := []*Group{}
+	for _, name := range names {
+		g, err := Get(s, name)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, g)
+	}
+	return all, nil
+}
+
+// Attach attaches the group's volumes to the local instance in order. If a
+// volume cannot be attached, the volumes attached before it are detached.
+func Attach(
+	ctx apitypes.Context,
+	client apitypes.Client,
+	g *Group) ([]*Result, error) {
+
+	ids, err := volumeIDs(ctx, client, g)
+	if err != nil {
+		return nil, err
+	}
+
+	return run(g, "attach",
+		func(i int, r *Result) error {
+			_, _, err := client.Storage().VolumeAttach(ctx, ids[i],
+				&apitypes.VolumeAttachOpts{Opts: apiutils.NewStore()})
+			return err
+		},
+		func(i int) error {
+			_, err := client.Storage().VolumeDetach(ctx, ids[i],
+				&apitypes.VolumeDetachOpts{Opts: apiutils.NewStore()})
+			return err
+		})
+}
+
+// Mount mounts the group's volumes in order, attaching them as necessary. If
+// a volume cannot be mounted, the volumes mounted before it are unmounted in
+// the reverse order.
+func Mount(
+	ctx apitypes.Context,
+	client apitypes.Client,
+	g *Group) ([]*Result, error) {
+
+	return run(g, "mount",
+		func(i int, r *Result) error {
+			mountPath, _, err := client.Integration().Mount(
+				ctx, "", g.Volumes[i],
+				&apitypes.VolumeMountOpts{Opts: apiutils.NewStore()})
+			r.MountPath = mountPath
+			return err
+		},
+		func(i int) error {
+			return client.Integration().Unmount(
+				ctx, "", g.Volumes[i], apiutils.NewStore())
+		})
+}
+
+// Unmount unmounts the group's volumes in the reverse order. Every volume is
+// unmounted even if one fails; an unmount cannot be rolled back.
+func Unmount(
+	ctx apitypes.Context,
+	client apitypes.Client,
+	g *Group) ([]*Result, error) {
+
+	results := make([]*Result, len(g.Volumes))
+	failed := false
+	for i := len(g.Volumes) - 1; i >= 0; i-- {
+		r := &Result{Volume: g.Volumes[i]}
+		if err := client.Integration().Unmount(
+			ctx, "", g.Volumes[i], apiutils.NewStore()); err != nil {
+			r.Error = err.Error()
+			failed = true
+		}
+		results[i] = r
+	}
+	if failed {
+		return results, &Error{
+			Group: g.Name, Operation: "unmount", Results: results}
+	}
+	return results, nil
+}
+
+// run invokes do for each of the group's volumes in order. If it fails,
+// undo is invoked for each volume it succeeded for in the reverse order.
+func run(
+	g *Group,
+	op string,
+	do func(i int, r *Result) error,
+	undo func(i int) error) ([]*Result, error) {
+
+	results := make([]*Result, len(g.Volumes))
+	for i, v := range g.Volumes {
+		results[i] = &Result{Volume: v}
+	}
+
+	for i, r := range results {
+		err := do(i, r)
+		if err == nil {
+			continue
+		}
+		r.Error = err.Error()
+		r.MountPath = ""
+
+		for j := i - 1; j >= 0; j-- {
+			if err := undo(j); err != nil {
+				results[j].Error = "rollback failed: " + err.Error()
+				continue
+			}
+			results[j].RolledBack = true
+			results[j].MountPath = ""
+		}
+		return results, &Error{Group: g.Name, Operation: op, Results: results}
+	}
+	return results, nil
+}
+
+// volumeIDs returns the IDs of the group's volumes.
+func volumeIDs(
+	ctx apitypes.Context,
+	client apitypes.Client,
+	g *Group) ([]string, error) {
+
+	vols, err := client.Storage().Volumes(
+		ctx, &apitypes.VolumesOpts{Attachments: false})
+	if err != nil {
+		return nil, err
+	}
+	byName := map[string]string{}
+	for _, v := range vols {
+		byName[strings.ToLower(v.Name)] = v.ID
+	}
+
+	ids := make([]string, len(g.Volumes))
+	for i, name := range g.Volumes {
+		id, ok := byName[strings.ToLower(name)]
+		if !ok {
+			return nil, errcodes.New(errcodes.VolumeNotFound,
+				goof.WithFields(goof.Fields{
+					"group":      g.Name,
+					"volumeName": name,
+				}, "volume not found"))
+		}
+		ids[i] = id
+	}
+	return ids, nil
+}
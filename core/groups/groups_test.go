@@ -0,0 +1,60 @@
+package groups
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestRunRollsBack(t *testing.T) {
+	g := &Group{Name: "db", Volumes: []string{"data", "log", "wal"}}
+
+	calls := []string{}
+	results, err := run(g, "mount",
+		func(i int, r *Result) error {
+			calls = append(calls, "do "+g.Volumes[i])
+			if g.Volumes[i] == "wal" {
+				return errors.New("no space")
+			}
+			r.MountPath = "/mnt/" + g.Volumes[i]
+			return nil
+		},
+		func(i int) error {
+			calls = append(calls, "undo "+g.Volumes[i])
+			return nil
+		})
+
+	if _, ok := err.(*Error); !ok {
+		t.Fatalf("expected *Error, got %v", err)
+	}
+	expected := []string{
+		"do data", "do log", "do wal", "undo log", "undo data"}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Fatalf("expected %v, got %v", expected, calls)
+	}
+	for _, r := range results[:2] {
+		if !r.RolledBack || r.MountPath != "" {
+			t.Errorf("%s not rolled back: %+v", r.Volume, r)
+		}
+	}
+	if results[2].Error != "no space" {
+		t.Errorf("unexpected error %q", results[2].Error)
+	}
+}
+
+func TestRunSucceeds(t *testing.T) {
+	g := &Group{Name: "db", Volumes: []string{"data", "log"}}
+
+	results, err := run(g, "attach",
+		func(i int, r *Result) error { return nil },
+		func(i int) error {
+			t.Fatal("unexpected rollback")
+			return nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].Volume != "data" {
+		t.Fatalf("unexpected results %+v", results)
+	}
+}
@@ -0,0 +1,231 @@
+// Package health detects volumes whose mounts have become unresponsive, such
+// as NFS mounts whose server is unreachable or FUSE mounts whose helper
+// process has exited, and repairs them.
+package health
+
+import (
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	apitypes "github.com/emccode/libstorage/api/types"
+	apiutils "github.com/emccode/libstorage/api/utils"
+
+	"github.com/emccode/rexray/core/state"
+)
+
+// Status is the health of a volume's mount.
+type Status string
+
+const (
+	// Healthy indicates the volume's mounts are responsive.
+	Healthy Status = "healthy"
+
+	// Degraded indicates one or more of the volume's mounts are hung or
+	// stale.
+	Degraded Status = "degraded"
+
+	healthBucket = "volumeHealth"
+)
+
+func init() {
+	r := gofig.NewRegistration("Volume Health")
+	r.Key(gofig.String, "", "5s",
+		"The duration after which a mount that does not respond is hung",
+		"rexray.volume.health.statTimeout")
+	r.Key(gofig.Bool, "", false,
+		"Repair degraded volumes automatically",
+		"rexray.volume.health.autoRepair")
+	gofig.Register(r)
+}
+
+// Record is the recorded health of a volume on an instance.
+type Record struct {
+
+	// Status is the volume's health.
+	Status Status `json:"status"`
+
+	// MountPoint is the mount point that is degraded.
+	MountPoint string `json:"mountPoint,omitempty"`
+
+	// Reason describes why the volume is degraded.
+	Reason string `json:"reason,omitempty"`
+
+	// Checked is the time at which the volume was last checked.
+	Checked time.Time `json:"checked"`
+}
+
+// StatTimeout returns the configured duration after which a mount is hung.
+func StatTimeout(config gofig.Config) time.Duration {
+	d, err := time.ParseDuration(
+		config.GetString("rexray.volume.health.statTimeout"))
+	if err != nil {
+		return 5 * time.Second
+	}
+	return d
+}
+
+// Check returns an error if the file system mounted at the provided path is
+// stale or does not respond within the timeout.
+func Check(mountPoint string, timeout time.Duration) error {
+	err := Probe(mountPoint, timeout)
+	switch {
+	case err == nil:
+		return nil
+	case err == ErrHung:
+		return goof.WithFields(goof.Fields{
+			"mountPoint": mountPoint,
+			"timeout":    timeout.String(),
+		}, "mount is hung")
+	case IsStale(err):
+		return goof.WithFieldE("mountPoint", mountPoint, "mount is stale", err)
+	}
+	return err
+}
+
+// ErrHung is returned by Probe when a mount does not respond.
+var ErrHung = goof.New("mount is hung")
+
+// Probe stats the provided mount point, returning ErrHung if the stat does
+// not complete within the timeout. The stat is performed in a separate
+// goroutine so that a hung mount cannot block the caller, although the
+// goroutine remains blocked until the mount recovers.
+func Probe(mountPoint string, timeout time.Duration) error {
+	errs := make(chan error, 1)
+	go func() {
+		_, err := os.Stat(mountPoint)
+		errs <- err
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-time.After(timeout):
+		return ErrHung
+	}
+}
+
+// IsStale returns a flag indicating whether or not the provided error is the
+// result of accessing a stale NFS handle or a FUSE mount whose helper process
+// is no longer running.
+func IsStale(err error) bool {
+	if err == nil {
+		return false
+	}
+	if pe, ok := err.(*os.PathError); ok {
+		err = pe.Err
+	}
+	if err == syscall.ESTALE || err == syscall.ENOTCONN {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "stale") ||
+		strings.Contains(msg, "transport endpoint is not connected")
+}
+
+// Get returns the recorded health of the volume with the provided ID. A
+// volume that has never been checked is healthy.
+func Get(s *state.Store, volumeID string) (*Record, error) {
+	r := &Record{Status: Healthy}
+	if _, err := s.Get(healthBucket, volumeID, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Set records the health of the volume with the provided ID. Only degraded
+// volumes are recorded.
+func Set(s *state.Store, volumeID string, r *Record) error {
+	if r.Status == Healthy {
+		return s.Delete(healthBucket, volumeID)
+	}
+	return s.Set(healthBucket, volumeID, r)
+}
+
+// Delete removes the recorded health of the volume with the provided ID.
+func Delete(s *state.Store, volumeID string) error {
+	return s.Delete(healthBucket, volumeID)
+}
+
+// CheckVolume checks each of the provided mount points of a volume and
+// records the volume's health.
+func CheckVolume(
+	s *state.Store,
+	volumeID string,
+	mountPoints []string,
+	timeout time.Duration) (*Record, error) {
+
+	r := &Record{Status: Healthy, Checked: time.Now().UTC()}
+	for _, mp := range mountPoints {
+		if !IsNetworkMount(mp) {
+			continue
+		}
+		if err := Check(mp, timeout); err != nil {
+			r.Status = Degraded
+			r.MountPoint = mp
+			r.Reason = err.Error()
+			break
+		}
+	}
+	return r, Set(s, volumeID, r)
+}
+
+// Repair forcefully unmounts the local mounts of a volume and mounts the
+// volume again.
+func Repair(
+	ctx apitypes.Context,
+	client apitypes.Client,
+	s *state.Store,
+	volumeID string) (string, error) {
+
+	iid, err := client.Executor().InstanceID(ctx, apiutils.NewStore())
+	if err != nil {
+		return "", err
+	}
+
+	vol, err := client.Storage().VolumeInspect(
+		ctx, volumeID, &apitypes.VolumeInspectOpts{Attachments: true})
+	if err != nil {
+		return "", err
+	}
+
+	for _, a := range vol.Attachments {
+		if a.InstanceID == nil || a.InstanceID.ID != iid.ID {
+			continue
+		}
+		mounts, err := client.OS().Mounts(
+			ctx, a.DeviceName, "", apiutils.NewStore())
+		if err != nil {
+			return "", err
+		}
+		for _, m := range mounts {
+			ctx.WithFields(map[string]interface{}{
+				"volumeID":   volumeID,
+				"mountPoint": m.MountPoint,
+			}).Warn("forcefully unmounting degraded volume")
+			if err := forceUnmount(m.MountPoint); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	mountPath, _, err := client.Integration().Mount(
+		ctx, volumeID, "", &apitypes.VolumeMountOpts{})
+	if err != nil {
+		return "", goof.WithFieldE(
+			"volumeID", volumeID, "error remounting volume", err)
+	}
+
+	if err := Set(s, volumeID, &Record{Status: Healthy}); err != nil {
+		return "", err
+	}
+
+	ctx.WithFields(map[string]interface{}{
+		"volumeID":  volumeID,
+		"mountPath": mountPath,
+	}).Info("repaired volume")
+	return mountPath, nil
+}
@@ -0,0 +1,107 @@
+package health
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/akutz/goof"
+)
+
+// networkFSTypes are the file system types whose mounts depend on a remote
+// server or a helper process and may therefore hang.
+var networkFSTypes = []string{
+	"nfs", "nfs4", "cifs", "smbfs", "ceph", "glusterfs", "fuse",
+}
+
+// IsNetworkMount returns a flag indicating whether or not the file system
+// mounted at the provided path is a network or FUSE file system.
+func IsNetworkMount(mountPoint string) bool {
+	fsType := fsTypeOf(mountPoint)
+	for _, t := range networkFSTypes {
+		if fsType == t || strings.HasPrefix(fsType, t+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// IsFUSEMount returns a flag indicating whether or not the file system
+// mounted at the provided path is served by a FUSE helper process.
+func IsFUSEMount(mountPoint string) bool {
+	fsType := fsTypeOf(mountPoint)
+	return fsType == "fuse" || strings.HasPrefix(fsType, "fuse.")
+}
+
+// FUSEHelper returns the ID of the process serving the FUSE file system
+// mounted at the provided path, or zero if the process cannot be found. The
+// helper is identified as the process whose arguments include the mount
+// point, which is how s3fs, ceph-fuse, and quobyte's mount.quobyte are
+// invoked.
+func FUSEHelper(mountPoint string) int {
+	procs, err := ioutil.ReadDir("/proc")
+	if err != nil {
+		return 0
+	}
+	for _, p := range procs {
+		pid, err := strconv.Atoi(p.Name())
+		if err != nil || pid == os.Getpid() {
+			continue
+		}
+		buf, err := ioutil.ReadFile(
+			filepath.Join("/proc", p.Name(), "cmdline"))
+		if err != nil {
+			continue
+		}
+		for _, arg := range bytes.Split(buf, []byte{0}) {
+			if string(arg) == mountPoint {
+				return pid
+			}
+		}
+	}
+	return 0
+}
+
+// IsRunning returns a flag indicating whether or not the process with the
+// provided ID exists.
+func IsRunning(pid int) bool {
+	_, err := os.Stat(filepath.Join("/proc", strconv.Itoa(pid)))
+	return err == nil
+}
+
+// fsTypeOf returns the type of the file system mounted at the provided path
+// as recorded in /proc/self/mounts, which can be read without accessing the
+// possibly hung mount.
+func fsTypeOf(mountPoint string) string {
+	f, err := os.Open("/proc/self/mounts")
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	var fsType string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 3 && fields[1] == mountPoint {
+			fsType = fields[2]
+		}
+	}
+	return fsType
+}
+
+// forceUnmount aborts pending requests to the file system at the provided
+// mount point and detaches it.
+func forceUnmount(mountPoint string) error {
+	err := syscall.Unmount(mountPoint, syscall.MNT_FORCE|syscall.MNT_DETACH)
+	if err != nil && err != syscall.EINVAL {
+		return goof.WithFieldE("mountPoint", mountPoint,
+			"error forcefully unmounting", err)
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+// +build !linux
+
+package health
+
+import "github.com/akutz/goof"
+
+// IsNetworkMount returns false since mount types can only be inspected on
+// Linux.
+func IsNetworkMount(mountPoint string) bool {
+	return false
+}
+
+// IsFUSEMount returns false since mount types can only be inspected on Linux.
+func IsFUSEMount(mountPoint string) bool {
+	return false
+}
+
+// FUSEHelper returns zero since FUSE helpers can only be found on Linux.
+func FUSEHelper(mountPoint string) int {
+	return 0
+}
+
+// IsRunning returns true since processes can only be inspected on Linux.
+func IsRunning(pid int) bool {
+	return true
+}
+
+func forceUnmount(mountPoint string) error {
+	return goof.New("forced unmounts are only supported on Linux")
+}
@@ -0,0 +1,318 @@
+package ibmvpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+
+	"github.com/emccode/rexray/core/errcodes"
+)
+
+const apiVersion = "2022-09-13"
+
+// reference is a reference to another resource, as returned by the API.
+type reference struct {
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name,omitempty"`
+}
+
+// volume is a VPC block storage volume, as returned by the API.
+type volume struct {
+	ID                string              `json:"id"`
+	Name              string              `json:"name"`
+	Capacity          int64               `json:"capacity"`
+	IOPS              int64               `json:"iops"`
+	Profile           reference           `json:"profile"`
+	Zone              reference           `json:"zone"`
+	Status            string              `json:"status"`
+	UserTags          []string            `json:"user_tags"`
+	VolumeAttachments []*volumeAttachment `json:"volume_attachments"`
+}
+
+// volumeAttachment is the attachment of a volume to an instance.
+type volumeAttachment struct {
+	ID       string     `json:"id"`
+	Name     string     `json:"name"`
+	Type     string     `json:"type"`
+	Status   string     `json:"status"`
+	Device   *reference `json:"device"`
+	Instance *reference `json:"instance"`
+	Volume   *reference `json:"volume"`
+}
+
+// instance is a VPC virtual server instance, as returned by the API and the
+// metadata service.
+type instance struct {
+	ID   string    `json:"id"`
+	Name string    `json:"name"`
+	Zone reference `json:"zone"`
+}
+
+// apiError is an error returned by the API.
+type apiError struct {
+	status   int
+	messages []string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf(
+		"ibmvpc: %d %s", e.status, strings.Join(e.messages, "; "))
+}
+
+// Code returns the code of the error's HTTP status.
+func (e *apiError) Code() errcodes.Code {
+	return errcodes.FromStatus(e.status)
+}
+
+// MarshalJSON marshals the error as its message and code, since the
+// libStorage server reports a driver's error as the error's JSON.
+func (e *apiError) MarshalJSON() ([]byte, error) {
+	return errcodes.Marshal(e)
+}
+
+// isNotFound returns a flag indicating whether the error is that of a
+// request for an object that does not exist.
+func isNotFound(err error) bool {
+	e, ok := err.(*apiError)
+	return ok && e.status == http.StatusNotFound
+}
+
+// iam obtains and caches the IAM access tokens with which the API is called.
+type iam struct {
+	sync.Mutex
+	auth      string
+	apiKey    string
+	profileID string
+	endpoint  string
+	md        *metadata
+	client    *http.Client
+	token     string
+	expiry    time.Time
+}
+
+func newIAM(config gofig.Config) (*iam, error) {
+	i := &iam{
+		auth:      config.GetString("ibmvpc.auth"),
+		apiKey:    config.GetString("ibmvpc.apiKey"),
+		profileID: config.GetString("ibmvpc.trustedProfileID"),
+		endpoint: strings.TrimSuffix(
+			config.GetString("ibmvpc.iamEndpoint"), "/"),
+		md:     newMetadata(config),
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+	if i.endpoint == "" {
+		i.endpoint = defaultIAMEndpoint
+	}
+	switch i.auth {
+	case "", AuthAPIKey:
+		if i.apiKey == "" {
+			return nil, goof.New("ibmvpc driver requires ibmvpc.apiKey")
+		}
+		i.auth = AuthAPIKey
+	case AuthTrustedProfile:
+	default:
+		return nil, goof.WithField("auth", i.auth, "invalid ibmvpc auth")
+	}
+	return i, nil
+}
+
+// accessToken returns a cached access token, or a new one if the cached
+// token expires within a minute.
+func (i *iam) accessToken() (string, error) {
+	i.Lock()
+	defer i.Unlock()
+	if i.token != "" && time.Now().Add(time.Minute).Before(i.expiry) {
+		return i.token, nil
+	}
+
+	var err error
+	if i.auth == AuthTrustedProfile {
+		i.token, i.expiry, err = i.md.iamToken(i.profileID)
+	} else {
+		i.token, i.expiry, err = i.apiKeyToken()
+	}
+	if err != nil {
+		i.token = ""
+		return "", goof.WithFieldE("auth", i.auth,
+			"error authenticating to ibm cloud", err)
+	}
+	return i.token, nil
+}
+
+// apiKeyToken exchanges the API key for an access token.
+func (i *iam) apiKeyToken() (string, time.Time, error) {
+	form := url.Values{
+		"grant_type": {"urn:ibm:params:oauth:grant-type:apikey"},
+		"apikey":     {i.apiKey},
+	}
+	req, err := http.NewRequest("POST", i.endpoint+"/identity/token",
+		strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	res, err := i.client.Do(req)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", time.Time{}, goof.WithField(
+			"status", res.StatusCode, "error obtaining iam token")
+	}
+	t := &struct {
+		AccessToken string `json:"access_token"`
+		Expiration  int64  `json:"expiration"`
+	}{}
+	if err := json.NewDecoder(res.Body).Decode(t); err != nil {
+		return "", time.Time{}, err
+	}
+	return t.AccessToken, time.Unix(t.Expiration, 0), nil
+}
+
+// client calls the VPC API of a region.
+type client struct {
+	endpoint string
+	iam      *iam
+	client   *http.Client
+}
+
+func newClient(config gofig.Config, region string) (*client, error) {
+	i, err := newIAM(config)
+	if err != nil {
+		return nil, err
+	}
+	endpoint := strings.TrimSuffix(config.GetString("ibmvpc.endpoint"), "/")
+	if endpoint == "" {
+		if region == "" {
+			return nil, goof.New("ibmvpc driver requires ibmvpc.region")
+		}
+		endpoint = fmt.Sprintf("https://%s.iaas.cloud.ibm.com/v1", region)
+	}
+	return &client{
+		endpoint: endpoint,
+		iam:      i,
+		client:   &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+func (c *client) do(method, path string, body, result interface{}) error {
+	var buf []byte
+	if body != nil {
+		var err error
+		if buf, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	token, err := c.iam.accessToken()
+	if err != nil {
+		return err
+	}
+
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	req, err := http.NewRequest(method,
+		c.endpoint+path+sep+"version="+apiVersion+"&generation=2",
+		bytes.NewReader(buf))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if method == "PATCH" {
+		req.Header.Set("Content-Type", "application/merge-patch+json")
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return goof.WithFieldE("path", path, "error calling ibm cloud", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		e := &apiError{status: res.StatusCode}
+		errs := &struct {
+			Errors []struct {
+				Message string `json:"message"`
+			} `json:"errors"`
+		}{}
+		json.NewDecoder(res.Body).Decode(errs)
+		for _, m := range errs.Errors {
+			e.messages = append(e.messages, m.Message)
+		}
+		return e
+	}
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(result)
+}
+
+// volumes returns all of the region's volumes.
+func (c *client) volumes() ([]*volume, error) {
+	all := []*volume{}
+	q := url.Values{"limit": {"100"}}
+	for {
+		res := &struct {
+			Volumes []*volume `json:"volumes"`
+			Next    *struct {
+				Href string `json:"href"`
+			} `json:"next"`
+		}{}
+		if err := c.do("GET", "/volumes?"+q.Encode(), nil, res); err != nil {
+			return nil, err
+		}
+		all = append(all, res.Volumes...)
+		if res.Next == nil {
+			return all, nil
+		}
+		next, err := url.Parse(res.Next.Href)
+		if err != nil {
+			return nil, err
+		}
+		start := next.Query().Get("start")
+		if start == "" {
+			return all, nil
+		}
+		q.Set("start", start)
+	}
+}
+
+func (c *client) volume(id string) (*volume, error) {
+	v := &volume{}
+	if err := c.do("GET", "/volumes/"+id, nil, v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (c *client) instance(id string) (*instance, error) {
+	i := &instance{}
+	if err := c.do("GET", "/instances/"+id, nil, i); err != nil {
+		return nil, err
+	}
+	return i, nil
+}
+
+func (c *client) volumeAttachment(
+	instanceID, id string) (*volumeAttachment, error) {
+
+	a := &volumeAttachment{}
+	if err := c.do("GET", "/instances/"+instanceID+
+		"/volume_attachments/"+id, nil, a); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
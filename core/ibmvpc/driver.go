@@ -0,0 +1,587 @@
+package ibmvpc
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	"github.com/emccode/libstorage/api/context"
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/core/labels"
+	"github.com/emccode/rexray/core/migrate"
+	"github.com/emccode/rexray/core/topology"
+)
+
+type driver struct {
+	config  gofig.Config
+	api     *client
+	zone    string
+	timeout time.Duration
+}
+
+func newDriver() apitypes.StorageDriver {
+	return &driver{}
+}
+
+// newResizer returns a driver that resizes volumes for a client, which
+// reaches the libStorage server's driver only through the libStorage API.
+func newResizer(
+	ctx apitypes.Context, config gofig.Config) (migrate.VolumeResizer, error) {
+
+	d := &driver{}
+	if err := d.Init(ctx, config); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (d *driver) Name() string {
+	return Name
+}
+
+// Init creates a client of the configured region, or if there is none, of
+// the region of the instance REX-Ray runs on.
+func (d *driver) Init(ctx apitypes.Context, config gofig.Config) error {
+	d.config = config
+	d.zone = config.GetString("ibmvpc.zone")
+	region := config.GetString("ibmvpc.region")
+	if region == "" && d.zone != "" {
+		region = regionOf(d.zone)
+	}
+	if region == "" && config.GetString("ibmvpc.endpoint") == "" {
+		i, err := newMetadata(config).instance()
+		if err != nil {
+			return goof.WithFieldE("key", "ibmvpc.region",
+				"ibm cloud region is not configured", err)
+		}
+		region = regionOf(i.Zone.Name)
+	}
+	api, err := newClient(config, region)
+	if err != nil {
+		return err
+	}
+	d.api = api
+	d.timeout = 5 * time.Minute
+	if t, err := time.ParseDuration(
+		config.GetString("ibmvpc.timeout")); err == nil && t > 0 {
+		d.timeout = t
+	}
+
+	ctx.WithFields(map[string]interface{}{
+		"endpoint": d.api.endpoint,
+		"auth":     d.api.iam.auth,
+	}).Info("initialized ibmvpc driver")
+	return nil
+}
+
+// instanceID returns the ID of the instance on whose behalf an operation is
+// performed, or an empty string if there is none.
+func instanceID(ctx apitypes.Context) string {
+	iid, ok := ctx.Value(context.InstanceIDKey).(*apitypes.InstanceID)
+	if !ok {
+		return ""
+	}
+	return iid.ID
+}
+
+func (d *driver) Type(ctx apitypes.Context) (apitypes.StorageType, error) {
+	return apitypes.Block, nil
+}
+
+// NextDeviceInfo returns nil because the devices of attached volumes are
+// named by their serial numbers.
+func (d *driver) NextDeviceInfo(
+	ctx apitypes.Context) (*apitypes.NextDeviceInfo, error) {
+
+	return nil, nil
+}
+
+func (d *driver) InstanceInspect(
+	ctx apitypes.Context,
+	opts apitypes.Store) (*apitypes.Instance, error) {
+
+	iid, ok := ctx.Value(context.InstanceIDKey).(*apitypes.InstanceID)
+	if !ok {
+		return nil, goof.New("missing instance ID")
+	}
+	i, err := d.api.instance(iid.ID)
+	if err != nil {
+		return nil, goof.WithFieldE("instanceID", iid.ID,
+			"error inspecting instance", err)
+	}
+	return &apitypes.Instance{
+		InstanceID: iid,
+		Name:       i.Name,
+		Region:     regionOf(i.Zone.Name),
+	}, nil
+}
+
+// toVolume returns a libStorage volume for a VPC volume. The device of an
+// attachment to the instance in the operation's context is looked up by the
+// attachment's serial number.
+func (d *driver) toVolume(
+	ctx apitypes.Context, v *volume, attachments bool) *apitypes.Volume {
+
+	vol := &apitypes.Volume{
+		ID:               v.ID,
+		Name:             v.Name,
+		Size:             v.Capacity,
+		IOPS:             v.IOPS,
+		AvailabilityZone: v.Zone.Name,
+		Type:             v.Profile.Name,
+		Status:           v.Status,
+		Fields:           map[string]string{},
+	}
+	for i, t := range v.UserTags {
+		vol.Fields["tag."+strconv.Itoa(i)] = t
+	}
+	if !attachments {
+		return vol
+	}
+
+	ld, _ := ctx.Value(context.LocalDevicesKey).(*apitypes.LocalDevices)
+	iid := instanceID(ctx)
+	for _, a := range v.VolumeAttachments {
+		if a.Instance == nil {
+			continue
+		}
+		att := &apitypes.VolumeAttachment{
+			VolumeID: vol.ID,
+			InstanceID: &apitypes.InstanceID{
+				ID:     a.Instance.ID,
+				Driver: Name,
+			},
+			Status: "attached",
+			Fields: map[string]string{"attachmentID": a.ID},
+		}
+		if a.Instance.ID == iid && ld != nil {
+			att.DeviceName = ld.DeviceMap[serial(a.ID)]
+		}
+		vol.Attachments = append(vol.Attachments, att)
+	}
+	return vol
+}
+
+func (d *driver) Volumes(
+	ctx apitypes.Context,
+	opts *apitypes.VolumesOpts) ([]*apitypes.Volume, error) {
+
+	vols, err := d.api.volumes()
+	if err != nil {
+		return nil, err
+	}
+	all := []*apitypes.Volume{}
+	for _, v := range vols {
+		all = append(all, d.toVolume(ctx, v, opts.Attachments))
+	}
+	return all, nil
+}
+
+func (d *driver) VolumeInspect(
+	ctx apitypes.Context,
+	volumeID string,
+	opts *apitypes.VolumeInspectOpts) (*apitypes.Volume, error) {
+
+	v, err := d.volume(volumeID)
+	if err != nil {
+		return nil, err
+	}
+	return d.toVolume(ctx, v, opts.Attachments), nil
+}
+
+func (d *driver) volume(id string) (*volume, error) {
+	v, err := d.api.volume(id)
+	if isNotFound(err) {
+		return nil, goof.WithField("volumeID", id, "volume not found")
+	}
+	return v, err
+}
+
+// createZone returns the zone in which a volume is created: the requested
+// availability zone, or else the zone of the volume's selected topology
+// segment, or else the zone of the instance on whose behalf the volume is
+// created, or else the configured zone.
+func (d *driver) createZone(
+	ctx apitypes.Context, opts *apitypes.VolumeCreateOpts) (string, error) {
+
+	if opts.AvailabilityZone != nil && *opts.AvailabilityZone != "" {
+		return *opts.AvailabilityZone, nil
+	}
+	r, err := topology.FromOpts(opts.Opts)
+	if err != nil {
+		return "", err
+	}
+	if zone := r.Select()[topology.Zone]; zone != "" {
+		return zone, nil
+	}
+	if iid := instanceID(ctx); iid != "" {
+		i, err := d.api.instance(iid)
+		if err != nil {
+			return "", goof.WithFieldE("instanceID", iid,
+				"error inspecting instance", err)
+		}
+		return i.Zone.Name, nil
+	}
+	if d.zone != "" {
+		return d.zone, nil
+	}
+	return "", goof.New("ibmvpc volumes require a zone: the " +
+		"availability zone, the instance's zone, or ibmvpc.zone")
+}
+
+// createProfile returns the profile and IOPS of a new volume: those of its
+// options, or else those configured. The configured IOPS are only those of
+// volumes with the custom profile.
+func (d *driver) createProfile(
+	opts *apitypes.VolumeCreateOpts) (string, int64, error) {
+
+	profile := d.config.GetString("ibmvpc.profile")
+	iops := int64(0)
+	if opts.Opts != nil {
+		if p := opts.Opts.GetString(OptProfile); p != "" {
+			profile = p
+		}
+		if s := opts.Opts.GetString(OptIOPS); s != "" {
+			n, err := strconv.ParseInt(s, 10, 64)
+			if err != nil || n <= 0 {
+				return "", 0, goof.WithField(OptIOPS, s, "invalid iops")
+			}
+			iops = n
+		}
+	}
+	if opts.IOPS != nil && *opts.IOPS > 0 {
+		iops = *opts.IOPS
+	}
+	if profile == "" {
+		profile = defaultProfile
+	}
+	if iops == 0 && profile == ProfileCustom {
+		iops = int64(d.config.GetInt("ibmvpc.iops"))
+	}
+	if err := validateProfile(profile, iops); err != nil {
+		return "", 0, err
+	}
+	return profile, iops, nil
+}
+
+// validateProfile returns an error if IOPS are requested for a tiered
+// profile, whose IOPS are determined by a volume's size, or are not
+// requested for the custom profile.
+func validateProfile(profile string, iops int64) error {
+	switch {
+	case tieredProfiles[profile] && iops > 0:
+		return goof.WithFields(goof.Fields{
+			"profile": profile,
+			"iops":    iops,
+		}, "iops may not be requested for a tiered profile")
+	case profile == ProfileCustom && iops <= 0:
+		return goof.WithField("profile", profile,
+			"the custom profile requires iops")
+	}
+	return nil
+}
+
+// VolumeCreate creates a volume in the zone of the instance on whose behalf
+// it is created, unless another zone is requested, with the requested or
+// configured profile and the configured tags and the volume's labels as user
+// tags.
+func (d *driver) VolumeCreate(
+	ctx apitypes.Context,
+	name string,
+	opts *apitypes.VolumeCreateOpts) (*apitypes.Volume, error) {
+
+	zone, err := d.createZone(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	profile, iops, err := d.createProfile(opts)
+	if err != nil {
+		return nil, err
+	}
+	size := int64(defaultSize)
+	if opts.Size != nil && *opts.Size > 0 {
+		size = *opts.Size
+	}
+	l := labels.Labels{}
+	if opts.Opts != nil {
+		if l, err = labels.Parse(
+			[]string{opts.Opts.GetString(labels.OptKey)}); err != nil {
+			return nil, err
+		}
+	}
+
+	body := map[string]interface{}{
+		"name":      name,
+		"capacity":  size,
+		"profile":   reference{Name: profile},
+		"zone":      reference{Name: zone},
+		"user_tags": volumeTags(d.config, l),
+	}
+	if iops > 0 {
+		body["iops"] = iops
+	}
+	if rg := d.config.GetString("ibmvpc.resourceGroupID"); rg != "" {
+		body["resource_group"] = reference{ID: rg}
+	}
+	v := &volume{}
+	if err := d.api.do("POST", "/volumes", body, v); err != nil {
+		return nil, goof.WithFieldsE(goof.Fields{
+			"name":    name,
+			"zone":    zone,
+			"profile": profile,
+		}, "error creating volume", err)
+	}
+	if v, err = d.wait(v.ID, func(v *volume) bool {
+		return v.Status == "available"
+	}); err != nil {
+		return nil, err
+	}
+	return d.toVolume(ctx, v, false), nil
+}
+
+func (d *driver) VolumeCreateFromSnapshot(
+	ctx apitypes.Context,
+	snapshotID, volumeName string,
+	opts *apitypes.VolumeCreateOpts) (*apitypes.Volume, error) {
+
+	return nil, apitypes.ErrNotImplemented
+}
+
+func (d *driver) VolumeCopy(
+	ctx apitypes.Context,
+	volumeID, volumeName string,
+	opts apitypes.Store) (*apitypes.Volume, error) {
+
+	return nil, apitypes.ErrNotImplemented
+}
+
+func (d *driver) VolumeSnapshot(
+	ctx apitypes.Context,
+	volumeID, snapshotName string,
+	opts apitypes.Store) (*apitypes.Snapshot, error) {
+
+	return nil, apitypes.ErrNotImplemented
+}
+
+func (d *driver) VolumeRemove(
+	ctx apitypes.Context,
+	volumeID string,
+	opts apitypes.Store) error {
+
+	if err := d.api.do("DELETE", "/volumes/"+volumeID, nil, nil); err != nil {
+		return goof.WithFieldE("volumeID", volumeID,
+			"error removing volume", err)
+	}
+	return nil
+}
+
+// VolumeResize expands a volume, which must be attached to an instance. The
+// file system is grown by the node the next time the volume is mounted.
+func (d *driver) VolumeResize(
+	ctx apitypes.Context,
+	volumeID string,
+	size int64,
+	opts apitypes.Store) (*apitypes.Volume, error) {
+
+	v, err := d.volume(volumeID)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.api.do("PATCH", "/volumes/"+v.ID,
+		map[string]interface{}{"capacity": size}, nil); err != nil {
+		return nil, goof.WithFieldsE(goof.Fields{
+			"volumeID": volumeID,
+			"size":     size,
+		}, "error resizing volume", err)
+	}
+	if v, err = d.wait(v.ID, func(v *volume) bool {
+		return v.Capacity >= size && v.Status == "available"
+	}); err != nil {
+		return nil, err
+	}
+	return d.toVolume(ctx, v, true), nil
+}
+
+// VolumeAttach attaches a volume to the instance, which must be in the
+// volume's zone. The returned token is the attachment's serial number, with
+// which the node's device for the volume is looked up. A forced attach first
+// detaches the volume from other instances.
+func (d *driver) VolumeAttach(
+	ctx apitypes.Context,
+	volumeID string,
+	opts *apitypes.VolumeAttachOpts) (*apitypes.Volume, string, error) {
+
+	iid := instanceID(ctx)
+	if iid == "" {
+		return nil, "", goof.New("missing instance ID")
+	}
+	v, err := d.volume(volumeID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	for _, a := range v.VolumeAttachments {
+		switch {
+		case a.Instance == nil:
+		case a.Instance.ID == iid:
+			return d.toVolume(ctx, v, true), serial(a.ID), nil
+		case !opts.Force:
+			return nil, "", goof.WithFields(goof.Fields{
+				"volumeID":   volumeID,
+				"instanceID": a.Instance.ID,
+			}, "volume is attached to another instance")
+		default:
+			if err := d.detach(a.Instance.ID, a.ID); err != nil {
+				return nil, "", err
+			}
+		}
+	}
+
+	a := &volumeAttachment{}
+	if err := d.api.do("POST", "/instances/"+iid+"/volume_attachments",
+		map[string]interface{}{
+			"volume":                           reference{ID: v.ID},
+			"delete_volume_on_instance_delete": false,
+		}, a); err != nil {
+		return nil, "", goof.WithFieldsE(goof.Fields{
+			"volumeID":   volumeID,
+			"instanceID": iid,
+		}, "error attaching volume", err)
+	}
+	if err := d.waitAttachment(iid, a.ID, true); err != nil {
+		return nil, "", err
+	}
+	if v, err = d.api.volume(v.ID); err != nil {
+		return nil, "", err
+	}
+	return d.toVolume(ctx, v, true), serial(a.ID), nil
+}
+
+// VolumeDetach detaches a volume from the instance, or from every instance
+// if the detach is forced.
+func (d *driver) VolumeDetach(
+	ctx apitypes.Context,
+	volumeID string,
+	opts *apitypes.VolumeDetachOpts) (*apitypes.Volume, error) {
+
+	v, err := d.volume(volumeID)
+	if err != nil {
+		return nil, err
+	}
+	iid := instanceID(ctx)
+	detached := false
+	for _, a := range v.VolumeAttachments {
+		if a.Instance == nil || (a.Instance.ID != iid && !opts.Force) {
+			continue
+		}
+		if err := d.detach(a.Instance.ID, a.ID); err != nil {
+			return nil, err
+		}
+		detached = true
+	}
+	if detached {
+		if v, err = d.api.volume(v.ID); err != nil {
+			return nil, err
+		}
+	}
+	return d.toVolume(ctx, v, true), nil
+}
+
+func (d *driver) detach(instanceID, attachmentID string) error {
+	if err := d.api.do("DELETE", "/instances/"+instanceID+
+		"/volume_attachments/"+attachmentID, nil, nil); err != nil &&
+		!isNotFound(err) {
+		return goof.WithFieldsE(goof.Fields{
+			"instanceID":   instanceID,
+			"attachmentID": attachmentID,
+		}, "error detaching volume", err)
+	}
+	return d.waitAttachment(instanceID, attachmentID, false)
+}
+
+// waitAttachment polls an attachment until it is attached, or removed if the
+// flag is false.
+func (d *driver) waitAttachment(
+	instanceID, id string, attached bool) error {
+
+	deadline := time.Now().Add(d.timeout)
+	for {
+		a, err := d.api.volumeAttachment(instanceID, id)
+		switch {
+		case isNotFound(err) && !attached:
+			return nil
+		case err != nil:
+			return err
+		case attached && a.Status == "attached":
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return goof.WithFields(goof.Fields{
+				"instanceID":   instanceID,
+				"attachmentID": id,
+				"status":       a.Status,
+				"timeout":      d.timeout,
+			}, "timed out waiting for volume attachment")
+		}
+		time.Sleep(2 * time.Second)
+	}
+}
+
+// wait polls a volume until the condition is met since the API creates and
+// resizes volumes asynchronously.
+func (d *driver) wait(id string, cond func(v *volume) bool) (*volume, error) {
+	deadline := time.Now().Add(d.timeout)
+	for {
+		v, err := d.api.volume(id)
+		if err != nil {
+			return nil, err
+		}
+		if cond(v) {
+			return v, nil
+		}
+		if v.Status == "failed" {
+			return nil, goof.WithField("volumeID", id, "volume failed")
+		}
+		if time.Now().After(deadline) {
+			return nil, goof.WithFields(goof.Fields{
+				"volumeID": id,
+				"status":   v.Status,
+				"timeout":  d.timeout,
+			}, "timed out waiting for volume")
+		}
+		time.Sleep(2 * time.Second)
+	}
+}
+
+func (d *driver) Snapshots(
+	ctx apitypes.Context,
+	opts apitypes.Store) ([]*apitypes.Snapshot, error) {
+
+	return nil, apitypes.ErrNotImplemented
+}
+
+func (d *driver) SnapshotInspect(
+	ctx apitypes.Context,
+	snapshotID string,
+	opts apitypes.Store) (*apitypes.Snapshot, error) {
+
+	return nil, apitypes.ErrNotImplemented
+}
+
+func (d *driver) SnapshotCopy(
+	ctx apitypes.Context,
+	snapshotID, snapshotName, destinationID string,
+	opts apitypes.Store) (*apitypes.Snapshot, error) {
+
+	return nil, apitypes.ErrNotImplemented
+}
+
+func (d *driver) SnapshotRemove(
+	ctx apitypes.Context,
+	snapshotID string,
+	opts apitypes.Store) error {
+
+	return apitypes.ErrNotImplemented
+}
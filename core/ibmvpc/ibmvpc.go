@@ -0,0 +1,219 @@
+// Package ibmvpc is a storage driver for IBM Cloud Block Storage for VPC.
+// Volumes are managed with the VPC API, which is called with IAM access
+// tokens obtained with an API key or, on a VPC instance, with a trusted
+// profile. Volumes are attached to instances with volume attachments and an
+// attached volume appears on the node as /dev/disk/by-id/virtio-SERIAL, where
+// the serial is the first 20 characters of the attachment's ID.
+package ibmvpc
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/akutz/gofig"
+	"github.com/emccode/libstorage/api/registry"
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/core/labels"
+	"github.com/emccode/rexray/core/migrate"
+	"github.com/emccode/rexray/core/topology"
+)
+
+const (
+	// Name is the name with which the driver is registered.
+	Name = "ibmvpc"
+
+	// AuthAPIKey authenticates with an IAM API key.
+	AuthAPIKey = "apiKey"
+
+	// AuthTrustedProfile authenticates as a trusted profile with the
+	// identity token of the instance REX-Ray runs on.
+	AuthTrustedProfile = "trustedProfile"
+
+	// OptProfile is the create option with which a volume's profile is
+	// requested.
+	OptProfile = "profile"
+
+	// OptIOPS is the create option with which the IOPS of a volume with the
+	// custom profile are requested.
+	OptIOPS = "iops"
+
+	// ProfileCustom is the profile of volumes whose IOPS are requested.
+	ProfileCustom = "custom"
+
+	defaultIAMEndpoint      = "https://iam.cloud.ibm.com"
+	defaultMetadataEndpoint = "http://169.254.169.254"
+	defaultProfile          = "general-purpose"
+	defaultSize             = 10
+
+	byIDDir      = "/dev/disk/by-id"
+	byIDPrefix   = "virtio-"
+	partitionSep = "-part"
+
+	// serialLen is the length of the serial numbers of attached volumes,
+	// which are the prefixes of their attachments' IDs.
+	serialLen = 20
+)
+
+// tieredProfiles are the profiles whose IOPS are determined by the sizes of
+// volumes and may not be requested.
+var tieredProfiles = map[string]bool{
+	"general-purpose": true,
+	"5iops-tier":      true,
+	"10iops-tier":     true,
+}
+
+func init() {
+	registry.RegisterStorageDriver(Name, newDriver)
+	registry.RegisterStorageExecutor(Name, newExecutor)
+	topology.Register(Name, instanceTopology)
+	migrate.Register(Name, newResizer)
+
+	r := gofig.NewRegistration("IBM Cloud VPC Driver")
+	r.Key(gofig.String, "", AuthAPIKey,
+		"How the driver authenticates: apiKey or trustedProfile",
+		"ibmvpc.auth")
+	r.Key(gofig.String, "", "",
+		"The IAM API key with which the VPC API is called",
+		"ibmvpc.apiKey")
+	r.Key(gofig.String, "", "",
+		"The ID of the trusted profile the instance authenticates as",
+		"ibmvpc.trustedProfileID")
+	r.Key(gofig.String, "", defaultIAMEndpoint,
+		"The URL of the IAM token service",
+		"ibmvpc.iamEndpoint")
+	r.Key(gofig.String, "", "",
+		"The region; defaults to the region of the instance's zone",
+		"ibmvpc.region")
+	r.Key(gofig.String, "", "",
+		"The URL of the VPC API; defaults to the region's endpoint",
+		"ibmvpc.endpoint")
+	r.Key(gofig.String, "", "",
+		"The zone of volumes created without an instance",
+		"ibmvpc.zone")
+	r.Key(gofig.String, "", "",
+		"The ID of the resource group of new volumes",
+		"ibmvpc.resourceGroupID")
+	r.Key(gofig.String, "", defaultProfile,
+		"The profile of new volumes, ex. general-purpose, 5iops-tier, "+
+			"10iops-tier, or custom",
+		"ibmvpc.profile")
+	r.Key(gofig.Int, "", 0,
+		"The IOPS of new volumes with the custom profile",
+		"ibmvpc.iops")
+	r.Key(gofig.String, "", "",
+		"The user tags, separated by commas, applied to new volumes",
+		"ibmvpc.tags")
+	r.Key(gofig.String, "", "5m",
+		"How long to wait for a volume to be created, attached, or detached",
+		"ibmvpc.timeout")
+	r.Key(gofig.String, "", "",
+		"The node's instance ID if not read from the metadata service",
+		"ibmvpc.instanceID")
+	r.Key(gofig.String, "", defaultMetadataEndpoint,
+		"The URL of the VPC Instance Metadata service",
+		"ibmvpc.metadataEndpoint")
+	gofig.Register(r)
+}
+
+// instanceTopology returns the region and zone of the node's instance.
+func instanceTopology(
+	ctx apitypes.Context, config gofig.Config) (topology.Segment, error) {
+
+	i, err := newMetadata(config).instance()
+	if err != nil {
+		return nil, err
+	}
+	return topology.Segment{
+		topology.Region: regionOf(i.Zone.Name),
+		topology.Zone:   i.Zone.Name,
+	}, nil
+}
+
+// regionOf returns the region of a zone, ex. us-south of us-south-1.
+func regionOf(zone string) string {
+	if i := strings.LastIndex(zone, "-"); i > 0 {
+		return zone[:i]
+	}
+	return zone
+}
+
+// volumeTags returns the user tags of a new volume: the configured tags and
+// the volume's labels as key:value pairs.
+func volumeTags(config gofig.Config, l labels.Labels) []string {
+	tags := []string{}
+	for _, t := range strings.Split(config.GetString("ibmvpc.tags"), ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			tags = append(tags, t)
+		}
+	}
+	pairs := []string{}
+	for k, v := range l {
+		pairs = append(pairs, k+":"+v)
+	}
+	sort.Strings(pairs)
+	return append(tags, pairs...)
+}
+
+// serial returns the serial number with which a volume attached with an
+// attachment appears on the node.
+func serial(attachmentID string) string {
+	if len(attachmentID) > serialLen {
+		return attachmentID[:serialLen]
+	}
+	return attachmentID
+}
+
+// parseByID returns the serial number of the volume named by a link in
+// /dev/disk/by-id, ex. virtio-0717-1b2c3d4e-5f6a-7. The returned flag is
+// false if the link is not to a volume or is to a partition of one.
+func parseByID(name string) (string, bool) {
+	if !strings.HasPrefix(name, byIDPrefix) || isPartition(name) {
+		return "", false
+	}
+	s := strings.TrimPrefix(name, byIDPrefix)
+	return s, s != ""
+}
+
+// isPartition returns a flag indicating whether a link in /dev/disk/by-id is
+// to a partition, ex. virtio-0717-1b2c3d4e-5f6a-7-part1.
+func isPartition(name string) bool {
+	i := strings.LastIndex(name, partitionSep)
+	if i < 0 || i+len(partitionSep) == len(name) {
+		return false
+	}
+	for _, r := range name[i+len(partitionSep):] {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// devices returns the devices of the volumes attached to the node keyed by
+// their serial numbers.
+func devices(dir string) (map[string]string, error) {
+	devs := map[string]string{}
+	fis, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return devs, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, fi := range fis {
+		s, ok := parseByID(fi.Name())
+		if !ok {
+			continue
+		}
+		dev, err := filepath.EvalSymlinks(filepath.Join(dir, fi.Name()))
+		if err != nil {
+			continue
+		}
+		devs[s] = dev
+	}
+	return devs, nil
+}
@@ -0,0 +1,119 @@
+package ibmvpc
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseByID(t *testing.T) {
+	if s, ok := parseByID("virtio-0717-1b2c3d4e-5f6a-7"); !ok ||
+		s != "0717-1b2c3d4e-5f6a-7" {
+		t.Fatalf("serial=%s ok=%v", s, ok)
+	}
+	for _, name := range []string{
+		"virtio-0717-1b2c3d4e-5f6a-7-part1",
+		"virtio-",
+		"wwn-0x600a0b800012345600000000deadbeef",
+	} {
+		if _, ok := parseByID(name); ok {
+			t.Fatalf("name=%s", name)
+		}
+	}
+	if s := serial("0717-1b2c3d4e-5f6a-7b8c-9d0e-1f2a3b4c5d6e"); s !=
+		"0717-1b2c3d4e-5f6a-7" {
+		t.Fatalf("serial=%s", s)
+	}
+}
+
+func TestDevices(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ibmvpc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	dev := filepath.Join(dir, "vdd")
+	if err := ioutil.WriteFile(dev, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{
+		"virtio-0717-1b2c3d4e-5f6a-7", "virtio-0717-1b2c3d4e-5f6a-7-part1",
+	} {
+		if err := os.Symlink(dev, filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	devs, err := devices(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(devs) != 1 || devs["0717-1b2c3d4e-5f6a-7"] != dev {
+		t.Fatalf("devs=%v", devs)
+	}
+}
+
+func TestValidateProfile(t *testing.T) {
+	if regionOf("us-south-1") != "us-south" {
+		t.Fatal(regionOf("us-south-1"))
+	}
+	for _, tt := range []struct {
+		profile string
+		iops    int64
+		ok      bool
+	}{
+		{"general-purpose", 0, true},
+		{"10iops-tier", 0, true},
+		{"10iops-tier", 1000, false},
+		{ProfileCustom, 0, false},
+		{ProfileCustom, 3000, true},
+	} {
+		if err := validateProfile(tt.profile, tt.iops); (err == nil) != tt.ok {
+			t.Fatalf("profile=%s iops=%d err=%v", tt.profile, tt.iops, err)
+		}
+	}
+}
+
+func TestClientVolumes(t *testing.T) {
+	var s *httptest.Server
+	s = httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "Bearer secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte(`{"errors":[{"message":"Expired token"}]}`))
+				return
+			}
+			if r.URL.Query().Get("start") == "" {
+				w.Write([]byte(`{"volumes":[{"id":"r006-1","name":"data",` +
+					`"volume_attachments":[{"id":"0717-a",` +
+					`"instance":{"id":"0717_i"}}]}],` +
+					`"next":{"href":"` + s.URL + `/volumes?start=abc"}}`))
+				return
+			}
+			w.Write([]byte(`{"volumes":[{"id":"r006-2","name":"logs"}]}`))
+		}))
+	defer s.Close()
+
+	i := &iam{auth: AuthAPIKey, token: "secret",
+		expiry: time.Now().Add(time.Hour)}
+	c := &client{endpoint: s.URL, iam: i, client: http.DefaultClient}
+	vols, err := c.volumes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vols) != 2 || len(vols[0].VolumeAttachments) != 1 ||
+		vols[0].VolumeAttachments[0].Instance.ID != "0717_i" {
+		t.Fatalf("vols=%v", vols)
+	}
+
+	i.token = "invalid"
+	if _, err := c.volumes(); err == nil ||
+		err.Error() != "ibmvpc: 401 Expired token" {
+		t.Fatalf("err=%v", err)
+	}
+}
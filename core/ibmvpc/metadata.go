@@ -0,0 +1,126 @@
+package ibmvpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+)
+
+const metadataVersion = "2022-03-01"
+
+// metadata is a client for the VPC Instance Metadata service, which must be
+// enabled on the instance.
+type metadata struct {
+	endpoint string
+	client   *http.Client
+}
+
+func newMetadata(config gofig.Config) *metadata {
+	endpoint := strings.TrimSuffix(
+		config.GetString("ibmvpc.metadataEndpoint"), "/")
+	if endpoint == "" {
+		endpoint = defaultMetadataEndpoint
+	}
+	return &metadata{
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// identityToken returns a token of the instance's identity.
+func (m *metadata) identityToken() (string, error) {
+	res := &struct {
+		AccessToken string `json:"access_token"`
+	}{}
+	if err := m.do("PUT", "/instance_identity/v1/token", "",
+		map[string]interface{}{"expires_in": 300}, res); err != nil {
+		return "", err
+	}
+	return res.AccessToken, nil
+}
+
+// instance returns the instance the node runs on.
+func (m *metadata) instance() (*instance, error) {
+	token, err := m.identityToken()
+	if err != nil {
+		return nil, err
+	}
+	i := &instance{}
+	if err := m.do("GET", "/metadata/v1/instance", token, nil, i); err != nil {
+		return nil, err
+	}
+	return i, nil
+}
+
+// iamToken exchanges the instance's identity for an IAM access token of a
+// trusted profile, or of the instance's default trusted profile if the
+// profile ID is empty.
+func (m *metadata) iamToken(profileID string) (string, time.Time, error) {
+	token, err := m.identityToken()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	var body interface{}
+	if profileID != "" {
+		body = map[string]interface{}{
+			"trusted_profile": map[string]string{"id": profileID},
+		}
+	}
+	res := &struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}{}
+	if err := m.do("POST", "/instance_identity/v1/iam_token", token,
+		body, res); err != nil {
+		return "", time.Time{}, err
+	}
+	return res.AccessToken,
+		time.Now().Add(time.Duration(res.ExpiresIn) * time.Second), nil
+}
+
+func (m *metadata) do(
+	method, path, token string, body, result interface{}) error {
+
+	var buf []byte
+	if body != nil {
+		var err error
+		if buf, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequest(method,
+		m.endpoint+path+"?version="+metadataVersion, bytes.NewReader(buf))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	if token == "" {
+		req.Header.Set("Metadata-Flavor", "ibm")
+	} else {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	res, err := m.client.Do(req)
+	if err != nil {
+		return goof.WithFieldE("endpoint", m.endpoint,
+			"error reading ibm cloud instance metadata", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return goof.WithFields(goof.Fields{
+			"path":   path,
+			"status": res.StatusCode,
+		}, "error reading ibm cloud instance metadata")
+	}
+	if err := json.NewDecoder(res.Body).Decode(result); err != nil {
+		return goof.WithFieldE("path", path,
+			"error decoding ibm cloud instance metadata", err)
+	}
+	return nil
+}
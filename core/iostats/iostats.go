@@ -0,0 +1,260 @@
+// Package iostats samples the I/O statistics of the devices that back the
+// volumes mounted on a node, as reported by /proc/diskstats, and computes
+// each volume's IOPS, throughput, and average latency between samples.
+package iostats
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/akutz/gofig"
+
+	"github.com/emccode/rexray/core/metrics"
+)
+
+const (
+	diskstatsFile = "/proc/diskstats"
+
+	// sectorSize is the size of the sectors counted by /proc/diskstats,
+	// which is 512 bytes regardless of the device's sector size.
+	sectorSize = 512
+)
+
+func init() {
+	r := gofig.NewRegistration("Volume I/O Statistics")
+	r.Key(gofig.Bool, "", true,
+		"Sample the I/O statistics of the volumes mounted on the node",
+		"rexray.volume.iostats.enabled")
+	r.Key(gofig.String, "", "15s",
+		"The interval at which the I/O statistics of volumes are sampled",
+		"rexray.volume.iostats.interval")
+	gofig.Register(r)
+}
+
+// Interval returns the interval at which the I/O statistics of volumes are
+// sampled.
+func Interval(config gofig.Config) time.Duration {
+	d, err := time.ParseDuration(
+		config.GetString("rexray.volume.iostats.interval"))
+	if err != nil || d <= 0 {
+		return 15 * time.Second
+	}
+	return d
+}
+
+// Volume is a mounted volume and the device that backs it.
+type Volume struct {
+	ID     string
+	Name   string
+	Driver string
+
+	// Device is the path of the volume's device, ex. /dev/xvdf. Links, such
+	// as those in /dev/mapper, are resolved to the device they name.
+	Device string
+}
+
+// Stats are the I/O statistics of a volume over the interval between two
+// samples. Latencies are the average time an operation took to complete.
+type Stats struct {
+	VolumeID   string    `json:"volumeID" yaml:"volumeID"`
+	VolumeName string    `json:"volumeName" yaml:"volumeName"`
+	Driver     string    `json:"driver" yaml:"driver"`
+	Device     string    `json:"device" yaml:"device"`
+	Sampled    time.Time `json:"sampled" yaml:"sampled"`
+	Interval   float64   `json:"intervalSeconds" yaml:"intervalSeconds"`
+
+	ReadIOPS         float64 `json:"readIOPS" yaml:"readIOPS"`
+	WriteIOPS        float64 `json:"writeIOPS" yaml:"writeIOPS"`
+	ReadBytesPerSec  float64 `json:"readBytesPerSecond" yaml:"readBytesPerSecond"`
+	WriteBytesPerSec float64 `json:"writeBytesPerSecond" yaml:"writeBytesPerSecond"`
+	ReadLatencySecs  float64 `json:"readLatencySeconds" yaml:"readLatencySeconds"`
+	WriteLatencySecs float64 `json:"writeLatencySeconds" yaml:"writeLatencySeconds"`
+}
+
+// counters are the cumulative statistics of a device in /proc/diskstats.
+type counters struct {
+	readOps      uint64
+	readSectors  uint64
+	readMillis   uint64
+	writeOps     uint64
+	writeSectors uint64
+	writeMillis  uint64
+}
+
+// parseDiskstats returns the counters of each device in the contents of
+// /proc/diskstats, keyed by the device's name, ex. xvdf or dm-0.
+func parseDiskstats(r io.Reader) map[string]*counters {
+	all := map[string]*counters{}
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		f := strings.Fields(s.Text())
+		if len(f) < 11 {
+			continue
+		}
+		v := make([]uint64, 8)
+		ok := true
+		for i := range v {
+			var err error
+			if v[i], err = strconv.ParseUint(f[i+3], 10, 64); err != nil {
+				ok = false
+				break
+			}
+		}
+		if !ok {
+			continue
+		}
+		all[f[2]] = &counters{
+			readOps:      v[0],
+			readSectors:  v[2],
+			readMillis:   v[3],
+			writeOps:     v[4],
+			writeSectors: v[6],
+			writeMillis:  v[7],
+		}
+	}
+	return all
+}
+
+// delta returns the difference between two values of a counter, or zero if
+// the counter was reset, such as when the device was detached and another
+// device was given its name.
+func delta(prev, cur uint64) float64 {
+	if cur < prev {
+		return 0
+	}
+	return float64(cur - prev)
+}
+
+func latency(millis, ops float64) float64 {
+	if ops == 0 {
+		return 0
+	}
+	return millis / ops / 1000
+}
+
+// Sampler computes the statistics of volumes from successive samples of
+// their devices' counters.
+type Sampler struct {
+	statsRwl sync.RWMutex
+	prev     map[string]*counters
+	prevAt   time.Time
+	stats    []*Stats
+}
+
+var defaultSampler = NewSampler()
+
+// NewSampler returns a new sampler.
+func NewSampler() *Sampler {
+	return &Sampler{prev: map[string]*counters{}, stats: []*Stats{}}
+}
+
+// Default returns the sampler whose statistics the REX-Ray service exposes.
+func Default() *Sampler {
+	return defaultSampler
+}
+
+// Stats returns the statistics computed by the most recent sample.
+func (s *Sampler) Stats() []*Stats {
+	s.statsRwl.RLock()
+	defer s.statsRwl.RUnlock()
+	return s.stats
+}
+
+// Sample reads /proc/diskstats and returns the statistics of the volumes
+// since the previous sample. Volumes whose devices were not present in the
+// previous sample are omitted until the next one. The statistics are also
+// recorded as metrics in the provided registry.
+func (s *Sampler) Sample(vols []*Volume, r *metrics.Registry) ([]*Stats, error) {
+	f, err := os.Open(diskstatsFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	stats := s.sample(vols, parseDiskstats(f), time.Now())
+	record(r, stats)
+	return stats, nil
+}
+
+func (s *Sampler) sample(
+	vols []*Volume, cur map[string]*counters, now time.Time) []*Stats {
+
+	s.statsRwl.Lock()
+	defer s.statsRwl.Unlock()
+
+	stats := []*Stats{}
+	secs := now.Sub(s.prevAt).Seconds()
+	for _, v := range vols {
+		dev := deviceName(v.Device)
+		c, ok := cur[dev]
+		if !ok {
+			continue
+		}
+		p, ok := s.prev[dev]
+		if !ok || secs <= 0 {
+			continue
+		}
+		readOps := delta(p.readOps, c.readOps)
+		writeOps := delta(p.writeOps, c.writeOps)
+		stats = append(stats, &Stats{
+			VolumeID:         v.ID,
+			VolumeName:       v.Name,
+			Driver:           v.Driver,
+			Device:           v.Device,
+			Sampled:          now.UTC(),
+			Interval:         secs,
+			ReadIOPS:         readOps / secs,
+			WriteIOPS:        writeOps / secs,
+			ReadBytesPerSec:  delta(p.readSectors, c.readSectors) * sectorSize / secs,
+			WriteBytesPerSec: delta(p.writeSectors, c.writeSectors) * sectorSize / secs,
+			ReadLatencySecs:  latency(delta(p.readMillis, c.readMillis), readOps),
+			WriteLatencySecs: latency(delta(p.writeMillis, c.writeMillis), writeOps),
+		})
+	}
+	sort.Sort(byVolumeName(stats))
+
+	s.prev, s.prevAt, s.stats = cur, now, stats
+	return stats
+}
+
+// deviceName returns the name of a device in /proc/diskstats given its path.
+func deviceName(device string) string {
+	if path, err := filepath.EvalSymlinks(device); err == nil {
+		device = path
+	}
+	return filepath.Base(device)
+}
+
+// record replaces the volume I/O metrics with the statistics so that the
+// metrics of volumes that were unmounted are no longer reported.
+func record(r *metrics.Registry, stats []*Stats) {
+	r.Reset(metrics.VolumeIOPS)
+	r.Reset(metrics.VolumeThroughput)
+	r.Reset(metrics.VolumeLatency)
+	for _, st := range stats {
+		r.Set(metrics.VolumeIOPS, st.ReadIOPS,
+			st.VolumeName, st.Driver, metrics.DirectionRead)
+		r.Set(metrics.VolumeIOPS, st.WriteIOPS,
+			st.VolumeName, st.Driver, metrics.DirectionWrite)
+		r.Set(metrics.VolumeThroughput, st.ReadBytesPerSec,
+			st.VolumeName, st.Driver, metrics.DirectionRead)
+		r.Set(metrics.VolumeThroughput, st.WriteBytesPerSec,
+			st.VolumeName, st.Driver, metrics.DirectionWrite)
+		r.Set(metrics.VolumeLatency, st.ReadLatencySecs,
+			st.VolumeName, st.Driver, metrics.DirectionRead)
+		r.Set(metrics.VolumeLatency, st.WriteLatencySecs,
+			st.VolumeName, st.Driver, metrics.DirectionWrite)
+	}
+}
+
+type byVolumeName []*Stats
+
+func (s byVolumeName) Len() int           { return len(s) }
+func (s byVolumeName) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byVolumeName) Less(i, j int) bool { return s[i].VolumeName < s[j].VolumeName }
@@ -0,0 +1,50 @@
+package iostats
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const diskstats = `
+ 202       0 xvda 8823 12 453120 3021 2200 310 90112 4410 0 5120 7431
+ 202      80 xvdf 100 0 2048 200 50 0 1024 500 0 600 700
+ 253       0 dm-0 10 0 80 10 20 0 160 40 0 30 50
+`
+
+func TestSample(t *testing.T) {
+	s := NewSampler()
+	vols := []*Volume{
+		{ID: "vol-1", Name: "data", Driver: "ebs", Device: "/dev/xvdf"},
+		{ID: "vol-2", Name: "logs", Driver: "ebs", Device: "/dev/xvdz"},
+	}
+	now := time.Date(2017, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	if stats := s.sample(vols, parseDiskstats(
+		strings.NewReader(diskstats)), now); len(stats) != 0 {
+		t.Fatalf("expected no stats from first sample, got %d", len(stats))
+	}
+
+	next := strings.Replace(diskstats,
+		"xvdf 100 0 2048 200 50 0 1024 500",
+		"xvdf 300 0 6144 600 50 0 1024 500", 1)
+	stats := s.sample(vols, parseDiskstats(
+		strings.NewReader(next)), now.Add(10*time.Second))
+	if len(stats) != 1 {
+		t.Fatalf("expected stats of one volume, got %d", len(stats))
+	}
+	st := stats[0]
+	if st.VolumeName != "data" || st.Driver != "ebs" {
+		t.Fatalf("unexpected volume %s/%s", st.Driver, st.VolumeName)
+	}
+	if st.ReadIOPS != 20 || st.WriteIOPS != 0 {
+		t.Fatalf("unexpected iops %v/%v", st.ReadIOPS, st.WriteIOPS)
+	}
+	if st.ReadBytesPerSec != 4096*sectorSize/10.0 {
+		t.Fatalf("unexpected read throughput %v", st.ReadBytesPerSec)
+	}
+	if st.ReadLatencySecs != 0.002 || st.WriteLatencySecs != 0 {
+		t.Fatalf("unexpected latency %v/%v",
+			st.ReadLatencySecs, st.WriteLatencySecs)
+	}
+}
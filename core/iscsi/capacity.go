@@ -0,0 +1,61 @@
+package iscsi
+
+import (
+	"strings"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/core/capacity"
+)
+
+func init() {
+	capacity.Register(Name, poolCapacity)
+}
+
+// pool is a pool from which LUNs are provisioned, as returned by pool_list.
+type pool struct {
+	Name     string `json:"name"`
+	Size     int64  `json:"size"`
+	FreeSize int64  `json:"free_size"`
+	Type     string `json:"type"`
+}
+
+// Capacity returns the size and free space of the driver's pool.
+func (d *driver) Capacity(
+	ctx apitypes.Context,
+	opts apitypes.Store) ([]*capacity.Capacity, error) {
+
+	return listCapacity(d.rpc, d.pool)
+}
+
+// poolCapacity returns the size and free space of the configured pool
+// without an initialized driver.
+func poolCapacity(
+	ctx apitypes.Context, config gofig.Config) ([]*capacity.Capacity, error) {
+
+	rpc, err := newCaller(config)
+	if err != nil {
+		return nil, err
+	}
+	return listCapacity(rpc, poolName(config))
+}
+
+func listCapacity(rpc caller, name string) ([]*capacity.Capacity, error) {
+	pools := []*pool{}
+	if err := rpc.call("pool_list", nil, &pools); err != nil {
+		return nil, err
+	}
+	for _, p := range pools {
+		if !strings.EqualFold(p.Name, name) {
+			continue
+		}
+		return []*capacity.Capacity{{
+			TotalBytes:     p.Size,
+			AvailableBytes: p.FreeSize,
+			Topology:       map[string]string{capacity.TopologyPool: p.Name},
+		}}, nil
+	}
+	return nil, goof.WithField("pool", name, "pool not found")
+}
@@ -0,0 +1,382 @@
+package iscsi
+
+import (
+	"strconv"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	"github.com/emccode/libstorage/api/context"
+	apitypes "github.com/emccode/libstorage/api/types"
+)
+
+const gib = 1024 * 1024 * 1024
+
+type driver struct {
+	config gofig.Config
+	rpc    caller
+	pool   string
+	target string
+}
+
+func newDriver() apitypes.StorageDriver {
+	return &driver{}
+}
+
+func (d *driver) Name() string {
+	return Name
+}
+
+// Init provisions LUNs with the configured hook if there is one, and
+// otherwise with targetd.
+func (d *driver) Init(ctx apitypes.Context, config gofig.Config) error {
+	d.config = config
+	d.pool = poolName(config)
+	if d.target = config.GetString("iscsi.target"); d.target == "" {
+		return goof.New("iscsi driver requires iscsi.target")
+	}
+
+	rpc, err := newCaller(config)
+	if err != nil {
+		return err
+	}
+	d.rpc = rpc
+
+	ctx.WithFields(map[string]interface{}{
+		"target": d.target,
+		"pool":   d.pool,
+	}).Info("initialized iscsi driver")
+	return nil
+}
+
+// instanceID returns the initiator name of the instance on whose behalf an
+// operation is performed.
+func instanceID(ctx apitypes.Context) string {
+	if iid, ok := ctx.Value(context.InstanceIDKey).(*apitypes.InstanceID); ok {
+		return iid.ID
+	}
+	return ""
+}
+
+func (d *driver) Type(ctx apitypes.Context) (apitypes.StorageType, error) {
+	return apitypes.Block, nil
+}
+
+// NextDeviceInfo returns nil because the names of the devices of attached
+// LUNs are chosen by the node's SCSI subsystem.
+func (d *driver) NextDeviceInfo(
+	ctx apitypes.Context) (*apitypes.NextDeviceInfo, error) {
+
+	return nil, nil
+}
+
+func (d *driver) InstanceInspect(
+	ctx apitypes.Context,
+	opts apitypes.Store) (*apitypes.Instance, error) {
+
+	iid, ok := ctx.Value(context.InstanceIDKey).(*apitypes.InstanceID)
+	if !ok {
+		return nil, goof.New("missing instance ID")
+	}
+	return &apitypes.Instance{InstanceID: iid, Name: iid.ID}, nil
+}
+
+func (d *driver) listVolumes() ([]*volume, error) {
+	vols := []*volume{}
+	err := d.rpc.call("vol_list", map[string]interface{}{"pool": d.pool}, &vols)
+	return vols, err
+}
+
+func (d *driver) listExports() ([]*export, error) {
+	exports := []*export{}
+	if err := d.rpc.call("export_list", nil, &exports); err != nil {
+		return nil, err
+	}
+	inPool := []*export{}
+	for _, e := range exports {
+		if e.Pool == "" || e.Pool == d.pool {
+			inPool = append(inPool, e)
+		}
+	}
+	return inPool, nil
+}
+
+// toVolume returns a libStorage volume for a LUN. A LUN's name is its ID
+// because targetd's methods refer to LUNs by name.
+func (d *driver) toVolume(
+	ctx apitypes.Context, v *volume, exports []*export) *apitypes.Volume {
+
+	vol := &apitypes.Volume{
+		ID:     v.Name,
+		Name:   v.Name,
+		Size:   v.Size / gib,
+		Type:   d.pool,
+		Status: "available",
+		Fields: map[string]string{"uuid": v.UUID},
+	}
+
+	iid := instanceID(ctx)
+	ld, _ := ctx.Value(context.LocalDevicesKey).(*apitypes.LocalDevices)
+	for _, e := range exports {
+		if e.VolumeName != v.Name {
+			continue
+		}
+		att := &apitypes.VolumeAttachment{
+			VolumeID: v.Name,
+			InstanceID: &apitypes.InstanceID{
+				ID:     e.InitiatorWWN,
+				Driver: Name,
+			},
+			Status: "attached",
+			Fields: map[string]string{"lun": strconv.Itoa(e.LUN)},
+		}
+		if e.InitiatorWWN == iid && ld != nil {
+			att.DeviceName = ld.DeviceMap[deviceKey(d.target, e.LUN)]
+		}
+		vol.Attachments = append(vol.Attachments, att)
+		vol.Status = "attached"
+	}
+	return vol
+}
+
+func (d *driver) Volumes(
+	ctx apitypes.Context,
+	opts *apitypes.VolumesOpts) ([]*apitypes.Volume, error) {
+
+	vols, err := d.listVolumes()
+	if err != nil {
+		return nil, err
+	}
+	exports := []*export{}
+	if opts.Attachments {
+		if exports, err = d.listExports(); err != nil {
+			return nil, err
+		}
+	}
+
+	all := []*apitypes.Volume{}
+	for _, v := range vols {
+		all = append(all, d.toVolume(ctx, v, exports))
+	}
+	return all, nil
+}
+
+func (d *driver) VolumeInspect(
+	ctx apitypes.Context,
+	volumeID string,
+	opts *apitypes.VolumeInspectOpts) (*apitypes.Volume, error) {
+
+	return d.inspect(ctx, volumeID, opts.Attachments)
+}
+
+func (d *driver) inspect(
+	ctx apitypes.Context,
+	volumeID string,
+	attachments bool) (*apitypes.Volume, error) {
+
+	vols, err := d.Volumes(ctx, &apitypes.VolumesOpts{Attachments: attachments})
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range vols {
+		if v.ID == volumeID {
+			return v, nil
+		}
+	}
+	return nil, goof.WithField("volumeID", volumeID, "volume not found")
+}
+
+func (d *driver) VolumeCreate(
+	ctx apitypes.Context,
+	name string,
+	opts *apitypes.VolumeCreateOpts) (*apitypes.Volume, error) {
+
+	size := int64(defaultSize)
+	if opts.Size != nil && *opts.Size > 0 {
+		size = *opts.Size
+	}
+	if err := d.rpc.call("vol_create", map[string]interface{}{
+		"pool": d.pool,
+		"name": name,
+		"size": size * gib,
+	}, nil); err != nil {
+		return nil, goof.WithFieldE("name", name, "error creating volume", err)
+	}
+	return d.inspect(ctx, name, false)
+}
+
+func (d *driver) VolumeCreateFromSnapshot(
+	ctx apitypes.Context,
+	snapshotID, volumeName string,
+	opts *apitypes.VolumeCreateOpts) (*apitypes.Volume, error) {
+
+	return nil, apitypes.ErrNotImplemented
+}
+
+func (d *driver) VolumeCopy(
+	ctx apitypes.Context,
+	volumeID, volumeName string,
+	opts apitypes.Store) (*apitypes.Volume, error) {
+
+	if err := d.rpc.call("vol_copy", map[string]interface{}{
+		"pool":     d.pool,
+		"vol_orig": volumeID,
+		"vol_new":  volumeName,
+	}, nil); err != nil {
+		return nil, goof.WithFieldsE(map[string]interface{}{
+			"volumeID": volumeID,
+			"name":     volumeName,
+		}, "error copying volume", err)
+	}
+	return d.inspect(ctx, volumeName, false)
+}
+
+func (d *driver) VolumeSnapshot(
+	ctx apitypes.Context,
+	volumeID, snapshotName string,
+	opts apitypes.Store) (*apitypes.Snapshot, error) {
+
+	return nil, apitypes.ErrNotImplemented
+}
+
+func (d *driver) VolumeRemove(
+	ctx apitypes.Context,
+	volumeID string,
+	opts apitypes.Store) error {
+
+	if err := d.rpc.call("vol_destroy", map[string]interface{}{
+		"pool": d.pool,
+		"name": volumeID,
+	}, nil); err != nil {
+		return goof.WithFieldE("volumeID", volumeID,
+			"error removing volume", err)
+	}
+	return nil
+}
+
+// VolumeAttach exports the LUN to the instance's initiator at the lowest LUN
+// number the initiator does not use. The returned token is the key of the
+// LUN in the node's local devices. A forced attach first removes the LUN's
+// exports to other initiators.
+func (d *driver) VolumeAttach(
+	ctx apitypes.Context,
+	volumeID string,
+	opts *apitypes.VolumeAttachOpts) (*apitypes.Volume, string, error) {
+
+	iid := instanceID(ctx)
+	if iid == "" {
+		return nil, "", goof.New("missing instance ID")
+	}
+
+	exports, err := d.listExports()
+	if err != nil {
+		return nil, "", err
+	}
+
+	used := map[int]bool{}
+	for _, e := range exports {
+		switch {
+		case e.InitiatorWWN == iid && e.VolumeName == volumeID:
+			vol, err := d.inspect(ctx, volumeID, true)
+			return vol, deviceKey(d.target, e.LUN), err
+		case e.InitiatorWWN == iid:
+			used[e.LUN] = true
+		case e.VolumeName == volumeID && opts.Force:
+			if err := d.unexport(volumeID, e.InitiatorWWN); err != nil {
+				return nil, "", err
+			}
+		}
+	}
+
+	lun := 0
+	for used[lun] {
+		lun++
+	}
+	if err := d.rpc.call("export_create", map[string]interface{}{
+		"pool":          d.pool,
+		"vol":           volumeID,
+		"initiator_wwn": iid,
+		"lun":           lun,
+	}, nil); err != nil {
+		return nil, "", goof.WithFieldsE(map[string]interface{}{
+			"volumeID":  volumeID,
+			"initiator": iid,
+			"lun":       lun,
+		}, "error exporting volume", err)
+	}
+
+	vol, err := d.inspect(ctx, volumeID, true)
+	if err != nil {
+		return nil, "", err
+	}
+	return vol, deviceKey(d.target, lun), nil
+}
+
+// VolumeDetach removes the LUN's export to the instance's initiator, or its
+// exports to all initiators if the detach is forced.
+func (d *driver) VolumeDetach(
+	ctx apitypes.Context,
+	volumeID string,
+	opts *apitypes.VolumeDetachOpts) (*apitypes.Volume, error) {
+
+	iid := instanceID(ctx)
+	exports, err := d.listExports()
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range exports {
+		if e.VolumeName != volumeID {
+			continue
+		}
+		if e.InitiatorWWN == iid || opts.Force {
+			if err := d.unexport(volumeID, e.InitiatorWWN); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return d.inspect(ctx, volumeID, true)
+}
+
+func (d *driver) unexport(volumeID, initiator string) error {
+	if err := d.rpc.call("export_destroy", map[string]interface{}{
+		"pool":          d.pool,
+		"vol":           volumeID,
+		"initiator_wwn": initiator,
+	}, nil); err != nil {
+		return goof.WithFieldsE(map[string]interface{}{
+			"volumeID":  volumeID,
+			"initiator": initiator,
+		}, "error unexporting volume", err)
+	}
+	return nil
+}
+
+func (d *driver) Snapshots(
+	ctx apitypes.Context,
+	opts apitypes.Store) ([]*apitypes.Snapshot, error) {
+
+	return nil, apitypes.ErrNotImplemented
+}
+
+func (d *driver) SnapshotInspect(
+	ctx apitypes.Context,
+	snapshotID string,
+	opts apitypes.Store) (*apitypes.Snapshot, error) {
+
+	return nil, apitypes.ErrNotImplemented
+}
+
+func (d *driver) SnapshotCopy(
+	ctx apitypes.Context,
+	snapshotID, snapshotName, destinationID string,
+	opts apitypes.Store) (*apitypes.Snapshot, error) {
+
+	return nil, apitypes.ErrNotImplemented
+}
+
+func (d *driver) SnapshotRemove(
+	ctx apitypes.Context,
+	snapshotID string,
+	opts apitypes.Store) error {
+
+	return apitypes.ErrNotImplemented
+}
@@ -0,0 +1,95 @@
+package iscsi
+
+import (
+	"strings"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/core/openiscsi"
+	"github.com/emccode/rexray/core/scsi"
+)
+
+// executor runs on each node. A node's instance ID is its initiator name.
+type executor struct {
+	config gofig.Config
+}
+
+func newExecutor() apitypes.StorageExecutor {
+	return &executor{}
+}
+
+func (e *executor) Name() string {
+	return Name
+}
+
+func (e *executor) Init(ctx apitypes.Context, config gofig.Config) error {
+	e.config = config
+	return nil
+}
+
+func (e *executor) InstanceID(
+	ctx apitypes.Context,
+	opts apitypes.Store) (*apitypes.InstanceID, error) {
+
+	if name := e.config.GetString("iscsi.initiatorName"); name != "" {
+		return &apitypes.InstanceID{ID: name, Driver: Name}, nil
+	}
+
+	name, err := openiscsi.InitiatorName()
+	if err != nil {
+		return nil, err
+	}
+	return &apitypes.InstanceID{ID: name, Driver: Name}, nil
+}
+
+func (e *executor) NextDevice(
+	ctx apitypes.Context,
+	opts apitypes.Store) (string, error) {
+
+	return "", apitypes.ErrNotImplemented
+}
+
+// LocalDevices returns the devices of the target's LUNs. A deep scan first
+// logs in to the target at each of its portals, rescans the sessions for
+// LUNs that were attached, removes the devices of LUNs that were detached,
+// and waits for multipathd to coalesce the paths of new LUNs. The sessions are
+// logged out when no LUNs remain.
+func (e *executor) LocalDevices(
+	ctx apitypes.Context,
+	opts *apitypes.LocalDevicesOpts) (*apitypes.LocalDevices, error) {
+
+	target := e.config.GetString("iscsi.target")
+	if target == "" {
+		return nil, goof.New("iscsi executor requires iscsi.target")
+	}
+	multipath := e.config.GetBool("iscsi.multipath") &&
+		scsi.Multipath(e.config)
+
+	if opts.ScanType == apitypes.DeviceScanDeep {
+		portals := strings.Fields(e.config.GetString("iscsi.portals"))
+		if err := login(ctx, target, portals); err != nil {
+			return nil, err
+		}
+		if err := rescan(ctx, target); err != nil {
+			return nil, err
+		}
+		if multipath {
+			scsi.SettleMultipath(ctx, scsi.MultipathTimeout(e.config))
+		}
+	}
+
+	devs, err := devices(target, multipath)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.ScanType == apitypes.DeviceScanDeep && len(devs) == 0 {
+		if err := logout(ctx, target); err != nil {
+			return nil, err
+		}
+	}
+
+	return &apitypes.LocalDevices{Driver: Name, DeviceMap: devs}, nil
+}
@@ -0,0 +1,76 @@
+// Package iscsi is a storage driver for generic iSCSI SANs that have no
+// vendor driver. LUNs are provisioned with targetd, the JSON-RPC service that
+// manages a Linux LIO target, or with a provisioning hook that speaks the
+// same methods. Nodes log in to the target with open-iscsi and use the
+// device-mapper multipath device of a LUN when it is reachable through more
+// than one portal.
+package iscsi
+
+import (
+	"fmt"
+
+	"github.com/akutz/gofig"
+	"github.com/emccode/libstorage/api/registry"
+)
+
+const (
+	// Name is the name with which the driver is registered.
+	Name = "iscsi"
+
+	defaultPool = "vg-targetd"
+	defaultSize = 16
+)
+
+func init() {
+	registry.RegisterStorageDriver(Name, newDriver)
+	registry.RegisterStorageExecutor(Name, newExecutor)
+
+	r := gofig.NewRegistration("iSCSI Driver")
+	r.Key(gofig.String, "", "",
+		"The IQN of the target through which LUNs are exported",
+		"iscsi.target")
+	r.Key(gofig.String, "", "",
+		"The portals, host[:port], at which nodes log in to the target",
+		"iscsi.portals")
+	r.Key(gofig.String, "", defaultPool,
+		"The pool from which LUNs are provisioned",
+		"iscsi.pool")
+	r.Key(gofig.String, "", "",
+		"The URL of the targetd JSON-RPC endpoint",
+		"iscsi.targetd.endpoint")
+	r.Key(gofig.String, "", "",
+		"The user name with which targetd requests are authenticated",
+		"iscsi.targetd.username")
+	r.Key(gofig.String, "", "",
+		"The password with which targetd requests are authenticated",
+		"iscsi.targetd.password")
+	r.Key(gofig.Bool, "", false,
+		"Skip the verification of targetd's TLS certificate",
+		"iscsi.targetd.insecure")
+	r.Key(gofig.String, "", "30s",
+		"How long to wait for a provisioning request",
+		"iscsi.timeout")
+	r.Key(gofig.String, "", "",
+		"An executable that provisions LUNs instead of targetd",
+		"iscsi.hook")
+	r.Key(gofig.String, "", "",
+		"The node's initiator name if not read from initiatorname.iscsi",
+		"iscsi.initiatorName")
+	r.Key(gofig.Bool, "", true,
+		"Use the multipath device of a LUN when there is one",
+		"iscsi.multipath")
+	gofig.Register(r)
+}
+
+func poolName(config gofig.Config) string {
+	if p := config.GetString("iscsi.pool"); p != "" {
+		return p
+	}
+	return defaultPool
+}
+
+// deviceKey returns the key of a LUN in a node's local devices, which is
+// also the token with which the node waits for an attached LUN to appear.
+func deviceKey(target string, lun int) string {
+	return fmt.Sprintf("%s-lun-%d", target, lun)
+}
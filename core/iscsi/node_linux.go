@@ -0,0 +1,166 @@
+package iscsi
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/core/openiscsi"
+	"github.com/emccode/rexray/core/scsi"
+)
+
+// targetSessions returns the node's sessions with the target.
+func targetSessions(target string) ([]*openiscsi.Session, error) {
+	all, err := openiscsi.Sessions()
+	if err != nil {
+		return nil, err
+	}
+	sessions := []*openiscsi.Session{}
+	for _, s := range all {
+		if s.Target == target {
+			sessions = append(sessions, s)
+		}
+	}
+	return sessions, nil
+}
+
+// login logs in to the target at each of the portals at which the node has
+// no session.
+func login(ctx apitypes.Context, target string, portals []string) error {
+	sessions, err := targetSessions(target)
+	if err != nil {
+		return err
+	}
+	active := map[string]bool{}
+	for _, s := range sessions {
+		active[s.Portal] = true
+	}
+
+	for _, p := range portals {
+		addr := openiscsi.PortalAddr(p)
+		if active[addr] {
+			continue
+		}
+		if _, err := openiscsi.Run(
+			"-m", "discovery", "-t", "sendtargets", "-p", addr); err != nil {
+			return err
+		}
+		if err := openiscsi.LoginTarget(ctx, target, addr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rescan rescans the target's sessions for attached LUNs, and then removes
+// the devices of the LUNs that were detached. The target no longer reports
+// the capacity of a LUN that is not exported to the node, so the devices
+// whose size is zero after they are rescanned are removed.
+func rescan(ctx apitypes.Context, target string) error {
+	sessions, err := targetSessions(target)
+	if err != nil || len(sessions) == 0 {
+		return err
+	}
+	if _, err := openiscsi.Run("-m", "session", "--rescan"); err != nil {
+		return err
+	}
+
+	paths, err := lunPaths(target)
+	if err != nil {
+		return err
+	}
+	for _, p := range paths {
+		dev := filepath.Base(p.device)
+		sysDev := filepath.Join("/sys/block", dev, "device")
+		ioutil.WriteFile(filepath.Join(sysDev, "rescan"), []byte("1"), 0200)
+
+		size, err := ioutil.ReadFile(filepath.Join("/sys/block", dev, "size"))
+		if err != nil || strings.TrimSpace(string(size)) != "0" {
+			continue
+		}
+		if err := scsi.RemoveDevice(p.device); err != nil {
+			return err
+		}
+		ctx.WithFields(map[string]interface{}{
+			"device": p.device,
+			"lun":    p.lun,
+		}).Info("removed detached iscsi lun")
+	}
+	return nil
+}
+
+// logout logs out of the node's sessions with the target.
+func logout(ctx apitypes.Context, target string) error {
+	sessions, err := targetSessions(target)
+	if err != nil {
+		return err
+	}
+	for _, s := range sessions {
+		if err := openiscsi.LogoutTarget(
+			ctx, target, s.Portal, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type lunPath struct {
+	lun    int
+	device string
+}
+
+// lunPaths returns a path to a device for each portal through which each of
+// the target's LUNs is reachable, sorted by LUN.
+func lunPaths(target string) ([]*lunPath, error) {
+	infos, err := ioutil.ReadDir(openiscsi.ByPathDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	paths := []*lunPath{}
+	for _, fi := range infos {
+		t, lun, ok := openiscsi.ParseByPath(fi.Name())
+		if !ok || t != target {
+			continue
+		}
+		dev, err := filepath.EvalSymlinks(
+			filepath.Join(openiscsi.ByPathDir, fi.Name()))
+		if err != nil {
+			continue
+		}
+		paths = append(paths, &lunPath{lun: lun, device: dev})
+	}
+	sort.Sort(byLUN(paths))
+	return paths, nil
+}
+
+type byLUN []*lunPath
+
+func (p byLUN) Len() int           { return len(p) }
+func (p byLUN) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+func (p byLUN) Less(i, j int) bool { return p[i].lun < p[j].lun }
+
+// devices returns the devices of the target's LUNs keyed by deviceKey. If
+// multipath is enabled a LUN's device is the multipath device that holds
+// its paths, if there is one.
+func devices(target string, multipath bool) (map[string]string, error) {
+	paths, err := lunPaths(target)
+	if err != nil {
+		return nil, err
+	}
+	devs := map[string]string{}
+	for _, p := range paths {
+		key := deviceKey(target, p.lun)
+		dev := scsi.DevicePath(p.device, multipath)
+		if _, ok := devs[key]; !ok || dev != p.device {
+			devs[key] = dev
+		}
+	}
+	return devs, nil
+}
@@ -0,0 +1,26 @@
+// +build !linux
+
+package iscsi
+
+import (
+	"github.com/akutz/goof"
+	apitypes "github.com/emccode/libstorage/api/types"
+)
+
+var errNotSupported = goof.New("iscsi volumes are only supported on Linux")
+
+func login(ctx apitypes.Context, target string, portals []string) error {
+	return errNotSupported
+}
+
+func rescan(ctx apitypes.Context, target string) error {
+	return errNotSupported
+}
+
+func logout(ctx apitypes.Context, target string) error {
+	return nil
+}
+
+func devices(target string, multipath bool) (map[string]string, error) {
+	return map[string]string{}, nil
+}
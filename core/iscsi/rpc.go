@@ -0,0 +1,199 @@
+package iscsi
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"net/http"
+	"os/exec"
+	"sync/atomic"
+	"time"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+)
+
+// caller calls a provisioning method. The methods and their parameters are
+// those of targetd's JSON-RPC API, ex. vol_create, so that a provisioning
+// hook may be a thin shim over a SAN's own API.
+type caller interface {
+	call(method string, params map[string]interface{}, result interface{}) error
+}
+
+// volume is a LUN in a pool, as returned by vol_list.
+type volume struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+	UUID string `json:"uuid"`
+}
+
+// export is a LUN exported to an initiator, as returned by export_list.
+type export struct {
+	InitiatorWWN string `json:"initiator_wwn"`
+	LUN          int    `json:"lun"`
+	VolumeName   string `json:"vol_name"`
+	Pool         string `json:"pool"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type rpcResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *rpcError       `json:"error"`
+}
+
+func (r *rpcResponse) decode(method string, result interface{}) error {
+	if r.Error != nil {
+		return goof.WithFields(map[string]interface{}{
+			"method": method,
+			"code":   r.Error.Code,
+		}, r.Error.Message)
+	}
+	if result == nil || len(r.Result) == 0 {
+		return nil
+	}
+	return json.Unmarshal(r.Result, result)
+}
+
+func timeout(config gofig.Config) time.Duration {
+	if t, err := time.ParseDuration(
+		config.GetString("iscsi.timeout")); err == nil && t > 0 {
+		return t
+	}
+	return 30 * time.Second
+}
+
+// newCaller returns the configured hook if there is one, and otherwise a
+// client of targetd.
+func newCaller(config gofig.Config) (caller, error) {
+	if path := config.GetString("iscsi.hook"); path != "" {
+		return &hook{path: path, timeout: timeout(config)}, nil
+	}
+	return newTargetd(config)
+}
+
+// targetd calls the methods of a targetd JSON-RPC endpoint.
+type targetd struct {
+	endpoint string
+	username string
+	password string
+	client   *http.Client
+	id       int64
+}
+
+func newTargetd(config gofig.Config) (*targetd, error) {
+	endpoint := config.GetString("iscsi.targetd.endpoint")
+	if endpoint == "" {
+		return nil, goof.New(
+			"iscsi driver requires iscsi.targetd.endpoint or iscsi.hook")
+	}
+	t := &targetd{
+		endpoint: endpoint,
+		username: config.GetString("iscsi.targetd.username"),
+		password: config.GetString("iscsi.targetd.password"),
+		client:   &http.Client{Timeout: timeout(config)},
+	}
+	if config.GetBool("iscsi.targetd.insecure") {
+		t.client.Transport = &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		}
+	}
+	return t, nil
+}
+
+func (t *targetd) call(
+	method string, params map[string]interface{}, result interface{}) error {
+
+	buf, err := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      atomic.AddInt64(&t.id, 1),
+		"method":  method,
+		"params":  params,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", t.endpoint, bytes.NewReader(buf))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if t.username != "" {
+		req.SetBasicAuth(t.username, t.password)
+	}
+
+	res, err := t.client.Do(req)
+	if err != nil {
+		return goof.WithFieldE("method", method, "error calling targetd", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return goof.WithFields(map[string]interface{}{
+			"method": method,
+			"status": res.Status,
+		}, "error calling targetd")
+	}
+
+	rpcRes := &rpcResponse{}
+	if err := json.NewDecoder(res.Body).Decode(rpcRes); err != nil {
+		return goof.WithFieldE("method", method,
+			"error decoding targetd response", err)
+	}
+	return rpcRes.decode(method, result)
+}
+
+// hook calls the methods by running an executable with the method as its
+// argument. The parameters are written to its standard input as JSON, and it
+// writes a JSON-RPC response to its standard output.
+type hook struct {
+	path    string
+	timeout time.Duration
+}
+
+func (h *hook) call(
+	method string, params map[string]interface{}, result interface{}) error {
+
+	buf, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command(h.path, method)
+	cmd.Stdin = bytes.NewReader(buf)
+	out := &bytes.Buffer{}
+	cmd.Stdout = out
+	if err := cmd.Start(); err != nil {
+		return goof.WithFieldE("path", h.path,
+			"error starting provisioning hook", err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			return goof.WithFieldsE(map[string]interface{}{
+				"path":   h.path,
+				"method": method,
+			}, "provisioning hook failed", err)
+		}
+	case <-time.After(h.timeout):
+		cmd.Process.Kill()
+		return goof.WithFields(map[string]interface{}{
+			"path":    h.path,
+			"method":  method,
+			"timeout": h.timeout,
+		}, "provisioning hook timed out")
+	}
+
+	rpcRes := &rpcResponse{}
+	if err := json.Unmarshal(out.Bytes(), rpcRes); err != nil {
+		return goof.WithFieldE("method", method,
+			"error decoding provisioning hook response", err)
+	}
+	return rpcRes.decode(method, result)
+}
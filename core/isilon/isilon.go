@@ -0,0 +1,267 @@
+// Package isilon manages the NFS exports of the volumes of libStorage's
+// Isilon driver beyond what the driver itself configures: root and all
+// squashing, read-only and read-write client lists, and security flavors.
+// The settings are requested per volume with its create options and are
+// applied to the volume's export, along with the clients to which the volume
+// is attached, each time a host attaches or detaches the volume.
+package isilon
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/core/state"
+)
+
+// DriverName is the name of libStorage's Isilon driver.
+const DriverName = "isilon"
+
+// The create options with which a volume's export settings are requested.
+const (
+	OptRootSquash       = "rootSquash"
+	OptAllSquash        = "allSquash"
+	OptSquashUser       = "squashUser"
+	OptSquashGroup      = "squashGroup"
+	OptReadOnlyClients  = "roClients"
+	OptReadWriteClients = "rwClients"
+	OptSecurityFlavors  = "securityFlavors"
+)
+
+const (
+	exportsBucket = "isilonExports"
+
+	defaultSquashUser = "nobody"
+	defaultVolumePath = "/ifs/volumes"
+)
+
+// SecurityFlavors are the valid security flavors of an export.
+var SecurityFlavors = []string{"unix", "krb5", "krb5i", "krb5p"}
+
+// ExportOptions are the settings of a volume's export.
+type ExportOptions struct {
+
+	// RootSquash maps the root user of clients to SquashUser. The export's
+	// root mapping is left as is if it is nil.
+	RootSquash *bool `json:"rootSquash,omitempty"`
+
+	// AllSquash maps every user of clients to SquashUser.
+	AllSquash bool `json:"allSquash,omitempty"`
+
+	// SquashUser is the user to which squashed users are mapped, either a
+	// user name or a persona ID such as UID:1000.
+	SquashUser string `json:"squashUser,omitempty"`
+
+	// SquashGroup is the primary group of squashed users, either a group
+	// name or a persona ID such as GID:1000. The user's own primary group
+	// is used if it is empty.
+	SquashGroup string `json:"squashGroup,omitempty"`
+
+	// ReadOnlyClients are the clients that may always mount the volume
+	// read-only, in addition to those attached read-only.
+	ReadOnlyClients []string `json:"roClients,omitempty"`
+
+	// ReadWriteClients are the clients that may always mount the volume
+	// read-write, in addition to those attached read-write.
+	ReadWriteClients []string `json:"rwClients,omitempty"`
+
+	// SecurityFlavors are the security flavors clients may use. The
+	// export's flavors are left as is if there are none.
+	SecurityFlavors []string `json:"securityFlavors,omitempty"`
+}
+
+// ParseExportOptions returns the export settings requested in a volume's
+// create options. The returned flag is false if none were requested.
+func ParseExportOptions(opts apitypes.Store) (*ExportOptions, bool, error) {
+	if opts == nil {
+		return nil, false, nil
+	}
+	o := &ExportOptions{}
+	ok := false
+	if opts.IsSet(OptRootSquash) {
+		v := opts.GetBool(OptRootSquash)
+		o.RootSquash = &v
+		ok = true
+	}
+	if opts.IsSet(OptAllSquash) {
+		o.AllSquash = opts.GetBool(OptAllSquash)
+		ok = true
+	}
+	if v := opts.GetString(OptSquashUser); v != "" {
+		o.SquashUser = v
+		ok = true
+	}
+	if v := opts.GetString(OptSquashGroup); v != "" {
+		o.SquashGroup = v
+		ok = true
+	}
+	if v := splitList(opts.GetString(OptReadOnlyClients)); len(v) > 0 {
+		o.ReadOnlyClients = v
+		ok = true
+	}
+	if v := splitList(opts.GetString(OptReadWriteClients)); len(v) > 0 {
+		o.ReadWriteClients = v
+		ok = true
+	}
+	if v := splitList(opts.GetString(OptSecurityFlavors)); len(v) > 0 {
+		for i, f := range v {
+			v[i] = strings.ToLower(f)
+			if !isSecurityFlavor(v[i]) {
+				return nil, false, goof.WithFields(goof.Fields{
+					"flavor":  f,
+					"flavors": SecurityFlavors,
+				}, "invalid security flavor")
+			}
+		}
+		o.SecurityFlavors = v
+		ok = true
+	}
+	return o, ok, nil
+}
+
+func isSecurityFlavor(f string) bool {
+	for _, v := range SecurityFlavors {
+		if v == f {
+			return true
+		}
+	}
+	return false
+}
+
+func splitList(v string) []string {
+	list := []string{}
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			list = append(list, s)
+		}
+	}
+	return list
+}
+
+// GetExportOptions returns the export settings of the volume with the
+// provided ID. The returned flag is false if none were requested.
+func GetExportOptions(
+	s *state.Store, volumeID string) (*ExportOptions, bool, error) {
+
+	o := &ExportOptions{}
+	ok, err := s.Get(exportsBucket, volumeID, o)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	return o, true, nil
+}
+
+// SetExportOptions records the export settings of a volume.
+func SetExportOptions(s *state.Store, volumeID string, o *ExportOptions) error {
+	return s.Set(exportsBucket, volumeID, o)
+}
+
+// DeleteExportOptions removes the export settings of a volume.
+func DeleteExportOptions(s *state.Store, volumeID string) error {
+	return s.Delete(exportsBucket, volumeID)
+}
+
+// ExportPath returns the path of the directory of the volume with the
+// provided name, which is the path of its export.
+func ExportPath(config gofig.Config, volumeName string) string {
+	p := config.GetString("isilon.volumePath")
+	if p == "" {
+		p = defaultVolumePath
+	}
+	if !strings.HasPrefix(p, "/ifs") {
+		p = path.Join("/ifs", p)
+	}
+	return path.Join(p, volumeName)
+}
+
+// exportUpdate is the body of a request that modifies an export.
+type exportUpdate struct {
+	ReadOnlyClients  []string `json:"read_only_clients"`
+	ReadWriteClients []string `json:"read_write_clients"`
+	MapRoot          *userMap `json:"map_root,omitempty"`
+	MapAll           *userMap `json:"map_all,omitempty"`
+	SecurityFlavors  []string `json:"security_flavors,omitempty"`
+}
+
+type userMap struct {
+	Enabled      bool      `json:"enabled"`
+	User         *userName `json:"user,omitempty"`
+	PrimaryGroup *userName `json:"primary_group,omitempty"`
+}
+
+type userName struct {
+	ID string `json:"id"`
+}
+
+// update returns the modification of an export that applies the settings
+// and grants the attached clients the mode of their attachment, "ro" or
+// "rw". Clients are removed from the lists when they are detached unless
+// the settings list them.
+func (o *ExportOptions) update(attached map[string]string) *exportUpdate {
+	ro := map[string]bool{}
+	rw := map[string]bool{}
+	for _, c := range o.ReadOnlyClients {
+		ro[c] = true
+	}
+	for _, c := range o.ReadWriteClients {
+		rw[c] = true
+	}
+	for c, mode := range attached {
+		if mode == "ro" {
+			ro[c] = true
+		} else {
+			rw[c] = true
+		}
+	}
+
+	u := &exportUpdate{
+		ReadOnlyClients:  keys(ro),
+		ReadWriteClients: keys(rw),
+		SecurityFlavors:  o.SecurityFlavors,
+	}
+
+	user := o.SquashUser
+	if user == "" {
+		user = defaultSquashUser
+	}
+	if o.RootSquash != nil {
+		u.MapRoot = &userMap{Enabled: *o.RootSquash}
+		if *o.RootSquash {
+			o.mapUser(u.MapRoot, user)
+		}
+	}
+	u.MapAll = &userMap{Enabled: o.AllSquash}
+	if o.AllSquash {
+		o.mapUser(u.MapAll, user)
+	}
+	return u
+}
+
+func (o *ExportOptions) mapUser(m *userMap, user string) {
+	m.User = &userName{ID: personaID("USER:", user)}
+	if o.SquashGroup != "" {
+		m.PrimaryGroup = &userName{ID: personaID("GROUP:", o.SquashGroup)}
+	}
+}
+
+// personaID returns the persona ID of a user or group, which is the name
+// with the provided prefix unless it is already an ID such as UID:1000.
+func personaID(prefix, name string) string {
+	if i := strings.Index(name, ":"); i > 0 {
+		return name
+	}
+	return prefix + name
+}
+
+func keys(m map[string]bool) []string {
+	list := []string{}
+	for k := range m {
+		list = append(list, k)
+	}
+	sort.Strings(list)
+	return list
+}
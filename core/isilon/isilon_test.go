@@ -0,0 +1,46 @@
+package isilon
+
+import (
+	"testing"
+)
+
+func TestUpdate(t *testing.T) {
+	squash := true
+	o := &ExportOptions{
+		RootSquash:      &squash,
+		ReadOnlyClients: []string{"10.0.0.10"},
+		SecurityFlavors: []string{"krb5"},
+	}
+	u := o.update(map[string]string{"10.0.0.11": "rw", "10.0.0.12": "ro"})
+
+	if len(u.ReadOnlyClients) != 2 ||
+		u.ReadOnlyClients[0] != "10.0.0.10" ||
+		u.ReadOnlyClients[1] != "10.0.0.12" {
+		t.Fatalf("ro=%v", u.ReadOnlyClients)
+	}
+	if len(u.ReadWriteClients) != 1 || u.ReadWriteClients[0] != "10.0.0.11" {
+		t.Fatalf("rw=%v", u.ReadWriteClients)
+	}
+	if u.MapRoot == nil || !u.MapRoot.Enabled ||
+		u.MapRoot.User == nil || u.MapRoot.User.ID != "USER:nobody" {
+		t.Fatalf("mapRoot=%+v", u.MapRoot)
+	}
+	if u.MapAll == nil || u.MapAll.Enabled || u.MapAll.User != nil {
+		t.Fatalf("mapAll=%+v", u.MapAll)
+	}
+
+	o.RootSquash = nil
+	if u := o.update(nil); u.MapRoot != nil || len(u.ReadWriteClients) != 0 {
+		t.Fatalf("update=%+v", u)
+	}
+
+	o.AllSquash = true
+	o.SquashUser = "UID:1000"
+	o.SquashGroup = "staff"
+	u = o.update(nil)
+	if u.MapAll == nil || !u.MapAll.Enabled ||
+		u.MapAll.User.ID != "UID:1000" ||
+		u.MapAll.PrimaryGroup.ID != "GROUP:staff" {
+		t.Fatalf("mapAll=%+v", u.MapAll)
+	}
+}
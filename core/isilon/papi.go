@@ -0,0 +1,134 @@
+package isilon
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+)
+
+const exportsPath = "/platform/2/protocols/nfs/exports"
+
+// Client modifies exports with the OneFS platform API, using the endpoint
+// and credentials of libStorage's Isilon driver.
+type Client struct {
+	endpoint string
+	username string
+	password string
+	config   gofig.Config
+	client   *http.Client
+}
+
+// export is an NFS export, as returned by the platform API.
+type export struct {
+	ID    int      `json:"id"`
+	Paths []string `json:"paths"`
+}
+
+// NewClient returns a client of the configured cluster.
+func NewClient(config gofig.Config) (*Client, error) {
+	endpoint := strings.TrimSuffix(config.GetString("isilon.endpoint"), "/")
+	if endpoint == "" {
+		return nil, goof.New("isilon exports require isilon.endpoint")
+	}
+	c := &Client{
+		endpoint: endpoint,
+		username: config.GetString("isilon.userName"),
+		password: config.GetString("isilon.password"),
+		config:   config,
+		client:   &http.Client{Timeout: 30 * time.Second},
+	}
+	if config.GetBool("isilon.insecure") {
+		c.client.Transport = &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		}
+	}
+	return c, nil
+}
+
+// Reconcile applies the settings to the export of the volume with the
+// provided name and grants the attached clients, keyed by their instance IDs,
+// the modes of their attachments, "ro" or "rw". Nothing is done if the
+// volume is not exported, since the driver exports a volume when it is
+// first attached.
+func (c *Client) Reconcile(
+	volumeName string, o *ExportOptions, attached map[string]string) error {
+
+	p := ExportPath(c.config, volumeName)
+	e, ok, err := c.exportByPath(p)
+	if err != nil || !ok {
+		return err
+	}
+	return c.do("PUT", fmt.Sprintf("%s/%d", exportsPath, e.ID),
+		o.update(attached), nil)
+}
+
+// exportByPath returns the export of the provided path.
+func (c *Client) exportByPath(p string) (*export, bool, error) {
+	resume := ""
+	for {
+		q := url.Values{}
+		if resume != "" {
+			q.Set("resume", resume)
+		}
+		res := &struct {
+			Exports []*export `json:"exports"`
+			Resume  string    `json:"resume"`
+		}{}
+		if err := c.do("GET", exportsPath+"?"+q.Encode(), nil, res); err != nil {
+			return nil, false, err
+		}
+		for _, e := range res.Exports {
+			for _, ep := range e.Paths {
+				if ep == p {
+					return e, true, nil
+				}
+			}
+		}
+		if res.Resume == "" {
+			return nil, false, nil
+		}
+		resume = res.Resume
+	}
+}
+
+func (c *Client) do(method, path string, body, result interface{}) error {
+	var buf []byte
+	if body != nil {
+		var err error
+		if buf, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequest(method, c.endpoint+path, bytes.NewReader(buf))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBasicAuth(c.username, c.password)
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return goof.WithFieldE("path", path, "error calling isilon", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return goof.WithFields(map[string]interface{}{
+			"method": method,
+			"path":   path,
+			"status": res.Status,
+		}, "error calling isilon")
+	}
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(result)
+}
@@ -0,0 +1,92 @@
+// Package journal records the mount and unmount operations that are in
+// progress on this instance. An entry that remains after its operation should
+// have ended belongs to an operation interrupted by a crash, and is used by
+// the agent to complete or roll back the operation when it starts.
+package journal
+
+import (
+	"os"
+	"sort"
+	"time"
+
+	"github.com/emccode/rexray/core/state"
+)
+
+const journalBucket = "mountJournal"
+
+// Op is a journaled operation.
+type Op string
+
+const (
+	// OpMount is the attachment and mounting of a volume.
+	OpMount Op = "mount"
+
+	// OpUnmount is the unmounting and detachment of a volume.
+	OpUnmount Op = "unmount"
+)
+
+// Entry describes an operation in progress.
+type Entry struct {
+
+	// VolumeID is the ID of the volume being mounted or unmounted.
+	VolumeID string `json:"volumeID"`
+
+	// Op is the operation.
+	Op Op `json:"op"`
+
+	// MountPoints are the volume's mount points when the operation began.
+	MountPoints []string `json:"mountPoints,omitempty"`
+
+	// PID is the ID of the process performing the operation.
+	PID int `json:"pid"`
+
+	// Started is the time at which the operation began.
+	Started time.Time `json:"started"`
+}
+
+// Begin records the start of an operation on the volume with the provided ID.
+// Only one operation per volume is journaled; a new operation replaces the
+// entry of an earlier one.
+func Begin(
+	s *state.Store, op Op, volumeID string, mountPoints []string) error {
+
+	return s.Set(journalBucket, volumeID, &Entry{
+		VolumeID:    volumeID,
+		Op:          op,
+		MountPoints: mountPoints,
+		PID:         os.Getpid(),
+		Started:     time.Now().UTC(),
+	})
+}
+
+// End removes the entry for the volume with the provided ID.
+func End(s *state.Store, volumeID string) error {
+	return s.Delete(journalBucket, volumeID)
+}
+
+// List returns the journaled operations, oldest first.
+func List(s *state.Store) ([]*Entry, error) {
+	ids, err := s.Keys(journalBucket)
+	if err != nil {
+		return nil, err
+	}
+	entries := []*Entry{}
+	for _, id := range ids {
+		e := &Entry{}
+		ok, err := s.Get(journalBucket, id, e)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			entries = append(entries, e)
+		}
+	}
+	sort.Sort(byStarted(entries))
+	return entries, nil
+}
+
+type byStarted []*Entry
+
+func (b byStarted) Len() int           { return len(b) }
+func (b byStarted) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byStarted) Less(i, j int) bool { return b[i].Started.Before(b[j].Started) }
@@ -0,0 +1,88 @@
+package kms
+
+import (
+	"strings"
+
+	"github.com/akutz/gofig"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/kms"
+
+	"github.com/emccode/rexray/core/ebs"
+)
+
+func init() {
+	Register("awskms", newAWSProvider)
+}
+
+// awsProvider wraps data keys with an AWS KMS key using the credentials of
+// the environment or of the instance's profile.
+type awsProvider struct {
+	config gofig.Config
+}
+
+func newAWSProvider(config gofig.Config) (Provider, error) {
+	return &awsProvider{config: config}, nil
+}
+
+// client returns a client of the region of the key, which is the region of
+// its ARN, rexray.kms.region, or the instance's region, in that order.
+func (a *awsProvider) client(keyID string) (*kms.KMS, error) {
+	region := arnRegion(keyID)
+	if region == "" {
+		region = a.config.GetString("rexray.kms.region")
+	}
+	if region == "" {
+		id, err := ebs.NewMetadataFromConfig(a.config).Identity()
+		if err != nil {
+			return nil, err
+		}
+		region = id.Region
+	}
+	sess, err := session.NewSession(aws.NewConfig().WithRegion(region))
+	if err != nil {
+		return nil, err
+	}
+	return kms.New(sess), nil
+}
+
+func (a *awsProvider) Wrap(keyID string, dataKey []byte) ([]byte, error) {
+	client, err := a.client(keyID)
+	if err != nil {
+		return nil, err
+	}
+	out, err := client.Encrypt(&kms.EncryptInput{
+		KeyId:     aws.String(keyID),
+		Plaintext: dataKey,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out.CiphertextBlob, nil
+}
+
+func (a *awsProvider) Unwrap(keyID string, wrapped []byte) ([]byte, error) {
+	client, err := a.client(keyID)
+	if err != nil {
+		return nil, err
+	}
+	out, err := client.Decrypt(&kms.DecryptInput{
+		KeyId:          aws.String(keyID),
+		CiphertextBlob: wrapped,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out.Plaintext, nil
+}
+
+// arnRegion returns the region of an ARN, ex.
+// arn:aws:kms:us-east-1:123456789012:key/..., or an empty string if the key
+// ID is not an ARN.
+func arnRegion(keyID string) string {
+	parts := strings.SplitN(keyID, ":", 6)
+	if len(parts) < 6 || parts[0] != "arn" {
+		return ""
+	}
+	return parts[3]
+}
@@ -0,0 +1,125 @@
+package kms
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+)
+
+const (
+	azureLoginURL   = "https://login.microsoftonline.com/"
+	azureVaultScope = "https://vault.azure.net"
+	azureAPIVersion = "7.3"
+	azureWrapAlg    = "RSA-OAEP-256"
+)
+
+func init() {
+	Register("azurekv", newAzureProvider)
+}
+
+// azureProvider wraps data keys with a Key Vault key, ex.
+// https://vault.vault.azure.net/keys/rexray/VERSION, using the credentials
+// of a service principal.
+type azureProvider struct {
+	config gofig.Config
+	client *http.Client
+	token  string
+}
+
+func newAzureProvider(config gofig.Config) (Provider, error) {
+	return &azureProvider{
+		config: config,
+		client: &http.Client{Timeout: time.Minute},
+	}, nil
+}
+
+// login exchanges the service principal's credentials for a token.
+func (a *azureProvider) login() error {
+	if a.token != "" {
+		return nil
+	}
+	tenantID := a.config.GetString("rexray.kms.azure.tenantID")
+	res, err := a.client.PostForm(
+		azureLoginURL+tenantID+"/oauth2/token",
+		url.Values{
+			"grant_type":    {"client_credentials"},
+			"client_id":     {a.config.GetString("rexray.kms.azure.clientID")},
+			"client_secret": {a.config.GetString("rexray.kms.azure.clientSecret")},
+			"resource":      {azureVaultScope},
+		})
+	if err != nil {
+		return goof.WithFieldE(
+			"tenantID", tenantID, "error logging in to azure", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return goof.WithField("status", res.Status, "error logging in to azure")
+	}
+	t := struct {
+		AccessToken string `json:"access_token"`
+	}{}
+	if err := json.NewDecoder(res.Body).Decode(&t); err != nil {
+		return err
+	}
+	a.token = t.AccessToken
+	return nil
+}
+
+// do invokes a key operation. Key Vault encodes values as unpadded base64url.
+func (a *azureProvider) do(keyID, op string, value []byte) ([]byte, error) {
+	if err := a.login(); err != nil {
+		return nil, err
+	}
+	buf, err := json.Marshal(map[string]string{
+		"alg":   azureWrapAlg,
+		"value": base64.RawURLEncoding.EncodeToString(value),
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("POST",
+		strings.TrimSuffix(keyID, "/")+"/"+op+"?api-version="+azureAPIVersion,
+		bytes.NewReader(buf))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+a.token)
+
+	res, err := a.client.Do(req)
+	if err != nil {
+		return nil, goof.WithFieldE("keyID", keyID, "error calling key vault", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(res.Body)
+		return nil, goof.WithFields(goof.Fields{
+			"keyID":  keyID,
+			"status": res.Status,
+			"body":   strings.TrimSpace(string(msg)),
+		}, "error calling key vault")
+	}
+	out := struct {
+		Value string `json:"value"`
+	}{}
+	if err := json.NewDecoder(res.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	return base64.RawURLEncoding.DecodeString(out.Value)
+}
+
+func (a *azureProvider) Wrap(keyID string, dataKey []byte) ([]byte, error) {
+	return a.do(keyID, "wrapkey", dataKey)
+}
+
+func (a *azureProvider) Unwrap(keyID string, wrapped []byte) ([]byte, error) {
+	return a.do(keyID, "unwrapkey", wrapped)
+}
@@ -0,0 +1,84 @@
+package kms
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	"golang.org/x/oauth2/google"
+)
+
+const (
+	gcpKMSURL   = "https://cloudkms.googleapis.com/v1/"
+	gcpKMSScope = "https://www.googleapis.com/auth/cloudkms"
+)
+
+func init() {
+	Register("gcpkms", newGCPProvider)
+}
+
+// gcpProvider wraps data keys with a Cloud KMS key, ex.
+// projects/p/locations/global/keyRings/r/cryptoKeys/k, using the
+// application default credentials.
+type gcpProvider struct {
+	client *http.Client
+}
+
+func newGCPProvider(config gofig.Config) (Provider, error) {
+	client, err := google.DefaultClient(context.Background(), gcpKMSScope)
+	if err != nil {
+		return nil, err
+	}
+	return &gcpProvider{client: client}, nil
+}
+
+func (g *gcpProvider) do(keyID, method string, body, result interface{}) error {
+	buf, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	url := gcpKMSURL + strings.TrimPrefix(keyID, "/") + ":" + method
+	res, err := g.client.Post(url, "application/json", bytes.NewReader(buf))
+	if err != nil {
+		return goof.WithFieldE("keyID", keyID, "error calling cloud kms", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(res.Body)
+		return goof.WithFields(goof.Fields{
+			"keyID":  keyID,
+			"status": res.Status,
+			"body":   strings.TrimSpace(string(msg)),
+		}, "error calling cloud kms")
+	}
+	return json.NewDecoder(res.Body).Decode(result)
+}
+
+func (g *gcpProvider) Wrap(keyID string, dataKey []byte) ([]byte, error) {
+	out := struct {
+		Ciphertext []byte `json:"ciphertext"`
+	}{}
+	if err := g.do(keyID, "encrypt", map[string][]byte{
+		"plaintext": dataKey,
+	}, &out); err != nil {
+		return nil, err
+	}
+	return out.Ciphertext, nil
+}
+
+func (g *gcpProvider) Unwrap(keyID string, wrapped []byte) ([]byte, error) {
+	out := struct {
+		Plaintext []byte `json:"plaintext"`
+	}{}
+	if err := g.do(keyID, "decrypt", map[string][]byte{
+		"ciphertext": wrapped,
+	}, &out); err != nil {
+		return nil, err
+	}
+	return out.Plaintext, nil
+}
@@ -0,0 +1,230 @@
+// Package kms protects the secrets REX-Ray stores, such as the key with which
+// tokens are signed or the private keys of ACME certificates, with envelope
+// encryption. Each secret is encrypted with a data key of its own, and the
+// data key is encrypted, or wrapped, by a key management service (AWS KMS,
+// Google Cloud KMS, or Azure Key Vault) so that the secret is never written
+// to disk in plaintext and may only be read by hosts permitted to use the
+// service's key.
+package kms
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+)
+
+// envelopeVersion identifies the format of an envelope.
+const envelopeVersion = 1
+
+func init() {
+	r := gofig.NewRegistration("KMS")
+	r.Key(gofig.String, "", "",
+		"The key management service with which secrets are encrypted: "+
+			"awskms, gcpkms, or azurekv; secrets are stored in plaintext "+
+			"if empty",
+		"rexray.kms.provider")
+	r.Key(gofig.String, "", "",
+		"The key with which data keys are wrapped, ex. the ARN of an AWS "+
+			"KMS key, the resource name of a Cloud KMS key, or the URL of a "+
+			"Key Vault key",
+		"rexray.kms.keyID")
+	r.Key(gofig.String, "", "",
+		"The region of the AWS KMS key if not the instance's",
+		"rexray.kms.region")
+	r.Key(gofig.String, "", "",
+		"The tenant of the service principal with which Key Vault is used",
+		"rexray.kms.azure.tenantID")
+	r.Key(gofig.String, "", "",
+		"The client ID of the service principal with which Key Vault is used",
+		"rexray.kms.azure.clientID")
+	r.Key(gofig.String, "", "",
+		"The secret of the service principal with which Key Vault is used",
+		"rexray.kms.azure.clientSecret")
+	gofig.Register(r)
+}
+
+// Provider wraps and unwraps data keys with a key management service.
+type Provider interface {
+
+	// Wrap encrypts a data key with the key with the provided ID.
+	Wrap(keyID string, dataKey []byte) ([]byte, error)
+
+	// Unwrap decrypts a data key that was wrapped with the key with the
+	// provided ID.
+	Unwrap(keyID string, wrapped []byte) ([]byte, error)
+}
+
+// NewProvider returns a provider that uses the provided configuration.
+type NewProvider func(config gofig.Config) (Provider, error)
+
+var (
+	providers    = map[string]NewProvider{}
+	providersRwl sync.RWMutex
+)
+
+// Register registers the constructor of the provider with the provided name.
+func Register(name string, f NewProvider) {
+	providersRwl.Lock()
+	defer providersRwl.Unlock()
+	providers[strings.ToLower(name)] = f
+}
+
+func newProvider(config gofig.Config, name string) (Provider, error) {
+	providersRwl.RLock()
+	f, ok := providers[strings.ToLower(name)]
+	providersRwl.RUnlock()
+	if !ok {
+		return nil, goof.WithField("provider", name, "unknown kms provider")
+	}
+	return f(config)
+}
+
+// envelope is a secret encrypted with a data key, and the data key wrapped
+// by a key management service.
+type envelope struct {
+	Version  int    `json:"rexrayEnvelope"`
+	Provider string `json:"provider"`
+	KeyID    string `json:"keyID"`
+	DataKey  []byte `json:"dataKey"`
+	Nonce    []byte `json:"nonce"`
+	Data     []byte `json:"data"`
+}
+
+// IsEnabled returns a flag indicating whether or not secrets are encrypted.
+func IsEnabled(config gofig.Config) bool {
+	return config.GetString("rexray.kms.provider") != ""
+}
+
+// Seal encrypts a secret with a new data key that is wrapped by the
+// configured provider. The secret is returned as it is if no provider is
+// configured.
+func Seal(config gofig.Config, plaintext []byte) ([]byte, error) {
+	if !IsEnabled(config) {
+		return plaintext, nil
+	}
+	name := config.GetString("rexray.kms.provider")
+	keyID := config.GetString("rexray.kms.keyID")
+	if keyID == "" {
+		return nil, goof.WithField(
+			"provider", name, "kms provider requires rexray.kms.keyID")
+	}
+	p, err := newProvider(config, name)
+	if err != nil {
+		return nil, err
+	}
+
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	wrapped, err := p.Wrap(keyID, dataKey)
+	if err != nil {
+		return nil, goof.WithFieldsE(goof.Fields{
+			"provider": name,
+			"keyID":    keyID,
+		}, "error wrapping data key", err)
+	}
+
+	return json.Marshal(&envelope{
+		Version:  envelopeVersion,
+		Provider: name,
+		KeyID:    keyID,
+		DataKey:  wrapped,
+		Nonce:    nonce,
+		Data:     gcm.Seal(nil, nonce, plaintext, nil),
+	})
+}
+
+// Open decrypts a secret that was encrypted with Seal. The provider and key
+// with which its data key was wrapped are those recorded in the envelope,
+// so secrets remain readable after the configured key is rotated. A secret
+// that is not an envelope, such as one written before a provider was
+// configured, is returned as it is.
+func Open(config gofig.Config, buf []byte) ([]byte, error) {
+	e, ok := parseEnvelope(buf)
+	if !ok {
+		return buf, nil
+	}
+	p, err := newProvider(config, e.Provider)
+	if err != nil {
+		return nil, err
+	}
+	dataKey, err := p.Unwrap(e.KeyID, e.DataKey)
+	if err != nil {
+		return nil, goof.WithFieldsE(goof.Fields{
+			"provider": e.Provider,
+			"keyID":    e.KeyID,
+		}, "error unwrapping data key", err)
+	}
+	gcm, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := gcm.Open(nil, e.Nonce, e.Data, nil)
+	if err != nil {
+		return nil, goof.WithFieldE(
+			"keyID", e.KeyID, "error decrypting secret", err)
+	}
+	return plaintext, nil
+}
+
+func parseEnvelope(buf []byte) (*envelope, bool) {
+	if !bytes.HasPrefix(bytes.TrimSpace(buf), []byte("{")) {
+		return nil, false
+	}
+	e := &envelope{}
+	if err := json.Unmarshal(buf, e); err != nil ||
+		e.Version != envelopeVersion {
+		return nil, false
+	}
+	return e, true
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// ReadFile reads a secret from a file and decrypts it.
+func ReadFile(config gofig.Config, path string) ([]byte, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := Open(config, buf)
+	if err != nil {
+		return nil, goof.WithFieldE("path", path, "error reading secret", err)
+	}
+	return plaintext, nil
+}
+
+// WriteFile encrypts a secret and writes it to a file that only its owner
+// may read.
+func WriteFile(config gofig.Config, path string, plaintext []byte) error {
+	buf, err := Seal(config, plaintext)
+	if err != nil {
+		return goof.WithFieldE("path", path, "error writing secret", err)
+	}
+	return ioutil.WriteFile(path, buf, os.FileMode(0600))
+}
@@ -0,0 +1,82 @@
+package kms
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/akutz/gofig"
+)
+
+// xorProvider wraps data keys by XORing them with the key ID's bytes.
+type xorProvider struct{}
+
+func (p *xorProvider) xor(keyID string, buf []byte) []byte {
+	out := make([]byte, len(buf))
+	for i := range buf {
+		out[i] = buf[i] ^ keyID[i%len(keyID)]
+	}
+	return out
+}
+
+func (p *xorProvider) Wrap(keyID string, dataKey []byte) ([]byte, error) {
+	return p.xor(keyID, dataKey), nil
+}
+
+func (p *xorProvider) Unwrap(keyID string, wrapped []byte) ([]byte, error) {
+	return p.xor(keyID, wrapped), nil
+}
+
+func TestSealOpen(t *testing.T) {
+	Register("xor", func(gofig.Config) (Provider, error) {
+		return &xorProvider{}, nil
+	})
+	secret := []byte("the signing key")
+
+	config := gofig.New()
+	buf, err := Seal(config, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf, secret) {
+		t.Fatal("secret should be unchanged without a provider")
+	}
+
+	config.Set("rexray.kms.provider", "xor")
+	config.Set("rexray.kms.keyID", "key1")
+	buf, err = Seal(config, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(buf, secret) {
+		t.Fatal("sealed secret contains plaintext")
+	}
+
+	// envelopes are opened with the key they were sealed with
+	config.Set("rexray.kms.keyID", "key2")
+	opened, err := Open(config, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(opened, secret) {
+		t.Fatalf("opened %q; want %q", opened, secret)
+	}
+
+	// secrets written before a provider was configured are still readable
+	opened, err = Open(config, secret)
+	if err != nil || !bytes.Equal(opened, secret) {
+		t.Fatalf("opened %q, %v; want %q", opened, err, secret)
+	}
+}
+
+func TestARNRegion(t *testing.T) {
+	tests := map[string]string{
+		"arn:aws:kms:us-west-2:123456789012:key/abcd": "us-west-2",
+		"alias/rexray": "",
+		"abcd-1234":    "",
+	}
+	for keyID, region := range tests {
+		if r := arnRegion(keyID); r != region {
+			t.Errorf("arnRegion(%s)=%s; want %s", keyID, r, region)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+// Package labels manages the key/value labels REX-Ray associates with volumes
+// and snapshots, and the selectors used to filter on them.
+package labels
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/akutz/goof"
+
+	"github.com/emccode/rexray/core/state"
+)
+
+const (
+	// OptKey is the name of the create option used to pass a volume's labels
+	// to a storage driver so they may be applied as platform-native tags.
+	OptKey = "labels"
+
+	// VolumesBucket is the bucket of the volumes' labels.
+	VolumesBucket = "volumeLabels"
+
+	snapshotsBucket = "snapshotLabels"
+)
+
+// Labels is a set of key/value pairs.
+type Labels map[string]string
+
+// Parse parses a list of key=value strings into a set of labels.
+func Parse(pairs []string) (Labels, error) {
+	l := Labels{}
+	for _, p := range pairs {
+		for _, kv := range strings.Split(p, ",") {
+			kv = strings.TrimSpace(kv)
+			if kv == "" {
+				continue
+			}
+			parts := strings.SplitN(kv, "=", 2)
+			if len(parts) != 2 || parts[0] == "" {
+				return nil, goof.WithField("label", kv, "invalid label")
+			}
+			l[parts[0]] = parts[1]
+		}
+	}
+	return l, nil
+}
+
+// String returns the labels as a sorted, comma-separated list of key=value
+// pairs. The format is the one accepted by Parse.
+func (l Labels) String() string {
+	pairs := []string{}
+	for k, v := range l {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// Volume returns the labels for the volume with the provided ID.
+func Volume(s *state.Store, volumeID string) (Labels, error) {
+	return get(s, VolumesBucket, volumeID)
+}
+
+// SetVolume replaces the labels for the volume with the provided ID.
+func SetVolume(s *state.Store, volumeID string, l Labels) error {
+	return set(s, VolumesBucket, volumeID, l)
+}
+
+// Snapshot returns the labels for the snapshot with the provided ID.
+func Snapshot(s *state.Store, snapshotID string) (Labels, error) {
+	return get(s, snapshotsBucket, snapshotID)
+}
+
+// SetSnapshot replaces the labels for the snapshot with the provided ID.
+func SetSnapshot(s *state.Store, snapshotID string, l Labels) error {
+	return set(s, snapshotsBucket, snapshotID, l)
+}
+
+func get(s *state.Store, bucket, id string) (Labels, error) {
+	l := Labels{}
+	if _, err := s.Get(bucket, id, &l); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func set(s *state.Store, bucket, id string, l Labels) error {
+	if len(l) == 0 {
+		return s.Delete(bucket, id)
+	}
+	return s.Set(bucket, id, l)
+}
@@ -0,0 +1,70 @@
+package labels
+
+import (
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	l, err := Parse([]string{"env=prod,team=db", "tier=", "owner=a=b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(l) != 4 ||
+		l["env"] != "prod" ||
+		l["team"] != "db" ||
+		l["tier"] != "" ||
+		l["owner"] != "a=b" {
+		t.Fatalf("labels=%v", l)
+	}
+	if s := l.String(); s != "env=prod,owner=a=b,team=db,tier=" {
+		t.Fatalf("String()=%s", s)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	if _, err := Parse([]string{"env"}); err == nil {
+		t.Fatal("expected error for label without value")
+	}
+	if _, err := Parse([]string{"=prod"}); err == nil {
+		t.Fatal("expected error for label without key")
+	}
+}
+
+func TestSelector(t *testing.T) {
+	l := Labels{"env": "prod", "team": "db"}
+
+	tests := []struct {
+		expr    string
+		matches bool
+	}{
+		{"", true},
+		{"env=prod", true},
+		{"env=dev", false},
+		{"env!=dev", true},
+		{"env!=prod", false},
+		{"team", true},
+		{"owner", false},
+		{"!owner", true},
+		{"!team", false},
+		{"env=prod,team=db", true},
+		{"env=prod,team=web", false},
+	}
+
+	for _, tt := range tests {
+		s, err := ParseSelector(tt.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m := s.Matches(l); m != tt.matches {
+			t.Errorf("%q.Matches(%v) == %v, != %v", tt.expr, l, m, tt.matches)
+		}
+	}
+}
+
+func TestSelectorInvalid(t *testing.T) {
+	for _, expr := range []string{"=prod", "!=prod", "!"} {
+		if _, err := ParseSelector(expr); err == nil {
+			t.Errorf("expected error for %q", expr)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package labels
+
+import (
+	"strings"
+
+	"github.com/akutz/goof"
+)
+
+type operator int
+
+const (
+	opEquals operator = iota
+	opNotEquals
+	opExists
+	opNotExists
+)
+
+type requirement struct {
+	key   string
+	op    operator
+	value string
+}
+
+// Selector matches a set of labels. A selector is a comma-separated list of
+// requirements that must all be satisfied:
+//
+//	key=value     the label is present and equal to value
+//	key!=value    the label is absent or not equal to value
+//	key           the label is present
+//	!key          the label is absent
+type Selector []requirement
+
+// ParseSelector parses one or more selector expressions. Requirements from
+// all of the expressions are combined.
+func ParseSelector(exprs ...string) (Selector, error) {
+	s := Selector{}
+	for _, expr := range exprs {
+		for _, r := range strings.Split(expr, ",") {
+			r = strings.TrimSpace(r)
+			if r == "" {
+				continue
+			}
+			req, err := parseRequirement(r)
+			if err != nil {
+				return nil, err
+			}
+			s = append(s, req)
+		}
+	}
+	return s, nil
+}
+
+func parseRequirement(r string) (requirement, error) {
+	switch {
+	case strings.Contains(r, "!="):
+		parts := strings.SplitN(r, "!=", 2)
+		return newRequirement(parts[0], opNotEquals, parts[1], r)
+	case strings.Contains(r, "="):
+		parts := strings.SplitN(r, "=", 2)
+		return newRequirement(parts[0], opEquals, parts[1], r)
+	case strings.HasPrefix(r, "!"):
+		return newRequirement(r[1:], opNotExists, "", r)
+	}
+	return newRequirement(r, opExists, "", r)
+}
+
+func newRequirement(
+	key string, op operator, value, expr string) (requirement, error) {
+
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return requirement{}, goof.WithField(
+			"selector", expr, "invalid label selector")
+	}
+	return requirement{key: key, op: op, value: strings.TrimSpace(value)}, nil
+}
+
+// Empty returns a flag indicating whether or not the selector has no
+// requirements. An empty selector matches all labels.
+func (s Selector) Empty() bool {
+	return len(s) == 0
+}
+
+// Matches returns a flag indicating whether or not the provided labels
+// satisfy all of the selector's requirements.
+func (s Selector) Matches(l Labels) bool {
+	for _, r := range s {
+		v, ok := l[r.key]
+		switch r.op {
+		case opEquals:
+			if !ok || v != r.value {
+				return false
+			}
+		case opNotEquals:
+			if ok && v == r.value {
+				return false
+			}
+		case opExists:
+			if !ok {
+				return false
+			}
+		case opNotExists:
+			if ok {
+				return false
+			}
+		}
+	}
+	return true
+}
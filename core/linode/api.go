@@ -0,0 +1,177 @@
+package linode
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+
+	"github.com/emccode/rexray/core/errcodes"
+)
+
+// volume is a Linode Volume, as returned by the API. A volume that is not
+// attached has no Linode ID.
+type volume struct {
+	ID             int      `json:"id"`
+	Label          string   `json:"label"`
+	Size           int64    `json:"size"`
+	Region         string   `json:"region"`
+	LinodeID       *int     `json:"linode_id"`
+	Status         string   `json:"status"`
+	Tags           []string `json:"tags"`
+	FilesystemPath string   `json:"filesystem_path"`
+}
+
+// attachedTo returns the ID of the Linode the volume is attached to, or 0 if
+// it is not attached.
+func (v *volume) attachedTo() int {
+	if v.LinodeID == nil {
+		return 0
+	}
+	return *v.LinodeID
+}
+
+// instance is a Linode, as returned by the API.
+type instance struct {
+	ID     int    `json:"id"`
+	Label  string `json:"label"`
+	Region string `json:"region"`
+}
+
+// apiError is an error returned by the API.
+type apiError struct {
+	status  int
+	reasons []string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("linode: %d %s", e.status, strings.Join(e.reasons, "; "))
+}
+
+// Code returns the code of the error's HTTP status.
+func (e *apiError) Code() errcodes.Code {
+	return errcodes.FromStatus(e.status)
+}
+
+// MarshalJSON marshals the error as its message and code, since the
+// libStorage server reports a driver's error as the error's JSON.
+func (e *apiError) MarshalJSON() ([]byte, error) {
+	return errcodes.Marshal(e)
+}
+
+// isNotFound returns a flag indicating whether the error is that of a
+// request for an object that does not exist.
+func isNotFound(err error) bool {
+	e, ok := err.(*apiError)
+	return ok && e.status == http.StatusNotFound
+}
+
+// client calls the Linode API v4 with a personal access token.
+type client struct {
+	endpoint string
+	token    string
+	client   *http.Client
+}
+
+func newClient(config gofig.Config) (*client, error) {
+	token := config.GetString("linode.token")
+	if token == "" {
+		return nil, goof.New("linode driver requires linode.token")
+	}
+	endpoint := strings.TrimSuffix(config.GetString("linode.endpoint"), "/")
+	if endpoint == "" {
+		endpoint = defaultEndpoint
+	}
+	return &client{
+		endpoint: endpoint,
+		token:    token,
+		client:   &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+func (c *client) do(method, path string, body, result interface{}) error {
+	var buf []byte
+	if body != nil {
+		var err error
+		if buf, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequest(method, c.endpoint+path, bytes.NewReader(buf))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.token)
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return goof.WithFieldE("path", path, "error calling linode", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		e := &apiError{status: res.StatusCode}
+		errs := &struct {
+			Errors []struct {
+				Reason string `json:"reason"`
+				Field  string `json:"field"`
+			} `json:"errors"`
+		}{}
+		json.NewDecoder(res.Body).Decode(errs)
+		for _, r := range errs.Errors {
+			if r.Field != "" {
+				r.Reason = r.Field + ": " + r.Reason
+			}
+			e.reasons = append(e.reasons, r.Reason)
+		}
+		return e
+	}
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(result)
+}
+
+// volumes returns all of the account's volumes.
+func (c *client) volumes() ([]*volume, error) {
+	all := []*volume{}
+	for page := 1; ; page++ {
+		res := &struct {
+			Data  []*volume `json:"data"`
+			Page  int       `json:"page"`
+			Pages int       `json:"pages"`
+		}{}
+		q := url.Values{"page": {fmt.Sprint(page)}, "page_size": {"500"}}
+		if err := c.do("GET", "/volumes?"+q.Encode(), nil, res); err != nil {
+			return nil, err
+		}
+		all = append(all, res.Data...)
+		if res.Page >= res.Pages {
+			return all, nil
+		}
+	}
+}
+
+func (c *client) volume(id int) (*volume, error) {
+	v := &volume{}
+	if err := c.do("GET", fmt.Sprintf("/volumes/%d", id), nil, v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (c *client) instance(id int) (*instance, error) {
+	i := &instance{}
+	if err := c.do(
+		"GET", fmt.Sprintf("/linode/instances/%d", id), nil, i); err != nil {
+		return nil, err
+	}
+	return i, nil
+}
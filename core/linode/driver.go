@@ -0,0 +1,486 @@
+package linode
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	"github.com/emccode/libstorage/api/context"
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/core/labels"
+	"github.com/emccode/rexray/core/migrate"
+	"github.com/emccode/rexray/core/topology"
+)
+
+type driver struct {
+	config  gofig.Config
+	api     *client
+	region  string
+	timeout time.Duration
+}
+
+func newDriver() apitypes.StorageDriver {
+	return &driver{}
+}
+
+// newResizer returns a driver that resizes volumes for a client, which
+// reaches the libStorage server's driver only through the libStorage API.
+func newResizer(
+	ctx apitypes.Context, config gofig.Config) (migrate.VolumeResizer, error) {
+
+	d := &driver{}
+	if err := d.Init(ctx, config); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (d *driver) Name() string {
+	return Name
+}
+
+func (d *driver) Init(ctx apitypes.Context, config gofig.Config) error {
+	d.config = config
+	api, err := newClient(config)
+	if err != nil {
+		return err
+	}
+	d.api = api
+	d.region = config.GetString("linode.region")
+	d.timeout = 2 * time.Minute
+	if t, err := time.ParseDuration(
+		config.GetString("linode.timeout")); err == nil && t > 0 {
+		d.timeout = t
+	}
+
+	ctx.WithFields(map[string]interface{}{
+		"endpoint": d.api.endpoint,
+		"region":   d.region,
+	}).Info("initialized linode driver")
+	return nil
+}
+
+// instanceID returns the ID of the Linode on whose behalf an operation is
+// performed, or 0 if there is none.
+func instanceID(ctx apitypes.Context) int {
+	iid, ok := ctx.Value(context.InstanceIDKey).(*apitypes.InstanceID)
+	if !ok {
+		return 0
+	}
+	id, _ := strconv.Atoi(iid.ID)
+	return id
+}
+
+// parseVolumeID parses the ID of a volume, which is the ID of its Linode
+// Volume.
+func parseVolumeID(id string) (int, error) {
+	n, err := strconv.Atoi(id)
+	if err != nil || n <= 0 {
+		return 0, goof.WithField("volumeID", id, "invalid volume ID")
+	}
+	return n, nil
+}
+
+func (d *driver) Type(ctx apitypes.Context) (apitypes.StorageType, error) {
+	return apitypes.Block, nil
+}
+
+// NextDeviceInfo returns nil because the devices of attached volumes are
+// named by their labels.
+func (d *driver) NextDeviceInfo(
+	ctx apitypes.Context) (*apitypes.NextDeviceInfo, error) {
+
+	return nil, nil
+}
+
+func (d *driver) InstanceInspect(
+	ctx apitypes.Context,
+	opts apitypes.Store) (*apitypes.Instance, error) {
+
+	iid, ok := ctx.Value(context.InstanceIDKey).(*apitypes.InstanceID)
+	if !ok {
+		return nil, goof.New("missing instance ID")
+	}
+	id, err := strconv.Atoi(iid.ID)
+	if err != nil {
+		return nil, goof.WithField("instanceID", iid.ID, "invalid instance ID")
+	}
+	i, err := d.api.instance(id)
+	if err != nil {
+		return nil, goof.WithFieldE("instanceID", iid.ID,
+			"error inspecting linode", err)
+	}
+	return &apitypes.Instance{
+		InstanceID: iid,
+		Name:       i.Label,
+		Region:     i.Region,
+	}, nil
+}
+
+// toVolume returns a libStorage volume for a Linode Volume. The volume's
+// availability zone is its region. The device of an attachment to the
+// instance in the operation's context is looked up by the volume's label.
+func (d *driver) toVolume(
+	ctx apitypes.Context, v *volume, attachments bool) *apitypes.Volume {
+
+	vol := &apitypes.Volume{
+		ID:               strconv.Itoa(v.ID),
+		Name:             v.Label,
+		Size:             v.Size,
+		AvailabilityZone: v.Region,
+		Type:             Name,
+		Status:           v.Status,
+		Fields:           map[string]string{},
+	}
+	for i, t := range v.Tags {
+		vol.Fields["tag."+strconv.Itoa(i)] = t
+	}
+
+	lid := v.attachedTo()
+	if !attachments || lid == 0 {
+		return vol
+	}
+	att := &apitypes.VolumeAttachment{
+		VolumeID: vol.ID,
+		InstanceID: &apitypes.InstanceID{
+			ID:     strconv.Itoa(lid),
+			Driver: Name,
+		},
+		Status: "attached",
+	}
+	ld, _ := ctx.Value(context.LocalDevicesKey).(*apitypes.LocalDevices)
+	if lid == instanceID(ctx) && ld != nil {
+		att.DeviceName = ld.DeviceMap[v.Label]
+	}
+	vol.Attachments = append(vol.Attachments, att)
+	return vol
+}
+
+func (d *driver) Volumes(
+	ctx apitypes.Context,
+	opts *apitypes.VolumesOpts) ([]*apitypes.Volume, error) {
+
+	vols, err := d.api.volumes()
+	if err != nil {
+		return nil, err
+	}
+	all := []*apitypes.Volume{}
+	for _, v := range vols {
+		all = append(all, d.toVolume(ctx, v, opts.Attachments))
+	}
+	return all, nil
+}
+
+func (d *driver) VolumeInspect(
+	ctx apitypes.Context,
+	volumeID string,
+	opts *apitypes.VolumeInspectOpts) (*apitypes.Volume, error) {
+
+	v, err := d.volume(volumeID)
+	if err != nil {
+		return nil, err
+	}
+	return d.toVolume(ctx, v, opts.Attachments), nil
+}
+
+func (d *driver) volume(id string) (*volume, error) {
+	n, err := parseVolumeID(id)
+	if err != nil {
+		return nil, err
+	}
+	v, err := d.api.volume(n)
+	if isNotFound(err) {
+		return nil, goof.WithField("volumeID", id, "volume not found")
+	}
+	return v, err
+}
+
+// createRegion returns the region in which a volume is created: the
+// requested availability zone, or else the region of the volume's selected
+// topology segment, or else the region of the Linode on whose behalf the
+// volume is created, or else the configured region.
+func (d *driver) createRegion(
+	ctx apitypes.Context, opts *apitypes.VolumeCreateOpts) (string, error) {
+
+	if opts.AvailabilityZone != nil && *opts.AvailabilityZone != "" {
+		return *opts.AvailabilityZone, nil
+	}
+	r, err := topology.FromOpts(opts.Opts)
+	if err != nil {
+		return "", err
+	}
+	if region := r.Select()[topology.Region]; region != "" {
+		return region, nil
+	}
+	if iid := instanceID(ctx); iid != 0 {
+		i, err := d.api.instance(iid)
+		if err != nil {
+			return "", goof.WithFieldE("instanceID", iid,
+				"error inspecting linode", err)
+		}
+		return i.Region, nil
+	}
+	if d.region != "" {
+		return d.region, nil
+	}
+	return "", goof.New("linode volumes require a region: the " +
+		"availability zone, the instance's region, or linode.region")
+}
+
+// VolumeCreate creates a volume in the region of the Linode on whose behalf
+// it is created, unless another region is requested, with the configured
+// tags and the volume's labels as tags.
+func (d *driver) VolumeCreate(
+	ctx apitypes.Context,
+	name string,
+	opts *apitypes.VolumeCreateOpts) (*apitypes.Volume, error) {
+
+	region, err := d.createRegion(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	size := int64(defaultSize)
+	if opts.Size != nil && *opts.Size > 0 {
+		size = *opts.Size
+	}
+	l := labels.Labels{}
+	if opts.Opts != nil {
+		if l, err = labels.Parse(
+			[]string{opts.Opts.GetString(labels.OptKey)}); err != nil {
+			return nil, err
+		}
+	}
+
+	v := &volume{}
+	if err := d.api.do("POST", "/volumes", map[string]interface{}{
+		"label":  name,
+		"size":   size,
+		"region": region,
+		"tags":   volumeTags(d.config, l),
+	}, v); err != nil {
+		return nil, goof.WithFieldsE(goof.Fields{
+			"name":   name,
+			"region": region,
+		}, "error creating volume", err)
+	}
+	return d.toVolume(ctx, v, false), nil
+}
+
+func (d *driver) VolumeCreateFromSnapshot(
+	ctx apitypes.Context,
+	snapshotID, volumeName string,
+	opts *apitypes.VolumeCreateOpts) (*apitypes.Volume, error) {
+
+	return nil, apitypes.ErrNotImplemented
+}
+
+// VolumeCopy clones a volume into a new volume in the same region.
+func (d *driver) VolumeCopy(
+	ctx apitypes.Context,
+	volumeID, volumeName string,
+	opts apitypes.Store) (*apitypes.Volume, error) {
+
+	n, err := parseVolumeID(volumeID)
+	if err != nil {
+		return nil, err
+	}
+	v := &volume{}
+	if err := d.api.do("POST", "/volumes/"+strconv.Itoa(n)+"/clone",
+		map[string]interface{}{"label": volumeName}, v); err != nil {
+		return nil, goof.WithFieldsE(goof.Fields{
+			"volumeID": volumeID,
+			"name":     volumeName,
+		}, "error copying volume", err)
+	}
+	return d.toVolume(ctx, v, false), nil
+}
+
+func (d *driver) VolumeSnapshot(
+	ctx apitypes.Context,
+	volumeID, snapshotName string,
+	opts apitypes.Store) (*apitypes.Snapshot, error) {
+
+	return nil, apitypes.ErrNotImplemented
+}
+
+func (d *driver) VolumeRemove(
+	ctx apitypes.Context,
+	volumeID string,
+	opts apitypes.Store) error {
+
+	n, err := parseVolumeID(volumeID)
+	if err != nil {
+		return err
+	}
+	if err := d.api.do(
+		"DELETE", "/volumes/"+strconv.Itoa(n), nil, nil); err != nil {
+		return goof.WithFieldE("volumeID", volumeID,
+			"error removing volume", err)
+	}
+	return nil
+}
+
+// VolumeResize expands a volume in place. The file system is grown by the
+// node the next time the volume is mounted.
+func (d *driver) VolumeResize(
+	ctx apitypes.Context,
+	volumeID string,
+	size int64,
+	opts apitypes.Store) (*apitypes.Volume, error) {
+
+	v, err := d.volume(volumeID)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.api.do("POST", "/volumes/"+strconv.Itoa(v.ID)+"/resize",
+		map[string]interface{}{"size": size}, nil); err != nil {
+		return nil, goof.WithFieldsE(goof.Fields{
+			"volumeID": volumeID,
+			"size":     size,
+		}, "error resizing volume", err)
+	}
+	if v, err = d.wait(v.ID, func(v *volume) bool {
+		return v.Size >= size && v.Status == "active"
+	}); err != nil {
+		return nil, err
+	}
+	return d.toVolume(ctx, v, true), nil
+}
+
+// VolumeAttach attaches a volume to the instance's Linode, which must be in
+// the volume's region. The returned token is the volume's label, with which
+// the node's device for the volume is looked up. A forced attach first
+// detaches the volume from another Linode.
+func (d *driver) VolumeAttach(
+	ctx apitypes.Context,
+	volumeID string,
+	opts *apitypes.VolumeAttachOpts) (*apitypes.Volume, string, error) {
+
+	iid := instanceID(ctx)
+	if iid == 0 {
+		return nil, "", goof.New("missing instance ID")
+	}
+	v, err := d.volume(volumeID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	switch lid := v.attachedTo(); {
+	case lid == iid:
+		return d.toVolume(ctx, v, true), v.Label, nil
+	case lid != 0 && !opts.Force:
+		return nil, "", goof.WithFields(goof.Fields{
+			"volumeID": volumeID,
+			"linodeID": lid,
+		}, "volume is attached to another linode")
+	case lid != 0:
+		if err := d.detach(v.ID); err != nil {
+			return nil, "", err
+		}
+	}
+
+	if err := d.api.do("POST", "/volumes/"+strconv.Itoa(v.ID)+"/attach",
+		map[string]interface{}{
+			"linode_id":            iid,
+			"persist_across_boots": false,
+		}, nil); err != nil {
+		return nil, "", goof.WithFieldsE(goof.Fields{
+			"volumeID": volumeID,
+			"linodeID": iid,
+		}, "error attaching volume", err)
+	}
+	if v, err = d.wait(v.ID, func(v *volume) bool {
+		return v.attachedTo() == iid
+	}); err != nil {
+		return nil, "", err
+	}
+	return d.toVolume(ctx, v, true), v.Label, nil
+}
+
+// VolumeDetach detaches a volume from the instance's Linode, or from any
+// Linode if the detach is forced.
+func (d *driver) VolumeDetach(
+	ctx apitypes.Context,
+	volumeID string,
+	opts *apitypes.VolumeDetachOpts) (*apitypes.Volume, error) {
+
+	v, err := d.volume(volumeID)
+	if err != nil {
+		return nil, err
+	}
+	if lid := v.attachedTo(); lid != 0 &&
+		(lid == instanceID(ctx) || opts.Force) {
+		if err := d.detach(v.ID); err != nil {
+			return nil, err
+		}
+		if v, err = d.api.volume(v.ID); err != nil {
+			return nil, err
+		}
+	}
+	return d.toVolume(ctx, v, true), nil
+}
+
+func (d *driver) detach(id int) error {
+	if err := d.api.do("POST", "/volumes/"+strconv.Itoa(id)+"/detach",
+		nil, nil); err != nil {
+		return goof.WithFieldE("volumeID", id, "error detaching volume", err)
+	}
+	_, err := d.wait(id, func(v *volume) bool { return v.attachedTo() == 0 })
+	return err
+}
+
+// wait polls a volume until the condition is met since the API attaches,
+// detaches, and resizes volumes asynchronously.
+func (d *driver) wait(id int, cond func(v *volume) bool) (*volume, error) {
+	deadline := time.Now().Add(d.timeout)
+	for {
+		v, err := d.api.volume(id)
+		if err != nil {
+			return nil, err
+		}
+		if cond(v) {
+			return v, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, goof.WithFields(goof.Fields{
+				"volumeID": id,
+				"status":   v.Status,
+				"timeout":  d.timeout,
+			}, "timed out waiting for volume")
+		}
+		time.Sleep(2 * time.Second)
+	}
+}
+
+func (d *driver) Snapshots(
+	ctx apitypes.Context,
+	opts apitypes.Store) ([]*apitypes.Snapshot, error) {
+
+	return nil, apitypes.ErrNotImplemented
+}
+
+func (d *driver) SnapshotInspect(
+	ctx apitypes.Context,
+	snapshotID string,
+	opts apitypes.Store) (*apitypes.Snapshot, error) {
+
+	return nil, apitypes.ErrNotImplemented
+}
+
+func (d *driver) SnapshotCopy(
+	ctx apitypes.Context,
+	snapshotID, snapshotName, destinationID string,
+	opts apitypes.Store) (*apitypes.Snapshot, error) {
+
+	return nil, apitypes.ErrNotImplemented
+}
+
+func (d *driver) SnapshotRemove(
+	ctx apitypes.Context,
+	snapshotID string,
+	opts apitypes.Store) error {
+
+	return apitypes.ErrNotImplemented
+}
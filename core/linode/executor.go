@@ -0,0 +1,60 @@
+package linode
+
+import (
+	"strconv"
+
+	"github.com/akutz/gofig"
+	apitypes "github.com/emccode/libstorage/api/types"
+)
+
+// executor runs on each node. A node's instance ID is the ID of its Linode.
+type executor struct {
+	config gofig.Config
+}
+
+func newExecutor() apitypes.StorageExecutor {
+	return &executor{}
+}
+
+func (e *executor) Name() string {
+	return Name
+}
+
+func (e *executor) Init(ctx apitypes.Context, config gofig.Config) error {
+	e.config = config
+	return nil
+}
+
+func (e *executor) InstanceID(
+	ctx apitypes.Context,
+	opts apitypes.Store) (*apitypes.InstanceID, error) {
+
+	if id := e.config.GetString("linode.instanceID"); id != "" {
+		return &apitypes.InstanceID{ID: id, Driver: Name}, nil
+	}
+	i, err := newMetadata(e.config).instance()
+	if err != nil {
+		return nil, err
+	}
+	return &apitypes.InstanceID{ID: strconv.Itoa(i.ID), Driver: Name}, nil
+}
+
+func (e *executor) NextDevice(
+	ctx apitypes.Context,
+	opts apitypes.Store) (string, error) {
+
+	return "", apitypes.ErrNotImplemented
+}
+
+// LocalDevices returns the devices of the volumes attached to the node keyed
+// by their labels.
+func (e *executor) LocalDevices(
+	ctx apitypes.Context,
+	opts *apitypes.LocalDevicesOpts) (*apitypes.LocalDevices, error) {
+
+	devs, err := devices(byIDDir)
+	if err != nil {
+		return nil, err
+	}
+	return &apitypes.LocalDevices{Driver: Name, DeviceMap: devs}, nil
+}
@@ -0,0 +1,134 @@
+// Package linode is a storage driver for Linode Block Storage. Volumes are
+// managed with the Linode API v4 and are created in the region of the Linode
+// on whose behalf they are created, since a volume may only be attached to a
+// Linode in its own region. A node's instance ID is the ID of its Linode,
+// which is read from the Linode Metadata service, and an attached volume
+// appears on the node as /dev/disk/by-id/scsi-0Linode_Volume_LABEL.
+package linode
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/akutz/gofig"
+	"github.com/emccode/libstorage/api/registry"
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/core/labels"
+	"github.com/emccode/rexray/core/migrate"
+	"github.com/emccode/rexray/core/topology"
+)
+
+const (
+	// Name is the name with which the driver is registered.
+	Name = "linode"
+
+	defaultEndpoint         = "https://api.linode.com/v4"
+	defaultMetadataEndpoint = "http://169.254.169.254"
+	defaultSize             = 20
+
+	byIDDir      = "/dev/disk/by-id"
+	byIDPrefix   = "scsi-0Linode_Volume_"
+	partitionSep = "-part"
+)
+
+func init() {
+	registry.RegisterStorageDriver(Name, newDriver)
+	registry.RegisterStorageExecutor(Name, newExecutor)
+	topology.Register(Name, instanceTopology)
+	migrate.Register(Name, newResizer)
+
+	r := gofig.NewRegistration("Linode Driver")
+	r.Key(gofig.String, "", "",
+		"The personal access token with which the Linode API is called",
+		"linode.token")
+	r.Key(gofig.String, "", defaultEndpoint,
+		"The URL of the Linode API",
+		"linode.endpoint")
+	r.Key(gofig.String, "", "",
+		"The region in which volumes are created when the region of the "+
+			"Linode they are created for is unknown",
+		"linode.region")
+	r.Key(gofig.String, "", "",
+		"The tags, separated by commas, applied to the volumes that are "+
+			"created",
+		"linode.tags")
+	r.Key(gofig.String, "", "2m",
+		"How long to wait for a volume to be attached, detached, or resized",
+		"linode.timeout")
+	r.Key(gofig.String, "", "",
+		"The node's Linode ID if not read from the Linode Metadata service",
+		"linode.instanceID")
+	r.Key(gofig.String, "", defaultMetadataEndpoint,
+		"The URL of the Linode Metadata service",
+		"linode.metadataEndpoint")
+	gofig.Register(r)
+}
+
+// instanceTopology returns the region of the node's Linode.
+func instanceTopology(
+	ctx apitypes.Context, config gofig.Config) (topology.Segment, error) {
+
+	i, err := newMetadata(config).instance()
+	if err != nil {
+		return nil, err
+	}
+	return topology.Segment{topology.Region: i.Region}, nil
+}
+
+// volumeTags returns the tags of a new volume: the configured tags and the
+// volume's labels as key=value pairs.
+func volumeTags(config gofig.Config, l labels.Labels) []string {
+	tags := []string{}
+	for _, t := range strings.Split(config.GetString("linode.tags"), ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			tags = append(tags, t)
+		}
+	}
+	pairs := []string{}
+	for k, v := range l {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return append(tags, pairs...)
+}
+
+// parseByID returns the label of the volume named by a link in
+// /dev/disk/by-id, ex. scsi-0Linode_Volume_data. The returned flag is false
+// if the link is not to a volume or is to a partition of one.
+func parseByID(name string) (string, bool) {
+	if !strings.HasPrefix(name, byIDPrefix) ||
+		strings.Contains(name, partitionSep) {
+		return "", false
+	}
+	label := strings.TrimPrefix(name, byIDPrefix)
+	return label, label != ""
+}
+
+// devices returns the devices of the volumes attached to the node keyed by
+// the volumes' labels.
+func devices(dir string) (map[string]string, error) {
+	devs := map[string]string{}
+	fis, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return devs, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, fi := range fis {
+		label, ok := parseByID(fi.Name())
+		if !ok {
+			continue
+		}
+		dev, err := filepath.EvalSymlinks(filepath.Join(dir, fi.Name()))
+		if err != nil {
+			continue
+		}
+		devs[label] = dev
+	}
+	return devs, nil
+}
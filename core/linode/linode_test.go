@@ -0,0 +1,93 @@
+package linode
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseByID(t *testing.T) {
+	if label, ok := parseByID("scsi-0Linode_Volume_data"); !ok ||
+		label != "data" {
+		t.Fatalf("label=%s ok=%v", label, ok)
+	}
+	for _, name := range []string{
+		"scsi-0Linode_Volume_data-part1",
+		"scsi-0Linode_Volume_",
+		"wwn-0x600a0b800012345600000000deadbeef",
+	} {
+		if _, ok := parseByID(name); ok {
+			t.Fatalf("name=%s", name)
+		}
+	}
+}
+
+func TestDevices(t *testing.T) {
+	dir, err := ioutil.TempDir("", "linode")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	dev := filepath.Join(dir, "sdc")
+	if err := ioutil.WriteFile(dev, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{
+		"scsi-0Linode_Volume_data", "scsi-0Linode_Volume_data-part1",
+	} {
+		if err := os.Symlink(dev, filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	devs, err := devices(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(devs) != 1 || devs["data"] != dev {
+		t.Fatalf("devs=%v", devs)
+	}
+	if devs, err = devices(filepath.Join(dir, "missing")); err != nil ||
+		len(devs) != 0 {
+		t.Fatalf("devs=%v err=%v", devs, err)
+	}
+}
+
+func TestClientVolumes(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "Bearer secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				w.Write([]byte(`{"errors":[{"reason":"Invalid Token"}]}`))
+				return
+			}
+			if r.URL.Query().Get("page") == "1" {
+				w.Write([]byte(`{"page":1,"pages":2,"data":[` +
+					`{"id":1,"label":"data","linode_id":7}]}`))
+				return
+			}
+			w.Write([]byte(`{"page":2,"pages":2,"data":[` +
+				`{"id":2,"label":"logs","linode_id":null}]}`))
+		}))
+	defer s.Close()
+
+	c := &client{endpoint: s.URL, token: "secret", client: http.DefaultClient}
+	vols, err := c.volumes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vols) != 2 || vols[0].attachedTo() != 7 ||
+		vols[1].attachedTo() != 0 {
+		t.Fatalf("vols=%v", vols)
+	}
+
+	c.token = "invalid"
+	if _, err := c.volumes(); err == nil ||
+		err.Error() != "linode: 401 Invalid Token" {
+		t.Fatalf("err=%v", err)
+	}
+}
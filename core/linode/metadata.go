@@ -0,0 +1,84 @@
+package linode
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+)
+
+const (
+	tokenExpiryHeader = "Metadata-Token-Expiry-Seconds"
+	tokenHeader       = "Metadata-Token"
+	tokenExpiry       = "3600"
+)
+
+// metadata is a client for the Linode Metadata service, which is available
+// to Linodes in the regions that support it.
+type metadata struct {
+	endpoint string
+	client   *http.Client
+}
+
+func newMetadata(config gofig.Config) *metadata {
+	endpoint := strings.TrimSuffix(
+		config.GetString("linode.metadataEndpoint"), "/")
+	if endpoint == "" {
+		endpoint = defaultMetadataEndpoint
+	}
+	return &metadata{
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: 2 * time.Second},
+	}
+}
+
+// instance returns the Linode the node runs on.
+func (m *metadata) instance() (*instance, error) {
+	req, err := http.NewRequest("PUT", m.endpoint+"/v1/token", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(tokenExpiryHeader, tokenExpiry)
+	token, err := m.read(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if req, err = http.NewRequest(
+		"GET", m.endpoint+"/v1/instance", nil); err != nil {
+		return nil, err
+	}
+	req.Header.Set(tokenHeader, strings.TrimSpace(string(token)))
+	req.Header.Set("Accept", "application/json")
+	buf, err := m.read(req)
+	if err != nil {
+		return nil, err
+	}
+
+	i := &instance{}
+	if err := json.Unmarshal(buf, i); err != nil {
+		return nil, goof.WithFieldE("endpoint", m.endpoint,
+			"error decoding linode metadata", err)
+	}
+	return i, nil
+}
+
+func (m *metadata) read(req *http.Request) ([]byte, error) {
+	res, err := m.client.Do(req)
+	if err != nil {
+		return nil, goof.WithFieldE("endpoint", m.endpoint,
+			"error reading linode metadata", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, goof.WithFields(goof.Fields{
+			"path":   req.URL.Path,
+			"status": res.StatusCode,
+		}, "error reading linode metadata")
+	}
+	return ioutil.ReadAll(res.Body)
+}
@@ -0,0 +1,122 @@
+// Package maintenance records the libStorage services that are undergoing
+// planned maintenance. While a service is in maintenance no new volumes may be
+// created on it or mounted from it, although existing volumes may still be
+// unmounted and detached.
+package maintenance
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/akutz/gofig"
+
+	"github.com/emccode/rexray/core/errcodes"
+	"github.com/emccode/rexray/core/state"
+)
+
+// Bucket is the bucket of the services in maintenance.
+const Bucket = "maintenance"
+
+func init() {
+	r := gofig.NewRegistration("Service Maintenance")
+	r.Key(gofig.String, "", "the service is undergoing maintenance",
+		"The message with which operations are refused when a service is "+
+			"in maintenance and no message was provided",
+		"rexray.maintenance.message")
+	gofig.Register(r)
+}
+
+// Mode describes a service's maintenance.
+type Mode struct {
+
+	// Service is the name of the libStorage service.
+	Service string `json:"service"`
+
+	// Message is reported to the clients whose operations are refused.
+	Message string `json:"message"`
+
+	// Since is the time at which maintenance began.
+	Since time.Time `json:"since"`
+}
+
+// Error is returned when an operation is refused because a service is in
+// maintenance.
+type Error struct {
+	Mode *Mode
+
+	// Operation is the operation that was refused.
+	Operation string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s refused: service %s is in maintenance: %s",
+		e.Operation, e.Mode.Service, e.Mode.Message)
+}
+
+// Status returns the HTTP status code with which the error is reported.
+func (e *Error) Status() int {
+	return http.StatusServiceUnavailable
+}
+
+// Code returns the code of the error.
+func (e *Error) Code() errcodes.Code {
+	return errcodes.ServiceInMaintenance
+}
+
+// Enable places the service with the provided name in maintenance. The
+// configured default message is used if the provided message is empty.
+func Enable(
+	config gofig.Config, s *state.Store, service, message string) (*Mode, error) {
+
+	if message == "" {
+		message = config.GetString("rexray.maintenance.message")
+	}
+	m := &Mode{
+		Service: strings.ToLower(service),
+		Message: message,
+		Since:   time.Now().UTC(),
+	}
+	if err := s.Set(Bucket, m.Service, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Disable ends the maintenance of the service with the provided name.
+func Disable(s *state.Store, service string) error {
+	return s.Delete(Bucket, strings.ToLower(service))
+}
+
+// Get returns the maintenance of the service with the provided name. The
+// returned flag is false if the service is not in maintenance.
+func Get(s *state.Store, service string) (*Mode, bool, error) {
+	m := &Mode{}
+	ok, err := s.Get(Bucket, strings.ToLower(service), m)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	return m, true, nil
+}
+
+// List returns the services in maintenance.
+func List(s *state.Store) ([]*Mode, error) {
+	services, err := s.Keys(Bucket)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(services)
+	modes := []*Mode{}
+	for _, service := range services {
+		m, ok, err := Get(s, service)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			modes = append(modes, m)
+		}
+	}
+	return modes, nil
+}
@@ -0,0 +1,234 @@
+package metrics
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/akutz/gofig"
+)
+
+// RuleFile is a Prometheus alerting rules file.
+type RuleFile struct {
+	Groups []*RuleGroup `json:"groups" yaml:"groups"`
+}
+
+// RuleGroup is a group of alerting rules.
+type RuleGroup struct {
+	Name  string  `json:"name" yaml:"name"`
+	Rules []*Rule `json:"rules" yaml:"rules"`
+}
+
+// Rule is an alerting rule.
+type Rule struct {
+	Alert       string            `json:"alert" yaml:"alert"`
+	Expr        string            `json:"expr" yaml:"expr"`
+	For         string            `json:"for,omitempty" yaml:"for,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty" yaml:"annotations,omitempty"`
+}
+
+// selector returns a selector of a metric's series. The suffix is appended to
+// the metric's name, ex. _bucket for a histogram's buckets, and the matchers
+// are pairs of label names and values. It panics if a matcher names a label
+// the metric does not have so that the generated assets cannot drift from
+// the metrics.
+func selector(d *Desc, suffix string, matchers ...string) string {
+	name := d.Name + suffix
+	if len(matchers) == 0 {
+		return name
+	}
+	pairs := []string{}
+	for i := 0; i+1 < len(matchers); i += 2 {
+		pairs = append(pairs,
+			fmt.Sprintf(`%s="%s"`, label(d, matchers[i]), matchers[i+1]))
+	}
+	return name + "{" + strings.Join(pairs, ",") + "}"
+}
+
+// by returns an aggregation clause over the provided labels of a metric.
+func by(d *Desc, labels ...string) string {
+	for i, l := range labels {
+		labels[i] = label(d, l)
+	}
+	return "by (" + strings.Join(labels, ", ") + ")"
+}
+
+func label(d *Desc, name string) string {
+	if name == "le" && d.Kind == Histogram {
+		return name
+	}
+	for _, l := range d.Labels {
+		if l == name {
+			return name
+		}
+	}
+	panic(fmt.Sprintf("%s has no label %s", d.Name, name))
+}
+
+// heartbeatTimeout is how long a node's agent may go without reporting
+// before it is considered down.
+func heartbeatTimeout(config gofig.Config) time.Duration {
+	interval, err := time.ParseDuration(
+		config.GetString("rexray.nodes.heartbeat.interval"))
+	if err != nil {
+		interval = 30 * time.Second
+	}
+	return 5 * interval
+}
+
+// Rules returns the alerting rules for the metrics the REX-Ray service
+// exposes.
+func Rules(config gofig.Config) *RuleFile {
+	ops := by(VolumeOperations, "service", "operation")
+	errorRatio := fmt.Sprintf(
+		"sum %s (rate(%s[5m])) / sum %s (rate(%s[5m])) > 0.1",
+		ops, selector(VolumeOperations, "", "result", ResultError),
+		ops, selector(VolumeOperations, ""))
+
+	slowMounts := fmt.Sprintf(
+		"histogram_quantile(0.95, sum %s (rate(%s[10m]))) > 60",
+		by(VolumeOperationDuration, "service", "le"),
+		selector(VolumeOperationDuration, "_bucket",
+			"operation", "integration.Mount"))
+
+	timeout := heartbeatTimeout(config)
+	heartbeat := fmt.Sprintf("time() - %s > %d",
+		selector(NodeHeartbeat, ""), int(timeout.Seconds()))
+
+	scrubAnomaly := fmt.Sprintf("%s > 0", selector(VolumeScrubAnomaly, ""))
+
+	return &RuleFile{Groups: []*RuleGroup{{
+		Name: "rexray",
+		Rules: []*Rule{
+			{
+				Alert:  "RexRayVolumeOperationErrors",
+				Expr:   errorRatio,
+				For:    "10m",
+				Labels: map[string]string{"severity": "warning"},
+				Annotations: map[string]string{
+					"summary": "More than 10% of {{ $labels.operation }} " +
+						"operations on {{ $labels.service }} are failing",
+				},
+			},
+			{
+				Alert:  "RexRayVolumeMountsSlow",
+				Expr:   slowMounts,
+				For:    "15m",
+				Labels: map[string]string{"severity": "warning"},
+				Annotations: map[string]string{
+					"summary": "95% of mounts on {{ $labels.service }} " +
+						"take longer than a minute",
+				},
+			},
+			{
+				Alert:  "RexRayAgentDown",
+				Expr:   heartbeat,
+				For:    "5m",
+				Labels: map[string]string{"severity": "critical"},
+				Annotations: map[string]string{
+					"summary": fmt.Sprintf(
+						"The agent on {{ $labels.hostname }} has not "+
+							"reported for more than %s", timeout),
+				},
+			},
+			{
+				Alert:  "RexRayVolumeScrubAnomaly",
+				Expr:   scrubAnomaly,
+				Labels: map[string]string{"severity": "warning"},
+				Annotations: map[string]string{
+					"summary": "The {{ $labels.check }} check of " +
+						"{{ $labels.volume }} found an anomaly",
+				},
+			},
+		},
+	}}}
+}
+
+// Dashboard returns a Grafana dashboard that graphs the metrics the REX-Ray
+// service exposes. The dashboard's data source is chosen when it is
+// imported.
+func Dashboard() map[string]interface{} {
+	panels := []map[string]interface{}{
+		graph(1, "Operations", "ops",
+			target(fmt.Sprintf("sum %s (rate(%s[5m]))",
+				by(VolumeOperations, "operation", "result"),
+				selector(VolumeOperations, "")),
+				"{{operation}} {{result}}")),
+		graph(2, "Error Ratio", "percentunit",
+			target(fmt.Sprintf(
+				"sum %s (rate(%s[5m])) / sum %s (rate(%s[5m]))",
+				by(VolumeOperations, "service", "operation"),
+				selector(VolumeOperations, "", "result", ResultError),
+				by(VolumeOperations, "service", "operation"),
+				selector(VolumeOperations, "")),
+				"{{service}} {{operation}}")),
+		graph(3, "Operation Duration (p95)", "s",
+			target(fmt.Sprintf(
+				"histogram_quantile(0.95, sum %s (rate(%s[5m])))",
+				by(VolumeOperationDuration, "operation", "le"),
+				selector(VolumeOperationDuration, "_bucket")),
+				"{{operation}}")),
+		graph(4, "Time Since Agent Heartbeat", "s",
+			target(fmt.Sprintf("time() - %s", selector(NodeHeartbeat, "")),
+				"{{hostname}}")),
+		graph(5, "Volume IOPS", "iops",
+			target(selector(VolumeIOPS, ""), "{{volume}} {{direction}}")),
+		graph(6, "Volume Throughput", "Bps",
+			target(selector(VolumeThroughput, ""),
+				"{{volume}} {{direction}}")),
+		graph(7, "Volume Latency", "s",
+			target(selector(VolumeLatency, ""), "{{volume}} {{direction}}")),
+		graph(8, "Volume Scrub Anomalies", "short",
+			target(selector(VolumeScrubAnomaly, ""), "{{volume}} {{check}}")),
+	}
+
+	return map[string]interface{}{
+		"__inputs": []map[string]interface{}{{
+			"name":     "DS_PROMETHEUS",
+			"label":    "Prometheus",
+			"type":     "datasource",
+			"pluginId": "prometheus",
+		}},
+		"title":         "REX-Ray",
+		"tags":          []string{"rexray", "storage"},
+		"timezone":      "utc",
+		"schemaVersion": 14,
+		"refresh":       "1m",
+		"time": map[string]string{
+			"from": "now-6h",
+			"to":   "now",
+		},
+		"rows": []map[string]interface{}{{
+			"title":  "Volumes",
+			"height": "300px",
+			"panels": panels,
+		}},
+	}
+}
+
+func graph(
+	id int, title, unit string,
+	targets ...map[string]interface{}) map[string]interface{} {
+
+	return map[string]interface{}{
+		"id":         id,
+		"title":      title,
+		"type":       "graph",
+		"span":       6,
+		"datasource": "${DS_PROMETHEUS}",
+		"targets":    targets,
+		"yaxes": []map[string]interface{}{
+			{"format": unit, "min": 0},
+			{"format": "short"},
+		},
+	}
+}
+
+func target(expr, legend string) map[string]interface{} {
+	return map[string]interface{}{
+		"expr":         expr,
+		"legendFormat": legend,
+		"refId":        "A",
+	}
+}
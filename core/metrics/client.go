@@ -0,0 +1,135 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/akutz/gofig"
+	apitypes "github.com/emccode/libstorage/api/types"
+)
+
+type client struct {
+	apitypes.Client
+	service string
+}
+
+// Wrap returns a libStorage client that records the number, result, and
+// duration of each storage and integration driver operation. The client is
+// returned as is if metrics are disabled.
+func Wrap(config gofig.Config, c apitypes.Client) apitypes.Client {
+	if !config.GetBool("rexray.metrics.enabled") {
+		return c
+	}
+	return &client{
+		Client:  c,
+		service: config.GetString(apitypes.ConfigService),
+	}
+}
+
+func (c *client) Storage() apitypes.StorageDriver {
+	return &storageDriver{StorageDriver: c.Client.Storage(), c: c}
+}
+
+func (c *client) Integration() apitypes.IntegrationDriver {
+	return &integrationDriver{IntegrationDriver: c.Client.Integration(), c: c}
+}
+
+// observe records an operation that started at the provided time.
+func (c *client) observe(operation string, started time.Time, err error) {
+	result := ResultSuccess
+	if err != nil {
+		result = ResultError
+	}
+	r := Default()
+	r.Inc(VolumeOperations, c.service, operation, result)
+	r.Observe(VolumeOperationDuration,
+		time.Since(started).Seconds(), c.service, operation)
+}
+
+type storageDriver struct {
+	apitypes.StorageDriver
+	c *client
+}
+
+func (d *storageDriver) VolumeCreate(
+	ctx apitypes.Context,
+	volumeName string,
+	opts *apitypes.VolumeCreateOpts) (*apitypes.Volume, error) {
+
+	started := time.Now()
+	vol, err := d.StorageDriver.VolumeCreate(ctx, volumeName, opts)
+	d.c.observe("storage.VolumeCreate", started, err)
+	return vol, err
+}
+
+func (d *storageDriver) VolumeRemove(
+	ctx apitypes.Context,
+	volumeID string,
+	opts apitypes.Store) error {
+
+	started := time.Now()
+	err := d.StorageDriver.VolumeRemove(ctx, volumeID, opts)
+	d.c.observe("storage.VolumeRemove", started, err)
+	return err
+}
+
+func (d *storageDriver) VolumeAttach(
+	ctx apitypes.Context,
+	volumeID string,
+	opts *apitypes.VolumeAttachOpts) (*apitypes.Volume, string, error) {
+
+	started := time.Now()
+	vol, token, err := d.StorageDriver.VolumeAttach(ctx, volumeID, opts)
+	d.c.observe("storage.VolumeAttach", started, err)
+	return vol, token, err
+}
+
+func (d *storageDriver) VolumeDetach(
+	ctx apitypes.Context,
+	volumeID string,
+	opts *apitypes.VolumeDetachOpts) (*apitypes.Volume, error) {
+
+	started := time.Now()
+	vol, err := d.StorageDriver.VolumeDetach(ctx, volumeID, opts)
+	d.c.observe("storage.VolumeDetach", started, err)
+	return vol, err
+}
+
+func (d *storageDriver) VolumeSnapshot(
+	ctx apitypes.Context,
+	volumeID, snapshotName string,
+	opts apitypes.Store) (*apitypes.Snapshot, error) {
+
+	started := time.Now()
+	snap, err := d.StorageDriver.VolumeSnapshot(
+		ctx, volumeID, snapshotName, opts)
+	d.c.observe("storage.VolumeSnapshot", started, err)
+	return snap, err
+}
+
+type integrationDriver struct {
+	apitypes.IntegrationDriver
+	c *client
+}
+
+func (d *integrationDriver) Mount(
+	ctx apitypes.Context,
+	volumeID, volumeName string,
+	opts *apitypes.VolumeMountOpts) (string, *apitypes.Volume, error) {
+
+	started := time.Now()
+	mountPath, vol, err := d.IntegrationDriver.Mount(
+		ctx, volumeID, volumeName, opts)
+	d.c.observe("integration.Mount", started, err)
+	return mountPath, vol, err
+}
+
+func (d *integrationDriver) Unmount(
+	ctx apitypes.Context,
+	volumeID, volumeName string,
+	opts apitypes.Store) error {
+
+	started := time.Now()
+	err := d.IntegrationDriver.Unmount(ctx, volumeID, volumeName, opts)
+	d.c.observe("integration.Unmount", started, err)
+	return err
+}
@@ -0,0 +1,290 @@
+// Package metrics records the metrics the REX-Ray service exposes in the
+// Prometheus text format, and generates the alerting rules and Grafana
+// dashboard that monitor them.
+//
+// Every metric is described by a Desc in this package. The alerting rules and
+// the dashboard are built from the same descriptors so that they cannot refer
+// to a metric or label the service does not emit.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/akutz/gofig"
+)
+
+func init() {
+	r := gofig.NewRegistration("Metrics")
+	r.Key(gofig.Bool, "", true,
+		"Record metrics and expose them at the admin module's /metrics route",
+		"rexray.metrics.enabled")
+	gofig.Register(r)
+}
+
+// Kind is the Prometheus type of a metric.
+type Kind string
+
+const (
+	// Counter is a value that only increases.
+	Counter Kind = "counter"
+
+	// Gauge is a value that may increase or decrease.
+	Gauge Kind = "gauge"
+
+	// Histogram is a distribution of observed values.
+	Histogram Kind = "histogram"
+)
+
+// Desc describes a metric.
+type Desc struct {
+	Name    string
+	Help    string
+	Kind    Kind
+	Labels  []string
+	Buckets []float64
+}
+
+var (
+	// VolumeOperations counts the storage and integration driver operations.
+	VolumeOperations = &Desc{
+		Name:   "rexray_volume_operations_total",
+		Help:   "The number of volume operations by operation and result.",
+		Kind:   Counter,
+		Labels: []string{"service", "operation", "result"},
+	}
+
+	// VolumeOperationDuration is the distribution of the durations of the
+	// storage and integration driver operations.
+	VolumeOperationDuration = &Desc{
+		Name:    "rexray_volume_operation_duration_seconds",
+		Help:    "The duration of volume operations in seconds.",
+		Kind:    Histogram,
+		Labels:  []string{"service", "operation"},
+		Buckets: []float64{0.1, 0.5, 1, 5, 10, 30, 60, 120, 300},
+	}
+
+	// NodeHeartbeat is the time at which each node's agent last reported.
+	NodeHeartbeat = &Desc{
+		Name:   "rexray_node_heartbeat_timestamp_seconds",
+		Help:   "The Unix time at which a node's agent last reported.",
+		Kind:   Gauge,
+		Labels: []string{"instance_id", "hostname"},
+	}
+
+	// VolumeIOPS is the rate of I/O operations on each volume mounted on
+	// the node.
+	VolumeIOPS = &Desc{
+		Name:   "rexray_volume_io_operations_per_second",
+		Help:   "The rate of I/O operations on a mounted volume.",
+		Kind:   Gauge,
+		Labels: []string{"volume", "driver", "direction"},
+	}
+
+	// VolumeThroughput is the rate at which each volume mounted on the node
+	// is read and written.
+	VolumeThroughput = &Desc{
+		Name:   "rexray_volume_io_bytes_per_second",
+		Help:   "The rate at which a mounted volume is read and written.",
+		Kind:   Gauge,
+		Labels: []string{"volume", "driver", "direction"},
+	}
+
+	// VolumeLatency is the average time the I/O operations on each volume
+	// mounted on the node took to complete.
+	VolumeLatency = &Desc{
+		Name:   "rexray_volume_io_latency_seconds",
+		Help:   "The average latency of I/O operations on a mounted volume.",
+		Kind:   Gauge,
+		Labels: []string{"volume", "driver", "direction"},
+	}
+
+	// VolumeScrubChecks counts the integrity checks of idle volumes.
+	VolumeScrubChecks = &Desc{
+		Name:   "rexray_volume_scrub_checks_total",
+		Help:   "The number of volume integrity checks by check and result.",
+		Kind:   Counter,
+		Labels: []string{"check", "result"},
+	}
+
+	// VolumeScrubAnomaly is whether the last integrity check of each volume
+	// found an anomaly.
+	VolumeScrubAnomaly = &Desc{
+		Name:   "rexray_volume_scrub_anomaly",
+		Help:   "Whether a volume's last integrity check found an anomaly.",
+		Kind:   Gauge,
+		Labels: []string{"volume", "check"},
+	}
+
+	// Descs are the metrics the REX-Ray service exposes.
+	Descs = []*Desc{
+		VolumeOperations, VolumeOperationDuration, NodeHeartbeat,
+		VolumeIOPS, VolumeThroughput, VolumeLatency,
+		VolumeScrubChecks, VolumeScrubAnomaly,
+	}
+)
+
+// Result label values of VolumeOperations.
+const (
+	ResultSuccess = "success"
+	ResultError   = "error"
+)
+
+// Direction label values of the volume I/O metrics.
+const (
+	DirectionRead  = "read"
+	DirectionWrite = "write"
+)
+
+type series struct {
+	labels  []string
+	value   float64
+	buckets []uint64
+	count   uint64
+}
+
+// Registry holds the current values of the metrics.
+type Registry struct {
+	sync.Mutex
+	series map[*Desc]map[string]*series
+}
+
+var defaultRegistry = NewRegistry()
+
+// NewRegistry returns a new, empty registry.
+func NewRegistry() *Registry {
+	return &Registry{series: map[*Desc]map[string]*series{}}
+}
+
+// Default returns the registry whose metrics the REX-Ray service exposes.
+func Default() *Registry {
+	return defaultRegistry
+}
+
+func (r *Registry) get(d *Desc, labels []string) *series {
+	if len(labels) != len(d.Labels) {
+		panic(fmt.Sprintf("%s: %d label values for %d labels",
+			d.Name, len(labels), len(d.Labels)))
+	}
+	m, ok := r.series[d]
+	if !ok {
+		m = map[string]*series{}
+		r.series[d] = m
+	}
+	key := strings.Join(labels, "\xff")
+	s, ok := m[key]
+	if !ok {
+		s = &series{labels: labels}
+		if d.Kind == Histogram {
+			s.buckets = make([]uint64, len(d.Buckets))
+		}
+		m[key] = s
+	}
+	return s
+}
+
+// Inc increments a counter.
+func (r *Registry) Inc(d *Desc, labels ...string) {
+	r.Lock()
+	defer r.Unlock()
+	r.get(d, labels).value++
+}
+
+// Set sets the value of a gauge.
+func (r *Registry) Set(d *Desc, v float64, labels ...string) {
+	r.Lock()
+	defer r.Unlock()
+	r.get(d, labels).value = v
+}
+
+// Observe records an observation of a histogram.
+func (r *Registry) Observe(d *Desc, v float64, labels ...string) {
+	r.Lock()
+	defer r.Unlock()
+	s := r.get(d, labels)
+	for i, b := range d.Buckets {
+		if v <= b {
+			s.buckets[i]++
+		}
+	}
+	s.value += v
+	s.count++
+}
+
+// Reset removes all of a metric's series, such as before a gauge is
+// repopulated from the current state.
+func (r *Registry) Reset(d *Desc) {
+	r.Lock()
+	defer r.Unlock()
+	delete(r.series, d)
+}
+
+// Write writes the metrics in the Prometheus text format.
+func (r *Registry) Write(w io.Writer) error {
+	r.Lock()
+	defer r.Unlock()
+
+	for _, d := range Descs {
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n",
+			d.Name, d.Help, d.Name, d.Kind); err != nil {
+			return err
+		}
+
+		keys := []string{}
+		for k := range r.series[d] {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			if err := writeSeries(w, d, r.series[d][k]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func writeSeries(w io.Writer, d *Desc, s *series) error {
+	if d.Kind != Histogram {
+		_, err := fmt.Fprintf(w, "%s%s %s\n",
+			d.Name, labelPairs(d.Labels, s.labels), formatFloat(s.value))
+		return err
+	}
+
+	names := append(append([]string{}, d.Labels...), "le")
+	for i, b := range d.Buckets {
+		values := append(append([]string{}, s.labels...), formatFloat(b))
+		if _, err := fmt.Fprintf(w, "%s_bucket%s %d\n",
+			d.Name, labelPairs(names, values), s.buckets[i]); err != nil {
+			return err
+		}
+	}
+	values := append(append([]string{}, s.labels...), "+Inf")
+	_, err := fmt.Fprintf(w, "%s_bucket%s %d\n%s_sum%s %s\n%s_count%s %d\n",
+		d.Name, labelPairs(names, values), s.count,
+		d.Name, labelPairs(d.Labels, s.labels), formatFloat(s.value),
+		d.Name, labelPairs(d.Labels, s.labels), s.count)
+	return err
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func labelPairs(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	pairs := make([]string, len(names))
+	for i, n := range names {
+		pairs[i] = fmt.Sprintf(`%s="%s"`, n, labelEscaper.Replace(values[i]))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
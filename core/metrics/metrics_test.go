@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/akutz/gofig"
+)
+
+func TestWrite(t *testing.T) {
+	r := NewRegistry()
+	r.Inc(VolumeOperations, "ebs", "integration.Mount", ResultSuccess)
+	r.Inc(VolumeOperations, "ebs", "integration.Mount", ResultSuccess)
+	r.Observe(VolumeOperationDuration, 2.5, "ebs", "integration.Mount")
+	r.Set(NodeHeartbeat, 1488478869, "i-123", `web"1`)
+
+	buf := &bytes.Buffer{}
+	if err := r.Write(buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+
+	for _, line := range []string{
+		"# TYPE rexray_volume_operations_total counter",
+		`rexray_volume_operations_total{service="ebs",operation="integration.Mount",result="success"} 2`,
+		`rexray_volume_operation_duration_seconds_bucket{service="ebs",operation="integration.Mount",le="1"} 0`,
+		`rexray_volume_operation_duration_seconds_bucket{service="ebs",operation="integration.Mount",le="5"} 1`,
+		`rexray_volume_operation_duration_seconds_bucket{service="ebs",operation="integration.Mount",le="+Inf"} 1`,
+		`rexray_volume_operation_duration_seconds_sum{service="ebs",operation="integration.Mount"} 2.5`,
+		`rexray_node_heartbeat_timestamp_seconds{instance_id="i-123",hostname="web\"1"} 1.488478869e+09`,
+	} {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("missing line: %s\n%s", line, out)
+		}
+	}
+}
+
+// TestAssetsReferenceMetrics verifies that the alerting rules and dashboard
+// refer only to the metrics the service exposes.
+func TestAssetsReferenceMetrics(t *testing.T) {
+	known := map[string]bool{}
+	for _, d := range Descs {
+		known[d.Name] = true
+		if d.Kind == Histogram {
+			for _, s := range []string{"_bucket", "_sum", "_count"} {
+				known[d.Name+s] = true
+			}
+		}
+	}
+
+	rules, err := json.Marshal(Rules(gofig.New()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dashboard, err := json.Marshal(Dashboard())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := regexp.MustCompile(`rexray_[a-z_]+`)
+	for _, asset := range [][]byte{rules, dashboard} {
+		for _, name := range names.FindAllString(string(asset), -1) {
+			if !known[name] {
+				t.Errorf("unknown metric %s", name)
+			}
+		}
+	}
+}
@@ -0,0 +1,515 @@
+// Package migrate moves the data of a volume onto a new volume. It is used to
+// emulate operations such as resize for storage platforms that cannot expand
+// a volume in place.
+package migrate
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	apitypes "github.com/emccode/libstorage/api/types"
+	apiutils "github.com/emccode/libstorage/api/utils"
+
+	"github.com/emccode/rexray/core/errcodes"
+)
+
+// DowntimeWarning is the message displayed to users prior to a resize that
+// is performed by migrating the volume's data.
+const DowntimeWarning = `The storage platform does not support expanding volumes
+in place. The volume will be resized by creating a new volume, copying the
+data, and swapping the new volume in place of the old one. The volume is
+unavailable for the duration of the operation and its data is copied twice in
+order to preserve the volume's name.`
+
+// VolumeResizer is implemented by storage drivers that are able to expand a
+// volume natively.
+type VolumeResizer interface {
+
+	// VolumeResize expands a volume to the provided size.
+	VolumeResize(
+		ctx apitypes.Context,
+		volumeID string,
+		size int64,
+		opts apitypes.Store) (*apitypes.Volume, error)
+}
+
+// ResizeOpts are the options used when resizing a volume.
+type ResizeOpts struct {
+
+	// Size is the new size of the volume in GB.
+	Size int64
+
+	// FSType is the file system type used when formatting the new volume.
+	FSType string
+
+	// Opts are additional, driver-specific options.
+	Opts apitypes.Store
+
+	// Progress, if set, is invoked before each step of a migration with the
+	// index of the step and the total number of steps.
+	Progress func(step, total int) error
+
+	// TempVolumeID is the ID of the temporary volume of a migration. It is
+	// set when the temporary volume is created, and must be provided to
+	// resume a migration past that step.
+	TempVolumeID string
+
+	// TempCreated, if set, is invoked with the ID of the temporary volume
+	// once it is created so that the ID may be recorded for a migration
+	// that is resumed. The temporary volume is removed if it returns an
+	// error.
+	TempCreated func(volumeID string) error
+}
+
+// The steps of a resize performed by migrating a volume's data. Each step may
+// be repeated if a migration is resumed after it was interrupted.
+const (
+	stepCreateTemp = iota
+	stepCopyToTemp
+	stepRemoveOriginal
+	stepRecreate
+	stepCopyFromTemp
+	stepRemoveTemp
+
+	// ResizeSteps is the number of steps in a resize performed by migrating
+	// a volume's data.
+	ResizeSteps
+)
+
+// NewResizerFunc returns a resizer that expands the volumes of a storage
+// driver with the storage platform's API, without an initialized driver.
+type NewResizerFunc func(
+	ctx apitypes.Context, config gofig.Config) (VolumeResizer, error)
+
+var (
+	resizers    = map[string]NewResizerFunc{}
+	resizersRwl sync.RWMutex
+)
+
+// Register registers the function that returns the resizer of the storage
+// driver with the provided name. A driver that registers a resizer is able
+// to resize volumes without migrating their data.
+func Register(driverName string, f NewResizerFunc) {
+	resizersRwl.Lock()
+	defer resizersRwl.Unlock()
+	resizers[strings.ToLower(driverName)] = f
+}
+
+func lookup(driverName string) (NewResizerFunc, bool) {
+	resizersRwl.RLock()
+	defer resizersRwl.RUnlock()
+	f, ok := resizers[strings.ToLower(driverName)]
+	return f, ok
+}
+
+// IsNative returns a flag indicating whether or not the storage driver of
+// the configured service is able to resize volumes without migrating their
+// data. The client's storage is not the driver itself unless the driver is
+// local, so the driver's capability is determined by its name.
+func IsNative(
+	ctx apitypes.Context,
+	config gofig.Config,
+	client apitypes.Client) (bool, error) {
+
+	if _, ok := client.Storage().(VolumeResizer); ok {
+		return true, nil
+	}
+	driverName, err := serviceDriverName(ctx, config, client)
+	if err != nil {
+		return false, err
+	}
+	_, ok := lookup(driverName)
+	return ok, nil
+}
+
+// resizer returns the resizer of the configured service's storage driver,
+// or nil if the driver is not able to resize volumes natively.
+func resizer(
+	ctx apitypes.Context,
+	config gofig.Config,
+	client apitypes.Client) (VolumeResizer, error) {
+
+	if r, ok := client.Storage().(VolumeResizer); ok {
+		return r, nil
+	}
+	driverName, err := serviceDriverName(ctx, config, client)
+	if err != nil {
+		return nil, err
+	}
+	f, ok := lookup(driverName)
+	if !ok {
+		return nil, nil
+	}
+	return f(ctx, config)
+}
+
+// serviceDriverName returns the name of the configured service's storage
+// driver.
+func serviceDriverName(
+	ctx apitypes.Context,
+	config gofig.Config,
+	client apitypes.Client) (string, error) {
+
+	service := config.GetString(apitypes.ConfigService)
+	svcs, err := client.API().Services(ctx)
+	if err != nil {
+		return "", err
+	}
+	for name, svc := range svcs {
+		if strings.EqualFold(name, service) && svc.Driver != nil {
+			return svc.Driver.Name, nil
+		}
+	}
+	return "", goof.WithField("service", service, "unknown service")
+}
+
+// Resize resizes a volume. If the storage driver does not support expanding
+// volumes natively then the volume is resized by migrating its data to a new
+// volume with the same name.
+func Resize(
+	ctx apitypes.Context,
+	config gofig.Config,
+	client apitypes.Client,
+	volumeID string,
+	opts *ResizeOpts) (*apitypes.Volume, error) {
+
+	if opts.Opts == nil {
+		opts.Opts = apiutils.NewStore()
+	}
+
+	vol, err := client.Storage().VolumeInspect(
+		ctx, volumeID, &apitypes.VolumeInspectOpts{
+			Attachments: true,
+			Opts:        opts.Opts,
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	fields := goof.Fields{
+		"volumeID":   vol.ID,
+		"volumeName": vol.Name,
+		"size":       vol.Size,
+		"newSize":    opts.Size,
+	}
+
+	if opts.Size <= vol.Size {
+		return nil, goof.WithFields(
+			fields, "new size must be larger than current size")
+	}
+
+	r, err := resizer(ctx, config, client)
+	if err != nil {
+		return nil, err
+	}
+	if r != nil {
+		ctx.WithFields(fields).Info("resizing volume natively")
+		return r.VolumeResize(ctx, vol.ID, opts.Size, opts.Opts)
+	}
+
+	ctx.WithFields(fields).Warn("resizing volume by migrating its data")
+	return ResumeResize(ctx, client, vol.Name, stepCreateTemp, opts)
+}
+
+// ResumeResize performs a resize by migrating a volume's data, beginning at
+// the provided step. The volume is looked up by name at each step since the
+// original volume is replaced part way through the migration. The temporary
+// volume is only looked up by its ID, opts.TempVolumeID, since an unrelated
+// volume may have its name; a migration past the creation of the temporary
+// volume is not resumed without it. A migration is refused while the volume
+// is attached, since its data would change as it is copied.
+func ResumeResize(
+	ctx apitypes.Context,
+	client apitypes.Client,
+	volumeName string,
+	step int,
+	opts *ResizeOpts) (*apitypes.Volume, error) {
+
+	if opts.Opts == nil {
+		opts.Opts = apiutils.NewStore()
+	}
+
+	tmpName := fmt.Sprintf("%s-resize", volumeName)
+	fields := goof.Fields{
+		"volumeName":    volumeName,
+		"tmpVolumeName": tmpName,
+		"tmpVolumeID":   opts.TempVolumeID,
+		"newSize":       opts.Size,
+	}
+	tmpFields := goof.Fields{
+		"tmpVolumeName": tmpName,
+		"tmpVolumeID":   opts.TempVolumeID,
+	}
+
+	if step > stepCreateTemp && opts.TempVolumeID == "" {
+		return nil, goof.WithFields(
+			fields, "temporary volume unknown; resize cannot be resumed")
+	}
+
+	if err := checkDetached(ctx, client, volumeName, fields); err != nil {
+		return nil, err
+	}
+
+	for ; step < ResizeSteps; step++ {
+		if opts.Progress != nil {
+			if err := opts.Progress(step, ResizeSteps); err != nil {
+				return nil, err
+			}
+		}
+
+		vol, err := volumeByName(ctx, client, volumeName)
+		if err != nil {
+			return nil, err
+		}
+		tmp, err := volumeByID(ctx, client, opts.TempVolumeID)
+		if err != nil {
+			return nil, err
+		}
+
+		switch step {
+		case stepCreateTemp:
+			if vol == nil {
+				return nil, notFound(fields, "volume not found")
+			}
+			if tmp != nil {
+				continue
+			}
+			tmp, err = Create(ctx, client, vol, tmpName, opts.Size, opts.Opts)
+			if err != nil {
+				return nil, err
+			}
+			ctx.WithField("tmpVolumeID", tmp.ID).Debug(
+				"created temporary volume")
+
+			opts.TempVolumeID = tmp.ID
+			fields["tmpVolumeID"] = tmp.ID
+			tmpFields["tmpVolumeID"] = tmp.ID
+			if opts.TempCreated != nil {
+				if err := opts.TempCreated(tmp.ID); err != nil {
+					removeTemp(ctx, client, tmp, opts.Opts)
+					return nil, goof.WithFieldsE(fields,
+						"error recording temporary volume", err)
+				}
+			}
+
+		case stepCopyToTemp:
+			if vol == nil || tmp == nil {
+				return nil, notFound(fields, "volume not found")
+			}
+			if err := CopyData(ctx, client, vol, tmp, opts.FSType); err != nil {
+				// the original volume is intact, so the temporary volume
+				// is removed rather than left for a resumed migration
+				removeTemp(ctx, client, tmp, opts.Opts)
+				return nil, goof.WithFieldsE(
+					fields, "error copying data to temporary volume", err)
+			}
+
+		case stepRemoveOriginal:
+			if vol == nil {
+				continue
+			}
+			if err := client.Storage().VolumeRemove(
+				ctx, vol.ID, opts.Opts); err != nil {
+				return nil, goof.WithFieldsE(
+					fields, "error removing original volume", err)
+			}
+
+		case stepRecreate:
+			if tmp == nil {
+				return nil, notFound(fields, "temporary volume not found")
+			}
+			if vol != nil {
+				continue
+			}
+			if _, err := Create(
+				ctx, client, tmp, volumeName, opts.Size, opts.Opts); err != nil {
+				return nil, goof.WithFieldsE(tmpFields,
+					"error re-creating volume; data remains on temporary volume",
+					err)
+			}
+
+		case stepCopyFromTemp:
+			if vol == nil || tmp == nil {
+				return nil, notFound(fields, "volume not found")
+			}
+			if err := CopyData(ctx, client, tmp, vol, opts.FSType); err != nil {
+				return nil, goof.WithFieldsE(tmpFields,
+					"error copying data; data remains on temporary volume", err)
+			}
+
+		case stepRemoveTemp:
+			if tmp == nil {
+				continue
+			}
+			removeTemp(ctx, client, tmp, opts.Opts)
+		}
+	}
+
+	vol, err := volumeByName(ctx, client, volumeName)
+	if err != nil {
+		return nil, err
+	}
+	if vol == nil {
+		return nil, notFound(fields, "volume not found")
+	}
+
+	ctx.WithFields(fields).Info("resized volume")
+	return vol, nil
+}
+
+// checkDetached returns an error if the volume with the provided name is
+// attached to any instance.
+func checkDetached(
+	ctx apitypes.Context,
+	client apitypes.Client,
+	name string,
+	fields goof.Fields) error {
+
+	vols, err := client.Storage().Volumes(
+		ctx, &apitypes.VolumesOpts{Attachments: true})
+	if err != nil {
+		return err
+	}
+	for _, v := range vols {
+		if strings.EqualFold(v.Name, name) && len(v.Attachments) > 0 {
+			return goof.WithFields(
+				fields, "volume must be detached before it can be migrated")
+		}
+	}
+	return nil
+}
+
+// notFound returns the error of a volume of a resize that does not exist.
+func notFound(fields goof.Fields, msg string) error {
+	return errcodes.New(errcodes.VolumeNotFound, goof.WithFields(fields, msg))
+}
+
+// volumeByName returns the volume with the provided name, or nil if no such
+// volume exists.
+func volumeByName(
+	ctx apitypes.Context,
+	client apitypes.Client,
+	name string) (*apitypes.Volume, error) {
+
+	vols, err := client.Storage().Volumes(
+		ctx, &apitypes.VolumesOpts{Attachments: false})
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range vols {
+		if strings.ToLower(v.Name) == strings.ToLower(name) {
+			return v, nil
+		}
+	}
+	return nil, nil
+}
+
+// volumeByID returns the volume with the provided ID, or nil if the ID is
+// empty or no such volume exists.
+func volumeByID(
+	ctx apitypes.Context,
+	client apitypes.Client,
+	id string) (*apitypes.Volume, error) {
+
+	if id == "" {
+		return nil, nil
+	}
+	vols, err := client.Storage().Volumes(
+		ctx, &apitypes.VolumesOpts{Attachments: false})
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range vols {
+		if v.ID == id {
+			return v, nil
+		}
+	}
+	return nil, nil
+}
+
+// removeTemp removes the temporary volume of a migration.
+func removeTemp(
+	ctx apitypes.Context,
+	client apitypes.Client,
+	tmp *apitypes.Volume,
+	opts apitypes.Store) {
+
+	if err := client.Storage().VolumeRemove(ctx, tmp.ID, opts); err != nil {
+		ctx.WithError(err).WithField("tmpVolumeID", tmp.ID).Warn(
+			"error removing temporary volume")
+	}
+}
+
+// Create creates a new volume with the same properties as the provided
+// volume but with the given name and size.
+func Create(
+	ctx apitypes.Context,
+	client apitypes.Client,
+	src *apitypes.Volume,
+	name string,
+	size int64,
+	opts apitypes.Store) (*apitypes.Volume, error) {
+
+	return client.Storage().VolumeCreate(
+		ctx, name, &apitypes.VolumeCreateOpts{
+			AvailabilityZone: &src.AvailabilityZone,
+			IOPS:             &src.IOPS,
+			Size:             &size,
+			Type:             &src.Type,
+			Opts:             opts,
+		})
+}
+
+// CopyData mounts the source and target volumes on the local host and copies
+// the contents of the source volume to the target volume. Both volumes are
+// unmounted when the copy completes, whether or not it succeeded.
+func CopyData(
+	ctx apitypes.Context,
+	client apitypes.Client,
+	src, dst *apitypes.Volume,
+	fsType string) error {
+
+	srcPath, _, err := client.Integration().Mount(
+		ctx, src.ID, "", &apitypes.VolumeMountOpts{})
+	if err != nil {
+		return err
+	}
+	defer unmount(ctx, client, src)
+
+	dstPath, _, err := client.Integration().Mount(
+		ctx, dst.ID, "", &apitypes.VolumeMountOpts{NewFSType: fsType})
+	if err != nil {
+		return err
+	}
+	defer unmount(ctx, client, dst)
+
+	ctx.WithFields(map[string]interface{}{
+		"srcPath": srcPath,
+		"dstPath": dstPath,
+	}).Info("copying volume data")
+
+	out, err := exec.Command(
+		"cp", "-a", fmt.Sprintf("%s/.", srcPath), dstPath).CombinedOutput()
+	if err != nil {
+		return goof.WithFieldsE(goof.Fields{
+			"srcPath": srcPath,
+			"dstPath": dstPath,
+			"output":  strings.TrimSpace(string(out)),
+		}, "error copying volume data", err)
+	}
+
+	return nil
+}
+
+func unmount(
+	ctx apitypes.Context, client apitypes.Client, vol *apitypes.Volume) {
+	if err := client.Integration().Unmount(
+		ctx, vol.ID, "", apiutils.NewStore()); err != nil {
+		ctx.WithError(err).WithField("volumeID", vol.ID).Warn(
+			"error unmounting volume")
+	}
+}
@@ -0,0 +1,344 @@
+package migrate
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/akutz/gofig"
+	"github.com/emccode/libstorage/api/context"
+	apitypes "github.com/emccode/libstorage/api/types"
+)
+
+// fakeClient is a libStorage client whose volumes are directories.
+type fakeClient struct {
+	apitypes.Client
+	storage     *fakeStorage
+	integration *fakeIntegration
+}
+
+func (c *fakeClient) API() apitypes.APIClient {
+	return &fakeAPI{}
+}
+
+func (c *fakeClient) Storage() apitypes.StorageDriver {
+	return c.storage
+}
+
+func (c *fakeClient) Integration() apitypes.IntegrationDriver {
+	return c.integration
+}
+
+type fakeAPI struct {
+	apitypes.APIClient
+}
+
+func (a *fakeAPI) Services(
+	ctx apitypes.Context) (map[string]*apitypes.ServiceInfo, error) {
+
+	return map[string]*apitypes.ServiceInfo{
+		"fake": {Name: "fake", Driver: &apitypes.DriverInfo{Name: "fake"}},
+	}, nil
+}
+
+type fakeStorage struct {
+	apitypes.StorageDriver
+	dir   string
+	vols  []*apitypes.Volume
+	next  int
+	calls []string
+}
+
+func (s *fakeStorage) path(id string) string {
+	return filepath.Join(s.dir, id)
+}
+
+func (s *fakeStorage) add(name string, size int64) *apitypes.Volume {
+	s.next++
+	v := &apitypes.Volume{
+		ID:   fmt.Sprintf("vol-%d", s.next),
+		Name: name,
+		Size: size,
+	}
+	os.MkdirAll(s.path(v.ID), 0755)
+	s.vols = append(s.vols, v)
+	return v
+}
+
+func (s *fakeStorage) Volumes(
+	ctx apitypes.Context,
+	opts *apitypes.VolumesOpts) ([]*apitypes.Volume, error) {
+
+	return s.vols, nil
+}
+
+func (s *fakeStorage) VolumeInspect(
+	ctx apitypes.Context,
+	volumeID string,
+	opts *apitypes.VolumeInspectOpts) (*apitypes.Volume, error) {
+
+	for _, v := range s.vols {
+		if v.ID == volumeID {
+			return v, nil
+		}
+	}
+	return nil, fmt.Errorf("volume %s not found", volumeID)
+}
+
+func (s *fakeStorage) VolumeCreate(
+	ctx apitypes.Context,
+	name string,
+	opts *apitypes.VolumeCreateOpts) (*apitypes.Volume, error) {
+
+	s.calls = append(s.calls, "create "+name)
+	return s.add(name, *opts.Size), nil
+}
+
+func (s *fakeStorage) VolumeRemove(
+	ctx apitypes.Context,
+	volumeID string,
+	opts apitypes.Store) error {
+
+	for i, v := range s.vols {
+		if v.ID == volumeID {
+			s.calls = append(s.calls, "remove "+v.Name)
+			s.vols = append(s.vols[:i], s.vols[i+1:]...)
+			return os.RemoveAll(s.path(volumeID))
+		}
+	}
+	return fmt.Errorf("volume %s not found", volumeID)
+}
+
+type fakeIntegration struct {
+	apitypes.IntegrationDriver
+	storage *fakeStorage
+
+	// failName is the name of the volume that cannot be mounted.
+	failName string
+}
+
+func (d *fakeIntegration) Mount(
+	ctx apitypes.Context,
+	volumeID, volumeName string,
+	opts *apitypes.VolumeMountOpts) (string, *apitypes.Volume, error) {
+
+	v, err := d.storage.VolumeInspect(ctx, volumeID, nil)
+	if err != nil {
+		return "", nil, err
+	}
+	if v.Name == d.failName {
+		return "", nil, fmt.Errorf("error mounting %s", v.Name)
+	}
+	return d.storage.path(volumeID), v, nil
+}
+
+func (d *fakeIntegration) Unmount(
+	ctx apitypes.Context,
+	volumeID, volumeName string,
+	opts apitypes.Store) error {
+
+	return nil
+}
+
+func newFakeClient(t *testing.T) (*fakeClient, func()) {
+	dir, err := ioutil.TempDir("", "migrate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeStorage{dir: dir}
+	c := &fakeClient{
+		storage:     s,
+		integration: &fakeIntegration{storage: s},
+	}
+	return c, func() { os.RemoveAll(dir) }
+}
+
+// volumeData returns the contents of the file "data" on the volume with the
+// provided name.
+func volumeData(t *testing.T, c *fakeClient, name string) string {
+	for _, v := range c.storage.vols {
+		if v.Name == name {
+			buf, err := ioutil.ReadFile(
+				filepath.Join(c.storage.path(v.ID), "data"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			return string(buf)
+		}
+	}
+	t.Fatalf("volume %s not found", name)
+	return ""
+}
+
+func names(vols []*apitypes.Volume) string {
+	n := []string{}
+	for _, v := range vols {
+		n = append(n, v.Name)
+	}
+	return strings.Join(n, ",")
+}
+
+func TestResumeResize(t *testing.T) {
+	c, cleanup := newFakeClient(t)
+	defer cleanup()
+
+	orig := c.storage.add("data", 10)
+	if err := ioutil.WriteFile(filepath.Join(
+		c.storage.path(orig.ID), "data"), []byte("1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []int{}
+	var tempID string
+	vol, err := ResumeResize(context.Background(), c, "data", stepCreateTemp,
+		&ResizeOpts{
+			Size: 20,
+			Progress: func(step, total int) error {
+				steps = append(steps, step)
+				return nil
+			},
+			TempCreated: func(volumeID string) error {
+				tempID = volumeID
+				return nil
+			},
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(steps, []int{0, 1, 2, 3, 4, 5}) {
+		t.Fatalf("steps=%v", steps)
+	}
+	if !reflect.DeepEqual(c.storage.calls, []string{
+		"create data-resize",
+		"remove data",
+		"create data",
+		"remove data-resize",
+	}) {
+		t.Fatalf("calls=%v", c.storage.calls)
+	}
+	if tempID == "" || vol.Name != "data" || vol.Size != 20 ||
+		vol.ID == orig.ID {
+		t.Fatalf("tempID=%q vol=%+v", tempID, vol)
+	}
+	if names(c.storage.vols) != "data" {
+		t.Fatalf("volumes=%s", names(c.storage.vols))
+	}
+	if d := volumeData(t, c, "data"); d != "1" {
+		t.Fatalf("data=%q", d)
+	}
+}
+
+func TestResumeResizeRollback(t *testing.T) {
+	c, cleanup := newFakeClient(t)
+	defer cleanup()
+
+	orig := c.storage.add("data", 10)
+	if err := ioutil.WriteFile(filepath.Join(
+		c.storage.path(orig.ID), "data"), []byte("1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// the copy to the temporary volume fails, so the temporary volume is
+	// removed and the original is left intact
+	c.integration.failName = "data-resize"
+	if _, err := ResumeResize(context.Background(), c, "data",
+		stepCreateTemp, &ResizeOpts{Size: 20}); err == nil {
+		t.Fatal("expected the copy to fail")
+	}
+	if !reflect.DeepEqual(c.storage.calls, []string{
+		"create data-resize",
+		"remove data-resize",
+	}) {
+		t.Fatalf("calls=%v", c.storage.calls)
+	}
+	if names(c.storage.vols) != "data" || c.storage.vols[0].ID != orig.ID {
+		t.Fatalf("volumes=%s", names(c.storage.vols))
+	}
+
+	// the copy back from the temporary volume fails, so the temporary
+	// volume is kept and the migration is resumed from that step
+	c.storage.calls = nil
+	c.integration.failName = ""
+	var tempID string
+	opts := &ResizeOpts{
+		Size: 20,
+		Progress: func(step, total int) error {
+			if step == stepCopyFromTemp && c.integration.failName == "" {
+				c.integration.failName = "data"
+			}
+			return nil
+		},
+		TempCreated: func(volumeID string) error {
+			tempID = volumeID
+			return nil
+		},
+	}
+	if _, err := ResumeResize(context.Background(), c, "data",
+		stepCreateTemp, opts); err == nil {
+		t.Fatal("expected the copy to fail")
+	}
+	if names(c.storage.vols) != "data-resize,data" {
+		t.Fatalf("volumes=%s", names(c.storage.vols))
+	}
+	if d := volumeData(t, c, "data-resize"); d != "1" {
+		t.Fatalf("data=%q", d)
+	}
+
+	c.storage.calls = nil
+	c.integration.failName = "-"
+	vol, err := ResumeResize(context.Background(), c, "data",
+		stepCopyFromTemp, &ResizeOpts{Size: 20, TempVolumeID: tempID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(c.storage.calls, []string{"remove data-resize"}) {
+		t.Fatalf("calls=%v", c.storage.calls)
+	}
+	if vol.Size != 20 || volumeData(t, c, "data") != "1" {
+		t.Fatalf("vol=%+v", vol)
+	}
+}
+
+func TestResumeResizeUnknownTemp(t *testing.T) {
+	c, cleanup := newFakeClient(t)
+	defer cleanup()
+
+	c.storage.add("data", 10)
+	if _, err := ResumeResize(context.Background(), c, "data",
+		stepRemoveOriginal, &ResizeOpts{Size: 20}); err == nil {
+		t.Fatal("expected a resume without the temporary volume to fail")
+	}
+	if len(c.storage.calls) > 0 {
+		t.Fatalf("calls=%v", c.storage.calls)
+	}
+}
+
+func TestResizeAttached(t *testing.T) {
+	c, cleanup := newFakeClient(t)
+	defer cleanup()
+
+	vol := c.storage.add("data", 10)
+	vol.Attachments = []*apitypes.VolumeAttachment{{
+		VolumeID:   vol.ID,
+		InstanceID: &apitypes.InstanceID{ID: "i-1", Driver: "fake"},
+	}}
+
+	config := gofig.New()
+	config.Set(apitypes.ConfigService, "fake")
+	if _, err := Resize(context.Background(), config, c, vol.ID,
+		&ResizeOpts{Size: 20}); err == nil {
+		t.Fatal("expected the migration of an attached volume to fail")
+	}
+	if _, err := ResumeResize(context.Background(), c, "data",
+		stepCreateTemp, &ResizeOpts{Size: 20}); err == nil {
+		t.Fatal("expected the migration of an attached volume to fail")
+	}
+	if len(c.storage.calls) > 0 {
+		t.Fatalf("calls=%v", c.storage.calls)
+	}
+}
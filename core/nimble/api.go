@@ -0,0 +1,329 @@
+package nimble
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+
+	"github.com/emccode/rexray/core/errcodes"
+)
+
+// pageSize is the number of objects requested per page.
+const pageSize = 1000
+
+// volume is a volume, as returned by the API.
+type volume struct {
+	ID                   string `json:"id"`
+	Name                 string `json:"name"`
+	Description          string `json:"description"`
+	Size                 int64  `json:"size"`
+	VolState             string `json:"vol_state"`
+	TargetName           string `json:"target_name"`
+	SerialNumber         string `json:"serial_number"`
+	PerfPolicyName       string `json:"perfpolicy_name"`
+	PoolName             string `json:"pool_name"`
+	AccessControlRecords []*acr `json:"access_control_records"`
+}
+
+const volumeFields = "id,name,description,size,vol_state,target_name," +
+	"serial_number,perfpolicy_name,pool_name,access_control_records"
+
+// acr is an access control record, which grants the initiators of a group
+// access to a volume as a LUN.
+type acr struct {
+	ID                 string `json:"id"`
+	InitiatorGroupID   string `json:"initiator_group_id"`
+	InitiatorGroupName string `json:"initiator_group_name"`
+	LUN                int    `json:"lun"`
+}
+
+// snapshot is a snapshot, as returned by the API.
+type snapshot struct {
+	ID           string `json:"id"`
+	Name         string `json:"name"`
+	Description  string `json:"description"`
+	VolID        string `json:"vol_id"`
+	Size         int64  `json:"size"`
+	CreationTime int64  `json:"creation_time"`
+	Online       bool   `json:"online"`
+}
+
+const snapshotFields = "id,name,description,vol_id,size,creation_time,online"
+
+// initiatorGroup is an initiator group, as returned by the API.
+type initiatorGroup struct {
+	ID              string `json:"id"`
+	Name            string `json:"name"`
+	ISCSIInitiators []struct {
+		IQN string `json:"iqn"`
+	} `json:"iscsi_initiators"`
+}
+
+// apiError is an error returned by the API.
+type apiError struct {
+	status int
+	code   string
+	text   string
+}
+
+func (e *apiError) Error() string {
+	return "nimble: " + strconv.Itoa(e.status) + " " + e.text +
+		" (" + e.code + ")"
+}
+
+// Code returns the code of the error's HTTP status.
+func (e *apiError) Code() errcodes.Code {
+	return errcodes.FromStatus(e.status)
+}
+
+// MarshalJSON marshals the error as its message and code, since the
+// libStorage server reports a driver's error as the error's JSON.
+func (e *apiError) MarshalJSON() ([]byte, error) {
+	return errcodes.Marshal(e)
+}
+
+// isNotFound returns a flag indicating whether the error is that of a
+// request for an object that does not exist.
+func isNotFound(err error) bool {
+	e, ok := err.(*apiError)
+	return ok && e.status == http.StatusNotFound
+}
+
+// client calls the REST API of an array with a session token.
+type client struct {
+	endpoint string
+	username string
+	password string
+	client   *http.Client
+
+	tokenRwl sync.RWMutex
+	token    string
+}
+
+func newClient(config gofig.Config) (*client, error) {
+	endpoint := strings.TrimSuffix(config.GetString("nimble.endpoint"), "/")
+	if endpoint == "" {
+		return nil, goof.New("nimble driver requires nimble.endpoint")
+	}
+	c := &client{
+		endpoint: endpoint + "/v1",
+		username: config.GetString("nimble.username"),
+		password: config.GetString("nimble.password"),
+		client:   &http.Client{Timeout: 60 * time.Second},
+	}
+	if config.GetBool("nimble.insecure") {
+		c.client.Transport = &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		}
+	}
+	return c, nil
+}
+
+// do sends a request whose body and result are the data of the API's
+// request and response envelopes. A new session token is requested if there
+// is none or the current one expired.
+func (c *client) do(method, path string, body, result interface{}) error {
+	res, err := c.send(method, path, body)
+	if err == nil && res.StatusCode == http.StatusUnauthorized {
+		res.Body.Close()
+		c.tokenRwl.Lock()
+		c.token = ""
+		c.tokenRwl.Unlock()
+		res, err = c.send(method, path, body)
+	}
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		e := &struct {
+			Messages []struct {
+				Code string `json:"code"`
+				Text string `json:"text"`
+			} `json:"messages"`
+		}{}
+		json.NewDecoder(res.Body).Decode(e)
+		ae := &apiError{status: res.StatusCode}
+		if len(e.Messages) > 0 {
+			ae.code, ae.text = e.Messages[0].Code, e.Messages[0].Text
+		}
+		return ae
+	}
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(&struct {
+		Data interface{} `json:"data"`
+	}{result})
+}
+
+func (c *client) send(
+	method, path string, body interface{}) (*http.Response, error) {
+
+	if err := c.login(); err != nil {
+		return nil, err
+	}
+	c.tokenRwl.RLock()
+	token := c.token
+	c.tokenRwl.RUnlock()
+	return c.request(method, path, body, token)
+}
+
+func (c *client) request(
+	method, path string,
+	body interface{},
+	token string) (*http.Response, error) {
+
+	var buf []byte
+	if body != nil {
+		var err error
+		if buf, err = json.Marshal(map[string]interface{}{
+			"data": body,
+		}); err != nil {
+			return nil, err
+		}
+	}
+	req, err := http.NewRequest(method, c.endpoint+path, bytes.NewReader(buf))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("X-Auth-Token", token)
+	}
+	res, err := c.client.Do(req)
+	if err != nil {
+		return nil, goof.WithFieldE("path", path, "error calling nimble", err)
+	}
+	return res, nil
+}
+
+// login requests a session token if there is none.
+func (c *client) login() error {
+	c.tokenRwl.Lock()
+	defer c.tokenRwl.Unlock()
+	if c.token != "" {
+		return nil
+	}
+	res, err := c.request("POST", "/tokens", map[string]string{
+		"username": c.username,
+		"password": c.password,
+	}, "")
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusCreated && res.StatusCode != http.StatusOK {
+		return &apiError{
+			status: res.StatusCode,
+			code:   "login",
+			text:   "error requesting session token",
+		}
+	}
+	t := &struct {
+		Data struct {
+			SessionToken string `json:"session_token"`
+		} `json:"data"`
+	}{}
+	if err := json.NewDecoder(res.Body).Decode(t); err != nil {
+		return err
+	}
+	c.token = t.Data.SessionToken
+	return nil
+}
+
+// list returns all of the objects of a collection's detail view that match
+// the query.
+func (c *client) list(path string, q url.Values, all interface{}) error {
+	raw := []json.RawMessage{}
+	for start := 0; ; start += pageSize {
+		q.Set("startRow", strconv.Itoa(start))
+		q.Set("endRow", strconv.Itoa(start+pageSize))
+		page := []json.RawMessage{}
+		if err := c.do(
+			"GET", path+"/detail?"+q.Encode(), nil, &page); err != nil {
+			return err
+		}
+		raw = append(raw, page...)
+		if len(page) < pageSize {
+			break
+		}
+	}
+	buf, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(buf, all)
+}
+
+func (c *client) volumes() ([]*volume, error) {
+	vols := []*volume{}
+	err := c.list("/volumes", url.Values{"fields": {volumeFields}}, &vols)
+	return vols, err
+}
+
+func (c *client) volume(id string) (*volume, error) {
+	v := &volume{}
+	if err := c.do("GET", "/volumes/"+id, nil, v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// id returns the ID of the object of a collection with the name.
+func (c *client) id(path, name string) (string, error) {
+	all := []*struct {
+		ID string `json:"id"`
+	}{}
+	if err := c.list(path, url.Values{
+		"name":   {name},
+		"fields": {"id"},
+	}, &all); err != nil {
+		return "", err
+	}
+	if len(all) == 0 {
+		return "", &apiError{
+			status: http.StatusNotFound,
+			code:   "SM_enoent",
+			text:   path[1:] + " not found: " + name,
+		}
+	}
+	return all[0].ID, nil
+}
+
+func (c *client) initiatorGroups() ([]*initiatorGroup, error) {
+	groups := []*initiatorGroup{}
+	err := c.list("/initiator_groups", url.Values{
+		"fields": {"id,name,iscsi_initiators"},
+	}, &groups)
+	return groups, err
+}
+
+// snapshots returns the snapshots of a volume. The API only lists the
+// snapshots of one volume at a time.
+func (c *client) snapshots(volumeID string) ([]*snapshot, error) {
+	snaps := []*snapshot{}
+	err := c.list("/snapshots", url.Values{
+		"vol_id": {volumeID},
+		"fields": {snapshotFields},
+	}, &snaps)
+	return snaps, err
+}
+
+func (c *client) snapshot(id string) (*snapshot, error) {
+	s := &snapshot{}
+	if err := c.do("GET", "/snapshots/"+id, nil, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
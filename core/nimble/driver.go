@@ -0,0 +1,560 @@
+package nimble
+
+import (
+	"strconv"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	"github.com/emccode/libstorage/api/context"
+	apitypes "github.com/emccode/libstorage/api/types"
+)
+
+// driver manages the volumes of an array. The instance ID of a node is its
+// iSCSI initiator name, and a node is granted access to a volume with an
+// access control record for an initiator group of its initiator.
+type driver struct {
+	config gofig.Config
+	api    *client
+	prefix string
+}
+
+func (d *driver) Name() string {
+	return Name
+}
+
+func (d *driver) Init(ctx apitypes.Context, config gofig.Config) error {
+	d.config = config
+	api, err := newClient(config)
+	if err != nil {
+		return err
+	}
+	d.api = api
+	if d.prefix = config.GetString("nimble.prefix"); d.prefix == "" {
+		d.prefix = defaultPrefix
+	}
+
+	ctx.WithField("endpoint", api.endpoint).Info("initialized nimble driver")
+	return nil
+}
+
+func (d *driver) Type(ctx apitypes.Context) (apitypes.StorageType, error) {
+	return apitypes.Block, nil
+}
+
+// NextDeviceInfo returns nil because the devices of attached volumes are
+// named by the node's SCSI subsystem.
+func (d *driver) NextDeviceInfo(
+	ctx apitypes.Context) (*apitypes.NextDeviceInfo, error) {
+
+	return nil, nil
+}
+
+func (d *driver) InstanceInspect(
+	ctx apitypes.Context,
+	opts apitypes.Store) (*apitypes.Instance, error) {
+
+	iid, ok := ctx.Value(context.InstanceIDKey).(*apitypes.InstanceID)
+	if !ok {
+		return nil, goof.New("missing instance ID")
+	}
+	return &apitypes.Instance{InstanceID: iid, Name: iid.ID}, nil
+}
+
+// instanceID returns the initiator name of the node on whose behalf an
+// operation is performed.
+func instanceID(ctx apitypes.Context) string {
+	if iid, ok := ctx.Value(context.InstanceIDKey).(*apitypes.InstanceID); ok {
+		return iid.ID
+	}
+	return ""
+}
+
+// toVolume returns a libStorage volume for a volume of the array. The
+// attachments of the volume are the initiators of the groups its access
+// control records grant access, and the device of the attachment of the
+// instance's initiator is looked up in the local devices by the volume's
+// target and LUN.
+func (d *driver) toVolume(
+	ctx apitypes.Context,
+	v *volume,
+	groups map[string]*initiatorGroup) *apitypes.Volume {
+
+	vol := &apitypes.Volume{
+		ID:     v.ID,
+		Name:   v.Name,
+		Size:   v.Size / mib,
+		Type:   v.PerfPolicyName,
+		Status: v.VolState,
+		Fields: map[string]string{
+			"targetName":   v.TargetName,
+			"serialNumber": v.SerialNumber,
+			"pool":         v.PoolName,
+		},
+	}
+	if groups == nil {
+		return vol
+	}
+	iid := instanceID(ctx)
+	ld, _ := ctx.Value(context.LocalDevicesKey).(*apitypes.LocalDevices)
+	for _, r := range v.AccessControlRecords {
+		g, ok := groups[r.InitiatorGroupID]
+		if !ok {
+			continue
+		}
+		for _, i := range g.ISCSIInitiators {
+			att := &apitypes.VolumeAttachment{
+				VolumeID: vol.ID,
+				InstanceID: &apitypes.InstanceID{
+					ID:     i.IQN,
+					Driver: Name,
+				},
+				Status: "attached",
+				Fields: map[string]string{
+					"initiatorGroup": g.Name,
+					"lun":            strconv.Itoa(r.LUN),
+				},
+			}
+			if i.IQN == iid && ld != nil {
+				att.DeviceName = ld.DeviceMap[deviceKey(v.TargetName, r.LUN)]
+			}
+			vol.Attachments = append(vol.Attachments, att)
+		}
+	}
+	return vol
+}
+
+// groups returns the array's initiator groups keyed by their IDs, or nil if
+// attachments are not requested.
+func (d *driver) groups(attachments bool) (map[string]*initiatorGroup, error) {
+	if !attachments {
+		return nil, nil
+	}
+	all, err := d.api.initiatorGroups()
+	if err != nil {
+		return nil, err
+	}
+	groups := map[string]*initiatorGroup{}
+	for _, g := range all {
+		groups[g.ID] = g
+	}
+	return groups, nil
+}
+
+func (d *driver) Volumes(
+	ctx apitypes.Context,
+	opts *apitypes.VolumesOpts) ([]*apitypes.Volume, error) {
+
+	all, err := d.api.volumes()
+	if err != nil {
+		return nil, err
+	}
+	groups, err := d.groups(opts.Attachments)
+	if err != nil {
+		return nil, err
+	}
+	vols := []*apitypes.Volume{}
+	for _, v := range all {
+		vols = append(vols, d.toVolume(ctx, v, groups))
+	}
+	return vols, nil
+}
+
+func (d *driver) VolumeInspect(
+	ctx apitypes.Context,
+	volumeID string,
+	opts *apitypes.VolumeInspectOpts) (*apitypes.Volume, error) {
+
+	return d.inspect(ctx, volumeID, opts.Attachments)
+}
+
+func (d *driver) inspect(
+	ctx apitypes.Context,
+	volumeID string,
+	attachments bool) (*apitypes.Volume, error) {
+
+	v, err := d.volume(volumeID)
+	if err != nil {
+		return nil, err
+	}
+	groups, err := d.groups(attachments)
+	if err != nil {
+		return nil, err
+	}
+	return d.toVolume(ctx, v, groups), nil
+}
+
+func (d *driver) volume(id string) (*volume, error) {
+	v, err := d.api.volume(id)
+	if isNotFound(err) {
+		return nil, goof.WithField("volumeID", id, "volume not found")
+	}
+	return v, err
+}
+
+// createOpt returns a create option, or else the configured value.
+func (d *driver) createOpt(opts apitypes.Store, key string) string {
+	if opts != nil {
+		if v := opts.GetString(key); v != "" {
+			return v
+		}
+	}
+	return d.config.GetString("nimble." + key)
+}
+
+// VolumeCreate creates a volume in the requested or configured pool with the
+// requested or configured performance policy. A requested volume type is
+// the name of a performance policy.
+func (d *driver) VolumeCreate(
+	ctx apitypes.Context,
+	name string,
+	opts *apitypes.VolumeCreateOpts) (*apitypes.Volume, error) {
+
+	size := int64(defaultSize)
+	if opts.Size != nil && *opts.Size > 0 {
+		size = *opts.Size
+	}
+	body := map[string]interface{}{
+		"name": name,
+		"size": size * mib,
+	}
+
+	policy := d.createOpt(opts.Opts, OptPerformancePolicy)
+	if opts.Type != nil && *opts.Type != "" {
+		policy = *opts.Type
+	}
+	if policy != "" {
+		id, err := d.api.id("/performance_policies", policy)
+		if err != nil {
+			return nil, goof.WithFieldE("performancePolicy", policy,
+				"invalid performance policy", err)
+		}
+		body["perfpolicy_id"] = id
+	}
+	if pool := d.createOpt(opts.Opts, OptPool); pool != "" {
+		id, err := d.api.id("/pools", pool)
+		if err != nil {
+			return nil, goof.WithFieldE("pool", pool, "invalid pool", err)
+		}
+		body["pool_id"] = id
+	}
+
+	return d.create(ctx, body)
+}
+
+func (d *driver) create(
+	ctx apitypes.Context,
+	body map[string]interface{}) (*apitypes.Volume, error) {
+
+	v := &volume{}
+	if err := d.api.do("POST", "/volumes", body, v); err != nil {
+		return nil, goof.WithFieldE("name", body["name"],
+			"error creating volume", err)
+	}
+	return d.toVolume(ctx, v, nil), nil
+}
+
+// VolumeCreateFromSnapshot creates a clone of a snapshot, which has the
+// snapshot's size unless a larger size is requested.
+func (d *driver) VolumeCreateFromSnapshot(
+	ctx apitypes.Context,
+	snapshotID, volumeName string,
+	opts *apitypes.VolumeCreateOpts) (*apitypes.Volume, error) {
+
+	body := map[string]interface{}{
+		"name":         volumeName,
+		"clone":        true,
+		"base_snap_id": snapshotID,
+	}
+	if opts.Size != nil && *opts.Size > 0 {
+		body["size"] = *opts.Size * mib
+	}
+	return d.create(ctx, body)
+}
+
+// VolumeCopy creates a clone of a new snapshot of a volume. The snapshot is
+// named after the copy and remains the copy's base snapshot.
+func (d *driver) VolumeCopy(
+	ctx apitypes.Context,
+	volumeID, volumeName string,
+	opts apitypes.Store) (*apitypes.Volume, error) {
+
+	snap, err := d.VolumeSnapshot(ctx, volumeID, volumeName, opts)
+	if err != nil {
+		return nil, err
+	}
+	return d.VolumeCreateFromSnapshot(ctx, snap.ID, volumeName,
+		&apitypes.VolumeCreateOpts{Opts: opts})
+}
+
+func (d *driver) VolumeSnapshot(
+	ctx apitypes.Context,
+	volumeID, snapshotName string,
+	opts apitypes.Store) (*apitypes.Snapshot, error) {
+
+	s := &snapshot{}
+	if err := d.api.do("POST", "/snapshots", map[string]interface{}{
+		"vol_id": volumeID,
+		"name":   snapshotName,
+	}, s); err != nil {
+		return nil, goof.WithFieldsE(goof.Fields{
+			"volumeID": volumeID,
+			"name":     snapshotName,
+		}, "error creating snapshot", err)
+	}
+	return toSnapshot(s), nil
+}
+
+// VolumeRemove takes a volume offline and removes it. The array refuses to
+// remove a volume that has snapshots or clones.
+func (d *driver) VolumeRemove(
+	ctx apitypes.Context,
+	volumeID string,
+	opts apitypes.Store) error {
+
+	if err := d.api.do("PUT", "/volumes/"+volumeID,
+		map[string]interface{}{"online": false}, nil); err != nil {
+		if isNotFound(err) {
+			return goof.WithField("volumeID", volumeID, "volume not found")
+		}
+		return goof.WithFieldE("volumeID", volumeID,
+			"error taking volume offline", err)
+	}
+	if err := d.api.do("DELETE", "/volumes/"+volumeID, nil, nil); err != nil {
+		return goof.WithFieldE("volumeID", volumeID,
+			"error removing volume", err)
+	}
+	return nil
+}
+
+// initiatorGroup returns the ID of the initiator group of an initiator,
+// which is created if there is none.
+func (d *driver) initiatorGroup(
+	ctx apitypes.Context, initiator string) (string, error) {
+
+	groups, err := d.api.initiatorGroups()
+	if err != nil {
+		return "", err
+	}
+	for _, g := range groups {
+		for _, i := range g.ISCSIInitiators {
+			if i.IQN == initiator {
+				return g.ID, nil
+			}
+		}
+	}
+
+	name := initiatorGroupName(d.prefix, initiator)
+	g := &initiatorGroup{}
+	if err := d.api.do("POST", "/initiator_groups", map[string]interface{}{
+		"name":            name,
+		"access_protocol": "iscsi",
+		"iscsi_initiators": []map[string]string{{
+			"label":      name,
+			"iqn":        initiator,
+			"ip_address": "*",
+		}},
+	}, g); err != nil {
+		return "", goof.WithFieldsE(goof.Fields{
+			"initiatorGroup": name,
+			"initiator":      initiator,
+		}, "error creating initiator group", err)
+	}
+	ctx.WithFields(map[string]interface{}{
+		"initiatorGroup": name,
+		"initiator":      initiator,
+	}).Info("created initiator group")
+	return g.ID, nil
+}
+
+// VolumeAttach adds an access control record for the initiator group of the
+// instance's initiator to a volume, creating the group if there is none,
+// and returns the key of the volume's target and LUN in the node's local
+// devices as the token. A forced attach first removes the volume's other
+// access control records.
+func (d *driver) VolumeAttach(
+	ctx apitypes.Context,
+	volumeID string,
+	opts *apitypes.VolumeAttachOpts) (*apitypes.Volume, string, error) {
+
+	iid := instanceID(ctx)
+	if iid == "" {
+		return nil, "", goof.New("missing instance ID")
+	}
+	v, err := d.volume(volumeID)
+	if err != nil {
+		return nil, "", err
+	}
+	groupID, err := d.initiatorGroup(ctx, iid)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var rec *acr
+	for _, r := range v.AccessControlRecords {
+		switch {
+		case r.InitiatorGroupID == groupID:
+			rec = r
+		case !opts.Force:
+			return nil, "", goof.WithFields(goof.Fields{
+				"volumeID":       volumeID,
+				"initiatorGroup": r.InitiatorGroupName,
+			}, "volume is attached to another initiator group")
+		default:
+			if err := d.removeACR(volumeID, r); err != nil {
+				return nil, "", err
+			}
+		}
+	}
+	if rec == nil {
+		rec = &acr{}
+		if err := d.api.do("POST", "/access_control_records",
+			map[string]interface{}{
+				"vol_id":             volumeID,
+				"initiator_group_id": groupID,
+			}, rec); err != nil {
+			return nil, "", goof.WithFieldsE(goof.Fields{
+				"volumeID":         volumeID,
+				"initiatorGroupID": groupID,
+			}, "error adding access control record", err)
+		}
+	}
+
+	vol, err := d.inspect(ctx, volumeID, true)
+	if err != nil {
+		return nil, "", err
+	}
+	return vol, deviceKey(v.TargetName, rec.LUN), nil
+}
+
+func (d *driver) removeACR(volumeID string, r *acr) error {
+	if err := d.api.do("DELETE", "/access_control_records/"+r.ID,
+		nil, nil); err != nil && !isNotFound(err) {
+		return goof.WithFieldsE(goof.Fields{
+			"volumeID":       volumeID,
+			"initiatorGroup": r.InitiatorGroupName,
+		}, "error removing access control record", err)
+	}
+	return nil
+}
+
+// VolumeDetach removes the access control record of the initiator group of
+// the instance's initiator from a volume, or all of its records if the
+// detach is forced.
+func (d *driver) VolumeDetach(
+	ctx apitypes.Context,
+	volumeID string,
+	opts *apitypes.VolumeDetachOpts) (*apitypes.Volume, error) {
+
+	v, err := d.volume(volumeID)
+	if err != nil {
+		return nil, err
+	}
+	groups, err := d.groups(true)
+	if err != nil {
+		return nil, err
+	}
+	iid := instanceID(ctx)
+	for _, r := range v.AccessControlRecords {
+		if !opts.Force && !hasInitiator(groups[r.InitiatorGroupID], iid) {
+			continue
+		}
+		if err := d.removeACR(volumeID, r); err != nil {
+			return nil, err
+		}
+	}
+	return d.inspect(ctx, volumeID, true)
+}
+
+func hasInitiator(g *initiatorGroup, initiator string) bool {
+	if g == nil {
+		return false
+	}
+	for _, i := range g.ISCSIInitiators {
+		if i.IQN == initiator {
+			return true
+		}
+	}
+	return false
+}
+
+func toSnapshot(s *snapshot) *apitypes.Snapshot {
+	status := "online"
+	if !s.Online {
+		status = "offline"
+	}
+	return &apitypes.Snapshot{
+		ID:          s.ID,
+		Name:        s.Name,
+		VolumeID:    s.VolID,
+		VolumeSize:  s.Size / mib,
+		StartTime:   s.CreationTime,
+		Description: s.Description,
+		Status:      status,
+	}
+}
+
+// Snapshots returns the snapshots of all volumes, which the API lists one
+// volume at a time.
+func (d *driver) Snapshots(
+	ctx apitypes.Context,
+	opts apitypes.Store) ([]*apitypes.Snapshot, error) {
+
+	vols, err := d.api.volumes()
+	if err != nil {
+		return nil, err
+	}
+	snaps := []*apitypes.Snapshot{}
+	for _, v := range vols {
+		all, err := d.api.snapshots(v.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range all {
+			snaps = append(snaps, toSnapshot(s))
+		}
+	}
+	return snaps, nil
+}
+
+func (d *driver) SnapshotInspect(
+	ctx apitypes.Context,
+	snapshotID string,
+	opts apitypes.Store) (*apitypes.Snapshot, error) {
+
+	s, err := d.api.snapshot(snapshotID)
+	if isNotFound(err) {
+		return nil, goof.WithField("snapshotID", snapshotID,
+			"snapshot not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return toSnapshot(s), nil
+}
+
+func (d *driver) SnapshotCopy(
+	ctx apitypes.Context,
+	snapshotID, snapshotName, destinationID string,
+	opts apitypes.Store) (*apitypes.Snapshot, error) {
+
+	return nil, apitypes.ErrNotImplemented
+}
+
+// SnapshotRemove removes a snapshot. The array refuses to remove the base
+// snapshot of a clone.
+func (d *driver) SnapshotRemove(
+	ctx apitypes.Context,
+	snapshotID string,
+	opts apitypes.Store) error {
+
+	if err := d.api.do(
+		"DELETE", "/snapshots/"+snapshotID, nil, nil); err != nil {
+		if isNotFound(err) {
+			return goof.WithField("snapshotID", snapshotID,
+				"snapshot not found")
+		}
+		return goof.WithFieldE("snapshotID", snapshotID,
+			"error removing snapshot", err)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package nimble
+
+import (
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/core/openiscsi"
+	"github.com/emccode/rexray/core/scsi"
+)
+
+// executor runs on each node. A node's instance ID is its iSCSI initiator
+// name.
+type executor struct {
+	config gofig.Config
+}
+
+func (e *executor) Name() string {
+	return Name
+}
+
+func (e *executor) Init(ctx apitypes.Context, config gofig.Config) error {
+	e.config = config
+	return nil
+}
+
+func (e *executor) InstanceID(
+	ctx apitypes.Context,
+	opts apitypes.Store) (*apitypes.InstanceID, error) {
+
+	iqn, err := openiscsi.InitiatorName()
+	if err != nil {
+		return nil, err
+	}
+	return &apitypes.InstanceID{ID: iqn, Driver: Name}, nil
+}
+
+func (e *executor) NextDevice(
+	ctx apitypes.Context,
+	opts apitypes.Store) (string, error) {
+
+	return "", apitypes.ErrNotImplemented
+}
+
+// LocalDevices returns the devices of the volumes attached to the node keyed
+// by their targets and LUNs. A deep scan first discovers the targets the
+// array allows the node to access at nimble.discoveryIP, logs in to those
+// the node has no session with, logs out of those of volumes that were
+// detached, and waits for multipathd to coalesce the paths of new volumes.
+func (e *executor) LocalDevices(
+	ctx apitypes.Context,
+	opts *apitypes.LocalDevicesOpts) (*apitypes.LocalDevices, error) {
+
+	multipath := e.config.GetBool("nimble.multipath") &&
+		scsi.Multipath(e.config)
+
+	if opts.ScanType == apitypes.DeviceScanDeep {
+		ip := e.config.GetString("nimble.discoveryIP")
+		if ip == "" {
+			return nil, goof.New("nimble nodes require nimble.discoveryIP")
+		}
+		if err := syncTargets(ctx, openiscsi.PortalAddr(ip)); err != nil {
+			return nil, err
+		}
+		if multipath {
+			scsi.SettleMultipath(ctx, scsi.MultipathTimeout(e.config))
+		}
+	}
+
+	devs, err := devices(multipath)
+	if err != nil {
+		return nil, err
+	}
+	return &apitypes.LocalDevices{Driver: Name, DeviceMap: devs}, nil
+}
@@ -0,0 +1,178 @@
+// Package nimble is a storage driver for HPE Nimble Storage and Alletra 6000
+// arrays that manages volumes, their performance policies, snapshots, and
+// clones, and the initiator groups and access control records that grant
+// nodes access to them with the arrays' REST API. Each volume is its own
+// iSCSI target, so nodes discover the targets the array allows them to
+// access at its discovery IP, log in to those they have no session with,
+// and log out of those they may no longer access.
+package nimble
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/akutz/gofig"
+	"github.com/emccode/libstorage/api/registry"
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/core/openiscsi"
+)
+
+const (
+	// Name is the name with which the driver is registered.
+	Name = "nimble"
+
+	// OptPerformancePolicy is the create option with which the performance
+	// policy of a volume is requested.
+	OptPerformancePolicy = "performancePolicy"
+
+	// OptPool is the create option with which the pool of a volume is
+	// requested.
+	OptPool = "pool"
+
+	defaultPrefix = "rexray-"
+	defaultSize   = 8
+
+	// mib is the unit of the sizes of the array's volumes and snapshots.
+	mib = 1024
+
+	// maxNameLen is the length of the longest name of an initiator group.
+	maxNameLen = 64
+
+	// iqnPrefix is the prefix of the IQNs of the volumes' targets.
+	iqnPrefix = "iqn.2007-11.com.nimblestorage:"
+)
+
+func init() {
+	registry.RegisterStorageDriver(Name, newDriver)
+	registry.RegisterStorageExecutor(Name, newExecutor)
+
+	r := gofig.NewRegistration("HPE Nimble Driver")
+	r.Key(gofig.String, "", "",
+		"The URL of the array's REST API, ex. https://array:5392",
+		"nimble.endpoint")
+	r.Key(gofig.String, "", "",
+		"The user with which the REST API is called",
+		"nimble.username")
+	r.Key(gofig.String, "", "",
+		"The password of the user",
+		"nimble.password")
+	r.Key(gofig.Bool, "", false,
+		"Skip the verification of the array's TLS certificate",
+		"nimble.insecure")
+	r.Key(gofig.String, "", "",
+		"The pool in which volumes are created",
+		"nimble.pool")
+	r.Key(gofig.String, "", "",
+		"The performance policy of new volumes",
+		"nimble.performancePolicy")
+	r.Key(gofig.String, "", defaultPrefix,
+		"The prefix of the names of the initiator groups the driver creates",
+		"nimble.prefix")
+	r.Key(gofig.String, "", "",
+		"The array's iSCSI discovery IP, host[:port], at which nodes "+
+			"discover their volumes' targets",
+		"nimble.discoveryIP")
+	r.Key(gofig.Bool, "", true,
+		"Use the multipath device of a volume when there is one",
+		"nimble.multipath")
+	gofig.Register(r)
+}
+
+func newDriver() apitypes.StorageDriver {
+	return &driver{}
+}
+
+func newExecutor() apitypes.StorageExecutor {
+	return &executor{}
+}
+
+// initiatorGroupName returns the name of the initiator group the driver
+// creates for an initiator: the initiator's name with the prefix and with
+// the characters other than letters, digits, and hyphens replaced with
+// hyphens.
+func initiatorGroupName(prefix, initiator string) string {
+	n := []rune(prefix + initiator)
+	for i, r := range n {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' ||
+			r >= '0' && r <= '9' || r == '-') {
+			n[i] = '-'
+		}
+	}
+	if len(n) > maxNameLen {
+		n = n[:maxNameLen]
+	}
+	return string(n)
+}
+
+// deviceKey returns the key of a volume in a node's local devices, which is
+// also the token with which the node waits for an attached volume to appear.
+func deviceKey(target string, lun int) string {
+	return fmt.Sprintf("%s-lun-%d", target, lun)
+}
+
+// target is a volume's iSCSI target at one of the array's portals.
+type target struct {
+	IQN    string
+	Portal string
+}
+
+// parseTargets parses the output of iscsiadm -m discovery, ex.
+// 10.0.0.1:3260,2460 iqn.2007-11.com.nimblestorage:data-v1a2b,
+// and returns the targets of volumes.
+func parseTargets(r io.Reader) []*target {
+	targets := []*target{}
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) < 2 || !strings.HasPrefix(fields[1], iqnPrefix) {
+			continue
+		}
+		p := fields[0]
+		if i := strings.LastIndex(p, ","); i >= 0 {
+			p = p[:i]
+		}
+		targets = append(targets, &target{IQN: fields[1], Portal: p})
+	}
+	return targets
+}
+
+// volumeSessions returns the node's sessions with the targets of volumes,
+// whose IQNs begin with iqnPrefix.
+func volumeSessions(all []*openiscsi.Session) []*target {
+	sessions := []*target{}
+	for _, s := range all {
+		if strings.HasPrefix(s.Target, iqnPrefix) {
+			sessions = append(sessions,
+				&target{IQN: s.Target, Portal: s.Portal})
+		}
+	}
+	return sessions
+}
+
+// diffTargets returns the discovered targets the node has no session with
+// and the sessions with targets that are no longer discovered, which are
+// those of volumes the node may no longer access.
+func diffTargets(discovered, sessions []*target) ([]*target, []*target) {
+	active := map[target]bool{}
+	for _, s := range sessions {
+		active[*s] = true
+	}
+	allowed := map[target]bool{}
+	login := []*target{}
+	for _, t := range discovered {
+		allowed[*t] = true
+		if !active[*t] {
+			login = append(login, t)
+		}
+	}
+	logout := []*target{}
+	for _, s := range sessions {
+		if !allowed[*s] {
+			logout = append(logout, s)
+		}
+	}
+	return login, logout
+}
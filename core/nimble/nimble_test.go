@@ -0,0 +1,80 @@
+package nimble
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/emccode/rexray/core/openiscsi"
+)
+
+func TestInitiatorGroupName(t *testing.T) {
+	if n := initiatorGroupName("rexray-", "iqn.1994-05.com.redhat:a1b2"); n !=
+		"rexray-iqn-1994-05-com-redhat-a1b2" {
+		t.Fatalf("name=%s", n)
+	}
+	n := initiatorGroupName("rexray-", strings.Repeat("a", 100))
+	if len(n) != maxNameLen {
+		t.Fatalf("len=%d", len(n))
+	}
+}
+
+func TestParseByPath(t *testing.T) {
+	target, lun, ok := openiscsi.ParseByPath("ip-10.0.0.1:3260-iscsi-" +
+		"iqn.2007-11.com.nimblestorage:data-v1a2b.0000001f.3c5e-lun-0")
+	if !ok || target !=
+		"iqn.2007-11.com.nimblestorage:data-v1a2b.0000001f.3c5e" || lun != 0 {
+		t.Fatalf("target=%s lun=%d ok=%v", target, lun, ok)
+	}
+	if _, _, ok := openiscsi.ParseByPath("ip-10.0.0.1:3260-iscsi-" +
+		"iqn.2007-11.com.nimblestorage:data-v1a2b-lun-0-part1"); ok {
+		t.Fatal("parsed partition")
+	}
+}
+
+func TestDiffTargets(t *testing.T) {
+	discovered := parseTargets(strings.NewReader(
+		"10.0.0.5:3260,2460 iqn.2007-11.com.nimblestorage:a-v1\n" +
+			"10.0.0.6:3260,2460 iqn.2007-11.com.nimblestorage:a-v1\n"))
+	sessions := volumeSessions(openiscsi.ParseSessions(strings.NewReader(
+		"tcp: [1] 10.0.0.5:3260,2460 iqn.2007-11.com.nimblestorage:a-v1 " +
+			"(non-flash)\n" +
+			"tcp: [2] 10.0.0.5:3260,2460 iqn.2007-11.com.nimblestorage:b-v2\n" +
+			"tcp: [3] 10.1.0.1:3260,1 iqn.2003-01.org.linux-iscsi.t:sn\n")))
+	if len(discovered) != 2 || len(sessions) != 2 {
+		t.Fatalf("discovered=%v sessions=%v", discovered, sessions)
+	}
+	login, logout := diffTargets(discovered, sessions)
+	if len(login) != 1 || login[0].Portal != "10.0.0.6:3260" {
+		t.Fatalf("login=%v", login)
+	}
+	if len(logout) != 1 ||
+		logout[0].IQN != "iqn.2007-11.com.nimblestorage:b-v2" {
+		t.Fatalf("logout=%v", logout)
+	}
+}
+
+func TestVolume(t *testing.T) {
+	v := &volume{}
+	if err := json.Unmarshal([]byte(`{
+		"id": "0629c8d8a1b2c3d40000000000000000000000001f",
+		"name": "data",
+		"size": 10240,
+		"vol_state": "online",
+		"target_name": "iqn.2007-11.com.nimblestorage:data-v1a2b",
+		"perfpolicy_name": "default",
+		"access_control_records": [{
+			"id": "0d29c8d8a1b2c3d40000000000000000000000000a",
+			"initiator_group_id": "0229c8d8a1b2c3d400000000000000000000000003",
+			"initiator_group_name": "rexray-iqn-1994-05-com-redhat-a1b2",
+			"lun": 0
+		}]
+	}`), v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Size/mib != 10 || len(v.AccessControlRecords) != 1 ||
+		deviceKey(v.TargetName, v.AccessControlRecords[0].LUN) !=
+			"iqn.2007-11.com.nimblestorage:data-v1a2b-lun-0" {
+		t.Fatalf("volume=%+v", v)
+	}
+}
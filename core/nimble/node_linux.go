@@ -0,0 +1,80 @@
+package nimble
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/core/openiscsi"
+	"github.com/emccode/rexray/core/scsi"
+)
+
+// sessions returns the node's sessions with the targets of volumes.
+func sessions() ([]*target, error) {
+	all, err := openiscsi.Sessions()
+	if err != nil {
+		return nil, err
+	}
+	return volumeSessions(all), nil
+}
+
+// syncTargets discovers the targets of the volumes the node may access at
+// the discovery IP, logs in to those it has no session with, and logs out
+// of and forgets those of volumes that were detached.
+func syncTargets(ctx apitypes.Context, discoveryIP string) error {
+	out, err := openiscsi.Run(
+		"-m", "discovery", "-t", "sendtargets", "-p", discoveryIP)
+	if err != nil {
+		return err
+	}
+	active, err := sessions()
+	if err != nil {
+		return err
+	}
+	login, logout := diffTargets(parseTargets(bytes.NewReader(out)), active)
+
+	for _, t := range login {
+		if err := openiscsi.LoginTarget(ctx, t.IQN, t.Portal); err != nil {
+			return err
+		}
+	}
+
+	for _, t := range logout {
+		if err := openiscsi.LogoutTarget(
+			ctx, t.IQN, t.Portal, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// devices returns the devices of the volumes attached to the node keyed by
+// their targets and LUNs. If multipath is enabled a volume's device is the
+// multipath device that holds its paths, if there is one.
+func devices(multipath bool) (map[string]string, error) {
+	devs := map[string]string{}
+	links, err := ioutil.ReadDir(openiscsi.ByPathDir)
+	if err != nil {
+		return devs, nil
+	}
+	for _, l := range links {
+		target, lun, ok := openiscsi.ParseByPath(l.Name())
+		if !ok || !strings.HasPrefix(target, iqnPrefix) {
+			continue
+		}
+		path, err := filepath.EvalSymlinks(
+			filepath.Join(openiscsi.ByPathDir, l.Name()))
+		if err != nil {
+			continue
+		}
+		key := deviceKey(target, lun)
+		dev := scsi.DevicePath(path, multipath)
+		if _, ok := devs[key]; !ok || dev != path {
+			devs[key] = dev
+		}
+	}
+	return devs, nil
+}
@@ -0,0 +1,16 @@
+// +build !linux
+
+package nimble
+
+import (
+	"github.com/akutz/goof"
+	apitypes "github.com/emccode/libstorage/api/types"
+)
+
+func syncTargets(ctx apitypes.Context, discoveryIP string) error {
+	return goof.New("nimble volumes are only supported on Linux")
+}
+
+func devices(multipath bool) (map[string]string, error) {
+	return map[string]string{}, nil
+}
@@ -0,0 +1,138 @@
+package nodes
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	apitypes "github.com/emccode/libstorage/api/types"
+)
+
+// Attachment is a volume that is attached to, or mounted on, a node.
+type Attachment struct {
+
+	// VolumeID is the ID of the volume.
+	VolumeID string `json:"volumeID" yaml:"volumeID"`
+
+	// VolumeName is the name of the volume.
+	VolumeName string `json:"volumeName,omitempty" yaml:"volumeName,omitempty"`
+
+	// DeviceName is the device of the volume on the node.
+	DeviceName string `json:"deviceName,omitempty" yaml:"deviceName,omitempty"`
+
+	// MountPoint is the path at which the volume is mounted.
+	MountPoint string `json:"mountPoint,omitempty" yaml:"mountPoint,omitempty"`
+
+	// Attached is true if the storage platform reports the volume as
+	// attached to the node.
+	Attached bool `json:"attached" yaml:"attached"`
+
+	// Mounted is true if the node's agent reported the volume as mounted.
+	Mounted bool `json:"mounted" yaml:"mounted"`
+}
+
+// NodeAttachments are the volumes attached to, or mounted on, a node.
+type NodeAttachments struct {
+
+	// InstanceID is the ID of the node's instance.
+	InstanceID string `json:"instanceID" yaml:"instanceID"`
+
+	// Hostname is the name of the host, which is empty if the node's agent
+	// has never reported.
+	Hostname string `json:"hostname,omitempty" yaml:"hostname,omitempty"`
+
+	// Heartbeat is the time at which the node's agent last reported.
+	Heartbeat time.Time `json:"heartbeat,omitempty" yaml:"heartbeat,omitempty"`
+
+	// Attachments are the node's volumes, ordered by their IDs.
+	Attachments []*Attachment `json:"attachments" yaml:"attachments"`
+}
+
+// Matches returns a flag indicating whether the node is the one with the
+// provided instance ID or hostname.
+func (n *NodeAttachments) Matches(node string) bool {
+	return n.InstanceID == node ||
+		n.Hostname != "" && strings.EqualFold(n.Hostname, node)
+}
+
+// Attachments joins the attachments of the provided volumes with the
+// volumes the nodes' agents reported as mounted. Nodes that have reported but
+// have no volumes are included, as are the instances the volumes are
+// attached to whose agents have never reported. The nodes are ordered by
+// their instance IDs.
+func Attachments(
+	vols []*apitypes.Volume, all []*Node) []*NodeAttachments {
+
+	byID := map[string]*NodeAttachments{}
+	node := func(id string) *NodeAttachments {
+		na, ok := byID[id]
+		if !ok {
+			na = &NodeAttachments{
+				InstanceID:  id,
+				Attachments: []*Attachment{},
+			}
+			byID[id] = na
+		}
+		return na
+	}
+
+	names := map[string]string{}
+	attachments := map[[2]string]*Attachment{}
+	for _, v := range vols {
+		names[v.ID] = v.Name
+		for _, va := range v.Attachments {
+			if va.InstanceID == nil || va.InstanceID.ID == "" {
+				continue
+			}
+			na := node(va.InstanceID.ID)
+			a := &Attachment{
+				VolumeID:   v.ID,
+				VolumeName: v.Name,
+				DeviceName: va.DeviceName,
+				MountPoint: va.MountPoint,
+				Attached:   true,
+			}
+			na.Attachments = append(na.Attachments, a)
+			attachments[[2]string{na.InstanceID, v.ID}] = a
+		}
+	}
+
+	for _, n := range all {
+		na := node(n.InstanceID)
+		na.Hostname = n.Hostname
+		na.Heartbeat = n.Heartbeat
+		for _, id := range n.Mounts {
+			if a, ok := attachments[[2]string{n.InstanceID, id}]; ok {
+				a.Mounted = true
+				continue
+			}
+			na.Attachments = append(na.Attachments, &Attachment{
+				VolumeID:   id,
+				VolumeName: names[id],
+				Mounted:    true,
+			})
+		}
+	}
+
+	nas := make([]*NodeAttachments, 0, len(byID))
+	for _, na := range byID {
+		sort.Sort(byVolumeID(na.Attachments))
+		nas = append(nas, na)
+	}
+	sort.Sort(byInstanceID(nas))
+	return nas
+}
+
+type byVolumeID []*Attachment
+
+func (a byVolumeID) Len() int           { return len(a) }
+func (a byVolumeID) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byVolumeID) Less(i, j int) bool { return a[i].VolumeID < a[j].VolumeID }
+
+type byInstanceID []*NodeAttachments
+
+func (n byInstanceID) Len() int      { return len(n) }
+func (n byInstanceID) Swap(i, j int) { n[i], n[j] = n[j], n[i] }
+func (n byInstanceID) Less(i, j int) bool {
+	return n[i].InstanceID < n[j].InstanceID
+}
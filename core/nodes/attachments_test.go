@@ -0,0 +1,65 @@
+package nodes
+
+import (
+	"testing"
+
+	apitypes "github.com/emccode/libstorage/api/types"
+)
+
+func TestAttachments(t *testing.T) {
+	vols := []*apitypes.Volume{
+		{
+			ID:   "vol-2",
+			Name: "db",
+			Attachments: []*apitypes.VolumeAttachment{{
+				InstanceID: &apitypes.InstanceID{ID: "i-1"},
+				DeviceName: "/dev/xvdb",
+			}},
+		},
+		{
+			ID:   "vol-3",
+			Name: "logs",
+			Attachments: []*apitypes.VolumeAttachment{{
+				InstanceID: &apitypes.InstanceID{ID: "i-3"},
+			}},
+		},
+		{ID: "vol-1", Name: "nfs"},
+	}
+	all := []*Node{
+		{InstanceID: "i-1", Hostname: "web1", Mounts: []string{"vol-2", "vol-1"}},
+		{InstanceID: "i-2", Hostname: "web2", Mounts: []string{}},
+	}
+
+	nas := Attachments(vols, all)
+	if len(nas) != 3 {
+		t.Fatalf("nodes=%d", len(nas))
+	}
+
+	n1 := nas[0]
+	if n1.InstanceID != "i-1" || n1.Hostname != "web1" ||
+		len(n1.Attachments) != 2 {
+		t.Fatalf("unexpected node %+v", n1)
+	}
+	if a := n1.Attachments[0]; a.VolumeID != "vol-1" || a.VolumeName != "nfs" ||
+		a.Attached || !a.Mounted {
+		t.Fatalf("unexpected mount %+v", a)
+	}
+	if a := n1.Attachments[1]; a.VolumeID != "vol-2" ||
+		a.DeviceName != "/dev/xvdb" || !a.Attached || !a.Mounted {
+		t.Fatalf("unexpected attachment %+v", a)
+	}
+
+	if n2 := nas[1]; n2.InstanceID != "i-2" || len(n2.Attachments) != 0 {
+		t.Fatalf("unexpected node %+v", n2)
+	}
+
+	n3 := nas[2]
+	if n3.InstanceID != "i-3" || n3.Hostname != "" ||
+		len(n3.Attachments) != 1 || n3.Attachments[0].Mounted {
+		t.Fatalf("unexpected unreported node %+v", n3)
+	}
+
+	if !n1.Matches("WEB1") || !n1.Matches("i-1") || n3.Matches("") {
+		t.Fatal("unexpected match")
+	}
+}
@@ -0,0 +1,149 @@
+// Package nodes records the state reported by the REX-Ray agents running on
+// each instance, such as their heartbeats and mounted volumes. The nodes are
+// recorded in the controller's store, to which the agents report.
+package nodes
+
+import (
+	"time"
+
+	"github.com/emccode/rexray/core/state"
+)
+
+const (
+	// NodesBucket is the bucket of the nodes' reports.
+	NodesBucket = "nodes"
+
+	// FencesBucket is the bucket of the volumes fenced from each node.
+	FencesBucket = "fences"
+)
+
+// Node is the information an agent reports about the instance on which it is
+// running.
+type Node struct {
+
+	// InstanceID is the ID of the instance.
+	InstanceID string `json:"instanceID"`
+
+	// Hostname is the name of the host.
+	Hostname string `json:"hostname"`
+
+	// Heartbeat is the time at which the agent last reported.
+	Heartbeat time.Time `json:"heartbeat"`
+
+	// Mounts are the IDs of the volumes mounted on the instance.
+	Mounts []string `json:"mounts"`
+
+	// ConfigDigest is the digest of the agent's effective configuration.
+	ConfigDigest string `json:"configDigest,omitempty"`
+}
+
+// IsHealthy returns a flag indicating whether or not the node has reported
+// within the provided timeout.
+func (n *Node) IsHealthy(timeout time.Duration) bool {
+	return time.Since(n.Heartbeat) <= timeout
+}
+
+// IsMounted returns a flag indicating whether or not the node reported the
+// volume with the provided ID as mounted.
+func (n *Node) IsMounted(volumeID string) bool {
+	for _, id := range n.Mounts {
+		if id == volumeID {
+			return true
+		}
+	}
+	return false
+}
+
+// Get returns the node with the provided instance ID. The returned flag is
+// false if the node has never reported.
+func Get(s *state.Store, instanceID string) (*Node, bool, error) {
+	n := &Node{}
+	ok, err := s.Get(NodesBucket, instanceID, n)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	return n, true, nil
+}
+
+// Put records the provided node.
+func Put(s *state.Store, n *Node) error {
+	return s.Set(NodesBucket, n.InstanceID, n)
+}
+
+// List returns all of the nodes that have reported.
+func List(s *state.Store) ([]*Node, error) {
+	ids, err := s.Keys(NodesBucket)
+	if err != nil {
+		return nil, err
+	}
+	nodes := []*Node{}
+	for _, id := range ids {
+		n, ok, err := Get(s, id)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			nodes = append(nodes, n)
+		}
+	}
+	return nodes, nil
+}
+
+// Fence records that the volume with the provided ID was taken from the
+// instance with the provided ID. The instance's agent unmounts fenced volumes
+// the next time it reports, and acknowledges the fence by removing it.
+func Fence(s *state.Store, instanceID, volumeID string) error {
+	fenced := []string{}
+	return s.Update(FencesBucket, instanceID, &fenced,
+		func(ok bool) (bool, error) {
+			for _, id := range fenced {
+				if id == volumeID {
+					return true, nil
+				}
+			}
+			fenced = append(fenced, volumeID)
+			return true, nil
+		})
+}
+
+// Fenced returns the IDs of the volumes fenced from the instance with the
+// provided ID.
+func Fenced(s *state.Store, instanceID string) ([]string, error) {
+	fenced := []string{}
+	if _, err := s.Get(FencesBucket, instanceID, &fenced); err != nil {
+		return nil, err
+	}
+	return fenced, nil
+}
+
+// IsFenced returns a flag indicating whether the volume with the provided ID
+// is fenced from the instance with the provided ID. The flag is false once
+// the instance's agent has acknowledged the fence.
+func IsFenced(s *state.Store, instanceID, volumeID string) (bool, error) {
+	fenced, err := Fenced(s, instanceID)
+	if err != nil {
+		return false, err
+	}
+	for _, id := range fenced {
+		if id == volumeID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Unfence removes the fence for the volume from the instance.
+func Unfence(s *state.Store, instanceID, volumeID string) error {
+	fenced := []string{}
+	return s.Update(FencesBucket, instanceID, &fenced,
+		func(ok bool) (bool, error) {
+			remaining := []string{}
+			for _, id := range fenced {
+				if id != volumeID {
+					remaining = append(remaining, id)
+				}
+			}
+			fenced = remaining
+			return len(remaining) > 0, nil
+		})
+}
@@ -0,0 +1,90 @@
+package nodes
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+
+	"github.com/akutz/gofig"
+)
+
+func init() {
+	r := gofig.NewRegistration("Config Drift")
+	r.Key(gofig.String, "", "libstorage.host,rexray.logLevel",
+		"A comma-separated list of the configuration keys, or their "+
+			"prefixes, that are excluded from a node's config digest",
+		"rexray.nodes.configDigest.exclude")
+	r.Key(gofig.String, "", "",
+		"The config digest every node is expected to report; defaults to "+
+			"the digest of the local configuration",
+		"rexray.nodes.configDigest.expected")
+	gofig.Register(r)
+}
+
+// ConfigDigest returns a SHA-256 digest of the provided configuration. Keys
+// that differ between otherwise identically configured nodes, such as the
+// address of the libStorage server, are excluded as configured by the
+// rexray.nodes.configDigest.exclude property.
+func ConfigDigest(config gofig.Config) (string, error) {
+	s, err := config.ToJSON()
+	if err != nil {
+		return "", err
+	}
+	settings := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(s), &settings); err != nil {
+		return "", err
+	}
+
+	flat := map[string]interface{}{}
+	flatten("", settings, flat)
+
+	exclude := []string{}
+	for _, k := range strings.Split(
+		config.GetString("rexray.nodes.configDigest.exclude"), ",") {
+		if k = strings.ToLower(strings.TrimSpace(k)); k != "" {
+			exclude = append(exclude, k)
+		}
+	}
+	for k := range flat {
+		for _, e := range exclude {
+			if k == e || strings.HasPrefix(k, e+".") {
+				delete(flat, k)
+				break
+			}
+		}
+	}
+
+	// the keys of a marshalled map are sorted, so equal configurations
+	// always produce the same digest
+	buf, err := json.Marshal(flat)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(buf)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// ExpectedConfigDigest returns the config digest every node is expected to
+// report.
+func ExpectedConfigDigest(config gofig.Config) (string, error) {
+	if d := config.GetString("rexray.nodes.configDigest.expected"); d != "" {
+		return d, nil
+	}
+	return ConfigDigest(config)
+}
+
+func flatten(prefix string, v interface{}, flat map[string]interface{}) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		flat[prefix] = v
+		return
+	}
+	for k, child := range m {
+		key := strings.ToLower(k)
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		flatten(key, child, flat)
+	}
+}
@@ -0,0 +1,45 @@
+package nodes
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/emccode/rexray/core/state"
+)
+
+func TestFence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nodes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	s := state.Open(filepath.Join(dir, "state.json"))
+
+	for _, id := range []string{"vol-1", "vol-2", "vol-1"} {
+		if err := Fence(s, "i-1", id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if fenced, err := Fenced(s, "i-1"); err != nil || len(fenced) != 2 {
+		t.Fatalf("%v %v", fenced, err)
+	}
+
+	if err := Unfence(s, "i-1", "vol-1"); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := IsFenced(s, "i-1", "vol-1"); err != nil || ok {
+		t.Fatalf("%v %v", ok, err)
+	}
+	if ok, err := IsFenced(s, "i-1", "vol-2"); err != nil || !ok {
+		t.Fatalf("%v %v", ok, err)
+	}
+
+	if err := Unfence(s, "i-1", "vol-2"); err != nil {
+		t.Fatal(err)
+	}
+	if keys, err := s.Keys(FencesBucket); err != nil || len(keys) != 0 {
+		t.Fatalf("%v %v", keys, err)
+	}
+}
@@ -0,0 +1,188 @@
+// Package nvme connects a node to the NVMe over Fabrics subsystems that a
+// storage platform exports to it, over TCP or RDMA, and discovers the devices
+// of their namespaces. It is shared by the executors of drivers whose volumes
+// are NVMe-oF namespaces, in the same way that package scsi is shared by the
+// drivers of SCSI LUNs.
+package nvme
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+
+	apitypes "github.com/emccode/libstorage/api/types"
+)
+
+const (
+	// TransportTCP is the NVMe/TCP transport.
+	TransportTCP = "tcp"
+
+	// TransportRDMA is the NVMe/RDMA transport.
+	TransportRDMA = "rdma"
+
+	// DefaultPort is the port of a portal that has none.
+	DefaultPort = "4420"
+
+	hostNQNFile = "/etc/nvme/hostnqn"
+)
+
+// Executor connects the node to the subsystems exported to it by a target.
+// A driver whose volumes are NVMe-oF namespaces may use it as its executor,
+// or embed it. The node's instance ID is its host NQN, which the driver
+// allows to connect to a volume's subsystem. Local devices are keyed by the
+// NQN of their subsystem, so each of the driver's volumes is exported with a
+// subsystem of its own, and the driver's attach token is the subsystem's
+// NQN.
+type Executor struct {
+
+	// Driver is the name of the driver whose executor this is.
+	Driver string
+
+	// Transport is TransportTCP or TransportRDMA.
+	Transport string
+
+	// Portals are the addresses, host[:port], at which the target's
+	// discovery controller is reached.
+	Portals []string
+
+	// SubsystemPrefix is the prefix of the NQNs of the driver's subsystems.
+	// The node does not connect to or disconnect from other subsystems.
+	SubsystemPrefix string
+
+	// HostNQN is the node's host NQN. If it is empty the NQN in
+	// /etc/nvme/hostnqn is used, and one is generated if there is none.
+	HostNQN string
+
+	// Multipath reports a namespace's device-mapper multipath device, when
+	// the kernel's native NVMe multipathing is disabled and there is one.
+	Multipath bool
+}
+
+// InstanceID returns the node's host NQN.
+func (e *Executor) InstanceID(
+	ctx apitypes.Context,
+	opts apitypes.Store) (*apitypes.InstanceID, error) {
+
+	nqn, err := e.hostNQN(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &apitypes.InstanceID{ID: nqn, Driver: e.Driver}, nil
+}
+
+func (e *Executor) hostNQN(ctx apitypes.Context) (string, error) {
+	if e.HostNQN != "" {
+		return e.HostNQN, nil
+	}
+	return HostNQN(ctx)
+}
+
+// NextDevice is not implemented because the NVMe subsystem chooses the names
+// of the devices of new namespaces.
+func (e *Executor) NextDevice(
+	ctx apitypes.Context,
+	opts apitypes.Store) (string, error) {
+
+	return "", apitypes.ErrNotImplemented
+}
+
+// LocalDevices returns the devices of the namespaces of the subsystems to
+// which the node is connected. A deep scan first queries the discovery
+// controller at each portal, connects to the subsystems that were exported to
+// the node, and disconnects from those that no longer are.
+func (e *Executor) LocalDevices(
+	ctx apitypes.Context,
+	opts *apitypes.LocalDevicesOpts) (*apitypes.LocalDevices, error) {
+
+	if opts.ScanType == apitypes.DeviceScanDeep {
+		nqn, err := e.hostNQN(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if err := Sync(ctx, e.Transport, e.Portals, e.SubsystemPrefix,
+			nqn); err != nil {
+			return nil, err
+		}
+	}
+	devs, err := Devices(e.SubsystemPrefix, e.Multipath)
+	if err != nil {
+		return nil, err
+	}
+	return &apitypes.LocalDevices{Driver: e.Driver, DeviceMap: devs}, nil
+}
+
+// parseHostNQN returns the host NQN in the contents of a hostnqn file.
+func parseHostNQN(r io.Reader) (string, bool) {
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if strings.HasPrefix(line, "nqn.") {
+			return line, true
+		}
+	}
+	return "", false
+}
+
+// discoveryEntry is a record of a discovery log page, as printed by
+// nvme discover -o json.
+type discoveryEntry struct {
+	Transport string `json:"trtype"`
+	Address   string `json:"traddr"`
+	Port      string `json:"trsvcid"`
+	Subtype   string `json:"subtype"`
+	NQN       string `json:"subnqn"`
+}
+
+// parseDiscovery returns the entries of the NVMe subsystems in a discovery
+// log page whose NQNs have the prefix. Referrals to other discovery
+// controllers are omitted.
+func parseDiscovery(buf []byte, prefix string) ([]*discoveryEntry, error) {
+	page := &struct {
+		Records []*discoveryEntry `json:"records"`
+	}{}
+	if err := json.Unmarshal(buf, page); err != nil {
+		return nil, err
+	}
+	entries := []*discoveryEntry{}
+	for _, r := range page.Records {
+		if strings.Contains(r.Subtype, "discovery") ||
+			!strings.HasPrefix(r.NQN, prefix) {
+			continue
+		}
+		entries = append(entries, r)
+	}
+	return entries, nil
+}
+
+// SplitPortal returns a portal's host and port, using DefaultPort if it has
+// none. IPv6 addresses are enclosed in brackets when a port is given, ex.
+// [fd00::1]:4420.
+func SplitPortal(portal string) (string, string) {
+	if host, port, err := net.SplitHostPort(portal); err == nil {
+		return host, port
+	}
+	return strings.Trim(portal, "[]"), DefaultPort
+}
+
+// normalizeAddress returns the transport address and service ID of a
+// controller's address, ex. traddr=10.0.0.1,trsvcid=4420,src_addr=10.0.0.9,
+// in the form traddr=10.0.0.1,trsvcid=4420.
+func normalizeAddress(addr string) string {
+	fields := []string{}
+	for _, f := range strings.Split(addr, ",") {
+		if strings.HasPrefix(f, "traddr=") || strings.HasPrefix(f, "trsvcid=") {
+			fields = append(fields, f)
+		}
+	}
+	sort.Strings(fields)
+	return strings.Join(fields, ",")
+}
+
+// namespaceDevice matches the devices of namespaces, ex. nvme0n1, but not
+// their partitions or the hidden devices of their paths when the kernel's
+// native NVMe multipathing is enabled, ex. nvme0c1n1.
+var namespaceDevice = regexp.MustCompile(`^nvme\d+n\d+$`)
@@ -0,0 +1,212 @@
+package nvme
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/akutz/goof"
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/core/scsi"
+)
+
+const (
+	subsysDir = "/sys/class/nvme-subsystem"
+	sysBlock  = "/sys/block"
+)
+
+func nvme(args ...string) ([]byte, error) {
+	out, err := exec.Command("nvme", args...).CombinedOutput()
+	if err != nil {
+		return nil, goof.WithFieldsE(map[string]interface{}{
+			"args":   strings.Join(args, " "),
+			"output": string(bytes.TrimSpace(out)),
+		}, "nvme failed", err)
+	}
+	return out, nil
+}
+
+// HostNQN returns the node's host NQN from /etc/nvme/hostnqn. If the file
+// does not exist, an NQN is generated with nvme gen-hostnqn and written to
+// it so that the node keeps its identity.
+func HostNQN(ctx apitypes.Context) (string, error) {
+	f, err := os.Open(hostNQNFile)
+	if err == nil {
+		defer f.Close()
+		if nqn, ok := parseHostNQN(f); ok {
+			return nqn, nil
+		}
+		return "", goof.WithField("path", hostNQNFile, "no host nqn")
+	}
+	if !os.IsNotExist(err) {
+		return "", goof.WithFieldE("path", hostNQNFile,
+			"error reading host nqn", err)
+	}
+
+	out, err := nvme("gen-hostnqn")
+	if err != nil {
+		return "", err
+	}
+	nqn := strings.TrimSpace(string(out))
+	if err := os.MkdirAll(filepath.Dir(hostNQNFile), 0755); err != nil {
+		return "", err
+	}
+	if err := ioutil.WriteFile(hostNQNFile, []byte(nqn+"\n"), 0644); err != nil {
+		return "", goof.WithFieldE("path", hostNQNFile,
+			"error writing host nqn", err)
+	}
+	ctx.WithField("hostNQN", nqn).Info("generated nvme host nqn")
+	return nqn, nil
+}
+
+// Sync connects the node to the subsystems with the prefix that the
+// discovery controllers at the portals report are exported to it, and
+// disconnects it from the connected subsystems with the prefix that are no
+// longer reported.
+func Sync(
+	ctx apitypes.Context,
+	transport string,
+	portals []string,
+	prefix, hostNQN string) error {
+
+	exported := map[string]bool{}
+	for _, p := range portals {
+		host, port := SplitPortal(p)
+		out, err := nvme("discover", "-t", transport, "-a", host, "-s", port,
+			"-q", hostNQN, "-o", "json")
+		if err != nil {
+			return err
+		}
+		entries, err := parseDiscovery(out, prefix)
+		if err != nil {
+			return goof.WithFieldE("portal", p,
+				"error parsing discovery log", err)
+		}
+		for _, e := range entries {
+			exported[e.NQN] = true
+		}
+		if err := connect(ctx, transport, entries, hostNQN); err != nil {
+			return err
+		}
+	}
+
+	connected, err := subsystems()
+	if err != nil {
+		return err
+	}
+	for nqn := range connected {
+		if !strings.HasPrefix(nqn, prefix) || exported[nqn] {
+			continue
+		}
+		if _, err := nvme("disconnect", "-n", nqn); err != nil {
+			return err
+		}
+		ctx.WithField("nqn", nqn).Info("disconnected from nvme subsystem")
+	}
+	return nil
+}
+
+// connect connects to each of the discovered subsystems at the address at
+// which it was discovered, unless a controller of the subsystem is already
+// connected at that address.
+func connect(
+	ctx apitypes.Context,
+	transport string,
+	entries []*discoveryEntry,
+	hostNQN string) error {
+
+	connected, err := subsystems()
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		addr := "traddr=" + e.Address + ",trsvcid=" + e.Port
+		if connected[e.NQN][addr] {
+			continue
+		}
+		if _, err := nvme("connect", "-t", transport, "-a", e.Address,
+			"-s", e.Port, "-n", e.NQN, "-q", hostNQN); err != nil {
+			return err
+		}
+		ctx.WithFields(map[string]interface{}{
+			"nqn":     e.NQN,
+			"address": e.Address,
+			"port":    e.Port,
+		}).Info("connected to nvme subsystem")
+	}
+	return nil
+}
+
+// subsystems returns the NQNs of the subsystems to which the node is
+// connected, and the addresses of each one's controllers, ex.
+// traddr=10.0.0.1,trsvcid=4420.
+func subsystems() (map[string]map[string]bool, error) {
+	infos, err := ioutil.ReadDir(subsysDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return map[string]map[string]bool{}, nil
+		}
+		return nil, err
+	}
+	all := map[string]map[string]bool{}
+	for _, fi := range infos {
+		dir := filepath.Join(subsysDir, fi.Name())
+		nqn, ok := readAttr(filepath.Join(dir, "subsysnqn"))
+		if !ok {
+			continue
+		}
+		addrs := map[string]bool{}
+		ctrls, _ := filepath.Glob(filepath.Join(dir, "nvme*", "address"))
+		for _, c := range ctrls {
+			if addr, ok := readAttr(c); ok {
+				addrs[normalizeAddress(addr)] = true
+			}
+		}
+		all[nqn] = addrs
+	}
+	return all, nil
+}
+
+// Devices returns the devices of the namespaces of the connected subsystems
+// with the prefix, keyed by the subsystem's NQN. A subsystem's device is its
+// first namespace. If multipath is set and the namespace is held by a
+// device-mapper multipath device, that device is returned instead.
+func Devices(prefix string, multipath bool) (map[string]string, error) {
+	infos, err := ioutil.ReadDir(sysBlock)
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, fi := range infos {
+		if namespaceDevice.MatchString(fi.Name()) {
+			names = append(names, fi.Name())
+		}
+	}
+	sort.Strings(names)
+
+	devs := map[string]string{}
+	for _, name := range names {
+		nqn, ok := readAttr(filepath.Join(sysBlock, name, "device", "subsysnqn"))
+		if !ok || !strings.HasPrefix(nqn, prefix) {
+			continue
+		}
+		if _, ok := devs[nqn]; ok {
+			continue
+		}
+		devs[nqn] = scsi.DevicePath(filepath.Join("/dev", name), multipath)
+	}
+	return devs, nil
+}
+
+func readAttr(path string) (string, bool) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", false
+	}
+	return strings.TrimSpace(string(buf)), true
+}
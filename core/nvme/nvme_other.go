@@ -0,0 +1,30 @@
+// +build !linux
+
+package nvme
+
+import (
+	"github.com/akutz/goof"
+	apitypes "github.com/emccode/libstorage/api/types"
+)
+
+var errNotSupported = goof.New("nvme-of is only supported on Linux")
+
+// HostNQN is not supported on operating systems other than Linux.
+func HostNQN(ctx apitypes.Context) (string, error) {
+	return "", errNotSupported
+}
+
+// Sync is not supported on operating systems other than Linux.
+func Sync(
+	ctx apitypes.Context,
+	transport string,
+	portals []string,
+	prefix, hostNQN string) error {
+
+	return errNotSupported
+}
+
+// Devices returns no devices on operating systems other than Linux.
+func Devices(prefix string, multipath bool) (map[string]string, error) {
+	return map[string]string{}, nil
+}
@@ -0,0 +1,76 @@
+package nvme
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseHostNQN(t *testing.T) {
+	nqn, ok := parseHostNQN(strings.NewReader(
+		"nqn.2014-08.org.nvmexpress:uuid:4c4c4544-0031-4a10-8057-b4c04f4e3232\n"))
+	if !ok || nqn != "nqn.2014-08.org.nvmexpress:uuid:4c4c4544-0031-4a10-8057-b4c04f4e3232" {
+		t.Fatalf("nqn=%s ok=%v", nqn, ok)
+	}
+	if _, ok := parseHostNQN(strings.NewReader("\n")); ok {
+		t.Fatal("expected no host nqn")
+	}
+}
+
+func TestParseDiscovery(t *testing.T) {
+	entries, err := parseDiscovery([]byte(`{
+  "device": "nvme0",
+  "records": [
+    {"trtype": "tcp", "adrfam": "ipv4", "subtype": "discovery subsystem",
+     "trsvcid": "4420", "subnqn": "nqn.2014-08.org.nvmexpress.discovery",
+     "traddr": "10.0.0.1"},
+    {"trtype": "tcp", "adrfam": "ipv4", "subtype": "nvme subsystem",
+     "trsvcid": "4420", "subnqn": "nqn.2016-06.io.spdk:rexray-data",
+     "traddr": "10.0.0.1"},
+    {"trtype": "tcp", "adrfam": "ipv4", "subtype": "nvme subsystem",
+     "trsvcid": "4420", "subnqn": "nqn.2016-06.io.spdk:other",
+     "traddr": "10.0.0.1"}
+  ]
+}`), "nqn.2016-06.io.spdk:rexray-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 ||
+		entries[0].NQN != "nqn.2016-06.io.spdk:rexray-data" ||
+		entries[0].Address != "10.0.0.1" || entries[0].Port != "4420" {
+		t.Fatalf("entries=%v", entries)
+	}
+}
+
+func TestSplitPortal(t *testing.T) {
+	tests := []struct {
+		portal, host, port string
+	}{
+		{"10.0.0.1", "10.0.0.1", "4420"},
+		{"10.0.0.1:8009", "10.0.0.1", "8009"},
+		{"[fd00::1]:4421", "fd00::1", "4421"},
+		{"fd00::1", "fd00::1", "4420"},
+	}
+	for _, tt := range tests {
+		if host, port := SplitPortal(tt.portal); host != tt.host ||
+			port != tt.port {
+			t.Errorf("SplitPortal(%s)=%s,%s", tt.portal, host, port)
+		}
+	}
+}
+
+func TestNormalizeAddress(t *testing.T) {
+	a := normalizeAddress("trsvcid=4420,traddr=10.0.0.1,src_addr=10.0.0.9")
+	if a != "traddr=10.0.0.1,trsvcid=4420" {
+		t.Fatalf("address=%s", a)
+	}
+	for _, name := range []string{"nvme0n1", "nvme12n3"} {
+		if !namespaceDevice.MatchString(name) {
+			t.Errorf("expected %s to be a namespace device", name)
+		}
+	}
+	for _, name := range []string{"nvme0c1n1", "nvme0n1p1", "nvme0"} {
+		if namespaceDevice.MatchString(name) {
+			t.Errorf("expected %s not to be a namespace device", name)
+		}
+	}
+}
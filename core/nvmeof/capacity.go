@@ -0,0 +1,65 @@
+package nvmeof
+
+import (
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/core/capacity"
+)
+
+func init() {
+	capacity.Register(Name, lvstoreCapacity)
+}
+
+// lvstore is an lvstore, as returned by bdev_lvol_get_lvstores.
+type lvstore struct {
+	Name              string `json:"name"`
+	UUID              string `json:"uuid"`
+	ClusterSize       int64  `json:"cluster_size"`
+	TotalDataClusters int64  `json:"total_data_clusters"`
+	FreeClusters      int64  `json:"free_clusters"`
+}
+
+// Capacity returns the size and free space of the driver's lvstore. The
+// free space of a thinly provisioned lvstore is that of its clusters that
+// have not been written to, so it may be less than the sum of the sizes of
+// the volumes that may still be created.
+func (d *driver) Capacity(
+	ctx apitypes.Context,
+	opts apitypes.Store) ([]*capacity.Capacity, error) {
+
+	return listCapacity(d.rpc, d.lvstore)
+}
+
+// lvstoreCapacity returns the size and free space of the configured lvstore
+// without an initialized driver.
+func lvstoreCapacity(
+	ctx apitypes.Context, config gofig.Config) ([]*capacity.Capacity, error) {
+
+	rpc, err := newCaller(config)
+	if err != nil {
+		return nil, err
+	}
+	return listCapacity(rpc, lvstoreName(config))
+}
+
+func listCapacity(rpc caller, name string) ([]*capacity.Capacity, error) {
+	stores := []*lvstore{}
+	if err := rpc.call("bdev_lvol_get_lvstores", map[string]interface{}{
+		"lvs_name": name,
+	}, &stores); err != nil {
+		return nil, err
+	}
+	for _, s := range stores {
+		if s.Name != name {
+			continue
+		}
+		return []*capacity.Capacity{{
+			TotalBytes:     s.TotalDataClusters * s.ClusterSize,
+			AvailableBytes: s.FreeClusters * s.ClusterSize,
+			Topology:       map[string]string{capacity.TopologyPool: s.Name},
+		}}, nil
+	}
+	return nil, goof.WithField("lvstore", name, "lvstore not found")
+}
@@ -0,0 +1,470 @@
+package nvmeof
+
+import (
+	"net"
+	"strings"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	"github.com/emccode/libstorage/api/context"
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/core/nvme"
+)
+
+const (
+	gib = 1024 * 1024 * 1024
+	mib = 1024 * 1024
+)
+
+type driver struct {
+	config    gofig.Config
+	rpc       caller
+	lvstore   string
+	prefix    string
+	transport string
+	portals   []string
+}
+
+func newDriver() apitypes.StorageDriver {
+	return &driver{}
+}
+
+func (d *driver) Name() string {
+	return Name
+}
+
+// Init provisions volumes with the configured hook if there is one, and
+// otherwise with SPDK.
+func (d *driver) Init(ctx apitypes.Context, config gofig.Config) error {
+	d.config = config
+	d.lvstore = lvstoreName(config)
+	d.prefix = subsystemPrefix(config)
+
+	switch d.transport = config.GetString("nvmeof.transport"); d.transport {
+	case nvme.TransportTCP, nvme.TransportRDMA:
+	default:
+		return goof.WithField("transport", d.transport,
+			"invalid nvmeof transport")
+	}
+	if d.portals = strings.Fields(
+		config.GetString("nvmeof.portals")); len(d.portals) == 0 {
+		return goof.New("nvmeof driver requires nvmeof.portals")
+	}
+
+	rpc, err := newCaller(config)
+	if err != nil {
+		return err
+	}
+	d.rpc = rpc
+
+	ctx.WithFields(map[string]interface{}{
+		"lvstore":   d.lvstore,
+		"transport": d.transport,
+	}).Info("initialized nvmeof driver")
+	return nil
+}
+
+// instanceID returns the host NQN of the instance on whose behalf an
+// operation is performed.
+func instanceID(ctx apitypes.Context) string {
+	if iid, ok := ctx.Value(context.InstanceIDKey).(*apitypes.InstanceID); ok {
+		return iid.ID
+	}
+	return ""
+}
+
+func (d *driver) Type(ctx apitypes.Context) (apitypes.StorageType, error) {
+	return apitypes.Block, nil
+}
+
+// NextDeviceInfo returns nil because the names of the devices of attached
+// namespaces are chosen by the node's NVMe subsystem.
+func (d *driver) NextDeviceInfo(
+	ctx apitypes.Context) (*apitypes.NextDeviceInfo, error) {
+
+	return nil, nil
+}
+
+func (d *driver) InstanceInspect(
+	ctx apitypes.Context,
+	opts apitypes.Store) (*apitypes.Instance, error) {
+
+	iid, ok := ctx.Value(context.InstanceIDKey).(*apitypes.InstanceID)
+	if !ok {
+		return nil, goof.New("missing instance ID")
+	}
+	return &apitypes.Instance{InstanceID: iid, Name: iid.ID}, nil
+}
+
+// bdevName returns the name of a volume's logical volume.
+func (d *driver) bdevName(volumeID string) string {
+	return d.lvstore + "/" + volumeID
+}
+
+// volumeName returns the name of the volume whose logical volume has the
+// bdev, and false if the bdev is not a logical volume in the lvstore.
+func (d *driver) volumeName(b *bdev) (string, bool) {
+	prefix := d.lvstore + "/"
+	for _, a := range b.Aliases {
+		if strings.HasPrefix(a, prefix) {
+			return a[len(prefix):], true
+		}
+	}
+	return "", false
+}
+
+func (d *driver) listSubsystems() (map[string]*subsystem, error) {
+	subs := []*subsystem{}
+	if err := d.rpc.call("nvmf_get_subsystems", nil, &subs); err != nil {
+		return nil, err
+	}
+	byNQN := map[string]*subsystem{}
+	for _, s := range subs {
+		if strings.HasPrefix(s.NQN, d.prefix) {
+			byNQN[s.NQN] = s
+		}
+	}
+	return byNQN, nil
+}
+
+// toVolume returns a libStorage volume for a logical volume. A volume's name
+// is its ID because the logical volume is referred to by name.
+func (d *driver) toVolume(
+	ctx apitypes.Context,
+	name string,
+	b *bdev,
+	subs map[string]*subsystem) *apitypes.Volume {
+
+	vol := &apitypes.Volume{
+		ID:     name,
+		Name:   name,
+		Size:   b.NumBlocks * b.BlockSize / gib,
+		Type:   d.lvstore,
+		Status: "available",
+		Fields: map[string]string{"uuid": b.UUID},
+	}
+
+	nqn := subsystemNQN(d.prefix, name)
+	sub, ok := subs[nqn]
+	if !ok {
+		return vol
+	}
+	iid := instanceID(ctx)
+	ld, _ := ctx.Value(context.LocalDevicesKey).(*apitypes.LocalDevices)
+	for _, h := range sub.Hosts {
+		att := &apitypes.VolumeAttachment{
+			VolumeID: name,
+			InstanceID: &apitypes.InstanceID{
+				ID:     h.NQN,
+				Driver: Name,
+			},
+			Status: "attached",
+			Fields: map[string]string{"nqn": nqn},
+		}
+		if h.NQN == iid && ld != nil {
+			att.DeviceName = ld.DeviceMap[nqn]
+		}
+		vol.Attachments = append(vol.Attachments, att)
+		vol.Status = "attached"
+	}
+	return vol
+}
+
+func (d *driver) Volumes(
+	ctx apitypes.Context,
+	opts *apitypes.VolumesOpts) ([]*apitypes.Volume, error) {
+
+	bdevs := []*bdev{}
+	if err := d.rpc.call("bdev_get_bdevs", nil, &bdevs); err != nil {
+		return nil, err
+	}
+	subs := map[string]*subsystem{}
+	if opts.Attachments {
+		var err error
+		if subs, err = d.listSubsystems(); err != nil {
+			return nil, err
+		}
+	}
+
+	all := []*apitypes.Volume{}
+	for _, b := range bdevs {
+		if name, ok := d.volumeName(b); ok {
+			all = append(all, d.toVolume(ctx, name, b, subs))
+		}
+	}
+	return all, nil
+}
+
+func (d *driver) VolumeInspect(
+	ctx apitypes.Context,
+	volumeID string,
+	opts *apitypes.VolumeInspectOpts) (*apitypes.Volume, error) {
+
+	return d.inspect(ctx, volumeID, opts.Attachments)
+}
+
+func (d *driver) inspect(
+	ctx apitypes.Context,
+	volumeID string,
+	attachments bool) (*apitypes.Volume, error) {
+
+	vols, err := d.Volumes(ctx, &apitypes.VolumesOpts{Attachments: attachments})
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range vols {
+		if v.ID == volumeID {
+			return v, nil
+		}
+	}
+	return nil, goof.WithField("volumeID", volumeID, "volume not found")
+}
+
+func (d *driver) VolumeCreate(
+	ctx apitypes.Context,
+	name string,
+	opts *apitypes.VolumeCreateOpts) (*apitypes.Volume, error) {
+
+	size := int64(defaultSize)
+	if opts.Size != nil && *opts.Size > 0 {
+		size = *opts.Size
+	}
+	if err := d.rpc.call("bdev_lvol_create", map[string]interface{}{
+		"lvs_name":       d.lvstore,
+		"lvol_name":      name,
+		"size_in_mib":    size * gib / mib,
+		"thin_provision": d.config.GetBool("nvmeof.thinProvision"),
+	}, nil); err != nil {
+		return nil, goof.WithFieldE("name", name, "error creating volume", err)
+	}
+	return d.inspect(ctx, name, false)
+}
+
+func (d *driver) VolumeCreateFromSnapshot(
+	ctx apitypes.Context,
+	snapshotID, volumeName string,
+	opts *apitypes.VolumeCreateOpts) (*apitypes.Volume, error) {
+
+	return nil, apitypes.ErrNotImplemented
+}
+
+func (d *driver) VolumeCopy(
+	ctx apitypes.Context,
+	volumeID, volumeName string,
+	opts apitypes.Store) (*apitypes.Volume, error) {
+
+	return nil, apitypes.ErrNotImplemented
+}
+
+func (d *driver) VolumeSnapshot(
+	ctx apitypes.Context,
+	volumeID, snapshotName string,
+	opts apitypes.Store) (*apitypes.Snapshot, error) {
+
+	return nil, apitypes.ErrNotImplemented
+}
+
+// VolumeRemove deletes the volume's subsystem, if it was ever attached, and
+// then its logical volume.
+func (d *driver) VolumeRemove(
+	ctx apitypes.Context,
+	volumeID string,
+	opts apitypes.Store) error {
+
+	subs, err := d.listSubsystems()
+	if err != nil {
+		return err
+	}
+	nqn := subsystemNQN(d.prefix, volumeID)
+	if _, ok := subs[nqn]; ok {
+		if err := d.rpc.call("nvmf_delete_subsystem", map[string]interface{}{
+			"nqn": nqn,
+		}, nil); err != nil {
+			return goof.WithFieldE("nqn", nqn,
+				"error deleting subsystem", err)
+		}
+	}
+
+	if err := d.rpc.call("bdev_lvol_delete", map[string]interface{}{
+		"name": d.bdevName(volumeID),
+	}, nil); err != nil {
+		return goof.WithFieldE("volumeID", volumeID,
+			"error removing volume", err)
+	}
+	return nil
+}
+
+// VolumeAttach allows the instance's host NQN to connect to the volume's
+// subsystem, which is created the first time the volume is attached. The
+// returned token is the subsystem's NQN, which is the key of the volume's
+// device in the node's local devices. A forced attach first removes the
+// other hosts from the subsystem.
+func (d *driver) VolumeAttach(
+	ctx apitypes.Context,
+	volumeID string,
+	opts *apitypes.VolumeAttachOpts) (*apitypes.Volume, string, error) {
+
+	iid := instanceID(ctx)
+	if iid == "" {
+		return nil, "", goof.New("missing instance ID")
+	}
+	if _, err := d.inspect(ctx, volumeID, false); err != nil {
+		return nil, "", err
+	}
+
+	subs, err := d.listSubsystems()
+	if err != nil {
+		return nil, "", err
+	}
+	nqn := subsystemNQN(d.prefix, volumeID)
+	sub, ok := subs[nqn]
+	if !ok {
+		if err := d.createSubsystem(nqn, volumeID); err != nil {
+			return nil, "", err
+		}
+		sub = &subsystem{NQN: nqn}
+	}
+
+	for _, h := range sub.Hosts {
+		switch {
+		case h.NQN == iid:
+			vol, err := d.inspect(ctx, volumeID, true)
+			return vol, nqn, err
+		case opts.Force:
+			if err := d.removeHost(nqn, h.NQN); err != nil {
+				return nil, "", err
+			}
+		}
+	}
+
+	if err := d.rpc.call("nvmf_subsystem_add_host", map[string]interface{}{
+		"nqn":  nqn,
+		"host": iid,
+	}, nil); err != nil {
+		return nil, "", goof.WithFieldsE(map[string]interface{}{
+			"volumeID": volumeID,
+			"host":     iid,
+		}, "error exporting volume", err)
+	}
+
+	vol, err := d.inspect(ctx, volumeID, true)
+	if err != nil {
+		return nil, "", err
+	}
+	return vol, nqn, nil
+}
+
+// createSubsystem creates a subsystem whose only namespace is the volume's
+// logical volume, and which listens at each of the portals. Only the hosts
+// that are added to the subsystem may connect to it.
+func (d *driver) createSubsystem(nqn, volumeID string) error {
+	fields := map[string]interface{}{"volumeID": volumeID, "nqn": nqn}
+	if err := d.rpc.call("nvmf_create_subsystem", map[string]interface{}{
+		"nqn":            nqn,
+		"serial_number":  serialNumber(volumeID),
+		"allow_any_host": false,
+	}, nil); err != nil {
+		return goof.WithFieldsE(fields, "error creating subsystem", err)
+	}
+	if err := d.rpc.call("nvmf_subsystem_add_ns", map[string]interface{}{
+		"nqn": nqn,
+		"namespace": map[string]interface{}{
+			"bdev_name": d.bdevName(volumeID),
+		},
+	}, nil); err != nil {
+		return goof.WithFieldsE(fields, "error adding namespace", err)
+	}
+	for _, p := range d.portals {
+		host, port := nvme.SplitPortal(p)
+		adrfam := "IPv4"
+		if ip := net.ParseIP(host); ip != nil && ip.To4() == nil {
+			adrfam = "IPv6"
+		}
+		if err := d.rpc.call("nvmf_subsystem_add_listener",
+			map[string]interface{}{
+				"nqn": nqn,
+				"listen_address": map[string]interface{}{
+					"trtype":  strings.ToUpper(d.transport),
+					"adrfam":  adrfam,
+					"traddr":  host,
+					"trsvcid": port,
+				},
+			}, nil); err != nil {
+			return goof.WithFieldsE(map[string]interface{}{
+				"nqn":    nqn,
+				"portal": p,
+			}, "error adding listener", err)
+		}
+	}
+	return nil
+}
+
+// VolumeDetach removes the instance's host NQN from the volume's subsystem,
+// or all of its hosts if the detach is forced.
+func (d *driver) VolumeDetach(
+	ctx apitypes.Context,
+	volumeID string,
+	opts *apitypes.VolumeDetachOpts) (*apitypes.Volume, error) {
+
+	iid := instanceID(ctx)
+	subs, err := d.listSubsystems()
+	if err != nil {
+		return nil, err
+	}
+	nqn := subsystemNQN(d.prefix, volumeID)
+	if sub, ok := subs[nqn]; ok {
+		for _, h := range sub.Hosts {
+			if h.NQN == iid || opts.Force {
+				if err := d.removeHost(nqn, h.NQN); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return d.inspect(ctx, volumeID, true)
+}
+
+func (d *driver) removeHost(nqn, host string) error {
+	if err := d.rpc.call("nvmf_subsystem_remove_host", map[string]interface{}{
+		"nqn":  nqn,
+		"host": host,
+	}, nil); err != nil {
+		return goof.WithFieldsE(map[string]interface{}{
+			"nqn":  nqn,
+			"host": host,
+		}, "error unexporting volume", err)
+	}
+	return nil
+}
+
+func (d *driver) Snapshots(
+	ctx apitypes.Context,
+	opts apitypes.Store) ([]*apitypes.Snapshot, error) {
+
+	return nil, apitypes.ErrNotImplemented
+}
+
+func (d *driver) SnapshotInspect(
+	ctx apitypes.Context,
+	snapshotID string,
+	opts apitypes.Store) (*apitypes.Snapshot, error) {
+
+	return nil, apitypes.ErrNotImplemented
+}
+
+func (d *driver) SnapshotCopy(
+	ctx apitypes.Context,
+	snapshotID, snapshotName, destinationID string,
+	opts apitypes.Store) (*apitypes.Snapshot, error) {
+
+	return nil, apitypes.ErrNotImplemented
+}
+
+func (d *driver) SnapshotRemove(
+	ctx apitypes.Context,
+	snapshotID string,
+	opts apitypes.Store) error {
+
+	return apitypes.ErrNotImplemented
+}
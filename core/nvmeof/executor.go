@@ -0,0 +1,42 @@
+package nvmeof
+
+import (
+	"strings"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/core/nvme"
+	"github.com/emccode/rexray/core/scsi"
+)
+
+// executor runs on each node. A node's instance ID is its host NQN.
+type executor struct {
+	*nvme.Executor
+}
+
+func newExecutor() apitypes.StorageExecutor {
+	return &executor{}
+}
+
+func (e *executor) Name() string {
+	return Name
+}
+
+func (e *executor) Init(ctx apitypes.Context, config gofig.Config) error {
+	portals := strings.Fields(config.GetString("nvmeof.portals"))
+	if len(portals) == 0 {
+		return goof.New("nvmeof executor requires nvmeof.portals")
+	}
+	e.Executor = &nvme.Executor{
+		Driver:          Name,
+		Transport:       config.GetString("nvmeof.transport"),
+		Portals:         portals,
+		SubsystemPrefix: subsystemPrefix(config),
+		HostNQN:         config.GetString("nvmeof.hostNQN"),
+		Multipath: config.GetBool("nvmeof.multipath") &&
+			scsi.Multipath(config),
+	}
+	return nil
+}
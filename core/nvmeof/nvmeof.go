@@ -0,0 +1,100 @@
+// Package nvmeof is a storage driver for NVMe over Fabrics targets whose
+// volumes are provisioned with SPDK's JSON-RPC API, or with a provisioning
+// hook that speaks the same methods, such as a shim for a Lightbits cluster.
+// A volume is a logical volume in an lvstore, exported as the namespace of a
+// subsystem of its own to the host NQNs of the nodes it is attached to.
+// Nodes connect to the subsystems with nvme-cli over TCP or RDMA.
+package nvmeof
+
+import (
+	"github.com/akutz/gofig"
+	"github.com/emccode/libstorage/api/registry"
+
+	"github.com/emccode/rexray/core/nvme"
+)
+
+const (
+	// Name is the name with which the driver is registered.
+	Name = "nvmeof"
+
+	defaultLVStore = "lvs0"
+	defaultPrefix  = "nqn.2016-06.io.spdk:rexray-"
+	defaultSize    = 16
+)
+
+func init() {
+	registry.RegisterStorageDriver(Name, newDriver)
+	registry.RegisterStorageExecutor(Name, newExecutor)
+
+	r := gofig.NewRegistration("NVMe-oF Driver")
+	r.Key(gofig.String, "", nvme.TransportTCP,
+		"The transport with which nodes connect to subsystems: tcp or rdma",
+		"nvmeof.transport")
+	r.Key(gofig.String, "", "",
+		"The portals, host[:port], at which subsystems are exported",
+		"nvmeof.portals")
+	r.Key(gofig.String, "", defaultLVStore,
+		"The lvstore in which volumes are provisioned",
+		"nvmeof.lvstore")
+	r.Key(gofig.Bool, "", true,
+		"Thin provision volumes",
+		"nvmeof.thinProvision")
+	r.Key(gofig.String, "", defaultPrefix,
+		"The prefix of the NQNs of the volumes' subsystems",
+		"nvmeof.subsystemPrefix")
+	r.Key(gofig.String, "", "",
+		"The URL of SPDK's JSON-RPC HTTP endpoint",
+		"nvmeof.endpoint")
+	r.Key(gofig.String, "", "",
+		"The user name with which JSON-RPC requests are authenticated",
+		"nvmeof.username")
+	r.Key(gofig.String, "", "",
+		"The password with which JSON-RPC requests are authenticated",
+		"nvmeof.password")
+	r.Key(gofig.Bool, "", false,
+		"Skip the verification of the endpoint's TLS certificate",
+		"nvmeof.insecure")
+	r.Key(gofig.String, "", "30s",
+		"How long to wait for a provisioning request",
+		"nvmeof.timeout")
+	r.Key(gofig.String, "", "",
+		"An executable that provisions volumes instead of SPDK",
+		"nvmeof.hook")
+	r.Key(gofig.String, "", "",
+		"The node's host NQN if not read from /etc/nvme/hostnqn",
+		"nvmeof.hostNQN")
+	r.Key(gofig.Bool, "", true,
+		"Use the multipath device of a namespace when there is one",
+		"nvmeof.multipath")
+	gofig.Register(r)
+}
+
+func lvstoreName(config gofig.Config) string {
+	if s := config.GetString("nvmeof.lvstore"); s != "" {
+		return s
+	}
+	return defaultLVStore
+}
+
+func subsystemPrefix(config gofig.Config) string {
+	if p := config.GetString("nvmeof.subsystemPrefix"); p != "" {
+		return p
+	}
+	return defaultPrefix
+}
+
+// subsystemNQN returns the NQN of the subsystem with which a volume is
+// exported, which is also the key of the volume's device in a node's local
+// devices.
+func subsystemNQN(prefix, volumeID string) string {
+	return prefix + volumeID
+}
+
+// serialNumber returns the serial number of a volume's subsystem. NVMe serial
+// numbers are at most 20 ASCII characters.
+func serialNumber(volumeID string) string {
+	if len(volumeID) > 20 {
+		return volumeID[len(volumeID)-20:]
+	}
+	return volumeID
+}
@@ -0,0 +1,27 @@
+package nvmeof
+
+import "testing"
+
+func TestVolumeName(t *testing.T) {
+	d := &driver{lvstore: "lvs0"}
+	name, ok := d.volumeName(&bdev{
+		Name:    "b6a6a1a4-3c1e-4f6e-9f43-0c6c0f6e2b1a",
+		Aliases: []string{"lvs0/data"},
+	})
+	if !ok || name != "data" {
+		t.Fatalf("name=%s ok=%v", name, ok)
+	}
+	if _, ok := d.volumeName(&bdev{
+		Name: "Malloc0", Aliases: []string{"lvs1/data"}}); ok {
+		t.Fatal("expected bdev of other lvstore to be ignored")
+	}
+}
+
+func TestSerialNumber(t *testing.T) {
+	if s := serialNumber("data"); s != "data" {
+		t.Fatalf("serial=%s", s)
+	}
+	if s := serialNumber("a-very-long-volume-name-01"); s != "-long-volume-name-01" {
+		t.Fatalf("serial=%s", s)
+	}
+}
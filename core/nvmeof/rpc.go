@@ -0,0 +1,208 @@
+package nvmeof
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"net/http"
+	"os/exec"
+	"sync/atomic"
+	"time"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+)
+
+// caller calls a provisioning method of SPDK's JSON-RPC API, ex.
+// bdev_lvol_create.
+type caller interface {
+	call(method string, params map[string]interface{}, result interface{}) error
+}
+
+// bdev is a block device, as returned by bdev_get_bdevs. The aliases of a
+// logical volume include LVSTORE/NAME.
+type bdev struct {
+	Name      string   `json:"name"`
+	Aliases   []string `json:"aliases"`
+	UUID      string   `json:"uuid"`
+	BlockSize int64    `json:"block_size"`
+	NumBlocks int64    `json:"num_blocks"`
+}
+
+// subsystem is an NVMe-oF subsystem, as returned by nvmf_get_subsystems.
+type subsystem struct {
+	NQN   string `json:"nqn"`
+	Hosts []struct {
+		NQN string `json:"nqn"`
+	} `json:"hosts"`
+	Namespaces []struct {
+		BdevName string `json:"bdev_name"`
+	} `json:"namespaces"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type rpcResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *rpcError       `json:"error"`
+}
+
+func (r *rpcResponse) decode(method string, result interface{}) error {
+	if r.Error != nil {
+		return goof.WithFields(map[string]interface{}{
+			"method": method,
+			"code":   r.Error.Code,
+		}, r.Error.Message)
+	}
+	if result == nil || len(r.Result) == 0 {
+		return nil
+	}
+	return json.Unmarshal(r.Result, result)
+}
+
+func timeout(config gofig.Config) time.Duration {
+	if t, err := time.ParseDuration(
+		config.GetString("nvmeof.timeout")); err == nil && t > 0 {
+		return t
+	}
+	return 30 * time.Second
+}
+
+// newCaller returns the configured hook if there is one, and otherwise a
+// client of SPDK.
+func newCaller(config gofig.Config) (caller, error) {
+	if path := config.GetString("nvmeof.hook"); path != "" {
+		return &hook{path: path, timeout: timeout(config)}, nil
+	}
+	return newSPDK(config)
+}
+
+// spdk calls the methods of SPDK's JSON-RPC API at an HTTP endpoint, such as
+// the one served by SPDK's rpc_http_proxy.
+type spdk struct {
+	endpoint string
+	username string
+	password string
+	client   *http.Client
+	id       int64
+}
+
+func newSPDK(config gofig.Config) (*spdk, error) {
+	endpoint := config.GetString("nvmeof.endpoint")
+	if endpoint == "" {
+		return nil, goof.New(
+			"nvmeof driver requires nvmeof.endpoint or nvmeof.hook")
+	}
+	s := &spdk{
+		endpoint: endpoint,
+		username: config.GetString("nvmeof.username"),
+		password: config.GetString("nvmeof.password"),
+		client:   &http.Client{Timeout: timeout(config)},
+	}
+	if config.GetBool("nvmeof.insecure") {
+		s.client.Transport = &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		}
+	}
+	return s, nil
+}
+
+func (s *spdk) call(
+	method string, params map[string]interface{}, result interface{}) error {
+
+	req := map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      atomic.AddInt64(&s.id, 1),
+		"method":  method,
+	}
+	if params != nil {
+		req["params"] = params
+	}
+	buf, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	hreq, err := http.NewRequest("POST", s.endpoint, bytes.NewReader(buf))
+	if err != nil {
+		return err
+	}
+	hreq.Header.Set("Content-Type", "application/json")
+	if s.username != "" {
+		hreq.SetBasicAuth(s.username, s.password)
+	}
+
+	res, err := s.client.Do(hreq)
+	if err != nil {
+		return goof.WithFieldE("method", method, "error calling spdk", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return goof.WithFields(map[string]interface{}{
+			"method": method,
+			"status": res.Status,
+		}, "error calling spdk")
+	}
+
+	rpcRes := &rpcResponse{}
+	if err := json.NewDecoder(res.Body).Decode(rpcRes); err != nil {
+		return goof.WithFieldE("method", method,
+			"error decoding spdk response", err)
+	}
+	return rpcRes.decode(method, result)
+}
+
+// hook calls the methods by running an executable with the method as its
+// argument. The parameters are written to its standard input as JSON, and it
+// writes a JSON-RPC response to its standard output.
+type hook struct {
+	path    string
+	timeout time.Duration
+}
+
+func (h *hook) call(
+	method string, params map[string]interface{}, result interface{}) error {
+
+	buf, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command(h.path, method)
+	cmd.Stdin = bytes.NewReader(buf)
+	out := &bytes.Buffer{}
+	cmd.Stdout = out
+	if err := cmd.Start(); err != nil {
+		return goof.WithFieldE("path", h.path,
+			"error starting provisioning hook", err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			return goof.WithFieldsE(map[string]interface{}{
+				"path":   h.path,
+				"method": method,
+			}, "provisioning hook failed", err)
+		}
+	case <-time.After(h.timeout):
+		cmd.Process.Kill()
+		return goof.WithFields(map[string]interface{}{
+			"path":    h.path,
+			"method":  method,
+			"timeout": h.timeout,
+		}, "provisioning hook timed out")
+	}
+
+	rpcRes := &rpcResponse{}
+	if err := json.Unmarshal(out.Bytes(), rpcRes); err != nil {
+		return goof.WithFieldE("method", method,
+			"error decoding provisioning hook response", err)
+	}
+	return rpcRes.decode(method, result)
+}
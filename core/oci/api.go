@@ -0,0 +1,268 @@
+package oci
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	"github.com/oracle/oci-go-sdk/common"
+	"github.com/oracle/oci-go-sdk/core"
+)
+
+const requestTimeout = time.Minute
+
+// client calls the block storage and compute APIs in a compartment.
+type client struct {
+	storage     core.BlockstorageClient
+	compute     core.ComputeClient
+	compartment string
+	timeout     time.Duration
+}
+
+// newClient returns a client of the configured compartment, or if there is
+// none, the compartment of the instance REX-Ray runs on.
+func newClient(config gofig.Config) (*client, error) {
+	p, err := configProvider(config)
+	if err != nil {
+		return nil, err
+	}
+	c := &client{
+		compartment: config.GetString("oci.compartmentID"),
+		timeout:     5 * time.Minute,
+	}
+	if t, err := time.ParseDuration(
+		config.GetString("oci.timeout")); err == nil && t > 0 {
+		c.timeout = t
+	}
+	if c.storage, err = core.NewBlockstorageClientWithConfigurationProvider(
+		p); err != nil {
+		return nil, err
+	}
+	if c.compute, err = core.NewComputeClientWithConfigurationProvider(
+		p); err != nil {
+		return nil, err
+	}
+	if r := config.GetString("oci.region"); r != "" {
+		c.storage.SetRegion(r)
+		c.compute.SetRegion(r)
+	}
+	if c.compartment == "" {
+		md, err := localInstance()
+		if err != nil {
+			return nil, goof.WithFieldE("key", "oci.compartmentID",
+				"oci compartment is not configured", err)
+		}
+		c.compartment = md.CompartmentID
+	}
+	return c, nil
+}
+
+func requestContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), requestTimeout)
+}
+
+// isNotFound returns a flag indicating whether the error is that of a
+// request for an object that does not exist.
+func isNotFound(err error) bool {
+	se, ok := common.IsServiceError(err)
+	return ok && se.GetHTTPStatusCode() == http.StatusNotFound
+}
+
+// volumes returns the compartment's volumes that are not terminated.
+func (c *client) volumes() ([]core.Volume, error) {
+	all := []core.Volume{}
+	req := core.ListVolumesRequest{CompartmentId: &c.compartment}
+	for {
+		ctx, cancel := requestContext()
+		res, err := c.storage.ListVolumes(ctx, req)
+		cancel()
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range res.Items {
+			if !isTerminated(v.LifecycleState) {
+				all = append(all, v)
+			}
+		}
+		if res.OpcNextPage == nil {
+			return all, nil
+		}
+		req.Page = res.OpcNextPage
+	}
+}
+
+func isTerminated(s core.VolumeLifecycleStateEnum) bool {
+	return s == core.VolumeLifecycleStateTerminating ||
+		s == core.VolumeLifecycleStateTerminated
+}
+
+func (c *client) volume(id string) (*core.Volume, error) {
+	ctx, cancel := requestContext()
+	defer cancel()
+	res, err := c.storage.GetVolume(ctx, core.GetVolumeRequest{VolumeId: &id})
+	if err != nil {
+		return nil, err
+	}
+	if isTerminated(res.Volume.LifecycleState) {
+		return nil, goof.WithField("volumeID", id, "volume not found")
+	}
+	return &res.Volume, nil
+}
+
+func (c *client) instance(id string) (*core.Instance, error) {
+	ctx, cancel := requestContext()
+	defer cancel()
+	res, err := c.compute.GetInstance(
+		ctx, core.GetInstanceRequest{InstanceId: &id})
+	if err != nil {
+		return nil, err
+	}
+	return &res.Instance, nil
+}
+
+// attachments returns the attachments of a volume or an instance that are
+// attaching or attached.
+func (c *client) attachments(
+	volumeID, instanceID string) ([]core.VolumeAttachment, error) {
+
+	all := []core.VolumeAttachment{}
+	req := core.ListVolumeAttachmentsRequest{CompartmentId: &c.compartment}
+	if volumeID != "" {
+		req.VolumeId = &volumeID
+	}
+	if instanceID != "" {
+		req.InstanceId = &instanceID
+	}
+	for {
+		ctx, cancel := requestContext()
+		res, err := c.compute.ListVolumeAttachments(ctx, req)
+		cancel()
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range res.Items {
+			switch a.GetLifecycleState() {
+			case core.VolumeAttachmentLifecycleStateAttaching,
+				core.VolumeAttachmentLifecycleStateAttached:
+				all = append(all, a)
+			}
+		}
+		if res.OpcNextPage == nil {
+			return all, nil
+		}
+		req.Page = res.OpcNextPage
+	}
+}
+
+// availableDevice returns the first of an instance's consistent device paths
+// that no volume is attached at.
+func (c *client) availableDevice(instanceID string) (string, error) {
+	ctx, cancel := requestContext()
+	defer cancel()
+	res, err := c.compute.ListInstanceDevices(ctx,
+		core.ListInstanceDevicesRequest{
+			InstanceId:  &instanceID,
+			IsAvailable: common.Bool(true),
+		})
+	if err != nil {
+		return "", err
+	}
+	for _, d := range res.Items {
+		if d.Name != nil {
+			return *d.Name, nil
+		}
+	}
+	return "", goof.WithField("instanceID", instanceID,
+		"instance has no available device paths")
+}
+
+// backupPolicyID returns the OCID of a backup policy, which may be the name
+// of an Oracle-defined policy, ex. gold, or of a policy in the compartment.
+func (c *client) backupPolicyID(policy string) (string, error) {
+	if strings.HasPrefix(policy, "ocid1.") {
+		return policy, nil
+	}
+	for _, compartment := range []*string{nil, &c.compartment} {
+		req := core.ListVolumeBackupPoliciesRequest{CompartmentId: compartment}
+		for {
+			ctx, cancel := requestContext()
+			res, err := c.storage.ListVolumeBackupPolicies(ctx, req)
+			cancel()
+			if err != nil {
+				return "", err
+			}
+			for _, p := range res.Items {
+				if p.DisplayName != nil && p.Id != nil &&
+					strings.EqualFold(*p.DisplayName, policy) {
+					return *p.Id, nil
+				}
+			}
+			if res.OpcNextPage == nil {
+				break
+			}
+			req.Page = res.OpcNextPage
+		}
+	}
+	return "", goof.WithField("backupPolicy", policy,
+		"backup policy not found")
+}
+
+// waitVolume polls a volume until it is available.
+func (c *client) waitVolume(id string) (*core.Volume, error) {
+	deadline := time.Now().Add(c.timeout)
+	for {
+		v, err := c.volume(id)
+		if err != nil {
+			return nil, err
+		}
+		switch v.LifecycleState {
+		case core.VolumeLifecycleStateAvailable:
+			return v, nil
+		case core.VolumeLifecycleStateFaulty:
+			return nil, goof.WithField("volumeID", id, "volume is faulty")
+		}
+		if time.Now().After(deadline) {
+			return nil, goof.WithFields(goof.Fields{
+				"volumeID": id,
+				"state":    v.LifecycleState,
+				"timeout":  c.timeout,
+			}, "timed out waiting for volume")
+		}
+		time.Sleep(2 * time.Second)
+	}
+}
+
+// waitAttachment polls an attachment until it is attached, or detached if
+// the flag is false.
+func (c *client) waitAttachment(
+	id string, attached bool) (core.VolumeAttachment, error) {
+
+	state := core.VolumeAttachmentLifecycleStateDetached
+	if attached {
+		state = core.VolumeAttachmentLifecycleStateAttached
+	}
+	deadline := time.Now().Add(c.timeout)
+	for {
+		ctx, cancel := requestContext()
+		res, err := c.compute.GetVolumeAttachment(ctx,
+			core.GetVolumeAttachmentRequest{VolumeAttachmentId: &id})
+		cancel()
+		if err != nil {
+			return nil, err
+		}
+		if res.VolumeAttachment.GetLifecycleState() == state {
+			return res.VolumeAttachment, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, goof.WithFields(goof.Fields{
+				"attachmentID": id,
+				"state":        res.VolumeAttachment.GetLifecycleState(),
+				"timeout":      c.timeout,
+			}, "timed out waiting for volume attachment")
+		}
+		time.Sleep(2 * time.Second)
+	}
+}
@@ -0,0 +1,527 @@
+package oci
+
+import (
+	"strconv"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	"github.com/emccode/libstorage/api/context"
+	apitypes "github.com/emccode/libstorage/api/types"
+	"github.com/oracle/oci-go-sdk/common"
+	"github.com/oracle/oci-go-sdk/core"
+)
+
+type driver struct {
+	config         gofig.Config
+	api            *client
+	ad             string
+	attachmentType string
+	backupPolicy   string
+	vpusPerGB      int64
+}
+
+func newDriver() apitypes.StorageDriver {
+	return &driver{}
+}
+
+func (d *driver) Name() string {
+	return Name
+}
+
+func (d *driver) Init(ctx apitypes.Context, config gofig.Config) error {
+	d.config = config
+	d.attachmentType = config.GetString("oci.attachmentType")
+	switch d.attachmentType {
+	case "":
+		d.attachmentType = AttachParavirtualized
+	case AttachParavirtualized, AttachISCSI:
+	default:
+		return goof.WithField("attachmentType", d.attachmentType,
+			"invalid oci attachment type")
+	}
+	api, err := newClient(config)
+	if err != nil {
+		return err
+	}
+	d.api = api
+	d.ad = config.GetString("oci.availabilityDomain")
+	d.backupPolicy = config.GetString("oci.backupPolicy")
+	d.vpusPerGB = int64(config.GetInt("oci.vpusPerGB"))
+
+	ctx.WithFields(map[string]interface{}{
+		"compartmentID":  d.api.compartment,
+		"attachmentType": d.attachmentType,
+		"backupPolicy":   d.backupPolicy,
+	}).Info("initialized oci driver")
+	return nil
+}
+
+// instanceID returns the OCID of the instance on whose behalf an operation
+// is performed.
+func instanceID(ctx apitypes.Context) string {
+	if iid, ok := ctx.Value(context.InstanceIDKey).(*apitypes.InstanceID); ok {
+		return iid.ID
+	}
+	return ""
+}
+
+func (d *driver) Type(ctx apitypes.Context) (apitypes.StorageType, error) {
+	return apitypes.Block, nil
+}
+
+// NextDeviceInfo returns nil because volumes are attached at the first of
+// an instance's consistent device paths that is available.
+func (d *driver) NextDeviceInfo(
+	ctx apitypes.Context) (*apitypes.NextDeviceInfo, error) {
+
+	return nil, nil
+}
+
+func (d *driver) InstanceInspect(
+	ctx apitypes.Context,
+	opts apitypes.Store) (*apitypes.Instance, error) {
+
+	iid, ok := ctx.Value(context.InstanceIDKey).(*apitypes.InstanceID)
+	if !ok {
+		return nil, goof.New("missing instance ID")
+	}
+	i, err := d.api.instance(iid.ID)
+	if err != nil {
+		return nil, goof.WithFieldE("instanceID", iid.ID,
+			"error inspecting instance", err)
+	}
+	return &apitypes.Instance{
+		InstanceID: iid,
+		Name:       str(i.DisplayName),
+		Region:     str(i.Region),
+	}, nil
+}
+
+func str(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// toVolume returns a libStorage volume for a block volume. The volume's
+// availability zone is its availability domain, and the device of an
+// attachment to the instance in the operation's context is looked up by the
+// attachment's consistent device path.
+func (d *driver) toVolume(
+	ctx apitypes.Context,
+	v *core.Volume,
+	atts []core.VolumeAttachment) *apitypes.Volume {
+
+	vol := &apitypes.Volume{
+		ID:               str(v.Id),
+		Name:             str(v.DisplayName),
+		AvailabilityZone: str(v.AvailabilityDomain),
+		Type:             Name,
+		Status:           string(v.LifecycleState),
+		Fields:           map[string]string{},
+	}
+	if v.SizeInGBs != nil {
+		vol.Size = *v.SizeInGBs
+	}
+	if v.VpusPerGB != nil {
+		vol.Fields["vpusPerGB"] = strconv.FormatInt(*v.VpusPerGB, 10)
+	}
+
+	iid := instanceID(ctx)
+	ld, _ := ctx.Value(context.LocalDevicesKey).(*apitypes.LocalDevices)
+	for _, a := range atts {
+		if str(a.GetVolumeId()) != vol.ID {
+			continue
+		}
+		att := &apitypes.VolumeAttachment{
+			VolumeID: vol.ID,
+			InstanceID: &apitypes.InstanceID{
+				ID:     str(a.GetInstanceId()),
+				Driver: Name,
+			},
+			Status: string(a.GetLifecycleState()),
+			Fields: map[string]string{
+				"attachmentID": str(a.GetId()),
+				"device":       str(a.GetDevice()),
+			},
+		}
+		if _, ok := a.(core.IScsiVolumeAttachment); ok {
+			att.Fields["attachmentType"] = AttachISCSI
+		} else {
+			att.Fields["attachmentType"] = AttachParavirtualized
+		}
+		if att.InstanceID.ID == iid && ld != nil {
+			att.DeviceName = ld.DeviceMap[str(a.GetDevice())]
+		}
+		vol.Attachments = append(vol.Attachments, att)
+	}
+	return vol
+}
+
+func (d *driver) Volumes(
+	ctx apitypes.Context,
+	opts *apitypes.VolumesOpts) ([]*apitypes.Volume, error) {
+
+	vols, err := d.api.volumes()
+	if err != nil {
+		return nil, err
+	}
+	var atts []core.VolumeAttachment
+	if opts.Attachments {
+		if atts, err = d.api.attachments("", ""); err != nil {
+			return nil, err
+		}
+	}
+	all := []*apitypes.Volume{}
+	for i := range vols {
+		all = append(all, d.toVolume(ctx, &vols[i], atts))
+	}
+	return all, nil
+}
+
+func (d *driver) VolumeInspect(
+	ctx apitypes.Context,
+	volumeID string,
+	opts *apitypes.VolumeInspectOpts) (*apitypes.Volume, error) {
+
+	return d.inspect(ctx, volumeID, opts.Attachments)
+}
+
+func (d *driver) inspect(
+	ctx apitypes.Context,
+	volumeID string,
+	attachments bool) (*apitypes.Volume, error) {
+
+	v, err := d.api.volume(volumeID)
+	if isNotFound(err) {
+		return nil, goof.WithField("volumeID", volumeID, "volume not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	var atts []core.VolumeAttachment
+	if attachments {
+		if atts, err = d.api.attachments(volumeID, ""); err != nil {
+			return nil, err
+		}
+	}
+	return d.toVolume(ctx, v, atts), nil
+}
+
+// createAvailabilityDomain returns the availability domain in which a volume
+// is created: the requested availability zone, or else the availability
+// domain of the instance on whose behalf the volume is created, or else the
+// configured availability domain.
+func (d *driver) createAvailabilityDomain(
+	ctx apitypes.Context, opts *apitypes.VolumeCreateOpts) (string, error) {
+
+	if opts.AvailabilityZone != nil && *opts.AvailabilityZone != "" {
+		return *opts.AvailabilityZone, nil
+	}
+	if iid := instanceID(ctx); iid != "" {
+		i, err := d.api.instance(iid)
+		if err != nil {
+			return "", goof.WithFieldE("instanceID", iid,
+				"error inspecting instance", err)
+		}
+		return str(i.AvailabilityDomain), nil
+	}
+	if d.ad != "" {
+		return d.ad, nil
+	}
+	return "", goof.New("oci volumes require an availability domain: the " +
+		"availability zone, the instance's, or oci.availabilityDomain")
+}
+
+// VolumeCreate creates a volume in the availability domain of the instance
+// on whose behalf it is created, unless another one is requested, and
+// assigns it the requested or configured backup policy.
+func (d *driver) VolumeCreate(
+	ctx apitypes.Context,
+	name string,
+	opts *apitypes.VolumeCreateOpts) (*apitypes.Volume, error) {
+
+	return d.create(ctx, name, opts, nil)
+}
+
+func (d *driver) create(
+	ctx apitypes.Context,
+	name string,
+	opts *apitypes.VolumeCreateOpts,
+	source core.VolumeSourceDetails) (*apitypes.Volume, error) {
+
+	ad, err := d.createAvailabilityDomain(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	policy, vpus := d.backupPolicy, d.vpusPerGB
+	if opts.Opts != nil {
+		if p := opts.Opts.GetString(OptBackupPolicy); p != "" {
+			policy = p
+		}
+		if s := opts.Opts.GetString(OptVPUsPerGB); s != "" {
+			if vpus, err = strconv.ParseInt(s, 10, 64); err != nil {
+				return nil, goof.WithField(OptVPUsPerGB, s,
+					"invalid volume performance units per GB")
+			}
+		}
+	}
+	var policyID string
+	if policy != "" {
+		if policyID, err = d.api.backupPolicyID(policy); err != nil {
+			return nil, err
+		}
+	}
+
+	details := core.CreateVolumeDetails{
+		CompartmentId:      &d.api.compartment,
+		AvailabilityDomain: &ad,
+		DisplayName:        &name,
+		SourceDetails:      source,
+	}
+	if source == nil {
+		size := int64(defaultSize)
+		if opts.Size != nil && *opts.Size > 0 {
+			size = *opts.Size
+		}
+		details.SizeInGBs = &size
+	}
+	if vpus > 0 {
+		details.VpusPerGB = &vpus
+	}
+
+	rctx, cancel := requestContext()
+	res, err := d.api.storage.CreateVolume(
+		rctx, core.CreateVolumeRequest{CreateVolumeDetails: details})
+	cancel()
+	if err != nil {
+		return nil, goof.WithFieldsE(goof.Fields{
+			"name":               name,
+			"availabilityDomain": ad,
+		}, "error creating volume", err)
+	}
+	v, err := d.api.waitVolume(str(res.Volume.Id))
+	if err != nil {
+		return nil, err
+	}
+
+	if policyID != "" {
+		assignment := core.CreateVolumeBackupPolicyAssignmentDetails{
+			AssetId:  v.Id,
+			PolicyId: &policyID,
+		}
+		rctx, cancel := requestContext()
+		_, err := d.api.storage.CreateVolumeBackupPolicyAssignment(rctx,
+			core.CreateVolumeBackupPolicyAssignmentRequest{
+				CreateVolumeBackupPolicyAssignmentDetails: assignment,
+			})
+		cancel()
+		if err != nil {
+			return nil, goof.WithFieldsE(goof.Fields{
+				"volumeID":     str(v.Id),
+				"backupPolicy": policy,
+			}, "error assigning backup policy", err)
+		}
+		ctx.WithFields(map[string]interface{}{
+			"volumeID":     str(v.Id),
+			"backupPolicy": policy,
+		}).Info("assigned backup policy")
+	}
+	return d.toVolume(ctx, v, nil), nil
+}
+
+func (d *driver) VolumeCreateFromSnapshot(
+	ctx apitypes.Context,
+	snapshotID, volumeName string,
+	opts *apitypes.VolumeCreateOpts) (*apitypes.Volume, error) {
+
+	return nil, apitypes.ErrNotImplemented
+}
+
+// VolumeCopy clones a volume into a new volume in the same availability
+// domain.
+func (d *driver) VolumeCopy(
+	ctx apitypes.Context,
+	volumeID, volumeName string,
+	opts apitypes.Store) (*apitypes.Volume, error) {
+
+	v, err := d.api.volume(volumeID)
+	if err != nil {
+		return nil, err
+	}
+	ad := str(v.AvailabilityDomain)
+	return d.create(ctx, volumeName, &apitypes.VolumeCreateOpts{
+		AvailabilityZone: &ad,
+		Opts:             opts,
+	}, core.VolumeSourceFromVolumeDetails{Id: v.Id})
+}
+
+func (d *driver) VolumeSnapshot(
+	ctx apitypes.Context,
+	volumeID, snapshotName string,
+	opts apitypes.Store) (*apitypes.Snapshot, error) {
+
+	return nil, apitypes.ErrNotImplemented
+}
+
+func (d *driver) VolumeRemove(
+	ctx apitypes.Context,
+	volumeID string,
+	opts apitypes.Store) error {
+
+	rctx, cancel := requestContext()
+	defer cancel()
+	if _, err := d.api.storage.DeleteVolume(rctx,
+		core.DeleteVolumeRequest{VolumeId: &volumeID}); err != nil {
+		return goof.WithFieldE("volumeID", volumeID,
+			"error removing volume", err)
+	}
+	return nil
+}
+
+// VolumeAttach attaches a volume to the instance at the first of its
+// consistent device paths that is available, which is the returned token. A
+// forced attach first detaches the volume from other instances.
+func (d *driver) VolumeAttach(
+	ctx apitypes.Context,
+	volumeID string,
+	opts *apitypes.VolumeAttachOpts) (*apitypes.Volume, string, error) {
+
+	iid := instanceID(ctx)
+	if iid == "" {
+		return nil, "", goof.New("missing instance ID")
+	}
+	atts, err := d.api.attachments(volumeID, "")
+	if err != nil {
+		return nil, "", err
+	}
+	for _, a := range atts {
+		if str(a.GetInstanceId()) == iid {
+			if _, err := d.api.waitAttachment(str(a.GetId()), true); err != nil {
+				return nil, "", err
+			}
+			vol, err := d.inspect(ctx, volumeID, true)
+			return vol, str(a.GetDevice()), err
+		}
+		if !opts.Force {
+			return nil, "", goof.WithFields(goof.Fields{
+				"volumeID":   volumeID,
+				"instanceID": str(a.GetInstanceId()),
+			}, "volume is attached to another instance")
+		}
+		if err := d.detach(a); err != nil {
+			return nil, "", err
+		}
+	}
+
+	device, err := d.api.availableDevice(iid)
+	if err != nil {
+		return nil, "", err
+	}
+	var details core.AttachVolumeDetails
+	if d.attachmentType == AttachISCSI {
+		details = core.AttachIScsiVolumeDetails{
+			InstanceId: &iid,
+			VolumeId:   &volumeID,
+			Device:     &device,
+			UseChap:    common.Bool(false),
+		}
+	} else {
+		details = core.AttachParavirtualizedVolumeDetails{
+			InstanceId: &iid,
+			VolumeId:   &volumeID,
+			Device:     &device,
+		}
+	}
+
+	rctx, cancel := requestContext()
+	res, err := d.api.compute.AttachVolume(
+		rctx, core.AttachVolumeRequest{AttachVolumeDetails: details})
+	cancel()
+	if err != nil {
+		return nil, "", goof.WithFieldsE(goof.Fields{
+			"volumeID":   volumeID,
+			"instanceID": iid,
+			"device":     device,
+		}, "error attaching volume", err)
+	}
+	if _, err := d.api.waitAttachment(
+		str(res.VolumeAttachment.GetId()), true); err != nil {
+		return nil, "", err
+	}
+
+	vol, err := d.inspect(ctx, volumeID, true)
+	if err != nil {
+		return nil, "", err
+	}
+	return vol, device, nil
+}
+
+// VolumeDetach detaches a volume from the instance, or from all instances
+// if the detach is forced.
+func (d *driver) VolumeDetach(
+	ctx apitypes.Context,
+	volumeID string,
+	opts *apitypes.VolumeDetachOpts) (*apitypes.Volume, error) {
+
+	iid := instanceID(ctx)
+	atts, err := d.api.attachments(volumeID, "")
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range atts {
+		if str(a.GetInstanceId()) == iid || opts.Force {
+			if err := d.detach(a); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return d.inspect(ctx, volumeID, true)
+}
+
+func (d *driver) detach(a core.VolumeAttachment) error {
+	rctx, cancel := requestContext()
+	_, err := d.api.compute.DetachVolume(rctx,
+		core.DetachVolumeRequest{VolumeAttachmentId: a.GetId()})
+	cancel()
+	if err != nil {
+		return goof.WithFieldsE(goof.Fields{
+			"volumeID":   str(a.GetVolumeId()),
+			"instanceID": str(a.GetInstanceId()),
+		}, "error detaching volume", err)
+	}
+	_, err = d.api.waitAttachment(str(a.GetId()), false)
+	return err
+}
+
+func (d *driver) Snapshots(
+	ctx apitypes.Context,
+	opts apitypes.Store) ([]*apitypes.Snapshot, error) {
+
+	return nil, apitypes.ErrNotImplemented
+}
+
+func (d *driver) SnapshotInspect(
+	ctx apitypes.Context,
+	snapshotID string,
+	opts apitypes.Store) (*apitypes.Snapshot, error) {
+
+	return nil, apitypes.ErrNotImplemented
+}
+
+func (d *driver) SnapshotCopy(
+	ctx apitypes.Context,
+	snapshotID, snapshotName, destinationID string,
+	opts apitypes.Store) (*apitypes.Snapshot, error) {
+
+	return nil, apitypes.ErrNotImplemented
+}
+
+func (d *driver) SnapshotRemove(
+	ctx apitypes.Context,
+	snapshotID string,
+	opts apitypes.Store) error {
+
+	return apitypes.ErrNotImplemented
+}
@@ -0,0 +1,106 @@
+package oci
+
+import (
+	"github.com/akutz/gofig"
+	apitypes "github.com/emccode/libstorage/api/types"
+	"github.com/oracle/oci-go-sdk/core"
+)
+
+// executor runs on each node. A node's instance ID is the OCID of its
+// instance, which is read from the instance metadata service.
+type executor struct {
+	config gofig.Config
+	api    *client
+}
+
+func newExecutor() apitypes.StorageExecutor {
+	return &executor{}
+}
+
+func (e *executor) Name() string {
+	return Name
+}
+
+func (e *executor) Init(ctx apitypes.Context, config gofig.Config) error {
+	e.config = config
+	return nil
+}
+
+func (e *executor) InstanceID(
+	ctx apitypes.Context,
+	opts apitypes.Store) (*apitypes.InstanceID, error) {
+
+	md, err := localInstance()
+	if err != nil {
+		return nil, err
+	}
+	return &apitypes.InstanceID{ID: md.ID, Driver: Name}, nil
+}
+
+func (e *executor) NextDevice(
+	ctx apitypes.Context,
+	opts apitypes.Store) (string, error) {
+
+	return "", apitypes.ErrNotImplemented
+}
+
+// LocalDevices returns the devices of the volumes attached to the node keyed
+// by their consistent device paths. When volumes are attached as iSCSI
+// targets a deep scan first logs in to the targets of the instance's
+// attachments and out of the targets of attachments that were removed.
+func (e *executor) LocalDevices(
+	ctx apitypes.Context,
+	opts *apitypes.LocalDevicesOpts) (*apitypes.LocalDevices, error) {
+
+	if opts.ScanType == apitypes.DeviceScanDeep &&
+		e.config.GetString("oci.attachmentType") == AttachISCSI {
+		targets, err := e.iscsiTargets()
+		if err != nil {
+			return nil, err
+		}
+		if err := syncTargets(ctx, targets); err != nil {
+			return nil, err
+		}
+	}
+
+	devs, err := devices(deviceDir)
+	if err != nil {
+		return nil, err
+	}
+	return &apitypes.LocalDevices{Driver: Name, DeviceMap: devs}, nil
+}
+
+// iscsiTargets returns the targets of the node's attached iSCSI
+// attachments.
+func (e *executor) iscsiTargets() ([]*target, error) {
+	md, err := localInstance()
+	if err != nil {
+		return nil, err
+	}
+	if e.api == nil {
+		if e.api, err = newClient(e.config); err != nil {
+			return nil, err
+		}
+	}
+	atts, err := e.api.attachments("", md.ID)
+	if err != nil {
+		return nil, err
+	}
+	targets := []*target{}
+	for _, a := range atts {
+		ia, ok := a.(core.IScsiVolumeAttachment)
+		if !ok || ia.LifecycleState !=
+			core.VolumeAttachmentLifecycleStateAttached {
+			continue
+		}
+		port := 3260
+		if ia.Port != nil {
+			port = *ia.Port
+		}
+		targets = append(targets, &target{
+			IQN:    str(ia.Iqn),
+			Portal: portal(str(ia.Ipv4), port),
+		})
+	}
+	return targets, nil
+}
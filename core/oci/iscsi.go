@@ -0,0 +1,55 @@
+package oci
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/emccode/rexray/core/openiscsi"
+)
+
+// target is the iSCSI target of an attachment.
+type target struct {
+	IQN    string
+	Portal string
+}
+
+func portal(ip string, port int) string {
+	return ip + ":" + strconv.Itoa(port)
+}
+
+// attachmentSessions returns the node's sessions with the targets of
+// attachments, whose IQNs begin with iqnPrefix.
+func attachmentSessions(all []*openiscsi.Session) []*target {
+	sessions := []*target{}
+	for _, s := range all {
+		if strings.HasPrefix(s.Target, iqnPrefix) {
+			sessions = append(sessions,
+				&target{IQN: s.Target, Portal: s.Portal})
+		}
+	}
+	return sessions
+}
+
+// diffTargets returns the targets the node has no session with and the
+// sessions whose targets are no longer attached.
+func diffTargets(targets, sessions []*target) ([]*target, []*target) {
+	active := map[string]bool{}
+	for _, s := range sessions {
+		active[s.IQN] = true
+	}
+	attached := map[string]bool{}
+	login := []*target{}
+	for _, t := range targets {
+		attached[t.IQN] = true
+		if !active[t.IQN] {
+			login = append(login, t)
+		}
+	}
+	logout := []*target{}
+	for _, s := range sessions {
+		if !attached[s.IQN] {
+			logout = append(logout, s)
+		}
+	}
+	return login, logout
+}
@@ -0,0 +1,47 @@
+package oci
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/akutz/goof"
+)
+
+const metadataURL = "http://169.254.169.254/opc/v2/instance/"
+
+// instanceMetadata is the subset of an instance's metadata that the driver
+// uses, as returned by the instance metadata service.
+type instanceMetadata struct {
+	ID                 string `json:"id"`
+	CompartmentID      string `json:"compartmentId"`
+	AvailabilityDomain string `json:"availabilityDomain"`
+	Region             string `json:"canonicalRegionName"`
+}
+
+// localInstance returns the metadata of the instance REX-Ray runs on.
+func localInstance() (*instanceMetadata, error) {
+	req, err := http.NewRequest("GET", metadataURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer Oracle")
+
+	res, err := (&http.Client{Timeout: 2 * time.Second}).Do(req)
+	if err != nil {
+		return nil, goof.WithFieldE("url", metadataURL,
+			"error reading instance metadata", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, goof.WithField("status", res.StatusCode,
+			"error reading instance metadata")
+	}
+
+	md := &instanceMetadata{}
+	if err := json.NewDecoder(res.Body).Decode(md); err != nil {
+		return nil, goof.WithFieldE("url", metadataURL,
+			"error decoding instance metadata", err)
+	}
+	return md, nil
+}
@@ -0,0 +1,50 @@
+package oci
+
+import (
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/core/openiscsi"
+)
+
+// sessions returns the node's sessions with the targets of attachments.
+func sessions() ([]*target, error) {
+	all, err := openiscsi.Sessions()
+	if err != nil {
+		return nil, err
+	}
+	return attachmentSessions(all), nil
+}
+
+// syncTargets logs in to the targets the node has no session with, as OCI
+// documents for iSCSI attachments, and logs out of and forgets the targets
+// of attachments that were removed.
+func syncTargets(ctx apitypes.Context, targets []*target) error {
+	active, err := sessions()
+	if err != nil {
+		return err
+	}
+	login, logout := diffTargets(targets, active)
+
+	for _, t := range login {
+		for _, args := range [][]string{
+			{"-m", "node", "-o", "new", "-T", t.IQN, "-p", t.Portal},
+			{"-m", "node", "-o", "update", "-T", t.IQN,
+				"-n", "node.startup", "-v", "automatic"},
+		} {
+			if _, err := openiscsi.Run(args...); err != nil {
+				return err
+			}
+		}
+		if err := openiscsi.LoginTarget(ctx, t.IQN, t.Portal); err != nil {
+			return err
+		}
+	}
+
+	for _, t := range logout {
+		if err := openiscsi.LogoutTarget(
+			ctx, t.IQN, t.Portal, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,12 @@
+// +build !linux
+
+package oci
+
+import (
+	"github.com/akutz/goof"
+	apitypes "github.com/emccode/libstorage/api/types"
+)
+
+func syncTargets(ctx apitypes.Context, targets []*target) error {
+	return goof.New("oci iscsi attachments are only supported on Linux")
+}
@@ -0,0 +1,173 @@
+// Package oci is a storage driver for Oracle Cloud Infrastructure block
+// volumes. Volumes are attached to instances as paravirtualized or iSCSI
+// attachments at the instances' consistent device paths, ex.
+// /dev/oracleoci/oraclevdb, and nodes log in to the targets of iSCSI
+// attachments with iscsiadm. The driver authenticates as the instance it runs
+// on with instance principals, or with an API signing key.
+package oci
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	"github.com/emccode/libstorage/api/registry"
+	"github.com/oracle/oci-go-sdk/common"
+	"github.com/oracle/oci-go-sdk/common/auth"
+)
+
+const (
+	// Name is the name with which the driver is registered.
+	Name = "oci"
+
+	// AuthInstancePrincipal authenticates as the instance REX-Ray runs on.
+	AuthInstancePrincipal = "instancePrincipal"
+
+	// AuthAPIKey authenticates as a user with an API signing key.
+	AuthAPIKey = "apiKey"
+
+	// AttachParavirtualized attaches volumes as paravirtualized devices.
+	AttachParavirtualized = "paravirtualized"
+
+	// AttachISCSI attaches volumes as iSCSI targets.
+	AttachISCSI = "iscsi"
+
+	// OptBackupPolicy is the create option with which a volume's backup
+	// policy is requested.
+	OptBackupPolicy = "backupPolicy"
+
+	// OptVPUsPerGB is the create option with which a volume's performance,
+	// in volume performance units per GB, is requested.
+	OptVPUsPerGB = "vpusPerGB"
+
+	defaultSize = 50
+
+	// deviceDir is the directory of the instances' consistent device paths.
+	deviceDir = "/dev/oracleoci"
+
+	// iqnPrefix is the prefix of the IQNs of the targets of iSCSI
+	// attachments.
+	iqnPrefix = "iqn.2015-12.com.oracleiaas:"
+)
+
+func init() {
+	registry.RegisterStorageDriver(Name, newDriver)
+	registry.RegisterStorageExecutor(Name, newExecutor)
+
+	r := gofig.NewRegistration("OCI Driver")
+	r.Key(gofig.String, "", AuthInstancePrincipal,
+		"How the driver authenticates: instancePrincipal or apiKey",
+		"oci.auth")
+	r.Key(gofig.String, "", "",
+		"The OCID of the tenancy of the user with the API signing key",
+		"oci.tenancyID")
+	r.Key(gofig.String, "", "",
+		"The OCID of the user with the API signing key",
+		"oci.userID")
+	r.Key(gofig.String, "", "",
+		"The fingerprint of the API signing key",
+		"oci.fingerprint")
+	r.Key(gofig.String, "", "",
+		"The path of the PEM file of the API signing key",
+		"oci.privateKey")
+	r.Key(gofig.String, "", "",
+		"The passphrase of the API signing key",
+		"oci.privateKeyPassphrase")
+	r.Key(gofig.String, "", "",
+		"The region; defaults to the instance's region",
+		"oci.region")
+	r.Key(gofig.String, "", "",
+		"The OCID of the compartment of volumes; defaults to the instance's "+
+			"compartment",
+		"oci.compartmentID")
+	r.Key(gofig.String, "", "",
+		"The availability domain of volumes created without an instance",
+		"oci.availabilityDomain")
+	r.Key(gofig.String, "", AttachParavirtualized,
+		"How volumes are attached: paravirtualized or iscsi",
+		"oci.attachmentType")
+	r.Key(gofig.String, "", "",
+		"The name or OCID of the backup policy assigned to new volumes",
+		"oci.backupPolicy")
+	r.Key(gofig.Int, "", 0,
+		"The volume performance units per GB of new volumes; defaults to "+
+			"the balanced performance of 10",
+		"oci.vpusPerGB")
+	r.Key(gofig.String, "", "5m",
+		"How long to wait for a volume to be created, attached, or detached",
+		"oci.timeout")
+	gofig.Register(r)
+}
+
+// configProvider returns the provider of the credentials with which the API
+// is called.
+func configProvider(config gofig.Config) (common.ConfigurationProvider, error) {
+	switch a := config.GetString("oci.auth"); a {
+	case "", AuthInstancePrincipal:
+		p, err := auth.InstancePrincipalConfigurationProvider()
+		if err != nil {
+			return nil, goof.WithFieldE("auth", AuthInstancePrincipal,
+				"error authenticating to oci", err)
+		}
+		return p, nil
+	case AuthAPIKey:
+		path := config.GetString("oci.privateKey")
+		key, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, goof.WithFieldE("path", path,
+				"error reading oci api signing key", err)
+		}
+		var passphrase *string
+		if p := config.GetString("oci.privateKeyPassphrase"); p != "" {
+			passphrase = &p
+		}
+		return common.NewRawConfigurationProvider(
+			config.GetString("oci.tenancyID"),
+			config.GetString("oci.userID"),
+			config.GetString("oci.region"),
+			config.GetString("oci.fingerprint"),
+			string(key),
+			passphrase), nil
+	default:
+		return nil, goof.WithField("auth", a, "invalid oci auth")
+	}
+}
+
+// isDevicePath returns a flag indicating whether a file in the directory of
+// consistent device paths is a device rather than a partition of one, ex.
+// oraclevdb rather than oraclevdb1.
+func isDevicePath(name string) bool {
+	if !strings.HasPrefix(name, "oraclevd") || len(name) <= len("oraclevd") {
+		return false
+	}
+	last := name[len(name)-1]
+	return last < '0' || last > '9'
+}
+
+// devices returns the devices of the volumes attached to the node keyed by
+// their consistent device paths.
+func devices(dir string) (map[string]string, error) {
+	devs := map[string]string{}
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return devs, nil
+		}
+		return nil, err
+	}
+	for _, fi := range fis {
+		if !isDevicePath(fi.Name()) {
+			continue
+		}
+		path := filepath.Join(dir, fi.Name())
+		dev, err := filepath.EvalSymlinks(path)
+		if err != nil {
+			continue
+		}
+		devs[path] = dev
+	}
+	return devs, nil
+}
@@ -0,0 +1,74 @@
+package oci
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/emccode/rexray/core/openiscsi"
+)
+
+func TestIsDevicePath(t *testing.T) {
+	for name, ok := range map[string]bool{
+		"oraclevdb":  true,
+		"oraclevdab": true,
+		"oraclevdb1": false,
+		"oraclevd":   false,
+		"sda":        false,
+	} {
+		if isDevicePath(name) != ok {
+			t.Errorf("isDevicePath(%s)=%v", name, !ok)
+		}
+	}
+}
+
+func TestDevices(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oci")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	dev := filepath.Join(dir, "sdb")
+	if err := ioutil.WriteFile(dev, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"oraclevdb", "oraclevdb1"} {
+		if err := os.Symlink(dev, filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	devs, err := devices(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := map[string]string{filepath.Join(dir, "oraclevdb"): dev}
+	if !reflect.DeepEqual(devs, exp) {
+		t.Fatalf("devs=%v", devs)
+	}
+}
+
+func TestSyncTargets(t *testing.T) {
+	sessions := attachmentSessions(openiscsi.ParseSessions(strings.NewReader(
+		"tcp: [1] 169.254.2.2:3260,1 iqn.2015-12.com.oracleiaas:aaa (non-flash)\n" +
+			"tcp: [2] 169.254.2.3:3260,1 iqn.2015-12.com.oracleiaas:bbb (non-flash)\n" +
+			"tcp: [3] 10.0.0.1:3260,1 iqn.2003-01.org.linux-iscsi.san:t (non-flash)\n")))
+	if len(sessions) != 2 || sessions[0].Portal != "169.254.2.2:3260" {
+		t.Fatalf("sessions=%v", sessions)
+	}
+
+	login, logout := diffTargets([]*target{
+		{IQN: iqnPrefix + "aaa", Portal: portal("169.254.2.2", 3260)},
+		{IQN: iqnPrefix + "ccc", Portal: portal("169.254.2.4", 3260)},
+	}, sessions)
+	if len(login) != 1 || login[0].IQN != iqnPrefix+"ccc" {
+		t.Fatalf("login=%v", login)
+	}
+	if len(logout) != 1 || logout[0].IQN != iqnPrefix+"bbb" {
+		t.Fatalf("logout=%v", logout)
+	}
+}
@@ -0,0 +1,388 @@
+package ontap
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+
+	"github.com/emccode/rexray/core/errcodes"
+)
+
+// ref is a reference to another object by name, as used by the API.
+type ref struct {
+	Name string `json:"name,omitempty"`
+	UUID string `json:"uuid,omitempty"`
+}
+
+// flexVol is a FlexVol volume, as returned by the API.
+type flexVol struct {
+	UUID           string `json:"uuid"`
+	Name           string `json:"name"`
+	Size           int64  `json:"size"`
+	State          string `json:"state"`
+	Comment        string `json:"comment"`
+	SnapshotPolicy *ref   `json:"snapshot_policy"`
+	QoS            *struct {
+		Policy *ref `json:"policy"`
+	} `json:"qos"`
+	NAS *struct {
+		Path         string `json:"path"`
+		ExportPolicy *struct {
+			ID   int64  `json:"id"`
+			Name string `json:"name"`
+		} `json:"export_policy"`
+	} `json:"nas"`
+}
+
+const volumeFields = "uuid,name,size,state,comment,snapshot_policy.name," +
+	"qos.policy.name,nas.path,nas.export_policy.id,nas.export_policy.name"
+
+// lun is a LUN, as returned by the API.
+type lun struct {
+	UUID  string `json:"uuid"`
+	Name  string `json:"name"`
+	Space *struct {
+		Size int64 `json:"size"`
+	} `json:"space"`
+}
+
+// lunMap is the mapping of a LUN to an initiator group.
+type lunMap struct {
+	LUN    ref `json:"lun"`
+	IGroup ref `json:"igroup"`
+	Number int `json:"logical_unit_number"`
+}
+
+// igroup is an initiator group, as returned by the API.
+type igroup struct {
+	UUID       string `json:"uuid"`
+	Name       string `json:"name"`
+	Initiators []ref  `json:"initiators"`
+}
+
+// exportRule is a rule of an export policy.
+type exportRule struct {
+	Index   int64 `json:"index"`
+	Clients []struct {
+		Match string `json:"match"`
+	} `json:"clients"`
+}
+
+// apiError is an error returned by the API.
+type apiError struct {
+	status  int
+	code    string
+	message string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("ontap: %d %s (%s)", e.status, e.message, e.code)
+}
+
+// Code returns the code of the error's HTTP status.
+func (e *apiError) Code() errcodes.Code {
+	return errcodes.FromStatus(e.status)
+}
+
+// MarshalJSON marshals the error as its message and code, since the
+// libStorage server reports a driver's error as the error's JSON.
+func (e *apiError) MarshalJSON() ([]byte, error) {
+	return errcodes.Marshal(e)
+}
+
+// isNotFound returns a flag indicating whether the error is that of a
+// request for an object that does not exist.
+func isNotFound(err error) bool {
+	e, ok := err.(*apiError)
+	return ok && e.status == http.StatusNotFound
+}
+
+// client calls the ONTAP REST API of a cluster or SVM management interface
+// with basic authentication.
+type client struct {
+	endpoint string
+	username string
+	password string
+	svm      string
+	timeout  time.Duration
+	client   *http.Client
+}
+
+func newClient(config gofig.Config) (*client, error) {
+	endpoint := strings.TrimSuffix(config.GetString("ontap.endpoint"), "/")
+	if endpoint == "" {
+		return nil, goof.New("ontap driver requires ontap.endpoint")
+	}
+	svm := config.GetString("ontap.svm")
+	if svm == "" {
+		return nil, goof.New("ontap driver requires ontap.svm")
+	}
+	c := &client{
+		endpoint: endpoint + "/api",
+		username: config.GetString("ontap.username"),
+		password: config.GetString("ontap.password"),
+		svm:      svm,
+		timeout:  2 * time.Minute,
+		client:   &http.Client{Timeout: 60 * time.Second},
+	}
+	if t, err := time.ParseDuration(
+		config.GetString("ontap.timeout")); err == nil && t > 0 {
+		c.timeout = t
+	}
+	if config.GetBool("ontap.insecure") {
+		c.client.Transport = &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		}
+	}
+	return c, nil
+}
+
+func (c *client) do(method, path string, body, result interface{}) error {
+	var buf []byte
+	if body != nil {
+		var err error
+		if buf, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequest(method, c.endpoint+path, bytes.NewReader(buf))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(c.username, c.password)
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return goof.WithFieldE("path", path, "error calling ontap", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		e := &apiError{status: res.StatusCode}
+		errs := &struct {
+			Error struct {
+				Code    string `json:"code"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}{}
+		json.NewDecoder(res.Body).Decode(errs)
+		e.code, e.message = errs.Error.Code, errs.Error.Message
+		return e
+	}
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(result)
+}
+
+// job is the asynchronous job with which the API performs some requests.
+type job struct {
+	Job *struct {
+		UUID string `json:"uuid"`
+	} `json:"job"`
+}
+
+// doJob performs a request and waits for the job with which the API
+// performs it to complete.
+func (c *client) doJob(method, path string, body interface{}) error {
+	j := &job{}
+	if err := c.do(method, path, body, j); err != nil {
+		return err
+	}
+	if j.Job == nil {
+		return nil
+	}
+	deadline := time.Now().Add(c.timeout)
+	for {
+		s := &struct {
+			State   string `json:"state"`
+			Message string `json:"message"`
+		}{}
+		if err := c.do("GET", "/cluster/jobs/"+j.Job.UUID+
+			"?fields=state,message", nil, s); err != nil {
+			return err
+		}
+		switch s.State {
+		case "success":
+			return nil
+		case "failure":
+			return goof.WithFields(goof.Fields{
+				"path": path,
+				"job":  j.Job.UUID,
+			}, "ontap job failed: "+s.Message)
+		}
+		if time.Now().After(deadline) {
+			return goof.WithFields(goof.Fields{
+				"path":    path,
+				"job":     j.Job.UUID,
+				"state":   s.State,
+				"timeout": c.timeout,
+			}, "timed out waiting for ontap job")
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// records returns all of the records of a collection, following the links
+// to the collection's next pages.
+func (c *client) records(path string, q url.Values, all interface{}) error {
+	q.Set("svm.name", c.svm)
+	next := path + "?" + q.Encode()
+	raw := []json.RawMessage{}
+	for next != "" {
+		res := &struct {
+			Records []json.RawMessage `json:"records"`
+			Links   struct {
+				Next *struct {
+					Href string `json:"href"`
+				} `json:"next"`
+			} `json:"_links"`
+		}{}
+		if err := c.do("GET", next, nil, res); err != nil {
+			return err
+		}
+		raw = append(raw, res.Records...)
+		next = ""
+		if res.Links.Next != nil {
+			next = strings.TrimPrefix(res.Links.Next.Href, "/api")
+		}
+	}
+	buf, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(buf, all)
+}
+
+// volumes returns the SVM's FlexVols whose names start with the prefix.
+func (c *client) volumes(prefix string) ([]*flexVol, error) {
+	vols := []*flexVol{}
+	err := c.records("/storage/volumes", url.Values{
+		"name":   {prefix + "*"},
+		"fields": {volumeFields},
+	}, &vols)
+	return vols, err
+}
+
+// volumeByName returns the SVM's FlexVol with the name.
+func (c *client) volumeByName(name string) (*flexVol, error) {
+	vols := []*flexVol{}
+	if err := c.records("/storage/volumes", url.Values{
+		"name":   {name},
+		"fields": {volumeFields},
+	}, &vols); err != nil {
+		return nil, err
+	}
+	if len(vols) == 0 {
+		return nil, &apiError{
+			status:  http.StatusNotFound,
+			message: "volume not found: " + name,
+		}
+	}
+	return vols[0], nil
+}
+
+func (c *client) volume(uuid string) (*flexVol, error) {
+	v := &flexVol{}
+	if err := c.do("GET", "/storage/volumes/"+uuid+"?fields="+
+		url.QueryEscape(volumeFields), nil, v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// lun returns the LUN of a FlexVol, which is its only LUN.
+func (c *client) lun(volumeName string) (*lun, error) {
+	luns := []*lun{}
+	if err := c.records("/storage/luns", url.Values{
+		"name":   {lunPath(volumeName)},
+		"fields": {"uuid,name,space.size"},
+	}, &luns); err != nil {
+		return nil, err
+	}
+	if len(luns) == 0 {
+		return nil, &apiError{
+			status:  http.StatusNotFound,
+			message: "lun not found: " + lunPath(volumeName),
+		}
+	}
+	return luns[0], nil
+}
+
+// luns returns the LUNs of the SVM's FlexVols whose names start with the
+// prefix keyed by the names of their FlexVols.
+func (c *client) luns(prefix string) (map[string]*lun, error) {
+	luns := []*lun{}
+	if err := c.records("/storage/luns", url.Values{
+		"name":   {lunPath(prefix + "*")},
+		"fields": {"uuid,name,space.size"},
+	}, &luns); err != nil {
+		return nil, err
+	}
+	m := map[string]*lun{}
+	for _, l := range luns {
+		v := strings.TrimSuffix(strings.TrimPrefix(l.Name, "/vol/"), "/lun0")
+		m[v] = l
+	}
+	return m, nil
+}
+
+// lunMaps returns the SVM's LUN maps.
+func (c *client) lunMaps() ([]*lunMap, error) {
+	maps := []*lunMap{}
+	err := c.records("/protocols/san/lun-maps", url.Values{
+		"fields": {"lun.name,lun.uuid,igroup.name,igroup.uuid," +
+			"logical_unit_number"},
+	}, &maps)
+	return maps, err
+}
+
+// igroups returns the SVM's initiator groups whose names start with the
+// prefix.
+func (c *client) igroups(prefix string) ([]*igroup, error) {
+	groups := []*igroup{}
+	err := c.records("/protocols/san/igroups", url.Values{
+		"name":   {prefix + "*"},
+		"fields": {"uuid,name,initiators.name"},
+	}, &groups)
+	return groups, err
+}
+
+// target returns the name of the SVM's iSCSI target.
+func (c *client) target() (string, error) {
+	services := []*struct {
+		Target ref `json:"target"`
+	}{}
+	if err := c.records("/protocols/san/iscsi/services", url.Values{
+		"fields": {"target.name"},
+	}, &services); err != nil {
+		return "", err
+	}
+	if len(services) == 0 || services[0].Target.Name == "" {
+		return "", goof.WithField("svm", c.svm, "svm has no iscsi service")
+	}
+	return services[0].Target.Name, nil
+}
+
+// exportRules returns the rules of an export policy.
+func (c *client) exportRules(policyID int64) ([]*exportRule, error) {
+	res := &struct {
+		Records []*exportRule `json:"records"`
+	}{}
+	if err := c.do("GET", fmt.Sprintf(
+		"/protocols/nfs/export-policies/%d/rules?fields=index,clients",
+		policyID), nil, res); err != nil {
+		return nil, err
+	}
+	return res.Records, nil
+}
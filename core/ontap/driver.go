@@ -0,0 +1,724 @@
+package ontap
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	"github.com/emccode/libstorage/api/context"
+	apitypes "github.com/emccode/libstorage/api/types"
+)
+
+// driver is the ontapnas driver, or the ontapsan driver if san is set.
+type driver struct {
+	name   string
+	san    bool
+	config gofig.Config
+	api    *client
+	prefix string
+
+	// target is the name of the SVM's iSCSI target.
+	target string
+}
+
+func (d *driver) Name() string {
+	return d.name
+}
+
+func (d *driver) Init(ctx apitypes.Context, config gofig.Config) error {
+	d.config = config
+	api, err := newClient(config)
+	if err != nil {
+		return err
+	}
+	d.api = api
+	if d.prefix = config.GetString("ontap.prefix"); d.prefix == "" {
+		d.prefix = defaultPrefix
+	}
+	if d.san {
+		if d.target, err = d.api.target(); err != nil {
+			return err
+		}
+	}
+
+	ctx.WithFields(map[string]interface{}{
+		"endpoint": d.api.endpoint,
+		"svm":      d.api.svm,
+		"target":   d.target,
+	}).Info("initialized " + d.name + " driver")
+	return nil
+}
+
+// instanceID returns the ID of the instance on whose behalf an operation is
+// performed: its initiator name, or its address in export policy rules.
+func instanceID(ctx apitypes.Context) string {
+	if iid, ok := ctx.Value(context.InstanceIDKey).(*apitypes.InstanceID); ok {
+		return iid.ID
+	}
+	return ""
+}
+
+func (d *driver) Type(ctx apitypes.Context) (apitypes.StorageType, error) {
+	if d.san {
+		return apitypes.Block, nil
+	}
+	return apitypes.NAS, nil
+}
+
+// NextDeviceInfo returns nil because the devices of attached LUNs are named
+// by the node's SCSI subsystem and the volumes of the NAS driver have none.
+func (d *driver) NextDeviceInfo(
+	ctx apitypes.Context) (*apitypes.NextDeviceInfo, error) {
+
+	return nil, nil
+}
+
+func (d *driver) InstanceInspect(
+	ctx apitypes.Context,
+	opts apitypes.Store) (*apitypes.Instance, error) {
+
+	iid, ok := ctx.Value(context.InstanceIDKey).(*apitypes.InstanceID)
+	if !ok {
+		return nil, goof.New("missing instance ID")
+	}
+	return &apitypes.Instance{InstanceID: iid, Name: iid.ID}, nil
+}
+
+// sanState is the state of the SVM's LUNs: the LUNs keyed by the names of
+// their FlexVols, their maps, and the initiator groups keyed by name.
+type sanState struct {
+	luns    map[string]*lun
+	maps    []*lunMap
+	igroups map[string]*igroup
+}
+
+func (d *driver) sanState(attachments bool) (*sanState, error) {
+	s := &sanState{igroups: map[string]*igroup{}}
+	var err error
+	if s.luns, err = d.api.luns(d.prefix); err != nil {
+		return nil, err
+	}
+	if !attachments {
+		return s, nil
+	}
+	if s.maps, err = d.api.lunMaps(); err != nil {
+		return nil, err
+	}
+	groups, err := d.api.igroups("")
+	if err != nil {
+		return nil, err
+	}
+	for _, g := range groups {
+		s.igroups[g.Name] = g
+	}
+	return s, nil
+}
+
+// toVolume returns a libStorage volume for a FlexVol. The volume's name is
+// the FlexVol's comment, which is the name with which the volume was
+// created. The size of a SAN volume is that of its LUN, and its attachments
+// are the initiators of the groups its LUN is mapped to. The attachments of
+// a NAS volume are the clients of the rules of its export policy.
+func (d *driver) toVolume(
+	ctx apitypes.Context,
+	v *flexVol,
+	san *sanState,
+	rules []*exportRule) *apitypes.Volume {
+
+	vol := &apitypes.Volume{
+		ID:     v.UUID,
+		Name:   v.Comment,
+		Size:   v.Size / gib,
+		Type:   d.name,
+		Status: v.State,
+		Fields: map[string]string{"flexVol": v.Name},
+	}
+	if vol.Name == "" {
+		vol.Name = strings.TrimPrefix(v.Name, d.prefix)
+	}
+	if v.SnapshotPolicy != nil {
+		vol.Fields["snapshotPolicy"] = v.SnapshotPolicy.Name
+	}
+	if v.QoS != nil && v.QoS.Policy != nil {
+		vol.Fields["qosPolicy"] = v.QoS.Policy.Name
+	}
+
+	iid := instanceID(ctx)
+	if !d.san {
+		if v.NAS != nil {
+			vol.Fields["junctionPath"] = v.NAS.Path
+			if v.NAS.ExportPolicy != nil {
+				vol.Fields["exportPolicy"] = v.NAS.ExportPolicy.Name
+			}
+		}
+		vol.Fields["dataLIF"] = d.config.GetString("ontap.dataLIF")
+		for _, r := range rules {
+			for _, c := range r.Clients {
+				vol.Attachments = append(vol.Attachments,
+					&apitypes.VolumeAttachment{
+						VolumeID: vol.ID,
+						InstanceID: &apitypes.InstanceID{
+							ID:     c.Match,
+							Driver: d.name,
+						},
+						Status: "attached",
+					})
+			}
+		}
+		return vol
+	}
+
+	if san == nil {
+		return vol
+	}
+	l, ok := san.luns[v.Name]
+	if !ok {
+		return vol
+	}
+	vol.Fields["lun"] = l.Name
+	if l.Space != nil {
+		vol.Size = l.Space.Size / gib
+	}
+	ld, _ := ctx.Value(context.LocalDevicesKey).(*apitypes.LocalDevices)
+	for _, m := range san.maps {
+		g, ok := san.igroups[m.IGroup.Name]
+		if m.LUN.Name != l.Name || !ok {
+			continue
+		}
+		for _, i := range g.Initiators {
+			att := &apitypes.VolumeAttachment{
+				VolumeID: vol.ID,
+				InstanceID: &apitypes.InstanceID{
+					ID:     i.Name,
+					Driver: d.name,
+				},
+				Status: "attached",
+				Fields: map[string]string{
+					"igroup": g.Name,
+					"lun":    strconv.Itoa(m.Number),
+				},
+			}
+			if i.Name == iid && ld != nil {
+				att.DeviceName = ld.DeviceMap[deviceKey(d.target, m.Number)]
+			}
+			vol.Attachments = append(vol.Attachments, att)
+		}
+	}
+	return vol
+}
+
+// rules returns the rules of a NAS volume's export policy if the driver
+// manages the policy.
+func (d *driver) rules(v *flexVol) ([]*exportRule, error) {
+	if d.san || !d.managedPolicy(v) {
+		return nil, nil
+	}
+	return d.api.exportRules(v.NAS.ExportPolicy.ID)
+}
+
+// managedPolicy returns a flag indicating whether a NAS volume's export
+// policy is the one the driver created for it.
+func (d *driver) managedPolicy(v *flexVol) bool {
+	return v.NAS != nil && v.NAS.ExportPolicy != nil &&
+		v.NAS.ExportPolicy.Name == v.Name
+}
+
+func (d *driver) Volumes(
+	ctx apitypes.Context,
+	opts *apitypes.VolumesOpts) ([]*apitypes.Volume, error) {
+
+	vols, err := d.api.volumes(d.prefix)
+	if err != nil {
+		return nil, err
+	}
+	var san *sanState
+	if d.san {
+		if san, err = d.sanState(opts.Attachments); err != nil {
+			return nil, err
+		}
+	}
+	all := []*apitypes.Volume{}
+	for _, v := range vols {
+		var rules []*exportRule
+		if opts.Attachments {
+			if rules, err = d.rules(v); err != nil {
+				return nil, err
+			}
+		}
+		all = append(all, d.toVolume(ctx, v, san, rules))
+	}
+	return all, nil
+}
+
+func (d *driver) VolumeInspect(
+	ctx apitypes.Context,
+	volumeID string,
+	opts *apitypes.VolumeInspectOpts) (*apitypes.Volume, error) {
+
+	return d.inspect(ctx, volumeID, opts.Attachments)
+}
+
+func (d *driver) inspect(
+	ctx apitypes.Context,
+	volumeID string,
+	attachments bool) (*apitypes.Volume, error) {
+
+	v, err := d.volume(volumeID)
+	if err != nil {
+		return nil, err
+	}
+	var san *sanState
+	if d.san {
+		if san, err = d.sanState(attachments); err != nil {
+			return nil, err
+		}
+	}
+	var rules []*exportRule
+	if attachments {
+		if rules, err = d.rules(v); err != nil {
+			return nil, err
+		}
+	}
+	return d.toVolume(ctx, v, san, rules), nil
+}
+
+func (d *driver) volume(id string) (*flexVol, error) {
+	v, err := d.api.volume(id)
+	if isNotFound(err) {
+		return nil, goof.WithField("volumeID", id, "volume not found")
+	}
+	return v, err
+}
+
+// createOpt returns a create option, or else the configured value.
+func (d *driver) createOpt(
+	opts *apitypes.VolumeCreateOpts, key, configKey string) string {
+
+	if opts.Opts != nil {
+		if v := opts.Opts.GetString(key); v != "" {
+			return v
+		}
+	}
+	return d.config.GetString(configKey)
+}
+
+// VolumeCreate creates a FlexVol with the requested or configured snapshot
+// policy and QoS policy group. A NAS volume is mounted in the SVM's
+// namespace at /NAME and is exported with an export policy of its own, or
+// with the configured shared policy. A SAN volume holds one LUN of the
+// volume's size, to which the QoS policy group is assigned instead, and is
+// thin provisioned and grows as its snapshots require.
+func (d *driver) VolumeCreate(
+	ctx apitypes.Context,
+	name string,
+	opts *apitypes.VolumeCreateOpts) (*apitypes.Volume, error) {
+
+	size := int64(defaultSize)
+	if opts.Size != nil && *opts.Size > 0 {
+		size = *opts.Size
+	}
+	fv := flexVolName(d.prefix, name)
+	snapshotPolicy := d.createOpt(opts, OptSnapshotPolicy,
+		"ontap.snapshotPolicy")
+	if snapshotPolicy == "" {
+		snapshotPolicy = defaultSnapshotPolicy
+	}
+	qos := d.createOpt(opts, OptQoSPolicy, "ontap.qosPolicy")
+
+	body := map[string]interface{}{
+		"name":            fv,
+		"svm":             ref{Name: d.api.svm},
+		"size":            size * gib,
+		"comment":         name,
+		"snapshot_policy": ref{Name: snapshotPolicy},
+		"guarantee":       map[string]string{"type": "none"},
+	}
+	if aggr := d.createOpt(opts, OptAggregate, "ontap.aggregate"); aggr != "" {
+		body["aggregates"] = []ref{{Name: aggr}}
+	}
+
+	if d.san {
+		body["size"] = size*gib + size*gib/10
+		body["space"] = map[string]interface{}{
+			"snapshot": map[string]int{"reserve_percent": 0},
+		}
+		body["autosize"] = map[string]string{"mode": "grow"}
+	} else {
+		policy, err := d.createExportPolicy(fv)
+		if err != nil {
+			return nil, err
+		}
+		body["nas"] = map[string]interface{}{
+			"path":          "/" + fv,
+			"export_policy": ref{Name: policy},
+		}
+		if qos != "" {
+			body["qos"] = map[string]interface{}{"policy": ref{Name: qos}}
+		}
+	}
+
+	if err := d.api.doJob("POST", "/storage/volumes", body); err != nil {
+		return nil, goof.WithFieldsE(goof.Fields{
+			"name":    name,
+			"flexVol": fv,
+		}, "error creating volume", err)
+	}
+
+	if d.san {
+		l := map[string]interface{}{
+			"svm":     ref{Name: d.api.svm},
+			"name":    lunPath(fv),
+			"os_type": "linux",
+			"space":   map[string]int64{"size": size * gib},
+		}
+		if qos != "" {
+			l["qos_policy"] = ref{Name: qos}
+		}
+		if err := d.api.do("POST", "/storage/luns", l, nil); err != nil {
+			return nil, goof.WithFieldsE(goof.Fields{
+				"name": name,
+				"lun":  lunPath(fv),
+			}, "error creating lun", err)
+		}
+	}
+
+	v, err := d.api.volumeByName(fv)
+	if err != nil {
+		return nil, err
+	}
+	var san *sanState
+	if d.san {
+		if san, err = d.sanState(false); err != nil {
+			return nil, err
+		}
+	}
+	return d.toVolume(ctx, v, san, nil), nil
+}
+
+// createExportPolicy returns the export policy of a new NAS volume: the
+// configured shared policy, or else a new policy with no rules that has
+// the FlexVol's name.
+func (d *driver) createExportPolicy(fv string) (string, error) {
+	if p := d.config.GetString("ontap.exportPolicy"); p != "" {
+		return p, nil
+	}
+	if err := d.api.do("POST", "/protocols/nfs/export-policies",
+		map[string]interface{}{
+			"name": fv,
+			"svm":  ref{Name: d.api.svm},
+		}, nil); err != nil {
+		return "", goof.WithFieldE("exportPolicy", fv,
+			"error creating export policy", err)
+	}
+	return fv, nil
+}
+
+func (d *driver) VolumeCreateFromSnapshot(
+	ctx apitypes.Context,
+	snapshotID, volumeName string,
+	opts *apitypes.VolumeCreateOpts) (*apitypes.Volume, error) {
+
+	return nil, apitypes.ErrNotImplemented
+}
+
+func (d *driver) VolumeCopy(
+	ctx apitypes.Context,
+	volumeID, volumeName string,
+	opts apitypes.Store) (*apitypes.Volume, error) {
+
+	return nil, apitypes.ErrNotImplemented
+}
+
+func (d *driver) VolumeSnapshot(
+	ctx apitypes.Context,
+	volumeID, snapshotName string,
+	opts apitypes.Store) (*apitypes.Snapshot, error) {
+
+	return nil, apitypes.ErrNotImplemented
+}
+
+// VolumeRemove removes a volume's LUN, or its junction in the SVM's
+// namespace, takes it offline, and removes it along with the export policy
+// the driver created for it.
+func (d *driver) VolumeRemove(
+	ctx apitypes.Context,
+	volumeID string,
+	opts apitypes.Store) error {
+
+	v, err := d.volume(volumeID)
+	if err != nil {
+		return err
+	}
+	fields := goof.Fields{"volumeID": volumeID, "flexVol": v.Name}
+
+	if d.san {
+		l, err := d.api.lun(v.Name)
+		if err != nil && !isNotFound(err) {
+			return err
+		}
+		if l != nil {
+			if err := d.api.do(
+				"DELETE", "/storage/luns/"+l.UUID, nil, nil); err != nil {
+				return goof.WithFieldsE(fields, "error removing lun", err)
+			}
+		}
+	} else if err := d.api.doJob("PATCH", "/storage/volumes/"+v.UUID,
+		map[string]interface{}{
+			"nas": map[string]string{"path": ""},
+		}); err != nil {
+		return goof.WithFieldsE(fields, "error unmounting volume", err)
+	}
+
+	if err := d.api.doJob("PATCH", "/storage/volumes/"+v.UUID,
+		map[string]string{"state": "offline"}); err != nil {
+		return goof.WithFieldsE(fields, "error taking volume offline", err)
+	}
+	if err := d.api.doJob(
+		"DELETE", "/storage/volumes/"+v.UUID, nil); err != nil {
+		return goof.WithFieldsE(fields, "error removing volume", err)
+	}
+
+	if !d.san && d.managedPolicy(v) {
+		if err := d.api.do("DELETE", fmt.Sprintf(
+			"/protocols/nfs/export-policies/%d", v.NAS.ExportPolicy.ID),
+			nil, nil); err != nil && !isNotFound(err) {
+			return goof.WithFieldsE(fields,
+				"error removing export policy", err)
+		}
+	}
+	return nil
+}
+
+// VolumeAttach maps a SAN volume's LUN to the initiator group of the
+// instance's initiator, which is created if there is none, and returns the
+// key of the LUN in the node's local devices as the token. A forced attach
+// first removes the LUN's maps to other groups. A NAS volume is attached by
+// adding a rule for the instance's address to its export policy, unless its
+// policy is the configured shared policy.
+func (d *driver) VolumeAttach(
+	ctx apitypes.Context,
+	volumeID string,
+	opts *apitypes.VolumeAttachOpts) (*apitypes.Volume, string, error) {
+
+	iid := instanceID(ctx)
+	if iid == "" {
+		return nil, "", goof.New("missing instance ID")
+	}
+	v, err := d.volume(volumeID)
+	if err != nil {
+		return nil, "", err
+	}
+	if !d.san {
+		if err := d.addRule(v, iid); err != nil {
+			return nil, "", err
+		}
+		vol, err := d.inspect(ctx, volumeID, true)
+		return vol, "", err
+	}
+
+	san, err := d.sanState(true)
+	if err != nil {
+		return nil, "", err
+	}
+	l, ok := san.luns[v.Name]
+	if !ok {
+		return nil, "", goof.WithField("volumeID", volumeID, "volume has no lun")
+	}
+	group := igroupName(d.prefix, iid)
+	if _, ok := san.igroups[group]; !ok {
+		if err := d.createIGroup(group, iid); err != nil {
+			return nil, "", err
+		}
+	}
+
+	for _, m := range san.maps {
+		switch {
+		case m.LUN.Name != l.Name:
+		case m.IGroup.Name == group:
+			vol, err := d.inspect(ctx, volumeID, true)
+			return vol, deviceKey(d.target, m.Number), err
+		case !opts.Force:
+			return nil, "", goof.WithFields(goof.Fields{
+				"volumeID": volumeID,
+				"igroup":   m.IGroup.Name,
+			}, "volume is attached to another initiator group")
+		default:
+			if err := d.unmap(m); err != nil {
+				return nil, "", err
+			}
+		}
+	}
+
+	res := &struct {
+		Records []*lunMap `json:"records"`
+	}{}
+	if err := d.api.do("POST", "/protocols/san/lun-maps?return_records=true",
+		map[string]interface{}{
+			"svm":    ref{Name: d.api.svm},
+			"lun":    ref{Name: l.Name},
+			"igroup": ref{Name: group},
+		}, res); err != nil || len(res.Records) == 0 {
+		return nil, "", goof.WithFieldsE(goof.Fields{
+			"volumeID": volumeID,
+			"igroup":   group,
+		}, "error mapping lun", err)
+	}
+	vol, err := d.inspect(ctx, volumeID, true)
+	if err != nil {
+		return nil, "", err
+	}
+	return vol, deviceKey(d.target, res.Records[0].Number), nil
+}
+
+func (d *driver) createIGroup(group, initiator string) error {
+	if err := d.api.do("POST", "/protocols/san/igroups",
+		map[string]interface{}{
+			"svm":        ref{Name: d.api.svm},
+			"name":       group,
+			"os_type":    "linux",
+			"protocol":   "iscsi",
+			"initiators": []ref{{Name: initiator}},
+		}, nil); err != nil {
+		return goof.WithFieldsE(goof.Fields{
+			"igroup":    group,
+			"initiator": initiator,
+		}, "error creating initiator group", err)
+	}
+	return nil
+}
+
+func (d *driver) unmap(m *lunMap) error {
+	if err := d.api.do("DELETE", "/protocols/san/lun-maps/"+m.LUN.UUID+"/"+
+		m.IGroup.UUID, nil, nil); err != nil && !isNotFound(err) {
+		return goof.WithFieldsE(goof.Fields{
+			"lun":    m.LUN.Name,
+			"igroup": m.IGroup.Name,
+		}, "error unmapping lun", err)
+	}
+	return nil
+}
+
+// addRule adds a rule that grants a client read-write access to a NAS
+// volume to the volume's export policy if it has none.
+func (d *driver) addRule(v *flexVol, client string) error {
+	if !d.managedPolicy(v) {
+		return nil
+	}
+	rules, err := d.api.exportRules(v.NAS.ExportPolicy.ID)
+	if err != nil {
+		return err
+	}
+	for _, r := range rules {
+		for _, c := range r.Clients {
+			if c.Match == client {
+				return nil
+			}
+		}
+	}
+	sys := []string{"sys"}
+	if err := d.api.do("POST", fmt.Sprintf(
+		"/protocols/nfs/export-policies/%d/rules", v.NAS.ExportPolicy.ID),
+		map[string]interface{}{
+			"clients":   []map[string]string{{"match": client}},
+			"protocols": []string{"nfs"},
+			"ro_rule":   sys,
+			"rw_rule":   sys,
+			"superuser": sys,
+		}, nil); err != nil {
+		return goof.WithFieldsE(goof.Fields{
+			"exportPolicy": v.NAS.ExportPolicy.Name,
+			"client":       client,
+		}, "error adding export rule", err)
+	}
+	return nil
+}
+
+// VolumeDetach unmaps a SAN volume's LUN from the instance's initiator
+// group, or removes the rule for the instance's address from a NAS volume's
+// export policy. A forced detach removes all of the LUN's maps or all of the
+// policy's rules.
+func (d *driver) VolumeDetach(
+	ctx apitypes.Context,
+	volumeID string,
+	opts *apitypes.VolumeDetachOpts) (*apitypes.Volume, error) {
+
+	v, err := d.volume(volumeID)
+	if err != nil {
+		return nil, err
+	}
+	iid := instanceID(ctx)
+
+	if !d.san {
+		rules, err := d.rules(v)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range rules {
+			for _, c := range r.Clients {
+				if c.Match != iid && !opts.Force {
+					continue
+				}
+				if err := d.api.do("DELETE", fmt.Sprintf(
+					"/protocols/nfs/export-policies/%d/rules/%d",
+					v.NAS.ExportPolicy.ID, r.Index),
+					nil, nil); err != nil && !isNotFound(err) {
+					return nil, goof.WithFieldsE(goof.Fields{
+						"exportPolicy": v.NAS.ExportPolicy.Name,
+						"client":       c.Match,
+					}, "error removing export rule", err)
+				}
+				break
+			}
+		}
+		return d.inspect(ctx, volumeID, true)
+	}
+
+	san, err := d.sanState(true)
+	if err != nil {
+		return nil, err
+	}
+	group := igroupName(d.prefix, iid)
+	for _, m := range san.maps {
+		if m.LUN.Name != lunPath(v.Name) ||
+			(m.IGroup.Name != group && !opts.Force) {
+			continue
+		}
+		if err := d.unmap(m); err != nil {
+			return nil, err
+		}
+	}
+	return d.inspect(ctx, volumeID, true)
+}
+
+func (d *driver) Snapshots(
+	ctx apitypes.Context,
+	opts apitypes.Store) ([]*apitypes.Snapshot, error) {
+
+	return nil, apitypes.ErrNotImplemented
+}
+
+func (d *driver) SnapshotInspect(
+	ctx apitypes.Context,
+	snapshotID string,
+	opts apitypes.Store) (*apitypes.Snapshot, error) {
+
+	return nil, apitypes.ErrNotImplemented
+}
+
+func (d *driver) SnapshotCopy(
+	ctx apitypes.Context,
+	snapshotID, snapshotName, destinationID string,
+	opts apitypes.Store) (*apitypes.Snapshot, error) {
+
+	return nil, apitypes.ErrNotImplemented
+}
+
+func (d *driver) SnapshotRemove(
+	ctx apitypes.Context,
+	snapshotID string,
+	opts apitypes.Store) error {
+
+	return apitypes.ErrNotImplemented
+}
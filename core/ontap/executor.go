@@ -0,0 +1,112 @@
+package ontap
+
+import (
+	"net"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/core/openiscsi"
+	"github.com/emccode/rexray/core/scsi"
+)
+
+// executor runs on each node. The instance ID of an ontapsan node is its
+// initiator name, and that of an ontapnas node is the address with which it
+// appears in export policy rules.
+type executor struct {
+	name   string
+	san    bool
+	config gofig.Config
+}
+
+func (e *executor) Name() string {
+	return e.name
+}
+
+func (e *executor) Init(ctx apitypes.Context, config gofig.Config) error {
+	e.config = config
+	return nil
+}
+
+func (e *executor) InstanceID(
+	ctx apitypes.Context,
+	opts apitypes.Store) (*apitypes.InstanceID, error) {
+
+	if e.san {
+		iqn, err := openiscsi.InitiatorName()
+		if err != nil {
+			return nil, err
+		}
+		return &apitypes.InstanceID{ID: iqn, Driver: e.name}, nil
+	}
+
+	addr, err := e.clientAddress()
+	if err != nil {
+		return nil, err
+	}
+	return &apitypes.InstanceID{ID: addr, Driver: e.name}, nil
+}
+
+// clientAddress returns the configured address of the node, or else the
+// local address of the route to the data LIF.
+func (e *executor) clientAddress() (string, error) {
+	if addr := e.config.GetString("ontap.clientAddress"); addr != "" {
+		return addr, nil
+	}
+	lif := e.config.GetString("ontap.dataLIF")
+	if lif == "" {
+		return "", goof.New(
+			"ontapnas requires ontap.clientAddress or ontap.dataLIF")
+	}
+	conn, err := net.Dial("udp", net.JoinHostPort(lif, "2049"))
+	if err != nil {
+		return "", goof.WithFieldE("dataLIF", lif,
+			"error finding route to data lif", err)
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP.String(), nil
+}
+
+func (e *executor) NextDevice(
+	ctx apitypes.Context,
+	opts apitypes.Store) (string, error) {
+
+	return "", apitypes.ErrNotImplemented
+}
+
+// LocalDevices returns the devices of the LUNs of the SVM's target that are
+// mapped to the node, keyed by the target and their LUN numbers. A deep scan
+// first logs in to the portals in ontap.portals the node has no session
+// with, rescans the sessions for LUNs mapped since, and waits for multipathd
+// to coalesce their paths. An ontapnas node has no local devices.
+func (e *executor) LocalDevices(
+	ctx apitypes.Context,
+	opts *apitypes.LocalDevicesOpts) (*apitypes.LocalDevices, error) {
+
+	devs := map[string]string{}
+	if !e.san {
+		return &apitypes.LocalDevices{Driver: e.name, DeviceMap: devs}, nil
+	}
+	multipath := e.config.GetBool("ontap.multipath") &&
+		scsi.Multipath(e.config)
+
+	if opts.ScanType == apitypes.DeviceScanDeep {
+		portals := []string{}
+		for _, p := range splitList(e.config.GetString("ontap.portals")) {
+			portals = append(portals, openiscsi.PortalAddr(p))
+		}
+		if err := login(ctx, portals); err != nil {
+			return nil, err
+		}
+		if multipath {
+			scsi.SettleMultipath(ctx, scsi.MultipathTimeout(e.config))
+		}
+	}
+
+	devs, err := devices(multipath)
+	if err != nil {
+		return nil, err
+	}
+	return &apitypes.LocalDevices{Driver: e.name, DeviceMap: devs}, nil
+}
@@ -0,0 +1,69 @@
+package ontap
+
+import (
+	"os/exec"
+	"strings"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	apitypes "github.com/emccode/libstorage/api/types"
+)
+
+// mount mounts an ontapnas volume's junction path with NFS. The volume is
+// mounted from the data LIF in ontap.dataLIF in the node's configuration, or
+// else the server's, and the token is unused.
+func mount(
+	ctx apitypes.Context,
+	config gofig.Config,
+	vol *apitypes.Volume,
+	token, mountPath string,
+	readOnly bool) error {
+
+	path := vol.Fields["junctionPath"]
+	if path == "" {
+		return goof.WithField("volumeID", vol.ID,
+			"volume has no junction path")
+	}
+	lif := config.GetString("ontap.dataLIF")
+	if lif == "" {
+		lif = vol.Fields["dataLIF"]
+	}
+	if lif == "" {
+		return goof.WithField("volumeID", vol.ID,
+			"ontapnas mounts require ontap.dataLIF")
+	}
+
+	args := mountArgs(lif, path, mountPath,
+		config.GetString("ontap.nfsMountOptions"), readOnly)
+	ctx.WithFields(map[string]interface{}{
+		"volumeID":  vol.ID,
+		"source":    args[2],
+		"mountPath": mountPath,
+	}).Debug("mounting ontap volume")
+
+	if out, err := exec.Command("mount", args...).CombinedOutput(); err != nil {
+		return goof.WithFieldE("output", strings.TrimSpace(string(out)),
+			"error mounting ontap volume", err)
+	}
+	return nil
+}
+
+// mountArgs returns the arguments of the mount command that mounts a
+// junction path from a data LIF with the configured NFS mount options.
+func mountArgs(lif, path, mountPath, options string, readOnly bool) []string {
+	if strings.Contains(lif, ":") && !strings.HasPrefix(lif, "[") {
+		lif = "[" + lif + "]"
+	}
+	opts := []string{}
+	if options != "" {
+		opts = append(opts, options)
+	}
+	if readOnly {
+		opts = append(opts, "ro")
+	}
+	args := []string{"-t", "nfs", lif + ":" + path, mountPath}
+	if len(opts) > 0 {
+		args = append(args, "-o", strings.Join(opts, ","))
+	}
+	return args
+}
@@ -0,0 +1,46 @@
+package ontap
+
+import (
+	"io/ioutil"
+	"path/filepath"
+
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/core/openiscsi"
+	"github.com/emccode/rexray/core/scsi"
+)
+
+// login discovers the targets of and logs in to the portals the node has no
+// session with, and rescans its sessions for LUNs that were mapped since
+// they were established.
+func login(ctx apitypes.Context, portals []string) error {
+	return openiscsi.Login(ctx, portals)
+}
+
+// devices returns the devices of the iSCSI LUNs attached to the node keyed
+// by their targets and LUN numbers. If multipath is enabled a LUN's device
+// is the multipath device that holds its paths, if there is one.
+func devices(multipath bool) (map[string]string, error) {
+	devs := map[string]string{}
+	links, err := ioutil.ReadDir(openiscsi.ByPathDir)
+	if err != nil {
+		return devs, nil
+	}
+	for _, l := range links {
+		target, lun, ok := openiscsi.ParseByPath(l.Name())
+		if !ok {
+			continue
+		}
+		path, err := filepath.EvalSymlinks(
+			filepath.Join(openiscsi.ByPathDir, l.Name()))
+		if err != nil {
+			continue
+		}
+		key := deviceKey(target, lun)
+		dev := scsi.DevicePath(path, multipath)
+		if _, ok := devs[key]; !ok || dev != path {
+			devs[key] = dev
+		}
+	}
+	return devs, nil
+}
@@ -0,0 +1,16 @@
+// +build !linux
+
+package ontap
+
+import (
+	"github.com/akutz/goof"
+	apitypes "github.com/emccode/libstorage/api/types"
+)
+
+func login(ctx apitypes.Context, portals []string) error {
+	return goof.New("ontapsan volumes are only supported on Linux")
+}
+
+func devices(multipath bool) (map[string]string, error) {
+	return map[string]string{}, nil
+}
@@ -0,0 +1,176 @@
+// Package ontap is a storage driver for NetApp ONTAP that manages volumes
+// with the ONTAP REST API. It is registered as two drivers: ontapnas, whose
+// volumes are FlexVols that nodes mount with NFS through a per-volume export
+// policy, and ontapsan, whose volumes are FlexVols that each hold one LUN
+// that is mapped to the initiator group of each node it is attached to and
+// that nodes log in to with open-iscsi. The volumes of both are assigned
+// snapshot policies and QoS policy groups.
+package ontap
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/akutz/gofig"
+	"github.com/emccode/libstorage/api/registry"
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/core/sharedfs"
+)
+
+const (
+	// NameNAS is the name of the driver whose volumes are mounted with NFS.
+	NameNAS = "ontapnas"
+
+	// NameSAN is the name of the driver whose volumes are attached with
+	// iSCSI.
+	NameSAN = "ontapsan"
+
+	// OptSnapshotPolicy is the create option with which a volume's snapshot
+	// policy is requested.
+	OptSnapshotPolicy = "snapshotPolicy"
+
+	// OptQoSPolicy is the create option with which a volume's QoS policy
+	// group is requested.
+	OptQoSPolicy = "qosPolicy"
+
+	// OptAggregate is the create option with which the aggregate of a
+	// volume is requested.
+	OptAggregate = "aggregate"
+
+	defaultPrefix         = "rexray_"
+	defaultSnapshotPolicy = "none"
+	defaultSize           = 1
+
+	gib = 1024 * 1024 * 1024
+
+	// maxNameLen is the length of the longest name of a FlexVol.
+	maxNameLen = 203
+
+	// maxIGroupNameLen is the length of the longest name of an initiator
+	// group.
+	maxIGroupNameLen = 96
+)
+
+func init() {
+	registry.RegisterStorageDriver(NameNAS, newNASDriver)
+	registry.RegisterStorageDriver(NameSAN, newSANDriver)
+	registry.RegisterStorageExecutor(NameNAS, newNASExecutor)
+	registry.RegisterStorageExecutor(NameSAN, newSANExecutor)
+	sharedfs.Register(NameNAS, mount)
+
+	r := gofig.NewRegistration("NetApp ONTAP Drivers")
+	r.Key(gofig.String, "", "",
+		"The URL of the cluster or SVM management interface",
+		"ontap.endpoint")
+	r.Key(gofig.String, "", "",
+		"The user with which the ONTAP REST API is called",
+		"ontap.username")
+	r.Key(gofig.String, "", "",
+		"The password of the user",
+		"ontap.password")
+	r.Key(gofig.Bool, "", false,
+		"Skip the verification of the management interface's TLS certificate",
+		"ontap.insecure")
+	r.Key(gofig.String, "", "",
+		"The SVM in which volumes are created",
+		"ontap.svm")
+	r.Key(gofig.String, "", "",
+		"The aggregate in which volumes are created",
+		"ontap.aggregate")
+	r.Key(gofig.String, "", defaultPrefix,
+		"The prefix of the names of the FlexVols and initiator groups the "+
+			"drivers create",
+		"ontap.prefix")
+	r.Key(gofig.String, "", defaultSnapshotPolicy,
+		"The snapshot policy of new volumes",
+		"ontap.snapshotPolicy")
+	r.Key(gofig.String, "", "",
+		"The QoS policy group of new volumes",
+		"ontap.qosPolicy")
+	r.Key(gofig.String, "", "",
+		"An export policy shared by all volumes instead of a policy per "+
+			"volume whose rules are managed by the driver",
+		"ontap.exportPolicy")
+	r.Key(gofig.String, "", "",
+		"The address of the NFS data LIF from which nodes mount volumes",
+		"ontap.dataLIF")
+	r.Key(gofig.String, "", "",
+		"The NFS mount options, ex. vers=4.1",
+		"ontap.nfsMountOptions")
+	r.Key(gofig.String, "", "",
+		"The node's address in export policy rules; defaults to the "+
+			"address from which the node reaches the data LIF",
+		"ontap.clientAddress")
+	r.Key(gofig.String, "", "",
+		"The iSCSI data LIFs, host[:port] and separated by commas, at which "+
+			"nodes log in to the SVM's target",
+		"ontap.portals")
+	r.Key(gofig.Bool, "", true,
+		"Use the multipath device of a LUN when there is one",
+		"ontap.multipath")
+	r.Key(gofig.String, "", "2m",
+		"How long to wait for an ONTAP job",
+		"ontap.timeout")
+	gofig.Register(r)
+}
+
+func newNASDriver() apitypes.StorageDriver {
+	return &driver{name: NameNAS}
+}
+
+func newSANDriver() apitypes.StorageDriver {
+	return &driver{name: NameSAN, san: true}
+}
+
+func newNASExecutor() apitypes.StorageExecutor {
+	return &executor{name: NameNAS}
+}
+
+func newSANExecutor() apitypes.StorageExecutor {
+	return &executor{name: NameSAN, san: true}
+}
+
+// flexVolName returns the name of the FlexVol of a volume: the volume's name
+// with the prefix and with the characters a FlexVol's name may not contain
+// replaced with underscores.
+func flexVolName(prefix, volumeName string) string {
+	n := []rune(prefix + volumeName)
+	for i, r := range n {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' ||
+			r >= '0' && r <= '9' || r == '_') {
+			n[i] = '_'
+		}
+	}
+	if len(n) > maxNameLen {
+		n = n[:maxNameLen]
+	}
+	return string(n)
+}
+
+// lunPath returns the path of the LUN of a FlexVol.
+func lunPath(volumeName string) string {
+	return "/vol/" + volumeName + "/lun0"
+}
+
+// igroupName returns the name of the initiator group of an initiator.
+func igroupName(prefix, initiator string) string {
+	n := flexVolName(prefix, initiator)
+	if len(n) > maxIGroupNameLen {
+		n = n[:maxIGroupNameLen]
+	}
+	return n
+}
+
+// deviceKey returns the key of a LUN in a node's local devices, which is
+// also the token with which the node waits for an attached LUN to appear.
+func deviceKey(target string, lun int) string {
+	return fmt.Sprintf("%s-lun-%d", target, lun)
+}
+
+// splitList splits a list separated by commas or spaces.
+func splitList(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+}
@@ -0,0 +1,59 @@
+package ontap
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/emccode/rexray/core/openiscsi"
+)
+
+func TestFlexVolName(t *testing.T) {
+	if n := flexVolName("rexray_", "pg-data.1"); n != "rexray_pg_data_1" {
+		t.Fatalf("name=%s", n)
+	}
+	if n := flexVolName("rexray_", strings.Repeat("a", 300)); len(n) != 203 {
+		t.Fatalf("len=%d", len(n))
+	}
+	if n := igroupName("rexray_", "iqn.1994-05.com.redhat:"+
+		strings.Repeat("b", 100)); len(n) != 96 {
+		t.Fatalf("len=%d", len(n))
+	}
+	if p := lunPath("rexray_db"); p != "/vol/rexray_db/lun0" {
+		t.Fatalf("path=%s", p)
+	}
+}
+
+func TestParseByPath(t *testing.T) {
+	target, lun, ok := openiscsi.ParseByPath("ip-10.0.0.1:3260-iscsi-" +
+		"iqn.1992-08.com.netapp:sn.1f2e:vs.3-lun-12")
+	if !ok || target != "iqn.1992-08.com.netapp:sn.1f2e:vs.3" || lun != 12 {
+		t.Fatalf("target=%s lun=%d ok=%v", target, lun, ok)
+	}
+	if k := deviceKey(target, lun); k !=
+		"iqn.1992-08.com.netapp:sn.1f2e:vs.3-lun-12" {
+		t.Fatalf("key=%s", k)
+	}
+	for _, n := range []string{
+		"ip-10.0.0.1:3260-iscsi-iqn.1992-08.com.netapp:sn.1f2e:vs.3" +
+			"-lun-12-part1",
+		"pci-0000:00:10.0-scsi-0:0:0:0",
+	} {
+		if _, _, ok := openiscsi.ParseByPath(n); ok {
+			t.Fatalf("parsed %s", n)
+		}
+	}
+}
+
+func TestMountArgs(t *testing.T) {
+	args := mountArgs("10.0.0.2", "/rexray_db", "/mnt/db", "vers=4.1", true)
+	exp := []string{"-t", "nfs", "10.0.0.2:/rexray_db", "/mnt/db",
+		"-o", "vers=4.1,ro"}
+	if !reflect.DeepEqual(args, exp) {
+		t.Fatalf("args=%v", args)
+	}
+	args = mountArgs("fd00::2", "/rexray_db", "/mnt/db", "", false)
+	if len(args) != 4 || args[2] != "[fd00::2]:/rexray_db" {
+		t.Fatalf("args=%v", args)
+	}
+}
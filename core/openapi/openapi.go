@@ -0,0 +1,443 @@
+// Package openapi describes the libStorage REST API with an OpenAPI 3
+// document, so that clients of the API may be generated in other languages.
+// The API's operations are declared in routes.go, and the schemas of their
+// requests and responses are generated from libStorage's types, so that the
+// document follows the types when libStorage is updated.
+package openapi
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/akutz/gofig"
+	apiversion "github.com/emccode/libstorage/api"
+)
+
+// Path is the path at which the document is served.
+const Path = "/openapi.json"
+
+// Document is an OpenAPI 3 document.
+type Document struct {
+	OpenAPI    string                           `json:"openapi"`
+	Info       *Info                            `json:"info"`
+	Servers    []*Server                        `json:"servers,omitempty"`
+	Tags       []*Tag                           `json:"tags,omitempty"`
+	Paths      map[string]map[string]*Operation `json:"paths"`
+	Components *Components                      `json:"components"`
+}
+
+// Info describes the API.
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// Server is a URL at which the API is served.
+type Server struct {
+	URL string `json:"url"`
+}
+
+// Tag groups operations.
+type Tag struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// Operation is an operation of the API.
+type Operation struct {
+	OperationID string               `json:"operationId"`
+	Summary     string               `json:"summary"`
+	Description string               `json:"description,omitempty"`
+	Tags        []string             `json:"tags,omitempty"`
+	Parameters  []*Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+// Parameter is a parameter of an operation.
+type Parameter struct {
+	Name            string  `json:"name"`
+	In              string  `json:"in"`
+	Description     string  `json:"description,omitempty"`
+	Required        bool    `json:"required,omitempty"`
+	AllowEmptyValue bool    `json:"allowEmptyValue,omitempty"`
+	Schema          *Schema `json:"schema"`
+}
+
+// RequestBody is the body of an operation's request.
+type RequestBody struct {
+	Required bool                  `json:"required,omitempty"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+// Response is a response of an operation.
+type Response struct {
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+// MediaType is the schema of a body of a media type.
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Components are the schemas that are referred to by the operations.
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas"`
+}
+
+// Schema is the schema of a value.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	OneOf                []*Schema          `json:"oneOf,omitempty"`
+}
+
+const schemaRefPrefix = "#/components/schemas/"
+
+func init() {
+	r := gofig.NewRegistration("OpenAPI")
+	r.Key(gofig.Bool, "", false,
+		"Serve the libStorage API's OpenAPI document at its endpoints",
+		"rexray.openapi.enabled")
+	gofig.Register(r)
+}
+
+// Enabled returns a flag indicating whether the libStorage endpoints serve
+// the API's document.
+func Enabled(config gofig.Config) bool {
+	return config.GetBool("rexray.openapi.enabled")
+}
+
+// New returns the document of the libStorage REST API served at the
+// provided URLs, or relative to the document's own URL if there are none.
+// Empty URLs are ignored.
+func New(servers ...string) *Document {
+	g := &generator{schemas: map[string]*Schema{}}
+	d := &Document{
+		OpenAPI: "3.0.3",
+		Info: &Info{
+			Title: "libStorage",
+			Description: "The REST API of the libStorage server embedded " +
+				"in REX-Ray",
+			Version: apiVersion(),
+		},
+		Tags:       tags,
+		Paths:      map[string]map[string]*Operation{},
+		Components: &Components{Schemas: g.schemas},
+	}
+	for _, s := range servers {
+		if s == "" {
+			continue
+		}
+		d.Servers = append(d.Servers, &Server{URL: s})
+	}
+	for _, r := range routes {
+		if d.Paths[r.path] == nil {
+			d.Paths[r.path] = map[string]*Operation{}
+		}
+		d.Paths[r.path][strings.ToLower(r.method)] = r.operation(g)
+	}
+	return d
+}
+
+// apiVersion returns the semantic version of the libStorage API, or
+// "unknown" if the binary was built without libStorage's version.
+func apiVersion() string {
+	if v := apiversion.Version; v != nil && v.SemVer != "" {
+		return v.SemVer
+	}
+	return "unknown"
+}
+
+// Handler returns an HTTP handler that serves the document of the API served
+// at the provided URLs.
+func Handler(servers ...string) http.Handler {
+	buf, err := json.MarshalIndent(New(servers...), "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		w.Write(buf)
+	})
+}
+
+// ServerURL returns the URL of the API served at a libStorage host, ex.
+// http://127.0.0.1:7979 for tcp://127.0.0.1:7979, or an empty string if the
+// host is not served over TCP.
+func ServerURL(host string, tls bool) string {
+	i := strings.Index(host, "://")
+	if i < 0 || host[:i] != "tcp" {
+		return ""
+	}
+	if tls {
+		return "https://" + host[i+3:]
+	}
+	return "http://" + host[i+3:]
+}
+
+// operation returns the route's operation. A route with actions has a flag
+// in its query for each action, and the union of the actions' requests and
+// responses.
+func (r *route) operation(g *generator) *Operation {
+	o := &Operation{
+		OperationID: r.id,
+		Summary:     r.summary,
+		Tags:        []string{r.tag},
+		Responses:   map[string]*Response{},
+	}
+	for _, name := range pathParams(r.path) {
+		o.Parameters = append(o.Parameters, &Parameter{
+			Name:     name,
+			In:       "path",
+			Required: true,
+			Schema:   &Schema{Type: "string"},
+		})
+	}
+	o.Parameters = append(o.Parameters, r.params...)
+
+	if len(r.actions) == 0 {
+		if r.request != nil {
+			o.RequestBody = jsonBody(g.schema(reflect.TypeOf(r.request)))
+		}
+		o.Responses[fmt.Sprint(r.status())] = r.response(g)
+		return o
+	}
+
+	descs := []string{}
+	reqs, ress := []*Schema{}, []*Schema{}
+	for _, a := range r.actions {
+		if a.flag == "" {
+			descs = append(descs, "Without a flag: "+a.summary+".")
+		} else {
+			descs = append(descs, "?"+a.flag+": "+a.summary+".")
+			o.Parameters = append(o.Parameters, &Parameter{
+				Name:            a.flag,
+				In:              "query",
+				Description:     a.summary,
+				AllowEmptyValue: true,
+				Schema:          &Schema{Type: "boolean"},
+			})
+		}
+		reqs = append(reqs, g.schema(reflect.TypeOf(a.request)))
+		ress = append(ress, g.schema(reflect.TypeOf(a.response)))
+	}
+	o.Description = strings.Join(descs, " ")
+	o.RequestBody = jsonBody(&Schema{OneOf: reqs})
+	o.Responses["200"] = &Response{
+		Description: "The result of the action",
+		Content: map[string]*MediaType{
+			"application/json": {Schema: &Schema{OneOf: ress}},
+		},
+	}
+	return o
+}
+
+func (r *route) status() int {
+	switch {
+	case r.code != 0:
+		return r.code
+	case r.binary, r.resp != nil:
+		return http.StatusOK
+	}
+	return http.StatusNoContent
+}
+
+func (r *route) response(g *generator) *Response {
+	switch {
+	case r.binary:
+		return &Response{
+			Description: r.summary,
+			Content: map[string]*MediaType{
+				"application/octet-stream": {
+					Schema: &Schema{Type: "string", Format: "binary"},
+				},
+			},
+		}
+	case r.resp != nil:
+		return &Response{
+			Description: r.summary,
+			Content: map[string]*MediaType{
+				"application/json": {
+					Schema: g.schema(reflect.TypeOf(r.resp)),
+				},
+			},
+		}
+	}
+	return &Response{Description: r.summary}
+}
+
+func jsonBody(s *Schema) *RequestBody {
+	return &RequestBody{
+		Required: true,
+		Content:  map[string]*MediaType{"application/json": {Schema: s}},
+	}
+}
+
+// pathParams returns the names of the parameters of a path, ex. service
+// for /volumes/{service}.
+func pathParams(path string) []string {
+	names := []string{}
+	for _, p := range strings.Split(path, "/") {
+		if strings.HasPrefix(p, "{") && strings.HasSuffix(p, "}") {
+			names = append(names, p[1:len(p)-1])
+		}
+	}
+	return names
+}
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// generator generates the schemas of Go types as they are marshaled by
+// package encoding/json. Named structs are added to the components and are
+// referred to by their names.
+type generator struct {
+	schemas map[string]*Schema
+}
+
+func (g *generator) schema(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case reflect.PtrTo(t).Implements(textMarshalerType):
+		return &Schema{Type: "string"}
+	case reflect.PtrTo(t).Implements(jsonMarshalerType):
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{
+			Type:                 "object",
+			AdditionalProperties: g.schema(t.Elem()),
+		}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t)
+		}
+		if _, ok := g.schemas[t.Name()]; !ok {
+			// the name is reserved before the properties are generated so
+			// that recursive types refer to themselves
+			g.schemas[t.Name()] = nil
+			g.schemas[t.Name()] = g.object(t)
+		}
+		return &Schema{Ref: schemaRefPrefix + t.Name()}
+	}
+	return &Schema{}
+}
+
+// object returns the schema of a struct. The fields of embedded structs
+// without names are properties of the struct, as they are marshaled.
+func (g *generator) object(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" || f.PkgPath != "" && !f.Anonymous {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		ft := f.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if name == "" && f.Anonymous && ft.Kind() == reflect.Struct {
+			for k, v := range g.object(ft).Properties {
+				s.Properties[k] = v
+			}
+			continue
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		s.Properties[name] = g.schema(f.Type)
+	}
+	return s
+}
+
+// refs returns the names of the schemas that the document refers to, in
+// order.
+func (d *Document) refs() []string {
+	refs := map[string]bool{}
+	var walk func(s *Schema)
+	walk = func(s *Schema) {
+		if s == nil {
+			return
+		}
+		if s.Ref != "" {
+			refs[strings.TrimPrefix(s.Ref, schemaRefPrefix)] = true
+		}
+		walk(s.Items)
+		walk(s.AdditionalProperties)
+		for _, p := range s.Properties {
+			walk(p)
+		}
+		for _, o := range s.OneOf {
+			walk(o)
+		}
+	}
+	for _, ops := range d.Paths {
+		for _, o := range ops {
+			for _, p := range o.Parameters {
+				walk(p.Schema)
+			}
+			if o.RequestBody != nil {
+				for _, m := range o.RequestBody.Content {
+					walk(m.Schema)
+				}
+			}
+			for _, r := range o.Responses {
+				for _, m := range r.Content {
+					walk(m.Schema)
+				}
+			}
+		}
+	}
+	for _, s := range d.Components.Schemas {
+		walk(s)
+	}
+	names := []string{}
+	for n := range refs {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
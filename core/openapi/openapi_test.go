@@ -0,0 +1,78 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDocument(t *testing.T) {
+	d := New("http://127.0.0.1:7979")
+	if _, err := json.Marshal(d); err != nil {
+		t.Fatal(err)
+	}
+	if d.Info.Version == "" {
+		t.Error("missing version")
+	}
+
+	ids := map[string]bool{}
+	for path, ops := range d.Paths {
+		for method, o := range ops {
+			if ids[o.OperationID] {
+				t.Errorf("duplicate operationId %s", o.OperationID)
+			}
+			ids[o.OperationID] = true
+
+			declared := map[string]bool{}
+			for _, p := range o.Parameters {
+				if p.In == "path" {
+					declared[p.Name] = true
+				}
+			}
+			for _, name := range pathParams(path) {
+				if !declared[name] {
+					t.Errorf("%s %s: undeclared param %s", method, path, name)
+				}
+			}
+			if len(o.Responses) == 0 {
+				t.Errorf("%s %s: no responses", method, path)
+			}
+		}
+	}
+
+	for _, name := range d.refs() {
+		if d.Components.Schemas[name] == nil {
+			t.Errorf("missing schema %s", name)
+		}
+	}
+	if d.Components.Schemas["Volume"] == nil {
+		t.Error("missing schema Volume")
+	}
+}
+
+func TestHandler(t *testing.T) {
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest("GET", Path, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d", w.Code)
+	}
+	d := &Document{}
+	if err := json.Unmarshal(w.Body.Bytes(), d); err != nil {
+		t.Fatal(err)
+	}
+	if d.OpenAPI != "3.0.3" || len(d.Servers) != 0 {
+		t.Fatalf("unexpected document %+v", d)
+	}
+}
+
+func TestServerURL(t *testing.T) {
+	for host, url := range map[string]string{
+		"tcp://127.0.0.1:7979":           "https://127.0.0.1:7979",
+		"unix:///var/run/rexray/ls.sock": "",
+	} {
+		if u := ServerURL(host, true); u != url {
+			t.Errorf("%s: %q != %q", host, u, url)
+		}
+	}
+}
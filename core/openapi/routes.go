@@ -0,0 +1,293 @@
+package openapi
+
+import (
+	apitypes "github.com/emccode/libstorage/api/types"
+)
+
+// route is an operation of the libStorage API. The request and response are
+// values of the types that are marshaled as the operation's bodies.
+type route struct {
+	method  string
+	path    string
+	id      string
+	tag     string
+	summary string
+	params  []*Parameter
+	request interface{}
+	resp    interface{}
+
+	// binary indicates the response is a file.
+	binary bool
+
+	// code is the status of a successful response, if it is not 200 OK for
+	// a response with a body or 204 No Content for one without.
+	code int
+
+	// actions are the operations of a POST route that are selected by flags
+	// in the query, ex. ?attach.
+	actions []*action
+}
+
+// action is an operation of a POST route selected by a flag. The action
+// without a flag is performed if no flag is given.
+type action struct {
+	flag     string
+	summary  string
+	request  interface{}
+	response interface{}
+}
+
+var tags = []*Tag{
+	{Name: "root", Description: "The API's routes"},
+	{Name: "executors", Description: "The executors that clients run"},
+	{Name: "services", Description: "The storage services"},
+	{Name: "volumes", Description: "The volumes of the storage services"},
+	{Name: "snapshots", Description: "The snapshots of the storage services"},
+	{Name: "tasks", Description: "The operations the server runs"},
+}
+
+var (
+	instanceIDHeader = &Parameter{
+		Name: "libStorage-InstanceID",
+		In:   "header",
+		Description: "The instance ID of the client, ex. " +
+			"ebs=i-0123456789abcdef0",
+		Schema: &Schema{Type: "string"},
+	}
+	localDevicesHeader = &Parameter{
+		Name: "libStorage-LocalDevices",
+		In:   "header",
+		Description: "The devices of the client's instance, ex. " +
+			"ebs=/dev/xvda::vol-01,/dev/xvdb::vol-02",
+		Schema: &Schema{Type: "string"},
+	}
+	attachmentsQuery = &Parameter{
+		Name:        "attachments",
+		In:          "query",
+		Description: "Whether the volumes' attachments are included",
+		Schema:      &Schema{Type: "boolean"},
+	}
+	clientParams = []*Parameter{instanceIDHeader, localDevicesHeader}
+	volumeParams = []*Parameter{
+		attachmentsQuery, instanceIDHeader, localDevicesHeader,
+	}
+)
+
+// routes are the operations of the libStorage API.
+var routes = []*route{
+	{
+		method:  "GET",
+		path:    "/",
+		id:      "listRoutes",
+		tag:     "root",
+		summary: "The URLs of the API's routes",
+		resp:    []string{},
+	},
+
+	{
+		method:  "GET",
+		path:    "/executors",
+		id:      "listExecutors",
+		tag:     "executors",
+		summary: "The executors, by name",
+		resp:    map[string]*apitypes.ExecutorInfo{},
+	},
+	{
+		method:  "GET",
+		path:    "/executors/{executor}",
+		id:      "downloadExecutor",
+		tag:     "executors",
+		summary: "The executor's binary",
+		binary:  true,
+	},
+	{
+		method:  "HEAD",
+		path:    "/executors/{executor}",
+		id:      "inspectExecutor",
+		tag:     "executors",
+		summary: "The executor's size and checksum, in the headers",
+		code:    200,
+	},
+
+	{
+		method:  "GET",
+		path:    "/services",
+		id:      "listServices",
+		tag:     "services",
+		summary: "The services, by name",
+		resp:    map[string]*apitypes.ServiceInfo{},
+	},
+	{
+		method:  "GET",
+		path:    "/services/{service}",
+		id:      "inspectService",
+		tag:     "services",
+		summary: "The service",
+		resp:    &apitypes.ServiceInfo{},
+	},
+
+	{
+		method:  "GET",
+		path:    "/volumes",
+		id:      "listVolumes",
+		tag:     "volumes",
+		summary: "The volumes of every service, by service and ID",
+		params:  volumeParams,
+		resp:    map[string]map[string]*apitypes.Volume{},
+	},
+	{
+		method:  "POST",
+		path:    "/volumes",
+		id:      "detachAllVolumes",
+		tag:     "volumes",
+		summary: "Detach the volumes of every service from the instance",
+		params:  clientParams,
+		actions: []*action{{
+			flag:     "detach",
+			summary:  "Detach the volumes of every service from the instance",
+			request:  &apitypes.VolumeDetachRequest{},
+			response: map[string]map[string]*apitypes.Volume{},
+		}},
+	},
+	{
+		method:  "GET",
+		path:    "/volumes/{service}",
+		id:      "listServiceVolumes",
+		tag:     "volumes",
+		summary: "The service's volumes, by ID",
+		params:  volumeParams,
+		resp:    map[string]*apitypes.Volume{},
+	},
+	{
+		method:  "POST",
+		path:    "/volumes/{service}",
+		id:      "createVolume",
+		tag:     "volumes",
+		summary: "Create a volume, or detach the service's volumes",
+		params:  clientParams,
+		actions: []*action{{
+			summary:  "Create a volume",
+			request:  &apitypes.VolumeCreateRequest{},
+			response: &apitypes.Volume{},
+		}, {
+			flag:     "detach",
+			summary:  "Detach the service's volumes from the instance",
+			request:  &apitypes.VolumeDetachRequest{},
+			response: map[string]*apitypes.Volume{},
+		}},
+	},
+	{
+		method:  "GET",
+		path:    "/volumes/{service}/{volumeID}",
+		id:      "inspectVolume",
+		tag:     "volumes",
+		summary: "The volume",
+		params:  volumeParams,
+		resp:    &apitypes.Volume{},
+	},
+	{
+		method:  "POST",
+		path:    "/volumes/{service}/{volumeID}",
+		id:      "volumeAction",
+		tag:     "volumes",
+		summary: "Attach, detach, copy, or snapshot the volume",
+		params:  clientParams,
+		actions: []*action{{
+			flag:     "attach",
+			summary:  "Attach the volume to the instance",
+			request:  &apitypes.VolumeAttachRequest{},
+			response: &apitypes.VolumeAttachResponse{},
+		}, {
+			flag:     "detach",
+			summary:  "Detach the volume from the instance",
+			request:  &apitypes.VolumeDetachRequest{},
+			response: &apitypes.Volume{},
+		}, {
+			flag:     "copy",
+			summary:  "Copy the volume to a new volume",
+			request:  &apitypes.VolumeCopyRequest{},
+			response: &apitypes.Volume{},
+		}, {
+			flag:     "snapshot",
+			summary:  "Snapshot the volume",
+			request:  &apitypes.VolumeSnapshotRequest{},
+			response: &apitypes.Snapshot{},
+		}},
+	},
+	{
+		method:  "DELETE",
+		path:    "/volumes/{service}/{volumeID}",
+		id:      "removeVolume",
+		tag:     "volumes",
+		summary: "Remove the volume",
+		params:  clientParams,
+	},
+
+	{
+		method:  "GET",
+		path:    "/snapshots",
+		id:      "listSnapshots",
+		tag:     "snapshots",
+		summary: "The snapshots of every service, by service and ID",
+		resp:    map[string]map[string]*apitypes.Snapshot{},
+	},
+	{
+		method:  "GET",
+		path:    "/snapshots/{service}",
+		id:      "listServiceSnapshots",
+		tag:     "snapshots",
+		summary: "The service's snapshots, by ID",
+		resp:    map[string]*apitypes.Snapshot{},
+	},
+	{
+		method:  "GET",
+		path:    "/snapshots/{service}/{snapshotID}",
+		id:      "inspectSnapshot",
+		tag:     "snapshots",
+		summary: "The snapshot",
+		resp:    &apitypes.Snapshot{},
+	},
+	{
+		method:  "POST",
+		path:    "/snapshots/{service}/{snapshotID}",
+		id:      "snapshotAction",
+		tag:     "snapshots",
+		summary: "Create a volume from, or copy, the snapshot",
+		params:  clientParams,
+		actions: []*action{{
+			flag:     "create",
+			summary:  "Create a volume from the snapshot",
+			request:  &apitypes.VolumeCreateRequest{},
+			response: &apitypes.Volume{},
+		}, {
+			flag:     "copy",
+			summary:  "Copy the snapshot to a new snapshot",
+			request:  &apitypes.SnapshotCopyRequest{},
+			response: &apitypes.Snapshot{},
+		}},
+	},
+	{
+		method:  "DELETE",
+		path:    "/snapshots/{service}/{snapshotID}",
+		id:      "removeSnapshot",
+		tag:     "snapshots",
+		summary: "Remove the snapshot",
+	},
+
+	{
+		method:  "GET",
+		path:    "/tasks",
+		id:      "listTasks",
+		tag:     "tasks",
+		summary: "The tasks, by ID",
+		resp:    map[string]*apitypes.Task{},
+	},
+	{
+		method:  "GET",
+		path:    "/tasks/{taskID}",
+		id:      "inspectTask",
+		tag:     "tasks",
+		summary: "The task",
+		resp:    &apitypes.Task{},
+	},
+}
@@ -0,0 +1,75 @@
+// Package openfiles finds the processes that hold files open beneath a mount
+// point, similar to lsof, so that busy unmounts can be diagnosed.
+package openfiles
+
+import (
+	"fmt"
+	"strings"
+)
+
+// File is a file held open by a process.
+type File struct {
+
+	// PID is the ID of the process.
+	PID int `json:"pid"`
+
+	// Command is the name of the process's executable.
+	Command string `json:"command,omitempty"`
+
+	// ContainerID is the ID of the container in which the process is
+	// running, if any.
+	ContainerID string `json:"containerID,omitempty"`
+
+	// FD is the file descriptor, or "cwd" or "root" if the path is the
+	// process's working or root directory.
+	FD string `json:"fd"`
+
+	// Path is the path of the file.
+	Path string `json:"path"`
+}
+
+// String returns a description of the file similar to a line of lsof output.
+func (f *File) String() string {
+	s := fmt.Sprintf("%s[%d] %s %s", f.Command, f.PID, f.FD, f.Path)
+	if f.ContainerID != "" {
+		s = fmt.Sprintf("%s (container %s)", s, shortID(f.ContainerID))
+	}
+	return s
+}
+
+// Describe returns a description of the provided files, one per line.
+func Describe(files []*File) string {
+	lines := make([]string, len(files))
+	for i, f := range files {
+		lines[i] = f.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// IncompleteError is returned by List, along with the open files it found,
+// if the files of some processes could not be inspected, ex. because REX-Ray
+// is not permitted to inspect the processes of other users.
+type IncompleteError struct {
+
+	// PIDs are the IDs of the processes that could not be inspected.
+	PIDs []int
+}
+
+func (e *IncompleteError) Error() string {
+	return fmt.Sprintf(
+		"open files of %d processes could not be inspected", len(e.PIDs))
+}
+
+// IsIncomplete returns a flag indicating whether or not the provided error is
+// the result of a list of open files that may be incomplete.
+func IsIncomplete(err error) bool {
+	_, ok := err.(*IncompleteError)
+	return ok
+}
+
+func shortID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
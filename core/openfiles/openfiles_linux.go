@@ -0,0 +1,123 @@
+package openfiles
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/akutz/goof"
+)
+
+var containerIDRx = regexp.MustCompile(`[0-9a-f]{64}`)
+
+// List returns the files on the file system mounted at the provided mount
+// point that are open by any process, including processes whose working or
+// root directory is on it. Files are matched by the device of their file
+// system rather than by their paths, which differ for processes in other
+// mount namespaces such as containers. The returned error is an
+// IncompleteError, along with the files that were found, if some processes
+// could not be inspected.
+func List(mountPoint string) ([]*File, error) {
+	dev, err := device(mountPoint)
+	if err != nil {
+		return nil, err
+	}
+
+	procs, err := ioutil.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+
+	files := []*File{}
+	incomplete := []int{}
+	for _, p := range procs {
+		pid, err := strconv.Atoi(p.Name())
+		if err != nil || pid == os.Getpid() {
+			continue
+		}
+		procDir := filepath.Join("/proc", p.Name())
+
+		links := map[string]string{
+			"cwd":  filepath.Join(procDir, "cwd"),
+			"root": filepath.Join(procDir, "root"),
+		}
+		fds, err := ioutil.ReadDir(filepath.Join(procDir, "fd"))
+		if err != nil && !os.IsNotExist(err) {
+			incomplete = append(incomplete, pid)
+		}
+		for _, fd := range fds {
+			links[fd.Name()] = filepath.Join(procDir, "fd", fd.Name())
+		}
+
+		var cmd, containerID string
+		for fd, l := range links {
+			// processes may exit while they are being inspected
+			d, err := device(l)
+			if err != nil || d != dev {
+				continue
+			}
+			if cmd == "" {
+				cmd = command(procDir)
+				containerID = container(procDir)
+			}
+			target, _ := os.Readlink(l)
+			files = append(files, &File{
+				PID:         pid,
+				Command:     cmd,
+				ContainerID: containerID,
+				FD:          fd,
+				Path:        target,
+			})
+		}
+	}
+
+	if len(incomplete) > 0 {
+		return files, &IncompleteError{PIDs: incomplete}
+	}
+	return files, nil
+}
+
+// device returns the ID of the device of the file system on which the file
+// at the provided path, or that a link in /proc refers to, resides.
+func device(path string) (uint64, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, goof.WithField("path", path, "error reading device")
+	}
+	return uint64(st.Dev), nil
+}
+
+func command(procDir string) string {
+	buf, err := ioutil.ReadFile(filepath.Join(procDir, "comm"))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(buf))
+}
+
+// container returns the ID of the container to which the process belongs by
+// looking for a container ID in the paths of its control groups.
+func container(procDir string) string {
+	f, err := os.Open(filepath.Join(procDir, "cgroup"))
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if id := containerIDRx.FindString(scanner.Text()); id != "" {
+			return id
+		}
+	}
+	return ""
+}
@@ -0,0 +1,91 @@
+package openfiles
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestList(t *testing.T) {
+	dir, err := ioutil.TempDir("", "openfiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// the current process is excluded, so hold the file open in a child
+	path := filepath.Join(dir, "held")
+	cmd := exec.Command("sh", "-c", "exec sleep 10 3>"+path)
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer cmd.Process.Kill()
+
+	for i := 0; i < 50; i++ {
+		files, err := List(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, f := range files {
+			if f.PID == cmd.Process.Pid && f.Path == path && f.FD == "3" {
+				return
+			}
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	t.Fatalf("open file %s not found", path)
+}
+
+func TestListOtherMountNamespace(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("mounting requires root")
+	}
+	if _, err := exec.LookPath("unshare"); err != nil {
+		t.Skip("unshare not found")
+	}
+
+	dir, err := ioutil.TempDir("", "openfiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := syscall.Mount("tmpfs", dir, "tmpfs", 0, ""); err != nil {
+		t.Skipf("error mounting tmpfs: %v", err)
+	}
+	defer syscall.Unmount(dir, 0)
+
+	other, err := ioutil.TempDir("", "openfiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(other)
+
+	// like a container, the child opens the file by a path that exists only
+	// in its own mount namespace
+	path := filepath.Join(other, "held")
+	cmd := exec.Command("unshare", "-m", "sh", "-c", fmt.Sprintf(
+		"mount --bind %s %s && exec sleep 10 3>%s", dir, other, path))
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer cmd.Process.Kill()
+
+	for i := 0; i < 50; i++ {
+		files, err := List(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, f := range files {
+			if f.PID == cmd.Process.Pid && f.Path == path && f.FD == "3" {
+				return
+			}
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	t.Fatalf("open file %s in another mount namespace not found", path)
+}
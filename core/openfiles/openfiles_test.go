@@ -0,0 +1,20 @@
+package openfiles
+
+import (
+	"testing"
+)
+
+func TestString(t *testing.T) {
+	f := &File{
+		PID:         42,
+		Command:     "postgres",
+		ContainerID: "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+		FD:          "7",
+		Path:        "/var/lib/rexray/volumes/data/base/1",
+	}
+	exp := "postgres[42] 7 /var/lib/rexray/volumes/data/base/1 " +
+		"(container 0123456789ab)"
+	if s := f.String(); s != exp {
+		t.Fatalf("String()=%s", s)
+	}
+}
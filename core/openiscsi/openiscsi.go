@@ -0,0 +1,114 @@
+// Package openiscsi manages a node's iSCSI sessions with open-iscsi's
+// iscsiadm, and parses the names open-iscsi and udev give the node's
+// initiator, sessions and LUNs. It is shared by the storage drivers whose
+// nodes attach volumes over iSCSI.
+package openiscsi
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/akutz/goof"
+)
+
+const (
+	// ByPathDir is the directory of the links udev names after the portal,
+	// target and LUN of each iSCSI LUN attached to the node.
+	ByPathDir = "/dev/disk/by-path"
+
+	// InitiatorNameFile is the file in which open-iscsi keeps the node's
+	// initiator name.
+	InitiatorNameFile = "/etc/iscsi/initiatorname.iscsi"
+
+	// defaultPort is the port of a portal whose address has none.
+	defaultPort = "3260"
+)
+
+// Session is a session of the node with a target at one of its portals.
+type Session struct {
+	Portal string
+	Target string
+}
+
+// InitiatorName returns the node's initiator name.
+func InitiatorName() (string, error) {
+	f, err := os.Open(InitiatorNameFile)
+	if err != nil {
+		return "", goof.WithFieldE("path", InitiatorNameFile,
+			"error reading initiator name", err)
+	}
+	defer f.Close()
+	name, ok := ParseInitiatorName(f)
+	if !ok {
+		return "", goof.WithField("path", InitiatorNameFile,
+			"no initiator name")
+	}
+	return name, nil
+}
+
+// ParseInitiatorName returns the initiator name in the contents of an
+// initiatorname.iscsi file.
+func ParseInitiatorName(r io.Reader) (string, bool) {
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		if v := strings.TrimPrefix(line, "InitiatorName="); v != line {
+			return strings.TrimSpace(v), true
+		}
+	}
+	return "", false
+}
+
+// ParseSessions parses the output of iscsiadm -m session, ex.
+// tcp: [1] 10.0.0.1:3260,1 iqn.2003-01.org.linux-iscsi.san:targetd (non-flash)
+func ParseSessions(r io.Reader) []*Session {
+	sessions := []*Session{}
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) < 4 {
+			continue
+		}
+		portal := fields[2]
+		if i := strings.LastIndex(portal, ","); i >= 0 {
+			portal = portal[:i]
+		}
+		sessions = append(sessions, &Session{Portal: portal, Target: fields[3]})
+	}
+	return sessions
+}
+
+// ParseByPath parses the name of a link in ByPathDir, ex.
+// ip-10.0.0.1:3260-iscsi-iqn.2003-01.org.linux-iscsi.san:targetd-lun-3, and
+// returns the target and LUN it names. The returned flag is false if the
+// link is not to an iSCSI LUN or is to a partition of one.
+func ParseByPath(name string) (string, int, bool) {
+	i := strings.Index(name, "-iscsi-")
+	j := strings.LastIndex(name, "-lun-")
+	if i < 0 || j < i+len("-iscsi-") {
+		return "", 0, false
+	}
+	lun, err := strconv.Atoi(name[j+len("-lun-"):])
+	if err != nil {
+		return "", 0, false
+	}
+	return name[i+len("-iscsi-") : j], lun, true
+}
+
+// PortalAddr returns a portal's address with the default iSCSI port if it
+// has none. An IPv6 address without a port is enclosed in brackets.
+func PortalAddr(portal string) string {
+	if strings.HasSuffix(portal, "]") || !strings.Contains(portal, ":") {
+		return portal + ":" + defaultPort
+	}
+	if strings.Count(portal, ":") > 1 && !strings.HasPrefix(portal, "[") {
+		return "[" + portal + "]:" + defaultPort
+	}
+	return portal
+}
@@ -0,0 +1,123 @@
+package openiscsi
+
+import (
+	"bytes"
+	"os/exec"
+	"strings"
+	"syscall"
+
+	"github.com/akutz/goof"
+	apitypes "github.com/emccode/libstorage/api/types"
+)
+
+// exitNoObjects is the status with which iscsiadm exits when there are no
+// sessions.
+const exitNoObjects = 21
+
+// Run runs iscsiadm with the provided arguments and returns its output.
+func Run(args ...string) ([]byte, error) {
+	out, err := exec.Command("iscsiadm", args...).CombinedOutput()
+	if err != nil {
+		return nil, goof.WithFieldsE(map[string]interface{}{
+			"args":   strings.Join(args, " "),
+			"output": string(bytes.TrimSpace(out)),
+		}, "iscsiadm failed", err)
+	}
+	return out, nil
+}
+
+// Sessions returns the node's sessions.
+func Sessions() ([]*Session, error) {
+	out, err := exec.Command("iscsiadm", "-m", "session").Output()
+	if err != nil {
+		if ee, ok := err.(*exec.ExitError); ok {
+			if ws, ok := ee.Sys().(syscall.WaitStatus); ok &&
+				ws.ExitStatus() == exitNoObjects {
+				return nil, nil
+			}
+		}
+		return nil, goof.WithFieldE("command", "iscsiadm",
+			"error listing iscsi sessions", err)
+	}
+	return ParseSessions(bytes.NewReader(out)), nil
+}
+
+// SessionPortals returns the portals of the node's sessions.
+func SessionPortals() (map[string]bool, error) {
+	sessions, err := Sessions()
+	if err != nil {
+		return nil, err
+	}
+	portals := map[string]bool{}
+	for _, s := range sessions {
+		portals[s.Portal] = true
+	}
+	return portals, nil
+}
+
+// Login discovers the targets of and logs in to the portals the node has no
+// session with, and rescans its sessions for LUNs that were mapped to the
+// node since they were established.
+func Login(ctx apitypes.Context, portals []string) error {
+	active, err := SessionPortals()
+	if err != nil {
+		return err
+	}
+	for _, p := range portals {
+		if active[p] {
+			continue
+		}
+		for _, args := range [][]string{
+			{"-m", "discovery", "-t", "sendtargets", "-p", p},
+			{"-m", "node", "-p", p, "--login"},
+		} {
+			if _, err := Run(args...); err != nil {
+				return err
+			}
+		}
+		active[p] = true
+		ctx.WithField("portal", p).Info("logged in to iscsi portal")
+	}
+	if len(active) == 0 {
+		return nil
+	}
+	_, err = Run("-m", "session", "--rescan")
+	return err
+}
+
+// LoginTarget logs in to the target at the portal. The node must have
+// discovered the target, or otherwise created its record, first.
+func LoginTarget(ctx apitypes.Context, target, portal string) error {
+	if _, err := Run(
+		"-m", "node", "-T", target, "-p", portal, "--login"); err != nil {
+		return err
+	}
+	ctx.WithFields(map[string]interface{}{
+		"target": target,
+		"portal": portal,
+	}).Info("logged in to iscsi target")
+	return nil
+}
+
+// LogoutTarget logs out of the node's session with the target at the
+// portal. If forget is set the node's record of the target is deleted too,
+// so that the node does not log in to it again when it boots.
+func LogoutTarget(
+	ctx apitypes.Context, target, portal string, forget bool) error {
+
+	if _, err := Run(
+		"-m", "node", "-T", target, "-p", portal, "--logout"); err != nil {
+		return err
+	}
+	if forget {
+		if _, err := Run("-m", "node", "-o", "delete",
+			"-T", target, "-p", portal); err != nil {
+			return err
+		}
+	}
+	ctx.WithFields(map[string]interface{}{
+		"target": target,
+		"portal": portal,
+	}).Info("logged out of iscsi target")
+	return nil
+}
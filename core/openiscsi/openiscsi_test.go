@@ -0,0 +1,63 @@
+package openiscsi
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseByPath(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		lun    int
+		ok     bool
+	}{
+		{"ip-10.0.0.1:3260-iscsi-iqn.2003-01.org.linux-iscsi.san:targetd-lun-3",
+			"iqn.2003-01.org.linux-iscsi.san:targetd", 3, true},
+		{"ip-10.0.0.1:3260-iscsi-iqn.2003-01.org.linux-iscsi.san:targetd-lun-3-part1",
+			"", 0, false},
+		{"pci-0000:00:1f.2-ata-1", "", 0, false},
+	}
+	for _, tt := range tests {
+		target, lun, ok := ParseByPath(tt.name)
+		if target != tt.target || lun != tt.lun || ok != tt.ok {
+			t.Errorf("ParseByPath(%s)=%s,%d,%v", tt.name, target, lun, ok)
+		}
+	}
+}
+
+func TestParseInitiatorName(t *testing.T) {
+	name, ok := ParseInitiatorName(strings.NewReader(
+		"## DO NOT EDIT\n#InitiatorName=iqn.old\nInitiatorName=iqn.1993-08.org.debian:01:abc\n"))
+	if !ok || name != "iqn.1993-08.org.debian:01:abc" {
+		t.Fatalf("name=%s ok=%v", name, ok)
+	}
+	if _, ok := ParseInitiatorName(strings.NewReader("")); ok {
+		t.Fatal("expected no initiator name")
+	}
+}
+
+func TestParseSessions(t *testing.T) {
+	sessions := ParseSessions(strings.NewReader(
+		"tcp: [1] 10.0.0.1:3260,1 iqn.2003-01.org.linux-iscsi.san:targetd (non-flash)\n" +
+			"tcp: [2] [fd00::1]:3260,1 iqn.2003-01.org.linux-iscsi.san:targetd (non-flash)\n"))
+	if len(sessions) != 2 ||
+		sessions[0].Portal != "10.0.0.1:3260" ||
+		sessions[1].Portal != "[fd00::1]:3260" ||
+		sessions[0].Target != "iqn.2003-01.org.linux-iscsi.san:targetd" {
+		t.Fatalf("sessions=%v", sessions)
+	}
+}
+
+func TestPortalAddr(t *testing.T) {
+	for in, out := range map[string]string{
+		"10.0.0.1":      "10.0.0.1:3260",
+		"10.0.0.1:3261": "10.0.0.1:3261",
+		"[fd00::1]":     "[fd00::1]:3260",
+		"fd00::1":       "[fd00::1]:3260",
+	} {
+		if a := PortalAddr(in); a != out {
+			t.Fatalf("portal=%s addr=%s", in, a)
+		}
+	}
+}
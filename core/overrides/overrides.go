@@ -0,0 +1,111 @@
+// Package overrides records per-volume option overrides, such as a volume's
+// filesystem type or mount options, that are applied the next time the
+// volume is mounted. Overrides are kept in REX-Ray's state rather than the
+// configuration so that they may be changed without restarting anything.
+package overrides
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/akutz/goof"
+
+	"github.com/emccode/rexray/core/state"
+)
+
+const overridesBucket = "volumeOverrides"
+
+const (
+	// FSTypeKey overrides the filesystem type with which a volume is
+	// formatted if it has no filesystem.
+	FSTypeKey = "fsType"
+
+	// ReadOnlyKey overrides whether a volume is mounted read-only.
+	ReadOnlyKey = "readOnly"
+
+	// VolumeModeKey overrides whether a volume is mounted as a file system
+	// or exposed as a raw block device.
+	VolumeModeKey = "volumeMode"
+)
+
+// Overrides are a volume's option overrides. Keys other than the ones
+// handled by REX-Ray are passed to the storage driver as mount options.
+type Overrides map[string]string
+
+// Parse parses a list of key=value strings into a set of overrides.
+func Parse(pairs []string) (Overrides, error) {
+	o := Overrides{}
+	for _, p := range pairs {
+		parts := strings.SplitN(p, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, goof.WithField("override", p, "invalid override")
+		}
+		o[parts[0]] = parts[1]
+	}
+	return o, nil
+}
+
+// Get returns the overrides for the volume with the provided ID.
+func Get(s *state.Store, volumeID string) (Overrides, error) {
+	o := Overrides{}
+	if _, err := s.Get(overridesBucket, volumeID, &o); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// Set merges the provided overrides into the overrides for the volume with
+// the provided ID and returns the result.
+func Set(s *state.Store, volumeID string, set Overrides) (Overrides, error) {
+	o := Overrides{}
+	if err := s.Update(overridesBucket, volumeID, &o,
+		func(bool) (bool, error) {
+			for k, v := range set {
+				o[k] = v
+			}
+			return len(o) > 0, nil
+		}); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// Clear removes the overrides with the provided keys from the volume with
+// the provided ID, or all of its overrides if no keys are provided, and
+// returns the overrides that remain.
+func Clear(s *state.Store, volumeID string, keys ...string) (Overrides, error) {
+	o := Overrides{}
+	if err := s.Update(overridesBucket, volumeID, &o,
+		func(bool) (bool, error) {
+			if len(keys) == 0 {
+				o = Overrides{}
+			}
+			for _, k := range keys {
+				delete(o, k)
+			}
+			return len(o) > 0, nil
+		}); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// List returns the overrides of all volumes indexed by volume ID.
+func List(s *state.Store) (map[string]Overrides, error) {
+	ids, err := s.Keys(overridesBucket)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(ids)
+	all := map[string]Overrides{}
+	for _, id := range ids {
+		o, err := Get(s, id)
+		if err != nil {
+			return nil, err
+		}
+		if len(o) > 0 {
+			all[id] = o
+		}
+	}
+	return all, nil
+}
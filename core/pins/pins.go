@@ -0,0 +1,107 @@
+// Package pins records the volumes that are pinned. A pinned volume may not
+// be unmounted, detached, or removed until it is unpinned, which protects
+// critical volumes from orchestrator operations that would otherwise release
+// them.
+package pins
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/emccode/rexray/core/errcodes"
+	"github.com/emccode/rexray/core/state"
+)
+
+// Bucket is the bucket of the pinned volumes.
+const Bucket = "volumePins"
+
+// Pin describes a pinned volume.
+type Pin struct {
+
+	// VolumeID is the ID of the pinned volume.
+	VolumeID string `json:"volumeID"`
+
+	// Reason is an optional note explaining why the volume is pinned.
+	Reason string `json:"reason,omitempty"`
+
+	// Pinned is the time at which the volume was pinned.
+	Pinned time.Time `json:"pinned"`
+}
+
+// Error is returned when an operation is refused because a volume is pinned.
+type Error struct {
+	Pin *Pin
+
+	// Operation is the operation that was refused.
+	Operation string
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("%s refused: volume %s is pinned",
+		e.Operation, e.Pin.VolumeID)
+	if e.Pin.Reason != "" {
+		msg = fmt.Sprintf("%s: %s", msg, e.Pin.Reason)
+	}
+	return msg
+}
+
+// Status returns the HTTP status code with which the error is reported.
+func (e *Error) Status() int {
+	return http.StatusConflict
+}
+
+// Code returns the code of the error.
+func (e *Error) Code() errcodes.Code {
+	return errcodes.VolumePinned
+}
+
+// Set pins the volume with the provided ID.
+func Set(s *state.Store, volumeID, reason string) (*Pin, error) {
+	p := &Pin{
+		VolumeID: volumeID,
+		Reason:   reason,
+		Pinned:   time.Now().UTC(),
+	}
+	if err := s.Set(Bucket, volumeID, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Remove unpins the volume with the provided ID.
+func Remove(s *state.Store, volumeID string) error {
+	return s.Delete(Bucket, volumeID)
+}
+
+// Get returns the pin for the volume with the provided ID. The returned flag
+// is false if the volume is not pinned.
+func Get(s *state.Store, volumeID string) (*Pin, bool, error) {
+	p := &Pin{}
+	ok, err := s.Get(Bucket, volumeID, p)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	return p, true, nil
+}
+
+// List returns the pinned volumes.
+func List(s *state.Store) ([]*Pin, error) {
+	ids, err := s.Keys(Bucket)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(ids)
+	pins := []*Pin{}
+	for _, id := range ids {
+		p, ok, err := Get(s, id)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			pins = append(pins, p)
+		}
+	}
+	return pins, nil
+}
@@ -0,0 +1,370 @@
+// Package policy wraps a libStorage client so that REX-Ray's volume policies
+// are enforced regardless of whether an operation originates from the CLI or
+// from a daemon module.
+package policy
+
+import (
+	"strings"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	apitypes "github.com/emccode/libstorage/api/types"
+	apiutils "github.com/emccode/libstorage/api/utils"
+	apiclient "github.com/emccode/libstorage/client"
+
+	"github.com/emccode/rexray/core/breaker"
+	"github.com/emccode/rexray/core/errcodes"
+	"github.com/emccode/rexray/core/events"
+	"github.com/emccode/rexray/core/journal"
+	"github.com/emccode/rexray/core/metrics"
+	"github.com/emccode/rexray/core/simulate"
+	"github.com/emccode/rexray/core/state"
+	"github.com/emccode/rexray/core/timeouts"
+	"github.com/emccode/rexray/core/tracing"
+)
+
+func init() {
+	r := gofig.NewRegistration("Volume Policies")
+	r.Key(gofig.Bool, "", false,
+		"Allow read-only attachments of a volume to multiple instances",
+		"rexray.volume.readOnlyMultiAttach")
+	gofig.Register(r)
+}
+
+type client struct {
+	apitypes.Client
+	config gofig.Config
+	store  *state.Store
+
+	// local is the store of the host's own data, such as its mount journal.
+	local *state.Store
+}
+
+// New returns a new libStorage client that enforces REX-Ray's volume
+// policies.
+func New(ctx apitypes.Context, config gofig.Config) (apitypes.Client, error) {
+	if err := ValidateAttachMode(config); err != nil {
+		return nil, err
+	}
+	if err := ValidatePreemptPolicy(config); err != nil {
+		return nil, err
+	}
+	if err := ValidateCrossAZPolicy(config); err != nil {
+		return nil, err
+	}
+	if err := prepareExecutor(ctx, config); err != nil {
+		return nil, err
+	}
+	c, err := apiclient.New(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	c = breaker.Wrap(config, timeouts.Wrap(config, simulate.Wrap(config, c)))
+	return Wrap(config, events.Wrap(config,
+		metrics.Wrap(config, tracing.Wrap(c)))), nil
+}
+
+// Wrap returns a libStorage client that enforces REX-Ray's volume policies
+// before delegating operations to the provided client.
+func Wrap(config gofig.Config, c apitypes.Client) apitypes.Client {
+	return &client{
+		Client: c,
+		config: config,
+		store:  state.Default(),
+		local:  state.Local(),
+	}
+}
+
+func (c *client) Storage() apitypes.StorageDriver {
+	return &storageDriver{StorageDriver: c.Client.Storage(), c: c}
+}
+
+func (c *client) Integration() apitypes.IntegrationDriver {
+	return &integrationDriver{IntegrationDriver: c.Client.Integration(), c: c}
+}
+
+func (c *client) instanceID(ctx apitypes.Context) (string, error) {
+	iid, err := c.Client.Executor().InstanceID(ctx, apiutils.NewStore())
+	if err != nil {
+		return "", err
+	}
+	return iid.ID, nil
+}
+
+// volumeByName returns the volume with the provided name.
+func (c *client) volumeByName(
+	ctx apitypes.Context, name string) (*apitypes.Volume, error) {
+
+	vols, err := c.Client.Storage().Volumes(
+		ctx, &apitypes.VolumesOpts{Attachments: true})
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range vols {
+		if strings.ToLower(v.Name) == strings.ToLower(name) {
+			return v, nil
+		}
+	}
+	return nil, errcodes.New(errcodes.VolumeNotFound,
+		goof.WithField("volumeName", name, "volume not found"))
+}
+
+// volumeID returns the provided volume ID, or if it is empty, the ID of the
+// volume with the provided name.
+func (c *client) volumeID(
+	ctx apitypes.Context, volumeID, volumeName string) (string, error) {
+
+	if volumeID != "" {
+		return volumeID, nil
+	}
+	v, err := c.volumeByName(ctx, volumeName)
+	if err != nil {
+		return "", err
+	}
+	return v.ID, nil
+}
+
+type storageDriver struct {
+	apitypes.StorageDriver
+	c *client
+}
+
+func (d *storageDriver) VolumeAttach(
+	ctx apitypes.Context,
+	volumeID string,
+	opts *apitypes.VolumeAttachOpts) (*apitypes.Volume, string, error) {
+
+	if err := d.c.checkMaintenance(ctx, "volume attach"); err != nil {
+		return nil, "", err
+	}
+	volumeID, err := d.c.recoverAcrossAZ(ctx, volumeID)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := d.c.checkTrashed(ctx, volumeID, "volume attach"); err != nil {
+		return nil, "", err
+	}
+
+	readOnly := IsReadOnly(opts.Opts)
+	preempt, err := d.c.checkPreempt(ctx, volumeID, readOnly)
+	if err != nil {
+		return nil, "", err
+	}
+	if preempt {
+		opts.Force = true
+	}
+
+	if err := d.c.acquireAttachment(
+		ctx, volumeID, readOnly, preempt); err != nil {
+		return nil, "", err
+	}
+
+	vol, token, err := d.StorageDriver.VolumeAttach(ctx, volumeID, opts)
+	if err != nil {
+		d.c.releaseAttachment(ctx, volumeID)
+		return nil, "", err
+	}
+
+	d.c.reconcileExport(ctx, volumeID)
+	return vol, token, nil
+}
+
+func (d *storageDriver) VolumeDetach(
+	ctx apitypes.Context,
+	volumeID string,
+	opts *apitypes.VolumeDetachOpts) (*apitypes.Volume, error) {
+
+	if err := d.c.checkPinned(ctx, volumeID, "volume detach"); err != nil {
+		return nil, err
+	}
+
+	vol, err := d.StorageDriver.VolumeDetach(ctx, volumeID, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	d.c.releaseAttachment(ctx, volumeID)
+	d.c.reconcileExport(ctx, volumeID)
+	return vol, nil
+}
+
+type integrationDriver struct {
+	apitypes.IntegrationDriver
+	c *client
+}
+
+func (d *integrationDriver) Mount(
+	ctx apitypes.Context,
+	volumeID, volumeName string,
+	opts *apitypes.VolumeMountOpts) (string, *apitypes.Volume, error) {
+
+	if err := d.c.checkMaintenance(ctx, "volume mount"); err != nil {
+		return "", nil, err
+	}
+
+	volumeID, volumeName, err := d.c.resolveAdopted(volumeID, volumeName)
+	if err != nil {
+		return "", nil, err
+	}
+
+	id, err := d.c.volumeID(ctx, volumeID, volumeName)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// a volume recreated in the local availability zone is mounted by its
+	// ID since the original volume still has the same name
+	recovered, err := d.c.recoverAcrossAZ(ctx, id)
+	if err != nil {
+		return "", nil, err
+	}
+	if recovered != id {
+		id, volumeID = recovered, recovered
+	}
+
+	if err := d.c.checkTrashed(ctx, id, "volume mount"); err != nil {
+		return "", nil, err
+	}
+
+	if err := d.c.applyOverrides(ctx, id, opts); err != nil {
+		return "", nil, err
+	}
+
+	if err := d.c.checkMountAttachMode(ctx, id); err != nil {
+		return "", nil, err
+	}
+
+	mode, err := GetVolumeMode(opts.Opts)
+	if err != nil {
+		return "", nil, err
+	}
+
+	readOnly := IsReadOnly(opts.Opts)
+	if readOnly && mode == VolumeModeBlock {
+		return "", nil, goof.WithField("volumeID", id,
+			"block volumes may not be mounted read-only")
+	}
+
+	mountShared, err := d.c.sharedMounter(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+	if mountShared != nil && mode == VolumeModeBlock {
+		return "", nil, goof.WithField("volumeID", id,
+			"shared file systems may not be mounted as block volumes")
+	}
+
+	preempt, err := d.c.checkPreempt(ctx, id, readOnly)
+	if err != nil {
+		return "", nil, err
+	}
+	opts.Preempt = preempt
+
+	if err := d.c.acquireAttachment(ctx, id, readOnly, preempt); err != nil {
+		return "", nil, err
+	}
+
+	defer d.c.beginOp(ctx, journal.OpMount, id)()
+
+	var mountPath string
+	var vol *apitypes.Volume
+	switch {
+	case mode == VolumeModeBlock:
+		mountPath, vol, err = d.c.mountBlock(ctx, id, opts)
+	case mountShared != nil:
+		mountPath, vol, err = d.c.mountShared(
+			ctx, id, mountShared, opts, readOnly)
+	case readOnly:
+		mountPath, vol, err = d.c.mountReadOnly(ctx, id, opts)
+	default:
+		mountPath, vol, err = d.IntegrationDriver.Mount(
+			ctx, volumeID, volumeName, opts)
+	}
+	if err != nil {
+		d.c.releaseAttachment(ctx, id)
+		return "", nil, err
+	}
+
+	d.c.reconcileExport(ctx, id)
+
+	// a read-only mount leaves the volume's file system as it is
+	if mode != VolumeModeBlock && !readOnly {
+		if err := d.c.applyPermissions(
+			ctx, id, mountPath, opts.Opts); err != nil {
+			return "", nil, err
+		}
+	}
+
+	if mode != VolumeModeBlock {
+		if err := d.c.applySELinux(
+			ctx, id, mountPath, opts.Opts); err != nil {
+			return "", nil, err
+		}
+	}
+
+	return mountPath, vol, nil
+}
+
+func (d *integrationDriver) Unmount(
+	ctx apitypes.Context,
+	volumeID, volumeName string,
+	opts apitypes.Store) error {
+
+	volumeID, volumeName, err := d.c.resolveAdopted(volumeID, volumeName)
+	if err != nil {
+		return err
+	}
+
+	id, err := d.c.volumeID(ctx, volumeID, volumeName)
+	if err != nil {
+		return err
+	}
+
+	if err := d.c.checkPinned(ctx, id, "volume unmount"); err != nil {
+		return err
+	}
+
+	defer d.c.beginOp(ctx, journal.OpUnmount, id)()
+
+	block, err := isBlockMounted(id)
+	if err != nil {
+		return err
+	}
+	if block {
+		return d.c.unmountBlockAndDetach(ctx, id, opts)
+	}
+
+	shared, err := isSharedMounted(id)
+	if err != nil {
+		return err
+	}
+	if shared {
+		return d.c.unmountSharedAndDetach(ctx, id, opts)
+	}
+
+	ro, err := isReadOnlyMounted(id)
+	if err != nil {
+		return err
+	}
+	if ro {
+		return d.c.unmountReadOnlyAndDetach(ctx, id, opts)
+	}
+
+	// volumes remain attached after an unmount unless they are attached
+	// on mount
+	if GetAttachMode(d.c.config) != AttachOnMount {
+		return d.c.unmountWithEscalation(ctx, id, func() error {
+			return d.c.unmountOnly(ctx, id)
+		})
+	}
+
+	if err := d.c.unmountWithEscalation(ctx, id, func() error {
+		return d.IntegrationDriver.Unmount(ctx, volumeID, volumeName, opts)
+	}); err != nil {
+		return err
+	}
+
+	d.c.releaseAttachment(ctx, id)
+	d.c.reconcileExport(ctx, id)
+	return nil
+}
@@ -0,0 +1,230 @@
+package policy
+
+import (
+	"strings"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/core/errcodes"
+	"github.com/emccode/rexray/core/state"
+)
+
+// AccessMode describes how many instances may attach a volume at once and
+// whether they may write to it.
+type AccessMode string
+
+const (
+	// ReadWriteOnce permits a single instance to attach a volume, read-write
+	// or read-only.
+	ReadWriteOnce AccessMode = "RWO"
+
+	// ReadOnlyMany permits any number of instances to attach a volume
+	// read-only.
+	ReadOnlyMany AccessMode = "ROX"
+
+	// ReadWriteMany permits any number of instances to attach a volume
+	// read-write. Only shared file systems support it.
+	ReadWriteMany AccessMode = "RWX"
+
+	// AccessModeKey is the name of the create option that requests a
+	// volume's access mode.
+	AccessModeKey = "accessMode"
+
+	// AccessModesBucket is the bucket of the volumes' access modes.
+	AccessModesBucket = "volumeAccessModes"
+)
+
+// accessModeCapabilities are the access modes each storage driver supports.
+// Drivers that are not listed support ReadWriteOnce, and ReadOnlyMany if
+// rexray.volume.readOnlyMultiAttach is enabled.
+var accessModeCapabilities = map[string][]AccessMode{
+	"cephfs":    {ReadWriteOnce, ReadOnlyMany, ReadWriteMany},
+	"efs":       {ReadWriteOnce, ReadOnlyMany, ReadWriteMany},
+	"glusterfs": {ReadWriteOnce, ReadOnlyMany, ReadWriteMany},
+	"isilon":    {ReadWriteOnce, ReadOnlyMany, ReadWriteMany},
+	"ontapnas":  {ReadWriteOnce, ReadOnlyMany, ReadWriteMany},
+	"s3fs":      {ReadWriteOnce, ReadOnlyMany, ReadWriteMany},
+	"rbd":       {ReadWriteOnce, ReadOnlyMany},
+}
+
+func init() {
+	r := gofig.NewRegistration("Volume Access Modes")
+	r.Key(gofig.String, "", "",
+		"The access modes the service's driver supports, ex. RWO,ROX; "+
+			"defaults to the driver's known capabilities",
+		"rexray.volume.accessModes")
+	gofig.Register(r)
+}
+
+// ParseAccessMode parses an access mode from its abbreviation or its full
+// name, ex. RWX or ReadWriteMany.
+func ParseAccessMode(s string) (AccessMode, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "rwo", "readwriteonce":
+		return ReadWriteOnce, nil
+	case "rox", "readonlymany":
+		return ReadOnlyMany, nil
+	case "rwx", "readwritemany":
+		return ReadWriteMany, nil
+	}
+	return "", goof.WithField("accessMode", s, "invalid access mode")
+}
+
+// Permits returns a flag indicating whether a volume with the access mode
+// may be attached with the requested access mode.
+func (m AccessMode) Permits(requested AccessMode, readOnly bool) bool {
+	switch m {
+	case ReadWriteMany:
+		return true
+	case ReadOnlyMany:
+		return readOnly
+	}
+	return requested == ReadWriteOnce
+}
+
+// SupportedAccessModes returns the access modes supported by the storage
+// driver with the provided name. Modes configured with
+// rexray.volume.accessModes, or rexray.volume.services.SERVICE.accessModes,
+// take precedence over the driver's known capabilities.
+func SupportedAccessModes(
+	config gofig.Config, driverName string) ([]AccessMode, error) {
+
+	if v := serviceString(config, "accessModes"); v != "" {
+		modes := []AccessMode{}
+		for _, s := range strings.Split(v, ",") {
+			m, err := ParseAccessMode(s)
+			if err != nil {
+				return nil, err
+			}
+			modes = append(modes, m)
+		}
+		return modes, nil
+	}
+
+	if modes, ok := accessModeCapabilities[strings.ToLower(driverName)]; ok {
+		return modes, nil
+	}
+	modes := []AccessMode{ReadWriteOnce}
+	if config.GetBool("rexray.volume.readOnlyMultiAttach") {
+		modes = append(modes, ReadOnlyMany)
+	}
+	return modes, nil
+}
+
+// ServiceAccessModes returns the access modes supported by the driver of
+// the configured service.
+func ServiceAccessModes(
+	ctx apitypes.Context,
+	config gofig.Config,
+	client apitypes.Client) ([]AccessMode, error) {
+
+	driver, err := ServiceDriverName(ctx, config, client)
+	if err != nil {
+		return nil, err
+	}
+	return SupportedAccessModes(config, driver)
+}
+
+// GetVolumeAccessMode returns the access mode recorded for the volume with
+// the provided ID. The returned flag is false if the volume was created
+// without one.
+func GetVolumeAccessMode(
+	s *state.Store, volumeID string) (AccessMode, bool, error) {
+
+	var m AccessMode
+	ok, err := s.Get(AccessModesBucket, volumeID, &m)
+	if err != nil || !ok {
+		return "", false, err
+	}
+	return m, true, nil
+}
+
+// createAccessMode returns the access mode requested in a volume's create
+// options and returns an error if the service's driver does not support it.
+// The returned mode is empty if none was requested.
+func (c *client) createAccessMode(
+	ctx apitypes.Context, opts *apitypes.VolumeCreateOpts) (AccessMode, error) {
+
+	if opts == nil || opts.Opts == nil ||
+		opts.Opts.GetString(AccessModeKey) == "" {
+		return "", nil
+	}
+	m, err := ParseAccessMode(opts.Opts.GetString(AccessModeKey))
+	if err != nil {
+		return "", err
+	}
+
+	supported, err := ServiceAccessModes(ctx, c.config, c.Client)
+	if err != nil {
+		return "", err
+	}
+	for _, s := range supported {
+		if s == m {
+			return m, nil
+		}
+	}
+	return "", goof.WithFields(goof.Fields{
+		"accessMode": m,
+		"supported":  supported,
+	}, "access mode not supported by driver")
+}
+
+func (c *client) setVolumeAccessMode(
+	ctx apitypes.Context, volumeID string, m AccessMode) {
+
+	var err error
+	if m == "" {
+		err = state.Controller().Delete(AccessModesBucket, volumeID)
+	} else {
+		err = state.Controller().Set(AccessModesBucket, volumeID, m)
+	}
+	if err != nil {
+		ctx.WithError(err).WithField("volumeID", volumeID).Warn(
+			"error storing volume access mode")
+	}
+}
+
+// CheckAccessMode returns an error if the volume's access mode does not
+// permit attaching it to the instance with the provided ID alongside its
+// attachments to other instances, whose modes are keyed by instance ID.
+// Volumes without an access mode may be attached read-write by a single
+// instance, or read-only by many.
+func CheckAccessMode(
+	volumeID, iid string,
+	access AccessMode,
+	readOnly bool,
+	modes map[string]string) error {
+
+	if access == ReadOnlyMany && !readOnly {
+		return errcodes.New(errcodes.InvalidRequest, goof.WithFields(
+			goof.Fields{
+				"volumeID":   volumeID,
+				"accessMode": access,
+			}, "volume may only be attached read-only"))
+	}
+
+	for otherID, mode := range modes {
+		if otherID == iid || access == ReadWriteMany {
+			continue
+		}
+		if access == ReadWriteOnce {
+			return errcodes.New(errcodes.AlreadyAttached, goof.WithFields(
+				goof.Fields{
+					"volumeID":   volumeID,
+					"instanceID": otherID,
+					"accessMode": access,
+				}, "volume may only be attached to a single instance"))
+		}
+		if !readOnly || mode == modeReadWrite {
+			return errcodes.New(errcodes.AlreadyAttached, goof.WithFields(
+				goof.Fields{
+					"volumeID":   volumeID,
+					"instanceID": otherID,
+					"mode":       mode,
+				}, "read-write attachment excludes all other attachments"))
+		}
+	}
+	return nil
+}
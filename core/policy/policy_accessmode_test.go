@@ -0,0 +1,39 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/emccode/rexray/core/errcodes"
+)
+
+func TestCheckAccessMode(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		access   AccessMode
+		readOnly bool
+		modes    map[string]string
+		code     errcodes.Code
+	}{
+		{"rwo first", ReadWriteOnce, false, nil, ""},
+		{"rwo same instance", ReadWriteOnce, false,
+			map[string]string{"i-1": modeReadWrite}, ""},
+		{"rwo second", ReadWriteOnce, true,
+			map[string]string{"i-2": modeReadOnly}, errcodes.AlreadyAttached},
+		{"rox read-write", ReadOnlyMany, false, nil, errcodes.InvalidRequest},
+		{"rox read-only", ReadOnlyMany, true,
+			map[string]string{"i-2": modeReadOnly}, ""},
+		{"rwx read-write", ReadWriteMany, false,
+			map[string]string{"i-2": modeReadWrite}, ""},
+		{"none read-only", "", true,
+			map[string]string{"i-2": modeReadOnly}, ""},
+		{"none read-only beside read-write", "", true,
+			map[string]string{"i-2": modeReadWrite}, errcodes.AlreadyAttached},
+		{"none read-write beside read-only", "", false,
+			map[string]string{"i-2": modeReadOnly}, errcodes.AlreadyAttached},
+	} {
+		err := CheckAccessMode("vol-1", "i-1", tc.access, tc.readOnly, tc.modes)
+		if got := errcodes.Of(err); got != tc.code {
+			t.Errorf("%s: code=%q err=%v", tc.name, got, err)
+		}
+	}
+}
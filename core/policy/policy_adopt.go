@@ -0,0 +1,48 @@
+package policy
+
+import (
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/core/adoption"
+	"github.com/emccode/rexray/core/state"
+)
+
+// resolveAdopted replaces the name of an adopted volume with its ID since the
+// storage platform does not know the volume by the name with which it was
+// adopted.
+func (c *client) resolveAdopted(
+	volumeID, volumeName string) (string, string, error) {
+
+	if volumeID != "" || volumeName == "" {
+		return volumeID, volumeName, nil
+	}
+	r, ok, err := adoption.ByName(state.Controller(), volumeName)
+	if err != nil {
+		return "", "", err
+	}
+	if !ok {
+		return volumeID, volumeName, nil
+	}
+	return r.VolumeID, "", nil
+}
+
+func (d *integrationDriver) Path(
+	ctx apitypes.Context,
+	volumeID, volumeName string,
+	opts apitypes.Store) (string, error) {
+
+	volumeID, volumeName, err := d.c.resolveAdopted(volumeID, volumeName)
+	if err != nil {
+		return "", err
+	}
+	if mountPath, ok := d.c.blockPath(ctx, volumeID, volumeName); ok {
+		return mountPath, nil
+	}
+	if mountPath, ok := d.c.sharedPath(ctx, volumeID, volumeName); ok {
+		return mountPath, nil
+	}
+	if mountPath, ok := d.c.readOnlyPath(ctx, volumeID, volumeName); ok {
+		return mountPath, nil
+	}
+	return d.IntegrationDriver.Path(ctx, volumeID, volumeName, opts)
+}
@@ -0,0 +1,152 @@
+package policy
+
+import (
+	"github.com/akutz/goof"
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/core/state"
+)
+
+const (
+	// ReadOnlyKey is the name of the option used to request a read-only
+	// attachment or mount.
+	ReadOnlyKey = "readOnly"
+
+	// AttachModesBucket is the bucket of the modes with which volumes are
+	// attached to each instance.
+	AttachModesBucket = "attachModes"
+
+	modeReadOnly  = "ro"
+	modeReadWrite = "rw"
+)
+
+// IsReadOnly returns a flag indicating whether or not the provided options
+// request a read-only attachment.
+func IsReadOnly(opts apitypes.Store) bool {
+	return opts != nil && opts.GetBool(ReadOnlyKey)
+}
+
+// acquireAttachment records the attachment of a volume to the local instance.
+// An attachment is refused if the volume's access mode does not permit it
+// alongside the volume's other attachments. If the volume is being preempted
+// the records of all other attachments are discarded instead. The records
+// are kept in the controller's state, and updated atomically, so that the
+// attachments of every instance are known to the others.
+func (c *client) acquireAttachment(
+	ctx apitypes.Context, volumeID string, readOnly, preempt bool) error {
+
+	iid, err := c.instanceID(ctx)
+	if err != nil {
+		return err
+	}
+
+	access, ok, err := GetVolumeAccessMode(state.Controller(), volumeID)
+	if err != nil {
+		return err
+	}
+
+	if !ok && readOnly &&
+		!c.config.GetBool("rexray.volume.readOnlyMultiAttach") {
+		return goof.WithField("volumeID", volumeID,
+			"read-only multi-attach is not enabled")
+	}
+
+	attached, err := c.attachedInstances(ctx, volumeID, preempt)
+	if err != nil {
+		return err
+	}
+
+	mode := modeReadWrite
+	if readOnly {
+		mode = modeReadOnly
+	}
+
+	modes := map[string]string{}
+	if err := state.Controller().Update(AttachModesBucket, volumeID, &modes,
+		func(bool) (bool, error) {
+			if preempt {
+				for id := range modes {
+					delete(modes, id)
+				}
+			}
+			// attachments not recorded by REX-Ray are assumed to be
+			// read-write
+			for _, id := range attached {
+				if _, ok := modes[id]; !ok {
+					modes[id] = modeReadWrite
+				}
+			}
+			if err := CheckAccessMode(
+				volumeID, iid, access, readOnly, modes); err != nil {
+				return false, err
+			}
+			modes[iid] = mode
+			return true, nil
+		}); err != nil {
+		return err
+	}
+
+	ctx.WithFields(map[string]interface{}{
+		"volumeID":   volumeID,
+		"instanceID": iid,
+		"mode":       mode,
+	}).Debug("recorded volume attachment mode")
+	return nil
+}
+
+// attachedInstances returns the IDs of the instances to which the storage
+// platform reports a volume is attached, or none if the volume is being
+// preempted.
+func (c *client) attachedInstances(
+	ctx apitypes.Context, volumeID string, preempt bool) ([]string, error) {
+
+	if preempt {
+		return nil, nil
+	}
+
+	vol, err := c.Client.Storage().VolumeInspect(
+		ctx, volumeID, &apitypes.VolumeInspectOpts{Attachments: true})
+	if err != nil {
+		return nil, err
+	}
+
+	ids := []string{}
+	for _, a := range vol.Attachments {
+		if a.InstanceID != nil {
+			ids = append(ids, a.InstanceID.ID)
+		}
+	}
+	return ids, nil
+}
+
+// GetAttachModes returns the recorded attachment modes of a volume, keyed by
+// instance ID.
+func GetAttachModes(
+	s *state.Store, volumeID string) (map[string]string, error) {
+
+	modes := map[string]string{}
+	if _, err := s.Get(AttachModesBucket, volumeID, &modes); err != nil {
+		return nil, err
+	}
+	return modes, nil
+}
+
+// releaseAttachment removes the record of a volume's attachment to the local
+// instance.
+func (c *client) releaseAttachment(ctx apitypes.Context, volumeID string) {
+
+	iid, err := c.instanceID(ctx)
+	if err != nil {
+		ctx.WithError(err).Warn("error getting instance ID")
+		return
+	}
+
+	modes := map[string]string{}
+	if err := state.Controller().Update(AttachModesBucket, volumeID, &modes,
+		func(bool) (bool, error) {
+			delete(modes, iid)
+			return len(modes) > 0, nil
+		}); err != nil {
+		ctx.WithError(err).Warn("error writing volume attachment modes")
+	}
+}
@@ -0,0 +1,155 @@
+package policy
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/akutz/gofig"
+	"github.com/emccode/libstorage/api/context"
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/core/errcodes"
+	"github.com/emccode/rexray/core/state"
+)
+
+// fakeClient is a libStorage client of the instance with the provided ID
+// whose storage platform reports the provided attachments.
+type fakeClient struct {
+	apitypes.Client
+	iid      string
+	attached []string
+}
+
+func (c *fakeClient) Executor() apitypes.StorageExecutorCLI {
+	return &fakeExecutor{iid: c.iid}
+}
+
+func (c *fakeClient) Storage() apitypes.StorageDriver {
+	return &fakeStorage{attached: c.attached}
+}
+
+type fakeExecutor struct {
+	apitypes.StorageExecutorCLI
+	iid string
+}
+
+func (e *fakeExecutor) InstanceID(
+	ctx apitypes.Context, opts apitypes.Store) (*apitypes.InstanceID, error) {
+
+	return &apitypes.InstanceID{ID: e.iid, Driver: "fake"}, nil
+}
+
+type fakeStorage struct {
+	apitypes.StorageDriver
+	attached []string
+}
+
+func (s *fakeStorage) VolumeInspect(
+	ctx apitypes.Context,
+	volumeID string,
+	opts *apitypes.VolumeInspectOpts) (*apitypes.Volume, error) {
+
+	vol := &apitypes.Volume{ID: volumeID}
+	for _, id := range s.attached {
+		vol.Attachments = append(vol.Attachments, &apitypes.VolumeAttachment{
+			VolumeID:   volumeID,
+			InstanceID: &apitypes.InstanceID{ID: id, Driver: "fake"},
+		})
+	}
+	return vol, nil
+}
+
+// newTestClient returns a policy client of the instance with the provided
+// ID.
+func newTestClient(
+	config gofig.Config, iid string, attached ...string) *client {
+
+	return &client{
+		Client: &fakeClient{iid: iid, attached: attached},
+		config: config,
+	}
+}
+
+// newTestConfig returns a configuration with which the controller's state
+// is kept in a temporary file.
+func newTestConfig(t *testing.T) (gofig.Config, func()) {
+	dir, err := ioutil.TempDir("", "policy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := gofig.New()
+	config.Set("rexray.state.path", filepath.Join(dir, "state.json"))
+	state.Configure(config)
+	return config, func() {
+		state.Configure(nil)
+		os.RemoveAll(dir)
+	}
+}
+
+func TestAcquireAttachment(t *testing.T) {
+	config, cleanup := newTestConfig(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	// read-only attachments of volumes without an access mode require
+	// read-only multi-attach
+	c1 := newTestClient(config, "i-1")
+	if err := c1.acquireAttachment(ctx, "vol-1", true, false); err == nil {
+		t.Fatal("expected a read-only attachment to be refused")
+	}
+	config.Set("rexray.volume.readOnlyMultiAttach", true)
+
+	if err := c1.acquireAttachment(ctx, "vol-1", true, false); err != nil {
+		t.Fatal(err)
+	}
+	c2 := newTestClient(config, "i-2", "i-1")
+	if err := c2.acquireAttachment(ctx, "vol-1", true, false); err != nil {
+		t.Fatal(err)
+	}
+	modes, err := GetAttachModes(state.Controller(), "vol-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(modes) != 2 || modes["i-1"] != modeReadOnly ||
+		modes["i-2"] != modeReadOnly {
+		t.Fatalf("modes=%v", modes)
+	}
+
+	// a read-write attachment excludes the read-only ones
+	c3 := newTestClient(config, "i-3", "i-1", "i-2")
+	err = c3.acquireAttachment(ctx, "vol-1", false, false)
+	if errcodes.Of(err) != errcodes.AlreadyAttached {
+		t.Fatalf("err=%v", err)
+	}
+
+	// an attachment the platform reports but REX-Ray did not record is
+	// assumed to be read-write
+	c4 := newTestClient(config, "i-4", "i-5")
+	err = c4.acquireAttachment(ctx, "vol-2", true, false)
+	if errcodes.Of(err) != errcodes.AlreadyAttached {
+		t.Fatalf("err=%v", err)
+	}
+
+	// a preempting attachment discards the records of the others
+	if err := c3.acquireAttachment(ctx, "vol-1", false, true); err != nil {
+		t.Fatal(err)
+	}
+	if modes, err = GetAttachModes(state.Controller(), "vol-1"); err != nil {
+		t.Fatal(err)
+	}
+	if len(modes) != 1 || modes["i-3"] != modeReadWrite {
+		t.Fatalf("modes=%v", modes)
+	}
+
+	// an access mode of ReadOnlyMany refuses read-write attachments
+	if err := state.Controller().Set(
+		AccessModesBucket, "vol-3", ReadOnlyMany); err != nil {
+		t.Fatal(err)
+	}
+	err = c1.acquireAttachment(ctx, "vol-3", false, false)
+	if errcodes.Of(err) != errcodes.InvalidRequest {
+		t.Fatalf("err=%v", err)
+	}
+}
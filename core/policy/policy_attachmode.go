@@ -0,0 +1,181 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	apitypes "github.com/emccode/libstorage/api/types"
+	apiutils "github.com/emccode/libstorage/api/utils"
+)
+
+// AttachMode describes when a volume is attached to and detached from an
+// instance.
+type AttachMode string
+
+const (
+	// AttachOnMount attaches a volume when it is mounted and detaches the
+	// volume when it is unmounted. This is libStorage's default behavior.
+	AttachOnMount AttachMode = "onMount"
+
+	// AttachPersistent attaches a volume when it is mounted if it is not
+	// already attached, but leaves the volume attached after it is unmounted.
+	AttachPersistent AttachMode = "persistent"
+
+	// AttachManual requires a volume be attached explicitly before it may be
+	// mounted, and leaves the volume attached after it is unmounted.
+	AttachManual AttachMode = "manual"
+
+	configPreempt = "libstorage.integration.volume.operations.mount.preempt"
+)
+
+func init() {
+	r := gofig.NewRegistration("Volume Attach Mode")
+	r.Key(gofig.String, "", string(AttachOnMount),
+		"When volumes are attached (onMount, persistent, manual)",
+		"rexray.volume.attachMode")
+	gofig.Register(r)
+}
+
+// GetAttachMode returns the attach mode for the service configured in the
+// provided config. A mode defined for the service with the key
+// rexray.volume.services.SERVICE.attachMode takes precedence over the global
+// rexray.volume.attachMode key.
+func GetAttachMode(config gofig.Config) AttachMode {
+	v := serviceString(config, "attachMode")
+	switch strings.ToLower(v) {
+	case "", strings.ToLower(string(AttachOnMount)):
+		return AttachOnMount
+	case strings.ToLower(string(AttachPersistent)):
+		return AttachPersistent
+	case strings.ToLower(string(AttachManual)):
+		return AttachManual
+	}
+	return AttachMode(v)
+}
+
+// serviceKey returns the service-specific form of the provided volume option,
+// or an empty string if no service is configured.
+func serviceKey(config gofig.Config, name string) string {
+	svc := config.GetString(apitypes.ConfigService)
+	if svc == "" {
+		return ""
+	}
+	return fmt.Sprintf("rexray.volume.services.%s.%s", svc, name)
+}
+
+// isServiceKeySet returns a flag indicating whether or not the provided volume
+// option is set either globally or for the configured service.
+func isServiceKeySet(config gofig.Config, name string) bool {
+	if k := serviceKey(config, name); k != "" && config.IsSet(k) {
+		return true
+	}
+	return config.IsSet("rexray.volume." + name)
+}
+
+// serviceString returns the value of the provided volume option. A value
+// defined for the configured service takes precedence over the global one.
+func serviceString(config gofig.Config, name string) string {
+	if k := serviceKey(config, name); k != "" && config.IsSet(k) {
+		return config.GetString(k)
+	}
+	return config.GetString("rexray.volume." + name)
+}
+
+// ValidateAttachMode returns an error if the configured attach mode is
+// unknown or conflicts with other volume options:
+//
+//   - onMount permits preemption; a volume attached to another instance is
+//     forcefully detached from it when mounted
+//   - persistent permits preemption; a volume remains attached after it is
+//     unmounted, so preemption is the only way another instance may mount it
+//     before it is detached explicitly
+//   - manual forbids preemption; a volume is never attached as part of a
+//     mount operation, so there is nothing for a mount to preempt
+func ValidateAttachMode(config gofig.Config) error {
+	mode := GetAttachMode(config)
+	switch mode {
+	case AttachOnMount, AttachPersistent:
+		return nil
+	case AttachManual:
+		if p := GetPreemptPolicy(config); p != PreemptNever {
+			return goof.WithFields(goof.Fields{
+				"attachMode": mode,
+				"preempt":    p,
+			}, "volume preemption requires attachments occur at mount time")
+		}
+		return nil
+	}
+	return goof.WithField("attachMode", mode, "invalid attach mode")
+}
+
+// isAttachedLocally returns a flag indicating whether or not the volume is
+// attached to the local instance.
+func (c *client) isAttachedLocally(
+	ctx apitypes.Context, volumeID string) (*apitypes.Volume, bool, error) {
+
+	iid, err := c.instanceID(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	vol, err := c.Client.Storage().VolumeInspect(
+		ctx, volumeID, &apitypes.VolumeInspectOpts{Attachments: true})
+	if err != nil {
+		return nil, false, err
+	}
+
+	for _, a := range vol.Attachments {
+		if a.InstanceID != nil && a.InstanceID.ID == iid {
+			return vol, true, nil
+		}
+	}
+	return vol, false, nil
+}
+
+// checkMountAttachMode returns an error if the volume may not be mounted
+// under the configured attach mode.
+func (c *client) checkMountAttachMode(
+	ctx apitypes.Context, volumeID string) error {
+
+	if GetAttachMode(c.config) != AttachManual {
+		return nil
+	}
+
+	_, attached, err := c.isAttachedLocally(ctx, volumeID)
+	if err != nil {
+		return err
+	}
+	if !attached {
+		return goof.WithFields(goof.Fields{
+			"volumeID":   volumeID,
+			"attachMode": AttachManual,
+		}, "volume must be attached before it is mounted")
+	}
+	return nil
+}
+
+// unmountOnly unmounts all of the local mounts of a volume's device without
+// detaching the volume.
+func (c *client) unmountOnly(ctx apitypes.Context, volumeID string) error {
+
+	mountPoints, err := c.localMountPoints(ctx, volumeID)
+	if err != nil {
+		return err
+	}
+
+	for _, mp := range mountPoints {
+		ctx.WithFields(map[string]interface{}{
+			"volumeID":   volumeID,
+			"mountPoint": mp,
+		}).Debug("unmounting volume without detaching")
+
+		if err := c.Client.OS().Unmount(
+			ctx, mp, apiutils.NewStore()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,294 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	apitypes "github.com/emccode/libstorage/api/types"
+	apiutils "github.com/emccode/libstorage/api/utils"
+
+	"github.com/emccode/rexray/core/ebs"
+	"github.com/emccode/rexray/core/errcodes"
+	"github.com/emccode/rexray/core/events"
+	"github.com/emccode/rexray/core/state"
+	"github.com/emccode/rexray/core/trash"
+)
+
+// CrossAZPolicy describes what happens when an EBS volume is attached to an
+// instance in another availability zone than the volume's.
+type CrossAZPolicy string
+
+const (
+	// CrossAZFail refuses to attach the volume.
+	CrossAZFail CrossAZPolicy = "fail"
+
+	// CrossAZRecreate recreates the volume in the instance's availability
+	// zone from the volume's latest snapshot and moves the original volume
+	// to the trash.
+	CrossAZRecreate CrossAZPolicy = "recreate"
+
+	azRecoveriesBucket = "azRecoveries"
+)
+
+func init() {
+	r := gofig.NewRegistration("Cross-AZ Recovery")
+	r.Key(gofig.String, "", string(CrossAZFail),
+		"What happens when an EBS volume is attached in another "+
+			"availability zone (fail, recreate)",
+		"rexray.volume.crossAZ.policy")
+	r.Key(gofig.String, "", "",
+		"The age of the newest snapshot beyond which a volume is not "+
+			"recreated; any snapshot is used if empty",
+		"rexray.volume.crossAZ.maxSnapshotAge")
+	gofig.Register(r)
+}
+
+// AZRecovery records a volume that was recreated in another availability
+// zone.
+type AZRecovery struct {
+	SourceVolumeID   string    `json:"sourceVolumeID"`
+	VolumeID         string    `json:"volumeID"`
+	SnapshotID       string    `json:"snapshotID"`
+	AvailabilityZone string    `json:"availabilityZone"`
+	Time             time.Time `json:"time"`
+}
+
+// GetCrossAZPolicy returns the configured cross-AZ policy.
+func GetCrossAZPolicy(config gofig.Config) CrossAZPolicy {
+	v := config.GetString("rexray.volume.crossAZ.policy")
+	if strings.EqualFold(v, string(CrossAZRecreate)) {
+		return CrossAZRecreate
+	}
+	return CrossAZFail
+}
+
+// ValidateCrossAZPolicy returns an error if the configured cross-AZ policy
+// or maximum snapshot age is invalid.
+func ValidateCrossAZPolicy(config gofig.Config) error {
+	v := config.GetString("rexray.volume.crossAZ.policy")
+	switch CrossAZPolicy(v) {
+	case "", CrossAZFail, CrossAZRecreate:
+	default:
+		return goof.WithField("policy", v, "invalid cross-AZ policy")
+	}
+	if _, err := maxSnapshotAge(config); err != nil {
+		return err
+	}
+	return nil
+}
+
+func maxSnapshotAge(config gofig.Config) (time.Duration, error) {
+	v := config.GetString("rexray.volume.crossAZ.maxSnapshotAge")
+	if v == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, goof.WithFieldE(
+			"maxSnapshotAge", v, "invalid maximum snapshot age", err)
+	}
+	return d, nil
+}
+
+// recoverAcrossAZ returns the ID of the volume to attach in place of the
+// volume with the provided ID. If the cross-AZ policy is recreate and the
+// volume is an EBS volume in another availability zone than the local
+// instance, the volume is recreated in the local availability zone from its
+// latest snapshot, unless that was already done, and the new volume's ID is
+// returned. Otherwise the provided ID is returned. A volume that is attached
+// to any instance, or is not available, is not recreated since it may still
+// be written after its snapshot was taken.
+func (c *client) recoverAcrossAZ(
+	ctx apitypes.Context, volumeID string) (string, error) {
+
+	if GetCrossAZPolicy(c.config) != CrossAZRecreate {
+		return volumeID, nil
+	}
+
+	driver, err := c.driverName(ctx)
+	if err != nil || !ebs.IsDriver(driver) {
+		return volumeID, err
+	}
+
+	id, err := ebs.NewMetadataFromConfig(c.config).Identity()
+	if err != nil {
+		return "", err
+	}
+	az := id.AvailabilityZone
+	if az == "" {
+		return volumeID, nil
+	}
+
+	rec := &AZRecovery{}
+	ok, err := c.store.Get(azRecoveriesBucket, volumeID, rec)
+	if err != nil {
+		return "", err
+	}
+	if ok && rec.AvailabilityZone == az {
+		return rec.VolumeID, nil
+	}
+
+	vol, err := c.Client.Storage().VolumeInspect(
+		ctx, volumeID, &apitypes.VolumeInspectOpts{Attachments: true})
+	if err != nil {
+		return "", err
+	}
+	if vol.AvailabilityZone == "" || vol.AvailabilityZone == az {
+		return volumeID, nil
+	}
+	if err := checkRecreatable(vol); err != nil {
+		return "", err
+	}
+
+	snap, err := c.latestSnapshot(ctx, volumeID)
+	if err != nil {
+		return "", err
+	}
+
+	fields := map[string]interface{}{
+		"volumeID":         volumeID,
+		"volumeZone":       vol.AvailabilityZone,
+		"availabilityZone": az,
+		"snapshotID":       snap.ID,
+	}
+	ctx.WithFields(fields).Warn(
+		"recreating volume in local availability zone from snapshot")
+
+	access, _, err := GetVolumeAccessMode(state.Controller(), volumeID)
+	if err != nil {
+		return "", err
+	}
+
+	newVol, err := c.Storage().VolumeCreateFromSnapshot(
+		ctx, snap.ID, vol.Name, &apitypes.VolumeCreateOpts{
+			AvailabilityZone: &az,
+			IOPS:             &vol.IOPS,
+			Size:             &vol.Size,
+			Type:             &vol.Type,
+			Opts:             apiutils.NewStore(),
+		})
+	if err != nil {
+		return "", goof.WithFieldsE(fields,
+			"error recreating volume in local availability zone", err)
+	}
+	c.setVolumeAccessMode(ctx, newVol.ID, access)
+
+	// the original volume is hidden so that its name refers to the new
+	// volume, but it is kept until the trash is purged in case the
+	// snapshot was older than its data
+	e, err := trash.Add(c.config, c.store, vol)
+	if err != nil {
+		return "", err
+	}
+	if err := c.markTrashed(ctx, e); err != nil {
+		ctx.WithFields(fields).WithError(err).Warn(
+			"error marking original volume as trashed")
+	}
+
+	rec = &AZRecovery{
+		SourceVolumeID:   volumeID,
+		VolumeID:         newVol.ID,
+		SnapshotID:       snap.ID,
+		AvailabilityZone: az,
+		Time:             time.Now().UTC(),
+	}
+	if err := c.store.Set(azRecoveriesBucket, volumeID, rec); err != nil {
+		return "", err
+	}
+
+	events.Emit(c.store, &events.Event{
+		Type:     events.VolumeRecreated,
+		VolumeID: newVol.ID,
+		Message: fmt.Sprintf("volume recreated in %s from snapshot %s",
+			az, snap.ID),
+		Fields: map[string]string{
+			"sourceVolumeID":   volumeID,
+			"snapshotID":       snap.ID,
+			"availabilityZone": az,
+			"volumeName":       vol.Name,
+		},
+	})
+
+	return newVol.ID, nil
+}
+
+// checkRecreatable returns an error unless a volume in another availability
+// zone is available and attached to no instance. An attached volume's
+// instance may still be using it, and the volume's data would be split
+// between the two volumes if it were recreated.
+func checkRecreatable(vol *apitypes.Volume) error {
+	fields := goof.Fields{
+		"volumeID":   vol.ID,
+		"volumeZone": vol.AvailabilityZone,
+	}
+	if len(vol.Attachments) > 0 {
+		ids := []string{}
+		for _, a := range vol.Attachments {
+			if a.InstanceID != nil {
+				ids = append(ids, a.InstanceID.ID)
+			}
+		}
+		fields["instanceIDs"] = ids
+		return errcodes.New(errcodes.AlreadyAttached, goof.WithFields(fields,
+			"volume is attached in another availability zone and is "+
+				"not recreated"))
+	}
+	if vol.Status != "" && !strings.EqualFold(vol.Status, "available") {
+		fields["status"] = vol.Status
+		return goof.WithFields(fields,
+			"volume is in another availability zone and is not available "+
+				"to be recreated")
+	}
+	return nil
+}
+
+// latestSnapshot returns the volume's most recent completed snapshot. An
+// error is returned if there is none or if it is older than the configured
+// maximum age.
+func (c *client) latestSnapshot(
+	ctx apitypes.Context, volumeID string) (*apitypes.Snapshot, error) {
+
+	maxAge, err := maxSnapshotAge(c.config)
+	if err != nil {
+		return nil, err
+	}
+
+	snaps, err := c.Client.Storage().Snapshots(ctx, apiutils.NewStore())
+	if err != nil {
+		return nil, err
+	}
+
+	var latest *apitypes.Snapshot
+	for _, s := range snaps {
+		if s.VolumeID != volumeID {
+			continue
+		}
+		if s.Status != "" && !strings.EqualFold(s.Status, "completed") {
+			continue
+		}
+		if latest == nil || s.StartTime > latest.StartTime {
+			latest = s
+		}
+	}
+	if latest == nil {
+		return nil, goof.WithField("volumeID", volumeID,
+			"volume is in another availability zone and has no snapshot")
+	}
+
+	if maxAge > 0 {
+		age := time.Since(time.Unix(latest.StartTime, 0))
+		if age > maxAge {
+			return nil, goof.WithFields(goof.Fields{
+				"volumeID":       volumeID,
+				"snapshotID":     latest.ID,
+				"snapshotAge":    age.String(),
+				"maxSnapshotAge": maxAge.String(),
+			}, "volume is in another availability zone and its latest "+
+				"snapshot is too old")
+		}
+	}
+	return latest, nil
+}
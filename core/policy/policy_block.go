@@ -0,0 +1,261 @@
+package policy
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/akutz/goof"
+	apitypes "github.com/emccode/libstorage/api/types"
+	apiutils "github.com/emccode/libstorage/api/utils"
+
+	"github.com/emccode/rexray/core/overrides"
+	"github.com/emccode/rexray/core/scsi"
+	"github.com/emccode/rexray/util"
+)
+
+const (
+	// VolumeModeKey is the name of the option that selects whether a volume
+	// is mounted as a file system or exposed as a raw block device.
+	VolumeModeKey = "volumeMode"
+
+	// VolumeModeFilesystem formats and mounts a volume. It is the default.
+	VolumeModeFilesystem = "filesystem"
+
+	// VolumeModeBlock attaches a volume and exposes its device without
+	// formatting or mounting it.
+	VolumeModeBlock = "block"
+
+	// BlockDeviceFile is the name of the file in a block volume's mount path
+	// to which the volume's device is bind mounted.
+	BlockDeviceFile = "device"
+)
+
+// blockDeviceTimeout is how long to wait for an attached volume's device to
+// appear.
+var blockDeviceTimeout = 30 * time.Second
+
+// GetVolumeMode returns the volume mode requested by the provided options.
+func GetVolumeMode(opts apitypes.Store) (string, error) {
+	if opts == nil {
+		return VolumeModeFilesystem, nil
+	}
+	switch mode := strings.ToLower(opts.GetString(VolumeModeKey)); mode {
+	case "", VolumeModeFilesystem:
+		return VolumeModeFilesystem, nil
+	case VolumeModeBlock:
+		return VolumeModeBlock, nil
+	default:
+		return "", goof.WithField("volumeMode", mode, "invalid volume mode")
+	}
+}
+
+// BlockDevicePath returns the path of the device of a block volume mounted
+// at the provided path.
+func BlockDevicePath(mountPath string) string {
+	return filepath.Join(mountPath, BlockDeviceFile)
+}
+
+func blockMountPath(volumeID string) string {
+	return util.RunFilePath(filepath.Join(
+		"block", strings.Replace(volumeID, "/", "_", -1)))
+}
+
+// isBlockMounted returns a flag indicating whether the volume's device is
+// bind mounted to its block mount path.
+func isBlockMounted(volumeID string) (bool, error) {
+	return isBound(BlockDevicePath(blockMountPath(volumeID)))
+}
+
+// blockPath returns the mount path of a block volume whose device is exposed
+// on the local instance. The integration driver cannot find the path since
+// the device is not mounted. The volume is only looked up if any block
+// volume is exposed.
+func (c *client) blockPath(
+	ctx apitypes.Context, volumeID, volumeName string) (string, bool) {
+
+	if fis, _ := ioutil.ReadDir(util.RunFilePath("block")); len(fis) == 0 {
+		return "", false
+	}
+	id, err := c.volumeID(ctx, volumeID, volumeName)
+	if err != nil {
+		return "", false
+	}
+	if block, _ := isBlockMounted(id); !block {
+		return "", false
+	}
+	return blockMountPath(id), true
+}
+
+// recordVolumeMode records the mode of a volume created in block mode as one
+// of its overrides so that it is exposed as a device whenever it is mounted.
+func (c *client) recordVolumeMode(
+	ctx apitypes.Context, volumeID string, opts apitypes.Store) {
+
+	if mode, _ := GetVolumeMode(opts); mode != VolumeModeBlock {
+		return
+	}
+	if _, err := overrides.Set(c.store, volumeID, overrides.Overrides{
+		overrides.VolumeModeKey: VolumeModeBlock,
+	}); err != nil {
+		ctx.WithError(err).WithField("volumeID", volumeID).Warn(
+			"error storing volume mode")
+	}
+}
+
+// mountBlock attaches a volume to the local instance if it is not already
+// attached and bind mounts its device to a file in the volume's block mount
+// path. The device is neither formatted nor mounted.
+func (c *client) mountBlock(
+	ctx apitypes.Context,
+	volumeID string,
+	opts *apitypes.VolumeMountOpts) (string, *apitypes.Volume, error) {
+
+	device, vol, _, err := c.attachDevice(ctx, volumeID, opts)
+	if err != nil {
+		return "", nil, err
+	}
+
+	mountPath := blockMountPath(volumeID)
+	if err := os.MkdirAll(mountPath, 0750); err != nil {
+		return "", nil, err
+	}
+
+	target := BlockDevicePath(mountPath)
+	bound, err := isBound(target)
+	if err != nil {
+		return "", nil, err
+	}
+	if !bound {
+		if err := bindDevice(device, target); err != nil {
+			return "", nil, goof.WithFieldsE(goof.Fields{
+				"volumeID": volumeID,
+				"device":   device,
+				"target":   target,
+			}, "error exposing block device", err)
+		}
+	}
+
+	ctx.WithFields(map[string]interface{}{
+		"volumeID": volumeID,
+		"device":   device,
+		"target":   target,
+	}).Info("exposed block volume")
+	return mountPath, vol, nil
+}
+
+// attachDevice attaches a volume to the local instance if it is not already
+// attached and returns the path of its device once the device appears. The
+// returned flag indicates whether the volume was attached by the call.
+func (c *client) attachDevice(
+	ctx apitypes.Context,
+	volumeID string,
+	opts *apitypes.VolumeMountOpts) (string, *apitypes.Volume, bool, error) {
+
+	vol, attached, err := c.isAttachedLocally(ctx, volumeID)
+	if err != nil {
+		return "", nil, false, err
+	}
+
+	if !attached {
+		attachOpts := &apitypes.VolumeAttachOpts{
+			Force: opts.Preempt,
+			Opts:  opts.Opts,
+		}
+		next, err := c.Executor().NextDevice(ctx, apiutils.NewStore())
+		if err == nil && next != "" {
+			attachOpts.NextDevice = &next
+		}
+		if _, _, err := c.Client.Storage().VolumeAttach(
+			ctx, volumeID, attachOpts); err != nil {
+			return "", nil, false, err
+		}
+		if vol, _, err = c.isAttachedLocally(ctx, volumeID); err != nil {
+			return "", nil, true, err
+		}
+	}
+
+	device, err := c.localDevice(ctx, vol)
+	if err != nil {
+		return "", nil, !attached, err
+	}
+	if err := waitForDevice(device, blockDeviceTimeout); err != nil {
+		return "", nil, !attached, err
+	}
+	if scsi.Multipath(c.config) {
+		device = scsi.ResolveMultipath(
+			ctx, device, scsi.MultipathTimeout(c.config))
+	}
+	return device, vol, !attached, nil
+}
+
+// unmountBlock removes the bind mount of a block volume's device.
+func (c *client) unmountBlock(ctx apitypes.Context, volumeID string) error {
+	mountPath := blockMountPath(volumeID)
+	target := BlockDevicePath(mountPath)
+	if err := unbindDevice(target); err != nil {
+		return err
+	}
+	if err := os.Remove(target); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Remove(mountPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	ctx.WithField("volumeID", volumeID).Info("released block volume")
+	return nil
+}
+
+// unmountBlockAndDetach releases a block volume and detaches it unless
+// volumes remain attached after an unmount.
+func (c *client) unmountBlockAndDetach(
+	ctx apitypes.Context, volumeID string, opts apitypes.Store) error {
+
+	if err := c.unmountBlock(ctx, volumeID); err != nil {
+		return err
+	}
+	if GetAttachMode(c.config) != AttachOnMount {
+		return nil
+	}
+	if _, err := c.Client.Storage().VolumeDetach(ctx, volumeID,
+		&apitypes.VolumeDetachOpts{Opts: opts}); err != nil {
+		return err
+	}
+	c.releaseAttachment(ctx, volumeID)
+	return nil
+}
+
+// localDevice returns the device of the volume's attachment to the local
+// instance.
+func (c *client) localDevice(
+	ctx apitypes.Context, vol *apitypes.Volume) (string, error) {
+
+	iid, err := c.instanceID(ctx)
+	if err != nil {
+		return "", err
+	}
+	for _, a := range vol.Attachments {
+		if a.InstanceID != nil && a.InstanceID.ID == iid &&
+			a.DeviceName != "" {
+			return a.DeviceName, nil
+		}
+	}
+	return "", goof.WithField(
+		"volumeID", vol.ID, "volume has no local device")
+}
+
+func waitForDevice(device string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		_, err := os.Stat(device)
+		if err == nil {
+			return nil
+		}
+		if !os.IsNotExist(err) || time.Now().After(deadline) {
+			return goof.WithFieldE("device", device, "device not found", err)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
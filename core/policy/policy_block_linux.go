@@ -0,0 +1,58 @@
+package policy
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// isBound returns a flag indicating whether something is mounted at the
+// provided path.
+func isBound(path string) (bool, error) {
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	mountPoint, err := mountPointOf(path)
+	if err != nil {
+		return false, err
+	}
+	return mountPoint == path, nil
+}
+
+// bindDevice bind mounts a device to a file, creating the file if necessary.
+func bindDevice(device, target string) error {
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_RDONLY, 0600)
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return syscall.Mount(device, target, "", syscall.MS_BIND, "")
+}
+
+func unbindDevice(target string) error {
+	bound, err := isBound(target)
+	if err != nil || !bound {
+		return err
+	}
+	return syscall.Unmount(target, 0)
+}
+
+// deviceFSType returns the type of the file system on a device, or an empty
+// string if the device has none.
+func deviceFSType(device string) (string, error) {
+	out, err := exec.Command(
+		"blkid", "-o", "value", "-s", "TYPE", device).Output()
+	if err != nil {
+		// blkid exits with status 2 if the device has no file system
+		if ee, ok := err.(*exec.ExitError); ok &&
+			ee.Sys().(syscall.WaitStatus).ExitStatus() == 2 {
+			return "", nil
+		}
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
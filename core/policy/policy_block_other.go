@@ -0,0 +1,21 @@
+// +build !linux
+
+package policy
+
+import "github.com/akutz/goof"
+
+func isBound(path string) (bool, error) {
+	return false, nil
+}
+
+func bindDevice(device, target string) error {
+	return goof.New("block volumes are only supported on Linux")
+}
+
+func unbindDevice(target string) error {
+	return nil
+}
+
+func deviceFSType(device string) (string, error) {
+	return "", nil
+}
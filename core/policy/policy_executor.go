@@ -0,0 +1,101 @@
+package policy
+
+import (
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/core/executors"
+	"github.com/emccode/rexray/util"
+)
+
+// prepareExecutor writes the embedded executor to the configured executor
+// path and disables the download of executors from the libStorage server.
+// Builds without an embedded executor download it as before.
+func prepareExecutor(ctx apitypes.Context, config gofig.Config) error {
+	if !executors.IsEmbedded() {
+		ctx.Debug("no embedded executor; executor download enabled")
+		return nil
+	}
+
+	path := config.GetString(apitypes.ConfigExecutorPath)
+	if path == "" {
+		path = util.LibFilePath(executors.Name())
+		config.Set(apitypes.ConfigExecutorPath, path)
+	}
+
+	if err := executors.Install(path); err != nil {
+		return goof.WithFieldE("path", path, "error installing executor", err)
+	}
+	config.Set(apitypes.ConfigExecutorNoDownload, true)
+
+	ctx.WithField("path", path).Debug("installed embedded executor")
+	return nil
+}
+
+// Executor returns the client's executor. If an executor is embedded, the
+// executor's hash is compared with the embedded executor's before each of its
+// operations, and the operation fails if they do not match.
+func (c *client) Executor() apitypes.StorageExecutorCLI {
+	path := c.config.GetString(apitypes.ConfigExecutorPath)
+	if path == "" || !executors.IsEmbedded() {
+		return c.Client.Executor()
+	}
+	return &verifiedExecutor{
+		StorageExecutorCLI: c.Client.Executor(),
+		path:               path,
+	}
+}
+
+type verifiedExecutor struct {
+	apitypes.StorageExecutorCLI
+	path string
+}
+
+func (e *verifiedExecutor) verify() error {
+	if err := executors.Verify(e.path); err != nil {
+		return goof.WithFieldE(
+			"path", e.path, "refusing to run executor", err)
+	}
+	return nil
+}
+
+func (e *verifiedExecutor) InstanceID(
+	ctx apitypes.Context,
+	opts apitypes.Store) (*apitypes.InstanceID, error) {
+
+	if err := e.verify(); err != nil {
+		return nil, err
+	}
+	return e.StorageExecutorCLI.InstanceID(ctx, opts)
+}
+
+func (e *verifiedExecutor) NextDevice(
+	ctx apitypes.Context,
+	opts apitypes.Store) (string, error) {
+
+	if err := e.verify(); err != nil {
+		return "", err
+	}
+	return e.StorageExecutorCLI.NextDevice(ctx, opts)
+}
+
+func (e *verifiedExecutor) LocalDevices(
+	ctx apitypes.Context,
+	opts *apitypes.LocalDevicesOpts) (*apitypes.LocalDevices, error) {
+
+	if err := e.verify(); err != nil {
+		return nil, err
+	}
+	return e.StorageExecutorCLI.LocalDevices(ctx, opts)
+}
+
+func (e *verifiedExecutor) WaitForDevice(
+	ctx apitypes.Context,
+	opts *apitypes.WaitForDeviceOpts) (bool, *apitypes.LocalDevices, error) {
+
+	if err := e.verify(); err != nil {
+		return false, nil, err
+	}
+	return e.StorageExecutorCLI.WaitForDevice(ctx, opts)
+}
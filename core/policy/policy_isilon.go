@@ -0,0 +1,104 @@
+package policy
+
+import (
+	"strings"
+
+	"github.com/akutz/goof"
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/core/isilon"
+	"github.com/emccode/rexray/core/state"
+)
+
+// createExportOptions returns the export settings requested in a volume's
+// create options, if any, including those to which the requested
+// permissions are mapped. An error is returned if export settings are
+// requested of a driver other than Isilon.
+func (c *client) createExportOptions(
+	ctx apitypes.Context,
+	opts *apitypes.VolumeCreateOpts) (*isilon.ExportOptions, error) {
+
+	if opts == nil {
+		return nil, nil
+	}
+	o, ok, err := isilon.ParseExportOptions(opts.Opts)
+	if err != nil {
+		return nil, err
+	}
+	p, pok, err := ParsePermissions(opts.Opts)
+	if err != nil {
+		return nil, err
+	}
+	if !ok && !pok {
+		return nil, nil
+	}
+	driver, err := c.driverName(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(driver, isilon.DriverName) {
+		if !ok {
+			return nil, nil
+		}
+		return nil, goof.WithField("driver", driver,
+			"export options are only supported by the isilon driver")
+	}
+	if !ok {
+		o = nil
+	}
+	if pok {
+		return exportPermissions(o, p)
+	}
+	return o, nil
+}
+
+func (c *client) setExportOptions(
+	ctx apitypes.Context, volumeID string, o *isilon.ExportOptions) {
+
+	if o == nil {
+		return
+	}
+	if err := isilon.SetExportOptions(c.store, volumeID, o); err != nil {
+		ctx.WithError(err).WithField("volumeID", volumeID).Warn(
+			"error storing export options")
+	}
+}
+
+// reconcileExport applies the recorded export settings of a volume, along
+// with the clients to which the volume is attached and the modes of their
+// attachments, to the volume's export. An export whose settings cannot be
+// applied is logged rather than failing the attachment that changed it.
+func (c *client) reconcileExport(ctx apitypes.Context, volumeID string) {
+	o, ok, err := isilon.GetExportOptions(c.store, volumeID)
+	if err != nil || !ok {
+		if err != nil {
+			ctx.WithError(err).Warn("error reading export options")
+		}
+		return
+	}
+
+	log := ctx.WithField("volumeID", volumeID)
+
+	modes, err := GetAttachModes(state.Controller(), volumeID)
+	if err != nil {
+		log.WithError(err).Warn("error reading volume attachment modes")
+		return
+	}
+
+	vol, err := c.Client.Storage().VolumeInspect(
+		ctx, volumeID, &apitypes.VolumeInspectOpts{Attachments: false})
+	if err != nil {
+		log.WithError(err).Warn("error inspecting exported volume")
+		return
+	}
+
+	ic, err := isilon.NewClient(c.config)
+	if err == nil {
+		err = ic.Reconcile(vol.Name, o, modes)
+	}
+	if err != nil {
+		log.WithError(err).Warn("error reconciling volume export")
+		return
+	}
+	log.WithField("clients", len(modes)).Debug("reconciled volume export")
+}
@@ -0,0 +1,35 @@
+package policy
+
+import (
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/core/journal"
+)
+
+// beginOp journals the start of a mount or unmount of a volume. The returned
+// function ends the operation. Failing to journal an operation does not
+// prevent it.
+func (c *client) beginOp(
+	ctx apitypes.Context, op journal.Op, volumeID string) func() {
+
+	fields := map[string]interface{}{
+		"volumeID":  volumeID,
+		"operation": op,
+	}
+
+	var mountPoints []string
+	if op == journal.OpUnmount {
+		mountPoints, _ = c.localMountPoints(ctx, volumeID)
+	}
+
+	if err := journal.Begin(c.local, op, volumeID, mountPoints); err != nil {
+		ctx.WithFields(fields).WithError(err).Warn("error journaling operation")
+	}
+
+	return func() {
+		if err := journal.End(c.local, volumeID); err != nil {
+			ctx.WithFields(fields).WithError(err).Warn(
+				"error ending journaled operation")
+		}
+	}
+}
@@ -0,0 +1,319 @@
+package policy
+
+import (
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/core/isilon"
+	"github.com/emccode/rexray/core/labels"
+	"github.com/emccode/rexray/core/topology"
+	"github.com/emccode/rexray/core/trash"
+)
+
+// createLabels returns the labels requested in a volume's create options.
+func createLabels(opts *apitypes.VolumeCreateOpts) (labels.Labels, error) {
+	if opts == nil || opts.Opts == nil {
+		return labels.Labels{}, nil
+	}
+	return labels.Parse([]string{opts.Opts.GetString(labels.OptKey)})
+}
+
+func (d *storageDriver) VolumeCreate(
+	ctx apitypes.Context,
+	volumeName string,
+	opts *apitypes.VolumeCreateOpts) (*apitypes.Volume, error) {
+
+	if err := d.c.checkMaintenance(ctx, "volume create"); err != nil {
+		return nil, err
+	}
+
+	l, err := createLabels(opts)
+	if err != nil {
+		return nil, err
+	}
+	access, err := d.c.createAccessMode(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	exportOpts, err := d.c.createExportOptions(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	seg, err := d.c.createTopology(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	perms, err := d.c.createPermissions(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	selinux, err := d.c.createSELinux(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	vol, err := d.StorageDriver.VolumeCreate(ctx, volumeName, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	d.c.setVolumeLabels(ctx, vol.ID, l)
+	d.c.setVolumeAccessMode(ctx, vol.ID, access)
+	d.c.setExportOptions(ctx, vol.ID, exportOpts)
+	d.c.setVolumeTopology(ctx, vol, seg)
+	d.c.setVolumePermissions(ctx, vol.ID, perms)
+	d.c.setVolumeSELinux(ctx, vol.ID, selinux)
+	return vol, nil
+}
+
+func (d *storageDriver) VolumeCreateFromSnapshot(
+	ctx apitypes.Context,
+	snapshotID, volumeName string,
+	opts *apitypes.VolumeCreateOpts) (*apitypes.Volume, error) {
+
+	if err := d.c.checkMaintenance(ctx, "volume create"); err != nil {
+		return nil, err
+	}
+
+	l, err := createLabels(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// a volume inherits the labels of the snapshot from which it is created
+	snapLabels, err := labels.Snapshot(d.c.store, snapshotID)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range snapLabels {
+		if _, ok := l[k]; !ok {
+			l[k] = v
+		}
+	}
+	if opts.Opts != nil && len(l) > 0 {
+		opts.Opts.Set(labels.OptKey, l.String())
+	}
+
+	seg, err := d.c.createTopology(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	vol, err := d.StorageDriver.VolumeCreateFromSnapshot(
+		ctx, snapshotID, volumeName, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	d.c.setVolumeLabels(ctx, vol.ID, l)
+	d.c.setVolumeTopology(ctx, vol, seg)
+	return vol, nil
+}
+
+func (d *storageDriver) VolumeSnapshot(
+	ctx apitypes.Context,
+	volumeID, snapshotName string,
+	opts apitypes.Store) (*apitypes.Snapshot, error) {
+
+	l, err := labels.Volume(d.c.store, volumeID)
+	if err != nil {
+		return nil, err
+	}
+
+	// labels requested for the snapshot take precedence over its volume's
+	if opts != nil {
+		requested, err := labels.Parse(
+			[]string{opts.GetString(labels.OptKey)})
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range requested {
+			l[k] = v
+		}
+	}
+	if opts != nil && len(l) > 0 {
+		opts.Set(labels.OptKey, l.String())
+	}
+
+	snap, err := d.StorageDriver.VolumeSnapshot(
+		ctx, volumeID, snapshotName, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// a snapshot inherits the labels of its volume
+	if err := labels.SetSnapshot(d.c.store, snap.ID, l); err != nil {
+		ctx.WithError(err).WithField("snapshotID", snap.ID).Warn(
+			"error storing snapshot labels")
+	}
+	return snap, nil
+}
+
+func (d *storageDriver) SnapshotRemove(
+	ctx apitypes.Context,
+	snapshotID string,
+	opts apitypes.Store) error {
+
+	if err := d.StorageDriver.SnapshotRemove(ctx, snapshotID, opts); err != nil {
+		return err
+	}
+
+	if err := labels.SetSnapshot(d.c.store, snapshotID, nil); err != nil {
+		ctx.WithError(err).WithField("snapshotID", snapshotID).Warn(
+			"error removing snapshot labels")
+	}
+	return nil
+}
+
+func (d *storageDriver) VolumeRemove(
+	ctx apitypes.Context,
+	volumeID string,
+	opts apitypes.Store) error {
+
+	if err := d.c.checkPinned(ctx, volumeID, "volume remove"); err != nil {
+		return err
+	}
+	if err := d.c.checkProtected(ctx, volumeID); err != nil {
+		return err
+	}
+
+	trashed, err := d.c.trashVolume(ctx, volumeID, opts)
+	if trashed || err != nil {
+		return err
+	}
+
+	if err := d.StorageDriver.VolumeRemove(ctx, volumeID, opts); err != nil {
+		return err
+	}
+
+	d.c.removeVolumeState(ctx, volumeID)
+	return nil
+}
+
+// removeVolumeState removes the state REX-Ray kept for a removed volume.
+func (c *client) removeVolumeState(ctx apitypes.Context, volumeID string) {
+	c.setVolumeLabels(ctx, volumeID, nil)
+	c.setVolumeAccessMode(ctx, volumeID, "")
+	c.setVolumePermissions(ctx, volumeID, nil)
+	c.setVolumeSELinux(ctx, volumeID, nil)
+	if err := isilon.DeleteExportOptions(c.store, volumeID); err != nil {
+		ctx.WithError(err).WithField("volumeID", volumeID).Warn(
+			"error removing export options")
+	}
+	if err := topology.SetVolume(c.store, volumeID, nil); err != nil {
+		ctx.WithError(err).WithField("volumeID", volumeID).Warn(
+			"error removing volume topology")
+	}
+	if err := trash.Forget(c.store, volumeID); err != nil {
+		ctx.WithError(err).WithField("volumeID", volumeID).Warn(
+			"error removing trash entry")
+	}
+}
+
+func (c *client) setVolumeLabels(
+	ctx apitypes.Context, volumeID string, l labels.Labels) {
+	if err := labels.SetVolume(c.store, volumeID, l); err != nil {
+		ctx.WithError(err).WithField("volumeID", volumeID).Warn(
+			"error storing volume labels")
+	}
+}
+
+func (d *integrationDriver) Create(
+	ctx apitypes.Context,
+	volumeName string,
+	opts *apitypes.VolumeCreateOpts) (*apitypes.Volume, error) {
+
+	if err := d.c.checkMaintenance(ctx, "volume create"); err != nil {
+		return nil, err
+	}
+
+	l, err := createLabels(opts)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := GetVolumeMode(opts.Opts); err != nil {
+		return nil, err
+	}
+	access, err := d.c.createAccessMode(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	exportOpts, err := d.c.createExportOptions(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	seg, err := d.c.createTopology(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	perms, err := d.c.createPermissions(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	selinux, err := d.c.createSELinux(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	vol, err := d.IntegrationDriver.Create(ctx, volumeName, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	d.c.setVolumeLabels(ctx, vol.ID, l)
+	d.c.setVolumeAccessMode(ctx, vol.ID, access)
+	d.c.setExportOptions(ctx, vol.ID, exportOpts)
+	d.c.setVolumeTopology(ctx, vol, seg)
+	d.c.setVolumePermissions(ctx, vol.ID, perms)
+	d.c.setVolumeSELinux(ctx, vol.ID, selinux)
+	d.c.recordVolumeMode(ctx, vol.ID, opts.Opts)
+	return vol, nil
+}
+
+func (d *integrationDriver) Remove(
+	ctx apitypes.Context,
+	volumeName string,
+	opts apitypes.Store) error {
+
+	vol, err := d.c.volumeByName(ctx, volumeName)
+	if err != nil {
+		return err
+	}
+
+	if err := d.c.checkProtected(ctx, vol.ID); err != nil {
+		return err
+	}
+
+	trashed, err := d.c.trashVolume(ctx, vol.ID, opts)
+	if trashed || err != nil {
+		return err
+	}
+
+	if err := d.IntegrationDriver.Remove(ctx, volumeName, opts); err != nil {
+		return err
+	}
+
+	d.c.removeVolumeState(ctx, vol.ID)
+	return nil
+}
+
+func (d *storageDriver) VolumeCopy(
+	ctx apitypes.Context,
+	volumeID, volumeName string,
+	opts apitypes.Store) (*apitypes.Volume, error) {
+
+	if err := d.c.checkMaintenance(ctx, "volume copy"); err != nil {
+		return nil, err
+	}
+
+	// a copy inherits the labels, and therefore the tenant, of its source
+	l, err := labels.Volume(d.c.store, volumeID)
+	if err != nil {
+		return nil, err
+	}
+
+	vol, err := d.StorageDriver.VolumeCopy(ctx, volumeID, volumeName, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	d.c.setVolumeLabels(ctx, vol.ID, l)
+	return vol, nil
+}
@@ -0,0 +1,46 @@
+package policy
+
+import (
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/core/maintenance"
+	"github.com/emccode/rexray/core/state"
+)
+
+// serviceName returns the name of the configured service. A client
+// configured without a service uses the only service the server provides.
+func (c *client) serviceName(ctx apitypes.Context) (string, error) {
+	if name := c.config.GetString(apitypes.ConfigService); name != "" {
+		return name, nil
+	}
+	svcs, err := c.Client.API().Services(ctx)
+	if err != nil {
+		return "", err
+	}
+	if len(svcs) != 1 {
+		return "", nil
+	}
+	for name := range svcs {
+		return name, nil
+	}
+	return "", nil
+}
+
+// checkMaintenance returns an error if the configured service is in
+// maintenance. Only operations that begin using a volume are checked so that
+// volumes may still be unmounted and detached during maintenance.
+func (c *client) checkMaintenance(ctx apitypes.Context, op string) error {
+	name, err := c.serviceName(ctx)
+	if err != nil || name == "" {
+		return err
+	}
+	m, ok, err := maintenance.Get(state.Controller(), name)
+	if err != nil || !ok {
+		return err
+	}
+	ctx.WithFields(map[string]interface{}{
+		"service":   m.Service,
+		"operation": op,
+	}).Warn("refused operation on service in maintenance")
+	return &maintenance.Error{Mode: m, Operation: op}
+}
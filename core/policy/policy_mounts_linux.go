@@ -0,0 +1,41 @@
+package policy
+
+import (
+	"bufio"
+	"os"
+	"strings"
+
+	"github.com/akutz/goof"
+)
+
+// mountPointOf returns the longest mount point that contains the provided
+// path.
+func mountPointOf(path string) (string, error) {
+	f, err := os.Open("/proc/self/mounts")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	var mountPoint string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		mp := fields[1]
+		if (path == mp || strings.HasPrefix(path, mp+"/") || mp == "/") &&
+			len(mp) > len(mountPoint) {
+			mountPoint = mp
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+
+	if mountPoint == "" {
+		return "", goof.WithField("path", path, "mount point not found")
+	}
+	return mountPoint, nil
+}
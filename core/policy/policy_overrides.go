@@ -0,0 +1,42 @@
+package policy
+
+import (
+	apitypes "github.com/emccode/libstorage/api/types"
+	apiutils "github.com/emccode/libstorage/api/utils"
+
+	"github.com/emccode/rexray/core/overrides"
+)
+
+// applyOverrides applies the volume's option overrides to the options with
+// which it is mounted. An override replaces the option provided by the
+// caller.
+func (c *client) applyOverrides(
+	ctx apitypes.Context,
+	volumeID string,
+	opts *apitypes.VolumeMountOpts) error {
+
+	o, err := overrides.Get(c.store, volumeID)
+	if err != nil || len(o) == 0 {
+		return err
+	}
+
+	if opts.Opts == nil {
+		opts.Opts = apiutils.NewStore()
+	}
+	for k, v := range o {
+		switch k {
+		case overrides.FSTypeKey:
+			opts.NewFSType = v
+		case overrides.ReadOnlyKey:
+			opts.Opts.Set(ReadOnlyKey, v == "true")
+		default:
+			opts.Opts.Set(k, v)
+		}
+	}
+
+	ctx.WithFields(map[string]interface{}{
+		"volumeID":  volumeID,
+		"overrides": o,
+	}).Info("applied volume overrides")
+	return nil
+}
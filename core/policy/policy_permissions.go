@@ -0,0 +1,209 @@
+package policy
+
+import (
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/akutz/goof"
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/core/isilon"
+	"github.com/emccode/rexray/core/state"
+)
+
+// The names of the create and mount options that set the owner and mode of
+// the root of a volume's file system.
+const (
+	UIDKey  = "uid"
+	GIDKey  = "gid"
+	ModeKey = "mode"
+
+	permissionsBucket = "volumePermissions"
+)
+
+// Permissions are the owner and mode of the root of a volume's file system.
+type Permissions struct {
+	UID  *int    `json:"uid,omitempty"`
+	GID  *int    `json:"gid,omitempty"`
+	Mode *uint32 `json:"mode,omitempty"`
+}
+
+// ParsePermissions returns the permissions requested in a volume's create
+// or mount options. The mode is octal, ex. 0770. The returned flag is false
+// if none were requested.
+func ParsePermissions(opts apitypes.Store) (*Permissions, bool, error) {
+	if opts == nil {
+		return nil, false, nil
+	}
+	p := &Permissions{}
+	ok := false
+	for _, k := range []string{UIDKey, GIDKey} {
+		v := opts.GetString(k)
+		if v == "" {
+			continue
+		}
+		id, err := strconv.Atoi(v)
+		if err != nil || id < 0 {
+			return nil, false, goof.WithField(k, v, "invalid "+k)
+		}
+		if k == UIDKey {
+			p.UID = &id
+		} else {
+			p.GID = &id
+		}
+		ok = true
+	}
+	if v := opts.GetString(ModeKey); v != "" {
+		m, err := strconv.ParseUint(v, 8, 32)
+		if err != nil || m > 07777 {
+			return nil, false, goof.WithField(ModeKey, v, "invalid mode")
+		}
+		mode := uint32(m)
+		p.Mode = &mode
+		ok = true
+	}
+	return p, ok, nil
+}
+
+// fileMode returns the os.FileMode of a Unix mode, whose setuid, setgid,
+// and sticky bits differ from os.FileMode's.
+func fileMode(mode uint32) os.FileMode {
+	m := os.FileMode(mode & 0777)
+	if mode&04000 != 0 {
+		m |= os.ModeSetuid
+	}
+	if mode&02000 != 0 {
+		m |= os.ModeSetgid
+	}
+	if mode&01000 != 0 {
+		m |= os.ModeSticky
+	}
+	return m
+}
+
+// GetVolumePermissions returns the permissions recorded for the volume with
+// the provided ID when it was created.
+func GetVolumePermissions(
+	s *state.Store, volumeID string) (*Permissions, bool, error) {
+
+	p := &Permissions{}
+	ok, err := s.Get(permissionsBucket, volumeID, p)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	return p, true, nil
+}
+
+// createPermissions returns the permissions requested in a volume's create
+// options, if any. Block volumes have no file system to apply them to.
+func (c *client) createPermissions(
+	ctx apitypes.Context,
+	opts *apitypes.VolumeCreateOpts) (*Permissions, error) {
+
+	if opts == nil {
+		return nil, nil
+	}
+	p, ok, err := ParsePermissions(opts.Opts)
+	if err != nil || !ok {
+		return nil, err
+	}
+	if mode, _ := GetVolumeMode(opts.Opts); mode == VolumeModeBlock {
+		return nil, goof.New(
+			"permissions may not be requested of block volumes")
+	}
+	return p, nil
+}
+
+// exportPermissions maps the requested owner of a volume onto the settings
+// of its export: every user of the export's clients is mapped to the owner.
+// A mode cannot be applied since clients may not change the mode of an
+// export that squashes them.
+func exportPermissions(
+	o *isilon.ExportOptions, p *Permissions) (*isilon.ExportOptions, error) {
+
+	if p.Mode != nil {
+		return nil, goof.WithField(ModeKey, *p.Mode,
+			"mode is not supported by the isilon driver; "+
+				"use uid and gid to map the export's users")
+	}
+	if o == nil {
+		o = &isilon.ExportOptions{}
+	}
+	if p.UID != nil && o.SquashUser == "" {
+		o.AllSquash = true
+		o.SquashUser = "UID:" + strconv.Itoa(*p.UID)
+	}
+	if p.GID != nil && o.SquashGroup == "" {
+		o.SquashGroup = "GID:" + strconv.Itoa(*p.GID)
+	}
+	return o, nil
+}
+
+func (c *client) setVolumePermissions(
+	ctx apitypes.Context, volumeID string, p *Permissions) {
+
+	var err error
+	if p == nil {
+		err = c.store.Delete(permissionsBucket, volumeID)
+	} else {
+		err = c.store.Set(permissionsBucket, volumeID, p)
+	}
+	if err != nil {
+		ctx.WithError(err).WithField("volumeID", volumeID).Warn(
+			"error storing volume permissions")
+	}
+}
+
+// applyPermissions sets the owner and mode of the root of a mounted volume's
+// file system to those requested in the mount options, or otherwise to
+// those recorded when the volume was created. The permissions of Isilon
+// volumes are applied by their exports instead.
+func (c *client) applyPermissions(
+	ctx apitypes.Context,
+	volumeID, mountPath string,
+	opts apitypes.Store) error {
+
+	p, ok, err := ParsePermissions(opts)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		if p, ok, err = GetVolumePermissions(c.store, volumeID); err != nil ||
+			!ok {
+			return err
+		}
+	}
+
+	driver, err := c.driverName(ctx)
+	if err != nil {
+		return err
+	}
+	if strings.EqualFold(driver, isilon.DriverName) {
+		return nil
+	}
+
+	fields := goof.Fields{
+		"volumeID":  volumeID,
+		"mountPath": mountPath,
+	}
+	if p.UID != nil || p.GID != nil {
+		uid, gid := -1, -1
+		if p.UID != nil {
+			uid = *p.UID
+		}
+		if p.GID != nil {
+			gid = *p.GID
+		}
+		if err := os.Chown(mountPath, uid, gid); err != nil {
+			return goof.WithFieldsE(fields, "error changing volume owner", err)
+		}
+	}
+	if p.Mode != nil {
+		if err := os.Chmod(mountPath, fileMode(*p.Mode)); err != nil {
+			return goof.WithFieldsE(fields, "error changing volume mode", err)
+		}
+	}
+	ctx.WithFields(fields).Debug("applied volume permissions")
+	return nil
+}
@@ -0,0 +1,23 @@
+package policy
+
+import (
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/core/pins"
+	"github.com/emccode/rexray/core/state"
+)
+
+// checkPinned returns an error if the volume with the provided ID is pinned.
+func (c *client) checkPinned(
+	ctx apitypes.Context, volumeID, op string) error {
+
+	p, ok, err := pins.Get(state.Controller(), volumeID)
+	if err != nil || !ok {
+		return err
+	}
+	ctx.WithFields(map[string]interface{}{
+		"volumeID":  volumeID,
+		"operation": op,
+	}).Warn("refused operation on pinned volume")
+	return &pins.Error{Pin: p, Operation: op}
+}
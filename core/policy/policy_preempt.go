@@ -0,0 +1,317 @@
+package policy
+
+import (
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/core/nodes"
+	"github.com/emccode/rexray/core/state"
+)
+
+// PreemptPolicy describes when a volume attached to another instance may be
+// taken from that instance.
+type PreemptPolicy string
+
+const (
+	// PreemptNever refuses to take a volume from another instance.
+	PreemptNever PreemptPolicy = "never"
+
+	// PreemptIfUnmounted takes a volume from another instance only if that
+	// instance is not healthy or did not report the volume as mounted.
+	PreemptIfUnmounted PreemptPolicy = "ifUnmounted"
+
+	// PreemptAlwaysWithFencing always takes a volume from another instance
+	// and fences the volume from that instance, causing its agent to unmount
+	// the volume the next time it reports.
+	PreemptAlwaysWithFencing PreemptPolicy = "alwaysWithFencing"
+)
+
+// preemptCapabilities are the preemption policies each storage driver is
+// able to honor. Preempting a volume requires the driver to forcefully detach
+// the volume from another instance, and fencing additionally requires the
+// forced detach be safe while the volume may still be in use. Drivers that
+// are not listed support PreemptNever and PreemptIfUnmounted.
+var preemptCapabilities = map[string][]PreemptPolicy{
+	"scaleio": {
+		PreemptNever, PreemptIfUnmounted, PreemptAlwaysWithFencing},
+	"virtualbox": {
+		PreemptNever, PreemptIfUnmounted},
+	"isilon": {
+		PreemptNever},
+	"efs": {
+		PreemptNever},
+}
+
+func init() {
+	r := gofig.NewRegistration("Volume Preemption")
+	r.Key(gofig.String, "", string(PreemptNever),
+		"When a volume may be taken from another instance "+
+			"(never, ifUnmounted, alwaysWithFencing)",
+		"rexray.volume.preempt")
+	r.Key(gofig.String, "", "2m",
+		"The duration after which a node that has not reported is unhealthy",
+		"rexray.nodes.heartbeat.timeout")
+	gofig.Register(r)
+}
+
+// GetPreemptPolicy returns the preemption policy for the service configured
+// in the provided config. If no policy is configured but libStorage's mount
+// preemption option is enabled, the policy is PreemptIfUnmounted.
+func GetPreemptPolicy(config gofig.Config) PreemptPolicy {
+	v := serviceString(config, "preempt")
+	if !isServiceKeySet(config, "preempt") && config.GetBool(configPreempt) {
+		log.WithField("key", configPreempt).Warn(
+			"deprecated preemption option; use rexray.volume.preempt")
+		return PreemptIfUnmounted
+	}
+	switch strings.ToLower(v) {
+	case "", strings.ToLower(string(PreemptNever)):
+		return PreemptNever
+	case strings.ToLower(string(PreemptIfUnmounted)):
+		return PreemptIfUnmounted
+	case strings.ToLower(string(PreemptAlwaysWithFencing)):
+		return PreemptAlwaysWithFencing
+	}
+	return PreemptPolicy(v)
+}
+
+// ValidatePreemptPolicy returns an error if the configured preemption policy
+// is unknown.
+func ValidatePreemptPolicy(config gofig.Config) error {
+	switch p := GetPreemptPolicy(config); p {
+	case PreemptNever, PreemptIfUnmounted, PreemptAlwaysWithFencing:
+		return nil
+	default:
+		return goof.WithField("preempt", p, "invalid preemption policy")
+	}
+}
+
+// ValidatePreemptPolicyForDriver returns an error if the provided storage
+// driver is unable to honor the policy.
+func ValidatePreemptPolicyForDriver(
+	driverName string, policy PreemptPolicy) error {
+
+	supported, ok := preemptCapabilities[strings.ToLower(driverName)]
+	if !ok {
+		supported = []PreemptPolicy{PreemptNever, PreemptIfUnmounted}
+	}
+	for _, p := range supported {
+		if p == policy {
+			return nil
+		}
+	}
+	return goof.WithFields(goof.Fields{
+		"driver":  driverName,
+		"preempt": policy,
+	}, "preemption policy not supported by driver")
+}
+
+func heartbeatTimeout(config gofig.Config) time.Duration {
+	d, err := time.ParseDuration(
+		config.GetString("rexray.nodes.heartbeat.timeout"))
+	if err != nil {
+		return 2 * time.Minute
+	}
+	return d
+}
+
+// driverName returns the name of the storage driver used by the configured
+// service.
+func (c *client) driverName(ctx apitypes.Context) (string, error) {
+	return ServiceDriverName(ctx, c.config, c.Client)
+}
+
+// ServiceDriverName returns the name of the storage driver used by the
+// configured service.
+func ServiceDriverName(
+	ctx apitypes.Context,
+	config gofig.Config,
+	client apitypes.Client) (string, error) {
+
+	svcName := config.GetString(apitypes.ConfigService)
+	svcs, err := client.API().Services(ctx)
+	if err != nil {
+		return "", err
+	}
+	for name, svc := range svcs {
+		if strings.ToLower(name) == strings.ToLower(svcName) &&
+			svc.Driver != nil {
+			return svc.Driver.Name, nil
+		}
+	}
+	return "", nil
+}
+
+// checkPreempt determines whether or not attaching the volume to the local
+// instance requires preempting other instances and if the configured policy
+// permits it. The returned flag is true if the volume must be preempted.
+func (c *client) checkPreempt(
+	ctx apitypes.Context, volumeID string, readOnly bool) (bool, error) {
+
+	iid, err := c.instanceID(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	vol, err := c.Client.Storage().VolumeInspect(
+		ctx, volumeID, &apitypes.VolumeInspectOpts{Attachments: true})
+	if err != nil {
+		return false, err
+	}
+
+	others := []string{}
+	for _, a := range vol.Attachments {
+		if a.InstanceID != nil && a.InstanceID.ID != iid {
+			others = append(others, a.InstanceID.ID)
+		}
+	}
+	if len(others) == 0 {
+		return false, nil
+	}
+
+	// a read-only attachment may share the volume with other read-only
+	// attachments, so there is nothing to preempt
+	if readOnly {
+		modes, err := GetAttachModes(state.Controller(), volumeID)
+		if err != nil {
+			return false, err
+		}
+		shared := true
+		for _, otherID := range others {
+			if modes[otherID] != modeReadOnly {
+				shared = false
+				break
+			}
+		}
+		if shared {
+			return false, nil
+		}
+	}
+
+	policy := GetPreemptPolicy(c.config)
+	fields := map[string]interface{}{
+		"volumeID":  volumeID,
+		"instances": others,
+		"preempt":   policy,
+	}
+
+	if policy == PreemptNever {
+		return false, goof.WithFields(
+			fields, "volume attached to another instance")
+	}
+
+	driverName, err := c.driverName(ctx)
+	if err != nil {
+		return false, err
+	}
+	if err := ValidatePreemptPolicyForDriver(driverName, policy); err != nil {
+		return false, err
+	}
+
+	s := state.Controller()
+	timeout := heartbeatTimeout(c.config)
+	fenced := []string{}
+	for _, otherID := range others {
+		n, ok, err := nodes.Get(s, otherID)
+		if err != nil {
+			return false, err
+		}
+
+		// an instance that has never reported may be using the volume
+		// without REX-Ray knowing it, so it is never preempted
+		if !ok {
+			fields["instanceID"] = otherID
+			return false, goof.WithFields(
+				fields, "volume attached to an instance that never reported")
+		}
+
+		switch policy {
+		case PreemptIfUnmounted:
+			if n.IsHealthy(timeout) && n.IsMounted(volumeID) {
+				fields["instanceID"] = otherID
+				return false, goof.WithFields(
+					fields, "volume mounted on a healthy instance")
+			}
+		case PreemptAlwaysWithFencing:
+			// a fence is acknowledged by the instance's agent, which must
+			// be reporting to do so
+			if !n.IsHealthy(timeout) {
+				fields["instanceID"] = otherID
+				return false, goof.WithFields(
+					fields, "volume attached to an instance not reporting")
+			}
+			fenced = append(fenced, otherID)
+		}
+	}
+
+	if len(fenced) > 0 {
+		if err := c.fence(ctx, s, volumeID, fenced, fields); err != nil {
+			return false, err
+		}
+	}
+
+	ctx.WithFields(fields).Info("preempting volume")
+	return true, nil
+}
+
+// fencePollInterval is the interval at which the fences are checked for the
+// agents' acknowledgements.
+var fencePollInterval = time.Second
+
+// fence fences the volume from the provided instances and waits until their
+// agents acknowledge the fences, which they do once the volume is unmounted,
+// so that the volume is not detached while it is still mounted. The fences
+// that are not acknowledged within the heartbeat timeout are withdrawn and
+// the volume is not preempted.
+func (c *client) fence(
+	ctx apitypes.Context,
+	s *state.Store,
+	volumeID string,
+	instanceIDs []string,
+	fields map[string]interface{}) error {
+
+	for _, id := range instanceIDs {
+		if err := nodes.Fence(s, id, volumeID); err != nil {
+			return err
+		}
+		ctx.WithFields(fields).WithField("fenced", id).Warn(
+			"fenced volume from instance")
+	}
+
+	deadline := time.Now().Add(heartbeatTimeout(c.config))
+	for {
+		pending := []string{}
+		for _, id := range instanceIDs {
+			ok, err := nodes.IsFenced(s, id, volumeID)
+			if err != nil {
+				return err
+			}
+			if ok {
+				pending = append(pending, id)
+			}
+		}
+		if len(pending) == 0 {
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			for _, id := range pending {
+				if err := nodes.Unfence(s, id, volumeID); err != nil {
+					ctx.WithFields(fields).WithField(
+						"fenced", id).WithError(err).Error(
+						"error withdrawing fence")
+				}
+			}
+			fields["instances"] = pending
+			return goof.WithFields(fields, "fence not acknowledged")
+		}
+
+		instanceIDs = pending
+		time.Sleep(fencePollInterval)
+	}
+}
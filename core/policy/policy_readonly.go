@@ -0,0 +1,156 @@
+package policy
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/akutz/goof"
+	apitypes "github.com/emccode/libstorage/api/types"
+	apiutils "github.com/emccode/libstorage/api/utils"
+
+	"github.com/emccode/rexray/util"
+)
+
+func readOnlyMountPath(volumeID string) string {
+	return util.RunFilePath(filepath.Join(
+		"ro", strings.Replace(volumeID, "/", "_", -1)))
+}
+
+// isReadOnlyMounted returns a flag indicating whether the volume's file
+// system is mounted read-only at its read-only mount path.
+func isReadOnlyMounted(volumeID string) (bool, error) {
+	return isBound(readOnlyMountPath(volumeID))
+}
+
+// readOnlyPath returns the mount path of a volume whose file system is
+// mounted read-only on the local instance. The volume is only looked up if
+// any file system is mounted read-only.
+func (c *client) readOnlyPath(
+	ctx apitypes.Context, volumeID, volumeName string) (string, bool) {
+
+	if fis, _ := ioutil.ReadDir(util.RunFilePath("ro")); len(fis) == 0 {
+		return "", false
+	}
+	id, err := c.volumeID(ctx, volumeID, volumeName)
+	if err != nil {
+		return "", false
+	}
+	if mounted, _ := isReadOnlyMounted(id); !mounted {
+		return "", false
+	}
+	return readOnlyMountPath(id), true
+}
+
+// mountReadOnly attaches a volume to the local instance if it is not already
+// attached and mounts its file system read-only. The file system is mounted
+// read-only from the start, rather than mounted read-write by the
+// integration driver and then remounted, so that it is never formatted or
+// written by an instance that shares it. A volume attached by the call is
+// detached again if its file system cannot be mounted.
+func (c *client) mountReadOnly(
+	ctx apitypes.Context,
+	volumeID string,
+	opts *apitypes.VolumeMountOpts) (string, *apitypes.Volume, error) {
+
+	device, vol, attached, err := c.attachDevice(ctx, volumeID, opts)
+	if err == nil {
+		var mountPath string
+		if mountPath, err = c.mountDeviceReadOnly(
+			ctx, volumeID, device); err == nil {
+			return mountPath, vol, nil
+		}
+	}
+
+	if attached {
+		if _, derr := c.Client.Storage().VolumeDetach(ctx, volumeID,
+			&apitypes.VolumeDetachOpts{Opts: opts.Opts}); derr != nil {
+			ctx.WithError(derr).WithField("volumeID", volumeID).Warn(
+				"error detaching volume after failed read-only mount")
+		}
+	}
+	return "", nil, err
+}
+
+// readOnlyMountOptions returns the options with which a file system of the
+// provided type is mounted read-only. Journaling file systems replay their
+// journals even when they are mounted read-only, which writes to the device,
+// unless the replay is disabled.
+func readOnlyMountOptions(fsType string) string {
+	switch fsType {
+	case "ext3", "ext4":
+		return "ro,noload"
+	case "xfs":
+		return "ro,norecovery"
+	case "btrfs":
+		return "ro,nologreplay"
+	}
+	return "ro"
+}
+
+func (c *client) mountDeviceReadOnly(
+	ctx apitypes.Context, volumeID, device string) (string, error) {
+
+	mountPath := readOnlyMountPath(volumeID)
+	if err := os.MkdirAll(mountPath, 0750); err != nil {
+		return "", err
+	}
+	mounted, err := isBound(mountPath)
+	if err != nil {
+		return "", err
+	}
+	if !mounted {
+		fsType, err := deviceFSType(device)
+		if err != nil {
+			os.Remove(mountPath)
+			return "", goof.WithFieldE("device", device,
+				"error reading file system type", err)
+		}
+		if err := c.Client.OS().Mount(ctx, device, mountPath,
+			&apitypes.DeviceMountOpts{
+				MountOptions: readOnlyMountOptions(fsType),
+			}); err != nil {
+			os.Remove(mountPath)
+			return "", goof.WithFieldsE(goof.Fields{
+				"volumeID":  volumeID,
+				"device":    device,
+				"mountPath": mountPath,
+			}, "error mounting volume read-only", err)
+		}
+	}
+
+	ctx.WithFields(map[string]interface{}{
+		"volumeID":  volumeID,
+		"device":    device,
+		"mountPath": mountPath,
+	}).Info("mounted volume read-only")
+	return mountPath, nil
+}
+
+// unmountReadOnlyAndDetach unmounts a volume's read-only file system and
+// detaches the volume unless volumes remain attached after an unmount.
+func (c *client) unmountReadOnlyAndDetach(
+	ctx apitypes.Context, volumeID string, opts apitypes.Store) error {
+
+	mountPath := readOnlyMountPath(volumeID)
+	if err := c.unmountWithEscalation(ctx, volumeID, func() error {
+		return c.Client.OS().Unmount(ctx, mountPath, apiutils.NewStore())
+	}); err != nil {
+		return err
+	}
+	if err := os.Remove(mountPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	ctx.WithField("volumeID", volumeID).Info("unmounted read-only volume")
+
+	if GetAttachMode(c.config) != AttachOnMount {
+		return nil
+	}
+	if _, err := c.Client.Storage().VolumeDetach(ctx, volumeID,
+		&apitypes.VolumeDetachOpts{Opts: opts}); err != nil {
+		return err
+	}
+	c.releaseAttachment(ctx, volumeID)
+	return nil
+}
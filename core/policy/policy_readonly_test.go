@@ -0,0 +1,18 @@
+package policy
+
+import "testing"
+
+func TestReadOnlyMountOptions(t *testing.T) {
+	for fsType, opts := range map[string]string{
+		"ext4":  "ro,noload",
+		"ext3":  "ro,noload",
+		"xfs":   "ro,norecovery",
+		"btrfs": "ro,nologreplay",
+		"vfat":  "ro",
+		"":      "ro",
+	} {
+		if got := readOnlyMountOptions(fsType); got != opts {
+			t.Errorf("readOnlyMountOptions(%q)=%q", fsType, got)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package policy
+
+import (
+	"strings"
+
+	"github.com/akutz/goof"
+	apitypes "github.com/emccode/libstorage/api/types"
+	apiutils "github.com/emccode/libstorage/api/utils"
+
+	"github.com/emccode/rexray/core/state"
+)
+
+const (
+	// SELinuxLabelKey is the name of the create and mount option that sets
+	// the SELinux type, ex. container_file_t, or the full SELinux context of
+	// a volume's files.
+	SELinuxLabelKey = "selinuxLabel"
+
+	// SELinuxRelabelKey is the name of the create and mount option that
+	// selects how a volume's files are labeled.
+	SELinuxRelabelKey = "selinuxRelabel"
+
+	// SELinuxRelabelShared labels a volume's files so that every container
+	// may use them, like Docker's :z.
+	SELinuxRelabelShared = "z"
+
+	// SELinuxRelabelPrivate labels a volume's files with a context whose
+	// MCS categories restrict them to the containers that share them, like
+	// Docker's :Z.
+	SELinuxRelabelPrivate = "Z"
+
+	// SELinuxRelabelRestorecon labels a volume's files with the contexts the
+	// host's policy defines for their paths.
+	SELinuxRelabelRestorecon = "restorecon"
+
+	// SELinuxRelabelContext mounts a volume's file system with the context
+	// mount option, labeling every file without writing the labels.
+	SELinuxRelabelContext = "context"
+
+	defaultSELinuxType = "container_file_t"
+	selinuxBucket      = "volumeSELinux"
+)
+
+// SELinux is how the files of a volume's file system are labeled.
+type SELinux struct {
+	Label   string `json:"label,omitempty"`
+	Relabel string `json:"relabel"`
+}
+
+// ParseSELinux returns the SELinux labeling requested in a volume's create
+// or mount options. The labeling defaults to z if only a label is
+// requested. The returned flag is false if no labeling was requested.
+func ParseSELinux(opts apitypes.Store) (*SELinux, bool, error) {
+	if opts == nil {
+		return nil, false, nil
+	}
+	s := &SELinux{
+		Label:   opts.GetString(SELinuxLabelKey),
+		Relabel: opts.GetString(SELinuxRelabelKey),
+	}
+	if s.Label == "" && s.Relabel == "" {
+		return nil, false, nil
+	}
+
+	switch s.Relabel {
+	case "":
+		s.Relabel = SELinuxRelabelShared
+	case SELinuxRelabelShared, SELinuxRelabelPrivate:
+	default:
+		s.Relabel = strings.ToLower(s.Relabel)
+		if s.Relabel != SELinuxRelabelRestorecon &&
+			s.Relabel != SELinuxRelabelContext {
+			return nil, false, goof.WithField(
+				SELinuxRelabelKey, s.Relabel, "invalid selinux relabel")
+		}
+	}
+	if s.Relabel == SELinuxRelabelRestorecon && s.Label != "" {
+		return nil, false, goof.WithField(SELinuxLabelKey, s.Label,
+			"restorecon applies the policy's labels; omit selinuxLabel")
+	}
+	if _, err := s.Context(); err != nil {
+		return nil, false, err
+	}
+	return s, true, nil
+}
+
+// Context returns the SELinux context with which a volume's files are
+// labeled. A label that is only a type is labeled with the shared level s0.
+// The context is empty if the policy's labels are restored instead.
+func (s *SELinux) Context() (string, error) {
+	if s.Relabel == SELinuxRelabelRestorecon {
+		return "", nil
+	}
+	label := s.Label
+	if label == "" {
+		label = defaultSELinuxType
+	}
+
+	parts := strings.Split(label, ":")
+	switch {
+	case len(parts) == 1:
+		if s.Relabel == SELinuxRelabelPrivate {
+			return "", goof.WithField(SELinuxLabelKey, label,
+				"private relabeling requires a context with MCS categories, "+
+					"ex. system_u:object_r:container_file_t:s0:c1,c2")
+		}
+		return "system_u:object_r:" + label + ":s0", nil
+	case len(parts) < 4:
+		return "", goof.WithField(
+			SELinuxLabelKey, label, "invalid selinux label")
+	case s.Relabel == SELinuxRelabelPrivate && len(parts) < 5:
+		return "", goof.WithField(SELinuxLabelKey, label,
+			"private relabeling requires a context with MCS categories")
+	}
+	return label, nil
+}
+
+// GetVolumeSELinux returns the SELinux labeling recorded for the volume
+// with the provided ID when it was created.
+func GetVolumeSELinux(
+	s *state.Store, volumeID string) (*SELinux, bool, error) {
+
+	l := &SELinux{}
+	ok, err := s.Get(selinuxBucket, volumeID, l)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	return l, true, nil
+}
+
+// createSELinux returns the SELinux labeling requested in a volume's create
+// options, if any.
+func (c *client) createSELinux(
+	ctx apitypes.Context,
+	opts *apitypes.VolumeCreateOpts) (*SELinux, error) {
+
+	if opts == nil {
+		return nil, nil
+	}
+	s, ok, err := ParseSELinux(opts.Opts)
+	if err != nil || !ok {
+		return nil, err
+	}
+	if mode, _ := GetVolumeMode(opts.Opts); mode == VolumeModeBlock {
+		return nil, goof.New(
+			"selinux labels may not be requested of block volumes")
+	}
+	return s, nil
+}
+
+func (c *client) setVolumeSELinux(
+	ctx apitypes.Context, volumeID string, s *SELinux) {
+
+	var err error
+	if s == nil {
+		err = c.store.Delete(selinuxBucket, volumeID)
+	} else {
+		err = c.store.Set(selinuxBucket, volumeID, s)
+	}
+	if err != nil {
+		ctx.WithError(err).WithField("volumeID", volumeID).Warn(
+			"error storing volume selinux labeling")
+	}
+}
+
+// applySELinux labels the files of a mounted volume's file system as
+// requested in the mount options, or otherwise as recorded when the volume
+// was created. Nothing is labeled on hosts without SELinux.
+func (c *client) applySELinux(
+	ctx apitypes.Context,
+	volumeID, mountPath string,
+	opts apitypes.Store) error {
+
+	s, ok, err := ParseSELinux(opts)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		if s, ok, err = GetVolumeSELinux(c.store, volumeID); err != nil ||
+			!ok {
+			return err
+		}
+	}
+
+	fields := goof.Fields{
+		"volumeID":  volumeID,
+		"mountPath": mountPath,
+		"relabel":   s.Relabel,
+	}
+	if !selinuxEnabled() {
+		ctx.WithFields(fields).Debug("selinux disabled; not labeling volume")
+		return nil
+	}
+	// the files of a read-only file system cannot be relabeled, but the
+	// file system may be mounted again with a context
+	if IsReadOnly(opts) && s.Relabel != SELinuxRelabelContext {
+		ctx.WithFields(fields).Warn(
+			"read-only volume only labeled by context mounts; not labeling")
+		return nil
+	}
+	label, err := s.Context()
+	if err != nil {
+		return err
+	}
+	fields["label"] = label
+
+	switch s.Relabel {
+	case SELinuxRelabelRestorecon:
+		err = restorecon(mountPath)
+	case SELinuxRelabelContext:
+		err = c.mountContext(ctx, mountPath, label)
+	default:
+		err = relabel(mountPath, label)
+	}
+	if err != nil {
+		return goof.WithFieldsE(fields, "error labeling volume", err)
+	}
+	ctx.WithFields(fields).Debug("labeled volume")
+	return nil
+}
+
+// mountContext remounts the device mounted at the provided path with the
+// context mount option, unless it already has one. The label cannot be
+// changed by a remount, so the device is unmounted and mounted again by the
+// OS driver, read-only if it was.
+func (c *client) mountContext(
+	ctx apitypes.Context, path, label string) error {
+
+	m, err := mountOf(path)
+	if err != nil {
+		return err
+	}
+	if strings.Contains(m.options, "context=") {
+		return nil
+	}
+	if !strings.HasPrefix(m.device, "/dev/") {
+		return goof.WithField("device", m.device,
+			"context mounts are only supported by volumes with devices")
+	}
+	if err := c.Client.OS().Unmount(
+		ctx, m.mountPoint, apiutils.NewStore()); err != nil {
+		return err
+	}
+	mo := &apitypes.DeviceMountOpts{MountLabel: label}
+	for _, o := range strings.Split(m.options, ",") {
+		if o == "ro" {
+			mo.MountOptions = "ro"
+		}
+	}
+	return c.Client.OS().Mount(ctx, m.device, m.mountPoint, mo)
+}
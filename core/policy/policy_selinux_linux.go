@@ -0,0 +1,85 @@
+package policy
+
+import (
+	"bufio"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/akutz/goof"
+)
+
+// mount is an entry of /proc/self/mounts.
+type mount struct {
+	device     string
+	mountPoint string
+	options    string
+}
+
+// selinuxEnabled returns a flag indicating whether or not the host enforces
+// or audits SELinux policy.
+func selinuxEnabled() bool {
+	_, err := os.Stat("/sys/fs/selinux/enforce")
+	return err == nil
+}
+
+// relabel labels the files under the provided path with the provided
+// context.
+func relabel(path, label string) error {
+	return run("chcon", "-R", label, path)
+}
+
+// restorecon labels the files under the provided path with the contexts
+// the host's policy defines for them.
+func restorecon(path string) error {
+	return run("restorecon", "-R", path)
+}
+
+func run(name string, args ...string) error {
+	out, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil {
+		return goof.WithFieldsE(goof.Fields{
+			"command": name,
+			"output":  strings.TrimSpace(string(out)),
+		}, "error running command", err)
+	}
+	return nil
+}
+
+// mountOf returns the mount of the file system containing the provided
+// path.
+func mountOf(path string) (*mount, error) {
+	mountPoint, err := mountPointOf(path)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open("/proc/self/mounts")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var m *mount
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 || fields[1] != mountPoint {
+			continue
+		}
+		// the last entry of a mount point is the one that is visible
+		m = &mount{
+			device:     fields[0],
+			mountPoint: fields[1],
+			options:    fields[3],
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if m == nil {
+		return nil, goof.WithField("path", path, "mount point not found")
+	}
+	return m, nil
+}
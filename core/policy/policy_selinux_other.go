@@ -0,0 +1,27 @@
+// +build !linux
+
+package policy
+
+import "github.com/akutz/goof"
+
+type mount struct {
+	device     string
+	mountPoint string
+	options    string
+}
+
+func selinuxEnabled() bool {
+	return false
+}
+
+func relabel(path, label string) error {
+	return goof.New("selinux labels are only supported on Linux")
+}
+
+func restorecon(path string) error {
+	return goof.New("selinux labels are only supported on Linux")
+}
+
+func mountOf(path string) (*mount, error) {
+	return nil, goof.New("selinux labels are only supported on Linux")
+}
@@ -0,0 +1,127 @@
+package policy
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/akutz/goof"
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/core/sharedfs"
+	"github.com/emccode/rexray/util"
+)
+
+func sharedMountPath(volumeID string) string {
+	return util.RunFilePath(filepath.Join(
+		"fs", strings.Replace(volumeID, "/", "_", -1)))
+}
+
+// isSharedMounted returns a flag indicating whether the volume's shared file
+// system is mounted at its mount path.
+func isSharedMounted(volumeID string) (bool, error) {
+	return isBound(sharedMountPath(volumeID))
+}
+
+// sharedMounter returns the function that mounts the volumes of the
+// service's driver if its volumes are shared file systems.
+func (c *client) sharedMounter(
+	ctx apitypes.Context) (sharedfs.Func, error) {
+
+	driverName, err := c.driverName(ctx)
+	if err != nil {
+		return nil, err
+	}
+	f, _ := sharedfs.Lookup(driverName)
+	return f, nil
+}
+
+// sharedPath returns the mount path of a volume whose shared file system is
+// mounted on the local instance. The volume is only looked up if any shared
+// file system is mounted.
+func (c *client) sharedPath(
+	ctx apitypes.Context, volumeID, volumeName string) (string, bool) {
+
+	if fis, _ := ioutil.ReadDir(util.RunFilePath("fs")); len(fis) == 0 {
+		return "", false
+	}
+	id, err := c.volumeID(ctx, volumeID, volumeName)
+	if err != nil {
+		return "", false
+	}
+	if mounted, _ := isSharedMounted(id); !mounted {
+		return "", false
+	}
+	return sharedMountPath(id), true
+}
+
+// mountShared attaches a volume to the local instance, which grants the
+// instance access to the volume's file system, and mounts the file system
+// with the driver's mount function. The volume is attached even if it
+// already is since the attach token is required to mount it.
+func (c *client) mountShared(
+	ctx apitypes.Context,
+	volumeID string,
+	mount sharedfs.Func,
+	opts *apitypes.VolumeMountOpts,
+	readOnly bool) (string, *apitypes.Volume, error) {
+
+	vol, token, err := c.Client.Storage().VolumeAttach(
+		ctx, volumeID, &apitypes.VolumeAttachOpts{
+			Force: opts.Preempt,
+			Opts:  opts.Opts,
+		})
+	if err != nil {
+		return "", nil, err
+	}
+
+	mountPath := sharedMountPath(volumeID)
+	if err := os.MkdirAll(mountPath, 0750); err != nil {
+		return "", nil, err
+	}
+	mounted, err := isBound(mountPath)
+	if err != nil {
+		return "", nil, err
+	}
+	if !mounted {
+		if err := mount(
+			ctx, c.config, vol, token, mountPath, readOnly); err != nil {
+			return "", nil, goof.WithFieldsE(goof.Fields{
+				"volumeID":  volumeID,
+				"mountPath": mountPath,
+			}, "error mounting shared file system", err)
+		}
+	}
+
+	ctx.WithFields(map[string]interface{}{
+		"volumeID":  volumeID,
+		"mountPath": mountPath,
+	}).Info("mounted shared file system")
+	return mountPath, vol, nil
+}
+
+// unmountSharedAndDetach unmounts a volume's shared file system and detaches
+// the volume unless volumes remain attached after an unmount.
+func (c *client) unmountSharedAndDetach(
+	ctx apitypes.Context, volumeID string, opts apitypes.Store) error {
+
+	mountPath := sharedMountPath(volumeID)
+	if err := unbindDevice(mountPath); err != nil {
+		return err
+	}
+	if err := os.Remove(mountPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	ctx.WithField("volumeID", volumeID).Info("unmounted shared file system")
+
+	if GetAttachMode(c.config) != AttachOnMount {
+		return nil
+	}
+	if _, err := c.Client.Storage().VolumeDetach(ctx, volumeID,
+		&apitypes.VolumeDetachOpts{Opts: opts}); err != nil {
+		return err
+	}
+	c.releaseAttachment(ctx, volumeID)
+	return nil
+}
@@ -0,0 +1,76 @@
+package policy
+
+import (
+	"strings"
+
+	"github.com/akutz/goof"
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/core/topology"
+)
+
+// createTopology returns the segment in which a volume is created according
+// to the topology requirement of its create options. The zone of the
+// selected segment becomes the volume's availability zone, and an
+// availability zone that is requested explicitly must be permitted by the
+// requisite segments. The returned segment is nil if there is no
+// requirement.
+func (c *client) createTopology(
+	ctx apitypes.Context,
+	opts *apitypes.VolumeCreateOpts) (topology.Segment, error) {
+
+	if opts == nil {
+		return nil, nil
+	}
+	r, err := topology.FromOpts(opts.Opts)
+	if err != nil {
+		return nil, err
+	}
+	if r.IsEmpty() {
+		return nil, nil
+	}
+
+	if opts.AvailabilityZone != nil && *opts.AvailabilityZone != "" {
+		seg := topology.Segment{topology.Zone: *opts.AvailabilityZone}
+		for _, req := range r.Requisite {
+			if z, ok := req[topology.Zone]; ok && strings.EqualFold(
+				z, *opts.AvailabilityZone) {
+				seg = req
+				break
+			}
+		}
+		if !r.Permits(seg) {
+			return nil, goof.WithFields(goof.Fields{
+				"availabilityZone": *opts.AvailabilityZone,
+				"requisite":        topology.FormatSegments(r.Requisite),
+			}, "availability zone is not in the requisite topology")
+		}
+		return seg, nil
+	}
+
+	seg := r.Select()
+	if az, ok := seg[topology.Zone]; ok {
+		opts.AvailabilityZone = &az
+	}
+	ctx.WithField("topology", seg.String()).Debug(
+		"selected volume topology")
+	return seg, nil
+}
+
+// setVolumeTopology records the segment in which a volume was created. The
+// volume's availability zone is recorded as its zone if the segment has
+// none.
+func (c *client) setVolumeTopology(
+	ctx apitypes.Context, vol *apitypes.Volume, seg topology.Segment) {
+
+	if seg == nil {
+		return
+	}
+	if _, ok := seg[topology.Zone]; !ok && vol.AvailabilityZone != "" {
+		seg[topology.Zone] = vol.AvailabilityZone
+	}
+	if err := topology.SetVolume(c.store, vol.ID, seg); err != nil {
+		ctx.WithError(err).WithField("volumeID", vol.ID).Warn(
+			"error storing volume topology")
+	}
+}
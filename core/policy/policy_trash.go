@@ -0,0 +1,114 @@
+package policy
+
+import (
+	"github.com/akutz/goof"
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/core/protect"
+	"github.com/emccode/rexray/core/trash"
+)
+
+// checkProtected returns an error if the volume with the provided ID is
+// protected from removal.
+func (c *client) checkProtected(ctx apitypes.Context, volumeID string) error {
+	p, ok, err := protect.Get(c.store, volumeID)
+	if err != nil || !ok {
+		return err
+	}
+	ctx.WithField("volumeID", volumeID).Warn(
+		"refused removal of protected volume")
+	return &protect.Error{Protection: p}
+}
+
+// checkTrashed returns an error if the volume with the provided ID is in the
+// trash.
+func (c *client) checkTrashed(
+	ctx apitypes.Context, volumeID, op string) error {
+
+	e, ok, err := trash.Get(c.store, volumeID)
+	if err != nil || !ok {
+		return err
+	}
+	return &trash.Error{Entry: e, Operation: op}
+}
+
+// trashVolume moves a volume to the trash instead of removing it. The
+// returned flag is false if the volume should be removed instead, either
+// because the trash is disabled or because a purge was requested.
+func (c *client) trashVolume(
+	ctx apitypes.Context, volumeID string, opts apitypes.Store) (bool, error) {
+
+	if !trash.Enabled(c.config) || trash.IsPurge(opts) {
+		return false, nil
+	}
+
+	if _, ok, err := trash.Get(c.store, volumeID); err != nil || ok {
+		return ok, err
+	}
+
+	vol, err := c.Client.Storage().VolumeInspect(
+		ctx, volumeID, &apitypes.VolumeInspectOpts{Attachments: true})
+	if err != nil {
+		return false, err
+	}
+	if len(vol.Attachments) > 0 {
+		return false, goof.WithField(
+			"volumeID", volumeID, "volume is attached")
+	}
+
+	e, err := trash.Add(c.config, c.store, vol)
+	if err != nil {
+		return false, err
+	}
+	if err := c.markTrashed(ctx, e); err != nil {
+		if _, rerr := trash.Restore(c.store, volumeID); rerr != nil {
+			ctx.WithError(rerr).WithField("volumeID", volumeID).Warn(
+				"error removing unmarked volume from trash")
+		}
+		return false, err
+	}
+	ctx.WithFields(map[string]interface{}{
+		"volumeID":   volumeID,
+		"purgeAfter": e.PurgeAfter,
+	}).Info("moved volume to trash")
+	return true, nil
+}
+
+// markTrashed marks a trashed volume on its storage platform, if the
+// platform's driver has a marker.
+func (c *client) markTrashed(ctx apitypes.Context, e *trash.Entry) error {
+	driver, err := c.driverName(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = trash.Mark(ctx, c.config, driver, e)
+	return err
+}
+
+// Volumes hides the trashed volumes.
+func (d *storageDriver) Volumes(
+	ctx apitypes.Context,
+	opts *apitypes.VolumesOpts) ([]*apitypes.Volume, error) {
+
+	vols, err := d.StorageDriver.Volumes(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	trashed, err := trash.List(d.c.store)
+	if err != nil || len(trashed) == 0 {
+		return vols, err
+	}
+	hidden := map[string]bool{}
+	for _, e := range trashed {
+		hidden[e.VolumeID] = true
+	}
+
+	visible := []*apitypes.Volume{}
+	for _, v := range vols {
+		if !hidden[v.ID] {
+			visible = append(visible, v)
+		}
+	}
+	return visible, nil
+}
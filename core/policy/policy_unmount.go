@@ -0,0 +1,211 @@
+package policy
+
+import (
+	"strings"
+	"time"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	apitypes "github.com/emccode/libstorage/api/types"
+	apiutils "github.com/emccode/libstorage/api/utils"
+
+	"github.com/emccode/rexray/core/openfiles"
+)
+
+func init() {
+	r := gofig.NewRegistration("Volume Unmount")
+	r.Key(gofig.Int, "", 3,
+		"The number of times a busy unmount is retried",
+		"rexray.volume.unmount.retries")
+	r.Key(gofig.String, "", "1s",
+		"The duration to wait between unmount retries",
+		"rexray.volume.unmount.retryDelay")
+	r.Key(gofig.Bool, "", false,
+		"Kill the processes that prevent a volume from being unmounted",
+		"rexray.volume.unmount.killProcesses")
+	gofig.Register(r)
+}
+
+// isBusy returns a flag indicating whether or not the provided error is the
+// result of unmounting a file system that is in use.
+func isBusy(err error) bool {
+	return err != nil && strings.Contains(strings.ToLower(err.Error()), "busy")
+}
+
+func unmountRetryDelay(config gofig.Config) time.Duration {
+	d, err := time.ParseDuration(
+		config.GetString("rexray.volume.unmount.retryDelay"))
+	if err != nil {
+		return time.Second
+	}
+	return d
+}
+
+// localMountPoints returns the local mount points of a volume's device.
+func (c *client) localMountPoints(
+	ctx apitypes.Context, volumeID string) ([]string, error) {
+
+	vol, attached, err := c.isAttachedLocally(ctx, volumeID)
+	if err != nil || !attached {
+		return nil, err
+	}
+
+	iid, err := c.instanceID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	mountPoints := []string{}
+	for _, a := range vol.Attachments {
+		if a.InstanceID == nil || a.InstanceID.ID != iid {
+			continue
+		}
+		mounts, err := c.Client.OS().Mounts(
+			ctx, a.DeviceName, "", apiutils.NewStore())
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range mounts {
+			mountPoints = append(mountPoints, m.MountPoint)
+		}
+	}
+	return mountPoints, nil
+}
+
+// unmountWithEscalation invokes the provided unmount function, and if it
+// fails because the volume is busy, escalates until the volume's mount points
+// are released before invoking it once more:
+//
+//  1. the unmount is retried rexray.volume.unmount.retries times
+//  2. if rexray.volume.unmount.killProcesses is enabled, the processes with
+//     files open on a mount point are killed and it is unmounted again
+//
+// A busy volume is never unmounted lazily since it could then be detached
+// while a process is still writing to it. If the volume cannot be released
+// the returned error lists the processes and files that hold it.
+func (c *client) unmountWithEscalation(
+	ctx apitypes.Context, volumeID string, unmount func() error) error {
+
+	err := unmount()
+	if !isBusy(err) {
+		return err
+	}
+
+	fields := map[string]interface{}{"volumeID": volumeID}
+	retries := c.config.GetInt("rexray.volume.unmount.retries")
+	delay := unmountRetryDelay(c.config)
+
+	for i := 1; i <= retries; i++ {
+		fields["attempt"] = i
+		ctx.WithFields(fields).WithError(err).Warn(
+			"volume busy; retrying unmount")
+		time.Sleep(delay)
+		if err = unmount(); !isBusy(err) {
+			return err
+		}
+	}
+	delete(fields, "attempt")
+
+	mountPoints, mpErr := c.localMountPoints(ctx, volumeID)
+	if mpErr != nil {
+		return mpErr
+	}
+
+	for _, mp := range mountPoints {
+		fields["mountPoint"] = mp
+		if escErr := c.releaseMountPoint(ctx, mp, fields); escErr != nil {
+			return escErr
+		}
+	}
+
+	if err = unmount(); isBusy(err) {
+		delete(fields, "mountPoint")
+		return busyError(ctx, mountPoints, fields, err)
+	}
+	return err
+}
+
+// busyError returns an error describing the processes that have files open
+// on the provided mount points, and logs each of them so that operators can
+// identify the process or container preventing the unmount.
+func busyError(
+	ctx apitypes.Context,
+	mountPoints []string,
+	fields map[string]interface{},
+	err error) error {
+
+	files := []*openfiles.File{}
+	for _, mp := range mountPoints {
+		mpFiles, lerr := openfiles.List(mp)
+		if lerr != nil {
+			ctx.WithError(lerr).WithField("mountPoint", mp).Warn(
+				"error listing open files")
+		}
+		files = append(files, mpFiles...)
+	}
+
+	for _, f := range files {
+		ctx.WithFields(fields).WithFields(map[string]interface{}{
+			"pid":         f.PID,
+			"command":     f.Command,
+			"containerID": f.ContainerID,
+			"fd":          f.FD,
+			"path":        f.Path,
+		}).Error("open file prevents unmount")
+	}
+
+	efields := goof.Fields{
+		"mountPoints": mountPoints,
+		"openFiles":   files,
+	}
+	for k, v := range fields {
+		efields[k] = v
+	}
+
+	msg := "volume busy"
+	if len(files) > 0 {
+		msg = "volume busy; open files:\n" + openfiles.Describe(files)
+	}
+	return goof.WithFieldsE(efields, msg, err)
+}
+
+// releaseMountPoint kills the processes that have files open on a busy mount
+// point, if permitted, so that it may be unmounted. A busy mount point is
+// never unmounted lazily, even if no open files are found, since the scan
+// may have missed a process that would then keep writing to the volume after
+// it is detached.
+func (c *client) releaseMountPoint(
+	ctx apitypes.Context,
+	mountPoint string,
+	fields map[string]interface{}) error {
+
+	files, err := openfiles.List(mountPoint)
+	if err != nil && !openfiles.IsIncomplete(err) {
+		return err
+	}
+
+	if len(files) == 0 {
+		if err == nil {
+			err = goof.New("no open files found")
+		}
+		return busyError(ctx, []string{mountPoint}, fields, err)
+	}
+
+	if !c.config.GetBool("rexray.volume.unmount.killProcesses") {
+		return busyError(ctx, []string{mountPoint}, fields,
+			goof.New("processes have open files"))
+	}
+
+	for _, f := range files {
+		ctx.WithFields(fields).WithFields(map[string]interface{}{
+			"pid":         f.PID,
+			"command":     f.Command,
+			"containerID": f.ContainerID,
+			"path":        f.Path,
+		}).Warn("killing process holding busy volume")
+		if err := killProcess(f.PID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
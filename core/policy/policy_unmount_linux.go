@@ -0,0 +1,32 @@
+package policy
+
+import (
+	"syscall"
+	"time"
+
+	"github.com/akutz/goof"
+)
+
+// killProcess asks the process with the provided ID to terminate, and kills
+// it if it has not exited within five seconds.
+func killProcess(pid int) error {
+	if err := syscall.Kill(pid, syscall.SIGTERM); err != nil {
+		if err == syscall.ESRCH {
+			return nil
+		}
+		return goof.WithFieldE("pid", pid, "error terminating process", err)
+	}
+
+	for i := 0; i < 50; i++ {
+		if syscall.Kill(pid, 0) == syscall.ESRCH {
+			return nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	if err := syscall.Kill(pid, syscall.SIGKILL); err != nil &&
+		err != syscall.ESRCH {
+		return goof.WithFieldE("pid", pid, "error killing process", err)
+	}
+	return nil
+}
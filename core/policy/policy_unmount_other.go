@@ -0,0 +1,9 @@
+// +build !linux
+
+package policy
+
+import "github.com/akutz/goof"
+
+func killProcess(pid int) error {
+	return goof.New("killing processes is only supported on Linux")
+}
@@ -0,0 +1,405 @@
+// Package preflight checks that the host is able to run the configured
+// services before the REX-Ray service starts, so that a missing kernel
+// module or binary is reported when the service starts rather than when the
+// first volume is mounted.
+package preflight
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	"github.com/akutz/gotil"
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/util"
+)
+
+// Status is the outcome of a check.
+type Status string
+
+const (
+	// Pass indicates a check succeeded.
+	Pass Status = "pass"
+
+	// Warn indicates a check found a problem that may not prevent the
+	// service from working.
+	Warn Status = "warn"
+
+	// Fail indicates a check found a problem that prevents a service from
+	// working.
+	Fail Status = "fail"
+)
+
+// Check is the result of a single pre-flight check.
+type Check struct {
+	Name    string `json:"name" yaml:"name"`
+	Service string `json:"service,omitempty" yaml:"service,omitempty"`
+	Status  Status `json:"status" yaml:"status"`
+	Message string `json:"message,omitempty" yaml:"message,omitempty"`
+
+	// Remedy describes how to correct a failed check.
+	Remedy string `json:"remedy,omitempty" yaml:"remedy,omitempty"`
+}
+
+// Report is the result of the pre-flight checks.
+type Report struct {
+	Time   time.Time `json:"time" yaml:"time"`
+	Passed bool      `json:"passed" yaml:"passed"`
+	Checks []*Check  `json:"checks" yaml:"checks"`
+}
+
+// requirement is what a driver requires of the host.
+type requirement struct {
+	modules  []string
+	binaries []string
+
+	// endpoints are the keys of the service configuration that hold the
+	// address of the driver's backend.
+	endpoints []string
+
+	// endpoint returns the address of the driver's backend when it is not
+	// configured.
+	endpoint func(config gofig.Config, service string) string
+}
+
+var requirements = map[string]*requirement{
+	"rbd": {
+		modules:  []string{"rbd"},
+		binaries: []string{"rbd"},
+	},
+	"nbd": {
+		modules:  []string{"nbd"},
+		binaries: []string{"qemu-nbd"},
+	},
+	"iscsi": {
+		modules:   []string{"iscsi_tcp"},
+		binaries:  []string{"iscsiadm"},
+		endpoints: []string{"iscsi.targetd.endpoint"},
+	},
+	"equinix": {
+		modules:   []string{"iscsi_tcp"},
+		binaries:  []string{"iscsiadm"},
+		endpoints: []string{"equinix.endpoint"},
+	},
+	"unity": {
+		modules:   []string{"iscsi_tcp"},
+		binaries:  []string{"iscsiadm"},
+		endpoints: []string{"unity.endpoint"},
+	},
+	"powerstore": {
+		modules:   []string{"iscsi_tcp"},
+		binaries:  []string{"iscsiadm"},
+		endpoints: []string{"powerstore.endpoint"},
+	},
+	"nimble": {
+		modules:   []string{"iscsi_tcp"},
+		binaries:  []string{"iscsiadm"},
+		endpoints: []string{"nimble.endpoint"},
+	},
+	"ontapsan": {
+		modules:   []string{"iscsi_tcp"},
+		binaries:  []string{"iscsiadm"},
+		endpoints: []string{"ontap.endpoint"},
+	},
+	"nvmeof": {
+		modules:   []string{"nvme_fabrics"},
+		binaries:  []string{"nvme"},
+		endpoints: []string{"nvmeof.endpoint"},
+	},
+	"scaleio": {
+		endpoints: []string{"scaleio.endpoint"},
+	},
+	"isilon": {
+		binaries:  []string{"mount.nfs"},
+		endpoints: []string{"isilon.endpoint"},
+	},
+	"ontapnas": {
+		binaries:  []string{"mount.nfs"},
+		endpoints: []string{"ontap.endpoint"},
+	},
+	"efs": {
+		binaries: []string{"mount.nfs4"},
+	},
+	"glusterfs": {
+		modules:  []string{"fuse"},
+		binaries: []string{"mount.glusterfs"},
+	},
+	"s3fs": {
+		modules:  []string{"fuse"},
+		binaries: []string{"s3fs"},
+	},
+	"ebs": {
+		endpoint: ec2Endpoint,
+	},
+	"ec2": {
+		endpoint: ec2Endpoint,
+	},
+	"gcepd": {
+		endpoint: func(gofig.Config, string) string {
+			return "https://compute.googleapis.com"
+		},
+	},
+	"external": {
+		endpoints: []string{"external.address"},
+	},
+}
+
+func ec2Endpoint(config gofig.Config, service string) string {
+	region := config.GetString(serviceKey(service, "ebs.region"))
+	if region == "" {
+		region = config.GetString("ebs.region")
+	}
+	if region == "" {
+		return ""
+	}
+	return fmt.Sprintf("https://ec2.%s.amazonaws.com", region)
+}
+
+func init() {
+	r := gofig.NewRegistration("Pre-flight Checks")
+	r.Key(gofig.Bool, "", true,
+		"Check the host before the REX-Ray service starts",
+		"rexray.preflight.enabled")
+	r.Key(gofig.Bool, "", true,
+		"Refuse to start the REX-Ray service if a check fails",
+		"rexray.preflight.failOnError")
+	r.Key(gofig.String, "", "5s",
+		"How long to wait for a backend to accept a connection",
+		"rexray.preflight.timeout")
+	gofig.Register(r)
+}
+
+func serviceKey(service, key string) string {
+	return fmt.Sprintf("%s.%s.%s", apitypes.ConfigServices, service, key)
+}
+
+// ReportPath returns the path of the report written by Verify.
+func ReportPath() string {
+	return util.RunFilePath("preflight.json")
+}
+
+// Run runs the pre-flight checks for the configured services.
+func Run(config gofig.Config) *Report {
+	r := &Report{Time: time.Now().UTC(), Passed: true}
+
+	timeout, err := time.ParseDuration(
+		config.GetString("rexray.preflight.timeout"))
+	if err != nil || timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+
+	r.add(checkDir("runDir", util.RunDirPath()))
+	for _, dir := range socketDirs(config) {
+		r.add(checkDir("socketDir", dir))
+	}
+
+	services, _ := config.Get(apitypes.ConfigServices).(map[string]interface{})
+	for name := range services {
+		driver := strings.ToLower(
+			config.GetString(serviceKey(name, "driver")))
+		if driver == "" {
+			driver = strings.ToLower(name)
+		}
+		req, ok := requirements[driver]
+		if !ok {
+			continue
+		}
+		for _, m := range req.modules {
+			r.add(service(name, checkModule(m)))
+		}
+		for _, b := range req.binaries {
+			r.add(service(name, checkBinary(b)))
+		}
+		for _, addr := range endpoints(config, name, req) {
+			r.add(service(name, checkReachable(addr, timeout)))
+		}
+	}
+
+	return r
+}
+
+// Verify runs the pre-flight checks, logs the failed checks along with their
+// remedies, and writes the report to ReportPath. An error is returned if a
+// check failed and rexray.preflight.failOnError is set.
+func Verify(ctx apitypes.Context, config gofig.Config) error {
+	if !config.GetBool("rexray.preflight.enabled") {
+		return nil
+	}
+
+	r := Run(config)
+	for _, c := range r.Checks {
+		if c.Status == Pass {
+			continue
+		}
+		f := ctx.WithFields(map[string]interface{}{
+			"check":   c.Name,
+			"service": c.Service,
+			"remedy":  c.Remedy,
+		})
+		if c.Status == Warn {
+			f.Warn(c.Message)
+		} else {
+			f.Error(c.Message)
+		}
+	}
+
+	if buf, err := json.MarshalIndent(r, "", "  "); err == nil {
+		if err := ioutil.WriteFile(ReportPath(), buf, 0644); err != nil {
+			ctx.WithError(err).Warn("error writing pre-flight report")
+		}
+	}
+
+	if !r.Passed && config.GetBool("rexray.preflight.failOnError") {
+		return goof.WithField("report", ReportPath(), "pre-flight checks failed")
+	}
+	return nil
+}
+
+func (r *Report) add(c *Check) {
+	if c.Status == Fail {
+		r.Passed = false
+	}
+	r.Checks = append(r.Checks, c)
+}
+
+func service(name string, c *Check) *Check {
+	c.Service = name
+	return c
+}
+
+// socketDirs returns the directories of the UNIX sockets on which the
+// configured modules listen.
+func socketDirs(config gofig.Config) []string {
+	mods, _ := config.Get("rexray.modules").(map[string]interface{})
+	seen := map[string]bool{}
+	dirs := []string{}
+	for name := range mods {
+		host := config.GetString(fmt.Sprintf("rexray.modules.%s.host", name))
+		proto, addr, err := gotil.ParseAddress(host)
+		if err != nil || proto != "unix" {
+			continue
+		}
+		dir := filepath.Dir(addr)
+		if !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+func endpoints(config gofig.Config, service string, req *requirement) []string {
+	addrs := []string{}
+	for _, k := range req.endpoints {
+		if v := config.GetString(serviceKey(service, k)); v != "" {
+			addrs = append(addrs, v)
+		} else if v := config.GetString(k); v != "" {
+			addrs = append(addrs, v)
+		}
+	}
+	if len(addrs) == 0 && req.endpoint != nil {
+		if v := req.endpoint(config, service); v != "" {
+			addrs = append(addrs, v)
+		}
+	}
+	return addrs
+}
+
+func checkDir(name, dir string) *Check {
+	c := &Check{Name: name}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		c.Status = Fail
+		c.Message = fmt.Sprintf("cannot create %s: %v", dir, err)
+		c.Remedy = fmt.Sprintf(
+			"create %s or run the REX-Ray service as a user that can", dir)
+		return c
+	}
+	f, err := ioutil.TempFile(dir, ".rexray-preflight")
+	if err != nil {
+		c.Status = Fail
+		c.Message = fmt.Sprintf("cannot write to %s: %v", dir, err)
+		c.Remedy = fmt.Sprintf(
+			"grant the REX-Ray service's user write access to %s", dir)
+		return c
+	}
+	f.Close()
+	os.Remove(f.Name())
+	c.Status = Pass
+	c.Message = dir
+	return c
+}
+
+func checkBinary(name string) *Check {
+	c := &Check{Name: "binary"}
+	path, err := exec.LookPath(name)
+	if err != nil {
+		c.Status = Fail
+		c.Message = fmt.Sprintf("%s not found in PATH", name)
+		c.Remedy = fmt.Sprintf("install the package that provides %s", name)
+		return c
+	}
+	c.Status = Pass
+	c.Message = path
+	return c
+}
+
+func checkReachable(addr string, timeout time.Duration) *Check {
+	c := &Check{Name: "reachable"}
+
+	hostPort, err := dialAddress(addr)
+	if err != nil {
+		c.Status = Fail
+		c.Message = fmt.Sprintf("invalid backend address %s: %v", addr, err)
+		c.Remedy = "correct the address in the service's configuration"
+		return c
+	}
+
+	conn, err := net.DialTimeout("tcp", hostPort, timeout)
+	if err != nil {
+		c.Status = Fail
+		c.Message = fmt.Sprintf("cannot connect to %s: %v", hostPort, err)
+		c.Remedy = "check the address, DNS, routes, and firewall rules " +
+			"between this host and the backend"
+		return c
+	}
+	conn.Close()
+	c.Status = Pass
+	c.Message = hostPort
+	return c
+}
+
+// dialAddress returns the host and port of a backend address, which may be
+// a URL or a host with an optional port.
+func dialAddress(addr string) (string, error) {
+	if !strings.Contains(addr, "://") {
+		if _, _, err := net.SplitHostPort(addr); err == nil {
+			return addr, nil
+		}
+		return net.JoinHostPort(addr, "443"), nil
+	}
+	u, err := url.Parse(addr)
+	if err != nil {
+		return "", err
+	}
+	if u.Host == "" {
+		return "", goof.New("missing host")
+	}
+	if _, _, err := net.SplitHostPort(u.Host); err == nil {
+		return u.Host, nil
+	}
+	port := "443"
+	if u.Scheme == "http" {
+		port = "80"
+	}
+	return net.JoinHostPort(u.Host, port), nil
+}
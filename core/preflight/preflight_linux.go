@@ -0,0 +1,35 @@
+// +build linux
+
+package preflight
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+func checkModule(name string) *Check {
+	c := &Check{Name: "kernelModule", Message: name}
+
+	// built-in modules are absent from /proc/modules but present in
+	// /sys/module
+	if _, err := os.Stat("/sys/module/" + name); err == nil {
+		c.Status = Pass
+		return c
+	}
+	if buf, err := ioutil.ReadFile("/proc/modules"); err == nil {
+		for _, l := range strings.Split(string(buf), "\n") {
+			if strings.HasPrefix(l, name+" ") {
+				c.Status = Pass
+				return c
+			}
+		}
+	}
+
+	c.Status = Fail
+	c.Message = fmt.Sprintf("kernel module %s is not loaded", name)
+	c.Remedy = fmt.Sprintf(
+		"run 'modprobe %[1]s' and add %[1]s to /etc/modules-load.d", name)
+	return c
+}
@@ -0,0 +1,11 @@
+// +build !linux
+
+package preflight
+
+func checkModule(name string) *Check {
+	return &Check{
+		Name:    "kernelModule",
+		Status:  Warn,
+		Message: "kernel modules are only checked on Linux: " + name,
+	}
+}
@@ -0,0 +1,134 @@
+// Package probes reports whether the REX-Ray service is live and whether it
+// is ready to serve volume requests. Readiness aggregates the pre-flight
+// checks of each configured service's driver, such as its kernel modules,
+// binaries, and backend, with a call to the storage platform that requires
+// valid credentials and the state of the service's circuit breaker.
+package probes
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/akutz/gofig"
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/core/breaker"
+	"github.com/emccode/rexray/core/preflight"
+)
+
+func init() {
+	r := gofig.NewRegistration("Health Probes")
+	r.Key(gofig.String, "", "10s",
+		"How long the readiness probe waits for the storage platform",
+		"rexray.probes.timeout")
+	gofig.Register(r)
+}
+
+// Result is the result of a probe.
+type Result struct {
+	Ready    bool               `json:"ready" yaml:"ready"`
+	Degraded bool               `json:"degraded,omitempty" yaml:"degraded,omitempty"`
+	Checked  time.Time          `json:"checked" yaml:"checked"`
+	Checks   []*preflight.Check `json:"checks,omitempty" yaml:"checks,omitempty"`
+}
+
+// Live returns the result of the liveness probe. The service is live if it
+// is able to respond.
+func Live() *Result {
+	return &Result{Ready: true, Checked: time.Now().UTC()}
+}
+
+// Ready returns the result of the readiness probe. The service is ready if
+// none of its checks fail, and degraded if its circuit is open.
+func Ready(
+	ctx apitypes.Context,
+	config gofig.Config,
+	client apitypes.Client) *Result {
+
+	r := &Result{Ready: true, Checked: time.Now().UTC()}
+
+	pr := preflight.Run(config)
+	for _, c := range pr.Checks {
+		r.add(c)
+	}
+	r.add(checkCredentials(ctx, config, client))
+	if c := checkCircuit(config); c != nil {
+		r.Degraded = true
+		r.add(c)
+	}
+	return r
+}
+
+func (r *Result) add(c *preflight.Check) {
+	if c.Status == preflight.Fail {
+		r.Ready = false
+	}
+	r.Checks = append(r.Checks, c)
+}
+
+// checkCredentials lists the volumes of the client's service, which fails if
+// the service's credentials are invalid or its backend cannot be reached.
+func checkCredentials(
+	ctx apitypes.Context,
+	config gofig.Config,
+	client apitypes.Client) *preflight.Check {
+
+	c := &preflight.Check{
+		Name:    "credentials",
+		Service: config.GetString(apitypes.ConfigService),
+	}
+
+	timeout, err := time.ParseDuration(
+		config.GetString("rexray.probes.timeout"))
+	if err != nil || timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := client.Storage().Volumes(
+			ctx, &apitypes.VolumesOpts{Attachments: false})
+		errs <- err
+	}()
+
+	select {
+	case err := <-errs:
+		if err != nil {
+			c.Status = preflight.Fail
+			c.Message = fmt.Sprintf("error listing volumes: %v", err)
+			c.Remedy = "check the service's credentials and that its " +
+				"storage platform is reachable"
+			return c
+		}
+	case <-time.After(timeout):
+		c.Status = preflight.Fail
+		c.Message = fmt.Sprintf("listing volumes took longer than %s", timeout)
+		c.Remedy = "check that the service's storage platform is reachable " +
+			"and responsive"
+		return c
+	}
+
+	c.Status = preflight.Pass
+	c.Message = "listed volumes"
+	return c
+}
+
+// checkCircuit returns a warning if the service's circuit is open, or nil if
+// it is closed.
+func checkCircuit(config gofig.Config) *preflight.Check {
+	service := config.GetString(apitypes.ConfigService)
+	s, ok := breaker.Get(service)
+	if !ok || s.State == breaker.Closed {
+		return nil
+	}
+	return &preflight.Check{
+		Name:    "circuit",
+		Service: service,
+		Status:  preflight.Warn,
+		Message: fmt.Sprintf(
+			"operations fail fast until %s after %d consecutive failures: %s",
+			s.Until.Format(time.RFC3339), s.Failures, s.LastError),
+		Remedy: "check that the service's storage platform is reachable " +
+			"and responsive",
+	}
+}
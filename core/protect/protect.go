@@ -0,0 +1,102 @@
+// Package protect records the volumes that are protected from removal. A
+// protected volume may not be removed until its protection is cleared, which
+// guards against accidentally deleting a volume that holds important data.
+package protect
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/emccode/rexray/core/errcodes"
+	"github.com/emccode/rexray/core/state"
+)
+
+const protectedBucket = "protectedVolumes"
+
+// Protection describes a protected volume.
+type Protection struct {
+
+	// VolumeID is the ID of the protected volume.
+	VolumeID string `json:"volumeID"`
+
+	// Reason is an optional note explaining why the volume is protected.
+	Reason string `json:"reason,omitempty"`
+
+	// Protected is the time at which the volume was protected.
+	Protected time.Time `json:"protected"`
+}
+
+// Error is returned when a volume is not removed because it is protected.
+type Error struct {
+	Protection *Protection
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf(
+		"volume remove refused: volume %s is protected", e.Protection.VolumeID)
+	if e.Protection.Reason != "" {
+		msg = fmt.Sprintf("%s: %s", msg, e.Protection.Reason)
+	}
+	return msg
+}
+
+// Status returns the HTTP status code with which the error is reported.
+func (e *Error) Status() int {
+	return http.StatusConflict
+}
+
+// Code returns the code of the error.
+func (e *Error) Code() errcodes.Code {
+	return errcodes.VolumeProtected
+}
+
+// Set protects the volume with the provided ID.
+func Set(s *state.Store, volumeID, reason string) (*Protection, error) {
+	p := &Protection{
+		VolumeID:  volumeID,
+		Reason:    reason,
+		Protected: time.Now().UTC(),
+	}
+	if err := s.Set(protectedBucket, volumeID, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Clear clears the protection of the volume with the provided ID.
+func Clear(s *state.Store, volumeID string) error {
+	return s.Delete(protectedBucket, volumeID)
+}
+
+// Get returns the protection of the volume with the provided ID. The
+// returned flag is false if the volume is not protected.
+func Get(s *state.Store, volumeID string) (*Protection, bool, error) {
+	p := &Protection{}
+	ok, err := s.Get(protectedBucket, volumeID, p)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	return p, true, nil
+}
+
+// List returns the protected volumes.
+func List(s *state.Store) ([]*Protection, error) {
+	ids, err := s.Keys(protectedBucket)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(ids)
+	all := []*Protection{}
+	for _, id := range ids {
+		p, ok, err := Get(s, id)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			all = append(all, p)
+		}
+	}
+	return all, nil
+}
@@ -0,0 +1,258 @@
+// Package quota limits the number and aggregate size of the volumes that may
+// be created by each tenant and principal.
+//
+// A volume's tenant is the value of its tenant label. A volume's principal is
+// the identity with which the libStorage server authenticated the request
+// that created it, which the server records when the volume is created. The
+// quotas are enforced by the server, so that a client cannot exceed them by
+// claiming another identity.
+package quota
+
+import (
+	"fmt"
+	"net/http"
+	"os/user"
+	"sort"
+	"strings"
+
+	"github.com/akutz/gofig"
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/core/errcodes"
+	"github.com/emccode/rexray/core/labels"
+	"github.com/emccode/rexray/core/state"
+)
+
+const (
+	// KindTenant is the kind of quota that applies to a tenant.
+	KindTenant = "tenant"
+
+	// KindPrincipal is the kind of quota that applies to a principal.
+	KindPrincipal = "principal"
+
+	// OwnersBucket is the bucket of the volumes' principals.
+	OwnersBucket = "volumeOwners"
+)
+
+func init() {
+	r := gofig.NewRegistration("Volume Quotas")
+	r.Key(gofig.String, "", "tenant",
+		"The key of the label that identifies a volume's tenant",
+		"rexray.quota.tenantLabel")
+	r.Key(gofig.String, "", "",
+		"The principal recorded as the owner of the volumes the client "+
+			"adopts; defaults to the name of the user running REX-Ray",
+		"rexray.quota.principal")
+	gofig.Register(r)
+}
+
+// Limit is the maximum number and aggregate size of volumes. A limit of zero
+// is unlimited.
+type Limit struct {
+
+	// Volumes is the maximum number of volumes.
+	Volumes int `json:"volumes"`
+
+	// Size is the maximum aggregate size of the volumes in GiB.
+	Size int64 `json:"size"`
+}
+
+// Usage is the current usage of a tenant or principal and its limit.
+type Usage struct {
+	Kind    string `json:"kind"`
+	Name    string `json:"name"`
+	Volumes int    `json:"volumes"`
+	Size    int64  `json:"size"`
+	Limit   Limit  `json:"limit"`
+}
+
+// ExceededError is returned when creating a volume would exceed a quota.
+type ExceededError struct {
+	Usage *Usage
+
+	// Size is the size in GiB of the volume that was refused.
+	Size int64
+}
+
+func (e *ExceededError) Error() string {
+	u := e.Usage
+	if u.Limit.Volumes > 0 && u.Volumes+1 > u.Limit.Volumes {
+		return fmt.Sprintf(
+			"volume quota exceeded for %s %s: %d of %d volumes in use",
+			u.Kind, u.Name, u.Volumes, u.Limit.Volumes)
+	}
+	return fmt.Sprintf(
+		"volume quota exceeded for %s %s: %dGiB of %dGiB in use, %dGiB requested",
+		u.Kind, u.Name, u.Size, u.Limit.Size, e.Size)
+}
+
+// Status returns the HTTP status code with which the error is reported.
+func (e *ExceededError) Status() int {
+	return http.StatusForbidden
+}
+
+// Code returns the code of the error.
+func (e *ExceededError) Code() errcodes.Code {
+	return errcodes.QuotaExceeded
+}
+
+// IsExceeded returns a flag indicating whether or not the provided error is
+// the result of exceeding a quota.
+func IsExceeded(err error) bool {
+	_, ok := err.(*ExceededError)
+	return ok
+}
+
+// TenantLabel returns the key of the label that identifies a volume's tenant.
+func TenantLabel(config gofig.Config) string {
+	return config.GetString("rexray.quota.tenantLabel")
+}
+
+// Principal returns the principal as which the client adopts volumes.
+func Principal(config gofig.Config) string {
+	if p := config.GetString("rexray.quota.principal"); p != "" {
+		return p
+	}
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return ""
+}
+
+// Limits returns the configured limits of the provided kind, keyed by the
+// name of the tenant or principal to which they apply.
+func Limits(config gofig.Config, kind string) map[string]Limit {
+	key := fmt.Sprintf("rexray.quota.%ss", kind)
+	limits := map[string]Limit{}
+	names, ok := config.Get(key).(map[string]interface{})
+	if !ok {
+		return limits
+	}
+	for name := range names {
+		sc := config.Scope(fmt.Sprintf("%s.%s", key, name))
+		limits[strings.ToLower(name)] = Limit{
+			Volumes: sc.GetInt("volumes"),
+			Size:    int64(sc.GetInt("size")),
+		}
+	}
+	return limits
+}
+
+// Owner returns the principal that created the volume with the provided ID.
+func Owner(s *state.Store, volumeID string) (string, error) {
+	var owner string
+	if _, err := s.Get(OwnersBucket, volumeID, &owner); err != nil {
+		return "", err
+	}
+	return owner, nil
+}
+
+// SetOwner records the principal that created the volume with the provided
+// ID. An empty principal removes the record.
+func SetOwner(s *state.Store, volumeID, principal string) error {
+	if principal == "" {
+		return s.Delete(OwnersBucket, volumeID)
+	}
+	return s.Set(OwnersBucket, volumeID, principal)
+}
+
+// Status returns the usage of each tenant and principal for which a quota is
+// configured.
+func Status(
+	config gofig.Config,
+	s *state.Store,
+	vols []*apitypes.Volume) ([]*Usage, error) {
+
+	usage := []*Usage{}
+	for _, kind := range []string{KindTenant, KindPrincipal} {
+		for name, limit := range Limits(config, kind) {
+			u, err := usageOf(config, s, vols, kind, name)
+			if err != nil {
+				return nil, err
+			}
+			u.Limit = limit
+			usage = append(usage, u)
+		}
+	}
+	sort.Sort(byKindAndName(usage))
+	return usage, nil
+}
+
+// Check returns an ExceededError if creating a volume of the provided size
+// in GiB for the tenant and principal would exceed either of their quotas.
+func Check(
+	config gofig.Config,
+	s *state.Store,
+	vols []*apitypes.Volume,
+	tenant, principal string,
+	size int64) error {
+
+	subjects := map[string]string{
+		KindTenant:    tenant,
+		KindPrincipal: principal,
+	}
+	for _, kind := range []string{KindTenant, KindPrincipal} {
+		name := strings.ToLower(subjects[kind])
+		if name == "" {
+			continue
+		}
+		limit, ok := Limits(config, kind)[name]
+		if !ok {
+			continue
+		}
+		u, err := usageOf(config, s, vols, kind, name)
+		if err != nil {
+			return err
+		}
+		u.Limit = limit
+		if (limit.Volumes > 0 && u.Volumes+1 > limit.Volumes) ||
+			(limit.Size > 0 && u.Size+size > limit.Size) {
+			return &ExceededError{Usage: u, Size: size}
+		}
+	}
+	return nil
+}
+
+func usageOf(
+	config gofig.Config,
+	s *state.Store,
+	vols []*apitypes.Volume,
+	kind, name string) (*Usage, error) {
+
+	u := &Usage{Kind: kind, Name: name}
+	tenantLabel := TenantLabel(config)
+	for _, v := range vols {
+		var subject string
+		switch kind {
+		case KindTenant:
+			l, err := labels.Volume(s, v.ID)
+			if err != nil {
+				return nil, err
+			}
+			subject = l[tenantLabel]
+		case KindPrincipal:
+			owner, err := Owner(s, v.ID)
+			if err != nil {
+				return nil, err
+			}
+			subject = owner
+		}
+		if strings.ToLower(subject) != name {
+			continue
+		}
+		u.Volumes++
+		u.Size += v.Size
+	}
+	return u, nil
+}
+
+type byKindAndName []*Usage
+
+func (u byKindAndName) Len() int      { return len(u) }
+func (u byKindAndName) Swap(i, j int) { u[i], u[j] = u[j], u[i] }
+func (u byKindAndName) Less(i, j int) bool {
+	if u[i].Kind != u[j].Kind {
+		return u[i].Kind > u[j].Kind
+	}
+	return u[i].Name < u[j].Name
+}
@@ -0,0 +1,329 @@
+package ratelimit
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	"github.com/akutz/gotil"
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/core/certs"
+	"github.com/emccode/rexray/core/openapi"
+	"github.com/emccode/rexray/core/systemd"
+	"github.com/emccode/rexray/core/tracing"
+)
+
+const endpointsKey = "libstorage.server.endpoints"
+
+// Front serves the addresses of the libStorage server's endpoints and
+// proxies the requests that are within the limits to the server. The
+// libStorage server has no means of adding handlers to its own, so its
+// endpoints are moved to unix sockets behind the front. The front also
+// terminates the endpoints' TLS, so that their certificates can be changed
+// while the server runs, and serves the API's OpenAPI document. Requests
+// are admitted by the registered filters before they are proxied, so that
+// policies are enforced by the server rather than trusted to its clients.
+// Since the server is only reached through the front, another server may be
+// started with new configuration and the front's requests directed to it.
+type Front struct {
+	ctx       apitypes.Context
+	config    gofig.Config
+	dir       string
+	limiter   *Limiter
+	openapi   bool
+	endpoints []*endpoint
+	listeners []net.Listener
+	gen       int
+}
+
+type endpoint struct {
+	sync.RWMutex
+	name    string
+	address string
+	proto   string
+	sock    string
+	tls     *tls.Config
+	handler http.Handler
+}
+
+// socket returns the path of the unix socket of the server's endpoint.
+func (ep *endpoint) socket() string {
+	ep.RLock()
+	defer ep.RUnlock()
+	return ep.sock
+}
+
+// Filter returns a handler that admits the requests of the libStorage
+// server's endpoints before they are passed to next.
+type Filter func(
+	ctx apitypes.Context, config gofig.Config, next http.Handler) http.Handler
+
+var filters []Filter
+
+// RegisterFilter registers a filter of the requests served by the front.
+// Requests pass through the filters in the order in which they were
+// registered.
+func RegisterFilter(f Filter) {
+	filters = append(filters, f)
+}
+
+type localKey struct{}
+
+// IsLocal returns a flag indicating whether the request was received on a
+// unix socket, which only the users of the host permitted to open the socket
+// may connect to.
+func IsLocal(req *http.Request) bool {
+	local, _ := req.Context().Value(localKey{}).(bool)
+	return local
+}
+
+// Prepare moves the configured endpoints of the libStorage server to unix
+// sockets in the provided directory and returns a front for their original
+// addresses. It must be called before the server is started. The endpoints
+// with TLS are served with their certificates by the front, which loads them
+// again when their files change, or with the certificate returned by
+// getCertificate if it is not nil, in which case every endpoint is served
+// with TLS.
+func Prepare(
+	ctx apitypes.Context,
+	config gofig.Config,
+	dir string,
+	getCertificate certs.GetCertificateFunc) (*Front, error) {
+
+	f := &Front{
+		ctx:     ctx,
+		config:  config,
+		dir:     dir,
+		limiter: New(config),
+		openapi: openapi.Enabled(config),
+	}
+
+	eps, _ := config.Get(endpointsKey).(map[string]interface{})
+	names := []string{}
+	for name := range eps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		key := endpointsKey + "." + name
+		ep := &endpoint{
+			name:    name,
+			address: config.GetString(key + ".address"),
+			sock:    filepath.Join(dir, "libstorage-"+name+".sock"),
+		}
+		if ep.address == "" {
+			continue
+		}
+		proto, _, err := gotil.ParseAddress(ep.address)
+		if err != nil {
+			return nil, err
+		}
+		ep.proto = proto
+		tlsConfig, err := endpointTLS(ctx, config, name, getCertificate)
+		if err != nil {
+			return nil, err
+		}
+		if tlsConfig != nil {
+			ep.tls = tlsConfig
+			config.Set(key+".tls", false)
+		}
+		os.Remove(ep.sock)
+		config.Set(key+".address", "unix://"+ep.sock)
+		ep.handler = f.handler(ep)
+		f.endpoints = append(f.endpoints, ep)
+		ctx.WithFields(map[string]interface{}{
+			"endpoint": name,
+			"address":  ep.address,
+			"sock":     ep.sock,
+			"tls":      ep.tls != nil,
+		}).Debug("moved libStorage endpoint behind front")
+	}
+	return f, nil
+}
+
+// endpointTLS returns the TLS configuration with which the front serves an
+// endpoint, or nil if the endpoint is served without TLS. The endpoint's TLS
+// properties, ex. libstorage.server.endpoints.public.tls.certFile, take
+// precedence over the server's, ex. libstorage.tls.certFile.
+func endpointTLS(
+	ctx apitypes.Context,
+	config gofig.Config,
+	name string,
+	getCertificate certs.GetCertificateFunc) (*tls.Config, error) {
+
+	key := endpointsKey + "." + name + ".tls"
+	if v := config.GetString(key); strings.EqualFold(v, "false") {
+		return nil, nil
+	}
+	get := func(k string) string {
+		if v := config.GetString(key + "." + k); v != "" {
+			return v
+		}
+		return config.GetString("libstorage.tls." + k)
+	}
+
+	certFile, keyFile := get("certFile"), get("keyFile")
+	if getCertificate != nil {
+		certFile, keyFile = "", ""
+	} else if certFile == "" {
+		return nil, nil
+	}
+
+	r, err := certs.NewReloader(
+		ctx, certFile, keyFile, get("trustedCertsFile"))
+	if err != nil {
+		return nil, goof.WithFieldE("endpoint", name,
+			"error loading endpoint certificate", err)
+	}
+	required := strings.EqualFold(get("clientCertRequired"), "true")
+	return r.ServerConfig(
+		getCertificate, required, certs.ClientURIs(config)), nil
+}
+
+// Host returns the address at which a client on this host reaches the
+// server through the front, or an empty string if the front serves no
+// endpoints. It is the address of the first endpoint with TLS, since a
+// client configured with TLS cannot connect to an endpoint without it, or
+// else the address of the first endpoint.
+func (f *Front) Host() string {
+	for _, ep := range f.endpoints {
+		if ep.tls != nil {
+			return ep.address
+		}
+	}
+	if len(f.endpoints) > 0 {
+		return f.endpoints[0].address
+	}
+	return ""
+}
+
+// Rebind moves the endpoints in the provided configuration, a copy of the
+// configuration with which the running libStorage server was started, to
+// new unix sockets. The returned function directs the front's requests to
+// the new sockets, and must be invoked once another server is listening on
+// them. Requests that were already proxied to the running server are not
+// affected.
+func (f *Front) Rebind(config gofig.Config) func() {
+	f.gen++
+	socks := make([]string, len(f.endpoints))
+	for i, ep := range f.endpoints {
+		socks[i] = filepath.Join(
+			f.dir, fmt.Sprintf("libstorage-%s-%d.sock", ep.name, f.gen))
+		os.Remove(socks[i])
+		config.Set(endpointsKey+"."+ep.name+".address", "unix://"+socks[i])
+	}
+	return func() {
+		for i, ep := range f.endpoints {
+			ep.Lock()
+			old := ep.sock
+			ep.sock = socks[i]
+			ep.Unlock()
+			f.ctx.WithFields(map[string]interface{}{
+				"endpoint": ep.name,
+				"sock":     socks[i],
+				"oldSock":  old,
+			}).Debug("directed libStorage endpoint to new server")
+		}
+	}
+}
+
+// Serve listens on the original addresses of the endpoints. The libStorage
+// server must have been started.
+func (f *Front) Serve(ctx apitypes.Context) error {
+	for _, ep := range f.endpoints {
+		proto, laddr, err := gotil.ParseAddress(ep.address)
+		if err != nil {
+			f.Close()
+			return err
+		}
+		l, _, err := systemd.Listen(proto, laddr)
+		if err != nil {
+			f.Close()
+			return goof.WithFieldE(
+				"address", ep.address, "error listening", err)
+		}
+		f.listeners = append(f.listeners, l)
+		if ep.tls != nil {
+			l = tls.NewListener(l, ep.tls)
+		}
+
+		go func(ep *endpoint, l net.Listener) {
+			if err := http.Serve(l, ep.handler); err != nil {
+				ctx.WithError(err).WithField("endpoint", ep.name).Debug(
+					"libStorage endpoint behind front stopped")
+			}
+		}(ep, l)
+
+		ctx.WithFields(map[string]interface{}{
+			"endpoint": ep.name,
+			"address":  ep.address,
+			"tls":      ep.tls != nil,
+		}).Info("serving libStorage endpoint behind front")
+	}
+	return nil
+}
+
+// handler returns the handler of an endpoint's requests, which serves the
+// API's document itself, if it is enabled, and proxies the other requests
+// that are within the limits and admitted by the filters to the endpoint.
+// Each request is recorded as a span, as is its request to the endpoint, if
+// tracing is enabled.
+func (f *Front) handler(ep *endpoint) http.Handler {
+	h := proxy(ep)
+	for i := len(filters) - 1; i >= 0; i-- {
+		h = filters[i](f.ctx, f.config, h)
+	}
+	h = f.limiter.Handler(h)
+	if f.openapi {
+		mux := http.NewServeMux()
+		mux.Handle(openapi.Path, openapi.Handler(
+			openapi.ServerURL(ep.address, ep.tls != nil)))
+		mux.Handle("/", h)
+		h = mux
+	}
+	h = tracing.Handler(h, "libstorage")
+	if ep.proto != "unix" {
+		return h
+	}
+	next := h
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		next.ServeHTTP(w, req.WithContext(
+			context.WithValue(req.Context(), localKey{}, true)))
+	})
+}
+
+// Close stops listening on the endpoints' addresses.
+func (f *Front) Close() {
+	for _, l := range f.listeners {
+		l.Close()
+	}
+	f.listeners = nil
+}
+
+// proxy returns a reverse proxy to the libStorage server's endpoint at the
+// endpoint's current unix socket.
+func proxy(ep *endpoint) http.Handler {
+	return &httputil.ReverseProxy{
+		Director: func(req *http.Request) {
+			req.URL.Scheme = "http"
+			req.URL.Host = "libstorage"
+		},
+		Transport: tracing.Transport(&http.Transport{
+			Dial: func(network, addr string) (net.Conn, error) {
+				return net.Dial("unix", ep.socket())
+			},
+		}),
+	}
+}
@@ -0,0 +1,235 @@
+// Package ratelimit protects the libStorage server, and through it the
+// storage platforms, from clients that send more requests than the platforms
+// can handle. Requests are limited globally and per client with token
+// buckets, and the number of concurrent mutations, such as creating,
+// attaching, or removing volumes, may be capped. A request over a limit is
+// refused with 429 Too Many Requests and a Retry-After header.
+package ratelimit
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/akutz/gofig"
+)
+
+// idleClientTTL is how long a client's bucket is kept after its last
+// request.
+const idleClientTTL = 10 * time.Minute
+
+func init() {
+	r := gofig.NewRegistration("Rate Limits")
+	r.Key(gofig.Int, "", 0,
+		"The requests per second the libStorage server accepts from all "+
+			"clients; 0 is unlimited",
+		"rexray.ratelimit.global.rate")
+	r.Key(gofig.Int, "", 0,
+		"The requests the libStorage server accepts from all clients in a "+
+			"burst; defaults to the rate",
+		"rexray.ratelimit.global.burst")
+	r.Key(gofig.Int, "", 0,
+		"The requests per second the libStorage server accepts from each "+
+			"client; 0 is unlimited",
+		"rexray.ratelimit.client.rate")
+	r.Key(gofig.Int, "", 0,
+		"The requests the libStorage server accepts from each client in a "+
+			"burst; defaults to the rate",
+		"rexray.ratelimit.client.burst")
+	r.Key(gofig.Int, "", 0,
+		"The mutating requests the libStorage server handles at once; "+
+			"0 is unlimited",
+		"rexray.ratelimit.maxConcurrentMutations")
+	gofig.Register(r)
+}
+
+// Enabled returns a flag indicating whether any limit is configured.
+func Enabled(config gofig.Config) bool {
+	return config.GetInt("rexray.ratelimit.global.rate") > 0 ||
+		config.GetInt("rexray.ratelimit.client.rate") > 0 ||
+		config.GetInt("rexray.ratelimit.maxConcurrentMutations") > 0
+}
+
+// bucket is a token bucket that holds up to burst tokens and gains rate
+// tokens per second.
+type bucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newBucket(rate, burst int, now time.Time) *bucket {
+	if burst <= 0 {
+		burst = rate
+	}
+	return &bucket{
+		rate:   float64(rate),
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   now,
+	}
+}
+
+// take takes a token if one is available. Otherwise it returns how long to
+// wait until one is.
+func (b *bucket) take(now time.Time) (bool, time.Duration) {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(b.burst, b.tokens+elapsed*b.rate)
+	}
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+// Limiter enforces the configured limits.
+type Limiter struct {
+	lock sync.Mutex
+
+	global      *bucket
+	clientRate  int
+	clientBurst int
+	clients     map[string]*bucket
+	lastPrune   time.Time
+
+	maxMutations int
+	mutations    int
+
+	now func() time.Time
+}
+
+// New returns a limiter for the configured limits.
+func New(config gofig.Config) *Limiter {
+	l := &Limiter{
+		clientRate:   config.GetInt("rexray.ratelimit.client.rate"),
+		clientBurst:  config.GetInt("rexray.ratelimit.client.burst"),
+		clients:      map[string]*bucket{},
+		maxMutations: config.GetInt("rexray.ratelimit.maxConcurrentMutations"),
+		now:          time.Now,
+	}
+	if rate := config.GetInt("rexray.ratelimit.global.rate"); rate > 0 {
+		l.global = newBucket(
+			rate, config.GetInt("rexray.ratelimit.global.burst"), l.now())
+	}
+	return l
+}
+
+// allow returns a flag indicating whether a request from the provided
+// client is within the rate limits, and otherwise how long the client
+// should wait before retrying.
+func (l *Limiter) allow(client string) (bool, time.Duration) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := l.now()
+	var cb *bucket
+	if l.clientRate > 0 {
+		l.prune(now)
+		var ok bool
+		if cb, ok = l.clients[client]; !ok {
+			cb = newBucket(l.clientRate, l.clientBurst, now)
+			l.clients[client] = cb
+		}
+		if ok, wait := cb.take(now); !ok {
+			return false, wait
+		}
+	}
+	if l.global != nil {
+		if ok, wait := l.global.take(now); !ok {
+			// the request is refused, so the client keeps its token
+			if cb != nil {
+				cb.tokens++
+			}
+			return false, wait
+		}
+	}
+	return true, 0
+}
+
+// prune removes the buckets of the clients that have been idle long enough
+// for their buckets to be full.
+func (l *Limiter) prune(now time.Time) {
+	if now.Sub(l.lastPrune) < idleClientTTL {
+		return
+	}
+	l.lastPrune = now
+	for k, b := range l.clients {
+		if now.Sub(b.last) > idleClientTTL {
+			delete(l.clients, k)
+		}
+	}
+}
+
+// acquire reserves a slot for a mutation. It returns false if the maximum
+// number of mutations is already in progress.
+func (l *Limiter) acquire() bool {
+	if l.maxMutations <= 0 {
+		return true
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.mutations >= l.maxMutations {
+		return false
+	}
+	l.mutations++
+	return true
+}
+
+func (l *Limiter) release() {
+	if l.maxMutations <= 0 {
+		return
+	}
+	l.lock.Lock()
+	l.mutations--
+	l.lock.Unlock()
+}
+
+// Handler returns an HTTP handler that delegates to h the requests that are
+// within the limits. A client is identified by its remote IP address.
+func (l *Limiter) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if ok, wait := l.allow(clientOf(req)); !ok {
+			tooManyRequests(w, wait, "rate limit exceeded")
+			return
+		}
+		if isMutation(req) {
+			if !l.acquire() {
+				tooManyRequests(w, time.Second, "too many concurrent mutations")
+				return
+			}
+			defer l.release()
+		}
+		h.ServeHTTP(w, req)
+	})
+}
+
+func tooManyRequests(w http.ResponseWriter, wait time.Duration, msg string) {
+	secs := int(math.Ceil(wait.Seconds()))
+	if secs < 1 {
+		secs = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(secs))
+	http.Error(w, msg, http.StatusTooManyRequests)
+}
+
+func isMutation(req *http.Request) bool {
+	switch req.Method {
+	case "GET", "HEAD", "OPTIONS":
+		return false
+	}
+	return true
+}
+
+func clientOf(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,81 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestBucket(t *testing.T) {
+	now := time.Unix(0, 0)
+	b := newBucket(2, 0, now)
+	for i := 0; i < 2; i++ {
+		if ok, _ := b.take(now); !ok {
+			t.Fatalf("take %d refused", i)
+		}
+	}
+	ok, wait := b.take(now)
+	if ok || wait != 500*time.Millisecond {
+		t.Fatalf("ok=%v wait=%v", ok, wait)
+	}
+	if ok, _ := b.take(now.Add(500 * time.Millisecond)); !ok {
+		t.Fatal("take refused after refill")
+	}
+}
+
+func TestHandler(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := &Limiter{
+		clientRate:   1,
+		clients:      map[string]*bucket{},
+		maxMutations: 1,
+		now:          func() time.Time { return now },
+	}
+
+	release := make(chan struct{})
+	h := l.Handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method == "POST" {
+			<-release
+		}
+	}))
+	serve := func(method, client string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, "/volumes", nil)
+		req.RemoteAddr = client + ":1234"
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := serve("GET", "10.0.0.1"); w.Code != http.StatusOK {
+		t.Fatalf("code=%d", w.Code)
+	}
+	w := serve("GET", "10.0.0.1")
+	if w.Code != http.StatusTooManyRequests ||
+		w.Header().Get("Retry-After") != "1" {
+		t.Fatalf("code=%d retryAfter=%s", w.Code, w.Header().Get("Retry-After"))
+	}
+
+	done := make(chan struct{})
+	go func() {
+		serve("POST", "10.0.0.2")
+		close(done)
+	}()
+	for {
+		l.lock.Lock()
+		n := l.mutations
+		l.lock.Unlock()
+		if n == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if w := serve("POST", "10.0.0.3"); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("code=%d", w.Code)
+	}
+	close(release)
+	<-done
+	if l.mutations != 0 {
+		t.Fatalf("mutations=%d", l.mutations)
+	}
+}
@@ -0,0 +1,42 @@
+package revert
+
+import (
+	"os/exec"
+	"strings"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	apitypes "github.com/emccode/libstorage/api/types"
+)
+
+func init() {
+	Register("rbd", revertRBD)
+}
+
+// revertRBD rolls an RBD image back with rbd snap rollback. The IDs of the
+// RBD driver's volumes are the image's pool and name joined by a period.
+func revertRBD(
+	ctx apitypes.Context,
+	config gofig.Config,
+	vol *apitypes.Volume,
+	snap *apitypes.Snapshot) error {
+
+	out, err := exec.Command("rbd", "snap", "rollback",
+		rbdImageSpec(vol.ID, snapshotName(snap))).CombinedOutput()
+	if err != nil {
+		return goof.WithFieldE(
+			"output", strings.TrimSpace(string(out)),
+			"error rolling back rbd image", err)
+	}
+	return nil
+}
+
+// rbdImageSpec returns the pool/image@snap spec of a snapshot of the image
+// with the provided volume ID.
+func rbdImageSpec(volumeID, snapName string) string {
+	image := volumeID
+	if i := strings.Index(volumeID, "."); i > 0 {
+		image = volumeID[:i] + "/" + volumeID[i+1:]
+	}
+	return image + "@" + snapName
+}
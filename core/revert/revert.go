@@ -0,0 +1,133 @@
+// Package revert rolls a volume back to one of its snapshots in place, so
+// that the volume keeps its ID, name, and attachments instead of being
+// replaced by a new volume created from the snapshot. Only storage platforms
+// that are able to roll back a volume natively are supported.
+package revert
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	apitypes "github.com/emccode/libstorage/api/types"
+)
+
+// SnapshotReverter is implemented by storage drivers that are able to revert
+// a volume to one of its snapshots natively.
+type SnapshotReverter interface {
+
+	// SnapshotRevert reverts a volume to the provided snapshot.
+	SnapshotRevert(
+		ctx apitypes.Context,
+		volumeID, snapshotID string,
+		opts apitypes.Store) (*apitypes.Volume, error)
+}
+
+// Func reverts a volume to one of its snapshots with a storage platform's
+// native tools or API.
+type Func func(
+	ctx apitypes.Context,
+	config gofig.Config,
+	vol *apitypes.Volume,
+	snap *apitypes.Snapshot) error
+
+var (
+	funcs    = map[string]Func{}
+	funcsRwl sync.RWMutex
+)
+
+// Register registers the function that reverts the volumes of the storage
+// driver with the provided name.
+func Register(driverName string, f Func) {
+	funcsRwl.Lock()
+	defer funcsRwl.Unlock()
+	funcs[strings.ToLower(driverName)] = f
+}
+
+func lookup(driverName string) (Func, bool) {
+	funcsRwl.RLock()
+	defer funcsRwl.RUnlock()
+	f, ok := funcs[strings.ToLower(driverName)]
+	return f, ok
+}
+
+// IsSupported returns a flag indicating whether or not the volumes of the
+// storage driver with the provided name may be reverted.
+func IsSupported(client apitypes.Client, driverName string) bool {
+	if _, ok := client.Storage().(SnapshotReverter); ok {
+		return true
+	}
+	_, ok := lookup(driverName)
+	return ok
+}
+
+// Revert reverts a volume to one of its snapshots. If volumeID is empty the
+// volume from which the snapshot was taken is reverted. The volume must be
+// the one from which the snapshot was taken and must not be attached, since
+// the data of a volume in use would change beneath its file system.
+func Revert(
+	ctx apitypes.Context,
+	config gofig.Config,
+	client apitypes.Client,
+	driverName, snapshotID, volumeID string) (*apitypes.Volume, error) {
+
+	snap, err := client.Storage().SnapshotInspect(ctx, snapshotID, nil)
+	if err != nil {
+		return nil, err
+	}
+	if volumeID == "" {
+		volumeID = snap.VolumeID
+	}
+
+	fields := goof.Fields{
+		"driver":     driverName,
+		"snapshotID": snap.ID,
+		"volumeID":   volumeID,
+	}
+
+	if !strings.EqualFold(snap.VolumeID, volumeID) {
+		fields["snapshotVolumeID"] = snap.VolumeID
+		return nil, goof.WithFields(
+			fields, "snapshot was not taken of the volume")
+	}
+
+	vol, err := client.Storage().VolumeInspect(
+		ctx, volumeID, &apitypes.VolumeInspectOpts{Attachments: true})
+	if err != nil {
+		return nil, err
+	}
+	if len(vol.Attachments) > 0 {
+		return nil, goof.WithFields(
+			fields, "volume must be detached before it can be reverted")
+	}
+
+	if r, ok := client.Storage().(SnapshotReverter); ok {
+		ctx.WithFields(fields).Info("reverting volume natively")
+		return r.SnapshotRevert(ctx, vol.ID, snap.ID, nil)
+	}
+
+	f, ok := lookup(driverName)
+	if !ok {
+		return nil, goof.WithFields(
+			fields, "driver does not support reverting volumes in place")
+	}
+
+	ctx.WithFields(fields).Info("reverting volume")
+	if err := f(ctx, config, vol, snap); err != nil {
+		return nil, goof.WithFieldsE(fields, "error reverting volume", err)
+	}
+
+	return client.Storage().VolumeInspect(
+		ctx, vol.ID, &apitypes.VolumeInspectOpts{Attachments: true})
+}
+
+// snapshotName returns the name of a snapshot on platforms whose snapshot
+// IDs are the name of the volume and the snapshot joined by an @, ex.
+// pool/image@snap.
+func snapshotName(snap *apitypes.Snapshot) string {
+	if i := strings.LastIndex(snap.ID, "@"); i >= 0 {
+		return snap.ID[i+1:]
+	}
+	return snap.Name
+}
@@ -0,0 +1,18 @@
+package revert
+
+import (
+	"testing"
+
+	apitypes "github.com/emccode/libstorage/api/types"
+)
+
+func TestRBDImageSpec(t *testing.T) {
+	snap := &apitypes.Snapshot{ID: "rbd.data@nightly", Name: "other"}
+	if s := rbdImageSpec("rbd.data", snapshotName(snap)); s != "rbd/data@nightly" {
+		t.Fatalf("spec=%s", s)
+	}
+	snap = &apitypes.Snapshot{ID: "snap-1", Name: "nightly"}
+	if s := rbdImageSpec("data", snapshotName(snap)); s != "data@nightly" {
+		t.Fatalf("spec=%s", s)
+	}
+}
@@ -0,0 +1,97 @@
+package revert
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	apitypes "github.com/emccode/libstorage/api/types"
+)
+
+func init() {
+	Register("scaleio", revertScaleIO)
+}
+
+// revertScaleIO overwrites the content of a ScaleIO volume with that of one
+// of its snapshots, which are themselves volumes, through the REST gateway
+// at scaleio.endpoint, ex. https://gateway/api.
+func revertScaleIO(
+	ctx apitypes.Context,
+	config gofig.Config,
+	vol *apitypes.Volume,
+	snap *apitypes.Snapshot) error {
+
+	endpoint := strings.TrimSuffix(config.GetString("scaleio.endpoint"), "/")
+	if endpoint == "" {
+		return goof.New("reverting scaleio volumes requires scaleio.endpoint")
+	}
+
+	client := &http.Client{Timeout: 5 * time.Minute}
+	if config.GetBool("scaleio.insecure") {
+		client.Transport = &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		}
+	}
+
+	// the gateway exchanges the user's credentials for a token that is used
+	// as the password of subsequent requests
+	req, err := http.NewRequest("GET", endpoint+"/login", nil)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(
+		config.GetString("scaleio.userName"),
+		config.GetString("scaleio.password"))
+	buf, err := scaleIODo(client, req)
+	if err != nil {
+		return err
+	}
+	var token string
+	if err := json.Unmarshal(buf, &token); err != nil {
+		return goof.WithFieldE(
+			"path", "/login", "error reading scaleio token", err)
+	}
+
+	body, err := json.Marshal(map[string]string{"srcVolumeId": snap.ID})
+	if err != nil {
+		return err
+	}
+	req, err = http.NewRequest("POST", fmt.Sprintf(
+		"%s/instances/Volume::%s/action/overwriteVolumeContent",
+		endpoint, vol.ID), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBasicAuth(config.GetString("scaleio.userName"), token)
+	_, err = scaleIODo(client, req)
+	return err
+}
+
+func scaleIODo(client *http.Client, req *http.Request) ([]byte, error) {
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, goof.WithFieldE(
+			"path", req.URL.Path, "error calling scaleio", err)
+	}
+	defer res.Body.Close()
+	buf, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return nil, goof.WithFields(map[string]interface{}{
+			"path":   req.URL.Path,
+			"status": res.Status,
+			"body":   strings.TrimSpace(string(buf)),
+		}, "error calling scaleio")
+	}
+	return buf, nil
+}
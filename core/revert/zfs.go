@@ -0,0 +1,34 @@
+package revert
+
+import (
+	"os/exec"
+	"strings"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	apitypes "github.com/emccode/libstorage/api/types"
+)
+
+func init() {
+	Register("zfs", revertZFS)
+}
+
+// revertZFS rolls a dataset back with zfs rollback. The IDs of the ZFS
+// driver's volumes are the names of their datasets. A dataset may only be
+// rolled back to its most recent snapshot, since rolling back further would
+// destroy the snapshots taken since.
+func revertZFS(
+	ctx apitypes.Context,
+	config gofig.Config,
+	vol *apitypes.Volume,
+	snap *apitypes.Snapshot) error {
+
+	out, err := exec.Command(
+		"zfs", "rollback", vol.ID+"@"+snapshotName(snap)).CombinedOutput()
+	if err != nil {
+		return goof.WithFieldE(
+			"output", strings.TrimSpace(string(out)),
+			"error rolling back zfs dataset", err)
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+package schedule
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/akutz/goof"
+)
+
+// Schedule returns the times at which a job runs.
+type Schedule interface {
+
+	// Next returns the first time after t at which the job runs. The zero
+	// time is returned if the job never runs again.
+	Next(t time.Time) time.Time
+}
+
+// every is a schedule that runs at a fixed interval.
+type every time.Duration
+
+func (e every) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(e))
+}
+
+// Every returns a schedule that runs at a fixed interval.
+func Every(d time.Duration) Schedule {
+	return every(d)
+}
+
+// cron is a schedule parsed from a five field cron expression. Each field is
+// a bit set of the values it matches.
+type cron struct {
+	minute, hour, dom, month, dow uint64
+
+	// anyDOM and anyDOW record whether the day of month and day of week fields
+	// are wildcards; if both are restricted a day matches either.
+	anyDOM, anyDOW bool
+}
+
+type bounds struct {
+	name     string
+	min, max uint
+}
+
+var (
+	minuteBounds = bounds{"minute", 0, 59}
+	hourBounds   = bounds{"hour", 0, 23}
+	domBounds    = bounds{"day of month", 1, 31}
+	monthBounds  = bounds{"month", 1, 12}
+	dowBounds    = bounds{"day of week", 0, 7}
+)
+
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Parse parses a schedule. The schedule is either a standard five field cron
+// expression, ex. "30 2 * * 1-5", one of the descriptors @yearly, @monthly,
+// @weekly, @daily, and @hourly, or "@every" followed by a duration, ex.
+// "@every 15m".
+func Parse(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+
+	if strings.HasPrefix(spec, "@every ") {
+		d, err := time.ParseDuration(strings.TrimSpace(spec[len("@every "):]))
+		if err != nil || d <= 0 {
+			return nil, goof.WithField("schedule", spec, "invalid interval")
+		}
+		return Every(d), nil
+	}
+	if expr, ok := descriptors[spec]; ok {
+		spec = expr
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, goof.WithField(
+			"schedule", spec, "cron expression must have five fields")
+	}
+
+	c := &cron{
+		anyDOM: fields[2] == "*" || fields[2] == "?",
+		anyDOW: fields[4] == "*" || fields[4] == "?",
+	}
+
+	var err error
+	for i, f := range []struct {
+		bits *uint64
+		b    bounds
+	}{
+		{&c.minute, minuteBounds},
+		{&c.hour, hourBounds},
+		{&c.dom, domBounds},
+		{&c.month, monthBounds},
+		{&c.dow, dowBounds},
+	} {
+		if *f.bits, err = parseField(fields[i], f.b); err != nil {
+			return nil, goof.WithFieldsE(map[string]interface{}{
+				"schedule": spec,
+				"field":    f.b.name,
+			}, "invalid cron field", err)
+		}
+	}
+
+	// sunday is both 0 and 7
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+
+	return c, nil
+}
+
+// parseField parses a comma-separated list of values, ranges, and steps,
+// ex. "1,15-20,*/5", into a bit set.
+func parseField(field string, b bounds) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		step := uint(1)
+		if i := strings.Index(part, "/"); i >= 0 {
+			s, err := strconv.ParseUint(part[i+1:], 10, 8)
+			if err != nil || s == 0 {
+				return 0, goof.WithField("step", part[i+1:], "invalid step")
+			}
+			step = uint(s)
+			part = part[:i]
+		}
+
+		lo, hi := b.min, b.max
+		switch {
+		case part == "*" || part == "?":
+		case strings.Contains(part, "-"):
+			r := strings.SplitN(part, "-", 2)
+			var err error
+			if lo, err = parseValue(r[0], b); err != nil {
+				return 0, err
+			}
+			if hi, err = parseValue(r[1], b); err != nil {
+				return 0, err
+			}
+			if lo > hi {
+				return 0, goof.WithField("range", part, "invalid range")
+			}
+		default:
+			v, err := parseValue(part, b)
+			if err != nil {
+				return 0, err
+			}
+			lo = v
+			if step == 1 {
+				hi = v
+			}
+		}
+
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+func parseValue(s string, b bounds) (uint, error) {
+	v, err := strconv.ParseUint(s, 10, 8)
+	if err != nil || uint(v) < b.min || uint(v) > b.max {
+		return 0, goof.WithField("value", s, "value out of range")
+	}
+	return uint(v), nil
+}
+
+func (c *cron) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if c.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.dayMatches(t) {
+			t = time.Date(
+				t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if c.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(),
+				t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if c.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func (c *cron) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	switch {
+	case c.anyDOM && c.anyDOW:
+		return true
+	case c.anyDOM:
+		return dow
+	case c.anyDOW:
+		return dom
+	default:
+		return dom || dow
+	}
+}
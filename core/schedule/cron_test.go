@@ -0,0 +1,55 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseNext(t *testing.T) {
+	// a wednesday
+	from := time.Date(2016, 6, 1, 10, 30, 15, 0, time.UTC)
+
+	tests := []struct {
+		spec     string
+		expected time.Time
+	}{
+		{"* * * * *", time.Date(2016, 6, 1, 10, 31, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2016, 6, 1, 10, 45, 0, 0, time.UTC)},
+		{"0 2 * * *", time.Date(2016, 6, 2, 2, 0, 0, 0, time.UTC)},
+		{"30 2 * * 1-5", time.Date(2016, 6, 2, 2, 30, 0, 0, time.UTC)},
+		{"0 0 * * 0", time.Date(2016, 6, 5, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2016, 6, 5, 0, 0, 0, 0, time.UTC)},
+		{"0 0 31 * *", time.Date(2016, 7, 31, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2020, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2016, 7, 1, 0, 0, 0, 0, time.UTC)},
+		{"@every 90s", from.Add(90 * time.Second)},
+	}
+
+	for _, tt := range tests {
+		s, err := Parse(tt.spec)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.spec, err)
+		}
+		if actual := s.Next(from); !actual.Equal(tt.expected) {
+			t.Errorf("%s: next=%v; expected %v", tt.spec, actual, tt.expected)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"@every",
+		"@every -1m",
+	} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("%q: expected error", spec)
+		}
+	}
+}
@@ -0,0 +1,302 @@
+// Package schedule runs REX-Ray's periodic and time-based work, such as
+// heartbeats, credential refreshes, and scheduled snapshots, from a shared
+// scheduler so that each feature does not manage its own tickers. The
+// scheduler parses cron expressions, applies jitter, and persists the next run
+// time of each job so that a run missed while REX-Ray was stopped happens when
+// it starts again.
+package schedule
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/akutz/goof"
+	apitypes "github.com/emccode/libstorage/api/types"
+)
+
+const jobsBucket = "scheduledJobs"
+
+// Clock is the source of time for a scheduler. It is an interface so that
+// tests may control the passage of time.
+type Clock interface {
+
+	// Now returns the current time.
+	Now() time.Time
+
+	// After returns a channel on which the current time is sent after the
+	// duration elapses.
+	After(d time.Duration) <-chan time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// SystemClock is the clock that reports the system time.
+var SystemClock Clock = systemClock{}
+
+// Store persists the next run times of jobs. It is satisfied by the state
+// store, which is provided by the caller since the state store's packages
+// import this one.
+type Store interface {
+
+	// Get reads the value of a key in a bucket into v and returns a flag
+	// indicating whether or not the key exists.
+	Get(bucket, key string, v interface{}) (bool, error)
+
+	// Set stores the value of a key in a bucket.
+	Set(bucket, key string, v interface{}) error
+}
+
+// Job is a unit of work run by a scheduler.
+type Job struct {
+
+	// Name uniquely identifies the job.
+	Name string
+
+	// Schedule determines when the job runs.
+	Schedule Schedule
+
+	// Jitter is the maximum random delay added to each run so that jobs on
+	// many instances with the same schedule do not run in lockstep.
+	Jitter time.Duration
+
+	// Persist records the job's next run time in the scheduler's store. If
+	// the scheduler is restarted after a persisted run time has passed, the
+	// job runs immediately.
+	Persist bool
+
+	// RunOnStart runs the job as soon as it is added.
+	RunOnStart bool
+
+	// Run is the job's work. A job does not run again until Run returns.
+	Run func()
+}
+
+// Status describes a scheduled job.
+type Status struct {
+	Name    string    `json:"name" yaml:"name"`
+	NextRun time.Time `json:"nextRun" yaml:"nextRun"`
+	LastRun time.Time `json:"lastRun,omitempty" yaml:"lastRun,omitempty"`
+}
+
+type entry struct {
+	job     *Job
+	stop    chan bool
+	nextRun time.Time
+	lastRun time.Time
+}
+
+// Scheduler runs jobs according to their schedules.
+type Scheduler struct {
+	ctx   apitypes.Context
+	clock Clock
+
+	sync.RWMutex
+	store Store
+	jobs  map[string]*entry
+}
+
+// New returns a scheduler that uses the provided clock and persists next run
+// times in the provided store. Run times are not persisted if the store is
+// nil.
+func New(ctx apitypes.Context, clock Clock, store Store) *Scheduler {
+	return &Scheduler{
+		ctx:   ctx,
+		clock: clock,
+		store: store,
+		jobs:  map[string]*entry{},
+	}
+}
+
+var (
+	defaultScheduler   *Scheduler
+	defaultStore       Store
+	defaultSchedulerMu sync.Mutex
+)
+
+// SetStore sets the store in which the scheduler shared by this process
+// persists next run times. It is called when the configuration is loaded,
+// before jobs that are persisted are added.
+func SetStore(store Store) {
+	defaultSchedulerMu.Lock()
+	defer defaultSchedulerMu.Unlock()
+	defaultStore = store
+	if defaultScheduler != nil {
+		defaultScheduler.Lock()
+		defaultScheduler.store = store
+		defaultScheduler.Unlock()
+	}
+}
+
+// Default returns the scheduler shared by this process.
+func Default(ctx apitypes.Context) *Scheduler {
+	defaultSchedulerMu.Lock()
+	defer defaultSchedulerMu.Unlock()
+	if defaultScheduler == nil {
+		defaultScheduler = New(ctx, SystemClock, defaultStore)
+	}
+	return defaultScheduler
+}
+
+// Add schedules a job. Adding a job with the name of a scheduled job
+// replaces it.
+func (s *Scheduler) Add(job *Job) error {
+	if job.Name == "" {
+		return goof.New("missing job name")
+	}
+	if job.Schedule == nil || job.Run == nil {
+		return goof.WithField("job", job.Name, "missing schedule or run")
+	}
+
+	s.Remove(job.Name)
+
+	e := &entry{job: job, stop: make(chan bool)}
+
+	now := s.clock.Now()
+	switch {
+	case job.RunOnStart:
+		e.nextRun = now
+	case job.Persist && s.getStore() != nil:
+		var next time.Time
+		ok, err := s.getStore().Get(jobsBucket, job.Name, &next)
+		if err != nil {
+			return err
+		}
+		if ok {
+			e.nextRun = next
+		}
+	}
+	if e.nextRun.IsZero() {
+		e.nextRun = s.next(job, now)
+		if e.nextRun.IsZero() {
+			return goof.WithField("job", job.Name, "schedule never runs")
+		}
+		if err := s.persist(e); err != nil {
+			return err
+		}
+	}
+
+	s.Lock()
+	s.jobs[job.Name] = e
+	s.Unlock()
+
+	go s.run(e)
+	return nil
+}
+
+// Remove stops and removes the job with the provided name.
+func (s *Scheduler) Remove(name string) {
+	s.Lock()
+	e, ok := s.jobs[name]
+	delete(s.jobs, name)
+	s.Unlock()
+	if ok {
+		close(e.stop)
+	}
+}
+
+// Stop stops and removes all of the scheduler's jobs.
+func (s *Scheduler) Stop() {
+	s.RLock()
+	names := []string{}
+	for name := range s.jobs {
+		names = append(names, name)
+	}
+	s.RUnlock()
+	for _, name := range names {
+		s.Remove(name)
+	}
+}
+
+// Jobs returns the status of the scheduled jobs sorted by name.
+func (s *Scheduler) Jobs() []*Status {
+	s.RLock()
+	defer s.RUnlock()
+	all := []*Status{}
+	for _, e := range s.jobs {
+		all = append(all, &Status{
+			Name:    e.job.Name,
+			NextRun: e.nextRun,
+			LastRun: e.lastRun,
+		})
+	}
+	sort.Sort(byName(all))
+	return all
+}
+
+type byName []*Status
+
+func (s byName) Len() int           { return len(s) }
+func (s byName) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byName) Less(i, j int) bool { return s[i].Name < s[j].Name }
+
+func (s *Scheduler) run(e *entry) {
+	for {
+		s.RLock()
+		next := e.nextRun
+		s.RUnlock()
+
+		select {
+		case <-e.stop:
+			return
+		case <-s.clock.After(next.Sub(s.clock.Now())):
+		}
+
+		e.job.Run()
+
+		select {
+		case <-e.stop:
+			return
+		default:
+		}
+
+		now := s.clock.Now()
+		s.Lock()
+		e.lastRun = now
+		e.nextRun = s.next(e.job, now)
+		done := e.nextRun.IsZero()
+		if done && s.jobs[e.job.Name] == e {
+			delete(s.jobs, e.job.Name)
+		}
+		s.Unlock()
+
+		if done {
+			return
+		}
+		if err := s.persist(e); err != nil {
+			s.ctx.WithField("job", e.job.Name).WithError(err).Error(
+				"error persisting next run time")
+		}
+	}
+}
+
+func (s *Scheduler) next(job *Job, now time.Time) time.Time {
+	next := job.Schedule.Next(now)
+	if next.IsZero() || job.Jitter <= 0 {
+		return next
+	}
+	return next.Add(time.Duration(rand.Int63n(int64(job.Jitter))))
+}
+
+func (s *Scheduler) getStore() Store {
+	s.RLock()
+	defer s.RUnlock()
+	return s.store
+}
+
+func (s *Scheduler) persist(e *entry) error {
+	store := s.getStore()
+	if !e.job.Persist || store == nil {
+		return nil
+	}
+	return store.Set(jobsBucket, e.job.Name, e.nextRun)
+}
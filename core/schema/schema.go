@@ -0,0 +1,235 @@
+// Package schema describes the configuration keys registered with gofig by
+// REX-Ray and libStorage, and validates configuration files against them.
+package schema
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/akutz/gofig"
+	flag "github.com/spf13/pflag"
+)
+
+// Key describes a registered configuration key.
+type Key struct {
+	Name        string `json:"name" yaml:"name"`
+	Group       string `json:"group" yaml:"group"`
+	Type        string `json:"type" yaml:"type"`
+	Default     string `json:"default,omitempty" yaml:"default,omitempty"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	Flag        string `json:"flag,omitempty" yaml:"flag,omitempty"`
+}
+
+// Schema is the set of registered configuration keys.
+type Schema struct {
+
+	// keys are the registered keys indexed by their lower-case names, which
+	// is how gofig compares them.
+	keys map[string]*Key
+}
+
+// Load returns the schema of the keys registered with the provided
+// configuration.
+//
+// gofig does not expose its registrations, so the schema is derived from the
+// flag sets it generates: each flag is matched to the configuration key it is
+// bound to, which gofig names by removing the key's dots and capitalizing the
+// first letter of each of its segments unless the registration supplies a
+// flag name.
+func Load(config gofig.Config) *Schema {
+	s := &Schema{keys: map[string]*Key{}}
+
+	compact := map[string]string{}
+	for _, k := range config.AllKeys() {
+		compact[strings.Replace(strings.ToLower(k), ".", "", -1)] = k
+	}
+
+	for group, fs := range config.FlagSets() {
+		fs.VisitAll(func(f *flag.Flag) {
+			name, ok := keyForFlag(compact, f.Name)
+			if !ok {
+				return
+			}
+			s.keys[strings.ToLower(name)] = &Key{
+				Name:        name,
+				Group:       group,
+				Type:        typeName(f.Value.Type()),
+				Default:     f.DefValue,
+				Description: f.Usage,
+				Flag:        f.Name,
+			}
+		})
+	}
+
+	for _, k := range serviceKeys {
+		if _, ok := s.keys[strings.ToLower(k.Name)]; !ok {
+			s.keys[strings.ToLower(k.Name)] = k
+		}
+	}
+
+	return s
+}
+
+// keyForFlag returns the name of the configuration key bound to a flag,
+// restoring the case of the key's segments from the flag's name.
+func keyForFlag(compact map[string]string, flagName string) (string, bool) {
+	lf := strings.ToLower(flagName)
+	if k, ok := compact[lf]; ok {
+		return restoreCase(k, flagName), true
+	}
+
+	// the registration supplied a flag name, which is usually the key's last
+	// segments, ex. logLevel for rexray.logLevel
+	var match string
+	for c, k := range compact {
+		if strings.HasSuffix(c, lf) {
+			if match != "" {
+				return "", false
+			}
+			match = k
+		}
+	}
+	if match == "" {
+		return "", false
+	}
+
+	segs := strings.Split(match, ".")
+	n := 0
+	for i := len(segs) - 1; i >= 0 && n < len(flagName); i-- {
+		n += len(segs[i])
+		if n == len(flagName) {
+			segs = append(segs[:i],
+				restoreCase(strings.Join(segs[i:], "."), flagName))
+			return strings.Join(segs, "."), true
+		}
+	}
+	return match, true
+}
+
+func restoreCase(key, flagName string) string {
+	segs := strings.Split(key, ".")
+	i := 0
+	for n, seg := range segs {
+		if i+len(seg) > len(flagName) {
+			return key
+		}
+		s := flagName[i : i+len(seg)]
+		if n > 0 {
+			s = strings.ToLower(s[:1]) + s[1:]
+		}
+		segs[n] = s
+		i += len(seg)
+	}
+	return strings.Join(segs, ".")
+}
+
+func typeName(flagType string) string {
+	switch flagType {
+	case "bool":
+		return "bool"
+	case "int", "int32", "int64":
+		return "int"
+	case "stringSlice":
+		return "stringSlice"
+	default:
+		return "string"
+	}
+}
+
+// Lookup returns the key with the provided name.
+func (s *Schema) Lookup(name string) (*Key, bool) {
+	k, ok := s.keys[strings.ToLower(name)]
+	return k, ok
+}
+
+// Keys returns the keys sorted by name.
+func (s *Schema) Keys() []*Key {
+	keys := []*Key{}
+	for _, k := range s.keys {
+		keys = append(keys, k)
+	}
+	sort.Sort(byName(keys))
+	return keys
+}
+
+type byName []*Key
+
+func (k byName) Len() int           { return len(k) }
+func (k byName) Swap(i, j int)      { k[i], k[j] = k[j], k[i] }
+func (k byName) Less(i, j int) bool { return k[i].Name < k[j].Name }
+
+// JSONSchema returns the schema as a JSON Schema document that editors may
+// use to complete and check configuration files.
+func (s *Schema) JSONSchema() map[string]interface{} {
+	root := object()
+	root["$schema"] = "http://json-schema.org/draft-07/schema#"
+	root["title"] = "REX-Ray configuration"
+
+	// the services are a map of names to service-scoped configurations
+	service := object()
+	insert(root, "libstorage.server.services", map[string]interface{}{
+		"type":                 "object",
+		"additionalProperties": service,
+	})
+
+	for _, k := range s.Keys() {
+		if strings.HasPrefix(k.Name, servicePrefix) {
+			name := strings.TrimPrefix(k.Name, servicePrefix)
+			insert(service, name, property(k))
+			continue
+		}
+		insert(root, k.Name, property(k))
+	}
+
+	return root
+}
+
+// insert adds a property to a JSON Schema object at the provided dotted path,
+// creating the intermediate objects.
+func insert(node map[string]interface{}, path string, p interface{}) {
+	segs := strings.Split(path, ".")
+	for _, seg := range segs[:len(segs)-1] {
+		props := node["properties"].(map[string]interface{})
+		child, ok := props[seg].(map[string]interface{})
+		if !ok {
+			child = object()
+			props[seg] = child
+		}
+		if _, ok := child["properties"]; !ok {
+			child["properties"] = map[string]interface{}{}
+		}
+		node = child
+	}
+	props := node["properties"].(map[string]interface{})
+	if _, exists := props[segs[len(segs)-1]]; !exists {
+		props[segs[len(segs)-1]] = p
+	}
+}
+
+func object() map[string]interface{} {
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": map[string]interface{}{},
+	}
+}
+
+func property(k *Key) map[string]interface{} {
+	p := map[string]interface{}{}
+	switch k.Type {
+	case "bool":
+		p["type"] = "boolean"
+	case "int":
+		p["type"] = "integer"
+	case "stringSlice":
+		p["type"] = []string{"array", "string"}
+	default:
+		p["type"] = []string{"string", "number", "boolean"}
+	}
+	if k.Description != "" {
+		p["description"] = k.Description
+	}
+	if k.Default != "" && k.Default != "[]" {
+		p["default"] = k.Default
+	}
+	return p
+}
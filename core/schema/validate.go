@@ -0,0 +1,324 @@
+package schema
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/akutz/goof"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// servicePrefix is the prefix of the keys that describe the configuration of
+// an individual libStorage service.
+const servicePrefix = "libstorage.server.services.*."
+
+// serviceKeys are the keys read from a service's configuration that are not
+// registered with gofig.
+var serviceKeys = []*Key{
+	{
+		Name:        servicePrefix + "driver",
+		Group:       "Services",
+		Type:        "string",
+		Description: "The storage driver used by the service",
+	},
+	{
+		Name:        servicePrefix + "ebs.roleARN",
+		Group:       "Services",
+		Type:        "string",
+		Description: "The ARN of the IAM role the service assumes",
+	},
+	{
+		Name:        servicePrefix + "ebs.externalID",
+		Group:       "Services",
+		Type:        "string",
+		Description: "The external ID presented when assuming the role",
+	},
+	{
+		Name:        servicePrefix + "ebs.roleSessionName",
+		Group:       "Services",
+		Type:        "string",
+		Description: "The session name used when assuming the role",
+	},
+	{
+		Name:        servicePrefix + "ebs.roleDuration",
+		Group:       "Services",
+		Type:        "string",
+		Description: "The lifetime of the assumed role's credentials",
+	},
+	{
+		Name:        servicePrefix + "debug.capture",
+		Group:       "Services",
+		Type:        "bool",
+		Description: "Capture the service's provider API requests",
+	},
+	{
+		Name:        servicePrefix + "debug.captureHosts",
+		Group:       "Services",
+		Type:        "stringSlice",
+		Description: "The provider API hosts whose requests are captured",
+	},
+}
+
+// dynamicPrefixes are the prefixes of keys whose next segment is a name
+// chosen by the user, such as the name of a module. The keys beneath them are
+// not reported as unknown.
+var dynamicPrefixes = []string{
+	"rexray.modules.",
+	"rexray.simulate.services.",
+	"libstorage.server.endpoints.",
+}
+
+// exclusion is a set of keys that may not be set with another set of keys in
+// the configuration of a service that uses one of the listed drivers.
+type exclusion struct {
+	drivers []string
+	a, b    []string
+	reason  string
+}
+
+var exclusions = []*exclusion{
+	{
+		drivers: []string{"ebs", "ec2"},
+		a:       []string{"ebs.roleARN"},
+		b:       []string{"ebs.accessKey", "ebs.secretKey"},
+		reason:  "assumed role credentials replace the access keys",
+	},
+	{
+		drivers: []string{"external"},
+		a:       []string{"external.path"},
+		b:       []string{"external.address"},
+		reason:  "a driver is either started or already running",
+	},
+}
+
+// Severity is the severity of a problem.
+type Severity string
+
+const (
+	// Error is a problem that prevents the configuration from working.
+	Error Severity = "error"
+
+	// Warning is a problem that may be intentional, such as a key that is
+	// read by a driver that did not register it.
+	Warning Severity = "warning"
+)
+
+// Problem is a problem found when validating a configuration.
+type Problem struct {
+	Severity Severity `json:"severity" yaml:"severity"`
+	Key      string   `json:"key,omitempty" yaml:"key,omitempty"`
+	Service  string   `json:"service,omitempty" yaml:"service,omitempty"`
+	Message  string   `json:"message" yaml:"message"`
+}
+
+// HasErrors returns a flag indicating whether any of the problems is an
+// error.
+func HasErrors(problems []*Problem) bool {
+	for _, p := range problems {
+		if p.Severity == Error {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateYAML validates a YAML configuration document.
+func (s *Schema) ValidateYAML(buf []byte) ([]*Problem, error) {
+	doc := map[interface{}]interface{}{}
+	if err := yaml.Unmarshal(buf, &doc); err != nil {
+		return nil, goof.WithFieldE("format", "yaml", "invalid config", err)
+	}
+	leaves := map[string]interface{}{}
+	flatten("", doc, leaves)
+	return s.Validate(leaves), nil
+}
+
+// Validate validates a configuration expressed as a map of dotted key names
+// to values.
+func (s *Schema) Validate(leaves map[string]interface{}) []*Problem {
+	problems := []*Problem{}
+	services := map[string]map[string]interface{}{}
+
+	for key, val := range leaves {
+		lk := strings.ToLower(key)
+
+		if strings.HasPrefix(lk, "libstorage.server.services.") {
+			rest := key[len("libstorage.server.services."):]
+			parts := strings.SplitN(rest, ".", 2)
+			if len(parts) != 2 {
+				continue
+			}
+			svc := services[parts[0]]
+			if svc == nil {
+				svc = map[string]interface{}{}
+				services[parts[0]] = svc
+			}
+			svc[strings.ToLower(parts[1])] = val
+			problems = append(problems,
+				s.validateKey(parts[0], parts[1], val)...)
+			continue
+		}
+
+		if isDynamic(lk) {
+			continue
+		}
+		problems = append(problems, s.validateKey("", key, val)...)
+	}
+
+	for name, svc := range services {
+		problems = append(problems, validateExclusions(name, svc)...)
+	}
+
+	sort.Sort(byKey(problems))
+	return problems
+}
+
+func isDynamic(key string) bool {
+	for _, p := range dynamicPrefixes {
+		if strings.HasPrefix(key, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Schema) validateKey(service, key string, val interface{}) []*Problem {
+	k, ok := s.Lookup(key)
+	if service != "" {
+		if sk, sok := s.Lookup(servicePrefix + key); sok {
+			k, ok = sk, sok
+		}
+	}
+	if !ok {
+		return []*Problem{{
+			Severity: Warning,
+			Key:      key,
+			Service:  service,
+			Message:  "unknown key",
+		}}
+	}
+	if msg := checkType(k.Type, val); msg != "" {
+		return []*Problem{{
+			Severity: Error,
+			Key:      key,
+			Service:  service,
+			Message:  msg,
+		}}
+	}
+	return nil
+}
+
+// checkType returns a message describing why a value is not of the provided
+// type, or an empty string if it is.
+func checkType(t string, val interface{}) string {
+	switch t {
+	case "bool":
+		switch v := val.(type) {
+		case bool:
+			return ""
+		case string:
+			if _, err := strconv.ParseBool(v); err == nil {
+				return ""
+			}
+		}
+		return fmt.Sprintf("expected a boolean, got %v", val)
+	case "int":
+		switch v := val.(type) {
+		case int, int64, uint64:
+			return ""
+		case string:
+			if _, err := strconv.ParseInt(v, 10, 64); err == nil {
+				return ""
+			}
+		}
+		return fmt.Sprintf("expected an integer, got %v", val)
+	case "stringSlice":
+		switch val.(type) {
+		case []interface{}, string:
+			return ""
+		}
+		return fmt.Sprintf("expected a list, got %v", val)
+	default:
+		switch val.(type) {
+		case map[interface{}]interface{}, []interface{}:
+			return fmt.Sprintf("expected a scalar, got %v", val)
+		}
+		return ""
+	}
+}
+
+func validateExclusions(
+	service string, svc map[string]interface{}) []*Problem {
+
+	driver, _ := svc["driver"].(string)
+	if driver == "" {
+		driver = service
+	}
+
+	problems := []*Problem{}
+	for _, e := range exclusions {
+		if !contains(e.drivers, strings.ToLower(driver)) {
+			continue
+		}
+		a, b := setKeys(svc, e.a), setKeys(svc, e.b)
+		if len(a) == 0 || len(b) == 0 {
+			continue
+		}
+		problems = append(problems, &Problem{
+			Severity: Error,
+			Key:      a[0],
+			Service:  service,
+			Message: fmt.Sprintf("%s may not be set with %s: %s",
+				strings.Join(a, ", "), strings.Join(b, ", "), e.reason),
+		})
+	}
+	return problems
+}
+
+func setKeys(svc map[string]interface{}, keys []string) []string {
+	set := []string{}
+	for _, k := range keys {
+		if v, ok := svc[strings.ToLower(k)]; ok && v != nil && v != "" {
+			set = append(set, k)
+		}
+	}
+	return set
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// flatten records the leaves of a YAML document by their dotted key names.
+func flatten(prefix string, v interface{}, leaves map[string]interface{}) {
+	m, ok := v.(map[interface{}]interface{})
+	if !ok {
+		leaves[prefix] = v
+		return
+	}
+	for k, child := range m {
+		key := fmt.Sprintf("%v", k)
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		flatten(key, child, leaves)
+	}
+}
+
+type byKey []*Problem
+
+func (p byKey) Len() int      { return len(p) }
+func (p byKey) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+func (p byKey) Less(i, j int) bool {
+	if p[i].Service != p[j].Service {
+		return p[i].Service < p[j].Service
+	}
+	return p[i].Key < p[j].Key
+}
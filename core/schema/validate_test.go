@@ -0,0 +1,81 @@
+package schema
+
+import (
+	"strings"
+	"testing"
+)
+
+func testSchema() *Schema {
+	s := &Schema{keys: map[string]*Key{}}
+	for _, k := range append([]*Key{
+		{Name: "rexray.logLevel", Type: "string"},
+		{Name: "rexray.tracing.enabled", Type: "bool"},
+		{Name: "rexray.debug.capture.size", Type: "int"},
+		{Name: "ebs.accessKey", Type: "string"},
+	}, serviceKeys...) {
+		s.keys[strings.ToLower(k.Name)] = k
+	}
+	return s
+}
+
+func TestValidateYAML(t *testing.T) {
+	problems, err := testSchema().ValidateYAML([]byte(`
+rexray:
+  logLevel: debug
+  tracing:
+    enabled: maybe
+  debug:
+    capture:
+      size: 10
+  unknownKey: true
+  modules:
+    default-docker:
+      type: docker
+libstorage:
+  server:
+    services:
+      ebs-prod:
+        driver: ebs
+        ebs:
+          accessKey: AKIA
+          roleARN: arn:aws:iam::123456789012:role/rexray
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]Severity{
+		"rexray.tracing.enabled": Error,
+		"rexray.unknownKey":      Warning,
+		"ebs.roleARN":            Error,
+	}
+	if len(problems) != len(expected) {
+		t.Fatalf("expected %d problems, got %d: %+v",
+			len(expected), len(problems), problems)
+	}
+	for _, p := range problems {
+		if sev, ok := expected[p.Key]; !ok || sev != p.Severity {
+			t.Errorf("unexpected problem %+v", p)
+		}
+	}
+	if !HasErrors(problems) {
+		t.Error("expected errors")
+	}
+}
+
+func TestKeyForFlag(t *testing.T) {
+	compact := map[string]string{
+		"rexraydebugcapturemaxbodysize": "rexray.debug.capture.maxbodysize",
+		"rexrayloglevel":                "rexray.loglevel",
+	}
+	tests := map[string]string{
+		"rexrayDebugCaptureMaxBodySize": "rexray.debug.capture.maxBodySize",
+		"logLevel":                      "rexray.logLevel",
+	}
+	for flagName, expected := range tests {
+		actual, ok := keyForFlag(compact, flagName)
+		if !ok || actual != expected {
+			t.Errorf("keyForFlag(%s)=%s; expected %s", flagName, actual, expected)
+		}
+	}
+}
@@ -0,0 +1,322 @@
+package script
+
+import (
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	"github.com/emccode/libstorage/api/context"
+	apitypes "github.com/emccode/libstorage/api/types"
+)
+
+// driver runs the executables of the storage operations on the libStorage
+// server.
+type driver struct {
+	runner      *runner
+	storageType apitypes.StorageType
+}
+
+func (d *driver) Name() string {
+	return Name
+}
+
+func (d *driver) Init(ctx apitypes.Context, config gofig.Config) error {
+	r, err := newRunner(config)
+	if err != nil {
+		return err
+	}
+	d.runner = r
+
+	switch t := apitypes.StorageType(config.GetString("script.type")); t {
+	case apitypes.Block, apitypes.NAS, apitypes.Object:
+		d.storageType = t
+	case "":
+		d.storageType = apitypes.Block
+	default:
+		return goof.WithField("type", t, "invalid script type")
+	}
+
+	ctx.WithField("dir", r.dir).Info("initialized script driver")
+	return nil
+}
+
+func (d *driver) Type(ctx apitypes.Context) (apitypes.StorageType, error) {
+	return d.storageType, nil
+}
+
+// NextDeviceInfo returns nil because the devices of attached volumes are
+// discovered by the executor's localDevices operation.
+func (d *driver) NextDeviceInfo(
+	ctx apitypes.Context) (*apitypes.NextDeviceInfo, error) {
+
+	return nil, nil
+}
+
+func (d *driver) InstanceInspect(
+	ctx apitypes.Context,
+	opts apitypes.Store) (*apitypes.Instance, error) {
+
+	iid, ok := ctx.Value(context.InstanceIDKey).(*apitypes.InstanceID)
+	if !ok {
+		return nil, goof.New("missing instance ID")
+	}
+	return &apitypes.Instance{InstanceID: iid, Name: iid.ID}, nil
+}
+
+// instanceID returns the ID of the instance on whose behalf an operation is
+// performed.
+func instanceID(ctx apitypes.Context) string {
+	if iid, ok := ctx.Value(context.InstanceIDKey).(*apitypes.InstanceID); ok {
+		return iid.ID
+	}
+	return ""
+}
+
+// volume runs an operation that returns a volume.
+func (d *driver) volume(
+	ctx apitypes.Context, req *Request) (*apitypes.Volume, error) {
+
+	res, err := d.runner.run(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if res.Volume == nil {
+		return nil, goof.WithField("operation", req.Operation,
+			"script returned no volume")
+	}
+	return res.Volume.toVolume(ctx), nil
+}
+
+// snapshot runs an operation that returns a snapshot.
+func (d *driver) snapshot(
+	ctx apitypes.Context, req *Request) (*apitypes.Snapshot, error) {
+
+	res, err := d.runner.run(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if res.Snapshot == nil {
+		return nil, goof.WithField("operation", req.Operation,
+			"script returned no snapshot")
+	}
+	return res.Snapshot.toSnapshot(), nil
+}
+
+func (d *driver) Volumes(
+	ctx apitypes.Context,
+	opts *apitypes.VolumesOpts) ([]*apitypes.Volume, error) {
+
+	res, err := d.runner.run(ctx, &Request{
+		Operation:   OpVolumes,
+		InstanceID:  instanceID(ctx),
+		Attachments: opts.Attachments,
+		Opts:        toOpts(opts.Opts),
+	})
+	if err != nil {
+		return nil, err
+	}
+	vols := []*apitypes.Volume{}
+	for _, v := range res.Volumes {
+		vols = append(vols, v.toVolume(ctx))
+	}
+	return vols, nil
+}
+
+func (d *driver) VolumeInspect(
+	ctx apitypes.Context,
+	volumeID string,
+	opts *apitypes.VolumeInspectOpts) (*apitypes.Volume, error) {
+
+	return d.volume(ctx, &Request{
+		Operation:   OpVolumeInspect,
+		InstanceID:  instanceID(ctx),
+		VolumeID:    volumeID,
+		Attachments: opts.Attachments,
+		Opts:        toOpts(opts.Opts),
+	})
+}
+
+func (d *driver) VolumeCreate(
+	ctx apitypes.Context,
+	name string,
+	opts *apitypes.VolumeCreateOpts) (*apitypes.Volume, error) {
+
+	return d.volumeCreate(ctx, "", name, opts)
+}
+
+// VolumeCreateFromSnapshot runs the create operation with the ID of the
+// snapshot.
+func (d *driver) VolumeCreateFromSnapshot(
+	ctx apitypes.Context,
+	snapshotID, volumeName string,
+	opts *apitypes.VolumeCreateOpts) (*apitypes.Volume, error) {
+
+	return d.volumeCreate(ctx, snapshotID, volumeName, opts)
+}
+
+func (d *driver) volumeCreate(
+	ctx apitypes.Context,
+	snapshotID, name string,
+	opts *apitypes.VolumeCreateOpts) (*apitypes.Volume, error) {
+
+	req := &Request{
+		Operation:  OpVolumeCreate,
+		InstanceID: instanceID(ctx),
+		SnapshotID: snapshotID,
+		Name:       name,
+		Opts:       toOpts(opts.Opts),
+	}
+	if opts.AvailabilityZone != nil {
+		req.AvailabilityZone = *opts.AvailabilityZone
+	}
+	if opts.Size != nil {
+		req.Size = *opts.Size
+	}
+	if opts.Type != nil {
+		req.Type = *opts.Type
+	}
+	if opts.IOPS != nil {
+		req.IOPS = *opts.IOPS
+	}
+	return d.volume(ctx, req)
+}
+
+func (d *driver) VolumeCopy(
+	ctx apitypes.Context,
+	volumeID, volumeName string,
+	opts apitypes.Store) (*apitypes.Volume, error) {
+
+	return d.volume(ctx, &Request{
+		Operation:  OpVolumeCopy,
+		InstanceID: instanceID(ctx),
+		VolumeID:   volumeID,
+		Name:       volumeName,
+		Opts:       toOpts(opts),
+	})
+}
+
+func (d *driver) VolumeSnapshot(
+	ctx apitypes.Context,
+	volumeID, snapshotName string,
+	opts apitypes.Store) (*apitypes.Snapshot, error) {
+
+	return d.snapshot(ctx, &Request{
+		Operation:  OpVolumeSnapshot,
+		InstanceID: instanceID(ctx),
+		VolumeID:   volumeID,
+		Name:       snapshotName,
+		Opts:       toOpts(opts),
+	})
+}
+
+func (d *driver) VolumeRemove(
+	ctx apitypes.Context,
+	volumeID string,
+	opts apitypes.Store) error {
+
+	_, err := d.runner.run(ctx, &Request{
+		Operation:  OpVolumeRemove,
+		InstanceID: instanceID(ctx),
+		VolumeID:   volumeID,
+		Opts:       toOpts(opts),
+	})
+	return err
+}
+
+// VolumeAttach runs the attach operation and returns the token it returned,
+// or the ID of the volume if it returned none.
+func (d *driver) VolumeAttach(
+	ctx apitypes.Context,
+	volumeID string,
+	opts *apitypes.VolumeAttachOpts) (*apitypes.Volume, string, error) {
+
+	req := &Request{
+		Operation:  OpVolumeAttach,
+		InstanceID: instanceID(ctx),
+		VolumeID:   volumeID,
+		Force:      opts.Force,
+		Opts:       toOpts(opts.Opts),
+	}
+	if opts.NextDevice != nil {
+		req.NextDevice = *opts.NextDevice
+	}
+	res, err := d.runner.run(ctx, req)
+	if err != nil {
+		return nil, "", err
+	}
+	if res.Volume == nil {
+		return nil, "", goof.WithField("operation", req.Operation,
+			"script returned no volume")
+	}
+	token := res.Token
+	if token == "" {
+		token = volumeID
+	}
+	return res.Volume.toVolume(ctx), token, nil
+}
+
+func (d *driver) VolumeDetach(
+	ctx apitypes.Context,
+	volumeID string,
+	opts *apitypes.VolumeDetachOpts) (*apitypes.Volume, error) {
+
+	return d.volume(ctx, &Request{
+		Operation:  OpVolumeDetach,
+		InstanceID: instanceID(ctx),
+		VolumeID:   volumeID,
+		Force:      opts.Force,
+		Opts:       toOpts(opts.Opts),
+	})
+}
+
+func (d *driver) Snapshots(
+	ctx apitypes.Context,
+	opts apitypes.Store) ([]*apitypes.Snapshot, error) {
+
+	res, err := d.runner.run(ctx, &Request{
+		Operation:  OpSnapshots,
+		InstanceID: instanceID(ctx),
+		Opts:       toOpts(opts),
+	})
+	if err != nil {
+		return nil, err
+	}
+	snaps := []*apitypes.Snapshot{}
+	for _, s := range res.Snapshots {
+		snaps = append(snaps, s.toSnapshot())
+	}
+	return snaps, nil
+}
+
+func (d *driver) SnapshotInspect(
+	ctx apitypes.Context,
+	snapshotID string,
+	opts apitypes.Store) (*apitypes.Snapshot, error) {
+
+	return d.snapshot(ctx, &Request{
+		Operation:  OpSnapshotInspect,
+		InstanceID: instanceID(ctx),
+		SnapshotID: snapshotID,
+		Opts:       toOpts(opts),
+	})
+}
+
+func (d *driver) SnapshotCopy(
+	ctx apitypes.Context,
+	snapshotID, snapshotName, destinationID string,
+	opts apitypes.Store) (*apitypes.Snapshot, error) {
+
+	return nil, apitypes.ErrNotImplemented
+}
+
+func (d *driver) SnapshotRemove(
+	ctx apitypes.Context,
+	snapshotID string,
+	opts apitypes.Store) error {
+
+	_, err := d.runner.run(ctx, &Request{
+		Operation:  OpSnapshotRemove,
+		InstanceID: instanceID(ctx),
+		SnapshotID: snapshotID,
+		Opts:       toOpts(opts),
+	})
+	return err
+}
@@ -0,0 +1,78 @@
+package script
+
+import (
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	apitypes "github.com/emccode/libstorage/api/types"
+)
+
+// executor runs the instanceID and localDevices operations' executables on
+// each node.
+type executor struct {
+	runner *runner
+}
+
+func (e *executor) Name() string {
+	return Name
+}
+
+func (e *executor) Init(ctx apitypes.Context, config gofig.Config) error {
+	r, err := newRunner(config)
+	if err != nil {
+		return err
+	}
+	e.runner = r
+	return nil
+}
+
+func (e *executor) InstanceID(
+	ctx apitypes.Context,
+	opts apitypes.Store) (*apitypes.InstanceID, error) {
+
+	res, err := e.runner.run(ctx, &Request{
+		Operation: OpInstanceID,
+		Opts:      toOpts(opts),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if res.InstanceID == "" {
+		return nil, goof.New("script returned no instance ID")
+	}
+	return &apitypes.InstanceID{ID: res.InstanceID, Driver: Name}, nil
+}
+
+func (e *executor) NextDevice(
+	ctx apitypes.Context,
+	opts apitypes.Store) (string, error) {
+
+	return "", apitypes.ErrNotImplemented
+}
+
+// LocalDevices returns the devices of the volumes attached to the node keyed
+// by their tokens. A node without a localDevices executable has none.
+func (e *executor) LocalDevices(
+	ctx apitypes.Context,
+	opts *apitypes.LocalDevicesOpts) (*apitypes.LocalDevices, error) {
+
+	scanType := "quick"
+	if opts.ScanType == apitypes.DeviceScanDeep {
+		scanType = "deep"
+	}
+	res, err := e.runner.run(ctx, &Request{
+		Operation: OpLocalDevices,
+		ScanType:  scanType,
+		Opts:      toOpts(opts.Opts),
+	})
+	if err == apitypes.ErrNotImplemented {
+		res, err = &Response{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	devs := res.Devices
+	if devs == nil {
+		devs = map[string]string{}
+	}
+	return &apitypes.LocalDevices{Driver: Name, DeviceMap: devs}, nil
+}
@@ -0,0 +1,19 @@
+// +build darwin dragonfly freebsd !android,linux netbsd openbsd solaris
+
+package script
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup runs an executable in its own process group so that the
+// processes it starts are killed along with it.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// kill kills an executable's process group.
+func kill(cmd *exec.Cmd) {
+	syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
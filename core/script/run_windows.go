@@ -0,0 +1,10 @@
+package script
+
+import "os/exec"
+
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// kill kills an executable's process.
+func kill(cmd *exec.Cmd) {
+	cmd.Process.Kill()
+}
@@ -0,0 +1,352 @@
+// Package script is a storage driver that delegates each storage operation
+// to an executable supplied by the operator, so that backends without a
+// driver may be integrated without writing Go. The executable of an
+// operation is named after it in the script directory, receives a JSON
+// request on its standard input, and writes a JSON response to its standard
+// output. Volumes and snapshots are encoded as libStorage encodes them in
+// its API. An operation whose executable does not exist is not implemented.
+package script
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	"github.com/emccode/libstorage/api/context"
+	"github.com/emccode/libstorage/api/registry"
+	apitypes "github.com/emccode/libstorage/api/types"
+)
+
+// Name is the name with which the driver is registered.
+const Name = "script"
+
+// The operations whose executables the driver and executor run.
+const (
+	OpVolumes         = "volumes"
+	OpVolumeInspect   = "inspect"
+	OpVolumeCreate    = "create"
+	OpVolumeCopy      = "copy"
+	OpVolumeRemove    = "remove"
+	OpVolumeAttach    = "attach"
+	OpVolumeDetach    = "detach"
+	OpVolumeSnapshot  = "snapshot"
+	OpSnapshots       = "snapshots"
+	OpSnapshotInspect = "snapshotInspect"
+	OpSnapshotRemove  = "snapshotRemove"
+	OpInstanceID      = "instanceID"
+	OpLocalDevices    = "localDevices"
+)
+
+// EnvOperation is the environment variable that holds the name of the
+// operation an executable is run for, so that one executable may be linked
+// under the names of several operations.
+const EnvOperation = "REXRAY_SCRIPT_OPERATION"
+
+const defaultTimeout = time.Minute
+
+func init() {
+	registry.RegisterStorageDriver(Name, newDriver)
+	registry.RegisterStorageExecutor(Name, newExecutor)
+
+	r := gofig.NewRegistration("Script Driver")
+	r.Key(gofig.String, "", "",
+		"The directory of the executables of the operations",
+		"script.dir")
+	r.Key(gofig.String, "", "block",
+		"The type of the volumes: block, nas, or object",
+		"script.type")
+	r.Key(gofig.String, "", "1m",
+		"How long an operation's executable may run",
+		"script.timeout")
+	r.Key(gofig.String, "", "",
+		"How long the executables of specific operations may run, ex. "+
+			"create=10m,attach=5m",
+		"script.timeouts")
+	gofig.Register(r)
+}
+
+func newDriver() apitypes.StorageDriver {
+	return &driver{}
+}
+
+func newExecutor() apitypes.StorageExecutor {
+	return &executor{}
+}
+
+// Request is the JSON request an operation's executable receives. Only the
+// fields that apply to the operation are set.
+type Request struct {
+	Operation        string                 `json:"operation"`
+	InstanceID       string                 `json:"instanceID,omitempty"`
+	VolumeID         string                 `json:"volumeID,omitempty"`
+	SnapshotID       string                 `json:"snapshotID,omitempty"`
+	Name             string                 `json:"name,omitempty"`
+	Size             int64                  `json:"size,omitempty"`
+	IOPS             int64                  `json:"iops,omitempty"`
+	Type             string                 `json:"type,omitempty"`
+	AvailabilityZone string                 `json:"availabilityZone,omitempty"`
+	NextDevice       string                 `json:"nextDevice,omitempty"`
+	Force            bool                   `json:"force,omitempty"`
+	Attachments      bool                   `json:"attachments,omitempty"`
+	ScanType         string                 `json:"scanType,omitempty"`
+	Opts             map[string]interface{} `json:"opts,omitempty"`
+}
+
+// Response is the JSON response an operation's executable writes. An
+// operation that returns a volume sets Volume, and one that lists volumes
+// sets Volumes. An attach may also set the token with which the node waits
+// for the volume's device to appear in its local devices, which is the ID of
+// the volume if it does not. The executor's instanceID and localDevices
+// operations set the instance ID and the node's devices keyed by the tokens
+// of the volumes attached to it.
+type Response struct {
+	Volume     *Volume           `json:"volume,omitempty"`
+	Volumes    []*Volume         `json:"volumes,omitempty"`
+	Snapshot   *Snapshot         `json:"snapshot,omitempty"`
+	Snapshots  []*Snapshot       `json:"snapshots,omitempty"`
+	Token      string            `json:"token,omitempty"`
+	InstanceID string            `json:"instanceID,omitempty"`
+	Devices    map[string]string `json:"devices,omitempty"`
+}
+
+// Volume is a volume, as encoded in a response. Its size is in GiB.
+type Volume struct {
+	ID               string            `json:"id"`
+	Name             string            `json:"name,omitempty"`
+	Size             int64             `json:"size,omitempty"`
+	IOPS             int64             `json:"iops,omitempty"`
+	Type             string            `json:"type,omitempty"`
+	Status           string            `json:"status,omitempty"`
+	AvailabilityZone string            `json:"availabilityZone,omitempty"`
+	Attachments      []*Attachment     `json:"attachments,omitempty"`
+	Fields           map[string]string `json:"fields,omitempty"`
+}
+
+// Attachment is the attachment of a volume to an instance, as encoded in a
+// response. The device of an attachment to the instance on whose behalf the
+// operation is performed is looked up in its local devices by the token of
+// the attachment, or by the ID of the volume if the attachment has none.
+type Attachment struct {
+	InstanceID string `json:"instanceID"`
+	Token      string `json:"token,omitempty"`
+	DeviceName string `json:"deviceName,omitempty"`
+	MountPoint string `json:"mountPoint,omitempty"`
+	Status     string `json:"status,omitempty"`
+}
+
+// Snapshot is a snapshot, as encoded in a response. Its size is in GiB and
+// its start time is in seconds since the epoch.
+type Snapshot struct {
+	ID          string            `json:"id"`
+	Name        string            `json:"name,omitempty"`
+	VolumeID    string            `json:"volumeID,omitempty"`
+	Size        int64             `json:"size,omitempty"`
+	Status      string            `json:"status,omitempty"`
+	Description string            `json:"description,omitempty"`
+	StartTime   int64             `json:"startTime,omitempty"`
+	Fields      map[string]string `json:"fields,omitempty"`
+}
+
+// toVolume returns the libStorage volume of a volume in a response.
+func (v *Volume) toVolume(ctx apitypes.Context) *apitypes.Volume {
+	vol := &apitypes.Volume{
+		ID:               v.ID,
+		Name:             v.Name,
+		Size:             v.Size,
+		IOPS:             v.IOPS,
+		Type:             v.Type,
+		Status:           v.Status,
+		AvailabilityZone: v.AvailabilityZone,
+		Fields:           v.Fields,
+	}
+	iid := instanceID(ctx)
+	ld, _ := ctx.Value(context.LocalDevicesKey).(*apitypes.LocalDevices)
+	for _, a := range v.Attachments {
+		att := &apitypes.VolumeAttachment{
+			VolumeID:   v.ID,
+			InstanceID: &apitypes.InstanceID{ID: a.InstanceID, Driver: Name},
+			DeviceName: a.DeviceName,
+			MountPoint: a.MountPoint,
+			Status:     a.Status,
+		}
+		if att.DeviceName == "" && a.InstanceID == iid && ld != nil {
+			token := a.Token
+			if token == "" {
+				token = v.ID
+			}
+			att.DeviceName = ld.DeviceMap[token]
+		}
+		vol.Attachments = append(vol.Attachments, att)
+	}
+	return vol
+}
+
+// toSnapshot returns the libStorage snapshot of a snapshot in a response.
+func (s *Snapshot) toSnapshot() *apitypes.Snapshot {
+	return &apitypes.Snapshot{
+		ID:          s.ID,
+		Name:        s.Name,
+		VolumeID:    s.VolumeID,
+		VolumeSize:  s.Size,
+		Status:      s.Status,
+		Description: s.Description,
+		StartTime:   s.StartTime,
+		Fields:      s.Fields,
+	}
+}
+
+// runner runs the executables of operations.
+type runner struct {
+	dir      string
+	timeout  time.Duration
+	timeouts map[string]time.Duration
+}
+
+func newRunner(config gofig.Config) (*runner, error) {
+	dir := config.GetString("script.dir")
+	if dir == "" {
+		return nil, goof.New("script driver requires script.dir")
+	}
+	r := &runner{dir: dir, timeout: defaultTimeout}
+	if t, err := time.ParseDuration(
+		config.GetString("script.timeout")); err == nil && t > 0 {
+		r.timeout = t
+	}
+	var err error
+	if r.timeouts, err = parseTimeouts(
+		config.GetString("script.timeouts")); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// parseTimeouts parses a list of operations and their timeouts, ex.
+// create=10m,attach=5m.
+func parseTimeouts(s string) (map[string]time.Duration, error) {
+	timeouts := map[string]time.Duration{}
+	for _, f := range strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ' '
+	}) {
+		kv := strings.SplitN(f, "=", 2)
+		if len(kv) != 2 {
+			return nil, goof.WithField("timeout", f, "invalid script timeout")
+		}
+		t, err := time.ParseDuration(kv[1])
+		if err != nil || t <= 0 {
+			return nil, goof.WithField("timeout", f, "invalid script timeout")
+		}
+		timeouts[kv[0]] = t
+	}
+	return timeouts, nil
+}
+
+// path returns the path of an operation's executable, or an empty string if
+// there is none.
+func (r *runner) path(op string) string {
+	p := filepath.Join(r.dir, op)
+	if fi, err := os.Stat(p); err != nil || fi.IsDir() ||
+		fi.Mode()&0111 == 0 {
+		return ""
+	}
+	return p
+}
+
+// run runs an operation's executable with the request and decodes its
+// response. The executable is killed if it runs longer than the operation's
+// timeout, or if the context is canceled first. An executable that exits
+// with a non-zero status fails the operation with the last line it wrote to
+// its standard error as the message.
+func (r *runner) run(
+	ctx apitypes.Context, req *Request) (*Response, error) {
+
+	path := r.path(req.Operation)
+	if path == "" {
+		return nil, apitypes.ErrNotImplemented
+	}
+	in, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	timeout := r.timeout
+	if t, ok := r.timeouts[req.Operation]; ok {
+		timeout = t
+	}
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	cmd := exec.Command(path)
+	cmd.Stdin = bytes.NewReader(in)
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	cmd.Env = append(os.Environ(), EnvOperation+"="+req.Operation)
+	setProcessGroup(cmd)
+
+	fields := map[string]interface{}{
+		"operation": req.Operation,
+		"path":      path,
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, goof.WithFieldsE(fields, "error running script", err)
+	}
+	timer := time.AfterFunc(timeout, func() { kill(cmd) })
+	exited := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			kill(cmd)
+		case <-exited:
+		}
+	}()
+	err = cmd.Wait()
+	close(exited)
+	timedOut := !timer.Stop()
+
+	if msg := strings.TrimSpace(stderr.String()); msg != "" {
+		ctx.WithFields(fields).Debug(msg)
+	}
+	if timedOut {
+		fields["timeout"] = timeout
+		return nil, goof.WithFields(fields, "script timed out")
+	}
+	if ctxErr := ctx.Err(); ctxErr != nil && err != nil {
+		return nil, goof.WithFieldsE(fields, "script canceled", ctxErr)
+	}
+	if err != nil {
+		fields["stderr"] = lastLine(stderr.String())
+		return nil, goof.WithFieldsE(fields, "script failed", err)
+	}
+
+	res := &Response{}
+	if out := bytes.TrimSpace(stdout.Bytes()); len(out) > 0 {
+		if err := json.Unmarshal(out, res); err != nil {
+			return nil, goof.WithFieldsE(fields,
+				"invalid script response", err)
+		}
+	}
+	return res, nil
+}
+
+// lastLine returns the last non-empty line of an executable's output.
+func lastLine(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
+// toOpts returns the values of a store of options.
+func toOpts(opts apitypes.Store) map[string]interface{} {
+	if opts == nil {
+		return nil
+	}
+	m := map[string]interface{}{}
+	for _, k := range opts.Keys() {
+		m[k] = opts.Get(k)
+	}
+	if len(m) == 0 {
+		return nil
+	}
+	return m
+}
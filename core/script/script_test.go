@@ -0,0 +1,151 @@
+package script
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/emccode/libstorage/api/context"
+	apitypes "github.com/emccode/libstorage/api/types"
+)
+
+// newTestRunner returns a runner of a temporary directory with executables
+// of the operations with the contents of the shell scripts.
+func newTestRunner(t *testing.T, scripts map[string]string) *runner {
+	dir, err := ioutil.TempDir("", "script")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for op, s := range scripts {
+		if err := ioutil.WriteFile(filepath.Join(dir, op),
+			[]byte("#!/bin/sh\n"+s+"\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &runner{dir: dir, timeout: 10 * time.Second}
+}
+
+func TestParseTimeouts(t *testing.T) {
+	timeouts, err := parseTimeouts("create=10m, attach=90s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if timeouts[OpVolumeCreate] != 10*time.Minute ||
+		timeouts[OpVolumeAttach] != 90*time.Second || len(timeouts) != 2 {
+		t.Fatalf("timeouts=%v", timeouts)
+	}
+	for _, s := range []string{"create", "create=", "create=-1s"} {
+		if _, err := parseTimeouts(s); err == nil {
+			t.Fatalf("parsed %s", s)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	r := newTestRunner(t, map[string]string{
+		OpVolumeCreate: `cat > "$(dirname "$0")/request.json"
+echo "$` + EnvOperation + `" >&2
+echo '{"volume":{"id":"v1","name":"data","size":8,` +
+			`"attachments":[{"instanceID":"node1"}]}}'`,
+	})
+	defer os.RemoveAll(r.dir)
+
+	res, err := r.run(context.Background(), &Request{
+		Operation: OpVolumeCreate,
+		Name:      "data",
+		Size:      8,
+		Opts:      map[string]interface{}{"tier": "gold"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := res.Volume; v == nil || v.ID != "v1" || v.Size != 8 ||
+		len(v.Attachments) != 1 || v.Attachments[0].InstanceID != "node1" {
+		t.Fatalf("volume=%+v", res.Volume)
+	}
+
+	buf, err := ioutil.ReadFile(filepath.Join(r.dir, "request.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := &Request{}
+	if err := json.Unmarshal(buf, req); err != nil {
+		t.Fatal(err)
+	}
+	if req.Operation != OpVolumeCreate || req.Name != "data" ||
+		req.Size != 8 || req.Opts["tier"] != "gold" {
+		t.Fatalf("request=%s", buf)
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	r := newTestRunner(t, map[string]string{
+		OpVolumeRemove:   "echo 'volume is in use' >&2; exit 1",
+		OpVolumeInspect:  "echo 'not json'",
+		OpVolumeSnapshot: "sleep 10",
+	})
+	defer os.RemoveAll(r.dir)
+	r.timeouts = map[string]time.Duration{
+		OpVolumeSnapshot: 100 * time.Millisecond,
+	}
+	ctx := context.Background()
+
+	if _, err := r.run(ctx, &Request{Operation: OpVolumeAttach}); err !=
+		apitypes.ErrNotImplemented {
+		t.Fatalf("err=%v", err)
+	}
+	for _, op := range []string{OpVolumeRemove, OpVolumeInspect} {
+		if _, err := r.run(ctx, &Request{Operation: op}); err == nil {
+			t.Fatalf("%s succeeded", op)
+		}
+	}
+
+	start := time.Now()
+	if _, err := r.run(ctx, &Request{Operation: OpVolumeSnapshot}); err == nil {
+		t.Fatal("snapshot succeeded")
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Fatalf("snapshot ran for %v", d)
+	}
+}
+
+func TestLastLine(t *testing.T) {
+	if l := lastLine("warning\nvolume is in use\n\n"); l != "volume is in use" {
+		t.Fatalf("line=%s", l)
+	}
+}
+
+func TestToVolume(t *testing.T) {
+	ctx := context.WithValue(context.Background(),
+		context.InstanceIDKey, &apitypes.InstanceID{ID: "node1", Driver: Name})
+	ctx = context.WithValue(ctx, context.LocalDevicesKey,
+		&apitypes.LocalDevices{
+			Driver: Name,
+			DeviceMap: map[string]string{
+				"v1":     "/dev/sdb",
+				"lun-42": "/dev/sdc",
+			},
+		})
+
+	for token, dev := range map[string]string{
+		"":       "/dev/sdb",
+		"lun-42": "/dev/sdc",
+	} {
+		vol := (&Volume{
+			ID: "v1",
+			Attachments: []*Attachment{
+				{InstanceID: "node1", Token: token},
+				{InstanceID: "node2"},
+			},
+		}).toVolume(ctx)
+		if n := vol.Attachments[0].DeviceName; n != dev {
+			t.Fatalf("token=%s device=%s", token, n)
+		}
+		if n := vol.Attachments[1].DeviceName; n != "" {
+			t.Fatalf("device=%s", n)
+		}
+	}
+}
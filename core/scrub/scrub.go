@@ -0,0 +1,245 @@
+// Package scrub checks the integrity of the volumes that are attached to a
+// node but not mounted, either by running a read-only check of their file
+// systems or by querying the storage platform for their health. Which checks
+// are run, and how often, is determined by policies that select volumes by
+// their labels.
+package scrub
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/core/labels"
+	"github.com/emccode/rexray/core/state"
+)
+
+const (
+	// CheckFS is a read-only check of a volume's file system, such as
+	// e2fsck -n or xfs_repair -n.
+	CheckFS = "fs"
+
+	// CheckBackend queries the storage platform for a volume's health.
+	CheckBackend = "backend"
+
+	policiesKey  = "rexray.volume.scrub.policies"
+	scrubsBucket = "volumeScrubs"
+)
+
+func init() {
+	r := gofig.NewRegistration("Volume Scrubbing")
+	r.Key(gofig.String, "", "",
+		"When idle attached volumes are checked, as a cron expression, "+
+			"descriptor, or @every duration; empty disables scrubbing",
+		"rexray.volume.scrub.schedule")
+	r.Key(gofig.String, "", "fs,backend",
+		"The checks run on volumes that match no policy",
+		"rexray.volume.scrub.checks")
+	r.Key(gofig.String, "", "0",
+		"The minimum time between checks of a volume that matches no policy",
+		"rexray.volume.scrub.interval")
+	r.Key(gofig.String, "", "30m",
+		"The duration after which a check is abandoned",
+		"rexray.volume.scrub.timeout")
+	gofig.Register(r)
+}
+
+// Status is the outcome of a check.
+type Status string
+
+const (
+	// OK indicates the check found no anomaly.
+	OK Status = "ok"
+
+	// Anomaly indicates the check found an anomaly.
+	Anomaly Status = "anomaly"
+
+	// Failed indicates the check could not be completed.
+	Failed Status = "failed"
+
+	// Skipped indicates the check does not apply to the volume, such as a
+	// file system check of a raw block volume.
+	Skipped Status = "skipped"
+)
+
+// Result is the outcome of one check of a volume.
+type Result struct {
+	Check  string `json:"check" yaml:"check"`
+	Status Status `json:"status" yaml:"status"`
+
+	// Reason describes the anomaly or failure.
+	Reason string `json:"reason,omitempty" yaml:"reason,omitempty"`
+}
+
+// Record is the outcome of the most recent scrub of a volume.
+type Record struct {
+	Checked time.Time `json:"checked" yaml:"checked"`
+	Results []*Result `json:"results" yaml:"results"`
+}
+
+// Policy determines the checks run on the volumes whose labels match its
+// selector.
+type Policy struct {
+	Name     string
+	Selector labels.Selector
+	Checks   []string
+
+	// Interval is the minimum time between checks of a volume. A volume is
+	// checked by every run of the job if it is zero.
+	Interval time.Duration
+}
+
+// Timeout returns the duration after which a check is abandoned.
+func Timeout(config gofig.Config) time.Duration {
+	d, err := time.ParseDuration(
+		config.GetString("rexray.volume.scrub.timeout"))
+	if err != nil || d <= 0 {
+		return 30 * time.Minute
+	}
+	return d
+}
+
+// Policies returns the configured policies ordered by name, followed by the
+// default policy, which matches every volume.
+func Policies(config gofig.Config) ([]*Policy, error) {
+	names := []string{}
+	if m, ok := config.Get(policiesKey).(map[string]interface{}); ok {
+		for name := range m {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	policies := []*Policy{}
+	for _, name := range names {
+		sc := config.Scope(fmt.Sprintf("%s.%s", policiesKey, name))
+		sel, err := labels.ParseSelector(sc.GetString("selector"))
+		if err != nil {
+			return nil, goof.WithFieldE("policy", name, "invalid selector", err)
+		}
+		p, err := newPolicy(
+			name, sel, sc.GetString("checks"), sc.GetString("interval"))
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, p)
+	}
+
+	p, err := newPolicy("default", labels.Selector{},
+		config.GetString("rexray.volume.scrub.checks"),
+		config.GetString("rexray.volume.scrub.interval"))
+	if err != nil {
+		return nil, err
+	}
+	return append(policies, p), nil
+}
+
+func newPolicy(
+	name string,
+	sel labels.Selector,
+	checks, interval string) (*Policy, error) {
+
+	p := &Policy{Name: name, Selector: sel, Checks: []string{}}
+	for _, c := range strings.Split(checks, ",") {
+		switch c = strings.TrimSpace(c); c {
+		case "", "none":
+		case CheckFS, CheckBackend:
+			p.Checks = append(p.Checks, c)
+		default:
+			return nil, goof.WithFields(goof.Fields{
+				"policy": name,
+				"check":  c,
+			}, "invalid scrub check")
+		}
+	}
+	if interval != "" {
+		d, err := time.ParseDuration(interval)
+		if err != nil {
+			return nil, goof.WithFieldE(
+				"policy", name, "invalid scrub interval", err)
+		}
+		p.Interval = d
+	}
+	return p, nil
+}
+
+// For returns the first of the policies whose selector matches the provided
+// labels.
+func For(policies []*Policy, l labels.Labels) *Policy {
+	for _, p := range policies {
+		if p.Selector.Matches(l) {
+			return p
+		}
+	}
+	return nil
+}
+
+// Due returns a flag indicating whether a volume with the provided record
+// should be checked at the provided time.
+func (p *Policy) Due(r *Record, now time.Time) bool {
+	if len(p.Checks) == 0 {
+		return false
+	}
+	return r == nil || !now.Before(r.Checked.Add(p.Interval))
+}
+
+// Get returns the record of the most recent scrub of the volume with the
+// provided ID, or nil if it has never been scrubbed.
+func Get(s *state.Store, volumeID string) (*Record, error) {
+	r := &Record{}
+	ok, err := s.Get(scrubsBucket, volumeID, r)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Set records the most recent scrub of the volume with the provided ID.
+func Set(s *state.Store, volumeID string, r *Record) error {
+	return s.Set(scrubsBucket, volumeID, r)
+}
+
+// unhealthyStatuses are the words in the statuses that storage platforms
+// report for volumes and attachments that are unhealthy.
+var unhealthyStatuses = []string{
+	"error", "fail", "degraded", "impaired", "corrupt", "offline",
+}
+
+// Backend checks the health the storage platform reports for a volume
+// that should be attached to the instance with the provided ID.
+func Backend(vol *apitypes.Volume, iid string) *Result {
+	r := &Result{Check: CheckBackend, Status: OK}
+	if unhealthy(vol.Status) {
+		r.Status = Anomaly
+		r.Reason = fmt.Sprintf("volume status is %s", vol.Status)
+		return r
+	}
+	for _, a := range vol.Attachments {
+		if a.InstanceID == nil || a.InstanceID.ID != iid {
+			continue
+		}
+		if unhealthy(a.Status) {
+			r.Status = Anomaly
+			r.Reason = fmt.Sprintf("attachment status is %s", a.Status)
+		}
+		return r
+	}
+	r.Status = Anomaly
+	r.Reason = "volume is no longer attached"
+	return r
+}
+
+func unhealthy(status string) bool {
+	status = strings.ToLower(status)
+	for _, s := range unhealthyStatuses {
+		if strings.Contains(status, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,120 @@
+package scrub
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/akutz/goof"
+)
+
+// fsCheck is the read-only check of a file system type. The check found an
+// anomaly if its exit status has one of the anomaly bits set, even if it
+// could not complete, and it failed if its exit status is otherwise non-zero.
+type fsCheck struct {
+	name    string
+	args    []string
+	anomaly int
+}
+
+var fsChecks = map[string]*fsCheck{
+	"ext2":  {"e2fsck", []string{"-n", "-f"}, 4},
+	"ext3":  {"e2fsck", []string{"-n", "-f"}, 4},
+	"ext4":  {"e2fsck", []string{"-n", "-f"}, 4},
+	"xfs":   {"xfs_repair", []string{"-n"}, 1},
+	"btrfs": {"btrfs", []string{"check", "--readonly"}, 1},
+}
+
+// FileSystem runs a read-only check of the file system on the provided device,
+// which must not be mounted.
+func FileSystem(device string, timeout time.Duration) *Result {
+	r := &Result{Check: CheckFS, Status: OK}
+
+	out, status, err := run(
+		timeout, "blkid", "-o", "value", "-s", "TYPE", device)
+	switch {
+	case err != nil:
+		r.Status = Failed
+		r.Reason = err.Error()
+		return r
+	case status == 2:
+		r.Status = Skipped
+		r.Reason = "device has no file system"
+		return r
+	case status != 0:
+		r.Status = Failed
+		r.Reason = fmt.Sprintf("blkid exited with status %d: %s",
+			status, lastLine(out))
+		return r
+	}
+
+	fsType := strings.TrimSpace(out)
+	c, ok := fsChecks[fsType]
+	if !ok {
+		r.Status = Skipped
+		r.Reason = fmt.Sprintf("%s file systems are not checked", fsType)
+		return r
+	}
+
+	out, status, err = run(timeout, c.name, append(c.args, device)...)
+	switch {
+	case err != nil:
+		r.Status = Failed
+		r.Reason = err.Error()
+	case status == 0:
+	case status&c.anomaly != 0:
+		r.Status = Anomaly
+		r.Reason = fmt.Sprintf("%s found errors: %s", c.name, lastLine(out))
+	default:
+		r.Status = Failed
+		r.Reason = fmt.Sprintf("%s exited with status %d: %s",
+			c.name, status, lastLine(out))
+	}
+	return r
+}
+
+// run runs a command and returns its combined output and exit status. An
+// error is returned if the command cannot be started or does not complete
+// within the timeout, in which case it is killed.
+func run(
+	timeout time.Duration,
+	name string, args ...string) (string, int, error) {
+
+	buf := &bytes.Buffer{}
+	cmd := exec.Command(name, args...)
+	cmd.Stdout, cmd.Stderr = buf, buf
+	if err := cmd.Start(); err != nil {
+		return "", 0, goof.WithFieldE(
+			"command", name, "error starting check", err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	select {
+	case err := <-done:
+		if ee, ok := err.(*exec.ExitError); ok {
+			if ws, ok := ee.Sys().(syscall.WaitStatus); ok {
+				return buf.String(), ws.ExitStatus(), nil
+			}
+		}
+		if err != nil {
+			return "", 0, goof.WithFieldE(
+				"command", name, "error running check", err)
+		}
+		return buf.String(), 0, nil
+	case <-time.After(timeout):
+		cmd.Process.Kill()
+		return "", 0, goof.WithFields(goof.Fields{
+			"command": name,
+			"timeout": timeout,
+		}, "check timed out")
+	}
+}
+
+func lastLine(out string) string {
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
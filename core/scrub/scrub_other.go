@@ -0,0 +1,14 @@
+// +build !linux
+
+package scrub
+
+import "time"
+
+// FileSystem skips the check since file systems are only checked on Linux.
+func FileSystem(device string, timeout time.Duration) *Result {
+	return &Result{
+		Check:  CheckFS,
+		Status: Skipped,
+		Reason: "file systems are only checked on linux",
+	}
+}
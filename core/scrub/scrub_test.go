@@ -0,0 +1,74 @@
+package scrub
+
+import (
+	"testing"
+	"time"
+
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/core/labels"
+)
+
+func TestPolicies(t *testing.T) {
+	sel, err := labels.ParseSelector("env=prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	prod, err := newPolicy("prod", sel, "fs, backend", "24h")
+	if err != nil {
+		t.Fatal(err)
+	}
+	def, err := newPolicy("default", labels.Selector{}, "none", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	policies := []*Policy{prod, def}
+
+	if p := For(policies, labels.Labels{"env": "prod"}); p != prod ||
+		len(p.Checks) != 2 || p.Interval != 24*time.Hour {
+		t.Fatalf("unexpected policy %+v", p)
+	}
+	if p := For(policies, labels.Labels{"env": "dev"}); p != def {
+		t.Fatalf("unexpected policy %+v", p)
+	}
+
+	now := time.Now()
+	if !prod.Due(nil, now) ||
+		prod.Due(&Record{Checked: now.Add(-time.Hour)}, now) ||
+		!prod.Due(&Record{Checked: now.Add(-25 * time.Hour)}, now) {
+		t.Fatal("unexpected due")
+	}
+	if def.Due(nil, now) {
+		t.Fatal("policy without checks is due")
+	}
+
+	if _, err := newPolicy("bad", sel, "fs,smart", ""); err == nil {
+		t.Fatal("invalid check accepted")
+	}
+}
+
+func TestBackend(t *testing.T) {
+	vol := &apitypes.Volume{
+		ID:     "vol-1",
+		Status: "in-use",
+		Attachments: []*apitypes.VolumeAttachment{{
+			InstanceID: &apitypes.InstanceID{ID: "i-1"},
+			Status:     "attached",
+		}},
+	}
+	if r := Backend(vol, "i-1"); r.Status != OK {
+		t.Fatalf("unexpected result %+v", r)
+	}
+	if r := Backend(vol, "i-2"); r.Status != Anomaly {
+		t.Fatalf("unexpected result %+v", r)
+	}
+	vol.Attachments[0].Status = "impaired"
+	if r := Backend(vol, "i-1"); r.Status != Anomaly {
+		t.Fatalf("unexpected result %+v", r)
+	}
+	vol.Status = "error"
+	if r := Backend(vol, "i-1"); r.Status != Anomaly ||
+		r.Reason != "volume status is error" {
+		t.Fatalf("unexpected result %+v", r)
+	}
+}
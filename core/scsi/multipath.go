@@ -0,0 +1,37 @@
+package scsi
+
+import (
+	"time"
+
+	"github.com/akutz/gofig"
+)
+
+const defaultMultipathTimeout = 10 * time.Second
+
+func init() {
+	r := gofig.NewRegistration("Linux Multipath")
+	r.Key(gofig.Bool, "", true,
+		"Use the multipath devices of LUNs when multipathd is active",
+		"linux.multipath.enabled")
+	r.Key(gofig.String, "", "10s",
+		"How long to wait for multipathd to coalesce the paths of a LUN",
+		"linux.multipath.timeout")
+	gofig.Register(r)
+}
+
+// Multipath returns a flag indicating whether the multipath devices of LUNs
+// are used instead of their paths, which is when linux.multipath.enabled is
+// set and multipathd is active on the node.
+func Multipath(config gofig.Config) bool {
+	return config.GetBool("linux.multipath.enabled") && MultipathActive()
+}
+
+// MultipathTimeout returns how long to wait for multipathd to create the
+// multipath device of a LUN whose paths it has claimed.
+func MultipathTimeout(config gofig.Config) time.Duration {
+	d, err := time.ParseDuration(config.GetString("linux.multipath.timeout"))
+	if err != nil {
+		return defaultMultipathTimeout
+	}
+	return d
+}
@@ -0,0 +1,115 @@
+package scsi
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	apitypes "github.com/emccode/libstorage/api/types"
+)
+
+var multipathdPIDFiles = []string{
+	"/run/multipathd.pid",
+	"/var/run/multipathd.pid",
+	"/run/multipathd/multipathd.pid",
+}
+
+// MultipathActive returns a flag indicating whether multipathd is running on
+// the node.
+func MultipathActive() bool {
+	for _, f := range multipathdPIDFiles {
+		if _, err := os.Stat(f); err == nil {
+			return true
+		}
+	}
+	return exec.Command("multipathd", "show", "daemon").Run() == nil
+}
+
+// isMultipathPath returns a flag indicating whether multipathd claims a
+// device as a path of a multipath device.
+func isMultipathPath(device string) bool {
+	return exec.Command("multipath", "-c", device).Run() == nil
+}
+
+// ResolveMultipath returns the path of a LUN's multipath device given the
+// path of the LUN's device, which may be one of its paths, a device-mapper
+// device such as /dev/dm-0, or a link to either. The multipath device is
+// named in /dev/mapper by the name multipathd gave it, which is its alias or
+// friendly name, ex. mpatha, if one is configured and its WWID otherwise. If
+// multipathd claims a path that no multipath device holds yet, the path is
+// waited on for up to the timeout for multipathd to coalesce it with the
+// LUN's other paths. The device is returned if it has no multipath device.
+func ResolveMultipath(
+	ctx apitypes.Context, device string, timeout time.Duration) string {
+
+	path, err := filepath.EvalSymlinks(device)
+	if err != nil {
+		return device
+	}
+	dev := filepath.Base(path)
+	if strings.HasPrefix(dev, "dm-") {
+		if name, ok := dmName(dev); ok {
+			return filepath.Join(mapperDir, name)
+		}
+		return device
+	}
+	if h := Holders(dev); len(h) > 0 {
+		return filepath.Join(mapperDir, h[0])
+	}
+	if !isMultipathPath(path) {
+		return device
+	}
+
+	f := ctx.WithField("device", path)
+	f.Debug("waiting for multipath device")
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		time.Sleep(250 * time.Millisecond)
+		if h := Holders(dev); len(h) > 0 {
+			return filepath.Join(mapperDir, h[0])
+		}
+	}
+	f.Warn("timed out waiting for multipath device")
+	return device
+}
+
+// SettleMultipath waits for up to the timeout for multipathd to coalesce the
+// paths of new LUNs, which is when each SCSI disk that multipathd claims is
+// held by a multipath device. It is called after a rescan so that the LUNs
+// that appeared are reported as their multipath devices.
+func SettleMultipath(ctx apitypes.Context, timeout time.Duration) {
+	infos, err := ioutil.ReadDir(sysBlock)
+	if err != nil {
+		return
+	}
+	pending := []string{}
+	for _, fi := range infos {
+		dev := fi.Name()
+		if !strings.HasPrefix(dev, "sd") || len(Holders(dev)) > 0 {
+			continue
+		}
+		if isMultipathPath(filepath.Join("/dev", dev)) {
+			pending = append(pending, dev)
+		}
+	}
+
+	deadline := time.Now().Add(timeout)
+	for len(pending) > 0 {
+		if !time.Now().Before(deadline) {
+			ctx.WithField("devices", strings.Join(pending, " ")).Warn(
+				"timed out waiting for multipath devices")
+			return
+		}
+		time.Sleep(250 * time.Millisecond)
+		unheld := pending[:0]
+		for _, dev := range pending {
+			if len(Holders(dev)) == 0 {
+				unheld = append(unheld, dev)
+			}
+		}
+		pending = unheld
+	}
+}
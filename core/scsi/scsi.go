@@ -0,0 +1,103 @@
+// Package scsi discovers the SCSI devices of the LUNs that storage platforms
+// present to a node. It is shared by the executors of drivers whose LUNs
+// appear as SCSI disks, such as iSCSI and Fibre Channel arrays, and resolves
+// a LUN's paths to its device-mapper multipath device.
+package scsi
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	apitypes "github.com/emccode/libstorage/api/types"
+)
+
+// NormalizeWWN returns a WWN in the form used to match LUNs and ports:
+// lower case hexadecimal digits without a prefix or separators. The forms
+// 0x600a0b80..., naa.600a0b80..., wwn-0x600a0b80..., and 60:0a:0b:80... are
+// accepted.
+func NormalizeWWN(wwn string) string {
+	wwn = strings.ToLower(strings.TrimSpace(wwn))
+	for _, p := range []string{"wwn-", "naa.", "0x"} {
+		wwn = strings.TrimPrefix(wwn, p)
+	}
+	return strings.Replace(wwn, ":", "", -1)
+}
+
+// parseByIDWWN returns the WWN named by a link in /dev/disk/by-id, ex.
+// wwn-0x600a0b800012345600000000deadbeef. The returned flag is false if the
+// link does not name a WWN or names a partition.
+func parseByIDWWN(name string) (string, bool) {
+	if !strings.HasPrefix(name, "wwn-0x") || strings.Contains(name, "-part") {
+		return "", false
+	}
+	return NormalizeWWN(name), true
+}
+
+// FCExecutor discovers the LUNs presented to a node over Fibre Channel. A
+// driver for an array that presents LUNs over Fibre Channel may use it as its
+// executor, or embed it. The node's instance ID is the comma-separated list
+// of the WWPNs of its HBA ports, which the driver uses to map LUNs to the
+// node. Local devices are keyed by the normalized WWN of their LUN, so a
+// driver's attach token and the device names of its attachments are looked
+// up by the LUN's WWN.
+type FCExecutor struct {
+
+	// Driver is the name of the driver whose executor this is.
+	Driver string
+
+	// Multipath reports a LUN's multipath device instead of one of its paths
+	// when there is one.
+	Multipath bool
+
+	// MultipathTimeout is how long a deep scan waits for multipathd to
+	// coalesce the paths of new LUNs when Multipath is set.
+	MultipathTimeout time.Duration
+}
+
+// InstanceID returns the WWPNs of the node's Fibre Channel ports.
+func (e *FCExecutor) InstanceID(
+	ctx apitypes.Context,
+	opts apitypes.Store) (*apitypes.InstanceID, error) {
+
+	wwpns, err := PortWWNs()
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(wwpns)
+	return &apitypes.InstanceID{
+		ID:     strings.Join(wwpns, ","),
+		Driver: e.Driver,
+	}, nil
+}
+
+// NextDevice is not implemented because the SCSI subsystem chooses the names
+// of the devices of new LUNs.
+func (e *FCExecutor) NextDevice(
+	ctx apitypes.Context,
+	opts apitypes.Store) (string, error) {
+
+	return "", apitypes.ErrNotImplemented
+}
+
+// LocalDevices returns the devices of the LUNs presented to the node. A deep
+// scan first issues a LIP on each port and rescans the SCSI hosts so that
+// newly mapped LUNs appear, and then waits for their multipath devices.
+func (e *FCExecutor) LocalDevices(
+	ctx apitypes.Context,
+	opts *apitypes.LocalDevicesOpts) (*apitypes.LocalDevices, error) {
+
+	if opts.ScanType == apitypes.DeviceScanDeep {
+		if err := RescanFC(ctx); err != nil {
+			return nil, err
+		}
+		if e.Multipath {
+			SettleMultipath(ctx, e.MultipathTimeout)
+		}
+	}
+	devs, err := WWNDevices(e.Multipath)
+	if err != nil {
+		return nil, err
+	}
+	return &apitypes.LocalDevices{Driver: e.Driver, DeviceMap: devs}, nil
+}
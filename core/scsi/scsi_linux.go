@@ -0,0 +1,209 @@
+package scsi
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/akutz/goof"
+	apitypes "github.com/emccode/libstorage/api/types"
+)
+
+const (
+	byIDDir    = "/dev/disk/by-id"
+	fcHostDir  = "/sys/class/fc_host"
+	scsiHost   = "/sys/class/scsi_host"
+	sysBlock   = "/sys/block"
+	mapperDir  = "/dev/mapper"
+	fcPortPart = "/rport-"
+
+	// iscsiSessionPart is part of the sysfs path of a SCSI disk that is
+	// reached through an iSCSI session.
+	iscsiSessionPart = "/session"
+)
+
+// Holders returns the names of the device-mapper devices that hold a device,
+// ex. the multipath device of one of a LUN's paths. The device is named as
+// in /sys/block, ex. sdb.
+func Holders(dev string) []string {
+	infos, err := ioutil.ReadDir(filepath.Join(sysBlock, dev, "holders"))
+	if err != nil {
+		return nil
+	}
+	names := []string{}
+	for _, fi := range infos {
+		if name, ok := dmName(fi.Name()); ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func dmName(dev string) (string, bool) {
+	name, err := ioutil.ReadFile(filepath.Join(sysBlock, dev, "dm", "name"))
+	if err != nil {
+		return "", false
+	}
+	return strings.TrimSpace(string(name)), true
+}
+
+// DevicePath returns the path of a LUN's device given the path of one of its
+// paths, ex. /dev/sdb. If multipath is set and the path is held by a
+// multipath device, the multipath device's path is returned.
+func DevicePath(device string, multipath bool) string {
+	if multipath {
+		if h := Holders(filepath.Base(device)); len(h) > 0 {
+			return filepath.Join(mapperDir, h[0])
+		}
+	}
+	return device
+}
+
+// RemoveDevice removes a SCSI device whose LUN is no longer presented to the
+// node. The multipath devices that hold it are flushed first.
+func RemoveDevice(device string) error {
+	dev := filepath.Base(device)
+	for _, h := range Holders(dev) {
+		exec.Command("multipath", "-f", h).Run()
+	}
+	if err := ioutil.WriteFile(filepath.Join(
+		sysBlock, dev, "device", "delete"), []byte("1"), 0200); err != nil {
+		return goof.WithFieldE("device", device, "error removing device", err)
+	}
+	return nil
+}
+
+// PortWWNs returns the normalized WWPNs of the node's Fibre Channel ports.
+func PortWWNs() ([]string, error) {
+	hosts, err := ioutil.ReadDir(fcHostDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	wwpns := []string{}
+	for _, h := range hosts {
+		buf, err := ioutil.ReadFile(
+			filepath.Join(fcHostDir, h.Name(), "port_name"))
+		if err != nil {
+			continue
+		}
+		wwpns = append(wwpns, NormalizeWWN(string(buf)))
+	}
+	if len(wwpns) == 0 {
+		return nil, goof.New("no fibre channel ports")
+	}
+	return wwpns, nil
+}
+
+// RescanFC issues a LIP on each of the node's Fibre Channel ports so that
+// the fabric is logged in to again, and then scans each port's SCSI host for
+// new LUNs.
+func RescanFC(ctx apitypes.Context) error {
+	hosts, err := ioutil.ReadDir(fcHostDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for _, h := range hosts {
+		f := ctx.WithField("host", h.Name())
+		if err := ioutil.WriteFile(filepath.Join(
+			fcHostDir, h.Name(), "issue_lip"), []byte("1"), 0200); err != nil {
+			f.WithError(err).Warn("error issuing lip")
+		}
+		if err := ioutil.WriteFile(filepath.Join(
+			scsiHost, h.Name(), "scan"), []byte("- - -"), 0200); err != nil {
+			return goof.WithFieldE("host", h.Name(),
+				"error scanning scsi host", err)
+		}
+		f.Debug("rescanned fibre channel host")
+	}
+	return nil
+}
+
+// isFC returns a flag indicating whether a SCSI disk, or any path of a
+// multipath device, is reached over Fibre Channel.
+func isFC(dev string) bool {
+	return reachedThrough(dev, fcPortPart)
+}
+
+// isISCSI returns a flag indicating whether a SCSI disk, or any path of a
+// multipath device, is reached through an iSCSI session.
+func isISCSI(dev string) bool {
+	return reachedThrough(dev, iscsiSessionPart)
+}
+
+// reachedThrough returns a flag indicating whether the sysfs path of a SCSI
+// disk, or of any path of a multipath device, contains the part that names
+// its transport.
+func reachedThrough(dev, part string) bool {
+	if strings.HasPrefix(dev, "dm-") {
+		slaves, _ := ioutil.ReadDir(filepath.Join(sysBlock, dev, "slaves"))
+		for _, s := range slaves {
+			if reachedThrough(s.Name(), part) {
+				return true
+			}
+		}
+		return false
+	}
+	path, err := filepath.EvalSymlinks(filepath.Join(sysBlock, dev))
+	return err == nil && strings.Contains(path, part)
+}
+
+// WWNDevices returns the devices of the LUNs presented to the node over
+// Fibre Channel keyed by their normalized WWN. If multipath is set a LUN's
+// device is its multipath device when it has one.
+func WWNDevices(multipath bool) (map[string]string, error) {
+	return wwnDevices(multipath, isFC)
+}
+
+// ISCSIWWNDevices returns the devices of the LUNs presented to the node
+// through iSCSI sessions keyed by their normalized WWN, as WWNDevices does
+// for Fibre Channel.
+func ISCSIWWNDevices(multipath bool) (map[string]string, error) {
+	return wwnDevices(multipath, isISCSI)
+}
+
+func wwnDevices(
+	multipath bool, transport func(string) bool) (map[string]string, error) {
+
+	infos, err := ioutil.ReadDir(byIDDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return map[string]string{}, nil
+		}
+		return nil, err
+	}
+	devs := map[string]string{}
+	for _, fi := range infos {
+		wwn, ok := parseByIDWWN(fi.Name())
+		if !ok {
+			continue
+		}
+		device, err := filepath.EvalSymlinks(filepath.Join(byIDDir, fi.Name()))
+		if err != nil {
+			continue
+		}
+		dev := filepath.Base(device)
+		if !transport(dev) {
+			continue
+		}
+		if strings.HasPrefix(dev, "dm-") {
+			if name, ok := dmName(dev); ok && multipath {
+				devs[wwn] = filepath.Join(mapperDir, name)
+				continue
+			}
+			// the link was claimed by the multipath device, so report
+			// one of its paths instead
+			slaves, _ := ioutil.ReadDir(filepath.Join(sysBlock, dev, "slaves"))
+			if len(slaves) == 0 {
+				continue
+			}
+			device = filepath.Join("/dev", slaves[0].Name())
+		}
+		devs[wwn] = DevicePath(device, multipath)
+	}
+	return devs, nil
+}
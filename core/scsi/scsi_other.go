@@ -0,0 +1,63 @@
+// +build !linux
+
+package scsi
+
+import (
+	"time"
+
+	"github.com/akutz/goof"
+	apitypes "github.com/emccode/libstorage/api/types"
+)
+
+var errNotSupported = goof.New("scsi devices are only discovered on Linux")
+
+// Holders returns nil on operating systems other than Linux.
+func Holders(dev string) []string {
+	return nil
+}
+
+// DevicePath returns the device on operating systems other than Linux.
+func DevicePath(device string, multipath bool) string {
+	return device
+}
+
+// RemoveDevice is not supported on operating systems other than Linux.
+func RemoveDevice(device string) error {
+	return errNotSupported
+}
+
+// PortWWNs is not supported on operating systems other than Linux.
+func PortWWNs() ([]string, error) {
+	return nil, errNotSupported
+}
+
+// RescanFC is not supported on operating systems other than Linux.
+func RescanFC(ctx apitypes.Context) error {
+	return errNotSupported
+}
+
+// WWNDevices returns no devices on operating systems other than Linux.
+func WWNDevices(multipath bool) (map[string]string, error) {
+	return map[string]string{}, nil
+}
+
+// ISCSIWWNDevices returns no devices on operating systems other than Linux.
+func ISCSIWWNDevices(multipath bool) (map[string]string, error) {
+	return map[string]string{}, nil
+}
+
+// MultipathActive returns false on operating systems other than Linux.
+func MultipathActive() bool {
+	return false
+}
+
+// ResolveMultipath returns the device on operating systems other than Linux.
+func ResolveMultipath(
+	ctx apitypes.Context, device string, timeout time.Duration) string {
+
+	return device
+}
+
+// SettleMultipath does nothing on operating systems other than Linux.
+func SettleMultipath(ctx apitypes.Context, timeout time.Duration) {
+}
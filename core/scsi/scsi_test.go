@@ -0,0 +1,29 @@
+package scsi
+
+import "testing"
+
+func TestNormalizeWWN(t *testing.T) {
+	for _, wwn := range []string{
+		"0x600A0B800012345600000000DEADBEEF",
+		"naa.600a0b800012345600000000deadbeef",
+		"wwn-0x600a0b800012345600000000deadbeef",
+		"60:0a:0b:80:00:12:34:56:00:00:00:00:de:ad:be:ef\n",
+	} {
+		if n := NormalizeWWN(wwn); n != "600a0b800012345600000000deadbeef" {
+			t.Errorf("NormalizeWWN(%q)=%s", wwn, n)
+		}
+	}
+}
+
+func TestParseByIDWWN(t *testing.T) {
+	if wwn, ok := parseByIDWWN("wwn-0x5000c500a1b2c3d4"); !ok ||
+		wwn != "5000c500a1b2c3d4" {
+		t.Fatalf("wwn=%s ok=%v", wwn, ok)
+	}
+	if _, ok := parseByIDWWN("wwn-0x5000c500a1b2c3d4-part1"); ok {
+		t.Fatal("expected partition to be ignored")
+	}
+	if _, ok := parseByIDWWN("scsi-35000c500a1b2c3d4"); ok {
+		t.Fatal("expected non-wwn link to be ignored")
+	}
+}
@@ -0,0 +1,45 @@
+// Package sharedfs mounts the volumes of storage drivers whose volumes are
+// shared file systems rather than block devices, such as CephFS. The
+// integration driver only mounts devices, so the volumes of such drivers are
+// mounted by the functions registered here instead.
+package sharedfs
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/akutz/gofig"
+	apitypes "github.com/emccode/libstorage/api/types"
+)
+
+// Func mounts the file system of a volume that is attached to the local
+// instance at the provided path. The token is the one returned by the
+// volume's driver when the volume was attached.
+type Func func(
+	ctx apitypes.Context,
+	config gofig.Config,
+	vol *apitypes.Volume,
+	token, mountPath string,
+	readOnly bool) error
+
+var (
+	funcs    = map[string]Func{}
+	funcsRwl sync.RWMutex
+)
+
+// Register registers the function that mounts the volumes of the storage
+// driver with the provided name.
+func Register(driverName string, f Func) {
+	funcsRwl.Lock()
+	defer funcsRwl.Unlock()
+	funcs[strings.ToLower(driverName)] = f
+}
+
+// Lookup returns the function that mounts the volumes of the storage driver
+// with the provided name, if the driver's volumes are shared file systems.
+func Lookup(driverName string) (Func, bool) {
+	funcsRwl.RLock()
+	defer funcsRwl.RUnlock()
+	f, ok := funcs[strings.ToLower(driverName)]
+	return f, ok
+}
@@ -0,0 +1,210 @@
+package simulate
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/emccode/rexray/core/errcodes"
+)
+
+// Distribution is the name of a latency distribution.
+type Distribution string
+
+const (
+	// Fixed latencies are always the mean.
+	Fixed Distribution = "fixed"
+
+	// Uniform latencies are drawn uniformly between the minimum and maximum.
+	Uniform Distribution = "uniform"
+
+	// Normal latencies are drawn from a normal distribution with the mean and
+	// standard deviation.
+	Normal Distribution = "normal"
+
+	// Exponential latencies are drawn from an exponential distribution with
+	// the mean, which models the long tail of a busy storage platform.
+	Exponential Distribution = "exponential"
+)
+
+// Latency describes the distribution from which simulated latencies are
+// drawn. Drawn latencies are bounded by the minimum and, if it is not zero,
+// the maximum.
+type Latency struct {
+	Distribution Distribution  `json:"distribution"`
+	Mean         time.Duration `json:"mean"`
+	StdDev       time.Duration `json:"stdDev"`
+	Min          time.Duration `json:"min"`
+	Max          time.Duration `json:"max"`
+}
+
+// Sample draws a latency from the distribution.
+func (l *Latency) Sample(r *rand.Rand) time.Duration {
+	var d time.Duration
+	switch l.Distribution {
+	case Uniform:
+		d = l.Min
+		if l.Max > l.Min {
+			d += time.Duration(r.Int63n(int64(l.Max-l.Min) + 1))
+		}
+	case Normal:
+		d = l.Mean + time.Duration(r.NormFloat64()*float64(l.StdDev))
+	case Exponential:
+		d = time.Duration(r.ExpFloat64() * float64(l.Mean))
+	default:
+		d = l.Mean
+	}
+	if d < l.Min {
+		d = l.Min
+	}
+	if l.Max > 0 && d > l.Max {
+		d = l.Max
+	}
+	if d < 0 {
+		d = 0
+	}
+	return d
+}
+
+// Error is returned by an operation that failed by simulation.
+type Error struct {
+
+	// Operation is the operation that failed.
+	Operation string
+
+	// Exhausted is true if the operation failed because the service's
+	// capacity or volume count was exhausted.
+	Exhausted bool
+}
+
+func (e *Error) Error() string {
+	if e.Exhausted {
+		return fmt.Sprintf("%s failed: simulated capacity exhausted",
+			e.Operation)
+	}
+	return fmt.Sprintf("%s failed: simulated failure", e.Operation)
+}
+
+// Status returns the HTTP status code with which the error is reported.
+func (e *Error) Status() int {
+	if e.Exhausted {
+		return http.StatusInsufficientStorage
+	}
+	return http.StatusServiceUnavailable
+}
+
+// Code returns the code of the error.
+func (e *Error) Code() errcodes.Code {
+	if e.Exhausted {
+		return errcodes.CapacityExhausted
+	}
+	return errcodes.BackendUnavailable
+}
+
+// Profile describes the simulated behavior of a service: the latency of its
+// operations, the fraction of them that fail, and its capacity.
+type Profile struct {
+	Latency Latency `json:"latency"`
+
+	// FailureRate is the fraction of operations, between 0 and 1, that fail.
+	FailureRate float64 `json:"failureRate"`
+
+	// Capacity is the total size of the volumes that may be created, in GiB.
+	// Zero is unlimited.
+	Capacity int64 `json:"capacity"`
+
+	// MaxVolumes is the number of volumes that may be created. Zero is
+	// unlimited.
+	MaxVolumes int `json:"maxVolumes"`
+
+	mu      sync.Mutex
+	rnd     *rand.Rand
+	used    int64
+	count   int
+	volumes map[string]int64
+}
+
+// NewProfile returns a new profile.
+func NewProfile(
+	l Latency, failureRate float64, capacity int64, maxVolumes int) *Profile {
+
+	return &Profile{
+		Latency:     l,
+		FailureRate: failureRate,
+		Capacity:    capacity,
+		MaxVolumes:  maxVolumes,
+		rnd:         rand.New(rand.NewSource(time.Now().UnixNano())),
+		volumes:     map[string]int64{},
+	}
+}
+
+// Do sleeps for a latency drawn from the profile's distribution and then
+// returns an error if the operation should fail.
+func (p *Profile) Do(op string) error {
+	p.mu.Lock()
+	d := p.Latency.Sample(p.rnd)
+	fail := p.FailureRate > 0 && p.rnd.Float64() < p.FailureRate
+	p.mu.Unlock()
+
+	time.Sleep(d)
+	if fail {
+		return &Error{Operation: op}
+	}
+	return nil
+}
+
+// Reserve reserves capacity for a new volume of the provided size. The
+// reservation is returned with Unreserve if the volume is not created, or
+// recorded with Track if it is.
+func (p *Profile) Reserve(op string, size int64) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if (p.Capacity > 0 && p.used+size > p.Capacity) ||
+		(p.MaxVolumes > 0 && p.count >= p.MaxVolumes) {
+		return &Error{Operation: op, Exhausted: true}
+	}
+	p.used += size
+	p.count++
+	return nil
+}
+
+// Unreserve returns a reservation for a volume that was not created.
+func (p *Profile) Unreserve(size int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.used -= size
+	p.count--
+}
+
+// Track records the size of a created volume so that its capacity is
+// returned when it is removed.
+func (p *Profile) Track(volumeID string, size int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.volumes[volumeID] = size
+}
+
+// Release returns the capacity of a removed volume. Volumes created before
+// the profile was loaded are not tracked and are ignored.
+func (p *Profile) Release(volumeID string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	size, ok := p.volumes[volumeID]
+	if !ok {
+		return
+	}
+	delete(p.volumes, volumeID)
+	p.used -= size
+	p.count--
+}
+
+// Usage returns the total size and number of the volumes created under the
+// profile.
+func (p *Profile) Usage() (int64, int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.used, p.count
+}
@@ -0,0 +1,125 @@
+package simulate
+
+import (
+	"math/rand"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLatencySampleBounds(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, l := range []Latency{
+		{Distribution: Fixed, Mean: 5 * time.Millisecond},
+		{Distribution: Uniform, Min: time.Millisecond, Max: 3 * time.Millisecond},
+		{Distribution: Normal, Mean: 2 * time.Millisecond,
+			StdDev: 5 * time.Millisecond, Max: 4 * time.Millisecond},
+		{Distribution: Exponential, Mean: time.Millisecond,
+			Min: 100 * time.Microsecond, Max: 10 * time.Millisecond},
+	} {
+		for i := 0; i < 1000; i++ {
+			d := l.Sample(r)
+			if d < l.Min || d < 0 || (l.Max > 0 && d > l.Max) {
+				t.Fatalf("%s: sample %v out of bounds", l.Distribution, d)
+			}
+		}
+	}
+}
+
+func TestProfileCapacity(t *testing.T) {
+	p := NewProfile(Latency{}, 0, 10, 2)
+
+	if err := p.Reserve("create", 6); err != nil {
+		t.Fatal(err)
+	}
+	p.Track("a", 6)
+
+	if err := p.Reserve("create", 6); err == nil {
+		t.Fatal("expected capacity to be exhausted")
+	} else if !err.(*Error).Exhausted {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := p.Reserve("create", 4); err != nil {
+		t.Fatal(err)
+	}
+	p.Track("b", 4)
+
+	if err := p.Reserve("create", 0); err == nil {
+		t.Fatal("expected volume count to be exhausted")
+	}
+
+	p.Release("a")
+	p.Release("unknown")
+	if used, count := p.Usage(); used != 4 || count != 1 {
+		t.Fatalf("unexpected usage: %d GiB, %d volumes", used, count)
+	}
+}
+
+func TestProfileFailureRate(t *testing.T) {
+	if err := NewProfile(Latency{}, 0, 0, 0).Do("op"); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewProfile(Latency{}, 1, 0, 0).Do("op"); err == nil {
+		t.Fatal("expected a simulated failure")
+	}
+}
+
+func BenchmarkLatencySample(b *testing.B) {
+	r := rand.New(rand.NewSource(1))
+	for _, dist := range []Distribution{Fixed, Uniform, Normal, Exponential} {
+		l := &Latency{
+			Distribution: dist,
+			Mean:         time.Millisecond,
+			StdDev:       time.Millisecond,
+			Max:          10 * time.Millisecond,
+		}
+		b.Run(string(dist), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				l.Sample(r)
+			}
+		})
+	}
+}
+
+// BenchmarkCreateRemove models concurrent clients creating and removing
+// volumes on a service with a latency of about a millisecond, a 1% failure
+// rate, and room for 100 volumes.
+func BenchmarkCreateRemove(b *testing.B) {
+	p := NewProfile(Latency{
+		Distribution: Exponential,
+		Mean:         time.Millisecond,
+		Max:          10 * time.Millisecond,
+	}, 0.01, 1000, 100)
+
+	var (
+		seq       int64
+		failed    int64
+		exhausted int64
+	)
+
+	b.SetParallelism(50)
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if err := p.Reserve("create", 10); err != nil {
+				atomic.AddInt64(&exhausted, 1)
+				continue
+			}
+			if err := p.Do("create"); err != nil {
+				p.Unreserve(10)
+				atomic.AddInt64(&failed, 1)
+				continue
+			}
+			id := strconv.FormatInt(atomic.AddInt64(&seq, 1), 10)
+			p.Track(id, 10)
+			if err := p.Do("remove"); err != nil {
+				atomic.AddInt64(&failed, 1)
+			}
+			p.Release(id)
+		}
+	})
+
+	b.ReportMetric(float64(failed)/float64(b.N), "failures/op")
+	b.ReportMetric(float64(exhausted)/float64(b.N), "exhausted/op")
+}
@@ -0,0 +1,223 @@
+// Package simulate adds simulated latency, failures, and capacity limits to
+// the libStorage services used with REX-Ray. It allows the behavior of an
+// orchestrator and REX-Ray to be load tested against mock services, such as
+// the vfs driver, as if they were real storage platforms.
+package simulate
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/akutz/gofig"
+	apitypes "github.com/emccode/libstorage/api/types"
+)
+
+const servicesKey = "rexray.simulate.services"
+
+var (
+	profiles    = map[string]*Profile{}
+	profilesRwl sync.Mutex
+)
+
+func init() {
+	r := gofig.NewRegistration("Simulation")
+	r.Key(gofig.Bool, "", false,
+		"Simulate the latency, failures, and capacity defined for services "+
+			"in rexray.simulate.services",
+		"rexray.simulate.enabled")
+	gofig.Register(r)
+}
+
+// Load returns the profile defined for the service with the provided name.
+// Profiles are loaded once per process so that capacity is shared by every
+// client of a service. The returned flag is false if simulation is disabled
+// or no profile is defined for the service.
+func Load(config gofig.Config, service string) (*Profile, bool) {
+	if service == "" || !config.GetBool("rexray.simulate.enabled") {
+		return nil, false
+	}
+	prefix := servicesKey + "." + service
+	if !config.IsSet(prefix) {
+		return nil, false
+	}
+
+	profilesRwl.Lock()
+	defer profilesRwl.Unlock()
+
+	if p, ok := profiles[service]; ok {
+		return p, true
+	}
+
+	prefix += "."
+	l := Latency{
+		Distribution: Distribution(
+			config.GetString(prefix + "latency.distribution")),
+		Mean:   duration(config, prefix+"latency.mean"),
+		StdDev: duration(config, prefix+"latency.stdDev"),
+		Min:    duration(config, prefix+"latency.min"),
+		Max:    duration(config, prefix+"latency.max"),
+	}
+	failureRate, _ := strconv.ParseFloat(
+		config.GetString(prefix+"failureRate"), 64)
+
+	p := NewProfile(
+		l,
+		failureRate,
+		int64(config.GetInt(prefix+"capacity")),
+		config.GetInt(prefix+"maxVolumes"))
+	profiles[service] = p
+	return p, true
+}
+
+func duration(config gofig.Config, key string) time.Duration {
+	d, _ := time.ParseDuration(config.GetString(key))
+	return d
+}
+
+// Wrap returns a libStorage client whose operations are subject to the
+// profile of the configured service. The client is returned as is if no
+// profile applies.
+func Wrap(config gofig.Config, c apitypes.Client) apitypes.Client {
+	p, ok := Load(config, config.GetString(apitypes.ConfigService))
+	if !ok {
+		return c
+	}
+	return WrapProfile(c, p)
+}
+
+// WrapProfile returns a libStorage client whose operations are subject to
+// the provided profile.
+func WrapProfile(c apitypes.Client, p *Profile) apitypes.Client {
+	return &client{Client: c, p: p}
+}
+
+type client struct {
+	apitypes.Client
+	p *Profile
+}
+
+func (c *client) Storage() apitypes.StorageDriver {
+	return &storageDriver{StorageDriver: c.Client.Storage(), p: c.p}
+}
+
+func (c *client) Integration() apitypes.IntegrationDriver {
+	return &integrationDriver{IntegrationDriver: c.Client.Integration(), p: c.p}
+}
+
+type storageDriver struct {
+	apitypes.StorageDriver
+	p *Profile
+}
+
+func (d *storageDriver) Volumes(
+	ctx apitypes.Context,
+	opts *apitypes.VolumesOpts) ([]*apitypes.Volume, error) {
+
+	if err := d.p.Do("volumes"); err != nil {
+		return nil, err
+	}
+	return d.StorageDriver.Volumes(ctx, opts)
+}
+
+func (d *storageDriver) VolumeInspect(
+	ctx apitypes.Context,
+	volumeID string,
+	opts *apitypes.VolumeInspectOpts) (*apitypes.Volume, error) {
+
+	if err := d.p.Do("volume inspect"); err != nil {
+		return nil, err
+	}
+	return d.StorageDriver.VolumeInspect(ctx, volumeID, opts)
+}
+
+func (d *storageDriver) VolumeCreate(
+	ctx apitypes.Context,
+	volumeName string,
+	opts *apitypes.VolumeCreateOpts) (*apitypes.Volume, error) {
+
+	var size int64
+	if opts != nil && opts.Size != nil {
+		size = *opts.Size
+	}
+
+	if err := d.p.Reserve("volume create", size); err != nil {
+		return nil, err
+	}
+	if err := d.p.Do("volume create"); err != nil {
+		d.p.Unreserve(size)
+		return nil, err
+	}
+
+	vol, err := d.StorageDriver.VolumeCreate(ctx, volumeName, opts)
+	if err != nil {
+		d.p.Unreserve(size)
+		return nil, err
+	}
+	d.p.Track(vol.ID, size)
+	return vol, nil
+}
+
+func (d *storageDriver) VolumeRemove(
+	ctx apitypes.Context,
+	volumeID string,
+	opts apitypes.Store) error {
+
+	if err := d.p.Do("volume remove"); err != nil {
+		return err
+	}
+	if err := d.StorageDriver.VolumeRemove(ctx, volumeID, opts); err != nil {
+		return err
+	}
+	d.p.Release(volumeID)
+	return nil
+}
+
+func (d *storageDriver) VolumeAttach(
+	ctx apitypes.Context,
+	volumeID string,
+	opts *apitypes.VolumeAttachOpts) (*apitypes.Volume, string, error) {
+
+	if err := d.p.Do("volume attach"); err != nil {
+		return nil, "", err
+	}
+	return d.StorageDriver.VolumeAttach(ctx, volumeID, opts)
+}
+
+func (d *storageDriver) VolumeDetach(
+	ctx apitypes.Context,
+	volumeID string,
+	opts *apitypes.VolumeDetachOpts) (*apitypes.Volume, error) {
+
+	if err := d.p.Do("volume detach"); err != nil {
+		return nil, err
+	}
+	return d.StorageDriver.VolumeDetach(ctx, volumeID, opts)
+}
+
+type integrationDriver struct {
+	apitypes.IntegrationDriver
+	p *Profile
+}
+
+func (d *integrationDriver) Mount(
+	ctx apitypes.Context,
+	volumeID, volumeName string,
+	opts *apitypes.VolumeMountOpts) (string, *apitypes.Volume, error) {
+
+	if err := d.p.Do("volume mount"); err != nil {
+		return "", nil, err
+	}
+	return d.IntegrationDriver.Mount(ctx, volumeID, volumeName, opts)
+}
+
+func (d *integrationDriver) Unmount(
+	ctx apitypes.Context,
+	volumeID, volumeName string,
+	opts apitypes.Store) error {
+
+	if err := d.p.Do("volume unmount"); err != nil {
+		return err
+	}
+	return d.IntegrationDriver.Unmount(ctx, volumeID, volumeName, opts)
+}
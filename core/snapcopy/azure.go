@@ -0,0 +1,200 @@
+package snapcopy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	apitypes "github.com/emccode/libstorage/api/types"
+)
+
+const (
+	azureManagementURL = "https://management.azure.com"
+	azureLoginURL      = "https://login.microsoftonline.com/"
+	azureAPIVersion    = "2021-12-01"
+)
+
+func init() {
+	Register("azureud", newAzureCopier)
+}
+
+// azureCopier copies Azure managed disk snapshots with the CopyStart create
+// option, which copies an incremental snapshot to another region in the
+// background. The copy is created in the service's resource group and is
+// encrypted with the disk encryption set of the request, if any.
+type azureCopier struct {
+	config         gofig.Config
+	subscriptionID string
+	resourceGroup  string
+	client         *http.Client
+	token          string
+}
+
+func newAzureCopier(config gofig.Config) (Copier, error) {
+	a := &azureCopier{
+		config:         config,
+		subscriptionID: config.GetString("azureud.subscriptionID"),
+		resourceGroup:  config.GetString("azureud.resourceGroup"),
+		client:         &http.Client{Timeout: time.Minute},
+	}
+	if a.subscriptionID == "" || a.resourceGroup == "" {
+		return nil, goof.New("copying azure snapshots requires " +
+			"azureud.subscriptionID and azureud.resourceGroup")
+	}
+	return a, nil
+}
+
+// login exchanges the service principal's credentials for a token.
+func (a *azureCopier) login() error {
+	if a.token != "" {
+		return nil
+	}
+	res, err := a.client.PostForm(
+		azureLoginURL+a.config.GetString("azureud.tenantID")+"/oauth2/token",
+		url.Values{
+			"grant_type":    {"client_credentials"},
+			"client_id":     {a.config.GetString("azureud.clientID")},
+			"client_secret": {a.config.GetString("azureud.clientSecret")},
+			"resource":      {azureManagementURL + "/"},
+		})
+	if err != nil {
+		return goof.WithFieldE(
+			"tenantID", a.config.GetString("azureud.tenantID"),
+			"error logging in to azure", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return goof.WithField("status", res.Status, "error logging in to azure")
+	}
+	t := struct {
+		AccessToken string `json:"access_token"`
+	}{}
+	if err := json.NewDecoder(res.Body).Decode(&t); err != nil {
+		return err
+	}
+	a.token = t.AccessToken
+	return nil
+}
+
+func (a *azureCopier) do(method, id string, body, result interface{}) error {
+	if err := a.login(); err != nil {
+		return err
+	}
+	var buf []byte
+	if body != nil {
+		var err error
+		if buf, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequest(method, fmt.Sprintf("%s%s?api-version=%s",
+		azureManagementURL, id, azureAPIVersion), bytes.NewReader(buf))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+a.token)
+
+	res, err := a.client.Do(req)
+	if err != nil {
+		return goof.WithFieldE("id", id, "error calling azure", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		msg, _ := ioutil.ReadAll(res.Body)
+		return goof.WithFields(map[string]interface{}{
+			"id":     id,
+			"status": res.Status,
+			"body":   strings.TrimSpace(string(msg)),
+		}, "error calling azure")
+	}
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(result)
+}
+
+type azureSnapshot struct {
+	Location   string `json:"location"`
+	Properties struct {
+		ProvisioningState string  `json:"provisioningState"`
+		CompletionPercent float64 `json:"completionPercent"`
+	} `json:"properties"`
+}
+
+// snapshotID returns the resource ID of a snapshot. The IDs of snapshots
+// may be their resource IDs or their names in the service's resource group.
+func (a *azureCopier) snapshotID(idOrName string) string {
+	if strings.HasPrefix(idOrName, "/subscriptions/") {
+		return idOrName
+	}
+	return fmt.Sprintf(
+		"/subscriptions/%s/resourceGroups/%s/providers/"+
+			"Microsoft.Compute/snapshots/%s",
+		a.subscriptionID, a.resourceGroup, idOrName)
+}
+
+func (a *azureCopier) Start(
+	ctx apitypes.Context, req *Request) (*Copy, error) {
+
+	srcID := a.snapshotID(req.Snapshot.ID)
+	src := &azureSnapshot{}
+	if err := a.do("GET", srcID, nil, src); err != nil {
+		return nil, err
+	}
+
+	name := req.Name
+	if name == "" {
+		name = path.Base(srcID)
+	}
+	id := a.snapshotID(fmt.Sprintf("%s-%s", name, req.Region))
+
+	props := map[string]interface{}{
+		"creationData": map[string]string{
+			"createOption":     "CopyStart",
+			"sourceResourceId": srcID,
+		},
+		"incremental": true,
+	}
+	if req.EncryptionKey != "" {
+		props["encryption"] = map[string]string{
+			"type":                "EncryptionAtRestWithCustomerKey",
+			"diskEncryptionSetId": req.EncryptionKey,
+		}
+	}
+	body := map[string]interface{}{
+		"location":   req.Region,
+		"tags":       Tags(req, src.Location),
+		"properties": props,
+	}
+	if err := a.do("PUT", id, body, nil); err != nil {
+		return nil, err
+	}
+	return &Copy{ID: id, SourceRegion: src.Location}, nil
+}
+
+func (a *azureCopier) Refresh(ctx apitypes.Context, c *Copy) error {
+	s := &azureSnapshot{}
+	if err := a.do("GET", c.ID, nil, s); err != nil {
+		return err
+	}
+	c.Progress = int(s.Properties.CompletionPercent)
+	switch s.Properties.ProvisioningState {
+	case "Failed":
+		c.State = Failed
+		c.Error = "snapshot copy failed"
+	case "Succeeded":
+		if c.Progress >= 100 {
+			c.State = Completed
+		}
+	}
+	return nil
+}
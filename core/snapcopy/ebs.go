@@ -0,0 +1,144 @@
+package snapcopy
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/akutz/gofig"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/core/ebs"
+)
+
+func init() {
+	Register("ebs", newEBSCopier)
+	Register("ec2", newEBSCopier)
+}
+
+// ebsCopier copies EBS snapshots with CopySnapshot, which is invoked in the
+// destination region. A copy is encrypted with the KMS key of the request,
+// or with the destination region's default EBS key if the source is
+// encrypted.
+type ebsCopier struct {
+	config gofig.Config
+}
+
+func newEBSCopier(config gofig.Config) (Copier, error) {
+	return &ebsCopier{config: config}, nil
+}
+
+func (e *ebsCopier) client(region string) (*ec2.EC2, error) {
+	c := aws.NewConfig().WithRegion(region)
+	if ak := e.config.GetString("ebs.accessKey"); ak != "" {
+		c = c.WithCredentials(credentials.NewStaticCredentials(
+			ak,
+			e.config.GetString("ebs.secretKey"),
+			e.config.GetString("ebs.sessionToken")))
+	}
+	sess, err := session.NewSession(c)
+	if err != nil {
+		return nil, err
+	}
+	return ec2.New(sess), nil
+}
+
+// sourceRegion returns the service's region, or if it is not configured, the
+// region of the instance.
+func (e *ebsCopier) sourceRegion() (string, error) {
+	if r := e.config.GetString("ebs.region"); r != "" {
+		return r, nil
+	}
+	id, err := ebs.NewMetadataFromConfig(e.config).Identity()
+	if err != nil {
+		return "", err
+	}
+	return id.Region, nil
+}
+
+func (e *ebsCopier) Start(
+	ctx apitypes.Context, req *Request) (*Copy, error) {
+
+	src, err := e.sourceRegion()
+	if err != nil {
+		return nil, err
+	}
+	client, err := e.client(req.Region)
+	if err != nil {
+		return nil, err
+	}
+
+	tags := Tags(req, src)
+	if req.Name != "" {
+		tags["Name"] = req.Name
+	}
+	ec2Tags := []*ec2.Tag{}
+	for k, v := range tags {
+		ec2Tags = append(ec2Tags, &ec2.Tag{Key: aws.String(k), Value: aws.String(v)})
+	}
+
+	in := &ec2.CopySnapshotInput{
+		SourceRegion:     aws.String(src),
+		SourceSnapshotId: aws.String(req.Snapshot.ID),
+		Description: aws.String(
+			"Copy of " + req.Snapshot.ID + " from " + src),
+		TagSpecifications: []*ec2.TagSpecification{{
+			ResourceType: aws.String(ec2.ResourceTypeSnapshot),
+			Tags:         ec2Tags,
+		}},
+	}
+	if req.EncryptionKey != "" {
+		in.Encrypted = aws.Bool(true)
+		in.KmsKeyId = aws.String(req.EncryptionKey)
+	}
+
+	out, err := client.CopySnapshot(in)
+	if err != nil {
+		return nil, err
+	}
+	return &Copy{
+		ID:           aws.StringValue(out.SnapshotId),
+		SourceRegion: src,
+	}, nil
+}
+
+func (e *ebsCopier) Refresh(ctx apitypes.Context, c *Copy) error {
+	client, err := e.client(c.Region)
+	if err != nil {
+		return err
+	}
+	out, err := client.DescribeSnapshots(&ec2.DescribeSnapshotsInput{
+		SnapshotIds: []*string{aws.String(c.ID)},
+	})
+	if err != nil {
+		return err
+	}
+	if len(out.Snapshots) == 0 {
+		c.State = Failed
+		c.Error = "snapshot copy not found"
+		return nil
+	}
+
+	s := out.Snapshots[0]
+	c.Progress = parsePercent(aws.StringValue(s.Progress))
+	switch aws.StringValue(s.State) {
+	case ec2.SnapshotStateCompleted:
+		c.State = Completed
+	case ec2.SnapshotStateError:
+		c.State = Failed
+		c.Error = aws.StringValue(s.StateMessage)
+	}
+	return nil
+}
+
+// parsePercent parses a percentage such as 45%.
+func parsePercent(v string) int {
+	i, err := strconv.Atoi(strings.TrimSuffix(strings.TrimSpace(v), "%"))
+	if err != nil {
+		return 0
+	}
+	return i
+}
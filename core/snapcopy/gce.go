@@ -0,0 +1,273 @@
+package snapcopy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	apitypes "github.com/emccode/libstorage/api/types"
+	"golang.org/x/oauth2/google"
+)
+
+const (
+	gceComputeURL   = "https://compute.googleapis.com/compute/v1/projects/"
+	gceMetadataURL  = "http://metadata.google.internal/computeMetadata/v1/"
+	gceComputeScope = "https://www.googleapis.com/auth/compute"
+)
+
+// The phases of a GCE copy.
+const (
+	gcePhaseDisk     = "disk"
+	gcePhaseSnapshot = "snapshot"
+)
+
+func init() {
+	Register("gcepd", newGCECopier)
+}
+
+// gceCopier copies GCE snapshots. Snapshots are global resources, so a copy
+// is a new snapshot that is stored in the destination region, and that is
+// encrypted with the key of the request if one is provided. A snapshot
+// cannot be created from another snapshot, so the source is restored to a
+// temporary disk in a zone of the destination region, the copy is taken of
+// the disk, and the disk is deleted.
+type gceCopier struct {
+	client  *http.Client
+	project string
+}
+
+func newGCECopier(config gofig.Config) (Copier, error) {
+	ctx := context.Background()
+	c := &gceCopier{}
+
+	if keyFile := config.GetString("gcepd.keyfile"); keyFile != "" {
+		buf, err := ioutil.ReadFile(keyFile)
+		if err != nil {
+			return nil, err
+		}
+		jwt, err := google.JWTConfigFromJSON(buf, gceComputeScope)
+		if err != nil {
+			return nil, err
+		}
+		key := struct {
+			ProjectID string `json:"project_id"`
+		}{}
+		if err := json.Unmarshal(buf, &key); err != nil {
+			return nil, err
+		}
+		c.client = jwt.Client(ctx)
+		c.project = key.ProjectID
+	} else {
+		client, err := google.DefaultClient(ctx, gceComputeScope)
+		if err != nil {
+			return nil, err
+		}
+		c.client = client
+	}
+
+	if c.project == "" {
+		p, err := gceMetadata("project/project-id")
+		if err != nil {
+			return nil, err
+		}
+		c.project = p
+	}
+	return c, nil
+}
+
+func gceMetadata(p string) (string, error) {
+	req, err := http.NewRequest("GET", gceMetadataURL+p, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	res, err := (&http.Client{Timeout: 2 * time.Second}).Do(req)
+	if err != nil {
+		return "", goof.WithFieldE(
+			"path", p, "error reading instance metadata", err)
+	}
+	defer res.Body.Close()
+	buf, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(buf)), nil
+}
+
+func (g *gceCopier) do(method, p string, body, result interface{}) error {
+	var buf []byte
+	if body != nil {
+		var err error
+		if buf, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequest(
+		method, gceComputeURL+g.project+"/"+p, bytes.NewReader(buf))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := g.client.Do(req)
+	if err != nil {
+		return goof.WithFieldE("path", p, "error calling gce", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		msg, _ := ioutil.ReadAll(res.Body)
+		return goof.WithFields(map[string]interface{}{
+			"path":   p,
+			"status": res.Status,
+			"body":   strings.TrimSpace(string(msg)),
+		}, "error calling gce")
+	}
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(result)
+}
+
+type gceResource struct {
+	Status string   `json:"status"`
+	Zones  []string `json:"zones,omitempty"`
+}
+
+func (g *gceCopier) Start(
+	ctx apitypes.Context, req *Request) (*Copy, error) {
+
+	region := &gceResource{}
+	if err := g.do("GET", "regions/"+req.Region, nil, region); err != nil {
+		return nil, err
+	}
+	if len(region.Zones) == 0 {
+		return nil, goof.WithField("region", req.Region, "region has no zones")
+	}
+	zone := path.Base(region.Zones[0])
+
+	name := req.Name
+	if name == "" {
+		name = req.Snapshot.ID
+	}
+	name = fmt.Sprintf("%s-%s", name, req.Region)
+	disk := name + "-copy"
+
+	d := map[string]interface{}{
+		"name": disk,
+		"sourceSnapshot": fmt.Sprintf(
+			"projects/%s/global/snapshots/%s", g.project, req.Snapshot.ID),
+	}
+	if req.EncryptionKey != "" {
+		d["diskEncryptionKey"] = map[string]string{
+			"kmsKeyName": req.EncryptionKey,
+		}
+	}
+	if err := g.do("POST", "zones/"+zone+"/disks", d, nil); err != nil {
+		return nil, err
+	}
+
+	// the labels are applied when the snapshot is taken
+	fields := map[string]string{
+		"phase": gcePhaseDisk,
+		"zone":  zone,
+		"disk":  disk,
+	}
+	if req.EncryptionKey != "" {
+		fields["kmsKeyName"] = req.EncryptionKey
+	}
+	for k, v := range Tags(req, "") {
+		fields["label."+gceLabel(k)] = gceLabel(v)
+	}
+
+	return &Copy{ID: name, Fields: fields}, nil
+}
+
+func (g *gceCopier) Refresh(ctx apitypes.Context, c *Copy) error {
+	zone, disk := c.Fields["zone"], c.Fields["disk"]
+
+	switch c.Fields["phase"] {
+	case gcePhaseDisk:
+		d := &gceResource{}
+		if err := g.do("GET", "zones/"+zone+"/disks/"+disk, nil, d); err != nil {
+			return err
+		}
+		if d.Status == "FAILED" {
+			c.State = Failed
+			c.Error = "error restoring snapshot to temporary disk"
+			return nil
+		}
+		if d.Status != "READY" {
+			c.Progress = 25
+			return nil
+		}
+
+		labels := map[string]string{}
+		for k, v := range c.Fields {
+			if strings.HasPrefix(k, "label.") {
+				labels[strings.TrimPrefix(k, "label.")] = v
+			}
+		}
+		s := map[string]interface{}{
+			"name":             c.ID,
+			"storageLocations": []string{c.Region},
+			"labels":           labels,
+		}
+		if key := c.Fields["kmsKeyName"]; key != "" {
+			s["snapshotEncryptionKey"] = map[string]string{"kmsKeyName": key}
+		}
+		if err := g.do("POST", "zones/"+zone+"/disks/"+disk+"/createSnapshot",
+			s, nil); err != nil {
+			return err
+		}
+		c.Fields["phase"] = gcePhaseSnapshot
+		c.Progress = 50
+
+	case gcePhaseSnapshot:
+		s := &gceResource{}
+		if err := g.do("GET", "global/snapshots/"+c.ID, nil, s); err != nil {
+			return err
+		}
+		switch s.Status {
+		case "READY":
+		case "FAILED":
+			c.State = Failed
+			c.Error = "error taking snapshot of temporary disk"
+			return nil
+		default:
+			c.Progress = 75
+			return nil
+		}
+		if err := g.do(
+			"DELETE", "zones/"+zone+"/disks/"+disk, nil, nil); err != nil {
+			ctx.WithError(err).WithField("disk", disk).Warn(
+				"error deleting temporary disk")
+		}
+		c.State = Completed
+	}
+	return nil
+}
+
+// gceLabel returns a value that is valid as a GCE label key or value, which
+// may only contain lower-case letters, digits, dashes, and underscores.
+func gceLabel(v string) string {
+	v = strings.ToLower(v)
+	buf := make([]byte, 0, len(v))
+	for i := 0; i < len(v) && len(buf) < 63; i++ {
+		b := v[i]
+		if (b >= 'a' && b <= 'z') || (b >= '0' && b <= '9') ||
+			b == '-' || b == '_' {
+			buf = append(buf, b)
+		} else {
+			buf = append(buf, '-')
+		}
+	}
+	return string(buf)
+}
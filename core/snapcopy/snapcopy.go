@@ -0,0 +1,295 @@
+// Package snapcopy copies snapshots to other regions so that volumes may be
+// recreated there if a region is lost. A copy may re-encrypt the snapshot
+// with a key of the destination region and tags it with its source. The
+// progress of each copy is recorded so that it may be followed after the
+// command that started it exits.
+package snapcopy
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/core/state"
+)
+
+// State is the state of a copy.
+type State string
+
+const (
+	// Pending indicates a copy is in progress.
+	Pending State = "pending"
+
+	// Completed indicates a copy completed successfully.
+	Completed State = "completed"
+
+	// Failed indicates a copy failed.
+	Failed State = "failed"
+
+	copiesBucket = "snapshotCopies"
+)
+
+// The tags with which a copy records its source.
+const (
+	TagSourceSnapshot = "rexray-source-snapshot"
+	TagSourceRegion   = "rexray-source-region"
+)
+
+// Request describes a copy to start.
+type Request struct {
+
+	// Snapshot is the snapshot to copy.
+	Snapshot *apitypes.Snapshot
+
+	// Region is the region to which the snapshot is copied.
+	Region string
+
+	// Name is the name of the copy. It defaults to the snapshot's name.
+	Name string
+
+	// EncryptionKey is the key of the destination region with which the
+	// copy is encrypted, ex. the ARN of a KMS key.
+	EncryptionKey string
+
+	// Tags are applied to the copy in addition to the tags that record its
+	// source.
+	Tags map[string]string
+}
+
+// Copy is a copy of a snapshot to another region.
+type Copy struct {
+
+	// ID is the ID of the copy in the destination region.
+	ID string `json:"id" yaml:"id"`
+
+	// Driver is the name of the storage driver of the source snapshot.
+	Driver string `json:"driver" yaml:"driver"`
+
+	// SourceID is the ID of the source snapshot.
+	SourceID string `json:"sourceID" yaml:"sourceID"`
+
+	// SourceRegion is the region of the source snapshot.
+	SourceRegion string `json:"sourceRegion,omitempty" yaml:"sourceRegion,omitempty"`
+
+	// Region is the destination region.
+	Region string `json:"region" yaml:"region"`
+
+	// State is the state of the copy.
+	State State `json:"state" yaml:"state"`
+
+	// Progress is the percentage of the copy that is complete.
+	Progress int `json:"progress" yaml:"progress"`
+
+	// Error is the error that caused the copy to fail.
+	Error string `json:"error,omitempty" yaml:"error,omitempty"`
+
+	// Fields are driver-specific details of the copy's progress.
+	Fields map[string]string `json:"fields,omitempty" yaml:"fields,omitempty"`
+
+	// StartTime is the time at which the copy started.
+	StartTime time.Time `json:"startTime" yaml:"startTime"`
+
+	// CompleteTime is the time at which the copy completed or failed.
+	CompleteTime time.Time `json:"completeTime,omitempty" yaml:"completeTime,omitempty"`
+}
+
+// IsDone returns a flag indicating whether or not the copy has completed.
+func (c *Copy) IsDone() bool {
+	return c.State == Completed || c.State == Failed
+}
+
+// Copier copies the snapshots of a storage platform to other regions.
+type Copier interface {
+
+	// Start starts copying a snapshot and returns the copy. The copy's ID
+	// and source region must be set.
+	Start(ctx apitypes.Context, req *Request) (*Copy, error)
+
+	// Refresh updates the state and progress of a copy in progress.
+	Refresh(ctx apitypes.Context, c *Copy) error
+}
+
+// NewCopier returns a copier that uses the provided configuration.
+type NewCopier func(config gofig.Config) (Copier, error)
+
+var (
+	copiers    = map[string]NewCopier{}
+	copiersRwl sync.RWMutex
+)
+
+// Register registers the constructor of the copier of the storage driver
+// with the provided name.
+func Register(driverName string, f NewCopier) {
+	copiersRwl.Lock()
+	defer copiersRwl.Unlock()
+	copiers[strings.ToLower(driverName)] = f
+}
+
+func newCopier(config gofig.Config, driverName string) (Copier, error) {
+	copiersRwl.RLock()
+	f, ok := copiers[strings.ToLower(driverName)]
+	copiersRwl.RUnlock()
+	if !ok {
+		return nil, goof.WithField("driver", driverName,
+			"driver does not support copying snapshots to other regions")
+	}
+	return f(config)
+}
+
+// Start starts copying a snapshot of the storage driver with the provided
+// name to another region and records the copy.
+func Start(
+	ctx apitypes.Context,
+	config gofig.Config,
+	s *state.Store,
+	driverName string,
+	req *Request) (*Copy, error) {
+
+	if req.Region == "" {
+		return nil, goof.New("missing destination region")
+	}
+	if req.Name == "" {
+		req.Name = req.Snapshot.Name
+	}
+
+	cp, err := newCopier(config, driverName)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := cp.Start(ctx, req)
+	if err != nil {
+		return nil, goof.WithFieldsE(goof.Fields{
+			"snapshotID": req.Snapshot.ID,
+			"region":     req.Region,
+		}, "error copying snapshot", err)
+	}
+	c.Driver = driverName
+	c.SourceID = req.Snapshot.ID
+	c.Region = req.Region
+	c.StartTime = time.Now().UTC()
+	if c.State == "" {
+		c.State = Pending
+	}
+
+	ctx.WithFields(map[string]interface{}{
+		"snapshotID": c.SourceID,
+		"copyID":     c.ID,
+		"region":     c.Region,
+	}).Info("copying snapshot")
+
+	return c, Put(s, c)
+}
+
+// Refresh updates and records the state and progress of a copy that is in
+// progress.
+func Refresh(
+	ctx apitypes.Context,
+	config gofig.Config,
+	s *state.Store,
+	c *Copy) error {
+
+	if c.IsDone() {
+		return nil
+	}
+	cp, err := newCopier(config, c.Driver)
+	if err != nil {
+		return err
+	}
+	if err := cp.Refresh(ctx, c); err != nil {
+		return err
+	}
+	if c.IsDone() {
+		c.CompleteTime = time.Now().UTC()
+		if c.State == Completed {
+			c.Progress = 100
+		}
+	}
+	return Put(s, c)
+}
+
+// Wait refreshes a copy at the provided interval until it completes,
+// invoking progress, if set, after each refresh.
+func Wait(
+	ctx apitypes.Context,
+	config gofig.Config,
+	s *state.Store,
+	c *Copy,
+	interval time.Duration,
+	progress func(c *Copy)) error {
+
+	for {
+		if err := Refresh(ctx, config, s, c); err != nil {
+			return err
+		}
+		if progress != nil {
+			progress(c)
+		}
+		if c.IsDone() {
+			if c.State == Failed {
+				return goof.WithFields(goof.Fields{
+					"copyID": c.ID,
+					"region": c.Region,
+					"error":  c.Error,
+				}, "snapshot copy failed")
+			}
+			return nil
+		}
+		time.Sleep(interval)
+	}
+}
+
+// Tags returns the tags of a copy: the requested tags and the tags that
+// record the copy's source.
+func Tags(req *Request, sourceRegion string) map[string]string {
+	tags := map[string]string{}
+	for k, v := range req.Tags {
+		tags[k] = v
+	}
+	tags[TagSourceSnapshot] = req.Snapshot.ID
+	if sourceRegion != "" {
+		tags[TagSourceRegion] = sourceRegion
+	}
+	return tags
+}
+
+func copyKey(c *Copy) string {
+	return c.Region + ":" + c.ID
+}
+
+// Put records a copy.
+func Put(s *state.Store, c *Copy) error {
+	return s.Set(copiesBucket, copyKey(c), c)
+}
+
+// List returns the recorded copies, oldest first.
+func List(s *state.Store) ([]*Copy, error) {
+	keys, err := s.Keys(copiesBucket)
+	if err != nil {
+		return nil, err
+	}
+	all := []*Copy{}
+	for _, k := range keys {
+		c := &Copy{}
+		ok, err := s.Get(copiesBucket, k, c)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			all = append(all, c)
+		}
+	}
+	sort.Sort(byStartTime(all))
+	return all, nil
+}
+
+type byStartTime []*Copy
+
+func (c byStartTime) Len() int           { return len(c) }
+func (c byStartTime) Swap(i, j int)      { c[i], c[j] = c[j], c[i] }
+func (c byStartTime) Less(i, j int) bool { return c[i].StartTime.Before(c[j].StartTime) }
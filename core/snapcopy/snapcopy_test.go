@@ -0,0 +1,38 @@
+package snapcopy
+
+import (
+	"testing"
+
+	apitypes "github.com/emccode/libstorage/api/types"
+)
+
+func TestTags(t *testing.T) {
+	req := &Request{
+		Snapshot: &apitypes.Snapshot{ID: "snap-1"},
+		Tags:     map[string]string{"env": "prod"},
+	}
+	tags := Tags(req, "us-east-1")
+	if len(tags) != 3 ||
+		tags["env"] != "prod" ||
+		tags[TagSourceSnapshot] != "snap-1" ||
+		tags[TagSourceRegion] != "us-east-1" {
+		t.Fatalf("tags=%v", tags)
+	}
+	if len(req.Tags) != 1 {
+		t.Fatalf("request tags modified: %v", req.Tags)
+	}
+}
+
+func TestParsePercent(t *testing.T) {
+	for v, exp := range map[string]int{"45%": 45, "100%": 100, "": 0, "x": 0} {
+		if p := parsePercent(v); p != exp {
+			t.Fatalf("parsePercent(%q)=%d", v, p)
+		}
+	}
+}
+
+func TestGCELabel(t *testing.T) {
+	if l := gceLabel("Env.Name/1"); l != "env-name-1" {
+		t.Fatalf("label=%s", l)
+	}
+}
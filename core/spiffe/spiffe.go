@@ -0,0 +1,242 @@
+// Package spiffe authenticates REX-Ray agents and controllers to each other
+// with the X.509 SVIDs that SPIRE issues to them, so that no certificates or
+// tokens are distributed to the hosts. The SVID and the trust bundle of its
+// trust domain are fetched from the SPIRE agent's workload API and are used
+// as the libStorage client's and server's TLS certificate and trusted CAs.
+// The server accepts only the SVIDs of agents and controllers, and clients
+// accept only the SVID of a controller.
+package spiffe
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	apitypes "github.com/emccode/libstorage/api/types"
+	"github.com/spiffe/go-spiffe/v2/workloadapi"
+)
+
+const (
+	// DefaultSocket is the address of the SPIRE agent's workload API in a
+	// default installation.
+	DefaultSocket = "unix:///tmp/spire-agent/public/api.sock"
+
+	certFile   = "svid.pem"
+	keyFile    = "svid.key"
+	bundleFile = "bundle.pem"
+
+	tlsCertFile           = "libstorage.tls.certFile"
+	tlsKeyFile            = "libstorage.tls.keyFile"
+	tlsTrustedCertsFile   = "libstorage.tls.trustedCertsFile"
+	tlsClientCertRequired = "libstorage.tls.clientCertRequired"
+	tlsReload             = "rexray.tls.reload"
+	tlsServerURI          = "rexray.tls.serverURI"
+	tlsClientURIs         = "rexray.tls.clientURIs"
+
+	controllerPath = "/rexray/controller"
+	agentPath      = "/rexray/agent/*"
+)
+
+func init() {
+	r := gofig.NewRegistration("SPIFFE")
+	r.Key(gofig.Bool, "", false,
+		"Authenticate agents and controllers with SPIFFE SVIDs",
+		"rexray.spiffe.enabled")
+	r.Key(gofig.String, "", DefaultSocket,
+		"The address of the SPIRE agent's workload API",
+		"rexray.spiffe.socket")
+	r.Key(gofig.String, "", "",
+		"The directory to which the SVID and trust bundle are written; "+
+			"defaults to spiffe in the lib directory",
+		"rexray.spiffe.path")
+	r.Key(gofig.String, "", "",
+		"The SPIFFE ID of the controllers; defaults to /rexray/controller in "+
+			"the trust domain of the workload's SVID",
+		"rexray.spiffe.controllerID")
+	r.Key(gofig.String, "", "",
+		"The SPIFFE IDs, separated by commas, of the agents; an ID ending "+
+			"with /* matches the IDs under its path; defaults to "+
+			"/rexray/agent/* in the trust domain of the workload's SVID",
+		"rexray.spiffe.agentIDs")
+	gofig.Register(r)
+}
+
+// Enabled returns a flag indicating whether agents and controllers are
+// authenticated with SVIDs.
+func Enabled(config gofig.Config) bool {
+	return config.GetBool("rexray.spiffe.enabled")
+}
+
+// Identity is the X.509 SVID of the workload and the trust bundle with
+// which the SVIDs of its peers are verified.
+type Identity struct {
+
+	// ID is the SPIFFE ID of the workload, ex.
+	// spiffe://example.org/rexray/agent/node1.
+	ID string
+
+	// TrustDomain is the SPIFFE ID of the workload's trust domain, ex.
+	// spiffe://example.org.
+	TrustDomain string
+
+	Cert   []byte
+	Key    []byte
+	Bundle []byte
+}
+
+// Fetch returns the workload's identity from the workload API.
+func Fetch(ctx apitypes.Context, config gofig.Config) (*Identity, error) {
+	socket := config.GetString("rexray.spiffe.socket")
+	x509ctx, err := workloadapi.FetchX509Context(
+		ctx, workloadapi.WithAddr(socket))
+	if err != nil {
+		return nil, goof.WithFieldE("socket", socket,
+			"error fetching svid", err)
+	}
+	return toIdentity(x509ctx)
+}
+
+func toIdentity(x509ctx *workloadapi.X509Context) (*Identity, error) {
+	svid := x509ctx.DefaultSVID()
+	if svid == nil {
+		return nil, goof.New("workload has no svid")
+	}
+	cert, key, err := svid.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	b, err := x509ctx.Bundles.GetX509BundleForTrustDomain(svid.ID.TrustDomain())
+	if err != nil {
+		return nil, goof.WithFieldE("spiffeID", svid.ID.String(),
+			"no trust bundle", err)
+	}
+	bundle, err := b.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	return &Identity{
+		ID:          svid.ID.String(),
+		TrustDomain: svid.ID.TrustDomain().IDString(),
+		Cert:        cert,
+		Key:         key,
+		Bundle:      bundle,
+	}, nil
+}
+
+// Dir returns the directory to which the identity's files are written,
+// which is rexray.spiffe.path, or the provided directory if the property is
+// not set.
+func Dir(config gofig.Config, dir string) string {
+	if path := config.GetString("rexray.spiffe.path"); path != "" {
+		return path
+	}
+	return dir
+}
+
+// Configure fetches the workload's identity, writes it to files, and sets
+// the libStorage TLS properties so that the libStorage client presents the
+// SVID to the server and verifies the server's SVID with the trust bundle,
+// and the server requires clients to present SVIDs of the trust domain. The
+// server accepts only the SVIDs of agents and controllers, and clients only
+// the SVID of a controller, whose SPIFFE IDs are verified rather than their
+// host names, since an SVID need not have any. The files are written to the
+// directory returned by Dir, and are rewritten whenever SPIRE rotates the
+// SVID. The libStorage endpoints' certificates are then loaded again when the
+// files are rewritten, and the client's by Connect.
+func Configure(ctx apitypes.Context, config gofig.Config, dir string) error {
+	dir = Dir(config, dir)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	id, err := Fetch(ctx, config)
+	if err != nil {
+		return err
+	}
+	if err := write(dir, id); err != nil {
+		return err
+	}
+
+	controllerID := config.GetString("rexray.spiffe.controllerID")
+	if controllerID == "" {
+		controllerID = id.TrustDomain + controllerPath
+	}
+	agentIDs := config.GetString("rexray.spiffe.agentIDs")
+	if agentIDs == "" {
+		agentIDs = id.TrustDomain + agentPath
+	}
+	clientIDs := []string{controllerID}
+	for _, a := range strings.Split(agentIDs, ",") {
+		if a = strings.TrimSpace(a); a != "" {
+			clientIDs = append(clientIDs, a)
+		}
+	}
+
+	config.Set(tlsCertFile, filepath.Join(dir, certFile))
+	config.Set(tlsKeyFile, filepath.Join(dir, keyFile))
+	config.Set(tlsTrustedCertsFile, filepath.Join(dir, bundleFile))
+	config.Set(tlsClientCertRequired, true)
+	config.Set(tlsReload, true)
+	config.Set(tlsServerURI, controllerID)
+	config.Set(tlsClientURIs, strings.Join(clientIDs, ","))
+
+	ctx.WithFields(map[string]interface{}{
+		"spiffeID":     id.ID,
+		"controllerID": controllerID,
+		"clientIDs":    clientIDs,
+	}).Info("using spiffe identity")
+
+	go workloadapi.WatchX509Context(ctx, &watcher{ctx: ctx, dir: dir},
+		workloadapi.WithAddr(config.GetString("rexray.spiffe.socket")))
+	return nil
+}
+
+// watcher rewrites the identity's files when SPIRE rotates the SVID or the
+// trust bundle changes.
+type watcher struct {
+	ctx apitypes.Context
+	dir string
+}
+
+func (w *watcher) OnX509ContextUpdate(x509ctx *workloadapi.X509Context) {
+	id, err := toIdentity(x509ctx)
+	if err == nil {
+		err = write(w.dir, id)
+	}
+	if err != nil {
+		w.ctx.WithError(err).Error("error updating spiffe identity")
+		return
+	}
+	w.ctx.WithField("spiffeID", id.ID).Debug("updated spiffe identity")
+}
+
+func (w *watcher) OnX509ContextWatchError(err error) {
+	w.ctx.WithError(err).Warn("error watching spiffe identity")
+}
+
+// write writes the identity's files. Each file is written to a temporary file
+// and renamed into place so that it is never read partially written.
+func write(dir string, id *Identity) error {
+	files := []struct {
+		name string
+		buf  []byte
+	}{
+		{keyFile, id.Key},
+		{certFile, id.Cert},
+		{bundleFile, id.Bundle},
+	}
+	for _, f := range files {
+		path := filepath.Join(dir, f.name)
+		tmp := path + ".tmp"
+		if err := ioutil.WriteFile(tmp, f.buf, 0600); err != nil {
+			return goof.WithFieldE("path", tmp, "error writing svid", err)
+		}
+		if err := os.Rename(tmp, path); err != nil {
+			return goof.WithFieldE("path", path, "error writing svid", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package spiffe
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/gotil"
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/core/certs"
+)
+
+// Connect directs the libStorage client to its remote server through a
+// tunnel on a unix socket in the directory returned by Dir. The libStorage
+// client reads its certificate only when it is created, and verifies the
+// server's host name, which an SVID need not have, so the tunnel makes the
+// connections to the server itself: it presents the current SVID, verifies
+// that the server's SVID has the controller's SPIFFE ID, and forwards the
+// client's requests, which are sent to the tunnel without TLS. Connect does
+// nothing unless SPIFFE is enabled and the server is remote.
+func Connect(ctx apitypes.Context, config gofig.Config, dir string) error {
+	if !Enabled(config) {
+		return nil
+	}
+	host := config.GetString(apitypes.ConfigHost)
+	if host == "" {
+		return nil
+	}
+	proto, addr, err := gotil.ParseAddress(host)
+	if err != nil {
+		return err
+	}
+	if proto == "unix" {
+		return nil
+	}
+
+	tlsConfig, err := certs.ClientConfig(ctx, config)
+	if err != nil {
+		return err
+	}
+	if tlsConfig == nil {
+		return nil
+	}
+	if tlsConfig.ServerName == "" {
+		tlsConfig.ServerName, _, _ = net.SplitHostPort(addr)
+	}
+
+	dir = Dir(config, dir)
+	removeStale(dir)
+	sock := filepath.Join(dir, fmt.Sprintf("controller-%d.sock", os.Getpid()))
+	os.Remove(sock)
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		return err
+	}
+
+	t := &tunnel{
+		ctx:    ctx,
+		proto:  proto,
+		addr:   addr,
+		config: tlsConfig,
+	}
+	go t.serve(l)
+
+	// the tunnel terminates the client's connections without TLS
+	config.Set(apitypes.ConfigHost, "unix://"+sock)
+	config.Set("libstorage.tls", false)
+
+	ctx.WithFields(map[string]interface{}{
+		"host": host,
+		"sock": sock,
+	}).Debug("connecting to libStorage server through spiffe tunnel")
+	return nil
+}
+
+// tunnel forwards the connections it accepts to the server with TLS.
+type tunnel struct {
+	ctx    apitypes.Context
+	proto  string
+	addr   string
+	config *tls.Config
+}
+
+func (t *tunnel) serve(l net.Listener) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			t.ctx.WithError(err).Error("spiffe tunnel stopped")
+			return
+		}
+		go t.forward(conn)
+	}
+}
+
+func (t *tunnel) forward(conn net.Conn) {
+	defer conn.Close()
+
+	upstream, err := tls.Dial(t.proto, t.addr, t.config)
+	if err != nil {
+		t.ctx.WithError(err).WithField("address", t.addr).Error(
+			"error connecting to libStorage server")
+		return
+	}
+	defer upstream.Close()
+
+	done := make(chan bool, 2)
+	go func() {
+		io.Copy(upstream, conn)
+		upstream.CloseWrite()
+		done <- true
+	}()
+	go func() {
+		io.Copy(conn, upstream)
+		if c, ok := conn.(*net.UnixConn); ok {
+			c.CloseWrite()
+		}
+		done <- true
+	}()
+	<-done
+	<-done
+}
+
+// removeStale removes the sockets of the tunnels of processes that exited.
+func removeStale(dir string) {
+	socks, _ := filepath.Glob(filepath.Join(dir, "controller-*.sock"))
+	for _, sock := range socks {
+		var pid int
+		if _, err := fmt.Sscanf(
+			filepath.Base(sock), "controller-%d.sock", &pid); err != nil {
+			continue
+		}
+		if p, err := os.FindProcess(pid); err == nil &&
+			p.Signal(syscall.Signal(0)) == nil {
+			continue
+		}
+		os.Remove(sock)
+	}
+}
@@ -0,0 +1,129 @@
+package state
+
+import (
+	"github.com/akutz/gofig"
+	"github.com/boltdb/bolt"
+
+	"github.com/emccode/rexray/util"
+)
+
+// BoltBackend is the name of the backend that persists the buckets to a
+// BoltDB database.
+const BoltBackend = "bolt"
+
+const defaultBoltFileName = "state.db"
+
+func init() {
+	RegisterBackend(BoltBackend, newBoltBackend)
+}
+
+// boltBackend persists each bucket to a bucket of a BoltDB database. BoltDB
+// locks the database file while it is open, so the database is opened for
+// each operation in order that the CLI may use the store while the service
+// is running.
+type boltBackend struct {
+	path    string
+	options *bolt.Options
+}
+
+func newBoltBackend(config gofig.Config) (Backend, error) {
+	path := config.GetString("rexray.state.path")
+	if path == "" {
+		path = util.LibFilePath(defaultBoltFileName)
+	}
+	return &boltBackend{
+		path:    path,
+		options: &bolt.Options{Timeout: requestTimeout(config)},
+	}, nil
+}
+
+func (b *boltBackend) view(fn func(tx *bolt.Tx) error) error {
+	db, err := bolt.Open(b.path, 0600, b.options)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.View(fn)
+}
+
+func (b *boltBackend) update(fn func(tx *bolt.Tx) error) error {
+	db, err := bolt.Open(b.path, 0600, b.options)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.Update(fn)
+}
+
+func (b *boltBackend) Get(bucket, key string) ([]byte, bool, error) {
+	var value []byte
+	err := b.view(func(tx *bolt.Tx) error {
+		bkt := tx.Bucket([]byte(bucket))
+		if bkt == nil {
+			return nil
+		}
+		// values are only valid during the transaction
+		if v := bkt.Get([]byte(key)); v != nil {
+			value = append([]byte{}, v...)
+		}
+		return nil
+	})
+	return value, value != nil, err
+}
+
+func (b *boltBackend) Put(bucket, key string, value []byte) error {
+	return b.update(func(tx *bolt.Tx) error {
+		bkt, err := tx.CreateBucketIfNotExists([]byte(bucket))
+		if err != nil {
+			return err
+		}
+		return bkt.Put([]byte(key), value)
+	})
+}
+
+func (b *boltBackend) Delete(bucket, key string) error {
+	return b.update(func(tx *bolt.Tx) error {
+		bkt := tx.Bucket([]byte(bucket))
+		if bkt == nil {
+			return nil
+		}
+		return bkt.Delete([]byte(key))
+	})
+}
+
+func (b *boltBackend) Update(
+	bucket, key string,
+	fn func(value []byte, ok bool) ([]byte, bool, error)) error {
+
+	return b.update(func(tx *bolt.Tx) error {
+		bkt, err := tx.CreateBucketIfNotExists([]byte(bucket))
+		if err != nil {
+			return err
+		}
+		raw := bkt.Get([]byte(key))
+		value, keep, err := fn(raw, raw != nil)
+		if err != nil {
+			return err
+		}
+		if !keep {
+			return bkt.Delete([]byte(key))
+		}
+		return bkt.Put([]byte(key), value)
+	})
+}
+
+func (b *boltBackend) Keys(bucket string) ([]string, error) {
+	keys := []string{}
+	err := b.view(func(tx *bolt.Tx) error {
+		bkt := tx.Bucket([]byte(bucket))
+		if bkt == nil {
+			return nil
+		}
+		// a bucket's keys are iterated in byte order
+		return bkt.ForEach(func(k, v []byte) error {
+			keys = append(keys, string(k))
+			return nil
+		})
+	})
+	return keys, err
+}
@@ -0,0 +1,130 @@
+package state
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/akutz/gofig"
+	"github.com/hashicorp/consul/api"
+)
+
+// ConsulBackend is the name of the backend that persists the buckets to
+// Consul's KV store.
+const ConsulBackend = "consul"
+
+func init() {
+	RegisterBackend(ConsulBackend, newConsulBackend)
+}
+
+// consulBackend persists each key of a bucket to the Consul key
+// <prefix><bucket>/<key>. Updates are check-and-set operations on the key's
+// modify index so that controllers sharing the state do not overwrite each
+// other's updates.
+type consulBackend struct {
+	kv     *api.KV
+	prefix string
+}
+
+func newConsulBackend(config gofig.Config) (Backend, error) {
+	c := api.DefaultConfig()
+	if v := config.GetString("rexray.state.consul.address"); v != "" {
+		c.Address = v
+	}
+	if v := config.GetString("rexray.state.consul.token"); v != "" {
+		c.Token = v
+	}
+	httpClient, err := api.NewHttpClient(c.Transport, c.TLSConfig)
+	if err != nil {
+		return nil, err
+	}
+	httpClient.Timeout = requestTimeout(config)
+	c.HttpClient = httpClient
+	client, err := api.NewClient(c)
+	if err != nil {
+		return nil, err
+	}
+	return &consulBackend{
+		kv:     client.KV(),
+		prefix: config.GetString("rexray.state.consul.prefix"),
+	}, nil
+}
+
+func (b *consulBackend) bucketPrefix(bucket string) string {
+	return b.prefix + bucket + "/"
+}
+
+func (b *consulBackend) Get(bucket, key string) ([]byte, bool, error) {
+	pair, _, err := b.kv.Get(b.bucketPrefix(bucket)+key, nil)
+	if err != nil || pair == nil {
+		return nil, false, err
+	}
+	return pair.Value, true, nil
+}
+
+func (b *consulBackend) Put(bucket, key string, value []byte) error {
+	_, err := b.kv.Put(
+		&api.KVPair{Key: b.bucketPrefix(bucket) + key, Value: value}, nil)
+	return err
+}
+
+func (b *consulBackend) Delete(bucket, key string) error {
+	_, err := b.kv.Delete(b.bucketPrefix(bucket)+key, nil)
+	return err
+}
+
+func (b *consulBackend) Update(
+	bucket, key string,
+	fn func(value []byte, ok bool) ([]byte, bool, error)) error {
+
+	k := b.bucketPrefix(bucket) + key
+	for {
+		pair, _, err := b.kv.Get(k, nil)
+		if err != nil {
+			return err
+		}
+
+		// a check-and-set with an index of zero only succeeds if the key
+		// does not exist
+		var raw []byte
+		var index uint64
+		if pair != nil {
+			raw, index = pair.Value, pair.ModifyIndex
+		}
+
+		value, keep, err := fn(raw, pair != nil)
+		if err != nil {
+			return err
+		}
+
+		var ok bool
+		if keep {
+			ok, _, err = b.kv.CAS(
+				&api.KVPair{Key: k, Value: value, ModifyIndex: index}, nil)
+		} else if pair == nil {
+			return nil
+		} else {
+			ok, _, err = b.kv.DeleteCAS(
+				&api.KVPair{Key: k, ModifyIndex: index}, nil)
+		}
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+	}
+}
+
+func (b *consulBackend) Keys(bucket string) ([]string, error) {
+	p := b.bucketPrefix(bucket)
+	all, _, err := b.kv.Keys(p, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	keys := []string{}
+	for _, k := range all {
+		keys = append(keys, strings.TrimPrefix(k, p))
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
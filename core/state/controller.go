@@ -0,0 +1,360 @@
+package state
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	"github.com/akutz/gotil"
+	"github.com/emccode/libstorage/api/context"
+
+	"github.com/emccode/rexray/core/certs"
+)
+
+// HandlerPath is the path under which the controller serves its state to
+// the other hosts.
+const HandlerPath = "/rexray/state/"
+
+var (
+	controllerStore *Store
+	controllerLocal bool
+)
+
+// ServeController records that this process serves the controller's state
+// to the other hosts, so that Controller returns the default store.
+func ServeController() {
+	defaultStoreRwl.Lock()
+	defer defaultStoreRwl.Unlock()
+	controllerLocal = true
+	controllerStore = nil
+}
+
+// Controller returns the store of the state that the controller keeps on
+// behalf of every host, such as the nodes' heartbeats. It is the default
+// store in the process that serves the controller's state, or if the
+// configured backend is shared by every host. Otherwise it is the store of
+// the controller that serves the libStorage API at libstorage.host, whose
+// state is read and written through the API's endpoint.
+func Controller() *Store {
+	defaultStoreRwl.Lock()
+	config, local := defaultConfig, controllerLocal
+	defaultStoreRwl.Unlock()
+	if local || Shared(config) || config == nil ||
+		config.GetString("libstorage.host") == "" {
+		return Default()
+	}
+
+	defaultStoreRwl.Lock()
+	defer defaultStoreRwl.Unlock()
+	if controllerStore == nil {
+		controllerStore = &Store{open: func() (Backend, error) {
+			return newControllerBackend(config)
+		}}
+	}
+	return controllerStore
+}
+
+// controllerBackend reads and writes the controller's state through the
+// endpoint of its libStorage server. Updates are conditional on the ETag of
+// the value that was read so that hosts do not overwrite each other's
+// updates.
+type controllerBackend struct {
+	client *http.Client
+	url    string
+	token  string
+}
+
+func newControllerBackend(config gofig.Config) (Backend, error) {
+	host := config.GetString("libstorage.host")
+	proto, addr, err := gotil.ParseAddress(host)
+	if err != nil {
+		return nil, goof.WithFieldE("host", host, "invalid controller", err)
+	}
+
+	tlsConfig, err := certs.ClientConfig(context.Background(), config)
+	if err != nil {
+		return nil, err
+	}
+
+	dial := func(string, string) (net.Conn, error) {
+		return net.DialTimeout(proto, addr, requestTimeout(config))
+	}
+	scheme := "http"
+	if tlsConfig != nil {
+		scheme = "https"
+		if tlsConfig.ServerName == "" && proto != "unix" {
+			tlsConfig.ServerName, _, _ = net.SplitHostPort(addr)
+		}
+	}
+
+	return &controllerBackend{
+		client: &http.Client{
+			Timeout: requestTimeout(config),
+			Transport: &http.Transport{
+				Dial:            dial,
+				TLSClientConfig: tlsConfig,
+			},
+		},
+		url:   scheme + "://controller" + HandlerPath,
+		token: config.GetString("rexray.controller.token"),
+	}, nil
+}
+
+func (b *controllerBackend) do(
+	method, bucket, key string,
+	body []byte,
+	header http.Header) (*http.Response, []byte, error) {
+
+	u := b.url + url.PathEscape(bucket)
+	if key != "" {
+		u += "/" + url.PathEscape(key)
+	}
+	req, err := http.NewRequest(method, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if b.token != "" {
+		req.Header.Set("Authorization", "Bearer "+b.token)
+	}
+	res, err := b.client.Do(req)
+	if err != nil {
+		return nil, nil, goof.WithFieldE(
+			"bucket", bucket, "error reaching controller", err)
+	}
+	defer res.Body.Close()
+	buf, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch res.StatusCode {
+	case http.StatusOK, http.StatusNoContent,
+		http.StatusNotFound, http.StatusPreconditionFailed:
+		return res, buf, nil
+	}
+	return nil, nil, goof.WithFields(goof.Fields{
+		"status": res.StatusCode,
+		"bucket": bucket,
+		"error":  strings.TrimSpace(string(buf)),
+	}, "error from controller")
+}
+
+func (b *controllerBackend) get(
+	bucket, key string) ([]byte, string, bool, error) {
+
+	res, buf, err := b.do("GET", bucket, key, nil, nil)
+	if err != nil || res.StatusCode == http.StatusNotFound {
+		return nil, "", false, err
+	}
+	return buf, res.Header.Get("ETag"), true, nil
+}
+
+func (b *controllerBackend) Get(bucket, key string) ([]byte, bool, error) {
+	raw, _, ok, err := b.get(bucket, key)
+	return raw, ok, err
+}
+
+func (b *controllerBackend) Put(bucket, key string, value []byte) error {
+	_, _, err := b.do("PUT", bucket, key, value, nil)
+	return err
+}
+
+func (b *controllerBackend) Delete(bucket, key string) error {
+	_, _, err := b.do("DELETE", bucket, key, nil, nil)
+	return err
+}
+
+func (b *controllerBackend) Keys(bucket string) ([]string, error) {
+	res, buf, err := b.do("GET", bucket, "", nil, nil)
+	if err != nil || res.StatusCode == http.StatusNotFound {
+		return nil, err
+	}
+	keys := []string{}
+	return keys, json.Unmarshal(buf, &keys)
+}
+
+func (b *controllerBackend) Update(
+	bucket, key string,
+	fn func(value []byte, ok bool) ([]byte, bool, error)) error {
+
+	for {
+		raw, etag, ok, err := b.get(bucket, key)
+		if err != nil {
+			return err
+		}
+
+		value, keep, err := fn(raw, ok)
+		if err != nil {
+			return err
+		}
+
+		header := http.Header{}
+		if ok {
+			header.Set("If-Match", etag)
+		} else {
+			header.Set("If-None-Match", "*")
+		}
+
+		method := "DELETE"
+		if keep {
+			method = "PUT"
+		} else if !ok {
+			return nil
+		}
+
+		res, _, err := b.do(method, bucket, key, value, header)
+		if err != nil {
+			return err
+		}
+		if res.StatusCode != http.StatusPreconditionFailed {
+			return nil
+		}
+	}
+}
+
+// etag returns the ETag of a value.
+func etag(raw []byte) string {
+	sum := sha256.Sum256(raw)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+// Authorizer returns an error if the request may not read, or write if
+// write is set, the provided bucket.
+type Authorizer func(req *http.Request, bucket string, write bool) error
+
+// errPrecondition is returned by an update whose precondition failed.
+var errPrecondition = goof.New("precondition failed")
+
+// Handler returns an HTTP handler that serves the provided store's buckets
+// under HandlerPath to the hosts whose requests are authorized. An error
+// returned by authorize that has a Status method is reported with its
+// status, and any other with 403 Forbidden.
+func Handler(s *Store, authorize Authorizer) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		bucket, key, err := splitPath(req.URL.EscapedPath())
+		if err != nil || bucket == "" {
+			http.Error(w, "invalid path", http.StatusNotFound)
+			return
+		}
+
+		write := req.Method != "GET" && req.Method != "HEAD"
+		if err := authorize(req, bucket, write); err != nil {
+			status := http.StatusForbidden
+			if se, ok := err.(interface {
+				Status() int
+			}); ok {
+				status = se.Status()
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+
+		if key == "" {
+			if req.Method != "GET" {
+				http.Error(w, "method not allowed",
+					http.StatusMethodNotAllowed)
+				return
+			}
+			keys, err := s.Keys(bucket)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if keys == nil {
+				keys = []string{}
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(keys)
+			return
+		}
+
+		switch req.Method {
+		case "GET":
+			b, err := s.getBackend()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			raw, ok, err := b.Get(bucket, key)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if !ok {
+				http.Error(w, "key not found", http.StatusNotFound)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("ETag", etag(raw))
+			w.Write(raw)
+		case "PUT", "DELETE":
+			body, err := ioutil.ReadAll(req.Body)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if req.Method == "PUT" && !json.Valid(body) {
+				http.Error(w, "invalid value", http.StatusBadRequest)
+				return
+			}
+			err = update(s, bucket, key, req, body)
+			switch {
+			case err == errPrecondition:
+				http.Error(w, err.Error(), http.StatusPreconditionFailed)
+			case err != nil:
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			default:
+				w.WriteHeader(http.StatusNoContent)
+			}
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+// update writes, or deletes, a key if the request's If-Match or
+// If-None-Match header matches the key's value.
+func update(
+	s *Store, bucket, key string, req *http.Request, body []byte) error {
+
+	b, err := s.getBackend()
+	if err != nil {
+		return err
+	}
+	ifMatch := req.Header.Get("If-Match")
+	ifNoneMatch := req.Header.Get("If-None-Match")
+	return b.Update(bucket, key,
+		func(raw []byte, ok bool) ([]byte, bool, error) {
+			switch {
+			case ifMatch != "" && (!ok || etag(raw) != ifMatch):
+				return nil, false, errPrecondition
+			case ifNoneMatch == "*" && ok:
+				return nil, false, errPrecondition
+			case req.Method == "DELETE":
+				return nil, false, nil
+			}
+			return body, true, nil
+		})
+}
+
+// splitPath returns the bucket and key of a path under HandlerPath.
+func splitPath(p string) (string, string, error) {
+	p = strings.TrimPrefix(p, strings.TrimSuffix(HandlerPath, "/"))
+	parts := strings.SplitN(strings.TrimPrefix(p, "/"), "/", 2)
+	bucket, err := url.PathUnescape(parts[0])
+	if err != nil || len(parts) == 1 {
+		return bucket, "", err
+	}
+	key, err := url.PathUnescape(parts[1])
+	return bucket, key, err
+}
@@ -0,0 +1,89 @@
+package state
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestControllerBackend(t *testing.T) {
+	dir, err := ioutil.TempDir("", "state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s := Open(filepath.Join(dir, "state.json"))
+	srv := httptest.NewServer(Handler(s,
+		func(req *http.Request, bucket string, write bool) error {
+			if bucket == "private" || (write && bucket == "readonly") {
+				return errors.New("forbidden")
+			}
+			return nil
+		}))
+	defer srv.Close()
+
+	b := &controllerBackend{
+		client: srv.Client(),
+		url:    srv.URL + HandlerPath,
+	}
+	c := New(b)
+
+	if err := c.Set("nodes", "i-1/a", map[string]int{"n": 1}); err != nil {
+		t.Fatal(err)
+	}
+	v := map[string]int{}
+	if ok, err := s.Get("nodes", "i-1/a", &v); err != nil || !ok ||
+		v["n"] != 1 {
+		t.Fatalf("%v %v %v", ok, err, v)
+	}
+	if keys, err := c.Keys("nodes"); err != nil || len(keys) != 1 ||
+		keys[0] != "i-1/a" {
+		t.Fatalf("%v %v", keys, err)
+	}
+	if keys, err := c.Keys("empty"); err != nil || len(keys) != 0 {
+		t.Fatalf("%v %v", keys, err)
+	}
+
+	// an update conditional on a value that changed since it was read is
+	// retried
+	calls := 0
+	err = c.Update("nodes", "i-1/a", &v, func(ok bool) (bool, error) {
+		if calls == 0 {
+			if err := s.Set("nodes", "i-1/a",
+				map[string]int{"n": 5}); err != nil {
+				return false, err
+			}
+		}
+		calls++
+		v["n"]++
+		return true, nil
+	})
+	if err != nil || calls != 2 {
+		t.Fatalf("%v %d", err, calls)
+	}
+	if _, err := s.Get("nodes", "i-1/a", &v); err != nil || v["n"] != 6 {
+		t.Fatalf("%v %v", err, v)
+	}
+
+	err = c.Update("nodes", "i-1/a", &v, func(ok bool) (bool, error) {
+		return false, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := c.Get("nodes", "i-1/a", &v); err != nil || ok {
+		t.Fatalf("%v %v", ok, err)
+	}
+
+	if _, err := c.Keys("private"); err == nil {
+		t.Fatal("expected forbidden bucket to be refused")
+	}
+	if err := c.Set("readonly", "k", 1); err == nil {
+		t.Fatal("expected write to read-only bucket to be refused")
+	}
+}
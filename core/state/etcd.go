@@ -0,0 +1,142 @@
+package state
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+
+	"github.com/emccode/rexray/core/etcd"
+)
+
+// EtcdBackend is the name of the backend that persists the buckets to etcd.
+const EtcdBackend = "etcd"
+
+func init() {
+	RegisterBackend(EtcdBackend, newEtcdBackend)
+}
+
+// etcdBackend persists each key of a bucket to the etcd key
+// <prefix><bucket>/<key>. Updates are transactions that compare the key's
+// revision so that controllers sharing the state do not overwrite each
+// other's updates.
+type etcdBackend struct {
+	client  *etcd.Client
+	prefix  string
+	timeout time.Duration
+}
+
+func newEtcdBackend(config gofig.Config) (Backend, error) {
+	endpoints := strings.Fields(config.GetString("rexray.state.etcd.endpoints"))
+	if len(endpoints) == 0 {
+		return nil, goof.New("etcd backend requires rexray.state.etcd.endpoints")
+	}
+	client, err := etcd.New(etcd.Config{
+		Endpoints: endpoints,
+		Username:  config.GetString("rexray.state.etcd.username"),
+		Password:  config.GetString("rexray.state.etcd.password"),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &etcdBackend{
+		client:  client,
+		prefix:  config.GetString("rexray.state.etcd.prefix"),
+		timeout: requestTimeout(config),
+	}, nil
+}
+
+func (b *etcdBackend) bucketPrefix(bucket string) string {
+	return b.prefix + bucket + "/"
+}
+
+func (b *etcdBackend) context() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), b.timeout)
+}
+
+func (b *etcdBackend) Get(bucket, key string) ([]byte, bool, error) {
+	ctx, cancel := b.context()
+	defer cancel()
+	kv, _, err := b.client.Get(ctx, b.bucketPrefix(bucket)+key)
+	if err != nil || kv == nil {
+		return nil, false, err
+	}
+	return kv.Value, true, nil
+}
+
+func (b *etcdBackend) Put(bucket, key string, value []byte) error {
+	ctx, cancel := b.context()
+	defer cancel()
+	return b.client.Put(ctx, b.bucketPrefix(bucket)+key, value)
+}
+
+func (b *etcdBackend) Delete(bucket, key string) error {
+	ctx, cancel := b.context()
+	defer cancel()
+	return b.client.Delete(ctx, b.bucketPrefix(bucket)+key)
+}
+
+func (b *etcdBackend) Update(
+	bucket, key string,
+	fn func(value []byte, ok bool) ([]byte, bool, error)) error {
+
+	k := b.bucketPrefix(bucket) + key
+	for {
+		ctx, cancel := b.context()
+		kv, _, err := b.client.Get(ctx, k)
+		cancel()
+		if err != nil {
+			return err
+		}
+
+		// the revision of a key that does not exist is zero
+		var raw []byte
+		var rev int64
+		if kv != nil {
+			raw, rev = kv.Value, kv.ModRevision
+			if raw == nil {
+				raw = []byte{}
+			}
+		}
+
+		value, keep, err := fn(raw, raw != nil)
+		if err != nil {
+			return err
+		}
+
+		if !keep && raw == nil {
+			return nil
+		}
+
+		var ok bool
+		ctx, cancel = b.context()
+		if keep {
+			ok, err = b.client.PutIf(ctx, k, value, rev)
+		} else {
+			ok, err = b.client.DeleteIf(ctx, k, rev)
+		}
+		cancel()
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+	}
+}
+
+func (b *etcdBackend) Keys(bucket string) ([]string, error) {
+	ctx, cancel := b.context()
+	defer cancel()
+	p := b.bucketPrefix(bucket)
+	keys, err := b.client.Keys(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	for i, k := range keys {
+		keys[i] = strings.TrimPrefix(k, p)
+	}
+	return keys, nil
+}
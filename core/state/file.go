@@ -0,0 +1,159 @@
+package state
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/akutz/gotil"
+)
+
+type buckets map[string]map[string]json.RawMessage
+
+// fileBackend persists the buckets to a JSON file that is read and rewritten
+// in its entirety by each operation.
+type fileBackend struct {
+	path string
+	rwl  sync.RWMutex
+}
+
+func newFileBackend(path string) *fileBackend {
+	return &fileBackend{path: path}
+}
+
+func (f *fileBackend) Get(bucket, key string) ([]byte, bool, error) {
+	f.rwl.RLock()
+	defer f.rwl.RUnlock()
+
+	b, err := f.load()
+	if err != nil {
+		return nil, false, err
+	}
+
+	raw, ok := b[bucket][key]
+	return raw, ok, nil
+}
+
+func (f *fileBackend) Put(bucket, key string, value []byte) error {
+	f.rwl.Lock()
+	defer f.rwl.Unlock()
+
+	b, err := f.load()
+	if err != nil {
+		return err
+	}
+
+	if _, ok := b[bucket]; !ok {
+		b[bucket] = map[string]json.RawMessage{}
+	}
+	b[bucket][key] = value
+
+	return f.save(b)
+}
+
+func (f *fileBackend) Delete(bucket, key string) error {
+	f.rwl.Lock()
+	defer f.rwl.Unlock()
+
+	b, err := f.load()
+	if err != nil {
+		return err
+	}
+
+	if _, ok := b[bucket][key]; !ok {
+		return nil
+	}
+	delete(b[bucket], key)
+
+	return f.save(b)
+}
+
+func (f *fileBackend) Update(
+	bucket, key string,
+	fn func(value []byte, ok bool) ([]byte, bool, error)) error {
+
+	f.rwl.Lock()
+	defer f.rwl.Unlock()
+
+	b, err := f.load()
+	if err != nil {
+		return err
+	}
+
+	raw, ok := b[bucket][key]
+	value, keep, err := fn(raw, ok)
+	if err != nil {
+		return err
+	}
+
+	if !keep {
+		if !ok {
+			return nil
+		}
+		delete(b[bucket], key)
+		return f.save(b)
+	}
+
+	if _, ok := b[bucket]; !ok {
+		b[bucket] = map[string]json.RawMessage{}
+	}
+	b[bucket][key] = value
+
+	return f.save(b)
+}
+
+func (f *fileBackend) Keys(bucket string) ([]string, error) {
+	f.rwl.RLock()
+	defer f.rwl.RUnlock()
+
+	b, err := f.load()
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []string{}
+	for k := range b[bucket] {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys, nil
+}
+
+func (f *fileBackend) load() (buckets, error) {
+	b := buckets{}
+	if !gotil.FileExists(f.path) {
+		return b, nil
+	}
+
+	buf, err := ioutil.ReadFile(f.path)
+	if err != nil {
+		return nil, err
+	}
+	if len(buf) == 0 {
+		return b, nil
+	}
+
+	if err := json.Unmarshal(buf, &b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// save writes the buckets to a temporary file that is then renamed in order
+// to avoid leaving a partially written state file behind.
+func (f *fileBackend) save(b buckets) error {
+	buf, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := f.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, buf, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, f.path)
+}
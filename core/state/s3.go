@@ -0,0 +1,162 @@
+package state
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// S3Backend is the name of the backend that persists the buckets to S3.
+const S3Backend = "s3"
+
+func init() {
+	RegisterBackend(S3Backend, newS3Backend)
+}
+
+// s3Backend persists each key of a bucket to the object
+// <prefix><bucket>/<key>. S3 cannot make a write conditional on an object's
+// previous contents, so updates are only serialized within the process and
+// the state must not be shared by controllers that are active at the same
+// time.
+type s3Backend struct {
+	client    *s3.S3
+	bucket    string
+	prefix    string
+	timeout   time.Duration
+	updateRwl sync.Mutex
+}
+
+func newS3Backend(config gofig.Config) (Backend, error) {
+	bucket := config.GetString("rexray.state.s3.bucket")
+	if bucket == "" {
+		return nil, goof.New("s3 backend requires rexray.state.s3.bucket")
+	}
+	c := &aws.Config{}
+	if v := config.GetString("rexray.state.s3.region"); v != "" {
+		c.Region = aws.String(v)
+	}
+	if v := config.GetString("rexray.state.s3.endpoint"); v != "" {
+		c.Endpoint = aws.String(v)
+		c.S3ForcePathStyle = aws.Bool(true)
+	}
+	sess, err := session.NewSession(c)
+	if err != nil {
+		return nil, err
+	}
+	return &s3Backend{
+		client:  s3.New(sess),
+		bucket:  bucket,
+		prefix:  config.GetString("rexray.state.s3.prefix"),
+		timeout: requestTimeout(config),
+	}, nil
+}
+
+func (b *s3Backend) bucketPrefix(bucket string) string {
+	return b.prefix + bucket + "/"
+}
+
+func (b *s3Backend) context() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), b.timeout)
+}
+
+func (b *s3Backend) Get(bucket, key string) ([]byte, bool, error) {
+	ctx, cancel := b.context()
+	defer cancel()
+	out, err := b.client.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(b.bucket),
+		Key:    aws.String(b.bucketPrefix(bucket) + key),
+	})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok &&
+			aerr.Code() == s3.ErrCodeNoSuchKey {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	defer out.Body.Close()
+	value, err := ioutil.ReadAll(out.Body)
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+func (b *s3Backend) Put(bucket, key string, value []byte) error {
+	ctx, cancel := b.context()
+	defer cancel()
+	_, err := b.client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(b.bucket),
+		Key:         aws.String(b.bucketPrefix(bucket) + key),
+		Body:        bytes.NewReader(value),
+		ContentType: aws.String("application/json"),
+	})
+	return err
+}
+
+func (b *s3Backend) Delete(bucket, key string) error {
+	ctx, cancel := b.context()
+	defer cancel()
+	_, err := b.client.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(b.bucket),
+		Key:    aws.String(b.bucketPrefix(bucket) + key),
+	})
+	return err
+}
+
+func (b *s3Backend) Update(
+	bucket, key string,
+	fn func(value []byte, ok bool) ([]byte, bool, error)) error {
+
+	b.updateRwl.Lock()
+	defer b.updateRwl.Unlock()
+
+	raw, ok, err := b.Get(bucket, key)
+	if err != nil {
+		return err
+	}
+	value, keep, err := fn(raw, ok)
+	if err != nil {
+		return err
+	}
+	if !keep {
+		if !ok {
+			return nil
+		}
+		return b.Delete(bucket, key)
+	}
+	return b.Put(bucket, key, value)
+}
+
+func (b *s3Backend) Keys(bucket string) ([]string, error) {
+	ctx, cancel := b.context()
+	defer cancel()
+	p := b.bucketPrefix(bucket)
+	keys := []string{}
+	err := b.client.ListObjectsV2PagesWithContext(ctx,
+		&s3.ListObjectsV2Input{
+			Bucket: aws.String(b.bucket),
+			Prefix: aws.String(p),
+		},
+		func(page *s3.ListObjectsV2Output, last bool) bool {
+			for _, o := range page.Contents {
+				keys = append(keys, strings.TrimPrefix(aws.StringValue(o.Key), p))
+			}
+			return true
+		})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
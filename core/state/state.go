@@ -0,0 +1,364 @@
+// Package state provides persistent storage for metadata owned by REX-Ray
+// rather than by a storage platform, such as volume labels, quotas,
+// schedules, tasks, or trash entries.
+//
+// A store's buckets are persisted by a backend. The default backend is a JSON
+// file in the REX-Ray lib directory, which suits a single controller. A
+// controller that should survive the loss of its host, or several controllers
+// that share their state, use the bolt, etcd, consul, or s3 backend, which is
+// selected with rexray.state.backend.
+//
+// The data that belongs to a single host, such as the journal of the mount
+// operations in progress on it, is kept in the host's local store, since the
+// etcd, consul, and s3 backends may be shared by every host of a fleet. The
+// data that the controller keeps on behalf of every host, such as the nodes'
+// heartbeats, is read and written through the controller's endpoint unless
+// the backend is shared.
+package state
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+
+	"github.com/emccode/rexray/util"
+)
+
+const (
+	defaultFileName = "state.json"
+	localFileName   = "local.json"
+
+	// FileBackend is the name of the backend that persists the buckets to a
+	// JSON file.
+	FileBackend = "file"
+)
+
+func init() {
+	r := gofig.NewRegistration("State")
+	r.Key(gofig.String, "", FileBackend,
+		"The backend that persists the state: file, bolt, etcd, consul, or s3",
+		"rexray.state.backend")
+	r.Key(gofig.String, "", "",
+		"The file that holds the state; defaults to state.json or state.db "+
+			"in the lib directory",
+		"rexray.state.path")
+	r.Key(gofig.String, "", "",
+		"The etcd endpoints, separated by spaces",
+		"rexray.state.etcd.endpoints")
+	r.Key(gofig.String, "", "",
+		"The user name with which etcd requests are authenticated",
+		"rexray.state.etcd.username")
+	r.Key(gofig.String, "", "",
+		"The password with which etcd requests are authenticated",
+		"rexray.state.etcd.password")
+	r.Key(gofig.String, "", "/rexray/state/",
+		"The prefix of the state's etcd keys",
+		"rexray.state.etcd.prefix")
+	r.Key(gofig.String, "", "",
+		"The address of the Consul agent; defaults to CONSUL_HTTP_ADDR",
+		"rexray.state.consul.address")
+	r.Key(gofig.String, "", "",
+		"The ACL token with which Consul requests are authenticated",
+		"rexray.state.consul.token")
+	r.Key(gofig.String, "", "rexray/state/",
+		"The prefix of the state's Consul keys",
+		"rexray.state.consul.prefix")
+	r.Key(gofig.String, "", "",
+		"The S3 bucket that holds the state",
+		"rexray.state.s3.bucket")
+	r.Key(gofig.String, "", "rexray/state/",
+		"The prefix of the state's S3 object keys",
+		"rexray.state.s3.prefix")
+	r.Key(gofig.String, "", "",
+		"The region of the S3 bucket",
+		"rexray.state.s3.region")
+	r.Key(gofig.String, "", "",
+		"The S3 endpoint if not AWS, ex. a MinIO server",
+		"rexray.state.s3.endpoint")
+	r.Key(gofig.String, "", "10s",
+		"How long to wait for a request to a remote backend",
+		"rexray.state.timeout")
+	r.Key(gofig.String, "", "",
+		"The token with which requests for the controller's state are "+
+			"authenticated",
+		"rexray.controller.token")
+	gofig.Register(r)
+}
+
+// Backend persists the buckets of a store. Values are the JSON encoding of
+// the stored values.
+type Backend interface {
+
+	// Get returns the value of the given bucket and key. The returned flag is
+	// false if the key does not exist.
+	Get(bucket, key string) ([]byte, bool, error)
+
+	// Put stores the value of the given bucket and key.
+	Put(bucket, key string, value []byte) error
+
+	// Delete removes the given key from the bucket. Removing a key that does
+	// not exist is not an error.
+	Delete(bucket, key string) error
+
+	// Keys returns the sorted keys in the given bucket.
+	Keys(bucket string) ([]string, error)
+
+	// Update calls fn with the value of the given bucket and key and a flag
+	// indicating whether the key exists. If fn returns true the returned
+	// value is stored as the key's new value, otherwise the key is removed.
+	// The update fails or is retried if the key changes between the read
+	// and the write, so fn may be called more than once.
+	Update(
+		bucket, key string,
+		fn func(value []byte, ok bool) ([]byte, bool, error)) error
+}
+
+// NewBackend is a function that returns a new backend.
+type NewBackend func(config gofig.Config) (Backend, error)
+
+var backends = map[string]NewBackend{}
+
+// RegisterBackend registers a backend.
+func RegisterBackend(name string, ctor NewBackend) {
+	backends[strings.ToLower(name)] = ctor
+}
+
+func newBackend(config gofig.Config) (Backend, error) {
+	name := FileBackend
+	if config != nil {
+		if v := config.GetString("rexray.state.backend"); v != "" {
+			name = strings.ToLower(v)
+		}
+	}
+	if name == FileBackend {
+		path := util.LibFilePath(defaultFileName)
+		if config != nil {
+			if v := config.GetString("rexray.state.path"); v != "" {
+				path = v
+			}
+		}
+		return newFileBackend(path), nil
+	}
+	ctor, ok := backends[name]
+	if !ok {
+		return nil, goof.WithField("backend", name, "invalid state backend")
+	}
+	b, err := ctor(config)
+	if err != nil {
+		return nil, goof.WithFieldE("backend", name,
+			"error opening state backend", err)
+	}
+	return b, nil
+}
+
+// requestTimeout returns how long to wait for a request to a remote backend.
+func requestTimeout(config gofig.Config) time.Duration {
+	d, err := time.ParseDuration(config.GetString("rexray.state.timeout"))
+	if err != nil || d <= 0 {
+		return 10 * time.Second
+	}
+	return d
+}
+
+// Store is a persistent key/value store organized into buckets.
+type Store struct {
+	open    func() (Backend, error)
+	once    sync.Once
+	backend Backend
+	err     error
+}
+
+var (
+	defaultStore    *Store
+	localStore      *Store
+	defaultConfig   gofig.Config
+	defaultStoreRwl sync.Mutex
+)
+
+// Configure sets the configuration with which the default store's backend is
+// opened. It is called when the configuration is loaded, before the default
+// store is used.
+func Configure(config gofig.Config) {
+	defaultStoreRwl.Lock()
+	defer defaultStoreRwl.Unlock()
+	defaultConfig = config
+	defaultStore = nil
+	localStore = nil
+}
+
+// Default returns the store whose backend is chosen by the configuration, or
+// the store located in the REX-Ray lib directory if the store has not been
+// configured. The backend is opened when the store is first used.
+func Default() *Store {
+	defaultStoreRwl.Lock()
+	defer defaultStoreRwl.Unlock()
+	if defaultStore == nil {
+		config := defaultConfig
+		defaultStore = &Store{open: func() (Backend, error) {
+			return newBackend(config)
+		}}
+	}
+	return defaultStore
+}
+
+// Local returns the store of the data that belongs to this host. It is the
+// default store unless the configured backend may be shared by several
+// hosts, in which case it is a JSON file in the REX-Ray lib directory.
+func Local() *Store {
+	defaultStoreRwl.Lock()
+	config := defaultConfig
+	defaultStoreRwl.Unlock()
+	if !Shared(config) {
+		return Default()
+	}
+
+	defaultStoreRwl.Lock()
+	defer defaultStoreRwl.Unlock()
+	if localStore == nil {
+		localStore = Open(util.LibFilePath(localFileName))
+	}
+	return localStore
+}
+
+// Shared returns a flag indicating whether the configured backend may be
+// shared by several hosts. The file and bolt backends are files on the host.
+func Shared(config gofig.Config) bool {
+	if config == nil {
+		return false
+	}
+	switch strings.ToLower(config.GetString("rexray.state.backend")) {
+	case "", FileBackend, BoltBackend:
+		return false
+	}
+	return true
+}
+
+// Open returns a store persisted to the JSON file at the provided path.
+func Open(path string) *Store {
+	return New(newFileBackend(path))
+}
+
+// New returns a store persisted by the provided backend.
+func New(b Backend) *Store {
+	return &Store{backend: b}
+}
+
+func (s *Store) getBackend() (Backend, error) {
+	s.once.Do(func() {
+		if s.backend == nil {
+			s.backend, s.err = s.open()
+		}
+	})
+	return s.backend, s.err
+}
+
+// Get reads the value for the given bucket and key into v. The returned flag
+// is false if the key does not exist.
+func (s *Store) Get(bucket, key string, v interface{}) (bool, error) {
+	b, err := s.getBackend()
+	if err != nil {
+		return false, err
+	}
+
+	raw, ok, err := b.Get(bucket, key)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	return true, json.Unmarshal(raw, v)
+}
+
+// Set stores v as the value for the given bucket and key.
+func (s *Store) Set(bucket, key string, v interface{}) error {
+	b, err := s.getBackend()
+	if err != nil {
+		return err
+	}
+
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return b.Put(bucket, key, raw)
+}
+
+// Delete removes the given key from the bucket.
+func (s *Store) Delete(bucket, key string) error {
+	b, err := s.getBackend()
+	if err != nil {
+		return err
+	}
+	return b.Delete(bucket, key)
+}
+
+// Update reads the value for the given bucket and key into v and calls fn
+// with a flag indicating whether the key exists. If fn returns true v is
+// stored as the key's new value, otherwise the key is removed. No other
+// update to the store may occur between the read and the write.
+//
+// The backend of a shared store retries the update if another controller
+// updates the key first, in which case v is restored to the value it had
+// when Update was called before the key is read again.
+func (s *Store) Update(
+	bucket, key string, v interface{}, fn func(ok bool) (bool, error)) error {
+
+	b, err := s.getBackend()
+	if err != nil {
+		return err
+	}
+
+	initial, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	attempts := 0
+	return b.Update(bucket, key,
+		func(raw []byte, ok bool) ([]byte, bool, error) {
+			if attempts > 0 {
+				if err := reset(v, initial); err != nil {
+					return nil, false, err
+				}
+			}
+			attempts++
+
+			if ok {
+				if err := json.Unmarshal(raw, v); err != nil {
+					return nil, false, err
+				}
+			}
+
+			keep, err := fn(ok)
+			if err != nil || !keep {
+				return nil, false, err
+			}
+
+			raw, err = json.Marshal(v)
+			if err != nil {
+				return nil, false, err
+			}
+			return raw, true, nil
+		})
+}
+
+// reset restores the value v points to from its JSON encoding.
+func reset(v interface{}, raw []byte) error {
+	rv := reflect.ValueOf(v).Elem()
+	rv.Set(reflect.Zero(rv.Type()))
+	return json.Unmarshal(raw, v)
+}
+
+// Keys returns the sorted keys in the given bucket.
+func (s *Store) Keys(bucket string) ([]string, error) {
+	b, err := s.getBackend()
+	if err != nil {
+		return nil, err
+	}
+	return b.Keys(bucket)
+}
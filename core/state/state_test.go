@@ -0,0 +1,193 @@
+package state
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/akutz/gofig"
+)
+
+func newTestStore(t *testing.T) (*Store, func()) {
+	tmpDir, err := ioutil.TempDir("", "rexray-state_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Open(path.Join(tmpDir, "state.json")), func() {
+		os.RemoveAll(tmpDir)
+	}
+}
+
+func TestGetSetDelete(t *testing.T) {
+	s, cleanup := newTestStore(t)
+	defer cleanup()
+
+	var v string
+	ok, err := s.Get("bucket", "key", &v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		t.Fatal("key exists before set")
+	}
+
+	if err := s.Set("bucket", "key", "value"); err != nil {
+		t.Fatal(err)
+	}
+
+	ok, err = s.Get("bucket", "key", &v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok || v != "value" {
+		t.Fatalf("ok=%v, v=%s", ok, v)
+	}
+
+	if err := s.Delete("bucket", "key"); err != nil {
+		t.Fatal(err)
+	}
+
+	ok, err = s.Get("bucket", "key", &v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		t.Fatal("key exists after delete")
+	}
+}
+
+func TestKeys(t *testing.T) {
+	s, cleanup := newTestStore(t)
+	defer cleanup()
+
+	for _, k := range []string{"c", "a", "b"} {
+		if err := s.Set("bucket", k, k); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Set("other", "d", "d"); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := s.Keys("bucket")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 3 || keys[0] != "a" || keys[1] != "b" || keys[2] != "c" {
+		t.Fatalf("keys=%v", keys)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	s, cleanup := newTestStore(t)
+	defer cleanup()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			m := map[string]bool{}
+			if err := s.Update("bucket", "key", &m, func(bool) (bool, error) {
+				m[strconv.Itoa(i)] = true
+				return true, nil
+			}); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	m := map[string]bool{}
+	if _, err := s.Get("bucket", "key", &m); err != nil {
+		t.Fatal(err)
+	}
+	if len(m) != 20 {
+		t.Fatalf("len=%d, m=%v", len(m), m)
+	}
+
+	if err := s.Update("bucket", "key", &m, func(ok bool) (bool, error) {
+		if !ok {
+			t.Error("key does not exist before removal")
+		}
+		return false, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	ok, err := s.Get("bucket", "key", &m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		t.Fatal("key exists after removal")
+	}
+}
+
+// retryBackend calls an update's function twice as a backend does when
+// another controller updates the key between the read and the write.
+type retryBackend struct {
+	*fileBackend
+}
+
+func (b *retryBackend) Update(
+	bucket, key string,
+	fn func(value []byte, ok bool) ([]byte, bool, error)) error {
+
+	raw, ok, err := b.Get(bucket, key)
+	if err != nil {
+		return err
+	}
+	if _, _, err := fn(raw, ok); err != nil {
+		return err
+	}
+	return b.fileBackend.Update(bucket, key, fn)
+}
+
+func TestUpdateRetry(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "rexray-state_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	s := New(&retryBackend{newFileBackend(path.Join(tmpDir, "state.json"))})
+
+	if err := s.Set("bucket", "key", map[string]bool{"a": true}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the first call's changes must not leak into the value that is stored
+	calls := 0
+	m := map[string]bool{}
+	if err := s.Update("bucket", "key", &m, func(bool) (bool, error) {
+		calls++
+		m[strconv.Itoa(calls)] = true
+		return true, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	m = map[string]bool{}
+	if _, err := s.Get("bucket", "key", &m); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 || len(m) != 2 || !m["a"] || !m["2"] {
+		t.Fatalf("calls=%d, m=%v", calls, m)
+	}
+}
+
+func TestLocal(t *testing.T) {
+	config := gofig.New()
+	Configure(config)
+	if Local() != Default() {
+		t.Fatal("file store is not local")
+	}
+
+	config.Set("rexray.state.backend", "etcd")
+	Configure(config)
+	if Local() == Default() || !Shared(config) {
+		t.Fatal("shared store is local")
+	}
+}
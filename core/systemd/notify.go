@@ -0,0 +1,93 @@
+package systemd
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	apitypes "github.com/emccode/libstorage/api/types"
+)
+
+const (
+	// Ready tells systemd that the service has started.
+	Ready = "READY=1"
+
+	// Stopping tells systemd that the service is shutting down.
+	Stopping = "STOPPING=1"
+
+	// WatchdogPing tells systemd that the service is alive.
+	WatchdogPing = "WATCHDOG=1"
+)
+
+// Notify sends the provided state to systemd. The returned flag is false if
+// the service was not started by systemd with a notification socket.
+func Notify(state string) (bool, error) {
+	sock := os.Getenv("NOTIFY_SOCKET")
+	if sock == "" {
+		return false, nil
+	}
+	addr := &net.UnixAddr{Name: sock, Net: "unixgram"}
+	if sock[0] == '@' {
+		// an abstract socket
+		addr.Name = "\x00" + sock[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, addr)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// WatchdogInterval returns the interval within which systemd expects the
+// service to ping its watchdog, or 0 if the watchdog is not enabled for the
+// process.
+func WatchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" &&
+		pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond
+}
+
+// Watchdog pings systemd's watchdog at half of its interval until stop is
+// closed. The watchdog is only pinged while check succeeds, so a service that
+// has hung or can no longer serve requests is restarted by systemd.
+func Watchdog(
+	ctx apitypes.Context,
+	check func() error,
+	stop <-chan struct{}) {
+
+	interval := WatchdogInterval()
+	if interval == 0 {
+		return
+	}
+	ctx.WithField("interval", interval).Info("pinging systemd watchdog")
+
+	t := time.NewTicker(interval / 2)
+	defer t.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+			if err := check(); err != nil {
+				ctx.WithError(err).Warn(
+					"service is unhealthy; not pinging systemd watchdog")
+				continue
+			}
+			if _, err := Notify(WatchdogPing); err != nil {
+				ctx.WithError(err).Warn("error pinging systemd watchdog")
+			}
+		}
+	}
+}
@@ -0,0 +1,125 @@
+// Package systemd integrates the REX-Ray service with systemd. The service
+// accepts the sockets systemd passes to it with socket activation and tells
+// systemd when it is ready, when it is stopping, and that it is still alive
+// so that a hung service is restarted.
+package systemd
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// listenFdsStart is the first file descriptor passed by systemd.
+const listenFdsStart = 3
+
+var (
+	inheritedOnce sync.Once
+	inheritedLock sync.Mutex
+	inherited     []net.Listener
+)
+
+// Activated returns a flag indicating whether systemd passed any sockets to
+// the process.
+func Activated() bool {
+	loadInherited()
+	inheritedLock.Lock()
+	defer inheritedLock.Unlock()
+	return len(inherited) > 0
+}
+
+// Listen returns the socket passed by systemd for the provided address if
+// there is one, and otherwise a new listener. The returned flag indicates
+// whether the socket was passed by systemd, in which case the socket file of
+// a unix address belongs to systemd and must not be removed.
+func Listen(proto, laddr string) (net.Listener, bool, error) {
+	loadInherited()
+
+	inheritedLock.Lock()
+	for i, l := range inherited {
+		if matches(l.Addr(), proto, laddr) {
+			inherited = append(inherited[:i], inherited[i+1:]...)
+			inheritedLock.Unlock()
+			return l, true, nil
+		}
+	}
+	inheritedLock.Unlock()
+
+	if proto == "unix" {
+		os.Remove(laddr)
+	}
+	l, err := net.Listen(proto, laddr)
+	return l, false, err
+}
+
+// loadInherited wraps the sockets passed by systemd in listeners. The
+// environment variables are unset so that they are not inherited by child
+// processes.
+func loadInherited() {
+	inheritedOnce.Do(func() {
+		defer func() {
+			os.Unsetenv("LISTEN_PID")
+			os.Unsetenv("LISTEN_FDS")
+			os.Unsetenv("LISTEN_FDNAMES")
+		}()
+
+		pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+		if err != nil || pid != os.Getpid() {
+			return
+		}
+		n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+		if err != nil || n <= 0 {
+			return
+		}
+
+		for fd := listenFdsStart; fd < listenFdsStart+n; fd++ {
+			f := os.NewFile(uintptr(fd), "LISTEN_FD_"+strconv.Itoa(fd))
+			l, err := net.FileListener(f)
+			f.Close()
+			if err != nil {
+				// a datagram socket or a socket that is not listening
+				continue
+			}
+			inherited = append(inherited, l)
+		}
+	})
+}
+
+// matches returns a flag indicating whether the address of a listener is the
+// provided address. A TCP address without a host, or with an unspecified
+// host, matches a listener with the same port on any host.
+func matches(addr net.Addr, proto, laddr string) bool {
+	switch proto {
+	case "unix":
+		return addr.Network() == "unix" && addr.String() == laddr
+	case "tcp", "tcp4", "tcp6":
+		ta, ok := addr.(*net.TCPAddr)
+		if !ok {
+			return false
+		}
+		host, port, err := net.SplitHostPort(laddr)
+		if err != nil || port != strconv.Itoa(ta.Port) {
+			return false
+		}
+		if host == "" || host == "*" {
+			return true
+		}
+		ip := net.ParseIP(strings.Trim(host, "[]"))
+		if ip == nil {
+			ips, err := net.LookupIP(host)
+			if err != nil {
+				return false
+			}
+			for _, i := range ips {
+				if i.Equal(ta.IP) {
+					return true
+				}
+			}
+			return false
+		}
+		return ip.IsUnspecified() && ta.IP.IsUnspecified() || ip.Equal(ta.IP)
+	}
+	return false
+}
@@ -0,0 +1,35 @@
+package systemd
+
+import (
+	"net"
+	"testing"
+)
+
+func TestMatches(t *testing.T) {
+	tcp := &net.TCPAddr{IP: net.IPv6unspecified, Port: 7979}
+	local := &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 7979}
+	sock := &net.UnixAddr{Name: "/run/docker/plugins/rexray.sock", Net: "unix"}
+
+	tests := []struct {
+		addr  net.Addr
+		proto string
+		laddr string
+		match bool
+	}{
+		{tcp, "tcp", ":7979", true},
+		{tcp, "tcp", "0.0.0.0:7979", true},
+		{tcp, "tcp", "127.0.0.1:7979", false},
+		{tcp, "tcp", ":7980", false},
+		{local, "tcp", "127.0.0.1:7979", true},
+		{local, "tcp", "[::1]:7979", false},
+		{local, "unix", "127.0.0.1:7979", false},
+		{sock, "unix", "/run/docker/plugins/rexray.sock", true},
+		{sock, "unix", "/run/docker/plugins/ebs.sock", false},
+		{sock, "tcp", ":7979", false},
+	}
+	for _, tt := range tests {
+		if m := matches(tt.addr, tt.proto, tt.laddr); m != tt.match {
+			t.Errorf("%s %s://%s: match=%v", tt.addr, tt.proto, tt.laddr, m)
+		}
+	}
+}
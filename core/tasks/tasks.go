@@ -0,0 +1,314 @@
+// Package tasks runs long volume operations in the background and persists
+// their progress so that operations interrupted by a restart of the REX-Ray
+// service may be resumed.
+package tasks
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/core/errcodes"
+	"github.com/emccode/rexray/core/state"
+)
+
+// State is the state of a task.
+type State string
+
+const (
+	// Queued indicates a task has been submitted but has not started.
+	Queued State = "queued"
+
+	// Running indicates a task is in progress.
+	Running State = "running"
+
+	// Success indicates a task completed successfully.
+	Success State = "success"
+
+	// Failed indicates a task completed with an error.
+	Failed State = "failed"
+
+	tasksBucket = "tasks"
+	metaBucket  = "taskMeta"
+	lastIDKey   = "lastID"
+)
+
+// Task is a long operation that runs in the background.
+type Task struct {
+
+	// ID is the task's unique identifier.
+	ID int64 `json:"id"`
+
+	// Operation is the name of the operation the task performs.
+	Operation string `json:"operation"`
+
+	// Params are the operation's parameters.
+	Params map[string]string `json:"params,omitempty"`
+
+	// State is the task's state.
+	State State `json:"state"`
+
+	// Progress is the percentage of the task that is complete.
+	Progress int `json:"progress"`
+
+	// Step is the index of the operation's step that is in progress. A task
+	// that is resumed begins with this step.
+	Step int `json:"step"`
+
+	// Result is the result of the operation once it completes successfully.
+	Result json.RawMessage `json:"result,omitempty"`
+
+	// Error is the error that caused the task to fail.
+	Error string `json:"error,omitempty"`
+
+	// Resumed is the number of times the task was resumed after it was
+	// interrupted.
+	Resumed int `json:"resumed,omitempty"`
+
+	// QueueTime is the time at which the task was submitted.
+	QueueTime time.Time `json:"queueTime"`
+
+	// StartTime is the time at which the task started.
+	StartTime time.Time `json:"startTime,omitempty"`
+
+	// CompleteTime is the time at which the task completed.
+	CompleteTime time.Time `json:"completeTime,omitempty"`
+}
+
+// IsDone returns a flag indicating whether or not the task has completed.
+func (t *Task) IsDone() bool {
+	return t.State == Success || t.State == Failed
+}
+
+// Progress records that an operation has reached the provided step of the
+// total number of steps it performs.
+type Progress func(step, total int) error
+
+// Operation performs a task. A task that was interrupted is resumed by
+// invoking its operation again with the task's last recorded step, so an
+// operation must be able to begin at any of its steps.
+type Operation func(
+	ctx apitypes.Context,
+	config gofig.Config,
+	client apitypes.Client,
+	t *Task,
+	progress Progress) (interface{}, error)
+
+var (
+	ops    = map[string]Operation{}
+	opsRwl sync.RWMutex
+)
+
+// Register registers an operation.
+func Register(name string, op Operation) {
+	opsRwl.Lock()
+	defer opsRwl.Unlock()
+	ops[name] = op
+}
+
+func getOperation(name string) (Operation, bool) {
+	opsRwl.RLock()
+	defer opsRwl.RUnlock()
+	op, ok := ops[name]
+	return op, ok
+}
+
+// Manager runs tasks.
+type Manager struct {
+	ctx    apitypes.Context
+	config gofig.Config
+	client apitypes.Client
+	store  *state.Store
+	idLock sync.Mutex
+}
+
+// NewManager returns a new task manager that performs operations with the
+// provided configuration and client.
+func NewManager(
+	ctx apitypes.Context,
+	config gofig.Config,
+	client apitypes.Client,
+	store *state.Store) *Manager {
+
+	return &Manager{ctx: ctx, config: config, client: client, store: store}
+}
+
+// Submit queues a new task for the provided operation and runs it in the
+// background.
+func (m *Manager) Submit(
+	operation string, params map[string]string) (*Task, error) {
+
+	op, ok := getOperation(operation)
+	if !ok {
+		return nil, goof.WithField("operation", operation, "unknown operation")
+	}
+
+	id, err := m.nextID()
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Task{
+		ID:        id,
+		Operation: operation,
+		Params:    params,
+		State:     Queued,
+		QueueTime: time.Now().UTC(),
+	}
+	if err := Put(m.store, t); err != nil {
+		return nil, err
+	}
+
+	go m.run(t, op)
+	return t, nil
+}
+
+// Resume restarts the tasks that were queued or running when the REX-Ray
+// service last stopped.
+func (m *Manager) Resume() error {
+	all, err := List(m.store)
+	if err != nil {
+		return err
+	}
+
+	for _, t := range all {
+		if t.IsDone() {
+			continue
+		}
+
+		fields := map[string]interface{}{
+			"taskID":    t.ID,
+			"operation": t.Operation,
+			"step":      t.Step,
+		}
+
+		op, ok := getOperation(t.Operation)
+		if !ok {
+			m.ctx.WithFields(fields).Error("cannot resume unknown operation")
+			m.complete(t, nil, goof.New("unknown operation"))
+			continue
+		}
+
+		m.ctx.WithFields(fields).Warn("resuming interrupted task")
+		t.Resumed++
+		go m.run(t, op)
+	}
+	return nil
+}
+
+func (m *Manager) run(t *Task, op Operation) {
+	t.State = Running
+	if t.StartTime.IsZero() {
+		t.StartTime = time.Now().UTC()
+	}
+	if err := Put(m.store, t); err != nil {
+		m.ctx.WithError(err).WithField("taskID", t.ID).Error(
+			"error recording task")
+	}
+
+	ctx := m.ctx.WithValue("taskID", t.ID)
+
+	result, err := op(ctx, m.config, m.client, t, func(step, total int) error {
+		t.Step = step
+		if total > 0 {
+			t.Progress = step * 100 / total
+		}
+		return Put(m.store, t)
+	})
+
+	m.complete(t, result, err)
+}
+
+func (m *Manager) complete(t *Task, result interface{}, err error) {
+	fields := map[string]interface{}{
+		"taskID":    t.ID,
+		"operation": t.Operation,
+	}
+
+	t.CompleteTime = time.Now().UTC()
+	if err != nil {
+		t.State = Failed
+		t.Error = err.Error()
+		m.ctx.WithFields(fields).WithError(err).Error("task failed")
+	} else {
+		t.State = Success
+		t.Progress = 100
+		if result != nil {
+			if buf, merr := json.Marshal(result); merr == nil {
+				t.Result = buf
+			}
+		}
+		m.ctx.WithFields(fields).Info("task completed")
+	}
+
+	if err := Put(m.store, t); err != nil {
+		m.ctx.WithFields(fields).WithError(err).Error("error recording task")
+	}
+}
+
+func (m *Manager) nextID() (int64, error) {
+	m.idLock.Lock()
+	defer m.idLock.Unlock()
+
+	var id int64
+	if _, err := m.store.Get(metaBucket, lastIDKey, &id); err != nil {
+		return 0, err
+	}
+	id++
+	if err := m.store.Set(metaBucket, lastIDKey, id); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// Get returns the task with the provided ID.
+func Get(s *state.Store, id int64) (*Task, error) {
+	t := &Task{}
+	ok, err := s.Get(tasksBucket, strconv.FormatInt(id, 10), t)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errcodes.New(errcodes.NotFound,
+			goof.WithField("taskID", id, "task not found"))
+	}
+	return t, nil
+}
+
+// Put records the provided task.
+func Put(s *state.Store, t *Task) error {
+	return s.Set(tasksBucket, strconv.FormatInt(t.ID, 10), t)
+}
+
+// List returns all of the recorded tasks ordered by ID.
+func List(s *state.Store) ([]*Task, error) {
+	keys, err := s.Keys(tasksBucket)
+	if err != nil {
+		return nil, err
+	}
+
+	all := make([]*Task, 0, len(keys))
+	for _, k := range keys {
+		t := &Task{}
+		if _, err := s.Get(tasksBucket, k, t); err != nil {
+			return nil, err
+		}
+		all = append(all, t)
+	}
+
+	// the keys are sorted as strings rather than numbers
+	sort.Sort(byID(all))
+	return all, nil
+}
+
+type byID []*Task
+
+func (t byID) Len() int           { return len(t) }
+func (t byID) Swap(i, j int)      { t[i], t[j] = t[j], t[i] }
+func (t byID) Less(i, j int) bool { return t[i].ID < t[j].ID }
@@ -0,0 +1,149 @@
+package tasks
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	apitypes "github.com/emccode/libstorage/api/types"
+	apiutils "github.com/emccode/libstorage/api/utils"
+
+	"github.com/emccode/rexray/core/errcodes"
+	"github.com/emccode/rexray/core/migrate"
+)
+
+// The names of the volume operations that may be run as tasks.
+const (
+	OpVolumeResize             = "volume.resize"
+	OpVolumeCopy               = "volume.copy"
+	OpVolumeCreateFromSnapshot = "volume.createFromSnapshot"
+)
+
+func init() {
+	Register(OpVolumeResize, volumeResize)
+	Register(OpVolumeCopy, volumeCopy)
+	Register(OpVolumeCreateFromSnapshot, volumeCreateFromSnapshot)
+}
+
+// volumeResize resizes the volume named by the volumeName parameter to the
+// size parameter. The fsType parameter is optional.
+func volumeResize(
+	ctx apitypes.Context,
+	config gofig.Config,
+	client apitypes.Client,
+	t *Task,
+	progress Progress) (interface{}, error) {
+
+	size, err := strconv.ParseInt(t.Params["size"], 10, 64)
+	if err != nil {
+		return nil, goof.WithFieldE("size", t.Params["size"], "invalid size", err)
+	}
+
+	opts := &migrate.ResizeOpts{
+		Size:         size,
+		FSType:       t.Params["fsType"],
+		Opts:         apiutils.NewStore(),
+		Progress:     progress,
+		TempVolumeID: t.Params["tmpVolumeID"],
+	}
+
+	// the temporary volume's ID is recorded with the task's progress so that
+	// a resumed migration finds it by its ID rather than its name
+	opts.TempCreated = func(volumeID string) error {
+		t.Params["tmpVolumeID"] = volumeID
+		return progress(t.Step, migrate.ResizeSteps)
+	}
+
+	// a task that was interrupted after it began migrating the volume's data
+	// resumes the migration since the original volume may no longer exist
+	if t.Step > 0 {
+		return migrate.ResumeResize(
+			ctx, client, t.Params["volumeName"], t.Step, opts)
+	}
+
+	// the name is recorded so that the migration may be resumed
+	volumeID := t.Params["volumeID"]
+	if volumeID == "" {
+		vol, err := volumeByName(ctx, client, t.Params["volumeName"])
+		if err != nil {
+			return nil, err
+		}
+		volumeID = vol.ID
+	} else if t.Params["volumeName"] == "" {
+		vol, err := client.Storage().VolumeInspect(
+			ctx, volumeID, &apitypes.VolumeInspectOpts{})
+		if err != nil {
+			return nil, err
+		}
+		t.Params["volumeName"] = vol.Name
+	}
+	return migrate.Resize(ctx, config, client, volumeID, opts)
+}
+
+// volumeCopy copies the volume with the volumeID parameter to a new volume
+// named by the volumeName parameter.
+func volumeCopy(
+	ctx apitypes.Context,
+	config gofig.Config,
+	client apitypes.Client,
+	t *Task,
+	progress Progress) (interface{}, error) {
+
+	name := t.Params["volumeName"]
+	if t.Resumed > 0 {
+		if vol, err := volumeByName(ctx, client, name); err == nil {
+			return vol, nil
+		}
+	}
+	if err := progress(0, 1); err != nil {
+		return nil, err
+	}
+	return client.Storage().VolumeCopy(
+		ctx, t.Params["volumeID"], name, apiutils.NewStore())
+}
+
+// volumeCreateFromSnapshot creates a volume named by the volumeName parameter
+// from the snapshot with the snapshotID parameter.
+func volumeCreateFromSnapshot(
+	ctx apitypes.Context,
+	config gofig.Config,
+	client apitypes.Client,
+	t *Task,
+	progress Progress) (interface{}, error) {
+
+	name := t.Params["volumeName"]
+	if t.Resumed > 0 {
+		if vol, err := volumeByName(ctx, client, name); err == nil {
+			return vol, nil
+		}
+	}
+	if err := progress(0, 1); err != nil {
+		return nil, err
+	}
+	return client.Storage().VolumeCreateFromSnapshot(
+		ctx, t.Params["snapshotID"], name,
+		&apitypes.VolumeCreateOpts{Opts: apiutils.NewStore()})
+}
+
+// volumeByName returns the volume with the provided name. An operation that
+// is resumed uses it to determine whether the volume it creates already
+// exists.
+func volumeByName(
+	ctx apitypes.Context,
+	client apitypes.Client,
+	name string) (*apitypes.Volume, error) {
+
+	vols, err := client.Storage().Volumes(
+		ctx, &apitypes.VolumesOpts{Attachments: false})
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range vols {
+		if strings.ToLower(v.Name) == strings.ToLower(name) {
+			return v, nil
+		}
+	}
+	return nil, errcodes.New(errcodes.VolumeNotFound,
+		goof.WithField("volumeName", name, "volume not found"))
+}
@@ -0,0 +1,451 @@
+package timeouts
+
+import (
+	"time"
+
+	"github.com/akutz/gofig"
+	apitypes "github.com/emccode/libstorage/api/types"
+)
+
+type client struct {
+	apitypes.Client
+	config gofig.Config
+}
+
+// Wrap returns a libStorage client that bounds the time each storage,
+// integration, and executor operation may take by the timeout of its type.
+// The operations that attach, detach, mount, unmount, or remove a volume are
+// serialized by the volume's ID, or its name if it has no ID, and an
+// abandoned attachment or mount that later succeeds is undone.
+func Wrap(config gofig.Config, c apitypes.Client) apitypes.Client {
+	return &client{Client: c, config: config}
+}
+
+func (c *client) timeout(typ string) time.Duration {
+	return For(c.config, typ)
+}
+
+// volumeKey returns the key by which the operations on a volume are
+// serialized.
+func volumeKey(volumeID, volumeName string) string {
+	if volumeID != "" {
+		return volumeID
+	}
+	return volumeName
+}
+
+func (c *client) Storage() apitypes.StorageDriver {
+	return &storageDriver{StorageDriver: c.Client.Storage(), c: c}
+}
+
+func (c *client) Integration() apitypes.IntegrationDriver {
+	return &integrationDriver{IntegrationDriver: c.Client.Integration(), c: c}
+}
+
+func (c *client) Executor() apitypes.StorageExecutorCLI {
+	return &executor{StorageExecutorCLI: c.Client.Executor(), c: c}
+}
+
+type storageDriver struct {
+	apitypes.StorageDriver
+	c *client
+}
+
+func (d *storageDriver) Volumes(
+	ctx apitypes.Context,
+	opts *apitypes.VolumesOpts) ([]*apitypes.Volume, error) {
+
+	var vols []*apitypes.Volume
+	err := run(ctx, "storage.Volumes", d.c.timeout(Inspect),
+		func(ctx apitypes.Context) (err error) {
+			vols, err = d.StorageDriver.Volumes(ctx, opts)
+			return
+		})
+	if err != nil {
+		return nil, err
+	}
+	return vols, nil
+}
+
+func (d *storageDriver) VolumeInspect(
+	ctx apitypes.Context,
+	volumeID string,
+	opts *apitypes.VolumeInspectOpts) (*apitypes.Volume, error) {
+
+	var vol *apitypes.Volume
+	err := run(ctx, "storage.VolumeInspect", d.c.timeout(Inspect),
+		func(ctx apitypes.Context) (err error) {
+			vol, err = d.StorageDriver.VolumeInspect(ctx, volumeID, opts)
+			return
+		})
+	if err != nil {
+		return nil, err
+	}
+	return vol, nil
+}
+
+func (d *storageDriver) VolumeCreate(
+	ctx apitypes.Context,
+	volumeName string,
+	opts *apitypes.VolumeCreateOpts) (*apitypes.Volume, error) {
+
+	var vol *apitypes.Volume
+	err := run(ctx, "storage.VolumeCreate", d.c.timeout(Create),
+		func(ctx apitypes.Context) (err error) {
+			vol, err = d.StorageDriver.VolumeCreate(ctx, volumeName, opts)
+			return
+		})
+	if err != nil {
+		return nil, err
+	}
+	return vol, nil
+}
+
+func (d *storageDriver) VolumeCreateFromSnapshot(
+	ctx apitypes.Context,
+	snapshotID, volumeName string,
+	opts *apitypes.VolumeCreateOpts) (*apitypes.Volume, error) {
+
+	var vol *apitypes.Volume
+	err := run(ctx, "storage.VolumeCreateFromSnapshot", d.c.timeout(Create),
+		func(ctx apitypes.Context) (err error) {
+			vol, err = d.StorageDriver.VolumeCreateFromSnapshot(
+				ctx, snapshotID, volumeName, opts)
+			return
+		})
+	if err != nil {
+		return nil, err
+	}
+	return vol, nil
+}
+
+func (d *storageDriver) VolumeCopy(
+	ctx apitypes.Context,
+	volumeID, volumeName string,
+	opts apitypes.Store) (*apitypes.Volume, error) {
+
+	var vol *apitypes.Volume
+	err := run(ctx, "storage.VolumeCopy", d.c.timeout(Create),
+		func(ctx apitypes.Context) (err error) {
+			vol, err = d.StorageDriver.VolumeCopy(
+				ctx, volumeID, volumeName, opts)
+			return
+		})
+	if err != nil {
+		return nil, err
+	}
+	return vol, nil
+}
+
+func (d *storageDriver) VolumeSnapshot(
+	ctx apitypes.Context,
+	volumeID, snapshotName string,
+	opts apitypes.Store) (*apitypes.Snapshot, error) {
+
+	var snap *apitypes.Snapshot
+	err := run(ctx, "storage.VolumeSnapshot", d.c.timeout(Snapshot),
+		func(ctx apitypes.Context) (err error) {
+			snap, err = d.StorageDriver.VolumeSnapshot(
+				ctx, volumeID, snapshotName, opts)
+			return
+		})
+	if err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
+
+func (d *storageDriver) VolumeRemove(
+	ctx apitypes.Context,
+	volumeID string,
+	opts apitypes.Store) error {
+
+	return runVolume(ctx, volumeID, "storage.VolumeRemove",
+		d.c.timeout(Remove),
+		func(ctx apitypes.Context) error {
+			return d.StorageDriver.VolumeRemove(ctx, volumeID, opts)
+		}, nil)
+}
+
+func (d *storageDriver) VolumeAttach(
+	ctx apitypes.Context,
+	volumeID string,
+	opts *apitypes.VolumeAttachOpts) (*apitypes.Volume, string, error) {
+
+	var (
+		vol   *apitypes.Volume
+		token string
+	)
+	err := runVolume(ctx, volumeID, "storage.VolumeAttach",
+		d.c.timeout(Attach),
+		func(ctx apitypes.Context) (err error) {
+			vol, token, err = d.StorageDriver.VolumeAttach(ctx, volumeID, opts)
+			return
+		},
+		func(ctx apitypes.Context) error {
+			_, err := d.StorageDriver.VolumeDetach(ctx, volumeID,
+				&apitypes.VolumeDetachOpts{Opts: opts.Opts})
+			return err
+		})
+	if err != nil {
+		return nil, "", err
+	}
+	return vol, token, nil
+}
+
+func (d *storageDriver) VolumeDetach(
+	ctx apitypes.Context,
+	volumeID string,
+	opts *apitypes.VolumeDetachOpts) (*apitypes.Volume, error) {
+
+	var vol *apitypes.Volume
+	err := runVolume(ctx, volumeID, "storage.VolumeDetach",
+		d.c.timeout(Detach),
+		func(ctx apitypes.Context) (err error) {
+			vol, err = d.StorageDriver.VolumeDetach(ctx, volumeID, opts)
+			return
+		}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return vol, nil
+}
+
+func (d *storageDriver) Snapshots(
+	ctx apitypes.Context,
+	opts apitypes.Store) ([]*apitypes.Snapshot, error) {
+
+	var snaps []*apitypes.Snapshot
+	err := run(ctx, "storage.Snapshots", d.c.timeout(Inspect),
+		func(ctx apitypes.Context) (err error) {
+			snaps, err = d.StorageDriver.Snapshots(ctx, opts)
+			return
+		})
+	if err != nil {
+		return nil, err
+	}
+	return snaps, nil
+}
+
+func (d *storageDriver) SnapshotInspect(
+	ctx apitypes.Context,
+	snapshotID string,
+	opts apitypes.Store) (*apitypes.Snapshot, error) {
+
+	var snap *apitypes.Snapshot
+	err := run(ctx, "storage.SnapshotInspect", d.c.timeout(Inspect),
+		func(ctx apitypes.Context) (err error) {
+			snap, err = d.StorageDriver.SnapshotInspect(ctx, snapshotID, opts)
+			return
+		})
+	if err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
+
+func (d *storageDriver) SnapshotCopy(
+	ctx apitypes.Context,
+	snapshotID, snapshotName, destinationID string,
+	opts apitypes.Store) (*apitypes.Snapshot, error) {
+
+	var snap *apitypes.Snapshot
+	err := run(ctx, "storage.SnapshotCopy", d.c.timeout(Snapshot),
+		func(ctx apitypes.Context) (err error) {
+			snap, err = d.StorageDriver.SnapshotCopy(
+				ctx, snapshotID, snapshotName, destinationID, opts)
+			return
+		})
+	if err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
+
+func (d *storageDriver) SnapshotRemove(
+	ctx apitypes.Context,
+	snapshotID string,
+	opts apitypes.Store) error {
+
+	return run(ctx, "storage.SnapshotRemove", d.c.timeout(Remove),
+		func(ctx apitypes.Context) error {
+			return d.StorageDriver.SnapshotRemove(ctx, snapshotID, opts)
+		})
+}
+
+type integrationDriver struct {
+	apitypes.IntegrationDriver
+	c *client
+}
+
+func (d *integrationDriver) List(
+	ctx apitypes.Context,
+	opts apitypes.Store) ([]apitypes.VolumeMapping, error) {
+
+	var vols []apitypes.VolumeMapping
+	err := run(ctx, "integration.List", d.c.timeout(Inspect),
+		func(ctx apitypes.Context) (err error) {
+			vols, err = d.IntegrationDriver.List(ctx, opts)
+			return
+		})
+	if err != nil {
+		return nil, err
+	}
+	return vols, nil
+}
+
+func (d *integrationDriver) Inspect(
+	ctx apitypes.Context,
+	volumeName string,
+	opts apitypes.Store) (apitypes.VolumeMapping, error) {
+
+	var vol apitypes.VolumeMapping
+	err := run(ctx, "integration.Inspect", d.c.timeout(Inspect),
+		func(ctx apitypes.Context) (err error) {
+			vol, err = d.IntegrationDriver.Inspect(ctx, volumeName, opts)
+			return
+		})
+	if err != nil {
+		return nil, err
+	}
+	return vol, nil
+}
+
+func (d *integrationDriver) Mount(
+	ctx apitypes.Context,
+	volumeID, volumeName string,
+	opts *apitypes.VolumeMountOpts) (string, *apitypes.Volume, error) {
+
+	var (
+		mountPath string
+		vol       *apitypes.Volume
+	)
+	err := runVolume(ctx, volumeKey(volumeID, volumeName),
+		"integration.Mount", d.c.timeout(Mount),
+		func(ctx apitypes.Context) (err error) {
+			mountPath, vol, err = d.IntegrationDriver.Mount(
+				ctx, volumeID, volumeName, opts)
+			return
+		},
+		func(ctx apitypes.Context) error {
+			return d.IntegrationDriver.Unmount(
+				ctx, volumeID, volumeName, opts.Opts)
+		})
+	if err != nil {
+		return "", nil, err
+	}
+	return mountPath, vol, nil
+}
+
+func (d *integrationDriver) Unmount(
+	ctx apitypes.Context,
+	volumeID, volumeName string,
+	opts apitypes.Store) error {
+
+	return runVolume(ctx, volumeKey(volumeID, volumeName),
+		"integration.Unmount", d.c.timeout(Unmount),
+		func(ctx apitypes.Context) error {
+			return d.IntegrationDriver.Unmount(
+				ctx, volumeID, volumeName, opts)
+		}, nil)
+}
+
+func (d *integrationDriver) Path(
+	ctx apitypes.Context,
+	volumeID, volumeName string,
+	opts apitypes.Store) (string, error) {
+
+	var mountPath string
+	err := run(ctx, "integration.Path", d.c.timeout(Inspect),
+		func(ctx apitypes.Context) (err error) {
+			mountPath, err = d.IntegrationDriver.Path(
+				ctx, volumeID, volumeName, opts)
+			return
+		})
+	if err != nil {
+		return "", err
+	}
+	return mountPath, nil
+}
+
+func (d *integrationDriver) Create(
+	ctx apitypes.Context,
+	volumeName string,
+	opts *apitypes.VolumeCreateOpts) (*apitypes.Volume, error) {
+
+	var vol *apitypes.Volume
+	err := run(ctx, "integration.Create", d.c.timeout(Create),
+		func(ctx apitypes.Context) (err error) {
+			vol, err = d.IntegrationDriver.Create(ctx, volumeName, opts)
+			return
+		})
+	if err != nil {
+		return nil, err
+	}
+	return vol, nil
+}
+
+func (d *integrationDriver) Remove(
+	ctx apitypes.Context,
+	volumeName string,
+	opts apitypes.Store) error {
+
+	return runVolume(ctx, volumeName, "integration.Remove",
+		d.c.timeout(Remove),
+		func(ctx apitypes.Context) error {
+			return d.IntegrationDriver.Remove(ctx, volumeName, opts)
+		}, nil)
+}
+
+type executor struct {
+	apitypes.StorageExecutorCLI
+	c *client
+}
+
+func (e *executor) InstanceID(
+	ctx apitypes.Context,
+	opts apitypes.Store) (*apitypes.InstanceID, error) {
+
+	var iid *apitypes.InstanceID
+	err := run(ctx, "executor.InstanceID", e.c.timeout(Executor),
+		func(ctx apitypes.Context) (err error) {
+			iid, err = e.StorageExecutorCLI.InstanceID(ctx, opts)
+			return
+		})
+	if err != nil {
+		return nil, err
+	}
+	return iid, nil
+}
+
+func (e *executor) NextDevice(
+	ctx apitypes.Context,
+	opts apitypes.Store) (string, error) {
+
+	var dev string
+	err := run(ctx, "executor.NextDevice", e.c.timeout(Executor),
+		func(ctx apitypes.Context) (err error) {
+			dev, err = e.StorageExecutorCLI.NextDevice(ctx, opts)
+			return
+		})
+	if err != nil {
+		return "", err
+	}
+	return dev, nil
+}
+
+func (e *executor) LocalDevices(
+	ctx apitypes.Context,
+	opts *apitypes.LocalDevicesOpts) (*apitypes.LocalDevices, error) {
+
+	var ld *apitypes.LocalDevices
+	err := run(ctx, "executor.LocalDevices", e.c.timeout(Executor),
+		func(ctx apitypes.Context) (err error) {
+			ld, err = e.StorageExecutorCLI.LocalDevices(ctx, opts)
+			return
+		})
+	if err != nil {
+		return nil, err
+	}
+	return ld, nil
+}
@@ -0,0 +1,347 @@
+// Package timeouts bounds the time REX-Ray waits for storage, integration,
+// and executor operations, so that a hung storage platform cannot block a
+// request, such as a Docker volume mount, forever. Each type of operation has
+// its own timeout, and the deadline is carried by the operation's context so
+// that drivers that accept a context may abandon the work as well.
+package timeouts
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/akutz/gofig"
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/core/errcodes"
+)
+
+// The types of operations whose timeouts are configured.
+const (
+	// Inspect is the type of the operations that list or inspect volumes
+	// and snapshots or return a volume's mount path.
+	Inspect = "inspect"
+
+	// Create is the type of the operations that create or copy volumes.
+	Create = "create"
+
+	// Remove is the type of the operations that remove volumes and
+	// snapshots.
+	Remove = "remove"
+
+	// Attach is the type of the operation that attaches a volume.
+	Attach = "attach"
+
+	// Detach is the type of the operation that detaches a volume.
+	Detach = "detach"
+
+	// Mount is the type of the operation that attaches, formats, and mounts
+	// a volume.
+	Mount = "mount"
+
+	// Unmount is the type of the operation that unmounts and detaches a
+	// volume.
+	Unmount = "unmount"
+
+	// Snapshot is the type of the operations that snapshot volumes and copy
+	// snapshots.
+	Snapshot = "snapshot"
+
+	// Executor is the type of the executor's operations, which discover the
+	// instance ID and local devices of a node.
+	Executor = "executor"
+)
+
+// Types are the types of operations whose timeouts are configured.
+var Types = []string{
+	Inspect, Create, Remove, Attach, Detach, Mount, Unmount, Snapshot,
+	Executor,
+}
+
+const defaultTimeout = 10 * time.Minute
+
+func init() {
+	r := gofig.NewRegistration("Operation Timeouts")
+	r.Key(gofig.String, "", defaultTimeout.String(),
+		"How long an operation may take unless its type has a timeout; "+
+			"0 is unlimited",
+		"rexray.timeouts.default")
+	for _, t := range Types {
+		r.Key(gofig.String, "", "",
+			fmt.Sprintf("How long %s operations may take", t),
+			"rexray.timeouts."+t)
+	}
+	gofig.Register(r)
+}
+
+// For returns the timeout of a type of operation, which is zero if the
+// operation's time is unlimited.
+func For(config gofig.Config, typ string) time.Duration {
+	if d, ok := parse(config.GetString("rexray.timeouts." + typ)); ok {
+		return d
+	}
+	if d, ok := parse(config.GetString("rexray.timeouts.default")); ok {
+		return d
+	}
+	return defaultTimeout
+}
+
+// parse parses a timeout. The returned flag is false if the timeout is not
+// set or is invalid.
+func parse(s string) (time.Duration, bool) {
+	if s == "" {
+		return 0, false
+	}
+	if s == "0" {
+		return 0, true
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, false
+	}
+	return d, true
+}
+
+// Error is returned by an operation that did not complete before its
+// deadline or whose request was canceled.
+type Error struct {
+
+	// Operation is the operation that did not complete.
+	Operation string
+
+	// Timeout is the operation's timeout.
+	Timeout time.Duration
+
+	// Canceled is true if the operation's request was canceled, for example
+	// because the client that sent it disconnected, rather than timing out.
+	Canceled bool
+}
+
+func (e *Error) Error() string {
+	if e.Canceled {
+		return fmt.Sprintf("%s canceled", e.Operation)
+	}
+	return fmt.Sprintf("%s timed out after %v", e.Operation, e.Timeout)
+}
+
+// Status returns the HTTP status with which the error is reported.
+func (e *Error) Status() int {
+	return http.StatusGatewayTimeout
+}
+
+// Code returns the code of the error.
+func (e *Error) Code() errcodes.Code {
+	if e.Canceled {
+		return errcodes.Canceled
+	}
+	return errcodes.Timeout
+}
+
+// deadlineContext is a libStorage context whose deadline and cancellation
+// are those of a standard context.
+type deadlineContext struct {
+	apitypes.Context
+	std context.Context
+}
+
+func (c *deadlineContext) Deadline() (time.Time, bool) {
+	return c.std.Deadline()
+}
+
+func (c *deadlineContext) Done() <-chan struct{} {
+	return c.std.Done()
+}
+
+func (c *deadlineContext) Err() error {
+	return c.std.Err()
+}
+
+// WithValue returns a copy of the context with the value that keeps the
+// context's deadline.
+func (c *deadlineContext) WithValue(
+	key, val interface{}) apitypes.Context {
+
+	return &deadlineContext{Context: c.Context.WithValue(key, val), std: c.std}
+}
+
+// WithTimeout returns a copy of the context that is canceled after the
+// timeout, or when the context is. The timeout is unlimited if it is zero.
+func WithTimeout(
+	ctx apitypes.Context,
+	timeout time.Duration) (apitypes.Context, context.CancelFunc) {
+
+	var (
+		std    context.Context
+		cancel context.CancelFunc
+	)
+	if timeout > 0 {
+		std, cancel = context.WithTimeout(ctx, timeout)
+	} else {
+		std, cancel = context.WithCancel(ctx)
+	}
+	return &deadlineContext{Context: ctx, std: std}, cancel
+}
+
+// WithRequest returns a copy of the context that is canceled when an HTTP
+// request is, which is when the client that sent it disconnects.
+func WithRequest(ctx apitypes.Context, req *http.Request) apitypes.Context {
+	return &deadlineContext{Context: ctx, std: req.Context()}
+}
+
+// run calls an operation with a context whose deadline is the operation's
+// timeout. If the deadline passes, or the context is canceled, before the
+// operation returns, run returns an error without waiting for the operation,
+// which may continue in the background until it observes the cancellation.
+func run(
+	ctx apitypes.Context,
+	operation string,
+	timeout time.Duration,
+	f func(ctx apitypes.Context) error) error {
+
+	return runVolume(ctx, "", operation, timeout, f, nil)
+}
+
+// runVolume calls an operation on a volume like run, but only once the
+// operations on the volume that were called before it have returned,
+// including those that were abandoned, so that the operations on a volume
+// never run concurrently. If the operation is abandoned but later succeeds,
+// undo, if it is not nil, is called with a context of its own before the
+// volume's next operation runs, so that a volume is not left attached or
+// mounted by an operation that was reported to have failed. Operations on
+// the volume are not serialized if its key is empty.
+func runVolume(
+	ctx apitypes.Context,
+	key, operation string,
+	timeout time.Duration,
+	f, undo func(ctx apitypes.Context) error) error {
+
+	ctx, cancel := WithTimeout(ctx, timeout)
+	defer cancel()
+
+	unlock := func() {}
+	if key != "" {
+		var ok bool
+		if unlock, ok = volumes.lock(ctx, key); !ok {
+			return abandon(ctx, operation, timeout)
+		}
+	}
+
+	done := make(chan error)
+	abandoned := make(chan struct{})
+	go func() {
+		defer unlock()
+		err := f(ctx)
+		select {
+		case done <- err:
+		case <-abandoned:
+			if err == nil && undo != nil {
+				undoAbandoned(ctx, operation, timeout, undo)
+			}
+		}
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		close(abandoned)
+		return abandon(ctx, operation, timeout)
+	}
+}
+
+// abandon returns the error of an operation whose context is done.
+func abandon(
+	ctx apitypes.Context, operation string, timeout time.Duration) error {
+
+	err := &Error{
+		Operation: operation,
+		Timeout:   timeout,
+		Canceled:  ctx.Err() == context.Canceled,
+	}
+	ctx.WithField("operation", operation).WithError(err).Warn(
+		"abandoned operation")
+	return err
+}
+
+// undoAbandoned calls undo for an abandoned operation that succeeded. The
+// operation's context is done, so undo is called with a new context that
+// has the operation's values and timeout.
+func undoAbandoned(
+	ctx apitypes.Context,
+	operation string,
+	timeout time.Duration,
+	undo func(ctx apitypes.Context) error) {
+
+	uctx, cancel := WithTimeout(background(ctx), timeout)
+	defer cancel()
+
+	lctx := ctx.WithField("operation", operation)
+	if err := undo(uctx); err != nil {
+		lctx.WithError(err).Error(
+			"error undoing abandoned operation that succeeded")
+		return
+	}
+	lctx.Warn("undid abandoned operation that succeeded")
+}
+
+// background returns the context from which a context with a deadline was
+// derived, which has its values but not its deadline or cancellation.
+func background(ctx apitypes.Context) apitypes.Context {
+	for {
+		dc, ok := ctx.(*deadlineContext)
+		if !ok {
+			return ctx
+		}
+		ctx = dc.Context
+	}
+}
+
+// volumeLocks are the locks that serialize the operations on each volume.
+type volumeLocks struct {
+	sync.Mutex
+	locks map[string]*volumeLock
+}
+
+type volumeLock struct {
+	sem  chan struct{}
+	refs int
+}
+
+var volumes = &volumeLocks{locks: map[string]*volumeLock{}}
+
+// lock acquires the lock of a volume and returns the function that releases
+// it. The returned flag is false if the context is done before the lock is
+// acquired.
+func (l *volumeLocks) lock(
+	ctx apitypes.Context, key string) (func(), bool) {
+
+	l.Lock()
+	vl, ok := l.locks[key]
+	if !ok {
+		vl = &volumeLock{sem: make(chan struct{}, 1)}
+		l.locks[key] = vl
+	}
+	vl.refs++
+	l.Unlock()
+
+	release := func() {
+		l.Lock()
+		defer l.Unlock()
+		if vl.refs--; vl.refs == 0 {
+			delete(l.locks, key)
+		}
+	}
+
+	select {
+	case vl.sem <- struct{}{}:
+		return func() {
+			<-vl.sem
+			release()
+		}, true
+	case <-ctx.Done():
+		release()
+		return nil, false
+	}
+}
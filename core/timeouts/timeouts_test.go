@@ -0,0 +1,159 @@
+package timeouts
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/akutz/gofig"
+	"github.com/emccode/libstorage/api/context"
+	apitypes "github.com/emccode/libstorage/api/types"
+)
+
+func TestFor(t *testing.T) {
+	config := gofig.New()
+	if d := For(config, Attach); d != defaultTimeout {
+		t.Fatalf("attach=%v", d)
+	}
+	config.Set("rexray.timeouts.default", "1m")
+	config.Set("rexray.timeouts.attach", "2m")
+	config.Set("rexray.timeouts.inspect", "0")
+	config.Set("rexray.timeouts.mount", "soon")
+	for typ, want := range map[string]time.Duration{
+		Attach:  2 * time.Minute,
+		Inspect: 0,
+		Mount:   time.Minute,
+		Detach:  time.Minute,
+	} {
+		if d := For(config, typ); d != want {
+			t.Fatalf("%s=%v", typ, d)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	ctx := context.Background()
+	errFailed := errors.New("failed")
+	if err := run(ctx, "op", time.Second,
+		func(apitypes.Context) error { return errFailed }); err != errFailed {
+		t.Fatalf("err=%v", err)
+	}
+
+	release := make(chan struct{})
+	defer close(release)
+
+	// the operation observes the deadline through its context
+	observed := make(chan bool, 1)
+	err := run(ctx, "op", 50*time.Millisecond,
+		func(ctx apitypes.Context) error {
+			ctx = ctx.WithValue("key", "value")
+			<-ctx.Done()
+			observed <- ctx.Err() != nil
+			<-release
+			return nil
+		})
+	e, ok := err.(*Error)
+	if !ok || e.Canceled || e.Status() != http.StatusGatewayTimeout {
+		t.Fatalf("err=%v", err)
+	}
+	if !<-observed {
+		t.Fatal("deadline not observed")
+	}
+
+	// an operation that ignores its context is abandoned
+	start := time.Now()
+	if err := run(ctx, "op", 50*time.Millisecond,
+		func(apitypes.Context) error {
+			<-release
+			return nil
+		}); err == nil {
+		t.Fatal("operation was not abandoned")
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Fatalf("abandoned after %v", d)
+	}
+}
+
+func TestRunCanceled(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	ctx, cancel := WithTimeout(context.Background(), 0)
+	cancel()
+	err := run(ctx, "op", time.Minute, func(apitypes.Context) error {
+		<-release
+		return nil
+	})
+	if e, ok := err.(*Error); !ok || !e.Canceled {
+		t.Fatalf("err=%v", err)
+	}
+}
+
+func TestRunVolume(t *testing.T) {
+	ctx := context.Background()
+	release := make(chan struct{})
+	undone := make(chan struct{})
+
+	// an abandoned attachment that succeeds later is undone
+	err := runVolume(ctx, "vol-1", "attach", 50*time.Millisecond,
+		func(apitypes.Context) error {
+			<-release
+			return nil
+		},
+		func(ctx apitypes.Context) error {
+			if ctx.Err() != nil {
+				t.Error("undone with a done context")
+			}
+			close(undone)
+			return nil
+		})
+	if _, ok := err.(*Error); !ok {
+		t.Fatalf("err=%v", err)
+	}
+
+	// the volume's next operation waits for the abandoned one and its undo
+	done := make(chan error, 1)
+	go func() {
+		done <- runVolume(ctx, "vol-1", "detach", time.Minute,
+			func(apitypes.Context) error {
+				select {
+				case <-undone:
+					return nil
+				default:
+					return errors.New("ran before undo")
+				}
+			}, nil)
+	}()
+
+	// another volume's operations are not serialized with it
+	if err := runVolume(ctx, "vol-2", "attach", time.Second,
+		func(apitypes.Context) error { return nil }, nil); err != nil {
+		t.Fatalf("err=%v", err)
+	}
+
+	select {
+	case err := <-done:
+		t.Fatalf("ran concurrently with abandoned operation: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	// an operation that waits for the volume past its deadline times out
+	hold := make(chan struct{})
+	go runVolume(ctx, "vol-3", "mount", time.Minute,
+		func(apitypes.Context) error {
+			<-hold
+			return nil
+		}, nil)
+	time.Sleep(10 * time.Millisecond)
+	err = runVolume(ctx, "vol-3", "unmount", 50*time.Millisecond,
+		func(apitypes.Context) error { return nil }, nil)
+	close(hold)
+	if _, ok := err.(*Error); !ok {
+		t.Fatalf("err=%v", err)
+	}
+}
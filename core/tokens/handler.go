@@ -0,0 +1,69 @@
+package tokens
+
+import (
+	"net/http"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/akutz/gofig"
+
+	"github.com/emccode/rexray/core/state"
+)
+
+// Handler returns an HTTP handler that delegates to h the requests that
+// carry a valid token whose role includes the provided role. The token is
+// read from the request's Authorization header as a bearer token.
+func Handler(
+	config gofig.Config,
+	s *state.Store,
+	role Role,
+	h http.Handler) http.Handler {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		token := bearerToken(req)
+		if token == "" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="rexray"`)
+			http.Error(w, "missing token", http.StatusUnauthorized)
+			return
+		}
+		c, err := Verify(config, s, token)
+		if err != nil {
+			if _, ok := err.(*Error); ok {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="rexray"`)
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+			log.WithError(err).Error("error verifying token")
+			http.Error(w, "error verifying token",
+				http.StatusInternalServerError)
+			return
+		}
+		if !c.Role.Allows(role) {
+			http.Error(w, "token's role does not permit the request",
+				http.StatusForbidden)
+			return
+		}
+		h.ServeHTTP(w, req)
+	})
+}
+
+// FromRequest returns the claims of the bearer token carried by a request,
+// or nil if the request carries no token.
+func FromRequest(
+	config gofig.Config, s *state.Store, req *http.Request) (*Claims, error) {
+
+	token := bearerToken(req)
+	if token == "" {
+		return nil, nil
+	}
+	return Verify(config, s, token)
+}
+
+// bearerToken returns the bearer token of a request's Authorization header.
+func bearerToken(req *http.Request) string {
+	v := req.Header.Get("Authorization")
+	if len(v) < 7 || !strings.EqualFold(v[:7], "bearer ") {
+		return ""
+	}
+	return strings.TrimSpace(v[7:])
+}
@@ -0,0 +1,502 @@
+// Package tokens issues short-lived, scoped tokens with which agents and CI
+// jobs may authenticate to the REX-Ray controller, and records the tokens
+// that were revoked before they expired. Longer-lived tokens for the service
+// accounts of orchestrators are recorded, without the token itself, so that
+// they may be listed and revoked by account.
+//
+// A token is the base64 encoding of its claims followed by an HMAC-SHA256
+// signature of the claims made with a key that only the controller holds.
+package tokens
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+
+	"github.com/emccode/rexray/core/errcodes"
+	"github.com/emccode/rexray/core/kms"
+	"github.com/emccode/rexray/core/state"
+	"github.com/emccode/rexray/util"
+)
+
+const (
+	revokedBucket = "revokedTokens"
+	issuedBucket  = "issuedTokens"
+)
+
+// Role is the scope of a token.
+type Role string
+
+const (
+	// ReadOnly tokens may list and inspect resources.
+	ReadOnly Role = "read-only"
+
+	// Operator tokens may also create, attach, mount, and remove volumes.
+	Operator Role = "operator"
+
+	// Admin tokens may also manage the controller.
+	Admin Role = "admin"
+)
+
+// Roles are the valid roles.
+var Roles = []Role{ReadOnly, Operator, Admin}
+
+func init() {
+	r := gofig.NewRegistration("Tokens")
+	r.Key(gofig.String, "", "",
+		"The file that holds the key with which tokens are signed; "+
+			"defaults to token.key in the lib directory",
+		"rexray.tokens.keyFile")
+	r.Key(gofig.String, "", "1h",
+		"The lifetime of a token when none is requested",
+		"rexray.tokens.ttl")
+	r.Key(gofig.String, "", "24h",
+		"The longest lifetime a token may be issued with",
+		"rexray.tokens.maxTTL")
+	r.Key(gofig.String, "", "720h",
+		"The lifetime of a service account's token when none is requested",
+		"rexray.tokens.accountTTL")
+	r.Key(gofig.String, "", "8760h",
+		"The longest lifetime a service account's token may be issued with",
+		"rexray.tokens.accountMaxTTL")
+	gofig.Register(r)
+}
+
+// Claims are the claims of a token.
+type Claims struct {
+
+	// ID identifies the token so that it may be revoked.
+	ID string `json:"id" yaml:"id"`
+
+	// Role is the token's scope.
+	Role Role `json:"role" yaml:"role"`
+
+	// Subject describes the agent or job to which the token was issued.
+	Subject string `json:"subject,omitempty" yaml:"subject,omitempty"`
+
+	// Account is the service account to which the token was issued.
+	Account string `json:"account,omitempty" yaml:"account,omitempty"`
+
+	// Scopes are the names of the services the token may be used with. A
+	// token without scopes may be used with any service.
+	Scopes []string `json:"scopes,omitempty" yaml:"scopes,omitempty"`
+
+	// Issued is the time at which the token was issued.
+	Issued time.Time `json:"issued" yaml:"issued"`
+
+	// Expires is the time at which the token expires.
+	Expires time.Time `json:"expires" yaml:"expires"`
+}
+
+// Issued is an issued token and its claims.
+type Issued struct {
+	Claims `yaml:",inline"`
+	Token  string `json:"token" yaml:"token"`
+}
+
+// Record is a token issued to a service account. The token itself is not
+// recorded.
+type Record struct {
+	Claims `yaml:",inline"`
+
+	// Revoked is the time at which the token was revoked.
+	Revoked *time.Time `json:"revoked,omitempty" yaml:"revoked,omitempty"`
+}
+
+// Revocation records a token that was revoked.
+type Revocation struct {
+	ID      string    `json:"id" yaml:"id"`
+	Revoked time.Time `json:"revoked" yaml:"revoked"`
+
+	// Expires is the time after which the token can no longer be valid, so
+	// the revocation may be forgotten.
+	Expires time.Time `json:"expires" yaml:"expires"`
+}
+
+// Error is returned when a token is invalid, expired, or revoked.
+type Error struct {
+	Reason string
+}
+
+func (e *Error) Error() string {
+	return "invalid token: " + e.Reason
+}
+
+// Status returns the HTTP status code with which the error is reported.
+func (e *Error) Status() int {
+	return http.StatusUnauthorized
+}
+
+// Code returns the code of the error.
+func (e *Error) Code() errcodes.Code {
+	return errcodes.Unauthorized
+}
+
+// ParseRole returns the role with the provided name.
+func ParseRole(name string) (Role, error) {
+	for _, r := range Roles {
+		if string(r) == strings.ToLower(name) {
+			return r, nil
+		}
+	}
+	return "", goof.WithFields(goof.Fields{
+		"role":  name,
+		"roles": Roles,
+	}, "invalid role")
+}
+
+// Permits returns a flag indicating whether the claims permit an operation
+// that requires the provided role on the provided service.
+func (c *Claims) Permits(role Role, service string) bool {
+	if !c.Role.Allows(role) {
+		return false
+	}
+	if len(c.Scopes) == 0 {
+		return true
+	}
+	for _, s := range c.Scopes {
+		if strings.ToLower(s) == strings.ToLower(service) {
+			return true
+		}
+	}
+	return false
+}
+
+// Allows returns a flag indicating whether the role includes the privileges
+// of another role.
+func (r Role) Allows(other Role) bool {
+	return rank(r) >= rank(other)
+}
+
+func rank(r Role) int {
+	for i, v := range Roles {
+		if v == r {
+			return i
+		}
+	}
+	return -1
+}
+
+// Issue issues a token with the provided role. The configured lifetime is
+// used if ttl is zero.
+func Issue(
+	config gofig.Config, role Role, subject string, ttl time.Duration) (
+	*Issued, error) {
+
+	return issue(config, &Claims{Role: role, Subject: subject}, ttl,
+		"rexray.tokens.ttl", "rexray.tokens.maxTTL")
+}
+
+// Create issues a token to a service account and records it. The token may
+// only be used with the services named by scopes, if any. The configured
+// lifetime of a service account's token is used if ttl is zero.
+func Create(
+	config gofig.Config,
+	s *state.Store,
+	account string,
+	role Role,
+	scopes []string,
+	ttl time.Duration) (*Issued, error) {
+
+	if account == "" {
+		return nil, goof.New("missing service account")
+	}
+	for _, scope := range scopes {
+		if scope == "" {
+			return nil, goof.WithField("account", account, "empty scope")
+		}
+	}
+
+	i, err := issue(config, &Claims{
+		Role:    role,
+		Subject: account,
+		Account: account,
+		Scopes:  scopes,
+	}, ttl, "rexray.tokens.accountTTL", "rexray.tokens.accountMaxTTL")
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Set(issuedBucket, i.ID, &Record{Claims: i.Claims}); err != nil {
+		return nil, err
+	}
+	return i, nil
+}
+
+func issue(
+	config gofig.Config,
+	c *Claims,
+	ttl time.Duration,
+	ttlKey, maxTTLKey string) (*Issued, error) {
+
+	if rank(c.Role) < 0 {
+		return nil, goof.WithField("role", c.Role, "invalid role")
+	}
+
+	maxTTL, err := duration(config, maxTTLKey)
+	if err != nil {
+		return nil, err
+	}
+	if ttl == 0 {
+		if ttl, err = duration(config, ttlKey); err != nil {
+			return nil, err
+		}
+	}
+	if ttl <= 0 || ttl > maxTTL {
+		return nil, goof.WithFields(goof.Fields{
+			"ttl":    ttl,
+			"maxTTL": maxTTL,
+		}, "invalid token lifetime")
+	}
+
+	key, err := signingKey(config)
+	if err != nil {
+		return nil, err
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	i := &Issued{Claims: *c}
+	i.ID = hex.EncodeToString(id)
+	i.Issued = now
+	i.Expires = now.Add(ttl)
+
+	buf, err := json.Marshal(&i.Claims)
+	if err != nil {
+		return nil, err
+	}
+	i.Token = base64.RawURLEncoding.EncodeToString(buf) + "." +
+		base64.RawURLEncoding.EncodeToString(sign(key, buf))
+	return i, nil
+}
+
+// Verify returns the claims of a token if it was issued by this controller,
+// has not expired, and has not been revoked.
+func Verify(config gofig.Config, s *state.Store, token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return nil, &Error{"malformed"}
+	}
+	buf, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, &Error{"malformed"}
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, &Error{"malformed"}
+	}
+
+	key, err := signingKey(config)
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal(sig, sign(key, buf)) {
+		return nil, &Error{"bad signature"}
+	}
+
+	c := &Claims{}
+	if err := json.Unmarshal(buf, c); err != nil {
+		return nil, &Error{"malformed"}
+	}
+	if time.Now().After(c.Expires) {
+		return nil, &Error{"expired"}
+	}
+
+	ok, err := s.Get(revokedBucket, c.ID, &Revocation{})
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return nil, &Error{"revoked"}
+	}
+	return c, nil
+}
+
+// Revoke revokes the token with the provided ID. The revocation is kept
+// until the token expires if it was issued to a service account, and
+// otherwise, since the token's expiry is not known, for the longest lifetime
+// a token may be issued with.
+func Revoke(config gofig.Config, s *state.Store, id string) (*Revocation, error) {
+	now := time.Now().UTC().Truncate(time.Second)
+	r := &Revocation{ID: id, Revoked: now}
+
+	rec := &Record{}
+	ok, err := s.Get(issuedBucket, id, rec)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		r.Expires = rec.Expires
+		rec.Revoked = &now
+		if err := s.Set(issuedBucket, id, rec); err != nil {
+			return nil, err
+		}
+	} else {
+		maxTTL, err := duration(config, "rexray.tokens.maxTTL")
+		if err != nil {
+			return nil, err
+		}
+		r.Expires = now.Add(maxTTL)
+	}
+
+	if err := s.Set(revokedBucket, id, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// RevokeAccount revokes the unexpired tokens of a service account that have
+// not been revoked.
+func RevokeAccount(
+	config gofig.Config, s *state.Store, account string) (
+	[]*Revocation, error) {
+
+	recs, err := List(s, account)
+	if err != nil {
+		return nil, err
+	}
+	revoked := []*Revocation{}
+	for _, rec := range recs {
+		if rec.Revoked != nil {
+			continue
+		}
+		r, err := Revoke(config, s, rec.ID)
+		if err != nil {
+			return nil, err
+		}
+		revoked = append(revoked, r)
+	}
+	return revoked, nil
+}
+
+// List returns the unexpired tokens issued to service accounts sorted by
+// account and issue time, or only those of the provided account. The records
+// of the tokens that have expired are removed.
+func List(s *state.Store, account string) ([]*Record, error) {
+	ids, err := s.Keys(issuedBucket)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	recs := []*Record{}
+	for _, id := range ids {
+		rec := &Record{}
+		ok, err := s.Get(issuedBucket, id, rec)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		if now.After(rec.Expires) {
+			if err := s.Delete(issuedBucket, id); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if account == "" || rec.Account == account {
+			recs = append(recs, rec)
+		}
+	}
+	sort.Sort(byAccount(recs))
+	return recs, nil
+}
+
+type byAccount []*Record
+
+func (r byAccount) Len() int      { return len(r) }
+func (r byAccount) Swap(i, j int) { r[i], r[j] = r[j], r[i] }
+func (r byAccount) Less(i, j int) bool {
+	if r[i].Account != r[j].Account {
+		return r[i].Account < r[j].Account
+	}
+	return r[i].Issued.Before(r[j].Issued)
+}
+
+// Revoked returns the revoked tokens that have not yet expired. The
+// revocations of the tokens that have expired are removed.
+func Revoked(s *state.Store) ([]*Revocation, error) {
+	ids, err := s.Keys(revokedBucket)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(ids)
+
+	now := time.Now()
+	revoked := []*Revocation{}
+	for _, id := range ids {
+		r := &Revocation{}
+		ok, err := s.Get(revokedBucket, id, r)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		if now.After(r.Expires) {
+			if err := s.Delete(revokedBucket, id); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		revoked = append(revoked, r)
+	}
+	return revoked, nil
+}
+
+func duration(config gofig.Config, key string) (time.Duration, error) {
+	d, err := time.ParseDuration(config.GetString(key))
+	if err != nil {
+		return 0, goof.WithFieldE("key", key, "invalid duration", err)
+	}
+	return d, nil
+}
+
+// signingKey returns the key with which tokens are signed, creating it the
+// first time it is needed.
+func signingKey(config gofig.Config) ([]byte, error) {
+	path := config.GetString("rexray.tokens.keyFile")
+	if path == "" {
+		path = util.LibFilePath("token.key")
+	}
+
+	buf, err := kms.ReadFile(config, path)
+	if err == nil {
+		return hex.DecodeString(strings.TrimSpace(string(buf)))
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if err := kms.WriteFile(
+		config, path, []byte(fmt.Sprintf("%x\n", key))); err != nil {
+		return nil, goof.WithFieldE("path", path, "error writing token key", err)
+	}
+	return key, nil
+}
+
+func sign(key, buf []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write(buf)
+	return h.Sum(nil)
+}
@@ -0,0 +1,39 @@
+package tokens
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestPermits(t *testing.T) {
+	c := &Claims{Role: Operator, Scopes: []string{"ebs-prod"}}
+	if !c.Permits(ReadOnly, "EBS-prod") {
+		t.Fatal("expected read-only operation on scoped service")
+	}
+	if c.Permits(Admin, "ebs-prod") {
+		t.Fatal("expected admin operation to be refused")
+	}
+	if c.Permits(Operator, "ebs-dev") {
+		t.Fatal("expected operation on other service to be refused")
+	}
+	c.Scopes = nil
+	if !c.Permits(Operator, "ebs-dev") {
+		t.Fatal("expected unscoped token to permit any service")
+	}
+}
+
+func TestBearerToken(t *testing.T) {
+	for v, token := range map[string]string{
+		"Bearer abc.def": "abc.def",
+		"bearer abc.def": "abc.def",
+		"Basic abc":      "",
+		"Bearer":         "",
+		"":               "",
+	} {
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("Authorization", v)
+		if got := bearerToken(req); got != token {
+			t.Errorf("%q: expected %q, got %q", v, token, got)
+		}
+	}
+}
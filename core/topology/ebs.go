@@ -0,0 +1,23 @@
+package topology
+
+import (
+	"github.com/akutz/gofig"
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/core/ebs"
+)
+
+func init() {
+	Register("ebs", ebsTopology)
+	Register("ec2", ebsTopology)
+}
+
+// ebsTopology returns the region and availability zone of the instance from
+// its instance identity document.
+func ebsTopology(ctx apitypes.Context, config gofig.Config) (Segment, error) {
+	id, err := ebs.NewMetadataFromConfig(config).Identity()
+	if err != nil {
+		return nil, err
+	}
+	return Segment{Region: id.Region, Zone: id.AvailabilityZone}, nil
+}
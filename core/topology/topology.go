@@ -0,0 +1,275 @@
+// Package topology describes where volumes and instances are, ex. their
+// region, zone, and rack, so that volumes may be created where the
+// workloads that use them can reach them. A volume's create options may
+// carry requisite and preferred segments, one of which is selected when the
+// volume is created, and drivers report the segment of each instance.
+package topology
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/core/state"
+)
+
+// The keys of a segment.
+const (
+	Region = "region"
+	Zone   = "zone"
+	Rack   = "rack"
+)
+
+const (
+	// OptRequisite is the key of a volume create option whose value is the
+	// segments, one of which the volume must be created in.
+	OptRequisite = "topology.requisite"
+
+	// OptPreferred is the key of a volume create option whose value is the
+	// segments the volume should be created in, in order of preference.
+	OptPreferred = "topology.preferred"
+
+	volumeTopologyBucket = "volumeTopology"
+)
+
+func init() {
+	r := gofig.NewRegistration("Topology")
+	r.Key(gofig.String, "", "",
+		"The region of the instance, overriding the one its driver reports",
+		"rexray.topology.region")
+	r.Key(gofig.String, "", "",
+		"The zone of the instance, overriding the one its driver reports",
+		"rexray.topology.zone")
+	r.Key(gofig.String, "", "",
+		"The rack of the instance",
+		"rexray.topology.rack")
+	gofig.Register(r)
+}
+
+// Segment is a set of topology keys and their values, ex. zone=us-east-1a.
+type Segment map[string]string
+
+// Contains returns a flag indicating whether or not the segment is within
+// the provided one, i.e. has all of its keys and values.
+func (s Segment) Contains(other Segment) bool {
+	for k, v := range other {
+		if !strings.EqualFold(s[k], v) {
+			return false
+		}
+	}
+	return true
+}
+
+// String returns the segment as comma-separated key=value pairs sorted by
+// key.
+func (s Segment) String() string {
+	pairs := make([]string, 0, len(s))
+	for k, v := range s {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// ParseSegments parses semicolon-separated segments, each of which is
+// comma-separated key=value pairs, ex. zone=a,rack=1;zone=b.
+func ParseSegments(v string) ([]Segment, error) {
+	var segs []Segment
+	for _, sv := range strings.Split(v, ";") {
+		if sv = strings.TrimSpace(sv); sv == "" {
+			continue
+		}
+		s := Segment{}
+		for _, p := range strings.Split(sv, ",") {
+			kv := strings.SplitN(strings.TrimSpace(p), "=", 2)
+			if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+				return nil, goof.WithField(
+					"segment", sv, "invalid topology segment")
+			}
+			s[kv[0]] = kv[1]
+		}
+		segs = append(segs, s)
+	}
+	return segs, nil
+}
+
+// FormatSegments formats segments so that they may be parsed with
+// ParseSegments.
+func FormatSegments(segs []Segment) string {
+	vals := make([]string, len(segs))
+	for i, s := range segs {
+		vals[i] = s.String()
+	}
+	return strings.Join(vals, ";")
+}
+
+// Requirement is the topology in which a volume must or should be created.
+type Requirement struct {
+
+	// Requisite are the segments, one of which the volume must be created
+	// in. The volume may be created anywhere if there are none.
+	Requisite []Segment
+
+	// Preferred are the segments the volume should be created in, in order
+	// of preference.
+	Preferred []Segment
+}
+
+// IsEmpty returns a flag indicating whether or not the requirement has any
+// segments.
+func (r *Requirement) IsEmpty() bool {
+	return len(r.Requisite) == 0 && len(r.Preferred) == 0
+}
+
+// Permits returns a flag indicating whether or not a volume may be created
+// in the provided segment.
+func (r *Requirement) Permits(s Segment) bool {
+	if len(r.Requisite) == 0 {
+		return true
+	}
+	for _, req := range r.Requisite {
+		if s.Contains(req) {
+			return true
+		}
+	}
+	return false
+}
+
+// Select returns the segment in which a volume should be created: the first
+// preferred segment that the requisite segments permit, or otherwise the
+// first requisite segment. The returned segment is nil if the requirement
+// is empty.
+func (r *Requirement) Select() Segment {
+	for _, p := range r.Preferred {
+		if r.Permits(p) {
+			return p
+		}
+	}
+	if len(r.Requisite) > 0 {
+		return r.Requisite[0]
+	}
+	return nil
+}
+
+// FromOpts returns the requirement in a volume's create options.
+func FromOpts(opts apitypes.Store) (*Requirement, error) {
+	r := &Requirement{}
+	if opts == nil {
+		return r, nil
+	}
+	var err error
+	if r.Requisite, err = ParseSegments(
+		opts.GetString(OptRequisite)); err != nil {
+		return nil, err
+	}
+	if r.Preferred, err = ParseSegments(
+		opts.GetString(OptPreferred)); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// SetOpts sets the requirement in a volume's create options.
+func (r *Requirement) SetOpts(opts apitypes.Store) {
+	if len(r.Requisite) > 0 {
+		opts.Set(OptRequisite, FormatSegments(r.Requisite))
+	}
+	if len(r.Preferred) > 0 {
+		opts.Set(OptPreferred, FormatSegments(r.Preferred))
+	}
+}
+
+// Reporter is implemented by storage drivers that are able to report the
+// segment of the instance in the operation's context natively.
+type Reporter interface {
+
+	// InstanceTopology returns the segment of the instance.
+	InstanceTopology(
+		ctx apitypes.Context,
+		opts apitypes.Store) (map[string]string, error)
+}
+
+// Func returns the segment of the local instance with a storage platform's
+// native tools or API, ex. its instance metadata service.
+type Func func(ctx apitypes.Context, config gofig.Config) (Segment, error)
+
+var (
+	funcs    = map[string]Func{}
+	funcsRwl sync.RWMutex
+)
+
+// Register registers the function that returns the segment of the local
+// instance of the storage driver with the provided name.
+func Register(driverName string, f Func) {
+	funcsRwl.Lock()
+	defer funcsRwl.Unlock()
+	funcs[strings.ToLower(driverName)] = f
+}
+
+func lookup(driverName string) (Func, bool) {
+	funcsRwl.RLock()
+	defer funcsRwl.RUnlock()
+	f, ok := funcs[strings.ToLower(driverName)]
+	return f, ok
+}
+
+// Local returns the segment of the local instance: the segment reported by
+// the storage driver with the provided name, if any, with the keys that are
+// configured with rexray.topology.region, zone, and rack.
+func Local(
+	ctx apitypes.Context,
+	config gofig.Config,
+	client apitypes.Client,
+	driverName string) (Segment, error) {
+
+	s := Segment{}
+	if r, ok := client.Storage().(Reporter); ok {
+		reported, err := r.InstanceTopology(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range reported {
+			s[k] = v
+		}
+	} else if f, ok := lookup(driverName); ok {
+		reported, err := f(ctx, config)
+		if err != nil {
+			return nil, goof.WithFieldE(
+				"driver", driverName, "error getting instance topology", err)
+		}
+		for k, v := range reported {
+			s[k] = v
+		}
+	}
+
+	for _, k := range []string{Region, Zone, Rack} {
+		if v := config.GetString("rexray.topology." + k); v != "" {
+			s[k] = v
+		}
+	}
+	return s, nil
+}
+
+// Volume returns the segment in which the volume with the provided ID was
+// created, if it was recorded.
+func Volume(s *state.Store, volumeID string) (Segment, bool, error) {
+	seg := Segment{}
+	ok, err := s.Get(volumeTopologyBucket, volumeID, &seg)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	return seg, true, nil
+}
+
+// SetVolume records the segment in which the volume with the provided ID
+// was created. The record is removed if the segment is empty.
+func SetVolume(s *state.Store, volumeID string, seg Segment) error {
+	if len(seg) == 0 {
+		return s.Delete(volumeTopologyBucket, volumeID)
+	}
+	return s.Set(volumeTopologyBucket, volumeID, seg)
+}
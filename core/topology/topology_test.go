@@ -0,0 +1,43 @@
+package topology
+
+import "testing"
+
+func TestParseSegments(t *testing.T) {
+	segs, err := ParseSegments("zone=a,rack=1; zone=b;")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(segs) != 2 || segs[0][Zone] != "a" || segs[0][Rack] != "1" ||
+		segs[1][Zone] != "b" {
+		t.Fatalf("unexpected segments %v", segs)
+	}
+	if v := FormatSegments(segs); v != "rack=1,zone=a;zone=b" {
+		t.Errorf("FormatSegments=%s", v)
+	}
+	if _, err := ParseSegments("zone"); err == nil {
+		t.Error("expected error parsing segment without value")
+	}
+}
+
+func TestSelect(t *testing.T) {
+	tests := []struct {
+		requisite, preferred string
+		zone                 string
+	}{
+		{"", "", ""},
+		{"zone=a;zone=b", "", "a"},
+		{"zone=a;zone=b", "zone=c;zone=b", "b"},
+		{"", "zone=c;zone=b", "c"},
+		{"zone=a", "zone=b,rack=1;zone=a,rack=2", "a"},
+		{"zone=a", "zone=b", "a"},
+	}
+	for _, tt := range tests {
+		r := &Requirement{}
+		r.Requisite, _ = ParseSegments(tt.requisite)
+		r.Preferred, _ = ParseSegments(tt.preferred)
+		if s := r.Select(); s[Zone] != tt.zone {
+			t.Errorf("%s/%s: zone=%s; want %s",
+				tt.requisite, tt.preferred, s[Zone], tt.zone)
+		}
+	}
+}
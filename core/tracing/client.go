@@ -0,0 +1,178 @@
+package tracing
+
+import (
+	apitypes "github.com/emccode/libstorage/api/types"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+type client struct {
+	apitypes.Client
+}
+
+// Wrap returns a libStorage client that records a span for each storage and
+// integration driver operation. The client is returned as is if tracing is
+// not enabled.
+func Wrap(c apitypes.Client) apitypes.Client {
+	if !Enabled() {
+		return c
+	}
+	return &client{Client: c}
+}
+
+func (c *client) Storage() apitypes.StorageDriver {
+	return &storageDriver{StorageDriver: c.Client.Storage()}
+}
+
+func (c *client) Integration() apitypes.IntegrationDriver {
+	return &integrationDriver{IntegrationDriver: c.Client.Integration()}
+}
+
+func volumeAttrs(volumeID, volumeName string) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{}
+	if volumeID != "" {
+		attrs = append(attrs, attribute.String("volume.id", volumeID))
+	}
+	if volumeName != "" {
+		attrs = append(attrs, attribute.String("volume.name", volumeName))
+	}
+	return attrs
+}
+
+type storageDriver struct {
+	apitypes.StorageDriver
+}
+
+func (d *storageDriver) Volumes(
+	ctx apitypes.Context,
+	opts *apitypes.VolumesOpts) ([]*apitypes.Volume, error) {
+
+	ctx, span := start(ctx, "storage.Volumes")
+	vols, err := d.StorageDriver.Volumes(ctx, opts)
+	end(span, err)
+	return vols, err
+}
+
+func (d *storageDriver) VolumeInspect(
+	ctx apitypes.Context,
+	volumeID string,
+	opts *apitypes.VolumeInspectOpts) (*apitypes.Volume, error) {
+
+	ctx, span := start(
+		ctx, "storage.VolumeInspect", volumeAttrs(volumeID, "")...)
+	vol, err := d.StorageDriver.VolumeInspect(ctx, volumeID, opts)
+	end(span, err)
+	return vol, err
+}
+
+func (d *storageDriver) VolumeCreate(
+	ctx apitypes.Context,
+	volumeName string,
+	opts *apitypes.VolumeCreateOpts) (*apitypes.Volume, error) {
+
+	ctx, span := start(
+		ctx, "storage.VolumeCreate", volumeAttrs("", volumeName)...)
+	vol, err := d.StorageDriver.VolumeCreate(ctx, volumeName, opts)
+	end(span, err)
+	return vol, err
+}
+
+func (d *storageDriver) VolumeRemove(
+	ctx apitypes.Context,
+	volumeID string,
+	opts apitypes.Store) error {
+
+	ctx, span := start(
+		ctx, "storage.VolumeRemove", volumeAttrs(volumeID, "")...)
+	err := d.StorageDriver.VolumeRemove(ctx, volumeID, opts)
+	end(span, err)
+	return err
+}
+
+func (d *storageDriver) VolumeAttach(
+	ctx apitypes.Context,
+	volumeID string,
+	opts *apitypes.VolumeAttachOpts) (*apitypes.Volume, string, error) {
+
+	ctx, span := start(
+		ctx, "storage.VolumeAttach", volumeAttrs(volumeID, "")...)
+	vol, token, err := d.StorageDriver.VolumeAttach(ctx, volumeID, opts)
+	end(span, err)
+	return vol, token, err
+}
+
+func (d *storageDriver) VolumeDetach(
+	ctx apitypes.Context,
+	volumeID string,
+	opts *apitypes.VolumeDetachOpts) (*apitypes.Volume, error) {
+
+	ctx, span := start(
+		ctx, "storage.VolumeDetach", volumeAttrs(volumeID, "")...)
+	vol, err := d.StorageDriver.VolumeDetach(ctx, volumeID, opts)
+	end(span, err)
+	return vol, err
+}
+
+type integrationDriver struct {
+	apitypes.IntegrationDriver
+}
+
+func (d *integrationDriver) Mount(
+	ctx apitypes.Context,
+	volumeID, volumeName string,
+	opts *apitypes.VolumeMountOpts) (string, *apitypes.Volume, error) {
+
+	ctx, span := start(
+		ctx, "integration.Mount", volumeAttrs(volumeID, volumeName)...)
+	mountPath, vol, err := d.IntegrationDriver.Mount(
+		ctx, volumeID, volumeName, opts)
+	end(span, err)
+	return mountPath, vol, err
+}
+
+func (d *integrationDriver) Unmount(
+	ctx apitypes.Context,
+	volumeID, volumeName string,
+	opts apitypes.Store) error {
+
+	ctx, span := start(
+		ctx, "integration.Unmount", volumeAttrs(volumeID, volumeName)...)
+	err := d.IntegrationDriver.Unmount(ctx, volumeID, volumeName, opts)
+	end(span, err)
+	return err
+}
+
+func (d *integrationDriver) Path(
+	ctx apitypes.Context,
+	volumeID, volumeName string,
+	opts apitypes.Store) (string, error) {
+
+	ctx, span := start(
+		ctx, "integration.Path", volumeAttrs(volumeID, volumeName)...)
+	mountPath, err := d.IntegrationDriver.Path(ctx, volumeID, volumeName, opts)
+	end(span, err)
+	return mountPath, err
+}
+
+func (d *integrationDriver) Create(
+	ctx apitypes.Context,
+	volumeName string,
+	opts *apitypes.VolumeCreateOpts) (*apitypes.Volume, error) {
+
+	ctx, span := start(
+		ctx, "integration.Create", volumeAttrs("", volumeName)...)
+	vol, err := d.IntegrationDriver.Create(ctx, volumeName, opts)
+	end(span, err)
+	return vol, err
+}
+
+func (d *integrationDriver) Remove(
+	ctx apitypes.Context,
+	volumeName string,
+	opts apitypes.Store) error {
+
+	ctx, span := start(
+		ctx, "integration.Remove", volumeAttrs("", volumeName)...)
+	err := d.IntegrationDriver.Remove(ctx, volumeName, opts)
+	end(span, err)
+	return err
+}
@@ -0,0 +1,64 @@
+package tracing
+
+import (
+	"context"
+
+	apicontext "github.com/emccode/libstorage/api/context"
+	apitypes "github.com/emccode/libstorage/api/types"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// headerKey is the key of a context value that the libStorage client sends
+// to the server as the request header of the same name.
+type headerKey string
+
+func (k headerKey) String() string {
+	return string(k)
+}
+
+var headerKeys []headerKey
+
+// registerHeaders registers the headers of the W3C trace context with
+// libStorage so that the context of a client's span is sent to the server
+// with the span's request.
+func registerHeaders() {
+	for _, f := range otel.GetTextMapPropagator().Fields() {
+		k := headerKey(f)
+		apicontext.RegisterCustomKey(k, apicontext.CustomHeaderKey)
+		headerKeys = append(headerKeys, k)
+	}
+}
+
+// carrier holds the fields of a trace context that a propagator injects.
+type carrier map[string]string
+
+func (c carrier) Get(key string) string {
+	return c[key]
+}
+
+func (c carrier) Set(key, value string) {
+	c[key] = value
+}
+
+func (c carrier) Keys() []string {
+	keys := []string{}
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// inject returns a libStorage context that carries the trace context of the
+// provided span as the values of the header keys.
+func inject(ctx apitypes.Context, span trace.Span) apitypes.Context {
+	c := carrier{}
+	otel.GetTextMapPropagator().Inject(
+		trace.ContextWithSpan(context.Background(), span), c)
+	for _, k := range headerKeys {
+		if v := c.Get(string(k)); v != "" {
+			ctx = ctx.WithValue(k, v)
+		}
+	}
+	return ctx
+}
@@ -0,0 +1,200 @@
+// Package tracing instruments REX-Ray with OpenTelemetry so that an operation
+// such as a Docker volume mount can be traced from the HTTP request that
+// initiated it to the storage driver calls that fulfilled it.
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	apitypes "github.com/emccode/libstorage/api/types"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/jaeger"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/emccode/rexray"
+
+type spanKey struct{}
+
+var (
+	provider    *sdktrace.TracerProvider
+	providerRwl sync.RWMutex
+)
+
+func init() {
+	r := gofig.NewRegistration("Tracing")
+	r.Key(gofig.Bool, "", false,
+		"Enable OpenTelemetry tracing",
+		"rexray.tracing.enabled")
+	r.Key(gofig.String, "", "otlp",
+		"The trace exporter (otlp, jaeger)",
+		"rexray.tracing.exporter")
+	r.Key(gofig.String, "", "",
+		"The address of the OTLP receiver or the URL of the Jaeger collector",
+		"rexray.tracing.endpoint")
+	r.Key(gofig.String, "", "1.0",
+		"The fraction of traces that are sampled",
+		"rexray.tracing.sampleRatio")
+	gofig.Register(r)
+}
+
+// Init configures the global OpenTelemetry tracer provider if tracing is
+// enabled. Init may be called more than once; only the first call that
+// enables tracing has an effect.
+func Init(ctx apitypes.Context, config gofig.Config) error {
+	if !config.GetBool("rexray.tracing.enabled") {
+		return nil
+	}
+
+	providerRwl.Lock()
+	defer providerRwl.Unlock()
+
+	if provider != nil {
+		return nil
+	}
+
+	exp, err := newExporter(config)
+	if err != nil {
+		return err
+	}
+
+	ratio, err := strconv.ParseFloat(
+		config.GetString("rexray.tracing.sampleRatio"), 64)
+	if err != nil {
+		return goof.WithFieldE("sampleRatio",
+			config.GetString("rexray.tracing.sampleRatio"),
+			"invalid trace sample ratio", err)
+	}
+
+	provider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exp),
+		sdktrace.WithSampler(
+			sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+		sdktrace.WithResource(resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceNameKey.String("rexray"))))
+
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	registerHeaders()
+
+	ctx.WithFields(map[string]interface{}{
+		"exporter": config.GetString("rexray.tracing.exporter"),
+		"endpoint": config.GetString("rexray.tracing.endpoint"),
+	}).Info("enabled tracing")
+	return nil
+}
+
+func newExporter(config gofig.Config) (sdktrace.SpanExporter, error) {
+	endpoint := config.GetString("rexray.tracing.endpoint")
+
+	exporter := config.GetString("rexray.tracing.exporter")
+
+	switch strings.ToLower(exporter) {
+	case "", "otlp":
+		opts := []otlptracehttp.Option{otlptracehttp.WithInsecure()}
+		if endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpoint(endpoint))
+		}
+		return otlptracehttp.New(context.Background(), opts...)
+	case "jaeger":
+		opts := []jaeger.CollectorEndpointOption{}
+		if endpoint != "" {
+			opts = append(opts, jaeger.WithEndpoint(endpoint))
+		}
+		return jaeger.New(jaeger.WithCollectorEndpoint(opts...))
+	default:
+		return nil, goof.WithField("exporter", exporter, "invalid trace exporter")
+	}
+}
+
+// Shutdown flushes any spans that have not been exported.
+func Shutdown() {
+	providerRwl.RLock()
+	defer providerRwl.RUnlock()
+	if provider != nil {
+		provider.Shutdown(context.Background())
+	}
+}
+
+// Enabled returns a flag indicating whether or not tracing is enabled.
+func Enabled() bool {
+	providerRwl.RLock()
+	defer providerRwl.RUnlock()
+	return provider != nil
+}
+
+// Handler returns the provided HTTP handler instrumented so that each request
+// is recorded as a span. The handler is returned as is if tracing is not
+// enabled.
+func Handler(h http.Handler, operation string) http.Handler {
+	if !Enabled() {
+		return h
+	}
+	return otelhttp.NewHandler(h, operation)
+}
+
+// Transport returns the provided HTTP transport instrumented so that each
+// request is recorded as a span and carries the span's trace context. The
+// transport is returned as is if tracing is not enabled.
+func Transport(rt http.RoundTripper) http.RoundTripper {
+	if !Enabled() {
+		return rt
+	}
+	return otelhttp.NewTransport(rt)
+}
+
+// Context returns a libStorage context that carries the span of the provided
+// HTTP request so that the spans of the operations performed with the context
+// are children of the request's span.
+func Context(ctx apitypes.Context, req *http.Request) apitypes.Context {
+	span := trace.SpanFromContext(req.Context())
+	if !span.SpanContext().IsValid() {
+		return ctx
+	}
+	return ctx.WithValue(spanKey{}, span)
+}
+
+// start begins the span of a libStorage client request that is a child of
+// the span carried by the provided context, if any. The returned context
+// carries the span's trace context so that it is sent to the server with the
+// request.
+func start(
+	ctx apitypes.Context,
+	name string,
+	attrs ...attribute.KeyValue) (apitypes.Context, trace.Span) {
+
+	parent := context.Background()
+	if s, ok := ctx.Value(spanKey{}).(trace.Span); ok {
+		parent = trace.ContextWithSpan(parent, s)
+	}
+
+	_, span := otel.Tracer(tracerName).Start(
+		parent, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...))
+	return inject(ctx.WithValue(spanKey{}, span), span), span
+}
+
+// end ends a span, recording the provided error if it is not nil.
+func end(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
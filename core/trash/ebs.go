@@ -0,0 +1,84 @@
+package trash
+
+import (
+	"time"
+
+	"github.com/akutz/gofig"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/core/ebs"
+)
+
+// ebsTag is the tag of a trashed EBS volume, whose value is the time after
+// which the volume is purged.
+const ebsTag = "rexray:trash:purgeAfter"
+
+func init() {
+	RegisterMarker("ebs", newEBSMarker)
+	RegisterMarker("ec2", newEBSMarker)
+}
+
+// ebsMarker tags trashed EBS volumes.
+type ebsMarker struct {
+	config gofig.Config
+}
+
+func newEBSMarker(config gofig.Config) (Marker, error) {
+	return &ebsMarker{config: config}, nil
+}
+
+// client returns a client of the service's region, or if it is not
+// configured, of the instance's region.
+func (m *ebsMarker) client() (*ec2.EC2, error) {
+	region := m.config.GetString("ebs.region")
+	if region == "" {
+		id, err := ebs.NewMetadataFromConfig(m.config).Identity()
+		if err != nil {
+			return nil, err
+		}
+		region = id.Region
+	}
+	c := aws.NewConfig().WithRegion(region)
+	if ak := m.config.GetString("ebs.accessKey"); ak != "" {
+		c = c.WithCredentials(credentials.NewStaticCredentials(
+			ak,
+			m.config.GetString("ebs.secretKey"),
+			m.config.GetString("ebs.sessionToken")))
+	}
+	sess, err := session.NewSession(c)
+	if err != nil {
+		return nil, err
+	}
+	return ec2.New(sess), nil
+}
+
+func (m *ebsMarker) Mark(ctx apitypes.Context, e *Entry) error {
+	client, err := m.client()
+	if err != nil {
+		return err
+	}
+	_, err = client.CreateTags(&ec2.CreateTagsInput{
+		Resources: []*string{aws.String(e.VolumeID)},
+		Tags: []*ec2.Tag{{
+			Key:   aws.String(ebsTag),
+			Value: aws.String(e.PurgeAfter.Format(time.RFC3339)),
+		}},
+	})
+	return err
+}
+
+func (m *ebsMarker) Unmark(ctx apitypes.Context, volumeID string) error {
+	client, err := m.client()
+	if err != nil {
+		return err
+	}
+	_, err = client.DeleteTags(&ec2.DeleteTagsInput{
+		Resources: []*string{aws.String(volumeID)},
+		Tags:      []*ec2.Tag{{Key: aws.String(ebsTag)}},
+	})
+	return err
+}
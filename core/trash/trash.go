@@ -0,0 +1,294 @@
+// Package trash records the volumes that were removed while soft deletion is
+// enabled. A trashed volume remains on its storage platform but is hidden
+// from volume listings and may not be attached or mounted. It is also marked
+// on its storage platform if the platform's driver has a marker. It may be
+// restored until its retention period elapses, after which it is purged.
+package trash
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	apitypes "github.com/emccode/libstorage/api/types"
+	apiutils "github.com/emccode/libstorage/api/utils"
+
+	"github.com/emccode/rexray/core/errcodes"
+	"github.com/emccode/rexray/core/state"
+)
+
+const (
+	// PurgeKey is the name of the remove option that removes a volume
+	// immediately rather than moving it to the trash.
+	PurgeKey = "purge"
+
+	trashBucket = "volumeTrash"
+)
+
+func init() {
+	r := gofig.NewRegistration("Volume Trash")
+	r.Key(gofig.Bool, "", false,
+		"Move removed volumes to the trash rather than removing them",
+		"rexray.volume.trash.enabled")
+	r.Key(gofig.String, "", "72h",
+		"How long a trashed volume may be restored before it is purged",
+		"rexray.volume.trash.retention")
+	r.Key(gofig.String, "", "@hourly",
+		"When the REX-Ray service purges the expired volumes in the trash",
+		"rexray.volume.trash.purgeSchedule")
+	gofig.Register(r)
+}
+
+// Entry describes a trashed volume.
+type Entry struct {
+
+	// VolumeID is the ID of the trashed volume.
+	VolumeID string `json:"volumeID"`
+
+	// VolumeName is the name of the volume when it was trashed.
+	VolumeName string `json:"volumeName,omitempty"`
+
+	// Trashed is the time at which the volume was removed.
+	Trashed time.Time `json:"trashed"`
+
+	// PurgeAfter is the time after which the volume is purged.
+	PurgeAfter time.Time `json:"purgeAfter"`
+}
+
+// Error is returned when an operation is refused because a volume is in the
+// trash.
+type Error struct {
+	Entry *Entry
+
+	// Operation is the operation that was refused.
+	Operation string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s refused: volume %s is in the trash until %s",
+		e.Operation, e.Entry.VolumeID,
+		e.Entry.PurgeAfter.Format(time.RFC3339))
+}
+
+// Status returns the HTTP status code with which the error is reported.
+func (e *Error) Status() int {
+	return http.StatusGone
+}
+
+// Code returns the code of the error.
+func (e *Error) Code() errcodes.Code {
+	return errcodes.VolumeTrashed
+}
+
+// Marker marks trashed volumes on a storage platform, so that they are
+// recognized as trashed by the platform's own tools and by the hosts that do
+// not share REX-Ray's state.
+type Marker interface {
+
+	// Mark marks a volume as trashed.
+	Mark(ctx apitypes.Context, e *Entry) error
+
+	// Unmark removes the mark of a volume that was restored.
+	Unmark(ctx apitypes.Context, volumeID string) error
+}
+
+// NewMarker returns a marker that uses the provided configuration.
+type NewMarker func(config gofig.Config) (Marker, error)
+
+var (
+	markers    = map[string]NewMarker{}
+	markersRwl sync.RWMutex
+)
+
+// RegisterMarker registers the constructor of the marker of the storage
+// driver with the provided name.
+func RegisterMarker(driverName string, f NewMarker) {
+	markersRwl.Lock()
+	defer markersRwl.Unlock()
+	markers[strings.ToLower(driverName)] = f
+}
+
+func newMarker(config gofig.Config, driverName string) (Marker, error) {
+	markersRwl.RLock()
+	f, ok := markers[strings.ToLower(driverName)]
+	markersRwl.RUnlock()
+	if !ok {
+		return nil, nil
+	}
+	return f(config)
+}
+
+// Mark marks a trashed volume on its storage platform. The returned flag is
+// false if the storage driver with the provided name has no marker.
+func Mark(
+	ctx apitypes.Context,
+	config gofig.Config,
+	driverName string,
+	e *Entry) (bool, error) {
+
+	m, err := newMarker(config, driverName)
+	if err != nil || m == nil {
+		return false, err
+	}
+	if err := m.Mark(ctx, e); err != nil {
+		return false, goof.WithFieldE("volumeID", e.VolumeID,
+			"error marking trashed volume", err)
+	}
+	return true, nil
+}
+
+// Unmark removes the mark of a restored volume on its storage platform. The
+// returned flag is false if the storage driver with the provided name has no
+// marker.
+func Unmark(
+	ctx apitypes.Context,
+	config gofig.Config,
+	driverName, volumeID string) (bool, error) {
+
+	m, err := newMarker(config, driverName)
+	if err != nil || m == nil {
+		return false, err
+	}
+	if err := m.Unmark(ctx, volumeID); err != nil {
+		return false, goof.WithFieldE("volumeID", volumeID,
+			"error unmarking restored volume", err)
+	}
+	return true, nil
+}
+
+// Enabled returns a flag indicating whether removed volumes are moved to the
+// trash.
+func Enabled(config gofig.Config) bool {
+	return config.GetBool("rexray.volume.trash.enabled")
+}
+
+// IsPurge returns a flag indicating whether the remove options request that
+// the volume be removed immediately.
+func IsPurge(opts apitypes.Store) bool {
+	return opts != nil && opts.GetBool(PurgeKey)
+}
+
+// Add moves a volume to the trash.
+func Add(config gofig.Config, s *state.Store, vol *apitypes.Volume) (
+	*Entry, error) {
+
+	retention, err := time.ParseDuration(
+		config.GetString("rexray.volume.trash.retention"))
+	if err != nil {
+		return nil, goof.WithFieldE("retention",
+			config.GetString("rexray.volume.trash.retention"),
+			"invalid trash retention", err)
+	}
+
+	now := time.Now().UTC()
+	e := &Entry{
+		VolumeID:   vol.ID,
+		VolumeName: vol.Name,
+		Trashed:    now,
+		PurgeAfter: now.Add(retention),
+	}
+	if err := s.Set(trashBucket, vol.ID, e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Restore removes the volume with the provided ID from the trash. An error
+// is returned if the volume is not in the trash.
+func Restore(s *state.Store, volumeID string) (*Entry, error) {
+	e, ok, err := Get(s, volumeID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, goof.WithField("volumeID", volumeID, "volume not in trash")
+	}
+	if err := s.Delete(trashBucket, volumeID); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Forget removes the record of a volume that was purged.
+func Forget(s *state.Store, volumeID string) error {
+	return s.Delete(trashBucket, volumeID)
+}
+
+// Get returns the trash entry for the volume with the provided ID. The
+// returned flag is false if the volume is not in the trash.
+func Get(s *state.Store, volumeID string) (*Entry, bool, error) {
+	e := &Entry{}
+	ok, err := s.Get(trashBucket, volumeID, e)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	return e, true, nil
+}
+
+// List returns the trashed volumes.
+func List(s *state.Store) ([]*Entry, error) {
+	ids, err := s.Keys(trashBucket)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(ids)
+	all := []*Entry{}
+	for _, id := range ids {
+		e, ok, err := Get(s, id)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			all = append(all, e)
+		}
+	}
+	return all, nil
+}
+
+// Purge removes the trashed volumes whose retention period has elapsed. The
+// volumes are removed with the provided client, which is expected to forget
+// their trash entries once they are removed. A volume that is attached to
+// any instance is not removed, since a host that does not share REX-Ray's
+// state may have attached it after it was trashed; it is purged once it is
+// detached.
+func Purge(ctx apitypes.Context, client apitypes.Client, s *state.Store) {
+	all, err := List(s)
+	if err != nil {
+		ctx.WithError(err).Error("error listing trashed volumes")
+		return
+	}
+
+	now := time.Now()
+	for _, e := range all {
+		if now.Before(e.PurgeAfter) {
+			continue
+		}
+		opts := apiutils.NewStore()
+		opts.Set(PurgeKey, true)
+		f := ctx.WithFields(map[string]interface{}{
+			"volumeID":   e.VolumeID,
+			"volumeName": e.VolumeName,
+		})
+		vol, err := client.Storage().VolumeInspect(ctx, e.VolumeID,
+			&apitypes.VolumeInspectOpts{Attachments: true})
+		if err != nil {
+			f.WithError(err).Error("error inspecting trashed volume")
+			continue
+		}
+		if len(vol.Attachments) > 0 {
+			f.Warn("not purging trashed volume; volume is attached")
+			continue
+		}
+		if err := client.Storage().VolumeRemove(
+			ctx, e.VolumeID, opts); err != nil {
+			f.WithError(err).Error("error purging trashed volume")
+			continue
+		}
+		f.Info("purged trashed volume")
+	}
+}
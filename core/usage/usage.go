@@ -0,0 +1,391 @@
+// Package usage records the provisioned size of each service's volumes and
+// snapshots at a regular interval and aggregates the samples into GiB-hours
+// per tenant, principal, service, volume, or label, so that storage may be
+// charged back or shown back to the teams that use it.
+package usage
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	apitypes "github.com/emccode/libstorage/api/types"
+	apiutils "github.com/emccode/libstorage/api/utils"
+
+	"github.com/emccode/rexray/core/labels"
+	"github.com/emccode/rexray/core/policy"
+	"github.com/emccode/rexray/core/quota"
+	"github.com/emccode/rexray/core/state"
+)
+
+const (
+	// GroupTenant groups usage by the tenant label of volumes and snapshots.
+	GroupTenant = "tenant"
+
+	// GroupPrincipal groups usage by the principal that created volumes.
+	GroupPrincipal = "principal"
+
+	// GroupService groups usage by service.
+	GroupService = "service"
+
+	// GroupVolume groups usage by volume. A snapshot's usage is that of the
+	// volume it was taken of.
+	GroupVolume = "volume"
+
+	// GroupLabelPrefix is the prefix of a grouping by the value of a label,
+	// ex. label:env.
+	GroupLabelPrefix = "label:"
+
+	samplesBucket = "usageSamples"
+)
+
+func init() {
+	r := gofig.NewRegistration("Usage Reports")
+	r.Key(gofig.Bool, "", false,
+		"Sample the provisioned size of volumes and snapshots for usage "+
+			"reports",
+		"rexray.usage.enabled")
+	r.Key(gofig.String, "", "1h",
+		"The interval at which the sizes of volumes and snapshots are sampled",
+		"rexray.usage.interval")
+	r.Key(gofig.String, "", "2160h",
+		"How long usage samples are kept",
+		"rexray.usage.retention")
+	gofig.Register(r)
+}
+
+// Item is a volume or snapshot as of a sample.
+type Item struct {
+	ID      string `json:"id"`
+	Name    string `json:"name,omitempty"`
+	Service string `json:"service"`
+
+	// Volume is the name, or the ID if it has none, of the volume. It is the
+	// volume a snapshot was taken of.
+	Volume string `json:"volume"`
+
+	// Size is the provisioned size in GiB. A snapshot's size is that of the
+	// volume it was taken of.
+	Size int64 `json:"size"`
+
+	// Labels are the item's labels. A snapshot inherits the labels of the
+	// volume it was taken of unless it has labels of its own.
+	Labels labels.Labels `json:"labels,omitempty"`
+
+	// Owner is the principal that created the volume.
+	Owner string `json:"owner,omitempty"`
+}
+
+// Sample is the size of the volumes and snapshots at a point in time. It
+// accounts for the interval that preceded it.
+type Sample struct {
+	Time      time.Time `json:"time"`
+	Hours     float64   `json:"hours"`
+	Volumes   []*Item   `json:"volumes"`
+	Snapshots []*Item   `json:"snapshots"`
+}
+
+// Row is the usage of a group over a period of time.
+type Row struct {
+	Group            string  `json:"group"`
+	VolumeGiBHours   float64 `json:"volumeGiBHours"`
+	SnapshotGiBHours float64 `json:"snapshotGiBHours"`
+
+	// Volumes and Snapshots are the number of distinct volumes and
+	// snapshots that were sampled.
+	Volumes   int `json:"volumes"`
+	Snapshots int `json:"snapshots"`
+
+	volumes   map[string]bool
+	snapshots map[string]bool
+}
+
+// Enabled returns a flag indicating whether usage is sampled.
+func Enabled(config gofig.Config) bool {
+	return config.GetBool("rexray.usage.enabled")
+}
+
+// Interval returns the interval at which usage is sampled.
+func Interval(config gofig.Config) time.Duration {
+	if d, err := time.ParseDuration(
+		config.GetString("rexray.usage.interval")); err == nil && d > 0 {
+		return d
+	}
+	return time.Hour
+}
+
+// Record samples the volumes and snapshots of every service and records the
+// sample, and removes the samples that are older than the retention.
+func Record(
+	ctx apitypes.Context,
+	config gofig.Config,
+	client apitypes.Client,
+	s *state.Store) (*Sample, error) {
+
+	now := time.Now().UTC()
+	sample := &Sample{
+		Time:      now,
+		Hours:     Interval(config).Hours(),
+		Volumes:   []*Item{},
+		Snapshots: []*Item{},
+	}
+
+	svcs, err := client.API().Services(ctx)
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for name := range svcs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		sc, err := serviceClient(ctx, config, client, name)
+		if err != nil {
+			return nil, err
+		}
+		if err := sampleService(ctx, sc, s, name, sample); err != nil {
+			return nil, goof.WithFieldE(
+				"service", name, "error sampling usage", err)
+		}
+	}
+
+	if err := s.Set(
+		samplesBucket, now.Format(time.RFC3339Nano), sample); err != nil {
+		return nil, err
+	}
+	if err := prune(config, s, now); err != nil {
+		ctx.WithError(err).Warn("error removing expired usage samples")
+	}
+	return sample, nil
+}
+
+func sampleService(
+	ctx apitypes.Context,
+	client apitypes.Client,
+	s *state.Store,
+	service string,
+	sample *Sample) error {
+
+	vols, err := client.Storage().Volumes(
+		ctx, &apitypes.VolumesOpts{Attachments: false})
+	if err != nil {
+		return err
+	}
+	byID := map[string]*Item{}
+	for _, v := range vols {
+		l, err := labels.Volume(s, v.ID)
+		if err != nil {
+			return err
+		}
+		// owners are recorded by the libStorage server that admitted the
+		// volume's creation
+		owner, err := quota.Owner(state.Controller(), v.ID)
+		if err != nil {
+			return err
+		}
+		name := v.Name
+		if name == "" {
+			name = v.ID
+		}
+		item := &Item{
+			ID:      v.ID,
+			Name:    v.Name,
+			Service: service,
+			Volume:  name,
+			Size:    v.Size,
+			Labels:  l,
+			Owner:   owner,
+		}
+		byID[v.ID] = item
+		sample.Volumes = append(sample.Volumes, item)
+	}
+
+	snaps, err := client.Storage().Snapshots(ctx, apiutils.NewStore())
+	if err != nil {
+		return err
+	}
+	for _, sn := range snaps {
+		l, err := labels.Snapshot(s, sn.ID)
+		if err != nil {
+			return err
+		}
+		item := &Item{
+			ID:      sn.ID,
+			Name:    sn.Name,
+			Service: service,
+			Volume:  sn.VolumeID,
+			Size:    sn.VolumeSize,
+			Labels:  l,
+		}
+		if v, ok := byID[sn.VolumeID]; ok {
+			item.Volume = v.Volume
+			item.Owner = v.Owner
+			if len(item.Labels) == 0 {
+				item.Labels = v.Labels
+			}
+		}
+		sample.Snapshots = append(sample.Snapshots, item)
+	}
+	return nil
+}
+
+// serviceClient returns a client for the named service. The provided client
+// is returned for the configured service.
+func serviceClient(
+	ctx apitypes.Context,
+	config gofig.Config,
+	client apitypes.Client,
+	service string) (apitypes.Client, error) {
+
+	if strings.EqualFold(service, config.GetString(apitypes.ConfigService)) {
+		return client, nil
+	}
+	sc, err := config.Copy()
+	if err != nil {
+		return nil, err
+	}
+	sc.Set(apitypes.ConfigService, service)
+	return policy.New(ctx, sc)
+}
+
+func prune(config gofig.Config, s *state.Store, now time.Time) error {
+	retention, err := time.ParseDuration(
+		config.GetString("rexray.usage.retention"))
+	if err != nil {
+		return goof.WithFieldE("retention",
+			config.GetString("rexray.usage.retention"),
+			"invalid usage retention", err)
+	}
+	keys, err := s.Keys(samplesBucket)
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		t, err := time.Parse(time.RFC3339Nano, k)
+		if err != nil || now.Sub(t) <= retention {
+			continue
+		}
+		if err := s.Delete(samplesBucket, k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Samples returns the recorded samples taken at or after from and before
+// to, in the order they were taken. A zero to is now.
+func Samples(s *state.Store, from, to time.Time) ([]*Sample, error) {
+	keys, err := s.Keys(samplesBucket)
+	if err != nil {
+		return nil, err
+	}
+	all := []*Sample{}
+	for _, k := range keys {
+		t, err := time.Parse(time.RFC3339Nano, k)
+		if err != nil || t.Before(from) || (!to.IsZero() && !t.Before(to)) {
+			continue
+		}
+		sample := &Sample{}
+		ok, err := s.Get(samplesBucket, k, sample)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			all = append(all, sample)
+		}
+	}
+	sort.Sort(byTime(all))
+	return all, nil
+}
+
+type byTime []*Sample
+
+func (b byTime) Len() int           { return len(b) }
+func (b byTime) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byTime) Less(i, j int) bool { return b[i].Time.Before(b[j].Time) }
+
+// ValidGroupBy returns an error if a grouping is not supported.
+func ValidGroupBy(groupBy string) error {
+	switch groupBy {
+	case GroupTenant, GroupPrincipal, GroupService, GroupVolume:
+		return nil
+	}
+	if strings.HasPrefix(groupBy, GroupLabelPrefix) &&
+		len(groupBy) > len(GroupLabelPrefix) {
+		return nil
+	}
+	return goof.WithField("groupBy", groupBy, "invalid usage grouping")
+}
+
+// Report aggregates samples into the GiB-hours of each group. The items
+// that do not belong to a group, such as volumes without a tenant, are
+// reported in the group with an empty name. The rows are sorted by group.
+func Report(
+	config gofig.Config, samples []*Sample, groupBy string) ([]*Row, error) {
+
+	if err := ValidGroupBy(groupBy); err != nil {
+		return nil, err
+	}
+	key := groupKey(config, groupBy)
+
+	rows := map[string]*Row{}
+	row := func(item *Item) *Row {
+		g := key(item)
+		r, ok := rows[g]
+		if !ok {
+			r = &Row{
+				Group:     g,
+				volumes:   map[string]bool{},
+				snapshots: map[string]bool{},
+			}
+			rows[g] = r
+		}
+		return r
+	}
+	for _, sample := range samples {
+		for _, v := range sample.Volumes {
+			r := row(v)
+			r.VolumeGiBHours += float64(v.Size) * sample.Hours
+			r.volumes[v.Service+"/"+v.ID] = true
+		}
+		for _, sn := range sample.Snapshots {
+			r := row(sn)
+			r.SnapshotGiBHours += float64(sn.Size) * sample.Hours
+			r.snapshots[sn.Service+"/"+sn.ID] = true
+		}
+	}
+
+	all := []*Row{}
+	for _, r := range rows {
+		r.Volumes, r.Snapshots = len(r.volumes), len(r.snapshots)
+		all = append(all, r)
+	}
+	sort.Sort(byGroup(all))
+	return all, nil
+}
+
+func groupKey(config gofig.Config, groupBy string) func(*Item) string {
+	switch groupBy {
+	case GroupTenant:
+		tenantLabel := quota.TenantLabel(config)
+		return func(i *Item) string { return i.Labels[tenantLabel] }
+	case GroupPrincipal:
+		return func(i *Item) string { return i.Owner }
+	case GroupService:
+		return func(i *Item) string { return i.Service }
+	case GroupVolume:
+		return func(i *Item) string { return i.Service + "/" + i.Volume }
+	}
+	k := strings.TrimPrefix(groupBy, GroupLabelPrefix)
+	return func(i *Item) string { return i.Labels[k] }
+}
+
+type byGroup []*Row
+
+func (b byGroup) Len() int           { return len(b) }
+func (b byGroup) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byGroup) Less(i, j int) bool { return b[i].Group < b[j].Group }
@@ -0,0 +1,58 @@
+package usage
+
+import (
+	"testing"
+
+	"github.com/emccode/rexray/core/labels"
+)
+
+func TestReport(t *testing.T) {
+	prod := labels.Labels{"env": "prod"}
+	samples := []*Sample{
+		{
+			Hours: 1,
+			Volumes: []*Item{
+				{ID: "vol-1", Service: "ebs", Size: 10, Labels: prod},
+				{ID: "vol-2", Service: "ebs", Size: 5},
+			},
+			Snapshots: []*Item{
+				{ID: "snap-1", Service: "ebs", Size: 10, Labels: prod},
+			},
+		},
+		{
+			Hours: 2,
+			Volumes: []*Item{
+				{ID: "vol-1", Service: "ebs", Size: 20, Labels: prod},
+			},
+		},
+	}
+
+	rows, err := Report(nil, samples, GroupLabelPrefix+"env")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("rows=%d", len(rows))
+	}
+	if r := rows[0]; r.Group != "" || r.VolumeGiBHours != 5 ||
+		r.Volumes != 1 || r.Snapshots != 0 {
+		t.Fatalf("row=%+v", r)
+	}
+	if r := rows[1]; r.Group != "prod" || r.VolumeGiBHours != 50 ||
+		r.SnapshotGiBHours != 10 || r.Volumes != 1 || r.Snapshots != 1 {
+		t.Fatalf("row=%+v", r)
+	}
+}
+
+func TestValidGroupBy(t *testing.T) {
+	for _, g := range []string{"tenant", "volume", "label:env"} {
+		if err := ValidGroupBy(g); err != nil {
+			t.Fatalf("%s: %v", g, err)
+		}
+	}
+	for _, g := range []string{"", "label:", "Tenant"} {
+		if err := ValidGroupBy(g); err == nil {
+			t.Fatalf("%s: expected error", g)
+		}
+	}
+}
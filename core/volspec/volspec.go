@@ -0,0 +1,352 @@
+// Package volspec describes a set of volumes declaratively so that they may
+// be kept in version control and applied to services, which creates the
+// volumes that are missing and reports those that have drifted from their
+// description. Volumes are never modified or removed by an apply.
+package volspec
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	apitypes "github.com/emccode/libstorage/api/types"
+	apiutils "github.com/emccode/libstorage/api/utils"
+	"gopkg.in/yaml.v1"
+
+	"github.com/emccode/rexray/core/labels"
+	"github.com/emccode/rexray/core/policy"
+	"github.com/emccode/rexray/core/state"
+)
+
+const (
+	// ActionCreate is the action of a volume that is missing.
+	ActionCreate = "create"
+
+	// ActionDrift is the action of a volume that exists but differs from its
+	// description.
+	ActionDrift = "drift"
+
+	// ActionNone is the action of a volume that matches its description.
+	ActionNone = "none"
+)
+
+// Spec is a set of volumes.
+type Spec struct {
+	Volumes []*Volume `json:"volumes" yaml:"volumes"`
+}
+
+// Volume describes a volume. Empty fields are not compared to the volume.
+type Volume struct {
+	Name string `json:"name" yaml:"name"`
+
+	// Service is the name of the volume's service. The configured service
+	// is used if it is empty.
+	Service string `json:"service,omitempty" yaml:"service,omitempty"`
+
+	// Size is the volume's size in GiB.
+	Size int64 `json:"size,omitempty" yaml:"size,omitempty"`
+
+	Type             string `json:"type,omitempty" yaml:"type,omitempty"`
+	IOPS             int64  `json:"iops,omitempty" yaml:"iops,omitempty"`
+	AvailabilityZone string `json:"availabilityZone,omitempty" yaml:"availabilityZone,omitempty"`
+
+	Labels labels.Labels `json:"labels,omitempty" yaml:"labels,omitempty"`
+
+	// Opts are the driver-specific options with which the volume is
+	// created. They cannot be read back from a volume, so they are not
+	// compared.
+	Opts map[string]string `json:"opts,omitempty" yaml:"opts,omitempty"`
+}
+
+// Change is the result of applying the description of a volume.
+type Change struct {
+	Service  string `json:"service,omitempty" yaml:"service,omitempty"`
+	Volume   string `json:"volume" yaml:"volume"`
+	VolumeID string `json:"volumeID,omitempty" yaml:"volumeID,omitempty"`
+	Action   string `json:"action" yaml:"action"`
+
+	// Drift lists the fields of a volume that differ from its description.
+	Drift []string `json:"drift,omitempty" yaml:"drift,omitempty"`
+
+	Error string `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// Parse parses a YAML or JSON spec.
+func Parse(buf []byte) (*Spec, error) {
+	s := &Spec{}
+	if err := yaml.Unmarshal(buf, s); err != nil {
+		return nil, goof.WithFieldE("format", "yaml", "invalid volume spec", err)
+	}
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// ReadFile reads a spec from a file.
+func ReadFile(path string) (*Spec, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s, err := Parse(buf)
+	if err != nil {
+		return nil, goof.WithFieldE("path", path, "error reading spec", err)
+	}
+	return s, nil
+}
+
+// Validate returns an error if a volume has no name or if a service's
+// volumes are described more than once.
+func (s *Spec) Validate() error {
+	names := map[string]bool{}
+	for _, v := range s.Volumes {
+		if v.Name == "" {
+			return goof.New("volume spec missing name")
+		}
+		if v.Size < 0 || v.IOPS < 0 {
+			return goof.WithField("volume", v.Name, "invalid size or iops")
+		}
+		k := strings.ToLower(v.Service + "/" + v.Name)
+		if names[k] {
+			return goof.WithFields(goof.Fields{
+				"service": v.Service,
+				"volume":  v.Name,
+			}, "volume described more than once")
+		}
+		names[k] = true
+	}
+	return nil
+}
+
+// Export returns a spec that describes the named volumes of the provided
+// client's service. The service is recorded in the spec if it is not empty.
+func Export(
+	ctx apitypes.Context,
+	client apitypes.Client,
+	s *state.Store,
+	service string) (*Spec, error) {
+
+	vols, err := client.Storage().Volumes(
+		ctx, &apitypes.VolumesOpts{Attachments: false})
+	if err != nil {
+		return nil, err
+	}
+
+	spec := &Spec{Volumes: []*Volume{}}
+	for _, v := range vols {
+		if v.Name == "" {
+			continue
+		}
+		l, err := labels.Volume(s, v.ID)
+		if err != nil {
+			return nil, err
+		}
+		if len(l) == 0 {
+			l = nil
+		}
+		spec.Volumes = append(spec.Volumes, &Volume{
+			Name:             v.Name,
+			Service:          service,
+			Size:             v.Size,
+			Type:             v.Type,
+			IOPS:             v.IOPS,
+			AvailabilityZone: v.AvailabilityZone,
+			Labels:           l,
+		})
+	}
+	sort.Sort(byName(spec.Volumes))
+	return spec, nil
+}
+
+type byName []*Volume
+
+func (b byName) Len() int           { return len(b) }
+func (b byName) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byName) Less(i, j int) bool { return b[i].Name < b[j].Name }
+
+// Apply creates the volumes of a spec that are missing and reports those
+// that have drifted. Nothing is created if dryRun is true. The error of a
+// volume is recorded in its change, and an error is returned if any volume
+// failed.
+func Apply(
+	ctx apitypes.Context,
+	config gofig.Config,
+	client apitypes.Client,
+	s *state.Store,
+	spec *Spec,
+	dryRun bool) ([]*Change, error) {
+
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+
+	var (
+		changes []*Change
+		failed  int
+		clients = map[string]apitypes.Client{}
+		vols    = map[string][]*apitypes.Volume{}
+	)
+	for _, v := range spec.Volumes {
+		ch := &Change{Service: v.Service, Volume: v.Name}
+		changes = append(changes, ch)
+
+		k := strings.ToLower(v.Service)
+		if _, ok := clients[k]; !ok {
+			sc, err := Client(ctx, config, client, v.Service)
+			if err != nil {
+				return nil, err
+			}
+			all, err := sc.Storage().Volumes(
+				ctx, &apitypes.VolumesOpts{Attachments: false})
+			if err != nil {
+				return nil, goof.WithFieldE(
+					"service", v.Service, "error listing volumes", err)
+			}
+			clients[k], vols[k] = sc, all
+		}
+
+		if err := apply(
+			ctx, clients[k], s, vols[k], v, ch, dryRun); err != nil {
+			ch.Error = err.Error()
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return changes, goof.WithField(
+			"failed", failed, "error applying volume spec")
+	}
+	return changes, nil
+}
+
+func apply(
+	ctx apitypes.Context,
+	client apitypes.Client,
+	s *state.Store,
+	existing []*apitypes.Volume,
+	v *Volume,
+	ch *Change,
+	dryRun bool) error {
+
+	for _, vol := range existing {
+		if strings.ToLower(vol.Name) != strings.ToLower(v.Name) {
+			continue
+		}
+		ch.VolumeID = vol.ID
+		drift, err := diff(s, vol, v)
+		if err != nil {
+			return err
+		}
+		ch.Drift = drift
+		if len(drift) > 0 {
+			ch.Action = ActionDrift
+		} else {
+			ch.Action = ActionNone
+		}
+		return nil
+	}
+
+	ch.Action = ActionCreate
+	if dryRun {
+		return nil
+	}
+	vol, err := client.Storage().VolumeCreate(ctx, v.Name, createOpts(v))
+	if err != nil {
+		return err
+	}
+	ch.VolumeID = vol.ID
+	ctx.WithFields(map[string]interface{}{
+		"service":  v.Service,
+		"volume":   v.Name,
+		"volumeID": vol.ID,
+	}).Info("created volume from spec")
+	return nil
+}
+
+// diff returns the fields of a volume that differ from its description. The
+// size only drifts if the volume is smaller than described, since a driver
+// may round the size up.
+func diff(s *state.Store, vol *apitypes.Volume, v *Volume) ([]string, error) {
+	var drift []string
+	if v.Size > 0 && vol.Size < v.Size {
+		drift = append(drift, fmt.Sprintf("size: %d < %d", vol.Size, v.Size))
+	}
+	if v.Type != "" && !strings.EqualFold(vol.Type, v.Type) {
+		drift = append(drift, fmt.Sprintf("type: %s != %s", vol.Type, v.Type))
+	}
+	if v.IOPS > 0 && vol.IOPS != v.IOPS {
+		drift = append(drift, fmt.Sprintf("iops: %d != %d", vol.IOPS, v.IOPS))
+	}
+	if v.AvailabilityZone != "" &&
+		!strings.EqualFold(vol.AvailabilityZone, v.AvailabilityZone) {
+		drift = append(drift, fmt.Sprintf("availabilityZone: %s != %s",
+			vol.AvailabilityZone, v.AvailabilityZone))
+	}
+
+	if len(v.Labels) > 0 {
+		l, err := labels.Volume(s, vol.ID)
+		if err != nil {
+			return nil, err
+		}
+		keys := []string{}
+		for k := range v.Labels {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if actual, ok := l[k]; !ok {
+				drift = append(drift, fmt.Sprintf("labels.%s: missing", k))
+			} else if actual != v.Labels[k] {
+				drift = append(drift, fmt.Sprintf(
+					"labels.%s: %s != %s", k, actual, v.Labels[k]))
+			}
+		}
+	}
+	return drift, nil
+}
+
+func createOpts(v *Volume) *apitypes.VolumeCreateOpts {
+	opts := &apitypes.VolumeCreateOpts{Opts: apiutils.NewStore()}
+	if v.Size > 0 {
+		opts.Size = &v.Size
+	}
+	if v.Type != "" {
+		opts.Type = &v.Type
+	}
+	if v.IOPS > 0 {
+		opts.IOPS = &v.IOPS
+	}
+	if v.AvailabilityZone != "" {
+		opts.AvailabilityZone = &v.AvailabilityZone
+	}
+	for k, o := range v.Opts {
+		opts.Opts.Set(k, o)
+	}
+	if len(v.Labels) > 0 {
+		opts.Opts.Set(labels.OptKey, v.Labels.String())
+	}
+	return opts
+}
+
+// Client returns a client for the named service. The provided client is
+// returned for the configured service.
+func Client(
+	ctx apitypes.Context,
+	config gofig.Config,
+	client apitypes.Client,
+	service string) (apitypes.Client, error) {
+
+	if service == "" {
+		return client, nil
+	}
+	sc, err := config.Copy()
+	if err != nil {
+		return nil, err
+	}
+	sc.Set(apitypes.ConfigService, service)
+	return policy.New(ctx, sc)
+}
@@ -0,0 +1,43 @@
+package volspec
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	s, err := Parse([]byte(`
+volumes:
+- name: pg-data
+  size: 100
+  labels:
+    env: prod
+  opts:
+    encrypted: "true"
+- name: pg-data
+  service: ebs-dr
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Volumes) != 2 {
+		t.Fatalf("volumes=%d", len(s.Volumes))
+	}
+	v := s.Volumes[0]
+	if v.Name != "pg-data" || v.Size != 100 || v.Labels["env"] != "prod" ||
+		v.Opts["encrypted"] != "true" {
+		t.Fatalf("volume=%+v", v)
+	}
+	if s.Volumes[1].Service != "ebs-dr" {
+		t.Fatalf("service=%s", s.Volumes[1].Service)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, spec := range []string{
+		"volumes:\n- size: 1\n",
+		"volumes:\n- name: a\n  size: -1\n",
+		"volumes:\n- name: a\n- name: A\n",
+	} {
+		if _, err := Parse([]byte(spec)); err == nil {
+			t.Fatalf("expected error: %s", spec)
+		}
+	}
+}
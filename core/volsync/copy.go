@@ -0,0 +1,133 @@
+package volsync
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/akutz/goof"
+)
+
+// chunkSize is the size of the blocks that are compared by a block sync.
+const chunkSize = 1 << 20
+
+// blockHashes are the checksums of the source's blocks as of the previous
+// run of a block sync. The destination's blocks match them unless the
+// destination was written to since, so only the source must be read.
+type blockHashes struct {
+	SourceID      string   `json:"sourceID"`
+	DestinationID string   `json:"destinationID"`
+	ChunkSize     int64    `json:"chunkSize"`
+	Hashes        []string `json:"hashes"`
+}
+
+// copyBlocks copies the blocks of the source device that differ from those
+// of the destination device. A block whose checksum matches the previous
+// one is skipped without reading the destination. Without previous
+// checksums the destination's block is read and compared instead. The
+// checksums of the source's blocks and the number of bytes written are
+// returned.
+func copyBlocks(src, dst string, size int64, prev []string) (
+	[]string, int64, error) {
+
+	sf, err := os.Open(src)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer sf.Close()
+
+	df, err := os.OpenFile(dst, os.O_RDWR, 0)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer df.Close()
+
+	srcSize, err := sf.Seek(0, os.SEEK_END)
+	if err != nil {
+		return nil, 0, err
+	}
+	dstSize, err := df.Seek(0, os.SEEK_END)
+	if err != nil {
+		return nil, 0, err
+	}
+	if dstSize < srcSize {
+		return nil, 0, goof.WithFields(goof.Fields{
+			"sourceSize":      srcSize,
+			"destinationSize": dstSize,
+		}, "destination is smaller than source")
+	}
+
+	sbuf := make([]byte, size)
+	dbuf := make([]byte, size)
+	hashes := []string{}
+	var written int64
+
+	for off, i := int64(0), 0; off < srcSize; off, i = off+size, i+1 {
+		n, err := sf.ReadAt(sbuf, off)
+		if err != nil && err != io.EOF {
+			return nil, written, err
+		}
+		sum := sha256.Sum256(sbuf[:n])
+		hash := hex.EncodeToString(sum[:])
+		hashes = append(hashes, hash)
+
+		if i < len(prev) && prev[i] == hash {
+			continue
+		}
+		if len(prev) == 0 {
+			m, err := df.ReadAt(dbuf[:n], off)
+			if err != nil && err != io.EOF {
+				return nil, written, err
+			}
+			if m == n && bytes.Equal(sbuf[:n], dbuf[:n]) {
+				continue
+			}
+		}
+		if _, err := df.WriteAt(sbuf[:n], off); err != nil {
+			return nil, written, err
+		}
+		written += int64(n)
+	}
+
+	if err := df.Sync(); err != nil {
+		return nil, written, err
+	}
+	return hashes, written, nil
+}
+
+// rsync copies the files of the source path to the destination path and
+// removes the destination's files that the source does not have. Only the
+// changed parts of changed files are written.
+func rsync(src, dst string) (int64, error) {
+	out, err := exec.Command("rsync",
+		"-aHAX", "--delete", "--inplace", "--numeric-ids", "--stats",
+		strings.TrimSuffix(src, "/")+"/",
+		strings.TrimSuffix(dst, "/")+"/").CombinedOutput()
+	if err != nil {
+		return 0, goof.WithFieldsE(goof.Fields{
+			"srcPath": src,
+			"dstPath": dst,
+			"output":  strings.TrimSpace(string(out)),
+		}, "error syncing volume data", err)
+	}
+	return parseRsyncStats(out), nil
+}
+
+var literalData = regexp.MustCompile(`(?m)^Literal data: ([\d,]+) bytes`)
+
+// parseRsyncStats returns the amount of file data rsync sent, which is the
+// data that differed, from the statistics it prints with --stats.
+func parseRsyncStats(out []byte) int64 {
+	m := literalData.FindSubmatch(out)
+	if m == nil {
+		return 0
+	}
+	n, _ := strconv.ParseInt(strings.Replace(string(m[1]), ",", "", -1), 10, 64)
+	return n
+}
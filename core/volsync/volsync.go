@@ -0,0 +1,423 @@
+// Package volsync copies the data of a volume to another volume, which may
+// belong to another service or to a service of another libStorage server, and
+// repeats the copy on a schedule. Each copy transfers only what changed since
+// the previous one, which provides a simple form of replication for storage
+// platforms without native mirroring.
+package volsync
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	apitypes "github.com/emccode/libstorage/api/types"
+	apiutils "github.com/emccode/libstorage/api/utils"
+
+	"github.com/emccode/rexray/core/errcodes"
+	"github.com/emccode/rexray/core/policy"
+	"github.com/emccode/rexray/core/schedule"
+	"github.com/emccode/rexray/core/state"
+)
+
+const (
+	// ModeRsync copies the files of mounted volumes with rsync.
+	ModeRsync = "rsync"
+
+	// ModeBlock copies the changed blocks of raw block volumes.
+	ModeBlock = "block"
+
+	syncsBucket  = "volumeSyncs"
+	hashesBucket = "volumeSyncHashes"
+)
+
+// Endpoint identifies a volume of a service.
+type Endpoint struct {
+
+	// Host is the address of the libStorage server of the volume's service.
+	// The configured server is used if it is empty.
+	Host string `json:"host,omitempty" yaml:"host,omitempty"`
+
+	// Service is the name of the volume's service. The configured service
+	// is used if it is empty.
+	Service string `json:"service,omitempty" yaml:"service,omitempty"`
+
+	// Volume is the name of the volume.
+	Volume string `json:"volume" yaml:"volume"`
+}
+
+// ParseEndpoint parses an endpoint in the form [SERVICE/]VOLUME_NAME.
+func ParseEndpoint(s, host string) (*Endpoint, error) {
+	e := &Endpoint{Host: host, Volume: s}
+	if i := strings.Index(s, "/"); i >= 0 {
+		e.Service, e.Volume = s[:i], s[i+1:]
+	}
+	if e.Volume == "" {
+		return nil, goof.WithField("endpoint", s, "missing volume name")
+	}
+	return e, nil
+}
+
+func (e *Endpoint) String() string {
+	s := e.Volume
+	if e.Service != "" {
+		s = e.Service + "/" + s
+	}
+	if e.Host != "" {
+		s = s + "@" + e.Host
+	}
+	return s
+}
+
+func (e *Endpoint) isDefault() bool {
+	return e.Host == "" && e.Service == ""
+}
+
+// Sync copies the data of a source volume to a destination volume.
+type Sync struct {
+
+	// Name is the sync's name. A sync without a name is run once and is not
+	// recorded.
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+
+	Source      *Endpoint `json:"source" yaml:"source"`
+	Destination *Endpoint `json:"destination" yaml:"destination"`
+
+	// Mode is how the data is copied, either ModeRsync or ModeBlock.
+	Mode string `json:"mode" yaml:"mode"`
+
+	// Schedule is when the REX-Ray service runs the sync.
+	Schedule string `json:"schedule,omitempty" yaml:"schedule,omitempty"`
+
+	Created time.Time `json:"created" yaml:"created"`
+
+	// LastResult is the result of the sync's most recent run.
+	LastResult *Result `json:"lastResult,omitempty" yaml:"lastResult,omitempty"`
+}
+
+// Result is the result of a run of a sync.
+type Result struct {
+	Started  time.Time `json:"started" yaml:"started"`
+	Duration string    `json:"duration" yaml:"duration"`
+
+	// Bytes is the amount of data that was written to the destination.
+	Bytes int64 `json:"bytes" yaml:"bytes"`
+
+	Error string `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+// Validate returns an error if the sync is invalid.
+func (sy *Sync) Validate() error {
+	if sy.Source == nil || sy.Destination == nil {
+		return goof.New("missing source or destination")
+	}
+	if *sy.Source == *sy.Destination {
+		return goof.WithField("volume", sy.Source.String(),
+			"source and destination are the same volume")
+	}
+	switch sy.Mode {
+	case ModeRsync, ModeBlock:
+	default:
+		return goof.WithField("mode", sy.Mode, "invalid sync mode")
+	}
+	if sy.Schedule != "" {
+		if _, err := schedule.Parse(sy.Schedule); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Due returns a flag indicating whether a scheduled sync should run at the
+// provided time.
+func (sy *Sync) Due(now time.Time) bool {
+	if sy.Schedule == "" {
+		return false
+	}
+	sched, err := schedule.Parse(sy.Schedule)
+	if err != nil {
+		return false
+	}
+	last := sy.Created
+	if sy.LastResult != nil {
+		last = sy.LastResult.Started
+	}
+	next := sched.Next(last)
+	return !next.IsZero() && !now.Before(next)
+}
+
+// Set records a sync so that the REX-Ray service runs it on its schedule.
+func Set(s *state.Store, sy *Sync) error {
+	if sy.Name == "" {
+		return goof.New("missing sync name")
+	}
+	if sy.Schedule == "" {
+		return goof.WithField("sync", sy.Name, "missing sync schedule")
+	}
+	if err := sy.Validate(); err != nil {
+		return err
+	}
+	if sy.Created.IsZero() {
+		sy.Created = time.Now().UTC()
+	}
+	if err := s.Delete(hashesBucket, sy.Name); err != nil {
+		return err
+	}
+	return s.Set(syncsBucket, sy.Name, sy)
+}
+
+// Remove removes the sync with the provided name. Its volumes are not
+// affected.
+func Remove(s *state.Store, name string) error {
+	if err := s.Delete(hashesBucket, name); err != nil {
+		return err
+	}
+	return s.Delete(syncsBucket, name)
+}
+
+// Get returns the sync with the provided name.
+func Get(s *state.Store, name string) (*Sync, error) {
+	sy := &Sync{}
+	ok, err := s.Get(syncsBucket, name, sy)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errcodes.New(errcodes.NotFound,
+			goof.WithField("sync", name, "sync not found"))
+	}
+	return sy, nil
+}
+
+// List returns the recorded syncs.
+func List(s *state.Store) ([]*Sync, error) {
+	names, err := s.Keys(syncsBucket)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	all := []*Sync{}
+	for _, name := range names {
+		sy, err := Get(s, name)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, sy)
+	}
+	return all, nil
+}
+
+// Client returns a client for an endpoint's service. The provided client is
+// returned for an endpoint of the configured service and server.
+func Client(
+	ctx apitypes.Context,
+	config gofig.Config,
+	client apitypes.Client,
+	e *Endpoint) (apitypes.Client, error) {
+
+	if e.isDefault() {
+		return client, nil
+	}
+	ec, err := config.Copy()
+	if err != nil {
+		return nil, err
+	}
+	if e.Host != "" {
+		ec.Set(apitypes.ConfigHost, e.Host)
+	}
+	if e.Service != "" {
+		ec.Set(apitypes.ConfigService, e.Service)
+	}
+	return policy.New(ctx, ec)
+}
+
+// Run runs a sync. The volumes are mounted on the local host for the
+// duration of the copy. The result of a recorded sync is saved with it.
+func Run(
+	ctx apitypes.Context,
+	config gofig.Config,
+	client apitypes.Client,
+	s *state.Store,
+	sy *Sync) (*Result, error) {
+
+	if err := sy.Validate(); err != nil {
+		return nil, err
+	}
+
+	r := &Result{Started: time.Now().UTC()}
+	bytes, err := run(ctx, config, client, s, sy)
+	r.Duration = time.Since(r.Started).String()
+	r.Bytes = bytes
+	if err != nil {
+		r.Error = err.Error()
+	}
+
+	if sy.Name != "" {
+		sy.LastResult = r
+		stored := &Sync{}
+		if serr := s.Update(syncsBucket, sy.Name, stored,
+			func(ok bool) (bool, error) {
+				stored.LastResult = r
+				return ok, nil
+			}); serr != nil {
+			return r, serr
+		}
+	}
+
+	f := ctx.WithFields(map[string]interface{}{
+		"sync":        sy.Name,
+		"source":      sy.Source.String(),
+		"destination": sy.Destination.String(),
+		"bytes":       r.Bytes,
+		"duration":    r.Duration,
+	})
+	if err != nil {
+		f.WithError(err).Error("error syncing volume")
+		return r, err
+	}
+	f.Info("synced volume")
+	return r, nil
+}
+
+// RunDue runs the recorded syncs whose scheduled time has passed.
+func RunDue(
+	ctx apitypes.Context,
+	config gofig.Config,
+	client apitypes.Client,
+	s *state.Store) {
+
+	all, err := List(s)
+	if err != nil {
+		ctx.WithError(err).Error("error listing volume syncs")
+		return
+	}
+	now := time.Now()
+	for _, sy := range all {
+		if sy.Due(now) {
+			Run(ctx, config, client, s, sy)
+		}
+	}
+}
+
+type mounted struct {
+	client apitypes.Client
+	vol    *apitypes.Volume
+	path   string
+}
+
+func (m *mounted) unmount(ctx apitypes.Context) {
+	if err := m.client.Integration().Unmount(
+		ctx, m.vol.ID, "", apiutils.NewStore()); err != nil {
+		ctx.WithError(err).WithField("volumeID", m.vol.ID).Warn(
+			"error unmounting volume")
+	}
+}
+
+func mount(
+	ctx apitypes.Context,
+	config gofig.Config,
+	client apitypes.Client,
+	e *Endpoint,
+	mode string) (*mounted, error) {
+
+	ec, err := Client(ctx, config, client, e)
+	if err != nil {
+		return nil, goof.WithFieldE("endpoint", e.String(),
+			"error connecting to service", err)
+	}
+	vol, err := volumeByName(ctx, ec, e.Volume)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := apiutils.NewStore()
+	if mode == ModeBlock {
+		opts.Set(policy.VolumeModeKey, policy.VolumeModeBlock)
+	}
+	path, _, err := ec.Integration().Mount(
+		ctx, vol.ID, "", &apitypes.VolumeMountOpts{Opts: opts})
+	if err != nil {
+		return nil, goof.WithFieldE("endpoint", e.String(),
+			"error mounting volume", err)
+	}
+	if mode == ModeBlock {
+		path = policy.BlockDevicePath(path)
+	}
+	return &mounted{client: ec, vol: vol, path: path}, nil
+}
+
+func run(
+	ctx apitypes.Context,
+	config gofig.Config,
+	client apitypes.Client,
+	s *state.Store,
+	sy *Sync) (int64, error) {
+
+	src, err := mount(ctx, config, client, sy.Source, sy.Mode)
+	if err != nil {
+		return 0, err
+	}
+	defer src.unmount(ctx)
+
+	dst, err := mount(ctx, config, client, sy.Destination, sy.Mode)
+	if err != nil {
+		return 0, err
+	}
+	defer dst.unmount(ctx)
+
+	if sy.Mode == ModeRsync {
+		return rsync(src.path, dst.path)
+	}
+	return syncBlocks(s, sy.Name, src, dst)
+}
+
+// syncBlocks copies the blocks of the source that changed since the previous
+// run of the sync. The checksums of the blocks are kept only for a recorded
+// sync between the same volumes.
+func syncBlocks(
+	s *state.Store, name string, src, dst *mounted) (int64, error) {
+
+	prev := &blockHashes{}
+	if name != "" {
+		if _, err := s.Get(hashesBucket, name, prev); err != nil {
+			return 0, err
+		}
+		if prev.SourceID != src.vol.ID ||
+			prev.DestinationID != dst.vol.ID ||
+			prev.ChunkSize != chunkSize {
+			prev = &blockHashes{}
+		}
+	}
+
+	hashes, written, err := copyBlocks(
+		src.path, dst.path, chunkSize, prev.Hashes)
+	if err != nil || name == "" {
+		return written, err
+	}
+	return written, s.Set(hashesBucket, name, &blockHashes{
+		SourceID:      src.vol.ID,
+		DestinationID: dst.vol.ID,
+		ChunkSize:     chunkSize,
+		Hashes:        hashes,
+	})
+}
+
+func volumeByName(
+	ctx apitypes.Context,
+	client apitypes.Client,
+	name string) (*apitypes.Volume, error) {
+
+	vols, err := client.Storage().Volumes(
+		ctx, &apitypes.VolumesOpts{Attachments: false})
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range vols {
+		if strings.ToLower(v.Name) == strings.ToLower(name) {
+			return v, nil
+		}
+	}
+	return nil, errcodes.New(errcodes.VolumeNotFound,
+		goof.WithField("volumeName", name, "volume not found"))
+}
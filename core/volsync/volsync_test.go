@@ -0,0 +1,100 @@
+package volsync
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, path string, data []byte) {
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCopyBlocks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "volsync")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	src := filepath.Join(dir, "src")
+	dst := filepath.Join(dir, "dst")
+	data := bytes.Repeat([]byte("abcdefgh"), 8)
+	writeFile(t, src, data)
+	writeFile(t, dst, make([]byte, len(data)))
+
+	hashes, written, err := copyBlocks(src, dst, 16, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hashes) != 4 || written != 64 {
+		t.Fatalf("hashes=%d written=%d", len(hashes), written)
+	}
+
+	data[20] = 'z'
+	writeFile(t, src, data)
+	if hashes, written, err = copyBlocks(src, dst, 16, hashes); err != nil {
+		t.Fatal(err)
+	}
+	if written != 16 {
+		t.Fatalf("written=%d", written)
+	}
+	buf, _ := ioutil.ReadFile(dst)
+	if !bytes.Equal(buf, data) {
+		t.Fatalf("dst=%s", buf)
+	}
+
+	if _, written, err = copyBlocks(src, dst, 16, nil); err != nil {
+		t.Fatal(err)
+	}
+	if written != 0 {
+		t.Fatalf("written=%d after compare", written)
+	}
+
+	writeFile(t, dst, make([]byte, 8))
+	if _, _, err := copyBlocks(src, dst, 16, nil); err == nil {
+		t.Fatal("expected error for smaller destination")
+	}
+}
+
+func TestParseRsyncStats(t *testing.T) {
+	out := []byte("Number of files: 3\nLiteral data: 1,048,576 bytes\n" +
+		"Matched data: 0 bytes\n")
+	if n := parseRsyncStats(out); n != 1048576 {
+		t.Fatalf("n=%d", n)
+	}
+}
+
+func TestDue(t *testing.T) {
+	created := time.Date(2016, 6, 1, 12, 0, 0, 0, time.UTC)
+	sy := &Sync{Schedule: "@every 1h", Created: created}
+	if sy.Due(created.Add(30 * time.Minute)) {
+		t.Fatal("due before first run")
+	}
+	if !sy.Due(created.Add(time.Hour)) {
+		t.Fatal("not due at first run")
+	}
+	sy.LastResult = &Result{Started: created.Add(time.Hour)}
+	if sy.Due(created.Add(90 * time.Minute)) {
+		t.Fatal("due before next run")
+	}
+}
+
+func TestParseEndpoint(t *testing.T) {
+	e, err := ParseEndpoint("ebs-dr/db01", "tcp://dr:7979")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Service != "ebs-dr" || e.Volume != "db01" ||
+		e.String() != "ebs-dr/db01@tcp://dr:7979" {
+		t.Fatalf("endpoint=%v", e)
+	}
+	if _, err := ParseEndpoint("ebs-dr/", ""); err == nil {
+		t.Fatal("expected error for missing volume")
+	}
+}
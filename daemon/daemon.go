@@ -8,6 +8,9 @@ import (
 	"github.com/akutz/gofig"
 	apitypes "github.com/emccode/libstorage/api/types"
 
+	"github.com/emccode/rexray/core/configsrc"
+	"github.com/emccode/rexray/core/preflight"
+	"github.com/emccode/rexray/core/tracing"
 	"github.com/emccode/rexray/daemon/module"
 	"github.com/emccode/rexray/util"
 )
@@ -25,6 +28,16 @@ func Start(
 		serverErrChan <-chan error
 	)
 
+	if err = tracing.Init(ctx, config); err != nil {
+		ctx.WithError(err).Error("error initializing tracing")
+		return nil, err
+	}
+
+	if err = preflight.Verify(ctx, config); err != nil {
+		ctx.WithError(err).Error("pre-flight checks failed")
+		return nil, err
+	}
+
 	if serverErrChan, err = module.InitializeDefaultModules(
 		ctx, config); err != nil {
 		ctx.WithError(err).Error("default module(s) failed to initialize")
@@ -36,12 +49,21 @@ func Start(
 		return nil, err
 	}
 
+	done := make(chan struct{})
+	if err = configsrc.Watch(
+		ctx, config, util.RestartLibStorage, done); err != nil {
+		ctx.WithError(err).Error("error watching configuration source")
+		return nil, err
+	}
+
 	ctx.Info("service successfully initialized, waiting on stop signal")
 
 	go func() {
 		sig := <-stop
 		ctx.WithField("signal", sig).Info("service received stop signal")
+		close(done)
 		util.WaitUntilLibStorageStopped(ctx, serverErrChan)
+		tracing.Shutdown()
 		close(errs)
 	}()
 
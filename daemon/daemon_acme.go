@@ -0,0 +1,55 @@
+package daemon
+
+import (
+	"time"
+
+	"github.com/akutz/gofig"
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/core/acme"
+	"github.com/emccode/rexray/core/certs"
+	"github.com/emccode/rexray/core/schedule"
+	"github.com/emccode/rexray/util"
+)
+
+const renewControllerCertJob = "acme.renewControllerCertificate"
+
+func init() {
+	util.RegisterCertificateSource(controllerCertificate)
+}
+
+// controllerCertificate obtains the libStorage endpoints' certificate if
+// there is none or it is due for renewal, and schedules its renewal. The
+// server starts with the previous certificate if it has not expired and a new
+// one cannot be obtained.
+func controllerCertificate(
+	ctx apitypes.Context,
+	config gofig.Config) (certs.GetCertificateFunc, error) {
+
+	if !acme.ControllerEnabled(config) {
+		return nil, nil
+	}
+
+	m, err := acme.New(ctx, config, util.LibFilePath("acme"))
+	if err != nil {
+		return nil, err
+	}
+	if err := m.Renew(); err != nil {
+		if !m.Ready() {
+			return nil, err
+		}
+		ctx.WithError(err).Warn("error renewing acme certificate")
+	}
+	if err := schedule.Default(ctx).Add(&schedule.Job{
+		Name:     renewControllerCertJob,
+		Schedule: schedule.Every(12 * time.Hour),
+		Run: func() {
+			if err := m.Renew(); err != nil {
+				ctx.WithError(err).Error("error renewing acme certificate")
+			}
+		},
+	}); err != nil {
+		return nil, err
+	}
+	return m.TLSConfig().GetCertificate, nil
+}
@@ -5,5 +5,8 @@ package daemon
 import (
 	// load the modules
 	_ "github.com/emccode/rexray/daemon/module/admin"
+	_ "github.com/emccode/rexray/daemon/module/agent"
+	_ "github.com/emccode/rexray/daemon/module/csi"
 	_ "github.com/emccode/rexray/daemon/module/docker/volumedriver"
+	_ "github.com/emccode/rexray/daemon/module/ui"
 )
@@ -0,0 +1,243 @@
+package admin
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/gorilla/websocket"
+
+	"github.com/emccode/rexray/core/errcodes"
+	"github.com/emccode/rexray/core/events"
+)
+
+const (
+	// eventPollInterval is how often a stream checks for events emitted by
+	// other processes, such as the CLI, that share the state.
+	eventPollInterval = time.Second
+
+	// eventKeepAlive is how often an idle stream is written to so that
+	// proxies do not close it and closed clients are noticed.
+	eventKeepAlive = 15 * time.Second
+
+	eventWriteTimeout = 10 * time.Second
+)
+
+var eventUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+// eventStreamHandler streams events as they are emitted, as server-sent
+// events or, if the request asks to be upgraded, over a websocket. The
+// events may be filtered with the type and volume query parameters, each of
+// which may be repeated or given as a comma-separated list. The stream
+// starts with the events after the ID in the since parameter or the
+// Last-Event-ID header, if any.
+func (m *mod) eventStreamHandler(w http.ResponseWriter, req *http.Request) {
+	f := &events.Filter{
+		Types:     queryList(req, "type"),
+		VolumeIDs: queryList(req, "volume"),
+	}
+
+	since, err := m.eventStreamStart(req)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(getJSONError(
+			"Error parsing last event ID",
+			errcodes.New(errcodes.InvalidRequest, err)))
+		return
+	}
+
+	if websocket.IsWebSocketUpgrade(req) {
+		m.streamWebsocket(w, req, since, f)
+		return
+	}
+	m.streamSSE(w, since, f)
+}
+
+// eventStreamStart returns the ID after which a stream's events start, which
+// is the ID of the most recent event unless the client provides one.
+func (m *mod) eventStreamStart(req *http.Request) (int64, error) {
+	v := req.Header.Get("Last-Event-ID")
+	if v == "" {
+		v = req.FormValue("since")
+	}
+	if v != "" {
+		return strconv.ParseInt(v, 10, 64)
+	}
+	all, err := events.List(m.store)
+	if err != nil || len(all) == 0 {
+		return 0, err
+	}
+	return all[len(all)-1].ID, nil
+}
+
+// streamSSE writes events to a hijacked connection so that the stream is not
+// cut off by the server's write timeout. The body is delimited by closing the
+// connection.
+func (m *mod) streamSSE(w http.ResponseWriter, since int64, f *events.Filter) {
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		log.WithError(err).Error("error hijacking event stream connection")
+		return
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Time{})
+
+	write := func(fn func(w *bufio.Writer)) error {
+		conn.SetWriteDeadline(time.Now().Add(eventWriteTimeout))
+		fn(rw.Writer)
+		return rw.Flush()
+	}
+
+	if err := write(func(w *bufio.Writer) {
+		fmt.Fprint(w, "HTTP/1.1 200 OK\r\n"+
+			"Content-Type: text/event-stream\r\n"+
+			"Cache-Control: no-cache\r\n"+
+			"Connection: close\r\n\r\n")
+	}); err != nil {
+		return
+	}
+
+	// the client sends nothing after its request, so a read returns when the
+	// connection is closed
+	done := make(chan struct{})
+	go func() {
+		io.Copy(ioutil.Discard, rw.Reader)
+		close(done)
+	}()
+
+	err = m.streamEvents(since, f, done,
+		func(e *events.Event) error {
+			buf, err := json.Marshal(e)
+			if err != nil {
+				return err
+			}
+			return write(func(w *bufio.Writer) {
+				fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n",
+					e.ID, e.Type, buf)
+			})
+		},
+		func() error {
+			return write(func(w *bufio.Writer) {
+				fmt.Fprint(w, ": keepalive\n\n")
+			})
+		})
+	if err != nil {
+		log.WithError(err).Debug("event stream closed")
+	}
+}
+
+// streamWebsocket writes each event as a JSON text message.
+func (m *mod) streamWebsocket(
+	w http.ResponseWriter, req *http.Request,
+	since int64, f *events.Filter) {
+
+	conn, err := eventUpgrader.Upgrade(w, req, nil)
+	if err != nil {
+		log.WithError(err).Error("error upgrading event stream connection")
+		return
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Time{})
+
+	// the messages sent by the client are discarded, but they must be read
+	// for its close and ping messages to be handled
+	done := make(chan struct{})
+	go func() {
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				close(done)
+				return
+			}
+		}
+	}()
+
+	err = m.streamEvents(since, f, done,
+		func(e *events.Event) error {
+			conn.SetWriteDeadline(time.Now().Add(eventWriteTimeout))
+			return conn.WriteJSON(e)
+		},
+		func() error {
+			return conn.WriteControl(websocket.PingMessage, nil,
+				time.Now().Add(eventWriteTimeout))
+		})
+	if err != nil {
+		log.WithError(err).Debug("event stream closed")
+	}
+}
+
+// streamEvents sends the events after the provided ID that match the filter
+// until done is closed or an event cannot be sent. Events emitted by this
+// process are sent as they are emitted, and the events emitted by other
+// processes as they are polled.
+func (m *mod) streamEvents(
+	since int64,
+	f *events.Filter,
+	done <-chan struct{},
+	send func(e *events.Event) error,
+	keepAlive func() error) error {
+
+	watch, stop := events.Watch()
+	defer stop()
+
+	poll := time.NewTicker(eventPollInterval)
+	defer poll.Stop()
+
+	idle := time.NewTicker(eventKeepAlive)
+	defer idle.Stop()
+
+	for {
+		all, err := events.Since(m.store, since)
+		if err != nil {
+			return err
+		}
+		for _, e := range all {
+			since = e.ID
+			if f.Match(e) {
+				if err := send(e); err != nil {
+					return err
+				}
+			}
+		}
+
+		select {
+		case <-watch:
+		case <-poll.C:
+		case <-idle.C:
+			if err := keepAlive(); err != nil {
+				return err
+			}
+		case <-done:
+			return nil
+		}
+	}
+}
+
+// queryList returns the values of a query parameter that may be repeated or
+// given as a comma-separated list.
+func queryList(req *http.Request, name string) []string {
+	req.ParseForm()
+	var values []string
+	for _, v := range req.Form[name] {
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				values = append(values, s)
+			}
+		}
+	}
+	return values
+}
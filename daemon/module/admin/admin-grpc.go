@@ -0,0 +1,352 @@
+package admin
+
+//go:generate protoc -I adminpb --go_out=plugins=grpc:adminpb adminpb/admin.proto
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/akutz/gotil"
+	apitypes "github.com/emccode/libstorage/api/types"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+
+	"github.com/emccode/rexray/core/errcodes"
+	"github.com/emccode/rexray/core/tasks"
+	"github.com/emccode/rexray/daemon/module"
+	"github.com/emccode/rexray/daemon/module/admin/adminpb"
+)
+
+type grpcServer struct {
+	m *mod
+}
+
+// startGRPC serves the admin API over gRPC at the provided address.
+func (m *mod) startGRPC(addr string) error {
+	proto, laddr, err := gotil.ParseAddress(addr)
+	if err != nil {
+		return err
+	}
+
+	if proto == "unix" {
+		os.MkdirAll(filepath.Dir(laddr), 0755)
+		os.Remove(laddr)
+	}
+
+	l, err := net.Listen(proto, laddr)
+	if err != nil {
+		return err
+	}
+
+	s := grpc.NewServer(grpc.UnaryInterceptor(statusInterceptor))
+	adminpb.RegisterAdminServer(s, &grpcServer{m})
+
+	m.serversLock.Lock()
+	m.grpc = s
+	m.serversLock.Unlock()
+
+	go func() {
+		if err := s.Serve(l); err != nil && m.serving(s) {
+			m.fail(err, "admin gRPC server failed")
+		}
+	}()
+
+	m.ctx.WithField("address", addr).Info("serving admin gRPC API")
+	return nil
+}
+
+// statusInterceptor returns the errors of the gRPC methods with the gRPC
+// status of their codes, so that clients may branch on the type of a
+// failure as they do on the code of an HTTP response.
+func statusInterceptor(
+	ctx context.Context,
+	req interface{},
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler) (interface{}, error) {
+
+	res, err := handler(ctx, req)
+	if err != nil {
+		return nil, grpc.Errorf(grpcCode(errcodes.Of(err)), "%v", err)
+	}
+	return res, nil
+}
+
+// grpcCode returns the gRPC status code of an error code.
+func grpcCode(c errcodes.Code) codes.Code {
+	switch c {
+	case errcodes.InvalidRequest:
+		return codes.InvalidArgument
+	case errcodes.NotFound, errcodes.VolumeNotFound,
+		errcodes.SnapshotNotFound:
+		return codes.NotFound
+	case errcodes.AlreadyAttached, errcodes.VolumePinned,
+		errcodes.VolumeProtected, errcodes.VolumeTrashed,
+		errcodes.GroupFailed:
+		return codes.FailedPrecondition
+	case errcodes.QuotaExceeded, errcodes.RateLimited,
+		errcodes.BackendThrottled, errcodes.CapacityExhausted:
+		return codes.ResourceExhausted
+	case errcodes.ServiceInMaintenance, errcodes.BackendUnavailable:
+		return codes.Unavailable
+	case errcodes.Timeout:
+		return codes.DeadlineExceeded
+	case errcodes.Canceled:
+		return codes.Canceled
+	case errcodes.Unauthorized:
+		return codes.PermissionDenied
+	case errcodes.NotImplemented:
+		return codes.Unimplemented
+	}
+	return codes.Unknown
+}
+
+func (s *grpcServer) ListVolumes(
+	ctx context.Context,
+	req *adminpb.ListVolumesRequest) (*adminpb.ListVolumesResponse, error) {
+
+	vols, err := s.m.listVolumes(req.Attachments)
+	if err != nil {
+		return nil, err
+	}
+	res := &adminpb.ListVolumesResponse{}
+	for _, v := range vols {
+		res.Volumes = append(res.Volumes, toVolume(v))
+	}
+	return res, nil
+}
+
+func (s *grpcServer) InspectVolume(
+	ctx context.Context,
+	req *adminpb.InspectVolumeRequest) (*adminpb.Volume, error) {
+
+	vol, err := s.m.inspectVolume(req.Id, req.Attachments)
+	if err != nil {
+		return nil, err
+	}
+	return toVolume(vol), nil
+}
+
+func (s *grpcServer) ListSnapshots(
+	ctx context.Context,
+	req *adminpb.ListSnapshotsRequest) (*adminpb.ListSnapshotsResponse, error) {
+
+	snaps, err := s.m.listSnapshots()
+	if err != nil {
+		return nil, err
+	}
+	res := &adminpb.ListSnapshotsResponse{}
+	for _, v := range snaps {
+		res.Snapshots = append(res.Snapshots, toSnapshot(v))
+	}
+	return res, nil
+}
+
+func (s *grpcServer) ListServices(
+	ctx context.Context,
+	req *adminpb.ListServicesRequest) (*adminpb.ListServicesResponse, error) {
+
+	svcs, err := s.m.listServices()
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for name := range svcs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	res := &adminpb.ListServicesResponse{}
+	for _, name := range names {
+		svc := &adminpb.Service{Name: name}
+		if d := svcs[name].Driver; d != nil {
+			svc.Driver = d.Name
+		}
+		res.Services = append(res.Services, svc)
+	}
+	return res, nil
+}
+
+func (s *grpcServer) ListTasks(
+	ctx context.Context,
+	req *adminpb.ListTasksRequest) (*adminpb.ListTasksResponse, error) {
+
+	all, err := s.m.listTasks()
+	if err != nil {
+		return nil, err
+	}
+	res := &adminpb.ListTasksResponse{}
+	for _, t := range all {
+		res.Tasks = append(res.Tasks, toTask(t))
+	}
+	return res, nil
+}
+
+func (s *grpcServer) ListModules(
+	ctx context.Context,
+	req *adminpb.ListModulesRequest) (*adminpb.ListModulesResponse, error) {
+
+	res := &adminpb.ListModulesResponse{}
+	for _, mi := range listModules() {
+		res.Modules = append(res.Modules, toModule(mi))
+	}
+	return res, nil
+}
+
+func (s *grpcServer) CreateVolume(
+	ctx context.Context,
+	req *adminpb.CreateVolumeRequest) (*adminpb.Volume, error) {
+
+	opts := &apitypes.VolumeCreateOpts{Opts: optsStore(req.Opts)}
+	if req.Size > 0 {
+		opts.Size = &req.Size
+	}
+	if req.Iops > 0 {
+		opts.IOPS = &req.Iops
+	}
+	if req.Type != "" {
+		opts.Type = &req.Type
+	}
+	if req.AvailabilityZone != "" {
+		opts.AvailabilityZone = &req.AvailabilityZone
+	}
+	vol, err := s.m.createVolume(req.Name, opts)
+	if err != nil {
+		return nil, err
+	}
+	return toVolume(vol), nil
+}
+
+func (s *grpcServer) RemoveVolume(
+	ctx context.Context,
+	req *adminpb.RemoveVolumeRequest) (*adminpb.RemoveVolumeResponse, error) {
+
+	if err := s.m.removeVolume(req.Id, req.Purge); err != nil {
+		return nil, err
+	}
+	return &adminpb.RemoveVolumeResponse{}, nil
+}
+
+func (s *grpcServer) CreateSnapshot(
+	ctx context.Context,
+	req *adminpb.CreateSnapshotRequest) (*adminpb.Snapshot, error) {
+
+	snap, err := s.m.createSnapshot(req.VolumeId, req.Name, optsStore(req.Opts))
+	if err != nil {
+		return nil, err
+	}
+	return toSnapshot(snap), nil
+}
+
+func (s *grpcServer) RemoveSnapshot(
+	ctx context.Context,
+	req *adminpb.RemoveSnapshotRequest) (
+	*adminpb.RemoveSnapshotResponse, error) {
+
+	if err := s.m.removeSnapshot(req.Id); err != nil {
+		return nil, err
+	}
+	return &adminpb.RemoveSnapshotResponse{}, nil
+}
+
+func (s *grpcServer) SubmitTask(
+	ctx context.Context,
+	req *adminpb.SubmitTaskRequest) (*adminpb.Task, error) {
+
+	t, err := s.m.submitTask(req.Operation, req.Params)
+	if err != nil {
+		return nil, err
+	}
+	return toTask(t), nil
+}
+
+func (s *grpcServer) InspectTask(
+	ctx context.Context,
+	req *adminpb.InspectTaskRequest) (*adminpb.Task, error) {
+
+	t, err := s.m.inspectTask(req.Id)
+	if err != nil {
+		return nil, err
+	}
+	return toTask(t), nil
+}
+
+func (s *grpcServer) StartModule(
+	ctx context.Context,
+	req *adminpb.StartModuleRequest) (*adminpb.Module, error) {
+
+	mi, err := s.m.startModule(req.Name)
+	if err != nil {
+		return nil, err
+	}
+	return toModule(mi), nil
+}
+
+func toSnapshot(v *apitypes.Snapshot) *adminpb.Snapshot {
+	return &adminpb.Snapshot{
+		Id:          v.ID,
+		Name:        v.Name,
+		VolumeId:    v.VolumeID,
+		VolumeSize:  v.VolumeSize,
+		StartTime:   v.StartTime,
+		Description: v.Description,
+		Status:      v.Status,
+	}
+}
+
+func toTask(t *tasks.Task) *adminpb.Task {
+	task := &adminpb.Task{
+		Id:        t.ID,
+		Operation: t.Operation,
+		State:     string(t.State),
+		Progress:  int32(t.Progress),
+		Error:     t.Error,
+		QueueTime: t.QueueTime.Unix(),
+	}
+	if !t.StartTime.IsZero() {
+		task.StartTime = t.StartTime.Unix()
+	}
+	if !t.CompleteTime.IsZero() {
+		task.CompleteTime = t.CompleteTime.Unix()
+	}
+	return task
+}
+
+func toModule(mi *module.Instance) *adminpb.Module {
+	mod := &adminpb.Module{
+		Name:        mi.Name,
+		Type:        mi.TypeName,
+		Description: mi.Description,
+		Started:     mi.IsStarted,
+	}
+	if mi.Config != nil {
+		mod.Address = mi.Config.Address
+	}
+	return mod
+}
+
+func toVolume(v *apitypes.Volume) *adminpb.Volume {
+	vol := &adminpb.Volume{
+		Id:               v.ID,
+		Name:             v.Name,
+		Size:             v.Size,
+		Type:             v.Type,
+		Iops:             v.IOPS,
+		AvailabilityZone: v.AvailabilityZone,
+		Status:           v.Status,
+	}
+	for _, a := range v.Attachments {
+		att := &adminpb.Attachment{
+			DeviceName: a.DeviceName,
+			MountPoint: a.MountPoint,
+			Status:     a.Status,
+		}
+		if a.InstanceID != nil {
+			att.InstanceId = a.InstanceID.ID
+		}
+		vol.Attachments = append(vol.Attachments, att)
+	}
+	return vol
+}
@@ -0,0 +1,626 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/akutz/goof"
+	apitypes "github.com/emccode/libstorage/api/types"
+	apiutils "github.com/emccode/libstorage/api/utils"
+	"github.com/gorilla/mux"
+
+	"github.com/emccode/rexray/core/capacity"
+	"github.com/emccode/rexray/core/capture"
+	"github.com/emccode/rexray/core/errcodes"
+	"github.com/emccode/rexray/core/events"
+	"github.com/emccode/rexray/core/groups"
+	"github.com/emccode/rexray/core/iostats"
+	"github.com/emccode/rexray/core/metrics"
+	"github.com/emccode/rexray/core/nodes"
+	"github.com/emccode/rexray/core/openapi"
+	"github.com/emccode/rexray/core/overrides"
+	"github.com/emccode/rexray/core/probes"
+	"github.com/emccode/rexray/core/state"
+	"github.com/emccode/rexray/core/tasks"
+	"github.com/emccode/rexray/core/trash"
+	"github.com/emccode/rexray/daemon/module"
+)
+
+// The functions in this file are shared by the HTTP routes and the gRPC
+// server so that both expose the same management surface.
+
+func (m *mod) listVolumes(attachments bool) ([]*apitypes.Volume, error) {
+	return m.lsc.Storage().Volumes(
+		m.ctx, &apitypes.VolumesOpts{Attachments: attachments})
+}
+
+func (m *mod) inspectVolume(
+	id string, attachments bool) (*apitypes.Volume, error) {
+	return m.lsc.Storage().VolumeInspect(
+		m.ctx, id, &apitypes.VolumeInspectOpts{Attachments: attachments})
+}
+
+// listAttachments returns the volumes attached to, or mounted on, each node,
+// or only on the node with the provided instance ID or hostname.
+func (m *mod) listAttachments(node string) ([]*nodes.NodeAttachments, error) {
+	vols, err := m.listVolumes(true)
+	if err != nil {
+		return nil, err
+	}
+	all, err := nodes.List(state.Controller())
+	if err != nil {
+		return nil, err
+	}
+	nas := nodes.Attachments(vols, all)
+	if node == "" {
+		return nas, nil
+	}
+	matched := []*nodes.NodeAttachments{}
+	for _, na := range nas {
+		if na.Matches(node) {
+			matched = append(matched, na)
+		}
+	}
+	return matched, nil
+}
+
+func (m *mod) listSnapshots() ([]*apitypes.Snapshot, error) {
+	return m.lsc.Storage().Snapshots(m.ctx, apiutils.NewStore())
+}
+
+func (m *mod) listServices() (map[string]*apitypes.ServiceInfo, error) {
+	return m.lsc.API().Services(m.ctx)
+}
+
+func (m *mod) serviceCapacity(name string) ([]*capacity.Capacity, error) {
+	return capacity.Get(m.ctx, m.config, m.lsc, name)
+}
+
+func (m *mod) listTasks() ([]*tasks.Task, error) {
+	return tasks.List(m.store)
+}
+
+func (m *mod) inspectTask(id int64) (*tasks.Task, error) {
+	return tasks.Get(m.store, id)
+}
+
+func (m *mod) submitTask(
+	operation string, params map[string]string) (*tasks.Task, error) {
+	return m.tasks.Submit(operation, params)
+}
+
+func (m *mod) createVolume(
+	name string, opts *apitypes.VolumeCreateOpts) (*apitypes.Volume, error) {
+	if name == "" {
+		return nil, errcodes.New(
+			errcodes.InvalidRequest, goof.New("missing volume name"))
+	}
+	return m.lsc.Storage().VolumeCreate(m.ctx, name, opts)
+}
+
+// removeVolume removes a volume, or if purge is set, removes it without
+// moving it to the trash.
+func (m *mod) removeVolume(id string, purge bool) error {
+	opts := apiutils.NewStore()
+	if purge {
+		opts.Set(trash.PurgeKey, true)
+	}
+	return m.lsc.Storage().VolumeRemove(m.ctx, id, opts)
+}
+
+func (m *mod) createSnapshot(
+	volumeID, name string, opts apitypes.Store) (*apitypes.Snapshot, error) {
+	if volumeID == "" {
+		return nil, errcodes.New(
+			errcodes.InvalidRequest, goof.New("missing volume ID"))
+	}
+	return m.lsc.Storage().VolumeSnapshot(m.ctx, volumeID, name, opts)
+}
+
+func (m *mod) removeSnapshot(id string) error {
+	return m.lsc.Storage().SnapshotRemove(m.ctx, id, apiutils.NewStore())
+}
+
+// startModule starts the module instance with the provided name if it is not
+// already running.
+func (m *mod) startModule(name string) (*module.Instance, error) {
+	mi, err := module.GetModuleInstance(name)
+	if err != nil {
+		return nil, errcodes.New(errcodes.NotFound, err)
+	}
+	if !mi.IsStarted {
+		if err := module.StartModule(m.ctx, m.config, name); err != nil {
+			return nil, err
+		}
+	}
+	return mi, nil
+}
+
+// optsStore returns a store of the driver options of a request.
+func optsStore(opts map[string]string) apitypes.Store {
+	store := apiutils.NewStore()
+	for k, v := range opts {
+		store.Set(k, v)
+	}
+	return store
+}
+
+func (m *mod) listEvents() ([]*events.Event, error) {
+	return events.List(m.store)
+}
+
+func listModules() []*module.Instance {
+	var mods []*module.Instance
+	for m := range module.Instances() {
+		mods = append(mods, m)
+	}
+	return mods
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}, err error) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+	if err != nil {
+		status := errcodes.Of(err).Status()
+		if se, ok := err.(interface {
+			Status() int
+		}); ok {
+			status = se.Status()
+		}
+		w.WriteHeader(status)
+		w.Write(getJSONError("Error servicing request", err))
+		log.Printf("Error servicing request ERR: %v", err)
+		return
+	}
+
+	jsonBuf, jsonBufErr := json.MarshalIndent(v, "", "  ")
+	if jsonBufErr != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		log.Printf("Error servicing request ERR: %v", jsonBufErr)
+		return
+	}
+
+	if _, writeErr := w.Write(jsonBuf); writeErr != nil {
+		log.Printf("Error writing json buffer ERR: %v", writeErr)
+	}
+}
+
+func attachmentsParam(req *http.Request) bool {
+	return req.FormValue("attachments") == "true"
+}
+
+// page returns the bounds of the page of a list of n items that is requested
+// with the offset and limit parameters, and sets the X-Total-Count header to
+// n so that clients know when they have read every page. The items from the
+// offset to the end of the list are returned if there is no limit.
+func page(w http.ResponseWriter, req *http.Request, n int) (int, int, error) {
+	param := func(name string) (int, error) {
+		v := req.FormValue(name)
+		if v == "" {
+			return 0, nil
+		}
+		i, err := strconv.Atoi(v)
+		if err != nil || i < 0 {
+			return 0, errcodes.New(errcodes.InvalidRequest,
+				goof.WithField(name, v, "invalid "+name))
+		}
+		return i, nil
+	}
+	offset, err := param("offset")
+	if err != nil {
+		return 0, 0, err
+	}
+	limit, err := param("limit")
+	if err != nil {
+		return 0, 0, err
+	}
+
+	w.Header().Set("X-Total-Count", strconv.Itoa(n))
+	if offset > n {
+		offset = n
+	}
+	end := n
+	if limit > 0 && limit < n-offset {
+		end = offset + limit
+	}
+	return offset, end, nil
+}
+
+// optsParam returns the driver options of a request, which are passed as
+// opt parameters of the form key=value.
+func optsParam(req *http.Request) (map[string]string, error) {
+	opts := map[string]string{}
+	for _, o := range req.Form["opt"] {
+		kv := strings.SplitN(o, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, errcodes.New(errcodes.InvalidRequest,
+				goof.WithField("opt", o, "invalid option"))
+		}
+		opts[kv[0]] = kv[1]
+	}
+	return opts, nil
+}
+
+// int64Param returns the value of an integer parameter, or 0 if the
+// parameter is absent.
+func int64Param(req *http.Request, name string) (int64, error) {
+	v := req.FormValue(name)
+	if v == "" {
+		return 0, nil
+	}
+	i, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || i < 0 {
+		return 0, errcodes.New(errcodes.InvalidRequest,
+			goof.WithField(name, v, "invalid "+name))
+	}
+	return i, nil
+}
+
+func (m *mod) volumesHandler(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case "GET":
+	case "POST":
+		m.volumeCreateHandler(w, req)
+		return
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	vols, err := m.listVolumes(attachmentsParam(req))
+	if err == nil {
+		sort.Sort(volumesByID(vols))
+		var i, j int
+		if i, j, err = page(w, req, len(vols)); err == nil {
+			vols = vols[i:j]
+		}
+	}
+	writeJSON(w, vols, err)
+}
+
+type volumesByID []*apitypes.Volume
+
+func (v volumesByID) Len() int           { return len(v) }
+func (v volumesByID) Swap(i, j int)      { v[i], v[j] = v[j], v[i] }
+func (v volumesByID) Less(i, j int) bool { return v[i].ID < v[j].ID }
+
+func (m *mod) volumeCreateHandler(w http.ResponseWriter, req *http.Request) {
+	if err := req.ParseForm(); err != nil {
+		writeJSON(w, nil, errcodes.New(errcodes.InvalidRequest, err))
+		return
+	}
+	opts, err := optsParam(req)
+	if err != nil {
+		writeJSON(w, nil, err)
+		return
+	}
+	createOpts := &apitypes.VolumeCreateOpts{Opts: optsStore(opts)}
+	if size, err := int64Param(req, "size"); err != nil {
+		writeJSON(w, nil, err)
+		return
+	} else if size > 0 {
+		createOpts.Size = &size
+	}
+	if iops, err := int64Param(req, "iops"); err != nil {
+		writeJSON(w, nil, err)
+		return
+	} else if iops > 0 {
+		createOpts.IOPS = &iops
+	}
+	if t := req.FormValue("type"); t != "" {
+		createOpts.Type = &t
+	}
+	if az := req.FormValue("availabilityZone"); az != "" {
+		createOpts.AvailabilityZone = &az
+	}
+	vol, err := m.createVolume(req.FormValue("name"), createOpts)
+	writeJSON(w, vol, err)
+}
+
+func (m *mod) volumeHandler(w http.ResponseWriter, req *http.Request) {
+	id := mux.Vars(req)["id"]
+	switch req.Method {
+	case "GET":
+		vol, err := m.inspectVolume(id, attachmentsParam(req))
+		writeJSON(w, vol, err)
+	case "DELETE":
+		writeJSON(w, nil, m.removeVolume(id, req.FormValue("purge") == "true"))
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func (m *mod) attachmentsHandler(w http.ResponseWriter, req *http.Request) {
+	nas, err := m.listAttachments(req.FormValue("node"))
+	writeJSON(w, nas, err)
+}
+
+func (m *mod) volumeOverridesHandler(
+	w http.ResponseWriter, req *http.Request) {
+
+	id := mux.Vars(req)["id"]
+	switch req.Method {
+	case "GET":
+		o, err := overrides.Get(m.store, id)
+		writeJSON(w, o, err)
+	case "PUT", "POST":
+		if err := req.ParseForm(); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write(getJSONError(
+				"Error parsing form",
+				errcodes.New(errcodes.InvalidRequest, err)))
+			return
+		}
+		set, err := overrides.Parse(req.Form["override"])
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write(getJSONError(
+				"Invalid override",
+				errcodes.New(errcodes.InvalidRequest, err)))
+			return
+		}
+		o, err := overrides.Set(m.store, id, set)
+		writeJSON(w, o, err)
+	case "DELETE":
+		o, err := overrides.Clear(m.store, id, req.URL.Query()["key"]...)
+		writeJSON(w, o, err)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func (m *mod) groupsHandler(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case "GET":
+		all, err := groups.List(m.store)
+		writeJSON(w, all, err)
+	case "POST":
+		if err := req.ParseForm(); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write(getJSONError(
+				"Error parsing form",
+				errcodes.New(errcodes.InvalidRequest, err)))
+			return
+		}
+		g, err := groups.Set(
+			m.store, req.FormValue("name"), req.Form["volume"])
+		writeJSON(w, g, err)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func (m *mod) groupHandler(w http.ResponseWriter, req *http.Request) {
+	name := mux.Vars(req)["name"]
+	switch req.Method {
+	case "GET":
+		g, err := groups.Get(m.store, name)
+		writeJSON(w, g, err)
+	case "DELETE":
+		writeJSON(w, nil, groups.Remove(m.store, name))
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// groupOpHandler attaches, mounts, or unmounts a group's volumes. A failed
+// operation is reported with the result of each volume's operation.
+func (m *mod) groupOpHandler(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "POST" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	vars := mux.Vars(req)
+	g, err := groups.Get(m.store, vars["name"])
+	if err != nil {
+		writeJSON(w, nil, err)
+		return
+	}
+
+	var results []*groups.Result
+	switch vars["op"] {
+	case "attach":
+		results, err = groups.Attach(m.ctx, m.lsc, g)
+	case "mount":
+		results, err = groups.Mount(m.ctx, m.lsc, g)
+	case "unmount":
+		results, err = groups.Unmount(m.ctx, m.lsc, g)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(getJSONError("Invalid group operation", nil))
+		return
+	}
+	writeJSON(w, results, err)
+}
+
+func (m *mod) snapshotsHandler(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case "GET":
+	case "POST":
+		m.snapshotCreateHandler(w, req)
+		return
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	snaps, err := m.listSnapshots()
+	if err == nil {
+		sort.Sort(snapshotsByID(snaps))
+		var i, j int
+		if i, j, err = page(w, req, len(snaps)); err == nil {
+			snaps = snaps[i:j]
+		}
+	}
+	writeJSON(w, snaps, err)
+}
+
+func (m *mod) snapshotCreateHandler(
+	w http.ResponseWriter, req *http.Request) {
+
+	if err := req.ParseForm(); err != nil {
+		writeJSON(w, nil, errcodes.New(errcodes.InvalidRequest, err))
+		return
+	}
+	opts, err := optsParam(req)
+	if err != nil {
+		writeJSON(w, nil, err)
+		return
+	}
+	snap, err := m.createSnapshot(
+		req.FormValue("volumeID"), req.FormValue("name"), optsStore(opts))
+	writeJSON(w, snap, err)
+}
+
+func (m *mod) snapshotHandler(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "DELETE" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, nil, m.removeSnapshot(mux.Vars(req)["id"]))
+}
+
+type snapshotsByID []*apitypes.Snapshot
+
+func (s snapshotsByID) Len() int           { return len(s) }
+func (s snapshotsByID) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s snapshotsByID) Less(i, j int) bool { return s[i].ID < s[j].ID }
+
+func (m *mod) servicesHandler(w http.ResponseWriter, req *http.Request) {
+	svcs, err := m.listServices()
+	writeJSON(w, svcs, err)
+}
+
+func (m *mod) serviceCapacityHandler(
+	w http.ResponseWriter, req *http.Request) {
+
+	c, err := m.serviceCapacity(mux.Vars(req)["name"])
+	writeJSON(w, c, err)
+}
+
+func (m *mod) tasksHandler(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case "GET":
+		all, err := m.listTasks()
+		if err == nil {
+			var i, j int
+			if i, j, err = page(w, req, len(all)); err == nil {
+				all = all[i:j]
+			}
+		}
+		writeJSON(w, all, err)
+	case "POST":
+		m.taskSubmitHandler(w, req)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func (m *mod) taskSubmitHandler(w http.ResponseWriter, req *http.Request) {
+	params := map[string]string{}
+	if p := req.FormValue("params"); p != "" {
+		if err := json.Unmarshal([]byte(p), &params); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write(getJSONError(
+				"Error unmarshalling params json",
+				errcodes.New(errcodes.InvalidRequest, err)))
+			return
+		}
+	}
+	t, err := m.submitTask(req.FormValue("operation"), params)
+	writeJSON(w, t, err)
+}
+
+func (m *mod) taskHandler(w http.ResponseWriter, req *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(req)["id"], 10, 64)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(getJSONError(
+			"Invalid task ID", errcodes.New(errcodes.InvalidRequest, err)))
+		return
+	}
+	t, err := m.inspectTask(id)
+	writeJSON(w, t, err)
+}
+
+func (m *mod) eventsHandler(w http.ResponseWriter, req *http.Request) {
+	all, err := m.listEvents()
+	if err == nil {
+		var i, j int
+		if i, j, err = page(w, req, len(all)); err == nil {
+			all = all[i:j]
+		}
+	}
+	writeJSON(w, all, err)
+}
+
+// ioStatsHandler responds with the I/O statistics of the volumes mounted on
+// this node as of the agent's last sample.
+func (m *mod) ioStatsHandler(w http.ResponseWriter, req *http.Request) {
+	writeJSON(w, iostats.Default().Stats(), nil)
+}
+
+func (m *mod) captureHandler(w http.ResponseWriter, req *http.Request) {
+	b := capture.Default()
+	switch req.Method {
+	case "GET":
+		writeJSON(w, b.List(req.FormValue("service")), nil)
+	case "DELETE":
+		b.Clear()
+		writeJSON(w, []*capture.Exchange{}, nil)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func (m *mod) metricsHandler(w http.ResponseWriter, req *http.Request) {
+	r := metrics.Default()
+
+	// the heartbeats are read from the state when scraped so that nodes
+	// that were removed are no longer reported
+	all, err := nodes.List(state.Controller())
+	if err != nil {
+		writeJSON(w, nil, err)
+		return
+	}
+	r.Reset(metrics.NodeHeartbeat)
+	for _, n := range all {
+		r.Set(metrics.NodeHeartbeat,
+			float64(n.Heartbeat.Unix()), n.InstanceID, n.Hostname)
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	if err := r.Write(w); err != nil {
+		log.Printf("Error writing metrics ERR: %v", err)
+	}
+}
+
+// openAPIHandler serves the OpenAPI document of the libStorage API at the
+// host to which the service's libStorage client connects.
+func (m *mod) openAPIHandler(w http.ResponseWriter, req *http.Request) {
+	tls := m.config.IsSet("libstorage.tls") &&
+		!strings.EqualFold(m.config.GetString("libstorage.tls"), "false")
+	openapi.Handler(openapi.ServerURL(
+		m.config.GetString(apitypes.ConfigHost), tls)).ServeHTTP(w, req)
+}
+
+func (m *mod) liveHandler(w http.ResponseWriter, req *http.Request) {
+	writeJSON(w, probes.Live(), nil)
+}
+
+// readyHandler responds with 503 if the service is not ready so that the
+// probe may be used by orchestrators and load balancers without parsing the
+// result.
+func (m *mod) readyHandler(w http.ResponseWriter, req *http.Request) {
+	r := probes.Ready(m.ctx, m.config, m.lsc)
+	if !r.Ready {
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(r)
+		return
+	}
+	writeJSON(w, r, nil)
+}
@@ -1,14 +1,15 @@
 package admin
 
 import (
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	golog "log"
-	"net"
 	"net/http"
 	"os"
 	"strconv"
+	"sync"
 	"time"
 
 	log "github.com/Sirupsen/logrus"
@@ -18,12 +19,30 @@ import (
 	apitypes "github.com/emccode/libstorage/api/types"
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
-
+	"google.golang.org/grpc"
+
+	"github.com/emccode/rexray/core/acme"
+	"github.com/emccode/rexray/core/errcodes"
+	"github.com/emccode/rexray/core/openapi"
+	"github.com/emccode/rexray/core/schedule"
+	"github.com/emccode/rexray/core/state"
+	"github.com/emccode/rexray/core/systemd"
+	"github.com/emccode/rexray/core/tasks"
+	"github.com/emccode/rexray/core/tracing"
+	"github.com/emccode/rexray/core/trash"
+	"github.com/emccode/rexray/core/usage"
+	"github.com/emccode/rexray/core/volsync"
 	"github.com/emccode/rexray/daemon/module"
+	"github.com/emccode/rexray/util"
 )
 
 const (
 	modName = "admin"
+
+	purgeTrashJob = "admin.purgeTrash"
+	volumeSyncJob = "admin.volumeSync"
+	usageJob      = "admin.sampleUsage"
+	renewCertJob  = "admin.renewCertificate"
 )
 
 type mod struct {
@@ -32,11 +51,22 @@ type mod struct {
 	desc   string
 	ctx    apitypes.Context
 	config gofig.Config
+	lsc    apitypes.Client
+	store  *state.Store
+	tasks  *tasks.Manager
+	sched  *schedule.Scheduler
+
+	// serversLock guards the module's servers, which are nil when the
+	// module is stopped.
+	serversLock sync.Mutex
+	http        *http.Server
+	grpc        *grpc.Server
 }
 
 type jsonError struct {
-	Message string `json:"message"`
-	Error   error  `json:"error"`
+	Message string        `json:"message"`
+	Code    errcodes.Code `json:"code"`
+	Error   error         `json:"error"`
 }
 
 func init() {
@@ -50,6 +80,10 @@ func newModule(ctx apitypes.Context, c *module.Config) (module.Module, error) {
 		addr:   c.Address,
 		ctx:    ctx,
 		config: c.Config,
+		lsc:    c.Client,
+		store:  state.Default(),
+		tasks:  tasks.NewManager(ctx, c.Config, c.Client, state.Default()),
+		sched:  schedule.Default(ctx),
 	}, nil
 }
 
@@ -133,23 +167,7 @@ func (m *mod) moduleTypeHandler(w http.ResponseWriter, req *http.Request) {
 }
 
 func (m *mod) moduleInstGetHandler(w http.ResponseWriter, req *http.Request) {
-	var mods []*module.Instance
-	for m := range module.Instances() {
-		mods = append(mods, m)
-	}
-
-	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
-	jsonBuf, jsonBufErr := json.MarshalIndent(mods, "", "  ")
-	if jsonBufErr != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		log.Printf("Error servicing request ERR: %v", jsonBufErr)
-		return
-	}
-
-	_, writeErr := w.Write(jsonBuf)
-	if writeErr != nil {
-		log.Printf("Error writing json buffer ERR: %v", writeErr)
-	}
+	writeJSON(w, listModules(), nil)
 }
 
 func (m *mod) moduleInstPostHandler(w http.ResponseWriter, req *http.Request) {
@@ -290,10 +308,18 @@ func (m *mod) moduleInstStartHandler(w http.ResponseWriter, req *http.Request) {
 	w.Write(jsonBuf)
 }
 
+// getJSONError returns the JSON of an error response. The code of a response
+// without an error is InvalidRequest, since such responses reject requests
+// that are missing arguments.
 func getJSONError(msg string, err error) []byte {
+	code := errcodes.InvalidRequest
+	if err != nil {
+		code = errcodes.Of(err)
+	}
 	buf, marshalErr := json.MarshalIndent(
 		&jsonError{
 			Message: msg,
+			Code:    code,
 			Error:   err,
 		}, "", "  ")
 	if marshalErr != nil {
@@ -314,6 +340,57 @@ func (m *mod) Start() error {
 		handlers.LoggingHandler(stdOut, http.HandlerFunc(m.moduleInstStartHandler)))
 	r.Handle("/r/module/types",
 		handlers.LoggingHandler(stdOut, http.HandlerFunc(m.moduleTypeHandler)))
+	r.Handle("/r/volumes",
+		handlers.LoggingHandler(stdOut, http.HandlerFunc(m.volumesHandler)))
+	r.Handle("/r/volumes/{id}",
+		handlers.LoggingHandler(stdOut, http.HandlerFunc(m.volumeHandler)))
+	r.Handle("/r/volumes/{id}/overrides",
+		handlers.LoggingHandler(
+			stdOut, http.HandlerFunc(m.volumeOverridesHandler)))
+	r.Handle("/r/attachments",
+		handlers.LoggingHandler(
+			stdOut, http.HandlerFunc(m.attachmentsHandler)))
+	r.Handle("/r/groups",
+		handlers.LoggingHandler(stdOut, http.HandlerFunc(m.groupsHandler)))
+	r.Handle("/r/groups/{name}",
+		handlers.LoggingHandler(stdOut, http.HandlerFunc(m.groupHandler)))
+	r.Handle("/r/groups/{name}/{op}",
+		handlers.LoggingHandler(stdOut, http.HandlerFunc(m.groupOpHandler)))
+	r.Handle("/r/snapshots",
+		handlers.LoggingHandler(stdOut, http.HandlerFunc(m.snapshotsHandler)))
+	r.Handle("/r/snapshots/{id}",
+		handlers.LoggingHandler(stdOut, http.HandlerFunc(m.snapshotHandler)))
+	r.Handle("/r/services",
+		handlers.LoggingHandler(stdOut, http.HandlerFunc(m.servicesHandler)))
+	r.Handle("/services/{name}/capacity",
+		handlers.LoggingHandler(
+			stdOut, http.HandlerFunc(m.serviceCapacityHandler)))
+	r.Handle("/r/tasks",
+		handlers.LoggingHandler(stdOut, http.HandlerFunc(m.tasksHandler)))
+	r.Handle("/r/tasks/{id}",
+		handlers.LoggingHandler(stdOut, http.HandlerFunc(m.taskHandler)))
+	r.Handle("/r/events",
+		handlers.LoggingHandler(stdOut, http.HandlerFunc(m.eventsHandler)))
+	r.Handle("/events",
+		handlers.LoggingHandler(
+			stdOut, http.HandlerFunc(m.eventStreamHandler)))
+	r.Handle("/r/iostats",
+		handlers.LoggingHandler(stdOut, http.HandlerFunc(m.ioStatsHandler)))
+	r.Handle("/r/debug/capture",
+		handlers.LoggingHandler(stdOut, http.HandlerFunc(m.captureHandler)))
+
+	r.Handle(openapi.Path,
+		handlers.LoggingHandler(stdOut, http.HandlerFunc(m.openAPIHandler)))
+
+	r.Handle("/health/live",
+		handlers.LoggingHandler(stdOut, http.HandlerFunc(m.liveHandler)))
+	r.Handle("/health/ready",
+		handlers.LoggingHandler(stdOut, http.HandlerFunc(m.readyHandler)))
+
+	if m.config.GetBool("rexray.metrics.enabled") {
+		r.Handle("/metrics",
+			handlers.LoggingHandler(stdOut, http.HandlerFunc(m.metricsHandler)))
+	}
 
 	r.Handle("/images/rexray-banner-logo.svg",
 		handlers.LoggingHandler(stdOut, http.HandlerFunc(m.imagesHandler)))
@@ -330,34 +407,164 @@ func (m *mod) Start() error {
 		return err
 	}
 
-	l, err := net.Listen(proto, laddr)
+	l, _, err := systemd.Listen(proto, laddr)
 	if err != nil {
 		return err
 	}
+	if acme.Enabled(m.config) {
+		certs, err := m.startACME()
+		if err != nil {
+			l.Close()
+			return err
+		}
+		l = tls.NewListener(l, certs.TLSConfig())
+	}
 
 	s := &http.Server{
-		Handler:        r,
+		Handler:        tracing.Handler(r, "admin"),
 		ReadTimeout:    10 * time.Second,
 		WriteTimeout:   10 * time.Second,
 		MaxHeaderBytes: 1 << 20,
 		ErrorLog:       golog.New(stdErr, "", 0),
 	}
 
+	m.serversLock.Lock()
+	m.http = s
+	m.serversLock.Unlock()
+
 	go func() {
 		defer stdOut.Close()
 		defer stdErr.Close()
-		if err := s.Serve(l); err != nil {
-			panic(err)
+		if err := s.Serve(l); err != nil && m.serving(s) {
+			m.fail(err, "admin HTTP server failed")
 		}
 	}()
 
+	if err := m.tasks.Resume(); err != nil {
+		return err
+	}
+
+	if grpcAddr := m.config.GetString("grpc"); grpcAddr != "" {
+		if err := m.startGRPC(grpcAddr); err != nil {
+			return err
+		}
+	}
+
+	if trash.Enabled(m.config) {
+		sched, err := schedule.Parse(
+			m.config.GetString("rexray.volume.trash.purgeSchedule"))
+		if err != nil {
+			return err
+		}
+		if err := m.sched.Add(&schedule.Job{
+			Name:     purgeTrashJob,
+			Schedule: sched,
+			Persist:  true,
+			Run: func() {
+				trash.Purge(m.ctx, m.lsc, m.store)
+			},
+		}); err != nil {
+			return err
+		}
+	}
+
+	// the recorded volume syncs are checked every minute so that syncs
+	// created with the CLI are run without restarting the service
+	if err := m.sched.Add(&schedule.Job{
+		Name:     volumeSyncJob,
+		Schedule: schedule.Every(time.Minute),
+		Run: func() {
+			volsync.RunDue(m.ctx, m.config, m.lsc, m.store)
+		},
+	}); err != nil {
+		return err
+	}
+
+	if usage.Enabled(m.config) {
+		if err := m.sched.Add(&schedule.Job{
+			Name:     usageJob,
+			Schedule: schedule.Every(usage.Interval(m.config)),
+			Run: func() {
+				if _, err := usage.Record(
+					m.ctx, m.config, m.lsc, m.store); err != nil {
+					m.ctx.WithError(err).Warn("error sampling usage")
+				}
+			},
+		}); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
 func (m *mod) Stop() error {
+	m.sched.Remove(purgeTrashJob)
+	m.sched.Remove(volumeSyncJob)
+	m.sched.Remove(usageJob)
+	m.sched.Remove(renewCertJob)
+
+	m.serversLock.Lock()
+	defer m.serversLock.Unlock()
+	if m.grpc != nil {
+		m.grpc.Stop()
+		m.grpc = nil
+	}
+	if m.http != nil {
+		err := m.http.Close()
+		m.http = nil
+		return err
+	}
 	return nil
 }
 
+// serving returns a flag indicating whether the provided server is one of
+// the module's running servers, which is false once the module is stopped.
+func (m *mod) serving(s interface{}) bool {
+	m.serversLock.Lock()
+	defer m.serversLock.Unlock()
+	return (m.http != nil && s == m.http) || (m.grpc != nil && s == m.grpc)
+}
+
+// fail logs the error that stopped one of the module's servers and stops
+// the module, so that the module is reported as stopped and may be started
+// again.
+func (m *mod) fail(err error, msg string) {
+	m.ctx.WithError(err).Error(msg)
+	if err := module.StopModule(m.ctx, m.name); err != nil {
+		m.ctx.WithError(err).Error("error stopping admin module")
+	}
+}
+
+// startACME obtains the admin API's certificate if there is none or it is
+// due for renewal, and schedules its renewal. The service starts with the
+// previous certificate if it has not expired and a new one cannot be
+// obtained.
+func (m *mod) startACME() (*acme.Manager, error) {
+	certs, err := acme.New(m.ctx, m.config, util.LibFilePath("acme"))
+	if err != nil {
+		return nil, err
+	}
+	if err := certs.Renew(); err != nil {
+		if !certs.Ready() {
+			return nil, err
+		}
+		m.ctx.WithError(err).Warn("error renewing acme certificate")
+	}
+	if err := m.sched.Add(&schedule.Job{
+		Name:     renewCertJob,
+		Schedule: schedule.Every(12 * time.Hour),
+		Run: func() {
+			if err := certs.Renew(); err != nil {
+				m.ctx.WithError(err).Error("error renewing acme certificate")
+			}
+		},
+	}); err != nil {
+		return nil, err
+	}
+	return certs, nil
+}
+
 func (m *mod) Name() string {
 	return m.name
 }
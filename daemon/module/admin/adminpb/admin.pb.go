@@ -0,0 +1,865 @@
+// This file was written by hand to match the output of protoc-gen-go for
+// admin.proto, since protoc was not available when the API was added. It
+// must be regenerated, and this comment with it, by running
+//
+//     go generate ./daemon/module/admin
+//
+// with protoc and protoc-gen-go installed. Do not edit it otherwise.
+
+/*
+Package adminpb is a generated protocol buffer package.
+
+It is generated from these files:
+
+	admin.proto
+
+It has these top-level messages:
+
+	Attachment
+	Volume
+	Snapshot
+	Service
+	Task
+	Module
+	ListVolumesRequest
+	ListVolumesResponse
+	InspectVolumeRequest
+	ListSnapshotsRequest
+	ListSnapshotsResponse
+	ListServicesRequest
+	ListServicesResponse
+	ListTasksRequest
+	ListTasksResponse
+	ListModulesRequest
+	ListModulesResponse
+	CreateVolumeRequest
+	RemoveVolumeRequest
+	RemoveVolumeResponse
+	CreateSnapshotRequest
+	RemoveSnapshotRequest
+	RemoveSnapshotResponse
+	SubmitTaskRequest
+	InspectTaskRequest
+	StartModuleRequest
+*/
+package adminpb
+
+import proto "github.com/golang/protobuf/proto"
+import fmt "fmt"
+import math "math"
+
+import (
+	context "golang.org/x/net/context"
+	grpc "google.golang.org/grpc"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
+
+type Attachment struct {
+	InstanceId string `protobuf:"bytes,1,opt,name=instance_id" json:"instance_id,omitempty"`
+	DeviceName string `protobuf:"bytes,2,opt,name=device_name" json:"device_name,omitempty"`
+	MountPoint string `protobuf:"bytes,3,opt,name=mount_point" json:"mount_point,omitempty"`
+	Status     string `protobuf:"bytes,4,opt,name=status" json:"status,omitempty"`
+}
+
+func (m *Attachment) Reset()         { *m = Attachment{} }
+func (m *Attachment) String() string { return proto.CompactTextString(m) }
+func (*Attachment) ProtoMessage()    {}
+
+type Volume struct {
+	Id               string        `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+	Name             string        `protobuf:"bytes,2,opt,name=name" json:"name,omitempty"`
+	Size             int64         `protobuf:"varint,3,opt,name=size" json:"size,omitempty"`
+	Type             string        `protobuf:"bytes,4,opt,name=type" json:"type,omitempty"`
+	Iops             int64         `protobuf:"varint,5,opt,name=iops" json:"iops,omitempty"`
+	AvailabilityZone string        `protobuf:"bytes,6,opt,name=availability_zone" json:"availability_zone,omitempty"`
+	Status           string        `protobuf:"bytes,7,opt,name=status" json:"status,omitempty"`
+	Attachments      []*Attachment `protobuf:"bytes,8,rep,name=attachments" json:"attachments,omitempty"`
+}
+
+func (m *Volume) Reset()         { *m = Volume{} }
+func (m *Volume) String() string { return proto.CompactTextString(m) }
+func (*Volume) ProtoMessage()    {}
+
+func (m *Volume) GetAttachments() []*Attachment {
+	if m != nil {
+		return m.Attachments
+	}
+	return nil
+}
+
+type Snapshot struct {
+	Id          string `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+	Name        string `protobuf:"bytes,2,opt,name=name" json:"name,omitempty"`
+	VolumeId    string `protobuf:"bytes,3,opt,name=volume_id" json:"volume_id,omitempty"`
+	VolumeSize  int64  `protobuf:"varint,4,opt,name=volume_size" json:"volume_size,omitempty"`
+	StartTime   int64  `protobuf:"varint,5,opt,name=start_time" json:"start_time,omitempty"`
+	Description string `protobuf:"bytes,6,opt,name=description" json:"description,omitempty"`
+	Status      string `protobuf:"bytes,7,opt,name=status" json:"status,omitempty"`
+}
+
+func (m *Snapshot) Reset()         { *m = Snapshot{} }
+func (m *Snapshot) String() string { return proto.CompactTextString(m) }
+func (*Snapshot) ProtoMessage()    {}
+
+type Service struct {
+	Name   string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Driver string `protobuf:"bytes,2,opt,name=driver" json:"driver,omitempty"`
+}
+
+func (m *Service) Reset()         { *m = Service{} }
+func (m *Service) String() string { return proto.CompactTextString(m) }
+func (*Service) ProtoMessage()    {}
+
+type Task struct {
+	Id           int64  `protobuf:"varint,1,opt,name=id" json:"id,omitempty"`
+	State        string `protobuf:"bytes,3,opt,name=state" json:"state,omitempty"`
+	Error        string `protobuf:"bytes,4,opt,name=error" json:"error,omitempty"`
+	QueueTime    int64  `protobuf:"varint,5,opt,name=queue_time" json:"queue_time,omitempty"`
+	StartTime    int64  `protobuf:"varint,6,opt,name=start_time" json:"start_time,omitempty"`
+	CompleteTime int64  `protobuf:"varint,7,opt,name=complete_time" json:"complete_time,omitempty"`
+	Operation    string `protobuf:"bytes,8,opt,name=operation" json:"operation,omitempty"`
+	Progress     int32  `protobuf:"varint,9,opt,name=progress" json:"progress,omitempty"`
+}
+
+func (m *Task) Reset()         { *m = Task{} }
+func (m *Task) String() string { return proto.CompactTextString(m) }
+func (*Task) ProtoMessage()    {}
+
+type Module struct {
+	Name        string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Type        string `protobuf:"bytes,2,opt,name=type" json:"type,omitempty"`
+	Description string `protobuf:"bytes,3,opt,name=description" json:"description,omitempty"`
+	Address     string `protobuf:"bytes,4,opt,name=address" json:"address,omitempty"`
+	Started     bool   `protobuf:"varint,5,opt,name=started" json:"started,omitempty"`
+}
+
+func (m *Module) Reset()         { *m = Module{} }
+func (m *Module) String() string { return proto.CompactTextString(m) }
+func (*Module) ProtoMessage()    {}
+
+type ListVolumesRequest struct {
+	Attachments bool `protobuf:"varint,1,opt,name=attachments" json:"attachments,omitempty"`
+}
+
+func (m *ListVolumesRequest) Reset()         { *m = ListVolumesRequest{} }
+func (m *ListVolumesRequest) String() string { return proto.CompactTextString(m) }
+func (*ListVolumesRequest) ProtoMessage()    {}
+
+type ListVolumesResponse struct {
+	Volumes []*Volume `protobuf:"bytes,1,rep,name=volumes" json:"volumes,omitempty"`
+}
+
+func (m *ListVolumesResponse) Reset()         { *m = ListVolumesResponse{} }
+func (m *ListVolumesResponse) String() string { return proto.CompactTextString(m) }
+func (*ListVolumesResponse) ProtoMessage()    {}
+
+func (m *ListVolumesResponse) GetVolumes() []*Volume {
+	if m != nil {
+		return m.Volumes
+	}
+	return nil
+}
+
+type InspectVolumeRequest struct {
+	Id          string `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+	Attachments bool   `protobuf:"varint,2,opt,name=attachments" json:"attachments,omitempty"`
+}
+
+func (m *InspectVolumeRequest) Reset()         { *m = InspectVolumeRequest{} }
+func (m *InspectVolumeRequest) String() string { return proto.CompactTextString(m) }
+func (*InspectVolumeRequest) ProtoMessage()    {}
+
+type ListSnapshotsRequest struct {
+}
+
+func (m *ListSnapshotsRequest) Reset()         { *m = ListSnapshotsRequest{} }
+func (m *ListSnapshotsRequest) String() string { return proto.CompactTextString(m) }
+func (*ListSnapshotsRequest) ProtoMessage()    {}
+
+type ListSnapshotsResponse struct {
+	Snapshots []*Snapshot `protobuf:"bytes,1,rep,name=snapshots" json:"snapshots,omitempty"`
+}
+
+func (m *ListSnapshotsResponse) Reset()         { *m = ListSnapshotsResponse{} }
+func (m *ListSnapshotsResponse) String() string { return proto.CompactTextString(m) }
+func (*ListSnapshotsResponse) ProtoMessage()    {}
+
+func (m *ListSnapshotsResponse) GetSnapshots() []*Snapshot {
+	if m != nil {
+		return m.Snapshots
+	}
+	return nil
+}
+
+type ListServicesRequest struct {
+}
+
+func (m *ListServicesRequest) Reset()         { *m = ListServicesRequest{} }
+func (m *ListServicesRequest) String() string { return proto.CompactTextString(m) }
+func (*ListServicesRequest) ProtoMessage()    {}
+
+type ListServicesResponse struct {
+	Services []*Service `protobuf:"bytes,1,rep,name=services" json:"services,omitempty"`
+}
+
+func (m *ListServicesResponse) Reset()         { *m = ListServicesResponse{} }
+func (m *ListServicesResponse) String() string { return proto.CompactTextString(m) }
+func (*ListServicesResponse) ProtoMessage()    {}
+
+func (m *ListServicesResponse) GetServices() []*Service {
+	if m != nil {
+		return m.Services
+	}
+	return nil
+}
+
+type ListTasksRequest struct {
+}
+
+func (m *ListTasksRequest) Reset()         { *m = ListTasksRequest{} }
+func (m *ListTasksRequest) String() string { return proto.CompactTextString(m) }
+func (*ListTasksRequest) ProtoMessage()    {}
+
+type ListTasksResponse struct {
+	Tasks []*Task `protobuf:"bytes,1,rep,name=tasks" json:"tasks,omitempty"`
+}
+
+func (m *ListTasksResponse) Reset()         { *m = ListTasksResponse{} }
+func (m *ListTasksResponse) String() string { return proto.CompactTextString(m) }
+func (*ListTasksResponse) ProtoMessage()    {}
+
+func (m *ListTasksResponse) GetTasks() []*Task {
+	if m != nil {
+		return m.Tasks
+	}
+	return nil
+}
+
+type ListModulesRequest struct {
+}
+
+func (m *ListModulesRequest) Reset()         { *m = ListModulesRequest{} }
+func (m *ListModulesRequest) String() string { return proto.CompactTextString(m) }
+func (*ListModulesRequest) ProtoMessage()    {}
+
+type ListModulesResponse struct {
+	Modules []*Module `protobuf:"bytes,1,rep,name=modules" json:"modules,omitempty"`
+}
+
+func (m *ListModulesResponse) Reset()         { *m = ListModulesResponse{} }
+func (m *ListModulesResponse) String() string { return proto.CompactTextString(m) }
+func (*ListModulesResponse) ProtoMessage()    {}
+
+func (m *ListModulesResponse) GetModules() []*Module {
+	if m != nil {
+		return m.Modules
+	}
+	return nil
+}
+
+type CreateVolumeRequest struct {
+	Name             string            `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Size             int64             `protobuf:"varint,2,opt,name=size" json:"size,omitempty"`
+	Type             string            `protobuf:"bytes,3,opt,name=type" json:"type,omitempty"`
+	Iops             int64             `protobuf:"varint,4,opt,name=iops" json:"iops,omitempty"`
+	AvailabilityZone string            `protobuf:"bytes,5,opt,name=availability_zone" json:"availability_zone,omitempty"`
+	Opts             map[string]string `protobuf:"bytes,6,rep,name=opts" json:"opts,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *CreateVolumeRequest) Reset()         { *m = CreateVolumeRequest{} }
+func (m *CreateVolumeRequest) String() string { return proto.CompactTextString(m) }
+func (*CreateVolumeRequest) ProtoMessage()    {}
+
+func (m *CreateVolumeRequest) GetOpts() map[string]string {
+	if m != nil {
+		return m.Opts
+	}
+	return nil
+}
+
+type RemoveVolumeRequest struct {
+	Id    string `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+	Purge bool   `protobuf:"varint,2,opt,name=purge" json:"purge,omitempty"`
+}
+
+func (m *RemoveVolumeRequest) Reset()         { *m = RemoveVolumeRequest{} }
+func (m *RemoveVolumeRequest) String() string { return proto.CompactTextString(m) }
+func (*RemoveVolumeRequest) ProtoMessage()    {}
+
+type RemoveVolumeResponse struct {
+}
+
+func (m *RemoveVolumeResponse) Reset()         { *m = RemoveVolumeResponse{} }
+func (m *RemoveVolumeResponse) String() string { return proto.CompactTextString(m) }
+func (*RemoveVolumeResponse) ProtoMessage()    {}
+
+type CreateSnapshotRequest struct {
+	VolumeId string            `protobuf:"bytes,1,opt,name=volume_id" json:"volume_id,omitempty"`
+	Name     string            `protobuf:"bytes,2,opt,name=name" json:"name,omitempty"`
+	Opts     map[string]string `protobuf:"bytes,3,rep,name=opts" json:"opts,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *CreateSnapshotRequest) Reset()         { *m = CreateSnapshotRequest{} }
+func (m *CreateSnapshotRequest) String() string { return proto.CompactTextString(m) }
+func (*CreateSnapshotRequest) ProtoMessage()    {}
+
+func (m *CreateSnapshotRequest) GetOpts() map[string]string {
+	if m != nil {
+		return m.Opts
+	}
+	return nil
+}
+
+type RemoveSnapshotRequest struct {
+	Id string `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+}
+
+func (m *RemoveSnapshotRequest) Reset()         { *m = RemoveSnapshotRequest{} }
+func (m *RemoveSnapshotRequest) String() string { return proto.CompactTextString(m) }
+func (*RemoveSnapshotRequest) ProtoMessage()    {}
+
+type RemoveSnapshotResponse struct {
+}
+
+func (m *RemoveSnapshotResponse) Reset()         { *m = RemoveSnapshotResponse{} }
+func (m *RemoveSnapshotResponse) String() string { return proto.CompactTextString(m) }
+func (*RemoveSnapshotResponse) ProtoMessage()    {}
+
+type SubmitTaskRequest struct {
+	Operation string            `protobuf:"bytes,1,opt,name=operation" json:"operation,omitempty"`
+	Params    map[string]string `protobuf:"bytes,2,rep,name=params" json:"params,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+}
+
+func (m *SubmitTaskRequest) Reset()         { *m = SubmitTaskRequest{} }
+func (m *SubmitTaskRequest) String() string { return proto.CompactTextString(m) }
+func (*SubmitTaskRequest) ProtoMessage()    {}
+
+func (m *SubmitTaskRequest) GetParams() map[string]string {
+	if m != nil {
+		return m.Params
+	}
+	return nil
+}
+
+type InspectTaskRequest struct {
+	Id int64 `protobuf:"varint,1,opt,name=id" json:"id,omitempty"`
+}
+
+func (m *InspectTaskRequest) Reset()         { *m = InspectTaskRequest{} }
+func (m *InspectTaskRequest) String() string { return proto.CompactTextString(m) }
+func (*InspectTaskRequest) ProtoMessage()    {}
+
+type StartModuleRequest struct {
+	Name string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+}
+
+func (m *StartModuleRequest) Reset()         { *m = StartModuleRequest{} }
+func (m *StartModuleRequest) String() string { return proto.CompactTextString(m) }
+func (*StartModuleRequest) ProtoMessage()    {}
+
+func init() {
+	proto.RegisterType((*Attachment)(nil), "adminpb.Attachment")
+	proto.RegisterType((*Volume)(nil), "adminpb.Volume")
+	proto.RegisterType((*Snapshot)(nil), "adminpb.Snapshot")
+	proto.RegisterType((*Service)(nil), "adminpb.Service")
+	proto.RegisterType((*Task)(nil), "adminpb.Task")
+	proto.RegisterType((*Module)(nil), "adminpb.Module")
+	proto.RegisterType((*ListVolumesRequest)(nil), "adminpb.ListVolumesRequest")
+	proto.RegisterType((*ListVolumesResponse)(nil), "adminpb.ListVolumesResponse")
+	proto.RegisterType((*InspectVolumeRequest)(nil), "adminpb.InspectVolumeRequest")
+	proto.RegisterType((*ListSnapshotsRequest)(nil), "adminpb.ListSnapshotsRequest")
+	proto.RegisterType((*ListSnapshotsResponse)(nil), "adminpb.ListSnapshotsResponse")
+	proto.RegisterType((*ListServicesRequest)(nil), "adminpb.ListServicesRequest")
+	proto.RegisterType((*ListServicesResponse)(nil), "adminpb.ListServicesResponse")
+	proto.RegisterType((*ListTasksRequest)(nil), "adminpb.ListTasksRequest")
+	proto.RegisterType((*ListTasksResponse)(nil), "adminpb.ListTasksResponse")
+	proto.RegisterType((*ListModulesRequest)(nil), "adminpb.ListModulesRequest")
+	proto.RegisterType((*ListModulesResponse)(nil), "adminpb.ListModulesResponse")
+	proto.RegisterType((*CreateVolumeRequest)(nil), "adminpb.CreateVolumeRequest")
+	proto.RegisterType((*RemoveVolumeRequest)(nil), "adminpb.RemoveVolumeRequest")
+	proto.RegisterType((*RemoveVolumeResponse)(nil), "adminpb.RemoveVolumeResponse")
+	proto.RegisterType((*CreateSnapshotRequest)(nil), "adminpb.CreateSnapshotRequest")
+	proto.RegisterType((*RemoveSnapshotRequest)(nil), "adminpb.RemoveSnapshotRequest")
+	proto.RegisterType((*RemoveSnapshotResponse)(nil), "adminpb.RemoveSnapshotResponse")
+	proto.RegisterType((*SubmitTaskRequest)(nil), "adminpb.SubmitTaskRequest")
+	proto.RegisterType((*InspectTaskRequest)(nil), "adminpb.InspectTaskRequest")
+	proto.RegisterType((*StartModuleRequest)(nil), "adminpb.StartModuleRequest")
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// Client API for Admin service
+
+type AdminClient interface {
+	ListVolumes(ctx context.Context, in *ListVolumesRequest, opts ...grpc.CallOption) (*ListVolumesResponse, error)
+	InspectVolume(ctx context.Context, in *InspectVolumeRequest, opts ...grpc.CallOption) (*Volume, error)
+	ListSnapshots(ctx context.Context, in *ListSnapshotsRequest, opts ...grpc.CallOption) (*ListSnapshotsResponse, error)
+	ListServices(ctx context.Context, in *ListServicesRequest, opts ...grpc.CallOption) (*ListServicesResponse, error)
+	ListTasks(ctx context.Context, in *ListTasksRequest, opts ...grpc.CallOption) (*ListTasksResponse, error)
+	ListModules(ctx context.Context, in *ListModulesRequest, opts ...grpc.CallOption) (*ListModulesResponse, error)
+	CreateVolume(ctx context.Context, in *CreateVolumeRequest, opts ...grpc.CallOption) (*Volume, error)
+	RemoveVolume(ctx context.Context, in *RemoveVolumeRequest, opts ...grpc.CallOption) (*RemoveVolumeResponse, error)
+	CreateSnapshot(ctx context.Context, in *CreateSnapshotRequest, opts ...grpc.CallOption) (*Snapshot, error)
+	RemoveSnapshot(ctx context.Context, in *RemoveSnapshotRequest, opts ...grpc.CallOption) (*RemoveSnapshotResponse, error)
+	SubmitTask(ctx context.Context, in *SubmitTaskRequest, opts ...grpc.CallOption) (*Task, error)
+	InspectTask(ctx context.Context, in *InspectTaskRequest, opts ...grpc.CallOption) (*Task, error)
+	StartModule(ctx context.Context, in *StartModuleRequest, opts ...grpc.CallOption) (*Module, error)
+}
+
+type adminClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewAdminClient(cc *grpc.ClientConn) AdminClient {
+	return &adminClient{cc}
+}
+
+func (c *adminClient) ListVolumes(ctx context.Context, in *ListVolumesRequest, opts ...grpc.CallOption) (*ListVolumesResponse, error) {
+	out := new(ListVolumesResponse)
+	err := grpc.Invoke(ctx, "/adminpb.Admin/ListVolumes", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) InspectVolume(ctx context.Context, in *InspectVolumeRequest, opts ...grpc.CallOption) (*Volume, error) {
+	out := new(Volume)
+	err := grpc.Invoke(ctx, "/adminpb.Admin/InspectVolume", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) ListSnapshots(ctx context.Context, in *ListSnapshotsRequest, opts ...grpc.CallOption) (*ListSnapshotsResponse, error) {
+	out := new(ListSnapshotsResponse)
+	err := grpc.Invoke(ctx, "/adminpb.Admin/ListSnapshots", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) ListServices(ctx context.Context, in *ListServicesRequest, opts ...grpc.CallOption) (*ListServicesResponse, error) {
+	out := new(ListServicesResponse)
+	err := grpc.Invoke(ctx, "/adminpb.Admin/ListServices", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) ListTasks(ctx context.Context, in *ListTasksRequest, opts ...grpc.CallOption) (*ListTasksResponse, error) {
+	out := new(ListTasksResponse)
+	err := grpc.Invoke(ctx, "/adminpb.Admin/ListTasks", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) ListModules(ctx context.Context, in *ListModulesRequest, opts ...grpc.CallOption) (*ListModulesResponse, error) {
+	out := new(ListModulesResponse)
+	err := grpc.Invoke(ctx, "/adminpb.Admin/ListModules", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) CreateVolume(ctx context.Context, in *CreateVolumeRequest, opts ...grpc.CallOption) (*Volume, error) {
+	out := new(Volume)
+	err := grpc.Invoke(ctx, "/adminpb.Admin/CreateVolume", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) RemoveVolume(ctx context.Context, in *RemoveVolumeRequest, opts ...grpc.CallOption) (*RemoveVolumeResponse, error) {
+	out := new(RemoveVolumeResponse)
+	err := grpc.Invoke(ctx, "/adminpb.Admin/RemoveVolume", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) CreateSnapshot(ctx context.Context, in *CreateSnapshotRequest, opts ...grpc.CallOption) (*Snapshot, error) {
+	out := new(Snapshot)
+	err := grpc.Invoke(ctx, "/adminpb.Admin/CreateSnapshot", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) RemoveSnapshot(ctx context.Context, in *RemoveSnapshotRequest, opts ...grpc.CallOption) (*RemoveSnapshotResponse, error) {
+	out := new(RemoveSnapshotResponse)
+	err := grpc.Invoke(ctx, "/adminpb.Admin/RemoveSnapshot", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) SubmitTask(ctx context.Context, in *SubmitTaskRequest, opts ...grpc.CallOption) (*Task, error) {
+	out := new(Task)
+	err := grpc.Invoke(ctx, "/adminpb.Admin/SubmitTask", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) InspectTask(ctx context.Context, in *InspectTaskRequest, opts ...grpc.CallOption) (*Task, error) {
+	out := new(Task)
+	err := grpc.Invoke(ctx, "/adminpb.Admin/InspectTask", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) StartModule(ctx context.Context, in *StartModuleRequest, opts ...grpc.CallOption) (*Module, error) {
+	out := new(Module)
+	err := grpc.Invoke(ctx, "/adminpb.Admin/StartModule", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Server API for Admin service
+
+type AdminServer interface {
+	ListVolumes(context.Context, *ListVolumesRequest) (*ListVolumesResponse, error)
+	InspectVolume(context.Context, *InspectVolumeRequest) (*Volume, error)
+	ListSnapshots(context.Context, *ListSnapshotsRequest) (*ListSnapshotsResponse, error)
+	ListServices(context.Context, *ListServicesRequest) (*ListServicesResponse, error)
+	ListTasks(context.Context, *ListTasksRequest) (*ListTasksResponse, error)
+	ListModules(context.Context, *ListModulesRequest) (*ListModulesResponse, error)
+	CreateVolume(context.Context, *CreateVolumeRequest) (*Volume, error)
+	RemoveVolume(context.Context, *RemoveVolumeRequest) (*RemoveVolumeResponse, error)
+	CreateSnapshot(context.Context, *CreateSnapshotRequest) (*Snapshot, error)
+	RemoveSnapshot(context.Context, *RemoveSnapshotRequest) (*RemoveSnapshotResponse, error)
+	SubmitTask(context.Context, *SubmitTaskRequest) (*Task, error)
+	InspectTask(context.Context, *InspectTaskRequest) (*Task, error)
+	StartModule(context.Context, *StartModuleRequest) (*Module, error)
+}
+
+func RegisterAdminServer(s *grpc.Server, srv AdminServer) {
+	s.RegisterService(&_Admin_serviceDesc, srv)
+}
+
+func _Admin_ListVolumes_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListVolumesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).ListVolumes(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/adminpb.Admin/ListVolumes",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).ListVolumes(ctx, req.(*ListVolumesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_InspectVolume_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InspectVolumeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).InspectVolume(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/adminpb.Admin/InspectVolume",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).InspectVolume(ctx, req.(*InspectVolumeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_ListSnapshots_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListSnapshotsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).ListSnapshots(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/adminpb.Admin/ListSnapshots",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).ListSnapshots(ctx, req.(*ListSnapshotsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_ListServices_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListServicesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).ListServices(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/adminpb.Admin/ListServices",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).ListServices(ctx, req.(*ListServicesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_ListTasks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListTasksRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).ListTasks(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/adminpb.Admin/ListTasks",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).ListTasks(ctx, req.(*ListTasksRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_ListModules_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListModulesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).ListModules(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/adminpb.Admin/ListModules",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).ListModules(ctx, req.(*ListModulesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_CreateVolume_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateVolumeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).CreateVolume(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/adminpb.Admin/CreateVolume",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).CreateVolume(ctx, req.(*CreateVolumeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_RemoveVolume_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RemoveVolumeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).RemoveVolume(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/adminpb.Admin/RemoveVolume",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).RemoveVolume(ctx, req.(*RemoveVolumeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_CreateSnapshot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateSnapshotRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).CreateSnapshot(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/adminpb.Admin/CreateSnapshot",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).CreateSnapshot(ctx, req.(*CreateSnapshotRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_RemoveSnapshot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RemoveSnapshotRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).RemoveSnapshot(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/adminpb.Admin/RemoveSnapshot",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).RemoveSnapshot(ctx, req.(*RemoveSnapshotRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_SubmitTask_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SubmitTaskRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).SubmitTask(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/adminpb.Admin/SubmitTask",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).SubmitTask(ctx, req.(*SubmitTaskRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_InspectTask_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InspectTaskRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).InspectTask(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/adminpb.Admin/InspectTask",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).InspectTask(ctx, req.(*InspectTaskRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_StartModule_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StartModuleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).StartModule(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/adminpb.Admin/StartModule",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).StartModule(ctx, req.(*StartModuleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _Admin_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "adminpb.Admin",
+	HandlerType: (*AdminServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListVolumes",
+			Handler:    _Admin_ListVolumes_Handler,
+		},
+		{
+			MethodName: "InspectVolume",
+			Handler:    _Admin_InspectVolume_Handler,
+		},
+		{
+			MethodName: "ListSnapshots",
+			Handler:    _Admin_ListSnapshots_Handler,
+		},
+		{
+			MethodName: "ListServices",
+			Handler:    _Admin_ListServices_Handler,
+		},
+		{
+			MethodName: "ListTasks",
+			Handler:    _Admin_ListTasks_Handler,
+		},
+		{
+			MethodName: "ListModules",
+			Handler:    _Admin_ListModules_Handler,
+		},
+		{
+			MethodName: "CreateVolume",
+			Handler:    _Admin_CreateVolume_Handler,
+		},
+		{
+			MethodName: "RemoveVolume",
+			Handler:    _Admin_RemoveVolume_Handler,
+		},
+		{
+			MethodName: "CreateSnapshot",
+			Handler:    _Admin_CreateSnapshot_Handler,
+		},
+		{
+			MethodName: "RemoveSnapshot",
+			Handler:    _Admin_RemoveSnapshot_Handler,
+		},
+		{
+			MethodName: "SubmitTask",
+			Handler:    _Admin_SubmitTask_Handler,
+		},
+		{
+			MethodName: "InspectTask",
+			Handler:    _Admin_InspectTask_Handler,
+		},
+		{
+			MethodName: "StartModule",
+			Handler:    _Admin_StartModule_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "admin.proto",
+}
@@ -0,0 +1,332 @@
+package agent
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"github.com/akutz/gofig"
+	apitypes "github.com/emccode/libstorage/api/types"
+	apiutils "github.com/emccode/libstorage/api/utils"
+
+	"github.com/emccode/rexray/core/health"
+	"github.com/emccode/rexray/core/iostats"
+	"github.com/emccode/rexray/core/nodes"
+	"github.com/emccode/rexray/core/openfiles"
+	"github.com/emccode/rexray/core/schedule"
+	"github.com/emccode/rexray/core/state"
+	"github.com/emccode/rexray/daemon/module"
+)
+
+const (
+	modName = "agent"
+
+	heartbeatJob = "agent.heartbeat"
+	fuseJob      = "agent.superviseFUSE"
+	ioStatsJob   = "agent.ioStats"
+	cleanupJob   = "agent.cleanup"
+	scrubJob     = "agent.scrub"
+)
+
+type mod struct {
+	lsc    apitypes.Client
+	ctx    apitypes.Context
+	config gofig.Config
+	name   string
+	addr   string
+	desc   string
+	store  *state.Store
+	local  *state.Store
+	sched  *schedule.Scheduler
+
+	// mounts are the mount points of the volumes mounted on this instance as
+	// of the last heartbeat, keyed by volume ID
+	mounts    map[string][]string
+	mountsRwl sync.RWMutex
+
+	// devices are the devices of the volumes mounted on this instance as of
+	// the last heartbeat; they are guarded by mountsRwl
+	devices []*iostats.Volume
+}
+
+func init() {
+	module.RegisterModule(modName, newModule)
+
+	r := gofig.NewRegistration("Agent")
+	r.Key(gofig.String, "", "30s",
+		"The interval at which the agent reports the state of the instance",
+		"rexray.nodes.heartbeat.interval")
+	gofig.Register(r)
+}
+
+func newModule(ctx apitypes.Context, c *module.Config) (module.Module, error) {
+	return &mod{
+		ctx:    ctx,
+		config: c.Config,
+		lsc:    c.Client,
+		name:   c.Name,
+		desc:   c.Description,
+		addr:   c.Address,
+		store:  state.Default(),
+		local:  state.Local(),
+		sched:  schedule.Default(ctx),
+		mounts: map[string][]string{},
+	}, nil
+}
+
+func (m *mod) Start() error {
+	interval := heartbeatInterval(m.config)
+
+	m.recoverJournal()
+
+	if err := m.sched.Add(&schedule.Job{
+		Name:       heartbeatJob,
+		Schedule:   schedule.Every(interval),
+		RunOnStart: true,
+		Run:        m.heartbeat,
+	}); err != nil {
+		return err
+	}
+
+	if m.config.GetBool("rexray.volume.fuse.supervise") {
+		if err := m.superviseFUSE(); err != nil {
+			return err
+		}
+	}
+
+	if m.config.GetBool("rexray.volume.iostats.enabled") {
+		if err := m.sampleIOStats(); err != nil {
+			return err
+		}
+	}
+
+	if err := m.scheduleCleanup(); err != nil {
+		return err
+	}
+
+	if err := m.scheduleScrub(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (m *mod) Stop() error {
+	m.sched.Remove(heartbeatJob)
+	m.sched.Remove(fuseJob)
+	m.sched.Remove(ioStatsJob)
+	m.sched.Remove(cleanupJob)
+	m.sched.Remove(scrubJob)
+	return nil
+}
+
+func (m *mod) Name() string {
+	return m.name
+}
+
+func (m *mod) Description() string {
+	return m.desc
+}
+
+func (m *mod) Address() string {
+	return m.addr
+}
+
+func heartbeatInterval(config gofig.Config) time.Duration {
+	dur, err := time.ParseDuration(
+		config.GetString("rexray.nodes.heartbeat.interval"))
+	if err != nil {
+		return time.Duration(30) * time.Second
+	}
+	return dur
+}
+
+// heartbeat unmounts any volumes fenced from this instance and then records
+// the instance's health and mounted volumes.
+func (m *mod) heartbeat() {
+	iid, err := m.lsc.Executor().InstanceID(m.ctx, apiutils.NewStore())
+	if err != nil {
+		m.ctx.WithError(err).Error("error getting instance ID")
+		return
+	}
+
+	m.unmountFenced(iid.ID)
+
+	mounts, devices, err := m.localMounts(iid.ID)
+	if err != nil {
+		m.ctx.WithError(err).Error("error getting mounted volumes")
+		return
+	}
+
+	m.mountsRwl.Lock()
+	m.mounts = mounts
+	m.devices = devices
+	m.mountsRwl.Unlock()
+
+	m.checkHealth(mounts)
+
+	digest, err := nodes.ConfigDigest(m.config)
+	if err != nil {
+		m.ctx.WithError(err).Warn("error computing config digest")
+	}
+
+	hostname, _ := os.Hostname()
+	n := &nodes.Node{
+		InstanceID:   iid.ID,
+		Hostname:     hostname,
+		Heartbeat:    time.Now().UTC(),
+		Mounts:       []string{},
+		ConfigDigest: digest,
+	}
+	for volumeID := range mounts {
+		n.Mounts = append(n.Mounts, volumeID)
+	}
+
+	if err := nodes.Put(state.Controller(), n); err != nil {
+		m.ctx.WithError(err).Error("error recording heartbeat")
+		return
+	}
+
+	m.ctx.WithFields(map[string]interface{}{
+		"instanceID": n.InstanceID,
+		"mounts":     len(n.Mounts),
+	}).Debug("recorded heartbeat")
+}
+
+// localMounts returns the mount points of the volumes attached to and
+// mounted on this instance, keyed by volume ID, and the volumes' devices.
+func (m *mod) localMounts(
+	iid string) (map[string][]string, []*iostats.Volume, error) {
+
+	vols, err := m.lsc.Storage().Volumes(
+		m.ctx, &apitypes.VolumesOpts{Attachments: true})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	mounts := map[string][]string{}
+	devices := []*iostats.Volume{}
+	for _, v := range vols {
+		for _, a := range v.Attachments {
+			if a.InstanceID == nil || a.InstanceID.ID != iid {
+				continue
+			}
+			mi, err := m.lsc.OS().Mounts(
+				m.ctx, a.DeviceName, "", apiutils.NewStore())
+			if err != nil {
+				return nil, nil, err
+			}
+			for _, mp := range mi {
+				mounts[v.ID] = append(mounts[v.ID], mp.MountPoint)
+			}
+			if len(mi) > 0 {
+				devices = append(devices, &iostats.Volume{
+					ID:     v.ID,
+					Name:   v.Name,
+					Driver: a.InstanceID.Driver,
+					Device: a.DeviceName,
+				})
+			}
+		}
+	}
+	return mounts, devices, nil
+}
+
+// checkHealth records the health of each of the mounted volumes and, if
+// configured, repairs the volumes whose mounts are hung or stale.
+func (m *mod) checkHealth(mounts map[string][]string) {
+	timeout := health.StatTimeout(m.config)
+	autoRepair := m.config.GetBool("rexray.volume.health.autoRepair")
+
+	for volumeID, mountPoints := range mounts {
+		r, err := health.CheckVolume(m.store, volumeID, mountPoints, timeout)
+		if err != nil {
+			m.ctx.WithField("volumeID", volumeID).WithError(err).Error(
+				"error recording volume health")
+			continue
+		}
+		if r.Status != health.Degraded {
+			continue
+		}
+
+		fields := map[string]interface{}{
+			"volumeID":   volumeID,
+			"mountPoint": r.MountPoint,
+			"reason":     r.Reason,
+		}
+		m.ctx.WithFields(fields).Warn("volume degraded")
+
+		// FUSE volumes whose helper exited are remounted by the supervisor
+		if !autoRepair || (m.config.GetBool("rexray.volume.fuse.supervise") &&
+			health.IsFUSEMount(r.MountPoint)) {
+			continue
+		}
+		if _, err := health.Repair(
+			m.ctx, m.lsc, m.store, volumeID); err != nil {
+			m.ctx.WithFields(fields).WithError(err).Error(
+				"error repairing volume")
+		}
+	}
+}
+
+// unmountFenced unmounts the volumes that were preempted by another instance
+// with fencing. A fenced volume may already be detached from this instance,
+// so it is unmounted using the mount points recorded by the last heartbeat.
+func (m *mod) unmountFenced(iid string) {
+	fenced, err := nodes.Fenced(state.Controller(), iid)
+	if err != nil {
+		m.ctx.WithError(err).Error("error getting fenced volumes")
+		return
+	}
+
+	m.mountsRwl.RLock()
+	defer m.mountsRwl.RUnlock()
+
+	for _, volumeID := range fenced {
+		fields := map[string]interface{}{"volumeID": volumeID}
+
+		failed := false
+		for _, mp := range m.mounts[volumeID] {
+			fields["mountPoint"] = mp
+			if err := m.lsc.OS().Unmount(
+				m.ctx, mp, apiutils.NewStore()); err != nil {
+				m.ctx.WithFields(fields).WithError(err).Error(
+					"error unmounting fenced volume")
+				m.logOpenFiles(mp)
+				failed = true
+			}
+		}
+		if failed {
+			continue
+		}
+
+		if err := nodes.Unfence(state.Controller(), iid, volumeID); err != nil {
+			m.ctx.WithFields(fields).WithError(err).Error(
+				"error removing fence")
+			continue
+		}
+		m.ctx.WithFields(fields).Warn("unmounted fenced volume")
+	}
+}
+
+// logOpenFiles logs the processes with files open on the provided mount
+// point.
+func (m *mod) logOpenFiles(mountPoint string) {
+	files, err := openfiles.List(mountPoint)
+	if err != nil {
+		m.ctx.WithError(err).Warn("error listing open files")
+		if !openfiles.IsIncomplete(err) {
+			return
+		}
+	}
+	for _, f := range files {
+		m.ctx.WithFields(map[string]interface{}{
+			"mountPoint":  mountPoint,
+			"pid":         f.PID,
+			"command":     f.Command,
+			"containerID": f.ContainerID,
+			"fd":          f.FD,
+			"path":        f.Path,
+		}).Error("open file prevents unmount")
+	}
+}
@@ -0,0 +1,248 @@
+package agent
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/akutz/gofig"
+	apitypes "github.com/emccode/libstorage/api/types"
+	apiutils "github.com/emccode/libstorage/api/utils"
+
+	"github.com/emccode/rexray/core/journal"
+	"github.com/emccode/rexray/core/policy"
+	"github.com/emccode/rexray/core/schedule"
+	"github.com/emccode/rexray/util"
+)
+
+func init() {
+	r := gofig.NewRegistration("Orphaned Mounts")
+	r.Key(gofig.String, "", "5m",
+		"The interval at which orphaned mounts and stale attachments are "+
+			"detected, or 0 to disable detection",
+		"rexray.volume.cleanup.interval")
+	r.Key(gofig.Bool, "", false,
+		"Unmount orphaned mounts and detach stale attachments",
+		"rexray.volume.cleanup.auto")
+	r.Key(gofig.String, "", "",
+		"The directory under which volumes are mounted; defaults to the "+
+			"volumes directory of the REX-Ray lib directory",
+		"rexray.volume.cleanup.rootPath")
+	gofig.Register(r)
+}
+
+// The kinds of inconsistencies found by the cleanup job.
+const (
+	orphanedMount   = "orphanedMount"
+	staleAttachment = "staleAttachment"
+)
+
+// orphan is an inconsistency between the mounts of this instance and the
+// volumes attached to it.
+type orphan struct {
+	kind       string
+	volumeID   string
+	mountPoint string
+}
+
+func (o orphan) key() string {
+	return o.kind + ":" + o.volumeID + ":" + o.mountPoint
+}
+
+// scheduleCleanup schedules a periodic search for orphaned mounts and stale
+// attachments until the module is stopped.
+func (m *mod) scheduleCleanup() error {
+	interval, err := time.ParseDuration(
+		m.config.GetString("rexray.volume.cleanup.interval"))
+	if err != nil || interval <= 0 {
+		return nil
+	}
+	suspects := map[string]bool{}
+	return m.sched.Add(&schedule.Job{
+		Name:     cleanupJob,
+		Schedule: schedule.Every(interval),
+		Run:      func() { m.cleanup(suspects) },
+	})
+}
+
+// cleanup finds the mount points under the volume root that do not belong
+// to a volume attached to this instance and, if volumes are attached on
+// mount, the volumes attached to this instance that are not mounted. An
+// inconsistency must be found by two consecutive runs before it is reported,
+// so that a mount or unmount in progress is not mistaken for one, and it is
+// reported once until it is resolved. Reported inconsistencies are cleaned
+// up if rexray.volume.cleanup.auto is set.
+func (m *mod) cleanup(suspects map[string]bool) {
+	iid, err := m.lsc.Executor().InstanceID(m.ctx, apiutils.NewStore())
+	if err != nil {
+		m.ctx.WithError(err).Error("error getting instance ID")
+		return
+	}
+
+	found, err := m.findOrphans(iid.ID)
+	if err != nil {
+		m.ctx.WithError(err).Error("error finding orphaned mounts")
+		return
+	}
+
+	auto := m.config.GetBool("rexray.volume.cleanup.auto")
+	seen := map[string]bool{}
+	for _, o := range found {
+		k := o.key()
+		seen[k] = true
+
+		reported, ok := suspects[k]
+		if !ok {
+			suspects[k] = false
+			continue
+		}
+		if !reported {
+			m.reportOrphan(o)
+			suspects[k] = true
+		}
+		if auto {
+			m.cleanupOrphan(iid.ID, o)
+		}
+	}
+
+	for k := range suspects {
+		if !seen[k] {
+			delete(suspects, k)
+		}
+	}
+}
+
+// findOrphans returns the inconsistencies between the mounts of this
+// instance and the volumes attached to it. The volumes of operations that
+// are in progress are ignored.
+func (m *mod) findOrphans(iid string) ([]orphan, error) {
+	entries, err := journal.List(m.local)
+	if err != nil {
+		return nil, err
+	}
+	busy := map[string]bool{}
+	for _, e := range entries {
+		busy[e.VolumeID] = true
+	}
+
+	vols, err := m.lsc.Storage().Volumes(
+		m.ctx, &apitypes.VolumesOpts{Attachments: true})
+	if err != nil {
+		return nil, err
+	}
+
+	all, err := m.lsc.OS().Mounts(m.ctx, "", "", apiutils.NewStore())
+	if err != nil {
+		return nil, err
+	}
+
+	found := []orphan{}
+	owned := map[string]bool{}
+	checkAttached := policy.GetAttachMode(m.config) == policy.AttachOnMount
+
+	for _, v := range vols {
+		for _, a := range v.Attachments {
+			if a.InstanceID == nil || a.InstanceID.ID != iid {
+				continue
+			}
+			mounted := false
+			for _, mi := range all {
+				if a.DeviceName != "" && mi.Source == a.DeviceName {
+					owned[mi.MountPoint] = true
+					mounted = true
+				}
+			}
+			if !mounted && checkAttached && !busy[v.ID] {
+				found = append(found, orphan{
+					kind:     staleAttachment,
+					volumeID: v.ID,
+				})
+			}
+		}
+	}
+
+	root := m.volumeRoot()
+	for _, mi := range all {
+		if owned[mi.MountPoint] ||
+			!strings.HasPrefix(mi.MountPoint, root+string(filepath.Separator)) {
+			continue
+		}
+		found = append(found, orphan{
+			kind:       orphanedMount,
+			mountPoint: mi.MountPoint,
+		})
+	}
+	return found, nil
+}
+
+func (m *mod) volumeRoot() string {
+	if p := m.config.GetString("rexray.volume.cleanup.rootPath"); p != "" {
+		return filepath.Clean(p)
+	}
+	return util.LibFilePath("volumes")
+}
+
+func (m *mod) reportOrphan(o orphan) {
+	fields := map[string]interface{}{"kind": o.kind}
+	if o.volumeID != "" {
+		fields["volumeID"] = o.volumeID
+	}
+	if o.mountPoint != "" {
+		fields["mountPoint"] = o.mountPoint
+	}
+
+	switch o.kind {
+	case orphanedMount:
+		m.ctx.WithFields(fields).Warn("mount has no attached volume")
+		m.emit("cleanup.orphanedMount", "",
+			"mount has no attached volume",
+			map[string]string{"mountPoint": o.mountPoint})
+	case staleAttachment:
+		m.ctx.WithFields(fields).Warn("attached volume is not mounted")
+		m.emit("cleanup.staleAttachment", o.volumeID,
+			"attached volume is not mounted", nil)
+	}
+}
+
+// cleanupOrphan unmounts an orphaned mount and removes its mount point, or
+// detaches a volume that is attached but not mounted.
+func (m *mod) cleanupOrphan(iid string, o orphan) {
+	switch o.kind {
+	case orphanedMount:
+		fields := map[string]string{"mountPoint": o.mountPoint}
+		if err := m.lsc.OS().Unmount(
+			m.ctx, o.mountPoint, apiutils.NewStore()); err != nil {
+			m.ctx.WithField("mountPoint", o.mountPoint).WithError(err).Error(
+				"error unmounting orphaned mount")
+			m.logOpenFiles(o.mountPoint)
+			fields["error"] = err.Error()
+			m.emit("cleanup.failed", "",
+				"error unmounting orphaned mount", fields)
+			return
+		}
+
+		// only empty directories are removed, up to the volume root
+		root := m.volumeRoot()
+		p := o.mountPoint
+		for p != root && strings.HasPrefix(p, root) && os.Remove(p) == nil {
+			p = filepath.Dir(p)
+		}
+		m.emit("cleanup.unmounted", "", "unmounted orphaned mount", fields)
+
+	case staleAttachment:
+		action, err := m.detachIfAttached(iid, o.volumeID)
+		if err != nil {
+			m.ctx.WithField("volumeID", o.volumeID).WithError(err).Error(
+				"error detaching stale attachment")
+			m.emit("cleanup.failed", o.volumeID,
+				"error detaching stale attachment",
+				map[string]string{"error": err.Error()})
+			return
+		}
+		if action == "detached" {
+			m.emit("cleanup.detached", o.volumeID,
+				"detached stale attachment", nil)
+		}
+	}
+}
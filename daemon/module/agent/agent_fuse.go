@@ -0,0 +1,153 @@
+package agent
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/akutz/gofig"
+
+	"github.com/emccode/rexray/core/events"
+	"github.com/emccode/rexray/core/health"
+	"github.com/emccode/rexray/core/schedule"
+)
+
+func init() {
+	r := gofig.NewRegistration("FUSE Supervision")
+	r.Key(gofig.Bool, "", true,
+		"Remount FUSE volumes whose helper process exits",
+		"rexray.volume.fuse.supervise")
+	r.Key(gofig.String, "", "5s",
+		"The interval at which FUSE mounts are checked",
+		"rexray.volume.fuse.checkInterval")
+	r.Key(gofig.String, "", "5m",
+		"The maximum duration between attempts to remount a FUSE volume",
+		"rexray.volume.fuse.maxBackoff")
+	gofig.Register(r)
+}
+
+// fuseMount is the supervision state of a FUSE mount point.
+type fuseMount struct {
+
+	// helper is the ID of the process serving the mount, or zero if it
+	// could not be found.
+	helper int
+
+	// failures is the number of consecutive failed attempts to remount.
+	failures int
+
+	// retryAt is the time before which the mount is not remounted again.
+	retryAt time.Time
+}
+
+func fuseDuration(
+	config gofig.Config, key string, def time.Duration) time.Duration {
+
+	d, err := time.ParseDuration(config.GetString(key))
+	if err != nil {
+		return def
+	}
+	return d
+}
+
+// superviseFUSE schedules a periodic check of the FUSE mounts of the volumes
+// mounted on this instance until the module is stopped.
+func (m *mod) superviseFUSE() error {
+	interval := fuseDuration(
+		m.config, "rexray.volume.fuse.checkInterval", 5*time.Second)
+	supervised := map[string]*fuseMount{}
+	return m.sched.Add(&schedule.Job{
+		Name:     fuseJob,
+		Schedule: schedule.Every(interval),
+		Run:      func() { m.checkFUSE(supervised) },
+	})
+}
+
+// checkFUSE remounts the FUSE volumes whose helper process has exited, which
+// leaves the mount point returning "transport endpoint is not connected".
+// Failed remounts are retried with exponential backoff.
+func (m *mod) checkFUSE(supervised map[string]*fuseMount) {
+	timeout := health.StatTimeout(m.config)
+	maxBackoff := fuseDuration(
+		m.config, "rexray.volume.fuse.maxBackoff", 5*time.Minute)
+
+	m.mountsRwl.RLock()
+	mounts := map[string][]string{}
+	for volumeID, mountPoints := range m.mounts {
+		mounts[volumeID] = mountPoints
+	}
+	m.mountsRwl.RUnlock()
+
+	seen := map[string]bool{}
+	for volumeID, mountPoints := range mounts {
+		for _, mp := range mountPoints {
+			if !health.IsFUSEMount(mp) {
+				continue
+			}
+			seen[mp] = true
+
+			fm, ok := supervised[mp]
+			if !ok {
+				fm = &fuseMount{helper: health.FUSEHelper(mp)}
+				supervised[mp] = fm
+			}
+
+			crashed := fm.helper != 0 && !health.IsRunning(fm.helper)
+			if !crashed {
+				crashed = health.IsStale(health.Probe(mp, timeout))
+			}
+			if !crashed || time.Now().Before(fm.retryAt) {
+				continue
+			}
+
+			fields := map[string]string{
+				"mountPoint": mp,
+				"helper":     strconv.Itoa(fm.helper),
+			}
+			if fm.failures == 0 {
+				m.emit("fuse.crashed", volumeID,
+					"FUSE mount helper exited", fields)
+			}
+
+			fm.failures++
+			fields["attempt"] = strconv.Itoa(fm.failures)
+
+			if _, err := health.Repair(
+				m.ctx, m.lsc, m.store, volumeID); err != nil {
+				backoff := time.Second << uint(fm.failures-1)
+				if backoff > maxBackoff || backoff <= 0 {
+					backoff = maxBackoff
+				}
+				fm.retryAt = time.Now().Add(backoff)
+				fields["error"] = err.Error()
+				fields["retryIn"] = backoff.String()
+				m.emit("fuse.remountFailed", volumeID,
+					"error remounting FUSE volume", fields)
+				continue
+			}
+
+			m.emit("fuse.remounted", volumeID, "remounted FUSE volume", fields)
+
+			// the remount started a new helper process
+			delete(supervised, mp)
+		}
+	}
+
+	for mp := range supervised {
+		if !seen[mp] {
+			delete(supervised, mp)
+		}
+	}
+}
+
+func (m *mod) emit(
+	eventType, volumeID, msg string, fields map[string]string) {
+
+	if err := events.Emit(m.store, &events.Event{
+		Type:     eventType,
+		VolumeID: volumeID,
+		Message:  msg,
+		Fields:   fields,
+	}); err != nil {
+		m.ctx.WithError(err).Warn("error recording event")
+	}
+}
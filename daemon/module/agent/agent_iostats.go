@@ -0,0 +1,31 @@
+package agent
+
+import (
+	"github.com/emccode/rexray/core/iostats"
+	"github.com/emccode/rexray/core/metrics"
+	"github.com/emccode/rexray/core/schedule"
+)
+
+// sampleIOStats schedules a periodic sample of the I/O statistics of the
+// volumes mounted on this instance until the module is stopped.
+func (m *mod) sampleIOStats() error {
+	return m.sched.Add(&schedule.Job{
+		Name:     ioStatsJob,
+		Schedule: schedule.Every(iostats.Interval(m.config)),
+		Run:      m.ioStats,
+	})
+}
+
+// ioStats samples the devices of the volumes that were mounted as of the
+// last heartbeat and records their statistics as metrics, which the admin
+// module exposes at /metrics and /r/iostats.
+func (m *mod) ioStats() {
+	m.mountsRwl.RLock()
+	devices := m.devices
+	m.mountsRwl.RUnlock()
+
+	if _, err := iostats.Default().Sample(
+		devices, metrics.Default()); err != nil {
+		m.ctx.WithError(err).Warn("error sampling volume io statistics")
+	}
+}
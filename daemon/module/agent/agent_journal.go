@@ -0,0 +1,122 @@
+package agent
+
+import (
+	"os"
+
+	apitypes "github.com/emccode/libstorage/api/types"
+	apiutils "github.com/emccode/libstorage/api/utils"
+
+	"github.com/emccode/rexray/core/health"
+	"github.com/emccode/rexray/core/journal"
+	"github.com/emccode/rexray/core/policy"
+)
+
+// recoverJournal completes or rolls back the mount and unmount operations that
+// were interrupted by a crash. An interrupted mount of a volume that is
+// mounted is considered complete, while an interrupted unmount of a volume
+// that is still mounted is repeated. Otherwise a volume left attached but not
+// mounted is detached if volumes are attached on mount, and the mount points
+// left behind by an unmount are removed if they are empty. The operations of
+// processes that are still running are left alone.
+func (m *mod) recoverJournal() {
+	entries, err := journal.List(m.local)
+	if err != nil {
+		m.ctx.WithError(err).Error("error reading mount journal")
+		return
+	}
+	if len(entries) == 0 {
+		return
+	}
+
+	iid, err := m.lsc.Executor().InstanceID(m.ctx, apiutils.NewStore())
+	if err != nil {
+		m.ctx.WithError(err).Error("error getting instance ID")
+		return
+	}
+
+	mounts, _, err := m.localMounts(iid.ID)
+	if err != nil {
+		m.ctx.WithError(err).Error("error getting mounted volumes")
+		return
+	}
+
+	for _, e := range entries {
+		if e.PID != os.Getpid() && health.IsRunning(e.PID) {
+			continue
+		}
+		m.recoverEntry(iid.ID, e, len(mounts[e.VolumeID]) > 0)
+	}
+}
+
+func (m *mod) recoverEntry(iid string, e *journal.Entry, mounted bool) {
+	fields := map[string]interface{}{
+		"volumeID":  e.VolumeID,
+		"operation": e.Op,
+		"started":   e.Started,
+	}
+	ctx := m.ctx.WithFields(fields)
+
+	var (
+		action string
+		err    error
+	)
+
+	switch {
+	case e.Op == journal.OpMount && mounted:
+		action = "completed"
+	case e.Op == journal.OpUnmount && mounted:
+		action = "unmounted"
+		err = m.lsc.Integration().Unmount(
+			m.ctx, e.VolumeID, "", apiutils.NewStore())
+	default:
+		action, err = m.detachIfAttached(iid, e.VolumeID)
+	}
+
+	if err != nil {
+		ctx.WithError(err).Error("error recovering interrupted operation")
+		m.emit("journal.recoveryFailed", e.VolumeID,
+			"error recovering interrupted "+string(e.Op)+": "+err.Error(),
+			map[string]string{"op": string(e.Op)})
+		return
+	}
+
+	if e.Op == journal.OpUnmount {
+		for _, mp := range e.MountPoints {
+			// only an empty directory is removed
+			os.Remove(mp)
+		}
+	}
+
+	if err := journal.End(m.local, e.VolumeID); err != nil {
+		ctx.WithError(err).Warn("error ending journaled operation")
+	}
+
+	ctx.WithField("action", action).Warn("recovered interrupted operation")
+	m.emit("journal.recovered", e.VolumeID,
+		"recovered interrupted "+string(e.Op),
+		map[string]string{"op": string(e.Op), "action": action})
+}
+
+// detachIfAttached detaches a volume that is attached to this instance if
+// volumes are attached on mount.
+func (m *mod) detachIfAttached(iid, volumeID string) (string, error) {
+	if policy.GetAttachMode(m.config) != policy.AttachOnMount {
+		return "none", nil
+	}
+
+	vol, err := m.lsc.Storage().VolumeInspect(
+		m.ctx, volumeID, &apitypes.VolumeInspectOpts{Attachments: true})
+	if err != nil {
+		return "", err
+	}
+
+	for _, a := range vol.Attachments {
+		if a.InstanceID != nil && a.InstanceID.ID == iid {
+			_, err := m.lsc.Storage().VolumeDetach(
+				m.ctx, volumeID,
+				&apitypes.VolumeDetachOpts{Opts: apiutils.NewStore()})
+			return "detached", err
+		}
+	}
+	return "none", nil
+}
@@ -0,0 +1,204 @@
+package agent
+
+import (
+	"time"
+
+	apitypes "github.com/emccode/libstorage/api/types"
+	apiutils "github.com/emccode/libstorage/api/utils"
+
+	"github.com/emccode/rexray/core/journal"
+	"github.com/emccode/rexray/core/labels"
+	"github.com/emccode/rexray/core/metrics"
+	"github.com/emccode/rexray/core/schedule"
+	"github.com/emccode/rexray/core/scrub"
+)
+
+// idleVolume is a volume that is attached to this instance but not mounted.
+type idleVolume struct {
+	vol    *apitypes.Volume
+	device string
+}
+
+// scheduleScrub schedules the integrity checks of idle volumes until the
+// module is stopped.
+func (m *mod) scheduleScrub() error {
+	spec := m.config.GetString("rexray.volume.scrub.schedule")
+	if spec == "" {
+		return nil
+	}
+	sched, err := schedule.Parse(spec)
+	if err != nil {
+		return err
+	}
+	if _, err := scrub.Policies(m.config); err != nil {
+		return err
+	}
+	return m.sched.Add(&schedule.Job{
+		Name:     scrubJob,
+		Schedule: sched,
+		Jitter:   time.Minute,
+		Persist:  true,
+		Run:      m.scrub,
+	})
+}
+
+// scrub checks the volumes attached to this instance that are not mounted
+// according to the policies that match their labels. A file system can only
+// be checked reliably when it is not mounted, so mounted volumes are never
+// checked.
+func (m *mod) scrub() {
+	iid, err := m.lsc.Executor().InstanceID(m.ctx, apiutils.NewStore())
+	if err != nil {
+		m.ctx.WithError(err).Error("error getting instance ID")
+		return
+	}
+
+	policies, err := scrub.Policies(m.config)
+	if err != nil {
+		m.ctx.WithError(err).Error("error reading scrub policies")
+		return
+	}
+
+	idle, err := m.idleVolumes(iid.ID)
+	if err != nil {
+		m.ctx.WithError(err).Error("error finding idle volumes")
+		return
+	}
+
+	for _, iv := range idle {
+		l, err := labels.Volume(m.store, iv.vol.ID)
+		if err != nil {
+			m.ctx.WithField("volumeID", iv.vol.ID).WithError(err).Error(
+				"error getting volume labels")
+			continue
+		}
+		p := scrub.For(policies, l)
+		r, err := scrub.Get(m.store, iv.vol.ID)
+		if err != nil {
+			m.ctx.WithField("volumeID", iv.vol.ID).WithError(err).Error(
+				"error getting last scrub")
+			continue
+		}
+		if !p.Due(r, time.Now().UTC()) {
+			continue
+		}
+		m.scrubVolume(iid.ID, iv, p)
+	}
+}
+
+// idleVolumes returns the volumes attached to this instance whose devices
+// are not mounted. The volumes of operations that are in progress are
+// ignored.
+func (m *mod) idleVolumes(iid string) ([]*idleVolume, error) {
+	entries, err := journal.List(m.local)
+	if err != nil {
+		return nil, err
+	}
+	busy := map[string]bool{}
+	for _, e := range entries {
+		busy[e.VolumeID] = true
+	}
+
+	vols, err := m.lsc.Storage().Volumes(
+		m.ctx, &apitypes.VolumesOpts{Attachments: true})
+	if err != nil {
+		return nil, err
+	}
+
+	all, err := m.lsc.OS().Mounts(m.ctx, "", "", apiutils.NewStore())
+	if err != nil {
+		return nil, err
+	}
+	mounted := map[string]bool{}
+	for _, mi := range all {
+		mounted[mi.Source] = true
+	}
+
+	idle := []*idleVolume{}
+	for _, v := range vols {
+		if busy[v.ID] {
+			continue
+		}
+		for _, a := range v.Attachments {
+			if a.InstanceID == nil || a.InstanceID.ID != iid ||
+				a.DeviceName == "" || mounted[a.DeviceName] {
+				continue
+			}
+			idle = append(idle, &idleVolume{vol: v, device: a.DeviceName})
+		}
+	}
+	return idle, nil
+}
+
+// scrubVolume runs a policy's checks on an idle volume, records their
+// results, and raises an event for each anomaly or failed check.
+func (m *mod) scrubVolume(iid string, iv *idleVolume, p *scrub.Policy) {
+	timeout := scrub.Timeout(m.config)
+	r := &scrub.Record{Checked: time.Now().UTC(), Results: []*scrub.Result{}}
+
+	for _, check := range p.Checks {
+		var res *scrub.Result
+		switch check {
+		case scrub.CheckFS:
+			res = scrub.FileSystem(iv.device, timeout)
+		case scrub.CheckBackend:
+			res = m.checkBackend(iid, iv.vol.ID)
+		}
+		r.Results = append(r.Results, res)
+		m.recordScrub(iv.vol, p, res)
+	}
+
+	if err := scrub.Set(m.store, iv.vol.ID, r); err != nil {
+		m.ctx.WithField("volumeID", iv.vol.ID).WithError(err).Error(
+			"error recording scrub")
+	}
+}
+
+func (m *mod) checkBackend(iid, volumeID string) *scrub.Result {
+	vol, err := m.lsc.Storage().VolumeInspect(
+		m.ctx, volumeID, &apitypes.VolumeInspectOpts{Attachments: true})
+	if err != nil {
+		return &scrub.Result{
+			Check:  scrub.CheckBackend,
+			Status: scrub.Failed,
+			Reason: err.Error(),
+		}
+	}
+	return scrub.Backend(vol, iid)
+}
+
+func (m *mod) recordScrub(
+	vol *apitypes.Volume, p *scrub.Policy, res *scrub.Result) {
+
+	reg := metrics.Default()
+	reg.Inc(metrics.VolumeScrubChecks, res.Check, string(res.Status))
+
+	fields := map[string]interface{}{
+		"volumeID": vol.ID,
+		"policy":   p.Name,
+		"check":    res.Check,
+		"status":   res.Status,
+		"reason":   res.Reason,
+	}
+	eventFields := map[string]string{
+		"policy": p.Name,
+		"check":  res.Check,
+		"reason": res.Reason,
+	}
+
+	switch res.Status {
+	case scrub.OK:
+		reg.Set(metrics.VolumeScrubAnomaly, 0, vol.Name, res.Check)
+		m.ctx.WithFields(fields).Debug("volume scrubbed")
+	case scrub.Anomaly:
+		reg.Set(metrics.VolumeScrubAnomaly, 1, vol.Name, res.Check)
+		m.ctx.WithFields(fields).Warn("volume scrub found an anomaly")
+		m.emit("scrub.anomaly", vol.ID,
+			"volume scrub found an anomaly", eventFields)
+	case scrub.Failed:
+		m.ctx.WithFields(fields).Error("volume scrub failed")
+		m.emit("scrub.failed", vol.ID, "volume scrub failed", eventFields)
+	case scrub.Skipped:
+		m.ctx.WithFields(fields).Debug("volume scrub skipped")
+	}
+}
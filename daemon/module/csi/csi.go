@@ -0,0 +1,149 @@
+// Package csi serves REX-Ray volumes to container orchestrators using the
+// Container Storage Interface (CSI). It allows the Mesos agent's storage
+// local resource provider to manage volumes without the docker/volume
+// isolator and dvdcli.
+package csi
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/gotil"
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	apitypes "github.com/emccode/libstorage/api/types"
+	"google.golang.org/grpc"
+
+	"github.com/emccode/rexray/core/systemd"
+	"github.com/emccode/rexray/daemon/module"
+)
+
+const (
+	modName = "csi"
+
+	// EndpointEnvVar is the environment variable with which a CO provides
+	// the address at which a CSI plug-in serves.
+	EndpointEnvVar = "CSI_ENDPOINT"
+
+	defaultEndpoint = "unix:///var/run/rexray/csi.sock"
+)
+
+func init() {
+	module.RegisterModule(modName, newModule)
+
+	r := gofig.NewRegistration("CSI")
+	r.Key(gofig.String, "", "com.emccode.rexray",
+		"The name with which the CSI plug-in identifies itself",
+		"rexray.csi.pluginName")
+	gofig.Register(r)
+}
+
+// Endpoint returns the address at which the CSI plug-in serves: the value of
+// CSI_ENDPOINT if it is set, otherwise the default endpoint.
+func Endpoint() string {
+	if v := os.Getenv(EndpointEnvVar); v != "" {
+		return v
+	}
+	return defaultEndpoint
+}
+
+// Server implements the CSI identity, controller, and node services by
+// delegating to a libStorage client.
+type Server struct {
+	ctx    apitypes.Context
+	config gofig.Config
+	lsc    apitypes.Client
+	srv    *grpc.Server
+
+	// targetsLock serializes updates to the paths volumes are published to
+	targetsLock sync.Mutex
+}
+
+// NewServer returns a new CSI server.
+func NewServer(
+	ctx apitypes.Context,
+	config gofig.Config,
+	client apitypes.Client) *Server {
+
+	return &Server{ctx: ctx, config: config, lsc: client}
+}
+
+// Serve serves the CSI services at the provided address until the server is
+// stopped.
+func (s *Server) Serve(addr string) error {
+	proto, laddr, err := gotil.ParseAddress(addr)
+	if err != nil {
+		return err
+	}
+
+	if proto == "unix" {
+		os.MkdirAll(filepath.Dir(laddr), 0755)
+	}
+
+	l, _, err := systemd.Listen(proto, laddr)
+	if err != nil {
+		return err
+	}
+
+	s.srv = grpc.NewServer()
+	csi.RegisterIdentityServer(s.srv, s)
+	csi.RegisterControllerServer(s.srv, s)
+	csi.RegisterNodeServer(s.srv, s)
+
+	s.ctx.WithField("address", addr).Info("serving CSI plug-in")
+	return s.srv.Serve(l)
+}
+
+// Stop stops the server.
+func (s *Server) Stop() {
+	if s.srv != nil {
+		s.srv.GracefulStop()
+	}
+}
+
+type mod struct {
+	name   string
+	addr   string
+	desc   string
+	server *Server
+}
+
+func newModule(ctx apitypes.Context, c *module.Config) (module.Module, error) {
+	addr := c.Address
+	if addr == "" {
+		addr = Endpoint()
+	}
+	return &mod{
+		name:   c.Name,
+		addr:   addr,
+		desc:   c.Description,
+		server: NewServer(ctx, c.Config, c.Client),
+	}, nil
+}
+
+func (m *mod) Start() error {
+	go func() {
+		if err := m.server.Serve(m.addr); err != nil {
+			panic(err)
+		}
+	}()
+	return nil
+}
+
+func (m *mod) Stop() error {
+	m.server.Stop()
+	return nil
+}
+
+func (m *mod) Name() string {
+	return m.name
+}
+
+func (m *mod) Description() string {
+	return m.desc
+}
+
+func (m *mod) Address() string {
+	return m.addr
+}
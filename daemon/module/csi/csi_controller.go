@@ -0,0 +1,407 @@
+package csi
+
+import (
+	"math"
+	"strings"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	apitypes "github.com/emccode/libstorage/api/types"
+	apiutils "github.com/emccode/libstorage/api/utils"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+
+	"github.com/emccode/rexray/core/capacity"
+	"github.com/emccode/rexray/core/policy"
+	"github.com/emccode/rexray/core/state"
+	"github.com/emccode/rexray/core/topology"
+)
+
+const gib = 1024 * 1024 * 1024
+
+// toStatus converts a libStorage error into a gRPC status error.
+func toStatus(err error) error {
+	if strings.Contains(strings.ToLower(err.Error()), "not found") {
+		return grpc.Errorf(codes.NotFound, "%v", err)
+	}
+	return grpc.Errorf(codes.Internal, "%v", err)
+}
+
+// sizeGiB returns the size in GiB of a volume that satisfies the provided
+// capacity range. libStorage sizes volumes in whole GiB, so the required size
+// is rounded up.
+func sizeGiB(cr *csi.CapacityRange) (int64, error) {
+	if cr == nil {
+		return 0, nil
+	}
+	size := (cr.RequiredBytes + gib - 1) / gib
+	if cr.LimitBytes > 0 && size*gib > cr.LimitBytes {
+		return 0, grpc.Errorf(codes.OutOfRange,
+			"no whole GiB size satisfies the capacity range")
+	}
+	return size, nil
+}
+
+// accessMode returns the access mode that corresponds to a CSI access mode
+// and a flag indicating whether the CSI access mode is read-only.
+func accessMode(
+	m csi.VolumeCapability_AccessMode_Mode) (policy.AccessMode, bool, error) {
+
+	switch m {
+	case csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER:
+		return policy.ReadWriteOnce, false, nil
+	case csi.VolumeCapability_AccessMode_SINGLE_NODE_READER_ONLY:
+		return policy.ReadWriteOnce, true, nil
+	case csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY:
+		return policy.ReadOnlyMany, true, nil
+	case csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER:
+		return policy.ReadWriteMany, false, nil
+	}
+	return "", false, grpc.Errorf(codes.InvalidArgument,
+		"access mode %s is not supported", m)
+}
+
+// validateCapabilities returns an error if any of the provided capabilities
+// cannot be satisfied by the provided access modes, and otherwise the
+// widest access mode the capabilities request. A volume exposed as a block
+// device may not be read-only.
+func validateCapabilities(
+	caps []*csi.VolumeCapability,
+	supported []policy.AccessMode) (policy.AccessMode, error) {
+
+	widest := policy.ReadWriteOnce
+	for _, c := range caps {
+		m, readOnly, err := accessMode(c.GetAccessMode().GetMode())
+		if err != nil {
+			return "", err
+		}
+		if c.GetBlock() != nil && readOnly {
+			return "", grpc.Errorf(codes.InvalidArgument,
+				"read-only block access is not supported")
+		}
+		permitted := false
+		for _, s := range supported {
+			if s.Permits(m, readOnly) {
+				permitted = true
+				break
+			}
+		}
+		if !permitted {
+			return "", grpc.Errorf(codes.InvalidArgument,
+				"access mode %s is not supported by %v",
+				c.GetAccessMode().GetMode(), supported)
+		}
+		if m == policy.ReadWriteMany ||
+			(m == policy.ReadOnlyMany && widest == policy.ReadWriteOnce) {
+			widest = m
+		}
+	}
+	return widest, nil
+}
+
+// accessModes returns the access mode recorded for the volume with the
+// provided ID, or if it has none or no ID is provided, the access modes the
+// service's driver supports.
+func (s *Server) accessModes(volumeID string) ([]policy.AccessMode, error) {
+	if volumeID != "" {
+		m, ok, err := policy.GetVolumeAccessMode(
+			state.Controller(), volumeID)
+		if err != nil {
+			return nil, grpc.Errorf(codes.Internal, "%v", err)
+		}
+		if ok {
+			return []policy.AccessMode{m}, nil
+		}
+	}
+	modes, err := policy.ServiceAccessModes(s.ctx, s.config, s.lsc)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return modes, nil
+}
+
+func toCSIVolume(v *apitypes.Volume) *csi.Volume {
+	return &csi.Volume{
+		VolumeId:      v.ID,
+		CapacityBytes: v.Size * gib,
+		VolumeContext: map[string]string{"name": v.Name},
+	}
+}
+
+func (s *Server) CreateVolume(
+	ctx context.Context,
+	req *csi.CreateVolumeRequest) (*csi.CreateVolumeResponse, error) {
+
+	if req.Name == "" {
+		return nil, grpc.Errorf(codes.InvalidArgument, "missing name")
+	}
+	supported, err := s.accessModes("")
+	if err != nil {
+		return nil, err
+	}
+	access, err := validateCapabilities(req.VolumeCapabilities, supported)
+	if err != nil {
+		return nil, err
+	}
+	size, err := sizeGiB(req.CapacityRange)
+	if err != nil {
+		return nil, err
+	}
+
+	// creating a volume is idempotent
+	vols, err := s.lsc.Storage().Volumes(
+		s.ctx, &apitypes.VolumesOpts{Attachments: false})
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	for _, v := range vols {
+		if v.Name != req.Name {
+			continue
+		}
+		if size > 0 && v.Size < size {
+			return nil, grpc.Errorf(codes.AlreadyExists,
+				"volume %s exists with a smaller size", req.Name)
+		}
+		return &csi.CreateVolumeResponse{
+			Volume: s.toCSIVolumeWithTopology(v)}, nil
+	}
+
+	opts := &apitypes.VolumeCreateOpts{Opts: apiutils.NewStore()}
+	opts.Opts.Set(policy.AccessModeKey, string(access))
+	topologyRequirement(req.AccessibilityRequirements).SetOpts(opts.Opts)
+	if size > 0 {
+		opts.Size = &size
+	}
+	for k, v := range req.Parameters {
+		switch k {
+		case "type":
+			t := v
+			opts.Type = &t
+		case "availabilityZone":
+			az := v
+			opts.AvailabilityZone = &az
+		default:
+			opts.Opts.Set(k, v)
+		}
+	}
+
+	v, err := s.lsc.Storage().VolumeCreate(s.ctx, req.Name, opts)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &csi.CreateVolumeResponse{
+		Volume: s.toCSIVolumeWithTopology(v)}, nil
+}
+
+func (s *Server) DeleteVolume(
+	ctx context.Context,
+	req *csi.DeleteVolumeRequest) (*csi.DeleteVolumeResponse, error) {
+
+	if req.VolumeId == "" {
+		return nil, grpc.Errorf(codes.InvalidArgument, "missing volume ID")
+	}
+
+	err := s.lsc.Storage().VolumeRemove(
+		s.ctx, req.VolumeId, apiutils.NewStore())
+	if err != nil {
+		// deleting a volume that does not exist succeeds
+		if grpc.Code(toStatus(err)) == codes.NotFound {
+			return &csi.DeleteVolumeResponse{}, nil
+		}
+		return nil, toStatus(err)
+	}
+	return &csi.DeleteVolumeResponse{}, nil
+}
+
+// ControllerPublishVolume is not supported. Volumes are attached by the node
+// service when they are published since libStorage attaches volumes to the
+// instance on which the client is running.
+func (s *Server) ControllerPublishVolume(
+	ctx context.Context,
+	req *csi.ControllerPublishVolumeRequest) (
+	*csi.ControllerPublishVolumeResponse, error) {
+
+	return nil, grpc.Errorf(codes.Unimplemented, "")
+}
+
+func (s *Server) ControllerUnpublishVolume(
+	ctx context.Context,
+	req *csi.ControllerUnpublishVolumeRequest) (
+	*csi.ControllerUnpublishVolumeResponse, error) {
+
+	return nil, grpc.Errorf(codes.Unimplemented, "")
+}
+
+func (s *Server) ValidateVolumeCapabilities(
+	ctx context.Context,
+	req *csi.ValidateVolumeCapabilitiesRequest) (
+	*csi.ValidateVolumeCapabilitiesResponse, error) {
+
+	if _, err := s.lsc.Storage().VolumeInspect(
+		s.ctx, req.VolumeId, &apitypes.VolumeInspectOpts{}); err != nil {
+		return nil, toStatus(err)
+	}
+	supported, err := s.accessModes(req.VolumeId)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := validateCapabilities(
+		req.VolumeCapabilities, supported); err != nil {
+		return &csi.ValidateVolumeCapabilitiesResponse{
+			Message: grpc.ErrorDesc(err),
+		}, nil
+	}
+	return &csi.ValidateVolumeCapabilitiesResponse{
+		Confirmed: &csi.ValidateVolumeCapabilitiesResponse_Confirmed{
+			VolumeCapabilities: req.VolumeCapabilities,
+		},
+	}, nil
+}
+
+func (s *Server) ListVolumes(
+	ctx context.Context,
+	req *csi.ListVolumesRequest) (*csi.ListVolumesResponse, error) {
+
+	vols, err := s.lsc.Storage().Volumes(
+		s.ctx, &apitypes.VolumesOpts{Attachments: false})
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	resp := &csi.ListVolumesResponse{}
+	for _, v := range vols {
+		resp.Entries = append(resp.Entries, &csi.ListVolumesResponse_Entry{
+			Volume: toCSIVolume(v),
+		})
+	}
+	return resp, nil
+}
+
+// topologySegment returns the segments of a CSI topology keyed without the
+// prefixes of their keys, ex. com.emccode.rexray/zone is keyed as zone.
+func topologySegment(t *csi.Topology) topology.Segment {
+	if t == nil {
+		return nil
+	}
+	seg := topology.Segment{}
+	for k, v := range t.Segments {
+		seg[k[strings.LastIndex(k, "/")+1:]] = v
+	}
+	return seg
+}
+
+// toCSITopology returns a CSI topology whose keys are prefixed with the
+// plug-in's name.
+func (s *Server) toCSITopology(seg topology.Segment) *csi.Topology {
+	prefix := s.config.GetString("rexray.csi.pluginName") + "/"
+	t := &csi.Topology{Segments: map[string]string{}}
+	for k, v := range seg {
+		t.Segments[prefix+k] = v
+	}
+	return t
+}
+
+// topologyRequirement returns the requirement with which a volume is created
+// for CSI accessibility requirements.
+func topologyRequirement(
+	ar *csi.TopologyRequirement) *topology.Requirement {
+
+	r := &topology.Requirement{}
+	for _, t := range ar.GetRequisite() {
+		r.Requisite = append(r.Requisite, topologySegment(t))
+	}
+	for _, t := range ar.GetPreferred() {
+		r.Preferred = append(r.Preferred, topologySegment(t))
+	}
+	return r
+}
+
+// toCSIVolumeWithTopology returns a CSI volume that is accessible from the
+// segment in which it was created, or from its availability zone if the
+// segment was not recorded.
+func (s *Server) toCSIVolumeWithTopology(v *apitypes.Volume) *csi.Volume {
+	cv := toCSIVolume(v)
+	seg, ok, err := topology.Volume(state.Default(), v.ID)
+	if err != nil {
+		s.ctx.WithError(err).WithField("volumeID", v.ID).Warn(
+			"error reading volume topology")
+	}
+	if !ok && v.AvailabilityZone != "" {
+		seg = topology.Segment{topology.Zone: v.AvailabilityZone}
+	}
+	if len(seg) > 0 {
+		cv.AccessibleTopology = []*csi.Topology{s.toCSITopology(seg)}
+	}
+	return cv
+}
+
+// GetCapacity returns the capacity available in the requested topology
+// segment. Capacity that is not limited by a pool is reported as the largest
+// possible size, and no capacity is available for volume capabilities the
+// service does not support.
+func (s *Server) GetCapacity(
+	ctx context.Context,
+	req *csi.GetCapacityRequest) (*csi.GetCapacityResponse, error) {
+
+	if len(req.VolumeCapabilities) > 0 {
+		supported, err := s.accessModes("")
+		if err != nil {
+			return nil, err
+		}
+		if _, err := validateCapabilities(
+			req.VolumeCapabilities, supported); err != nil {
+			return &csi.GetCapacityResponse{}, nil
+		}
+	}
+
+	all, err := capacity.Get(s.ctx, s.config, s.lsc, "")
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	avail, unlimited := capacity.Available(
+		all, topologySegment(req.AccessibleTopology))
+	if unlimited {
+		avail = math.MaxInt64
+	}
+	return &csi.GetCapacityResponse{AvailableCapacity: avail}, nil
+}
+
+func (s *Server) ControllerGetCapabilities(
+	ctx context.Context,
+	req *csi.ControllerGetCapabilitiesRequest) (
+	*csi.ControllerGetCapabilitiesResponse, error) {
+
+	caps := []*csi.ControllerServiceCapability{}
+	for _, t := range []csi.ControllerServiceCapability_RPC_Type{
+		csi.ControllerServiceCapability_RPC_CREATE_DELETE_VOLUME,
+		csi.ControllerServiceCapability_RPC_LIST_VOLUMES,
+		csi.ControllerServiceCapability_RPC_GET_CAPACITY,
+	} {
+		caps = append(caps, &csi.ControllerServiceCapability{
+			Type: &csi.ControllerServiceCapability_Rpc{
+				Rpc: &csi.ControllerServiceCapability_RPC{Type: t},
+			},
+		})
+	}
+	return &csi.ControllerGetCapabilitiesResponse{Capabilities: caps}, nil
+}
+
+func (s *Server) CreateSnapshot(
+	ctx context.Context,
+	req *csi.CreateSnapshotRequest) (*csi.CreateSnapshotResponse, error) {
+
+	return nil, grpc.Errorf(codes.Unimplemented, "")
+}
+
+func (s *Server) DeleteSnapshot(
+	ctx context.Context,
+	req *csi.DeleteSnapshotRequest) (*csi.DeleteSnapshotResponse, error) {
+
+	return nil, grpc.Errorf(codes.Unimplemented, "")
+}
+
+func (s *Server) ListSnapshots(
+	ctx context.Context,
+	req *csi.ListSnapshotsRequest) (*csi.ListSnapshotsResponse, error) {
+
+	return nil, grpc.Errorf(codes.Unimplemented, "")
+}
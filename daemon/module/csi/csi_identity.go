@@ -0,0 +1,58 @@
+package csi
+
+import (
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"golang.org/x/net/context"
+
+	"github.com/emccode/rexray/core"
+)
+
+func (s *Server) GetPluginInfo(
+	ctx context.Context,
+	req *csi.GetPluginInfoRequest) (*csi.GetPluginInfoResponse, error) {
+
+	resp := &csi.GetPluginInfoResponse{
+		Name: s.config.GetString("rexray.csi.pluginName"),
+	}
+	if core.Version != nil {
+		resp.VendorVersion = core.Version.SemVer
+	}
+	return resp, nil
+}
+
+func (s *Server) GetPluginCapabilities(
+	ctx context.Context,
+	req *csi.GetPluginCapabilitiesRequest) (
+	*csi.GetPluginCapabilitiesResponse, error) {
+
+	return &csi.GetPluginCapabilitiesResponse{
+		Capabilities: []*csi.PluginCapability{
+			{
+				Type: &csi.PluginCapability_Service_{
+					Service: &csi.PluginCapability_Service{
+						Type: csi.PluginCapability_Service_CONTROLLER_SERVICE,
+					},
+				},
+			},
+			{
+				Type: &csi.PluginCapability_Service_{
+					Service: &csi.PluginCapability_Service{
+						Type: csi.PluginCapability_Service_VOLUME_ACCESSIBILITY_CONSTRAINTS,
+					},
+				},
+			},
+		},
+	}, nil
+}
+
+// Probe reports the plug-in as ready if the libStorage server can be
+// reached.
+func (s *Server) Probe(
+	ctx context.Context,
+	req *csi.ProbeRequest) (*csi.ProbeResponse, error) {
+
+	if _, err := s.lsc.API().Services(s.ctx); err != nil {
+		return nil, toStatus(err)
+	}
+	return &csi.ProbeResponse{}, nil
+}
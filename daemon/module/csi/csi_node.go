@@ -0,0 +1,228 @@
+package csi
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	apitypes "github.com/emccode/libstorage/api/types"
+	apiutils "github.com/emccode/libstorage/api/utils"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+
+	"github.com/emccode/rexray/core/policy"
+	"github.com/emccode/rexray/core/state"
+	"github.com/emccode/rexray/core/topology"
+)
+
+// targetsBucket records the paths to which each volume is published so that
+// a volume is only unmounted once it is no longer published anywhere. The
+// paths are those of this node, so they are kept in its local store.
+const targetsBucket = "csiTargets"
+
+func (s *Server) NodeStageVolume(
+	ctx context.Context,
+	req *csi.NodeStageVolumeRequest) (*csi.NodeStageVolumeResponse, error) {
+
+	return nil, grpc.Errorf(codes.Unimplemented, "")
+}
+
+func (s *Server) NodeUnstageVolume(
+	ctx context.Context,
+	req *csi.NodeUnstageVolumeRequest) (
+	*csi.NodeUnstageVolumeResponse, error) {
+
+	return nil, grpc.Errorf(codes.Unimplemented, "")
+}
+
+// NodePublishVolume attaches and mounts a volume with libStorage and then
+// bind mounts the volume's mount path to the target path. A volume published
+// with block access is not mounted; its device is bind mounted to the target
+// path instead.
+func (s *Server) NodePublishVolume(
+	ctx context.Context,
+	req *csi.NodePublishVolumeRequest) (
+	*csi.NodePublishVolumeResponse, error) {
+
+	if req.VolumeId == "" || req.TargetPath == "" {
+		return nil, grpc.Errorf(codes.InvalidArgument,
+			"missing volume ID or target path")
+	}
+	supported, err := s.accessModes(req.VolumeId)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := validateCapabilities(
+		[]*csi.VolumeCapability{req.VolumeCapability},
+		supported); err != nil {
+		return nil, err
+	}
+
+	if mounted, err := isMountPoint(req.TargetPath); err != nil {
+		return nil, grpc.Errorf(codes.Internal, "%v", err)
+	} else if mounted {
+		return &csi.NodePublishVolumeResponse{}, nil
+	}
+
+	_, readOnly, _ := accessMode(
+		req.VolumeCapability.GetAccessMode().GetMode())
+	readOnly = readOnly || req.Readonly
+
+	opts := &apitypes.VolumeMountOpts{Opts: apiutils.NewStore()}
+	if mnt := req.VolumeCapability.GetMount(); mnt != nil {
+		opts.NewFSType = mnt.FsType
+	}
+	block := req.VolumeCapability.GetBlock() != nil
+	if block {
+		opts.Opts.Set(policy.VolumeModeKey, policy.VolumeModeBlock)
+	}
+	if readOnly {
+		opts.Opts.Set(policy.ReadOnlyKey, true)
+	}
+
+	mountPath, _, err := s.lsc.Integration().Mount(
+		s.ctx, req.VolumeId, "", opts)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	source := mountPath
+	if block {
+		source = policy.BlockDevicePath(mountPath)
+		if err := createFile(req.TargetPath); err != nil {
+			return nil, grpc.Errorf(codes.Internal, "%v", err)
+		}
+	} else if err := os.MkdirAll(req.TargetPath, 0750); err != nil {
+		return nil, grpc.Errorf(codes.Internal, "%v", err)
+	}
+	if err := bindMount(source, req.TargetPath, readOnly); err != nil {
+		return nil, grpc.Errorf(codes.Internal, "%v", err)
+	}
+
+	if err := s.setTargets(req.VolumeId, func(targets []string) []string {
+		return append(targets, req.TargetPath)
+	}); err != nil {
+		return nil, grpc.Errorf(codes.Internal, "%v", err)
+	}
+	return &csi.NodePublishVolumeResponse{}, nil
+}
+
+// NodeUnpublishVolume removes the bind mount at the target path and, if the
+// volume is not published to any other path, unmounts the volume.
+func (s *Server) NodeUnpublishVolume(
+	ctx context.Context,
+	req *csi.NodeUnpublishVolumeRequest) (
+	*csi.NodeUnpublishVolumeResponse, error) {
+
+	if req.VolumeId == "" || req.TargetPath == "" {
+		return nil, grpc.Errorf(codes.InvalidArgument,
+			"missing volume ID or target path")
+	}
+
+	mounted, err := isMountPoint(req.TargetPath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, grpc.Errorf(codes.Internal, "%v", err)
+	}
+	if mounted {
+		if err := unmount(req.TargetPath); err != nil {
+			return nil, grpc.Errorf(codes.Internal, "%v", err)
+		}
+	}
+
+	remaining := 0
+	if err := s.setTargets(req.VolumeId, func(targets []string) []string {
+		kept := []string{}
+		for _, t := range targets {
+			if t != req.TargetPath {
+				kept = append(kept, t)
+			}
+		}
+		remaining = len(kept)
+		return kept
+	}); err != nil {
+		return nil, grpc.Errorf(codes.Internal, "%v", err)
+	}
+
+	if remaining == 0 {
+		if err := s.lsc.Integration().Unmount(
+			s.ctx, req.VolumeId, "", apiutils.NewStore()); err != nil {
+			return nil, toStatus(err)
+		}
+	}
+	return &csi.NodeUnpublishVolumeResponse{}, nil
+}
+
+func (s *Server) NodeGetVolumeStats(
+	ctx context.Context,
+	req *csi.NodeGetVolumeStatsRequest) (
+	*csi.NodeGetVolumeStatsResponse, error) {
+
+	return nil, grpc.Errorf(codes.Unimplemented, "")
+}
+
+func (s *Server) NodeGetCapabilities(
+	ctx context.Context,
+	req *csi.NodeGetCapabilitiesRequest) (
+	*csi.NodeGetCapabilitiesResponse, error) {
+
+	return &csi.NodeGetCapabilitiesResponse{}, nil
+}
+
+func (s *Server) NodeGetInfo(
+	ctx context.Context,
+	req *csi.NodeGetInfoRequest) (*csi.NodeGetInfoResponse, error) {
+
+	iid, err := s.lsc.Executor().InstanceID(s.ctx, apiutils.NewStore())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	resp := &csi.NodeGetInfoResponse{NodeId: iid.ID}
+
+	// the node's topology is used by the CO to request that volumes be
+	// created where the node can reach them
+	driver, err := policy.ServiceDriverName(s.ctx, s.config, s.lsc)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	seg, err := topology.Local(s.ctx, s.config, s.lsc, driver)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	if len(seg) > 0 {
+		resp.AccessibleTopology = s.toCSITopology(seg)
+	}
+	return resp, nil
+}
+
+// createFile creates the file to which a block volume's device is bind
+// mounted.
+func createFile(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDONLY, 0600)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// setTargets updates the recorded paths to which a volume is published.
+func (s *Server) setTargets(
+	volumeID string, update func(targets []string) []string) error {
+
+	s.targetsLock.Lock()
+	defer s.targetsLock.Unlock()
+
+	store := state.Local()
+	targets := []string{}
+	if _, err := store.Get(targetsBucket, volumeID, &targets); err != nil {
+		return err
+	}
+	targets = update(targets)
+	if len(targets) == 0 {
+		return store.Delete(targetsBucket, volumeID)
+	}
+	return store.Set(targetsBucket, volumeID, targets)
+}
@@ -0,0 +1,53 @@
+package csi
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// isMountPoint returns a flag indicating whether or not a file system is
+// mounted at the provided path.
+func isMountPoint(path string) (bool, error) {
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	path = filepath.Clean(path)
+
+	f, err := os.Open("/proc/self/mounts")
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[1] == path {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
+
+// bindMount mounts the source path at the target path, read-only if
+// requested. A bind mount ignores the read-only flag until it is remounted.
+func bindMount(source, target string, readOnly bool) error {
+	if err := syscall.Mount(source, target, "", syscall.MS_BIND, ""); err != nil {
+		return err
+	}
+	if !readOnly {
+		return nil
+	}
+	return syscall.Mount("", target, "",
+		syscall.MS_BIND|syscall.MS_REMOUNT|syscall.MS_RDONLY, "")
+}
+
+func unmount(target string) error {
+	return syscall.Unmount(target, 0)
+}
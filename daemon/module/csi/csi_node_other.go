@@ -0,0 +1,21 @@
+// +build !linux
+
+package csi
+
+import (
+	"github.com/akutz/goof"
+)
+
+var errUnsupported = goof.New("CSI node service is only supported on Linux")
+
+func isMountPoint(path string) (bool, error) {
+	return false, errUnsupported
+}
+
+func bindMount(source, target string, readOnly bool) error {
+	return errUnsupported
+}
+
+func unmount(target string) error {
+	return errUnsupported
+}
@@ -3,13 +3,14 @@ package volumedriver
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
-	"net"
 	"net/http"
 	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/akutz/gofig"
@@ -18,6 +19,10 @@ import (
 	apitypes "github.com/emccode/libstorage/api/types"
 	apiutils "github.com/emccode/libstorage/api/utils"
 
+	"github.com/emccode/rexray/core/state"
+	"github.com/emccode/rexray/core/systemd"
+	"github.com/emccode/rexray/core/timeouts"
+	"github.com/emccode/rexray/core/tracing"
 	"github.com/emccode/rexray/daemon/module"
 )
 
@@ -32,6 +37,18 @@ type mod struct {
 	name   string
 	addr   string
 	desc   string
+	store  *state.Store
+
+	// local is the store of the host's own data, such as the volumes' mount
+	// references
+	local *state.Store
+
+	// refsLock serializes updates to the volumes' mount references
+	refsLock sync.Mutex
+
+	// mountTimes are the times at which each volume was last requested to
+	// be mounted, keyed by volume name; guarded by refsLock
+	mountTimes map[string]time.Time
 }
 
 var (
@@ -71,6 +88,8 @@ func newModule(ctx apitypes.Context, c *module.Config) (module.Module, error) {
 		name:   c.Name,
 		desc:   c.Description,
 		addr:   host,
+		store:  state.Default(),
+		local:  state.Local(),
 	}, nil
 }
 
@@ -93,6 +112,10 @@ var (
 type pluginRequest struct {
 	Name string            `json:"Name,omitempty"`
 	Opts map[string]string `json:"Opts,omitempty"`
+
+	// ID identifies the caller of a Mount request and the matching Unmount
+	// request.
+	ID string `json:"ID,omitempty"`
 }
 
 func (m *mod) Start() error {
@@ -136,30 +159,33 @@ func (m *mod) Start() error {
 			return mkSockFileDirErr
 		}
 
-		_ = os.RemoveAll(sockFile)
-
 		specPath = m.Address()
 		startFunc = func() error {
-			l, lErr := net.Listen("unix", sockFile)
+			l, inherited, lErr := systemd.Listen("unix", sockFile)
 			if lErr != nil {
 				return lErr
 			}
 			defer l.Close()
-			defer os.Remove(sockFile)
+			if !inherited {
+				defer os.Remove(sockFile)
+			}
 
 			return http.Serve(l, mux)
 		}
 	} else {
 		specPath = addr
 		startFunc = func() error {
+			l, _, lErr := systemd.Listen(proto, addr)
+			if lErr != nil {
+				return lErr
+			}
 			s := &http.Server{
-				Addr:           addr,
-				Handler:        mux,
+				Handler:        tracing.Handler(mux, "docker"),
 				ReadTimeout:    10 * time.Second,
 				WriteTimeout:   10 * time.Second,
 				MaxHeaderBytes: 1 << 20,
 			}
-			return s.ListenAndServe()
+			return s.Serve(l)
 		}
 	}
 
@@ -170,6 +196,16 @@ func (m *mod) Start() error {
 		}
 	}()
 
+	for _, sock := range m.config.GetStringSlice("sockets") {
+		if err := m.serveSocket(sock, mux); err != nil {
+			return err
+		}
+	}
+
+	if err := m.startReconciler(); err != nil {
+		return err
+	}
+
 	spec := m.config.GetString("spec")
 	if spec == "" {
 		if m.name == "default-docker" {
@@ -191,6 +227,37 @@ func (m *mod) Start() error {
 	return nil
 }
 
+// serveSocket serves the plug-in on an additional UNIX socket so that it may
+// be registered under other names or in the directories searched by other
+// consumers of the Docker volume plug-in protocol, such as Podman.
+func (m *mod) serveSocket(sock string, handler http.Handler) error {
+	sockFile := strings.TrimPrefix(sock, "unix://")
+	if err := os.MkdirAll(filepath.Dir(sockFile), 0755); err != nil {
+		return err
+	}
+
+	l, inherited, err := systemd.Listen("unix", sockFile)
+	if err != nil {
+		return goof.WithFieldE("sock", sockFile, "error listening", err)
+	}
+
+	m.ctx.WithFields(map[string]interface{}{
+		"sock":      sockFile,
+		"inherited": inherited,
+	}).Info("serving docker voldriver socket")
+
+	go func() {
+		if !inherited {
+			defer os.Remove(sockFile)
+		}
+		if err := http.Serve(l, handler); err != nil {
+			m.ctx.WithField("sock", sockFile).WithError(err).Error(
+				"error serving docker voldriver socket")
+		}
+	}()
+	return nil
+}
+
 func (m *mod) Stop() error {
 	return nil
 }
@@ -220,8 +287,8 @@ func (m *mod) buildMux() *http.ServeMux {
 
 	mux.HandleFunc("/VolumeDriver.Create", func(w http.ResponseWriter, r *http.Request) {
 		var pr pluginRequest
-		if err := json.NewDecoder(r.Body).Decode(&pr); err != nil {
-			http.Error(w, fmt.Sprintf("{\"Error\":\"%s\"}", err.Error()), 500)
+		if err := decodeRequest(r, &pr); err != nil {
+			writeError(w, err, 500)
 			m.ctx.WithError(err).Error("/VolumeDriver.Create: error decoding json")
 			return
 		}
@@ -233,7 +300,7 @@ func (m *mod) buildMux() *http.ServeMux {
 			vtype = store.GetStringPtr("volumetype")
 		}
 		_, err := m.lsc.Integration().Create(
-			m.ctx,
+			m.context(r),
 			pr.Name,
 			&apitypes.VolumeCreateOpts{
 				AvailabilityZone: store.GetStringPtr("availabilityZone"),
@@ -244,7 +311,7 @@ func (m *mod) buildMux() *http.ServeMux {
 			})
 
 		if err != nil {
-			http.Error(w, fmt.Sprintf("{\"Error\":\"%s\"}", err.Error()), 500)
+			writeError(w, err, errorStatus(err))
 			m.ctx.WithError(err).Error("/VolumeDriver.Create: error creating volume")
 			return
 		}
@@ -255,8 +322,8 @@ func (m *mod) buildMux() *http.ServeMux {
 
 	mux.HandleFunc("/VolumeDriver.Remove", func(w http.ResponseWriter, r *http.Request) {
 		var pr pluginRequest
-		if err := json.NewDecoder(r.Body).Decode(&pr); err != nil {
-			http.Error(w, fmt.Sprintf("{\"Error\":\"%s\"}", err.Error()), 500)
+		if err := decodeRequest(r, &pr); err != nil {
+			writeError(w, err, 500)
 			m.ctx.WithError(err).Error("/VolumeDriver.Remove: error decoding json")
 			return
 		}
@@ -264,9 +331,9 @@ func (m *mod) buildMux() *http.ServeMux {
 		m.ctx.WithField("pluginResponse", pr).Debug("/VolumeDriver.Remove")
 
 		// TODO We need the service name
-		err := m.lsc.Integration().Remove(m.ctx, pr.Name, apiutils.NewStore())
+		err := m.lsc.Integration().Remove(m.context(r), pr.Name, apiutils.NewStore())
 		if err != nil {
-			http.Error(w, fmt.Sprintf("{\"Error\":\"%s\"}", err.Error()), 500)
+			writeError(w, err, errorStatus(err))
 			m.ctx.WithError(err).Error("/VolumeDriver.Remove: error removing volume")
 			return
 		}
@@ -277,8 +344,8 @@ func (m *mod) buildMux() *http.ServeMux {
 
 	mux.HandleFunc("/VolumeDriver.Path", func(w http.ResponseWriter, r *http.Request) {
 		var pr pluginRequest
-		if err := json.NewDecoder(r.Body).Decode(&pr); err != nil {
-			http.Error(w, fmt.Sprintf("{\"Error\":\"%s\"}", err.Error()), 500)
+		if err := decodeRequest(r, &pr); err != nil {
+			writeError(w, err, 500)
 			m.ctx.WithError(err).Error("/VolumeDriver.Path: error decoding json")
 			return
 		}
@@ -286,9 +353,9 @@ func (m *mod) buildMux() *http.ServeMux {
 		m.ctx.WithField("pluginResponse", pr).Debug("/VolumeDriver.Path")
 
 		mountPath, err := m.lsc.Integration().Path(
-			m.ctx, "", pr.Name, apiutils.NewStore())
+			m.context(r), "", pr.Name, apiutils.NewStore())
 		if err != nil {
-			http.Error(w, fmt.Sprintf("{\"Error\":\"%s\"}", err.Error()), 500)
+			writeError(w, err, errorStatus(err))
 			m.ctx.WithError(err).Error("/VolumeDriver.Path: error returning path")
 			return
 		}
@@ -299,40 +366,68 @@ func (m *mod) buildMux() *http.ServeMux {
 
 	mux.HandleFunc("/VolumeDriver.Mount", func(w http.ResponseWriter, r *http.Request) {
 		var pr pluginRequest
-		if err := json.NewDecoder(r.Body).Decode(&pr); err != nil {
-			http.Error(w, fmt.Sprintf("{\"Error\":\"%s\"}", err.Error()), 500)
+		if err := decodeRequest(r, &pr); err != nil {
+			writeError(w, err, 500)
 			m.ctx.WithError(err).Error("/VolumeDriver.Mount: error decoding json")
 			return
 		}
 
 		m.ctx.WithField("pluginResponse", pr).Debug("/VolumeDriver.Mount")
 
+		m.noteMount(pr.Name)
 		mountPath, _, err := m.lsc.Integration().Mount(
-			m.ctx, "", pr.Name, &apitypes.VolumeMountOpts{})
+			m.context(r), "", pr.Name, &apitypes.VolumeMountOpts{})
 		if err != nil {
-			http.Error(w, fmt.Sprintf("{\"Error\":\"%s\"}", err.Error()), 500)
+			writeError(w, err, errorStatus(err))
 			m.ctx.WithError(err).Error("/VolumeDriver.Mount: error mounting volume")
 			return
 		}
 
+		if pr.ID != "" {
+			if _, err := m.addMountRef(pr.Name, pr.ID); err != nil {
+				m.ctx.WithError(err).Warn(
+					"/VolumeDriver.Mount: error recording mount reference")
+			}
+		}
+
 		w.Header().Set("Content-Type", "application/vnd.docker.plugins.v1.2+json")
 		fmt.Fprintln(w, fmt.Sprintf("{\"Mountpoint\": \"%s\"}", mountPath))
 	})
 
 	mux.HandleFunc("/VolumeDriver.Unmount", func(w http.ResponseWriter, r *http.Request) {
 		var pr pluginRequest
-		if err := json.NewDecoder(r.Body).Decode(&pr); err != nil {
-			http.Error(w, fmt.Sprintf("{\"Error\":\"%s\"}", err.Error()), 500)
+		if err := decodeRequest(r, &pr); err != nil {
+			writeError(w, err, 500)
 			m.ctx.WithError(err).Error("/VolumeDriver.Unmount: error decoding json")
 			return
 		}
 
 		m.ctx.WithField("pluginResponse", pr).Debug("/VolumeDriver.Unmount")
 
+		// the volume remains mounted while other containers are using it
+		if pr.ID != "" {
+			refs, err := m.removeMountRef(pr.Name, pr.ID)
+			if err != nil {
+				writeError(w, err, 500)
+				m.ctx.WithError(err).Error(
+					"/VolumeDriver.Unmount: error removing mount reference")
+				return
+			}
+			if refs > 0 {
+				m.ctx.WithFields(map[string]interface{}{
+					"name": pr.Name,
+					"refs": refs,
+				}).Debug("/VolumeDriver.Unmount: volume still in use")
+				w.Header().Set("Content-Type", "application/vnd.docker.plugins.v1.2+json")
+				fmt.Fprintln(w, `{}`)
+				return
+			}
+		}
+
 		err := m.lsc.Integration().Unmount(
-			m.ctx, "", pr.Name, apiutils.NewStore())
+			m.context(r), "", pr.Name, apiutils.NewStore())
 		if err != nil {
-			http.Error(w, fmt.Sprintf("{\"Error\":\"%s\"}", err.Error()), 500)
+			writeError(w, err, errorStatus(err))
 			m.ctx.WithError(err).Error("/VolumeDriver.Unmount: error unmounting volume")
 			return
 		}
@@ -343,8 +438,8 @@ func (m *mod) buildMux() *http.ServeMux {
 
 	mux.HandleFunc("/VolumeDriver.Get", func(w http.ResponseWriter, r *http.Request) {
 		var pr pluginRequest
-		if err := json.NewDecoder(r.Body).Decode(&pr); err != nil {
-			http.Error(w, fmt.Sprintf("{\"Error\":\"%s\"}", err.Error()), 500)
+		if err := decodeRequest(r, &pr); err != nil {
+			writeError(w, err, 500)
 			m.ctx.WithError(err).Error("/VolumeDriver.Get: error decoding json")
 			return
 		}
@@ -352,13 +447,19 @@ func (m *mod) buildMux() *http.ServeMux {
 		m.ctx.WithField("pluginResponse", pr).Debug("/VolumeDriver.Get")
 
 		volMapping, err := m.lsc.Integration().Inspect(
-			m.ctx, pr.Name, apiutils.NewStore())
+			m.context(r), pr.Name, apiutils.NewStore())
 		if err != nil {
-			http.Error(w, fmt.Sprintf("{\"Error\":\"%s\"}", err.Error()), 500)
+			writeError(w, err, errorStatus(err))
 			m.ctx.WithError(err).Error("/VolumeDriver.Get: error getting volume")
 			return
 		}
 
+		if volMapping == nil {
+			writeError(w, goof.WithField(
+				"name", pr.Name, "volume not found"), http.StatusNotFound)
+			return
+		}
+
 		w.Header().Set(
 			"Content-Type", "application/vnd.docker.plugins.v1.2+json")
 		json.NewEncoder(w).Encode(map[string]apitypes.VolumeMapping{
@@ -368,21 +469,26 @@ func (m *mod) buildMux() *http.ServeMux {
 
 	mux.HandleFunc("/VolumeDriver.List", func(w http.ResponseWriter, r *http.Request) {
 		var pr pluginRequest
-		if err := json.NewDecoder(r.Body).Decode(&pr); err != nil {
-			http.Error(w, fmt.Sprintf("{\"Error\":\"%s\"}", err.Error()), 500)
+		if err := decodeRequest(r, &pr); err != nil {
+			writeError(w, err, 500)
 			m.ctx.WithError(err).Error("/VolumeDriver.List: error decoding json")
 			return
 		}
 
 		m.ctx.WithField("pluginResponse", pr).Debug("/VolumeDriver.List")
 
-		volMappings, err := m.lsc.Integration().List(m.ctx, apiutils.NewStore())
+		volMappings, err := m.lsc.Integration().List(m.context(r), apiutils.NewStore())
 		if err != nil {
-			http.Error(w, fmt.Sprintf("{\"Error\":\"%s\"}", err.Error()), 500)
+			writeError(w, err, errorStatus(err))
 			m.ctx.WithError(err).Error("/VolumeDriver.List: error listing volumes")
 			return
 		}
 
+		// Podman expects an empty list rather than null
+		if volMappings == nil {
+			volMappings = []apitypes.VolumeMapping{}
+		}
+
 		w.Header().Set("Content-Type", "application/vnd.docker.plugins.v1.2+json")
 		json.NewEncoder(w).Encode(
 			map[string][]apitypes.VolumeMapping{"Volumes": volMappings})
@@ -390,8 +496,8 @@ func (m *mod) buildMux() *http.ServeMux {
 
 	mux.HandleFunc("/VolumeDriver.Capabilities", func(w http.ResponseWriter, r *http.Request) {
 		var pr pluginRequest
-		if err := json.NewDecoder(r.Body).Decode(&pr); err != nil {
-			http.Error(w, fmt.Sprintf("{\"Error\":\"%s\"}", err.Error()), 500)
+		if err := decodeRequest(r, &pr); err != nil {
+			writeError(w, err, 500)
 			m.ctx.WithError(err).Error("/VolumeDriver.Capabilities: error decoding json")
 			return
 		}
@@ -404,3 +510,43 @@ func (m *mod) buildMux() *http.ServeMux {
 
 	return mux
 }
+
+// context returns the context of the operations performed for a plug-in
+// request, which carries the request's trace and is canceled if Docker
+// disconnects before the request completes.
+func (m *mod) context(r *http.Request) apitypes.Context {
+	return timeouts.WithRequest(tracing.Context(m.ctx, r), r)
+}
+
+// errorStatus returns the HTTP status code with which an error is reported.
+// Errors caused by a volume policy, such as an exceeded quota, carry their own
+// status code. A volume that does not exist is reported with 404 so that
+// Podman recognizes the error as a missing volume.
+func errorStatus(err error) int {
+	if se, ok := err.(interface {
+		Status() int
+	}); ok {
+		return se.Status()
+	}
+	if strings.Contains(strings.ToLower(err.Error()), "not found") {
+		return http.StatusNotFound
+	}
+	return 500
+}
+
+// writeError writes an error response in the format defined by the Docker
+// volume plug-in protocol, which is also the format Podman expects.
+func writeError(w http.ResponseWriter, err error, status int) {
+	w.Header().Set("Content-Type", "application/vnd.docker.plugins.v1.2+json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"Err": err.Error()})
+}
+
+// decodeRequest decodes the body of a plug-in request. Podman sends no body
+// with requests that have no parameters, such as List and Capabilities.
+func decodeRequest(r *http.Request, pr *pluginRequest) error {
+	if err := json.NewDecoder(r.Body).Decode(pr); err != nil && err != io.EOF {
+		return err
+	}
+	return nil
+}
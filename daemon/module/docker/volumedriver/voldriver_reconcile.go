@@ -0,0 +1,326 @@
+package volumedriver
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/akutz/goof"
+	apiutils "github.com/emccode/libstorage/api/utils"
+
+	"github.com/emccode/rexray/core/events"
+)
+
+const (
+	defaultDockerHost = "unix:///var/run/docker.sock"
+
+	// defaultGracePeriod is how long a volume is left alone by the
+	// reconciler after it is requested to be mounted, since Docker does not
+	// report a container as running until its volumes are mounted
+	defaultGracePeriod = 2 * time.Minute
+)
+
+// inventory lists the running containers that use the plug-in's volumes
+// according to the container orchestrator.
+type inventory interface {
+
+	// volumeUsers returns the IDs of the running containers using each of
+	// the volumes provided by the named drivers, keyed by volume name.
+	volumeUsers(drivers []string) (map[string][]string, error)
+}
+
+// dockerInventory queries the local Docker daemon.
+type dockerInventory struct {
+	client *http.Client
+}
+
+func newDockerInventory(host string) (*dockerInventory, error) {
+	if !strings.HasPrefix(host, "unix://") {
+		return nil, goof.WithField(
+			"host", host, "the Docker host must be a UNIX socket")
+	}
+	sock := strings.TrimPrefix(host, "unix://")
+	return &dockerInventory{client: &http.Client{
+		Timeout: 30 * time.Second,
+		Transport: &http.Transport{
+			Dial: func(string, string) (net.Conn, error) {
+				return net.Dial("unix", sock)
+			},
+		},
+	}}, nil
+}
+
+func (d *dockerInventory) volumeUsers(
+	drivers []string) (map[string][]string, error) {
+
+	resp, err := d.client.Get("http://docker/containers/json")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, goof.WithField(
+			"status", resp.StatusCode, "error listing docker containers")
+	}
+
+	containers := []struct {
+		ID     string `json:"Id"`
+		Mounts []struct {
+			Type   string `json:"Type"`
+			Name   string `json:"Name"`
+			Driver string `json:"Driver"`
+		} `json:"Mounts"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&containers); err != nil {
+		return nil, err
+	}
+
+	users := map[string][]string{}
+	for _, c := range containers {
+		for _, mnt := range c.Mounts {
+			if mnt.Type != "volume" || !hasDriver(drivers, mnt.Driver) {
+				continue
+			}
+			name := strings.ToLower(mnt.Name)
+			users[name] = append(users[name], c.ID)
+		}
+	}
+	return users, nil
+}
+
+func hasDriver(drivers []string, driver string) bool {
+	// docker reports drivers installed as managed plug-ins with a tag
+	driver = strings.TrimSuffix(driver, ":latest")
+	for _, d := range drivers {
+		if d == driver {
+			return true
+		}
+	}
+	return false
+}
+
+// driverNames returns the names under which Docker knows the plug-in, which
+// are the names of the sockets on which it is served.
+func (m *mod) driverNames() []string {
+	socks := append([]string{m.Address()}, m.config.GetStringSlice("sockets")...)
+	names := []string{}
+	for _, sock := range socks {
+		if strings.HasPrefix(sock, "tcp://") {
+			continue
+		}
+		names = append(names, strings.TrimSuffix(
+			filepath.Base(strings.TrimPrefix(sock, "unix://")), ".sock"))
+	}
+	if spec := m.config.GetString("spec"); spec != "" {
+		names = append(names, strings.TrimSuffix(filepath.Base(spec), ".spec"))
+	}
+	return names
+}
+
+// startReconciler periodically reconciles the volumes' mount references with
+// the containers the orchestrator reports as using them. The first
+// reconciliation happens immediately so that references leaked by a crash
+// are repaired when the service restarts.
+func (m *mod) startReconciler() error {
+	if !m.config.GetBool("reconcile.enabled") {
+		return nil
+	}
+
+	host := m.config.GetString("reconcile.dockerHost")
+	if host == "" {
+		host = defaultDockerHost
+	}
+	inv, err := newDockerInventory(host)
+	if err != nil {
+		return err
+	}
+
+	interval, err := time.ParseDuration(
+		m.config.GetString("reconcile.interval"))
+	if err != nil || interval <= 0 {
+		interval = time.Minute
+	}
+
+	grace, err := time.ParseDuration(
+		m.config.GetString("reconcile.gracePeriod"))
+	if err != nil || grace < 0 {
+		grace = defaultGracePeriod
+	}
+
+	go func() {
+		for {
+			if err := m.reconcile(inv, grace); err != nil {
+				m.ctx.WithError(err).Error("error reconciling mounts")
+			}
+			time.Sleep(interval)
+		}
+	}()
+	return nil
+}
+
+// reconcile compares the mount references of each volume with the running
+// containers using it. References leaked by containers that are no longer
+// running are removed, unmounting the volume if no container uses it, and
+// references are added for containers whose mounts were not recorded.
+// Containers using volumes that are not mounted are reported. Volumes that
+// were requested to be mounted within the grace period are skipped, since the
+// containers that requested them may not be running yet.
+func (m *mod) reconcile(inv inventory, grace time.Duration) error {
+	users, err := inv.volumeUsers(m.driverNames())
+	if err != nil {
+		return err
+	}
+
+	recorded, err := m.mountRefVolumes()
+	if err != nil {
+		return err
+	}
+	volumes := map[string]bool{}
+	for _, name := range recorded {
+		volumes[name] = true
+	}
+	for name := range users {
+		volumes[name] = true
+	}
+
+	for name := range volumes {
+		if err := m.reconcileVolume(name, users[name], grace); err != nil {
+			m.ctx.WithField("name", name).WithError(err).Error(
+				"error reconciling volume")
+		}
+	}
+	return nil
+}
+
+func (m *mod) reconcileVolume(
+	name string, containers []string, grace time.Duration) error {
+
+	m.refsLock.Lock()
+	if m.mountedWithin(name, grace) {
+		m.refsLock.Unlock()
+		return nil
+	}
+	refs, err := m.mountRefs(name)
+	if err != nil {
+		m.refsLock.Unlock()
+		return err
+	}
+
+	fields := map[string]string{
+		"containers": strconv.Itoa(len(containers)),
+		"references": strconv.Itoa(len(refs)),
+	}
+
+	if len(refs) != len(containers) {
+		err = m.setMountRefs(name, reconcileRefs(refs, containers))
+	}
+	m.refsLock.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if len(refs) != len(containers) {
+		m.emit("mount.reconciled", name,
+			"repaired mount references", fields)
+	}
+
+	if len(containers) == 0 {
+		if len(refs) > 0 {
+			// the volume was left mounted by containers that are gone
+			return m.unmountUnused(name, grace)
+		}
+		return nil
+	}
+
+	mountPath, err := m.lsc.Integration().Path(
+		m.ctx, "", name, apiutils.NewStore())
+	if err != nil {
+		return err
+	}
+	if mountPath == "" {
+		m.emit("mount.missing", name,
+			"volume used by running containers is not mounted", fields)
+	}
+	return nil
+}
+
+// unmountUnused unmounts a volume whose references were all removed by the
+// reconciler. The references are checked again, and the volume is unmounted,
+// while refsLock is held so that a mount requested since they were removed
+// is neither undone nor interleaved with the unmount.
+func (m *mod) unmountUnused(name string, grace time.Duration) error {
+	m.refsLock.Lock()
+	defer m.refsLock.Unlock()
+
+	refs, err := m.mountRefs(name)
+	if err != nil {
+		return err
+	}
+	if len(refs) > 0 || m.mountedWithin(name, grace) {
+		m.ctx.WithField("name", name).Debug(
+			"volume mounted again during reconciliation; not unmounting")
+		return nil
+	}
+	return m.lsc.Integration().Unmount(m.ctx, "", name, apiutils.NewStore())
+}
+
+// reconcileRefs returns the provided references adjusted to number one per
+// container. Extra references are removed, those added by the reconciler
+// first and then the oldest, and missing references are added for the
+// containers that lack one.
+func reconcileRefs(refs, containers []string) []string {
+	if len(refs) > len(containers) {
+		kept := []string{}
+		for _, ref := range refs {
+			if !strings.HasPrefix(ref, reconciledRefPrefix) {
+				kept = append(kept, ref)
+			}
+		}
+		for _, ref := range refs {
+			if len(kept) >= len(containers) {
+				break
+			}
+			if strings.HasPrefix(ref, reconciledRefPrefix) {
+				kept = append(kept, ref)
+			}
+		}
+		if len(kept) > len(containers) {
+			kept = kept[len(kept)-len(containers):]
+		}
+		return kept
+	}
+
+	has := map[string]bool{}
+	for _, ref := range refs {
+		has[ref] = true
+	}
+	for _, id := range containers {
+		if len(refs) >= len(containers) {
+			break
+		}
+		// docker mount requests carry IDs that are unrelated to the
+		// container IDs, so a container's reference is recognized only if
+		// it was added by the reconciler
+		if !has[reconciledRefPrefix+id] {
+			refs = append(refs, reconciledRefPrefix+id)
+		}
+	}
+	return refs
+}
+
+func (m *mod) emit(
+	eventType, volumeName, msg string, fields map[string]string) {
+
+	fields["name"] = volumeName
+	if err := events.Emit(m.store, &events.Event{
+		Type:    eventType,
+		Message: msg,
+		Fields:  fields,
+	}); err != nil {
+		m.ctx.WithError(err).Warn("error recording event")
+	}
+}
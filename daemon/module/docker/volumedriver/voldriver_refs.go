@@ -0,0 +1,126 @@
+package volumedriver
+
+import (
+	"strings"
+	"time"
+)
+
+const (
+	mountRefsBucket = "dockerMountRefs"
+
+	// reconciledRefPrefix prefixes the references added by the reconciler
+	// for containers whose mount requests were not recorded.
+	reconciledRefPrefix = "reconciled-"
+)
+
+// refsKey returns the key under which the mount references of a volume are
+// recorded. References are recorded per module since each module is a
+// separate volume driver.
+func (m *mod) refsKey(volumeName string) string {
+	return m.name + "/" + strings.ToLower(volumeName)
+}
+
+// mountRefs returns the IDs of the mount requests for which the volume with
+// the provided name remains mounted.
+func (m *mod) mountRefs(volumeName string) ([]string, error) {
+	refs := []string{}
+	if _, err := m.local.Get(
+		mountRefsBucket, m.refsKey(volumeName), &refs); err != nil {
+		return nil, err
+	}
+	return refs, nil
+}
+
+func (m *mod) setMountRefs(volumeName string, refs []string) error {
+	if len(refs) == 0 {
+		return m.local.Delete(mountRefsBucket, m.refsKey(volumeName))
+	}
+	return m.local.Set(mountRefsBucket, m.refsKey(volumeName), refs)
+}
+
+// addMountRef records a mount request for the volume with the provided name
+// and returns the number of references to the volume.
+func (m *mod) addMountRef(volumeName, id string) (int, error) {
+	m.refsLock.Lock()
+	defer m.refsLock.Unlock()
+
+	refs, err := m.mountRefs(volumeName)
+	if err != nil {
+		return 0, err
+	}
+	for _, ref := range refs {
+		if ref == id {
+			return len(refs), nil
+		}
+	}
+	refs = append(refs, id)
+	return len(refs), m.setMountRefs(volumeName, refs)
+}
+
+// removeMountRef removes the record of a mount request for the volume with
+// the provided name and returns the number of remaining references. If the
+// request is unknown a reference added by the reconciler is removed instead.
+func (m *mod) removeMountRef(volumeName, id string) (int, error) {
+	m.refsLock.Lock()
+	defer m.refsLock.Unlock()
+
+	refs, err := m.mountRefs(volumeName)
+	if err != nil {
+		return 0, err
+	}
+
+	removed := false
+	remaining := []string{}
+	for _, ref := range refs {
+		if !removed && ref == id {
+			removed = true
+			continue
+		}
+		remaining = append(remaining, ref)
+	}
+	if !removed {
+		for i, ref := range remaining {
+			if strings.HasPrefix(ref, reconciledRefPrefix) {
+				remaining = append(remaining[:i], remaining[i+1:]...)
+				break
+			}
+		}
+	}
+	return len(remaining), m.setMountRefs(volumeName, remaining)
+}
+
+// noteMount records that the volume with the provided name was requested to
+// be mounted, so that the reconciler leaves it alone until the container that
+// requested it is running.
+func (m *mod) noteMount(volumeName string) {
+	m.refsLock.Lock()
+	defer m.refsLock.Unlock()
+	if m.mountTimes == nil {
+		m.mountTimes = map[string]time.Time{}
+	}
+	m.mountTimes[strings.ToLower(volumeName)] = time.Now()
+}
+
+// mountedWithin returns a flag indicating whether the volume with the
+// provided name was requested to be mounted within the provided duration.
+// The caller must hold refsLock.
+func (m *mod) mountedWithin(volumeName string, d time.Duration) bool {
+	t, ok := m.mountTimes[strings.ToLower(volumeName)]
+	return ok && time.Since(t) < d
+}
+
+// mountRefVolumes returns the names of the volumes with mount references.
+func (m *mod) mountRefVolumes() ([]string, error) {
+	keys, err := m.local.Keys(mountRefsBucket)
+	if err != nil {
+		return nil, err
+	}
+	prefix := m.name + "/"
+	names := []string{}
+	for _, k := range keys {
+		if strings.HasPrefix(k, prefix) {
+			names = append(names, strings.TrimPrefix(k, prefix))
+		}
+	}
+	return names, nil
+}
@@ -10,8 +10,8 @@ import (
 	"github.com/akutz/gofig"
 	"github.com/akutz/goof"
 	apitypes "github.com/emccode/libstorage/api/types"
-	apiclient "github.com/emccode/libstorage/client"
 
+	"github.com/emccode/rexray/core/policy"
 	"github.com/emccode/rexray/util"
 )
 
@@ -97,6 +97,7 @@ rexray:
             type:     admin
             desc:     The default admin module.
             host:     unix:///var/run/rexray/server.sock
+            grpc:     unix:///var/run/rexray/admin.sock
             disabled: false
         default-docker:
             type:     docker
@@ -104,6 +105,10 @@ rexray:
             host:     unix:///run/docker/plugins/rexray.sock
             spec:     /etc/docker/plugins/rexray.spec
             disabled: false
+        default-agent:
+            type:     agent
+            desc:     The default agent module.
+            disabled: false
 `)
 	cfg.Key(gofig.String, "", "10s", "", "rexray.module.startTimeout")
 	gofig.Register(cfg)
@@ -179,7 +184,7 @@ func InitializeDefaultModules(
 		ctx.WithField("name", mc.Name).Debug(
 			"creating libStorage client for module instance")
 
-		if mc.Client, err = apiclient.New(ctx, mc.Config); err != nil {
+		if mc.Client, err = policy.New(ctx, mc.Config); err != nil {
 			panic(err)
 		}
 
@@ -331,6 +336,28 @@ func StartModule(ctx apitypes.Context, config gofig.Config, name string) error {
 	return nil
 }
 
+// StopModule stops the module with the provided instance name.
+func StopModule(ctx apitypes.Context, name string) error {
+
+	modInstancesRwl.RLock()
+	defer modInstancesRwl.RUnlock()
+
+	name = strings.ToLower(name)
+	mod, modExists := modInstances[name]
+
+	if !modExists {
+		return goof.WithField("name", name, "unknown module instance")
+	}
+
+	if err := mod.Inst.Stop(); err != nil {
+		return err
+	}
+	mod.IsStarted = false
+	ctx.WithField("name", name).Info("stopped module")
+
+	return nil
+}
+
 func getConfiguredModules(
 	ctx apitypes.Context, c gofig.Config) ([]*Config, error) {
 
@@ -0,0 +1,258 @@
+package ui
+
+const htmlIndex = `<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="utf-8">
+    <title>REX-Ray</title>
+    <style>
+        body { font-family: sans-serif; margin: 0; color: #222; }
+        header { background: #222; color: #fff; padding: 12px 20px; }
+        header span { font-size: 1.5em; }
+        header button { float: right; }
+        main { padding: 0 20px 20px; }
+        section { margin-top: 20px; }
+        h2 { font-size: 1.1em; border-bottom: 1px solid #ccc; }
+        table { border-collapse: collapse; width: 100%; font-size: 0.9em; }
+        th, td { text-align: left; padding: 4px 8px; vertical-align: top; }
+        tr:nth-child(even) { background: #f4f4f4; }
+        .pass { color: #2a7a2a; }
+        .warn { color: #a66a00; }
+        .fail { color: #b22; }
+        .error { color: #b22; font-style: italic; }
+        #login { padding: 40px 20px; }
+        #login input { width: 40em; }
+    </style>
+</head>
+<body>
+    <header>
+        <span>REX-Ray</span>
+        <button id="logout" hidden>Sign out</button>
+    </header>
+    <div id="login" hidden>
+        <p>A token with the read-only role or greater is required. Issue one
+        with <code>rexray token new --role read-only</code>.</p>
+        <form id="login-form">
+            <input id="token" type="password" placeholder="Token" />
+            <button type="submit">Sign in</button>
+        </form>
+        <p id="login-error" class="error"></p>
+    </div>
+    <main id="main" hidden>
+        <section>
+            <h2>Driver Health</h2>
+            <table id="health"></table>
+        </section>
+        <section>
+            <h2>Volumes</h2>
+            <table id="volumes"></table>
+        </section>
+        <section>
+            <h2>Snapshots</h2>
+            <table id="snapshots"></table>
+        </section>
+        <section>
+            <h2>Mounts by Node</h2>
+            <table id="nodes"></table>
+        </section>
+        <section>
+            <h2>Recent Tasks</h2>
+            <table id="tasks"></table>
+        </section>
+        <section>
+            <h2>Recent Events</h2>
+            <table id="events"></table>
+        </section>
+    </main>
+    <script type="text/javascript">
+    (function() {
+        var tokenKey = "rexray.token";
+
+        function el(tag, text, cls) {
+            var e = document.createElement(tag);
+            if (text !== undefined && text !== null) {
+                e.textContent = text;
+            }
+            if (cls) {
+                e.className = cls;
+            }
+            return e;
+        }
+
+        function fill(id, headers, rows) {
+            var t = document.getElementById(id);
+            t.innerHTML = "";
+            var tr = el("tr");
+            headers.forEach(function(h) { tr.appendChild(el("th", h)); });
+            t.appendChild(tr);
+            rows.forEach(function(r) {
+                var tr = el("tr");
+                r.forEach(function(c) {
+                    tr.appendChild(c instanceof Node ? wrap(c) : el("td", c));
+                });
+                t.appendChild(tr);
+            });
+            if (rows.length === 0) {
+                var tr = el("tr");
+                var td = el("td", "none");
+                td.colSpan = headers.length;
+                tr.appendChild(td);
+                t.appendChild(tr);
+            }
+        }
+
+        function wrap(n) {
+            var td = el("td");
+            td.appendChild(n);
+            return td;
+        }
+
+        function fail(id, msg) {
+            var t = document.getElementById(id);
+            t.innerHTML = "";
+            var tr = el("tr");
+            tr.appendChild(el("td", msg, "error"));
+            t.appendChild(tr);
+        }
+
+        function time(v) {
+            if (!v || v.indexOf("0001-") === 0) {
+                return "";
+            }
+            return new Date(v).toLocaleString();
+        }
+
+        function showLogin(msg) {
+            document.getElementById("main").hidden = true;
+            document.getElementById("logout").hidden = true;
+            document.getElementById("login").hidden = false;
+            document.getElementById("login-error").textContent = msg || "";
+        }
+
+        function get(path, id, render) {
+            var token = sessionStorage.getItem(tokenKey);
+            if (!token) {
+                showLogin();
+                return;
+            }
+            fetch(path, {headers: {"Authorization": "Bearer " + token}})
+                .then(function(r) {
+                    if (r.status === 401 || r.status === 403) {
+                        sessionStorage.removeItem(tokenKey);
+                        return r.text().then(function(t) {
+                            showLogin(t);
+                        });
+                    }
+                    return r.json().then(function(v) {
+                        if (!r.ok) {
+                            fail(id, v.error || r.statusText);
+                            return;
+                        }
+                        render(v || []);
+                    });
+                })
+                .catch(function(e) { fail(id, e.message); });
+        }
+
+        function health(v) {
+            fill("health", ["Check", "Service", "Status", "Message"],
+                (v.checks || []).map(function(c) {
+                    return [c.name, c.service, el("span", c.status, c.status),
+                        c.message + (c.remedy ? " (" + c.remedy + ")" : "")];
+                }));
+        }
+
+        function volumes(v) {
+            fill("volumes", ["Name", "ID", "Size (GiB)", "Status", "Attached To"],
+                v.map(function(vol) {
+                    return [vol.name, vol.id, vol.size, vol.status,
+                        (vol.attachments || []).map(function(a) {
+                            return a.instanceID ? a.instanceID.id : "";
+                        }).join(", ")];
+                }));
+        }
+
+        function snapshots(v) {
+            fill("snapshots", ["Name", "ID", "Volume", "Size (GiB)", "Status", "Started"],
+                v.map(function(s) {
+                    return [s.name, s.id, s.volumeID, s.volumeSize, s.status,
+                        s.startTime ? new Date(s.startTime * 1000).toLocaleString() : ""];
+                }));
+        }
+
+        function nodes(v) {
+            fill("nodes", ["Host", "Instance", "Last Heartbeat", "Mounted Volumes"],
+                v.map(function(n) {
+                    return [n.hostname, n.instanceID, time(n.heartbeat),
+                        (n.mounts || []).join(", ")];
+                }));
+        }
+
+        function tasks(v) {
+            fill("tasks", ["ID", "Operation", "State", "Progress", "Queued", "Error"],
+                v.map(function(t) {
+                    return [t.id, t.operation, t.state, t.progress + "%",
+                        time(t.queueTime), t.error];
+                }));
+        }
+
+        function events(v) {
+            fill("events", ["ID", "Time", "Type", "Volume", "Message"],
+                v.map(function(e) {
+                    return [e.id, time(e.time), e.type, e.volumeID, e.message];
+                }));
+        }
+
+        function refresh() {
+            get("/api/volumes", "volumes", volumes);
+            get("/api/snapshots", "snapshots", snapshots);
+            get("/api/nodes", "nodes", nodes);
+            get("/api/tasks", "tasks", tasks);
+            get("/api/events", "events", events);
+        }
+
+        function start() {
+            if (!sessionStorage.getItem(tokenKey)) {
+                showLogin();
+                return;
+            }
+            document.getElementById("login").hidden = true;
+            document.getElementById("main").hidden = false;
+            document.getElementById("logout").hidden = false;
+            refresh();
+            get("/api/health", "health", health);
+        }
+
+        document.getElementById("login-form").onsubmit = function(e) {
+            e.preventDefault();
+            var t = document.getElementById("token");
+            sessionStorage.setItem(tokenKey, t.value.trim());
+            t.value = "";
+            start();
+        };
+
+        document.getElementById("logout").onclick = function() {
+            sessionStorage.removeItem(tokenKey);
+            showLogin();
+        };
+
+        setInterval(function() {
+            if (sessionStorage.getItem(tokenKey)) {
+                refresh();
+            }
+        }, 5000);
+
+        // the health checks query the storage platform, so they are run
+        // less often than the other data is refreshed
+        setInterval(function() {
+            if (sessionStorage.getItem(tokenKey)) {
+                get("/api/health", "health", health);
+            }
+        }, 30000);
+
+        start();
+    })();
+    </script>
+</body>
+</html>
+`
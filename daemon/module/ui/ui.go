@@ -0,0 +1,227 @@
+// Package ui serves a single-page dashboard of REX-Ray's volumes,
+// snapshots, nodes, tasks, events, and driver health. The page's assets are
+// embedded in the binary, and the data it displays is served only to
+// requests that carry a valid token.
+package ui
+
+import (
+	"encoding/json"
+	"fmt"
+	golog "log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/akutz/gofig"
+	"github.com/akutz/gotil"
+	apitypes "github.com/emccode/libstorage/api/types"
+	apiutils "github.com/emccode/libstorage/api/utils"
+	"github.com/gorilla/handlers"
+	"github.com/gorilla/mux"
+
+	"github.com/emccode/rexray/core/events"
+	"github.com/emccode/rexray/core/nodes"
+	"github.com/emccode/rexray/core/probes"
+	"github.com/emccode/rexray/core/state"
+	"github.com/emccode/rexray/core/tasks"
+	"github.com/emccode/rexray/core/tokens"
+	"github.com/emccode/rexray/daemon/module"
+)
+
+const (
+	modName = "ui"
+
+	defaultAddress = "tcp://127.0.0.1:7990"
+
+	// defaultLimit is the number of the most recent tasks and events served
+	// unless the request provides a limit.
+	defaultLimit = 25
+)
+
+func init() {
+	module.RegisterModule(modName, newModule)
+}
+
+type mod struct {
+	name   string
+	addr   string
+	desc   string
+	ctx    apitypes.Context
+	config gofig.Config
+	lsc    apitypes.Client
+	store  *state.Store
+	l      net.Listener
+}
+
+func newModule(ctx apitypes.Context, c *module.Config) (module.Module, error) {
+	addr := c.Address
+	if addr == "" {
+		addr = defaultAddress
+	}
+	return &mod{
+		name:   c.Name,
+		desc:   c.Description,
+		addr:   addr,
+		ctx:    ctx,
+		config: c.Config,
+		lsc:    c.Client,
+		store:  state.Default(),
+	}, nil
+}
+
+func (m *mod) Start() error {
+	stdOut := log.StandardLogger().Writer()
+	stdErr := log.StandardLogger().Writer()
+
+	api := func(h http.HandlerFunc) http.Handler {
+		return handlers.LoggingHandler(stdOut,
+			tokens.Handler(m.config, m.store, tokens.ReadOnly, h))
+	}
+
+	r := mux.NewRouter()
+	r.Handle("/api/volumes", api(m.volumesHandler))
+	r.Handle("/api/snapshots", api(m.snapshotsHandler))
+	r.Handle("/api/nodes", api(m.nodesHandler))
+	r.Handle("/api/tasks", api(m.tasksHandler))
+	r.Handle("/api/events", api(m.eventsHandler))
+	r.Handle("/api/health", api(m.healthHandler))
+	r.Handle("/",
+		handlers.LoggingHandler(stdOut, http.HandlerFunc(m.indexHandler)))
+
+	proto, laddr, err := gotil.ParseAddress(m.addr)
+	if err != nil {
+		return err
+	}
+	if proto == "unix" {
+		os.MkdirAll(filepath.Dir(laddr), 0755)
+		os.Remove(laddr)
+	}
+
+	l, err := net.Listen(proto, laddr)
+	if err != nil {
+		return err
+	}
+	m.l = l
+
+	s := &http.Server{
+		Handler:        r,
+		ReadTimeout:    10 * time.Second,
+		WriteTimeout:   30 * time.Second,
+		MaxHeaderBytes: 1 << 20,
+		ErrorLog:       golog.New(stdErr, "", 0),
+	}
+
+	go func() {
+		defer stdOut.Close()
+		defer stdErr.Close()
+		if err := s.Serve(l); err != nil {
+			log.WithError(err).Debug("ui server stopped")
+		}
+	}()
+
+	m.ctx.WithField("address", m.addr).Info("serving dashboard")
+	return nil
+}
+
+func (m *mod) Stop() error {
+	if m.l != nil {
+		return m.l.Close()
+	}
+	return nil
+}
+
+func (m *mod) Name() string {
+	return m.name
+}
+
+func (m *mod) Description() string {
+	return m.desc
+}
+
+func (m *mod) Address() string {
+	return m.addr
+}
+
+func (m *mod) indexHandler(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path != "/" {
+		http.NotFound(w, req)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+	w.Header().Set("Content-Length", fmt.Sprintf("%d", len(htmlIndex)))
+	fmt.Fprint(w, htmlIndex)
+}
+
+func (m *mod) volumesHandler(w http.ResponseWriter, req *http.Request) {
+	vols, err := m.lsc.Storage().Volumes(
+		m.ctx, &apitypes.VolumesOpts{Attachments: true})
+	writeJSON(w, vols, err)
+}
+
+func (m *mod) snapshotsHandler(w http.ResponseWriter, req *http.Request) {
+	snaps, err := m.lsc.Storage().Snapshots(m.ctx, apiutils.NewStore())
+	writeJSON(w, snaps, err)
+}
+
+func (m *mod) nodesHandler(w http.ResponseWriter, req *http.Request) {
+	all, err := nodes.List(state.Controller())
+	writeJSON(w, all, err)
+}
+
+// tasksHandler responds with the most recent tasks, newest first.
+func (m *mod) tasksHandler(w http.ResponseWriter, req *http.Request) {
+	all, err := tasks.List(m.store)
+	if err != nil {
+		writeJSON(w, nil, err)
+		return
+	}
+	recent := []*tasks.Task{}
+	for i := len(all) - 1; i >= 0 && len(recent) < limit(req); i-- {
+		recent = append(recent, all[i])
+	}
+	writeJSON(w, recent, nil)
+}
+
+// eventsHandler responds with the most recent events, newest first.
+func (m *mod) eventsHandler(w http.ResponseWriter, req *http.Request) {
+	all, err := events.List(m.store)
+	if err != nil {
+		writeJSON(w, nil, err)
+		return
+	}
+	recent := []*events.Event{}
+	for i := len(all) - 1; i >= 0 && len(recent) < limit(req); i-- {
+		recent = append(recent, all[i])
+	}
+	writeJSON(w, recent, nil)
+}
+
+// healthHandler responds with the result of the readiness probe, which runs
+// the pre-flight checks of the configured service's driver.
+func (m *mod) healthHandler(w http.ResponseWriter, req *http.Request) {
+	writeJSON(w, probes.Ready(m.ctx, m.config, m.lsc), nil)
+}
+
+func limit(req *http.Request) int {
+	if n, err := strconv.Atoi(req.FormValue("limit")); err == nil && n > 0 {
+		return n
+	}
+	return defaultLimit
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}, err error) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	if err != nil {
+		log.WithError(err).Error("error servicing dashboard request")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.WithError(err).Error("error writing dashboard response")
+	}
+}
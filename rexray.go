@@ -49,8 +49,14 @@ import (
 
 	// load libStorage
 	_ "github.com/emccode/libstorage"
+	_ "github.com/emccode/libstorage/imports/config"
 	_ "github.com/emccode/libstorage/imports/local"
-	_ "github.com/emccode/libstorage/imports/remote"
+
+	// load the storage drivers compiled into the binary
+	_ "github.com/emccode/rexray/core/drivers/storage"
+
+	// admit the libStorage server's requests according to its policies
+	_ "github.com/emccode/rexray/core/admission"
 	"github.com/emccode/rexray/util"
 )
 
@@ -0,0 +1,92 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/spf13/pflag"
+
+	"github.com/emccode/rexray/core/errcodes"
+)
+
+// batchResult is the result of an operation on one of several volumes.
+type batchResult struct {
+	Volume string        `json:"volume" yaml:"volume"`
+	Result interface{}   `json:"result,omitempty" yaml:"result,omitempty"`
+	Error  string        `json:"error,omitempty" yaml:"error,omitempty"`
+	Code   errcodes.Code `json:"code,omitempty" yaml:"code,omitempty"`
+}
+
+func (c *CLI) addParallelFlag(fs *pflag.FlagSet) {
+	fs.IntVar(&c.parallel, "parallel", 1,
+		"The number of volumes operated on at once")
+}
+
+// runBatch invokes the provided operation for each volume, running up to
+// --parallel operations at once. The results are printed in the order the
+// volumes were given, and the command exits with a non-zero code if any of
+// the operations failed, which is the exit status of the failures' error
+// code if they all have the same one.
+func (c *CLI) runBatch(
+	volumes []string, op func(volume string) (interface{}, error)) {
+
+	workers := c.parallel
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(volumes) {
+		workers = len(volumes)
+	}
+
+	results := make([]*batchResult, len(volumes))
+	work := make(chan int)
+	wg := &sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range work {
+				r := &batchResult{Volume: volumes[j]}
+				v, err := op(volumes[j])
+				if err != nil {
+					r.Error = err.Error()
+					r.Code = errcodes.Of(err)
+				} else {
+					r.Result = v
+				}
+				results[j] = r
+			}
+		}()
+	}
+	for i := range volumes {
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+
+	out, err := c.marshalOutput(results)
+	if err != nil {
+		fatal(err)
+	}
+	fmt.Println(out)
+
+	failed := 0
+	var code errcodes.Code
+	for _, r := range results {
+		if r.Error == "" {
+			continue
+		}
+		failed++
+		if code == "" {
+			code = r.Code
+		} else if code != r.Code {
+			code = errcodes.Unknown
+		}
+	}
+	if failed > 0 {
+		fmt.Fprintf(os.Stderr, "%d of %d volume operations failed\n",
+			failed, len(volumes))
+		panic(code.ExitCode())
+	}
+}
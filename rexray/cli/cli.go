@@ -1,15 +1,18 @@
 package cli
 
 import (
+	"bufio"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"strings"
+	"time"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/akutz/gofig"
 	glog "github.com/akutz/golf/logrus"
+	"github.com/akutz/goof"
 	"github.com/akutz/gotil"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
@@ -19,9 +22,15 @@ import (
 	apiserver "github.com/emccode/libstorage/api/server"
 	apitypes "github.com/emccode/libstorage/api/types"
 	apiutils "github.com/emccode/libstorage/api/utils"
-	apiclient "github.com/emccode/libstorage/client"
 
+	"github.com/emccode/rexray/core/configsrc"
+	"github.com/emccode/rexray/core/errcodes"
+	"github.com/emccode/rexray/core/policy"
+	"github.com/emccode/rexray/core/schedule"
+	"github.com/emccode/rexray/core/state"
+	"github.com/emccode/rexray/core/tracing"
 	"github.com/emccode/rexray/rexray/cli/term"
+	"github.com/emccode/rexray/rexray/cli/timeutil"
 	"github.com/emccode/rexray/util"
 )
 
@@ -76,6 +85,8 @@ type CLI struct {
 	volumeAttachCmd          *cobra.Command
 	volumeDetachCmd          *cobra.Command
 	snapshotCopyCmd          *cobra.Command
+	snapshotRevertCmd        *cobra.Command
+	snapshotCopiesCmd        *cobra.Command
 	deviceGetCmd             *cobra.Command
 	deviceMountCmd           *cobra.Command
 	devuceUnmountCmd         *cobra.Command
@@ -83,12 +94,77 @@ type CLI struct {
 	volumeMountCmd           *cobra.Command
 	volumeUnmountCmd         *cobra.Command
 	volumePathCmd            *cobra.Command
+	volumeResizeCmd          *cobra.Command
+	volumeRepairCmd          *cobra.Command
+	volumeAdoptCmd           *cobra.Command
+	volumeReleaseCmd         *cobra.Command
+	volumePinCmd             *cobra.Command
+	volumeUnpinCmd           *cobra.Command
+	volumeBenchCmd           *cobra.Command
+	volumeLabelCmd           *cobra.Command
+	volumeOverrideCmd        *cobra.Command
+	volumeProtectCmd         *cobra.Command
+	volumeUnprotectCmd       *cobra.Command
+	volumeTrashCmd           *cobra.Command
+	volumeRestoreTrashedCmd  *cobra.Command
+	taskCmd                  *cobra.Command
+	taskListCmd              *cobra.Command
+	taskInspectCmd           *cobra.Command
+	taskWaitCmd              *cobra.Command
+	quotaCmd                 *cobra.Command
+	quotaStatusCmd           *cobra.Command
+	nodeCmd                  *cobra.Command
+	nodeListCmd              *cobra.Command
+	nodeAttachmentsCmd       *cobra.Command
+	maintenanceCmd           *cobra.Command
+	maintenanceEnableCmd     *cobra.Command
+	maintenanceDisableCmd    *cobra.Command
+	csiCmd                   *cobra.Command
+	benchCmd                 *cobra.Command
+	debugCmd                 *cobra.Command
+	debugCaptureCmd          *cobra.Command
+	configCmd                *cobra.Command
+	configValidateCmd        *cobra.Command
+	configSchemaCmd          *cobra.Command
+	preflightCmd             *cobra.Command
+	tokenCmd                 *cobra.Command
+	tokenNewCmd              *cobra.Command
+	tokenCreateCmd           *cobra.Command
+	tokenListCmd             *cobra.Command
+	tokenRevokeCmd           *cobra.Command
+	tokenRevokedCmd          *cobra.Command
+	metricsCmd               *cobra.Command
+	metricsRulesCmd          *cobra.Command
+	metricsDashboardCmd      *cobra.Command
+	groupCmd                 *cobra.Command
+	groupCreateCmd           *cobra.Command
+	groupListCmd             *cobra.Command
+	groupRemoveCmd           *cobra.Command
+	groupAttachCmd           *cobra.Command
+	groupMountCmd            *cobra.Command
+	groupUnmountCmd          *cobra.Command
+	syncCmd                  *cobra.Command
+	syncListCmd              *cobra.Command
+	syncRemoveCmd            *cobra.Command
+	syncRunCmd               *cobra.Command
+	volumeExportSpecCmd      *cobra.Command
+	volumeApplyCmd           *cobra.Command
+	reportCmd                *cobra.Command
+	reportUsageCmd           *cobra.Command
+	completionCmd            *cobra.Command
+	completionWordsCmd       *cobra.Command
 
 	outputFormat            string
+	watch                   bool
+	watchInterval           time.Duration
 	fg                      bool
 	fork                    bool
 	force                   bool
+	yes                     bool
 	cfgFile                 string
+	installName             string
+	installInit             string
+	installUser             bool
 	snapshotID              string
 	volumeID                string
 	runAsync                bool
@@ -100,14 +176,65 @@ type CLI struct {
 	volumeName              string
 	snapshotName            string
 	availabilityZone        string
+	zones                   []string
 	destinationSnapshotName string
 	destinationRegion       string
+	encryptionKey           string
+	storageLocation         string
 	deviceName              string
 	mountPoint              string
 	mountOptions            string
 	mountLabel              string
 	fsType                  string
 	overwriteFs             bool
+	readOnly                bool
+	accessMode              string
+	parallel                int
+	syncMode                string
+	syncSchedule            string
+	syncName                string
+	syncSrcHost             string
+	syncDstHost             string
+	specService             string
+	specFile                string
+	dryRun                  bool
+	strict                  bool
+	reportFrom              string
+	reportTo                string
+	groupBy                 string
+	labels                  []string
+	attachedTo              string
+	removeLabels            []string
+	removeOverrides         []string
+	clearOverrides          bool
+	volumeOpts              []string
+	runTask                 bool
+	configDrift             bool
+	driverName              string
+	maintenanceMessage      string
+	pinReason               string
+	protectReason           string
+	purge                   bool
+	benchIterations         int
+	benchSize               int64
+	ioJob                   string
+	ioNative                bool
+	ioPattern               string
+	ioBlockSize             int64
+	ioFileSize              int64
+	ioDuration              string
+	ioWorkers               int
+	captureService          string
+	captureClear            bool
+	taskID                  int64
+	taskTimeout             time.Duration
+	since                   string
+	timeZone                string
+	tokenRole               string
+	tokenSubject            string
+	tokenTTL                time.Duration
+	tokenAccount            string
+	tokenScopes             []string
 	moduleTypeName          string
 	moduleInstanceName      string
 	moduleInstanceAddress   string
@@ -200,10 +327,26 @@ func NewWithArgs(a ...string) *CLI {
 	c.initAdapterCmdsAndFlags()
 	c.initDeviceCmdsAndFlags()
 	c.initVolumeCmdsAndFlags()
-	// c.initSnapshotCmdsAndFlags()
+	c.initSnapshotCmdsAndFlags()
 
 	c.initServiceCmdsAndFlags()
 	c.initModuleCmdsAndFlags()
+	c.initMaintenanceCmdsAndFlags()
+	c.initTaskCmdsAndFlags()
+	c.initQuotaCmdsAndFlags()
+	c.initNodeCmdsAndFlags()
+	c.initCSICmdsAndFlags()
+	c.initBenchCmdsAndFlags()
+	c.initDebugCmdsAndFlags()
+	c.initConfigCmdsAndFlags()
+	c.initPreflightCmdsAndFlags()
+	c.initTokenCmdsAndFlags()
+	c.initMetricsCmdsAndFlags()
+	c.initGroupCmdsAndFlags()
+	c.initSyncCmdsAndFlags()
+	c.initVolumeSpecCmdsAndFlags()
+	c.initReportCmdsAndFlags()
+	c.initCompletionCmdsAndFlags()
 
 	c.initUsageTemplates()
 
@@ -226,6 +369,7 @@ func (c *CLI) Execute() {
 	defer func() {
 		if c.activateLibStorage {
 			util.WaitUntilLibStorageStopped(c.ctx, c.rsErrs)
+			tracing.Shutdown()
 		}
 	}()
 
@@ -265,13 +409,43 @@ func (c *CLI) execute() {
 	c.c.Execute()
 }
 
+// fatal logs an error and exits with the exit status of the error's code, so
+// that scripts may branch on the type of the failure.
+func fatal(err error) {
+	log.Error(err)
+	panic(errcodes.ExitCode(err))
+}
+
+// fatalf logs a message about an invalid command and exits with the exit
+// status of an invalid request.
+func fatalf(format string, args ...interface{}) {
+	fatal(errcodes.New(errcodes.InvalidRequest, fmt.Errorf(format, args...)))
+}
+
+// confirm prints a question and returns a flag indicating whether the user
+// answered yes. The answer is no if standard input is closed.
+func confirm(question string) bool {
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", question)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
+
 func (c *CLI) marshalOutput(v interface{}) (string, error) {
 	var err error
 	var buf []byte
-	if strings.ToUpper(c.outputFormat) == "JSON" {
+	switch {
+	case isTemplateFormat(c.outputFormat):
+		buf, err = marshalTemplateOutput(c.outputFormat, v)
+	case strings.ToUpper(c.outputFormat) == "JSON":
 		buf, err = marshalJSONOutput(v)
-	} else {
+	case isYamlFormat(c.outputFormat):
 		buf, err = marshalYamlOutput(v)
+	default:
+		err = goof.WithField("format", c.outputFormat, "invalid output format")
 	}
 	if err != nil {
 		return "", err
@@ -289,7 +463,41 @@ func marshalJSONOutput(v interface{}) ([]byte, error) {
 
 func (c *CLI) addOutputFormatFlag(fs *pflag.FlagSet) {
 	fs.StringVarP(
-		&c.outputFormat, "format", "f", "yml", "The output format (yml, json)")
+		&c.outputFormat, "format", "f", "yml", outputFormatUsage)
+}
+
+// addOutputFormatFlagDefault adds the --format flag with a default other
+// than yml. The flag is not bound to outputFormat, since every binding of a
+// flag assigns its default to the bound variable, so the command's Run must
+// call readOutputFormatFlag.
+func (c *CLI) addOutputFormatFlagDefault(fs *pflag.FlagSet, def string) {
+	fs.StringP("format", "f", def, outputFormatUsage)
+}
+
+func (c *CLI) readOutputFormatFlag(cmd *cobra.Command) {
+	c.outputFormat, _ = cmd.Flags().GetString("format")
+}
+
+func (c *CLI) addTimeFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&c.since, "since", "",
+		"List only the entries since a time, ex. 7d, 36h, or 2016-06-01")
+	fs.StringVar(&c.timeZone, "time-zone", "",
+		"The time zone of the printed times, ex. local or America/Chicago; "+
+			"defaults to UTC")
+}
+
+// timeFilter returns the time parsed from --since, which is the zero time if
+// the flag is not set, and the time zone parsed from --time-zone.
+func (c *CLI) timeFilter() (time.Time, *time.Location) {
+	loc, err := timeutil.Location(c.timeZone)
+	if err != nil {
+		fatal(errcodes.New(errcodes.InvalidRequest, err))
+	}
+	since, err := timeutil.ParseSince(c.since, time.Now(), loc)
+	if err != nil {
+		fatal(errcodes.New(errcodes.InvalidRequest, err))
+	}
+	return since, loc
 }
 
 func (c *CLI) updateLogLevel() {
@@ -320,7 +528,13 @@ func (c *CLI) preRun(cmd *cobra.Command, args []string) {
 		cmd.Flags().Parse(os.Args[1:])
 	}
 
+	if err := configsrc.Load(c.ctx, c.config); err != nil {
+		panic(err)
+	}
+
 	c.updateLogLevel()
+	state.Configure(c.config)
+	schedule.SetStore(state.Default())
 
 	if v := c.rrHost(); v != "" {
 		c.config.Set(apitypes.ConfigHost, v)
@@ -364,7 +578,11 @@ func (c *CLI) preRun(cmd *cobra.Command, args []string) {
 		c.ctx, c.config, _, err = util.ActivateLibStorage(c.ctx, c.config)
 
 		if err == nil {
-			c.r, err = apiclient.New(c.ctx, c.config)
+			err = tracing.Init(c.ctx, c.config)
+		}
+
+		if err == nil {
+			c.r, err = policy.New(c.ctx, c.config)
 		}
 
 		if err != nil {
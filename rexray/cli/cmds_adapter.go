@@ -2,7 +2,6 @@ package cli
 
 import (
 	"fmt"
-	"log"
 
 	"github.com/spf13/cobra"
 )
@@ -34,12 +33,12 @@ func (c *CLI) initAdapterCmds() {
 		Run: func(cmd *cobra.Command, args []string) {
 			services, err := c.r.API().Services(c.ctx)
 			if err != nil {
-				log.Fatalf("Error: %s", err)
+				fatal(err)
 			}
 			if len(services) > 0 {
 				out, err := c.marshalOutput(&services)
 				if err != nil {
-					log.Fatal(err)
+					fatal(err)
 				}
 				fmt.Println(out)
 			}
@@ -54,13 +53,13 @@ func (c *CLI) initAdapterCmds() {
 
 			instances, err := c.r.API().Instances(c.ctx)
 			if err != nil {
-				log.Fatalf("Error: %s", err)
+				fatal(err)
 			}
 
 			if len(instances) > 0 {
 				out, err := c.marshalOutput(&instances)
 				if err != nil {
-					log.Fatal(err)
+					fatal(err)
 				}
 				fmt.Println(out)
 			}
@@ -0,0 +1,52 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/emccode/rexray/core/bench"
+)
+
+func (c *CLI) initBenchCmdsAndFlags() {
+	c.initBenchCmds()
+	c.initBenchFlags()
+}
+
+func (c *CLI) initBenchCmds() {
+	c.benchCmd = &cobra.Command{
+		Use:   "bench",
+		Short: "Measure the latency of the volume lifecycle",
+		Long: `Creates, attaches, mounts, unmounts, detaches, and removes a volume
+the requested number of times and prints the latency percentiles of each
+step. The volumes are created with the configured service.`,
+		PersistentPreRun: c.preRunActivateLibStorage,
+		Run: func(cmd *cobra.Command, args []string) {
+
+			res, err := bench.Run(c.ctx, c.config, c.r, &bench.Opts{
+				Iterations: c.benchIterations,
+				Size:       c.benchSize,
+				FSType:     c.fsType,
+				NamePrefix: c.volumeName,
+			})
+			if err != nil {
+				fatal(err)
+			}
+
+			out, err := c.marshalOutput(res)
+			if err != nil {
+				fatal(err)
+			}
+			fmt.Println(out)
+		},
+	}
+	c.c.AddCommand(c.benchCmd)
+}
+
+func (c *CLI) initBenchFlags() {
+	c.benchCmd.Flags().IntVar(&c.benchIterations, "iterations", 10, "The number of times the lifecycle is run")
+	c.benchCmd.Flags().Int64Var(&c.benchSize, "size", 1, "The size of the created volumes in GiB")
+	c.benchCmd.Flags().StringVar(&c.fsType, "fstype", "", "fstype")
+	c.benchCmd.Flags().StringVar(&c.volumeName, "prefix", "", "The prefix of the names of the created volumes, ex. rexray-bench")
+	c.addOutputFormatFlag(c.benchCmd.Flags())
+}
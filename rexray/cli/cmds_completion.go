@@ -0,0 +1,285 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/core/policy"
+	"github.com/emccode/rexray/util"
+)
+
+// The kinds of dynamic completions.
+const (
+	completeVolumeIDs     = "volumeIDs"
+	completeVolumeNames   = "volumeNames"
+	completeSnapshotIDs   = "snapshotIDs"
+	completeSnapshotNames = "snapshotNames"
+)
+
+// completionFlags are the flags whose values are completed dynamically.
+var completionFlags = map[string]string{
+	"volumeid":     completeVolumeIDs,
+	"volumename":   completeVolumeNames,
+	"snapshotid":   completeSnapshotIDs,
+	"snapshotname": completeSnapshotNames,
+}
+
+func (c *CLI) initCompletionCmdsAndFlags() {
+	c.initCompletionCmds()
+}
+
+func (c *CLI) initCompletionCmds() {
+	c.completionCmd = &cobra.Command{
+		Use:   "completion bash|zsh|fish",
+		Short: "Print a shell completion script",
+		Long: `Prints a script that completes REX-Ray's commands and flags in the provided
+shell. Volume and snapshot names and IDs are completed by querying the local
+REX-Ray service; they are not completed if the service is not running.
+
+    bash:  source <(rexray completion bash)
+    zsh:   source <(rexray completion zsh)
+    fish:  rexray completion fish | source`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if len(args) != 1 {
+				cmd.Usage()
+				return
+			}
+			script, ok := completionScripts[args[0]]
+			if !ok {
+				fatalf("unsupported shell: %s", args[0])
+			}
+			fmt.Print(script)
+		},
+	}
+	c.c.AddCommand(c.completionCmd)
+
+	// the words command is run by the completion scripts with the words
+	// preceding the cursor followed by the word being completed
+	c.completionWordsCmd = &cobra.Command{
+		Use:                "words",
+		Short:              "Print the completions of a command line",
+		Hidden:             true,
+		DisableFlagParsing: true,
+		Run: func(cmd *cobra.Command, args []string) {
+			if len(args) > 0 && args[0] == "--" {
+				args = args[1:]
+			}
+			for _, w := range c.complete(args) {
+				fmt.Println(w)
+			}
+		},
+	}
+	c.completionCmd.AddCommand(c.completionWordsCmd)
+}
+
+// positionalCompletions returns the kinds of dynamic completions of the
+// commands whose arguments are volumes.
+func (c *CLI) positionalCompletions() map[*cobra.Command]string {
+	return map[*cobra.Command]string{
+		c.volumeRemoveCmd:    completeVolumeIDs,
+		c.volumeAttachCmd:    completeVolumeIDs,
+		c.volumeAdoptCmd:     completeVolumeIDs,
+		c.volumeMountCmd:     completeVolumeNames,
+		c.volumeUnmountCmd:   completeVolumeNames,
+		c.volumePinCmd:       completeVolumeNames,
+		c.volumeUnpinCmd:     completeVolumeNames,
+		c.volumeProtectCmd:   completeVolumeNames,
+		c.volumeUnprotectCmd: completeVolumeNames,
+		c.snapshotRevertCmd:  completeSnapshotIDs,
+	}
+}
+
+// complete returns the completions of the last of the provided words, which
+// follow the program's name on the command line.
+func (c *CLI) complete(words []string) []string {
+	cur := ""
+	if len(words) > 0 {
+		cur, words = words[len(words)-1], words[:len(words)-1]
+	}
+
+	cmd := c.c
+	var prev *pflag.Flag
+	for _, w := range words {
+		if prev != nil {
+			prev = nil
+			continue
+		}
+		if strings.HasPrefix(w, "-") {
+			// the next word is the value of a flag that is not a bool and
+			// whose value is not joined to it with =
+			if f := lookupFlag(cmd, w); f != nil &&
+				f.Value.Type() != "bool" && !strings.Contains(w, "=") {
+				prev = f
+			}
+			continue
+		}
+		if sub := findSubCommand(cmd, w); sub != nil {
+			cmd = sub
+		}
+	}
+
+	if prev != nil {
+		if kind, ok := completionFlags[prev.Name]; ok {
+			return withPrefix(c.dynamicCompletions(kind), cur)
+		}
+		return nil
+	}
+
+	if strings.HasPrefix(cur, "-") {
+		names := []string{}
+		visit := func(f *pflag.Flag) {
+			names = append(names, "--"+f.Name)
+		}
+		cmd.Flags().VisitAll(visit)
+		cmd.InheritedFlags().VisitAll(visit)
+		return withPrefix(names, cur)
+	}
+
+	if cmd.HasSubCommands() {
+		names := []string{}
+		for _, sub := range cmd.Commands() {
+			if !sub.Hidden {
+				names = append(names, sub.Name())
+			}
+		}
+		return withPrefix(names, cur)
+	}
+
+	if kind, ok := c.positionalCompletions()[cmd]; ok {
+		return withPrefix(c.dynamicCompletions(kind), cur)
+	}
+	return nil
+}
+
+func lookupFlag(cmd *cobra.Command, word string) *pflag.Flag {
+	name := strings.TrimLeft(word, "-")
+	if i := strings.Index(name, "="); i >= 0 {
+		name = name[:i]
+	}
+	if f := cmd.Flags().Lookup(name); f != nil {
+		return f
+	}
+	if f := cmd.InheritedFlags().Lookup(name); f != nil {
+		return f
+	}
+	var short *pflag.Flag
+	if len(name) == 1 {
+		visit := func(f *pflag.Flag) {
+			if f.Shorthand == name {
+				short = f
+			}
+		}
+		cmd.Flags().VisitAll(visit)
+		cmd.InheritedFlags().VisitAll(visit)
+	}
+	return short
+}
+
+func findSubCommand(cmd *cobra.Command, name string) *cobra.Command {
+	for _, sub := range cmd.Commands() {
+		if sub.Name() == name || sub.HasAlias(name) {
+			return sub
+		}
+	}
+	return nil
+}
+
+func withPrefix(words []string, prefix string) []string {
+	matches := []string{}
+	for _, w := range words {
+		if strings.HasPrefix(w, prefix) {
+			matches = append(matches, w)
+		}
+	}
+	return matches
+}
+
+// dynamicCompletions queries the local service for the names or IDs of its
+// volumes or snapshots. Nothing is returned if the service is not running,
+// since completing a word must neither start an embedded server nor print
+// errors.
+func (c *CLI) dynamicCompletions(kind string) []string {
+	config := c.config.Scope("rexray")
+	if _, ok := util.IsLocalServerActive(c.ctx, config); !ok {
+		return nil
+	}
+	ctx, config, _, err := util.ActivateLibStorage(c.ctx, c.config)
+	if err != nil {
+		return nil
+	}
+	client, err := policy.New(ctx, config)
+	if err != nil {
+		return nil
+	}
+
+	words := []string{}
+	switch kind {
+	case completeVolumeIDs, completeVolumeNames:
+		vols, err := client.Storage().Volumes(
+			ctx, &apitypes.VolumesOpts{Attachments: false})
+		if err != nil {
+			return nil
+		}
+		for _, v := range vols {
+			if kind == completeVolumeIDs {
+				words = append(words, v.ID)
+			} else if v.Name != "" {
+				words = append(words, v.Name)
+			}
+		}
+	case completeSnapshotIDs, completeSnapshotNames:
+		snaps, err := client.Storage().Snapshots(ctx, store())
+		if err != nil {
+			return nil
+		}
+		for _, s := range snaps {
+			if kind == completeSnapshotIDs {
+				words = append(words, s.ID)
+			} else if s.Name != "" {
+				words = append(words, s.Name)
+			}
+		}
+	}
+	return words
+}
+
+var completionScripts = map[string]string{
+	"bash": `# bash completion for rexray
+
+_rexray() {
+    local IFS=$'\n'
+    COMPREPLY=( $(rexray completion words -- \
+        "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null) )
+}
+
+complete -o default -F _rexray rexray
+`,
+
+	"zsh": `#compdef rexray
+
+_rexray() {
+    local -a completions
+    completions=(${(f)"$(rexray completion words -- \
+        "${(@)words[2,CURRENT]}" 2>/dev/null)"})
+    compadd -a completions
+}
+
+compdef _rexray rexray
+`,
+
+	"fish": `# fish completion for rexray
+
+function __rexray_complete
+    set -l words (commandline -opc)
+    set -l cur (commandline -ct)
+    rexray completion words -- $words[2..-1] "$cur" 2>/dev/null
+end
+
+complete -c rexray -f -a '(__rexray_complete)'
+`,
+}
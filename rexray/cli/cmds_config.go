@@ -0,0 +1,83 @@
+package cli
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/spf13/cobra"
+
+	"github.com/emccode/rexray/core/schema"
+	"github.com/emccode/rexray/util"
+)
+
+func (c *CLI) initConfigCmdsAndFlags() {
+	c.initConfigCmds()
+	c.initConfigFlags()
+}
+
+func (c *CLI) initConfigCmds() {
+	c.configCmd = &cobra.Command{
+		Use:   "config",
+		Short: "Validate the configuration and describe its keys",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Usage()
+		},
+	}
+	c.c.AddCommand(c.configCmd)
+
+	c.configValidateCmd = &cobra.Command{
+		Use:   "validate [FILE]",
+		Short: "Validate a configuration file",
+		Long: `Validates a configuration file against the keys registered by REX-Ray and
+libStorage. Type mismatches and mutually exclusive options are reported as
+errors; unknown keys are reported as warnings. The file defaults to the one
+named by --config or the global configuration file.`,
+		Run: func(cmd *cobra.Command, args []string) {
+
+			path := c.cfgFile
+			if len(args) > 0 {
+				path = args[0]
+			}
+			if path == "" {
+				path = util.EtcFilePath("config.yml")
+			}
+
+			buf, err := ioutil.ReadFile(path)
+			if err != nil {
+				fatal(err)
+			}
+
+			problems, err := schema.Load(c.config).ValidateYAML(buf)
+			if err != nil {
+				fatal(err)
+			}
+
+			if len(problems) > 0 {
+				out, err := c.marshalOutput(problems)
+				if err != nil {
+					fatal(err)
+				}
+				fmt.Println(out)
+			}
+
+			if schema.HasErrors(problems) {
+				panic(1)
+			}
+		},
+	}
+	c.configCmd.AddCommand(c.configValidateCmd)
+
+	c.configSchemaCmd = &cobra.Command{
+		Use:   "schema",
+		Short: "Print the configuration schema as JSON Schema",
+		Run: func(cmd *cobra.Command, args []string) {
+			c.printIndentedOutput(cmd, schema.Load(c.config).JSONSchema())
+		},
+	}
+	c.configCmd.AddCommand(c.configSchemaCmd)
+}
+
+func (c *CLI) initConfigFlags() {
+	c.addOutputFormatFlag(c.configValidateCmd.Flags())
+	c.addOutputFormatFlagDefault(c.configSchemaCmd.Flags(), "json")
+}
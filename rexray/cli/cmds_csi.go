@@ -0,0 +1,30 @@
+package cli
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/emccode/rexray/daemon/module/csi"
+)
+
+func (c *CLI) initCSICmdsAndFlags() {
+	c.initCSICmds()
+}
+
+func (c *CLI) initCSICmds() {
+	c.csiCmd = &cobra.Command{
+		Use:   "csi",
+		Short: "Serve the CSI plug-in in the foreground",
+		Long: `Serves the CSI identity, controller, and node services at the
+address in the CSI_ENDPOINT environment variable. This command is intended
+to be launched and supervised by a container orchestrator such as the Mesos
+agent.`,
+		PersistentPreRun: c.preRunActivateLibStorage,
+		Run: func(cmd *cobra.Command, args []string) {
+			s := csi.NewServer(c.ctx, c.config, c.r)
+			if err := s.Serve(csi.Endpoint()); err != nil {
+				fatal(err)
+			}
+		},
+	}
+	c.c.AddCommand(c.csiCmd)
+}
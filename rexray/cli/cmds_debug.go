@@ -0,0 +1,92 @@
+package cli
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/spf13/cobra"
+
+	"github.com/emccode/rexray/core/capture"
+	"github.com/emccode/rexray/rexray/cli/timeutil"
+)
+
+func (c *CLI) initDebugCmdsAndFlags() {
+	c.initDebugCmds()
+	c.initDebugFlags()
+}
+
+func (c *CLI) initDebugCmds() {
+	c.debugCmd = &cobra.Command{
+		Use:   "debug",
+		Short: "Inspect the REX-Ray service for troubleshooting",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Usage()
+		},
+	}
+	c.c.AddCommand(c.debugCmd)
+
+	c.debugCaptureCmd = &cobra.Command{
+		Use:   "capture",
+		Short: "Print the captured provider API requests and responses",
+		Long: `Prints the requests the REX-Ray service's storage drivers made to their
+providers' APIs along with the responses, oldest first. Credentials and
+secrets are redacted. Requests are captured only for the services that set
+debug.capture.`,
+		Run: func(cmd *cobra.Command, args []string) {
+
+			all := []*capture.Exchange{}
+
+			if c.captureClear {
+				req, err := http.NewRequest(
+					"DELETE", "http://s/r/debug/capture", nil)
+				if err != nil {
+					fatal(err)
+				}
+				resp, err := newHTTPClient().Do(req)
+				if err != nil {
+					fatal(err)
+				}
+				defer resp.Body.Close()
+				if err := decodeJSON(resp.Body, &all); err != nil {
+					fatal(err)
+				}
+				return
+			}
+
+			u := "http://s/r/debug/capture"
+			if c.captureService != "" {
+				u = u + "?" + url.Values{"service": {c.captureService}}.Encode()
+			}
+			if err := getJSON(u, &all); err != nil {
+				fatal(err)
+			}
+
+			since, loc := c.timeFilter()
+			listed := []*capture.Exchange{}
+			for _, x := range all {
+				if x.Started.Before(since) {
+					continue
+				}
+				x.Started = timeutil.In(x.Started, loc)
+				listed = append(listed, x)
+			}
+
+			out, err := c.marshalOutput(listed)
+			if err != nil {
+				fatal(err)
+			}
+			fmt.Println(out)
+		},
+	}
+	c.debugCmd.AddCommand(c.debugCaptureCmd)
+}
+
+func (c *CLI) initDebugFlags() {
+	c.debugCaptureCmd.Flags().StringVar(&c.captureService, "service", "",
+		"Print only the exchanges of this libStorage service")
+	c.debugCaptureCmd.Flags().BoolVar(&c.captureClear, "clear", false,
+		"Discard the captured exchanges")
+	c.addOutputFormatFlag(c.debugCaptureCmd.Flags())
+	c.addTimeFlags(c.debugCaptureCmd.Flags())
+}
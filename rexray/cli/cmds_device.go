@@ -3,7 +3,6 @@ package cli
 import (
 	"fmt"
 
-	log "github.com/Sirupsen/logrus"
 	apitypes "github.com/emccode/libstorage/api/types"
 	"github.com/spf13/cobra"
 )
@@ -36,12 +35,12 @@ func (c *CLI) initDeviceCmds() {
 			mounts, err := c.r.OS().Mounts(
 				c.ctx, c.deviceName, c.mountPoint, store())
 			if err != nil {
-				log.Fatal(err)
+				fatal(err)
 			}
 
 			out, err := c.marshalOutput(&mounts)
 			if err != nil {
-				log.Fatal(err)
+				fatal(err)
 			}
 			fmt.Println(out)
 		},
@@ -53,7 +52,7 @@ func (c *CLI) initDeviceCmds() {
 		Run: func(cmd *cobra.Command, args []string) {
 
 			if c.deviceName == "" || c.mountPoint == "" {
-				log.Fatal("Missing --devicename and --mountpoint")
+				fatalf("Missing --devicename and --mountpoint")
 			}
 
 			// mountOptions = fmt.Sprintf("val,%s", mountOptions)
@@ -64,7 +63,7 @@ func (c *CLI) initDeviceCmds() {
 					MountLabel:   c.mountLabel,
 				})
 			if err != nil {
-				log.Fatal(err)
+				fatal(err)
 			}
 
 		},
@@ -76,12 +75,12 @@ func (c *CLI) initDeviceCmds() {
 		Run: func(cmd *cobra.Command, args []string) {
 
 			if c.mountPoint == "" {
-				log.Fatal("Missing --mountpoint")
+				fatalf("Missing --mountpoint")
 			}
 
 			err := c.r.OS().Unmount(c.ctx, c.mountPoint, store())
 			if err != nil {
-				log.Fatal(err)
+				fatal(err)
 			}
 
 		},
@@ -93,7 +92,7 @@ func (c *CLI) initDeviceCmds() {
 		Run: func(cmd *cobra.Command, args []string) {
 
 			if c.deviceName == "" {
-				log.Fatal("Missing --devicename")
+				fatalf("Missing --devicename")
 			}
 
 			if c.fsType == "" {
@@ -107,7 +106,7 @@ func (c *CLI) initDeviceCmds() {
 					OverwriteFS: c.overwriteFs,
 				})
 			if err != nil {
-				log.Fatal(err)
+				fatal(err)
 			}
 		},
 	}
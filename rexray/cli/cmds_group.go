@@ -0,0 +1,153 @@
+package cli
+
+import (
+	"fmt"
+
+	log "github.com/Sirupsen/logrus"
+	apitypes "github.com/emccode/libstorage/api/types"
+	"github.com/spf13/cobra"
+
+	"github.com/emccode/rexray/core/groups"
+	"github.com/emccode/rexray/core/state"
+)
+
+func (c *CLI) initGroupCmdsAndFlags() {
+	c.initGroupCmds()
+	c.initGroupFlags()
+}
+
+func (c *CLI) initGroupCmds() {
+	c.groupCmd = &cobra.Command{
+		Use:   "group",
+		Short: "Manage groups of volumes that are attached and mounted together",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Usage()
+		},
+	}
+	c.volumeCmd.AddCommand(c.groupCmd)
+
+	c.groupCreateCmd = &cobra.Command{
+		Use:   "create NAME VOLUME_NAME...",
+		Short: "Create or replace a group of volumes",
+		Long: `Creates a group of volumes, or replaces the group if it exists. The volumes
+are mounted in the order they are listed and unmounted in the reverse order.`,
+		Run: func(cmd *cobra.Command, args []string) {
+
+			if len(args) < 2 {
+				fatalf("Missing group name or volumes")
+			}
+
+			g, err := groups.Set(state.Default(), args[0], args[1:])
+			if err != nil {
+				fatal(err)
+			}
+			c.printGroupOutput(g)
+		},
+	}
+	c.groupCmd.AddCommand(c.groupCreateCmd)
+
+	c.groupListCmd = &cobra.Command{
+		Use:     "ls",
+		Short:   "List the groups of volumes",
+		Aliases: []string{"list"},
+		Run: func(cmd *cobra.Command, args []string) {
+
+			all, err := groups.List(state.Default())
+			if err != nil {
+				fatal(err)
+			}
+			c.printGroupOutput(all)
+		},
+	}
+	c.groupCmd.AddCommand(c.groupListCmd)
+
+	c.groupRemoveCmd = &cobra.Command{
+		Use:     "rm NAME",
+		Short:   "Remove a group without affecting its volumes",
+		Aliases: []string{"remove"},
+		Run: func(cmd *cobra.Command, args []string) {
+
+			if len(args) != 1 {
+				fatalf("Missing group name")
+			}
+			if err := groups.Remove(state.Default(), args[0]); err != nil {
+				fatal(err)
+			}
+		},
+	}
+	c.groupCmd.AddCommand(c.groupRemoveCmd)
+
+	c.groupAttachCmd = &cobra.Command{
+		Use:   "attach NAME",
+		Short: "Attach all of a group's volumes or none of them",
+		Run: func(cmd *cobra.Command, args []string) {
+			c.runGroupOp(args, groups.Attach)
+		},
+	}
+	c.groupCmd.AddCommand(c.groupAttachCmd)
+
+	c.groupMountCmd = &cobra.Command{
+		Use:   "mount NAME",
+		Short: "Mount all of a group's volumes in order or none of them",
+		Run: func(cmd *cobra.Command, args []string) {
+			c.runGroupOp(args, groups.Mount)
+		},
+	}
+	c.groupCmd.AddCommand(c.groupMountCmd)
+
+	c.groupUnmountCmd = &cobra.Command{
+		Use:   "unmount NAME",
+		Short: "Unmount a group's volumes in the reverse order",
+		Run: func(cmd *cobra.Command, args []string) {
+			c.runGroupOp(args, groups.Unmount)
+		},
+	}
+	c.groupCmd.AddCommand(c.groupUnmountCmd)
+}
+
+func (c *CLI) initGroupFlags() {
+	c.addOutputFormatFlag(c.groupCreateCmd.Flags())
+	c.addOutputFormatFlag(c.groupListCmd.Flags())
+	c.addOutputFormatFlag(c.groupAttachCmd.Flags())
+	c.addOutputFormatFlag(c.groupMountCmd.Flags())
+	c.addOutputFormatFlag(c.groupUnmountCmd.Flags())
+}
+
+// runGroupOp runs an operation on the named group's volumes and prints the
+// result of each volume's operation. The command exits with a non-zero code
+// if the operation failed.
+func (c *CLI) runGroupOp(
+	args []string,
+	op func(apitypes.Context, apitypes.Client, *groups.Group) (
+		[]*groups.Result, error)) {
+
+	if len(args) != 1 {
+		fatalf("Missing group name")
+	}
+
+	g, err := groups.Get(state.Default(), args[0])
+	if err != nil {
+		fatal(err)
+	}
+
+	results, opErr := op(c.ctx, c.r, g)
+	if gerr, ok := opErr.(*groups.Error); ok {
+		results = gerr.Results
+	} else if opErr != nil {
+		fatal(opErr)
+	}
+
+	c.printGroupOutput(results)
+	if opErr != nil {
+		log.Error(opErr)
+		panic(1)
+	}
+}
+
+func (c *CLI) printGroupOutput(v interface{}) {
+	out, err := c.marshalOutput(v)
+	if err != nil {
+		fatal(err)
+	}
+	fmt.Println(out)
+}
@@ -0,0 +1,84 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/emccode/rexray/core/maintenance"
+	"github.com/emccode/rexray/core/state"
+)
+
+func (c *CLI) initMaintenanceCmdsAndFlags() {
+	c.initMaintenanceCmds()
+	c.initMaintenanceFlags()
+}
+
+func (c *CLI) initMaintenanceCmds() {
+	c.maintenanceCmd = &cobra.Command{
+		Use:   "maintenance",
+		Short: "List the libStorage services in maintenance",
+		Run: func(cmd *cobra.Command, args []string) {
+
+			modes, err := maintenance.List(state.Controller())
+			if err != nil {
+				fatal(err)
+			}
+
+			out, err := c.marshalOutput(modes)
+			if err != nil {
+				fatal(err)
+			}
+			fmt.Println(out)
+		},
+	}
+	c.serviceCmd.AddCommand(c.maintenanceCmd)
+
+	c.maintenanceEnableCmd = &cobra.Command{
+		Use:   "enable NAME",
+		Short: "Refuse new volume creations and mounts on a libStorage service",
+		Run: func(cmd *cobra.Command, args []string) {
+
+			if len(args) != 1 {
+				fatalf("Missing service name")
+			}
+
+			m, err := maintenance.Enable(
+				c.config, state.Controller(), args[0], c.maintenanceMessage)
+			if err != nil {
+				fatal(err)
+			}
+
+			out, err := c.marshalOutput(m)
+			if err != nil {
+				fatal(err)
+			}
+			fmt.Println(out)
+		},
+	}
+	c.maintenanceCmd.AddCommand(c.maintenanceEnableCmd)
+
+	c.maintenanceDisableCmd = &cobra.Command{
+		Use:   "disable NAME",
+		Short: "End the maintenance of a libStorage service",
+		Run: func(cmd *cobra.Command, args []string) {
+
+			if len(args) != 1 {
+				fatalf("Missing service name")
+			}
+
+			if err := maintenance.Disable(
+				state.Controller(), args[0]); err != nil {
+				fatal(err)
+			}
+		},
+	}
+	c.maintenanceCmd.AddCommand(c.maintenanceDisableCmd)
+}
+
+func (c *CLI) initMaintenanceFlags() {
+	c.maintenanceEnableCmd.Flags().StringVar(&c.maintenanceMessage, "message",
+		"", "The message with which operations are refused")
+	c.addOutputFormatFlag(c.maintenanceCmd.Flags())
+	c.addOutputFormatFlag(c.maintenanceEnableCmd.Flags())
+}
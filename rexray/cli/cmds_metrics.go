@@ -0,0 +1,57 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/emccode/rexray/core/metrics"
+)
+
+func (c *CLI) initMetricsCmdsAndFlags() {
+	c.initMetricsCmds()
+	c.initMetricsFlags()
+}
+
+func (c *CLI) initMetricsCmds() {
+	c.metricsCmd = &cobra.Command{
+		Use:   "metrics",
+		Short: "Generate monitoring assets for the service's metrics",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Usage()
+		},
+	}
+	c.c.AddCommand(c.metricsCmd)
+
+	c.metricsRulesCmd = &cobra.Command{
+		Use:   "rules",
+		Short: "Print the Prometheus alerting rules",
+		Long: `Prints the Prometheus alerting rules for the metrics the service exposes at
+the admin module's /metrics route. The agent heartbeat alert is derived from
+rexray.nodes.heartbeat.interval.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			out, err := c.marshalOutput(metrics.Rules(c.config))
+			if err != nil {
+				fatal(err)
+			}
+			fmt.Println(out)
+		},
+	}
+	c.metricsCmd.AddCommand(c.metricsRulesCmd)
+
+	c.metricsDashboardCmd = &cobra.Command{
+		Use:   "dashboard",
+		Short: "Print the Grafana dashboard",
+		Long: `Prints a Grafana dashboard as JSON. The dashboard's Prometheus data source is
+chosen when the dashboard is imported.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			c.printIndentedOutput(cmd, metrics.Dashboard())
+		},
+	}
+	c.metricsCmd.AddCommand(c.metricsDashboardCmd)
+}
+
+func (c *CLI) initMetricsFlags() {
+	c.addOutputFormatFlag(c.metricsRulesCmd.Flags())
+	c.addOutputFormatFlagDefault(c.metricsDashboardCmd.Flags(), "json")
+}
@@ -1,11 +1,13 @@
 package cli
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net"
 	"net/http"
 	"net/url"
+	"strings"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -39,13 +41,7 @@ func (c *CLI) initModuleCmds() {
 				panic(respErr)
 			}
 
-			defer resp.Body.Close()
-			body, bodyErr := ioutil.ReadAll(resp.Body)
-			if bodyErr != nil {
-				panic(bodyErr)
-			}
-
-			fmt.Println(string(body))
+			c.printModuleResponse(cmd, resp)
 		},
 	}
 	c.moduleCmd.AddCommand(c.moduleTypesCmd)
@@ -73,13 +69,7 @@ func (c *CLI) initModuleCmds() {
 				panic(respErr)
 			}
 
-			defer resp.Body.Close()
-			body, bodyErr := ioutil.ReadAll(resp.Body)
-			if bodyErr != nil {
-				panic(bodyErr)
-			}
-
-			fmt.Println(string(body))
+			c.printModuleResponse(cmd, resp)
 		},
 	}
 	c.moduleInstancesCmd.AddCommand(c.moduleInstancesListCmd)
@@ -126,13 +116,7 @@ func (c *CLI) initModuleCmds() {
 				panic(respErr)
 			}
 
-			defer resp.Body.Close()
-			body, bodyErr := ioutil.ReadAll(resp.Body)
-			if bodyErr != nil {
-				panic(bodyErr)
-			}
-
-			fmt.Println(string(body))
+			c.printModuleResponse(cmd, resp)
 		},
 	}
 	c.moduleInstancesCmd.AddCommand(c.moduleInstancesCreateCmd)
@@ -156,19 +140,48 @@ func (c *CLI) initModuleCmds() {
 				panic(respErr)
 			}
 
-			defer resp.Body.Close()
-			body, bodyErr := ioutil.ReadAll(resp.Body)
-			if bodyErr != nil {
-				panic(bodyErr)
-			}
-
-			fmt.Println(string(body))
+			c.printModuleResponse(cmd, resp)
 		},
 	}
 	c.moduleInstancesCmd.AddCommand(c.moduleInstancesStartCmd)
 }
 
+// printModuleResponse prints the JSON body of a response from the admin
+// module in the format requested with --format, which defaults to json.
+func (c *CLI) printModuleResponse(cmd *cobra.Command, resp *http.Response) {
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		panic(err)
+	}
+
+	c.readOutputFormatFlag(cmd)
+	if strings.ToUpper(c.outputFormat) == "JSON" {
+		fmt.Println(string(body))
+		return
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		fatal(err)
+	}
+	out, err := c.marshalOutput(v)
+	if err != nil {
+		fatal(err)
+	}
+	fmt.Println(out)
+}
+
 func (c *CLI) initModuleFlags() {
+	for _, cmd := range []*cobra.Command{
+		c.moduleTypesCmd,
+		c.moduleInstancesListCmd,
+		c.moduleInstancesCreateCmd,
+		c.moduleInstancesStartCmd,
+	} {
+		c.addOutputFormatFlagDefault(cmd.Flags(), "json")
+	}
+
 	c.moduleInstancesCreateCmd.Flags().StringVarP(&c.moduleTypeName, "typeName",
 		"t", "", "The name of the module type to instance")
 
@@ -0,0 +1,133 @@
+package cli
+
+import (
+	"fmt"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/core/nodes"
+	"github.com/emccode/rexray/core/state"
+	"github.com/emccode/rexray/rexray/cli/timeutil"
+)
+
+// nodeStatus is a node as printed by the node commands.
+type nodeStatus struct {
+	nodes.Node  `yaml:",inline"`
+	ConfigDrift bool `json:"configDrift" yaml:"configDrift"`
+}
+
+func (c *CLI) initNodeCmdsAndFlags() {
+	c.initNodeCmds()
+	c.initNodeFlags()
+}
+
+func (c *CLI) initNodeCmds() {
+	c.nodeCmd = &cobra.Command{
+		Use:   "node",
+		Short: "The node manager",
+		Run: func(cmd *cobra.Command, args []string) {
+			if isHelpFlags(cmd) {
+				cmd.Usage()
+			} else {
+				c.nodeListCmd.Run(c.nodeListCmd, args)
+			}
+		},
+	}
+	c.c.AddCommand(c.nodeCmd)
+
+	c.nodeListCmd = &cobra.Command{
+		Use:     "ls",
+		Short:   "List the nodes whose agents have reported",
+		Aliases: []string{"get", "list"},
+		Run: func(cmd *cobra.Command, args []string) {
+
+			all, err := nodes.List(state.Controller())
+			if err != nil {
+				fatal(err)
+			}
+
+			expected, err := nodes.ExpectedConfigDigest(c.config)
+			if err != nil {
+				fatal(err)
+			}
+
+			since, loc := c.timeFilter()
+
+			statuses := []*nodeStatus{}
+			for _, n := range all {
+				if n.Heartbeat.Before(since) {
+					continue
+				}
+				n.Heartbeat = timeutil.In(n.Heartbeat, loc)
+				s := &nodeStatus{
+					Node:        *n,
+					ConfigDrift: n.ConfigDigest != expected,
+				}
+				if c.configDrift && !s.ConfigDrift {
+					continue
+				}
+				statuses = append(statuses, s)
+			}
+
+			if c.configDrift && len(statuses) > 0 {
+				log.WithFields(log.Fields{
+					"nodes":    len(statuses),
+					"expected": expected,
+				}).Warn("nodes with configuration drift")
+			}
+
+			out, err := c.marshalOutput(statuses)
+			if err != nil {
+				fatal(err)
+			}
+			fmt.Println(out)
+		},
+	}
+	c.nodeCmd.AddCommand(c.nodeListCmd)
+
+	c.nodeAttachmentsCmd = &cobra.Command{
+		Use:              "attachments [NODE]",
+		Short:            "List the volumes attached to or mounted on each node",
+		PersistentPreRun: c.preRunActivateLibStorage,
+		Run: func(cmd *cobra.Command, args []string) {
+
+			vols, err := c.r.Storage().Volumes(
+				c.ctx, &apitypes.VolumesOpts{Attachments: true})
+			if err != nil {
+				fatal(err)
+			}
+			all, err := nodes.List(state.Controller())
+			if err != nil {
+				fatal(err)
+			}
+
+			nas := []*nodes.NodeAttachments{}
+			for _, na := range nodes.Attachments(vols, all) {
+				if len(args) > 0 && !na.Matches(args[0]) {
+					continue
+				}
+				nas = append(nas, na)
+			}
+
+			out, err := c.marshalOutput(nas)
+			if err != nil {
+				fatal(err)
+			}
+			fmt.Println(out)
+		},
+	}
+	c.nodeCmd.AddCommand(c.nodeAttachmentsCmd)
+}
+
+func (c *CLI) initNodeFlags() {
+	c.nodeListCmd.Flags().BoolVar(&c.configDrift, "config-drift", false,
+		"List only the nodes whose config digest differs from the expected digest")
+	c.addOutputFormatFlag(c.nodeCmd.Flags())
+	c.addTimeFlags(c.nodeCmd.Flags())
+	c.addOutputFormatFlag(c.nodeListCmd.Flags())
+	c.addTimeFlags(c.nodeListCmd.Flags())
+	c.addOutputFormatFlag(c.nodeAttachmentsCmd.Flags())
+}
@@ -3,9 +3,17 @@ package cli
 import (
 	"fmt"
 	"os"
+	"strings"
 
+	log "github.com/Sirupsen/logrus"
+	"github.com/akutz/gofig"
 	"github.com/spf13/cobra"
 
+	apiversion "github.com/emccode/libstorage/api"
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/core"
+	"github.com/emccode/rexray/core/ebs"
 	"github.com/emccode/rexray/util"
 )
 
@@ -19,7 +27,19 @@ func (c *CLI) initOtherCmds() {
 		Use:   "version",
 		Short: "Print the version",
 		Run: func(cmd *cobra.Command, args []string) {
-			util.PrintVersion(os.Stdout)
+			c.readOutputFormatFlag(cmd)
+			if c.outputFormat == textFormat {
+				util.PrintVersion(os.Stdout)
+				return
+			}
+			out, err := c.marshalOutput(map[string]*apitypes.VersionInfo{
+				"rexray":     core.Version,
+				"libStorage": apiversion.Version,
+			})
+			if err != nil {
+				fatal(err)
+			}
+			fmt.Println(out)
 		},
 	}
 	c.c.AddCommand(c.versionCmd)
@@ -29,9 +49,29 @@ func (c *CLI) initOtherCmds() {
 		Short: "Print the REX-Ray environment",
 		Run: func(cmd *cobra.Command, args []string) {
 			evs := c.config.EnvVars()
+			if ebs.IsConfigured(c.config) {
+				evs = append(evs, ebsEnv(c.config)...)
+			}
+
+			c.readOutputFormatFlag(cmd)
+			if c.outputFormat == textFormat {
+				for _, ev := range evs {
+					fmt.Println(ev)
+				}
+				return
+			}
+
+			m := map[string]string{}
 			for _, ev := range evs {
-				fmt.Println(ev)
+				if i := strings.Index(ev, "="); i > 0 {
+					m[ev[:i]] = ev[i+1:]
+				}
+			}
+			out, err := c.marshalOutput(m)
+			if err != nil {
+				fatal(err)
 			}
+			fmt.Println(out)
 		},
 	}
 	c.c.AddCommand(c.envCmd)
@@ -40,7 +80,7 @@ func (c *CLI) initOtherCmds() {
 		Use:   "install",
 		Short: "Install REX-Ray",
 		Run: func(cmd *cobra.Command, args []string) {
-			install()
+			c.install()
 		},
 	}
 	c.c.AddCommand(c.installCmd)
@@ -50,12 +90,32 @@ func (c *CLI) initOtherCmds() {
 		Short: "Uninstall REX-Ray",
 		Run: func(cmd *cobra.Command, args []string) {
 			pkgManager, _ := cmd.Flags().GetBool("package")
-			uninstall(pkgManager)
+			c.uninstall(pkgManager)
 		},
 	}
 	c.c.AddCommand(c.uninstallCmd)
 }
 
+// ebsEnv returns the region and availability zone detected from the EC2
+// instance metadata service, and the version of the service that was used.
+func ebsEnv(config gofig.Config) []string {
+	m := ebs.NewMetadataFromConfig(config)
+	id, err := m.Identity()
+	if err != nil {
+		log.WithError(err).Debug("error reading instance identity")
+		return nil
+	}
+	imds := "v1"
+	if m.IMDSv2() {
+		imds = "v2"
+	}
+	return []string{
+		"REXRAY_EBS_REGION=" + id.Region,
+		"REXRAY_EBS_AVAILABILITYZONE=" + id.AvailabilityZone,
+		"REXRAY_EBS_IMDS=" + imds,
+	}
+}
+
 func (c *CLI) initOtherFlags() {
 	cobra.HelpFlagShorthand = "?"
 	cobra.HelpFlagUsageFormatString = "Help for %s"
@@ -74,4 +134,18 @@ func (c *CLI) initOtherFlags() {
 
 	c.uninstallCmd.Flags().Bool("package", false,
 		"A flag indicating a package manager is performing the uninstallation")
+
+	for _, cmd := range []*cobra.Command{c.installCmd, c.uninstallCmd} {
+		cmd.Flags().StringVar(&c.installName, "name", "",
+			"The name of an instance of the service, which has its own "+
+				"service and configuration")
+		cmd.Flags().StringVar(&c.installInit, "init", "",
+			"The init system: systemd, openrc, or sysv; defaults to the "+
+				"detected init system")
+		cmd.Flags().BoolVar(&c.installUser, "user", false,
+			"Install the service as a systemd service of the current user")
+	}
+
+	c.addOutputFormatFlagDefault(c.versionCmd.Flags(), textFormat)
+	c.addOutputFormatFlagDefault(c.envCmd.Flags(), textFormat)
 }
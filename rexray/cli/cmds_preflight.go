@@ -0,0 +1,44 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/emccode/rexray/core/preflight"
+)
+
+func (c *CLI) initPreflightCmdsAndFlags() {
+	c.initPreflightCmds()
+	c.initPreflightFlags()
+}
+
+func (c *CLI) initPreflightCmds() {
+	c.preflightCmd = &cobra.Command{
+		Use:   "preflight",
+		Short: "Check that this host is able to run the configured services",
+		Long: `Checks the kernel modules, binaries, and backends required by the configured
+services as well as the permissions of the run and socket directories. These
+are the same checks the service performs before it starts. The command exits
+with a non-zero code if a check fails.`,
+		Run: func(cmd *cobra.Command, args []string) {
+
+			r := preflight.Run(c.config)
+
+			out, err := c.marshalOutput(r)
+			if err != nil {
+				fatal(err)
+			}
+			fmt.Println(out)
+
+			if !r.Passed {
+				panic(1)
+			}
+		},
+	}
+	c.c.AddCommand(c.preflightCmd)
+}
+
+func (c *CLI) initPreflightFlags() {
+	c.addOutputFormatFlag(c.preflightCmd.Flags())
+}
@@ -0,0 +1,63 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/core/quota"
+	"github.com/emccode/rexray/core/state"
+)
+
+func (c *CLI) initQuotaCmdsAndFlags() {
+	c.initQuotaCmds()
+	c.initQuotaFlags()
+}
+
+func (c *CLI) initQuotaCmds() {
+	c.quotaCmd = &cobra.Command{
+		Use:              "quota",
+		Short:            "The volume quota manager",
+		PersistentPreRun: c.preRunActivateLibStorage,
+		Run: func(cmd *cobra.Command, args []string) {
+			if isHelpFlags(cmd) {
+				cmd.Usage()
+			} else {
+				c.quotaStatusCmd.Run(c.quotaStatusCmd, args)
+			}
+		},
+	}
+	c.c.AddCommand(c.quotaCmd)
+
+	c.quotaStatusCmd = &cobra.Command{
+		Use:   "status",
+		Short: "Print the usage and limit of each configured quota",
+		Run: func(cmd *cobra.Command, args []string) {
+
+			vols, err := c.r.Storage().Volumes(
+				c.ctx, &apitypes.VolumesOpts{Attachments: false})
+			if err != nil {
+				fatal(err)
+			}
+
+			usage, err := quota.Status(c.config, state.Controller(), vols)
+			if err != nil {
+				fatal(err)
+			}
+
+			out, err := c.marshalOutput(usage)
+			if err != nil {
+				fatal(err)
+			}
+			fmt.Println(out)
+		},
+	}
+	c.quotaCmd.AddCommand(c.quotaStatusCmd)
+}
+
+func (c *CLI) initQuotaFlags() {
+	c.addOutputFormatFlag(c.quotaCmd.Flags())
+	c.addOutputFormatFlag(c.quotaStatusCmd.Flags())
+}
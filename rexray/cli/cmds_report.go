@@ -0,0 +1,120 @@
+package cli
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/emccode/rexray/core/state"
+	"github.com/emccode/rexray/core/usage"
+	"github.com/emccode/rexray/rexray/cli/timeutil"
+)
+
+func (c *CLI) initReportCmdsAndFlags() {
+	c.initReportCmds()
+	c.initReportFlags()
+}
+
+func (c *CLI) initReportCmds() {
+	c.reportCmd = &cobra.Command{
+		Use:   "report",
+		Short: "Report on the use of storage",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Usage()
+		},
+	}
+	c.c.AddCommand(c.reportCmd)
+
+	c.reportUsageCmd = &cobra.Command{
+		Use:   "usage",
+		Short: "Report the GiB-hours of volumes and snapshots by group",
+		Long: `Aggregates the usage samples recorded by the REX-Ray service into the
+GiB-hours of the volumes and snapshots of each group for the period from
+--from to --to. Usage is only sampled if rexray.usage.enabled is true. The
+group is one of tenant, principal, service, volume, or label:KEY.`,
+		Run: func(cmd *cobra.Command, args []string) {
+
+			if err := usage.ValidGroupBy(c.groupBy); err != nil {
+				fatal(err)
+			}
+			loc, err := timeutil.Location(c.timeZone)
+			if err != nil {
+				fatal(err)
+			}
+			now := time.Now()
+			from, err := timeutil.ParseSince(c.reportFrom, now, loc)
+			if err != nil {
+				fatal(err)
+			}
+			to, err := timeutil.ParseSince(c.reportTo, now, loc)
+			if err != nil {
+				fatal(err)
+			}
+
+			samples, err := usage.Samples(state.Default(), from, to)
+			if err != nil {
+				fatal(err)
+			}
+			rows, err := usage.Report(c.config, samples, c.groupBy)
+			if err != nil {
+				fatal(err)
+			}
+
+			if strings.ToLower(c.outputFormat) == "csv" {
+				if err := writeUsageCSV(rows); err != nil {
+					fatal(err)
+				}
+				return
+			}
+			out, err := c.marshalOutput(rows)
+			if err != nil {
+				fatal(err)
+			}
+			fmt.Println(out)
+		},
+	}
+	c.reportCmd.AddCommand(c.reportUsageCmd)
+}
+
+func (c *CLI) initReportFlags() {
+	c.reportUsageCmd.Flags().StringVar(&c.reportFrom, "from", "30d",
+		"The start of the period, ex. 30d or 2016-06-01")
+	c.reportUsageCmd.Flags().StringVar(&c.reportTo, "to", "",
+		"The end of the period, ex. 2016-07-01; defaults to now")
+	c.reportUsageCmd.Flags().StringVar(&c.groupBy, "group-by", usage.GroupTenant,
+		"How usage is grouped: tenant, principal, service, volume, or "+
+			"label:KEY")
+	c.reportUsageCmd.Flags().StringVar(&c.timeZone, "time-zone", "",
+		"The time zone of dates without an offset; defaults to UTC")
+	c.reportUsageCmd.Flags().StringVarP(&c.outputFormat, "format", "f", "yml",
+		"The output format (yml, json, csv, or a Go template such as "+
+			"'{{.Group}}\\t{{.VolumeGiBHours}}')")
+}
+
+// writeUsageCSV writes usage rows to stdout as CSV with a header.
+func writeUsageCSV(rows []*usage.Row) error {
+	w := csv.NewWriter(os.Stdout)
+	if err := w.Write([]string{
+		"group", "volumeGiBHours", "snapshotGiBHours", "volumes", "snapshots",
+	}); err != nil {
+		return err
+	}
+	for _, r := range rows {
+		if err := w.Write([]string{
+			r.Group,
+			strconv.FormatFloat(r.VolumeGiBHours, 'f', 2, 64),
+			strconv.FormatFloat(r.SnapshotGiBHours, 'f', 2, 64),
+			strconv.Itoa(r.Volumes),
+			strconv.Itoa(r.Snapshots),
+		}); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
@@ -2,9 +2,25 @@ package cli
 
 import (
 	"fmt"
+	"os"
+	"time"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/spf13/cobra"
+
+	"github.com/emccode/rexray/core/events"
+	"github.com/emccode/rexray/core/labels"
+	"github.com/emccode/rexray/core/policy"
+	"github.com/emccode/rexray/core/revert"
+	"github.com/emccode/rexray/core/snapcopy"
+	"github.com/emccode/rexray/core/state"
+)
+
+const (
+	// storageLocationKey is the name of the option used to request the
+	// region or multi-region in which a snapshot is stored, ex. us or
+	// europe-west1 for a GCE persistent disk snapshot.
+	storageLocationKey = "storageLocation"
 )
 
 func (c *CLI) initSnapshotCmdsAndFlags() {
@@ -36,13 +52,13 @@ func (c *CLI) initSnapshotCmds() {
 
 			allSnapshots, err := c.r.Storage().Snapshots(c.ctx, store())
 			if err != nil {
-				log.Fatal(err)
+				fatal(err)
 			}
 
 			if len(allSnapshots) > 0 {
 				out, err := c.marshalOutput(&allSnapshots)
 				if err != nil {
-					log.Fatal(err)
+					fatal(err)
 				}
 				fmt.Println(out)
 			}
@@ -57,18 +73,30 @@ func (c *CLI) initSnapshotCmds() {
 		Run: func(cmd *cobra.Command, args []string) {
 
 			if c.volumeID == "" {
-				log.Fatalf("missing --volumeid")
+				fatalf("missing --volumeid")
+			}
+
+			opts := store()
+			if len(c.labels) > 0 {
+				l, err := labels.Parse(c.labels)
+				if err != nil {
+					fatal(err)
+				}
+				opts.Set(labels.OptKey, l.String())
+			}
+			if c.storageLocation != "" {
+				opts.Set(storageLocationKey, c.storageLocation)
 			}
 
 			snapshot, err := c.r.Storage().VolumeSnapshot(
-				c.ctx, c.volumeID, c.snapshotName, store())
+				c.ctx, c.volumeID, c.snapshotName, opts)
 			if err != nil {
-				log.Fatal(err)
+				fatal(err)
 			}
 
 			out, err := c.marshalOutput(&snapshot)
 			if err != nil {
-				log.Fatal(err)
+				fatal(err)
 			}
 			fmt.Println(out)
 
@@ -83,12 +111,12 @@ func (c *CLI) initSnapshotCmds() {
 		Run: func(cmd *cobra.Command, args []string) {
 
 			if c.snapshotID == "" {
-				log.Fatalf("missing --snapshotid")
+				fatalf("missing --snapshotid")
 			}
 
 			err := c.r.Storage().SnapshotRemove(c.ctx, c.snapshotID, store())
 			if err != nil {
-				log.Fatal(err)
+				fatal(err)
 			}
 
 		},
@@ -96,29 +124,128 @@ func (c *CLI) initSnapshotCmds() {
 	c.snapshotCmd.AddCommand(c.snapshotRemoveCmd)
 
 	c.snapshotCopyCmd = &cobra.Command{
-		Use:   "copy",
+		Use:   "copy [SNAPSHOT_ID]",
 		Short: "Copies a snapshot",
+		Long: `Copies a snapshot. A snapshot is copied to another region with --region,
+which is supported by the ebs, gcepd, and azureud drivers. The copy is tagged
+with the ID and region of its source, and is encrypted with --encryptionkey if
+it is provided: a KMS key ARN for EBS, a Cloud KMS key name for GCE, or a disk
+encryption set ID for Azure. The copy's progress is printed until it completes
+unless --runasync is set; "snapshot copies" prints the progress of every copy.`,
 		Run: func(cmd *cobra.Command, args []string) {
 
+			if len(args) > 0 {
+				c.snapshotID = args[0]
+			}
+
+			if c.destinationRegion != "" {
+				c.copySnapshotToRegion()
+				return
+			}
+
 			if c.snapshotID == "" && c.volumeID == "" && c.volumeName == "" {
-				log.Fatalf("missing --volumeid or --snapshotid or --volumename")
+				fatalf("missing --volumeid or --snapshotid or --volumename")
 			}
 
 			snapshot, err := c.r.Storage().SnapshotCopy(
 				c.ctx, c.snapshotID, c.snapshotName,
 				c.destinationRegion, store())
 			if err != nil {
-				log.Fatal(err)
+				fatal(err)
 			}
 
 			out, err := c.marshalOutput(&snapshot)
 			if err != nil {
-				log.Fatal(err)
+				fatal(err)
 			}
 			fmt.Println(out)
 		},
 	}
 	c.snapshotCmd.AddCommand(c.snapshotCopyCmd)
+
+	c.snapshotCopiesCmd = &cobra.Command{
+		Use:   "copies",
+		Short: "List the copies of snapshots to other regions",
+		Run: func(cmd *cobra.Command, args []string) {
+			all, err := snapcopy.List(state.Default())
+			if err != nil {
+				fatal(err)
+			}
+			for _, cp := range all {
+				if err := snapcopy.Refresh(
+					c.ctx, c.config, state.Default(), cp); err != nil {
+					log.WithError(err).WithField("copyID", cp.ID).Warn(
+						"error refreshing snapshot copy")
+				}
+			}
+			out, err := c.marshalOutput(&all)
+			if err != nil {
+				fatal(err)
+			}
+			fmt.Println(out)
+		},
+	}
+	c.snapshotCmd.AddCommand(c.snapshotCopiesCmd)
+
+	c.snapshotRevertCmd = &cobra.Command{
+		Use:   "revert [SNAPSHOT_ID]",
+		Short: "Revert a volume to one of its snapshots in place",
+		Long: `Reverts a volume to one of its snapshots in place with the storage
+platform's native rollback, so that the volume keeps its ID and name. The
+volume defaults to the one from which the snapshot was taken and must be
+detached. Drivers that cannot revert volumes natively, such as EBS, fail;
+create a new volume from the snapshot with "volume create --snapshotid"
+instead.`,
+		Run: func(cmd *cobra.Command, args []string) {
+
+			snapshotID := c.snapshotID
+			if len(args) > 0 {
+				snapshotID = args[0]
+			}
+			if snapshotID == "" {
+				fatalf("missing --snapshotid")
+			}
+
+			volumeID := c.volumeID
+			if volumeID == "" && c.volumeName != "" {
+				id, err := c.lookupVolumeID("", c.volumeName)
+				if err != nil {
+					fatal(err)
+				}
+				volumeID = id
+			}
+
+			driver, err := policy.ServiceDriverName(c.ctx, c.config, c.r)
+			if err != nil {
+				fatal(err)
+			}
+
+			vol, err := revert.Revert(
+				c.ctx, c.config, c.r, driver, snapshotID, volumeID)
+			if err != nil {
+				fatal(err)
+			}
+
+			if err := events.Emit(state.Default(), &events.Event{
+				Type:     events.VolumeReverted,
+				VolumeID: vol.ID,
+				Message:  "volume reverted to snapshot",
+				Fields: map[string]string{
+					"volumeName": vol.Name,
+					"snapshotID": snapshotID,
+				},
+			}); err != nil {
+				log.WithError(err).Warn("error recording event")
+			}
+
+			out, err := c.marshalOutput(&vol)
+			if err != nil {
+				fatal(err)
+			}
+			fmt.Println(out)
+		},
+	}
+	c.snapshotCmd.AddCommand(c.snapshotRevertCmd)
 }
 
 func (c *CLI) initSnapshotFlags() {
@@ -129,6 +256,8 @@ func (c *CLI) initSnapshotFlags() {
 	c.snapshotCreateCmd.Flags().StringVar(&c.snapshotName, "snapshotname", "", "snapshotname")
 	c.snapshotCreateCmd.Flags().StringVar(&c.volumeID, "volumeid", "", "volumeid")
 	c.snapshotCreateCmd.Flags().StringVar(&c.description, "description", "", "description")
+	c.snapshotCreateCmd.Flags().StringSliceVar(&c.labels, "label", nil, "A label to apply in addition to the volume's labels, ex. env=prod")
+	c.snapshotCreateCmd.Flags().StringVar(&c.storageLocation, "storagelocation", "", "The region or multi-region in which to store the snapshot")
 	c.snapshotRemoveCmd.Flags().StringVar(&c.snapshotID, "snapshotid", "", "snapshotid")
 	c.snapshotCopyCmd.Flags().BoolVar(&c.runAsync, "runasync", false, "runasync")
 	c.snapshotCopyCmd.Flags().StringVar(&c.volumeID, "volumeid", "", "volumeid")
@@ -136,9 +265,77 @@ func (c *CLI) initSnapshotFlags() {
 	c.snapshotCopyCmd.Flags().StringVar(&c.snapshotName, "snapshotname", "", "snapshotname")
 	c.snapshotCopyCmd.Flags().StringVar(&c.destinationSnapshotName, "destinationsnapshotname", "", "destinationsnapshotname")
 	c.snapshotCopyCmd.Flags().StringVar(&c.destinationRegion, "destinationregion", "", "destinationregion")
+	c.snapshotCopyCmd.Flags().StringVar(&c.destinationRegion, "region", "", "The region to which to copy the snapshot")
+	c.snapshotCopyCmd.Flags().StringVar(&c.encryptionKey, "encryptionkey", "", "The key of the destination region with which to encrypt the copy")
+	c.snapshotCopyCmd.Flags().StringSliceVar(&c.labels, "label", nil, "A tag to apply to the copy, ex. env=dr")
+
+	c.snapshotRevertCmd.Flags().StringVar(&c.snapshotID, "snapshotid", "", "The snapshot to which to revert")
+	c.snapshotRevertCmd.Flags().StringVar(&c.volumeID, "volumeid", "", "The volume to revert; defaults to the snapshot's volume")
+	c.snapshotRevertCmd.Flags().StringVar(&c.volumeName, "volumename", "", "The name of the volume to revert")
 
 	c.addOutputFormatFlag(c.snapshotCmd.Flags())
 	c.addOutputFormatFlag(c.snapshotGetCmd.Flags())
 	c.addOutputFormatFlag(c.snapshotCopyCmd.Flags())
 	c.addOutputFormatFlag(c.snapshotCreateCmd.Flags())
+	c.addOutputFormatFlag(c.snapshotRevertCmd.Flags())
+	c.addOutputFormatFlag(c.snapshotCopiesCmd.Flags())
+}
+
+// copySnapshotToRegion copies a snapshot to the region set by --region and
+// prints the copy's progress until it completes, unless --runasync is set.
+func (c *CLI) copySnapshotToRegion() {
+	if c.snapshotID == "" {
+		fatalf("missing --snapshotid")
+	}
+
+	snap, err := c.r.Storage().SnapshotInspect(c.ctx, c.snapshotID, store())
+	if err != nil {
+		fatal(err)
+	}
+
+	tags := map[string]string{}
+	if len(c.labels) > 0 {
+		l, err := labels.Parse(c.labels)
+		if err != nil {
+			fatal(err)
+		}
+		tags = l
+	}
+
+	driver, err := policy.ServiceDriverName(c.ctx, c.config, c.r)
+	if err != nil {
+		fatal(err)
+	}
+
+	cp, err := snapcopy.Start(c.ctx, c.config, state.Default(), driver,
+		&snapcopy.Request{
+			Snapshot:      snap,
+			Region:        c.destinationRegion,
+			Name:          c.destinationSnapshotName,
+			EncryptionKey: c.encryptionKey,
+			Tags:          tags,
+		})
+	if err != nil {
+		fatal(err)
+	}
+
+	if !c.runAsync {
+		last := -1
+		if err := snapcopy.Wait(c.ctx, c.config, state.Default(), cp,
+			10*time.Second, func(cp *snapcopy.Copy) {
+				if cp.Progress != last {
+					fmt.Fprintf(os.Stderr, "copying %s to %s: %d%%\n",
+						cp.SourceID, cp.Region, cp.Progress)
+					last = cp.Progress
+				}
+			}); err != nil {
+			fatal(err)
+		}
+	}
+
+	out, err := c.marshalOutput(cp)
+	if err != nil {
+		fatal(err)
+	}
+	fmt.Println(out)
 }
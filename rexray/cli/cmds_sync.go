@@ -0,0 +1,154 @@
+package cli
+
+import (
+	"fmt"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/emccode/rexray/core/state"
+	"github.com/emccode/rexray/core/volsync"
+)
+
+func (c *CLI) initSyncCmdsAndFlags() {
+	c.initSyncCmds()
+	c.initSyncFlags()
+}
+
+func (c *CLI) initSyncCmds() {
+	c.syncCmd = &cobra.Command{
+		Use:   "sync SRC DST",
+		Short: "Copy the changed data of a volume to another volume",
+		Long: `Copies the data of the source volume to the destination volume. Only the
+data that changed since the previous sync is written. The volumes are named
+as [SERVICE/]VOLUME_NAME and may belong to different services, or with
+--srchost and --dsthost to services of different libStorage servers.
+
+With --schedule the sync is recorded instead of run, and the REX-Ray service
+runs it on its schedule.`,
+		Run: func(cmd *cobra.Command, args []string) {
+
+			if len(args) != 2 {
+				fatalf("Missing source or destination volume")
+			}
+
+			src, err := volsync.ParseEndpoint(args[0], c.syncSrcHost)
+			if err != nil {
+				fatal(err)
+			}
+			dst, err := volsync.ParseEndpoint(args[1], c.syncDstHost)
+			if err != nil {
+				fatal(err)
+			}
+
+			sy := &volsync.Sync{
+				Name:        c.syncName,
+				Source:      src,
+				Destination: dst,
+				Mode:        c.syncMode,
+				Schedule:    c.syncSchedule,
+			}
+
+			if sy.Schedule != "" {
+				if sy.Name == "" {
+					sy.Name = fmt.Sprintf("%s-%s", src.Volume, dst.Volume)
+				}
+				if err := volsync.Set(state.Default(), sy); err != nil {
+					fatal(err)
+				}
+				c.printSyncOutput(sy)
+				return
+			}
+
+			c.runSync(sy)
+		},
+	}
+	c.volumeCmd.AddCommand(c.syncCmd)
+
+	c.syncListCmd = &cobra.Command{
+		Use:     "ls",
+		Short:   "List the scheduled volume syncs",
+		Aliases: []string{"list"},
+		Run: func(cmd *cobra.Command, args []string) {
+
+			all, err := volsync.List(state.Default())
+			if err != nil {
+				fatal(err)
+			}
+			c.printSyncOutput(all)
+		},
+	}
+	c.syncCmd.AddCommand(c.syncListCmd)
+
+	c.syncRemoveCmd = &cobra.Command{
+		Use:     "rm NAME",
+		Short:   "Remove a scheduled volume sync without affecting its volumes",
+		Aliases: []string{"remove"},
+		Run: func(cmd *cobra.Command, args []string) {
+
+			if len(args) != 1 {
+				fatalf("Missing sync name")
+			}
+			if err := volsync.Remove(state.Default(), args[0]); err != nil {
+				fatal(err)
+			}
+		},
+	}
+	c.syncCmd.AddCommand(c.syncRemoveCmd)
+
+	c.syncRunCmd = &cobra.Command{
+		Use:   "run NAME",
+		Short: "Run a scheduled volume sync now",
+		Run: func(cmd *cobra.Command, args []string) {
+
+			if len(args) != 1 {
+				fatalf("Missing sync name")
+			}
+			sy, err := volsync.Get(state.Default(), args[0])
+			if err != nil {
+				fatal(err)
+			}
+			c.runSync(sy)
+		},
+	}
+	c.syncCmd.AddCommand(c.syncRunCmd)
+}
+
+func (c *CLI) initSyncFlags() {
+	c.syncCmd.Flags().StringVar(&c.syncMode, "mode", volsync.ModeRsync,
+		"How the data is copied: rsync for file systems or block for raw "+
+			"block volumes")
+	c.syncCmd.Flags().StringVar(&c.syncSchedule, "schedule", "",
+		"A cron expression that records the sync for the service to run")
+	c.syncCmd.Flags().StringVar(&c.syncName, "name", "",
+		"The name of a scheduled sync. Defaults to SRC-DST")
+	c.syncCmd.Flags().StringVar(&c.syncSrcHost, "srchost", "",
+		"The libStorage server of the source volume's service")
+	c.syncCmd.Flags().StringVar(&c.syncDstHost, "dsthost", "",
+		"The libStorage server of the destination volume's service")
+	c.addOutputFormatFlag(c.syncCmd.Flags())
+	c.addOutputFormatFlag(c.syncListCmd.Flags())
+	c.addOutputFormatFlag(c.syncRunCmd.Flags())
+}
+
+// runSync runs a sync and prints its result. The command exits with a
+// non-zero code if the sync failed.
+func (c *CLI) runSync(sy *volsync.Sync) {
+	r, err := volsync.Run(c.ctx, c.config, c.r, state.Default(), sy)
+	if r == nil {
+		fatal(err)
+	}
+	c.printSyncOutput(r)
+	if err != nil {
+		log.Error(err)
+		panic(1)
+	}
+}
+
+func (c *CLI) printSyncOutput(v interface{}) {
+	out, err := c.marshalOutput(v)
+	if err != nil {
+		fatal(err)
+	}
+	fmt.Println(out)
+}
@@ -0,0 +1,227 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/akutz/goof"
+	"github.com/spf13/cobra"
+
+	"github.com/emccode/rexray/core/errcodes"
+	"github.com/emccode/rexray/core/tasks"
+	"github.com/emccode/rexray/rexray/cli/timeutil"
+)
+
+func (c *CLI) initTaskCmdsAndFlags() {
+	c.initTaskCmds()
+	c.initTaskFlags()
+}
+
+func (c *CLI) initTaskCmds() {
+	c.taskCmd = &cobra.Command{
+		Use:   "task",
+		Short: "The task manager",
+		Run: func(cmd *cobra.Command, args []string) {
+			if isHelpFlags(cmd) {
+				cmd.Usage()
+			} else {
+				c.taskListCmd.Run(c.taskListCmd, args)
+			}
+		},
+	}
+	c.c.AddCommand(c.taskCmd)
+
+	c.taskListCmd = &cobra.Command{
+		Use:     "ls",
+		Short:   "List the tasks run by the REX-Ray service",
+		Aliases: []string{"get", "list"},
+		Run: func(cmd *cobra.Command, args []string) {
+
+			since, loc := c.timeFilter()
+
+			all := []*tasks.Task{}
+			if err := getJSON("http://s/r/tasks", &all); err != nil {
+				fatal(err)
+			}
+
+			listed := []*tasks.Task{}
+			for _, t := range all {
+				if t.QueueTime.Before(since) {
+					continue
+				}
+				t.QueueTime = timeutil.In(t.QueueTime, loc)
+				t.StartTime = timeutil.In(t.StartTime, loc)
+				t.CompleteTime = timeutil.In(t.CompleteTime, loc)
+				listed = append(listed, t)
+			}
+			sort.Sort(byQueueTime(listed))
+
+			out, err := c.marshalOutput(listed)
+			if err != nil {
+				fatal(err)
+			}
+			fmt.Println(out)
+		},
+	}
+	c.taskCmd.AddCommand(c.taskListCmd)
+
+	c.taskInspectCmd = &cobra.Command{
+		Use:   "inspect",
+		Short: "Print a task",
+		Run: func(cmd *cobra.Command, args []string) {
+
+			t, err := getTask(c.taskID)
+			if err != nil {
+				fatal(err)
+			}
+
+			out, err := c.marshalOutput(t)
+			if err != nil {
+				fatal(err)
+			}
+			fmt.Println(out)
+		},
+	}
+	c.taskCmd.AddCommand(c.taskInspectCmd)
+
+	c.taskWaitCmd = &cobra.Command{
+		Use:   "wait",
+		Short: "Wait for a task to complete",
+		Run: func(cmd *cobra.Command, args []string) {
+
+			t, err := waitTask(c.taskID, c.taskTimeout)
+			if err != nil {
+				fatal(err)
+			}
+
+			out, err := c.marshalOutput(t)
+			if err != nil {
+				fatal(err)
+			}
+			fmt.Println(out)
+
+			if t.State == tasks.Failed {
+				panic(1)
+			}
+		},
+	}
+	c.taskCmd.AddCommand(c.taskWaitCmd)
+}
+
+func (c *CLI) initTaskFlags() {
+	c.taskInspectCmd.Flags().Int64Var(&c.taskID, "taskid", 0, "taskid")
+	c.taskWaitCmd.Flags().Int64Var(&c.taskID, "taskid", 0, "taskid")
+	c.taskWaitCmd.Flags().DurationVar(&c.taskTimeout, "timeout", 0,
+		"The maximum duration to wait; zero waits indefinitely")
+	c.addOutputFormatFlag(c.taskListCmd.Flags())
+	c.addTimeFlags(c.taskListCmd.Flags())
+	c.addOutputFormatFlag(c.taskInspectCmd.Flags())
+	c.addOutputFormatFlag(c.taskWaitCmd.Flags())
+}
+
+// submitTask submits an operation to the REX-Ray service to be run as a task.
+func submitTask(
+	operation string, params map[string]string) (*tasks.Task, error) {
+
+	buf, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := newHTTPClient().PostForm("http://s/r/tasks", url.Values{
+		"operation": {operation},
+		"params":    {string(buf)},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	t := &tasks.Task{}
+	if err := decodeJSON(resp.Body, t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func getTask(id int64) (*tasks.Task, error) {
+	if id == 0 {
+		return nil, goof.New("missing --taskid")
+	}
+	t := &tasks.Task{}
+	if err := getJSON(
+		"http://s/r/tasks/"+strconv.FormatInt(id, 10), t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// waitTask polls the task with the provided ID until it completes or the
+// timeout elapses.
+func waitTask(id int64, timeout time.Duration) (*tasks.Task, error) {
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+
+	for {
+		t, err := getTask(id)
+		if err != nil {
+			return nil, err
+		}
+		if t.IsDone() {
+			return t, nil
+		}
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			return nil, goof.WithFields(goof.Fields{
+				"taskID":   id,
+				"progress": t.Progress,
+			}, "timed out waiting for task")
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+type byQueueTime []*tasks.Task
+
+func (t byQueueTime) Len() int      { return len(t) }
+func (t byQueueTime) Swap(i, j int) { t[i], t[j] = t[j], t[i] }
+func (t byQueueTime) Less(i, j int) bool {
+	return t[i].QueueTime.Before(t[j].QueueTime)
+}
+
+func getJSON(u string, v interface{}) error {
+	resp, err := newHTTPClient().Get(u)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return decodeJSON(resp.Body, v)
+}
+
+// decodeJSON decodes a response from the admin module into v, returning the
+// response's error message and code if it is an error.
+func decodeJSON(r io.Reader, v interface{}) error {
+	buf, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	jsonErr := &struct {
+		Message string        `json:"message"`
+		Code    errcodes.Code `json:"code"`
+	}{}
+	if json.Unmarshal(buf, jsonErr) == nil && jsonErr.Message != "" {
+		err := goof.New(jsonErr.Message)
+		if jsonErr.Code == "" {
+			return err
+		}
+		return errcodes.New(jsonErr.Code, err)
+	}
+	return json.Unmarshal(buf, v)
+}
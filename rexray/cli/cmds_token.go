@@ -0,0 +1,180 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/emccode/rexray/core/state"
+	"github.com/emccode/rexray/core/tokens"
+)
+
+func (c *CLI) initTokenCmdsAndFlags() {
+	c.initTokenCmds()
+	c.initTokenFlags()
+}
+
+func (c *CLI) initTokenCmds() {
+	c.tokenCmd = &cobra.Command{
+		Use:   "token",
+		Short: "Issue and revoke scoped API tokens",
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Usage()
+		},
+	}
+	c.c.AddCommand(c.tokenCmd)
+
+	c.tokenNewCmd = &cobra.Command{
+		Use:   "new",
+		Short: "Issue a short-lived token for an agent or CI job",
+		Long: `Issues a token signed with the controller's key. The command must be run on
+the controller host. The token is printed once and is not recorded; revoke it
+with its ID.`,
+		Run: func(cmd *cobra.Command, args []string) {
+
+			role, err := tokens.ParseRole(c.tokenRole)
+			if err != nil {
+				fatal(err)
+			}
+
+			t, err := tokens.Issue(c.config, role, c.tokenSubject, c.tokenTTL)
+			if err != nil {
+				fatal(err)
+			}
+
+			out, err := c.marshalOutput(t)
+			if err != nil {
+				fatal(err)
+			}
+			fmt.Println(out)
+		},
+	}
+	c.tokenCmd.AddCommand(c.tokenNewCmd)
+
+	c.tokenCreateCmd = &cobra.Command{
+		Use:   "create ACCOUNT",
+		Short: "Issue a recorded token for an orchestrator's service account",
+		Long: `Issues a token to a service account and records it so that it may be listed
+and revoked by account. The token may be restricted to services with --scope.
+The command must be run on the controller host. The token itself is printed
+once and is not recorded.`,
+		Run: func(cmd *cobra.Command, args []string) {
+
+			if len(args) != 1 {
+				fatalf("Missing service account")
+			}
+
+			role, err := tokens.ParseRole(c.tokenRole)
+			if err != nil {
+				fatal(err)
+			}
+
+			t, err := tokens.Create(c.config, state.Default(),
+				args[0], role, c.tokenScopes, c.tokenTTL)
+			if err != nil {
+				fatal(err)
+			}
+
+			out, err := c.marshalOutput(t)
+			if err != nil {
+				fatal(err)
+			}
+			fmt.Println(out)
+		},
+	}
+	c.tokenCmd.AddCommand(c.tokenCreateCmd)
+
+	c.tokenListCmd = &cobra.Command{
+		Use:     "ls",
+		Short:   "List the unexpired tokens of service accounts",
+		Aliases: []string{"list"},
+		Run: func(cmd *cobra.Command, args []string) {
+
+			all, err := tokens.List(state.Default(), c.tokenAccount)
+			if err != nil {
+				fatal(err)
+			}
+
+			out, err := c.marshalOutput(all)
+			if err != nil {
+				fatal(err)
+			}
+			fmt.Println(out)
+		},
+	}
+	c.tokenCmd.AddCommand(c.tokenListCmd)
+
+	c.tokenRevokeCmd = &cobra.Command{
+		Use:   "revoke [ID]",
+		Short: "Revoke a token, or a service account's tokens, before expiry",
+		Run: func(cmd *cobra.Command, args []string) {
+
+			var (
+				r   interface{}
+				err error
+			)
+			switch {
+			case c.tokenAccount != "" && len(args) == 0:
+				r, err = tokens.RevokeAccount(
+					c.config, state.Default(), c.tokenAccount)
+			case c.tokenAccount == "" && len(args) == 1:
+				r, err = tokens.Revoke(c.config, state.Default(), args[0])
+			default:
+				fatalf("Missing token ID or --account")
+			}
+			if err != nil {
+				fatal(err)
+			}
+
+			out, err := c.marshalOutput(r)
+			if err != nil {
+				fatal(err)
+			}
+			fmt.Println(out)
+		},
+	}
+	c.tokenCmd.AddCommand(c.tokenRevokeCmd)
+
+	c.tokenRevokedCmd = &cobra.Command{
+		Use:   "revoked",
+		Short: "List the revoked tokens that have not yet expired",
+		Run: func(cmd *cobra.Command, args []string) {
+
+			all, err := tokens.Revoked(state.Default())
+			if err != nil {
+				fatal(err)
+			}
+
+			out, err := c.marshalOutput(all)
+			if err != nil {
+				fatal(err)
+			}
+			fmt.Println(out)
+		},
+	}
+	c.tokenCmd.AddCommand(c.tokenRevokedCmd)
+}
+
+func (c *CLI) initTokenFlags() {
+	c.tokenNewCmd.Flags().StringVar(&c.tokenRole, "role", "",
+		"The token's role: read-only, operator, or admin")
+	c.tokenNewCmd.Flags().DurationVar(&c.tokenTTL, "ttl", 0,
+		"The token's lifetime; defaults to rexray.tokens.ttl")
+	c.tokenNewCmd.Flags().StringVar(&c.tokenSubject, "subject", "",
+		"The agent or job to which the token is issued")
+	c.tokenCreateCmd.Flags().StringVar(&c.tokenRole, "role", "",
+		"The token's role: read-only, operator, or admin")
+	c.tokenCreateCmd.Flags().DurationVar(&c.tokenTTL, "ttl", 0,
+		"The token's lifetime; defaults to rexray.tokens.accountTTL")
+	c.tokenCreateCmd.Flags().StringSliceVar(&c.tokenScopes, "scope", nil,
+		"A service the token may be used with; may be repeated")
+	c.tokenListCmd.Flags().StringVar(&c.tokenAccount, "account", "",
+		"List only the tokens of this service account")
+	c.tokenRevokeCmd.Flags().StringVar(&c.tokenAccount, "account", "",
+		"Revoke all of the tokens of this service account")
+	c.addOutputFormatFlag(c.tokenNewCmd.Flags())
+	c.addOutputFormatFlag(c.tokenCreateCmd.Flags())
+	c.addOutputFormatFlag(c.tokenListCmd.Flags())
+	c.addOutputFormatFlag(c.tokenRevokeCmd.Flags())
+	c.addOutputFormatFlag(c.tokenRevokedCmd.Flags())
+}
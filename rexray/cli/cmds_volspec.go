@@ -0,0 +1,121 @@
+package cli
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/emccode/rexray/core/state"
+	"github.com/emccode/rexray/core/volspec"
+)
+
+func (c *CLI) initVolumeSpecCmdsAndFlags() {
+	c.initVolumeSpecCmds()
+	c.initVolumeSpecFlags()
+}
+
+func (c *CLI) initVolumeSpecCmds() {
+	c.volumeExportSpecCmd = &cobra.Command{
+		Use:   "export-spec",
+		Short: "Print a spec that describes the service's volumes",
+		Long: `Prints a spec that describes the named volumes of the configured service,
+or of the service selected with --service, in the format accepted by
+"rexray volume apply".`,
+		Run: func(cmd *cobra.Command, args []string) {
+
+			client, err := volspec.Client(
+				c.ctx, c.config, c.r, c.specService)
+			if err != nil {
+				fatal(err)
+			}
+			spec, err := volspec.Export(
+				c.ctx, client, state.Default(), c.specService)
+			if err != nil {
+				fatal(err)
+			}
+			out, err := c.marshalOutput(spec)
+			if err != nil {
+				fatal(err)
+			}
+			fmt.Println(out)
+		},
+	}
+	c.volumeCmd.AddCommand(c.volumeExportSpecCmd)
+
+	c.volumeApplyCmd = &cobra.Command{
+		Use:   "apply",
+		Short: "Create the missing volumes of a spec and report drift",
+		Long: `Reads a spec of volumes from the file given with -f, or from stdin if it is
+"-", and creates the volumes that do not exist. The volumes that exist but
+differ from the spec are reported as drifted and are not modified. The
+command exits with a non-zero code if any volume could not be created, or
+with --strict if any volume drifted.`,
+		Run: func(cmd *cobra.Command, args []string) {
+
+			if c.specFile == "" {
+				fatalf("Missing --file")
+			}
+			var (
+				buf []byte
+				err error
+			)
+			if c.specFile == "-" {
+				buf, err = ioutil.ReadAll(os.Stdin)
+			} else {
+				buf, err = ioutil.ReadFile(c.specFile)
+			}
+			if err != nil {
+				fatal(err)
+			}
+			spec, err := volspec.Parse(buf)
+			if err != nil {
+				fatal(err)
+			}
+
+			changes, err := volspec.Apply(
+				c.ctx, c.config, c.r, state.Default(), spec, c.dryRun)
+			if changes == nil {
+				fatal(err)
+			}
+			out, oerr := c.marshalOutput(changes)
+			if oerr != nil {
+				fatal(oerr)
+			}
+			fmt.Println(out)
+			if err != nil {
+				log.Error(err)
+				panic(1)
+			}
+			if c.strict {
+				for _, ch := range changes {
+					if ch.Action == volspec.ActionDrift {
+						log.Error("volumes drifted from spec")
+						panic(1)
+					}
+				}
+			}
+		},
+	}
+	c.volumeCmd.AddCommand(c.volumeApplyCmd)
+}
+
+func (c *CLI) initVolumeSpecFlags() {
+	c.volumeExportSpecCmd.Flags().StringVar(&c.specService, "service", "",
+		"The service whose volumes are described. Defaults to the "+
+			"configured service")
+	c.volumeApplyCmd.Flags().StringVarP(&c.specFile, "file", "f", "",
+		"The spec to apply, or - to read it from stdin")
+	c.volumeApplyCmd.Flags().BoolVar(&c.dryRun, "dryrun", false,
+		"Report the volumes that would be created without creating them")
+	c.volumeApplyCmd.Flags().BoolVar(&c.strict, "strict", false,
+		"Exit with a non-zero code if any volume drifted from the spec")
+	c.addOutputFormatFlag(c.volumeExportSpecCmd.Flags())
+
+	// -f is the spec file, as with kubectl apply, so --format has no
+	// shorthand
+	c.volumeApplyCmd.Flags().StringVar(
+		&c.outputFormat, "format", "yml", outputFormatUsage)
+}
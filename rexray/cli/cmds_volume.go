@@ -2,12 +2,32 @@ package cli
 
 import (
 	"fmt"
+	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	log "github.com/Sirupsen/logrus"
+	"github.com/akutz/goof"
 	"github.com/spf13/cobra"
 
 	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/core/adoption"
+	"github.com/emccode/rexray/core/bench"
+	"github.com/emccode/rexray/core/errcodes"
+	"github.com/emccode/rexray/core/health"
+	"github.com/emccode/rexray/core/labels"
+	"github.com/emccode/rexray/core/migrate"
+	"github.com/emccode/rexray/core/nodes"
+	"github.com/emccode/rexray/core/overrides"
+	"github.com/emccode/rexray/core/pins"
+	"github.com/emccode/rexray/core/policy"
+	"github.com/emccode/rexray/core/protect"
+	"github.com/emccode/rexray/core/state"
+	"github.com/emccode/rexray/core/tasks"
+	"github.com/emccode/rexray/core/topology"
+	"github.com/emccode/rexray/core/trash"
 )
 
 func (c *CLI) initVolumeCmdsAndFlags() {
@@ -39,13 +59,13 @@ func (c *CLI) initVolumeCmds() {
 			allBlockDevices, err := c.r.Storage().Volumes(
 				c.ctx, &apitypes.VolumesOpts{Attachments: true})
 			if err != nil {
-				log.Fatalf("Error: %s", err)
+				fatal(err)
 			}
 
 			if len(allBlockDevices) > 0 {
 				out, err := c.marshalOutput(&allBlockDevices)
 				if err != nil {
-					log.Fatal(err)
+					fatal(err)
 				}
 				fmt.Println(out)
 			}
@@ -59,10 +79,24 @@ func (c *CLI) initVolumeCmds() {
 		Aliases: []string{"ls", "list"},
 		Run: func(cmd *cobra.Command, args []string) {
 
-			vols, err := c.r.Storage().Volumes(
-				c.ctx, &apitypes.VolumesOpts{Attachments: false})
+			if c.watch {
+				c.watchVolumes()
+				return
+			}
+
+			opts := &apitypes.VolumesOpts{Attachments: c.attachedTo != ""}
+			vols, err := c.r.Storage().Volumes(c.ctx, opts)
 			if err != nil {
-				log.Fatal(err)
+				fatal(err)
+			}
+			if vols, err = c.filterVolumesByLabels(vols); err != nil {
+				fatal(err)
+			}
+			if vols, err = c.filterVolumesByNode(vols); err != nil {
+				fatal(err)
+			}
+			if err := annotateVolumes(vols); err != nil {
+				fatal(err)
 			}
 			if c.volumeID != "" || c.volumeName != "" {
 				for _, v := range vols {
@@ -70,7 +104,7 @@ func (c *CLI) initVolumeCmds() {
 						strings.ToLower(v.Name) == strings.ToLower(c.volumeName) {
 						out, err := c.marshalOutput(v)
 						if err != nil {
-							log.Fatal(err)
+							fatal(err)
 						}
 						fmt.Println(out)
 						return
@@ -82,7 +116,7 @@ func (c *CLI) initVolumeCmds() {
 			if len(vols) > 0 {
 				out, err := c.marshalOutput(vols)
 				if err != nil {
-					log.Fatal(err)
+					fatal(err)
 				}
 				fmt.Println(out)
 			}
@@ -91,27 +125,41 @@ func (c *CLI) initVolumeCmds() {
 	c.volumeCmd.AddCommand(c.volumeGetCmd)
 
 	c.volumeCreateCmd = &cobra.Command{
-		Use:     "create",
+		Use:     "create [NAME...]",
 		Short:   "Create a new volume",
 		Aliases: []string{"new"},
 		Run: func(cmd *cobra.Command, args []string) {
 
 			if c.size == 0 && c.snapshotID == "" && c.volumeID == "" {
-				log.Fatalf("missing --size")
+				fatalf("missing --size")
+			}
+
+			opts, err := c.volumeCreateOpts()
+			if err != nil {
+				fatal(err)
+			}
+
+			if c.runTask {
+				c.submitCreateTask()
+				return
 			}
 
-			opts := &apitypes.VolumeCreateOpts{
-				AvailabilityZone: &c.availabilityZone,
-				Size:             &c.size,
-				Type:             &c.volumeType,
-				IOPS:             &c.iops,
-				Opts:             store(),
+			if len(args) > 0 {
+				if c.volumeID != "" || c.snapshotID != "" {
+					fatalf(
+						"multiple volumes may only be created with --size")
+				}
+				c.runBatch(args, func(name string) (interface{}, error) {
+					opts, err := c.volumeCreateOpts()
+					if err != nil {
+						return nil, err
+					}
+					return c.r.Storage().VolumeCreate(c.ctx, name, opts)
+				})
+				return
 			}
 
-			var (
-				err    error
-				volume *apitypes.Volume
-			)
+			var volume *apitypes.Volume
 
 			if c.volumeID != "" && c.volumeName != "" {
 				volume, err = c.r.Storage().VolumeCopy(
@@ -125,12 +173,12 @@ func (c *CLI) initVolumeCmds() {
 			}
 			// TODO Get All Volumes
 			if err != nil {
-				log.Fatal(err)
+				fatal(err)
 			}
 
 			out, err := c.marshalOutput(&volume)
 			if err != nil {
-				log.Fatal(err)
+				fatal(err)
 			}
 			fmt.Println(out)
 
@@ -139,18 +187,27 @@ func (c *CLI) initVolumeCmds() {
 	c.volumeCmd.AddCommand(c.volumeCreateCmd)
 
 	c.volumeRemoveCmd = &cobra.Command{
-		Use:     "remove",
+		Use:     "remove [VOLUME_ID...]",
 		Short:   "Remove a volume",
 		Aliases: []string{"rm"},
 		Run: func(cmd *cobra.Command, args []string) {
 
+			if len(args) > 0 {
+				c.runBatch(args, func(id string) (interface{}, error) {
+					return nil, c.r.Storage().VolumeRemove(
+						c.ctx, id, c.volumeRemoveOpts())
+				})
+				return
+			}
+
 			if c.volumeID == "" {
-				log.Fatalf("missing --volumeid")
+				fatalf("missing --volumeid")
 			}
 
-			err := c.r.Storage().VolumeRemove(c.ctx, c.volumeID, store())
+			err := c.r.Storage().VolumeRemove(
+				c.ctx, c.volumeID, c.volumeRemoveOpts())
 			if err != nil {
-				log.Fatal(err)
+				fatal(err)
 			}
 
 		},
@@ -158,28 +215,41 @@ func (c *CLI) initVolumeCmds() {
 	c.volumeCmd.AddCommand(c.volumeRemoveCmd)
 
 	c.volumeAttachCmd = &cobra.Command{
-		Use:   "attach",
+		Use:   "attach [VOLUME_ID...]",
 		Short: "Attach a volume",
 		Run: func(cmd *cobra.Command, args []string) {
 
+			if len(args) > 0 {
+				c.runBatch(args, func(id string) (interface{}, error) {
+					vol, _, err := c.r.Storage().VolumeAttach(
+						c.ctx, id,
+						&apitypes.VolumeAttachOpts{
+							Force: c.force,
+							Opts:  c.accessModeStore(),
+						})
+					return vol, err
+				})
+				return
+			}
+
 			if c.volumeID == "" {
-				log.Fatalf("missing --volumeid")
+				fatalf("missing --volumeid")
 			}
 
 			vol, _, err := c.r.Storage().VolumeAttach(
 				c.ctx, c.volumeID,
 				&apitypes.VolumeAttachOpts{
 					Force: c.force,
-					Opts:  store(),
+					Opts:  c.accessModeStore(),
 				})
 
 			if err != nil {
-				log.Fatal(err)
+				fatal(err)
 			}
 
 			out, err := c.marshalOutput(vol)
 			if err != nil {
-				log.Fatal(err)
+				fatal(err)
 			}
 			fmt.Println(out)
 
@@ -193,7 +263,7 @@ func (c *CLI) initVolumeCmds() {
 		Run: func(cmd *cobra.Command, args []string) {
 
 			if c.volumeID == "" {
-				log.Fatalf("missing --volumeid")
+				fatalf("missing --volumeid")
 			}
 
 			_, err := c.r.Storage().VolumeDetach(
@@ -202,7 +272,7 @@ func (c *CLI) initVolumeCmds() {
 					Opts:  store(),
 				})
 			if err != nil {
-				log.Fatal(err)
+				fatal(err)
 			}
 
 		},
@@ -210,11 +280,25 @@ func (c *CLI) initVolumeCmds() {
 	c.volumeCmd.AddCommand(c.volumeDetachCmd)
 
 	c.volumeMountCmd = &cobra.Command{
-		Use:   "mount",
+		Use:   "mount [VOLUME_NAME...]",
 		Short: "Mount a volume",
 		Run: func(cmd *cobra.Command, args []string) {
+			if len(args) > 0 {
+				c.runBatch(args, func(name string) (interface{}, error) {
+					mountPath, _, err := c.r.Integration().Mount(
+						c.ctx, "", name,
+						&apitypes.VolumeMountOpts{
+							NewFSType:   c.fsType,
+							OverwriteFS: c.overwriteFs,
+							Opts:        c.mountStore(),
+						})
+					return mountPath, err
+				})
+				return
+			}
+
 			if c.volumeName == "" && c.volumeID == "" {
-				log.Fatal("Missing --volumename or --volumeid")
+				fatalf("Missing --volumename or --volumeid")
 			}
 
 			mountPath, _, err := c.r.Integration().Mount(
@@ -222,14 +306,15 @@ func (c *CLI) initVolumeCmds() {
 				&apitypes.VolumeMountOpts{
 					NewFSType:   c.fsType,
 					OverwriteFS: c.overwriteFs,
+					Opts:        c.mountStore(),
 				})
 			if err != nil {
-				log.Fatal(err)
+				fatal(err)
 			}
 
 			out, err := c.marshalOutput(&mountPath)
 			if err != nil {
-				log.Fatal(err)
+				fatal(err)
 			}
 			fmt.Println(out)
 
@@ -238,18 +323,25 @@ func (c *CLI) initVolumeCmds() {
 	c.volumeCmd.AddCommand(c.volumeMountCmd)
 
 	c.volumeUnmountCmd = &cobra.Command{
-		Use:   "unmount",
+		Use:   "unmount [VOLUME_NAME...]",
 		Short: "Unmount a volume",
 		Run: func(cmd *cobra.Command, args []string) {
+			if len(args) > 0 {
+				c.runBatch(args, func(name string) (interface{}, error) {
+					return nil, c.r.Integration().Unmount(
+						c.ctx, "", name, store())
+				})
+				return
+			}
 
 			if c.volumeName == "" && c.volumeID == "" {
-				log.Fatal("Missing --volumename or --volumeid")
+				fatalf("Missing --volumename or --volumeid")
 			}
 
 			err := c.r.Integration().Unmount(
 				c.ctx, c.volumeID, c.volumeName, store())
 			if err != nil {
-				log.Fatal(err)
+				fatal(err)
 			}
 		},
 	}
@@ -261,30 +353,811 @@ func (c *CLI) initVolumeCmds() {
 		Run: func(cmd *cobra.Command, args []string) {
 
 			if c.volumeName == "" && c.volumeID == "" {
-				log.Fatal("Missing --volumename or --volumeid")
+				fatalf("Missing --volumename or --volumeid")
 			}
 
 			mountPath, err := c.r.Integration().Path(
 				c.ctx, c.volumeID, c.volumeName, store())
 			if err != nil {
-				log.Fatal(err)
+				fatal(err)
 			}
 
 			if mountPath != "" {
 				out, err := c.marshalOutput(&mountPath)
 				if err != nil {
-					log.Fatal(err)
+					fatal(err)
 				}
 				fmt.Println(out)
 			}
 		},
 	}
 	c.volumeCmd.AddCommand(c.volumePathCmd)
+
+	c.volumeResizeCmd = &cobra.Command{
+		Use:   "resize",
+		Short: "Resize a volume",
+		Run: func(cmd *cobra.Command, args []string) {
+
+			if c.volumeName == "" && c.volumeID == "" {
+				fatalf("Missing --volumename or --volumeid")
+			}
+			if c.size == 0 {
+				fatalf("Missing --size")
+			}
+
+			volumeID, err := c.lookupVolumeID(c.volumeID, c.volumeName)
+			if err != nil {
+				fatal(err)
+			}
+
+			native, err := migrate.IsNative(c.ctx, c.config, c.r)
+			if err != nil {
+				fatal(err)
+			}
+			if !native {
+				fmt.Fprintf(os.Stderr, "WARNING: %s\n\n", migrate.DowntimeWarning)
+				if !c.yes && !confirm("Migrate the volume?") {
+					fatalf("resize not confirmed; pass --yes to confirm it")
+				}
+			}
+
+			if c.runTask {
+				c.printTask(submitTask(tasks.OpVolumeResize, map[string]string{
+					"volumeID":   volumeID,
+					"volumeName": c.volumeName,
+					"size":       strconv.FormatInt(c.size, 10),
+					"fsType":     c.fsType,
+				}))
+				return
+			}
+
+			opts := &migrate.ResizeOpts{
+				Size:   c.size,
+				FSType: c.fsType,
+				Opts:   store(),
+			}
+			vol, err := migrate.Resize(c.ctx, c.config, c.r, volumeID, opts)
+			if err != nil {
+				fatal(err)
+			}
+
+			out, err := c.marshalOutput(vol)
+			if err != nil {
+				fatal(err)
+			}
+			fmt.Println(out)
+		},
+	}
+	c.volumeCmd.AddCommand(c.volumeResizeCmd)
+
+	c.volumeRepairCmd = &cobra.Command{
+		Use:   "repair",
+		Short: "Forcefully unmount and remount a degraded volume",
+		Run: func(cmd *cobra.Command, args []string) {
+
+			if c.volumeName == "" && c.volumeID == "" {
+				fatalf("Missing --volumename or --volumeid")
+			}
+
+			volumeID, err := c.lookupVolumeID(c.volumeID, c.volumeName)
+			if err != nil {
+				fatal(err)
+			}
+
+			s := state.Default()
+			if !c.force {
+				r, err := health.Get(s, volumeID)
+				if err != nil {
+					fatal(err)
+				}
+				if r.Status != health.Degraded {
+					fatalf(
+						"volume %s is not degraded; use --force to repair it",
+						volumeID)
+				}
+			}
+
+			mountPath, err := health.Repair(c.ctx, c.r, s, volumeID)
+			if err != nil {
+				fatal(err)
+			}
+
+			out, err := c.marshalOutput(&mountPath)
+			if err != nil {
+				fatal(err)
+			}
+			fmt.Println(out)
+		},
+	}
+	c.volumeCmd.AddCommand(c.volumeRepairCmd)
+
+	c.volumeAdoptCmd = &cobra.Command{
+		Use:   "adopt VOLUMEID",
+		Short: "Bring a volume created outside of REX-Ray under its management",
+		Run: func(cmd *cobra.Command, args []string) {
+
+			if len(args) != 1 {
+				fatalf("Missing volume ID")
+			}
+
+			l, err := labels.Parse(c.labels)
+			if err != nil {
+				fatal(err)
+			}
+
+			r, err := adoption.Adopt(
+				c.ctx, c.config, c.r, state.Controller(), args[0],
+				&adoption.Opts{
+					Driver: c.driverName,
+					Name:   c.volumeName,
+					Labels: l,
+				})
+			if err != nil {
+				fatal(err)
+			}
+
+			out, err := c.marshalOutput(r)
+			if err != nil {
+				fatal(err)
+			}
+			fmt.Println(out)
+		},
+	}
+	c.volumeCmd.AddCommand(c.volumeAdoptCmd)
+
+	c.volumeReleaseCmd = &cobra.Command{
+		Use:   "release",
+		Short: "Release a volume from REX-Ray's management without removing it",
+		Run: func(cmd *cobra.Command, args []string) {
+
+			if c.volumeName == "" && c.volumeID == "" {
+				fatalf("Missing --volumename or --volumeid")
+			}
+
+			volumeID, err := c.lookupVolumeID(c.volumeID, c.volumeName)
+			if err != nil {
+				fatal(err)
+			}
+
+			if err := adoption.Release(
+				state.Controller(), state.Default(), volumeID); err != nil {
+				fatal(err)
+			}
+		},
+	}
+	c.volumeCmd.AddCommand(c.volumeReleaseCmd)
+
+	c.volumePinCmd = &cobra.Command{
+		Use:   "pin [NAME]",
+		Short: "Pin a volume so it may not be unmounted, detached, or removed",
+		Long: `Pins the volume with the provided name or ID. The pinned volumes
+are printed if no volume is specified.`,
+		Run: func(cmd *cobra.Command, args []string) {
+
+			if len(args) > 0 {
+				c.volumeName = args[0]
+			}
+
+			s := state.Controller()
+			if c.volumeName == "" && c.volumeID == "" {
+				l, err := pins.List(s)
+				if err != nil {
+					fatal(err)
+				}
+				out, err := c.marshalOutput(l)
+				if err != nil {
+					fatal(err)
+				}
+				fmt.Println(out)
+				return
+			}
+
+			volumeID, err := c.lookupVolumeID(c.volumeID, c.volumeName)
+			if err != nil {
+				fatal(err)
+			}
+
+			p, err := pins.Set(s, volumeID, c.pinReason)
+			if err != nil {
+				fatal(err)
+			}
+
+			out, err := c.marshalOutput(p)
+			if err != nil {
+				fatal(err)
+			}
+			fmt.Println(out)
+		},
+	}
+	c.volumeCmd.AddCommand(c.volumePinCmd)
+
+	c.volumeUnpinCmd = &cobra.Command{
+		Use:   "unpin NAME",
+		Short: "Unpin a volume",
+		Run: func(cmd *cobra.Command, args []string) {
+
+			if len(args) > 0 {
+				c.volumeName = args[0]
+			}
+			if c.volumeName == "" && c.volumeID == "" {
+				fatalf("Missing volume name or --volumeid")
+			}
+
+			volumeID, err := c.lookupVolumeID(c.volumeID, c.volumeName)
+			if err != nil {
+				fatal(err)
+			}
+
+			if err := pins.Remove(state.Controller(), volumeID); err != nil {
+				fatal(err)
+			}
+		},
+	}
+	c.volumeCmd.AddCommand(c.volumeUnpinCmd)
+
+	c.volumeProtectCmd = &cobra.Command{
+		Use:   "protect [NAME]",
+		Short: "Protect a volume from removal",
+		Long: `Protects the volume with the provided name or ID from removal until its
+protection is cleared. The protected volumes are printed if no volume is
+specified.`,
+		Run: func(cmd *cobra.Command, args []string) {
+
+			if len(args) > 0 {
+				c.volumeName = args[0]
+			}
+
+			s := state.Default()
+			if c.volumeName == "" && c.volumeID == "" {
+				l, err := protect.List(s)
+				if err != nil {
+					fatal(err)
+				}
+				out, err := c.marshalOutput(l)
+				if err != nil {
+					fatal(err)
+				}
+				fmt.Println(out)
+				return
+			}
+
+			volumeID, err := c.lookupVolumeID(c.volumeID, c.volumeName)
+			if err != nil {
+				fatal(err)
+			}
+
+			p, err := protect.Set(s, volumeID, c.protectReason)
+			if err != nil {
+				fatal(err)
+			}
+
+			out, err := c.marshalOutput(p)
+			if err != nil {
+				fatal(err)
+			}
+			fmt.Println(out)
+		},
+	}
+	c.volumeCmd.AddCommand(c.volumeProtectCmd)
+
+	c.volumeUnprotectCmd = &cobra.Command{
+		Use:   "unprotect NAME",
+		Short: "Clear a volume's protection from removal",
+		Run: func(cmd *cobra.Command, args []string) {
+
+			if len(args) > 0 {
+				c.volumeName = args[0]
+			}
+			if c.volumeName == "" && c.volumeID == "" {
+				fatalf("Missing volume name or --volumeid")
+			}
+
+			volumeID, err := c.lookupVolumeID(c.volumeID, c.volumeName)
+			if err != nil {
+				fatal(err)
+			}
+
+			if err := protect.Clear(state.Default(), volumeID); err != nil {
+				fatal(err)
+			}
+		},
+	}
+	c.volumeCmd.AddCommand(c.volumeUnprotectCmd)
+
+	c.volumeTrashCmd = &cobra.Command{
+		Use:   "trash",
+		Short: "List the removed volumes that may still be restored",
+		Run: func(cmd *cobra.Command, args []string) {
+
+			l, err := trash.List(state.Default())
+			if err != nil {
+				fatal(err)
+			}
+
+			out, err := c.marshalOutput(l)
+			if err != nil {
+				fatal(err)
+			}
+			fmt.Println(out)
+		},
+	}
+	c.volumeCmd.AddCommand(c.volumeTrashCmd)
+
+	c.volumeRestoreTrashedCmd = &cobra.Command{
+		Use:   "restore-trashed NAME",
+		Short: "Restore a removed volume from the trash",
+		Run: func(cmd *cobra.Command, args []string) {
+
+			if len(args) > 0 {
+				c.volumeName = args[0]
+			}
+			if c.volumeName == "" && c.volumeID == "" {
+				fatalf("Missing volume name or --volumeid")
+			}
+
+			s := state.Default()
+			volumeID := c.volumeID
+			if volumeID == "" {
+				// trashed volumes are hidden from the volume listing so the
+				// name is looked up in the trash
+				l, err := trash.List(s)
+				if err != nil {
+					fatal(err)
+				}
+				for _, e := range l {
+					if e.VolumeName == c.volumeName ||
+						e.VolumeID == c.volumeName {
+						volumeID = e.VolumeID
+						break
+					}
+				}
+				if volumeID == "" {
+					fatalf("volume %s not in trash", c.volumeName)
+				}
+			}
+
+			e, err := trash.Restore(s, volumeID)
+			if err != nil {
+				fatal(err)
+			}
+
+			driver, err := policy.ServiceDriverName(c.ctx, c.config, c.r)
+			if err == nil {
+				_, err = trash.Unmark(c.ctx, c.config, driver, volumeID)
+			}
+			if err != nil {
+				log.WithError(err).WithField("volumeID", volumeID).Warn(
+					"error removing volume's trash mark")
+			}
+
+			out, err := c.marshalOutput(e)
+			if err != nil {
+				fatal(err)
+			}
+			fmt.Println(out)
+		},
+	}
+	c.volumeCmd.AddCommand(c.volumeRestoreTrashedCmd)
+
+	c.volumeBenchCmd = &cobra.Command{
+		Use:   "bench NAME",
+		Short: "Measure the I/O performance of a volume",
+		Long: `Runs a fio job, or a Go I/O generator if fio is not installed,
+against a test file on the volume and prints the IOPS, throughput, and
+latency. The volume is mounted for the test if it is not mounted already.`,
+		Run: func(cmd *cobra.Command, args []string) {
+
+			if len(args) > 0 {
+				c.volumeName = args[0]
+			}
+			if c.volumeName == "" && c.volumeID == "" {
+				fatalf("Missing volume name or --volumeid")
+			}
+
+			var duration time.Duration
+			if c.ioDuration != "" {
+				d, err := time.ParseDuration(c.ioDuration)
+				if err != nil {
+					fatal(err)
+				}
+				duration = d
+			}
+
+			mountPath, err := c.r.Integration().Path(
+				c.ctx, c.volumeID, c.volumeName, store())
+			if err != nil {
+				fatal(err)
+			}
+			mounted := false
+			if mountPath == "" {
+				mountPath, _, err = c.r.Integration().Mount(
+					c.ctx, c.volumeID, c.volumeName,
+					&apitypes.VolumeMountOpts{Opts: store()})
+				if err != nil {
+					fatal(err)
+				}
+				mounted = true
+			}
+
+			res, err := bench.RunIO(mountPath, &bench.IOOpts{
+				Job:       c.ioJob,
+				Native:    c.ioNative,
+				Pattern:   c.ioPattern,
+				BlockSize: c.ioBlockSize,
+				FileSize:  c.ioFileSize,
+				Duration:  duration,
+				Workers:   c.ioWorkers,
+			})
+
+			// the volume is unmounted before any error is reported
+			if mounted {
+				if err := c.r.Integration().Unmount(
+					c.ctx, c.volumeID, c.volumeName, store()); err != nil {
+					log.WithError(err).Error("error unmounting volume")
+				}
+			}
+			if err != nil {
+				fatal(err)
+			}
+
+			out, err := c.marshalOutput(res)
+			if err != nil {
+				fatal(err)
+			}
+			fmt.Println(out)
+		},
+	}
+	c.volumeCmd.AddCommand(c.volumeBenchCmd)
+
+	c.volumeLabelCmd = &cobra.Command{
+		Use:   "label [key=value...]",
+		Short: "Print or update a volume's labels",
+		Run: func(cmd *cobra.Command, args []string) {
+
+			if c.volumeName == "" && c.volumeID == "" {
+				fatalf("Missing --volumename or --volumeid")
+			}
+
+			volumeID, err := c.lookupVolumeID(c.volumeID, c.volumeName)
+			if err != nil {
+				fatal(err)
+			}
+
+			l, err := labels.Volume(state.Default(), volumeID)
+			if err != nil {
+				fatal(err)
+			}
+
+			if len(args) > 0 || len(c.removeLabels) > 0 {
+				add, err := labels.Parse(args)
+				if err != nil {
+					fatal(err)
+				}
+				for k, v := range add {
+					l[k] = v
+				}
+				for _, k := range c.removeLabels {
+					delete(l, k)
+				}
+				if err := labels.SetVolume(
+					state.Default(), volumeID, l); err != nil {
+					fatal(err)
+				}
+			}
+
+			out, err := c.marshalOutput(l)
+			if err != nil {
+				fatal(err)
+			}
+			fmt.Println(out)
+		},
+	}
+	c.volumeCmd.AddCommand(c.volumeLabelCmd)
+
+	c.volumeOverrideCmd = &cobra.Command{
+		Use:   "override [key=value...]",
+		Short: "Print or update the options applied when a volume is next mounted",
+		Long: `Prints or updates a volume's option overrides. The overrides replace the
+options with which the volume is mounted the next time it is mounted. The
+fsType, readOnly, and volumeMode keys are handled by REX-Ray; other keys are
+passed to the storage driver.`,
+		Run: func(cmd *cobra.Command, args []string) {
+
+			if c.volumeName == "" && c.volumeID == "" {
+				fatalf("Missing --volumename or --volumeid")
+			}
+
+			volumeID, err := c.lookupVolumeID(c.volumeID, c.volumeName)
+			if err != nil {
+				fatal(err)
+			}
+
+			s := state.Default()
+			if c.clearOverrides {
+				if _, err := overrides.Clear(s, volumeID); err != nil {
+					fatal(err)
+				}
+			} else if len(c.removeOverrides) > 0 {
+				if _, err := overrides.Clear(
+					s, volumeID, c.removeOverrides...); err != nil {
+					fatal(err)
+				}
+			}
+
+			if len(args) > 0 {
+				set, err := overrides.Parse(args)
+				if err != nil {
+					fatal(err)
+				}
+				if _, err := overrides.Set(s, volumeID, set); err != nil {
+					fatal(err)
+				}
+			}
+
+			o, err := overrides.Get(s, volumeID)
+			if err != nil {
+				fatal(err)
+			}
+
+			out, err := c.marshalOutput(o)
+			if err != nil {
+				fatal(err)
+			}
+			fmt.Println(out)
+		},
+	}
+	c.volumeCmd.AddCommand(c.volumeOverrideCmd)
+}
+
+// filterVolumesByLabels returns the volumes whose labels match the selectors
+// specified with the --label flag.
+func (c *CLI) filterVolumesByLabels(
+	vols []*apitypes.Volume) ([]*apitypes.Volume, error) {
+
+	sel, err := labels.ParseSelector(c.labels...)
+	if err != nil {
+		return nil, err
+	}
+	if sel.Empty() {
+		return vols, nil
+	}
+
+	filtered := []*apitypes.Volume{}
+	for _, v := range vols {
+		l, err := labels.Volume(state.Default(), v.ID)
+		if err != nil {
+			return nil, err
+		}
+		if sel.Matches(l) {
+			filtered = append(filtered, v)
+		}
+	}
+	return filtered, nil
+}
+
+// filterVolumesByNode returns the volumes attached to, or mounted on, the
+// node selected with --attached-to, which is matched by its instance ID or by
+// the hostname its agent reported.
+func (c *CLI) filterVolumesByNode(
+	vols []*apitypes.Volume) ([]*apitypes.Volume, error) {
+
+	if c.attachedTo == "" {
+		return vols, nil
+	}
+	all, err := nodes.List(state.Controller())
+	if err != nil {
+		return nil, err
+	}
+
+	ids := map[string]bool{}
+	for _, na := range nodes.Attachments(vols, all) {
+		if !na.Matches(c.attachedTo) {
+			continue
+		}
+		for _, a := range na.Attachments {
+			ids[a.VolumeID] = true
+		}
+	}
+
+	filtered := []*apitypes.Volume{}
+	for _, v := range vols {
+		if ids[v.ID] {
+			filtered = append(filtered, v)
+		}
+	}
+	return filtered, nil
+}
+
+// annotateVolumes sets the names of adopted volumes to the names with which
+// they were adopted, and the status of the volumes whose mounts were found to
+// be hung or stale by an agent.
+func annotateVolumes(vols []*apitypes.Volume) error {
+	s := state.Default()
+	for _, v := range vols {
+		a, ok, err := adoption.Get(state.Controller(), v.ID)
+		if err != nil {
+			return err
+		}
+		if ok {
+			v.Name = a.Name
+		}
+
+		r, err := health.Get(s, v.ID)
+		if err != nil {
+			return err
+		}
+		if r.Status == health.Degraded {
+			v.Status = string(health.Degraded)
+		}
+	}
+	return nil
+}
+
+// volumeCreateOpts returns the options with which volumes are created. New
+// options are returned on each call since the options' store is not safe
+// for concurrent use.
+func (c *CLI) volumeCreateOpts() (*apitypes.VolumeCreateOpts, error) {
+	opts := &apitypes.VolumeCreateOpts{
+		AvailabilityZone: &c.availabilityZone,
+		Size:             &c.size,
+		Type:             &c.volumeType,
+		IOPS:             &c.iops,
+		Opts:             store(),
+	}
+
+	if len(c.labels) > 0 {
+		l, err := labels.Parse(c.labels)
+		if err != nil {
+			return nil, err
+		}
+		opts.Opts.Set(labels.OptKey, l.String())
+	}
+
+	if c.accessMode != "" {
+		opts.Opts.Set(policy.AccessModeKey, c.accessMode)
+	}
+
+	if len(c.zones) > 0 {
+		r := &topology.Requirement{}
+		for _, z := range c.zones {
+			r.Requisite = append(r.Requisite, topology.Segment{topology.Zone: z})
+		}
+		r.SetOpts(opts.Opts)
+	}
+
+	driverOpts, err := parseOpts(c.volumeOpts)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range driverOpts {
+		opts.Opts.Set(k, v)
+	}
+	return opts, nil
+}
+
+func (c *CLI) volumeRemoveOpts() apitypes.Store {
+	opts := store()
+	if c.purge {
+		opts.Set(trash.PurgeKey, true)
+	}
+	return opts
+}
+
+// accessModeStore returns a new store that requests a read-only attachment
+// if the --readonly flag was specified.
+func (c *CLI) accessModeStore() apitypes.Store {
+	s := store()
+	if c.readOnly {
+		s.Set(policy.ReadOnlyKey, true)
+	}
+	return s
+}
+
+// mountStore returns a new store with the options of a mount: a read-only
+// attachment if the --readonly flag was specified, and the options specified
+// with --opt, ex. uid=1000.
+func (c *CLI) mountStore() apitypes.Store {
+	s := c.accessModeStore()
+	opts, err := parseOpts(c.volumeOpts)
+	if err != nil {
+		fatal(err)
+	}
+	for k, v := range opts {
+		s.Set(k, v)
+	}
+	return s
+}
+
+// submitCreateTask submits a copy of a volume or the creation of a volume
+// from a snapshot to the REX-Ray service to be run as a task.
+func (c *CLI) submitCreateTask() {
+	switch {
+	case c.volumeID != "" && c.volumeName != "":
+		c.printTask(submitTask(tasks.OpVolumeCopy, map[string]string{
+			"volumeID":   c.volumeID,
+			"volumeName": c.volumeName,
+		}))
+	case c.snapshotID != "" && c.volumeName != "":
+		c.printTask(submitTask(
+			tasks.OpVolumeCreateFromSnapshot, map[string]string{
+				"snapshotID": c.snapshotID,
+				"volumeName": c.volumeName,
+			}))
+	default:
+		fatalf("--task requires --volumename and --volumeid or --snapshotid")
+	}
+}
+
+// parseOpts parses a list of key=value driver options.
+func parseOpts(pairs []string) (map[string]string, error) {
+	opts := map[string]string{}
+	for _, p := range pairs {
+		parts := strings.SplitN(p, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, goof.WithField("opt", p, "invalid option")
+		}
+		opts[parts[0]] = parts[1]
+	}
+	return opts, nil
+}
+
+func (c *CLI) printTask(t *tasks.Task, err error) {
+	if err != nil {
+		fatal(err)
+	}
+	out, err := c.marshalOutput(t)
+	if err != nil {
+		fatal(err)
+	}
+	fmt.Println(out)
+}
+
+// lookupVolumeID returns the provided volume ID, or if it is empty, the ID of
+// the volume with the provided name.
+func (c *CLI) lookupVolumeID(volumeID, volumeName string) (string, error) {
+	if volumeID != "" {
+		return volumeID, nil
+	}
+
+	// adopted volumes are referred to by the names with which they were
+	// adopted
+	a, ok, err := adoption.ByName(state.Controller(), volumeName)
+	if err != nil {
+		return "", err
+	}
+	if ok {
+		return a.VolumeID, nil
+	}
+
+	vols, err := c.r.Storage().Volumes(
+		c.ctx, &apitypes.VolumesOpts{Attachments: false})
+	if err != nil {
+		return "", err
+	}
+
+	for _, v := range vols {
+		if strings.ToLower(v.Name) == strings.ToLower(volumeName) {
+			return v.ID, nil
+		}
+	}
+
+	return "", errcodes.New(errcodes.VolumeNotFound,
+		goof.WithField("volumeName", volumeName, "volume not found"))
 }
 
 func (c *CLI) initVolumeFlags() {
 	c.volumeGetCmd.Flags().StringVar(&c.volumeName, "volumename", "", "volumename")
 	c.volumeGetCmd.Flags().StringVar(&c.volumeID, "volumeid", "", "volumeid")
+	for _, cmd := range []*cobra.Command{c.volumeCmd, c.volumeGetCmd} {
+		cmd.Flags().BoolVarP(&c.watch, "watch", "w", false,
+			"Print the volumes' state transitions until interrupted")
+		cmd.Flags().DurationVar(&c.watchInterval, "interval", 2*time.Second,
+			"The interval at which the volumes are polled with --watch")
+	}
+	c.volumeGetCmd.Flags().StringSliceVar(&c.labels, "label", nil, "A label selector, ex. env=prod")
+	c.volumeGetCmd.Flags().StringVar(&c.attachedTo, "attached-to", "",
+		"List only the volumes attached to or mounted on a node, by its "+
+			"instance ID or hostname")
 	c.volumeCreateCmd.Flags().BoolVar(&c.runAsync, "runasync", false, "runasync")
 	c.volumeCreateCmd.Flags().StringVar(&c.volumeName, "volumename", "", "volumename")
 	c.volumeCreateCmd.Flags().StringVar(&c.volumeType, "volumetype", "", "volumetype")
@@ -293,11 +1166,22 @@ func (c *CLI) initVolumeFlags() {
 	c.volumeCreateCmd.Flags().Int64Var(&c.iops, "iops", 0, "IOPS")
 	c.volumeCreateCmd.Flags().Int64Var(&c.size, "size", 0, "size")
 	c.volumeCreateCmd.Flags().StringVar(&c.availabilityZone, "availabilityzone", "", "availabilityzone")
+	c.volumeCreateCmd.Flags().StringSliceVar(&c.zones, "zone", nil, "A zone in which the volume may be created; may be repeated")
+	c.volumeCreateCmd.Flags().StringSliceVar(&c.labels, "label", nil, "A label to apply, ex. env=prod")
+	c.volumeCreateCmd.Flags().StringSliceVar(&c.volumeOpts, "opt", nil, "A driver-specific option, ex. dataPool=ecpool")
+	c.volumeCreateCmd.Flags().StringVar(&c.accessMode, "accessmode", "", "The volume's access mode: RWO, ROX, or RWX")
+	c.volumeCreateCmd.Flags().BoolVar(&c.runTask, "task", false, "Run a copy or create from a snapshot as a task in the REX-Ray service")
 	c.volumeRemoveCmd.Flags().StringVar(&c.volumeID, "volumeid", "", "volumeid")
+	c.volumeRemoveCmd.Flags().BoolVar(&c.purge, "purge", false, "Remove the volume immediately rather than moving it to the trash")
+	c.addParallelFlag(c.volumeCreateCmd.Flags())
+	c.addParallelFlag(c.volumeRemoveCmd.Flags())
+	c.addParallelFlag(c.volumeAttachCmd.Flags())
+	c.addParallelFlag(c.volumeMountCmd.Flags())
 	c.volumeAttachCmd.Flags().BoolVar(&c.runAsync, "runasync", false, "runasync")
 	c.volumeAttachCmd.Flags().StringVar(&c.volumeID, "volumeid", "", "volumeid")
 	c.volumeAttachCmd.Flags().StringVar(&c.instanceID, "instanceid", "", "instanceid")
 	c.volumeAttachCmd.Flags().BoolVar(&c.force, "force", false, "force")
+	c.volumeAttachCmd.Flags().BoolVar(&c.readOnly, "readonly", false, "readonly")
 	c.volumeDetachCmd.Flags().BoolVar(&c.runAsync, "runasync", false, "runasync")
 	c.volumeDetachCmd.Flags().StringVar(&c.volumeID, "volumeid", "", "volumeid")
 	c.volumeDetachCmd.Flags().StringVar(&c.instanceID, "instanceid", "", "instanceid")
@@ -306,16 +1190,66 @@ func (c *CLI) initVolumeFlags() {
 	c.volumeMountCmd.Flags().StringVar(&c.volumeName, "volumename", "", "volumename")
 	c.volumeMountCmd.Flags().BoolVar(&c.overwriteFs, "overwritefs", false, "overwritefs")
 	c.volumeMountCmd.Flags().StringVar(&c.fsType, "fstype", "", "fstype")
+	c.volumeMountCmd.Flags().BoolVar(&c.readOnly, "readonly", false, "readonly")
+	c.volumeMountCmd.Flags().StringSliceVar(&c.volumeOpts, "opt", nil, "A mount option, ex. uid=1000")
 	c.volumeUnmountCmd.Flags().StringVar(&c.volumeID, "volumeid", "", "volumeid")
 	c.volumeUnmountCmd.Flags().StringVar(&c.volumeName, "volumename", "", "volumename")
 	c.volumePathCmd.Flags().StringVar(&c.volumeID, "volumeid", "", "volumeid")
 	c.volumePathCmd.Flags().StringVar(&c.volumeName, "volumename", "", "volumename")
+	c.volumeResizeCmd.Flags().StringVar(&c.volumeID, "volumeid", "", "volumeid")
+	c.volumeResizeCmd.Flags().StringVar(&c.volumeName, "volumename", "", "volumename")
+	c.volumeResizeCmd.Flags().Int64Var(&c.size, "size", 0, "size")
+	c.volumeResizeCmd.Flags().StringVar(&c.fsType, "fstype", "", "fstype")
+	c.volumeResizeCmd.Flags().BoolVar(&c.yes, "yes", false, "Migrate the volume's data without asking for confirmation")
+	c.volumeResizeCmd.Flags().BoolVar(&c.runTask, "task", false, "Run the resize as a task in the REX-Ray service")
+	c.volumeRepairCmd.Flags().StringVar(&c.volumeID, "volumeid", "", "volumeid")
+	c.volumeRepairCmd.Flags().StringVar(&c.volumeName, "volumename", "", "volumename")
+	c.volumeRepairCmd.Flags().BoolVar(&c.force, "force", false, "Repair the volume even if it is not degraded")
+	c.volumeAdoptCmd.Flags().StringVar(&c.driverName, "driver", "", "The storage driver expected to own the volume, ex. ebs")
+	c.volumeAdoptCmd.Flags().StringVar(&c.volumeName, "name", "", "The name by which to refer to the volume")
+	c.volumeAdoptCmd.Flags().StringSliceVar(&c.labels, "label", nil, "A label to apply, ex. env=prod")
+	c.volumeReleaseCmd.Flags().StringVar(&c.volumeID, "volumeid", "", "volumeid")
+	c.volumeReleaseCmd.Flags().StringVar(&c.volumeName, "volumename", "", "volumename")
+	c.volumePinCmd.Flags().StringVar(&c.volumeID, "volumeid", "", "volumeid")
+	c.volumePinCmd.Flags().StringVar(&c.pinReason, "reason", "", "A note explaining why the volume is pinned")
+	c.volumeUnpinCmd.Flags().StringVar(&c.volumeID, "volumeid", "", "volumeid")
+	c.volumeProtectCmd.Flags().StringVar(&c.volumeID, "volumeid", "", "volumeid")
+	c.volumeProtectCmd.Flags().StringVar(&c.protectReason, "reason", "", "A note explaining why the volume is protected")
+	c.volumeUnprotectCmd.Flags().StringVar(&c.volumeID, "volumeid", "", "volumeid")
+	c.volumeRestoreTrashedCmd.Flags().StringVar(&c.volumeID, "volumeid", "", "volumeid")
+	c.volumeBenchCmd.Flags().StringVar(&c.volumeID, "volumeid", "", "volumeid")
+	c.volumeBenchCmd.Flags().StringVar(&c.ioJob, "job", "", "The path to a fio job file")
+	c.volumeBenchCmd.Flags().BoolVar(&c.ioNative, "native", false, "Use the Go I/O generator even if fio is installed")
+	c.volumeBenchCmd.Flags().StringVar(&c.ioPattern, "pattern", "", "The I/O pattern (read, write, randread, randwrite, randrw)")
+	c.volumeBenchCmd.Flags().Int64Var(&c.ioBlockSize, "blocksize", 0, "The size of each I/O in bytes, ex. 4096")
+	c.volumeBenchCmd.Flags().Int64Var(&c.ioFileSize, "filesize", 0, "The size of the test file in bytes")
+	c.volumeBenchCmd.Flags().StringVar(&c.ioDuration, "duration", "", "How long to generate I/O, ex. 30s")
+	c.volumeBenchCmd.Flags().IntVar(&c.ioWorkers, "workers", 0, "The number of concurrent workers")
+	c.volumeLabelCmd.Flags().StringVar(&c.volumeID, "volumeid", "", "volumeid")
+	c.volumeLabelCmd.Flags().StringVar(&c.volumeName, "volumename", "", "volumename")
+	c.volumeLabelCmd.Flags().StringSliceVar(&c.removeLabels, "remove", nil, "The keys of labels to remove")
+	c.volumeOverrideCmd.Flags().StringVar(&c.volumeID, "volumeid", "", "volumeid")
+	c.volumeOverrideCmd.Flags().StringVar(&c.volumeName, "volumename", "", "volumename")
+	c.volumeOverrideCmd.Flags().StringSliceVar(&c.removeOverrides, "remove", nil, "The keys of overrides to remove")
+	c.volumeOverrideCmd.Flags().BoolVar(&c.clearOverrides, "clear", false, "Remove all of the volume's overrides")
 
 	c.addOutputFormatFlag(c.volumeCmd.Flags())
 	c.addOutputFormatFlag(c.volumeGetCmd.Flags())
 	c.addOutputFormatFlag(c.volumeCreateCmd.Flags())
+	c.addOutputFormatFlag(c.volumeRemoveCmd.Flags())
 	c.addOutputFormatFlag(c.volumeAttachCmd.Flags())
 	c.addOutputFormatFlag(c.volumeMountCmd.Flags())
+	c.addOutputFormatFlag(c.volumeUnmountCmd.Flags())
 	c.addOutputFormatFlag(c.volumePathCmd.Flags())
 	c.addOutputFormatFlag(c.volumeMapCmd.Flags())
+	c.addOutputFormatFlag(c.volumeResizeCmd.Flags())
+	c.addOutputFormatFlag(c.volumeRepairCmd.Flags())
+	c.addOutputFormatFlag(c.volumeAdoptCmd.Flags())
+	c.addOutputFormatFlag(c.volumePinCmd.Flags())
+	c.addOutputFormatFlag(c.volumeProtectCmd.Flags())
+	c.addOutputFormatFlag(c.volumeTrashCmd.Flags())
+	c.addOutputFormatFlag(c.volumeRestoreTrashedCmd.Flags())
+	c.addOutputFormatFlag(c.volumeBenchCmd.Flags())
+	c.addOutputFormatFlag(c.volumeLabelCmd.Flags())
+	c.addOutputFormatFlag(c.volumeOverrideCmd.Flags())
 }
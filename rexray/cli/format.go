@@ -0,0 +1,100 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"text/template"
+
+	"github.com/spf13/cobra"
+)
+
+const outputFormatUsage = "The output format (yml, json, or a Go template " +
+	"such as '{{.ID}}\\t{{.Size}}')"
+
+// textFormat is the default format of the commands that print text for
+// people, such as version, unless another format is requested.
+const textFormat = "text"
+
+var unescapeFormat = strings.NewReplacer(`\t`, "\t", `\n`, "\n")
+
+var templateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		buf, err := json.Marshal(v)
+		return string(buf), err
+	},
+	"join":  strings.Join,
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+}
+
+func isTemplateFormat(format string) bool {
+	return strings.Contains(format, "{{")
+}
+
+func isYamlFormat(format string) bool {
+	switch strings.ToLower(format) {
+	case "", "yml", "yaml":
+		return true
+	}
+	return false
+}
+
+// marshalTemplateOutput executes a template for the value, or, if the value
+// is a slice, for each of its elements on a line of its own. The fields of
+// the template are those of the value's Go type, ex. {{.ID}}, and the escape
+// sequences \t and \n are replaced with a tab and a new line so that the
+// template may be given on the command line.
+func marshalTemplateOutput(format string, v interface{}) ([]byte, error) {
+	t, err := template.New("format").
+		Funcs(templateFuncs).
+		Parse(unescapeFormat.Replace(format))
+	if err != nil {
+		return nil, err
+	}
+
+	items := []interface{}{v}
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+		items = make([]interface{}, rv.Len())
+		for i := range items {
+			items[i] = rv.Index(i).Interface()
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	for i, item := range items {
+		if i > 0 {
+			buf.WriteByte('\n')
+		}
+		if err := t.Execute(buf, item); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// printIndentedOutput prints a document, such as a JSON schema or a Grafana
+// dashboard, that is indented JSON unless another format is requested with
+// --format.
+func (c *CLI) printIndentedOutput(cmd *cobra.Command, v interface{}) {
+	c.readOutputFormatFlag(cmd)
+	if strings.ToUpper(c.outputFormat) == "JSON" {
+		buf, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			fatal(err)
+		}
+		fmt.Println(string(buf))
+		return
+	}
+	out, err := c.marshalOutput(v)
+	if err != nil {
+		fatal(err)
+	}
+	fmt.Println(out)
+}
@@ -0,0 +1,28 @@
+package cli
+
+import "testing"
+
+type formatTestVolume struct {
+	ID   string
+	Size int64
+}
+
+func TestMarshalTemplateOutput(t *testing.T) {
+	vols := []*formatTestVolume{{"vol-1", 8}, {"vol-2", 16}}
+
+	buf, err := marshalTemplateOutput(`{{.ID}}\t{{.Size}}`, &vols)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := string(buf); s != "vol-1\t8\nvol-2\t16" {
+		t.Fatalf("unexpected output %q", s)
+	}
+
+	buf, err = marshalTemplateOutput(`{{json .}}`, vols[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := string(buf); s != `{"ID":"vol-1","Size":8}` {
+		t.Fatalf("unexpected output %q", s)
+	}
+}
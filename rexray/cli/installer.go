@@ -3,8 +3,11 @@ package cli
 import (
 	"bytes"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"os/exec"
+	"path"
+	"regexp"
 	"runtime"
 	"strings"
 	"text/template"
@@ -21,22 +24,176 @@ const (
 	SystemD
 	UpdateRcD
 	ChkConfig
+	OpenRC
 )
 
-func install() {
+// serviceInstance is an instance of the REX-Ray service registered with the
+// host's init system. Named instances have their own service, prefix, and
+// configuration so that several instances, for example one per storage
+// platform, may run on one host. User instances run as the systemd services
+// of the users that install them.
+type serviceInstance struct {
+	name   string
+	prefix string
+	user   bool
+}
+
+// svc is the instance controlled by the service commands. It is named by the
+// REXRAY_INSTANCE environment variable, which the installer sets in the
+// environment of named instances.
+var svc = detectServiceInstance(os.Getenv("REXRAY_INSTANCE"))
+
+func newServiceInstance(name string, user bool) *serviceInstance {
+	s := &serviceInstance{name: name, user: user}
+	if util.IsPrefixed() {
+		s.prefix = util.GetPrefix()
+	} else {
+		s.prefix = util.InstancePrefix(name, user)
+	}
+	return s
+}
+
+// detectServiceInstance returns an installed instance, which is a user
+// instance if only the user's systemd service of the instance is installed.
+func detectServiceInstance(name string) *serviceInstance {
+	s := newServiceInstance(name, false)
+	if gotil.FileExists(s.unitFilePath()) {
+		return s
+	}
+	if u := newServiceInstance(name, true); gotil.FileExists(
+		u.unitFilePath()) {
+		return u
+	}
+	return s
+}
+
+// serviceName returns the name of the instance's service.
+func (s *serviceInstance) serviceName() string {
+	if s.name == "" {
+		return "rexray"
+	}
+	return "rexray-" + s.name
+}
+
+func (s *serviceInstance) unitFilePath() string {
+	switch {
+	case s.user:
+		return path.Join(gotil.HomeDir(),
+			".config", "systemd", "user", s.serviceName()+".service")
+	case s.name == "":
+		return util.UnitFilePath
+	default:
+		return path.Join("/etc/systemd/system", s.serviceName()+".service")
+	}
+}
+
+func (s *serviceInstance) initFilePath() string {
+	if s.name == "" {
+		return util.InitFilePath
+	}
+	return path.Join("/etc/init.d", s.serviceName())
+}
+
+// env returns the environment that selects the instance's prefix.
+func (s *serviceInstance) env() [][2]string {
+	var env [][2]string
+	if s.prefix != "" && s.prefix != "/" {
+		env = append(env, [2]string{"REXRAY_HOME", s.prefix})
+	}
+	if s.name != "" {
+		env = append(env, [2]string{"REXRAY_INSTANCE", s.name})
+	}
+	return env
+}
+
+// systemctl returns a systemctl command for the instance's service.
+func (s *serviceInstance) systemctl(args ...string) *exec.Cmd {
+	if s.user {
+		args = append([]string{"--user"}, args...)
+	}
+	cmd := exec.Command("systemctl", args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd
+}
+
+func (c *CLI) install() {
 	checkOpPerms("installed")
 
 	_, _, exeFile := gotil.GetThisPathParts()
 
-	if runtime.GOOS == "linux" {
-		switch getInitSystemType() {
-		case SystemD:
-			installSystemD(exeFile)
-		case UpdateRcD:
-			installUpdateRcd(exeFile)
-		case ChkConfig:
-			installChkConfig(exeFile)
+	if runtime.GOOS != "linux" {
+		return
+	}
+
+	s := c.installInstance()
+	initSys := c.installInitSystem(s)
+	util.Prefix(s.prefix)
+	if c.cfgFile != "" {
+		installConfigFile(c.cfgFile)
+	}
+
+	switch initSys {
+	case SystemD:
+		installSystemD(s, exeFile)
+	case OpenRC:
+		installOpenRC(s, exeFile)
+	case UpdateRcD:
+		installUpdateRcd(s, exeFile)
+	case ChkConfig:
+		installChkConfig(s, exeFile)
+	}
+}
+
+var instanceNameRX = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// installInstance returns the instance selected with --name and --user.
+func (c *CLI) installInstance() *serviceInstance {
+	if c.installName != "" && !instanceNameRX.MatchString(c.installName) {
+		fatalf("invalid instance name: %s", c.installName)
+	}
+	return newServiceInstance(c.installName, c.installUser)
+}
+
+// installInitSystem returns the init system with which an instance is
+// installed or uninstalled, which is the one selected with --init or else
+// the detected one.
+func (c *CLI) installInitSystem(s *serviceInstance) int {
+	initSys := getInitSystemType()
+	switch c.installInit {
+	case "":
+	case "systemd":
+		initSys = SystemD
+	case "openrc":
+		initSys = OpenRC
+	case "sysv":
+		switch {
+		case gotil.FileExistsInPath("update-rc.d"):
+			initSys = UpdateRcD
+		case gotil.FileExistsInPath("chkconfig"):
+			initSys = ChkConfig
+		default:
+			fatalf("sysv requires update-rc.d or chkconfig")
 		}
+	default:
+		fatalf("invalid init system: %s", c.installInit)
+	}
+	if s.user && initSys != SystemD {
+		fatalf("--user requires systemd")
+	}
+	return initSys
+}
+
+// installConfigFile copies a configuration file to the configuration file
+// of the instance being installed.
+func installConfigFile(cfgFile string) {
+	buf, err := ioutil.ReadFile(cfgFile)
+	if err != nil {
+		fatal(err)
+	}
+	if err := ioutil.WriteFile(
+		util.EtcFilePath("config.yml"), buf, 0644); err != nil {
+		fatal(err)
 	}
 }
 
@@ -108,14 +265,21 @@ func uninstallDeb(pkgName string) bool {
 	return true
 }
 
-func uninstall(pkgManager bool) {
+// uninstall uninstalls an instance of the service. Uninstalling the unnamed
+// instance of root also removes the executable, or the package that
+// installed it, and the prefix. The prefixes of other instances are kept,
+// since they hold the instances' configuration and state.
+func (c *CLI) uninstall(pkgManager bool) {
 	checkOpPerms("uninstalled")
 
 	_, _, binFile := gotil.GetThisPathParts()
 
+	s := c.installInstance()
+	instance := s.name != "" || s.user
+
 	// if the uninstall command was executed manually we should check to see
 	// if this file is owned by a package manager and remove it that way if so
-	if !pkgManager {
+	if !pkgManager && !instance {
 		log.WithField("binFile", binFile).Debug("is this a managed file?")
 		var pkgName string
 		if isRpmInstall(binFile, &pkgName) {
@@ -127,6 +291,10 @@ func uninstall(pkgManager bool) {
 		}
 	}
 
+	initSys := c.installInitSystem(s)
+	svc = s
+	util.Prefix(s.prefix)
+
 	func() {
 		defer func() {
 			recover()
@@ -134,13 +302,21 @@ func uninstall(pkgManager bool) {
 		stop()
 	}()
 
-	switch getInitSystemType() {
+	switch initSys {
 	case SystemD:
-		uninstallSystemD()
+		uninstallSystemD(s)
+	case OpenRC:
+		uninstallOpenRC(s)
 	case UpdateRcD:
-		uninstallUpdateRcd()
+		uninstallUpdateRcd(s)
 	case ChkConfig:
-		uninstallChkConfig()
+		uninstallChkConfig(s)
+	}
+
+	if instance {
+		fmt.Printf("The configuration and state of %s remain in %s.\n",
+			s.serviceName(), s.prefix)
+		return
 	}
 
 	if !pkgManager {
@@ -155,6 +331,8 @@ func getInitSystemCmd() string {
 	switch getInitSystemType() {
 	case SystemD:
 		return "systemd"
+	case OpenRC:
+		return "openrc"
 	case UpdateRcD:
 		return "update-rc.d"
 	case ChkConfig:
@@ -169,6 +347,10 @@ func getInitSystemType() int {
 		return SystemD
 	}
 
+	if gotil.FileExistsInPath("openrc-run") {
+		return OpenRC
+	}
+
 	if gotil.FileExistsInPath("update-rc.d") {
 		return UpdateRcD
 	}
@@ -180,32 +362,62 @@ func getInitSystemType() int {
 	return Unknown
 }
 
-func installSystemD(exeFile string) {
-	createUnitFile(exeFile)
-	createEnvFile()
+func installSystemD(s *serviceInstance, exeFile string) {
+	createUnitFile(s, exeFile)
+	createEnvFile(s)
 
-	cmd := exec.Command("systemctl", "enable", "-q", "rexray.service")
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	err := cmd.Run()
+	if s.user {
+		if err := s.systemctl("daemon-reload").Run(); err != nil {
+			log.Fatalf("installation error %v", err)
+		}
+	}
+	err := s.systemctl("enable", "-q", s.serviceName()+".service").Run()
 
 	if err != nil {
 		log.Fatalf("installation error %v", err)
 	}
 
-	fmt.Print("REX-Ray is now installed. Before starting it please check ")
-	fmt.Print("http://github.com/emccode/rexray for instructions on how to ")
-	fmt.Print("configure it.\n\n Once configured the REX-Ray service can be ")
-	fmt.Print("started with the command 'sudo systemctl start rexray'.\n\n")
+	startCmd := "sudo systemctl start " + s.serviceName()
+	if s.user {
+		startCmd = "systemctl --user start " + s.serviceName()
+	}
+	printInstalled(s, startCmd)
 }
 
-func uninstallSystemD() {
+func uninstallSystemD(s *serviceInstance) {
 
 	// a link created by systemd as docker should "want" rexray as a service.
 	// the uninstaller will fail
-	os.Remove("/etc/systemd/system/docker.service.wants/rexray.service")
+	if !s.user {
+		os.Remove(path.Join("/etc/systemd/system/docker.service.wants",
+			s.serviceName()+".service"))
+	}
+
+	err := s.systemctl("disable", "-q", s.serviceName()+".service").Run()
+
+	if err != nil {
+		log.Fatalf("uninstallation error %v", err)
+	}
+
+	os.Remove(s.unitFilePath())
+}
 
-	cmd := exec.Command("systemctl", "disable", "-q", "rexray.service")
+func installOpenRC(s *serviceInstance, exeFile string) {
+	createInitFile(s, exeFile, "OpenRC", openRCScriptTemplate)
+	cmd := exec.Command("rc-update", "add", s.serviceName(), "default")
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	err := cmd.Run()
+
+	if err != nil {
+		log.Fatalf("installation error %v", err)
+	}
+
+	printInstalled(s, "sudo rc-service "+s.serviceName()+" start")
+}
+
+func uninstallOpenRC(s *serviceInstance) {
+	cmd := exec.Command("rc-update", "del", s.serviceName(), "default")
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	err := cmd.Run()
@@ -214,12 +426,12 @@ func uninstallSystemD() {
 		log.Fatalf("uninstallation error %v", err)
 	}
 
-	os.Remove(util.UnitFilePath)
+	os.Remove(s.initFilePath())
 }
 
-func installUpdateRcd(exeFile string) {
-	createInitFile(exeFile)
-	cmd := exec.Command("update-rc.d", "rexray", "defaults")
+func installUpdateRcd(s *serviceInstance, exeFile string) {
+	createInitFile(s, exeFile, "InitScript", initScriptTemplate)
+	cmd := exec.Command("update-rc.d", s.serviceName(), "defaults")
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	err := cmd.Run()
@@ -228,17 +440,14 @@ func installUpdateRcd(exeFile string) {
 		log.Fatalf("installation error %v", err)
 	}
 
-	fmt.Print("REX-Ray is now installed. Before starting it please check ")
-	fmt.Print("http://github.com/emccode/rexray for instructions on how to ")
-	fmt.Print("configure it.\n\n Once configured the REX-Ray service can be ")
-	fmt.Print("started with the command 'sudo /etc/init.d/rexray start'.\n\n")
+	printInstalled(s, "sudo "+s.initFilePath()+" start")
 }
 
-func uninstallUpdateRcd() {
+func uninstallUpdateRcd(s *serviceInstance) {
 
-	os.Remove(util.InitFilePath)
+	os.Remove(s.initFilePath())
 
-	cmd := exec.Command("update-rc.d", "rexray", "remove")
+	cmd := exec.Command("update-rc.d", s.serviceName(), "remove")
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	err := cmd.Run()
@@ -248,9 +457,9 @@ func uninstallUpdateRcd() {
 	}
 }
 
-func installChkConfig(exeFile string) {
-	createInitFile(exeFile)
-	cmd := exec.Command("chkconfig", "rexray", "on")
+func installChkConfig(s *serviceInstance, exeFile string) {
+	createInitFile(s, exeFile, "InitScript", initScriptTemplate)
+	cmd := exec.Command("chkconfig", s.serviceName(), "on")
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	err := cmd.Run()
@@ -259,14 +468,11 @@ func installChkConfig(exeFile string) {
 		log.Fatalf("installation error %v", err)
 	}
 
-	fmt.Print("REX-Ray is now installed. Before starting it please check ")
-	fmt.Print("http://github.com/emccode/rexray for instructions on how to ")
-	fmt.Print("configure it.\n\n Once configured the REX-Ray service can be ")
-	fmt.Print("started with the command 'sudo /etc/init.d/rexray start'.\n\n")
+	printInstalled(s, "sudo "+s.initFilePath()+" start")
 }
 
-func uninstallChkConfig() {
-	cmd := exec.Command("chkconfig", "--del", "rexray")
+func uninstallChkConfig(s *serviceInstance) {
+	cmd := exec.Command("chkconfig", "--del", s.serviceName())
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	err := cmd.Run()
@@ -275,34 +481,77 @@ func uninstallChkConfig() {
 		log.Fatalf("uninstallation error %v", err)
 	}
 
-	os.Remove(util.InitFilePath)
+	os.Remove(s.initFilePath())
 }
 
-func createEnvFile() {
-	f, err := os.OpenFile(
-		util.EtcFilePath(util.EnvFileName), os.O_CREATE|os.O_WRONLY, 0644)
+func printInstalled(s *serviceInstance, startCmd string) {
+	if s.name == "" {
+		fmt.Print("REX-Ray is now installed. ")
+	} else {
+		fmt.Printf("REX-Ray instance %s is now installed with its "+
+			"configuration at %s. ", s.name, util.EtcFilePath("config.yml"))
+	}
+	fmt.Print("Before starting it please check ")
+	fmt.Print("http://github.com/emccode/rexray for instructions on how to ")
+	fmt.Print("configure it.\n\n Once configured the REX-Ray service can be ")
+	fmt.Printf("started with the command '%s'.\n\n", startCmd)
+}
+
+func createEnvFile(s *serviceInstance) {
+	f, err := os.OpenFile(util.EtcFilePath(util.EnvFileName),
+		os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
 	if err != nil {
 		panic(err)
 	}
 	defer f.Close()
 
-	if util.IsPrefixed() {
-		f.WriteString("REXRAY_HOME=")
-		f.WriteString(util.GetPrefix())
+	for _, kv := range s.env() {
+		fmt.Fprintf(f, "%s=%s\n", kv[0], kv[1])
 	}
 }
 
-func createUnitFile(exeFile string) {
+// serviceTemplateData is the data of the templates of the unit and init
+// files.
+type serviceTemplateData struct {
+	Name        string
+	Service     string
+	Description string
+	RexrayBin   string
+	EnvFile     string
+	Env         [][2]string
+	User        bool
+}
 
-	data := struct {
-		RexrayBin string
-		EnvFile   string
-	}{
-		exeFile,
-		util.EtcFilePath(util.EnvFileName),
+func newServiceTemplateData(
+	s *serviceInstance, exeFile string) *serviceTemplateData {
+
+	d := &serviceTemplateData{
+		Name:        s.name,
+		Service:     s.serviceName(),
+		Description: "rexray",
+		RexrayBin:   exeFile,
+		EnvFile:     util.EtcFilePath(util.EnvFileName),
+		Env:         s.env(),
+		User:        s.user,
+	}
+	if s.name != "" {
+		d.Description = fmt.Sprintf("rexray (%s)", s.name)
 	}
+	return d
+}
+
+var serviceTemplateFuncs = template.FuncMap{
+	// quote quotes a value for a shell
+	"quote": func(s string) string {
+		return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+	},
+}
+
+// executeServiceTemplate renders the template of a unit or init file.
+func executeServiceTemplate(
+	name, text string, data *serviceTemplateData) string {
 
-	tmpl, err := template.New("UnitFile").Parse(unitFileTemplate)
+	tmpl, err := template.New(name).Funcs(serviceTemplateFuncs).Parse(text)
 	if err != nil {
 		panic(err)
 	}
@@ -311,9 +560,18 @@ func createUnitFile(exeFile string) {
 	if err != nil {
 		panic(err)
 	}
-	text := buf.String()
+	return buf.String()
+}
+
+func createUnitFile(s *serviceInstance, exeFile string) {
+
+	text := executeServiceTemplate(
+		"UnitFile", unitFileTemplate, newServiceTemplateData(s, exeFile))
 
-	f, err := os.OpenFile(util.UnitFilePath, os.O_CREATE|os.O_WRONLY, 0644)
+	unitFilePath := s.unitFilePath()
+	os.MkdirAll(path.Dir(unitFilePath), 0755)
+	f, err := os.OpenFile(
+		unitFilePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
 	if err != nil {
 		panic(err)
 	}
@@ -323,42 +581,40 @@ func createUnitFile(exeFile string) {
 }
 
 const unitFileTemplate = `[Unit]
-Description=rexray
+Description={{.Description}}
+{{- if not .User}}
 Before=docker.service
+{{- end}}
 
 [Service]
+Type=notify
 EnvironmentFile={{.EnvFile}}
 ExecStart={{.RexrayBin}} start -f
 ExecReload=/bin/kill -HUP $MAINPID
 KillMode=process
+WatchdogSec=60
+Restart=on-failure
 
 [Install]
+{{- if .User}}
+WantedBy=default.target
+{{- else}}
 WantedBy=docker.service
+{{- end}}
 `
 
-func createInitFile(exeFile string) {
+func createInitFile(s *serviceInstance, exeFile, name, text string) {
 
-	data := struct {
-		RexrayBin string
-	}{
-		exeFile,
-	}
+	text = executeServiceTemplate(
+		name, text, newServiceTemplateData(s, exeFile))
 
-	tmpl, err := template.New("InitScript").Parse(initScriptTemplate)
-	if err != nil {
-		panic(err)
-	}
-	var buf bytes.Buffer
-	err = tmpl.Execute(&buf, data)
-	if err != nil {
-		panic(err)
-	}
-	text := buf.String()
+	initFilePath := s.initFilePath()
 
 	// wrapped in a function to defer the close to ensure file is written to
 	// disk before subsequent chmod below
 	func() {
-		f, err := os.OpenFile(util.InitFilePath, os.O_CREATE|os.O_WRONLY, 0644)
+		f, err := os.OpenFile(
+			initFilePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
 		if err != nil {
 			panic(err)
 		}
@@ -367,11 +623,12 @@ func createInitFile(exeFile string) {
 		f.WriteString(text)
 	}()
 
-	os.Chmod(util.InitFilePath, 0755)
+	os.Chmod(initFilePath, 0755)
 }
 
-const initScriptTemplate = `### BEGIN INIT INFO
-# Provides:          rexray
+const initScriptTemplate = `#!/bin/sh
+### BEGIN INIT INFO
+# Provides:          {{.Service}}
 # Required-Start:    $remote_fs $syslog
 # Required-Stop:     $remote_fs $syslog
 # Default-Start:     2 3 4 5
@@ -379,6 +636,9 @@ const initScriptTemplate = `### BEGIN INIT INFO
 # Short-Description: Start daemon at boot time
 # Description:       Enable service provided by daemon.
 ### END INIT INFO
+{{- range .Env}}
+export {{index . 0}}={{quote (index . 1)}}
+{{- end}}
 
 case "$1" in
   start)
@@ -403,3 +663,20 @@ case "$1" in
     echo "Usage: $0 {start|stop|status|restart|reload|force-reload}"
 esac
 `
+
+const openRCScriptTemplate = `#!/sbin/openrc-run
+{{- range .Env}}
+export {{index . 0}}={{quote (index . 1)}}
+{{- end}}
+
+description="{{.Description}}"
+command={{quote .RexrayBin}}
+command_args="start -f"
+command_background=true
+pidfile="/run/{{.Service}}.pid"
+
+depend() {
+  need net
+  before docker
+}
+`
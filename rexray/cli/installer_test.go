@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestServiceInstance(t *testing.T) {
+	s := &serviceInstance{}
+	if s.serviceName() != "rexray" ||
+		s.unitFilePath() != "/etc/systemd/system/rexray.service" ||
+		s.initFilePath() != "/etc/init.d/rexray" || len(s.env()) != 0 {
+		t.Fatalf("unexpected default instance %+v", s)
+	}
+
+	s = &serviceInstance{name: "ebs", prefix: "/var/lib/rexray/instances/ebs"}
+	if s.serviceName() != "rexray-ebs" ||
+		s.unitFilePath() != "/etc/systemd/system/rexray-ebs.service" ||
+		s.initFilePath() != "/etc/init.d/rexray-ebs" {
+		t.Fatalf("unexpected instance %+v", s)
+	}
+	env := s.env()
+	if len(env) != 2 || env[0][1] != s.prefix || env[1][1] != "ebs" {
+		t.Fatalf("unexpected env %v", env)
+	}
+
+	s.user = true
+	if !strings.HasSuffix(
+		s.unitFilePath(), "/.config/systemd/user/rexray-ebs.service") {
+		t.Fatalf("unexpected user unit %s", s.unitFilePath())
+	}
+}
+
+func TestServiceTemplates(t *testing.T) {
+	d := &serviceTemplateData{
+		Name:        "ebs",
+		Service:     "rexray-ebs",
+		Description: "rexray (ebs)",
+		RexrayBin:   "/usr/bin/rexray",
+		EnvFile:     "/var/lib/rexray/instances/ebs/etc/rexray/rexray.env",
+		Env: [][2]string{
+			{"REXRAY_HOME", "/var/lib/rexray/instances/ebs"},
+			{"REXRAY_INSTANCE", "it's"},
+		},
+	}
+
+	unit := executeServiceTemplate("UnitFile", unitFileTemplate, d)
+	for _, l := range []string{
+		"Description=rexray (ebs)\nBefore=docker.service\n\n[Service]",
+		"EnvironmentFile=" + d.EnvFile + "\n",
+		"[Install]\nWantedBy=docker.service\n",
+	} {
+		if !strings.Contains(unit, l) {
+			t.Fatalf("unit missing %q:\n%s", l, unit)
+		}
+	}
+
+	d.User = true
+	unit = executeServiceTemplate("UnitFile", unitFileTemplate, d)
+	if strings.Contains(unit, "docker") ||
+		!strings.Contains(unit, "WantedBy=default.target\n") {
+		t.Fatalf("unexpected user unit:\n%s", unit)
+	}
+
+	for name, text := range map[string]string{
+		"InitScript": initScriptTemplate,
+		"OpenRC":     openRCScriptTemplate,
+	} {
+		script := executeServiceTemplate(name, text, d)
+		for _, l := range []string{
+			"\nexport REXRAY_HOME='/var/lib/rexray/instances/ebs'\n",
+			`export REXRAY_INSTANCE='it'\''s'` + "\n",
+		} {
+			if !strings.Contains(script, l) {
+				t.Fatalf("%s missing %q:\n%s", name, l, script)
+			}
+		}
+	}
+}
@@ -4,29 +4,31 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"net/http"
 	"os"
 	"os/exec"
 	"os/signal"
 	"syscall"
 
 	log "github.com/Sirupsen/logrus"
+	"github.com/akutz/goof"
 	"github.com/akutz/gotil"
 
 	"github.com/emccode/libstorage/api/context"
+	"github.com/emccode/rexray/core/preflight"
+	"github.com/emccode/rexray/core/probes"
+	"github.com/emccode/rexray/core/systemd"
 	rrdaemon "github.com/emccode/rexray/daemon"
 	"github.com/emccode/rexray/util"
 )
 
 var (
-	useSystemDForSCMCmds = gotil.FileExists(util.UnitFilePath) &&
-		getInitSystemType() == SystemD
-
 	serverSockFile = util.RunFilePath("server.sock")
 	clientSockFile = util.RunFilePath("client.sock")
 )
 
 func (c *CLI) start() {
-	if !c.fg && useSystemDForSCMCmds {
+	if !c.fg && useSystemDForSCMCmds() {
 		startViaSystemD()
 		return
 	}
@@ -91,10 +93,15 @@ func statusViaSystemD() {
 	execSystemDCmd("status")
 }
 
+// useSystemDForSCMCmds returns a flag indicating whether the service is
+// controlled by systemd, which is when the instance's unit is installed.
+func useSystemDForSCMCmds() bool {
+	return gotil.FileExists(svc.unitFilePath()) &&
+		getInitSystemType() == SystemD
+}
+
 func execSystemDCmd(cmdType string) {
-	cmd := exec.Command("systemctl", cmdType, "-l", "rexray")
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	cmd := svc.systemctl(cmdType, "-l", svc.serviceName())
 	if err := cmd.Run(); err != nil {
 		if exitErr, ok := err.(*exec.ExitError); ok {
 			if status, ok := exitErr.Sys().(syscall.WaitStatus); ok {
@@ -189,8 +196,19 @@ func (c *CLI) startDaemon() {
 		conn.Close()
 	}
 
+	if ok, err := systemd.Notify(systemd.Ready); err != nil {
+		c.ctx.WithError(err).Warn("error notifying systemd")
+	} else if ok {
+		c.ctx.Debug("notified systemd of readiness")
+	}
+
+	stopWatchdog := make(chan struct{})
+	go systemd.Watchdog(c.ctx, checkLive, stopWatchdog)
+
 	sigv := <-sigc
 	c.ctx.WithField("signal", sigv).Info("received shutdown signal")
+	close(stopWatchdog)
+	systemd.Notify(systemd.Stopping)
 	stop <- sigv
 
 	os.Remove(serverSockFile)
@@ -200,6 +218,22 @@ func (c *CLI) startDaemon() {
 	}
 }
 
+// checkLive probes the liveness of the service through its own socket so
+// that systemd's watchdog is only pinged while the service responds.
+func checkLive() error {
+	client := newHTTPClient()
+	client.Timeout = systemd.WatchdogInterval() / 2
+	resp, err := client.Get("http://s/health/live")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return goof.WithField("status", resp.StatusCode, "service is not live")
+	}
+	return nil
+}
+
 func (c *CLI) tryToStartDaemon() {
 	_, _, thisAbsPath := gotil.GetThisPathParts()
 
@@ -258,7 +292,7 @@ func (c *CLI) tryToStartDaemon() {
 }
 
 func stop() {
-	if useSystemDForSCMCmds {
+	if useSystemDForSCMCmds() {
 		stopViaSystemD()
 		return
 	}
@@ -285,8 +319,9 @@ func stop() {
 }
 
 func (c *CLI) status() {
-	if useSystemDForSCMCmds {
+	if useSystemDForSCMCmds() {
 		statusViaSystemD()
+		printReadiness()
 		return
 	}
 
@@ -315,7 +350,39 @@ func (c *CLI) status() {
 	}
 
 	fmt.Printf("REX-Ray is running at PID %d\n", pid)
-	return
+	printReadiness()
+}
+
+// printReadiness prints the result of the running service's readiness probe.
+func printReadiness() {
+	if !gotil.FileExists(serverSockFile) {
+		return
+	}
+
+	r := &probes.Result{}
+	if err := getJSON("http://s/health/ready", r); err != nil {
+		fmt.Printf("Error probing REX-Ray readiness: %v\n", err)
+		return
+	}
+
+	switch {
+	case !r.Ready:
+		fmt.Println("REX-Ray is not ready")
+	case r.Degraded:
+		fmt.Println("REX-Ray is ready but degraded")
+	default:
+		fmt.Println("REX-Ray is ready")
+	}
+	for _, c := range r.Checks {
+		name := c.Name
+		if c.Service != "" {
+			name = c.Service + "/" + c.Name
+		}
+		fmt.Printf("  %-4s  %-24s  %s\n", c.Status, name, c.Message)
+		if c.Status != preflight.Pass && c.Remedy != "" {
+			fmt.Printf("        remedy: %s\n", c.Remedy)
+		}
+	}
 }
 
 func (c *CLI) restart() {
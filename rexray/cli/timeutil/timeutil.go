@@ -0,0 +1,93 @@
+// Package timeutil parses and formats the times accepted and printed by the
+// CLI's listing commands.
+//
+// The times the commands accept are never localized: they are either
+// relative to now or ISO-8601, and the times they print are ISO-8601 in the
+// requested time zone so that the output may be sorted and parsed regardless
+// of the host's locale.
+package timeutil
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/akutz/goof"
+)
+
+// Day is the unit of the "d" suffix accepted by ParseSince.
+const Day = 24 * time.Hour
+
+// Week is the unit of the "w" suffix accepted by ParseSince.
+const Week = 7 * Day
+
+// dateLayouts are the absolute forms accepted by ParseSince.
+var dateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+// Location returns the time zone with the provided name. An empty name is
+// UTC and "local" is the host's time zone; any other name is an IANA time
+// zone name such as America/New_York.
+func Location(name string) (*time.Location, error) {
+	switch strings.ToLower(name) {
+	case "", "utc", "z":
+		return time.UTC, nil
+	case "local":
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, goof.WithFieldE("timeZone", name, "invalid time zone", err)
+	}
+	return loc, nil
+}
+
+// ParseSince parses a point in time that is either relative to now, such as
+// 7d, 2w, or 36h, or an ISO-8601 date or time. Dates and times without an
+// offset are interpreted in the provided time zone.
+func ParseSince(s string, now time.Time, loc *time.Location) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if d, ok := parseRelative(s); ok {
+		return now.Add(-d), nil
+	}
+	for _, l := range dateLayouts {
+		if t, err := time.ParseInLocation(l, s, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, goof.WithField("since", s,
+		"invalid time; expected a duration such as 7d or an ISO-8601 date")
+}
+
+func parseRelative(s string) (time.Duration, bool) {
+	var unit time.Duration
+	switch {
+	case strings.HasSuffix(s, "d"):
+		unit = Day
+	case strings.HasSuffix(s, "w"):
+		unit = Week
+	default:
+		d, err := time.ParseDuration(s)
+		return d, err == nil && d >= 0
+	}
+	n, err := strconv.ParseUint(s[:len(s)-1], 10, 32)
+	if err != nil {
+		return 0, false
+	}
+	return time.Duration(n) * unit, true
+}
+
+// In returns a time in the provided time zone, leaving the zero time as it
+// is so that it is still recognized as unset.
+func In(t time.Time, loc *time.Location) time.Time {
+	if t.IsZero() {
+		return t
+	}
+	return t.In(loc)
+}
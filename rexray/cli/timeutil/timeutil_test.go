@@ -0,0 +1,77 @@
+package timeutil
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2017, 3, 10, 12, 0, 0, 0, time.UTC)
+	est := time.FixedZone("EST", -5*3600)
+
+	tests := []struct {
+		since    string
+		loc      *time.Location
+		expected time.Time
+	}{
+		{"7d", time.UTC, now.Add(-7 * Day)},
+		{"2w", time.UTC, now.Add(-2 * Week)},
+		{"36h", time.UTC, now.Add(-36 * time.Hour)},
+		{"2017-03-01", time.UTC, time.Date(2017, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{"2017-03-01", est, time.Date(2017, 3, 1, 5, 0, 0, 0, time.UTC)},
+		{"2017-03-01T08:30", est, time.Date(2017, 3, 1, 13, 30, 0, 0, time.UTC)},
+		{"2017-03-01T08:30:00Z", est, time.Date(2017, 3, 1, 8, 30, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		actual, err := ParseSince(tt.since, now, tt.loc)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.since, err)
+		}
+		if !actual.Equal(tt.expected) {
+			t.Errorf("%s: since=%v; expected %v", tt.since, actual, tt.expected)
+		}
+	}
+}
+
+func TestParseSinceInvalid(t *testing.T) {
+	now := time.Now()
+	for _, since := range []string{"7x", "-1d", "d", "-2h", "03/01/2017"} {
+		if _, err := ParseSince(since, now, time.UTC); err == nil {
+			t.Errorf("%s: expected an error", since)
+		}
+	}
+}
+
+func TestLocation(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected *time.Location
+	}{
+		{"", time.UTC},
+		{"UTC", time.UTC},
+		{"local", time.Local},
+	}
+	for _, tt := range tests {
+		loc, err := Location(tt.name)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if loc != tt.expected {
+			t.Errorf("%s: %v; expected %v", tt.name, loc, tt.expected)
+		}
+	}
+	if _, err := Location("Nowhere/Special"); err == nil {
+		t.Error("Nowhere/Special: expected an error")
+	}
+
+	ts := time.Date(2017, 3, 1, 8, 30, 0, 0, time.UTC)
+	est := time.FixedZone("EST", -5*3600)
+	if actual := In(ts, est).Format(time.RFC3339); actual !=
+		"2017-03-01T03:30:00-05:00" {
+		t.Errorf("In: %s", actual)
+	}
+	if !In(time.Time{}, est).IsZero() {
+		t.Error("In: expected the zero time")
+	}
+}
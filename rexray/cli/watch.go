@@ -0,0 +1,185 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/core/nodes"
+	"github.com/emccode/rexray/core/state"
+)
+
+// The states of a volume reported by volume ls --watch.
+const (
+	volumeAvailable = "available"
+	volumeAttached  = "attached"
+	volumeMounted   = "mounted"
+	volumeRemoved   = "removed"
+)
+
+// volumeTransition is a change of a volume's state.
+type volumeTransition struct {
+	Time       time.Time `json:"time" yaml:"time"`
+	VolumeID   string    `json:"volumeID" yaml:"volumeID"`
+	VolumeName string    `json:"volumeName,omitempty" yaml:"volumeName,omitempty"`
+	From       string    `json:"from,omitempty" yaml:"from,omitempty"`
+	To         string    `json:"to" yaml:"to"`
+
+	// Instances are the IDs of the instances the volume is attached to.
+	Instances []string `json:"instances,omitempty" yaml:"instances,omitempty"`
+}
+
+type volumeStatus struct {
+	name      string
+	state     string
+	instances []string
+}
+
+// watchVolumes polls the volumes at the interval set by --interval and
+// prints each change of a volume's state, starting with the state of every
+// volume, until the process is interrupted. A volume is mounted if an agent
+// reported it mounted in its last heartbeat.
+func (c *CLI) watchVolumes() {
+	interval := c.watchInterval
+	if interval <= 0 {
+		interval = 2 * time.Second
+	}
+
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, syscall.SIGINT, syscall.SIGTERM)
+
+	known := map[string]*volumeStatus{}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		current, err := c.volumeStatuses()
+		if err != nil {
+			log.WithError(err).Warn("error listing volumes")
+		} else {
+			now := time.Now().UTC()
+			for _, t := range volumeTransitions(known, current, now) {
+				c.printTransition(t)
+			}
+			known = current
+		}
+
+		select {
+		case <-ticker.C:
+		case <-sigc:
+			return
+		}
+	}
+}
+
+func (c *CLI) volumeStatuses() (map[string]*volumeStatus, error) {
+	vols, err := c.r.Storage().Volumes(
+		c.ctx, &apitypes.VolumesOpts{Attachments: true})
+	if err != nil {
+		return nil, err
+	}
+	if vols, err = c.filterVolumesByLabels(vols); err != nil {
+		return nil, err
+	}
+
+	all, err := nodes.List(state.Controller())
+	if err != nil {
+		return nil, err
+	}
+	mounted := map[string]bool{}
+	for _, n := range all {
+		for _, id := range n.Mounts {
+			mounted[id] = true
+		}
+	}
+
+	statuses := map[string]*volumeStatus{}
+	for _, v := range vols {
+		if c.volumeID != "" && !strings.EqualFold(v.ID, c.volumeID) {
+			continue
+		}
+		if c.volumeName != "" && !strings.EqualFold(v.Name, c.volumeName) {
+			continue
+		}
+		s := &volumeStatus{name: v.Name, state: volumeAvailable}
+		for _, a := range v.Attachments {
+			if a.InstanceID != nil {
+				s.instances = append(s.instances, a.InstanceID.ID)
+			}
+		}
+		sort.Strings(s.instances)
+		if mounted[v.ID] {
+			s.state = volumeMounted
+		} else if len(v.Attachments) > 0 {
+			s.state = volumeAttached
+		}
+		statuses[v.ID] = s
+	}
+	return statuses, nil
+}
+
+// volumeTransitions returns the changes between two polls of the volumes'
+// states, ordered by volume ID.
+func volumeTransitions(
+	prev, cur map[string]*volumeStatus, now time.Time) []*volumeTransition {
+
+	all := []*volumeTransition{}
+	for id, s := range cur {
+		p, ok := prev[id]
+		if ok && p.state == s.state &&
+			strings.Join(p.instances, ",") == strings.Join(s.instances, ",") {
+			continue
+		}
+		t := &volumeTransition{
+			Time:       now,
+			VolumeID:   id,
+			VolumeName: s.name,
+			To:         s.state,
+			Instances:  s.instances,
+		}
+		if ok {
+			t.From = p.state
+		}
+		all = append(all, t)
+	}
+	for id, p := range prev {
+		if _, ok := cur[id]; !ok {
+			all = append(all, &volumeTransition{
+				Time:       now,
+				VolumeID:   id,
+				VolumeName: p.name,
+				From:       p.state,
+				To:         volumeRemoved,
+			})
+		}
+	}
+	sort.Sort(byTransitionVolumeID(all))
+	return all
+}
+
+// printTransition prints a transition as it is observed. The yml output is a
+// stream of documents.
+func (c *CLI) printTransition(t *volumeTransition) {
+	out, err := c.marshalOutput(t)
+	if err != nil {
+		fatal(err)
+	}
+	if isYamlFormat(c.outputFormat) {
+		fmt.Println("---")
+	}
+	fmt.Println(strings.TrimSuffix(out, "\n"))
+}
+
+type byTransitionVolumeID []*volumeTransition
+
+func (t byTransitionVolumeID) Len() int           { return len(t) }
+func (t byTransitionVolumeID) Swap(i, j int)      { t[i], t[j] = t[j], t[i] }
+func (t byTransitionVolumeID) Less(i, j int) bool { return t[i].VolumeID < t[j].VolumeID }
@@ -0,0 +1,14 @@
+// The lsx command is the libStorage executor that is embedded in REX-Ray.
+// It includes the executors of the storage drivers compiled into REX-Ray.
+package main
+
+import (
+	"github.com/emccode/libstorage/cli/lsx"
+
+	// load the executors of the storage drivers compiled into the binary
+	_ "github.com/emccode/rexray/core/drivers/executors"
+)
+
+func main() {
+	lsx.Run()
+}
@@ -5,8 +5,10 @@ import (
 	"io"
 	"os"
 	"os/exec"
+	"path"
 	"regexp"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/akutz/gofig"
@@ -17,6 +19,13 @@ import (
 	apitypes "github.com/emccode/libstorage/api/types"
 
 	"github.com/emccode/rexray/core"
+	"github.com/emccode/rexray/core/capture"
+	"github.com/emccode/rexray/core/certs"
+	"github.com/emccode/rexray/core/discovery"
+	"github.com/emccode/rexray/core/drivers"
+	"github.com/emccode/rexray/core/ebs"
+	"github.com/emccode/rexray/core/ratelimit"
+	"github.com/emccode/rexray/core/spiffe"
 )
 
 const (
@@ -35,6 +44,10 @@ const (
 	// EnvFileName is the name of the environment file used by the SystemD
 	// service.
 	EnvFileName = "rexray.env"
+
+	// InstancesDirPath is the directory of the prefixes of the named
+	// instances of the service that are installed by root.
+	InstancesDirPath = "/var/lib/rexray/instances"
 )
 
 var (
@@ -55,10 +68,31 @@ var (
 
 func init() {
 	prefix = os.Getenv("REXRAY_HOME")
+	if name := os.Getenv("REXRAY_INSTANCE"); prefix == "" && name != "" {
+		prefix = InstancePrefix(name, os.Geteuid() != 0)
+	}
 
 	thisExeDir, thisExeName, thisExeAbsPath = gotil.GetThisPathParts()
 }
 
+// InstancePrefix returns the default prefix of an instance of the service.
+// The named instances installed by root have their prefixes in
+// InstancesDirPath, and those installed by other users, which run as the
+// users' systemd services, in ~/.rexray/instances. The unnamed instance of
+// a user is prefixed by ~/.rexray, and that of root has no prefix.
+func InstancePrefix(name string, user bool) string {
+	if !user {
+		if name == "" {
+			return ""
+		}
+		return path.Join(InstancesDirPath, name)
+	}
+	if name == "" {
+		return path.Join(gotil.HomeDir(), ".rexray")
+	}
+	return path.Join(gotil.HomeDir(), ".rexray", "instances", name)
+}
+
 // GetPrefix gets the root path to the REX-Ray data.
 func GetPrefix() string {
 	return prefix
@@ -244,8 +278,9 @@ func PrintVersion(out io.Writer) {
 	fmt.Fprintf(out, "OsArch: %s\n", core.Version.Arch)
 	fmt.Fprintf(out, "Branch: %s\n", core.Version.Branch)
 	fmt.Fprintf(out, "Commit: %s\n", core.Version.ShaLong)
-	fmt.Fprintf(out, "Formed: %s\n\n",
+	fmt.Fprintf(out, "Formed: %s\n",
 		core.Version.BuildTimestamp.Format(time.RFC1123))
+	fmt.Fprintf(out, "Driver: %s\n\n", strings.Join(drivers.Names(), ", "))
 
 	fmt.Fprintln(out, "libStorage")
 	fmt.Fprintln(out, "----------")
@@ -279,6 +314,10 @@ func WaitUntilLibStorageStopped(ctx apitypes.Context, errs <-chan error) {
 	for range errs {
 	}
 	ctx.Debug("done waiting on err chan")
+
+	// stop the processes the drivers started, ex. external drivers
+	drivers.Shutdown()
+	ctx.Debug("done shutting down drivers")
 }
 
 var localHostRX = regexp.MustCompile(
@@ -330,19 +369,33 @@ func ActivateLibStorage(
 		config.Set(apitypes.ConfigIgVolOpsMountPath, LibFilePath("volumes"))
 	}
 
+	if spiffe.Enabled(config) {
+		if err := spiffe.Configure(
+			ctx, config, LibFilePath("spiffe")); err != nil {
+			return ctx, config, nil, err
+		}
+	}
+
 	var (
 		host      string
 		err       error
 		isRunning bool
-		errs      <-chan error
 		server    apitypes.Server
 	)
 
+	if err = discovery.Configure(ctx, config); err != nil {
+		return ctx, config, nil, err
+	}
+
 	if host = config.GetString(apitypes.ConfigHost); host != "" {
 		if !config.GetBool(apitypes.ConfigEmbedded) {
 			ctx.WithField(
 				"host", host,
 			).Debug("not starting embeddded server; embedded mode disabled")
+			if err = spiffe.Connect(
+				ctx, config, LibFilePath("spiffe")); err != nil {
+				return ctx, config, nil, err
+			}
 			return ctx, config, nil, nil
 		}
 	}
@@ -362,11 +415,45 @@ func ActivateLibStorage(
 		}
 	}
 
+	if err = ebs.ValidateCredentials(config); err != nil {
+		return ctx, config, nil, err
+	}
+	capture.Install(ctx, config)
+	if err = ebs.AssumeRoles(ctx, config, embedded.Restart); err != nil {
+		return ctx, config, nil, err
+	}
+	if err = ebs.UseInstanceProfile(
+		ctx, config, embedded.Restart); err != nil {
+		return ctx, config, nil, err
+	}
+
+	var getCert certs.GetCertificateFunc
+	if certSource != nil {
+		if getCert, err = certSource(ctx, config); err != nil {
+			return ctx, config, nil, err
+		}
+	}
+
+	// the front serves the endpoints so that the requests are admitted by
+	// the server's policies, since the libStorage server has no means of
+	// adding handlers. It also serves the endpoints' sockets that were passed
+	// by systemd, since the server only listens on its own, and terminates
+	// TLS, since the server reads its certificates only once.
+	front, err := ratelimit.Prepare(ctx, config, RunDirPath(), getCert)
+	if err != nil {
+		return ctx, config, nil, err
+	}
+
 	ctx.Debug("starting embedded libStorage server")
 
 	apiserver.CloseOnAbort()
 
-	if server, errs, err = apiserver.Serve(ctx, config); err != nil {
+	if server, err = embedded.serve(ctx, config, front); err != nil {
+		return ctx, config, nil, err
+	}
+	errs := embedded.errs
+
+	if err = front.Serve(ctx); err != nil {
 		return ctx, config, nil, err
 	}
 
@@ -377,8 +464,32 @@ func ActivateLibStorage(
 	}()
 
 	if host == "" {
-		config.Set(apitypes.ConfigHost, server.Addrs()[0])
+		if host = front.Host(); host == "" {
+			host = server.Addrs()[0]
+		}
+		config.Set(apitypes.ConfigHost, host)
+	}
+
+	// the server's configuration was copied when it started, so the client
+	// may be directed through the tunnel without affecting the server
+	if err = spiffe.Connect(ctx, config, LibFilePath("spiffe")); err != nil {
+		return ctx, config, errs, err
 	}
 
 	return ctx, config, errs, nil
 }
+
+// CertificateSource returns the function that gets the certificate with
+// which the front serves the libStorage endpoints, or nil if the endpoints are
+// served with the certificates in their configuration.
+type CertificateSource func(
+	ctx apitypes.Context, config gofig.Config) (certs.GetCertificateFunc, error)
+
+var certSource CertificateSource
+
+// RegisterCertificateSource registers the source of the certificate of the
+// libStorage endpoints. The daemon registers the source that obtains it from
+// an ACME certificate authority.
+func RegisterCertificateSource(s CertificateSource) {
+	certSource = s
+}
@@ -0,0 +1,141 @@
+package util
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"github.com/akutz/gofig"
+	"github.com/akutz/goof"
+	apiserver "github.com/emccode/libstorage/api/server"
+	apitypes "github.com/emccode/libstorage/api/types"
+
+	"github.com/emccode/rexray/core/ratelimit"
+)
+
+// drainPeriod is how long a libStorage server that was replaced by another
+// keeps serving the requests that were proxied to it before it is closed.
+const drainPeriod = 2 * time.Minute
+
+// embedded is the process's embedded libStorage server.
+var embedded = &embeddedServer{}
+
+// RestartLibStorage restarts the embedded libStorage server with a copy of
+// its configuration that is modified by update. The server's requests are
+// directed to the new server before the running one is closed.
+func RestartLibStorage(update func(config gofig.Config)) error {
+	return embedded.Restart(update)
+}
+
+// embeddedServer is the embedded libStorage server behind the front. The
+// server's drivers read their configuration only when they are initialized,
+// so the server is replaced by another when its configuration changes rather
+// than having its configuration changed while it runs.
+type embeddedServer struct {
+	sync.Mutex
+	ctx    apitypes.Context
+	front  *ratelimit.Front
+	config gofig.Config
+	server apitypes.Server
+
+	// errs receives the errors of the current server, and is closed when
+	// the current server stops.
+	errs chan error
+}
+
+// serve starts the server with the provided configuration.
+func (s *embeddedServer) serve(
+	ctx apitypes.Context,
+	config gofig.Config,
+	front *ratelimit.Front) (apitypes.Server, error) {
+
+	s.Lock()
+	defer s.Unlock()
+
+	// the copy is made before the server is started since the server's
+	// configuration is also the daemon's, which is changed once it starts
+	base, err := config.Copy()
+	if err != nil {
+		return nil, err
+	}
+	server, errs, err := apiserver.Serve(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	s.ctx, s.front, s.config, s.server = ctx, front, base, server
+	s.errs = make(chan error)
+	go s.forward(server, errs)
+	return server, nil
+}
+
+// Restart starts another server with a copy of the running server's
+// configuration that is modified by update, and directs the front's requests
+// to it. The running server is closed once the requests that were already
+// proxied to it have had time to complete.
+func (s *embeddedServer) Restart(update func(config gofig.Config)) error {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.server == nil {
+		return goof.New("libStorage server not started")
+	}
+	if s.front.Host() == "" {
+		return goof.New("libStorage server is not behind the front")
+	}
+	closer, ok := s.server.(io.Closer)
+	if !ok {
+		return goof.New("libStorage server cannot be closed")
+	}
+
+	config, err := s.config.Copy()
+	if err != nil {
+		return err
+	}
+	update(config)
+	commit := s.front.Rebind(config)
+	base, err := config.Copy()
+	if err != nil {
+		return err
+	}
+
+	server, errs, err := apiserver.Serve(s.ctx, config)
+	if err != nil {
+		return err
+	}
+	commit()
+	s.config, s.server = base, server
+	go s.forward(server, errs)
+
+	ctx := s.ctx
+	time.AfterFunc(drainPeriod, func() {
+		if err := closer.Close(); err != nil {
+			ctx.WithError(err).Warn("error closing replaced libStorage server")
+			return
+		}
+		ctx.Debug("closed replaced libStorage server")
+	})
+	ctx.Info("restarted libStorage server")
+	return nil
+}
+
+// forward passes the errors of a server to the embedded server's errors
+// while it is the current server, and closes them when it stops unless it
+// was replaced.
+func (s *embeddedServer) forward(server apitypes.Server, errs <-chan error) {
+	for err := range errs {
+		if s.isCurrent(server) {
+			s.errs <- err
+		} else if err != nil {
+			s.ctx.WithError(err).Debug("replaced libStorage server stopped")
+		}
+	}
+	if s.isCurrent(server) {
+		close(s.errs)
+	}
+}
+
+func (s *embeddedServer) isCurrent(server apitypes.Server) bool {
+	s.Lock()
+	defer s.Unlock()
+	return s.server == server
+}